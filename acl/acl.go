@@ -0,0 +1,373 @@
+// Package acl converts the firewall rules of the network map into the rules enforced by the peer firewall.
+// It is shared by the client applying the rules and the Management service simulating them, so that both
+// evaluate the traffic the same way
+package acl
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/FlintyLemming/netbird/client/ssh"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// anyIP is the special peer address of the rules matching all the peers
+const anyIP = "0.0.0.0"
+
+// Rule is a rule of the peer firewall matching the packets of one direction
+type Rule struct {
+	// PeerIP is the address of the remote peer, 0.0.0.0 matches all the peers
+	PeerIP net.IP
+	// Direction is the direction of the matched packets
+	Direction mgmProto.FirewallRuleDirection
+	Action    mgmProto.FirewallRuleAction
+	Protocol  mgmProto.FirewallRuleProtocol
+	// SrcPort is the source port of the matched packets, 0 matches all the ports
+	SrcPort int
+	// SrcPortEnd is the last port of the range of source ports starting at SrcPort, 0 for a single port
+	SrcPortEnd int
+	// DstPort is the destination port of the matched packets, 0 matches all the ports
+	DstPort int
+	// DstPortEnd is the last port of the range of destination ports starting at DstPort, 0 for a single port
+	DstPortEnd int
+	// Origin is the firewall rule of the network map the rule is created from
+	Origin *mgmProto.FirewallRule
+}
+
+// Packet is the first packet of a connection between the peer and a remote peer
+type Packet struct {
+	// PeerIP is the address of the remote peer
+	PeerIP net.IP
+	// Direction is IN for the packets received by the peer and OUT for the packets sent by it
+	Direction mgmProto.FirewallRuleDirection
+	Protocol  mgmProto.FirewallRuleProtocol
+	SrcPort   int
+	DstPort   int
+}
+
+// FromLegacyManagement returns true when the network map is sent by a Management service without firewall rules
+// support, in which case all the traffic is allowed
+func FromLegacyManagement(networkMap *mgmProto.NetworkMap) bool {
+	return len(networkMap.FirewallRules) == 0 && !networkMap.FirewallRulesIsEmpty
+}
+
+// NetworkMapRules returns the firewall rules of the network map applied by the peer: the rules accepting a protocol
+// from all the peers are squashed, the SSH port is opened when the SSH server is enabled and all the traffic is
+// accepted when the network map comes from a legacy Management service
+func NetworkMapRules(networkMap *mgmProto.NetworkMap) []*mgmProto.FirewallRule {
+	rules, squashedProtocols := SquashAcceptRules(networkMap)
+
+	enableSSH := (networkMap.PeerConfig != nil &&
+		networkMap.PeerConfig.SshConfig != nil &&
+		networkMap.PeerConfig.SshConfig.SshEnabled)
+	if _, ok := squashedProtocols[mgmProto.FirewallRule_ALL]; ok {
+		enableSSH = enableSSH && !ok
+	}
+	if _, ok := squashedProtocols[mgmProto.FirewallRule_TCP]; ok {
+		enableSSH = enableSSH && !ok
+	}
+
+	// if TCP protocol rules not squashed and SSH enabled
+	// we add default firewall rule which accepts connection to any peer
+	// in the network by SSH (TCP 22 port).
+	if enableSSH {
+		rules = append(rules, &mgmProto.FirewallRule{
+			PeerIP:    anyIP,
+			Direction: mgmProto.FirewallRule_IN,
+			Action:    mgmProto.FirewallRule_ACCEPT,
+			Protocol:  mgmProto.FirewallRule_TCP,
+			Port:      strconv.Itoa(ssh.DefaultSSHPort),
+		})
+	}
+
+	// if we got empty rules list but management not set networkMap.FirewallRulesIsEmpty flag
+	// we have old version of management without rules handling, we should allow all traffic
+	if FromLegacyManagement(networkMap) {
+		rules = append(rules,
+			&mgmProto.FirewallRule{
+				PeerIP:    anyIP,
+				Direction: mgmProto.FirewallRule_IN,
+				Action:    mgmProto.FirewallRule_ACCEPT,
+				Protocol:  mgmProto.FirewallRule_ALL,
+			},
+			&mgmProto.FirewallRule{
+				PeerIP:    anyIP,
+				Direction: mgmProto.FirewallRule_OUT,
+				Action:    mgmProto.FirewallRule_ACCEPT,
+				Protocol:  mgmProto.FirewallRule_ALL,
+			},
+		)
+	}
+
+	return rules
+}
+
+// ExpandRule returns the rules added to the peer firewall for a firewall rule of the network map. The rule matches
+// the packets opening the connections in its direction and, when it has a port, it comes with an inverted rule
+// matching the replies
+func ExpandRule(r *mgmProto.FirewallRule) ([]Rule, error) {
+	ip := net.ParseIP(r.PeerIP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address")
+	}
+
+	switch r.Protocol {
+	case mgmProto.FirewallRule_ALL, mgmProto.FirewallRule_TCP, mgmProto.FirewallRule_UDP, mgmProto.FirewallRule_ICMP,
+		mgmProto.FirewallRule_SCTP, mgmProto.FirewallRule_GRE:
+	default:
+		return nil, fmt.Errorf("invalid protocol type: %s", r.Protocol.String())
+	}
+
+	switch r.Action {
+	case mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_DROP:
+	default:
+		return nil, fmt.Errorf("invalid action type: %d", r.Action)
+	}
+
+	port, portEnd, err := ParsePort(r.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	var inverted mgmProto.FirewallRuleDirection
+	switch r.Direction {
+	case mgmProto.FirewallRule_IN:
+		inverted = mgmProto.FirewallRule_OUT
+	case mgmProto.FirewallRule_OUT:
+		inverted = mgmProto.FirewallRule_IN
+	default:
+		return nil, fmt.Errorf("invalid direction")
+	}
+
+	rules := []Rule{{
+		PeerIP:     ip,
+		Direction:  r.Direction,
+		Action:     r.Action,
+		Protocol:   r.Protocol,
+		DstPort:    port,
+		DstPortEnd: portEnd,
+		Origin:     r,
+	}}
+
+	if shouldSkipInvertedRule(r.Protocol, port) {
+		return rules, nil
+	}
+
+	return append(rules, Rule{
+		PeerIP:     ip,
+		Direction:  inverted,
+		Action:     r.Action,
+		Protocol:   r.Protocol,
+		SrcPort:    port,
+		SrcPortEnd: portEnd,
+		Origin:     r,
+	}), nil
+}
+
+// ParsePort parses the port of a firewall rule, either a single port or a range of ports (e.g. 8000-8080). The end
+// of the range is 0 for a single port and both values are 0 when the rule has no port
+func ParsePort(port string) (int, int, error) {
+	if port == "" {
+		return 0, 0, nil
+	}
+
+	startValue, endValue, isRange := strings.Cut(port, "-")
+	start, err := strconv.Atoi(startValue)
+	if err != nil || start < 1 || start > 65535 {
+		return 0, 0, fmt.Errorf("invalid port")
+	}
+	if !isRange {
+		return start, 0, nil
+	}
+
+	end, err := strconv.Atoi(endValue)
+	if err != nil || end <= start || end > 65535 {
+		return 0, 0, fmt.Errorf("invalid port range")
+	}
+	return start, end, nil
+}
+
+func shouldSkipInvertedRule(protocol mgmProto.FirewallRuleProtocol, port int) bool {
+	return protocol == mgmProto.FirewallRule_ALL || protocol == mgmProto.FirewallRule_ICMP ||
+		protocol == mgmProto.FirewallRule_GRE || port == 0
+}
+
+// Match returns the rule deciding the verdict of the packet, or nil when no rule matches and the packet is dropped
+// by default. Like in the userspace firewall, the rules of the remote peer address are evaluated before the rules
+// matching all the peers and the first matching rule wins
+func Match(rules []Rule, packet Packet) *Rule {
+	if rule := matchFirst(rules, packet, func(ip net.IP) bool { return ip.Equal(packet.PeerIP) }); rule != nil {
+		return rule
+	}
+	return matchFirst(rules, packet, func(ip net.IP) bool { return ip.String() == anyIP })
+}
+
+func matchFirst(rules []Rule, packet Packet, matchIP func(net.IP) bool) *Rule {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Direction != packet.Direction || !matchIP(rule.PeerIP) {
+			continue
+		}
+
+		if rule.Protocol == mgmProto.FirewallRule_ALL {
+			return rule
+		}
+		if rule.Protocol != packet.Protocol {
+			continue
+		}
+
+		switch {
+		case rule.Protocol == mgmProto.FirewallRule_ICMP, rule.Protocol == mgmProto.FirewallRule_GRE:
+			return rule
+		case rule.SrcPort == 0 && rule.DstPort == 0:
+			return rule
+		case rule.SrcPort != 0 && matchPort(rule.SrcPort, rule.SrcPortEnd, packet.SrcPort):
+			return rule
+		case rule.DstPort != 0 && matchPort(rule.DstPort, rule.DstPortEnd, packet.DstPort):
+			return rule
+		}
+	}
+	return nil
+}
+
+func matchPort(start, end, port int) bool {
+	if end == 0 {
+		return start == port
+	}
+	return port >= start && port <= end
+}
+
+// SquashAcceptRules does complex logic to convert many rules which allows connection by traffic type
+// to all peers in the network map to one rule which just accepts that type of the traffic.
+//
+// NOTE: It will not squash two rules for same protocol if one covers all peers in the network,
+// but other has port definitions or has drop policy.
+func SquashAcceptRules(
+	networkMap *mgmProto.NetworkMap,
+) ([]*mgmProto.FirewallRule, map[mgmProto.FirewallRuleProtocol]struct{}) {
+	totalIPs := 0
+	for _, p := range append(networkMap.RemotePeers, networkMap.OfflinePeers...) {
+		for range p.AllowedIps {
+			totalIPs++
+		}
+	}
+
+	type protoMatch map[mgmProto.FirewallRuleProtocol]map[string]int
+
+	in := protoMatch{}
+	out := protoMatch{}
+
+	// trace which type of protocols was squashed
+	squashedRules := []*mgmProto.FirewallRule{}
+	squashedProtocols := map[mgmProto.FirewallRuleProtocol]struct{}{}
+
+	// this function we use to do calculation, can we squash the rules by protocol or not.
+	// We summ amount of Peers IP for given protocol we found in original rules list.
+	// But we zeroed the IP's for protocol if:
+	// 1. Any of the rule has DROP action type.
+	// 2. Any of rule contains Port.
+	//
+	// We zeroed this to notify squash function that this protocol can't be squashed.
+	addRuleToCalculationMap := func(i int, r *mgmProto.FirewallRule, protocols protoMatch) {
+		drop := r.Action == mgmProto.FirewallRule_DROP || r.Port != ""
+		if drop {
+			protocols[r.Protocol] = map[string]int{}
+			return
+		}
+		if _, ok := protocols[r.Protocol]; !ok {
+			protocols[r.Protocol] = map[string]int{}
+		}
+
+		// special case, when we receive this all network IP address
+		// it means that rules for that protocol was already optimized on the
+		// management side
+		if r.PeerIP == anyIP {
+			squashedRules = append(squashedRules, r)
+			squashedProtocols[r.Protocol] = struct{}{}
+			return
+		}
+
+		ipset := protocols[r.Protocol]
+
+		if _, ok := ipset[r.PeerIP]; ok {
+			return
+		}
+		ipset[r.PeerIP] = i
+	}
+
+	for i, r := range networkMap.FirewallRules {
+		// calculate squash for different directions
+		if r.Direction == mgmProto.FirewallRule_IN {
+			addRuleToCalculationMap(i, r, in)
+		} else {
+			addRuleToCalculationMap(i, r, out)
+		}
+	}
+
+	// order of squashing by protocol is important
+	// only for their first element ALL, it must be done first
+	protocolOrders := []mgmProto.FirewallRuleProtocol{
+		mgmProto.FirewallRule_ALL,
+		mgmProto.FirewallRule_ICMP,
+		mgmProto.FirewallRule_TCP,
+		mgmProto.FirewallRule_UDP,
+		mgmProto.FirewallRule_SCTP,
+		mgmProto.FirewallRule_GRE,
+	}
+
+	squash := func(matches protoMatch, direction mgmProto.FirewallRuleDirection) {
+		for _, protocol := range protocolOrders {
+			if ipset, ok := matches[protocol]; !ok || len(ipset) != totalIPs || len(ipset) < 2 {
+				// don't squash if :
+				// 1. Rules not cover all peers in the network
+				// 2. Rules cover only one peer in the network.
+				continue
+			}
+
+			// add special rule 0.0.0.0 which allows all IP's in our firewall implementations
+			squashedRules = append(squashedRules, &mgmProto.FirewallRule{
+				PeerIP:    anyIP,
+				Direction: direction,
+				Action:    mgmProto.FirewallRule_ACCEPT,
+				Protocol:  protocol,
+			})
+			squashedProtocols[protocol] = struct{}{}
+
+			if protocol == mgmProto.FirewallRule_ALL {
+				// if we have ALL traffic type squashed rule
+				// it allows all other type of traffic, so we can stop processing
+				break
+			}
+		}
+	}
+
+	squash(in, mgmProto.FirewallRule_IN)
+	squash(out, mgmProto.FirewallRule_OUT)
+
+	// if all protocol was squashed everything is allow and we can ignore all other rules
+	if _, ok := squashedProtocols[mgmProto.FirewallRule_ALL]; ok {
+		return squashedRules, squashedProtocols
+	}
+
+	if len(squashedRules) == 0 {
+		return networkMap.FirewallRules, squashedProtocols
+	}
+
+	var rules []*mgmProto.FirewallRule
+	// filter out rules which was squashed from final list
+	// if we also have other not squashed rules.
+	for i, r := range networkMap.FirewallRules {
+		if _, ok := squashedProtocols[r.Protocol]; ok {
+			if m, ok := in[r.Protocol]; ok && m[r.PeerIP] == i {
+				continue
+			} else if m, ok := out[r.Protocol]; ok && m[r.PeerIP] == i {
+				continue
+			}
+		}
+		rules = append(rules, r)
+	}
+
+	return append(rules, squashedRules...), squashedProtocols
+}
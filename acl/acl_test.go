@@ -0,0 +1,180 @@
+package acl
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/client/ssh"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+func TestExpandRule(t *testing.T) {
+	rule := &mgmProto.FirewallRule{
+		PeerIP:    "100.64.0.2",
+		Direction: mgmProto.FirewallRule_IN,
+		Action:    mgmProto.FirewallRule_ACCEPT,
+		Protocol:  mgmProto.FirewallRule_TCP,
+		Port:      "80",
+	}
+
+	rules, err := ExpandRule(rule)
+	require.NoError(t, err)
+	require.Len(t, rules, 2, "a rule with a port should come with an inverted rule")
+
+	assert.Equal(t, mgmProto.FirewallRule_IN, rules[0].Direction)
+	assert.Equal(t, 80, rules[0].DstPort)
+	assert.Equal(t, 0, rules[0].SrcPort)
+	assert.Equal(t, mgmProto.FirewallRule_OUT, rules[1].Direction)
+	assert.Equal(t, 80, rules[1].SrcPort)
+	assert.Equal(t, 0, rules[1].DstPort)
+	assert.Same(t, rule, rules[1].Origin)
+
+	rules, err = ExpandRule(&mgmProto.FirewallRule{
+		PeerIP:    "100.64.0.2",
+		Direction: mgmProto.FirewallRule_OUT,
+		Action:    mgmProto.FirewallRule_DROP,
+		Protocol:  mgmProto.FirewallRule_ICMP,
+	})
+	require.NoError(t, err)
+	assert.Len(t, rules, 1, "ICMP rules should not be inverted")
+
+	rules, err = ExpandRule(&mgmProto.FirewallRule{
+		PeerIP:    "100.64.0.2",
+		Direction: mgmProto.FirewallRule_IN,
+		Action:    mgmProto.FirewallRule_ACCEPT,
+		Protocol:  mgmProto.FirewallRule_GRE,
+	})
+	require.NoError(t, err)
+	assert.Len(t, rules, 1, "GRE rules should not be inverted")
+
+	rules, err = ExpandRule(&mgmProto.FirewallRule{
+		PeerIP:    "100.64.0.2",
+		Direction: mgmProto.FirewallRule_IN,
+		Action:    mgmProto.FirewallRule_ACCEPT,
+		Protocol:  mgmProto.FirewallRule_SCTP,
+		Port:      "3868",
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 2, "SCTP rules with a port should come with an inverted rule like TCP")
+	assert.Equal(t, 3868, rules[1].SrcPort)
+
+	rules, err = ExpandRule(&mgmProto.FirewallRule{
+		PeerIP:    "100.64.0.2",
+		Direction: mgmProto.FirewallRule_IN,
+		Action:    mgmProto.FirewallRule_ACCEPT,
+		Protocol:  mgmProto.FirewallRule_TCP,
+		Port:      "8000-8080",
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, 8000, rules[0].DstPort)
+	assert.Equal(t, 8080, rules[0].DstPortEnd)
+	assert.Equal(t, 8000, rules[1].SrcPort)
+	assert.Equal(t, 8080, rules[1].SrcPortEnd)
+
+	invalid := []*mgmProto.FirewallRule{
+		{PeerIP: "invalid", Protocol: mgmProto.FirewallRule_TCP},
+		{PeerIP: "100.64.0.2", Protocol: mgmProto.FirewallRule_UNKNOWN},
+		{PeerIP: "100.64.0.2", Protocol: mgmProto.FirewallRule_TCP, Port: "http"},
+		{PeerIP: "100.64.0.2", Protocol: mgmProto.FirewallRule_TCP, Port: "8080-8000"},
+		{PeerIP: "100.64.0.2", Protocol: mgmProto.FirewallRule_TCP, Port: "8000-"},
+	}
+	for _, r := range invalid {
+		_, err := ExpandRule(r)
+		assert.Error(t, err, "rule %+v should be invalid", r)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var rules []Rule
+	for _, r := range []*mgmProto.FirewallRule{
+		{PeerIP: "0.0.0.0", Direction: mgmProto.FirewallRule_IN, Action: mgmProto.FirewallRule_ACCEPT, Protocol: mgmProto.FirewallRule_TCP, Port: "22"},
+		{PeerIP: "100.64.0.2", Direction: mgmProto.FirewallRule_IN, Action: mgmProto.FirewallRule_DROP, Protocol: mgmProto.FirewallRule_TCP, Port: "22"},
+		{PeerIP: "100.64.0.2", Direction: mgmProto.FirewallRule_IN, Action: mgmProto.FirewallRule_ACCEPT, Protocol: mgmProto.FirewallRule_UDP},
+		{PeerIP: "100.64.0.3", Direction: mgmProto.FirewallRule_IN, Action: mgmProto.FirewallRule_ACCEPT, Protocol: mgmProto.FirewallRule_TCP, Port: "8000-8080"},
+	} {
+		expanded, err := ExpandRule(r)
+		require.NoError(t, err)
+		rules = append(rules, expanded...)
+	}
+
+	testCases := []struct {
+		name   string
+		packet Packet
+		action mgmProto.FirewallRuleAction
+		noRule bool
+	}{
+		{
+			name:   "peer rule evaluated before the rules of all the peers",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.2"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 40000, DstPort: 22},
+			action: mgmProto.FirewallRule_DROP,
+		},
+		{
+			name:   "rule of all the peers",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.3"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 40000, DstPort: 22},
+			action: mgmProto.FirewallRule_ACCEPT,
+		},
+		{
+			name:   "reply matched by the inverted rule",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.3"), Direction: mgmProto.FirewallRule_OUT, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 22, DstPort: 40000},
+			action: mgmProto.FirewallRule_ACCEPT,
+		},
+		{
+			name:   "rule without port",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.2"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_UDP, SrcPort: 40000, DstPort: 53},
+			action: mgmProto.FirewallRule_ACCEPT,
+		},
+		{
+			name:   "other port dropped by default",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.3"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 40000, DstPort: 80},
+			noRule: true,
+		},
+		{
+			name:   "port in a range",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.3"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 40000, DstPort: 8042},
+			action: mgmProto.FirewallRule_ACCEPT,
+		},
+		{
+			name:   "port after a range dropped by default",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.3"), Direction: mgmProto.FirewallRule_IN, Protocol: mgmProto.FirewallRule_TCP, SrcPort: 40000, DstPort: 8081},
+			noRule: true,
+		},
+		{
+			name:   "other direction dropped by default",
+			packet: Packet{PeerIP: net.ParseIP("100.64.0.2"), Direction: mgmProto.FirewallRule_OUT, Protocol: mgmProto.FirewallRule_UDP, SrcPort: 40000, DstPort: 53},
+			noRule: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			rule := Match(rules, testCase.packet)
+			if testCase.noRule {
+				assert.Nil(t, rule)
+				return
+			}
+			require.NotNil(t, rule)
+			assert.Equal(t, testCase.action, rule.Action)
+		})
+	}
+}
+
+func TestNetworkMapRules(t *testing.T) {
+	networkMap := &mgmProto.NetworkMap{
+		PeerConfig: &mgmProto.PeerConfig{SshConfig: &mgmProto.SSHConfig{SshEnabled: true}},
+	}
+
+	rules := NetworkMapRules(networkMap)
+	require.Len(t, rules, 3, "a legacy network map should allow all the traffic besides SSH")
+	assert.Equal(t, strconv.Itoa(ssh.DefaultSSHPort), rules[0].Port)
+	assert.Equal(t, mgmProto.FirewallRule_ALL, rules[1].Protocol)
+	assert.Equal(t, mgmProto.FirewallRule_ALL, rules[2].Protocol)
+
+	networkMap.FirewallRulesIsEmpty = true
+	networkMap.PeerConfig.SshConfig.SshEnabled = false
+	assert.Empty(t, NetworkMapRules(networkMap))
+}
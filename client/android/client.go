@@ -3,6 +3,7 @@ package android
 import (
 	"context"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -21,6 +22,11 @@ type ConnectionListener interface {
 	peer.Listener
 }
 
+// AttentionListener export internal AttentionListener for mobile
+type AttentionListener interface {
+	peer.AttentionListener
+}
+
 // TunAdapter export internal TunAdapter for mobile
 type TunAdapter interface {
 	iface.TunAdapter
@@ -156,6 +162,11 @@ func (c *Client) PeersList() *PeerInfoArray {
 	return &PeerInfoArray{items: peerInfos}
 }
 
+// IsLoginExpired returns true if management limited the network access until the user logs in again
+func (c *Client) IsLoginExpired() bool {
+	return c.recorder.IsLoginExpired()
+}
+
 // OnUpdatedHostDNS update the DNS servers addresses for root zones
 func (c *Client) OnUpdatedHostDNS(list *DNSList) error {
 	dnsServer, err := dns.GetServerDns()
@@ -176,3 +187,19 @@ func (c *Client) SetConnectionListener(listener ConnectionListener) {
 func (c *Client) RemoveConnectionListener() {
 	c.recorder.RemoveConnectionListener()
 }
+
+// SetAttentionListener set the listener notified when the client requires the attention of the user
+func (c *Client) SetAttentionListener(listener AttentionListener) {
+	c.recorder.SetAttentionListener(listener)
+}
+
+// RemoveAttentionListener remove attention listener
+func (c *Client) RemoveAttentionListener() {
+	c.recorder.RemoveAttentionListener()
+}
+
+// SetManagementUnreachableMinutes set the minutes without a connection to the Management service after which the
+// attention listener is notified
+func (c *Client) SetManagementUnreachableMinutes(minutes int) {
+	c.recorder.SetManagementUnreachableThreshold(time.Duration(minutes) * time.Minute)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/client/internal/appliance"
+)
+
+const (
+	applianceFlag = "appliance"
+	stateDirFlag  = "state-dir"
+)
+
+var (
+	applianceMode bool
+	stateDir      string
+)
+
+// applyApplianceMode applies the uci config and the defaults of the router appliance mode, then moves the config of
+// the client, and the state stored next to it, to the state directory. It is called after the environment variables
+// are read, both take precedence over the uci config
+func applyApplianceMode(cmd *cobra.Command) error {
+	if applianceMode {
+		if appliance.IsOpenWrt() {
+			if err := applyUCIConfig(cmd, appliance.UCIConfigFile); err != nil {
+				return err
+			}
+		}
+
+		appliance.TuneMemory()
+
+		// procd forwards the output to the system log, writing the log file would wear the flash
+		if !rootCmd.PersistentFlags().Changed("log-file") {
+			logFile = "console"
+		}
+		if stateDir == "" {
+			stateDir = appliance.DefaultStateDir
+		}
+	}
+
+	if stateDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(stateDir, 0700); err != nil {
+		return fmt.Errorf("create state directory: %w", err)
+	}
+	if !rootCmd.PersistentFlags().Changed("config") {
+		configPath = filepath.Join(stateDir, "config.json")
+	}
+	return nil
+}
+
+// applyUCIConfig sets the flags of the command from the options of the uci config, the option names are the flag
+// names with underscores instead of dashes. The flags already set are kept
+func applyUCIConfig(cmd *cobra.Command, path string) error {
+	options, err := appliance.ReadUCIOptions(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read uci config: %w", err)
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flagName := strings.ReplaceAll(name, "_", "-")
+		flag := cmd.Flags().Lookup(flagName)
+		if flag == nil {
+			// e.g. the enabled option of the init script or the flags of other commands
+			log.Debugf("skipping uci option %s, the command has no flag %s", name, flagName)
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(flagName, options[name]); err != nil {
+			return fmt.Errorf("invalid uci option %s: %w", name, err)
+		}
+	}
+	return nil
+}
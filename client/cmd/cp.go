@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/client/internal"
+	nbssh "github.com/FlintyLemming/netbird/client/ssh"
+	"github.com/FlintyLemming/netbird/util"
+)
+
+var (
+	cpPort   int
+	cpResume bool
+)
+
+// remoteFile is a file on a remote peer in the [user@]host:path form
+type remoteFile struct {
+	user string
+	host string
+	path string
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp <source> <destination>",
+	Short: "copy a file from or to a remote peer",
+	Long: "Copies a file between this machine and a remote peer through the NetBird SSH server of the remote peer.\n" +
+		"Remote files are specified as [user@]host:path, relative paths start in the user's home directory.\n\n" +
+		"E.g. netbird cp backup.tar.gz peer.netbird.cloud:/srv/backups/ or netbird cp root@100.64.0.10:/var/log/syslog .",
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		SetFlagsFromEnvVars(rootCmd)
+		SetFlagsFromEnvVars(cmd)
+
+		cmd.SetOut(cmd.OutOrStdout())
+
+		err := util.InitLog(logLevel, "console")
+		if err != nil {
+			return fmt.Errorf("failed initializing log %v", err)
+		}
+
+		source, sourceIsRemote := parseRemoteFile(args[0])
+		destination, destinationIsRemote := parseRemoteFile(args[1])
+		if sourceIsRemote == destinationIsRemote {
+			return errors.New("exactly one of source and destination must be a remote file in the [user@]host:path form")
+		}
+
+		if !util.IsAdmin() {
+			cmd.Printf("error: you must have Administrator privileges to run this command\n")
+			return nil
+		}
+
+		config, err := internal.UpdateConfig(internal.ConfigInput{
+			ConfigPath: configPath,
+		})
+		if err != nil {
+			return err
+		}
+
+		remote := destination
+		if sourceIsRemote {
+			remote = source
+		}
+
+		c, err := nbssh.DialWithKey(fmt.Sprintf("%s:%d", remote.host, cpPort), remote.user, []byte(config.SSHKey))
+		if err != nil {
+			cmd.Printf("Error: %v\n", err)
+			cmd.Printf("Couldn't connect. Please check the connection status or if the ssh server is enabled on the other peer" +
+				"You can verify the connection by running:\n\n" +
+				" netbird status\n\n")
+			return err
+		}
+		defer func() {
+			_ = c.Close()
+		}()
+
+		if sourceIsRemote {
+			progress := newCpProgress(cmd, filepath.Base(source.path))
+			err = c.Download(source.path, args[1], cpResume, progress.update)
+			progress.done()
+		} else {
+			progress := newCpProgress(cmd, filepath.Base(args[0]))
+			err = c.Upload(args[0], destination.path, cpResume, progress.update)
+			progress.done()
+		}
+		return err
+	},
+}
+
+// parseRemoteFile parses a [user@]host:path argument. It returns false for local paths
+func parseRemoteFile(arg string) (remoteFile, bool) {
+	host, filePath, found := strings.Cut(arg, ":")
+	if !found || host == "" || strings.ContainsAny(host, `/\`) {
+		return remoteFile{}, false
+	}
+
+	// C:\file is a local path on Windows
+	if runtime.GOOS == "windows" && len(host) == 1 {
+		return remoteFile{}, false
+	}
+
+	remote := remoteFile{user: "root", host: host, path: filePath}
+	if u, h, ok := strings.Cut(host, "@"); ok {
+		remote.user = u
+		remote.host = h
+	}
+	return remote, true
+}
+
+// cpProgress prints the progress of a copy on a single line
+type cpProgress struct {
+	cmd     *cobra.Command
+	name    string
+	percent int64
+	printed bool
+}
+
+func newCpProgress(cmd *cobra.Command, name string) *cpProgress {
+	return &cpProgress{cmd: cmd, name: name, percent: -1}
+}
+
+func (p *cpProgress) update(transferred, total int64) {
+	percent := int64(100)
+	if total > 0 {
+		percent = transferred * 100 / total
+	}
+	if percent == p.percent {
+		return
+	}
+	p.percent = percent
+	p.printed = true
+	p.cmd.Printf("\r%s %3d%% %s / %s", p.name, percent, formatBytes(transferred), formatBytes(total))
+}
+
+func (p *cpProgress) done() {
+	if p.printed {
+		p.cmd.Println()
+	}
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	cpCmd.PersistentFlags().IntVarP(&cpPort, "port", "p", nbssh.DefaultSSHPort, "Sets remote SSH port. Defaults to "+fmt.Sprint(nbssh.DefaultSSHPort))
+	cpCmd.PersistentFlags().BoolVar(&cpResume, "resume", false, "Resumes an interrupted copy, bytes already present in the destination file are not sent again")
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var (
+	logLevelFor      time.Duration
+	logsLines        int
+	profilingAddress string
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Debugging commands",
+	Long:  "Commands to diagnose issues of the daemon without restarting it",
+}
+
+var logLevelCmd = &cobra.Command{
+	Use:   "log-level",
+	Short: "Manage the log level of the daemon",
+}
+
+var logLevelSetCmd = &cobra.Command{
+	Use:   "set <level>",
+	Short: "Change the log level of the daemon at runtime",
+	Long: "Change the log level of the daemon without restarting it. With --for the previous level is restored once " +
+		"the duration elapses, which avoids filling the disk with debug logs when diagnosing intermittent issues",
+	Example: "  netbird debug log-level set debug --for 10m\n" +
+		"  netbird debug log-level set info",
+	Args: cobra.ExactArgs(1),
+	RunE: setLogLevel,
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the latest log lines kept in memory by the daemon",
+	Long: "Show the latest log lines of the daemon, kept in memory regardless of the log file. " +
+		"Only the lines logged at the level in place when they were written are kept",
+	Example: "  netbird debug logs --lines 200",
+	RunE:    showLogs,
+}
+
+var profilingCmd = &cobra.Command{
+	Use:   "profiling <on|off>",
+	Short: "Serve the pprof and expvar endpoints of the daemon",
+	Long: "Serve the pprof and expvar endpoints of the daemon on a loopback address, or stop serving them. " +
+		"The profiles of a daemon using too much CPU or memory can then be captured with go tool pprof. " +
+		"The endpoints are disabled when the daemon restarts",
+	Example: "  netbird debug profiling on\n" +
+		"  go tool pprof http://127.0.0.1:6060/debug/pprof/profile?seconds=30\n" +
+		"  netbird debug profiling off",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"on", "off"},
+	RunE:      setProfiling,
+}
+
+func init() {
+	logLevelSetCmd.Flags().DurationVar(&logLevelFor, "for", 0, "restore the previous log level after the given duration, 0 keeps the new level")
+	logsCmd.Flags().IntVar(&logsLines, "lines", 100, "number of latest lines to show, 0 shows all kept lines")
+	profilingCmd.Flags().StringVar(&profilingAddress, "address", "", "loopback address of the endpoints, defaults to 127.0.0.1:6060")
+}
+
+func setLogLevel(cmd *cobra.Command, args []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	if logLevelFor < 0 {
+		return fmt.Errorf("--for must not be negative")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).SetLogLevel(ctx, &proto.SetLogLevelRequest{
+		Level:    args[0],
+		Duration: durationpb.New(logLevelFor),
+	})
+	if err != nil {
+		return fmt.Errorf("set log level: %v", status.Convert(err).Message())
+	}
+
+	if resp.GetRevertAt() == nil {
+		cmd.Printf("Log level set to %s\n", args[0])
+		return nil
+	}
+	cmd.Printf("Log level set to %s, %s is restored at %s\n", args[0], resp.GetPreviousLevel(),
+		resp.GetRevertAt().AsTime().Local().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func showLogs(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).GetLogs(ctx, &proto.GetLogsRequest{Lines: int32(logsLines)})
+	if err != nil {
+		return fmt.Errorf("get logs: %v", status.Convert(err).Message())
+	}
+
+	for _, line := range resp.GetLines() {
+		cmd.Println(line)
+	}
+	return nil
+}
+
+func setProfiling(cmd *cobra.Command, args []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	var enabled bool
+	switch args[0] {
+	case "on":
+		enabled = true
+	case "off":
+	default:
+		return fmt.Errorf("unknown argument %s, expected on or off", args[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).SetProfiling(ctx, &proto.SetProfilingRequest{
+		Enabled: enabled,
+		Address: profilingAddress,
+	})
+	if err != nil {
+		return fmt.Errorf("set profiling: %v", status.Convert(err).Message())
+	}
+
+	if resp.GetAddress() == "" {
+		cmd.Println("Profiling endpoints disabled")
+		return nil
+	}
+	cmd.Printf("Profiling endpoints enabled on http://%s/debug/pprof/ and http://%s/debug/vars\n",
+		resp.GetAddress(), resp.GetAddress())
+	return nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/client/internal/docker"
+	"github.com/FlintyLemming/netbird/util"
+)
+
+var (
+	dockerPluginSocket   string
+	dockerPluginStateDir string
+)
+
+var dockerPluginCmd = &cobra.Command{
+	Use:   "docker-plugin",
+	Short: "Run the NetBird Docker network driver",
+	Long: "Run a Docker network driver plugin where every container attached to a network of the driver joins the " +
+		"NetBird network as its own peer, enrolled with the setup key of the network or of the container endpoint.\n" +
+		"The containers need another network for the engine to reach the Management service and the other peers",
+	Example: "  netbird docker-plugin\n" +
+		"  docker network create -d netbird --ipam-driver null -o setup-key=<SETUP KEY> netbird\n" +
+		"  docker run --network bridge --name web nginx\n" +
+		"  docker network connect --driver-opt setup-key=<SETUP KEY> --driver-opt hostname=web netbird web",
+	RunE: runDockerPlugin,
+}
+
+func init() {
+	dockerPluginCmd.PersistentFlags().StringVar(&dockerPluginSocket, "socket", docker.DefaultSocketPath, "unix socket Docker discovers the driver plugin on")
+	dockerPluginCmd.PersistentFlags().StringVar(&dockerPluginStateDir, "state-dir", docker.DefaultStateDir, "directory holding the network options and the configs of the container peers")
+}
+
+func runDockerPlugin(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+	SetFlagsFromEnvVars(cmd)
+
+	if err := util.InitLog(logLevel, "console"); err != nil {
+		return fmt.Errorf("failed initializing log %v", err)
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("get the netbird binary path: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dockerPluginSocket), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(dockerPluginSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", dockerPluginSocket)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", dockerPluginSocket, err)
+	}
+
+	driver := docker.NewDriver(dockerPluginStateDir, docker.StartInNetNS(binary))
+	server := &http.Server{Handler: driver.Handler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	log.Infof("serving the Docker network driver on %s", dockerPluginSocket)
+	err = server.Serve(listener)
+	driver.Stop()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -8,9 +8,12 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/FlintyLemming/netbird/client/internal"
 	"github.com/FlintyLemming/netbird/client/proto"
 )
 
+var releaseKillSwitch bool
+
 var downCmd = &cobra.Command{
 	Use:   "down",
 	Short: "down netbird connections",
@@ -30,6 +33,11 @@ var downCmd = &cobra.Command{
 
 		conn, err := DialClientGRPCServer(ctx, daemonAddr)
 		if err != nil {
+			if releaseKillSwitch {
+				// the kill switch outlives the daemon and the foreground client
+				log.Warnf("failed to connect to service CLI interface %v, releasing the kill switch locally", err)
+				return internal.ReleaseKillSwitch()
+			}
 			log.Errorf("failed to connect to service CLI interface %v", err)
 			return err
 		}
@@ -37,10 +45,17 @@ var downCmd = &cobra.Command{
 
 		daemonClient := proto.NewDaemonServiceClient(conn)
 
-		if _, err := daemonClient.Down(ctx, &proto.DownRequest{}); err != nil {
+		if _, err := daemonClient.Down(ctx, &proto.DownRequest{Release: releaseKillSwitch}); err != nil {
 			log.Errorf("call service down method: %v", err)
 			return err
 		}
 		return nil
 	},
 }
+
+func init() {
+	downCmd.PersistentFlags().BoolVar(&releaseKillSwitch, "release", false,
+		`Removes the kill switch rules installed with netbird up --kill-switch and disables the kill switch, `+
+			`the traffic outside the tunnel is allowed again. E.g. netbird down --release`,
+	)
+}
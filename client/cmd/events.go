@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var eventsSince time.Duration
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show the events recorded by the daemon",
+	Long: "Show the peer connections, route changes, firewall rule updates and management reconnections recorded by the daemon. " +
+		"The events are kept across restarts of the daemon for up to a week",
+	Example: "  netbird events --since 1h",
+	RunE:    showEvents,
+}
+
+func init() {
+	eventsCmd.Flags().DurationVar(&eventsSince, "since", 24*time.Hour, "show the events of the given time span, 0 shows all kept events")
+}
+
+func showEvents(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	req := &proto.GetEventsRequest{}
+	if eventsSince > 0 {
+		req.Since = timestamppb.New(time.Now().Add(-eventsSince))
+	}
+
+	resp, err := proto.NewDaemonServiceClient(conn).GetEvents(ctx, req)
+	if err != nil {
+		return fmt.Errorf("get events: %v", status.Convert(err).Message())
+	}
+
+	if len(resp.GetEvents()) == 0 {
+		cmd.Println("No events recorded")
+		return nil
+	}
+
+	for _, event := range resp.GetEvents() {
+		cmd.Printf("%s  %-10s  %s\n", event.GetTime().AsTime().Local().Format("2006-01-02 15:04:05"), event.GetCategory(), event.GetMessage())
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/client/internal"
+	"github.com/FlintyLemming/netbird/client/internal/k8s"
+	"github.com/FlintyLemming/netbird/util"
+)
+
+var (
+	operatorInterval  time.Duration
+	operatorAPIURL    string
+	operatorAPIToken  string
+	operatorPeerGroup string
+)
+
+var kubernetesOperatorCmd = &cobra.Command{
+	Use:   "kubernetes-operator",
+	Short: "Reconcile Kubernetes resources into NetBird routes and policies",
+	Long: "Run next to the client of a Kubernetes DaemonSet and reconcile the NetBirdRoute custom resources and the Services " +
+		"annotated with " + k8s.ExposeAnnotation + " into routes advertised by the peer, making the pod and Service networks " +
+		"reachable over NetBird. Route advertisement must be enabled in the account settings.\n" +
+		"When a Management API token is set, the Services annotated with " + k8s.PolicySourcesAnnotation + " get access " +
+		"policies from the listed groups to the peers of the DaemonSet on the Service ports",
+	Example: "  netbird kubernetes-operator --config /etc/netbird/config.json --policy-peer-group k8s-nodes",
+	RunE:    runKubernetesOperator,
+}
+
+func init() {
+	kubernetesOperatorCmd.PersistentFlags().DurationVar(&operatorInterval, "interval", k8s.DefaultInterval, "interval at which the resources are reconciled")
+	kubernetesOperatorCmd.PersistentFlags().StringVar(&operatorAPIURL, "management-api-url", "", "Management service HTTP API URL used to manage the access policies, e.g. https://api.netbird.io")
+	kubernetesOperatorCmd.PersistentFlags().StringVar(&operatorAPIToken, "management-api-token", "", "personal access token of the Management service HTTP API, the access policies are not managed when empty")
+	kubernetesOperatorCmd.PersistentFlags().StringVar(&operatorPeerGroup, "policy-peer-group", "", "name of the group of the DaemonSet peers, the destination of the access policies")
+}
+
+func runKubernetesOperator(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+	SetFlagsFromEnvVars(cmd)
+
+	if err := util.InitLog(logLevel, "console"); err != nil {
+		return fmt.Errorf("failed initializing log %v", err)
+	}
+
+	if operatorInterval <= 0 {
+		return fmt.Errorf("invalid interval %s", operatorInterval)
+	}
+
+	var apiClient *k8s.APIClient
+	if operatorAPIToken != "" {
+		if operatorAPIURL == "" || operatorPeerGroup == "" {
+			return fmt.Errorf("--management-api-url and --policy-peer-group are required to manage the access policies")
+		}
+		apiClient = k8s.NewAPIClient(operatorAPIURL, operatorAPIToken)
+	}
+
+	kubeClient, err := k8s.NewInClusterKubeClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	controller := k8s.NewController(kubeClient, advertiseClusterRoutes(ctx), operatorInterval, apiClient, operatorPeerGroup)
+	controller.Start(ctx)
+	log.Infof("reconciling the Kubernetes resources every %s", operatorInterval)
+
+	<-ctx.Done()
+	controller.Stop()
+	return nil
+}
+
+// advertiseClusterRoutes returns a function advertising the routes with the identity of the peer of the DaemonSet pod.
+// The config is read on every call as the client may register the peer after the operator starts
+func advertiseClusterRoutes(ctx context.Context) k8s.AdvertiseFunc {
+	return func(netID string, networks, withdrawn []string) error {
+		if _, err := os.Stat(configPath); err != nil {
+			return fmt.Errorf("read config %s, the peer may not be registered yet: %w", configPath, err)
+		}
+		config, err := internal.ReadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("read config %s: %w", configPath, err)
+		}
+
+		advertiseCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+		_, err = internal.AdvertiseRoutes(advertiseCtx, config, netID, networks, withdrawn)
+		return err
+	}
+}
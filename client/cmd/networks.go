@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+// doctorTimeout bounds the diagnostics, every STUN server and relay is probed in turn
+const doctorTimeout = 2 * time.Minute
+
+var networksCmd = &cobra.Command{
+	Use:   "networks",
+	Short: "Inspect the network conditions of the peer",
+}
+
+var networksDoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the connectivity of the peer",
+	Long: "Check the reachability and latency of the Management and Signal services, the reachability of the STUN servers " +
+		"over UDP, the NAT mapping, the packet size reaching the STUN servers and the reachability of the relays. " +
+		"Prints the findings with the actions to take",
+	Example: "  netbird networks doctor",
+	RunE:    runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	cmd.Println("Running diagnostics, this can take a minute...")
+
+	resp, err := proto.NewDaemonServiceClient(conn).RunDiagnostics(ctx, &proto.RunDiagnosticsRequest{})
+	if err != nil {
+		return fmt.Errorf("run diagnostics: %v", status.Convert(err).Message())
+	}
+
+	for _, check := range resp.GetChecks() {
+		cmd.Printf("[%-7s] %s: %s\n", strings.ToUpper(check.GetStatus()), check.GetName(), check.GetDetail())
+	}
+
+	if len(resp.GetFindings()) == 0 {
+		cmd.Println("\nNo issues found")
+		return nil
+	}
+
+	cmd.Println("\nFindings:")
+	for _, finding := range resp.GetFindings() {
+		cmd.Printf("  - %s\n", finding)
+	}
+	return nil
+}
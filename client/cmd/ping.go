@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/internal/probe"
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var (
+	pingProtocol string
+	pingPort     int
+	pingJSON     bool
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping <peer>",
+	Short: "Probe a peer over the tunnel",
+	Long: "Send an ICMP echo request or open a TCP connection to a connected peer over the tunnel, and report the " +
+		"round trip time and whether the connection is direct or relayed. The command fails when the peer doesn't " +
+		"answer, which makes it usable in health checks",
+	Example: "  netbird ping office-router\n" +
+		"  netbird ping 100.64.0.2 --protocol tcp --port 22 --json",
+	Args: cobra.ExactArgs(1),
+	RunE: pingPeer,
+}
+
+// pingOutput is the result of a probe printed in json format
+type pingOutput struct {
+	Peer  string  `json:"peer"`
+	IP    string  `json:"netbirdIp"`
+	FQDN  string  `json:"fqdn"`
+	RTTMs float64 `json:"rttMs"`
+	Path  string  `json:"path"`
+}
+
+func init() {
+	pingCmd.Flags().StringVar(&pingProtocol, "protocol", string(probe.ProtocolICMP), "protocol of the probe, icmp or tcp")
+	pingCmd.Flags().IntVar(&pingPort, "port", 0, "port of the peer probed with tcp")
+	pingCmd.Flags().BoolVar(&pingJSON, "json", false, "display the result of the probe in json format")
+}
+
+func pingPeer(cmd *cobra.Command, args []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	protocol, err := probe.ParseProtocol(pingProtocol)
+	if err != nil {
+		return err
+	}
+	if protocol == probe.ProtocolTCP && pingPort == 0 {
+		return fmt.Errorf("--port is required by tcp probes")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probe.DefaultTimeout+3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).ProbePeer(ctx, &proto.ProbePeerRequest{
+		Peer:     args[0],
+		Protocol: string(protocol),
+		Port:     int32(pingPort),
+	})
+	if err != nil {
+		return fmt.Errorf("ping: %v", status.Convert(err).Message())
+	}
+
+	output, err := parsePingResponse(args[0], resp, pingJSON)
+	if err != nil {
+		return err
+	}
+	cmd.Println(output)
+	return nil
+}
+
+// parsePingResponse formats the result of a probe as text or json
+func parsePingResponse(peer string, resp *proto.ProbePeerResponse, asJSON bool) (string, error) {
+	path := "direct"
+	if resp.GetRelayed() {
+		path = "relayed"
+	}
+	rtt := resp.GetRtt().AsDuration()
+
+	if !asJSON {
+		return fmt.Sprintf("Reply from %s (%s): rtt=%s path=%s", peer, resp.GetIP(), rtt.Round(time.Microsecond), path), nil
+	}
+
+	jsonBytes, err := json.Marshal(pingOutput{
+		Peer:  peer,
+		IP:    resp.GetIP(),
+		FQDN:  resp.GetFqdn(),
+		RTTMs: float64(rtt.Microseconds()) / 1000,
+		Path:  path,
+	})
+	if err != nil {
+		return "", fmt.Errorf("json marshal failed")
+	}
+	return string(jsonBytes), nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+func TestParsingPingResponse(t *testing.T) {
+	resp := &proto.ProbePeerResponse{
+		IP:      "100.64.0.2",
+		Fqdn:    "office-router.netbird.cloud",
+		Rtt:     durationpb.New(12345 * time.Microsecond),
+		Relayed: true,
+	}
+
+	output, err := parsePingResponse("office-router", resp, false)
+	require.NoError(t, err)
+	assert.Equal(t, "Reply from office-router (100.64.0.2): rtt=12.345ms path=relayed", output)
+
+	output, err = parsePingResponse("office-router", resp, true)
+	require.NoError(t, err)
+	assert.JSONEq(t,
+		`{"peer":"office-router","netbirdIp":"100.64.0.2","fqdn":"office-router.netbird.cloud","rttMs":12.345,"path":"relayed"}`,
+		output,
+	)
+}
@@ -22,12 +22,30 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/FlintyLemming/netbird/client/internal"
+	"github.com/FlintyLemming/netbird/client/internal/appliance"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
 const (
 	externalIPMapFlag  = "external-ip-map"
 	preSharedKeyFlag   = "preshared-key"
 	dnsResolverAddress = "dns-resolver-address"
+	postQuantumFlag    = "enable-post-quantum"
+	flowCollectorFlag  = "flow-collector"
+	bgpImportFlag      = "import-bgp-routes"
+	maxConnectionsFlag = "max-concurrent-connections"
+	defaultDenyFlag    = "default-deny"
+	localPortsFlag     = "allowed-local-ports"
+	sharedConnFlag     = "disable-shared-connection"
+	servicesFlag       = "service"
+	jsonAPIAddrFlag    = "json-api-addr"
+	lanRoutesFlag      = "detect-lan-routes"
+	lazyConnFlag       = "lazy-connection-threshold"
+	strictDNSFlag      = "strict-dns"
+	killSwitchFlag     = "kill-switch"
+	interfaceNameFlag  = "interface-name"
+	extraAddrsFlag     = "extra-addresses"
+	priorityPeersFlag  = "priority-peers"
 )
 
 var (
@@ -43,6 +61,7 @@ var (
 	oldDefaultLogFile       string
 	logFile                 string
 	daemonAddr              string
+	jsonAPIAddr             string
 	managementURL           string
 	adminURL                string
 	setupKey                string
@@ -50,6 +69,21 @@ var (
 	preSharedKey            string
 	natExternalIPs          []string
 	customDNSAddress        string
+	postQuantumEnabled      bool
+	flowCollectorURL        string
+	importBGPRoutes         bool
+	maxConnections          int
+	defaultDeny             bool
+	allowedLocalPorts       []string
+	disableSharedConn       bool
+	advertisedServices      []string
+	detectLANRoutes         bool
+	lazyConnThreshold       int
+	strictDNS               bool
+	killSwitch              bool
+	interfaceName           string
+	extraAddresses          []string
+	priorityPeers           []string
 	rootCmd                 = &cobra.Command{
 		Use:          "netbird",
 		Short:        "",
@@ -89,6 +123,11 @@ func init() {
 		defaultDaemonAddr = "tcp://127.0.0.1:41731"
 	}
 	rootCmd.PersistentFlags().StringVar(&daemonAddr, "daemon-addr", defaultDaemonAddr, "Daemon service address to serve CLI requests [unix|tcp]://[path|host:port]")
+	defaultJSONAPIAddr := "unix:///var/run/netbird-api.sock"
+	if runtime.GOOS == "windows" {
+		defaultJSONAPIAddr = ""
+	}
+	rootCmd.PersistentFlags().StringVar(&jsonAPIAddr, jsonAPIAddrFlag, defaultJSONAPIAddr, "Daemon service address to serve the local JSON-RPC API unix://[path], empty disables it")
 	rootCmd.PersistentFlags().StringVarP(&managementURL, "management-url", "m", "", fmt.Sprintf("Management Service URL [http|https]://[host]:[port] (default \"%s\")", internal.DefaultManagementURL))
 	rootCmd.PersistentFlags().StringVar(&adminURL, "admin-url", "", fmt.Sprintf("Admin Panel URL [http|https]://[host]:[port] (default \"%s\")", internal.DefaultAdminURL))
 	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", defaultConfigPath, "Netbird config file location")
@@ -97,6 +136,15 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&setupKey, "setup-key", "k", "", "Setup key obtained from the Management Service Dashboard (used to register peer)")
 	rootCmd.PersistentFlags().StringVar(&preSharedKey, preSharedKeyFlag, "", "Sets Wireguard PreSharedKey property. If set, then only peers that have the same key can communicate.")
 	rootCmd.PersistentFlags().StringVarP(&hostName, "hostname", "n", "", "Sets a custom hostname for the device")
+	rootCmd.PersistentFlags().BoolVar(&applianceMode, applianceFlag, appliance.IsOpenWrt(),
+		`Runs in router appliance mode: reduces the memory footprint, logs to the console, stores the state in `+
+			appliance.DefaultStateDir+` unless --state-dir is set and, on OpenWrt, reads the options of `+appliance.UCIConfigFile+`. `+
+			`Enabled by default on OpenWrt. E.g. --appliance or --appliance=false`,
+	)
+	rootCmd.PersistentFlags().StringVar(&stateDir, stateDirFlag, "",
+		`Stores the config and the state of the client in a single writable directory, the --config flag takes precedence. `+
+			`E.g. --state-dir /mnt/usb/netbird`,
+	)
 	rootCmd.AddCommand(serviceCmd)
 	rootCmd.AddCommand(upCmd)
 	rootCmd.AddCommand(downCmd)
@@ -104,6 +152,22 @@ func init() {
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(sshCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(routesCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(networksCmd)
+	rootCmd.AddCommand(wolCmd)
+	rootCmd.AddCommand(pingCmd)
+	rootCmd.AddCommand(debugCmd)
+	rootCmd.AddCommand(servicesCmd)
+	rootCmd.AddCommand(kubernetesOperatorCmd)
+	rootCmd.AddCommand(dockerPluginCmd)
+	routesCmd.AddCommand(routesSelectCmd, routesDeselectCmd, routesAdvertiseCmd)
+	networksCmd.AddCommand(networksDoctorCmd)
+	debugCmd.AddCommand(logLevelCmd, logsCmd, profilingCmd)
+	logLevelCmd.AddCommand(logLevelSetCmd)
+	servicesCmd.AddCommand(servicesListCmd)
 	serviceCmd.AddCommand(runCmd, startCmd, stopCmd, restartCmd) // service control commands are subcommands of service
 	serviceCmd.AddCommand(installCmd, uninstallCmd)              // service installer commands are subcommands of service
 	upCmd.PersistentFlags().StringSliceVar(&natExternalIPs, externalIPMapFlag, nil,
@@ -119,6 +183,100 @@ func init() {
 			`An empty string "" clears the previous configuration. `+
 			`E.g. --dns-resolver-address 127.0.0.1:5053 or --dns-resolver-address ""`,
 	)
+	upCmd.PersistentFlags().BoolVar(&postQuantumEnabled, postQuantumFlag, false,
+		`Derives WireGuard pre-shared keys with a post-quantum key exchange with peers that also enabled it. `+
+			`The static pre-shared key, if set, is mixed into the derived key. `+
+			`E.g. --enable-post-quantum or --enable-post-quantum=false`,
+	)
+	upCmd.PersistentFlags().StringVar(&flowCollectorURL, flowCollectorFlag, "",
+		`Exports per-flow packet and byte counts of the userspace firewall to a collector. `+
+			`Supported formats are ipfix://host:port (IPFIX over UDP) and json://host:port (JSON lines over TCP). `+
+			`An empty string "" disables the export. `+
+			`E.g. --flow-collector ipfix://10.0.0.1:4739 or --flow-collector ""`,
+	)
+	upCmd.PersistentFlags().BoolVar(&importBGPRoutes, bgpImportFlag, false,
+		`Imports the routes learned by a BGP daemon running on this peer, e.g. FRR or BIRD, from the kernel routing table `+
+			`and advertises them as routes through this peer. Withdrawn routes are removed. `+
+			`Route advertisement must be enabled in the account settings. Only supported on Linux. `+
+			`E.g. --import-bgp-routes or --import-bgp-routes=false`,
+	)
+	upCmd.PersistentFlags().IntVar(&maxConnections, maxConnectionsFlag, 0,
+		`Sets the number of peer connections established at the same time, e.g. when all peers reconnect after a `+
+			`network change. The peers connected most recently are reconnected first. `+
+			`0 uses the default of 32. `+
+			`E.g. --max-concurrent-connections 64`,
+	)
+	upCmd.PersistentFlags().BoolVar(&defaultDeny, defaultDenyFlag, false,
+		`Drops the traffic exchanged with the routed networks through the NetBird interface unless a policy allows it, `+
+			`by default this traffic is accepted. The traffic between peers is always filtered by the policies. `+
+			`It is also enabled for all the peers by the account setting. `+
+			`E.g. --default-deny or --default-deny=false`,
+	)
+	upCmd.PersistentFlags().StringSliceVar(&allowedLocalPorts, localPortsFlag, nil,
+		`Always accepts the traffic of the NetBird interface to the given local ports, e.g. of a monitoring agent, `+
+			`before the policies and the default deny mode are applied. `+
+			`You can specify a comma-separated list of protocol/port, the protocol is tcp or udp. `+
+			`An empty string "" clears the previous configuration. `+
+			`E.g. --allowed-local-ports tcp/9100,udp/161 or --allowed-local-ports ""`,
+	)
+	upCmd.PersistentFlags().BoolVar(&disableSharedConn, sharedConnFlag, false,
+		`Connects to the Signal service over a separate connection even when the Management server also serves it, `+
+			`by default both services are multiplexed over the Management connection in this case. `+
+			`E.g. --disable-shared-connection or --disable-shared-connection=false`,
+	)
+	upCmd.PersistentFlags().StringSliceVar(&advertisedServices, servicesFlag, nil,
+		`Advertises services running on this peer to the other peers, which resolve them with DNS SRV records, `+
+			`i.e. _<label>._<protocol>.<peer fqdn> and _<label>._<protocol>.<domain>, and list them with netbird services list. `+
+			`You can specify a comma-separated list of label:port/protocol, the protocol is tcp or udp. `+
+			`An empty string "" clears the previous configuration. `+
+			`E.g. --service http:8080/tcp,syslog:514/udp or --service ""`,
+	)
+	upCmd.PersistentFlags().BoolVar(&detectLANRoutes, lanRoutesFlag, false,
+		`Reports the private networks of the local LAN interfaces to the Management service, which offers them `+
+			`as routes through this peer to be created without typing their CIDR. `+
+			`E.g. --detect-lan-routes or --detect-lan-routes=false`,
+	)
+	upCmd.PersistentFlags().IntVar(&lazyConnThreshold, lazyConnFlag, 0,
+		`Creates the connections to the peers on demand when the network has more peers than the threshold, `+
+			`i.e. when a peer resolves the other one with DNS, routes a network or reaches out over Signal, `+
+			`to limit the memory used in large networks. `+
+			`0 uses the default of 1000, a negative value creates all the connections upfront. `+
+			`E.g. --lazy-connection-threshold 500`,
+	)
+	upCmd.PersistentFlags().BoolVar(&strictDNS, strictDNSFlag, false,
+		`Blocks the DNS queries (ports 53 and 853) sent to other resolvers than the NetBird resolver and the `+
+			`nameservers of the account while connected, to prevent DNS leaks. The block is released on disconnect. `+
+			`The domains not matched by a nameserver group of the account can't be resolved in this mode. `+
+			`Requires a kernel firewall, it isn't supported on mobile platforms. `+
+			`E.g. --strict-dns or --strict-dns=false`,
+	)
+	upCmd.PersistentFlags().BoolVar(&killSwitch, killSwitchFlag, false,
+		`Drops all the traffic which doesn't go through the tunnel, except the Management, Signal and relay servers, `+
+			`DNS, DHCP and the IPv6 neighbor discovery, for using an exit node as a full VPN. `+
+			`The rules persist across reconnects and restarts of the client and are only removed with netbird down --release. `+
+			`Requires iptables or nftables, it is only supported on Linux. `+
+			`E.g. --kill-switch or --kill-switch=false`,
+	)
+	upCmd.PersistentFlags().StringVar(&interfaceName, interfaceNameFlag, "",
+		`Sets the name of the WireGuard interface, wt0 by default and utun100 on macOS. It must match utun[0-9]+ on macOS and be at most 15 characters long `+
+			`on Linux. The change applies on the next start of the connection. `+
+			`E.g. --interface-name wt1 or --interface-name utun101`,
+	)
+	upCmd.PersistentFlags().StringSliceVar(&extraAddresses, extraAddrsFlag, nil,
+		`Assigns secondary addresses to the WireGuard interface along with the address of the peer, e.g. an IPv6 `+
+			`address or the addresses of the services running on this peer, which resolve with the DNS name of the peer. `+
+			`You can specify a comma-separated list of addresses in the CIDR format. `+
+			`An empty string "" clears the previous configuration. `+
+			`E.g. --extra-addresses fd00:100::10/64,100.64.100.10/32 or --extra-addresses ""`,
+	)
+	upCmd.PersistentFlags().StringSliceVar(&priorityPeers, priorityPeersFlag, nil,
+		`Connects to the critical peers right away, even with lazy connections, and keeps their connections warm `+
+			`with an aggressive keepalive and the relay allocated in advance, so that the first packets to e.g. a DNS `+
+			`or directory server aren't delayed. `+
+			`You can specify a comma-separated list of peer FQDNs, NetBird IPs or WireGuard public keys. `+
+			`An empty string "" clears the previous configuration. `+
+			`E.g. --priority-peers dns.netbird.cloud,100.64.0.10 or --priority-peers ""`,
+	)
 }
 
 // SetupCloseHandler handles SIGTERM signal and exits with success
@@ -182,6 +340,29 @@ func DialClientGRPCServer(ctx context.Context, addr string) (*grpc.ClientConn, e
 	)
 }
 
+// initTracing installs the tracer provider configured with the OTEL_* environment variables and returns a function
+// flushing the pending spans. Tracing stays disabled when the variables are absent or invalid
+func initTracing() func() {
+	config, err := tracing.ConfigFromEnv()
+	if err != nil {
+		log.Warnf("failed reading the tracing config: %v", err)
+		return func() {}
+	}
+	shutdown, err := tracing.Init("netbird-client", config)
+	if err != nil {
+		log.Warnf("failed initializing tracing: %v", err)
+		return func() {}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Warnf("failed flushing the spans: %v", err)
+		}
+	}
+}
+
 // WithBackOff execute function in backoff cycle.
 func WithBackOff(bf func() error) error {
 	return backoff.RetryNotify(bf, CLIBackOffSettings, func(err error, duration time.Duration) {
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var routesCmd = &cobra.Command{
+	Use:   "routes",
+	Short: "Manage the routes accepted from the network",
+	Long: "Select or deselect the routes advertised in the network. Deselected routes are ignored until they are selected again.\n" +
+		"Advertise the networks reachable through this peer to have routes created for them",
+}
+
+var advertiseNetworkID string
+
+var routesSelectCmd = &cobra.Command{
+	Use:     "select <network-id> [network-id...]",
+	Short:   "Accept the routes of the networks",
+	Example: "  netbird routes select office-lan",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateRouteSelection(cmd, args, true)
+	},
+}
+
+var routesDeselectCmd = &cobra.Command{
+	Use:     "deselect <network-id> [network-id...]",
+	Short:   "Ignore the routes of the networks",
+	Example: "  netbird routes deselect office-lan",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateRouteSelection(cmd, args, false)
+	},
+}
+
+var routesAdvertiseCmd = &cobra.Command{
+	Use:   "advertise <network> [network...]",
+	Short: "Request routes to the networks through this peer",
+	Long: "Request the Management service to route the networks through this peer. " +
+		"Route advertisement must be enabled in the account settings and the routes may wait for the approval of an admin",
+	Example: "  netbird routes advertise 10.1.0.0/24 --network-id office-lan",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    advertiseRoutes,
+}
+
+func init() {
+	routesAdvertiseCmd.Flags().StringVar(&advertiseNetworkID, "network-id", "", "network identifier of the routes, defaults to the peer's DNS label")
+}
+
+func advertiseRoutes(cmd *cobra.Command, networks []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	for _, network := range networks {
+		if _, err := netip.ParsePrefix(network); err != nil {
+			return fmt.Errorf("invalid network %s, expected CIDR notation like 10.1.0.0/24", network)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).AdvertiseRoutes(ctx, &proto.AdvertiseRoutesRequest{
+		Networks:  networks,
+		NetworkID: advertiseNetworkID,
+	})
+	if err != nil {
+		return fmt.Errorf("advertise routes: %v", status.Convert(err).Message())
+	}
+
+	for _, r := range resp.GetRoutes() {
+		if r.GetPendingApproval() {
+			cmd.Printf("Route to %s (%s) is pending approval\n", r.GetNetwork(), r.GetNetworkID())
+		} else {
+			cmd.Printf("Route to %s (%s) is active\n", r.GetNetwork(), r.GetNetworkID())
+		}
+	}
+	return nil
+}
+
+func updateRouteSelection(cmd *cobra.Command, netIDs []string, selected bool) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	client := proto.NewDaemonServiceClient(conn)
+	req := &proto.SelectRoutesRequest{NetworkIDs: netIDs}
+
+	var resp *proto.SelectRoutesResponse
+	if selected {
+		resp, err = client.SelectRoutes(ctx, req)
+	} else {
+		resp, err = client.DeselectRoutes(ctx, req)
+	}
+	if err != nil {
+		return fmt.Errorf("update route selection: %v", status.Convert(err).Message())
+	}
+
+	if selected {
+		cmd.Printf("Routes of %s selected\n", strings.Join(netIDs, ", "))
+	} else {
+		cmd.Printf("Routes of %s deselected\n", strings.Join(netIDs, ", "))
+	}
+
+	if len(resp.GetDeselectedNetworkIDs()) == 0 {
+		cmd.Println("All routes are selected")
+	} else {
+		cmd.Printf("Deselected networks: %s\n", strings.Join(resp.GetDeselectedNetworkIDs(), ", "))
+	}
+	return nil
+}
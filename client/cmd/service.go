@@ -10,12 +10,15 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/FlintyLemming/netbird/client/internal"
+	"github.com/FlintyLemming/netbird/client/internal/jsonrpc"
 )
 
 type program struct {
-	ctx    context.Context
-	cancel context.CancelFunc
-	serv   *grpc.Server
+	ctx         context.Context
+	cancel      context.CancelFunc
+	serv        *grpc.Server
+	jsonAPI     *jsonrpc.Server
+	stopTracing func()
 }
 
 func newProgram(ctx context.Context, cancel context.CancelFunc) *program {
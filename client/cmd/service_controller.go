@@ -11,6 +11,8 @@ import (
 	"github.com/kardianos/service"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/FlintyLemming/netbird/client/internal/appliance"
+	"github.com/FlintyLemming/netbird/client/internal/jsonrpc"
 	"github.com/FlintyLemming/netbird/client/proto"
 	"github.com/FlintyLemming/netbird/client/server"
 	"github.com/FlintyLemming/netbird/util"
@@ -21,6 +23,7 @@ import (
 func (p *program) Start(svc service.Service) error {
 	// Start should not block. Do the actual work async.
 	log.Info("starting Netbird service") //nolint
+	p.stopTracing = initTracing()
 	// in any case, even if configuration does not exists we run daemon to serve CLI gRPC API.
 	p.serv = grpc.NewServer()
 
@@ -60,6 +63,10 @@ func (p *program) Start(svc service.Service) error {
 		}
 		proto.RegisterDaemonServiceServer(p.serv, serverInstance)
 
+		if jsonAPIAddr != "" {
+			p.startJSONAPI(serverInstance)
+		}
+
 		log.Printf("started daemon server: %v", split[1])
 		if err := p.serv.Serve(listen); err != nil {
 			log.Errorf("failed to serve daemon requests: %v", err)
@@ -68,6 +75,29 @@ func (p *program) Start(svc service.Service) error {
 	return nil
 }
 
+// startJSONAPI serves the local JSON-RPC API for scripts and tools without gRPC support next to the gRPC API
+func (p *program) startJSONAPI(daemon proto.DaemonServiceServer) {
+	path, found := strings.CutPrefix(jsonAPIAddr, "unix://")
+	if !found {
+		log.Errorf("unsupported JSON API address protocol: %s, only unix sockets are supported", jsonAPIAddr)
+		return
+	}
+
+	listener, err := jsonrpc.ListenUnix(path)
+	if err != nil {
+		log.Errorf("failed to listen JSON API interface: %v", err)
+		return
+	}
+
+	p.jsonAPI = jsonrpc.NewServer(daemon)
+	go func() {
+		log.Infof("started JSON API server: %s", path)
+		if err := p.jsonAPI.Serve(listener); err != nil {
+			log.Errorf("failed to serve JSON API requests: %v", err)
+		}
+	}()
+}
+
 func (p *program) Stop(srv service.Service) error {
 	p.cancel()
 
@@ -75,7 +105,14 @@ func (p *program) Stop(srv service.Service) error {
 		p.serv.Stop()
 	}
 
+	if p.jsonAPI != nil {
+		p.jsonAPI.Stop()
+	}
+
 	time.Sleep(time.Second * 2)
+	if p.stopTracing != nil {
+		p.stopTracing()
+	}
 	log.Info("stopped Netbird service") //nolint
 	return nil
 }
@@ -88,6 +125,10 @@ var runCmd = &cobra.Command{
 
 		cmd.SetOut(cmd.OutOrStdout())
 
+		if err := applyApplianceMode(cmd); err != nil {
+			return err
+		}
+
 		err := handleRebrand(cmd)
 		if err != nil {
 			return err
@@ -122,6 +163,14 @@ var startCmd = &cobra.Command{
 
 		cmd.SetOut(cmd.OutOrStdout())
 
+		if appliance.IsOpenWrt() {
+			if err := appliance.ProcdServiceAction("start"); err != nil {
+				return err
+			}
+			cmd.Println("Netbird service has been started")
+			return nil
+		}
+
 		err := handleRebrand(cmd)
 		if err != nil {
 			return err
@@ -157,6 +206,14 @@ var stopCmd = &cobra.Command{
 
 		cmd.SetOut(cmd.OutOrStdout())
 
+		if appliance.IsOpenWrt() {
+			if err := appliance.ProcdServiceAction("stop"); err != nil {
+				return err
+			}
+			cmd.Println("Netbird service has been stopped")
+			return nil
+		}
+
 		err := handleRebrand(cmd)
 		if err != nil {
 			return err
@@ -190,6 +247,14 @@ var restartCmd = &cobra.Command{
 
 		cmd.SetOut(cmd.OutOrStdout())
 
+		if appliance.IsOpenWrt() {
+			if err := appliance.ProcdServiceAction("restart"); err != nil {
+				return err
+			}
+			cmd.Println("Netbird service has been restarted")
+			return nil
+		}
+
 		err := handleRebrand(cmd)
 		if err != nil {
 			return err
@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 
 	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/client/internal/appliance"
 )
 
 var installCmd = &cobra.Command{
@@ -22,6 +25,10 @@ var installCmd = &cobra.Command{
 			return err
 		}
 
+		if appliance.IsOpenWrt() {
+			return installProcdService(cmd)
+		}
+
 		svcConfig := newSVCConfig()
 
 		svcConfig.Arguments = []string{
@@ -37,6 +44,10 @@ var installCmd = &cobra.Command{
 			svcConfig.Arguments = append(svcConfig.Arguments, "--management-url", managementURL)
 		}
 
+		if rootCmd.PersistentFlags().Changed(jsonAPIAddrFlag) {
+			svcConfig.Arguments = append(svcConfig.Arguments, "--"+jsonAPIAddrFlag, jsonAPIAddr)
+		}
+
 		if logFile != "console" {
 			svcConfig.Arguments = append(svcConfig.Arguments, "--log-file", logFile)
 		}
@@ -95,6 +106,14 @@ var uninstallCmd = &cobra.Command{
 			return err
 		}
 
+		if appliance.IsOpenWrt() {
+			if err := appliance.UninstallProcdService(); err != nil {
+				return err
+			}
+			cmd.Println("Netbird has been uninstalled")
+			return nil
+		}
+
 		ctx, cancel := context.WithCancel(cmd.Context())
 
 		s, err := newSVC(newProgram(ctx, cancel), newSVCConfig())
@@ -110,3 +129,19 @@ var uninstallCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// installProcdService installs the procd init script of OpenWrt, the service is configured with the uci config
+// instead of the flags
+func installProcdService(cmd *cobra.Command) error {
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("get path of the netbird binary: %w", err)
+	}
+
+	if err := appliance.InstallProcdService(binary); err != nil {
+		cmd.PrintErrln(err)
+		return err
+	}
+	cmd.Printf("Netbird service has been installed, configure it in %s\n", appliance.UCIConfigFile)
+	return nil
+}
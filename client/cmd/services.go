@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+	"github.com/FlintyLemming/netbird/client/system"
+)
+
+var servicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "Inspect the services advertised by the peers",
+}
+
+var servicesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the services advertised by the peers",
+	Long: "List the services advertised by this peer and the other peers of the network with netbird up --service, " +
+		"with the DNS SRV records resolving them",
+	Example: "  netbird services list",
+	RunE:    listServices,
+}
+
+// serviceEntry is a service advertised by a peer
+type serviceEntry struct {
+	fqdn    string
+	local   bool
+	service system.Service
+}
+
+func listServices(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).Status(ctx, &proto.StatusRequest{GetFullPeerStatus: true})
+	if err != nil {
+		return fmt.Errorf("status failed: %v", status.Convert(err).Message())
+	}
+
+	cmd.Print(parseServices(resp.GetFullStatus()))
+	return nil
+}
+
+// parseServices formats the services advertised by the local peer and the remote peers as a table
+func parseServices(fullStatus *proto.FullStatus) string {
+	entries := serviceEntries(fullStatus.GetLocalPeerState().GetFqdn(), fullStatus.GetLocalPeerState().GetServices(), true)
+	for _, peerState := range fullStatus.GetPeers() {
+		entries = append(entries, serviceEntries(peerState.GetFqdn(), peerState.GetServices(), false)...)
+	}
+	if len(entries) == 0 {
+		return "No services advertised\n"
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].local != entries[j].local {
+			return entries[i].local
+		}
+		return entries[i].fqdn < entries[j].fqdn
+	})
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%-40s %-16s %-12s %s\n", "Peer", "Service", "Port", "SRV record"))
+	for _, entry := range entries {
+		peerName := entry.fqdn
+		if entry.local {
+			peerName += " (this peer)"
+		}
+		builder.WriteString(fmt.Sprintf("%-40s %-16s %-12s %s\n",
+			peerName,
+			entry.service.Label,
+			fmt.Sprintf("%d/%s", entry.service.Port, entry.service.Protocol),
+			fmt.Sprintf("_%s._%s.%s", entry.service.Label, entry.service.Protocol, entry.fqdn),
+		))
+	}
+	return builder.String()
+}
+
+func serviceEntries(fqdn string, services []string, local bool) []serviceEntry {
+	var entries []serviceEntry
+	for _, value := range services {
+		service, err := system.ParseService(value)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, serviceEntry{fqdn: fqdn, local: local, service: service})
+	}
+	return entries
+}
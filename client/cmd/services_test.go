@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+func TestParsingServices(t *testing.T) {
+	fullStatus := &proto.FullStatus{
+		LocalPeerState: &proto.LocalPeerState{
+			Fqdn:     "laptop.netbird.cloud",
+			Services: []string{"ssh:22/tcp"},
+		},
+		Peers: []*proto.PeerState{
+			{
+				Fqdn:     "web.netbird.cloud",
+				Services: []string{"http:8080/tcp", "syslog:514/udp"},
+			},
+			{
+				Fqdn: "db.netbird.cloud",
+			},
+		},
+	}
+
+	expected := "Peer                                     Service          Port         SRV record\n" +
+		"laptop.netbird.cloud (this peer)         ssh              22/tcp       _ssh._tcp.laptop.netbird.cloud\n" +
+		"web.netbird.cloud                        http             8080/tcp     _http._tcp.web.netbird.cloud\n" +
+		"web.netbird.cloud                        syslog           514/udp      _syslog._udp.web.netbird.cloud\n"
+
+	assert.Equal(t, expected, parseServices(fullStatus))
+	assert.Equal(t, "No services advertised\n", parseServices(&proto.FullStatus{}))
+}
@@ -30,6 +30,17 @@ type peerStateDetailOutput struct {
 	ConnType         string           `json:"connectionType" yaml:"connectionType"`
 	Direct           bool             `json:"direct" yaml:"direct"`
 	IceCandidateType iceCandidateType `json:"iceCandidateType" yaml:"iceCandidateType"`
+	Groups           []string         `json:"groups,omitempty" yaml:"groups,omitempty"`
+	// Labels are the key/value pairs describing the peer, set by the administrators in the management service
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// LastWireguardHandshake is zero when the peer never completed a handshake
+	LastWireguardHandshake time.Time `json:"lastWireguardHandshake" yaml:"lastWireguardHandshake"`
+	TransferReceived       int64     `json:"transferReceived" yaml:"transferReceived"`
+	TransferSent           int64     `json:"transferSent" yaml:"transferSent"`
+	// IceRestarts counts the restarts of the connection after its WireGuard handshakes went stale
+	IceRestarts int32 `json:"iceRestarts" yaml:"iceRestarts"`
+	// Lazy is true when the connection to the peer is created on demand and wasn't needed yet
+	Lazy bool `json:"lazy,omitempty" yaml:"lazy,omitempty"`
 }
 
 type peersStateOutput struct {
@@ -44,8 +55,42 @@ type signalStateOutput struct {
 }
 
 type managementStateOutput struct {
-	URL       string `json:"url" yaml:"url"`
-	Connected bool   `json:"connected" yaml:"connected"`
+	URL          string `json:"url" yaml:"url"`
+	Connected    bool   `json:"connected" yaml:"connected"`
+	LoginExpired bool   `json:"loginExpired,omitempty" yaml:"loginExpired,omitempty"`
+	// MaintenanceUntil is set during a maintenance announced by the Management service
+	MaintenanceUntil   *time.Time `json:"maintenanceUntil,omitempty" yaml:"maintenanceUntil,omitempty"`
+	MaintenanceMessage string     `json:"maintenanceMessage,omitempty" yaml:"maintenanceMessage,omitempty"`
+}
+
+type componentOutput struct {
+	Name        string     `json:"name" yaml:"name"`
+	Healthy     bool       `json:"healthy" yaml:"healthy"`
+	LastSeen    time.Time  `json:"lastSeen" yaml:"lastSeen"`
+	LastError   string     `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty" yaml:"lastErrorAt,omitempty"`
+	Restarts    int32      `json:"restarts" yaml:"restarts"`
+}
+
+type routeOutput struct {
+	NetworkID string `json:"networkId" yaml:"networkId"`
+	Network   string `json:"network" yaml:"network"`
+	// Peer is the FQDN of the chosen routing peer, or its public key when the peer is unknown
+	Peer         string `json:"peer" yaml:"peer"`
+	LoadBalanced bool   `json:"loadBalanced" yaml:"loadBalanced"`
+}
+
+type resourcesOutput struct {
+	Peers             int32  `json:"peers" yaml:"peers"`
+	MaterializedPeers int32  `json:"materializedPeers" yaml:"materializedPeers"`
+	LazyConnections   bool   `json:"lazyConnections" yaml:"lazyConnections"`
+	HeapAllocBytes    uint64 `json:"heapAllocBytes" yaml:"heapAllocBytes"`
+	SysBytes          uint64 `json:"sysBytes" yaml:"sysBytes"`
+	// CPUPercent is the CPU consumed over the latest sample interval, 100 is one full core
+	CPUPercent float64 `json:"cpuPercent" yaml:"cpuPercent"`
+	Goroutines int32   `json:"goroutines" yaml:"goroutines"`
+	// ProfilingAddress is empty when the profiling endpoints are disabled
+	ProfilingAddress string `json:"profilingAddress,omitempty" yaml:"profilingAddress,omitempty"`
 }
 
 type iceCandidateType struct {
@@ -63,18 +108,30 @@ type statusOutputOverview struct {
 	PubKey          string                `json:"publicKey" yaml:"publicKey"`
 	KernelInterface bool                  `json:"usesKernelInterface" yaml:"usesKernelInterface"`
 	FQDN            string                `json:"fqdn" yaml:"fqdn"`
+	Components      []componentOutput     `json:"components,omitempty" yaml:"components,omitempty"`
+	Routes          []routeOutput         `json:"routes,omitempty" yaml:"routes,omitempty"`
+	// AllowedLocalPorts are accepted by the firewall before the policies and the default deny mode
+	AllowedLocalPorts []string `json:"allowedLocalPorts,omitempty" yaml:"allowedLocalPorts,omitempty"`
+	// Resources is nil when the daemon doesn't report them
+	Resources *resourcesOutput `json:"resources,omitempty" yaml:"resources,omitempty"`
 }
 
 var (
 	detailFlag           bool
+	healthFlag           bool
 	ipv4Flag             bool
 	jsonFlag             bool
 	yamlFlag             bool
+	resetCountersFlag    bool
 	ipsFilter            []string
 	prefixNamesFilter    []string
+	groupsFilter         []string
+	labelsFilter         []string
 	statusFilter         string
 	ipsFilterMap         map[string]struct{}
 	prefixNamesFilterMap map[string]struct{}
+	groupsFilterMap      map[string]struct{}
+	labelsFilterMap      map[string]string
 )
 
 var statusCmd = &cobra.Command{
@@ -86,14 +143,20 @@ var statusCmd = &cobra.Command{
 func init() {
 	ipsFilterMap = make(map[string]struct{})
 	prefixNamesFilterMap = make(map[string]struct{})
+	groupsFilterMap = make(map[string]struct{})
+	labelsFilterMap = make(map[string]string)
 	statusCmd.PersistentFlags().BoolVarP(&detailFlag, "detail", "d", false, "display detailed status information in human-readable format")
 	statusCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "display detailed status information in json format")
 	statusCmd.PersistentFlags().BoolVar(&yamlFlag, "yaml", false, "display detailed status information in yaml format")
 	statusCmd.PersistentFlags().BoolVar(&ipv4Flag, "ipv4", false, "display only NetBird IPv4 of this peer, e.g., --ipv4 will output 100.64.0.33")
 	statusCmd.MarkFlagsMutuallyExclusive("detail", "json", "yaml", "ipv4")
+	statusCmd.PersistentFlags().BoolVar(&healthFlag, "health", false, "display the health of the client components, e.g., DNS server, route manager, SSH server")
 	statusCmd.PersistentFlags().StringSliceVar(&ipsFilter, "filter-by-ips", []string{}, "filters the detailed output by a list of one or more IPs, e.g., --filter-by-ips 100.64.0.100,100.64.0.200")
 	statusCmd.PersistentFlags().StringSliceVar(&prefixNamesFilter, "filter-by-names", []string{}, "filters the detailed output by a list of one or more peer FQDN or hostnames, e.g., --filter-by-names peer-a,peer-b.netbird.cloud")
+	statusCmd.PersistentFlags().StringSliceVar(&groupsFilter, "filter-by-groups", []string{}, "filters the detailed output by a list of one or more peer group names, e.g., --filter-by-groups servers,databases")
+	statusCmd.PersistentFlags().StringSliceVar(&labelsFilter, "filter-by-labels", []string{}, "filters the detailed output by a list of one or more peer labels the peers must all have, e.g., --filter-by-labels env=prod,role=db")
 	statusCmd.PersistentFlags().StringVar(&statusFilter, "filter-by-status", "", "filters the detailed output by connection status(connected|disconnected), e.g., --filter-by-status connected")
+	statusCmd.PersistentFlags().BoolVar(&resetCountersFlag, "reset-counters", false, "reset the counters of the bytes transferred with the peers after displaying them")
 }
 
 func statusFunc(cmd *cobra.Command, args []string) error {
@@ -153,6 +216,10 @@ func statusFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if healthFlag && !jsonFlag && !yamlFlag {
+		statusOutputString += parseComponents(outputInformationHolder.Components)
+	}
+
 	cmd.Print(statusOutputString)
 
 	return nil
@@ -167,7 +234,10 @@ func getStatus(ctx context.Context, cmd *cobra.Command) (*proto.StatusResponse,
 	}
 	defer conn.Close()
 
-	resp, err := proto.NewDaemonServiceClient(conn).Status(cmd.Context(), &proto.StatusRequest{GetFullPeerStatus: true})
+	resp, err := proto.NewDaemonServiceClient(conn).Status(cmd.Context(), &proto.StatusRequest{
+		GetFullPeerStatus: true,
+		ResetPeerStats:    resetCountersFlag,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("status failed: %v", status.Convert(err).Message())
 	}
@@ -204,6 +274,24 @@ func parseFilters() error {
 		enableDetailFlagWhenFilterFlag()
 	}
 
+	if len(groupsFilter) > 0 {
+		for _, group := range groupsFilter {
+			groupsFilterMap[group] = struct{}{}
+		}
+		enableDetailFlagWhenFilterFlag()
+	}
+
+	if len(labelsFilter) > 0 {
+		for _, label := range labelsFilter {
+			key, value, found := strings.Cut(label, "=")
+			if !found || key == "" {
+				return fmt.Errorf("got an invalid label in the filter: %s, should be key=value", label)
+			}
+			labelsFilterMap[strings.ToLower(key)] = value
+		}
+		enableDetailFlagWhenFilterFlag()
+	}
+
 	return nil
 }
 
@@ -218,8 +306,14 @@ func convertToStatusOutputOverview(resp *proto.StatusResponse) statusOutputOverv
 
 	managementState := pbFullStatus.GetManagementState()
 	managementOverview := managementStateOutput{
-		URL:       managementState.GetURL(),
-		Connected: managementState.GetConnected(),
+		URL:          managementState.GetURL(),
+		Connected:    managementState.GetConnected(),
+		LoginExpired: resp.GetLoginExpired(),
+	}
+	if managementState.GetMaintenanceUntil() != nil {
+		maintenanceUntil := managementState.GetMaintenanceUntil().AsTime().Local()
+		managementOverview.MaintenanceUntil = &maintenanceUntil
+		managementOverview.MaintenanceMessage = managementState.GetMaintenanceMessage()
 	}
 
 	signalState := pbFullStatus.GetSignalState()
@@ -231,20 +325,86 @@ func convertToStatusOutputOverview(resp *proto.StatusResponse) statusOutputOverv
 	peersOverview := mapPeers(resp.GetFullStatus().GetPeers())
 
 	overview := statusOutputOverview{
-		Peers:           peersOverview,
-		CliVersion:      version.NetbirdVersion(),
-		DaemonVersion:   resp.GetDaemonVersion(),
-		ManagementState: managementOverview,
-		SignalState:     signalOverview,
-		IP:              pbFullStatus.GetLocalPeerState().GetIP(),
-		PubKey:          pbFullStatus.GetLocalPeerState().GetPubKey(),
-		KernelInterface: pbFullStatus.GetLocalPeerState().GetKernelInterface(),
-		FQDN:            pbFullStatus.GetLocalPeerState().GetFqdn(),
+		Peers:             peersOverview,
+		CliVersion:        version.NetbirdVersion(),
+		DaemonVersion:     resp.GetDaemonVersion(),
+		ManagementState:   managementOverview,
+		SignalState:       signalOverview,
+		IP:                pbFullStatus.GetLocalPeerState().GetIP(),
+		PubKey:            pbFullStatus.GetLocalPeerState().GetPubKey(),
+		KernelInterface:   pbFullStatus.GetLocalPeerState().GetKernelInterface(),
+		FQDN:              pbFullStatus.GetLocalPeerState().GetFqdn(),
+		AllowedLocalPorts: pbFullStatus.GetLocalPeerState().GetAllowedLocalPorts(),
+		Routes:            mapRoutes(pbFullStatus.GetRoutes(), pbFullStatus.GetPeers()),
+	}
+
+	if healthFlag {
+		overview.Components = mapComponents(pbFullStatus.GetComponents())
+	}
+
+	if resources := pbFullStatus.GetResources(); resources != nil {
+		overview.Resources = &resourcesOutput{
+			Peers:             resources.GetPeers(),
+			MaterializedPeers: resources.GetMaterializedPeers(),
+			LazyConnections:   resources.GetLazyConnections(),
+			HeapAllocBytes:    resources.GetHeapAllocBytes(),
+			SysBytes:          resources.GetSysBytes(),
+			CPUPercent:        resources.GetCpuPercent(),
+			Goroutines:        resources.GetGoroutines(),
+			ProfilingAddress:  resources.GetProfilingAddress(),
+		}
 	}
 
 	return overview
 }
 
+func mapComponents(components []*proto.ComponentHealth) []componentOutput {
+	componentsOutput := make([]componentOutput, 0, len(components))
+	for _, component := range components {
+		componentOutput := componentOutput{
+			Name:      component.GetName(),
+			Healthy:   component.GetHealthy(),
+			LastError: component.GetLastError(),
+			Restarts:  component.GetRestarts(),
+		}
+		if component.GetLastSeen() != nil {
+			componentOutput.LastSeen = component.GetLastSeen().AsTime().Local()
+		}
+		if component.GetLastErrorAt() != nil {
+			lastErrorAt := component.GetLastErrorAt().AsTime().Local()
+			componentOutput.LastErrorAt = &lastErrorAt
+		}
+		componentsOutput = append(componentsOutput, componentOutput)
+	}
+	return componentsOutput
+}
+
+func mapRoutes(routes []*proto.RouteState, peers []*proto.PeerState) []routeOutput {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	fqdns := make(map[string]string, len(peers))
+	for _, peerState := range peers {
+		fqdns[peerState.GetPubKey()] = peerState.GetFqdn()
+	}
+
+	routesOutput := make([]routeOutput, 0, len(routes))
+	for _, route := range routes {
+		routingPeer := route.GetPeer()
+		if fqdn := fqdns[routingPeer]; fqdn != "" {
+			routingPeer = fqdn
+		}
+		routesOutput = append(routesOutput, routeOutput{
+			NetworkID:    route.GetNetworkID(),
+			Network:      route.GetNetwork(),
+			Peer:         routingPeer,
+			LoadBalanced: route.GetLoadBalanced(),
+		})
+	}
+	return routesOutput
+}
+
 func mapPeers(peers []*proto.PeerState) peersStateOutput {
 	var peersStateDetail []peerStateDetailOutput
 	localICE := ""
@@ -279,7 +439,16 @@ func mapPeers(peers []*proto.PeerState) peersStateOutput {
 				Local:  localICE,
 				Remote: remoteICE,
 			},
-			FQDN: pbPeerState.GetFqdn(),
+			FQDN:             pbPeerState.GetFqdn(),
+			Groups:           pbPeerState.GetGroups(),
+			Labels:           pbPeerState.GetLabels(),
+			TransferReceived: pbPeerState.GetBytesRx(),
+			TransferSent:     pbPeerState.GetBytesTx(),
+			IceRestarts:      pbPeerState.GetIceRestarts(),
+			Lazy:             pbPeerState.GetLazy(),
+		}
+		if pbPeerState.GetLastWireguardHandshake() != nil {
+			peerState.LastWireguardHandshake = pbPeerState.GetLastWireguardHandshake().AsTime().Local()
 		}
 
 		peersStateDetail = append(peersStateDetail, peerState)
@@ -337,6 +506,15 @@ func parseGeneralSummary(overview statusOutputOverview, showURL bool) string {
 		if showURL {
 			managementConnString = fmt.Sprintf("%s to %s", managementConnString, overview.ManagementState.URL)
 		}
+		if overview.ManagementState.LoginExpired {
+			managementConnString += " (login expired, run netbird up to log in again)"
+		}
+	} else if until := overview.ManagementState.MaintenanceUntil; until != nil && until.After(time.Now()) {
+		managementConnString = fmt.Sprintf("Maintenance until %s", until.Format("15:04"))
+		if overview.ManagementState.MaintenanceMessage != "" {
+			managementConnString += fmt.Sprintf(" (%s)", overview.ManagementState.MaintenanceMessage)
+		}
+		managementConnString += ", keeping the last configuration"
 	}
 
 	signalConnString := "Disconnected"
@@ -376,9 +554,35 @@ func parseGeneralSummary(overview statusOutputOverview, showURL bool) string {
 		interfaceTypeString,
 		peersCountString,
 	)
+
+	// the allowed local ports take precedence over the policies received from the Management service
+	if len(overview.AllowedLocalPorts) > 0 {
+		summary += fmt.Sprintf("Allowed local ports: %s (accepted before the policies and the default deny mode)\n",
+			strings.Join(overview.AllowedLocalPorts, ", "))
+	}
+
+	if overview.Resources != nil {
+		summary += parseResources(*overview.Resources)
+	}
 	return summary
 }
 
+// parseResources returns the memory and the CPU used by the daemon, the address of its profiling endpoints when they
+// are enabled and, when the connections are created on demand, the number of peers with a connection allocated
+func parseResources(resources resourcesOutput) string {
+	summary := fmt.Sprintf("Memory: %s heap, %s total\n",
+		toIEC(int64(resources.HeapAllocBytes)), toIEC(int64(resources.SysBytes)))
+	summary += fmt.Sprintf("CPU: %.1f%%, %d goroutines\n", resources.CPUPercent, resources.Goroutines)
+	if resources.ProfilingAddress != "" {
+		summary += fmt.Sprintf("Profiling: http://%s/debug/pprof/\n", resources.ProfilingAddress)
+	}
+	if !resources.LazyConnections {
+		return summary
+	}
+	return summary + fmt.Sprintf("Peer connections: %d/%d allocated (created on demand)\n",
+		resources.MaterializedPeers, resources.Peers)
+}
+
 func parseToFullDetailSummary(overview statusOutputOverview) string {
 	parsedPeersString := parsePeers(overview.Peers)
 	summary := parseGeneralSummary(overview, true)
@@ -386,12 +590,30 @@ func parseToFullDetailSummary(overview statusOutputOverview) string {
 	return fmt.Sprintf(
 		"Peers detail:"+
 			"%s\n"+
+			"%s"+
 			"%s",
 		parsedPeersString,
+		parseRoutes(overview.Routes),
 		summary,
 	)
 }
 
+func parseRoutes(routes []routeOutput) string {
+	if len(routes) == 0 {
+		return ""
+	}
+
+	routesString := "Routes:\n"
+	for _, route := range routes {
+		balanced := ""
+		if route.LoadBalanced {
+			balanced = " (load balanced)"
+		}
+		routesString += fmt.Sprintf(" %s %s via %s%s\n", route.NetworkID, route.Network, route.Peer, balanced)
+	}
+	return routesString + "\n"
+}
+
 func parsePeers(peers peersStateOutput) string {
 	var (
 		peersString = ""
@@ -409,25 +631,57 @@ func parsePeers(peers peersStateOutput) string {
 			remoteICE = peerState.IceCandidateType.Remote
 		}
 
+		groups := "-"
+		if len(peerState.Groups) > 0 {
+			groups = strings.Join(peerState.Groups, ", ")
+		}
+
+		// the labels are only shown when the peer has some, most peers don't
+		labels := ""
+		if len(peerState.Labels) > 0 {
+			labels = fmt.Sprintf("  Labels: %s\n", formatLabels(peerState.Labels))
+		}
+
+		connStatus := peerState.Status
+		if peerState.Lazy {
+			connStatus += " (connection created on demand)"
+		}
+
+		lastHandshake := "-"
+		if !peerState.LastWireguardHandshake.IsZero() {
+			lastHandshake = peerState.LastWireguardHandshake.Format("2006-01-02 15:04:05")
+		}
+
 		peerString := fmt.Sprintf(
 			"\n %s:\n"+
 				"  NetBird IP: %s\n"+
 				"  Public key: %s\n"+
+				"  Groups: %s\n"+
+				"%s"+
 				"  Status: %s\n"+
 				"  -- detail --\n"+
 				"  Connection type: %s\n"+
 				"  Direct: %t\n"+
 				"  ICE candidate (Local/Remote): %s/%s\n"+
-				"  Last connection update: %s\n",
+				"  Last connection update: %s\n"+
+				"  Last WireGuard handshake: %s\n"+
+				"  Transfer status (received/sent): %s/%s\n"+
+				"  ICE restarts: %d\n",
 			peerState.FQDN,
 			peerState.IP,
 			peerState.PubKey,
-			peerState.Status,
+			groups,
+			labels,
+			connStatus,
 			peerState.ConnType,
 			peerState.Direct,
 			localICE,
 			remoteICE,
 			peerState.LastStatusUpdate.Format("2006-01-02 15:04:05"),
+			lastHandshake,
+			toIEC(peerState.TransferReceived),
+			toIEC(peerState.TransferSent),
+			peerState.IceRestarts,
 		)
 
 		peersString += peerString
@@ -435,10 +689,73 @@ func parsePeers(peers peersStateOutput) string {
 	return peersString
 }
 
+// formatLabels returns the labels as key=value pairs sorted by key
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+labels[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// toIEC formats a number of bytes with the binary prefixes, e.g. 1.5 KiB
+func toIEC(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+func parseComponents(components []componentOutput) string {
+	if len(components) == 0 {
+		return "\nComponents health: N/A, the client is not running\n"
+	}
+
+	componentsString := "\nComponents health:\n"
+	for _, component := range components {
+		healthString := "Healthy"
+		if !component.Healthy {
+			healthString = "Unhealthy"
+		}
+
+		lastSeen := "-"
+		if !component.LastSeen.IsZero() {
+			lastSeen = component.LastSeen.Format("2006-01-02 15:04:05")
+		}
+
+		componentsString += fmt.Sprintf(
+			" %s: %s\n"+
+				"  Last seen: %s\n"+
+				"  Restarts: %d\n",
+			component.Name,
+			healthString,
+			lastSeen,
+			component.Restarts,
+		)
+		if component.LastErrorAt != nil {
+			componentsString += fmt.Sprintf("  Last error: %s (%s)\n", component.LastError, component.LastErrorAt.Format("2006-01-02 15:04:05"))
+		}
+	}
+	return componentsString
+}
+
 func skipDetailByFilters(peerState *proto.PeerState, isConnected bool) bool {
 	statusEval := false
 	ipEval := false
 	nameEval := false
+	groupEval := len(groupsFilter) > 0
 
 	if statusFilter != "" {
 		lowerStatusFilter := strings.ToLower(statusFilter)
@@ -465,5 +782,20 @@ func skipDetailByFilters(peerState *proto.PeerState, isConnected bool) bool {
 		}
 	}
 
-	return statusEval || ipEval || nameEval
+	for _, group := range peerState.GetGroups() {
+		if _, ok := groupsFilterMap[group]; ok {
+			groupEval = false
+			break
+		}
+	}
+
+	labelEval := false
+	for key, value := range labelsFilterMap {
+		if labelValue, ok := peerState.GetLabels()[key]; !ok || labelValue != value {
+			labelEval = true
+			break
+		}
+	}
+
+	return statusEval || ipEval || nameEval || groupEval || labelEval
 }
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FlintyLemming/netbird/client/proto"
@@ -34,6 +36,11 @@ var resp = &proto.StatusResponse{
 				Direct:                 true,
 				LocalIceCandidateType:  "",
 				RemoteIceCandidateType: "",
+				Groups:                 []string{"all", "servers"},
+				LastWireguardHandshake: timestamppb.New(time.Date(2001, time.Month(1), 1, 1, 1, 2, 0, time.UTC)),
+				BytesRx:                200,
+				BytesTx:                1536,
+				IceRestarts:            2,
 			},
 			{
 				IP:                     "192.168.178.102",
@@ -82,6 +89,11 @@ var overview = statusOutputOverview{
 					Local:  "",
 					Remote: "",
 				},
+				Groups:                 []string{"all", "servers"},
+				LastWireguardHandshake: time.Date(2001, 1, 1, 1, 1, 2, 0, time.UTC),
+				TransferReceived:       200,
+				TransferSent:           1536,
+				IceRestarts:            2,
 			},
 			{
 				IP:               "192.168.178.102",
@@ -167,7 +179,12 @@ func TestParsingToJSON(t *testing.T) {
 		"{" +
 		"\"local\":\"\"," +
 		"\"remote\":\"\"" +
-		"}" +
+		"}," +
+		"\"groups\":[\"all\",\"servers\"]," +
+		"\"lastWireguardHandshake\":\"2001-01-01T01:01:02Z\"," +
+		"\"transferReceived\":200," +
+		"\"transferSent\":1536," +
+		"\"iceRestarts\":2" +
 		"}," +
 		"{" +
 		"\"fqdn\":\"peer-2.awesome-domain.com\"," +
@@ -181,7 +198,11 @@ func TestParsingToJSON(t *testing.T) {
 		"{" +
 		"\"local\":\"relay\"," +
 		"\"remote\":\"prflx\"" +
-		"}" +
+		"}," +
+		"\"lastWireguardHandshake\":\"0001-01-01T00:00:00Z\"," +
+		"\"transferReceived\":0," +
+		"\"transferSent\":0," +
+		"\"iceRestarts\":0" +
 		"}" +
 		"]" +
 		"}," +
@@ -224,6 +245,13 @@ func TestParsingToYAML(t *testing.T) {
 		"          iceCandidateType:\n" +
 		"            local: \"\"\n" +
 		"            remote: \"\"\n" +
+		"          groups:\n" +
+		"            - all\n" +
+		"            - servers\n" +
+		"          lastWireguardHandshake: 2001-01-01T01:01:02Z\n" +
+		"          transferReceived: 200\n" +
+		"          transferSent: 1536\n" +
+		"          iceRestarts: 2\n" +
 		"        - fqdn: peer-2.awesome-domain.com\n" +
 		"          netbirdIp: 192.168.178.102\n" +
 		"          publicKey: Pubkey2\n" +
@@ -234,6 +262,10 @@ func TestParsingToYAML(t *testing.T) {
 		"          iceCandidateType:\n" +
 		"            local: relay\n" +
 		"            remote: prflx\n" +
+		"          lastWireguardHandshake: 0001-01-01T00:00:00Z\n" +
+		"          transferReceived: 0\n" +
+		"          transferSent: 0\n" +
+		"          iceRestarts: 0\n" +
 		"cliVersion: development\n" +
 		"daemonVersion: 0.14.1\n" +
 		"management:\n" +
@@ -257,22 +289,30 @@ func TestParsingToDetail(t *testing.T) {
 		" peer-1.awesome-domain.com:\n" +
 		"  NetBird IP: 192.168.178.101\n" +
 		"  Public key: Pubkey1\n" +
+		"  Groups: all, servers\n" +
 		"  Status: Connected\n" +
 		"  -- detail --\n" +
 		"  Connection type: P2P\n" +
 		"  Direct: true\n" +
 		"  ICE candidate (Local/Remote): -/-\n" +
 		"  Last connection update: 2001-01-01 01:01:01\n" +
+		"  Last WireGuard handshake: 2001-01-01 01:01:02\n" +
+		"  Transfer status (received/sent): 200 B/1.5 KiB\n" +
+		"  ICE restarts: 2\n" +
 		"\n" +
 		" peer-2.awesome-domain.com:\n" +
 		"  NetBird IP: 192.168.178.102\n" +
 		"  Public key: Pubkey2\n" +
+		"  Groups: -\n" +
 		"  Status: Connected\n" +
 		"  -- detail --\n" +
 		"  Connection type: Relayed\n" +
 		"  Direct: false\n" +
 		"  ICE candidate (Local/Remote): relay/prflx\n" +
 		"  Last connection update: 2002-02-02 02:02:02\n" +
+		"  Last WireGuard handshake: -\n" +
+		"  Transfer status (received/sent): 0 B/0 B\n" +
+		"  ICE restarts: 0\n" +
 		"\n" +
 		"Daemon version: 0.14.1\n" +
 		"CLI version: development\n" +
@@ -301,6 +341,83 @@ func TestParsingToShortVersion(t *testing.T) {
 	assert.Equal(t, expectedString, shortVersion)
 }
 
+func TestParsingToShortVersionLoginExpired(t *testing.T) {
+	expiredOverview := overview
+	expiredOverview.ManagementState.LoginExpired = true
+
+	shortVersion := parseGeneralSummary(expiredOverview, false)
+
+	assert.Contains(t, shortVersion, "Management: Connected (login expired, run netbird up to log in again)\n")
+}
+
+func TestParsingToShortVersionMaintenance(t *testing.T) {
+	maintenanceUntil := time.Now().Add(10 * time.Minute)
+	maintenanceOverview := overview
+	maintenanceOverview.ManagementState.Connected = false
+	maintenanceOverview.ManagementState.MaintenanceUntil = &maintenanceUntil
+	maintenanceOverview.ManagementState.MaintenanceMessage = "upgrading"
+
+	shortVersion := parseGeneralSummary(maintenanceOverview, false)
+
+	assert.Contains(t, shortVersion, fmt.Sprintf("Management: Maintenance until %s (upgrading), keeping the last configuration\n",
+		maintenanceUntil.Format("15:04")))
+}
+
+func TestParsingToShortVersionAllowedLocalPorts(t *testing.T) {
+	localPortsOverview := overview
+	localPortsOverview.AllowedLocalPorts = []string{"tcp/9100", "udp/161"}
+
+	shortVersion := parseGeneralSummary(localPortsOverview, false)
+
+	assert.Contains(t, shortVersion,
+		"Allowed local ports: tcp/9100, udp/161 (accepted before the policies and the default deny mode)\n")
+}
+
+func TestParsingToShortVersionResources(t *testing.T) {
+	resourcesOverview := overview
+	resourcesOverview.Resources = &resourcesOutput{
+		HeapAllocBytes:   10 << 20,
+		SysBytes:         30 << 20,
+		CPUPercent:       12.34,
+		Goroutines:       42,
+		ProfilingAddress: "127.0.0.1:6060",
+	}
+
+	shortVersion := parseGeneralSummary(resourcesOverview, false)
+
+	assert.Contains(t, shortVersion, "CPU: 12.3%, 42 goroutines\n")
+	assert.Contains(t, shortVersion, "Profiling: http://127.0.0.1:6060/debug/pprof/\n")
+	assert.NotContains(t, shortVersion, "Peer connections")
+}
+
+func TestParsingComponents(t *testing.T) {
+	lastErrorAt := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	components := mapComponents([]*proto.ComponentHealth{
+		{
+			Name:     "dns",
+			Healthy:  true,
+			LastSeen: timestamppb.New(time.Date(2024, time.March, 4, 11, 0, 0, 0, time.UTC)),
+		},
+		{
+			Name:        "routes",
+			LastError:   "panic: nil map",
+			LastErrorAt: timestamppb.New(lastErrorAt),
+			Restarts:    1,
+		},
+	})
+
+	require.Len(t, components, 2)
+	assert.True(t, components[1].LastSeen.IsZero())
+	require.NotNil(t, components[1].LastErrorAt)
+	assert.True(t, lastErrorAt.Equal(*components[1].LastErrorAt))
+
+	parsed := parseComponents(components)
+
+	assert.Contains(t, parsed, " dns: Healthy\n")
+	assert.Contains(t, parsed, " routes: Unhealthy\n  Last seen: -\n  Restarts: 1\n  Last error: panic: nil map")
+	assert.Equal(t, "\nComponents health: N/A, the client is not running\n", parseComponents(nil))
+}
+
 func TestParsingOfIP(t *testing.T) {
 	InterfaceIP := "192.168.178.123/16"
 
@@ -308,3 +425,50 @@ func TestParsingOfIP(t *testing.T) {
 
 	assert.Equal(t, "192.168.178.123\n", parsedIP)
 }
+
+func TestFilteringPeersByGroups(t *testing.T) {
+	groupsFilter = []string{"servers"}
+	groupsFilterMap = map[string]struct{}{"servers": {}}
+	defer func() {
+		groupsFilter = []string{}
+		groupsFilterMap = make(map[string]struct{})
+	}()
+
+	peers := mapPeers(resp.GetFullStatus().GetPeers())
+
+	require.Len(t, peers.Details, 1)
+	assert.Equal(t, "peer-1.awesome-domain.com", peers.Details[0].FQDN)
+}
+
+func TestFilteringPeersByLabels(t *testing.T) {
+	labelsFilter = []string{"env=prod"}
+	labelsFilterMap = map[string]string{"env": "prod"}
+	defer func() {
+		labelsFilter = []string{}
+		labelsFilterMap = make(map[string]string)
+	}()
+
+	peers := []*proto.PeerState{
+		{IP: "192.168.178.101", Fqdn: "db.awesome-domain.com", Labels: map[string]string{"env": "prod", "role": "db"}},
+		{IP: "192.168.178.102", Fqdn: "web.awesome-domain.com", Labels: map[string]string{"env": "stage"}},
+		{IP: "192.168.178.103", Fqdn: "laptop.awesome-domain.com"},
+	}
+
+	filtered := mapPeers(peers)
+	require.Len(t, filtered.Details, 1)
+	assert.Equal(t, "db.awesome-domain.com", filtered.Details[0].FQDN)
+	assert.Equal(t, map[string]string{"env": "prod", "role": "db"}, filtered.Details[0].Labels)
+
+	labelsFilterMap["role"] = "web"
+	assert.Empty(t, mapPeers(peers).Details, "the peers should have all the labels of the filter")
+}
+
+func TestParsingLabelsToDetail(t *testing.T) {
+	detail := parsePeers(peersStateOutput{
+		Details: []peerStateDetailOutput{
+			{FQDN: "db.awesome-domain.com", Labels: map[string]string{"role": "db", "env": "prod"}},
+		},
+	})
+
+	assert.Contains(t, detail, "  Groups: -\n  Labels: env=prod, role=db\n  Status: ")
+}
@@ -51,7 +51,11 @@ func startSignal(t *testing.T) (*grpc.Server, net.Listener) {
 		t.Fatal(err)
 	}
 	s := grpc.NewServer()
-	sigProto.RegisterSignalExchangeServer(s, sig.NewServer())
+	signalServer, err := sig.NewServer("", sig.RateLimitConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigProto.RegisterSignalExchangeServer(s, signalServer)
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			panic(err)
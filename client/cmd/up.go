@@ -12,10 +12,12 @@ import (
 	"google.golang.org/grpc/codes"
 	gstatus "google.golang.org/grpc/status"
 
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/client/internal"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
 	"github.com/FlintyLemming/netbird/client/proto"
 	"github.com/FlintyLemming/netbird/client/system"
+	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/util"
 )
 
@@ -44,6 +46,10 @@ func upFunc(cmd *cobra.Command, args []string) error {
 
 	cmd.SetOut(cmd.OutOrStdout())
 
+	if err := applyApplianceMode(cmd); err != nil {
+		return err
+	}
+
 	err := util.InitLog(logLevel, "console")
 	if err != nil {
 		return fmt.Errorf("failed initializing log %v", err)
@@ -54,6 +60,24 @@ func upFunc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if _, err := firewall.ParseLocalPorts(allowedLocalPorts); err != nil {
+		return fmt.Errorf("invalid %s: %w", localPortsFlag, err)
+	}
+
+	if _, err := system.ParseServices(advertisedServices); err != nil {
+		return fmt.Errorf("invalid %s: %w", servicesFlag, err)
+	}
+
+	if cmd.Flag(interfaceNameFlag).Changed {
+		if err := iface.ValidateInterfaceName(interfaceName); err != nil {
+			return fmt.Errorf("invalid %s: %w", interfaceNameFlag, err)
+		}
+	}
+
+	if err := validateExtraAddresses(extraAddresses); err != nil {
+		return fmt.Errorf("invalid %s: %w", extraAddrsFlag, err)
+	}
+
 	ctx := internal.CtxInitState(cmd.Context())
 
 	if hostName != "" {
@@ -73,23 +97,74 @@ func runInForegroundMode(ctx context.Context, cmd *cobra.Command) error {
 		return err
 	}
 
+	stopTracing := initTracing()
+	defer stopTracing()
+
 	customDNSAddressConverted, err := parseCustomDNSAddress(cmd.Flag(dnsResolverAddress).Changed)
 	if err != nil {
 		return err
 	}
 
 	ic := internal.ConfigInput{
-		ManagementURL:    managementURL,
-		AdminURL:         adminURL,
-		ConfigPath:       configPath,
-		NATExternalIPs:   natExternalIPs,
-		CustomDNSAddress: customDNSAddressConverted,
+		ManagementURL:     managementURL,
+		AdminURL:          adminURL,
+		ConfigPath:        configPath,
+		NATExternalIPs:    natExternalIPs,
+		CustomDNSAddress:  customDNSAddressConverted,
+		AllowedLocalPorts: allowedLocalPorts,
+		Services:          advertisedServices,
+		ExtraAddresses:    extraAddresses,
+		PriorityPeers:     priorityPeers,
 	}
 
 	if rootCmd.PersistentFlags().Changed(preSharedKeyFlag) {
 		ic.PreSharedKey = &preSharedKey
 	}
 
+	if cmd.Flag(postQuantumFlag).Changed {
+		ic.PostQuantumEnabled = &postQuantumEnabled
+	}
+
+	if cmd.Flag(flowCollectorFlag).Changed {
+		ic.FlowCollectorURL = &flowCollectorURL
+	}
+
+	if cmd.Flag(bgpImportFlag).Changed {
+		ic.ImportBGPRoutes = &importBGPRoutes
+	}
+
+	if cmd.Flag(maxConnectionsFlag).Changed {
+		ic.MaxConcurrentConnections = &maxConnections
+	}
+
+	if cmd.Flag(defaultDenyFlag).Changed {
+		ic.DefaultDeny = &defaultDeny
+	}
+
+	if cmd.Flag(sharedConnFlag).Changed {
+		ic.DisableSharedConnection = &disableSharedConn
+	}
+
+	if cmd.Flag(lanRoutesFlag).Changed {
+		ic.DetectLANRoutes = &detectLANRoutes
+	}
+
+	if cmd.Flag(lazyConnFlag).Changed {
+		ic.LazyConnectionThreshold = &lazyConnThreshold
+	}
+
+	if cmd.Flag(strictDNSFlag).Changed {
+		ic.StrictDNS = &strictDNS
+	}
+
+	if cmd.Flag(killSwitchFlag).Changed {
+		ic.KillSwitch = &killSwitch
+	}
+
+	if cmd.Flag(interfaceNameFlag).Changed {
+		ic.InterfaceName = &interfaceName
+	}
+
 	config, err := internal.UpdateOrCreateConfig(ic)
 	if err != nil {
 		return fmt.Errorf("get config file: %v", err)
@@ -105,7 +180,7 @@ func runInForegroundMode(ctx context.Context, cmd *cobra.Command) error {
 	var cancel context.CancelFunc
 	ctx, cancel = context.WithCancel(ctx)
 	SetupCloseHandler(ctx, cancel)
-	return internal.RunClient(ctx, config, peer.NewRecorder(config.ManagementURL.String()))
+	return internal.RunClient(ctx, config, peer.NewRecorder(config.ManagementURL.String()), nil)
 }
 
 func runInDaemonMode(ctx context.Context, cmd *cobra.Command) error {
@@ -136,21 +211,76 @@ func runInDaemonMode(ctx context.Context, cmd *cobra.Command) error {
 		return fmt.Errorf("unable to get daemon status: %v", err)
 	}
 
-	if status.Status == string(internal.StatusConnected) {
+	// a connected peer with an expired login goes through the login flow again
+	if status.Status == string(internal.StatusConnected) && !status.GetLoginExpired() {
 		cmd.Println("Already connected")
 		return nil
 	}
 
 	loginRequest := proto.LoginRequest{
-		SetupKey:             setupKey,
-		PreSharedKey:         preSharedKey,
-		ManagementUrl:        managementURL,
-		AdminURL:             adminURL,
-		NatExternalIPs:       natExternalIPs,
-		CleanNATExternalIPs:  natExternalIPs != nil && len(natExternalIPs) == 0,
-		CustomDNSAddress:     customDNSAddressConverted,
-		IsLinuxDesktopClient: isLinuxRunningDesktop(),
-		Hostname:             hostName,
+		SetupKey:               setupKey,
+		PreSharedKey:           preSharedKey,
+		ManagementUrl:          managementURL,
+		AdminURL:               adminURL,
+		NatExternalIPs:         natExternalIPs,
+		CleanNATExternalIPs:    natExternalIPs != nil && len(natExternalIPs) == 0,
+		CustomDNSAddress:       customDNSAddressConverted,
+		IsLinuxDesktopClient:   isLinuxRunningDesktop(),
+		Hostname:               hostName,
+		AllowedLocalPorts:      allowedLocalPorts,
+		CleanAllowedLocalPorts: allowedLocalPorts != nil && len(allowedLocalPorts) == 0,
+		Services:               advertisedServices,
+		CleanServices:          advertisedServices != nil && len(advertisedServices) == 0,
+		ExtraAddresses:         extraAddresses,
+		CleanExtraAddresses:    extraAddresses != nil && len(extraAddresses) == 0,
+		PriorityPeers:          priorityPeers,
+		CleanPriorityPeers:     priorityPeers != nil && len(priorityPeers) == 0,
+	}
+
+	if cmd.Flag(postQuantumFlag).Changed {
+		loginRequest.PostQuantumEnabled = &postQuantumEnabled
+	}
+
+	if cmd.Flag(flowCollectorFlag).Changed {
+		loginRequest.FlowCollectorURL = &flowCollectorURL
+	}
+
+	if cmd.Flag(bgpImportFlag).Changed {
+		loginRequest.ImportBGPRoutes = &importBGPRoutes
+	}
+
+	if cmd.Flag(maxConnectionsFlag).Changed {
+		maxConnections := int32(maxConnections)
+		loginRequest.MaxConcurrentConnections = &maxConnections
+	}
+
+	if cmd.Flag(defaultDenyFlag).Changed {
+		loginRequest.DefaultDeny = &defaultDeny
+	}
+
+	if cmd.Flag(sharedConnFlag).Changed {
+		loginRequest.DisableSharedConnection = &disableSharedConn
+	}
+
+	if cmd.Flag(lanRoutesFlag).Changed {
+		loginRequest.DetectLANRoutes = &detectLANRoutes
+	}
+
+	if cmd.Flag(lazyConnFlag).Changed {
+		lazyConnThreshold := int32(lazyConnThreshold)
+		loginRequest.LazyConnectionThreshold = &lazyConnThreshold
+	}
+
+	if cmd.Flag(strictDNSFlag).Changed {
+		loginRequest.StrictDNS = &strictDNS
+	}
+
+	if cmd.Flag(killSwitchFlag).Changed {
+		loginRequest.KillSwitch = &killSwitch
+	}
+
+	if cmd.Flag(interfaceNameFlag).Changed {
+		loginRequest.InterfaceName = &interfaceName
 	}
 
 	var loginErr error
@@ -224,6 +354,16 @@ func validateNATExternalIPs(list []string) error {
 	return nil
 }
 
+// validateExtraAddresses checks that the extra interface addresses are in the CIDR format
+func validateExtraAddresses(list []string) error {
+	for _, element := range list {
+		if _, err := netip.ParsePrefix(element); err != nil {
+			return fmt.Errorf("%s is not a valid address, it should be formatted as \"IP/prefix length\"", element)
+		}
+	}
+	return nil
+}
+
 func validateElement(element string) (int, error) {
 	if isValidIP(element) {
 		return ipInputType, nil
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var (
+	usageDays  int
+	usagePeers bool
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show the daily traffic over the NetBird interface",
+	Long: "Show the bytes received and sent over the NetBird interface per day, in total and optionally per peer. " +
+		"The days are in UTC and the usage is kept across restarts of the daemon for up to 90 days",
+	Example: "  netbird usage --days 30 --peers",
+	RunE:    showUsage,
+}
+
+func init() {
+	usageCmd.Flags().IntVar(&usageDays, "days", 7, "show the usage of the given number of latest days, 0 shows all kept days")
+	usageCmd.Flags().BoolVar(&usagePeers, "peers", false, "show the usage of every peer")
+}
+
+func showUsage(cmd *cobra.Command, _ []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).GetUsage(ctx, &proto.GetUsageRequest{Days: int32(usageDays)})
+	if err != nil {
+		return fmt.Errorf("get usage: %v", status.Convert(err).Message())
+	}
+
+	if len(resp.GetDays()) == 0 {
+		cmd.Println("No usage recorded")
+		return nil
+	}
+
+	cmd.Print(formatUsage(resp.GetDays(), usagePeers))
+	return nil
+}
+
+// formatUsage returns a table of the received and sent bytes per day, followed by the total of the days
+func formatUsage(days []*proto.DailyUsage, peers bool) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%-40s %12s %12s\n", "Date", "Received", "Sent")
+
+	var totalRx, totalTx int64
+	for _, day := range days {
+		fmt.Fprintf(&builder, "%-40s %12s %12s\n", day.GetDate(), toIEC(day.GetBytesRx()), toIEC(day.GetBytesTx()))
+		totalRx += day.GetBytesRx()
+		totalTx += day.GetBytesTx()
+
+		if !peers {
+			continue
+		}
+		for _, peerUsage := range day.GetPeers() {
+			name := peerUsage.GetFqdn()
+			if name == "" {
+				name = peerUsage.GetPubKey()
+			}
+			fmt.Fprintf(&builder, "  %-38s %12s %12s\n", name, toIEC(peerUsage.GetBytesRx()), toIEC(peerUsage.GetBytesTx()))
+		}
+	}
+
+	fmt.Fprintf(&builder, "%-40s %12s %12s\n", "Total", toIEC(totalRx), toIEC(totalTx))
+	return builder.String()
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+func TestFormatUsage(t *testing.T) {
+	days := []*proto.DailyUsage{
+		{
+			Date:    "2023-11-20",
+			BytesRx: 2048,
+			BytesTx: 512,
+			Peers: []*proto.PeerUsage{
+				{PubKey: "key-a", Fqdn: "peer-a.netbird.cloud", BytesRx: 2048, BytesTx: 0},
+				{PubKey: "key-b", BytesTx: 512},
+			},
+		},
+		{Date: "2023-11-21", BytesRx: 1024, BytesTx: 1024},
+	}
+
+	expected := "" +
+		"Date                                         Received         Sent\n" +
+		"2023-11-20                                    2.0 KiB        512 B\n" +
+		"  peer-a.netbird.cloud                        2.0 KiB          0 B\n" +
+		"  key-b                                           0 B        512 B\n" +
+		"2023-11-21                                    1.0 KiB      1.0 KiB\n" +
+		"Total                                         3.0 KiB      1.5 KiB\n"
+	assert.Equal(t, expected, formatUsage(days, true))
+
+	assert.NotContains(t, formatUsage(days, false), "peer-a", "the peers should be shown on request only")
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/internal/wol"
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+var wolVia string
+
+var wolCmd = &cobra.Command{
+	Use:   "wol <mac>",
+	Short: "Wake up a machine on a network routed by a peer",
+	Long: "Send a Wake-on-LAN magic packet to a routing peer, which broadcasts it to the networks it routes. " +
+		"The access control policies must allow this peer to reach UDP port 9 of the routing peer",
+	Example: "  netbird wol 00:11:22:33:44:55 --via office-router",
+	Args:    cobra.ExactArgs(1),
+	RunE:    wakeOnLAN,
+}
+
+func init() {
+	wolCmd.Flags().StringVar(&wolVia, "via", "", "FQDN, DNS label or NetBird IP of the routing peer on the network of the machine")
+	_ = wolCmd.MarkFlagRequired("via")
+}
+
+func wakeOnLAN(cmd *cobra.Command, args []string) error {
+	SetFlagsFromEnvVars(rootCmd)
+
+	cmd.SetOut(cmd.OutOrStdout())
+
+	if _, err := wol.ParseMAC(args[0]); err != nil {
+		return fmt.Errorf("invalid MAC address %s, expected a format like 00:11:22:33:44:55", args[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	conn, err := DialClientGRPCServer(ctx, daemonAddr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to daemon error: %v\n"+
+			"If the daemon is not running please run: "+
+			"\nnetbird service install \nnetbird service start\n", err)
+	}
+	defer conn.Close()
+
+	resp, err := proto.NewDaemonServiceClient(conn).WakeOnLAN(ctx, &proto.WakeOnLANRequest{
+		Mac: args[0],
+		Via: wolVia,
+	})
+	if err != nil {
+		return fmt.Errorf("wake on LAN: %v", status.Convert(err).Message())
+	}
+
+	cmd.Printf("Magic packet for %s sent to %s (%s)\n", args[0], wolVia, resp.GetRelayIP())
+	return nil
+}
@@ -0,0 +1,109 @@
+// Package extension lets modules compiled into the client follow the engine and contribute firewall rules without
+// changes to the engine itself.
+//
+// An extension registers a factory from the init function of its package:
+//
+//	func init() {
+//		extension.Register("audit", func(ctx context.Context) (extension.Extension, error) {
+//			return &auditExtension{}, nil
+//		})
+//	}
+//
+// and the package is linked into the client with a blank import from a separate file of the client/cmd package. Each
+// engine creates the registered extensions and hands them the events of the hooks they implement: NetworkMapObserver,
+// PeerStateObserver, DNSQueryObserver and FirewallRuleProvider.
+package extension
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/miekg/dns"
+
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// Extension is a module compiled into the client
+type Extension interface {
+	// Name identifies the extension in the logs
+	Name() string
+}
+
+// Factory creates an extension when the engine starts. The context is canceled when the engine stops
+type Factory func(ctx context.Context) (Extension, error)
+
+// NetworkMapObserver is notified of each network map applied by the engine
+type NetworkMapObserver interface {
+	OnNetworkMap(networkMap *mgmProto.NetworkMap)
+}
+
+// PeerStateObserver is notified when the connection status of a remote peer changes
+type PeerStateObserver interface {
+	OnPeerStateChanged(state PeerState)
+}
+
+// DNSQueryObserver is notified of each query served by the DNS server of the client
+type DNSQueryObserver interface {
+	// OnDNSQuery receives the query and the response sent to the client, the response is nil when the query wasn't
+	// answered
+	OnDNSQuery(query, response *dns.Msg)
+}
+
+// FirewallRuleProvider contributes firewall rules applied along with the rules of the network map
+type FirewallRuleProvider interface {
+	// FirewallRules returns the rules to apply for the network map. It is called synchronously each time the
+	// firewall rules are applied and must return quickly
+	FirewallRules(networkMap *mgmProto.NetworkMap) []*mgmProto.FirewallRule
+}
+
+// Closer is implemented by the extensions releasing resources when the engine stops
+type Closer interface {
+	Close() error
+}
+
+// PeerState is the connection state of a remote peer
+type PeerState struct {
+	PubKey string
+	FQDN   string
+	IP     string
+	// Status is one of Idle, Connecting, Connected or Disconnected
+	Status  string
+	Relayed bool
+	Direct  bool
+	Groups  []string
+}
+
+var (
+	factoriesMu sync.Mutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes an extension available to the engine. It is meant to be called from an init function and panics
+// when the name is registered twice or the factory is nil
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil {
+		panic("extension: register factory is nil for " + name)
+	}
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("extension: register called twice for %s", name))
+	}
+	factories[name] = factory
+}
+
+// Registered returns the sorted names of the registered extensions
+func Registered() []string {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package extension
+
+import (
+	"context"
+	"runtime/debug"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/client/internal/eventbus"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// eventQueueSize is the number of events of a kind waiting for the extensions before new events are dropped
+const eventQueueSize = 256
+
+// Manager runs the registered extensions for the engine. The extensions observe the events of the engine bus, each
+// kind of event is handed to them one at a time from a separate goroutine, so that a slow extension doesn't hold the
+// engine back
+type Manager struct {
+	extensions    []Extension
+	subscriptions []interface{ Close() }
+	ruleProviders []FirewallRuleProvider
+}
+
+// NewManager creates the registered extensions, the extensions failing to start are skipped.
+// The events of the bus are dispatched until the context of the bus is done or the manager is closed
+func NewManager(ctx context.Context, bus *eventbus.Bus) *Manager {
+	var extensions []Extension
+	for _, name := range Registered() {
+		factoriesMu.Lock()
+		factory := factories[name]
+		factoriesMu.Unlock()
+
+		ext, err := factory(ctx)
+		if err != nil {
+			log.Errorf("failed starting extension %s: %v", name, err)
+			continue
+		}
+		log.Infof("started extension %s", ext.Name())
+		extensions = append(extensions, ext)
+	}
+
+	return newManager(bus, extensions)
+}
+
+func newManager(bus *eventbus.Bus, extensions []Extension) *Manager {
+	m := &Manager{extensions: extensions}
+
+	var (
+		networkMapObservers []NetworkMapObserver
+		peerStateObservers  []PeerStateObserver
+		dnsQueryObservers   []DNSQueryObserver
+	)
+	for _, ext := range extensions {
+		if o, ok := ext.(NetworkMapObserver); ok {
+			networkMapObservers = append(networkMapObservers, o)
+		}
+		if o, ok := ext.(PeerStateObserver); ok {
+			peerStateObservers = append(peerStateObservers, o)
+		}
+		if o, ok := ext.(DNSQueryObserver); ok {
+			dnsQueryObservers = append(dnsQueryObservers, o)
+		}
+		if p, ok := ext.(FirewallRuleProvider); ok {
+			m.ruleProviders = append(m.ruleProviders, p)
+		}
+	}
+
+	opts := eventbus.Options{QueueSize: eventQueueSize}
+	if len(networkMapObservers) > 0 {
+		m.subscriptions = append(m.subscriptions, eventbus.SubscribeFunc(bus, "extensions", opts, func(event eventbus.NetworkMapEvent) {
+			for _, o := range networkMapObservers {
+				m.safeCall(o, func() { o.OnNetworkMap(event.NetworkMap) })
+			}
+		}))
+	}
+	if len(peerStateObservers) > 0 {
+		m.subscriptions = append(m.subscriptions, eventbus.SubscribeFunc(bus, "extensions", opts, func(event eventbus.PeerStateEvent) {
+			state := PeerState(event)
+			for _, o := range peerStateObservers {
+				m.safeCall(o, func() { o.OnPeerStateChanged(state) })
+			}
+		}))
+	}
+	if len(dnsQueryObservers) > 0 {
+		m.subscriptions = append(m.subscriptions, eventbus.SubscribeFunc(bus, "extensions", opts, func(event eventbus.DNSQueryEvent) {
+			for _, o := range dnsQueryObservers {
+				m.safeCall(o, func() { o.OnDNSQuery(event.Query, event.Response) })
+			}
+		}))
+	}
+	return m
+}
+
+// FirewallRules returns the firewall rules contributed by the extensions for the network map
+func (m *Manager) FirewallRules(networkMap *mgmProto.NetworkMap) []*mgmProto.FirewallRule {
+	var rules []*mgmProto.FirewallRule
+	for _, p := range m.ruleProviders {
+		m.safeCall(p, func() { rules = append(rules, p.FirewallRules(networkMap)...) })
+	}
+	return rules
+}
+
+// Close stops observing the events and closes the extensions implementing Closer
+func (m *Manager) Close() {
+	for _, sub := range m.subscriptions {
+		sub.Close()
+	}
+	for _, ext := range m.extensions {
+		c, ok := ext.(Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			log.Warnf("failed closing extension %s: %v", ext.Name(), err)
+		}
+	}
+}
+
+// safeCall calls the hook of an extension, a panic is logged instead of crashing the client
+func (m *Manager) safeCall(hook any, call func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			name := "unknown"
+			if ext, ok := hook.(Extension); ok {
+				name = ext.Name()
+			}
+			log.Errorf("extension %s panicked: %v\n%s", name, r, debug.Stack())
+		}
+	}()
+	call()
+}
@@ -0,0 +1,134 @@
+package extension
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/client/internal/eventbus"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+type testExtension struct {
+	name       string
+	networkMap chan *mgmProto.NetworkMap
+	peerStates chan PeerState
+	queries    chan *dns.Msg
+	rules      []*mgmProto.FirewallRule
+	closed     bool
+}
+
+func newTestExtension(name string) *testExtension {
+	return &testExtension{
+		name:       name,
+		networkMap: make(chan *mgmProto.NetworkMap, 1),
+		peerStates: make(chan PeerState, 1),
+		queries:    make(chan *dns.Msg, 1),
+	}
+}
+
+func (e *testExtension) Name() string { return e.name }
+
+func (e *testExtension) OnNetworkMap(networkMap *mgmProto.NetworkMap) { e.networkMap <- networkMap }
+
+func (e *testExtension) OnPeerStateChanged(state PeerState) { e.peerStates <- state }
+
+func (e *testExtension) OnDNSQuery(query, _ *dns.Msg) { e.queries <- query }
+
+func (e *testExtension) FirewallRules(*mgmProto.NetworkMap) []*mgmProto.FirewallRule { return e.rules }
+
+func (e *testExtension) Close() error {
+	e.closed = true
+	return nil
+}
+
+type panickingExtension struct{}
+
+func (panickingExtension) Name() string { return "panicking" }
+
+func (panickingExtension) OnNetworkMap(*mgmProto.NetworkMap) { panic("boom") }
+
+func (panickingExtension) FirewallRules(*mgmProto.NetworkMap) []*mgmProto.FirewallRule { panic("boom") }
+
+func TestRegister(t *testing.T) {
+	ext := newTestExtension("test")
+	Register("test", func(context.Context) (Extension, error) { return ext, nil })
+	Register("failing", func(context.Context) (Extension, error) { return nil, errors.New("failed") })
+	defer func() {
+		factoriesMu.Lock()
+		delete(factories, "test")
+		delete(factories, "failing")
+		factoriesMu.Unlock()
+	}()
+
+	assert.Equal(t, []string{"failing", "test"}, Registered())
+	assert.Panics(t, func() {
+		Register("test", func(context.Context) (Extension, error) { return ext, nil })
+	}, "registering a name twice should panic")
+	assert.Panics(t, func() { Register("nil", nil) }, "registering a nil factory should panic")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewManager(ctx, eventbus.New(ctx))
+	require.Len(t, m.extensions, 1, "the extension failing to start should be skipped")
+	assert.Same(t, ext, m.extensions[0])
+}
+
+func TestManager_Events(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ext := newTestExtension("test")
+	ext.rules = []*mgmProto.FirewallRule{{PeerIP: "100.64.0.2", Protocol: mgmProto.FirewallRule_TCP, Port: "80"}}
+	bus := eventbus.New(ctx)
+	m := newManager(bus, []Extension{panickingExtension{}, ext})
+
+	networkMap := &mgmProto.NetworkMap{Serial: 1}
+	eventbus.Publish(bus, eventbus.NetworkMapEvent{Serial: 1, NetworkMap: networkMap})
+	select {
+	case received := <-ext.networkMap:
+		assert.Same(t, networkMap, received)
+	case <-time.After(time.Second):
+		t.Fatal("the network map should be handed to the extension despite the panicking one")
+	}
+
+	eventbus.Publish(bus, eventbus.PeerStateEvent{PubKey: "key", Status: "Connected"})
+	select {
+	case state := <-ext.peerStates:
+		assert.Equal(t, "key", state.PubKey)
+	case <-time.After(time.Second):
+		t.Fatal("the peer state should be handed to the extension")
+	}
+
+	assert.True(t, eventbus.HasSubscribers[eventbus.DNSQueryEvent](bus))
+	query := new(dns.Msg).SetQuestion("peer.netbird.cloud.", dns.TypeA)
+	eventbus.Publish(bus, eventbus.DNSQueryEvent{Query: query})
+	select {
+	case received := <-ext.queries:
+		assert.Same(t, query, received)
+	case <-time.After(time.Second):
+		t.Fatal("the DNS query should be handed to the extension")
+	}
+
+	assert.Equal(t, ext.rules, m.FirewallRules(networkMap), "the rules of the panicking extension should be skipped")
+
+	m.Close()
+	assert.True(t, ext.closed)
+	assert.False(t, eventbus.HasSubscribers[eventbus.NetworkMapEvent](bus), "the closed manager should unsubscribe")
+}
+
+func TestManager_WithoutExtensions(t *testing.T) {
+	bus := eventbus.New(context.Background())
+	m := newManager(bus, nil)
+
+	assert.False(t, eventbus.HasSubscribers[eventbus.DNSQueryEvent](bus))
+	assert.False(t, eventbus.HasSubscribers[eventbus.NetworkMapEvent](bus), "no event should be queued without extensions")
+	assert.Empty(t, m.FirewallRules(&mgmProto.NetworkMap{}))
+	m.Close()
+}
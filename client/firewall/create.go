@@ -1,4 +1,4 @@
-//go:build !linux || android
+//go:build (!linux || android) && !freebsd && !openbsd
 
 package firewall
 
@@ -13,8 +13,8 @@ import (
 	"github.com/FlintyLemming/netbird/client/firewall/uspfilter"
 )
 
-// NewFirewall creates a firewall manager instance
-func NewFirewall(context context.Context, iface IFaceMapper) (firewall.Manager, error) {
+// NewFirewall creates a firewall manager instance, the nftables options only apply on Linux
+func NewFirewall(context context.Context, iface IFaceMapper, _ firewall.NftablesOptions) (firewall.Manager, error) {
 	if !iface.IsUserspaceBind() {
 		return nil, fmt.Errorf("not implemented for this OS: %s", runtime.GOOS)
 	}
@@ -30,3 +30,14 @@ func NewFirewall(context context.Context, iface IFaceMapper) (firewall.Manager,
 	}
 	return fm, nil
 }
+
+// DetectCapabilities returns the capabilities of the firewall manager NewFirewall creates, the userspace packet
+// filtering firewall accepts port ranges
+func DetectCapabilities(bool) firewall.Capabilities {
+	return firewall.Capabilities{PortRanges: true, SCTPAndGRE: true}
+}
+
+// NewKillSwitch isn't implemented for this OS
+func NewKillSwitch() (firewall.KillSwitch, error) {
+	return nil, fmt.Errorf("the kill switch is not implemented for this OS: %s", runtime.GOOS)
+}
@@ -0,0 +1,71 @@
+//go:build freebsd || openbsd
+
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/client/firewall/pf"
+	"github.com/FlintyLemming/netbird/client/firewall/uspfilter"
+)
+
+// NewFirewall creates the firewall manager of the system, the nftables options don't apply to pf
+func NewFirewall(context context.Context, iface IFaceMapper, _ firewall.NftablesOptions) (firewall.Manager, error) {
+	// on the BSD systems we use pf, the userspace packet filtering firewall
+	// relies on it for the routing rules and to allow the netbird interface traffic
+	var fm firewall.Manager
+	log.Debug("creating a pf firewall manager")
+	pfManager, errFw := pf.Create(iface)
+	if errFw != nil {
+		log.Errorf("failed to create pf manager: %s", errFw)
+	} else {
+		fm = pfManager
+	}
+
+	if iface.IsUserspaceBind() {
+		var errUsp error
+		if errFw == nil {
+			fm, errUsp = uspfilter.CreateWithNativeFirewall(iface, fm)
+		} else {
+			fm, errUsp = uspfilter.Create(iface)
+		}
+		if errUsp != nil {
+			log.Debugf("failed to create userspace filtering firewall: %s", errUsp)
+			return nil, errUsp
+		}
+
+		if err := fm.AllowNetbird(); err != nil {
+			log.Errorf("failed to allow netbird interface traffic: %v", err)
+		}
+		return fm, nil
+	}
+
+	if errFw != nil {
+		return nil, errFw
+	}
+
+	return fm, nil
+}
+
+// DetectCapabilities returns the capabilities of the firewall manager NewFirewall creates for an interface with the
+// given bind. Both firewall managers accept port ranges, pf matches the peers with tables
+func DetectCapabilities(userspaceBind bool) firewall.Capabilities {
+	capabilities := firewall.Capabilities{PortRanges: true, SCTPAndGRE: true}
+	if userspaceBind {
+		// the rules are enforced by the userspace packet filtering firewall
+		return capabilities
+	}
+
+	capabilities.IPSet = true
+	return capabilities
+}
+
+// NewKillSwitch isn't implemented with pf, the NetBird anchor is flushed when the firewall manager is reset
+func NewKillSwitch() (firewall.KillSwitch, error) {
+	return nil, fmt.Errorf("the kill switch is not implemented for this OS: %s", runtime.GOOS)
+}
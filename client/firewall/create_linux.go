@@ -6,11 +6,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/google/nftables"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/FlintyLemming/netbird/client/firewall/firewalld"
 	nbiptables "github.com/FlintyLemming/netbird/client/firewall/iptables"
 	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
 	nbnftables "github.com/FlintyLemming/netbird/client/firewall/nftables"
@@ -24,15 +27,26 @@ const (
 	IPTABLES
 	// NFTABLES is the value for the nftables firewall type
 	NFTABLES
+	// FIREWALLD is the value for the firewalld firewall type
+	FIREWALLD
 )
 
 // SKIP_NFTABLES_ENV is the environment variable to skip nftables check
 const SKIP_NFTABLES_ENV = "NB_SKIP_NFTABLES_CHECK"
 
+// SKIP_FIREWALLD_ENV is the environment variable to skip firewalld check, the rules are then programmed with nftables
+// or iptables underneath firewalld
+const SKIP_FIREWALLD_ENV = "NB_SKIP_FIREWALLD_CHECK"
+
+// SKIP_UFW_ENV is the environment variable to skip ufw check, the firewall is then chosen as if ufw wasn't active
+const SKIP_UFW_ENV = "NB_SKIP_UFW_CHECK"
+
 // FWType is the type for the firewall type
 type FWType int
 
-func NewFirewall(context context.Context, iface IFaceMapper) (firewall.Manager, error) {
+// NewFirewall creates the firewall manager of the system, the nftables options configure how the nftables firewall
+// manager shares the host firewall
+func NewFirewall(context context.Context, iface IFaceMapper, nftablesOptions firewall.NftablesOptions) (firewall.Manager, error) {
 	// on the linux system we try to user nftables or iptables
 	// in any case, because we need to allow netbird interface traffic
 	// so we use AllowNetbird traffic from these firewall managers
@@ -49,10 +63,16 @@ func NewFirewall(context context.Context, iface IFaceMapper) (firewall.Manager,
 		}
 	case NFTABLES:
 		log.Debug("creating an nftables firewall manager")
-		fm, errFw = nbnftables.Create(context, iface)
+		fm, errFw = nbnftables.CreateWithOptions(context, iface, nftablesOptions)
 		if errFw != nil {
 			log.Errorf("failed to create nftables manager: %s", errFw)
 		}
+	case FIREWALLD:
+		log.Debug("creating a firewalld firewall manager")
+		fm, errFw = firewalld.Create(context, iface)
+		if errFw != nil {
+			log.Errorf("failed to create firewalld manager: %s", errFw)
+		}
 	default:
 		errFw = fmt.Errorf("no firewall manager found")
 		log.Debug("no firewall manager found, try to use userspace packet filtering firewall")
@@ -83,8 +103,65 @@ func NewFirewall(context context.Context, iface IFaceMapper) (firewall.Manager,
 	return fm, nil
 }
 
+// NewKillSwitch creates a kill switch with the firewall NewFirewall would use, the userspace packet filtering
+// firewall can't filter the traffic of the other interfaces. With firewalld, the kill switch uses the firewall
+// underneath it
+func NewKillSwitch() (firewall.KillSwitch, error) {
+	switch checkNative() {
+	case IPTABLES:
+		ks, err := nbiptables.NewKillSwitch()
+		if err != nil {
+			return nil, err
+		}
+		return ks, nil
+	case NFTABLES:
+		ks, err := nbnftables.NewKillSwitch()
+		if err != nil {
+			return nil, err
+		}
+		return ks, nil
+	default:
+		return nil, fmt.Errorf("the kill switch requires iptables or nftables")
+	}
+}
+
+// DetectCapabilities returns the capabilities of the firewall manager NewFirewall creates for an interface with the
+// given bind. All the firewall managers accept port ranges, the native ones but firewalld match the peers with
+// address sets
+func DetectCapabilities(userspaceBind bool) firewall.Capabilities {
+	capabilities := firewall.Capabilities{PortRanges: true, SCTPAndGRE: true}
+	if userspaceBind {
+		// the rules are enforced by the userspace packet filtering firewall
+		return capabilities
+	}
+
+	fwType := check()
+	capabilities.IPSet = fwType != UNKNOWN && fwType != FIREWALLD
+	return capabilities
+}
+
 // check returns the firewall type based on common lib checks. It returns UNKNOWN if no firewall is found.
 func check() FWType {
+	if os.Getenv(SKIP_FIREWALLD_ENV) != "true" && firewalld.IsRunning() {
+		log.Info("firewalld is running, adding the rules through it so that they survive its reloads")
+		return FIREWALLD
+	}
+	return checkNative()
+}
+
+// checkNative returns the type of the firewall below firewalld based on common lib checks. It returns UNKNOWN if no
+// firewall is found.
+func checkNative() FWType {
+	if iptablesLegacyInUse() {
+		log.Info("the system firewall uses iptables-legacy, using iptables to keep the rules in the same tables")
+		return IPTABLES
+	}
+
+	if os.Getenv(SKIP_UFW_ENV) != "true" && nbiptables.IsUFWActive() {
+		log.Info("ufw is active, using iptables to add the NetBird rules ahead of the ufw rules")
+		return IPTABLES
+	}
+
 	nf := nftables.Conn{}
 	if _, err := nf.ListChains(); err == nil && os.Getenv(SKIP_NFTABLES_ENV) != "true" {
 		return NFTABLES
@@ -101,6 +178,38 @@ func check() FWType {
 	return UNKNOWN
 }
 
+// iptablesLegacyInUse returns true when the iptables command uses the legacy backend and the filter table holds rules,
+// e.g. of fw3 on OpenWrt. The packets go through the legacy tables too, so the rules of an nftables table can't
+// accept the traffic they drop
+func iptablesLegacyInUse() bool {
+	out, err := exec.Command("iptables", "--version").Output()
+	if err != nil || !isIptablesLegacy(string(out)) {
+		return false
+	}
+
+	ip, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+	if err != nil {
+		return false
+	}
+	for _, chain := range []string{"INPUT", "FORWARD", "OUTPUT"} {
+		rules, err := ip.List("filter", chain)
+		if err != nil {
+			return false
+		}
+		// the first entry is the policy of the chain
+		if len(rules) > 1 || (len(rules) == 1 && rules[0] != "-P "+chain+" ACCEPT") {
+			return true
+		}
+	}
+	return false
+}
+
+// isIptablesLegacy returns true if the iptables version output reports the legacy backend, the versions before
+// 1.8 only have this backend and don't report it
+func isIptablesLegacy(version string) bool {
+	return !strings.Contains(version, "nf_tables")
+}
+
 func isIptablesClientAvailable(client *iptables.IPTables) bool {
 	_, err := client.ListChains("filter")
 	return err == nil
@@ -0,0 +1,15 @@
+//go:build !android
+
+package firewall
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsIptablesLegacy(t *testing.T) {
+	assert.True(t, isIptablesLegacy("iptables v1.8.7 (legacy)\n"))
+	assert.True(t, isIptablesLegacy("iptables v1.6.2\n"), "the versions before 1.8 have only the legacy backend")
+	assert.False(t, isIptablesLegacy("iptables v1.8.9 (nf_tables)\n"))
+}
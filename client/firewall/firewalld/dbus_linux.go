@@ -0,0 +1,223 @@
+package firewalld
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	dbusDest            = "org.fedoraproject.FirewallD1"
+	dbusPath            = "/org/fedoraproject/FirewallD1"
+	dbusConfigPath      = "/org/fedoraproject/FirewallD1/config"
+	dbusInterface       = "org.fedoraproject.FirewallD1"
+	dbusZoneInterface   = dbusInterface + ".zone"
+	dbusPolicyInterface = dbusInterface + ".policy"
+	dbusConfigInterface = dbusInterface + ".config"
+	dbusStateProperty   = dbusInterface + ".state"
+	dbusReloadedSignal  = "Reloaded"
+
+	// stateRunning is the state of firewalld once it has applied its configuration
+	stateRunning = "RUNNING"
+)
+
+// backend applies the NetBird zone, policies and rich rules to firewalld
+type backend interface {
+	// setupObjects adds the NetBird zone and policies missing in the permanent configuration
+	setupObjects() error
+	// bindInterface moves the interface into the NetBird zone, unbindInterface removes it
+	bindInterface(name string) error
+	unbindInterface(name string) error
+	// richRules returns the rich rules of the zone or the policy in the runtime configuration
+	richRules(object string) ([]string, error)
+	addRichRule(object, rule string) error
+	removeRichRule(object, rule string) error
+	// reloaded returns the channel receiving a value each time firewalld reloads and drops the runtime configuration
+	reloaded() <-chan struct{}
+	close() error
+}
+
+// IsRunning returns true when firewalld is running and reachable over D-Bus
+func IsRunning() bool {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return false
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			log.Debugf("failed to close the D-Bus connection: %v", err)
+		}
+	}()
+
+	state, err := conn.Object(dbusDest, dbusPath).GetProperty(dbusStateProperty)
+	if err != nil {
+		return false
+	}
+	value, ok := state.Value().(string)
+	return ok && value == stateRunning
+}
+
+// dbusBackend talks to firewalld over its D-Bus API
+type dbusBackend struct {
+	conn   *dbus.Conn
+	obj    dbus.BusObject
+	config dbus.BusObject
+
+	signals chan *dbus.Signal
+	reloads chan struct{}
+}
+
+func newDBusBackend() (*dbusBackend, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to the system bus: %w", err)
+	}
+
+	b := &dbusBackend{
+		conn:    conn,
+		obj:     conn.Object(dbusDest, dbusPath),
+		config:  conn.Object(dbusDest, dbusConfigPath),
+		signals: make(chan *dbus.Signal, 10),
+		reloads: make(chan struct{}, 1),
+	}
+
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(dbusPath),
+		dbus.WithMatchInterface(dbusInterface),
+		dbus.WithMatchMember(dbusReloadedSignal),
+	)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("subscribe to the %s signal: %w", dbusReloadedSignal, err)
+	}
+	conn.Signal(b.signals)
+	go b.forwardReloads()
+
+	return b, nil
+}
+
+// forwardReloads notifies the reloads until the connection is closed, the reloads received while the previous one
+// is handled are merged
+func (b *dbusBackend) forwardReloads() {
+	for signal := range b.signals {
+		if signal.Name != dbusInterface+"."+dbusReloadedSignal {
+			continue
+		}
+		select {
+		case b.reloads <- struct{}{}:
+		default:
+		}
+	}
+	close(b.reloads)
+}
+
+func (b *dbusBackend) setupObjects() error {
+	var zones, policies []string
+	if err := b.config.Call(dbusConfigInterface+".getZoneNames", 0).Store(&zones); err != nil {
+		return fmt.Errorf("list the zones: %w", err)
+	}
+	if err := b.config.Call(dbusConfigInterface+".getPolicyNames", 0).Store(&policies); err != nil {
+		return fmt.Errorf("list the policies, firewalld 0.9 or later is required: %w", err)
+	}
+	existing := make(map[string]struct{}, len(zones)+len(policies))
+	for _, name := range append(zones, policies...) {
+		existing[name] = struct{}{}
+	}
+
+	added := false
+	for _, o := range objects {
+		if _, ok := existing[o.name]; ok {
+			continue
+		}
+
+		settings := map[string]dbus.Variant{
+			"short":       dbus.MakeVariant("NetBird"),
+			"description": dbus.MakeVariant("Managed by NetBird, the rules are added while the client is connected"),
+			"target":      dbus.MakeVariant(o.target),
+		}
+		method := dbusConfigInterface + ".addZone2"
+		if !o.zone {
+			method = dbusConfigInterface + ".addPolicy"
+			settings["ingress_zones"] = dbus.MakeVariant(o.ingress)
+			settings["egress_zones"] = dbus.MakeVariant(o.egress)
+			settings["priority"] = dbus.MakeVariant(o.priority)
+		}
+		if err := b.config.Call(method, 0, o.name, settings).Err; err != nil {
+			return fmt.Errorf("add %s to the permanent configuration: %w", o.name, err)
+		}
+		log.Infof("added %s to the permanent configuration of firewalld", o.name)
+		added = true
+	}
+
+	if !added {
+		return nil
+	}
+	// the runtime configuration only gets the new zone and policies on reload
+	if err := b.obj.Call(dbusInterface+".reload", 0).Err; err != nil {
+		return fmt.Errorf("reload firewalld: %w", err)
+	}
+	return nil
+}
+
+func (b *dbusBackend) bindInterface(name string) error {
+	return b.obj.Call(dbusZoneInterface+".changeZoneOfInterface", 0, zoneName, name).Err
+}
+
+func (b *dbusBackend) unbindInterface(name string) error {
+	return b.obj.Call(dbusZoneInterface+".removeInterface", 0, zoneName, name).Err
+}
+
+func (b *dbusBackend) richRules(object string) ([]string, error) {
+	var rules []string
+	if isZone(object) {
+		err := b.obj.Call(dbusZoneInterface+".getRichRules", 0, object).Store(&rules)
+		return rules, err
+	}
+
+	var settings map[string]dbus.Variant
+	if err := b.obj.Call(dbusPolicyInterface+".getPolicySettings", 0, object).Store(&settings); err != nil {
+		return nil, err
+	}
+	if value, ok := settings["rich_rules"]; ok {
+		if err := value.Store(&rules); err != nil {
+			return nil, fmt.Errorf("rich rules of %s: %w", object, err)
+		}
+	}
+	return rules, nil
+}
+
+func (b *dbusBackend) addRichRule(object, rule string) error {
+	return b.obj.Call(objectInterface(object)+".addRichRule", 0, object, rule, int32(0)).Err
+}
+
+func (b *dbusBackend) removeRichRule(object, rule string) error {
+	return b.obj.Call(objectInterface(object)+".removeRichRule", 0, object, rule).Err
+}
+
+func (b *dbusBackend) reloaded() <-chan struct{} {
+	return b.reloads
+}
+
+func (b *dbusBackend) close() error {
+	b.conn.RemoveSignal(b.signals)
+	close(b.signals)
+	return b.conn.Close()
+}
+
+// objectInterface returns the D-Bus interface of the runtime configuration of the zone or the policy
+func objectInterface(object string) string {
+	if isZone(object) {
+		return dbusZoneInterface
+	}
+	return dbusPolicyInterface
+}
+
+func isZone(object string) bool {
+	for _, o := range objects {
+		if o.name == object {
+			return o.zone
+		}
+	}
+	return false
+}
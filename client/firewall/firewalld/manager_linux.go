@@ -0,0 +1,409 @@
+package firewalld
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+// Manager of firewalld. The NetBird interface is bound to the NetBird zone and the rules are rich rules of this zone
+// and of the NetBird policies, added to the runtime configuration over D-Bus and added again when firewalld reloads
+type Manager struct {
+	mutex sync.Mutex
+
+	wgIface iFaceMapper
+	backend backend
+	// stopWatch stops adding the rules again on reload, nil when the reloads aren't watched
+	stopWatch context.CancelFunc
+
+	state *ruleset
+	// txState is the state before the transaction, nil if no transaction was started
+	txState *ruleset
+	// applied are the rich rules added to each NetBird zone and policy. They are compared with the rules of the next
+	// state as written by NetBird, firewalld may return them in a normalized form
+	applied map[string]map[string]struct{}
+}
+
+// iFaceMapper defines subset methods of interface required for manager
+type iFaceMapper interface {
+	Name() string
+	Address() iface.WGAddress
+	IsUserspaceBind() bool
+}
+
+// Create firewalld firewall manager, the rules are added again each time firewalld reloads until the context is done
+// or the manager is reset
+func Create(ctx context.Context, wgIface iFaceMapper) (*Manager, error) {
+	b, err := newDBusBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := create(wgIface, b)
+	if err != nil {
+		if err := b.close(); err != nil {
+			log.Debugf("failed to close the firewalld backend: %v", err)
+		}
+		return nil, err
+	}
+
+	ctx, m.stopWatch = context.WithCancel(ctx)
+	go m.watchReloads(ctx)
+	return m, nil
+}
+
+func create(wgIface iFaceMapper, b backend) (*Manager, error) {
+	m := &Manager{
+		wgIface: wgIface,
+		backend: b,
+		state:   newRuleset(),
+		applied: make(map[string]map[string]struct{}),
+	}
+
+	if err := b.setupObjects(); err != nil {
+		return nil, fmt.Errorf("failed to set up the firewalld zone and policies: %w", err)
+	}
+	if err := b.bindInterface(wgIface.Name()); err != nil {
+		return nil, fmt.Errorf("failed to bind interface %s to zone %s: %w", wgIface.Name(), zoneName, err)
+	}
+
+	if err := m.removeStaleRules(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// removeStaleRules removes the rich rules left by a client which didn't shut down cleanly, the NetBird zone and
+// policies only hold NetBird rules
+func (m *Manager) removeStaleRules() error {
+	for _, o := range objects {
+		rules, err := m.backend.richRules(o.name)
+		if err != nil {
+			return fmt.Errorf("failed to get the rich rules of %s: %w", o.name, err)
+		}
+		for _, rule := range rules {
+			if err := m.backend.removeRichRule(o.name, rule); err != nil {
+				return fmt.Errorf("failed to remove rich rule %q from %s: %w", rule, o.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// watchReloads adds the interface and the rules again when firewalld reloads, the reload drops them from the
+// runtime configuration
+func (m *Manager) watchReloads(ctx context.Context) {
+	defer func() {
+		// the connection is closed once the reset using it is done
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if err := m.backend.close(); err != nil {
+			log.Debugf("failed to close the firewalld backend: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-m.backend.reloaded():
+			if !ok {
+				return
+			}
+			log.Info("firewalld reloaded, adding the NetBird rules again")
+			if err := m.restore(); err != nil {
+				log.Errorf("failed to restore the rules after the firewalld reload: %v", err)
+			}
+		}
+	}
+}
+
+// restore binds the interface and loads the rules applied before the reload. During a transaction they are the
+// rules before the transaction
+func (m *Manager) restore() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.backend.bindInterface(m.wgIface.Name()); err != nil {
+		return fmt.Errorf("bind interface %s to zone %s: %w", m.wgIface.Name(), zoneName, err)
+	}
+
+	state := m.state
+	if m.txState != nil {
+		state = m.txState
+	}
+	m.applied = make(map[string]map[string]struct{})
+	return m.load(state)
+}
+
+// AddFiltering rule to the firewall
+//
+// Comment will be ignored because the rich rules don't keep comments
+func (m *Manager) AddFiltering(
+	ip net.IP,
+	protocol firewall.Protocol,
+	sPort *firewall.Port,
+	dPort *firewall.Port,
+	direction firewall.RuleDirection,
+	action firewall.Action,
+	ipsetName string,
+	comment string,
+) ([]firewall.Rule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("only IPv4 rules are supported, got %s", ip)
+	}
+	if portValues(sPort) != nil && portValues(dPort) != nil {
+		return nil, fmt.Errorf("a rich rule matches either the source or the destination port, not both")
+	}
+
+	rule := &Rule{
+		ruleID:    uuid.New().String(),
+		ip:        ip.String(),
+		protocol:  protocol,
+		sPort:     sPort,
+		dPort:     dPort,
+		direction: direction,
+		action:    action,
+	}
+
+	err := m.update(func(state *ruleset) {
+		state.rules[rule.ruleID] = rule
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []firewall.Rule{rule}, nil
+}
+
+// DeleteRule from the firewall by rule definition
+func (m *Manager) DeleteRule(rule firewall.Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := rule.(*Rule)
+	if !ok {
+		return fmt.Errorf("invalid rule type")
+	}
+
+	return m.update(func(state *ruleset) {
+		delete(state.rules, r.ruleID)
+	})
+}
+
+func (m *Manager) IsServerRouteSupported() bool {
+	return true
+}
+
+// InsertRoutingRules accepts the traffic of the pair and, if enabled, masquerades it
+func (m *Manager) InsertRoutingRules(pair firewall.RouterPair) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.routes[pair.ID] = pair
+	})
+}
+
+func (m *Manager) RemoveRoutingRules(pair firewall.RouterPair) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		delete(state.routes, pair.ID)
+	})
+}
+
+// SetDefaultDeny makes the firewall drop the traffic exchanged with the routed networks unless an ACL rule accepts it
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.defaultDeny = enabled
+	})
+}
+
+// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface before the ACL rules
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.localPorts = append([]firewall.LocalPort{}, ports...)
+	})
+}
+
+// SetDNSLeakProtection drops the DNS queries sent to other destinations than the allowed resolvers
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var resolvers []string
+	if allowed != nil {
+		resolvers = make([]string, 0, len(allowed))
+		for _, ip := range allowed {
+			resolvers = append(resolvers, ip.String())
+		}
+	}
+	return m.update(func(state *ruleset) {
+		state.dnsResolvers = resolvers
+	})
+}
+
+// SetSourceValidation isn't needed, the routed policies drop the forwarded traffic unless the rules of a routed
+// network accept it: the traffic forwarded to the NetBird zone comes from the routed networks, the traffic forwarded
+// from it from the peer network. The accepting rules are IPv4 only, the forwarded IPv6 traffic is dropped
+func (m *Manager) SetSourceValidation(*firewall.SourceValidation) error {
+	return nil
+}
+
+// AllowNetbird allows netbird interface traffic
+func (m *Manager) AllowNetbird() error {
+	if !m.wgIface.IsUserspaceBind() {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.allowNetbird = true
+	})
+}
+
+// Reset firewall to the default state, the rich rules are removed and the interface leaves the NetBird zone. The
+// zone and the policies are kept in the permanent configuration and the rules aren't added again on reload
+func (m *Manager) Reset() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopWatch != nil {
+		m.stopWatch()
+	}
+
+	m.txState = nil
+	m.state = newRuleset()
+	if err := m.load(m.state); err != nil {
+		return err
+	}
+
+	if err := m.backend.unbindInterface(m.wgIface.Name()); err != nil {
+		return fmt.Errorf("failed to remove interface %s from zone %s: %w", m.wgIface.Name(), zoneName, err)
+	}
+	return nil
+}
+
+// Flush doesn't need to be implemented for this manager
+func (m *Manager) Flush() error { return nil }
+
+// BeginTx starts a transaction, the rules are loaded on Commit
+func (m *Manager) BeginTx() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState != nil {
+		return firewall.ErrTxInProgress
+	}
+	m.txState = m.state.clone()
+	return nil
+}
+
+// Commit loads the rules changed in the transaction at once
+func (m *Manager) Commit() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState == nil {
+		return firewall.ErrNoTx
+	}
+	previous := m.txState
+	m.txState = nil
+
+	if err := m.load(m.state); err != nil {
+		m.state = previous
+		if err := m.load(previous); err != nil {
+			log.Errorf("failed to restore the rules before the transaction: %v", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback discards the rules changed in the transaction
+func (m *Manager) Rollback() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState == nil {
+		return firewall.ErrNoTx
+	}
+	m.state = m.txState
+	m.txState = nil
+	return nil
+}
+
+// update applies the change to the rules. During a transaction the rules are loaded on Commit, otherwise the
+// change is discarded if firewalld rejects it
+func (m *Manager) update(change func(*ruleset)) error {
+	if m.txState != nil {
+		change(m.state)
+		return nil
+	}
+
+	next := m.state.clone()
+	change(next)
+	if err := m.load(next); err != nil {
+		if err := m.load(m.state); err != nil {
+			log.Errorf("failed to restore the rules: %v", err)
+		}
+		return err
+	}
+	m.state = next
+	return nil
+}
+
+// load makes the rich rules of the NetBird zone and policies match the state: the rules missing in firewalld are
+// added and the other ones removed. Unlike pf, firewalld changes the rules one by one
+func (m *Manager) load(state *ruleset) error {
+	for object, rules := range state.render() {
+		applied := m.applied[object]
+		if applied == nil {
+			applied = make(map[string]struct{})
+			m.applied[object] = applied
+		}
+
+		wanted := make(map[string]struct{}, len(rules))
+		for _, rule := range rules {
+			wanted[rule] = struct{}{}
+		}
+		for rule := range applied {
+			if _, ok := wanted[rule]; ok {
+				continue
+			}
+			if err := m.backend.removeRichRule(object, rule); err != nil {
+				return fmt.Errorf("failed to remove rich rule %q from %s: %w", rule, object, err)
+			}
+			delete(applied, rule)
+		}
+		for _, rule := range rules {
+			if _, ok := applied[rule]; ok {
+				continue
+			}
+			if err := m.backend.addRichRule(object, rule); err != nil {
+				return fmt.Errorf("failed to add rich rule %q to %s: %w", rule, object, err)
+			}
+			applied[rule] = struct{}{}
+		}
+	}
+	return nil
+}
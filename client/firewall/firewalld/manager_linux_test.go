@@ -0,0 +1,146 @@
+package firewalld
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+type iFaceMock struct{}
+
+func (i *iFaceMock) Name() string             { return "wt0" }
+func (i *iFaceMock) Address() iface.WGAddress { return iface.WGAddress{} }
+func (i *iFaceMock) IsUserspaceBind() bool    { return false }
+
+// fakeBackend keeps the runtime configuration of firewalld in memory
+type fakeBackend struct {
+	bound   map[string]bool
+	rules   map[string]map[string]struct{}
+	reloads chan struct{}
+	// failRule makes adding this rule fail
+	failRule string
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		bound:   make(map[string]bool),
+		rules:   make(map[string]map[string]struct{}),
+		reloads: make(chan struct{}),
+	}
+}
+
+func (b *fakeBackend) setupObjects() error { return nil }
+
+func (b *fakeBackend) bindInterface(name string) error {
+	b.bound[name] = true
+	return nil
+}
+
+func (b *fakeBackend) unbindInterface(name string) error {
+	delete(b.bound, name)
+	return nil
+}
+
+func (b *fakeBackend) richRules(object string) ([]string, error) {
+	var rules []string
+	for rule := range b.rules[object] {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return rules, nil
+}
+
+func (b *fakeBackend) addRichRule(object, rule string) error {
+	if rule == b.failRule {
+		return fmt.Errorf("invalid rule")
+	}
+	if _, ok := b.rules[object][rule]; ok {
+		return fmt.Errorf("ALREADY_ENABLED")
+	}
+	if b.rules[object] == nil {
+		b.rules[object] = make(map[string]struct{})
+	}
+	b.rules[object][rule] = struct{}{}
+	return nil
+}
+
+func (b *fakeBackend) removeRichRule(object, rule string) error {
+	if _, ok := b.rules[object][rule]; !ok {
+		return fmt.Errorf("NOT_ENABLED")
+	}
+	delete(b.rules[object], rule)
+	return nil
+}
+
+func (b *fakeBackend) reloaded() <-chan struct{} { return b.reloads }
+
+func (b *fakeBackend) close() error { return nil }
+
+// reload drops the runtime configuration like firewalld does
+func (b *fakeBackend) reload() {
+	b.bound = make(map[string]bool)
+	b.rules = make(map[string]map[string]struct{})
+}
+
+func TestManager(t *testing.T) {
+	b := newFakeBackend()
+	b.rules[zoneName] = map[string]struct{}{`rule family="ipv4" source address="100.64.0.9" accept`: {}}
+
+	m, err := create(&iFaceMock{}, b)
+	require.NoError(t, err)
+	require.True(t, b.bound["wt0"], "the interface should be in the NetBird zone")
+	require.Empty(t, b.rules[zoneName], "the stale rules should be removed")
+
+	rules, err := m.AddFiltering(net.ParseIP("100.64.0.2"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{22}},
+		fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	sshRule := `rule family="ipv4" source address="100.64.0.2" port port="22" protocol="tcp" accept`
+	require.Contains(t, b.rules[zoneName], sshRule)
+
+	_, err = m.AddFiltering(net.ParseIP("100.64.0.2"), fw.ProtocolTCP, &fw.Port{Values: []int{22}},
+		&fw.Port{Values: []int{22}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.Error(t, err, "a rich rule can't match both ports")
+
+	b.failRule = `rule family="ipv4" source address="100.64.0.3" accept`
+	_, err = m.AddFiltering(net.ParseIP("100.64.0.3"), fw.ProtocolALL, nil, nil, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.Error(t, err)
+	require.Len(t, m.state.rules, 1, "the rejected rule should be discarded")
+
+	b.reload()
+	require.NoError(t, m.restore())
+	require.True(t, b.bound["wt0"], "the interface should be bound again after the reload")
+	require.Contains(t, b.rules[zoneName], sshRule, "the rules should be added again after the reload")
+
+	require.NoError(t, m.DeleteRule(rules[0]))
+	require.Empty(t, b.rules[zoneName])
+
+	require.NoError(t, m.Reset())
+	require.False(t, b.bound["wt0"], "the interface should leave the NetBird zone")
+}
+
+func TestManagerTransaction(t *testing.T) {
+	b := newFakeBackend()
+	m, err := create(&iFaceMock{}, b)
+	require.NoError(t, err)
+
+	require.NoError(t, m.BeginTx())
+	_, err = m.AddFiltering(net.ParseIP("100.64.0.2"), fw.ProtocolUDP, nil, &fw.Port{Values: []int{53}},
+		fw.RuleDirectionOUT, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	require.Empty(t, b.rules[policyOutput], "the rules should only be added on commit")
+
+	require.NoError(t, m.Commit())
+	require.Len(t, b.rules[policyOutput], 1)
+
+	require.NoError(t, m.BeginTx())
+	require.NoError(t, m.SetAllowedLocalPorts([]fw.LocalPort{{Protocol: fw.ProtocolTCP, Port: 9100}}))
+	require.NoError(t, m.Rollback())
+	require.Len(t, b.rules[policyOutput], 1, "the rolled back changes should not be applied")
+	require.Empty(t, m.state.localPorts)
+}
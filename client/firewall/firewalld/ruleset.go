@@ -0,0 +1,289 @@
+package firewalld
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const (
+	// zoneName is the zone of the NetBird interface, its rich rules filter the traffic received by the peer
+	zoneName = "netbird"
+	// policyOutput filters the traffic sent by the peer on the NetBird interface
+	policyOutput = "netbird-output"
+	// policyRoutedOut filters the traffic of the NetBird interface forwarded to the other zones, and masquerades it
+	policyRoutedOut = "netbird-routed-out"
+	// policyRoutedIn filters the traffic of the other zones forwarded to the NetBird interface
+	policyRoutedIn = "netbird-routed-in"
+	// policyDNS drops the DNS queries sent on any interface to other resolvers than the allowed ones
+	policyDNS = "netbird-dns"
+
+	// the priorities order the rich rules of a zone or a policy, the lowest first. Within a priority firewalld
+	// evaluates the rules dropping the traffic before the rules accepting it
+	priorityAllowNetbird = -32000
+	priorityLocalPorts   = -100
+	priorityDNSAllowed   = -2
+	priorityDNSDropped   = -1
+)
+
+// objects are the zone and the policies of NetBird, with the zones their traffic comes from and goes to and the
+// target deciding the verdict of the traffic no rich rule matches
+var objects = []object{
+	{name: zoneName, zone: true, target: "DROP"},
+	{name: policyOutput, ingress: []string{"HOST"}, egress: []string{zoneName}, target: "DROP"},
+	{name: policyRoutedOut, ingress: []string{zoneName}, egress: []string{"ANY"}, target: "DROP"},
+	{name: policyRoutedIn, ingress: []string{"ANY"}, egress: []string{zoneName}, target: "DROP"},
+	{name: policyDNS, ingress: []string{"HOST"}, egress: []string{"ANY"}, target: "CONTINUE", priority: -100},
+}
+
+// object is a zone or a policy of the permanent configuration of firewalld
+type object struct {
+	name     string
+	zone     bool
+	ingress  []string
+	egress   []string
+	target   string
+	priority int32
+}
+
+// ruleset is the state of the NetBird rules, rendered into the rich rules of the NetBird zone and policies
+type ruleset struct {
+	// allowNetbird accepts all the traffic of the interface, the userspace firewall filters it
+	allowNetbird bool
+	// defaultDeny filters the traffic exchanged with the routed networks with the filtering rules
+	defaultDeny bool
+	localPorts  []firewall.LocalPort
+	// dnsResolvers are the resolvers allowed to receive DNS queries, nil when the DNS leak protection is disabled
+	dnsResolvers []string
+	rules        map[string]*Rule
+	routes       map[string]firewall.RouterPair
+}
+
+func newRuleset() *ruleset {
+	return &ruleset{
+		rules:  make(map[string]*Rule),
+		routes: make(map[string]firewall.RouterPair),
+	}
+}
+
+func (r *ruleset) clone() *ruleset {
+	clone := *r
+	clone.localPorts = append([]firewall.LocalPort{}, r.localPorts...)
+	if r.dnsResolvers != nil {
+		clone.dnsResolvers = append([]string{}, r.dnsResolvers...)
+	}
+	clone.rules = make(map[string]*Rule, len(r.rules))
+	for id, rule := range r.rules {
+		clone.rules[id] = rule
+	}
+	clone.routes = make(map[string]firewall.RouterPair, len(r.routes))
+	for id, pair := range r.routes {
+		clone.routes[id] = pair
+	}
+	return &clone
+}
+
+// render returns the sorted rich rules of each NetBird zone and policy, every object has an entry so that the rules
+// left from a previous state are removed:
+//   - the zone accepts the traffic of the allowed local ports and of the incoming filtering rules
+//   - the output policy accepts the traffic of the allowed local ports and of the outgoing filtering rules
+//   - the routed policies accept the traffic exchanged with the routed networks, in default deny mode only the one
+//     of the filtering rules, and masquerade it
+//   - the DNS policy drops the DNS queries sent to other resolvers than the allowed ones
+func (r *ruleset) render() map[string][]string {
+	rendered := make(map[string][]string, len(objects))
+	for _, o := range objects {
+		rendered[o.name] = nil
+	}
+	add := func(name string, rules ...string) {
+		rendered[name] = append(rendered[name], rules...)
+	}
+
+	if r.allowNetbird {
+		add(zoneName, fmt.Sprintf(`rule priority="%d" family="ipv4" accept`, priorityAllowNetbird))
+		add(policyOutput, fmt.Sprintf(`rule priority="%d" family="ipv4" accept`, priorityAllowNetbird))
+	}
+
+	for _, port := range r.localPorts {
+		add(zoneName, fmt.Sprintf(`rule priority="%d" family="ipv4" port port="%d" protocol="%s" accept`,
+			priorityLocalPorts, port.Port, port.Protocol))
+		add(policyOutput, fmt.Sprintf(`rule priority="%d" family="ipv4" source-port port="%d" protocol="%s" accept`,
+			priorityLocalPorts, port.Port, port.Protocol))
+	}
+
+	for _, rule := range r.rules {
+		if rule.direction == firewall.RuleDirectionOUT {
+			add(policyOutput, richRules(rule, "", "")...)
+		} else {
+			add(zoneName, richRules(rule, "", "")...)
+		}
+	}
+
+	for _, pair := range r.routes {
+		inPair := firewall.GetInPair(pair)
+		if r.defaultDeny {
+			for _, rule := range r.rules {
+				// the incoming rules filter the traffic sent to the routed network, the outgoing rules the traffic
+				// sent from it
+				if rule.direction == firewall.RuleDirectionOUT {
+					add(policyRoutedIn, richRules(rule, pair.Destination, pair.Source)...)
+				} else {
+					add(policyRoutedOut, richRules(rule, pair.Destination, pair.Source)...)
+				}
+			}
+		} else {
+			add(policyRoutedOut, pairRule(pair, "accept"))
+			add(policyRoutedIn, pairRule(inPair, "accept"))
+		}
+
+		if pair.Masquerade {
+			add(policyRoutedOut, pairRule(pair, "masquerade"))
+			add(policyRoutedIn, pairRule(inPair, "masquerade"))
+		}
+	}
+
+	if r.dnsResolvers != nil {
+		add(policyDNS, r.dnsLeakRules()...)
+	}
+
+	for name, rules := range rendered {
+		rendered[name] = dedup(rules)
+		sort.Strings(rendered[name])
+	}
+	return rendered
+}
+
+// dnsLeakRules returns the rich rules accepting the DNS queries sent to the allowed resolvers and dropping the other
+// ones, the queries sent on the loopback interface aren't filtered by firewalld
+func (r *ruleset) dnsLeakRules() []string {
+	var rules []string
+	for _, port := range firewall.DNSPorts {
+		for _, protocol := range []firewall.Protocol{firewall.ProtocolUDP, firewall.ProtocolTCP} {
+			for _, resolver := range r.dnsResolvers {
+				rules = append(rules, fmt.Sprintf(`rule priority="%d" family="%s" destination address="%s" port port="%d" protocol="%s" accept`,
+					priorityDNSAllowed, family(resolver), resolver, port, protocol))
+			}
+			rules = append(rules, fmt.Sprintf(`rule priority="%d" port port="%d" protocol="%s" drop`,
+				priorityDNSDropped, port, protocol))
+		}
+	}
+	return rules
+}
+
+// pairRule returns the rich rule applying the action to the traffic from the source to the destination of the pair
+func pairRule(pair firewall.RouterPair, action string) string {
+	return fmt.Sprintf(`rule family="ipv4" source address="%s" destination address="%s" %s`,
+		pair.Source, pair.Destination, action)
+}
+
+// richRules returns the rich rules of a filtering rule, one per port when it matches several ports. A routed
+// network restricts the other end of the traffic: the destination of the incoming traffic and the source of the
+// outgoing traffic. The peer end of the rules of all the peers is restricted to the peer network when it is set, so
+// that the routed traffic can't use other addresses
+func richRules(rule *Rule, routedNetwork, peerNetwork string) []string {
+	peer := peerNetwork
+	if rule.ip != "0.0.0.0" {
+		peer = rule.ip
+	}
+
+	source, destination := peer, routedNetwork
+	if rule.direction == firewall.RuleDirectionOUT {
+		source, destination = routedNetwork, peer
+	}
+	parts := []string{`rule family="ipv4"`}
+	if source != "" {
+		parts = append(parts, fmt.Sprintf(`source address="%s"`, source))
+	}
+	if destination != "" {
+		parts = append(parts, fmt.Sprintf(`destination address="%s"`, destination))
+	}
+	prefix := strings.Join(parts, " ")
+
+	var suffix []string
+	if rule.action.Logs() {
+		suffix = append(suffix, fmt.Sprintf(`nflog group="%d" prefix="%s" limit value="%d/s"`,
+			firewall.LogGroup, rule.action.LogPrefix(), firewall.LogRate))
+	}
+	if rule.action.Drops() {
+		suffix = append(suffix, "drop")
+	} else {
+		suffix = append(suffix, "accept")
+	}
+	action := strings.Join(suffix, " ")
+
+	var rules []string
+	for _, element := range elements(rule) {
+		if element == "" {
+			rules = append(rules, prefix+" "+action)
+			continue
+		}
+		rules = append(rules, prefix+" "+element+" "+action)
+	}
+	return rules
+}
+
+// elements returns the protocol or the port elements of the rich rules of a filtering rule, an empty element
+// matches all the protocols
+func elements(rule *Rule) []string {
+	switch rule.protocol {
+	case firewall.ProtocolALL:
+		return []string{""}
+	case firewall.ProtocolICMP, firewall.ProtocolGRE:
+		return []string{fmt.Sprintf(`protocol value="%s"`, rule.protocol)}
+	}
+
+	element, port := "port", rule.dPort
+	if portValues(rule.dPort) == nil {
+		element, port = "source-port", rule.sPort
+	}
+	values := portValues(port)
+	if values == nil {
+		return []string{fmt.Sprintf(`protocol value="%s"`, rule.protocol)}
+	}
+
+	elements := make([]string, 0, len(values))
+	for _, value := range values {
+		elements = append(elements, fmt.Sprintf(`%s port="%s" protocol="%s"`, element, value, rule.protocol))
+	}
+	return elements
+}
+
+// portValues returns the port values of a rich rule, a range is written as start-end
+func portValues(port *firewall.Port) []string {
+	if port == nil || len(port.Values) == 0 {
+		return nil
+	}
+	if port.IsRange && len(port.Values) == 2 {
+		return []string{fmt.Sprintf("%d-%d", port.Values[0], port.Values[1])}
+	}
+
+	values := make([]string, 0, len(port.Values))
+	for _, value := range port.Values {
+		values = append(values, strconv.Itoa(value))
+	}
+	return values
+}
+
+func family(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+func dedup(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := values[:0:0]
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	return unique
+}
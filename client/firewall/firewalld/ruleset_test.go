@@ -0,0 +1,100 @@
+package firewalld
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+func TestRuleset_Render(t *testing.T) {
+	state := newRuleset()
+	state.localPorts = []firewall.LocalPort{{Protocol: firewall.ProtocolTCP, Port: 9100}}
+	state.rules["ssh"] = &Rule{
+		ruleID: "ssh", ip: "100.64.0.2", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{22}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.rules["web"] = &Rule{
+		ruleID: "web", ip: "0.0.0.0", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{80, 443}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.rules["drop"] = &Rule{
+		ruleID: "drop", ip: "100.64.0.4", protocol: firewall.ProtocolUDP,
+		sPort: &firewall.Port{IsRange: true, Values: []int{1000, 2000}}, direction: firewall.RuleDirectionOUT,
+		action: firewall.ActionLogDrop,
+	}
+	state.rules["gre"] = &Rule{
+		ruleID: "gre", ip: "100.64.0.5", protocol: firewall.ProtocolGRE,
+		direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.routes["route"] = firewall.RouterPair{
+		ID: "route", Source: "100.64.0.0/16", Destination: "192.168.1.0/24", Masquerade: true,
+	}
+
+	expected := map[string][]string{
+		zoneName: {
+			`rule family="ipv4" port port="443" protocol="tcp" accept`,
+			`rule family="ipv4" port port="80" protocol="tcp" accept`,
+			`rule family="ipv4" source address="100.64.0.2" port port="22" protocol="tcp" accept`,
+			`rule family="ipv4" source address="100.64.0.5" protocol value="gre" accept`,
+			`rule priority="-100" family="ipv4" port port="9100" protocol="tcp" accept`,
+		},
+		policyOutput: {
+			`rule family="ipv4" destination address="100.64.0.4" source-port port="1000-2000" protocol="udp" nflog group="0" prefix="netbird-acl-drop" limit value="10/s" drop`,
+			`rule priority="-100" family="ipv4" source-port port="9100" protocol="tcp" accept`,
+		},
+		policyRoutedOut: {
+			`rule family="ipv4" source address="100.64.0.0/16" destination address="192.168.1.0/24" accept`,
+			`rule family="ipv4" source address="100.64.0.0/16" destination address="192.168.1.0/24" masquerade`,
+		},
+		policyRoutedIn: {
+			`rule family="ipv4" source address="192.168.1.0/24" destination address="100.64.0.0/16" accept`,
+			`rule family="ipv4" source address="192.168.1.0/24" destination address="100.64.0.0/16" masquerade`,
+		},
+		policyDNS: nil,
+	}
+	assert.Equal(t, expected, state.render())
+}
+
+func TestRuleset_RenderDefaultDeny(t *testing.T) {
+	state := newRuleset()
+	state.defaultDeny = true
+	state.rules["ssh"] = &Rule{
+		ruleID: "ssh", ip: "100.64.0.2", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{22}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.rules["out"] = &Rule{
+		ruleID: "out", ip: "100.64.0.2", protocol: firewall.ProtocolALL,
+		direction: firewall.RuleDirectionOUT, action: firewall.ActionAccept,
+	}
+	state.rules["web"] = &Rule{
+		ruleID: "web", ip: "0.0.0.0", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{80}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.routes["route"] = firewall.RouterPair{ID: "route", Source: "100.64.0.0/16", Destination: "10.0.0.0/8"}
+
+	rendered := state.render()
+	assert.Equal(t, []string{
+		`rule family="ipv4" source address="100.64.0.0/16" destination address="10.0.0.0/8" port port="80" protocol="tcp" accept`,
+		`rule family="ipv4" source address="100.64.0.2" destination address="10.0.0.0/8" port port="22" protocol="tcp" accept`,
+	}, rendered[policyRoutedOut], "only the traffic of the incoming rules from the peer network should be forwarded to the routed network")
+	assert.Equal(t, []string{
+		`rule family="ipv4" source address="10.0.0.0/8" destination address="100.64.0.2" accept`,
+	}, rendered[policyRoutedIn], "only the traffic of the outgoing rules should be forwarded from the routed network")
+}
+
+func TestRuleset_RenderDNSLeakProtection(t *testing.T) {
+	state := newRuleset()
+	state.allowNetbird = true
+	state.dnsResolvers = []string{"100.64.0.1", "fd00::1"}
+
+	rendered := state.render()
+	assert.Equal(t, []string{`rule priority="-32000" family="ipv4" accept`}, rendered[zoneName])
+	assert.Contains(t, rendered[policyDNS],
+		`rule priority="-2" family="ipv6" destination address="fd00::1" port port="53" protocol="udp" accept`)
+	assert.Contains(t, rendered[policyDNS],
+		`rule priority="-2" family="ipv4" destination address="100.64.0.1" port port="853" protocol="tcp" accept`)
+	assert.Contains(t, rendered[policyDNS], `rule priority="-1" port port="53" protocol="udp" drop`)
+	assert.Len(t, rendered[policyDNS], 12)
+}
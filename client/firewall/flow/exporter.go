@@ -0,0 +1,33 @@
+package flow
+
+import (
+	"fmt"
+	"net/url"
+)
+
+const (
+	// ipfixScheme exports IPFIX messages over UDP, e.g. ipfix://10.0.0.1:4739
+	ipfixScheme = "ipfix"
+	// jsonScheme exports newline delimited JSON records over TCP, e.g. json://10.0.0.1:5000
+	jsonScheme = "json"
+)
+
+// NewExporter creates an Exporter for the collector URL
+func NewExporter(collectorURL string) (Exporter, error) {
+	u, err := url.Parse(collectorURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flow collector URL %s: %v", collectorURL, err)
+	}
+	if u.Host == "" || u.Port() == "" {
+		return nil, fmt.Errorf("invalid flow collector URL %s, expected [ipfix|json]://host:port", collectorURL)
+	}
+
+	switch u.Scheme {
+	case ipfixScheme:
+		return newIPFIXExporter(u.Host)
+	case jsonScheme:
+		return newJSONExporter(u.Host), nil
+	default:
+		return nil, fmt.Errorf("unsupported flow collector scheme %s, expected ipfix or json", u.Scheme)
+	}
+}
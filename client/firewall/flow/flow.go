@@ -0,0 +1,148 @@
+package flow
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is the default interval at which aggregated flows are exported
+const DefaultInterval = 30 * time.Second
+
+// Direction of a flow relative to this peer
+type Direction uint8
+
+const (
+	// Ingress flows are initiated by packets received from a remote peer
+	Ingress Direction = iota
+	// Egress flows are initiated by packets sent to a remote peer
+	Egress
+)
+
+// String returns the name of the direction
+func (d Direction) String() string {
+	if d == Egress {
+		return "egress"
+	}
+	return "ingress"
+}
+
+// Key identifies a flow
+type Key struct {
+	SrcIP     netip.Addr
+	DstIP     netip.Addr
+	SrcPort   uint16
+	DstPort   uint16
+	Protocol  uint8
+	Direction Direction
+	// Dropped indicates that the packets of the flow were dropped by the firewall
+	Dropped bool
+}
+
+// Record holds the counters of a flow accumulated during an export interval
+type Record struct {
+	Key
+	Packets uint64
+	Bytes   uint64
+	Start   time.Time
+	End     time.Time
+}
+
+// Exporter sends flow records to a collector
+type Exporter interface {
+	Export(records []Record) error
+	Close() error
+}
+
+// Aggregator accumulates per-flow packet and byte counts and periodically exports them as delta records
+type Aggregator struct {
+	exporter Exporter
+	interval time.Duration
+
+	mu    sync.Mutex
+	flows map[Key]*Record
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewAggregator creates an Aggregator exporting with the exporter every interval
+func NewAggregator(exporter Exporter, interval time.Duration) *Aggregator {
+	return &Aggregator{
+		exporter: exporter,
+		interval: interval,
+		flows:    make(map[Key]*Record),
+	}
+}
+
+// Add accounts a packet of the flow
+func (a *Aggregator) Add(key Key, size int) {
+	now := time.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	record, ok := a.flows[key]
+	if !ok {
+		record = &Record{Key: key, Start: now}
+		a.flows[key] = record
+	}
+	record.Packets++
+	record.Bytes += uint64(size)
+	record.End = now
+}
+
+// Start exports the aggregated flows every interval until Stop is called
+func (a *Aggregator) Start(ctx context.Context) {
+	ctx, a.cancel = context.WithCancel(ctx)
+	a.done = make(chan struct{})
+
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.flush()
+			case <-ctx.Done():
+				a.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop exports the remaining flows and closes the exporter
+func (a *Aggregator) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+		<-a.done
+	}
+
+	if err := a.exporter.Close(); err != nil {
+		log.Debugf("failed closing flow exporter: %v", err)
+	}
+}
+
+func (a *Aggregator) flush() {
+	a.mu.Lock()
+	if len(a.flows) == 0 {
+		a.mu.Unlock()
+		return
+	}
+	records := make([]Record, 0, len(a.flows))
+	for _, record := range a.flows {
+		records = append(records, *record)
+	}
+	a.flows = make(map[Key]*Record)
+	a.mu.Unlock()
+
+	if err := a.exporter.Export(records); err != nil {
+		log.Warnf("failed exporting %d flow records: %v", len(records), err)
+	}
+}
@@ -0,0 +1,109 @@
+package flow
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockExporter struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+func (m *mockExporter) Export(records []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+func (m *mockExporter) Close() error {
+	return nil
+}
+
+func TestAggregator(t *testing.T) {
+	exporter := &mockExporter{}
+	aggregator := NewAggregator(exporter, time.Hour)
+
+	key := Key{
+		SrcIP:    netip.MustParseAddr("100.64.0.1"),
+		DstIP:    netip.MustParseAddr("100.64.0.2"),
+		SrcPort:  40000,
+		DstPort:  22,
+		Protocol: 6,
+	}
+	aggregator.Add(key, 100)
+	aggregator.Add(key, 200)
+
+	dropped := key
+	dropped.Dropped = true
+	aggregator.Add(dropped, 60)
+
+	aggregator.flush()
+	require.Len(t, exporter.records, 2)
+	for _, record := range exporter.records {
+		if record.Dropped {
+			assert.Equal(t, uint64(1), record.Packets)
+			assert.Equal(t, uint64(60), record.Bytes)
+		} else {
+			assert.Equal(t, uint64(2), record.Packets)
+			assert.Equal(t, uint64(300), record.Bytes)
+		}
+	}
+
+	// counters are exported as deltas
+	aggregator.flush()
+	assert.Len(t, exporter.records, 2, "no new records should be exported without traffic")
+}
+
+func TestIPFIXEncodeMessage(t *testing.T) {
+	e := &ipfixExporter{}
+	now := time.Now()
+
+	records := make([]Record, 0, 100)
+	for i := 0; i < 100; i++ {
+		src := netip.MustParseAddr("100.64.0.1")
+		if i%2 == 0 {
+			src = netip.MustParseAddr("fd00::1")
+		}
+		records = append(records, Record{
+			Key:     Key{SrcIP: src, DstIP: src, SrcPort: uint16(i), DstPort: 443, Protocol: 6},
+			Packets: 1,
+			Bytes:   100,
+			Start:   now,
+			End:     now,
+		})
+	}
+
+	var total int
+	var messages int
+	for len(records) > 0 {
+		msg, n := e.encodeMessage(records, now)
+		require.Greater(t, n, 0)
+		assert.LessOrEqual(t, len(msg), ipfixMaxMessageLen)
+		assert.Equal(t, uint16(ipfixVersion), binary.BigEndian.Uint16(msg[0:]))
+		assert.Equal(t, uint16(len(msg)), binary.BigEndian.Uint16(msg[2:]))
+		assert.Equal(t, uint32(total), binary.BigEndian.Uint32(msg[8:]), "sequence should count previously sent records")
+
+		// sets must add up to the message length
+		offset := ipfixHeaderLen
+		for offset < len(msg) {
+			setLen := int(binary.BigEndian.Uint16(msg[offset+2:]))
+			require.Greater(t, setLen, ipfixSetHeaderLen)
+			offset += setLen
+		}
+		assert.Equal(t, len(msg), offset)
+
+		total += n
+		messages++
+		records = records[n:]
+	}
+	assert.Equal(t, 100, total)
+	assert.Greater(t, messages, 1, "records should be split in multiple messages")
+}
@@ -0,0 +1,195 @@
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// IPFIX (RFC 7011) encoding constants
+const (
+	ipfixVersion       = 10
+	ipfixHeaderLen     = 16
+	ipfixSetHeaderLen  = 4
+	ipfixTemplateSetID = 2
+
+	ipfixTemplateIPv4 uint16 = 256
+	ipfixTemplateIPv6 uint16 = 257
+
+	// ipfixMaxMessageLen keeps messages within a single datagram on common MTUs
+	ipfixMaxMessageLen = 1400
+
+	// forwardingStatus values (RFC 7270)
+	ipfixForwarded = 0x40
+	ipfixDropped   = 0x80
+)
+
+// ipfixField is an information element of a template
+type ipfixField struct {
+	id     uint16
+	length uint16
+}
+
+var ipfixCommonFields = []ipfixField{
+	{7, 2},   // sourceTransportPort
+	{11, 2},  // destinationTransportPort
+	{4, 1},   // protocolIdentifier
+	{61, 1},  // flowDirection
+	{89, 1},  // forwardingStatus
+	{1, 8},   // octetDeltaCount
+	{2, 8},   // packetDeltaCount
+	{152, 8}, // flowStartMilliseconds
+	{153, 8}, // flowEndMilliseconds
+}
+
+var (
+	ipfixIPv4Fields = append([]ipfixField{{8, 4}, {12, 4}}, ipfixCommonFields...)    // source/destinationIPv4Address
+	ipfixIPv6Fields = append([]ipfixField{{27, 16}, {28, 16}}, ipfixCommonFields...) // source/destinationIPv6Address
+)
+
+// ipfixExporter sends IPFIX messages over UDP. Templates are included in every message as required for
+// unreliable transports
+type ipfixExporter struct {
+	conn     net.Conn
+	mu       sync.Mutex
+	sequence uint32
+	domainID uint32
+}
+
+func newIPFIXExporter(addr string) (*ipfixExporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &ipfixExporter{conn: conn}, nil
+}
+
+// Export sends the records to the collector, split in as many messages as needed
+func (e *ipfixExporter) Export(records []Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for len(records) > 0 {
+		msg, n := e.encodeMessage(records, time.Now())
+		if _, err := e.conn.Write(msg); err != nil {
+			return err
+		}
+		records = records[n:]
+	}
+	return nil
+}
+
+// Close closes the UDP socket
+func (e *ipfixExporter) Close() error {
+	return e.conn.Close()
+}
+
+// encodeMessage encodes as many records as fit in a message and returns the message and the number of records
+func (e *ipfixExporter) encodeMessage(records []Record, now time.Time) ([]byte, int) {
+	msg := make([]byte, ipfixHeaderLen, ipfixMaxMessageLen)
+	msg = appendTemplateSet(msg)
+
+	var count int
+	var setStart int
+	var setTemplate uint16
+	for _, record := range records {
+		template, fields := ipfixTemplateIPv4, ipfixIPv4Fields
+		if !record.SrcIP.Unmap().Is4() {
+			template, fields = ipfixTemplateIPv6, ipfixIPv6Fields
+		}
+
+		recordLen := fieldsLen(fields)
+		needed := recordLen
+		if template != setTemplate {
+			needed += ipfixSetHeaderLen
+		}
+		if len(msg)+needed > ipfixMaxMessageLen && count > 0 {
+			break
+		}
+
+		if template != setTemplate {
+			msg = closeSet(msg, setStart)
+			setStart = len(msg)
+			setTemplate = template
+			msg = binary.BigEndian.AppendUint16(msg, template)
+			msg = binary.BigEndian.AppendUint16(msg, 0)
+		}
+		msg = appendRecord(msg, record, template == ipfixTemplateIPv4)
+		count++
+	}
+	msg = closeSet(msg, setStart)
+
+	binary.BigEndian.PutUint16(msg[0:], ipfixVersion)
+	binary.BigEndian.PutUint16(msg[2:], uint16(len(msg)))
+	binary.BigEndian.PutUint32(msg[4:], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(msg[8:], e.sequence)
+	binary.BigEndian.PutUint32(msg[12:], e.domainID)
+
+	// the sequence number counts data records sent before this message
+	e.sequence += uint32(count)
+
+	return msg, count
+}
+
+// closeSet writes the length of the set started at setStart
+func closeSet(msg []byte, setStart int) []byte {
+	if setStart == 0 {
+		return msg
+	}
+	binary.BigEndian.PutUint16(msg[setStart+2:], uint16(len(msg)-setStart))
+	return msg
+}
+
+func appendTemplateSet(msg []byte) []byte {
+	setStart := len(msg)
+	msg = binary.BigEndian.AppendUint16(msg, ipfixTemplateSetID)
+	msg = binary.BigEndian.AppendUint16(msg, 0)
+	msg = appendTemplate(msg, ipfixTemplateIPv4, ipfixIPv4Fields)
+	msg = appendTemplate(msg, ipfixTemplateIPv6, ipfixIPv6Fields)
+	return closeSet(msg, setStart)
+}
+
+func appendTemplate(msg []byte, id uint16, fields []ipfixField) []byte {
+	msg = binary.BigEndian.AppendUint16(msg, id)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(fields)))
+	for _, field := range fields {
+		msg = binary.BigEndian.AppendUint16(msg, field.id)
+		msg = binary.BigEndian.AppendUint16(msg, field.length)
+	}
+	return msg
+}
+
+func appendRecord(msg []byte, record Record, ipv4 bool) []byte {
+	if ipv4 {
+		src, dst := record.SrcIP.Unmap().As4(), record.DstIP.Unmap().As4()
+		msg = append(msg, src[:]...)
+		msg = append(msg, dst[:]...)
+	} else {
+		src, dst := record.SrcIP.As16(), record.DstIP.As16()
+		msg = append(msg, src[:]...)
+		msg = append(msg, dst[:]...)
+	}
+
+	msg = binary.BigEndian.AppendUint16(msg, record.SrcPort)
+	msg = binary.BigEndian.AppendUint16(msg, record.DstPort)
+	msg = append(msg, record.Protocol, uint8(record.Direction))
+	if record.Dropped {
+		msg = append(msg, ipfixDropped)
+	} else {
+		msg = append(msg, ipfixForwarded)
+	}
+	msg = binary.BigEndian.AppendUint64(msg, record.Bytes)
+	msg = binary.BigEndian.AppendUint64(msg, record.Packets)
+	msg = binary.BigEndian.AppendUint64(msg, uint64(record.Start.UnixMilli()))
+	msg = binary.BigEndian.AppendUint64(msg, uint64(record.End.UnixMilli()))
+	return msg
+}
+
+func fieldsLen(fields []ipfixField) int {
+	var length int
+	for _, field := range fields {
+		length += int(field.length)
+	}
+	return length
+}
@@ -0,0 +1,93 @@
+package flow
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+)
+
+const jsonDialTimeout = 5 * time.Second
+
+// jsonRecord is the JSON representation of a Record
+type jsonRecord struct {
+	SrcIP     string    `json:"src_ip"`
+	DstIP     string    `json:"dst_ip"`
+	SrcPort   uint16    `json:"src_port"`
+	DstPort   uint16    `json:"dst_port"`
+	Protocol  uint8     `json:"protocol"`
+	Direction string    `json:"direction"`
+	Dropped   bool      `json:"dropped"`
+	Packets   uint64    `json:"packets"`
+	Bytes     uint64    `json:"bytes"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// jsonExporter writes newline delimited JSON records to a TCP collector, reconnecting on failures
+type jsonExporter struct {
+	addr string
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newJSONExporter(addr string) *jsonExporter {
+	return &jsonExporter{addr: addr}
+}
+
+// Export writes the records to the collector
+func (e *jsonExporter) Export(records []Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		conn, err := net.DialTimeout("tcp", e.addr, jsonDialTimeout)
+		if err != nil {
+			return err
+		}
+		e.conn = conn
+	}
+
+	w := bufio.NewWriter(e.conn)
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		err := encoder.Encode(jsonRecord{
+			SrcIP:     record.SrcIP.String(),
+			DstIP:     record.DstIP.String(),
+			SrcPort:   record.SrcPort,
+			DstPort:   record.DstPort,
+			Protocol:  record.Protocol,
+			Direction: record.Direction.String(),
+			Dropped:   record.Dropped,
+			Packets:   record.Packets,
+			Bytes:     record.Bytes,
+			Start:     record.Start.UTC(),
+			End:       record.End.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := w.Flush()
+	if err != nil {
+		// the collector might have restarted, connect again on the next export
+		_ = e.conn.Close()
+		e.conn = nil
+	}
+	return err
+}
+
+// Close closes the connection to the collector
+func (e *jsonExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+	err := e.conn.Close()
+	e.conn = nil
+	return err
+}
@@ -20,6 +20,14 @@ const (
 	chainNameInputRules  = "NETBIRD-ACL-INPUT"
 	chainNameOutputRules = "NETBIRD-ACL-OUTPUT"
 
+	// routed chains decide the verdict of the traffic exchanged with the routed networks
+	chainNameRoutedInput  = "NETBIRD-ACL-INPUT-ROUTED"
+	chainNameRoutedOutput = "NETBIRD-ACL-OUTPUT-ROUTED"
+
+	// local chains accept the traffic of the allowed local ports before any other rule
+	chainNameLocalInput  = "NETBIRD-ACL-INPUT-LOCAL"
+	chainNameLocalOutput = "NETBIRD-ACL-OUTPUT-LOCAL"
+
 	postRoutingMark = "0x000007e4"
 )
 
@@ -30,6 +38,14 @@ type aclManager struct {
 
 	entries    map[string][][]string
 	ipsetStore *ipsetStore
+
+	// defaultDeny makes the traffic of the routed chains go through the ACL rules
+	defaultDeny bool
+	// localPorts are the local ports accepted by the local chains
+	localPorts []firewall.LocalPort
+
+	// tx is the running transaction, nil if no transaction was started
+	tx *aclTx
 }
 
 func newAclManager(iptablesClient *iptables.IPTables, wgIface iFaceMapper, routeingFwChainName string) (*aclManager, error) {
@@ -53,6 +69,7 @@ func newAclManager(iptablesClient *iptables.IPTables, wgIface iFaceMapper, route
 	if err != nil {
 		return nil, err
 	}
+	m.destroyOrphanedIpsets()
 
 	err = m.createDefaultChains()
 	if err != nil {
@@ -70,14 +87,8 @@ func (m *aclManager) AddFiltering(
 	action firewall.Action,
 	ipsetName string,
 ) ([]firewall.Rule, error) {
-	var dPortVal, sPortVal string
-	if dPort != nil && dPort.Values != nil {
-		// TODO: we support only one port per rule in current implementation of ACLs
-		dPortVal = strconv.Itoa(dPort.Values[0])
-	}
-	if sPort != nil && sPort.Values != nil {
-		sPortVal = strconv.Itoa(sPort.Values[0])
-	}
+	dPortVal := portSpec(dPort)
+	sPortVal := portSpec(sPort)
 
 	var chain string
 	if direction == firewall.RuleDirectionOUT {
@@ -88,9 +99,10 @@ func (m *aclManager) AddFiltering(
 
 	ipsetName = transformIPsetName(ipsetName, sPortVal, dPortVal)
 	specs := filterRuleSpecs(ip, string(protocol), sPortVal, dPortVal, direction, action, ipsetName)
+	logSpecs := logRuleSpecs(specs, action)
 	if ipsetName != "" {
 		if ipList, ipsetExists := m.ipsetStore.ipset(ipsetName); ipsetExists {
-			if err := ipset.Add(ipsetName, ip.String()); err != nil {
+			if err := m.addIPToIpset(ipsetName, ip.String()); err != nil {
 				return nil, fmt.Errorf("failed to add IP to ipset: %w", err)
 			}
 			// if ruleset already exists it means we already have the firewall rule
@@ -102,38 +114,36 @@ func (m *aclManager) AddFiltering(
 				ip:        ip.String(),
 				chain:     chain,
 				specs:     specs,
+				logSpecs:  logSpecs,
 			}}, nil
 		}
 
-		if err := ipset.Flush(ipsetName); err != nil {
-			log.Errorf("flush ipset %s before use it: %s", ipsetName, err)
-		}
-		if err := ipset.Create(ipsetName); err != nil {
-			return nil, fmt.Errorf("failed to create ipset: %w", err)
-		}
-		if err := ipset.Add(ipsetName, ip.String()); err != nil {
-			return nil, fmt.Errorf("failed to add IP to ipset: %w", err)
+		if err := m.createIpset(ipsetName, ip.String()); err != nil {
+			return nil, err
 		}
 
 		ipList := newIpList(ip.String())
 		m.ipsetStore.addIpList(ipsetName, ipList)
 	}
 
-	ok, err := m.iptablesClient.Exists("filter", chain, specs...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check rule: %w", err)
-	}
-	if ok {
-		return nil, fmt.Errorf("rule already exists")
+	if err := m.insertRule("filter", chain, specs); err != nil {
+		return nil, err
 	}
 
-	if err := m.iptablesClient.Insert("filter", chain, 1, specs...); err != nil {
-		return nil, err
+	// the rules are inserted at the top of the chain, the logging rule goes before the verdict
+	if logSpecs != nil {
+		if err := m.insertRule("filter", chain, logSpecs); err != nil {
+			if delErr := m.deleteRule("filter", chain, specs); delErr != nil {
+				log.Errorf("failed to delete rule %v: %v", specs, delErr)
+			}
+			return nil, err
+		}
 	}
 
 	rule := &Rule{
 		ruleID:    uuid.New().String(),
 		specs:     specs,
+		logSpecs:  logSpecs,
 		ipsetName: ipsetName,
 		ip:        ip.String(),
 		chain:     chain,
@@ -164,7 +174,7 @@ func (m *aclManager) DeleteRule(rule firewall.Rule) error {
 	if ipsetList, ok := m.ipsetStore.ipset(r.ipsetName); ok {
 		// delete IP from ruleset IPs list and ipset
 		if _, ok := ipsetList.ips[r.ip]; ok {
-			if err := ipset.Del(r.ipsetName, r.ip); err != nil {
+			if err := m.deleteIPFromIpset(r.ipsetName, r.ip); err != nil {
 				return fmt.Errorf("failed to delete ip from ipset: %w", err)
 			}
 			delete(ipsetList.ips, r.ip)
@@ -180,7 +190,7 @@ func (m *aclManager) DeleteRule(rule firewall.Rule) error {
 		// set itself and associated firewall rule too
 		m.ipsetStore.deleteIpset(r.ipsetName)
 
-		if err := ipset.Destroy(r.ipsetName); err != nil {
+		if err := m.destroyIpset(r.ipsetName); err != nil {
 			log.Errorf("delete empty ipset: %v", err)
 		}
 	}
@@ -192,14 +202,83 @@ DELETERULE:
 	} else {
 		table = "filter"
 	}
-	err := m.iptablesClient.Delete(table, r.chain, r.specs...)
+	if r.logSpecs != nil {
+		if err := m.deleteRule(table, r.chain, r.logSpecs); err != nil {
+			log.Debugf("failed to delete rule, %s, %v: %s", r.chain, r.logSpecs, err)
+		}
+	}
+	err := m.deleteRule(table, r.chain, r.specs)
 	if err != nil {
 		log.Debugf("failed to delete rule, %s, %v: %s", r.chain, r.specs, err)
 	}
 	return err
 }
 
+// setDefaultDeny switches the routed chains between accepting their traffic and filtering it with the ACL rules
+func (m *aclManager) setDefaultDeny(enabled bool) error {
+	if m.tx != nil {
+		return firewall.ErrTxInProgress
+	}
+
+	m.defaultDeny = enabled
+	return m.applyDefaultPolicy()
+}
+
+// applyDefaultPolicy fills the routed chains: their traffic is accepted or, in default deny mode, it goes through the
+// ACL rules and it is dropped when no rule accepts it
+func (m *aclManager) applyDefaultPolicy() error {
+	for chain, rulesChain := range map[string]string{
+		chainNameRoutedInput:  chainNameInputRules,
+		chainNameRoutedOutput: chainNameOutputRules,
+	} {
+		// the chain is created if it doesn't exist
+		if err := m.iptablesClient.ClearChain(tableName, chain); err != nil {
+			return fmt.Errorf("failed to flush chain %s: %w", chain, err)
+		}
+
+		rules := [][]string{{"-j", "ACCEPT"}}
+		if m.defaultDeny {
+			rules = [][]string{{"-j", rulesChain}, {"-j", "DROP"}}
+		}
+		for _, rule := range rules {
+			if err := m.iptablesClient.Append(tableName, chain, rule...); err != nil {
+				return fmt.Errorf("failed to add rule to chain %s: %w", chain, err)
+			}
+		}
+	}
+	return nil
+}
+
+// setAllowedLocalPorts replaces the local ports accepted by the local chains
+func (m *aclManager) setAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.localPorts = ports
+	return m.applyLocalPorts()
+}
+
+// applyLocalPorts fills the local chains with the rules accepting the packets received on the allowed local ports and
+// the packets sent from them
+func (m *aclManager) applyLocalPorts() error {
+	for chain, portOption := range map[string]string{
+		chainNameLocalInput:  "--dport",
+		chainNameLocalOutput: "--sport",
+	} {
+		// the chain is created if it doesn't exist
+		if err := m.iptablesClient.ClearChain(tableName, chain); err != nil {
+			return fmt.Errorf("failed to flush chain %s: %w", chain, err)
+		}
+
+		for _, port := range m.localPorts {
+			rule := []string{"-p", string(port.Protocol), portOption, strconv.Itoa(port.Port), "-j", "ACCEPT"}
+			if err := m.iptablesClient.Append(tableName, chain, rule...); err != nil {
+				return fmt.Errorf("failed to add rule to chain %s: %w", chain, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (m *aclManager) Reset() error {
+	m.tx = nil
 	return m.cleanChains()
 }
 
@@ -217,17 +296,9 @@ func (m *aclManager) addPreroutingFilter(ipsetName string, protocol string, port
 		"-j", "MARK", "--set-mark", postRoutingMark,
 	}
 
-	specs = append(src, specs...)
+	specs = withComment(append(src, specs...))
 
-	ok, err := m.iptablesClient.Exists("mangle", "PREROUTING", specs...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check rule: %w", err)
-	}
-	if ok {
-		return nil, fmt.Errorf("rule already exists")
-	}
-
-	if err := m.iptablesClient.Insert("mangle", "PREROUTING", 1, specs...); err != nil {
+	if err := m.insertRule("mangle", "PREROUTING", specs); err != nil {
 		return nil, err
 	}
 
@@ -243,6 +314,27 @@ func (m *aclManager) addPreroutingFilter(ipsetName string, protocol string, port
 
 // todo write less destructive cleanup mechanism
 func (m *aclManager) cleanChains() error {
+	// the routed chains may jump to the ACL chains, they are flushed first and deleted once they aren't referenced
+	jumpedChains := []string{chainNameRoutedInput, chainNameRoutedOutput, chainNameLocalInput, chainNameLocalOutput}
+	for _, chain := range jumpedChains {
+		if ok, err := m.iptablesClient.ChainExists(tableName, chain); err != nil || !ok {
+			continue
+		}
+		if err := m.iptablesClient.ClearChain(tableName, chain); err != nil {
+			log.Debugf("failed to clear %s chain: %s", chain, err)
+		}
+	}
+	defer func() {
+		for _, chain := range jumpedChains {
+			if ok, err := m.iptablesClient.ChainExists(tableName, chain); err != nil || !ok {
+				continue
+			}
+			if err := m.iptablesClient.DeleteChain(tableName, chain); err != nil {
+				log.Debugf("failed to delete %s chain: %s", chain, err)
+			}
+		}
+	}()
+
 	ok, err := m.iptablesClient.ChainExists(tableName, chainNameOutputRules)
 	if err != nil {
 		log.Debugf("failed to list chains: %s", err)
@@ -336,6 +428,18 @@ func (m *aclManager) createDefaultChains() error {
 		return err
 	}
 
+	// chains netbird-acl-input-routed and netbird-acl-output-routed
+	if err := m.applyDefaultPolicy(); err != nil {
+		log.Debugf("failed to create routed chains: %s", err)
+		return err
+	}
+
+	// chains netbird-acl-input-local and netbird-acl-output-local
+	if err := m.applyLocalPorts(); err != nil {
+		log.Debugf("failed to create local chains: %s", err)
+		return err
+	}
+
 	for chainName, rules := range m.entries {
 		for _, rule := range rules {
 			if chainName == "FORWARD" {
@@ -357,22 +461,27 @@ func (m *aclManager) createDefaultChains() error {
 }
 
 func (m *aclManager) seedInitialEntries() {
+	// the allowed local ports take precedence over the ACL rules
+	m.appendToEntries("INPUT", []string{"-i", m.wgIface.Name(), "-j", chainNameLocalInput})
+
 	m.appendToEntries("INPUT",
-		[]string{"-i", m.wgIface.Name(), "!", "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", "ACCEPT"})
+		[]string{"-i", m.wgIface.Name(), "!", "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", chainNameRoutedInput})
 
 	m.appendToEntries("INPUT",
-		[]string{"-i", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "!", "-d", m.wgIface.Address().String(), "-j", "ACCEPT"})
+		[]string{"-i", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "!", "-d", m.wgIface.Address().String(), "-j", chainNameRoutedInput})
 
 	m.appendToEntries("INPUT",
 		[]string{"-i", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", chainNameInputRules})
 
 	m.appendToEntries("INPUT", []string{"-i", m.wgIface.Name(), "-j", "DROP"})
 
+	m.appendToEntries("OUTPUT", []string{"-o", m.wgIface.Name(), "-j", chainNameLocalOutput})
+
 	m.appendToEntries("OUTPUT",
-		[]string{"-o", m.wgIface.Name(), "!", "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", "ACCEPT"})
+		[]string{"-o", m.wgIface.Name(), "!", "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", chainNameRoutedOutput})
 
 	m.appendToEntries("OUTPUT",
-		[]string{"-o", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "!", "-d", m.wgIface.Address().String(), "-j", "ACCEPT"})
+		[]string{"-o", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "!", "-d", m.wgIface.Address().String(), "-j", chainNameRoutedOutput})
 
 	m.appendToEntries("OUTPUT",
 		[]string{"-o", m.wgIface.Name(), "-s", m.wgIface.Address().String(), "-d", m.wgIface.Address().String(), "-j", chainNameOutputRules})
@@ -393,7 +502,7 @@ func (m *aclManager) seedInitialEntries() {
 }
 
 func (m *aclManager) appendToEntries(chainName string, spec []string) {
-	m.entries[chainName] = append(m.entries[chainName], spec)
+	m.entries[chainName] = append(m.entries[chainName], withComment(spec))
 }
 
 // filterRuleSpecs returns the specs of a filtering rule
@@ -435,11 +544,37 @@ func filterRuleSpecs(
 	return append(specs, "-j", actionToStr(action))
 }
 
+// logRuleSpecs returns the specs of the rule sending the packets matched by a filtering rule to the netlink log group,
+// rate limited, or nil if the action isn't logged
+func logRuleSpecs(filterSpecs []string, action firewall.Action) []string {
+	if !action.Logs() {
+		return nil
+	}
+	// the match of the filtering rule without its verdict
+	specs := append([]string{}, filterSpecs[:len(filterSpecs)-2]...)
+	return append(specs,
+		"-m", "limit", "--limit", fmt.Sprintf("%d/sec", firewall.LogRate), "--limit-burst", strconv.Itoa(firewall.LogBurst),
+		"-j", "NFLOG", "--nflog-group", strconv.Itoa(firewall.LogGroup), "--nflog-prefix", action.LogPrefix(),
+	)
+}
+
+// portSpec returns the value of the --sport and --dport options for the port, a range is written as start:end
+func portSpec(port *firewall.Port) string {
+	if port == nil || len(port.Values) == 0 {
+		return ""
+	}
+	// TODO: we support only one port or one range per rule in current implementation of ACLs
+	if port.IsRange && len(port.Values) == 2 {
+		return fmt.Sprintf("%d:%d", port.Values[0], port.Values[1])
+	}
+	return strconv.Itoa(port.Values[0])
+}
+
 func actionToStr(action firewall.Action) string {
-	if action == firewall.ActionAccept {
-		return "ACCEPT"
+	if action.Drops() {
+		return "DROP"
 	}
-	return "DROP"
+	return "ACCEPT"
 }
 
 func transformIPsetName(ipsetName string, sPort, dPort string) string {
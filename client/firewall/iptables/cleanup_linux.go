@@ -0,0 +1,121 @@
+package iptables
+
+import (
+	"strings"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ruleComment tags the rules NetBird adds to the built-in chains, so that they can be found after a crash
+	ruleComment = "netbird"
+
+	// chainPrefix is the prefix of the chains created by NetBird
+	chainPrefix = "NETBIRD-"
+)
+
+// builtinChains are the built-in chains NetBird adds rules to, by table
+var builtinChains = map[string][]string{
+	"filter": {"INPUT", "OUTPUT", "FORWARD"},
+	"nat":    {"POSTROUTING"},
+	"mangle": {"PREROUTING"},
+}
+
+// withComment returns the specs of the rule tagged with the NetBird comment
+func withComment(specs []string) []string {
+	return append([]string{"-m", "comment", "--comment", ruleComment}, specs...)
+}
+
+// isNetbirdRule returns true for a rule listed by iptables -S which NetBird added: the rules tagged with the NetBird
+// comment and the jumps to the NetBird chains
+func isNetbirdRule(rule string) bool {
+	fields := strings.Fields(rule)
+	for i := 0; i < len(fields)-1; i++ {
+		switch fields[i] {
+		case "--comment":
+			if strings.Trim(fields[i+1], `"`) == ruleComment {
+				return true
+			}
+		case "-j", "-g":
+			if strings.HasPrefix(fields[i+1], chainPrefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeStaleRules removes the rules and the chains left behind by a client which didn't shut down cleanly, before
+// the managers create theirs
+func removeStaleRules(iptablesClient *iptables.IPTables) {
+	for table, chains := range builtinChains {
+		for _, chain := range chains {
+			removeNetbirdRules(iptablesClient, table, chain)
+		}
+	}
+
+	// the ufw hook chains jump to the NetBird chains, they are only flushed because the ufw chains jump to them
+	for _, hook := range ufwHooks {
+		if ok, err := iptablesClient.ChainExists(tableName, hook.chain); err != nil || !ok {
+			continue
+		}
+		if err := iptablesClient.ClearChain(tableName, hook.chain); err != nil {
+			log.Warnf("failed to flush stale chain %s: %v", hook.chain, err)
+		}
+	}
+
+	// the chains are all flushed before they are deleted because they may jump to each other
+	staleChains := make(map[string][]string)
+	for table := range builtinChains {
+		chains, err := iptablesClient.ListChains(table)
+		if err != nil {
+			log.Warnf("failed to list chains of table %s: %v", table, err)
+			continue
+		}
+		for _, chain := range chains {
+			if !strings.HasPrefix(chain, chainPrefix) {
+				continue
+			}
+			if err := iptablesClient.ClearChain(table, chain); err != nil {
+				log.Warnf("failed to flush stale chain %s of table %s: %v", chain, table, err)
+				continue
+			}
+			staleChains[table] = append(staleChains[table], chain)
+		}
+	}
+
+	for table, chains := range staleChains {
+		for _, chain := range chains {
+			if err := iptablesClient.DeleteChain(table, chain); err != nil {
+				log.Warnf("failed to delete stale chain %s of table %s: %v", chain, table, err)
+				continue
+			}
+			log.Debugf("removed stale chain %s of table %s", chain, table)
+		}
+	}
+}
+
+func removeNetbirdRules(iptablesClient *iptables.IPTables, table, chain string) {
+	rules, err := iptablesClient.List(table, chain)
+	if err != nil {
+		log.Warnf("failed to list rules of chain %s of table %s: %v", chain, table, err)
+		return
+	}
+
+	for _, rule := range rules {
+		if !isNetbirdRule(rule) {
+			continue
+		}
+		// the rules are listed as -A <chain> <specs>
+		fields := strings.Fields(rule)
+		if len(fields) < 3 {
+			continue
+		}
+		if err := iptablesClient.DeleteIfExists(table, chain, fields[2:]...); err != nil {
+			log.Warnf("failed to delete stale rule %q: %v", rule, err)
+			continue
+		}
+		log.Debugf("removed stale rule %q", rule)
+	}
+}
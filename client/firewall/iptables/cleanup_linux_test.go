@@ -0,0 +1,62 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNetbirdRule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     string
+		expected bool
+	}{
+		{
+			name:     "tagged rule",
+			rule:     `-A INPUT -i wt0 -m comment --comment netbird -j DROP`,
+			expected: true,
+		},
+		{
+			name:     "quoted tag",
+			rule:     `-A PREROUTING -i wt0 -m comment --comment "netbird" -j MARK --set-xmark 0x3e8/0xffffffff`,
+			expected: true,
+		},
+		{
+			name:     "jump to a netbird chain",
+			rule:     `-A FORWARD -o wt0 -j NETBIRD-RT-FWD`,
+			expected: true,
+		},
+		{
+			name:     "goto a netbird chain",
+			rule:     `-A INPUT -g NETBIRD-ACL-INPUT`,
+			expected: true,
+		},
+		{
+			name:     "kill switch jump",
+			rule:     `-A OUTPUT -m comment --comment netbird-kill-switch -j NB-KILLSWITCH-OUTPUT`,
+			expected: false,
+		},
+		{
+			name:     "other comment",
+			rule:     `-A INPUT -m comment --comment docker -j ACCEPT`,
+			expected: false,
+		},
+		{
+			name:     "other chain",
+			rule:     `-A FORWARD -j DOCKER-USER`,
+			expected: false,
+		},
+		{
+			name:     "chain policy",
+			rule:     `-P INPUT ACCEPT`,
+			expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, isNetbirdRule(testCase.rule))
+		})
+	}
+}
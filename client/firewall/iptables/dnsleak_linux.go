@@ -0,0 +1,106 @@
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// chainNameDNSLeak drops the DNS queries sent to other resolvers than the allowed ones, the OUTPUT chain jumps to it
+const chainNameDNSLeak = "NETBIRD-DNS-LEAK"
+
+// dnsLeakGuard keeps the DNS leak protection chain of the IPv4 filter table and, when ip6tables is available, of
+// the IPv6 one. The rest of the manager only handles IPv4, but the IPv6 queries would leak otherwise
+type dnsLeakGuard struct {
+	clients []*iptables.IPTables
+}
+
+func newDNSLeakGuard(ipv4Client *iptables.IPTables) *dnsLeakGuard {
+	g := &dnsLeakGuard{clients: []*iptables.IPTables{ipv4Client}}
+
+	ipv6Client, err := iptables.NewWithProtocol(iptables.ProtocolIPv6)
+	if err != nil {
+		log.Debugf("ip6tables is not available, the IPv6 DNS queries won't be filtered: %v", err)
+	} else {
+		g.clients = append(g.clients, ipv6Client)
+	}
+
+	// the chain is left behind if the client didn't shut down cleanly
+	if err := g.reset(); err != nil {
+		log.Warnf("failed to remove the stale DNS leak protection rules: %v", err)
+	}
+	return g
+}
+
+// set replaces the resolvers allowed to receive DNS queries, nil removes the protection
+func (g *dnsLeakGuard) set(allowed []net.IP) error {
+	if allowed == nil {
+		return g.reset()
+	}
+
+	for _, client := range g.clients {
+		if err := g.apply(client, allowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply fills the chain with the allowed resolvers of the family of the client and makes the OUTPUT chain jump to it
+func (g *dnsLeakGuard) apply(client *iptables.IPTables, allowed []net.IP) error {
+	// the chain is created if it doesn't exist
+	if err := client.ClearChain(tableName, chainNameDNSLeak); err != nil {
+		return fmt.Errorf("failed to flush chain %s: %w", chainNameDNSLeak, err)
+	}
+
+	rules := [][]string{{"-o", "lo", "-j", "RETURN"}}
+	for _, ip := range allowed {
+		if (ip.To4() != nil) != (client.Proto() == iptables.ProtocolIPv4) {
+			continue
+		}
+		rules = append(rules, []string{"-d", ip.String(), "-j", "RETURN"})
+	}
+	for _, protocol := range []string{"udp", "tcp"} {
+		for _, port := range firewall.DNSPorts {
+			rules = append(rules, []string{"-p", protocol, "--dport", strconv.Itoa(port), "-j", "DROP"})
+		}
+	}
+
+	for _, rule := range rules {
+		if err := client.Append(tableName, chainNameDNSLeak, rule...); err != nil {
+			return fmt.Errorf("failed to add rule to chain %s: %w", chainNameDNSLeak, err)
+		}
+	}
+
+	// the queries are filtered before the NetBird rules of the OUTPUT chain accept them
+	if err := client.InsertUnique(tableName, "OUTPUT", 1, withComment([]string{"-j", chainNameDNSLeak})...); err != nil {
+		return fmt.Errorf("failed to add jump rule to chain %s: %w", chainNameDNSLeak, err)
+	}
+	return nil
+}
+
+// reset removes the jump to the chain and the chain
+func (g *dnsLeakGuard) reset() error {
+	for _, client := range g.clients {
+		if err := client.DeleteIfExists(tableName, "OUTPUT", withComment([]string{"-j", chainNameDNSLeak})...); err != nil {
+			return fmt.Errorf("failed to delete jump rule to chain %s: %w", chainNameDNSLeak, err)
+		}
+
+		ok, err := client.ChainExists(tableName, chainNameDNSLeak)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if err := client.ClearAndDeleteChain(tableName, chainNameDNSLeak); err != nil {
+			return fmt.Errorf("failed to clear and delete %s chain: %w", chainNameDNSLeak, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package iptables
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nadoo/ipset"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ipsetCmd = "ipset"
+
+	// ipsetSwapSuffix is appended to the name of a set to get the name of the temporary set swapped with it
+	ipsetSwapSuffix = "-swap"
+)
+
+// managedIpsetName matches the names of the sets created for the ACL rules, nb%07d, and of their temporary sets
+var managedIpsetName = regexp.MustCompile(`^nb\d{7}(` + ipsetSwapSuffix + `)?$`)
+
+// swapIpsetScript returns the ipset restore commands replacing the content of the set at once: a temporary set is
+// filled with the IPs, swapped with the set and destroyed with the previous content
+func swapIpsetScript(name string, ips []string) string {
+	tmpName := name + ipsetSwapSuffix
+
+	sorted := append([]string(nil), ips...)
+	sort.Strings(sorted)
+
+	var script strings.Builder
+	// the temporary set may be left behind by a crash, it is flushed in that case
+	fmt.Fprintf(&script, "create %s hash:net family inet -exist\n", tmpName)
+	fmt.Fprintf(&script, "flush %s\n", tmpName)
+	for _, ip := range sorted {
+		fmt.Fprintf(&script, "add %s %s\n", tmpName, ip)
+	}
+	fmt.Fprintf(&script, "swap %s %s\n", tmpName, name)
+	fmt.Fprintf(&script, "destroy %s\n", tmpName)
+	return script.String()
+}
+
+// swapIpset replaces the content of the set with the IPs atomically, the rules matching the set never see a partial
+// content
+func swapIpset(ipsetPath, name string, ips []string) error {
+	cmd := exec.Command(ipsetPath, "restore")
+	cmd.Stdin = strings.NewReader(swapIpsetScript(name, ips))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s restore failed: %w: %s", ipsetCmd, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// listIpsets returns the names of all the sets of the system
+func listIpsets(ipsetPath string) ([]string, error) {
+	out, err := exec.Command(ipsetPath, "list", "-n").Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s list failed: %w", ipsetCmd, err)
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// orphanedIpsets returns the sets named like the ACL sets which are not in use
+func orphanedIpsets(names []string, store *ipsetStore) []string {
+	var orphaned []string
+	for _, name := range names {
+		if !managedIpsetName.MatchString(name) {
+			continue
+		}
+		// the temporary sets only live during a swap and are never in the store
+		if _, ok := store.ipset(name); ok {
+			continue
+		}
+		orphaned = append(orphaned, name)
+	}
+	return orphaned
+}
+
+// destroyOrphanedIpsets destroys the ACL sets left behind, e.g. by a crash of the client. The rules referencing them
+// must be removed before, otherwise the sets can't be destroyed
+func (m *aclManager) destroyOrphanedIpsets() {
+	ipsetPath, err := exec.LookPath(ipsetCmd)
+	if err != nil {
+		log.Debugf("%s is not installed, skipping the cleanup of orphaned ipsets", ipsetCmd)
+		return
+	}
+
+	names, err := listIpsets(ipsetPath)
+	if err != nil {
+		log.Warnf("failed to list ipsets: %v", err)
+		return
+	}
+
+	for _, name := range orphanedIpsets(names, m.ipsetStore) {
+		if err := ipset.Flush(name); err != nil {
+			log.Errorf("flush orphaned ipset %q: %v", name, err)
+		}
+		if err := ipset.Destroy(name); err != nil {
+			log.Errorf("destroy orphaned ipset %q: %v", name, err)
+			continue
+		}
+		log.Debugf("destroyed orphaned ipset %s", name)
+	}
+}
+
+// ipsetIPs returns the IPs of the ipset list
+func ipsetIPs(list ipList) []string {
+	ips := make([]string, 0, len(list.ips))
+	for ip := range list.ips {
+		ips = append(ips, ip)
+	}
+	return ips
+}
@@ -0,0 +1,27 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwapIpsetScript(t *testing.T) {
+	script := swapIpsetScript("nb0000001", []string{"100.64.0.3", "100.64.0.2"})
+
+	expected := "create nb0000001-swap hash:net family inet -exist\n" +
+		"flush nb0000001-swap\n" +
+		"add nb0000001-swap 100.64.0.2\n" +
+		"add nb0000001-swap 100.64.0.3\n" +
+		"swap nb0000001-swap nb0000001\n" +
+		"destroy nb0000001-swap\n"
+	require.Equal(t, expected, script)
+}
+
+func TestOrphanedIpsets(t *testing.T) {
+	store := newIpsetStore()
+	store.addIpList("nb0000002", newIpList("100.64.0.2"))
+
+	names := []string{"nb0000001", "nb0000002", "nb0000002-swap", "docker-set", "nb123", "nb0000003-swap"}
+	require.Equal(t, []string{"nb0000001", "nb0000002-swap", "nb0000003-swap"}, orphanedIpsets(names, store))
+}
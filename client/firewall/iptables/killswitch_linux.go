@@ -0,0 +1,153 @@
+package iptables
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const (
+	// the kill switch chains and rules don't use the NetBird chain prefix and comment, so that they survive the
+	// removal of the stale rules when a firewall manager is created
+	chainNameKillSwitchInput  = "NB-KILLSWITCH-INPUT"
+	chainNameKillSwitchOutput = "NB-KILLSWITCH-OUTPUT"
+	killSwitchComment         = "netbird-kill-switch"
+)
+
+// ndpTypes are the ICMPv6 types of the router and neighbor solicitations and advertisements
+var ndpTypes = []string{"133", "134", "135", "136"}
+
+// KillSwitch drops the traffic which doesn't go through the NetBird interface with iptables and ip6tables. The
+// allowed traffic returns to the chain it came from, so the kill switch never accepts what other rules drop
+type KillSwitch struct {
+	clients []*iptables.IPTables
+}
+
+// NewKillSwitch creates an iptables kill switch, it filters the IPv6 traffic too when ip6tables is available
+func NewKillSwitch() (*KillSwitch, error) {
+	ipv4Client, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+	if err != nil {
+		return nil, fmt.Errorf("iptables is not installed in the system or not supported")
+	}
+	k := &KillSwitch{clients: []*iptables.IPTables{ipv4Client}}
+
+	ipv6Client, err := iptables.NewWithProtocol(iptables.ProtocolIPv6)
+	if err != nil {
+		log.Warnf("ip6tables is not available, the IPv6 traffic won't be blocked by the kill switch: %v", err)
+	} else {
+		k.clients = append(k.clients, ipv6Client)
+	}
+	return k, nil
+}
+
+// Enable fills the kill switch chains with the rules of the config and makes the INPUT and OUTPUT chains jump to
+// them first
+func (k *KillSwitch) Enable(config firewall.KillSwitchConfig) error {
+	for _, client := range k.clients {
+		ipv6 := client.Proto() == iptables.ProtocolIPv6
+		chains := map[string][][]string{
+			chainNameKillSwitchInput:  killSwitchInputRules(config, ipv6),
+			chainNameKillSwitchOutput: killSwitchOutputRules(config, ipv6),
+		}
+		for chain, rules := range chains {
+			// the chain is created if it doesn't exist
+			if err := client.ClearChain(tableName, chain); err != nil {
+				return fmt.Errorf("failed to flush chain %s: %w", chain, err)
+			}
+			for _, rule := range rules {
+				if err := client.Append(tableName, chain, rule...); err != nil {
+					return fmt.Errorf("failed to add rule to chain %s: %w", chain, err)
+				}
+			}
+		}
+
+		for builtin, chain := range map[string]string{"INPUT": chainNameKillSwitchInput, "OUTPUT": chainNameKillSwitchOutput} {
+			if err := client.InsertUnique(tableName, builtin, 1, killSwitchJump(chain)...); err != nil {
+				return fmt.Errorf("failed to add jump rule to chain %s: %w", chain, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Disable removes the jumps to the kill switch chains and the chains
+func (k *KillSwitch) Disable() error {
+	for _, client := range k.clients {
+		for builtin, chain := range map[string]string{"INPUT": chainNameKillSwitchInput, "OUTPUT": chainNameKillSwitchOutput} {
+			if err := client.DeleteIfExists(tableName, builtin, killSwitchJump(chain)...); err != nil {
+				return fmt.Errorf("failed to delete jump rule to chain %s: %w", chain, err)
+			}
+
+			ok, err := client.ChainExists(tableName, chain)
+			if err != nil {
+				return fmt.Errorf("failed to list chains: %w", err)
+			}
+			if !ok {
+				continue
+			}
+			if err := client.ClearAndDeleteChain(tableName, chain); err != nil {
+				return fmt.Errorf("failed to clear and delete %s chain: %w", chain, err)
+			}
+		}
+	}
+	return nil
+}
+
+func killSwitchJump(chain string) []string {
+	return []string{"-m", "comment", "--comment", killSwitchComment, "-j", chain}
+}
+
+// killSwitchOutputRules returns the rules of the output chain: the allowed traffic returns, the rest is dropped
+func killSwitchOutputRules(config firewall.KillSwitchConfig, ipv6 bool) [][]string {
+	rules := [][]string{
+		{"-o", "lo", "-j", "RETURN"},
+		{"-o", config.Interface, "-j", "RETURN"},
+		{"-p", "udp", "--sport", strconv.Itoa(config.WgPort), "-j", "RETURN"},
+	}
+	for _, ip := range config.ControlPlane {
+		if (ip.To4() == nil) != ipv6 {
+			continue
+		}
+		rules = append(rules, []string{"-d", ip.String(), "-j", "RETURN"})
+	}
+	dnsPort := strconv.Itoa(firewall.KillSwitchDNSPort)
+	rules = append(rules,
+		[]string{"-p", "udp", "--dport", dnsPort, "-j", "RETURN"},
+		[]string{"-p", "tcp", "--dport", dnsPort, "-j", "RETURN"},
+	)
+
+	if ipv6 {
+		rules = append(rules, []string{"-p", "udp", "--sport", "546", "--dport", "547", "-j", "RETURN"})
+		for _, ndpType := range ndpTypes {
+			rules = append(rules, []string{"-p", "ipv6-icmp", "--icmpv6-type", ndpType, "-j", "RETURN"})
+		}
+	} else {
+		rules = append(rules, []string{"-p", "udp", "--sport", "68", "--dport", "67", "-j", "RETURN"})
+	}
+	return append(rules, []string{"-j", "DROP"})
+}
+
+// killSwitchInputRules returns the rules of the input chain: the allowed traffic and the replies to the allowed
+// output traffic return, the rest is dropped
+func killSwitchInputRules(config firewall.KillSwitchConfig, ipv6 bool) [][]string {
+	rules := [][]string{
+		{"-i", "lo", "-j", "RETURN"},
+		{"-i", config.Interface, "-j", "RETURN"},
+		{"-p", "udp", "--dport", strconv.Itoa(config.WgPort), "-j", "RETURN"},
+		{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "RETURN"},
+	}
+
+	if ipv6 {
+		rules = append(rules, []string{"-p", "udp", "--sport", "547", "--dport", "546", "-j", "RETURN"})
+		for _, ndpType := range ndpTypes {
+			rules = append(rules, []string{"-p", "ipv6-icmp", "--icmpv6-type", ndpType, "-j", "RETURN"})
+		}
+	} else {
+		rules = append(rules, []string{"-p", "udp", "--sport", "67", "--dport", "68", "-j", "RETURN"})
+	}
+	return append(rules, []string{"-j", "DROP"})
+}
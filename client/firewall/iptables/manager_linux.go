@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/coreos/go-iptables/iptables"
 	log "github.com/sirupsen/logrus"
@@ -22,6 +23,13 @@ type Manager struct {
 	ipv4Client *iptables.IPTables
 	aclMgr     *aclManager
 	router     *routerManager
+	dnsLeak    *dnsLeakGuard
+	srcValid   *srcValidationGuard
+
+	// ufw keeps the NetBird rules ahead of the ufw rules, nil when ufw isn't active
+	ufw *ufwGuard
+	// stopUFWWatch stops reconciling the ufw hook chains, nil when they aren't reconciled
+	stopUFWWatch context.CancelFunc
 }
 
 // iFaceMapper defines subset methods of interface required for manager
@@ -32,7 +40,7 @@ type iFaceMapper interface {
 }
 
 // Create iptables firewall manager
-func Create(context context.Context, wgIface iFaceMapper) (*Manager, error) {
+func Create(ctx context.Context, wgIface iFaceMapper) (*Manager, error) {
 	iptablesClient, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
 	if err != nil {
 		return nil, fmt.Errorf("iptables is not installed in the system or not supported")
@@ -43,7 +51,11 @@ func Create(context context.Context, wgIface iFaceMapper) (*Manager, error) {
 		ipv4Client: iptablesClient,
 	}
 
-	m.router, err = newRouterManager(context, iptablesClient)
+	removeStaleRules(iptablesClient)
+	m.dnsLeak = newDNSLeakGuard(iptablesClient)
+	m.srcValid = newSrcValidationGuard(iptablesClient, wgIface)
+
+	m.router, err = newRouterManager(ctx, iptablesClient)
 	if err != nil {
 		log.Debugf("failed to initialize route related chains: %s", err)
 		return nil, err
@@ -54,9 +66,42 @@ func Create(context context.Context, wgIface iFaceMapper) (*Manager, error) {
 		return nil, err
 	}
 
+	m.ufw = newUFWGuard(iptablesClient, wgIface)
+	if m.ufw != nil {
+		log.Info("ufw is active, adding the NetBird rules to its chains")
+		if err := m.ufw.reconcile(); err != nil {
+			log.Errorf("failed to add the NetBird rules to the ufw chains: %v", err)
+		}
+
+		var watchCtx context.Context
+		watchCtx, m.stopUFWWatch = context.WithCancel(ctx)
+		go m.watchUFW(watchCtx)
+	}
+
 	return m, nil
 }
 
+// watchUFW adds the NetBird rules to the ufw chains again when ufw reloads and flushes them, or when the NetBird
+// rules of the built-in chains change
+func (m *Manager) watchUFW(ctx context.Context) {
+	ticker := time.NewTicker(ufwReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mutex.Lock()
+			err := m.ufw.reconcile()
+			m.mutex.Unlock()
+			if err != nil {
+				log.Warnf("failed to add the NetBird rules to the ufw chains: %v", err)
+			}
+		}
+	}
+}
+
 // AddFiltering rule to the firewall
 //
 // Comment will be ignored because some system this feature is not supported
@@ -102,11 +147,77 @@ func (m *Manager) RemoveRoutingRules(pair firewall.RouterPair) error {
 	return m.router.RemoveRoutingRules(pair)
 }
 
+// SetDefaultDeny makes the firewall drop the traffic exchanged with the routed networks unless an ACL rule accepts it
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclMgr.setDefaultDeny(enabled)
+}
+
+// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface before the ACL rules
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclMgr.setAllowedLocalPorts(ports)
+}
+
+// SetDNSLeakProtection drops the DNS queries sent to other destinations than the allowed resolvers
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.dnsLeak.set(allowed); err != nil {
+		return err
+	}
+	m.reconcileUFW()
+	return nil
+}
+
+// SetSourceValidation drops the packets forwarded between the NetBird interface and the other interfaces whose
+// source isn't allowed
+func (m *Manager) SetSourceValidation(validation *firewall.SourceValidation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := m.srcValid.set(validation); err != nil {
+		return err
+	}
+	m.reconcileUFW()
+	return nil
+}
+
+// reconcileUFW copies the changed NetBird rules of the built-in chains to the ufw hook chains right away
+func (m *Manager) reconcileUFW() {
+	if m.ufw == nil {
+		return
+	}
+	if err := m.ufw.reconcile(); err != nil {
+		log.Warnf("failed to add the NetBird rules to the ufw chains: %v", err)
+	}
+}
+
 // Reset firewall to the default state
 func (m *Manager) Reset() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	if m.stopUFWWatch != nil {
+		m.stopUFWWatch()
+	}
+	// the hook chains jump to the chains removed below
+	if m.ufw != nil {
+		if err := m.ufw.reset(); err != nil {
+			log.Errorf("failed to remove the NetBird rules from the ufw chains: %s", err)
+		}
+	}
+	if err := m.dnsLeak.reset(); err != nil {
+		log.Errorf("failed to remove the DNS leak protection from firewall: %s", err)
+	}
+	if err := m.srcValid.reset(); err != nil {
+		log.Errorf("failed to remove the source validation from firewall: %s", err)
+	}
 	errAcl := m.aclMgr.Reset()
 	if errAcl != nil {
 		log.Errorf("failed to clean up ACL rules from firewall: %s", errAcl)
@@ -153,3 +264,27 @@ func (m *Manager) AllowNetbird() error {
 
 // Flush doesn't need to be implemented for this manager
 func (m *Manager) Flush() error { return nil }
+
+// BeginTx starts a transaction, filtering rules are applied with a single iptables-restore run on Commit
+func (m *Manager) BeginTx() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclMgr.BeginTx()
+}
+
+// Commit applies the filtering rules changed in the transaction at once
+func (m *Manager) Commit() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclMgr.Commit()
+}
+
+// Rollback discards the filtering rules changed in the transaction
+func (m *Manager) Rollback() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclMgr.Rollback()
+}
@@ -6,8 +6,10 @@ type Rule struct {
 	ipsetName string
 
 	specs []string
-	ip    string
-	chain string
+	// logSpecs are the specs of the rule logging the packets before the verdict, nil if the rule isn't logged
+	logSpecs []string
+	ip       string
+	chain    string
 }
 
 // GetRuleID returns the rule id
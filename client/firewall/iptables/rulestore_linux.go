@@ -41,6 +41,19 @@ func (s *ipsetStore) deleteIpset(ipsetName string) {
 	delete(s.ipsets, ipsetName)
 }
 
+// clone returns a deep copy of the store
+func (s *ipsetStore) clone() *ipsetStore {
+	c := newIpsetStore()
+	for name, list := range s.ipsets {
+		ips := make(map[string]struct{}, len(list.ips))
+		for ip := range list.ips {
+			ips[ip] = struct{}{}
+		}
+		c.ipsets[name] = ipList{ips: ips}
+	}
+	return c
+}
+
 func (s *ipsetStore) ipsetNames() []string {
 	names := make([]string, 0, len(s.ipsets))
 	for name := range s.ipsets {
@@ -0,0 +1,114 @@
+package iptables
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// chainNameSrcValidation drops the packets forwarded between the NetBird interface and the other interfaces whose
+// source isn't allowed, the FORWARD chain jumps to it
+const chainNameSrcValidation = "NETBIRD-SRC-VALIDATION"
+
+// srcValidationGuard keeps the source validation chain of the IPv4 filter table and, when ip6tables is available, of
+// the IPv6 one. The rest of the manager only handles IPv4, but the IPv6 addresses would be spoofed otherwise
+type srcValidationGuard struct {
+	clients []*iptables.IPTables
+	wgIface iFaceMapper
+}
+
+func newSrcValidationGuard(ipv4Client *iptables.IPTables, wgIface iFaceMapper) *srcValidationGuard {
+	g := &srcValidationGuard{clients: []*iptables.IPTables{ipv4Client}, wgIface: wgIface}
+
+	ipv6Client, err := iptables.NewWithProtocol(iptables.ProtocolIPv6)
+	if err != nil {
+		log.Debugf("ip6tables is not available, the source of the forwarded IPv6 traffic won't be validated: %v", err)
+	} else {
+		g.clients = append(g.clients, ipv6Client)
+	}
+	return g
+}
+
+// set replaces the sources allowed in the forwarded packets, nil removes the validation
+func (g *srcValidationGuard) set(validation *firewall.SourceValidation) error {
+	if validation == nil {
+		return g.reset()
+	}
+
+	for _, client := range g.clients {
+		if err := g.apply(client, validation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply fills the chain with the allowed sources of the family of the client and makes the FORWARD chain jump to it
+func (g *srcValidationGuard) apply(client *iptables.IPTables, validation *firewall.SourceValidation) error {
+	// the chain is created if it doesn't exist
+	if err := client.ClearChain(tableName, chainNameSrcValidation); err != nil {
+		return fmt.Errorf("failed to flush chain %s: %w", chainNameSrcValidation, err)
+	}
+
+	ipv4 := client.Proto() == iptables.ProtocolIPv4
+	ifaceName := g.wgIface.Name()
+	var rules [][]string
+	for _, network := range networksOfFamily(validation.RoutedNetworks, ipv4) {
+		rules = append(rules, []string{"-o", ifaceName, "-s", network, "-j", "RETURN"})
+	}
+	rules = append(rules, []string{"!", "-i", ifaceName, "-o", ifaceName, "-j", "DROP"})
+	for _, network := range networksOfFamily(validation.PeerSources(), ipv4) {
+		rules = append(rules, []string{"-i", ifaceName, "-s", network, "-j", "RETURN"})
+	}
+	rules = append(rules, []string{"-i", ifaceName, "!", "-o", ifaceName, "-j", "DROP"})
+
+	for _, rule := range rules {
+		if err := client.Append(tableName, chainNameSrcValidation, rule...); err != nil {
+			return fmt.Errorf("failed to add rule to chain %s: %w", chainNameSrcValidation, err)
+		}
+	}
+
+	// the packets are validated before the NetBird rules of the FORWARD chain accept them
+	if err := client.InsertUnique(tableName, "FORWARD", 1, withComment([]string{"-j", chainNameSrcValidation})...); err != nil {
+		return fmt.Errorf("failed to add jump rule to chain %s: %w", chainNameSrcValidation, err)
+	}
+	return nil
+}
+
+// reset removes the jump to the chain and the chain
+func (g *srcValidationGuard) reset() error {
+	for _, client := range g.clients {
+		if err := client.DeleteIfExists(tableName, "FORWARD", withComment([]string{"-j", chainNameSrcValidation})...); err != nil {
+			return fmt.Errorf("failed to delete jump rule to chain %s: %w", chainNameSrcValidation, err)
+		}
+
+		ok, err := client.ChainExists(tableName, chainNameSrcValidation)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if err := client.ClearAndDeleteChain(tableName, chainNameSrcValidation); err != nil {
+			return fmt.Errorf("failed to clear and delete %s chain: %w", chainNameSrcValidation, err)
+		}
+	}
+	return nil
+}
+
+// networksOfFamily returns the networks of the IPv4 or the IPv6 family, the invalid ones are skipped
+func networksOfFamily(networks []string, ipv4 bool) []string {
+	var matched []string
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil || prefix.Addr().Is4() != ipv4 {
+			continue
+		}
+		matched = append(matched, prefix.Masked().String())
+	}
+	return matched
+}
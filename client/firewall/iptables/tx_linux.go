@@ -0,0 +1,291 @@
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/nadoo/ipset"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const iptablesRestoreCmd = "iptables-restore"
+
+// ipsetChange is an ipset operation of a transaction and the operation reverting it
+type ipsetChange struct {
+	apply func() error
+	undo  func() error
+}
+
+// aclTx collects the changes of a transaction to apply the rules with a single iptables-restore run
+type aclTx struct {
+	restorePath string
+	// ipsetPath is the path of the ipset command, the sets are updated entry by entry when it is empty
+	ipsetPath string
+	// ipsetStore is restored when the transaction is rolled back
+	ipsetStore *ipsetStore
+
+	// ipsetChanges create the sets and add the IPs used by the rules, they are applied before the rules
+	ipsetChanges []ipsetChange
+	// ipsetCleanups delete the IPs and sets no longer used, they are applied after the rules
+	ipsetCleanups []func() error
+	// swappedIpsets are the existing sets whose IPs changed, their content is replaced at once on Commit
+	swappedIpsets map[string]struct{}
+
+	// rules holds the iptables-restore commands of each table
+	rules map[string][]string
+	// pending tells if a rule changed in the transaction is inserted (true) or deleted (false)
+	pending map[string]bool
+}
+
+// BeginTx starts a transaction, the rule changes are applied with a single iptables-restore run on Commit
+func (m *aclManager) BeginTx() error {
+	if m.tx != nil {
+		return firewall.ErrTxInProgress
+	}
+
+	restorePath, err := exec.LookPath(iptablesRestoreCmd)
+	if err != nil {
+		return fmt.Errorf("%s is required for transactions: %w", iptablesRestoreCmd, err)
+	}
+
+	// without the ipset command the sets are updated entry by entry
+	ipsetPath, err := exec.LookPath(ipsetCmd)
+	if err != nil {
+		log.Debugf("%s is not installed, ipsets will be updated entry by entry", ipsetCmd)
+	}
+
+	m.tx = &aclTx{
+		restorePath:   restorePath,
+		ipsetPath:     ipsetPath,
+		ipsetStore:    m.ipsetStore.clone(),
+		swappedIpsets: make(map[string]struct{}),
+		rules:         make(map[string][]string),
+		pending:       make(map[string]bool),
+	}
+	return nil
+}
+
+// Commit applies the changes of the transaction. If iptables-restore fails the ipset changes are reverted
+func (m *aclManager) Commit() error {
+	if m.tx == nil {
+		return firewall.ErrNoTx
+	}
+	tx := m.tx
+	m.tx = nil
+
+	tx.ipsetChanges = append(tx.ipsetChanges, m.ipsetSwaps(tx)...)
+	for i, change := range tx.ipsetChanges {
+		if err := change.apply(); err != nil {
+			undoIpsetChanges(tx.ipsetChanges[:i])
+			m.ipsetStore = tx.ipsetStore
+			return fmt.Errorf("apply ipset changes: %w", err)
+		}
+	}
+
+	if err := restoreRules(tx.restorePath, tx.rules); err != nil {
+		undoIpsetChanges(tx.ipsetChanges)
+		m.ipsetStore = tx.ipsetStore
+		return err
+	}
+
+	for _, cleanup := range tx.ipsetCleanups {
+		if err := cleanup(); err != nil {
+			log.Errorf("failed to clean up ipset: %v", err)
+		}
+	}
+	return nil
+}
+
+// Rollback discards the changes of the transaction
+func (m *aclManager) Rollback() error {
+	if m.tx == nil {
+		return firewall.ErrNoTx
+	}
+
+	m.ipsetStore = m.tx.ipsetStore
+	m.tx = nil
+	return nil
+}
+
+func (m *aclManager) insertRule(table, chain string, specs []string) error {
+	ok, err := m.iptablesClient.Exists(table, chain, specs...)
+	if err != nil {
+		return fmt.Errorf("failed to check rule: %w", err)
+	}
+
+	if m.tx == nil {
+		if ok {
+			return fmt.Errorf("rule already exists")
+		}
+		return m.iptablesClient.Insert(table, chain, 1, specs...)
+	}
+
+	key := ruleKey(table, chain, specs)
+	if inserted, changed := m.tx.pending[key]; changed {
+		ok = inserted
+	}
+	if ok {
+		return fmt.Errorf("rule already exists")
+	}
+	m.tx.pending[key] = true
+	m.tx.rules[table] = append(m.tx.rules[table], fmt.Sprintf("-I %s 1 %s", chain, strings.Join(specs, " ")))
+	return nil
+}
+
+func (m *aclManager) deleteRule(table, chain string, specs []string) error {
+	if m.tx == nil {
+		return m.iptablesClient.Delete(table, chain, specs...)
+	}
+
+	m.tx.pending[ruleKey(table, chain, specs)] = false
+	m.tx.rules[table] = append(m.tx.rules[table], fmt.Sprintf("-D %s %s", chain, strings.Join(specs, " ")))
+	return nil
+}
+
+func (m *aclManager) createIpset(name, ip string) error {
+	create := func() error {
+		if err := ipset.Flush(name); err != nil {
+			log.Errorf("flush ipset %s before use it: %s", name, err)
+		}
+		if err := ipset.Create(name); err != nil {
+			return fmt.Errorf("failed to create ipset: %w", err)
+		}
+		if err := ipset.Add(name, ip); err != nil {
+			return fmt.Errorf("failed to add IP to ipset: %w", err)
+		}
+		return nil
+	}
+
+	if m.tx == nil {
+		return create()
+	}
+	m.tx.ipsetChanges = append(m.tx.ipsetChanges, ipsetChange{
+		apply: create,
+		undo: func() error {
+			return ipset.Destroy(name)
+		},
+	})
+	return nil
+}
+
+func (m *aclManager) addIPToIpset(name, ip string) error {
+	if m.tx == nil {
+		return ipset.Add(name, ip)
+	}
+	if m.swapIpsetOnCommit(name) {
+		return nil
+	}
+	m.tx.ipsetChanges = append(m.tx.ipsetChanges, ipsetChange{
+		apply: func() error {
+			return ipset.Add(name, ip)
+		},
+		undo: func() error {
+			return ipset.Del(name, ip)
+		},
+	})
+	return nil
+}
+
+func (m *aclManager) deleteIPFromIpset(name, ip string) error {
+	if m.tx == nil {
+		return ipset.Del(name, ip)
+	}
+	if m.swapIpsetOnCommit(name) {
+		return nil
+	}
+	m.tx.ipsetCleanups = append(m.tx.ipsetCleanups, func() error {
+		return ipset.Del(name, ip)
+	})
+	return nil
+}
+
+func (m *aclManager) destroyIpset(name string) error {
+	if m.tx == nil {
+		return ipset.Destroy(name)
+	}
+	m.tx.ipsetCleanups = append(m.tx.ipsetCleanups, func() error {
+		return ipset.Destroy(name)
+	})
+	return nil
+}
+
+// swapIpsetOnCommit marks the set to be swapped on Commit and returns true if the set existed before the transaction
+// and the ipset command is available, otherwise the change has to be applied entry by entry
+func (m *aclManager) swapIpsetOnCommit(name string) bool {
+	if m.tx.ipsetPath == "" {
+		return false
+	}
+	if _, ok := m.tx.ipsetStore.ipset(name); !ok {
+		// the sets created in the transaction are not referenced by the rules yet
+		return false
+	}
+	m.tx.swappedIpsets[name] = struct{}{}
+	return true
+}
+
+// ipsetSwaps returns the changes replacing the content of the sets changed in the transaction, the sets destroyed
+// in the transaction are skipped
+func (m *aclManager) ipsetSwaps(tx *aclTx) []ipsetChange {
+	var changes []ipsetChange
+	for name := range tx.swappedIpsets {
+		list, ok := m.ipsetStore.ipset(name)
+		if !ok {
+			continue
+		}
+		name := name
+		ips := ipsetIPs(list)
+		previous, _ := tx.ipsetStore.ipset(name)
+		previousIPs := ipsetIPs(previous)
+		changes = append(changes, ipsetChange{
+			apply: func() error {
+				return swapIpset(tx.ipsetPath, name, ips)
+			},
+			undo: func() error {
+				return swapIpset(tx.ipsetPath, name, previousIPs)
+			},
+		})
+	}
+	return changes
+}
+
+func undoIpsetChanges(changes []ipsetChange) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		if err := changes[i].undo(); err != nil {
+			log.Errorf("failed to revert ipset change: %v", err)
+		}
+	}
+}
+
+// restoreRules applies the rule commands of each table with iptables-restore without flushing the existing rules
+func restoreRules(restorePath string, rules map[string][]string) error {
+	var input bytes.Buffer
+	for _, table := range []string{"filter", "mangle"} {
+		if len(rules[table]) == 0 {
+			continue
+		}
+		input.WriteString("*" + table + "\n")
+		for _, rule := range rules[table] {
+			input.WriteString(rule + "\n")
+		}
+		input.WriteString("COMMIT\n")
+	}
+
+	if input.Len() == 0 {
+		return nil
+	}
+
+	cmd := exec.Command(restorePath, "--noflush")
+	cmd.Stdin = &input
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", iptablesRestoreCmd, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func ruleKey(table, chain string, specs []string) string {
+	return table + " " + chain + " " + strings.Join(specs, " ")
+}
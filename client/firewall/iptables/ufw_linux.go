@@ -0,0 +1,278 @@
+package iptables
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-iptables/iptables"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ufwBeforeRules is loaded by ufw before the rules of the user each time it starts or reloads
+	ufwBeforeRules = "/etc/ufw/before.rules"
+	// ufwRequiredLinesEnd ends the chain declarations of the filter table in the ufw before rules
+	ufwRequiredLinesEnd = "# End required lines"
+	ufwSectionBegin     = "# BEGIN NETBIRD, managed by the NetBird client"
+	ufwSectionEnd       = "# END NETBIRD"
+
+	// ufwReconcileInterval is how often the hook chains are checked, ufw flushes them on reload without notice
+	ufwReconcileInterval = 5 * time.Second
+)
+
+// ufwHook makes the ufw chain evaluated before the rules of the user jump to the NetBird hook chain, which holds
+// the NetBird rules of the built-in chain. The hook chain names don't start with the NetBird chain prefix because
+// the ufw before rules reference them and they can't be removed as stale chains
+type ufwHook struct {
+	builtin  string
+	ufwChain string
+	chain    string
+}
+
+var ufwHooks = []ufwHook{
+	{builtin: "INPUT", ufwChain: "ufw-before-input", chain: "netbird-ufw-input"},
+	{builtin: "OUTPUT", ufwChain: "ufw-before-output", chain: "netbird-ufw-output"},
+	{builtin: "FORWARD", ufwChain: "ufw-before-forward", chain: "netbird-ufw-forward"},
+}
+
+// ufwGuard keeps the NetBird rules ahead of the ufw rules. ufw jumps to its chains before the NetBird rules of the
+// built-in chains, so the traffic of the NetBird interface its user rules accept, e.g. ufw allow 22, would skip the
+// ACL rules. The hook chains decide the verdict of this traffic before ufw does. Only the IPv4 rules are hooked,
+// the rest of the manager only handles IPv4
+type ufwGuard struct {
+	client  *iptables.IPTables
+	wgIface iFaceMapper
+}
+
+// IsUFWActive returns true when ufw has loaded its chains into the IPv4 filter table
+func IsUFWActive() bool {
+	client, err := iptables.NewWithProtocol(iptables.ProtocolIPv4)
+	if err != nil {
+		return false
+	}
+	return ufwActive(client)
+}
+
+func ufwActive(client *iptables.IPTables) bool {
+	ok, err := client.ChainExists(tableName, ufwHooks[0].ufwChain)
+	return err == nil && ok
+}
+
+// newUFWGuard returns nil when ufw isn't active. The jumps to the hook chains are added to the ufw before rules,
+// so that ufw adds them again on reload, and the hook chains are filled again by reconcile
+func newUFWGuard(client *iptables.IPTables, wgIface iFaceMapper) *ufwGuard {
+	if !ufwActive(client) {
+		return nil
+	}
+
+	if err := installUFWSection(ufwBeforeRules); err != nil {
+		log.Warnf("failed to add the NetBird section to %s, the NetBird rules are skipped for a few seconds "+
+			"after each ufw reload: %v", ufwBeforeRules, err)
+	}
+	return &ufwGuard{client: client, wgIface: wgIface}
+}
+
+// reconcile fills the hook chains with the NetBird rules of the built-in chains if they differ, e.g. after a ufw
+// reload flushed them, and makes the ufw chains jump to them first
+func (g *ufwGuard) reconcile() error {
+	for _, hook := range ufwHooks {
+		ok, err := g.client.ChainExists(tableName, hook.ufwChain)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if !ok {
+			log.Debugf("chain %s doesn't exist, ufw may have been disabled", hook.ufwChain)
+			continue
+		}
+
+		builtinRules, err := g.client.List(tableName, hook.builtin)
+		if err != nil {
+			return fmt.Errorf("failed to list rules of chain %s: %w", hook.builtin, err)
+		}
+		wanted := mirrorRules(builtinRules, hook, g.wgIface.Name())
+
+		var current []string
+		ok, err = g.client.ChainExists(tableName, hook.chain)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if ok {
+			if current, err = g.client.List(tableName, hook.chain); err != nil {
+				return fmt.Errorf("failed to list rules of chain %s: %w", hook.chain, err)
+			}
+		}
+
+		if !ok || !equalRules(current, wanted) {
+			log.Infof("adding the NetBird rules to chain %s of ufw", hook.chain)
+			if err := g.fill(hook, wanted); err != nil {
+				return err
+			}
+		}
+
+		if err := g.client.InsertUnique(tableName, hook.ufwChain, 1, "-j", hook.chain); err != nil {
+			return fmt.Errorf("failed to add jump rule to chain %s: %w", hook.chain, err)
+		}
+	}
+	return nil
+}
+
+// fill replaces the rules of the hook chain, the chain is created if it doesn't exist
+func (g *ufwGuard) fill(hook ufwHook, rules []string) error {
+	if err := g.client.ClearChain(tableName, hook.chain); err != nil {
+		return fmt.Errorf("failed to flush chain %s: %w", hook.chain, err)
+	}
+	for _, rule := range rules {
+		// the listed rules start with -A and the chain name, the comments may be quoted
+		var specs []string
+		for _, field := range strings.Fields(rule)[2:] {
+			specs = append(specs, strings.Trim(field, `"`))
+		}
+		if err := g.client.Append(tableName, hook.chain, specs...); err != nil {
+			return fmt.Errorf("failed to add rule to chain %s: %w", hook.chain, err)
+		}
+	}
+	return nil
+}
+
+// reset removes the jumps to the hook chains and the hook chains. The section of the ufw before rules is kept, the
+// hook chains ufw creates on reload are empty
+func (g *ufwGuard) reset() error {
+	for _, hook := range ufwHooks {
+		ok, err := g.client.ChainExists(tableName, hook.ufwChain)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if ok {
+			if err := g.client.DeleteIfExists(tableName, hook.ufwChain, "-j", hook.chain); err != nil {
+				return fmt.Errorf("failed to delete jump rule to chain %s: %w", hook.chain, err)
+			}
+		}
+
+		ok, err = g.client.ChainExists(tableName, hook.chain)
+		if err != nil {
+			return fmt.Errorf("failed to list chains: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		if err := g.client.ClearAndDeleteChain(tableName, hook.chain); err != nil {
+			return fmt.Errorf("failed to clear and delete %s chain: %w", hook.chain, err)
+		}
+	}
+	return nil
+}
+
+// mirrorRules returns the rules of the hook chain, as listed by iptables -S: the rules of the built-in chain which
+// match the NetBird interface or which NetBird added, in the same order
+func mirrorRules(builtinRules []string, hook ufwHook, ifaceName string) []string {
+	var rules []string
+	for _, rule := range builtinRules {
+		fields := strings.Fields(rule)
+		if len(fields) < 2 || fields[0] != "-A" {
+			continue
+		}
+		if !matchesInterface(fields, ifaceName) && !isNetbirdRule(rule) {
+			continue
+		}
+		rules = append(rules, strings.Join(append([]string{"-A", hook.chain}, fields[2:]...), " "))
+	}
+	return rules
+}
+
+func matchesInterface(fields []string, ifaceName string) bool {
+	for i := 0; i < len(fields)-1; i++ {
+		if (fields[i] == "-i" || fields[i] == "-o") && fields[i+1] == ifaceName {
+			return true
+		}
+	}
+	return false
+}
+
+// equalRules compares the rules listed for the hook chain, which start with its -N line, with the wanted ones
+func equalRules(current, wanted []string) bool {
+	var rules []string
+	for _, rule := range current {
+		if strings.HasPrefix(rule, "-A ") {
+			rules = append(rules, strings.Join(strings.Fields(rule), " "))
+		}
+	}
+	if len(rules) != len(wanted) {
+		return false
+	}
+	for i := range rules {
+		if rules[i] != wanted[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// installUFWSection adds or updates the NetBird section of the ufw before rules
+func installUFWSection(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	updated, err := withUFWSection(string(content))
+	if err != nil {
+		return err
+	}
+	if updated == string(content) {
+		return nil
+	}
+
+	// the file is replaced at once, ufw fails to start with a partially written file
+	tmp := path + ".netbird"
+	if err := os.WriteFile(tmp, []byte(updated), info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	log.Infof("added the NetBird section to %s", path)
+	return nil
+}
+
+// withUFWSection returns the ufw before rules with the NetBird section right after the chain declarations of the
+// filter table, a previous NetBird section is replaced. The section declares the hook chains, so that the rules
+// load before the NetBird client starts, and makes the ufw chains jump to them before any other rule
+func withUFWSection(content string) (string, error) {
+	var lines []string
+	inSection := false
+	for _, line := range strings.Split(content, "\n") {
+		switch trimmed := strings.TrimSpace(line); {
+		case strings.HasPrefix(trimmed, "# BEGIN NETBIRD"):
+			inSection = true
+		case inSection && trimmed == ufwSectionEnd:
+			inSection = false
+		case !inSection:
+			lines = append(lines, line)
+		}
+	}
+
+	section := []string{ufwSectionBegin}
+	for _, hook := range ufwHooks {
+		section = append(section, fmt.Sprintf(":%s - [0:0]", hook.chain))
+	}
+	for _, hook := range ufwHooks {
+		section = append(section, fmt.Sprintf("-A %s -j %s", hook.ufwChain, hook.chain))
+	}
+	section = append(section, ufwSectionEnd)
+
+	for i, line := range lines {
+		if strings.TrimSpace(line) != ufwRequiredLinesEnd {
+			continue
+		}
+		updated := append(append(append([]string{}, lines[:i+1]...), section...), lines[i+1:]...)
+		return strings.Join(updated, "\n"), nil
+	}
+	return "", fmt.Errorf("the %q line is missing", ufwRequiredLinesEnd)
+}
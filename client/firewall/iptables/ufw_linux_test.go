@@ -0,0 +1,81 @@
+package iptables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const beforeRules = `# Don't delete these required lines, otherwise there will be errors
+*filter
+:ufw-before-input - [0:0]
+:ufw-before-output - [0:0]
+:ufw-before-forward - [0:0]
+:ufw-not-local - [0:0]
+# End required lines
+
+
+# allow all on loopback
+-A ufw-before-input -i lo -j ACCEPT
+COMMIT
+`
+
+const beforeRulesWithSection = `# Don't delete these required lines, otherwise there will be errors
+*filter
+:ufw-before-input - [0:0]
+:ufw-before-output - [0:0]
+:ufw-before-forward - [0:0]
+:ufw-not-local - [0:0]
+# End required lines
+# BEGIN NETBIRD, managed by the NetBird client
+:netbird-ufw-input - [0:0]
+:netbird-ufw-output - [0:0]
+:netbird-ufw-forward - [0:0]
+-A ufw-before-input -j netbird-ufw-input
+-A ufw-before-output -j netbird-ufw-output
+-A ufw-before-forward -j netbird-ufw-forward
+# END NETBIRD
+
+
+# allow all on loopback
+-A ufw-before-input -i lo -j ACCEPT
+COMMIT
+`
+
+func TestWithUFWSection(t *testing.T) {
+	updated, err := withUFWSection(beforeRules)
+	require.NoError(t, err)
+	require.Equal(t, beforeRulesWithSection, updated)
+
+	updated, err = withUFWSection(beforeRulesWithSection)
+	require.NoError(t, err)
+	require.Equal(t, beforeRulesWithSection, updated, "the section should be added once")
+
+	_, err = withUFWSection("*filter\nCOMMIT\n")
+	require.Error(t, err, "the section can't be placed without the required lines")
+}
+
+func TestMirrorRules(t *testing.T) {
+	builtinRules := []string{
+		"-P INPUT DROP",
+		"-A INPUT -j ufw-before-logging-input",
+		"-A INPUT -j ufw-before-input",
+		"-A INPUT -i wt0 -j NETBIRD-ACL-INPUT-LOCAL",
+		"-A INPUT -i eth0 -p tcp -m tcp --dport 22 -j ACCEPT",
+		`-A INPUT -m comment --comment "netbird" -j DROP`,
+		"-A INPUT -i wt0 -j DROP",
+	}
+
+	hook := ufwHooks[0]
+	expected := []string{
+		"-A netbird-ufw-input -i wt0 -j NETBIRD-ACL-INPUT-LOCAL",
+		`-A netbird-ufw-input -m comment --comment "netbird" -j DROP`,
+		"-A netbird-ufw-input -i wt0 -j DROP",
+	}
+	mirrored := mirrorRules(builtinRules, hook, "wt0")
+	require.Equal(t, expected, mirrored)
+
+	current := append([]string{"-N netbird-ufw-input"}, expected...)
+	require.True(t, equalRules(current, mirrored))
+	require.False(t, equalRules([]string{"-N netbird-ufw-input"}, mirrored), "the flushed chain should be filled again")
+}
@@ -1,8 +1,10 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"sort"
 )
 
 const (
@@ -12,6 +14,13 @@ const (
 	InForwardingFormat = "netbird-fwd-in-%s"
 )
 
+var (
+	// ErrTxInProgress is returned by BeginTx when a transaction was already started
+	ErrTxInProgress = errors.New("firewall transaction already in progress")
+	// ErrNoTx is returned by Commit and Rollback when no transaction was started
+	ErrNoTx = errors.New("no firewall transaction in progress")
+)
+
 // Rule abstraction should be implemented by each firewall manager
 //
 // Each firewall type for different OS can use different type
@@ -39,8 +48,79 @@ const (
 	ActionAccept Action = iota
 	// ActionDrop is the action to drop a packet
 	ActionDrop
+	// ActionLogAccept is the action to log and accept a packet
+	ActionLogAccept
+	// ActionLogDrop is the action to log and drop a packet
+	ActionLogDrop
+)
+
+// Drops returns true if the packets matched by the rule are dropped
+func (a Action) Drops() bool {
+	return a == ActionDrop || a == ActionLogDrop
+}
+
+// Logs returns true if the packets matched by the rule are logged
+func (a Action) Logs() bool {
+	return a == ActionLogAccept || a == ActionLogDrop
+}
+
+// LogPrefix returns the prefix of the log entries of the packets matched by the rule
+func (a Action) LogPrefix() string {
+	if a.Drops() {
+		return LogPrefixDrop
+	}
+	return LogPrefixAccept
+}
+
+const (
+	// LogPrefixAccept prefixes the log entries of the packets accepted by a logged rule
+	LogPrefixAccept = "netbird-acl-accept"
+	// LogPrefixDrop prefixes the log entries of the packets dropped by a logged rule
+	LogPrefixDrop = "netbird-acl-drop"
+	// LogGroup is the netlink log group the kernel firewalls send the logged packets to
+	LogGroup = 0
+	// LogRate and LogBurst limit the number of packets logged per second by each logged rule, so a flood of
+	// packets doesn't flood the log
+	LogRate  = 10
+	LogBurst = 20
 )
 
+// PacketLog is a packet matched by a logged rule
+type PacketLog struct {
+	Dropped   bool
+	Direction RuleDirection
+	Protocol  Protocol
+	SrcIP     net.IP
+	SrcPort   uint16
+	DstIP     net.IP
+	DstPort   uint16
+}
+
+func (p PacketLog) String() string {
+	verdict := "accepted"
+	if p.Dropped {
+		verdict = "dropped"
+	}
+	if p.SrcPort == 0 && p.DstPort == 0 {
+		return fmt.Sprintf("%s %s packet %s -> %s", verdict, p.Protocol, p.SrcIP, p.DstIP)
+	}
+	return fmt.Sprintf("%s %s packet %s:%d -> %s:%d", verdict, p.Protocol, p.SrcIP, p.SrcPort, p.DstIP, p.DstPort)
+}
+
+// Capabilities are the features of a firewall manager the rules can be optimized for
+type Capabilities struct {
+	// IPSet is true when the peers of the rules sharing a port and an action are matched with an address set
+	IPSet bool
+	// PortRanges is true when the rules accept a range of ports
+	PortRanges bool
+	// Reject is true when the packets can be rejected instead of dropped
+	Reject bool
+	// IPv6 is true when the IPv6 traffic is filtered
+	IPv6 bool
+	// SCTPAndGRE is true when the rules match the SCTP and GRE protocols
+	SCTPAndGRE bool
+}
+
 // Manager is the high level abstraction of a firewall manager
 //
 // It declares methods which handle actions required by the
@@ -76,13 +156,96 @@ type Manager interface {
 	// RemoveRoutingRules removes a routing firewall rule
 	RemoveRoutingRules(pair RouterPair) error
 
+	// SetDefaultDeny makes the firewall drop the traffic exchanged with the routed networks through the NetBird
+	// interface unless a filtering rule accepts it, by default this traffic is accepted. The traffic accepted by
+	// AllowNetbird isn't affected
+	SetDefaultDeny(enabled bool) error
+
+	// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface: the packets
+	// received on them and the packets sent from them. They take precedence over the ACL rules and the default deny
+	SetAllowedLocalPorts(ports []LocalPort) error
+
+	// SetDNSLeakProtection drops the DNS queries (TCP and UDP ports 53 and 853) sent on any interface to other
+	// destinations than the allowed resolvers, the queries sent on the loopback interface are kept. Nil allowed
+	// resolvers remove the protection
+	SetDNSLeakProtection(allowed []net.IP) error
+
+	// SetSourceValidation drops the packets a routing peer forwards with a source outside the validation, IPv4 and
+	// IPv6, so that the routed networks and the peers can't spoof addresses through the routing peer: the packets
+	// forwarded from the other interfaces to the NetBird interface need a source in the routed networks, the packets
+	// forwarded from the NetBird interface to the other interfaces a source in the peer sources. Nil removes the
+	// validation
+	SetSourceValidation(validation *SourceValidation) error
+
 	// Reset firewall to the default state
 	Reset() error
 
 	// Flush the changes to firewall controller
 	Flush() error
+
+	// BeginTx starts a transaction, filtering rules added or deleted until Commit
+	// are applied to the firewall at once
+	BeginTx() error
+
+	// Commit applies the changes of the transaction. If it fails, the changes
+	// are discarded and the firewall is left in the state before BeginTx
+	Commit() error
+
+	// Rollback discards the changes of the transaction
+	Rollback() error
 }
 
+// SourceValidation are the sources allowed in the traffic forwarded by a routing peer
+type SourceValidation struct {
+	// PeerNetworks are the networks of the NetBird interface, they hold the addresses of the peers. The firewalls
+	// which can't match the interface the forwarded packets leave through match these destinations instead
+	PeerNetworks []string
+	// RoutedNetworks are the networks routed by the peer, the sources of the traffic forwarded to the peers
+	RoutedNetworks []string
+	// AdvertisedNetworks are the networks routed by the other peers by public key. The traffic forwarded from a
+	// peer comes from its address or from the networks it advertises: WireGuard only accepts the packets of a peer
+	// whose source is in its allowed IPs, the validation drops the sources no peer is allowed to use
+	AdvertisedNetworks map[string][]string
+}
+
+// PeerSources returns the sorted sources of the traffic forwarded from the peers: the peer networks and the networks
+// advertised by the peers
+func (v *SourceValidation) PeerSources() []string {
+	seen := make(map[string]struct{})
+	var sources []string
+	add := func(networks []string) {
+		for _, network := range networks {
+			if _, ok := seen[network]; ok {
+				continue
+			}
+			seen[network] = struct{}{}
+			sources = append(sources, network)
+		}
+	}
+	add(v.PeerNetworks)
+	for _, networks := range v.AdvertisedNetworks {
+		add(networks)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Copy returns a deep copy of the validation
+func (v *SourceValidation) Copy() *SourceValidation {
+	c := &SourceValidation{
+		PeerNetworks:       append([]string{}, v.PeerNetworks...),
+		RoutedNetworks:     append([]string{}, v.RoutedNetworks...),
+		AdvertisedNetworks: make(map[string][]string, len(v.AdvertisedNetworks)),
+	}
+	for peer, networks := range v.AdvertisedNetworks {
+		c.AdvertisedNetworks[peer] = append([]string{}, networks...)
+	}
+	return c
+}
+
+// DNSPorts are the ports of the DNS queries filtered by the DNS leak protection: plain DNS and DNS over TLS
+var DNSPorts = []int{53, 853}
+
 func GenKey(format string, input string) string {
 	return fmt.Sprintf(format, input)
 }
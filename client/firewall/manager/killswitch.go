@@ -0,0 +1,31 @@
+package manager
+
+import (
+	"net"
+)
+
+// KillSwitchConfig is the traffic a kill switch lets through besides the traffic of the NetBird interface
+type KillSwitchConfig struct {
+	// Interface is the name of the NetBird interface, all its traffic is accepted
+	Interface string
+	// WgPort is the local UDP port of the tunnel, the encrypted traffic exchanged with the peers goes through it
+	WgPort int
+	// ControlPlane are the addresses of the Management, Signal and STUN/TURN servers, all the traffic sent to them is
+	// accepted so that the client can connect again
+	ControlPlane []net.IP
+}
+
+// KillSwitch drops all the traffic which doesn't go through the NetBird interface, except the control plane, the
+// DNS queries resolving it, DHCP and the IPv6 neighbor discovery. It is independent of the Manager: its rules are
+// kept when the Manager is reset or the client stops, until it is disabled
+type KillSwitch interface {
+	// Enable installs the rules or replaces them with the ones of the config
+	Enable(config KillSwitchConfig) error
+
+	// Disable removes the rules, it succeeds when they were not installed
+	Disable() error
+}
+
+// KillSwitchDNSPort is the port of the DNS queries the kill switch accepts, the client resolves the control plane
+// with them while the tunnel is down
+const KillSwitchDNSPort = 53
@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LocalPort is a port of a service running on the peer, e.g. a monitoring agent, which accepts the traffic of the
+// NetBird interface whatever the ACL rules
+type LocalPort struct {
+	Protocol Protocol
+	Port     int
+}
+
+// ParseLocalPort parses a local port in the protocol/port format, e.g. tcp/9100
+func ParseLocalPort(value string) (LocalPort, error) {
+	protocol, port, found := strings.Cut(value, "/")
+	if !found {
+		return LocalPort{}, fmt.Errorf("invalid local port %q, expected protocol/port, e.g. tcp/9100", value)
+	}
+
+	localPort := LocalPort{Protocol: Protocol(strings.ToLower(protocol))}
+	if localPort.Protocol != ProtocolTCP && localPort.Protocol != ProtocolUDP {
+		return LocalPort{}, fmt.Errorf("invalid protocol of local port %q, expected tcp or udp", value)
+	}
+
+	var err error
+	localPort.Port, err = strconv.Atoi(port)
+	if err != nil || localPort.Port < 1 || localPort.Port > 65535 {
+		return LocalPort{}, fmt.Errorf("invalid port of local port %q, expected a number between 1 and 65535", value)
+	}
+	return localPort, nil
+}
+
+// ParseLocalPorts parses a list of local ports in the protocol/port format
+func ParseLocalPorts(values []string) ([]LocalPort, error) {
+	ports := make([]LocalPort, 0, len(values))
+	for _, value := range values {
+		port, err := ParseLocalPort(value)
+		if err != nil {
+			return nil, err
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// String returns the port in the protocol/port format
+func (p LocalPort) String() string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Port)
+}
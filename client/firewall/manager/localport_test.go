@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocalPort(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected LocalPort
+		wantErr  bool
+	}{
+		{
+			name:     "tcp port",
+			value:    "tcp/9100",
+			expected: LocalPort{Protocol: ProtocolTCP, Port: 9100},
+		},
+		{
+			name:     "upper case protocol",
+			value:    "UDP/161",
+			expected: LocalPort{Protocol: ProtocolUDP, Port: 161},
+		},
+		{
+			name:    "missing protocol",
+			value:   "9100",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported protocol",
+			value:   "icmp/1",
+			wantErr: true,
+		},
+		{
+			name:    "out of range port",
+			value:   "tcp/65536",
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			value:   "",
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			port, err := ParseLocalPort(testCase.value)
+			if testCase.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, port)
+		})
+	}
+}
+
+func TestLocalPortString(t *testing.T) {
+	require.Equal(t, "udp/161", LocalPort{Protocol: ProtocolUDP, Port: 161}.String())
+}
@@ -0,0 +1,27 @@
+package manager
+
+// NftablesOptions configure the table, the chains and the hook priorities used by the nftables firewall manager, so
+// that its rules coexist with the ones of other firewall managers, e.g. firewalld or ufw
+type NftablesOptions struct {
+	// Table is the IPv4 table of the NetBird chains. Without the coexistence mode it is owned by NetBird and created
+	// again on start, netbird when empty. In the coexistence mode it is an existing table, filter when empty
+	Table string
+
+	// InputPriority is the priority of the NetBird chains hooked to the input and the output hooks, the filter
+	// priority (0) when nil. It is ignored in the coexistence mode
+	InputPriority *int
+
+	// ForwardPriority is the priority of the NetBird chain hooked to the forward hook, the filter priority (0) when
+	// nil. It is ignored in the coexistence mode
+	ForwardPriority *int
+
+	// Coexist creates the NetBird chains in the existing table and inserts jump rules to them at the top of its
+	// input, output and forward chains, instead of hooking the NetBird chains to the input, output and forward hooks
+	Coexist bool
+
+	// InputChain, OutputChain and ForwardChain are the existing chains of the table jumping to the NetBird chains in
+	// the coexistence mode, INPUT, OUTPUT and FORWARD when empty
+	InputChain   string
+	OutputChain  string
+	ForwardChain string
+}
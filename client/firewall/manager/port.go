@@ -17,6 +17,12 @@ const (
 	// ProtocolICMP is the ICMP protocol
 	ProtocolICMP Protocol = "icmp"
 
+	// ProtocolSCTP is the SCTP protocol
+	ProtocolSCTP Protocol = "sctp"
+
+	// ProtocolGRE is the GRE protocol, it has no ports
+	ProtocolGRE Protocol = "gre"
+
 	// ProtocolALL cover all supported protocols
 	ProtocolALL Protocol = "all"
 
@@ -35,6 +41,10 @@ type Port struct {
 
 // String interface implementation
 func (p *Port) String() string {
+	if p.IsRange && len(p.Values) == 2 {
+		return strconv.Itoa(p.Values[0]) + "-" + strconv.Itoa(p.Values[1])
+	}
+
 	var ports string
 	for _, port := range p.Values {
 		if ports != "" {
@@ -30,7 +30,18 @@ const (
 	chainNameOutputFilter  = "netbird-acl-output-filter"
 	chainNameForwardFilter = "netbird-acl-forward-filter"
 
+	// routed chains decide the verdict of the traffic exchanged with the routed networks
+	chainNameInputRouted  = "netbird-acl-input-routed"
+	chainNameOutputRouted = "netbird-acl-output-routed"
+
+	// local chains accept the traffic of the allowed local ports before any other rule
+	chainNameInputLocal  = "netbird-acl-input-local"
+	chainNameOutputLocal = "netbird-acl-output-local"
+
 	allowNetbirdInputRuleID = "allow Netbird incoming traffic"
+
+	// logRuleIDSuffix is appended to the rule ID in the user data of the rules logging the packets of a rule
+	logRuleIDSuffix = "-log"
 )
 
 var (
@@ -50,8 +61,35 @@ type AclManager struct {
 	chainFwFilter    *nftables.Chain
 	chainPrerouting  *nftables.Chain
 
+	chainInputRouted  *nftables.Chain
+	chainOutputRouted *nftables.Chain
+
+	chainInputLocal  *nftables.Chain
+	chainOutputLocal *nftables.Chain
+
+	// options set the priorities of the chains hooked by NetBird
+	options firewall.NftablesOptions
+	// hookChains are the existing chains jumping to the filter chains in the coexistence mode, nil otherwise
+	hookChains map[nftables.ChainHook]*nftables.Chain
+
+	// defaultDeny makes the traffic of the routed chains go through the ACL rules
+	defaultDeny bool
+	// localPorts are the local ports accepted by the local chains
+	localPorts []firewall.LocalPort
+
 	ipsetStore *ipsetStore
 	rules      map[string]*Rule
+
+	// tx is the running transaction, nil if no transaction was started
+	tx *aclTx
+}
+
+// aclTx holds the state to restore when a transaction is rolled back
+type aclTx struct {
+	rules      map[string]*Rule
+	ipsetStore *ipsetStore
+	// sets created in the transaction, they are unknown to the kernel until commit
+	sets map[string]*nftables.Set
 }
 
 // iFaceMapper defines subset methods of interface required for manager
@@ -60,7 +98,13 @@ type iFaceMapper interface {
 	Address() iface.WGAddress
 }
 
-func newAclManager(table *nftables.Table, wgIface iFaceMapper, routeingFwChainName string) (*AclManager, error) {
+func newAclManager(
+	table *nftables.Table,
+	wgIface iFaceMapper,
+	routeingFwChainName string,
+	options firewall.NftablesOptions,
+	hookChains map[nftables.ChainHook]*nftables.Chain,
+) (*AclManager, error) {
 	// sConn is used for creating sets and adding/removing elements from them
 	// it's differ then rConn (which does create new conn for each flush operation)
 	// and is permanent. Using same connection for booth type of operations
@@ -76,6 +120,8 @@ func newAclManager(table *nftables.Table, wgIface iFaceMapper, routeingFwChainNa
 		wgIface:             wgIface,
 		workTable:           table,
 		routeingFwChainName: routeingFwChainName,
+		options:             options,
+		hookChains:          hookChains,
 
 		ipsetStore: newIpsetStore(),
 		rules:      make(map[string]*Rule),
@@ -139,29 +185,23 @@ func (m *AclManager) DeleteRule(rule firewall.Rule) error {
 	}
 
 	if r.nftSet == nil {
-		err := m.rConn.DelRule(r.nftRule)
-		if err != nil {
-			log.Errorf("failed to delete rule: %v", err)
-		}
+		m.delRule(r)
 		delete(m.rules, r.GetRuleID())
-		return m.rConn.Flush()
+		return m.flushRules()
 	}
 
 	ips, ok := m.ipsetStore.ips(r.nftSet.Name)
 	if !ok {
-		err := m.rConn.DelRule(r.nftRule)
-		if err != nil {
-			log.Errorf("failed to delete rule: %v", err)
-		}
+		m.delRule(r)
 		delete(m.rules, r.GetRuleID())
-		return m.rConn.Flush()
+		return m.flushRules()
 	}
 	if _, ok := ips[r.ip.String()]; ok {
-		err := m.sConn.SetDeleteElements(r.nftSet, []nftables.SetElement{{Key: r.ip.To4()}})
+		err := m.setConn().SetDeleteElements(r.nftSet, []nftables.SetElement{{Key: r.ip.To4()}})
 		if err != nil {
 			log.Errorf("delete elements for set %q: %v", r.nftSet.Name, err)
 		}
-		if err := m.sConn.Flush(); err != nil {
+		if err := m.flushSets(); err != nil {
 			log.Debugf("flush error of set delete element, %s", r.nftSet.Name)
 			return err
 		}
@@ -174,12 +214,8 @@ func (m *AclManager) DeleteRule(rule firewall.Rule) error {
 		return nil
 	}
 
-	err := m.rConn.DelRule(r.nftRule)
-	if err != nil {
-		log.Errorf("failed to delete rule: %v", err)
-	}
-	err = m.rConn.Flush()
-	if err != nil {
+	m.delRule(r)
+	if err := m.flushRules(); err != nil {
 		return err
 	}
 
@@ -195,9 +231,99 @@ func (m *AclManager) DeleteRule(rule firewall.Rule) error {
 	m.rConn.FlushSet(r.nftSet)
 	m.rConn.DelSet(r.nftSet)
 	m.ipsetStore.deleteIpset(r.nftSet.Name)
+	if m.tx != nil {
+		delete(m.tx.sets, r.nftSet.Name)
+	}
 	return nil
 }
 
+// delRule deletes the nftables rules of a rule
+func (m *AclManager) delRule(r *Rule) {
+	if r.logRule != nil {
+		if err := m.rConn.DelRule(r.logRule); err != nil {
+			log.Errorf("failed to delete log rule: %v", err)
+		}
+	}
+	if err := m.rConn.DelRule(r.nftRule); err != nil {
+		log.Errorf("failed to delete rule: %v", err)
+	}
+}
+
+// BeginTx starts a transaction, rule and set changes are buffered and sent to the kernel in a single batch on Commit
+func (m *AclManager) BeginTx() error {
+	if m.tx != nil {
+		return firewall.ErrTxInProgress
+	}
+
+	rules := make(map[string]*Rule, len(m.rules))
+	for id, rule := range m.rules {
+		rules[id] = rule
+	}
+	m.tx = &aclTx{
+		rules:      rules,
+		ipsetStore: m.ipsetStore.clone(),
+		sets:       make(map[string]*nftables.Set),
+	}
+	return nil
+}
+
+// Commit sends the changes of the transaction to the kernel. The batch is applied atomically, so on failure
+// the state before the transaction is restored
+func (m *AclManager) Commit() error {
+	if m.tx == nil {
+		return firewall.ErrNoTx
+	}
+
+	err := m.Flush()
+	if err != nil {
+		m.restoreTx()
+	}
+	m.tx = nil
+	return err
+}
+
+// Rollback discards the buffered changes of the transaction
+func (m *AclManager) Rollback() error {
+	if m.tx == nil {
+		return firewall.ErrNoTx
+	}
+
+	// the buffered messages are dropped with the connection
+	m.rConn = &nftables.Conn{}
+	m.restoreTx()
+	m.tx = nil
+	return nil
+}
+
+func (m *AclManager) restoreTx() {
+	m.rules = m.tx.rules
+	m.ipsetStore = m.tx.ipsetStore
+}
+
+// setConn returns the connection for set element changes, in a transaction they are batched with the rules
+func (m *AclManager) setConn() *nftables.Conn {
+	if m.tx != nil {
+		return m.rConn
+	}
+	return m.sConn
+}
+
+// flushRules sends the rule changes to the kernel unless they are batched in a transaction
+func (m *AclManager) flushRules() error {
+	if m.tx != nil {
+		return nil
+	}
+	return m.rConn.Flush()
+}
+
+// flushSets sends the set element changes to the kernel unless they are batched in a transaction
+func (m *AclManager) flushSets() error {
+	if m.tx != nil {
+		return nil
+	}
+	return m.sConn.Flush()
+}
+
 // Flush rule/chain/set operations from the buffer
 //
 // Method also get all rules after flush and refreshes handle values in the rulesets
@@ -225,10 +351,11 @@ func (m *AclManager) addIOFiltering(ip net.IP, proto firewall.Protocol, sPort *f
 	ruleId := generateRuleId(ip, sPort, dPort, direction, action, ipset)
 	if r, ok := m.rules[ruleId]; ok {
 		return &Rule{
-			r.nftRule,
-			r.nftSet,
-			r.ruleID,
-			ip,
+			nftRule: r.nftRule,
+			logRule: r.logRule,
+			nftSet:  r.nftSet,
+			ruleID:  r.ruleID,
+			ip:      ip,
 		}, nil
 	}
 
@@ -253,16 +380,9 @@ func (m *AclManager) addIOFiltering(ip net.IP, proto firewall.Protocol, sPort *f
 			Len:          uint32(1),
 		})
 
-		var protoData []byte
-		switch proto {
-		case firewall.ProtocolTCP:
-			protoData = []byte{unix.IPPROTO_TCP}
-		case firewall.ProtocolUDP:
-			protoData = []byte{unix.IPPROTO_UDP}
-		case firewall.ProtocolICMP:
-			protoData = []byte{unix.IPPROTO_ICMP}
-		default:
-			return nil, fmt.Errorf("unsupported protocol: %s", proto)
+		protoData, err := protocolData(proto)
+		if err != nil {
+			return nil, err
 		}
 		expressions = append(expressions, &expr.Cmp{
 			Register: 1,
@@ -310,42 +430,20 @@ func (m *AclManager) addIOFiltering(ip net.IP, proto firewall.Protocol, sPort *f
 	}
 
 	if sPort != nil && len(sPort.Values) != 0 {
-		expressions = append(expressions,
-			&expr.Payload{
-				DestRegister: 1,
-				Base:         expr.PayloadBaseTransportHeader,
-				Offset:       0,
-				Len:          2,
-			},
-			&expr.Cmp{
-				Op:       expr.CmpOpEq,
-				Register: 1,
-				Data:     encodePort(*sPort),
-			},
-		)
+		expressions = append(expressions, portExpressions(0, *sPort)...)
 	}
 
 	if dPort != nil && len(dPort.Values) != 0 {
-		expressions = append(expressions,
-			&expr.Payload{
-				DestRegister: 1,
-				Base:         expr.PayloadBaseTransportHeader,
-				Offset:       2,
-				Len:          2,
-			},
-			&expr.Cmp{
-				Op:       expr.CmpOpEq,
-				Register: 1,
-				Data:     encodePort(*dPort),
-			},
-		)
+		expressions = append(expressions, portExpressions(2, *dPort)...)
 	}
 
-	switch action {
-	case firewall.ActionAccept:
-		expressions = append(expressions, &expr.Verdict{Kind: expr.VerdictAccept})
-	case firewall.ActionDrop:
+	// the match expressions are shared by the verdict and the log rules
+	matchExpressions := expressions[:len(expressions):len(expressions)]
+
+	if action.Drops() {
 		expressions = append(expressions, &expr.Verdict{Kind: expr.VerdictDrop})
+	} else {
+		expressions = append(expressions, &expr.Verdict{Kind: expr.VerdictAccept})
 	}
 
 	userData := []byte(strings.Join([]string{ruleId, comment}, " "))
@@ -364,8 +462,22 @@ func (m *AclManager) addIOFiltering(ip net.IP, proto firewall.Protocol, sPort *f
 		UserData: userData,
 	})
 
+	// the rules are inserted at the top of the chain, the log rule goes before the verdict. The limit can't be in the
+	// rule of the verdict, the packets over the limit wouldn't get the verdict
+	var logRule *nftables.Rule
+	if action.Logs() {
+		logRule = m.rConn.InsertRule(&nftables.Rule{
+			Table:    m.workTable,
+			Chain:    chain,
+			Position: 0,
+			Exprs:    append(matchExpressions, logExpressions(action)...),
+			UserData: []byte(strings.Join([]string{ruleId + logRuleIDSuffix, comment}, " ")),
+		})
+	}
+
 	rule := &Rule{
 		nftRule: nftRule,
+		logRule: logRule,
 		nftSet:  ipset,
 		ruleID:  ruleId,
 		ip:      ip,
@@ -378,25 +490,18 @@ func (m *AclManager) addIOFiltering(ip net.IP, proto firewall.Protocol, sPort *f
 }
 
 func (m *AclManager) addPreroutingFiltering(ipset *nftables.Set, proto firewall.Protocol, port *firewall.Port, ip net.IP) (*Rule, error) {
-	var protoData []byte
-	switch proto {
-	case firewall.ProtocolTCP:
-		protoData = []byte{unix.IPPROTO_TCP}
-	case firewall.ProtocolUDP:
-		protoData = []byte{unix.IPPROTO_UDP}
-	case firewall.ProtocolICMP:
-		protoData = []byte{unix.IPPROTO_ICMP}
-	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", proto)
+	protoData, err := protocolData(proto)
+	if err != nil {
+		return nil, err
 	}
 
 	ruleId := generateRuleIdForMangle(ipset, ip, proto, port)
 	if r, ok := m.rules[ruleId]; ok {
 		return &Rule{
-			r.nftRule,
-			r.nftSet,
-			r.ruleID,
-			ip,
+			nftRule: r.nftRule,
+			nftSet:  r.nftSet,
+			ruleID:  r.ruleID,
+			ip:      ip,
 		}, nil
 	}
 
@@ -450,19 +555,7 @@ func (m *AclManager) addPreroutingFiltering(ipset *nftables.Set, proto firewall.
 	}
 
 	if port != nil {
-		expressions = append(expressions,
-			&expr.Payload{
-				DestRegister: 1,
-				Base:         expr.PayloadBaseTransportHeader,
-				Offset:       2,
-				Len:          2,
-			},
-			&expr.Cmp{
-				Op:       expr.CmpOpEq,
-				Register: 1,
-				Data:     encodePort(*port),
-			},
-		)
+		expressions = append(expressions, portExpressions(2, *port)...)
 	}
 
 	expressions = append(expressions,
@@ -485,7 +578,7 @@ func (m *AclManager) addPreroutingFiltering(ipset *nftables.Set, proto firewall.
 		UserData: []byte(ruleId),
 	})
 
-	if err := m.rConn.Flush(); err != nil {
+	if err := m.flushRules(); err != nil {
 		return nil, fmt.Errorf("flush insert rule: %v", err)
 	}
 
@@ -522,12 +615,33 @@ func (m *AclManager) createDefaultChains() (err error) {
 	}
 	m.chainOutputRules = chain
 
+	// netbird-acl-input-routed and netbird-acl-output-routed
+	m.chainInputRouted = m.createChain(chainNameInputRouted)
+	m.chainOutputRouted = m.createChain(chainNameOutputRouted)
+	m.addDefaultPolicyRules()
+	err = m.rConn.Flush()
+	if err != nil {
+		log.Debugf("failed to create routed chains: %s", err)
+		return err
+	}
+
+	// netbird-acl-input-local and netbird-acl-output-local
+	m.chainInputLocal = m.createChain(chainNameInputLocal)
+	m.chainOutputLocal = m.createChain(chainNameOutputLocal)
+	m.addLocalPortRules()
+	err = m.rConn.Flush()
+	if err != nil {
+		log.Debugf("failed to create local chains: %s", err)
+		return err
+	}
+
 	// netbird-acl-input-filter
 	// type filter hook input priority filter; policy accept;
 	chain = m.createFilterChainWithHook(chainNameInputFilter, nftables.ChainHookInput)
+	m.addInterfaceJumpRule(chain, m.chainInputLocal.Name, expr.MetaKeyIIFNAME) // to netbird-acl-input-local
 	//netbird-acl-input-filter iifname "wt0" ip saddr 100.72.0.0/16 ip daddr != 100.72.0.0/16 accept
-	m.addRouteAllowRule(chain, expr.MetaKeyIIFNAME)
-	m.addFwdAllow(chain, expr.MetaKeyIIFNAME)
+	m.addRouteAllowRule(chain, m.chainInputRouted.Name, expr.MetaKeyIIFNAME)
+	m.addFwdAllow(chain, m.chainInputRouted.Name, expr.MetaKeyIIFNAME)
 	m.addJumpRule(chain, m.chainInputRules.Name, expr.MetaKeyIIFNAME) // to netbird-acl-input-rules
 	m.addDropExpressions(chain, expr.MetaKeyIIFNAME)
 	err = m.rConn.Flush()
@@ -539,8 +653,9 @@ func (m *AclManager) createDefaultChains() (err error) {
 	// netbird-acl-output-filter
 	// type filter hook output priority filter; policy accept;
 	chain = m.createFilterChainWithHook(chainNameOutputFilter, nftables.ChainHookOutput)
-	m.addRouteAllowRule(chain, expr.MetaKeyOIFNAME)
-	m.addFwdAllow(chain, expr.MetaKeyOIFNAME)
+	m.addInterfaceJumpRule(chain, m.chainOutputLocal.Name, expr.MetaKeyOIFNAME) // to netbird-acl-output-local
+	m.addRouteAllowRule(chain, m.chainOutputRouted.Name, expr.MetaKeyOIFNAME)
+	m.addFwdAllow(chain, m.chainOutputRouted.Name, expr.MetaKeyOIFNAME)
 	m.addJumpRule(chain, m.chainOutputRules.Name, expr.MetaKeyOIFNAME) // to netbird-acl-output-rules
 	m.addDropExpressions(chain, expr.MetaKeyOIFNAME)
 	err = m.rConn.Flush()
@@ -646,6 +761,105 @@ func (m *AclManager) addMarkAccept() {
 	}
 }
 
+// SetDefaultDeny switches the routed chains between accepting their traffic and filtering it with the ACL rules
+func (m *AclManager) SetDefaultDeny(enabled bool) error {
+	if m.tx != nil {
+		return firewall.ErrTxInProgress
+	}
+
+	m.defaultDeny = enabled
+	m.rConn.FlushChain(m.chainInputRouted)
+	m.rConn.FlushChain(m.chainOutputRouted)
+	m.addDefaultPolicyRules()
+	if err := m.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to update routed chains: %w", err)
+	}
+	return nil
+}
+
+// addDefaultPolicyRules fills the routed chains: their traffic is accepted or, in default deny mode, it goes through
+// the ACL rules and it is dropped when no rule accepts it
+func (m *AclManager) addDefaultPolicyRules() {
+	for chain, rulesChain := range map[*nftables.Chain]*nftables.Chain{
+		m.chainInputRouted:  m.chainInputRules,
+		m.chainOutputRouted: m.chainOutputRules,
+	} {
+		verdicts := []*expr.Verdict{{Kind: expr.VerdictAccept}}
+		if m.defaultDeny {
+			verdicts = []*expr.Verdict{{Kind: expr.VerdictJump, Chain: rulesChain.Name}, {Kind: expr.VerdictDrop}}
+		}
+		for _, verdict := range verdicts {
+			m.rConn.AddRule(&nftables.Rule{
+				Table: chain.Table,
+				Chain: chain,
+				Exprs: []expr.Any{verdict},
+			})
+		}
+	}
+}
+
+// SetAllowedLocalPorts replaces the local ports accepted by the local chains
+func (m *AclManager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	if m.tx != nil {
+		return firewall.ErrTxInProgress
+	}
+
+	m.localPorts = ports
+	m.rConn.FlushChain(m.chainInputLocal)
+	m.rConn.FlushChain(m.chainOutputLocal)
+	m.addLocalPortRules()
+	if err := m.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to update local chains: %w", err)
+	}
+	return nil
+}
+
+// addLocalPortRules fills the local chains with the rules accepting the packets received on the allowed local ports
+// and the packets sent from them
+func (m *AclManager) addLocalPortRules() {
+	for chain, portOffset := range map[*nftables.Chain]uint32{
+		m.chainInputLocal:  2, // destination port
+		m.chainOutputLocal: 0, // source port
+	} {
+		for _, port := range m.localPorts {
+			protoData := []byte{unix.IPPROTO_TCP}
+			if port.Protocol == firewall.ProtocolUDP {
+				protoData = []byte{unix.IPPROTO_UDP}
+			}
+
+			m.rConn.AddRule(&nftables.Rule{
+				Table: chain.Table,
+				Chain: chain,
+				Exprs: []expr.Any{
+					&expr.Payload{
+						DestRegister: 1,
+						Base:         expr.PayloadBaseNetworkHeader,
+						Offset:       uint32(9),
+						Len:          uint32(1),
+					},
+					&expr.Cmp{
+						Register: 1,
+						Op:       expr.CmpOpEq,
+						Data:     protoData,
+					},
+					&expr.Payload{
+						DestRegister: 1,
+						Base:         expr.PayloadBaseTransportHeader,
+						Offset:       portOffset,
+						Len:          2,
+					},
+					&expr.Cmp{
+						Op:       expr.CmpOpEq,
+						Register: 1,
+						Data:     encodePort(firewall.Port{Values: []int{port.Port}}),
+					},
+					&expr.Verdict{Kind: expr.VerdictAccept},
+				},
+			})
+		}
+	}
+}
+
 func (m *AclManager) createChain(name string) *nftables.Chain {
 	chain := &nftables.Chain{
 		Name:  name,
@@ -656,13 +870,30 @@ func (m *AclManager) createChain(name string) *nftables.Chain {
 	return chain
 }
 
+// createFilterChainWithHook creates the filter chain of the hook. In the coexistence mode it is a regular chain the
+// existing chain of the hook jumps to, otherwise it is hooked with the configured priority
 func (m *AclManager) createFilterChainWithHook(name string, hookNum nftables.ChainHook) *nftables.Chain {
+	if from, ok := m.hookChains[hookNum]; ok {
+		chain := m.createChain(name)
+		m.rConn.InsertRule(jumpRule(from, name))
+		return chain
+	}
+
+	priority := nftables.ChainPriorityFilter
+	configured := m.options.InputPriority
+	if hookNum == nftables.ChainHookForward {
+		configured = m.options.ForwardPriority
+	}
+	if configured != nil {
+		priority = nftables.ChainPriority(*configured)
+	}
+
 	polAccept := nftables.ChainPolicyAccept
 	chain := &nftables.Chain{
 		Name:     name,
 		Table:    m.workTable,
 		Hooknum:  hookNum,
-		Priority: nftables.ChainPriorityFilter,
+		Priority: priority,
 		Type:     nftables.ChainTypeFilter,
 		Policy:   &polAccept,
 	}
@@ -778,7 +1009,9 @@ func (m *AclManager) addJumpRuleToInputChain() {
 	})
 }
 
-func (m *AclManager) addRouteAllowRule(chain *nftables.Chain, netIfName expr.MetaKey) {
+// addRouteAllowRule jumps to the routed chain with the traffic between the NetBird network and the local addresses
+// outside of it
+func (m *AclManager) addRouteAllowRule(chain *nftables.Chain, to string, netIfName expr.MetaKey) {
 	ip, _ := netip.AddrFromSlice(m.wgIface.Address().Network.IP.To4())
 	var srcOp, dstOp expr.CmpOp
 	if netIfName == expr.MetaKeyIIFNAME {
@@ -832,7 +1065,8 @@ func (m *AclManager) addRouteAllowRule(chain *nftables.Chain, netIfName expr.Met
 			Data:     ip.Unmap().AsSlice(),
 		},
 		&expr.Verdict{
-			Kind: expr.VerdictAccept,
+			Kind:  expr.VerdictJump,
+			Chain: to,
 		},
 	}
 	_ = m.rConn.AddRule(&nftables.Rule{
@@ -842,7 +1076,8 @@ func (m *AclManager) addRouteAllowRule(chain *nftables.Chain, netIfName expr.Met
 	})
 }
 
-func (m *AclManager) addFwdAllow(chain *nftables.Chain, iifname expr.MetaKey) {
+// addFwdAllow jumps to the routed chain with the traffic the peer exchanges with the routed networks
+func (m *AclManager) addFwdAllow(chain *nftables.Chain, to string, iifname expr.MetaKey) {
 	ip, _ := netip.AddrFromSlice(m.wgIface.Address().Network.IP.To4())
 	var srcOp, dstOp expr.CmpOp
 	if iifname == expr.MetaKeyIIFNAME {
@@ -896,7 +1131,8 @@ func (m *AclManager) addFwdAllow(chain *nftables.Chain, iifname expr.MetaKey) {
 			Data:     ip.Unmap().AsSlice(),
 		},
 		&expr.Verdict{
-			Kind: expr.VerdictAccept,
+			Kind:  expr.VerdictJump,
+			Chain: to,
 		},
 	}
 	_ = m.rConn.AddRule(&nftables.Rule{
@@ -906,6 +1142,26 @@ func (m *AclManager) addFwdAllow(chain *nftables.Chain, iifname expr.MetaKey) {
 	})
 }
 
+// addInterfaceJumpRule adds a rule jumping to the given chain for all the traffic of the NetBird interface
+func (m *AclManager) addInterfaceJumpRule(chain *nftables.Chain, to string, ifaceKey expr.MetaKey) {
+	_ = m.rConn.AddRule(&nftables.Rule{
+		Table: chain.Table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: ifaceKey, Register: 1},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     ifname(m.wgIface.Name()),
+			},
+			&expr.Verdict{
+				Kind:  expr.VerdictJump,
+				Chain: to,
+			},
+		},
+	})
+}
+
 func (m *AclManager) addJumpRule(chain *nftables.Chain, to string, ifaceKey expr.MetaKey) {
 	ip, _ := netip.AddrFromSlice(m.wgIface.Address().Network.IP.To4())
 	expressions := []expr.Any{
@@ -964,7 +1220,7 @@ func (m *AclManager) addJumpRule(chain *nftables.Chain, to string, ifaceKey expr
 }
 
 func (m *AclManager) addIpToSet(ipsetName string, ip net.IP) (*nftables.Set, error) {
-	ipset, err := m.rConn.GetSetByName(m.workTable, ipsetName)
+	ipset, err := m.getSet(ipsetName)
 	rawIP := ip.To4()
 	if err != nil {
 		if ipset, err = m.createSet(m.workTable, ipsetName); err != nil {
@@ -978,13 +1234,13 @@ func (m *AclManager) addIpToSet(ipsetName string, ip net.IP) (*nftables.Set, err
 		return ipset, nil
 	}
 
-	if err := m.sConn.SetAddElements(ipset, []nftables.SetElement{{Key: rawIP}}); err != nil {
+	if err := m.setConn().SetAddElements(ipset, []nftables.SetElement{{Key: rawIP}}); err != nil {
 		return nil, fmt.Errorf("add set element for the first time: %v", err)
 	}
 
 	m.ipsetStore.AddIpToSet(ipset.Name, ip)
 
-	if err := m.sConn.Flush(); err != nil {
+	if err := m.flushSets(); err != nil {
 		return nil, fmt.Errorf("flush add elements: %v", err)
 	}
 
@@ -1004,6 +1260,11 @@ func (m *AclManager) createSet(table *nftables.Table, name string) (*nftables.Se
 		return nil, fmt.Errorf("create set: %v", err)
 	}
 
+	if m.tx != nil {
+		m.tx.sets[name] = ipset
+		return ipset, nil
+	}
+
 	if err := m.rConn.Flush(); err != nil {
 		return nil, fmt.Errorf("flush created set: %v", err)
 	}
@@ -1011,6 +1272,16 @@ func (m *AclManager) createSet(table *nftables.Table, name string) (*nftables.Se
 	return ipset, nil
 }
 
+// getSet returns the set by name, including the sets created in the running transaction
+func (m *AclManager) getSet(name string) (*nftables.Set, error) {
+	if m.tx != nil {
+		if ipset, ok := m.tx.sets[name]; ok {
+			return ipset, nil
+		}
+	}
+	return m.rConn.GetSetByName(m.workTable, name)
+}
+
 func (m *AclManager) flushWithBackoff() (err error) {
 	backoff := 4
 	backoffTime := 1000 * time.Millisecond
@@ -1048,6 +1319,12 @@ func (m *AclManager) refreshRuleHandles(chain *nftables.Chain) error {
 			continue
 		}
 		split := bytes.Split(rule.UserData, []byte(" "))
+		if ruleID, found := strings.CutSuffix(string(split[0]), logRuleIDSuffix); found {
+			if r, ok := m.rules[ruleID]; ok && r.logRule != nil {
+				*r.logRule = *rule
+			}
+			continue
+		}
 		r, ok := m.rules[string(split[0])]
 		if ok {
 			*r.nftRule = *rule
@@ -1057,6 +1334,23 @@ func (m *AclManager) refreshRuleHandles(chain *nftables.Chain) error {
 	return nil
 }
 
+// logExpressions returns the expressions sending the packets to the netlink log group, rate limited
+func logExpressions(action firewall.Action) []expr.Any {
+	return []expr.Any{
+		&expr.Limit{
+			Type:  expr.LimitTypePkts,
+			Rate:  firewall.LogRate,
+			Unit:  expr.LimitTimeSecond,
+			Burst: firewall.LogBurst,
+		},
+		&expr.Log{
+			Key:   1<<unix.NFTA_LOG_GROUP | 1<<unix.NFTA_LOG_PREFIX,
+			Group: firewall.LogGroup,
+			Data:  []byte(action.LogPrefix()),
+		},
+	}
+}
+
 func generateRuleId(
 	ip net.IP,
 	sPort *firewall.Port,
@@ -1108,9 +1402,63 @@ func shouldAddToPrerouting(proto firewall.Protocol, dPort *firewall.Port, direct
 	return true
 }
 
+// protocolData returns the protocol number matched in the network header for the protocol
+func protocolData(proto firewall.Protocol) ([]byte, error) {
+	switch proto {
+	case firewall.ProtocolTCP:
+		return []byte{unix.IPPROTO_TCP}, nil
+	case firewall.ProtocolUDP:
+		return []byte{unix.IPPROTO_UDP}, nil
+	case firewall.ProtocolICMP:
+		return []byte{unix.IPPROTO_ICMP}, nil
+	case firewall.ProtocolSCTP:
+		return []byte{unix.IPPROTO_SCTP}, nil
+	case firewall.ProtocolGRE:
+		return []byte{unix.IPPROTO_GRE}, nil
+	default:
+		return nil, fmt.Errorf("unsupported protocol: %s", proto)
+	}
+}
+
+// portExpressions matches the port of the transport header at the offset, 0 for the source and 2 for the destination
+// port, with the port or the range of ports
+func portExpressions(offset uint32, port firewall.Port) []expr.Any {
+	payload := &expr.Payload{
+		DestRegister: 1,
+		Base:         expr.PayloadBaseTransportHeader,
+		Offset:       offset,
+		Len:          2,
+	}
+
+	if port.IsRange && len(port.Values) == 2 {
+		return []expr.Any{
+			payload,
+			&expr.Range{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				FromData: encodePortValue(port.Values[0]),
+				ToData:   encodePortValue(port.Values[1]),
+			},
+		}
+	}
+
+	return []expr.Any{
+		payload,
+		&expr.Cmp{
+			Op:       expr.CmpOpEq,
+			Register: 1,
+			Data:     encodePort(port),
+		},
+	}
+}
+
 func encodePort(port firewall.Port) []byte {
+	return encodePortValue(port.Values[0])
+}
+
+func encodePortValue(port int) []byte {
 	bs := make([]byte, 2)
-	binary.BigEndian.PutUint16(bs, uint16(port.Values[0]))
+	binary.BigEndian.PutUint16(bs, uint16(port))
 	return bs
 }
 
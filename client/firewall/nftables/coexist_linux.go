@@ -0,0 +1,143 @@
+package nftables
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const (
+	// coexistTableName is the default table of the NetBird chains in the coexistence mode, the one of iptables-nft
+	// used by ufw
+	coexistTableName = "filter"
+
+	// chainNamePrefix starts the names of all the NetBird chains
+	chainNamePrefix = "netbird-"
+
+	// jumpRuleID is the user data of the rules of the existing chains jumping to the NetBird chains
+	jumpRuleID = "netbird jump"
+)
+
+// withDefaults returns the options with the default table and chains set
+func withDefaults(options firewall.NftablesOptions) firewall.NftablesOptions {
+	if options.Table == "" {
+		options.Table = tableName
+		if options.Coexist {
+			options.Table = coexistTableName
+		}
+	}
+	if options.InputChain == "" {
+		options.InputChain = "INPUT"
+	}
+	if options.OutputChain == "" {
+		options.OutputChain = "OUTPUT"
+	}
+	if options.ForwardChain == "" {
+		options.ForwardChain = "FORWARD"
+	}
+	return options
+}
+
+// hookChains returns the existing chains of the table jumping to the NetBird chains of each hook in the coexistence
+// mode
+func hookChains(conn *nftables.Conn, options firewall.NftablesOptions) (map[nftables.ChainHook]*nftables.Chain, error) {
+	chains, err := conn.ListChainsOfTableFamily(nftables.TableFamilyIPv4)
+	if err != nil {
+		return nil, fmt.Errorf("list of chains: %w", err)
+	}
+
+	names := map[nftables.ChainHook]string{
+		nftables.ChainHookInput:   options.InputChain,
+		nftables.ChainHookOutput:  options.OutputChain,
+		nftables.ChainHookForward: options.ForwardChain,
+	}
+	hooks := make(map[nftables.ChainHook]*nftables.Chain, len(names))
+	for hook, name := range names {
+		for _, c := range chains {
+			if c.Table.Name == options.Table && c.Name == name {
+				hooks[hook] = c
+				break
+			}
+		}
+		if hooks[hook] == nil {
+			return nil, fmt.Errorf("chain %s not found in table %s", name, options.Table)
+		}
+	}
+	return hooks, nil
+}
+
+// removeNetbirdChains queues the deletion of the NetBird chains of the table and of the rules jumping to them, the
+// changes are applied on flush. The other chains of the table are kept
+func removeNetbirdChains(conn *nftables.Conn, table *nftables.Table) error {
+	chains, err := conn.ListChainsOfTableFamily(table.Family)
+	if err != nil {
+		return fmt.Errorf("list of chains: %w", err)
+	}
+
+	var netbirdChains []*nftables.Chain
+	for _, c := range chains {
+		if c.Table.Name != table.Name {
+			continue
+		}
+		if strings.HasPrefix(c.Name, chainNamePrefix) {
+			netbirdChains = append(netbirdChains, c)
+			continue
+		}
+
+		rules, err := conn.GetRules(c.Table, c)
+		if err != nil {
+			log.Errorf("get rules for chain %q: %v", c.Name, err)
+			continue
+		}
+		for _, r := range rules {
+			if !bytes.Equal(r.UserData, []byte(jumpRuleID)) {
+				continue
+			}
+			if err := conn.DelRule(r); err != nil {
+				log.Errorf("delete rule: %v", err)
+			}
+		}
+	}
+
+	// the chains jump to each other, they are emptied before being deleted
+	for _, c := range netbirdChains {
+		conn.FlushChain(c)
+	}
+	for _, c := range netbirdChains {
+		conn.DelChain(c)
+	}
+	return nil
+}
+
+// foreignChains returns the names of the chains of the table which weren't created by NetBird
+func foreignChains(conn *nftables.Conn, table *nftables.Table) ([]string, error) {
+	chains, err := conn.ListChainsOfTableFamily(table.Family)
+	if err != nil {
+		return nil, fmt.Errorf("list of chains: %w", err)
+	}
+
+	var names []string
+	for _, c := range chains {
+		if c.Table.Name == table.Name && !strings.HasPrefix(c.Name, chainNamePrefix) {
+			names = append(names, c.Name)
+		}
+	}
+	return names, nil
+}
+
+// jumpRule returns the rule of the existing chain jumping to the NetBird chain, it is inserted at the top of the
+// existing chain so that the NetBird rules decide the verdict of the traffic of the interface first
+func jumpRule(from *nftables.Chain, to string) *nftables.Rule {
+	return &nftables.Rule{
+		Table:    from.Table,
+		Chain:    from,
+		Exprs:    []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: to}},
+		UserData: []byte(jumpRuleID),
+	}
+}
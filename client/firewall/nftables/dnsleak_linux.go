@@ -0,0 +1,141 @@
+package nftables
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const (
+	// dnsLeakTableName is the name of the table dropping the DNS queries sent to other resolvers than the allowed ones
+	dnsLeakTableName = "netbird-dns-leak"
+	dnsLeakChainName = "output"
+)
+
+// dnsLeakGuard keeps the DNS leak protection table. It is separate from the work table because it is of the inet
+// family: the rest of the manager only handles IPv4, but the IPv6 queries would leak otherwise
+type dnsLeakGuard struct {
+	rConn *nftables.Conn
+}
+
+func newDNSLeakGuard(rConn *nftables.Conn) (*dnsLeakGuard, error) {
+	g := &dnsLeakGuard{rConn: rConn}
+
+	// the table is left behind if the client didn't shut down cleanly
+	if err := g.reset(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// set replaces the resolvers allowed to receive DNS queries, nil removes the protection. The table is replaced in a
+// single batch, so the queries aren't left unfiltered in between
+func (g *dnsLeakGuard) set(allowed []net.IP) error {
+	if allowed == nil {
+		return g.reset()
+	}
+
+	if err := g.delTable(); err != nil {
+		return err
+	}
+
+	table := g.rConn.AddTable(&nftables.Table{Name: dnsLeakTableName, Family: nftables.TableFamilyINet})
+	polAccept := nftables.ChainPolicyAccept
+	chain := g.rConn.AddChain(&nftables.Chain{
+		Name:     dnsLeakChainName,
+		Table:    table,
+		Hooknum:  nftables.ChainHookOutput,
+		Priority: nftables.ChainPriorityFilter,
+		Type:     nftables.ChainTypeFilter,
+		Policy:   &polAccept,
+	})
+
+	g.rConn.AddRule(&nftables.Rule{
+		Table: table,
+		Chain: chain,
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifname("lo")},
+			&expr.Verdict{Kind: expr.VerdictAccept},
+		},
+	})
+
+	for _, ip := range allowed {
+		family, offset, addr := byte(unix.NFPROTO_IPV6), uint32(24), ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			family, offset, addr = byte(unix.NFPROTO_IPV4), 16, ip4
+		}
+		g.rConn.AddRule(&nftables.Rule{
+			Table: table,
+			Chain: chain,
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+				&expr.Payload{
+					DestRegister: 1,
+					Base:         expr.PayloadBaseNetworkHeader,
+					Offset:       offset,
+					Len:          uint32(len(addr)),
+				},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: addr},
+				&expr.Verdict{Kind: expr.VerdictAccept},
+			},
+		})
+	}
+
+	for _, protocol := range []byte{unix.IPPROTO_UDP, unix.IPPROTO_TCP} {
+		for _, port := range firewall.DNSPorts {
+			g.rConn.AddRule(&nftables.Rule{
+				Table: table,
+				Chain: chain,
+				Exprs: []expr.Any{
+					&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{protocol}},
+					&expr.Payload{
+						DestRegister: 1,
+						Base:         expr.PayloadBaseTransportHeader,
+						Offset:       2, // destination port
+						Len:          2,
+					},
+					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: encodePortValue(port)},
+					&expr.Verdict{Kind: expr.VerdictDrop},
+				},
+			})
+		}
+	}
+
+	if err := g.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to update the DNS leak protection table: %w", err)
+	}
+	return nil
+}
+
+// reset removes the table
+func (g *dnsLeakGuard) reset() error {
+	if err := g.delTable(); err != nil {
+		return err
+	}
+	if err := g.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to delete the DNS leak protection table: %w", err)
+	}
+	return nil
+}
+
+// delTable queues the deletion of the table if it exists, the change is applied on flush
+func (g *dnsLeakGuard) delTable() error {
+	tables, err := g.rConn.ListTablesOfFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return fmt.Errorf("list of tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == dnsLeakTableName {
+			g.rConn.DelTable(t)
+		}
+	}
+	return nil
+}
@@ -16,6 +16,22 @@ func newIpsetStore() *ipsetStore {
 	}
 }
 
+// clone returns a deep copy of the store
+func (s *ipsetStore) clone() *ipsetStore {
+	c := newIpsetStore()
+	for name, references := range s.ipsetReference {
+		c.ipsetReference[name] = references
+	}
+	for name, ips := range s.ipsets {
+		ipList := make(map[string]struct{}, len(ips))
+		for ip := range ips {
+			ipList[ip] = struct{}{}
+		}
+		c.ipsets[name] = ipList
+	}
+	return c
+}
+
 func (s *ipsetStore) ips(ipsetName string) (map[string]struct{}, bool) {
 	r, ok := s.ipsets[ipsetName]
 	return r, ok
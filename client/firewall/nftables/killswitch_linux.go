@@ -0,0 +1,190 @@
+package nftables
+
+import (
+	"fmt"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// killSwitchTableName is the name of the kill switch table, the firewall manager doesn't touch it so that it
+// survives the restarts of the client
+const killSwitchTableName = "netbird-kill-switch"
+
+// ndpTypes are the ICMPv6 types of the router and neighbor solicitations and advertisements
+var ndpTypes = []byte{133, 134, 135, 136}
+
+// KillSwitch drops the traffic which doesn't go through the NetBird interface with a table of the inet family, so
+// the IPv6 traffic is dropped too
+type KillSwitch struct {
+	rConn *nftables.Conn
+}
+
+// NewKillSwitch creates an nftables kill switch
+func NewKillSwitch() (*KillSwitch, error) {
+	return &KillSwitch{rConn: &nftables.Conn{}}, nil
+}
+
+// Enable replaces the kill switch table with one filtering the traffic with the config in a single batch, so the
+// traffic isn't left unfiltered in between
+func (k *KillSwitch) Enable(config firewall.KillSwitchConfig) error {
+	if err := k.delTable(); err != nil {
+		return err
+	}
+
+	table := k.rConn.AddTable(&nftables.Table{Name: killSwitchTableName, Family: nftables.TableFamilyINet})
+	input := k.addChain(table, "input", nftables.ChainHookInput)
+	output := k.addChain(table, "output", nftables.ChainHookOutput)
+
+	wgPort := encodePortValue(config.WgPort)
+	dnsPort := encodePortValue(firewall.KillSwitchDNSPort)
+
+	// output: the loopback, the tunnel and its encrypted traffic, the control plane, DNS, DHCP and NDP
+	k.acceptIface(output, expr.MetaKeyOIFNAME, "lo")
+	k.acceptIface(output, expr.MetaKeyOIFNAME, config.Interface)
+	k.acceptPorts(output, unix.IPPROTO_UDP, wgPort, nil)
+	for _, ip := range config.ControlPlane {
+		family, offset, addr := byte(unix.NFPROTO_IPV6), uint32(24), ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			family, offset, addr = byte(unix.NFPROTO_IPV4), 16, ip4
+		}
+		k.accept(output,
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       offset,
+				Len:          uint32(len(addr)),
+			},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: addr},
+		)
+	}
+	k.acceptPorts(output, unix.IPPROTO_UDP, nil, dnsPort)
+	k.acceptPorts(output, unix.IPPROTO_TCP, nil, dnsPort)
+	k.acceptPorts(output, unix.IPPROTO_UDP, encodePortValue(68), encodePortValue(67))
+	k.acceptPorts(output, unix.IPPROTO_UDP, encodePortValue(546), encodePortValue(547))
+	k.acceptNDP(output)
+	k.drop(output)
+
+	// input: the same traffic and the replies to the accepted output traffic
+	k.acceptIface(input, expr.MetaKeyIIFNAME, "lo")
+	k.acceptIface(input, expr.MetaKeyIIFNAME, config.Interface)
+	k.acceptPorts(input, unix.IPPROTO_UDP, nil, wgPort)
+	k.accept(input,
+		&expr.Ct{Key: expr.CtKeySTATE, Register: 1},
+		&expr.Bitwise{
+			SourceRegister: 1,
+			DestRegister:   1,
+			Len:            4,
+			Mask:           binaryutil.NativeEndian.PutUint32(expr.CtStateBitESTABLISHED | expr.CtStateBitRELATED),
+			Xor:            zeroXor,
+		},
+		&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: zeroXor},
+	)
+	k.acceptPorts(input, unix.IPPROTO_UDP, encodePortValue(67), encodePortValue(68))
+	k.acceptPorts(input, unix.IPPROTO_UDP, encodePortValue(547), encodePortValue(546))
+	k.acceptNDP(input)
+	k.drop(input)
+
+	if err := k.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to install the kill switch table: %w", err)
+	}
+	return nil
+}
+
+// Disable removes the kill switch table
+func (k *KillSwitch) Disable() error {
+	if err := k.delTable(); err != nil {
+		return err
+	}
+	if err := k.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to delete the kill switch table: %w", err)
+	}
+	return nil
+}
+
+// delTable queues the deletion of the table if it exists, the change is applied on flush
+func (k *KillSwitch) delTable() error {
+	tables, err := k.rConn.ListTablesOfFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return fmt.Errorf("list of tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == killSwitchTableName {
+			k.rConn.DelTable(t)
+		}
+	}
+	return nil
+}
+
+func (k *KillSwitch) addChain(table *nftables.Table, name string, hook nftables.ChainHook) *nftables.Chain {
+	polAccept := nftables.ChainPolicyAccept
+	return k.rConn.AddChain(&nftables.Chain{
+		Name:     name,
+		Table:    table,
+		Hooknum:  hook,
+		Priority: nftables.ChainPriorityFilter,
+		Type:     nftables.ChainTypeFilter,
+		Policy:   &polAccept,
+	})
+}
+
+func (k *KillSwitch) accept(chain *nftables.Chain, exprs ...expr.Any) {
+	k.rConn.AddRule(&nftables.Rule{
+		Table: chain.Table,
+		Chain: chain,
+		Exprs: append(exprs, &expr.Verdict{Kind: expr.VerdictAccept}),
+	})
+}
+
+func (k *KillSwitch) drop(chain *nftables.Chain) {
+	k.rConn.AddRule(&nftables.Rule{
+		Table: chain.Table,
+		Chain: chain,
+		Exprs: []expr.Any{&expr.Verdict{Kind: expr.VerdictDrop}},
+	})
+}
+
+func (k *KillSwitch) acceptIface(chain *nftables.Chain, key expr.MetaKey, name string) {
+	k.accept(chain,
+		&expr.Meta{Key: key, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifname(name)},
+	)
+}
+
+// acceptPorts accepts the packets of the protocol with the source and the destination ports, a nil port matches
+// any port
+func (k *KillSwitch) acceptPorts(chain *nftables.Chain, protocol byte, sPort, dPort []byte) {
+	exprs := []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{protocol}},
+	}
+	for i, port := range [][]byte{sPort, dPort} {
+		if port == nil {
+			continue
+		}
+		exprs = append(exprs,
+			// the source port is followed by the destination port
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: uint32(i * 2), Len: 2},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: port},
+		)
+	}
+	k.accept(chain, exprs...)
+}
+
+// acceptNDP accepts the IPv6 neighbor discovery, without it the IPv6 addresses of the host aren't reachable
+func (k *KillSwitch) acceptNDP(chain *nftables.Chain) {
+	for _, ndpType := range ndpTypes {
+		k.accept(chain,
+			&expr.Meta{Key: expr.MetaKeyL4PROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.IPPROTO_ICMPV6}},
+			&expr.Payload{DestRegister: 1, Base: expr.PayloadBaseTransportHeader, Offset: 0, Len: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{ndpType}},
+		)
+	}
+}
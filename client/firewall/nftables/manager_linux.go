@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 
 	"github.com/google/nftables"
@@ -15,7 +16,7 @@ import (
 )
 
 const (
-	// tableName is the name of the table that is used for filtering by the Netbird client
+	// tableName is the default name of the table that is used for filtering by the Netbird client
 	tableName = "netbird"
 )
 
@@ -27,26 +28,63 @@ type Manager struct {
 
 	router     *router
 	aclManager *AclManager
+	dnsLeak    *dnsLeakGuard
+	srcValid   *srcValidationGuard
+
+	// options are the table, the chains and the hook priorities of the manager, with the defaults set
+	options   firewall.NftablesOptions
+	workTable *nftables.Table
 }
 
 // Create nftables firewall manager
 func Create(context context.Context, wgIface iFaceMapper) (*Manager, error) {
+	return CreateWithOptions(context, wgIface, firewall.NftablesOptions{})
+}
+
+// CreateWithOptions creates the nftables firewall manager with the given table, chains and hook priorities, e.g. to
+// coexist with the chains of firewalld or ufw
+func CreateWithOptions(context context.Context, wgIface iFaceMapper, options firewall.NftablesOptions) (*Manager, error) {
 	m := &Manager{
 		rConn:   &nftables.Conn{},
 		wgIface: wgIface,
+		options: withDefaults(options),
+	}
+
+	// the allow rule is left behind if the client didn't shut down cleanly, the work table is recreated below
+	if err := m.removeAllowNetbirdRules(); err != nil {
+		log.Errorf("failed to remove stale allow netbird rules: %s", err)
 	}
 
 	workTable, err := m.createWorkTable()
 	if err != nil {
 		return nil, err
 	}
+	m.workTable = workTable
+
+	var hooks map[nftables.ChainHook]*nftables.Chain
+	if m.options.Coexist {
+		if hooks, err = hookChains(m.rConn, m.options); err != nil {
+			return nil, err
+		}
+		log.Infof("nftables: inserting the NetBird chains into the table %s", workTable.Name)
+	}
 
 	m.router, err = newRouter(context, workTable)
 	if err != nil {
 		return nil, err
 	}
 
-	m.aclManager, err = newAclManager(workTable, wgIface, m.router.RouteingFwChainName())
+	m.aclManager, err = newAclManager(workTable, wgIface, m.router.RouteingFwChainName(), m.options, hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	m.dnsLeak, err = newDNSLeakGuard(m.rConn)
+	if err != nil {
+		return nil, err
+	}
+
+	m.srcValid, err = newSrcValidationGuard(m.rConn, wgIface)
 	if err != nil {
 		return nil, err
 	}
@@ -148,42 +186,70 @@ func (m *Manager) AllowNetbird() error {
 	return nil
 }
 
+// SetDefaultDeny makes the firewall drop the traffic exchanged with the routed networks unless an ACL rule accepts it
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclManager.SetDefaultDeny(enabled)
+}
+
+// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface before the ACL rules
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclManager.SetAllowedLocalPorts(ports)
+}
+
+// SetDNSLeakProtection drops the DNS queries sent to other destinations than the allowed resolvers
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.dnsLeak.set(allowed)
+}
+
+// SetSourceValidation drops the packets forwarded between the NetBird interface and the other interfaces whose
+// source isn't allowed
+func (m *Manager) SetSourceValidation(validation *firewall.SourceValidation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.srcValid.set(validation)
+}
+
 // Reset firewall to the default state
 func (m *Manager) Reset() error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	chains, err := m.rConn.ListChains()
-	if err != nil {
-		return fmt.Errorf("list of chains: %w", err)
+	if err := m.removeAllowNetbirdRules(); err != nil {
+		return err
 	}
 
-	for _, c := range chains {
-		// delete Netbird allow input traffic rule if it exists
-		if c.Table.Name == "filter" && c.Name == "INPUT" {
-			rules, err := m.rConn.GetRules(c.Table, c)
-			if err != nil {
-				log.Errorf("get rules for chain %q: %v", c.Name, err)
-				continue
-			}
-			for _, r := range rules {
-				if bytes.Equal(r.UserData, []byte(allowNetbirdInputRuleID)) {
-					if err := m.rConn.DelRule(r); err != nil {
-						log.Errorf("delete rule: %v", err)
-					}
-				}
-			}
-		}
+	if err := m.dnsLeak.delTable(); err != nil {
+		return err
+	}
+	if err := m.srcValid.delTable(); err != nil {
+		return err
 	}
 
 	m.router.ResetForwardRules()
 
+	if m.options.Coexist {
+		if err := removeNetbirdChains(m.rConn, m.workTable); err != nil {
+			return err
+		}
+		return m.rConn.Flush()
+	}
+
 	tables, err := m.rConn.ListTables()
 	if err != nil {
 		return fmt.Errorf("list of tables: %w", err)
 	}
 	for _, t := range tables {
-		if t.Name == tableName {
+		if t.Name == m.options.Table {
 			m.rConn.DelTable(t)
 		}
 	}
@@ -202,19 +268,95 @@ func (m *Manager) Flush() error {
 	return m.aclManager.Flush()
 }
 
+// BeginTx starts a transaction, filtering rules are sent to the kernel in a single batch on Commit
+func (m *Manager) BeginTx() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclManager.BeginTx()
+}
+
+// Commit applies the filtering rules changed in the transaction at once
+func (m *Manager) Commit() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclManager.Commit()
+}
+
+// Rollback discards the filtering rules changed in the transaction
+func (m *Manager) Rollback() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.aclManager.Rollback()
+}
+
+// removeAllowNetbirdRules queues the deletion of the Netbird allow input traffic rules, the changes are applied on flush
+func (m *Manager) removeAllowNetbirdRules() error {
+	chains, err := m.rConn.ListChains()
+	if err != nil {
+		return fmt.Errorf("list of chains: %w", err)
+	}
+
+	for _, c := range chains {
+		if c.Table.Name != "filter" || c.Name != "INPUT" {
+			continue
+		}
+		rules, err := m.rConn.GetRules(c.Table, c)
+		if err != nil {
+			log.Errorf("get rules for chain %q: %v", c.Name, err)
+			continue
+		}
+		for _, r := range rules {
+			if bytes.Equal(r.UserData, []byte(allowNetbirdInputRuleID)) {
+				if err := m.rConn.DelRule(r); err != nil {
+					log.Errorf("delete rule: %v", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// createWorkTable returns the table of the NetBird chains. The table owned by NetBird is created again, in the
+// coexistence mode the NetBird chains left in the existing table are removed
 func (m *Manager) createWorkTable() (*nftables.Table, error) {
 	tables, err := m.rConn.ListTablesOfFamily(nftables.TableFamilyIPv4)
 	if err != nil {
 		return nil, fmt.Errorf("list of tables: %w", err)
 	}
 
+	if m.options.Coexist {
+		for _, t := range tables {
+			if t.Name != m.options.Table {
+				continue
+			}
+			if err := removeNetbirdChains(m.rConn, t); err != nil {
+				return nil, err
+			}
+			return t, m.rConn.Flush()
+		}
+		return nil, fmt.Errorf("table %s not found for the coexistence mode", m.options.Table)
+	}
+
 	for _, t := range tables {
-		if t.Name == tableName {
-			m.rConn.DelTable(t)
+		if t.Name != m.options.Table {
+			continue
+		}
+		// the table is deleted with all its chains, it must not be shared with another firewall manager
+		foreign, err := foreignChains(m.rConn, t)
+		if err != nil {
+			return nil, err
+		}
+		if len(foreign) > 0 {
+			return nil, fmt.Errorf("table %s has chains of another firewall (%s), enable the coexistence mode to share it",
+				t.Name, strings.Join(foreign, ", "))
 		}
+		m.rConn.DelTable(t)
 	}
 
-	table := m.rConn.AddTable(&nftables.Table{Name: tableName, Family: nftables.TableFamilyIPv4})
+	table := m.rConn.AddTable(&nftables.Table{Name: m.options.Table, Family: nftables.TableFamilyIPv4})
 	err = m.rConn.Flush()
 	return table, err
 }
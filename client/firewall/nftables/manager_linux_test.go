@@ -150,6 +150,353 @@ func TestNftablesManager(t *testing.T) {
 	require.NoError(t, err, "failed to reset")
 }
 
+func TestNftablesManagerTransaction(t *testing.T) {
+	mock := &iFaceMock{
+		NameFunc: func() string {
+			return "lo"
+		},
+		AddressFunc: func() iface.WGAddress {
+			return iface.WGAddress{
+				IP: net.ParseIP("100.96.0.1"),
+				Network: &net.IPNet{
+					IP:   net.ParseIP("100.96.0.0"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			}
+		},
+	}
+
+	manager, err := Create(context.Background(), mock)
+	require.NoError(t, err)
+	time.Sleep(time.Second * 3)
+
+	defer func() {
+		err = manager.Reset()
+		require.NoError(t, err, "failed to reset")
+		time.Sleep(time.Second)
+	}()
+
+	testClient := &nftables.Conn{}
+	getRules := func() []*nftables.Rule {
+		rules, err := testClient.GetRules(manager.aclManager.workTable, manager.aclManager.chainInputRules)
+		require.NoError(t, err, "failed to get rules")
+		return rules
+	}
+
+	require.NoError(t, manager.BeginTx())
+
+	// the set is created in the transaction and used by the rules of both IPs
+	for _, ip := range []string{"100.96.0.2", "100.96.0.3"} {
+		_, err = manager.AddFiltering(net.ParseIP(ip), fw.ProtocolTCP, nil, &fw.Port{Values: []int{22}}, fw.RuleDirectionIN, fw.ActionAccept, "nb0000001", "")
+		require.NoError(t, err, "failed to add rule")
+	}
+	require.Len(t, getRules(), 0, "rules should not be applied before commit")
+
+	require.NoError(t, manager.Commit())
+	require.Len(t, getRules(), 1, "expected 1 rule matching the set")
+
+	ips, ok := manager.aclManager.ipsetStore.ips("nb0000001")
+	require.True(t, ok, "set should be stored")
+	require.Len(t, ips, 2)
+
+	storedRules := len(manager.aclManager.rules)
+	require.NoError(t, manager.BeginTx())
+	_, err = manager.AddFiltering(net.ParseIP("100.96.0.4"), fw.ProtocolUDP, nil, &fw.Port{Values: []int{53}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err, "failed to add rule")
+	require.NoError(t, manager.Rollback())
+
+	require.Len(t, getRules(), 1, "rolled back rule should not be applied")
+	require.Len(t, manager.aclManager.rules, storedRules, "rolled back rule should not be stored")
+
+	// the connection must be usable after a rollback
+	_, err = manager.AddFiltering(net.ParseIP("100.96.0.5"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{80}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err, "failed to add rule")
+	require.NoError(t, manager.Flush())
+	require.Len(t, getRules(), 2)
+}
+
+func TestNftablesManagerLoggedRule(t *testing.T) {
+	mock := &iFaceMock{
+		NameFunc: func() string {
+			return "lo"
+		},
+		AddressFunc: func() iface.WGAddress {
+			return iface.WGAddress{
+				IP: net.ParseIP("100.96.0.1"),
+				Network: &net.IPNet{
+					IP:   net.ParseIP("100.96.0.0"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			}
+		},
+	}
+
+	manager, err := Create(context.Background(), mock)
+	require.NoError(t, err)
+	time.Sleep(time.Second * 3)
+
+	defer func() {
+		err = manager.Reset()
+		require.NoError(t, err, "failed to reset")
+		time.Sleep(time.Second)
+	}()
+
+	testClient := &nftables.Conn{}
+	getRules := func() []*nftables.Rule {
+		rules, err := testClient.GetRules(manager.aclManager.workTable, manager.aclManager.chainInputRules)
+		require.NoError(t, err, "failed to get rules")
+		return rules
+	}
+
+	rules, err := manager.AddFiltering(net.ParseIP("100.96.0.2"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{22}}, fw.RuleDirectionIN, fw.ActionLogDrop, "", "")
+	require.NoError(t, err, "failed to add rule")
+	require.NoError(t, manager.Flush())
+
+	chainRules := getRules()
+	require.Len(t, chainRules, 2, "expected the log rule and the verdict rule")
+
+	logExprs := chainRules[0].Exprs
+	require.IsType(t, &expr.Limit{}, logExprs[len(logExprs)-2], "the log rule should be rate limited")
+	logExpr, ok := logExprs[len(logExprs)-1].(*expr.Log)
+	require.True(t, ok, "the first rule should log the packets")
+	require.Equal(t, fw.LogPrefixDrop, string(logExpr.Data))
+	require.Equal(t, &expr.Verdict{Kind: expr.VerdictDrop}, chainRules[1].Exprs[len(chainRules[1].Exprs)-1])
+
+	for _, r := range rules {
+		require.NoError(t, manager.DeleteRule(r), "failed to delete rule")
+	}
+	require.NoError(t, manager.Flush())
+	require.Len(t, getRules(), 0, "the log rule should be deleted with the rule")
+}
+
+func TestNftablesManagerDefaultDeny(t *testing.T) {
+	mock := &iFaceMock{
+		NameFunc: func() string {
+			return "lo"
+		},
+		AddressFunc: func() iface.WGAddress {
+			return iface.WGAddress{
+				IP: net.ParseIP("100.96.0.1"),
+				Network: &net.IPNet{
+					IP:   net.ParseIP("100.96.0.0"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			}
+		},
+	}
+
+	manager, err := Create(context.Background(), mock)
+	require.NoError(t, err)
+	time.Sleep(time.Second * 3)
+
+	defer func() {
+		err = manager.Reset()
+		require.NoError(t, err, "failed to reset")
+		time.Sleep(time.Second)
+	}()
+
+	testClient := &nftables.Conn{}
+	getVerdicts := func(chain *nftables.Chain) []expr.Verdict {
+		rules, err := testClient.GetRules(manager.aclManager.workTable, chain)
+		require.NoError(t, err, "failed to get rules")
+
+		var verdicts []expr.Verdict
+		for _, rule := range rules {
+			require.Len(t, rule.Exprs, 1)
+			verdict, ok := rule.Exprs[0].(*expr.Verdict)
+			require.True(t, ok, "rule should only hold a verdict")
+			verdicts = append(verdicts, *verdict)
+		}
+		return verdicts
+	}
+
+	accept := []expr.Verdict{{Kind: expr.VerdictAccept}}
+	require.Equal(t, accept, getVerdicts(manager.aclManager.chainInputRouted), "routed traffic should be accepted by default")
+	require.Equal(t, accept, getVerdicts(manager.aclManager.chainOutputRouted), "routed traffic should be accepted by default")
+
+	require.NoError(t, manager.SetDefaultDeny(true))
+	require.Equal(t,
+		[]expr.Verdict{{Kind: expr.VerdictJump, Chain: chainNameInputRules}, {Kind: expr.VerdictDrop}},
+		getVerdicts(manager.aclManager.chainInputRouted),
+	)
+	require.Equal(t,
+		[]expr.Verdict{{Kind: expr.VerdictJump, Chain: chainNameOutputRules}, {Kind: expr.VerdictDrop}},
+		getVerdicts(manager.aclManager.chainOutputRouted),
+	)
+
+	require.NoError(t, manager.SetDefaultDeny(false))
+	require.Equal(t, accept, getVerdicts(manager.aclManager.chainInputRouted))
+}
+
+func TestNftablesManagerAllowedLocalPorts(t *testing.T) {
+	mock := &iFaceMock{
+		NameFunc: func() string {
+			return "lo"
+		},
+		AddressFunc: func() iface.WGAddress {
+			return iface.WGAddress{
+				IP: net.ParseIP("100.96.0.1"),
+				Network: &net.IPNet{
+					IP:   net.ParseIP("100.96.0.0"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			}
+		},
+	}
+
+	manager, err := Create(context.Background(), mock)
+	require.NoError(t, err)
+	time.Sleep(time.Second * 3)
+
+	defer func() {
+		err = manager.Reset()
+		require.NoError(t, err, "failed to reset")
+		time.Sleep(time.Second)
+	}()
+
+	testClient := &nftables.Conn{}
+	getRules := func(chain *nftables.Chain) []*nftables.Rule {
+		rules, err := testClient.GetRules(manager.aclManager.workTable, chain)
+		require.NoError(t, err, "failed to get rules")
+		return rules
+	}
+
+	require.Len(t, getRules(manager.aclManager.chainInputLocal), 0, "no local port should be allowed by default")
+
+	ports := []fw.LocalPort{{Protocol: fw.ProtocolTCP, Port: 9100}, {Protocol: fw.ProtocolUDP, Port: 161}}
+	require.NoError(t, manager.SetAllowedLocalPorts(ports))
+
+	rules := getRules(manager.aclManager.chainInputLocal)
+	require.Len(t, rules, 2)
+	require.Len(t, getRules(manager.aclManager.chainOutputLocal), 2)
+	require.Equal(t,
+		[]expr.Any{
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       uint32(9),
+				Len:          uint32(1),
+			},
+			&expr.Cmp{
+				Register: 1,
+				Op:       expr.CmpOpEq,
+				Data:     []byte{unix.IPPROTO_TCP},
+			},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseTransportHeader,
+				Offset:       2,
+				Len:          2,
+			},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     []byte{0x23, 0x8c},
+			},
+			&expr.Verdict{Kind: expr.VerdictAccept},
+		},
+		rules[0].Exprs,
+	)
+
+	// the local chain is the first one the filter chain jumps to
+	filterRules := getRules(&nftables.Chain{Name: chainNameInputFilter, Table: manager.aclManager.workTable})
+	require.NotEmpty(t, filterRules)
+	require.Equal(t, &expr.Verdict{Kind: expr.VerdictJump, Chain: chainNameInputLocal}, filterRules[0].Exprs[len(filterRules[0].Exprs)-1])
+
+	require.NoError(t, manager.SetAllowedLocalPorts(nil))
+	require.Len(t, getRules(manager.aclManager.chainInputLocal), 0)
+	require.Len(t, getRules(manager.aclManager.chainOutputLocal), 0)
+}
+
+func TestNftablesManagerCoexist(t *testing.T) {
+	mock := &iFaceMock{
+		NameFunc: func() string {
+			return "lo"
+		},
+		AddressFunc: func() iface.WGAddress {
+			return iface.WGAddress{
+				IP: net.ParseIP("100.96.0.1"),
+				Network: &net.IPNet{
+					IP:   net.ParseIP("100.96.0.0"),
+					Mask: net.IPv4Mask(255, 255, 255, 0),
+				},
+			}
+		},
+	}
+
+	// the table and the base chains of another firewall manager
+	conn := &nftables.Conn{}
+	table := conn.AddTable(&nftables.Table{Name: "nbtest-host", Family: nftables.TableFamilyIPv4})
+	hostChains := map[string]nftables.ChainHook{
+		"host-input":   nftables.ChainHookInput,
+		"host-output":  nftables.ChainHookOutput,
+		"host-forward": nftables.ChainHookForward,
+	}
+	for name, hook := range hostChains {
+		conn.AddChain(&nftables.Chain{
+			Name:     name,
+			Table:    table,
+			Hooknum:  hook,
+			Priority: nftables.ChainPriorityFilter,
+			Type:     nftables.ChainTypeFilter,
+		})
+	}
+	require.NoError(t, conn.Flush())
+	defer func() {
+		conn.DelTable(table)
+		require.NoError(t, conn.Flush())
+	}()
+
+	manager, err := CreateWithOptions(context.Background(), mock, fw.NftablesOptions{
+		Table:        table.Name,
+		Coexist:      true,
+		InputChain:   "host-input",
+		OutputChain:  "host-output",
+		ForwardChain: "host-forward",
+	})
+	require.NoError(t, err)
+	require.Equal(t, table.Name, manager.aclManager.workTable.Name, "the NetBird chains should be in the host table")
+
+	jumps := map[string]string{
+		"host-input":   chainNameInputFilter,
+		"host-output":  chainNameOutputFilter,
+		"host-forward": chainNameForwardFilter,
+	}
+	for name, target := range jumps {
+		rules, err := conn.GetRules(table, &nftables.Chain{Name: name, Table: table})
+		require.NoError(t, err)
+		require.NotEmpty(t, rules, name)
+		require.Equal(t, []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: target}}, rules[0].Exprs,
+			"%s should jump to %s first", name, target)
+	}
+
+	require.NoError(t, manager.Reset())
+
+	chains, err := conn.ListChainsOfTableFamily(nftables.TableFamilyIPv4)
+	require.NoError(t, err)
+	for _, c := range chains {
+		if c.Table.Name != table.Name {
+			continue
+		}
+		require.Contains(t, hostChains, c.Name, "only the host chains should be left")
+		rules, err := conn.GetRules(table, c)
+		require.NoError(t, err)
+		require.Empty(t, rules, "the jump rules should be removed")
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	options := withDefaults(fw.NftablesOptions{})
+	require.Equal(t, tableName, options.Table)
+	require.Equal(t, "INPUT", options.InputChain)
+	require.Equal(t, "OUTPUT", options.OutputChain)
+	require.Equal(t, "FORWARD", options.ForwardChain)
+
+	require.Equal(t, coexistTableName, withDefaults(fw.NftablesOptions{Coexist: true}).Table,
+		"the coexistence mode should use the filter table of iptables-nft by default")
+	require.Equal(t, "host", withDefaults(fw.NftablesOptions{Table: "host", Coexist: true}).Table)
+}
+
 func TestNFtablesCreatePerformance(t *testing.T) {
 	mock := &iFaceMock{
 		NameFunc: func() string {
@@ -9,6 +9,8 @@ import (
 // Rule to handle management of rules
 type Rule struct {
 	nftRule *nftables.Rule
+	// logRule logs the packets matched by nftRule before its verdict, nil if the rule isn't logged
+	logRule *nftables.Rule
 	nftSet  *nftables.Set
 	ruleID  string
 	ip      net.IP
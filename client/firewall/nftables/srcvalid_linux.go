@@ -0,0 +1,163 @@
+package nftables
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+const (
+	// srcValidationTableName is the name of the table dropping the packets forwarded between the NetBird interface
+	// and the other interfaces whose source isn't allowed
+	srcValidationTableName = "netbird-src-validation"
+	srcValidationChainName = "forward"
+)
+
+// srcValidationGuard keeps the source validation table. It is separate from the work table so that its forward
+// hook sees the packets whatever the other tables accept, and because it is of the inet family: the rest of the
+// manager only handles IPv4, but the IPv6 addresses would be spoofed otherwise
+type srcValidationGuard struct {
+	rConn   *nftables.Conn
+	wgIface iFaceMapper
+}
+
+func newSrcValidationGuard(rConn *nftables.Conn, wgIface iFaceMapper) (*srcValidationGuard, error) {
+	g := &srcValidationGuard{rConn: rConn, wgIface: wgIface}
+
+	// the table is left behind if the client didn't shut down cleanly
+	if err := g.reset(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// set replaces the sources allowed in the forwarded packets, nil removes the validation. The table is replaced in a
+// single batch, so the packets aren't left unvalidated in between
+func (g *srcValidationGuard) set(validation *firewall.SourceValidation) error {
+	if validation == nil {
+		return g.reset()
+	}
+
+	if err := g.delTable(); err != nil {
+		return err
+	}
+
+	table := g.rConn.AddTable(&nftables.Table{Name: srcValidationTableName, Family: nftables.TableFamilyINet})
+	polAccept := nftables.ChainPolicyAccept
+	chain := g.rConn.AddChain(&nftables.Chain{
+		Name:     srcValidationChainName,
+		Table:    table,
+		Hooknum:  nftables.ChainHookForward,
+		Priority: nftables.ChainPriorityFilter,
+		Type:     nftables.ChainTypeFilter,
+		Policy:   &polAccept,
+	})
+
+	ifaceName := g.wgIface.Name()
+	addRule := func(exprs ...expr.Any) {
+		g.rConn.AddRule(&nftables.Rule{Table: table, Chain: chain, Exprs: exprs})
+	}
+	acceptSources := func(key expr.MetaKey, networks []string) {
+		for _, network := range networks {
+			match, ok := sourceMatcherExpressions(network)
+			if !ok {
+				continue
+			}
+			exprs := []expr.Any{
+				&expr.Meta{Key: key, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: ifname(ifaceName)},
+			}
+			exprs = append(exprs, match...)
+			addRule(append(exprs, &expr.Verdict{Kind: expr.VerdictAccept})...)
+		}
+	}
+	dropForwarded := func(inOp, outOp expr.CmpOp) {
+		addRule(
+			&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+			&expr.Cmp{Op: inOp, Register: 1, Data: ifname(ifaceName)},
+			&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+			&expr.Cmp{Op: outOp, Register: 1, Data: ifname(ifaceName)},
+			&expr.Verdict{Kind: expr.VerdictDrop},
+		)
+	}
+
+	// the traffic forwarded to the peers comes from the routed networks
+	acceptSources(expr.MetaKeyOIFNAME, validation.RoutedNetworks)
+	dropForwarded(expr.CmpOpNeq, expr.CmpOpEq)
+
+	// the traffic forwarded from the peers comes from their addresses or from the networks they advertise
+	acceptSources(expr.MetaKeyIIFNAME, validation.PeerSources())
+	dropForwarded(expr.CmpOpEq, expr.CmpOpNeq)
+
+	if err := g.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to update the source validation table: %w", err)
+	}
+	return nil
+}
+
+// reset removes the table
+func (g *srcValidationGuard) reset() error {
+	if err := g.delTable(); err != nil {
+		return err
+	}
+	if err := g.rConn.Flush(); err != nil {
+		return fmt.Errorf("failed to delete the source validation table: %w", err)
+	}
+	return nil
+}
+
+// delTable queues the deletion of the table if it exists, the change is applied on flush
+func (g *srcValidationGuard) delTable() error {
+	tables, err := g.rConn.ListTablesOfFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return fmt.Errorf("list of tables: %w", err)
+	}
+	for _, t := range tables {
+		if t.Name == srcValidationTableName {
+			g.rConn.DelTable(t)
+		}
+	}
+	return nil
+}
+
+// sourceMatcherExpressions returns the expressions matching the IPv4 or IPv6 packets whose source is in the network
+// of an inet table, false if the network is invalid
+func sourceMatcherExpressions(network string) ([]expr.Any, bool) {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return nil, false
+	}
+	prefix = prefix.Masked()
+
+	family, offset := byte(unix.NFPROTO_IPV6), uint32(8)
+	if prefix.Addr().Is4() {
+		family, offset = byte(unix.NFPROTO_IPV4), 12
+	}
+	addr := prefix.Addr().AsSlice()
+	mask := net.CIDRMask(prefix.Bits(), len(addr)*8)
+
+	return []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{family}},
+		&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          uint32(len(addr)),
+		},
+		&expr.Bitwise{
+			DestRegister:   1,
+			SourceRegister: 1,
+			Len:            uint32(len(addr)),
+			Mask:           mask,
+			Xor:            make([]byte, len(addr)),
+		},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: addr},
+	}, true
+}
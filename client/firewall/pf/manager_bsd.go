@@ -0,0 +1,361 @@
+//go:build freebsd || openbsd
+
+package pf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+const (
+	pfctlCmd = "pfctl"
+	// anchorName is the anchor holding the NetBird rules, the main ruleset has to reference it
+	anchorName = "netbird"
+)
+
+// Manager of pf firewall, the rules are kept in the netbird anchor
+type Manager struct {
+	mutex sync.Mutex
+
+	wgIface   iFaceMapper
+	pfctlPath string
+
+	state *ruleset
+	// seq numbers the rules in the order they are added
+	seq uint64
+	// txState is the state before the transaction, nil if no transaction was started
+	txState *ruleset
+}
+
+// iFaceMapper defines subset methods of interface required for manager
+type iFaceMapper interface {
+	Name() string
+	Address() iface.WGAddress
+	IsUserspaceBind() bool
+}
+
+// Create pf firewall manager
+func Create(wgIface iFaceMapper) (*Manager, error) {
+	pfctlPath, err := exec.LookPath(pfctlCmd)
+	if err != nil {
+		return nil, fmt.Errorf("pf is not installed in the system or not supported: %w", err)
+	}
+
+	syntax := syntaxFreeBSD
+	if runtime.GOOS == "openbsd" {
+		syntax = syntaxOpenBSD
+	}
+
+	m := &Manager{
+		wgIface:   wgIface,
+		pfctlPath: pfctlPath,
+		state:     newRuleset(syntax, wgIface.Name()),
+	}
+
+	if err := m.checkAnchor(); err != nil {
+		log.Warnf("failed to check the %s anchor of pf: %v", anchorName, err)
+	}
+
+	if err := m.load(m.state); err != nil {
+		return nil, fmt.Errorf("failed to initialize the %s anchor: %w", anchorName, err)
+	}
+	return m, nil
+}
+
+// AddFiltering rule to the firewall
+//
+// Comment will be ignored because pf doesn't keep the comments of the loaded rules
+func (m *Manager) AddFiltering(
+	ip net.IP,
+	protocol firewall.Protocol,
+	sPort *firewall.Port,
+	dPort *firewall.Port,
+	direction firewall.RuleDirection,
+	action firewall.Action,
+	ipsetName string,
+	comment string,
+) ([]firewall.Rule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("only IPv4 rules are supported, got %s", ip)
+	}
+
+	m.seq++
+	rule := &Rule{
+		ruleID:    uuid.New().String(),
+		seq:       m.seq,
+		ip:        ip.String(),
+		ipsetName: ipsetName,
+		protocol:  protocol,
+		sPort:     sPort,
+		dPort:     dPort,
+		direction: direction,
+		action:    action,
+	}
+
+	err := m.update(func(state *ruleset) {
+		state.rules[rule.ruleID] = rule
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []firewall.Rule{rule}, nil
+}
+
+// DeleteRule from the firewall by rule definition
+func (m *Manager) DeleteRule(rule firewall.Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := rule.(*Rule)
+	if !ok {
+		return fmt.Errorf("invalid rule type")
+	}
+
+	return m.update(func(state *ruleset) {
+		delete(state.rules, r.ruleID)
+	})
+}
+
+func (m *Manager) IsServerRouteSupported() bool {
+	return true
+}
+
+// InsertRoutingRules accepts the traffic of the pair and, if enabled, masquerades it
+func (m *Manager) InsertRoutingRules(pair firewall.RouterPair) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := routeEntry{pair: pair}
+	if pair.Masquerade {
+		outIface, err := egressInterface(pair.Destination)
+		if err != nil {
+			return fmt.Errorf("failed to find the interface of network %s: %w", pair.Destination, err)
+		}
+		entry.outIface = outIface
+	}
+
+	return m.update(func(state *ruleset) {
+		state.routes[pair.ID] = entry
+	})
+}
+
+func (m *Manager) RemoveRoutingRules(pair firewall.RouterPair) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		delete(state.routes, pair.ID)
+	})
+}
+
+// SetDefaultDeny makes the firewall drop the traffic exchanged with the routed networks unless an ACL rule accepts it
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.defaultDeny = enabled
+	})
+}
+
+// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface before the ACL rules
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.localPorts = append([]firewall.LocalPort{}, ports...)
+	})
+}
+
+// SetDNSLeakProtection drops the DNS queries sent to other destinations than the allowed resolvers
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var resolvers []string
+	if allowed != nil {
+		resolvers = make([]string, 0, len(allowed))
+		for _, ip := range allowed {
+			resolvers = append(resolvers, ip.String())
+		}
+	}
+	return m.update(func(state *ruleset) {
+		state.dnsResolvers = resolvers
+	})
+}
+
+// SetSourceValidation drops the packets forwarded to and from the peers whose source isn't allowed
+func (m *Manager) SetSourceValidation(validation *firewall.SourceValidation) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if validation != nil {
+		validation = validation.Copy()
+	}
+	return m.update(func(state *ruleset) {
+		state.srcValidation = validation
+	})
+}
+
+// AllowNetbird allows netbird interface traffic
+func (m *Manager) AllowNetbird() error {
+	if !m.wgIface.IsUserspaceBind() {
+		return nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.update(func(state *ruleset) {
+		state.allowNetbird = true
+	})
+}
+
+// Reset firewall to the default state, the anchor is flushed
+func (m *Manager) Reset() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.txState = nil
+	m.state = newRuleset(m.state.syntax, m.state.iface)
+
+	if out, err := exec.Command(m.pfctlPath, "-a", anchorName, "-F", "all").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to flush the %s anchor: %w: %s", anchorName, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Flush doesn't need to be implemented for this manager
+func (m *Manager) Flush() error { return nil }
+
+// BeginTx starts a transaction, the rules are loaded on Commit
+func (m *Manager) BeginTx() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState != nil {
+		return firewall.ErrTxInProgress
+	}
+	m.txState = m.state.clone()
+	return nil
+}
+
+// Commit loads the rules changed in the transaction at once
+func (m *Manager) Commit() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState == nil {
+		return firewall.ErrNoTx
+	}
+	previous := m.txState
+	m.txState = nil
+
+	if err := m.load(m.state); err != nil {
+		m.state = previous
+		return err
+	}
+	return nil
+}
+
+// Rollback discards the rules changed in the transaction
+func (m *Manager) Rollback() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txState == nil {
+		return firewall.ErrNoTx
+	}
+	m.state = m.txState
+	m.txState = nil
+	return nil
+}
+
+// update applies the change to the rules. During a transaction the rules are loaded on Commit, otherwise the
+// change is discarded if pf rejects it
+func (m *Manager) update(change func(*ruleset)) error {
+	if m.txState != nil {
+		change(m.state)
+		return nil
+	}
+
+	next := m.state.clone()
+	change(next)
+	if err := m.load(next); err != nil {
+		return err
+	}
+	m.state = next
+	return nil
+}
+
+// load replaces the rules of the anchor
+func (m *Manager) load(state *ruleset) error {
+	cmd := exec.Command(m.pfctlPath, "-a", anchorName, "-f", "-")
+	cmd.Stdin = strings.NewReader(state.render())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", pfctlCmd, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// checkAnchor warns when the main ruleset doesn't reference the anchor, its rules are ignored by pf until then
+func (m *Manager) checkAnchor() error {
+	out, err := exec.Command(m.pfctlPath, "-s", "Anchors").Output()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == anchorName {
+			return nil
+		}
+	}
+
+	references := fmt.Sprintf("anchor %q", anchorName)
+	if runtime.GOOS == "freebsd" {
+		references = fmt.Sprintf("nat-anchor %q and anchor %q", anchorName, anchorName)
+	}
+	log.Warnf("the main pf ruleset doesn't reference the %s anchor, add %s to pf.conf to enforce the NetBird rules",
+		anchorName, references)
+	return nil
+}
+
+// egressInterface returns the interface of the route to the network
+func egressInterface(network string) (string, error) {
+	prefix, err := netip.ParsePrefix(network)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := exec.Command("route", "-n", "get", prefix.Addr().String()).Output()
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if found && key == "interface" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("no route to %s", prefix.Addr())
+}
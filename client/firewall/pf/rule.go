@@ -0,0 +1,25 @@
+package pf
+
+import (
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// Rule to handle management of rules
+type Rule struct {
+	ruleID string
+	// seq orders the rules, the latest rules are evaluated first
+	seq uint64
+
+	ip        string
+	ipsetName string
+	protocol  firewall.Protocol
+	sPort     *firewall.Port
+	dPort     *firewall.Port
+	direction firewall.RuleDirection
+	action    firewall.Action
+}
+
+// GetRuleID returns the rule id
+func (r *Rule) GetRuleID() string {
+	return r.ruleID
+}
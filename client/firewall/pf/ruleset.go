@@ -0,0 +1,320 @@
+package pf
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// syntax is the flavour of the pf rules accepted by the OS
+type syntax int
+
+const (
+	// syntaxFreeBSD translates the addresses with nat rules, FreeBSD keeps the syntax of the older pf versions
+	syntaxFreeBSD syntax = iota
+	// syntaxOpenBSD translates the addresses with match rules
+	syntaxOpenBSD
+)
+
+// the tables of the source validation don't collide with the tables of the ACL rules which are named after their
+// ipset
+const (
+	// validSourcesTable holds the sources allowed in the traffic forwarded to the peers
+	validSourcesTable = "nb-src-valid"
+	// peerSourcesTable holds the sources allowed in the traffic forwarded from the peers
+	peerSourcesTable = "nb-peer-src"
+	// peerNetworksTable holds the networks of the NetBird interface
+	peerNetworksTable = "nb-peer-net"
+)
+
+// routeEntry is a routing pair and the interface its traffic to the routed network leaves through
+type routeEntry struct {
+	pair firewall.RouterPair
+	// outIface is the interface the traffic to the routed network is masqueraded on
+	outIface string
+}
+
+// ruleset is the content of the NetBird anchor. pf loads the rules of an anchor at once, so the whole anchor is
+// rendered again on every change
+type ruleset struct {
+	syntax syntax
+	// iface is the name of the NetBird interface
+	iface string
+
+	// allowNetbird accepts all the traffic of the interface, the userspace firewall filters it
+	allowNetbird bool
+	// defaultDeny drops the traffic exchanged with the routed networks unless a filtering rule accepts it
+	defaultDeny bool
+	localPorts  []firewall.LocalPort
+	// dnsResolvers are the resolvers allowed to receive DNS queries, nil when the DNS leak protection is disabled
+	dnsResolvers []string
+	rules        map[string]*Rule
+	routes       map[string]routeEntry
+
+	// srcValidation are the sources allowed in the forwarded traffic, nil when the source validation is disabled
+	srcValidation *firewall.SourceValidation
+}
+
+func newRuleset(syntax syntax, iface string) *ruleset {
+	return &ruleset{
+		syntax: syntax,
+		iface:  iface,
+		rules:  make(map[string]*Rule),
+		routes: make(map[string]routeEntry),
+	}
+}
+
+func (r *ruleset) clone() *ruleset {
+	clone := *r
+	clone.localPorts = append([]firewall.LocalPort{}, r.localPorts...)
+	if r.dnsResolvers != nil {
+		clone.dnsResolvers = append([]string{}, r.dnsResolvers...)
+	}
+	if r.srcValidation != nil {
+		clone.srcValidation = r.srcValidation.Copy()
+	}
+	clone.rules = make(map[string]*Rule, len(r.rules))
+	for id, rule := range r.rules {
+		clone.rules[id] = rule
+	}
+	clone.routes = make(map[string]routeEntry, len(r.routes))
+	for id, entry := range r.routes {
+		clone.routes[id] = entry
+	}
+	return &clone
+}
+
+// render returns the rules of the anchor in the pf.conf format. The tables and the translation rules come first,
+// then the filtering rules, all quick so the first matching rule decides:
+//   - the traffic forwarded to the peers from a source outside the routed networks, and the traffic of the peers
+//     forwarded from a source outside the peer networks and the advertised networks
+//   - the DNS queries sent on any interface but the loopback to other resolvers than the allowed ones
+//   - the allowed local ports
+//   - all the traffic of the interface when the userspace firewall filters it
+//   - the ACL rules, the latest first
+//   - the traffic exchanged with the routed networks
+//   - the rest of the traffic of the interface is dropped
+func (r *ruleset) render() string {
+	var b strings.Builder
+	b.WriteString("# generated by NetBird, do not edit\n")
+
+	rules := r.sortedRules()
+	tables := make(map[string][]string)
+	for _, rule := range rules {
+		if rule.ipsetName == "" {
+			continue
+		}
+		name := tableName(rule)
+		tables[name] = append(tables[name], rule.ip)
+	}
+	for _, name := range sortedKeys(tables) {
+		ips := dedup(tables[name])
+		sort.Strings(ips)
+		fmt.Fprintf(&b, "table <%s> persist { %s }\n", name, strings.Join(ips, " "))
+	}
+	if r.srcValidation != nil {
+		// the local addresses are the sources of the traffic the peer sends to itself through the loopback interface
+		sources := append(append([]string{}, r.srcValidation.RoutedNetworks...), "self")
+		fmt.Fprintf(&b, "table <%s> persist { %s }\n", validSourcesTable, strings.Join(sources, " "))
+		fmt.Fprintf(&b, "table <%s> persist { %s }\n", peerSourcesTable, strings.Join(r.srcValidation.PeerSources(), " "))
+		fmt.Fprintf(&b, "table <%s> persist { %s }\n", peerNetworksTable, strings.Join(r.srcValidation.PeerNetworks, " "))
+	}
+
+	routes := r.sortedRoutes()
+	for _, entry := range routes {
+		if !entry.pair.Masquerade {
+			continue
+		}
+		inPair := firewall.GetInPair(entry.pair)
+		b.WriteString(r.natRule(entry.outIface, entry.pair.Source, entry.pair.Destination))
+		b.WriteString(r.natRule(r.iface, inPair.Source, inPair.Destination))
+	}
+
+	if r.srcValidation != nil {
+		// pf doesn't know the interface the packets are forwarded to when they come in, they are matched by their
+		// destination. The rules don't name a family so that they apply to IPv4 and IPv6
+		fmt.Fprintf(&b, "block drop in quick on ! %s from ! <%s> to <%s>\n", r.iface, validSourcesTable, peerNetworksTable)
+		fmt.Fprintf(&b, "block drop in quick on %s from ! <%s> to ! <%s>\n", r.iface, peerSourcesTable, peerNetworksTable)
+	}
+
+	if r.dnsResolvers != nil {
+		b.WriteString(r.dnsLeakRules())
+	}
+
+	for _, port := range r.localPorts {
+		fmt.Fprintf(&b, "pass in quick on %s inet proto %s from any to any port %d\n", r.iface, port.Protocol, port.Port)
+		fmt.Fprintf(&b, "pass out quick on %s inet proto %s from any port %d to any\n", r.iface, port.Protocol, port.Port)
+	}
+
+	if r.allowNetbird {
+		fmt.Fprintf(&b, "pass quick on %s all\n", r.iface)
+	}
+
+	rendered := make(map[string]struct{}, len(rules))
+	for _, rule := range rules {
+		// the rules sharing a table are rendered once
+		line := r.filterRule(rule)
+		if _, ok := rendered[line]; ok {
+			continue
+		}
+		rendered[line] = struct{}{}
+		b.WriteString(line)
+	}
+
+	verdict := "pass"
+	if r.defaultDeny {
+		verdict = "block drop"
+	}
+	for _, entry := range routes {
+		pair := entry.pair
+		fmt.Fprintf(&b, "%s in quick on %s inet from %s to %s\n", verdict, r.iface, pair.Source, pair.Destination)
+		fmt.Fprintf(&b, "pass out quick on ! %s inet from %s to %s\n", r.iface, pair.Source, pair.Destination)
+		fmt.Fprintf(&b, "pass in quick on ! %s inet from %s to %s\n", r.iface, pair.Destination, pair.Source)
+		fmt.Fprintf(&b, "%s out quick on %s inet from %s to %s\n", verdict, r.iface, pair.Destination, pair.Source)
+	}
+
+	fmt.Fprintf(&b, "block drop quick on %s all\n", r.iface)
+	return b.String()
+}
+
+// dnsLeakRules returns the rules dropping the DNS queries sent to other resolvers than the allowed ones
+func (r *ruleset) dnsLeakRules() string {
+	var values []string
+	for _, port := range firewall.DNSPorts {
+		values = append(values, strconv.Itoa(port))
+	}
+	ports := "{ " + strings.Join(values, " ") + " }"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "pass out quick on lo0 proto { udp tcp } from any to any port %s\n", ports)
+	if len(r.dnsResolvers) > 0 {
+		fmt.Fprintf(&b, "pass out quick proto { udp tcp } from any to { %s } port %s\n", strings.Join(r.dnsResolvers, " "), ports)
+	}
+	fmt.Fprintf(&b, "block drop out quick proto { udp tcp } from any to any port %s\n", ports)
+	return b.String()
+}
+
+// natRule returns the rule masquerading the traffic from the source to the destination with the address of the
+// interface it leaves through
+func (r *ruleset) natRule(iface, source, destination string) string {
+	if r.syntax == syntaxOpenBSD {
+		return fmt.Sprintf("match out on %s inet from %s to %s nat-to (%s)\n", iface, source, destination, iface)
+	}
+	return fmt.Sprintf("nat on %s inet from %s to %s -> (%s)\n", iface, source, destination, iface)
+}
+
+// filterRule returns the pf rule of an ACL rule, the peers sharing a table are matched with it
+func (r *ruleset) filterRule(rule *Rule) string {
+	parts := []string{"pass"}
+	if rule.action.Drops() {
+		parts = []string{"block", "drop"}
+	}
+
+	if rule.direction == firewall.RuleDirectionOUT {
+		parts = append(parts, "out")
+	} else {
+		parts = append(parts, "in")
+	}
+	if rule.action.Logs() {
+		parts = append(parts, "log")
+	}
+	parts = append(parts, "quick", "on", r.iface, "inet")
+	if rule.protocol != firewall.ProtocolALL {
+		parts = append(parts, "proto", string(rule.protocol))
+	}
+
+	peer := "any"
+	switch {
+	case rule.ipsetName != "":
+		peer = "<" + tableName(rule) + ">"
+	case rule.ip != "0.0.0.0":
+		peer = rule.ip
+	}
+	source, destination := peer, "any"
+	if rule.direction == firewall.RuleDirectionOUT {
+		source, destination = "any", peer
+	}
+
+	parts = append(parts, "from", source)
+	parts = append(parts, portSpec(rule.sPort)...)
+	parts = append(parts, "to", destination)
+	parts = append(parts, portSpec(rule.dPort)...)
+	return strings.Join(parts, " ") + "\n"
+}
+
+// sortedRules returns the ACL rules, the latest first
+func (r *ruleset) sortedRules() []*Rule {
+	rules := make([]*Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].seq > rules[j].seq
+	})
+	return rules
+}
+
+func (r *ruleset) sortedRoutes() []routeEntry {
+	routes := make([]routeEntry, 0, len(r.routes))
+	for _, id := range sortedKeys(r.routes) {
+		routes = append(routes, r.routes[id])
+	}
+	return routes
+}
+
+// portSpec returns the port option of a rule, a range is written as start:end
+func portSpec(port *firewall.Port) []string {
+	if port == nil || len(port.Values) == 0 {
+		return nil
+	}
+	if port.IsRange && len(port.Values) == 2 {
+		return []string{"port", fmt.Sprintf("%d:%d", port.Values[0], port.Values[1])}
+	}
+	if len(port.Values) == 1 {
+		return []string{"port", strconv.Itoa(port.Values[0])}
+	}
+
+	values := make([]string, 0, len(port.Values))
+	for _, value := range port.Values {
+		values = append(values, strconv.Itoa(value))
+	}
+	return []string{"port", "{", strings.Join(values, " "), "}"}
+}
+
+// tableName returns the name of the table of the peers of the rule, the rules sharing the peers but not the ports
+// get different tables
+func tableName(rule *Rule) string {
+	name := rule.ipsetName
+	if portSpec(rule.sPort) != nil {
+		name += "-sport"
+	}
+	if portSpec(rule.dPort) != nil {
+		name += "-dport"
+	}
+	return name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func dedup(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	unique := values[:0:0]
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	return unique
+}
@@ -0,0 +1,119 @@
+package pf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+func TestRuleset_Render(t *testing.T) {
+	state := newRuleset(syntaxFreeBSD, "wt0")
+	state.localPorts = []firewall.LocalPort{{Protocol: firewall.ProtocolTCP, Port: 9100}}
+	state.rules["ssh-a"] = &Rule{
+		ruleID: "ssh-a", seq: 1, ip: "100.64.0.2", ipsetName: "nb0000001", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{22}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.rules["ssh-b"] = &Rule{
+		ruleID: "ssh-b", seq: 2, ip: "100.64.0.3", ipsetName: "nb0000001", protocol: firewall.ProtocolTCP,
+		dPort: &firewall.Port{Values: []int{22}}, direction: firewall.RuleDirectionIN, action: firewall.ActionAccept,
+	}
+	state.rules["drop"] = &Rule{
+		ruleID: "drop", seq: 3, ip: "100.64.0.4", protocol: firewall.ProtocolUDP,
+		sPort: &firewall.Port{IsRange: true, Values: []int{1000, 2000}}, direction: firewall.RuleDirectionOUT,
+		action: firewall.ActionLogDrop,
+	}
+	state.routes["route"] = routeEntry{
+		pair:     firewall.RouterPair{ID: "route", Source: "100.64.0.0/16", Destination: "192.168.1.0/24", Masquerade: true},
+		outIface: "em0",
+	}
+
+	expected := `# generated by NetBird, do not edit
+table <nb0000001-dport> persist { 100.64.0.2 100.64.0.3 }
+nat on em0 inet from 100.64.0.0/16 to 192.168.1.0/24 -> (em0)
+nat on wt0 inet from 192.168.1.0/24 to 100.64.0.0/16 -> (wt0)
+pass in quick on wt0 inet proto tcp from any to any port 9100
+pass out quick on wt0 inet proto tcp from any port 9100 to any
+block drop out log quick on wt0 inet proto udp from any port 1000:2000 to 100.64.0.4
+pass in quick on wt0 inet proto tcp from <nb0000001-dport> to any port 22
+pass in quick on wt0 inet from 100.64.0.0/16 to 192.168.1.0/24
+pass out quick on ! wt0 inet from 100.64.0.0/16 to 192.168.1.0/24
+pass in quick on ! wt0 inet from 192.168.1.0/24 to 100.64.0.0/16
+pass out quick on wt0 inet from 192.168.1.0/24 to 100.64.0.0/16
+block drop quick on wt0 all
+`
+	assert.Equal(t, expected, state.render())
+}
+
+func TestRuleset_RenderOpenBSD(t *testing.T) {
+	state := newRuleset(syntaxOpenBSD, "wt0")
+	state.allowNetbird = true
+	state.defaultDeny = true
+	state.routes["route"] = routeEntry{
+		pair:     firewall.RouterPair{ID: "route", Source: "100.64.0.0/16", Destination: "10.0.0.0/8", Masquerade: true},
+		outIface: "vio0",
+	}
+
+	expected := `# generated by NetBird, do not edit
+match out on vio0 inet from 100.64.0.0/16 to 10.0.0.0/8 nat-to (vio0)
+match out on wt0 inet from 10.0.0.0/8 to 100.64.0.0/16 nat-to (wt0)
+pass quick on wt0 all
+block drop in quick on wt0 inet from 100.64.0.0/16 to 10.0.0.0/8
+pass out quick on ! wt0 inet from 100.64.0.0/16 to 10.0.0.0/8
+pass in quick on ! wt0 inet from 10.0.0.0/8 to 100.64.0.0/16
+block drop out quick on wt0 inet from 10.0.0.0/8 to 100.64.0.0/16
+block drop quick on wt0 all
+`
+	assert.Equal(t, expected, state.render())
+}
+
+func TestRuleset_RenderDNSLeakProtection(t *testing.T) {
+	state := newRuleset(syntaxFreeBSD, "wt0")
+	state.dnsResolvers = []string{"100.64.0.1", "fd00::1"}
+
+	expected := `# generated by NetBird, do not edit
+pass out quick on lo0 proto { udp tcp } from any to any port { 53 853 }
+pass out quick proto { udp tcp } from any to { 100.64.0.1 fd00::1 } port { 53 853 }
+block drop out quick proto { udp tcp } from any to any port { 53 853 }
+block drop quick on wt0 all
+`
+	assert.Equal(t, expected, state.render())
+
+	state.dnsResolvers = []string{}
+	assert.NotContains(t, state.render(), "pass out quick proto", "no resolver should be allowed")
+}
+
+func TestRuleset_RenderSourceValidation(t *testing.T) {
+	state := newRuleset(syntaxFreeBSD, "wt0")
+	state.srcValidation = &firewall.SourceValidation{
+		PeerNetworks:       []string{"100.64.0.0/16", "fd00:1234::/64"},
+		RoutedNetworks:     []string{"192.168.1.0/24", "fd00:5678::/64"},
+		AdvertisedNetworks: map[string][]string{"peerA": {"10.0.0.0/24"}, "peerB": {"10.0.0.0/24", "10.1.0.0/24"}},
+	}
+
+	expected := `# generated by NetBird, do not edit
+table <nb-src-valid> persist { 192.168.1.0/24 fd00:5678::/64 self }
+table <nb-peer-src> persist { 10.0.0.0/24 10.1.0.0/24 100.64.0.0/16 fd00:1234::/64 }
+table <nb-peer-net> persist { 100.64.0.0/16 fd00:1234::/64 }
+block drop in quick on ! wt0 from ! <nb-src-valid> to <nb-peer-net>
+block drop in quick on wt0 from ! <nb-peer-src> to ! <nb-peer-net>
+block drop quick on wt0 all
+`
+	assert.Equal(t, expected, state.render())
+
+	state.srcValidation = nil
+	assert.NotContains(t, state.render(), "nb-src-valid", "the validation should be removed")
+}
+
+func TestRuleset_Clone(t *testing.T) {
+	state := newRuleset(syntaxFreeBSD, "wt0")
+	state.rules["rule"] = &Rule{ruleID: "rule"}
+
+	clone := state.clone()
+	delete(clone.rules, "rule")
+	clone.routes["route"] = routeEntry{}
+
+	assert.Len(t, state.rules, 1, "changing the clone should not change the original")
+	assert.Empty(t, state.routes)
+}
@@ -0,0 +1,18 @@
+package shaper
+
+import (
+	"net/netip"
+)
+
+// Limit is a bandwidth limit applied to the traffic sent to a set of prefixes
+type Limit struct {
+	Prefixes []netip.Prefix
+	// Rate is the maximum rate in kbit/s
+	Rate uint64
+}
+
+// Shaper limits the bandwidth of the traffic sent to remote peers
+type Shaper interface {
+	// SetBandwidthLimits replaces the configured limits. An empty list removes all the limits
+	SetBandwidthLimits(limits []Limit) error
+}
@@ -0,0 +1,13 @@
+//go:build !linux || android
+
+package shaper
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// New returns an error as traffic shaping of the interface is not supported on this OS
+func New(ifaceName string) (Shaper, error) {
+	return nil, fmt.Errorf("traffic shaping of interface %s is not supported on %s", ifaceName, runtime.GOOS)
+}
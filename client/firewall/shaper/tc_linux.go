@@ -0,0 +1,163 @@
+//go:build !android
+
+package shaper
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// tcMajor is the major number of the HTB qdisc and of its classes
+	tcMajor = 1
+
+	ipv4DstOffset = 16
+	ipv6DstOffset = 24
+)
+
+// tcShaper shapes the egress traffic of the interface with an HTB qdisc. Every limit gets an HTB class and
+// u32 filters matching the destination prefixes. Unclassified traffic is not shaped
+type tcShaper struct {
+	ifaceName string
+}
+
+// New creates a Shaper for the interface based on the Linux traffic control
+func New(ifaceName string) (Shaper, error) {
+	return &tcShaper{ifaceName: ifaceName}, nil
+}
+
+// SetBandwidthLimits replaces the qdisc of the interface with a new one holding the limits
+func (s *tcShaper) SetBandwidthLimits(limits []Limit) error {
+	link, err := netlink.LinkByName(s.ifaceName)
+	if err != nil {
+		return fmt.Errorf("get link %s: %w", s.ifaceName, err)
+	}
+
+	// deleting the qdisc removes its classes and filters as well
+	if err := s.deleteQdisc(link); err != nil {
+		return err
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	linkIndex := link.Attrs().Index
+	qdiscHandle := netlink.MakeHandle(tcMajor, 0)
+	qdisc := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: linkIndex,
+		Handle:    qdiscHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	if err := netlink.QdiscReplace(qdisc); err != nil {
+		return fmt.Errorf("add htb qdisc to %s: %w", s.ifaceName, err)
+	}
+
+	for i, limit := range limits {
+		classID := netlink.MakeHandle(tcMajor, uint16(i+1))
+		// the class expects bits per second
+		rate := limit.Rate * 1000
+		class := netlink.NewHtbClass(
+			netlink.ClassAttrs{LinkIndex: linkIndex, Parent: qdiscHandle, Handle: classID},
+			netlink.HtbClassAttrs{Rate: rate, Ceil: rate},
+		)
+		if err := netlink.ClassAdd(class); err != nil {
+			return fmt.Errorf("add htb class %d: %w", i+1, err)
+		}
+
+		for _, prefix := range limit.Prefixes {
+			if err := netlink.FilterAdd(u32Filter(linkIndex, qdiscHandle, classID, prefix)); err != nil {
+				return fmt.Errorf("add filter for %s: %w", prefix, err)
+			}
+		}
+	}
+
+	log.Debugf("applied %d bandwidth limits to interface %s", len(limits), s.ifaceName)
+	return nil
+}
+
+// deleteQdisc deletes the qdisc added by the shaper, if any
+func (s *tcShaper) deleteQdisc(link netlink.Link) error {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("list qdiscs of %s: %w", s.ifaceName, err)
+	}
+	for _, qdisc := range qdiscs {
+		attrs := qdisc.Attrs()
+		if qdisc.Type() != "htb" || attrs.Parent != netlink.HANDLE_ROOT || attrs.Handle != netlink.MakeHandle(tcMajor, 0) {
+			continue
+		}
+		if err := netlink.QdiscDel(qdisc); err != nil {
+			return fmt.Errorf("delete htb qdisc of %s: %w", s.ifaceName, err)
+		}
+	}
+	return nil
+}
+
+// u32Filter creates a filter classifying the packets sent to the prefix
+func u32Filter(linkIndex int, parent, classID uint32, prefix netip.Prefix) *netlink.U32 {
+	return &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: linkIndex,
+			Parent:    parent,
+			Priority:  1,
+			Protocol:  u32Protocol(prefix),
+		},
+		ClassId: classID,
+		Sel: &netlink.TcU32Sel{
+			Flags: netlink.TC_U32_TERMINAL,
+			Keys:  u32Keys(prefix),
+		},
+	}
+}
+
+func u32Protocol(prefix netip.Prefix) uint16 {
+	if prefix.Addr().Unmap().Is4() {
+		return unix.ETH_P_IP
+	}
+	return unix.ETH_P_IPV6
+}
+
+// u32Keys returns the keys matching the destination address of the packets with the prefix,
+// one key for every 32 bits word of the address covered by the prefix
+func u32Keys(prefix netip.Prefix) []netlink.TcU32Key {
+	offset := ipv6DstOffset
+	addr := prefix.Addr()
+	bits := prefix.Bits()
+	if addr.Is4In6() {
+		addr = addr.Unmap()
+		bits -= 96
+	}
+	if addr.Is4() {
+		offset = ipv4DstOffset
+	}
+
+	raw := addr.AsSlice()
+	var keys []netlink.TcU32Key
+	for word := 0; word < len(raw)/4; word++ {
+		wordBits := bits - word*32
+		if wordBits <= 0 && word > 0 {
+			break
+		}
+
+		var mask uint32
+		switch {
+		case wordBits >= 32:
+			mask = ^uint32(0)
+		case wordBits > 0:
+			mask = ^uint32(0) << (32 - wordBits)
+		}
+
+		keys = append(keys, netlink.TcU32Key{
+			Mask: mask,
+			Val:  binary.BigEndian.Uint32(raw[word*4:]) & mask,
+			Off:  int32(offset + word*4),
+		})
+	}
+	return keys
+}
@@ -0,0 +1,50 @@
+//go:build !android
+
+package shaper
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func TestU32Keys(t *testing.T) {
+	testCases := []struct {
+		name     string
+		prefix   string
+		expected []netlink.TcU32Key
+	}{
+		{
+			name:     "ipv4 host",
+			prefix:   "100.64.0.10/32",
+			expected: []netlink.TcU32Key{{Mask: 0xffffffff, Val: 0x6440000a, Off: 16}},
+		},
+		{
+			name:     "ipv4 network",
+			prefix:   "192.168.1.0/24",
+			expected: []netlink.TcU32Key{{Mask: 0xffffff00, Val: 0xc0a80100, Off: 16}},
+		},
+		{
+			name:     "ipv4 default",
+			prefix:   "0.0.0.0/0",
+			expected: []netlink.TcU32Key{{Mask: 0, Val: 0, Off: 16}},
+		},
+		{
+			name:   "ipv6 network",
+			prefix: "fd00:1234::/40",
+			expected: []netlink.TcU32Key{
+				{Mask: 0xffffffff, Val: 0xfd001234, Off: 24},
+				{Mask: 0xff000000, Val: 0, Off: 28},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			keys := u32Keys(netip.MustParsePrefix(testCase.prefix))
+			assert.Equal(t, testCase.expected, keys)
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// Operations recorded by the Manager
+const (
+	OpAllowNetbird         = "AllowNetbird"
+	OpAddFiltering         = "AddFiltering"
+	OpDeleteRule           = "DeleteRule"
+	OpInsertRoutingRules   = "InsertRoutingRules"
+	OpRemoveRoutingRules   = "RemoveRoutingRules"
+	OpSetDefaultDeny       = "SetDefaultDeny"
+	OpSetAllowedLocalPort  = "SetAllowedLocalPorts"
+	OpSetDNSLeakProtection = "SetDNSLeakProtection"
+	OpSetSourceValidation  = "SetSourceValidation"
+	OpReset                = "Reset"
+	OpFlush                = "Flush"
+	OpBeginTx              = "BeginTx"
+	OpCommit               = "Commit"
+	OpRollback             = "Rollback"
+)
+
+// Rule is a filtering rule added to the Manager
+type Rule struct {
+	ID        string
+	IP        net.IP
+	Protocol  firewall.Protocol
+	SrcPort   *firewall.Port
+	DstPort   *firewall.Port
+	Direction firewall.RuleDirection
+	Action    firewall.Action
+	IPSetName string
+	Comment   string
+}
+
+// GetRuleID returns the rule id
+func (r *Rule) GetRuleID() string {
+	return r.ID
+}
+
+// String returns the rule without its ID, the same rules have the same representation whatever the order they
+// were added in
+func (r *Rule) String() string {
+	direction := "in"
+	if r.Direction == firewall.RuleDirectionOUT {
+		direction = "out"
+	}
+	action := "accept"
+	if r.Action.Drops() {
+		action = "drop"
+	}
+	if r.Action.Logs() {
+		action = "log-" + action
+	}
+	return fmt.Sprintf("%s %s %s %s sport=%s dport=%s ipset=%s",
+		direction, action, r.Protocol, r.IP, portString(r.SrcPort), portString(r.DstPort), r.IPSetName)
+}
+
+func portString(port *firewall.Port) string {
+	if port == nil {
+		return "any"
+	}
+	return port.String()
+}
+
+// Operation is a call of a method of the Manager
+type Operation struct {
+	Op string
+	// Rule is the rule added or deleted by the AddFiltering and DeleteRule operations
+	Rule *Rule
+	// Err is the error returned to the caller
+	Err error
+}
+
+// Manager is a fake firewall manager recording the operations called on it. It keeps the filtering rules in memory
+// and supports transactions, so the callers can be tested without a firewall. It is safe for concurrent use.
+type Manager struct {
+	mu         sync.Mutex
+	rules      map[string]*Rule
+	ruleCount  int
+	operations []Operation
+	errors     map[string]error
+
+	txDisabled bool
+	inTx       bool
+	txRules    map[string]*Rule
+
+	defaultDeny   bool
+	routingRules  map[string]firewall.RouterPair
+	allowedPorts  []firewall.LocalPort
+	allowsNetbird bool
+	// dnsResolvers are the resolvers allowed by the DNS leak protection, nil when it is disabled
+	dnsResolvers []net.IP
+	// validSources are the sources allowed by the source validation, nil when it is disabled
+	validSources *firewall.SourceValidation
+}
+
+// NewManager returns an empty fake firewall manager
+func NewManager() *Manager {
+	return &Manager{
+		rules:        make(map[string]*Rule),
+		errors:       make(map[string]error),
+		routingRules: make(map[string]firewall.RouterPair),
+	}
+}
+
+// DisableTx makes BeginTx fail, as with the firewalls which don't support transactions
+func (m *Manager) DisableTx() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.txDisabled = true
+}
+
+// FailOn makes the calls of the operation return the error, a nil error makes them succeed again
+func (m *Manager) FailOn(op string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		delete(m.errors, op)
+		return
+	}
+	m.errors[op] = err
+}
+
+// Operations returns the operations recorded since the creation of the manager or the last ClearOperations
+func (m *Manager) Operations() []Operation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	operations := make([]Operation, len(m.operations))
+	copy(operations, m.operations)
+	return operations
+}
+
+// CountOperations returns how many times the operation was called
+func (m *Manager) CountOperations(op string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, operation := range m.operations {
+		if operation.Op == op {
+			count++
+		}
+	}
+	return count
+}
+
+// ClearOperations forgets the recorded operations
+func (m *Manager) ClearOperations() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operations = nil
+}
+
+// Rules returns the filtering rules of the firewall sorted by their representation
+func (m *Manager) Rules() []*Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]*Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		ruleCopy := *rule
+		rules = append(rules, &ruleCopy)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].String() < rules[j].String()
+	})
+	return rules
+}
+
+// Dump returns the filtering rules of the firewall, one per line, in a stable order suitable for golden files
+func (m *Manager) Dump() string {
+	var sb strings.Builder
+	for _, rule := range m.Rules() {
+		sb.WriteString(rule.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// AllowsNetbird returns whether AllowNetbird was called since the last Reset
+func (m *Manager) AllowsNetbird() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.allowsNetbird
+}
+
+// DefaultDeny returns whether the default deny is enabled
+func (m *Manager) DefaultDeny() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.defaultDeny
+}
+
+// AllowedLocalPorts returns the local ports set with SetAllowedLocalPorts
+func (m *Manager) AllowedLocalPorts() []firewall.LocalPort {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]firewall.LocalPort(nil), m.allowedPorts...)
+}
+
+// DNSLeakProtection returns the resolvers set with SetDNSLeakProtection, nil when the protection is disabled
+func (m *Manager) DNSLeakProtection() []net.IP {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.dnsResolvers
+}
+
+// SourceValidation returns the validation set with SetSourceValidation, nil when the validation is disabled
+func (m *Manager) SourceValidation() *firewall.SourceValidation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.validSources
+}
+
+// RoutingRules returns the routing rules inserted by ID
+func (m *Manager) RoutingRules() map[string]firewall.RouterPair {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pairs := make(map[string]firewall.RouterPair, len(m.routingRules))
+	for id, pair := range m.routingRules {
+		pairs[id] = pair
+	}
+	return pairs
+}
+
+// record saves the operation and returns the error injected for it
+func (m *Manager) record(op string, rule *Rule) error {
+	err := m.errors[op]
+	m.operations = append(m.operations, Operation{Op: op, Rule: rule, Err: err})
+	return err
+}
+
+// AllowNetbird allows netbird interface traffic
+func (m *Manager) AllowNetbird() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpAllowNetbird, nil); err != nil {
+		return err
+	}
+	m.allowsNetbird = true
+	return nil
+}
+
+// AddFiltering adds a filtering rule
+func (m *Manager) AddFiltering(
+	ip net.IP,
+	proto firewall.Protocol,
+	sPort *firewall.Port,
+	dPort *firewall.Port,
+	direction firewall.RuleDirection,
+	action firewall.Action,
+	ipsetName string,
+	comment string,
+) ([]firewall.Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ruleCount++
+	rule := &Rule{
+		ID:        fmt.Sprintf("rule-%d", m.ruleCount),
+		IP:        ip,
+		Protocol:  proto,
+		SrcPort:   sPort,
+		DstPort:   dPort,
+		Direction: direction,
+		Action:    action,
+		IPSetName: ipsetName,
+		Comment:   comment,
+	}
+	if comment == "" {
+		rule.Comment = rule.ID
+	}
+
+	if err := m.record(OpAddFiltering, rule); err != nil {
+		return nil, err
+	}
+	m.rules[rule.ID] = rule
+	return []firewall.Rule{rule}, nil
+}
+
+// DeleteRule deletes a filtering rule added by AddFiltering
+func (m *Manager) DeleteRule(rule firewall.Rule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := rule.(*Rule)
+	if !ok {
+		return fmt.Errorf("invalid rule type %T", rule)
+	}
+
+	if err := m.record(OpDeleteRule, r); err != nil {
+		return err
+	}
+	if _, ok := m.rules[r.ID]; !ok {
+		return fmt.Errorf("rule %s not found", r.ID)
+	}
+	delete(m.rules, r.ID)
+	return nil
+}
+
+// IsServerRouteSupported returns true, the fake manager accepts routing rules
+func (m *Manager) IsServerRouteSupported() bool {
+	return true
+}
+
+// InsertRoutingRules inserts a routing rule
+func (m *Manager) InsertRoutingRules(pair firewall.RouterPair) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpInsertRoutingRules, nil); err != nil {
+		return err
+	}
+	m.routingRules[pair.ID] = pair
+	return nil
+}
+
+// RemoveRoutingRules removes a routing rule
+func (m *Manager) RemoveRoutingRules(pair firewall.RouterPair) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpRemoveRoutingRules, nil); err != nil {
+		return err
+	}
+	delete(m.routingRules, pair.ID)
+	return nil
+}
+
+// SetDefaultDeny enables or disables the default deny
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpSetDefaultDeny, nil); err != nil {
+		return err
+	}
+	m.defaultDeny = enabled
+	return nil
+}
+
+// SetAllowedLocalPorts replaces the local ports accepting the traffic of the NetBird interface
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpSetAllowedLocalPort, nil); err != nil {
+		return err
+	}
+	m.allowedPorts = append([]firewall.LocalPort(nil), ports...)
+	return nil
+}
+
+// SetDNSLeakProtection replaces the resolvers allowed to receive DNS queries
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpSetDNSLeakProtection, nil); err != nil {
+		return err
+	}
+	if allowed == nil {
+		m.dnsResolvers = nil
+		return nil
+	}
+	m.dnsResolvers = append([]net.IP{}, allowed...)
+	return nil
+}
+
+// SetSourceValidation replaces the sources allowed in the forwarded traffic
+func (m *Manager) SetSourceValidation(validation *firewall.SourceValidation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpSetSourceValidation, nil); err != nil {
+		return err
+	}
+	if validation == nil {
+		m.validSources = nil
+		return nil
+	}
+	m.validSources = validation.Copy()
+	return nil
+}
+
+// Reset removes all the rules
+func (m *Manager) Reset() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpReset, nil); err != nil {
+		return err
+	}
+	m.rules = make(map[string]*Rule)
+	m.routingRules = make(map[string]firewall.RouterPair)
+	m.allowedPorts = nil
+	m.dnsResolvers = nil
+	m.validSources = nil
+	m.defaultDeny = false
+	m.allowsNetbird = false
+	m.inTx = false
+	m.txRules = nil
+	return nil
+}
+
+// Flush is a no-op, the rules are applied when added
+func (m *Manager) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.record(OpFlush, nil)
+}
+
+// BeginTx starts a transaction, it fails when DisableTx was called
+func (m *Manager) BeginTx() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.record(OpBeginTx, nil); err != nil {
+		return err
+	}
+	if m.txDisabled {
+		return fmt.Errorf("transactions are not supported")
+	}
+	if m.inTx {
+		return firewall.ErrTxInProgress
+	}
+
+	m.inTx = true
+	m.txRules = make(map[string]*Rule, len(m.rules))
+	for id, rule := range m.rules {
+		m.txRules[id] = rule
+	}
+	return nil
+}
+
+// Commit keeps the changes of the transaction, if it fails the rules are restored to the state before BeginTx
+func (m *Manager) Commit() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.inTx {
+		return firewall.ErrNoTx
+	}
+	if err := m.record(OpCommit, nil); err != nil {
+		m.rules = m.txRules
+		m.inTx = false
+		m.txRules = nil
+		return err
+	}
+
+	m.inTx = false
+	m.txRules = nil
+	return nil
+}
+
+// Rollback restores the rules to the state before BeginTx
+func (m *Manager) Rollback() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.inTx {
+		return firewall.ErrNoTx
+	}
+	if err := m.record(OpRollback, nil); err != nil {
+		return err
+	}
+
+	m.rules = m.txRules
+	m.inTx = false
+	m.txRules = nil
+	return nil
+}
+
+var _ firewall.Manager = (*Manager)(nil)
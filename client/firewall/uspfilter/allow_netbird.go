@@ -2,6 +2,15 @@
 
 package uspfilter
 
+import (
+	"errors"
+	"net"
+)
+
+// errDNSLeakProtectionNotSupported is returned without a native firewall, the userspace firewall only sees the
+// traffic of the NetBird interface
+var errDNSLeakProtectionNotSupported = errors.New("DNS leak protection not supported with userspace firewall")
+
 // Reset firewall to the default state
 func (m *Manager) Reset() error {
 	m.mutex.Lock()
@@ -9,6 +18,8 @@ func (m *Manager) Reset() error {
 
 	m.outgoingRules = make(map[string]RuleSet)
 	m.incomingRules = make(map[string]RuleSet)
+	m.txChanges = nil
+	m.updateState()
 
 	if m.nativeFirewall != nil {
 		return m.nativeFirewall.Reset()
@@ -23,3 +34,12 @@ func (m *Manager) AllowNetbird() error {
 	}
 	return nil
 }
+
+// SetDNSLeakProtection is delegated to the native firewall, the DNS queries leaking through the other interfaces
+// never reach the userspace firewall
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	if m.nativeFirewall == nil {
+		return errDNSLeakProtectionNotSupported
+	}
+	return m.nativeFirewall.SetDNSLeakProtection(allowed)
+}
@@ -1,11 +1,19 @@
 package uspfilter
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 
 	log "github.com/sirupsen/logrus"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
 )
 
 type action string
@@ -14,6 +22,9 @@ const (
 	addRule          action = "add"
 	deleteRule       action = "delete"
 	firewallRuleName        = "Netbird"
+	// dnsLeakRuleName prefixes the names of the rules blocking the DNS queries sent to other resolvers, one per
+	// protocol
+	dnsLeakRuleName = "Netbird DNS leak protection"
 )
 
 // Reset firewall to the default state
@@ -23,11 +34,17 @@ func (m *Manager) Reset() error {
 
 	m.outgoingRules = make(map[string]RuleSet)
 	m.incomingRules = make(map[string]RuleSet)
+	m.txChanges = nil
+	m.updateState()
 
 	if !isWindowsFirewallReachable() {
 		return nil
 	}
 
+	if err := removeDNSLeakRules(); err != nil {
+		return err
+	}
+
 	if !isFirewallRuleActive(firewallRuleName) {
 		return nil
 	}
@@ -39,6 +56,104 @@ func (m *Manager) Reset() error {
 	return nil
 }
 
+// SetDNSLeakProtection replaces the Windows firewall rules blocking the DNS queries sent to other destinations than
+// the allowed resolvers. The Windows firewall doesn't filter the loopback traffic
+func (m *Manager) SetDNSLeakProtection(allowed []net.IP) error {
+	if !isWindowsFirewallReachable() {
+		return errors.New("DNS leak protection requires the Windows firewall")
+	}
+
+	if err := removeDNSLeakRules(); err != nil {
+		return err
+	}
+	if allowed == nil {
+		return nil
+	}
+
+	var ports []string
+	for _, port := range firewall.DNSPorts {
+		ports = append(ports, strconv.Itoa(port))
+	}
+	blocked := strings.Join(blockedRanges(allowed), ",")
+	for _, protocol := range []string{"TCP", "UDP"} {
+		err := manageFirewallRule(dnsLeakRuleName+" "+protocol,
+			addRule,
+			"dir=out",
+			"enable=yes",
+			"action=block",
+			"profile=any",
+			"protocol="+protocol,
+			"remoteport="+strings.Join(ports, ","),
+			"remoteip="+blocked,
+		)
+		if err != nil {
+			return fmt.Errorf("couldn't add the %s DNS leak protection rule: %w", protocol, err)
+		}
+	}
+	return nil
+}
+
+func removeDNSLeakRules() error {
+	for _, protocol := range []string{"TCP", "UDP"} {
+		name := dnsLeakRuleName + " " + protocol
+		if !isFirewallRuleActive(name) {
+			continue
+		}
+		if err := manageFirewallRule(name, deleteRule); err != nil {
+			return fmt.Errorf("couldn't remove the %s DNS leak protection rule: %w", protocol, err)
+		}
+	}
+	return nil
+}
+
+// blockedRanges returns the IPv4 and IPv6 address ranges excluding the allowed addresses, the Windows firewall has
+// no negated address match and its block rules take precedence over its allow rules
+func blockedRanges(allowed []net.IP) []string {
+	var ranges []string
+	for _, family := range []struct {
+		first, last netip.Addr
+	}{
+		{netip.IPv4Unspecified(), netip.AddrFrom4([4]byte{255, 255, 255, 255})},
+		{netip.IPv6Unspecified(), netip.AddrFrom16([16]byte{
+			255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255,
+		})},
+	} {
+		var addrs []netip.Addr
+		for _, ip := range allowed {
+			addr, ok := netip.AddrFromSlice(ip)
+			if !ok {
+				continue
+			}
+			addr = addr.Unmap()
+			if addr.Is4() == family.first.Is4() {
+				addrs = append(addrs, addr)
+			}
+		}
+		sort.Slice(addrs, func(i, j int) bool {
+			return addrs[i].Less(addrs[j])
+		})
+
+		start := family.first
+		for _, addr := range addrs {
+			if addr.Less(start) {
+				// a duplicate
+				continue
+			}
+			if addr != start {
+				ranges = append(ranges, start.String()+"-"+addr.Prev().String())
+			}
+			start = addr.Next()
+			if !start.IsValid() {
+				break
+			}
+		}
+		if start.IsValid() {
+			ranges = append(ranges, start.String()+"-"+family.last.String())
+		}
+	}
+	return ranges
+}
+
 // AllowNetbird allows netbird interface traffic
 func (m *Manager) AllowNetbird() error {
 	if !isWindowsFirewallReachable() {
@@ -48,13 +163,19 @@ func (m *Manager) AllowNetbird() error {
 	if isFirewallRuleActive(firewallRuleName) {
 		return nil
 	}
+
+	// the traffic to the extra addresses of the interface is allowed too
+	var localIPs []string
+	for _, addr := range m.wgIface.Address().All() {
+		localIPs = append(localIPs, addr.IP.String())
+	}
 	return manageFirewallRule(firewallRuleName,
 		addRule,
 		"dir=in",
 		"enable=yes",
 		"action=allow",
 		"profile=any",
-		"localip="+m.wgIface.Address().IP.String(),
+		"localip="+strings.Join(localIPs, ","),
 	)
 }
 
@@ -0,0 +1,34 @@
+package uspfilter
+
+import (
+	"github.com/google/gopacket/layers"
+)
+
+// ndpHopLimit is the hop limit of the Neighbor Discovery messages, the messages with another one were forwarded by a
+// router and are invalid (RFC 4861)
+const ndpHopLimit = 255
+
+// icmpv6Verdict returns whether the packet is an ICMPv6 control message required for the operation of IPv6, accepted
+// or dropped before the rules are evaluated, and whether it has to be dropped. The error messages, e.g. Packet Too
+// Big needed by the path MTU discovery, and the valid Neighbor Discovery messages are accepted (RFC 4890). The echo
+// messages and the other types are left to the rules
+func icmpv6Verdict(d *decoder) (handled bool, drop bool) {
+	if d.decoded[0] != layers.LayerTypeIPv6 || d.decoded[1] != layers.LayerTypeICMPv6 {
+		return false, false
+	}
+
+	switch d.icmp6.TypeCode.Type() {
+	case layers.ICMPv6TypeDestinationUnreachable,
+		layers.ICMPv6TypePacketTooBig,
+		layers.ICMPv6TypeTimeExceeded,
+		layers.ICMPv6TypeParameterProblem:
+		return true, false
+	case layers.ICMPv6TypeRouterSolicitation,
+		layers.ICMPv6TypeRouterAdvertisement,
+		layers.ICMPv6TypeNeighborSolicitation,
+		layers.ICMPv6TypeNeighborAdvertisement:
+		return true, d.ip6.HopLimit != ndpHopLimit
+	default:
+		return false, false
+	}
+}
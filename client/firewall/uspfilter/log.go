@@ -0,0 +1,96 @@
+package uspfilter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/layers"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// logLimiter is a token bucket limiting the number of packets logged by a rule, shared by the copies of the rule
+type logLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newLogLimiter() *logLimiter {
+	return &logLimiter{tokens: firewall.LogBurst}
+}
+
+// allow returns true if the packet can be logged, the bucket is refilled at firewall.LogRate tokens per second up to
+// firewall.LogBurst tokens
+func (l *logLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * firewall.LogRate
+		if l.tokens > firewall.LogBurst {
+			l.tokens = firewall.LogBurst
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// SetPacketLogger sets the function the packets matched by the logged rules are passed to, rate limited per rule. Nil
+// disables the logging
+func (m *Manager) SetPacketLogger(logger func(firewall.PacketLog)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.packetLogger = logger
+	m.updateState()
+}
+
+// logPacket passes the packet to the logger if the rule is logged and its rate limit isn't exceeded
+func (s *filterState) logPacket(rule *Rule, d *decoder, isIncomingPacket bool, drop bool) {
+	if s.packetLogger == nil || rule == nil || rule.logLimiter == nil || !rule.logLimiter.allow(time.Now()) {
+		return
+	}
+	s.packetLogger(packetLog(d, isIncomingPacket, drop))
+}
+
+// packetLog describes a decoded packet
+func packetLog(d *decoder, isIncomingPacket bool, drop bool) firewall.PacketLog {
+	entry := firewall.PacketLog{Dropped: drop, Direction: firewall.RuleDirectionOUT}
+	if isIncomingPacket {
+		entry.Direction = firewall.RuleDirectionIN
+	}
+
+	switch d.decoded[0] {
+	case layers.LayerTypeIPv4:
+		entry.SrcIP, entry.DstIP = d.ip4.SrcIP, d.ip4.DstIP
+	case layers.LayerTypeIPv6:
+		entry.SrcIP, entry.DstIP = d.ip6.SrcIP, d.ip6.DstIP
+	}
+	// the decoder reuses the buffers of the addresses for the next packet
+	entry.SrcIP = append([]byte{}, entry.SrcIP...)
+	entry.DstIP = append([]byte{}, entry.DstIP...)
+
+	switch d.decoded[1] {
+	case layers.LayerTypeTCP:
+		entry.Protocol = firewall.ProtocolTCP
+		entry.SrcPort, entry.DstPort = uint16(d.tcp.SrcPort), uint16(d.tcp.DstPort)
+	case layers.LayerTypeUDP:
+		entry.Protocol = firewall.ProtocolUDP
+		entry.SrcPort, entry.DstPort = uint16(d.udp.SrcPort), uint16(d.udp.DstPort)
+	case layers.LayerTypeSCTP:
+		entry.Protocol = firewall.ProtocolSCTP
+		entry.SrcPort, entry.DstPort = d.sctp.SrcPort, d.sctp.DstPort
+	case layers.LayerTypeICMPv4, layers.LayerTypeICMPv6:
+		entry.Protocol = firewall.ProtocolICMP
+	case layers.LayerTypeGRE:
+		entry.Protocol = firewall.ProtocolGRE
+	default:
+		entry.Protocol = firewall.ProtocolALL
+	}
+	return entry
+}
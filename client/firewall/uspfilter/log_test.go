@@ -0,0 +1,30 @@
+package uspfilter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+func TestLogLimiter(t *testing.T) {
+	limiter := newLogLimiter()
+	now := time.Now()
+
+	for i := 0; i < fw.LogBurst; i++ {
+		require.True(t, limiter.allow(now), "the packets of the burst should be logged")
+	}
+	require.False(t, limiter.allow(now), "the packets over the burst should not be logged")
+
+	now = now.Add(time.Second / fw.LogRate)
+	require.True(t, limiter.allow(now), "a token should be refilled after the rate interval")
+	require.False(t, limiter.allow(now))
+
+	now = now.Add(time.Hour)
+	for i := 0; i < fw.LogBurst; i++ {
+		require.True(t, limiter.allow(now), "the bucket should be refilled up to the burst")
+	}
+	require.False(t, limiter.allow(now), "the bucket should not be refilled over the burst")
+}
@@ -18,8 +18,15 @@ type Rule struct {
 	direction  firewall.RuleDirection
 	sPort      uint16
 	dPort      uint16
-	drop       bool
-	comment    string
+	// sPortEnd and dPortEnd are the last ports of the port ranges, 0 for single ports
+	sPortEnd uint16
+	dPortEnd uint16
+	drop     bool
+	comment  string
+	// logLimiter limits the packets logged by the rule, nil if the rule isn't logged
+	logLimiter *logLimiter
+	// seq orders the rules by insertion, the first matching rule applies
+	seq uint64
 
 	udpHook func([]byte) bool
 }
@@ -28,3 +35,11 @@ type Rule struct {
 func (r *Rule) GetRuleID() string {
 	return r.id
 }
+
+// matchPort returns true if the port is the port of the rule or is in its range
+func matchPort(port, end, value uint16) bool {
+	if end == 0 {
+		return port == value
+	}
+	return value >= port && value <= end
+}
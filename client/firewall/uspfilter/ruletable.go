@@ -0,0 +1,182 @@
+package uspfilter
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/google/gopacket/layers"
+
+	"github.com/FlintyLemming/netbird/client/firewall/flow"
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+)
+
+// filterState is the state used on the packet path. It is immutable and replaced as a whole when the rules or the
+// settings change, so that packets are filtered without locking
+type filterState struct {
+	incoming        ruleTable
+	outgoing        ruleTable
+	wgNetwork       netip.Prefix
+	bandwidthLimits []bandwidthLimit
+	flows           *flow.Aggregator
+	// packetLogger receives the packets matched by the logged rules, nil if the logging is disabled
+	packetLogger func(firewall.PacketLog)
+	// defaultDeny filters the traffic exchanged with the routed networks with the rules
+	defaultDeny bool
+	// localPorts are the local ports whose traffic is accepted before the rules are evaluated
+	localPorts map[firewall.LocalPort]struct{}
+	// peerIdentities are the public keys of the peers by address
+	peerIdentities map[netip.Addr]string
+}
+
+// ruleTable holds the rules of one direction compiled for the lookup by the address of the remote peer
+type ruleTable struct {
+	// byIP holds the rules matching a single address in insertion order
+	byIP map[netip.Addr][]Rule
+	// anyIP holds the rules matching any IPv4 address followed by the rules matching any IPv6 address
+	anyIP []Rule
+}
+
+// compileRules builds the rule table of the rule sets keyed by address
+func compileRules(rules map[string]RuleSet) ruleTable {
+	table := ruleTable{byIP: make(map[netip.Addr][]Rule, len(rules))}
+
+	var any4, any6 []Rule
+	for key, ruleSet := range rules {
+		if len(ruleSet) == 0 {
+			continue
+		}
+
+		compiled := make([]Rule, 0, len(ruleSet))
+		for _, rule := range ruleSet {
+			compiled = append(compiled, rule)
+		}
+		sort.Slice(compiled, func(i, j int) bool {
+			return compiled[i].seq < compiled[j].seq
+		})
+
+		switch key {
+		case "0.0.0.0":
+			any4 = compiled
+		case "::":
+			any6 = compiled
+		default:
+			addr, err := netip.ParseAddr(key)
+			if err != nil {
+				continue
+			}
+			table.byIP[addr.Unmap()] = compiled
+		}
+	}
+	table.anyIP = append(any4, any6...)
+
+	return table
+}
+
+// allowsLocalPort returns true for the packets received on an allowed local port and the packets sent from it
+func (s *filterState) allowsLocalPort(d *decoder, isIncomingPacket bool) bool {
+	if len(s.localPorts) == 0 {
+		return false
+	}
+
+	var port firewall.LocalPort
+	switch d.decoded[1] {
+	case layers.LayerTypeTCP:
+		port = firewall.LocalPort{Protocol: firewall.ProtocolTCP, Port: int(d.tcp.SrcPort)}
+		if isIncomingPacket {
+			port.Port = int(d.tcp.DstPort)
+		}
+	case layers.LayerTypeUDP:
+		port = firewall.LocalPort{Protocol: firewall.ProtocolUDP, Port: int(d.udp.SrcPort)}
+		if isIncomingPacket {
+			port.Port = int(d.udp.DstPort)
+		}
+	default:
+		return false
+	}
+
+	_, ok := s.localPorts[port]
+	return ok
+}
+
+// spoofed returns true for a packet whose source address belongs to another peer than the one which sent it, the
+// peer is empty when it is unknown
+func (s *filterState) spoofed(src netip.Addr, peer string) bool {
+	if peer == "" {
+		return false
+	}
+	owner, ok := s.peerIdentities[src]
+	return ok && owner != peer
+}
+
+// drop returns whether the packet has to be dropped according to the rules of the remote address and the rule which
+// matched, nil when no rule matched
+func (t *ruleTable) drop(ip netip.Addr, packetData []byte, d *decoder) (bool, *Rule) {
+	if drop, rule := validateRule(packetData, t.byIP[ip], d); rule != nil {
+		return drop, rule
+	}
+	if drop, rule := validateRule(packetData, t.anyIP, d); rule != nil {
+		return drop, rule
+	}
+
+	// default policy is DROP ALL
+	return true, nil
+}
+
+// validateRule returns the verdict of the first rule matching the packet and the rule, nil when no rule matched
+func validateRule(packetData []byte, rules []Rule, d *decoder) (bool, *Rule) {
+	payloadLayer := d.decoded[1]
+	for i := range rules {
+		rule := &rules[i]
+
+		if rule.protoLayer == layerTypeAll {
+			return rule.drop, rule
+		}
+
+		if payloadLayer != rule.protoLayer {
+			continue
+		}
+
+		switch payloadLayer {
+		case layers.LayerTypeTCP:
+			if rule.sPort == 0 && rule.dPort == 0 {
+				return rule.drop, rule
+			}
+			if rule.sPort != 0 && matchPort(rule.sPort, rule.sPortEnd, uint16(d.tcp.SrcPort)) {
+				return rule.drop, rule
+			}
+			if rule.dPort != 0 && matchPort(rule.dPort, rule.dPortEnd, uint16(d.tcp.DstPort)) {
+				return rule.drop, rule
+			}
+		case layers.LayerTypeUDP:
+			// if rule has UDP hook (and if we are here we match this rule)
+			// we ignore rule.drop and call this hook
+			if rule.udpHook != nil {
+				return rule.udpHook(packetData), rule
+			}
+
+			if rule.sPort == 0 && rule.dPort == 0 {
+				return rule.drop, rule
+			}
+			if rule.sPort != 0 && matchPort(rule.sPort, rule.sPortEnd, uint16(d.udp.SrcPort)) {
+				return rule.drop, rule
+			}
+			if rule.dPort != 0 && matchPort(rule.dPort, rule.dPortEnd, uint16(d.udp.DstPort)) {
+				return rule.drop, rule
+			}
+			return rule.drop, rule
+		case layers.LayerTypeSCTP:
+			if rule.sPort == 0 && rule.dPort == 0 {
+				return rule.drop, rule
+			}
+			if rule.sPort != 0 && matchPort(rule.sPort, rule.sPortEnd, d.sctp.SrcPort) {
+				return rule.drop, rule
+			}
+			if rule.dPort != 0 && matchPort(rule.dPort, rule.dPortEnd, d.sctp.DstPort) {
+				return rule.drop, rule
+			}
+		case layers.LayerTypeICMPv4, layers.LayerTypeICMPv6, layers.LayerTypeGRE:
+			return rule.drop, rule
+		}
+	}
+	return false, nil
+}
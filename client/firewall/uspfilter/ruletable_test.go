@@ -0,0 +1,43 @@
+package uspfilter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func TestManagerRuleTable(t *testing.T) {
+	m, err := Create(&IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	})
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	peer := net.ParseIP("100.10.0.100")
+	dnsPort := &fw.Port{Values: []int{5201}}
+
+	// the first matching rule applies
+	_, err = m.AddFiltering(peer, fw.ProtocolUDP, nil, dnsPort, fw.RuleDirectionOUT, fw.ActionDrop, "", "drop iperf")
+	require.NoError(t, err)
+	allowAll, err := m.AddFiltering(net.ParseIP("0.0.0.0"), fw.ProtocolALL, nil, nil, fw.RuleDirectionOUT, fw.ActionAccept, "", "allow all")
+	require.NoError(t, err)
+
+	assert.True(t, m.DropOutgoing(udpPacket(t, "100.10.0.1", "100.10.0.100", 10)), "rule of the peer should apply first")
+	assert.False(t, m.DropOutgoing(udpPacket(t, "100.10.0.1", "100.10.0.200", 10)), "rule of any address should apply")
+	assert.False(t, m.DropOutgoing(udpPacket(t, "192.168.0.1", "192.168.0.2", 10)), "traffic outside of the network is not filtered")
+	assert.True(t, m.DropIncoming(udpPacket(t, "100.10.0.100", "100.10.0.1", 10)), "incoming traffic without rules should be dropped")
+
+	require.NoError(t, m.BeginTx())
+	require.NoError(t, m.DeleteRule(allowAll[0]))
+	assert.False(t, m.DropOutgoing(udpPacket(t, "100.10.0.1", "100.10.0.200", 10)), "changes should apply on commit")
+	require.NoError(t, m.Commit())
+	assert.True(t, m.DropOutgoing(udpPacket(t, "100.10.0.1", "100.10.0.200", 10)), "deleted rule should not apply")
+}
@@ -0,0 +1,90 @@
+package uspfilter
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/FlintyLemming/netbird/client/firewall/shaper"
+)
+
+// minBurstBytes allows at least a full sized packet to pass the bucket
+const minBurstBytes = 65535
+
+// bandwidthLimit drops the packets sent to the prefixes exceeding the rate of the bucket
+type bandwidthLimit struct {
+	prefixes []netip.Prefix
+	bucket   *tokenBucket
+}
+
+// tokenBucket is a token bucket with one token per byte
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket creates a full bucket refilled with rate kbit/s, holding up to a tenth of a second of traffic
+func newTokenBucket(rate uint64) *tokenBucket {
+	bytesPerSecond := float64(rate) * 1000 / 8
+	capacity := bytesPerSecond / 10
+	if capacity < minBurstBytes {
+		capacity = minBurstBytes
+	}
+	return &tokenBucket{
+		rate:     bytesPerSecond,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+// allow takes size tokens from the bucket, returns false if there are not enough of them
+func (b *tokenBucket) allow(size int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+
+	if b.tokens < float64(size) {
+		return false
+	}
+	b.tokens -= float64(size)
+	return true
+}
+
+// SetBandwidthLimits replaces the bandwidth limits of the outgoing traffic. Packets exceeding a limit are dropped,
+// leaving the congestion control of the transport protocols to adapt to the rate
+func (m *Manager) SetBandwidthLimits(limits []shaper.Limit) error {
+	bandwidthLimits := make([]bandwidthLimit, 0, len(limits))
+	for _, limit := range limits {
+		bandwidthLimits = append(bandwidthLimits, bandwidthLimit{
+			prefixes: limit.Prefixes,
+			bucket:   newTokenBucket(limit.Rate),
+		})
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bandwidthLimits = bandwidthLimits
+	m.updateState()
+	return nil
+}
+
+// exceedsBandwidthLimit returns true if the packet sent to dst exceeds its bandwidth limit
+func exceedsBandwidthLimit(limits []bandwidthLimit, dst netip.Addr, size int) bool {
+	for _, limit := range limits {
+		for _, prefix := range limit.prefixes {
+			if prefix.Contains(dst) {
+				return !limit.bucket.allow(size, time.Now())
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package uspfilter
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/client/firewall/shaper"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func TestTokenBucket(t *testing.T) {
+	// 8000 kbit/s is 1MB/s, holding up to 100KB
+	bucket := newTokenBucket(8000)
+	now := bucket.last
+
+	assert.True(t, bucket.allow(100_000, now), "a full bucket should allow its capacity")
+	assert.False(t, bucket.allow(1, now), "an empty bucket should not allow any byte")
+
+	now = now.Add(10 * time.Millisecond)
+	assert.True(t, bucket.allow(10_000, now), "the bucket should be refilled with the rate")
+	assert.False(t, bucket.allow(1000, now))
+
+	now = now.Add(time.Hour)
+	assert.True(t, bucket.allow(100_000, now))
+	assert.False(t, bucket.allow(1000, now), "the bucket should not be refilled over its capacity")
+}
+
+func TestManagerBandwidthLimits(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.wgNetwork = &net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	}
+
+	_, err = m.AddFiltering(net.ParseIP("0.0.0.0"), fw.ProtocolALL, nil, nil, fw.RuleDirectionOUT, fw.ActionAccept, "", "allow all")
+	require.NoError(t, err)
+
+	err = m.SetBandwidthLimits([]shaper.Limit{{
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("100.10.0.100/32")},
+		Rate:     1000,
+	}})
+	require.NoError(t, err)
+
+	limited := udpPacket(t, "100.10.0.1", "100.10.0.100", 1000)
+	unlimited := udpPacket(t, "100.10.0.1", "100.10.0.200", 1000)
+
+	var dropped int
+	for i := 0; i < 100; i++ {
+		if m.DropOutgoing(limited) {
+			dropped++
+		}
+		assert.False(t, m.DropOutgoing(unlimited), "packets to peers without limit should not be dropped")
+	}
+	assert.Greater(t, dropped, 0, "packets exceeding the limit should be dropped")
+	assert.Less(t, dropped, 100, "packets within the burst should pass")
+
+	require.NoError(t, m.SetBandwidthLimits(nil))
+	assert.False(t, m.DropOutgoing(limited), "removed limits should not drop packets")
+}
+
+func udpPacket(t testing.TB, src, dst string, size int) []byte {
+	t.Helper()
+
+	ipv4 := &layers.IPv4{
+		TTL:      64,
+		Version:  4,
+		SrcIP:    net.ParseIP(src),
+		DstIP:    net.ParseIP(dst),
+		Protocol: layers.IPProtocolUDP,
+	}
+	udp := &layers.UDP{
+		SrcPort: 51334,
+		DstPort: 5201,
+	}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ipv4))
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, udp, gopacket.Payload(make([]byte, size))))
+	return buf.Bytes()
+}
@@ -0,0 +1,59 @@
+package uspfilter
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// sctpHeaderLen is the length of the SCTP common header
+const sctpHeaderLen = 12
+
+// sctpHeader decodes the ports of the SCTP common header, gopacket doesn't provide a decoding layer for SCTP
+type sctpHeader struct {
+	layers.BaseLayer
+	SrcPort uint16
+	DstPort uint16
+}
+
+func (h *sctpHeader) CanDecode() gopacket.LayerClass {
+	return layers.LayerTypeSCTP
+}
+
+// NextLayerType stops the decoding, the chunks aren't filtered
+func (h *sctpHeader) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+func (h *sctpHeader) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < sctpHeaderLen {
+		df.SetTruncated()
+		return fmt.Errorf("invalid SCTP header, length %d less than %d", len(data), sctpHeaderLen)
+	}
+	h.SrcPort = binary.BigEndian.Uint16(data[0:2])
+	h.DstPort = binary.BigEndian.Uint16(data[2:4])
+	h.BaseLayer = layers.BaseLayer{Contents: data[:sctpHeaderLen], Payload: data[sctpHeaderLen:]}
+	return nil
+}
+
+// greHeader marks the GRE packets without decoding them. The decoder of gopacket would decode the tunneled packet
+// into the layers of the outer one
+type greHeader struct {
+	layers.BaseLayer
+}
+
+func (h *greHeader) CanDecode() gopacket.LayerClass {
+	return layers.LayerTypeGRE
+}
+
+// NextLayerType stops the decoding, the tunneled packet isn't filtered
+func (h *greHeader) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+func (h *greHeader) DecodeFromBytes(data []byte, _ gopacket.DecodeFeedback) error {
+	h.BaseLayer = layers.BaseLayer{Payload: data}
+	return nil
+}
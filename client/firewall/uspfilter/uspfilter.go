@@ -3,13 +3,16 @@ package uspfilter
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"sync"
+	"sync/atomic"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/FlintyLemming/netbird/client/firewall/flow"
 	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/iface"
 )
@@ -37,10 +40,33 @@ type Manager struct {
 	decoders       sync.Pool
 	wgIface        IFaceMapper
 	nativeFirewall firewall.Manager
+	flows          *flow.Aggregator
+	packetLogger   func(firewall.PacketLog)
+	defaultDeny    bool
+	localPorts     map[firewall.LocalPort]struct{}
+	// peerIdentities are the public keys of the peers by address, the incoming packets from these addresses have to
+	// be sent by these peers
+	peerIdentities map[netip.Addr]string
+
+	bandwidthLimits []bandwidthLimit
+
+	// txChanges holds the rule changes of the running transaction, nil if no transaction was started
+	txChanges []ruleChange
+	// ruleSeq numbers the rules in insertion order
+	ruleSeq uint64
+
+	// state is compiled from the fields above on every change and read without locking on the packet path
+	state atomic.Pointer[filterState]
 
 	mutex sync.RWMutex
 }
 
+// ruleChange is a rule added or deleted in a transaction
+type ruleChange struct {
+	rule   Rule
+	delete bool
+}
+
 // decoder for packages
 type decoder struct {
 	eth     layers.Ethernet
@@ -50,8 +76,20 @@ type decoder struct {
 	udp     layers.UDP
 	icmp4   layers.ICMPv4
 	icmp6   layers.ICMPv6
+	sctp    sctpHeader
+	gre     greHeader
 	decoded []gopacket.LayerType
-	parser  *gopacket.DecodingLayerParser
+	// parser4 decodes the IPv4 packets and parser6 the IPv6 ones, a parser starts with a fixed first layer
+	parser4 *gopacket.DecodingLayerParser
+	parser6 *gopacket.DecodingLayerParser
+}
+
+// decode decodes the layers of the packet with the parser of its IP version
+func (d *decoder) decode(packetData []byte) error {
+	if len(packetData) > 0 && packetData[0]>>4 == 6 {
+		return d.parser6.DecodeLayers(packetData, &d.decoded)
+	}
+	return d.parser4.DecodeLayers(packetData, &d.decoded)
 }
 
 // Create userspace firewall manager constructor
@@ -76,11 +114,16 @@ func create(iface IFaceMapper) (*Manager, error) {
 				d := &decoder{
 					decoded: []gopacket.LayerType{},
 				}
-				d.parser = gopacket.NewDecodingLayerParser(
+				d.parser4 = gopacket.NewDecodingLayerParser(
 					layers.LayerTypeIPv4,
-					&d.eth, &d.ip4, &d.ip6, &d.icmp4, &d.icmp6, &d.tcp, &d.udp,
+					&d.eth, &d.ip4, &d.ip6, &d.icmp4, &d.icmp6, &d.tcp, &d.udp, &d.sctp, &d.gre,
 				)
-				d.parser.IgnoreUnsupported = true
+				d.parser4.IgnoreUnsupported = true
+				d.parser6 = gopacket.NewDecodingLayerParser(
+					layers.LayerTypeIPv6,
+					&d.eth, &d.ip4, &d.ip6, &d.icmp4, &d.icmp6, &d.tcp, &d.udp, &d.sctp, &d.gre,
+				)
+				d.parser6.IgnoreUnsupported = true
 				return d
 			},
 		},
@@ -89,6 +132,8 @@ func create(iface IFaceMapper) (*Manager, error) {
 		wgIface:       iface,
 	}
 
+	m.updateState()
+
 	if err := iface.SetFilter(m); err != nil {
 		return nil, err
 	}
@@ -118,6 +163,14 @@ func (m *Manager) RemoveRoutingRules(pair firewall.RouterPair) error {
 	return m.nativeFirewall.RemoveRoutingRules(pair)
 }
 
+// SetSourceValidation is delegated to the native firewall, the forwarded traffic never reaches the userspace firewall
+func (m *Manager) SetSourceValidation(validation *firewall.SourceValidation) error {
+	if m.nativeFirewall == nil {
+		return errRouteNotSupported
+	}
+	return m.nativeFirewall.SetSourceValidation(validation)
+}
+
 // AddFiltering rule to the firewall
 //
 // If comment argument is empty firewall manager should set
@@ -138,9 +191,12 @@ func (m *Manager) AddFiltering(
 		ipLayer:   layers.LayerTypeIPv6,
 		matchByIP: true,
 		direction: direction,
-		drop:      action == firewall.ActionDrop,
+		drop:      action.Drops(),
 		comment:   comment,
 	}
+	if action.Logs() {
+		r.logLimiter = newLogLimiter()
+	}
 	if ipNormalized := ip.To4(); ipNormalized != nil {
 		r.ipLayer = layers.LayerTypeIPv4
 		r.ip = ipNormalized
@@ -150,19 +206,18 @@ func (m *Manager) AddFiltering(
 		r.matchByIP = false
 	}
 
-	if sPort != nil && len(sPort.Values) == 1 {
-		r.sPort = uint16(sPort.Values[0])
-	}
-
-	if dPort != nil && len(dPort.Values) == 1 {
-		r.dPort = uint16(dPort.Values[0])
-	}
+	r.sPort, r.sPortEnd = toRulePort(sPort)
+	r.dPort, r.dPortEnd = toRulePort(dPort)
 
 	switch proto {
 	case firewall.ProtocolTCP:
 		r.protoLayer = layers.LayerTypeTCP
 	case firewall.ProtocolUDP:
 		r.protoLayer = layers.LayerTypeUDP
+	case firewall.ProtocolSCTP:
+		r.protoLayer = layers.LayerTypeSCTP
+	case firewall.ProtocolGRE:
+		r.protoLayer = layers.LayerTypeGRE
 	case firewall.ProtocolICMP:
 		r.protoLayer = layers.LayerTypeICMPv4
 		if r.ipLayer == layers.LayerTypeIPv6 {
@@ -173,22 +228,31 @@ func (m *Manager) AddFiltering(
 	}
 
 	m.mutex.Lock()
-	if direction == firewall.RuleDirectionIN {
-		if _, ok := m.incomingRules[r.ip.String()]; !ok {
-			m.incomingRules[r.ip.String()] = make(RuleSet)
-		}
-		m.incomingRules[r.ip.String()][r.id] = r
+	if m.txChanges != nil {
+		m.txChanges = append(m.txChanges, ruleChange{rule: r})
 	} else {
-		if _, ok := m.outgoingRules[r.ip.String()]; !ok {
-			m.outgoingRules[r.ip.String()] = make(RuleSet)
-		}
-		m.outgoingRules[r.ip.String()][r.id] = r
+		m.addRule(r)
+		m.updateState()
 	}
 	m.mutex.Unlock()
 	return []firewall.Rule{&r}, nil
 }
 
 // DeleteRule from the firewall by rule definition
+// toRulePort returns the port and the end of the port range of a rule, the end is 0 for a single port
+func toRulePort(port *firewall.Port) (uint16, uint16) {
+	switch {
+	case port == nil:
+		return 0, 0
+	case port.IsRange && len(port.Values) == 2:
+		return uint16(port.Values[0]), uint16(port.Values[1])
+	case len(port.Values) == 1:
+		return uint16(port.Values[0]), 0
+	default:
+		return 0, 0
+	}
+}
+
 func (m *Manager) DeleteRule(rule firewall.Rule) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -198,45 +262,174 @@ func (m *Manager) DeleteRule(rule firewall.Rule) error {
 		return fmt.Errorf("delete rule: invalid rule type: %T", rule)
 	}
 
-	if r.direction == firewall.RuleDirectionIN {
-		_, ok := m.incomingRules[r.ip.String()][r.id]
-		if !ok {
-			return fmt.Errorf("delete rule: no rule with such id: %v", r.id)
-		}
-		delete(m.incomingRules[r.ip.String()], r.id)
-	} else {
-		_, ok := m.outgoingRules[r.ip.String()][r.id]
-		if !ok {
-			return fmt.Errorf("delete rule: no rule with such id: %v", r.id)
+	if m.txChanges == nil {
+		if err := m.deleteRule(r); err != nil {
+			return err
 		}
-		delete(m.outgoingRules[r.ip.String()], r.id)
+		m.updateState()
+		return nil
 	}
 
+	if !m.ruleExists(r) {
+		return fmt.Errorf("delete rule: no rule with such id: %v", r.id)
+	}
+	m.txChanges = append(m.txChanges, ruleChange{rule: *r, delete: true})
 	return nil
 }
 
 // Flush doesn't need to be implemented for this manager
 func (m *Manager) Flush() error { return nil }
 
+// BeginTx starts a transaction, rule changes are applied to the packet filter at once on Commit
+func (m *Manager) BeginTx() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txChanges != nil {
+		return firewall.ErrTxInProgress
+	}
+	m.txChanges = []ruleChange{}
+	return nil
+}
+
+// Commit applies the rule changes of the transaction
+func (m *Manager) Commit() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txChanges == nil {
+		return firewall.ErrNoTx
+	}
+
+	for _, change := range m.txChanges {
+		if !change.delete {
+			m.addRule(change.rule)
+			continue
+		}
+		rule := change.rule
+		if err := m.deleteRule(&rule); err != nil {
+			log.Debugf("skipping rule deleted in transaction: %v", err)
+		}
+	}
+	m.txChanges = nil
+	m.updateState()
+	return nil
+}
+
+// Rollback discards the rule changes of the transaction
+func (m *Manager) Rollback() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.txChanges == nil {
+		return firewall.ErrNoTx
+	}
+	m.txChanges = nil
+	return nil
+}
+
+// addRule adds the rule to the packet filter, the caller must hold the lock
+func (m *Manager) addRule(r Rule) {
+	rules := m.outgoingRules
+	if r.direction == firewall.RuleDirectionIN {
+		rules = m.incomingRules
+	}
+
+	m.ruleSeq++
+	r.seq = m.ruleSeq
+
+	if _, ok := rules[r.ip.String()]; !ok {
+		rules[r.ip.String()] = make(RuleSet)
+	}
+	rules[r.ip.String()][r.id] = r
+}
+
+// updateState compiles the rules and settings into the state used on the packet path, the caller must hold the lock
+func (m *Manager) updateState() {
+	state := &filterState{
+		incoming:        compileRules(m.incomingRules),
+		outgoing:        compileRules(m.outgoingRules),
+		bandwidthLimits: m.bandwidthLimits,
+		flows:           m.flows,
+		packetLogger:    m.packetLogger,
+		defaultDeny:     m.defaultDeny,
+		localPorts:      m.localPorts,
+		peerIdentities:  m.peerIdentities,
+	}
+	if m.wgNetwork != nil {
+		addr, _ := netip.AddrFromSlice(m.wgNetwork.IP)
+		bits, _ := m.wgNetwork.Mask.Size()
+		state.wgNetwork = netip.PrefixFrom(addr.Unmap(), bits)
+	}
+	m.state.Store(state)
+}
+
+// deleteRule removes the rule from the packet filter, the caller must hold the lock
+func (m *Manager) deleteRule(r *Rule) error {
+	rules := m.outgoingRules
+	if r.direction == firewall.RuleDirectionIN {
+		rules = m.incomingRules
+	}
+
+	if _, ok := rules[r.ip.String()][r.id]; !ok {
+		return fmt.Errorf("delete rule: no rule with such id: %v", r.id)
+	}
+	delete(rules[r.ip.String()], r.id)
+	return nil
+}
+
+// ruleExists checks if the rule is in the packet filter or was added in the running transaction
+func (m *Manager) ruleExists(r *Rule) bool {
+	rules := m.outgoingRules
+	if r.direction == firewall.RuleDirectionIN {
+		rules = m.incomingRules
+	}
+	if _, ok := rules[r.ip.String()][r.id]; ok {
+		return true
+	}
+
+	for _, change := range m.txChanges {
+		if change.rule.id == r.id {
+			return !change.delete
+		}
+	}
+	return false
+}
+
 // DropOutgoing filter outgoing packets
 func (m *Manager) DropOutgoing(packetData []byte) bool {
-	return m.dropFilter(packetData, m.outgoingRules, false)
+	return m.dropFilter(m.state.Load(), packetData, false, "")
 }
 
 // DropIncoming filter incoming packets
 func (m *Manager) DropIncoming(packetData []byte) bool {
-	return m.dropFilter(packetData, m.incomingRules, true)
+	return m.dropFilter(m.state.Load(), packetData, true, "")
+}
+
+// DropOutgoingBatch filters a batch of outgoing packets, large batches are spread over the CPUs
+func (m *Manager) DropOutgoingBatch(packets [][]byte, drop []bool) {
+	m.filterBatch(packets, nil, drop, false)
 }
 
-// dropFilter implements same logic for booth direction of the traffic
-func (m *Manager) dropFilter(packetData []byte, rules map[string]RuleSet, isIncomingPacket bool) bool {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+// DropIncomingBatch filters a batch of incoming packets, large batches are spread over the CPUs
+func (m *Manager) DropIncomingBatch(packets [][]byte, drop []bool) {
+	m.filterBatch(packets, nil, drop, true)
+}
+
+// DropIncomingFromPeers filters a batch of incoming packets sent by the peers, peers[i] is the public key of the
+// peer which sent packets[i]. The packets using the address of another peer are dropped before the rules are
+// evaluated, so that the rules of an address only apply to the traffic of its peer
+func (m *Manager) DropIncomingFromPeers(packets [][]byte, peers []string, drop []bool) {
+	m.filterBatch(packets, peers, drop, true)
+}
 
+// dropFilter implements same logic for booth direction of the traffic, the peer which sent an incoming packet is
+// empty if it is unknown
+func (m *Manager) dropFilter(state *filterState, packetData []byte, isIncomingPacket bool, peer string) bool {
 	d := m.decoders.Get().(*decoder)
 	defer m.decoders.Put(d)
 
-	if err := d.parser.DecodeLayers(packetData, &d.decoded); err != nil {
+	if err := d.decode(packetData); err != nil {
 		log.Tracef("couldn't decode layer, err: %s", err)
 		return true
 	}
@@ -246,108 +439,146 @@ func (m *Manager) dropFilter(packetData []byte, rules map[string]RuleSet, isInco
 		return true
 	}
 
-	ipLayer := d.decoded[0]
-
-	switch ipLayer {
+	var src, dst netip.Addr
+	switch d.decoded[0] {
 	case layers.LayerTypeIPv4:
-		if !m.wgNetwork.Contains(d.ip4.SrcIP) || !m.wgNetwork.Contains(d.ip4.DstIP) {
-			return false
-		}
+		src, _ = netip.AddrFromSlice(d.ip4.SrcIP)
+		dst, _ = netip.AddrFromSlice(d.ip4.DstIP)
 	case layers.LayerTypeIPv6:
-		if !m.wgNetwork.Contains(d.ip6.SrcIP) || !m.wgNetwork.Contains(d.ip6.DstIP) {
-			return false
-		}
+		src, _ = netip.AddrFromSlice(d.ip6.SrcIP)
+		dst, _ = netip.AddrFromSlice(d.ip6.DstIP)
 	default:
 		log.Errorf("unknown layer: %v", d.decoded[0])
 		return true
 	}
+	src, dst = src.Unmap(), dst.Unmap()
 
-	var ip net.IP
-	switch ipLayer {
-	case layers.LayerTypeIPv4:
-		if isIncomingPacket {
-			ip = d.ip4.SrcIP
-		} else {
-			ip = d.ip4.DstIP
+	if isIncomingPacket && state.spoofed(src, peer) {
+		log.Tracef("dropping packet from %s sent by peer %s, the address belongs to another peer", src, peer)
+		if state.flows != nil {
+			state.flows.Add(flowKey(d, isIncomingPacket, true), len(packetData))
 		}
-	case layers.LayerTypeIPv6:
+		return true
+	}
+
+	if !isIncomingPacket && len(state.bandwidthLimits) > 0 && exceedsBandwidthLimit(state.bandwidthLimits, dst, len(packetData)) {
+		return true
+	}
+
+	if state.allowsLocalPort(d, isIncomingPacket) {
+		return false
+	}
+
+	// the verdict of the ICMPv6 control messages overrides the one of the rules, they are still matched against the
+	// rules so that the messages are accounted and logged like the other packets
+	icmpHandled, icmpDrop := icmpv6Verdict(d)
+
+	if !icmpHandled && (!state.wgNetwork.Contains(src) || !state.wgNetwork.Contains(dst)) {
+		// the traffic exchanged with the routed networks is accepted, unless the peer denies it by default. The
+		// forwarded traffic isn't filtered, like with the native firewalls
+		local := src
 		if isIncomingPacket {
-			ip = d.ip6.SrcIP
-		} else {
-			ip = d.ip6.DstIP
+			local = dst
+		}
+		if !state.defaultDeny || !state.wgNetwork.Contains(local) {
+			return false
 		}
 	}
 
-	filter, ok := validateRule(ip, packetData, rules[ip.String()], d)
-	if ok {
-		return filter
+	var drop bool
+	var rule *Rule
+	if isIncomingPacket {
+		drop, rule = state.incoming.drop(src, packetData, d)
+	} else {
+		drop, rule = state.outgoing.drop(dst, packetData, d)
+	}
+	if icmpHandled {
+		drop = icmpDrop
+	}
+	state.logPacket(rule, d, isIncomingPacket, drop)
+
+	if state.flows != nil {
+		state.flows.Add(flowKey(d, isIncomingPacket, drop), len(packetData))
 	}
-	filter, ok = validateRule(ip, packetData, rules["0.0.0.0"], d)
-	if ok {
-		return filter
+	return drop
+}
+
+// flowKey builds the flow key of a decoded packet
+func flowKey(d *decoder, isIncomingPacket bool, drop bool) flow.Key {
+	key := flow.Key{Direction: flow.Egress, Dropped: drop}
+	if isIncomingPacket {
+		key.Direction = flow.Ingress
 	}
-	filter, ok = validateRule(ip, packetData, rules["::"], d)
-	if ok {
-		return filter
+
+	switch d.decoded[0] {
+	case layers.LayerTypeIPv4:
+		key.SrcIP, _ = netip.AddrFromSlice(d.ip4.SrcIP.To4())
+		key.DstIP, _ = netip.AddrFromSlice(d.ip4.DstIP.To4())
+		key.Protocol = uint8(d.ip4.Protocol)
+	case layers.LayerTypeIPv6:
+		key.SrcIP, _ = netip.AddrFromSlice(d.ip6.SrcIP)
+		key.DstIP, _ = netip.AddrFromSlice(d.ip6.DstIP)
+		key.Protocol = uint8(d.ip6.NextHeader)
 	}
 
-	// default policy is DROP ALL
-	return true
+	switch d.decoded[1] {
+	case layers.LayerTypeTCP:
+		key.SrcPort, key.DstPort = uint16(d.tcp.SrcPort), uint16(d.tcp.DstPort)
+	case layers.LayerTypeUDP:
+		key.SrcPort, key.DstPort = uint16(d.udp.SrcPort), uint16(d.udp.DstPort)
+	case layers.LayerTypeSCTP:
+		key.SrcPort, key.DstPort = d.sctp.SrcPort, d.sctp.DstPort
+	}
+	return key
 }
 
-func validateRule(ip net.IP, packetData []byte, rules map[string]Rule, d *decoder) (bool, bool) {
-	payloadLayer := d.decoded[1]
-	for _, rule := range rules {
-		if rule.matchByIP && !ip.Equal(rule.ip) {
-			continue
-		}
+// SetFlowAggregator enables the accounting of the filtered packets per flow. Nil disables it
+func (m *Manager) SetFlowAggregator(aggregator *flow.Aggregator) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.flows = aggregator
+	m.updateState()
+}
 
-		if rule.protoLayer == layerTypeAll {
-			return rule.drop, true
-		}
+// SetPeerIdentities replaces the public keys of the peers by address. The incoming packets from these addresses are
+// dropped unless the WireGuard device attributes them to the same peer, nil disables the check
+func (m *Manager) SetPeerIdentities(peers map[netip.Addr]string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.peerIdentities = peers
+	m.updateState()
+}
 
-		if payloadLayer != rule.protoLayer {
-			continue
-		}
+// SetDefaultDeny makes the filter drop the traffic exchanged with the routed networks unless a rule accepts it. The
+// native firewall isn't changed, it accepts the traffic of the interface filtered here
+func (m *Manager) SetDefaultDeny(enabled bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.defaultDeny = enabled
+	m.updateState()
+	return nil
+}
 
-		switch payloadLayer {
-		case layers.LayerTypeTCP:
-			if rule.sPort == 0 && rule.dPort == 0 {
-				return rule.drop, true
-			}
-			if rule.sPort != 0 && rule.sPort == uint16(d.tcp.SrcPort) {
-				return rule.drop, true
-			}
-			if rule.dPort != 0 && rule.dPort == uint16(d.tcp.DstPort) {
-				return rule.drop, true
-			}
-		case layers.LayerTypeUDP:
-			// if rule has UDP hook (and if we are here we match this rule)
-			// we ignore rule.drop and call this hook
-			if rule.udpHook != nil {
-				return rule.udpHook(packetData), true
-			}
+// SetAllowedLocalPorts replaces the local ports accepted before the rules are evaluated
+func (m *Manager) SetAllowedLocalPorts(ports []firewall.LocalPort) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-			if rule.sPort == 0 && rule.dPort == 0 {
-				return rule.drop, true
-			}
-			if rule.sPort != 0 && rule.sPort == uint16(d.udp.SrcPort) {
-				return rule.drop, true
-			}
-			if rule.dPort != 0 && rule.dPort == uint16(d.udp.DstPort) {
-				return rule.drop, true
-			}
-			return rule.drop, true
-		case layers.LayerTypeICMPv4, layers.LayerTypeICMPv6:
-			return rule.drop, true
-		}
+	localPorts := make(map[firewall.LocalPort]struct{}, len(ports))
+	for _, port := range ports {
+		localPorts[port] = struct{}{}
 	}
-	return false, false
+	m.localPorts = localPorts
+	m.updateState()
+	return nil
 }
 
 // SetNetwork of the wireguard interface to which filtering applied
 func (m *Manager) SetNetwork(network *net.IPNet) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 	m.wgNetwork = network
+	m.updateState()
 }
 
 // AddUDPPacketHook calls hook when UDP packet from given direction matched
@@ -371,32 +602,30 @@ func (m *Manager) AddUDPPacketHook(
 		r.ipLayer = layers.LayerTypeIPv4
 	}
 
-	m.mutex.Lock()
 	if in {
 		r.direction = firewall.RuleDirectionIN
-		if _, ok := m.incomingRules[r.ip.String()]; !ok {
-			m.incomingRules[r.ip.String()] = make(map[string]Rule)
-		}
-		m.incomingRules[r.ip.String()][r.id] = r
-	} else {
-		if _, ok := m.outgoingRules[r.ip.String()]; !ok {
-			m.outgoingRules[r.ip.String()] = make(map[string]Rule)
-		}
-		m.outgoingRules[r.ip.String()][r.id] = r
 	}
 
+	m.mutex.Lock()
+	m.addRule(r)
+	m.updateState()
 	m.mutex.Unlock()
 
 	return r.id
 }
 
 // RemovePacketHook removes packet hook by given ID
+//
+// Hooks are not part of transactions and are removed right away
 func (m *Manager) RemovePacketHook(hookID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
 	for _, arr := range m.incomingRules {
 		for _, r := range arr {
 			if r.id == hookID {
 				rule := r
-				return m.DeleteRule(&rule)
+				return m.deleteHook(&rule)
 			}
 		}
 	}
@@ -404,9 +633,18 @@ func (m *Manager) RemovePacketHook(hookID string) error {
 		for _, r := range arr {
 			if r.id == hookID {
 				rule := r
-				return m.DeleteRule(&rule)
+				return m.deleteHook(&rule)
 			}
 		}
 	}
 	return fmt.Errorf("hook with given id not found")
 }
+
+// deleteHook removes the hook rule and applies the change, the caller must hold the lock
+func (m *Manager) deleteHook(r *Rule) error {
+	if err := m.deleteRule(r); err != nil {
+		return err
+	}
+	m.updateState()
+	return nil
+}
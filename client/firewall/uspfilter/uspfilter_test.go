@@ -1,8 +1,11 @@
 package uspfilter
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -10,6 +13,7 @@ import (
 	"github.com/google/gopacket/layers"
 	"github.com/stretchr/testify/require"
 
+	"github.com/FlintyLemming/netbird/client/firewall/flow"
 	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/iface"
 )
@@ -154,6 +158,44 @@ func TestManagerDeleteRule(t *testing.T) {
 	}
 }
 
+func TestManagerTransaction(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+
+	ip := net.ParseIP("192.168.1.1")
+	port := &fw.Port{Values: []int{80}}
+
+	existing, err := m.AddFiltering(ip, fw.ProtocolTCP, nil, port, fw.RuleDirectionIN, fw.ActionAccept, "", "existing")
+	require.NoError(t, err)
+
+	require.NoError(t, m.BeginTx())
+	require.ErrorIs(t, m.BeginTx(), fw.ErrTxInProgress)
+
+	added, err := m.AddFiltering(ip, fw.ProtocolUDP, nil, port, fw.RuleDirectionIN, fw.ActionAccept, "", "added")
+	require.NoError(t, err)
+	require.NoError(t, m.DeleteRule(existing[0]))
+
+	// changes are not visible before commit
+	require.Len(t, m.incomingRules[ip.String()], 1)
+	require.Contains(t, m.incomingRules[ip.String()], existing[0].GetRuleID())
+
+	require.NoError(t, m.Commit())
+	require.Len(t, m.incomingRules[ip.String()], 1)
+	require.Contains(t, m.incomingRules[ip.String()], added[0].GetRuleID())
+
+	require.NoError(t, m.BeginTx())
+	_, err = m.AddFiltering(ip, fw.ProtocolTCP, nil, port, fw.RuleDirectionOUT, fw.ActionAccept, "", "discarded")
+	require.NoError(t, err)
+	require.NoError(t, m.Rollback())
+
+	require.Empty(t, m.outgoingRules[ip.String()], "rolled back rule should not be applied")
+	require.ErrorIs(t, m.Commit(), fw.ErrNoTx)
+}
+
 func TestAddUDPPacketHook(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -324,7 +366,7 @@ func TestNotMatchByIP(t *testing.T) {
 		return
 	}
 
-	if m.dropFilter(buf.Bytes(), m.outgoingRules, false) {
+	if m.DropOutgoing(buf.Bytes()) {
 		t.Errorf("expected packet to be accepted")
 		return
 	}
@@ -335,6 +377,393 @@ func TestNotMatchByIP(t *testing.T) {
 	}
 }
 
+func TestManagerDefaultDeny(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	packet := func(src, dst string) []byte {
+		ipv4 := &layers.IPv4{
+			TTL:      64,
+			Version:  4,
+			SrcIP:    net.ParseIP(src),
+			DstIP:    net.ParseIP(dst),
+			Protocol: layers.IPProtocolUDP,
+		}
+		udp := &layers.UDP{SrcPort: 51334, DstPort: 53}
+		require.NoError(t, udp.SetNetworkLayerForChecksum(ipv4))
+
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, udp, gopacket.Payload("test")))
+		return buf.Bytes()
+	}
+
+	toRoutedNetwork := packet("100.10.0.1", "192.168.1.10")
+	fromRoutedNetwork := packet("192.168.1.10", "100.10.0.1")
+	forwarded := packet("192.168.2.10", "192.168.1.10")
+
+	require.False(t, m.DropOutgoing(toRoutedNetwork), "traffic to a routed network should be accepted by default")
+	require.False(t, m.DropIncoming(fromRoutedNetwork), "traffic from a routed network should be accepted by default")
+
+	require.NoError(t, m.SetDefaultDeny(true))
+	require.True(t, m.DropOutgoing(toRoutedNetwork), "traffic to a routed network should be dropped without rule")
+	require.True(t, m.DropIncoming(fromRoutedNetwork), "traffic from a routed network should be dropped without rule")
+	require.False(t, m.DropOutgoing(forwarded), "forwarded traffic shouldn't be filtered")
+
+	_, err = m.AddFiltering(net.ParseIP("0.0.0.0"), fw.ProtocolUDP, nil, nil, fw.RuleDirectionOUT, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	require.False(t, m.DropOutgoing(toRoutedNetwork), "traffic accepted by a rule should pass")
+	require.True(t, m.DropIncoming(fromRoutedNetwork), "traffic of the other direction should still be dropped")
+
+	require.NoError(t, m.SetDefaultDeny(false))
+	require.False(t, m.DropIncoming(fromRoutedNetwork), "traffic from a routed network should be accepted again")
+}
+
+func TestManagerPortRange(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	packet := func(dstPort layers.TCPPort) []byte {
+		ipv4 := &layers.IPv4{
+			TTL:      64,
+			Version:  4,
+			SrcIP:    net.ParseIP("100.10.0.1"),
+			DstIP:    net.ParseIP("100.10.0.100"),
+			Protocol: layers.IPProtocolTCP,
+		}
+		tcp := &layers.TCP{SrcPort: 51334, DstPort: dstPort, SYN: true}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ipv4))
+
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, tcp, gopacket.Payload("test")))
+		return buf.Bytes()
+	}
+
+	port := &fw.Port{IsRange: true, Values: []int{8000, 8080}}
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.100"), fw.ProtocolTCP, nil, port, fw.RuleDirectionOUT, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+
+	require.False(t, m.DropOutgoing(packet(8000)), "the first port of the range should be accepted")
+	require.False(t, m.DropOutgoing(packet(8042)), "a port in the range should be accepted")
+	require.False(t, m.DropOutgoing(packet(8080)), "the last port of the range should be accepted")
+	require.True(t, m.DropOutgoing(packet(8081)), "a port after the range should be dropped")
+	require.True(t, m.DropOutgoing(packet(7999)), "a port before the range should be dropped")
+}
+
+func TestManagerAllowedLocalPorts(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	packet := func(src, dst string, srcPort, dstPort layers.TCPPort) []byte {
+		ipv4 := &layers.IPv4{
+			TTL:      64,
+			Version:  4,
+			SrcIP:    net.ParseIP(src),
+			DstIP:    net.ParseIP(dst),
+			Protocol: layers.IPProtocolTCP,
+		}
+		tcp := &layers.TCP{SrcPort: srcPort, DstPort: dstPort}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ipv4))
+
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, tcp, gopacket.Payload("test")))
+		return buf.Bytes()
+	}
+
+	request := packet("100.10.0.2", "100.10.0.1", 40000, 9100)
+	reply := packet("100.10.0.1", "100.10.0.2", 9100, 40000)
+	otherPort := packet("100.10.0.2", "100.10.0.1", 40000, 9200)
+
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolALL, nil, nil, fw.RuleDirectionIN, fw.ActionDrop, "", "")
+	require.NoError(t, err)
+	require.True(t, m.DropIncoming(request), "traffic should be dropped by the rule")
+
+	require.NoError(t, m.SetAllowedLocalPorts([]fw.LocalPort{{Protocol: fw.ProtocolTCP, Port: 9100}}))
+	require.False(t, m.DropIncoming(request), "traffic to an allowed local port should be accepted before the rules")
+	require.False(t, m.DropOutgoing(reply), "traffic from an allowed local port should be accepted")
+	require.True(t, m.DropIncoming(otherPort), "traffic to another port should still be dropped")
+
+	require.NoError(t, m.SetAllowedLocalPorts(nil))
+	require.True(t, m.DropIncoming(request), "traffic should be dropped once the port isn't allowed anymore")
+}
+
+func TestManagerICMPv6(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("fd00:1234::"),
+		Mask: net.CIDRMask(64, 128),
+	})
+
+	serialize := func(ipv6 *layers.IPv6, payload ...gopacket.SerializableLayer) []byte {
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, append([]gopacket.SerializableLayer{ipv6}, payload...)...))
+		return buf.Bytes()
+	}
+	icmp := func(src, dst string, hopLimit uint8, typ uint8) []byte {
+		ipv6 := &layers.IPv6{
+			Version:    6,
+			HopLimit:   hopLimit,
+			NextHeader: layers.IPProtocolICMPv6,
+			SrcIP:      net.ParseIP(src),
+			DstIP:      net.ParseIP(dst),
+		}
+		icmp6 := &layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(typ, 0)}
+		require.NoError(t, icmp6.SetNetworkLayerForChecksum(ipv6))
+		return serialize(ipv6, icmp6, gopacket.Payload(make([]byte, 20)))
+	}
+	tcp := func(src, dst string) []byte {
+		ipv6 := &layers.IPv6{
+			Version:    6,
+			HopLimit:   64,
+			NextHeader: layers.IPProtocolTCP,
+			SrcIP:      net.ParseIP(src),
+			DstIP:      net.ParseIP(dst),
+		}
+		tcp := &layers.TCP{SrcPort: 51334, DstPort: 22, SYN: true}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ipv6))
+		return serialize(ipv6, tcp)
+	}
+
+	echo := icmp("fd00:1234::2", "fd00:1234::1", 64, layers.ICMPv6TypeEchoRequest)
+	require.True(t, m.DropIncoming(echo), "an echo request should be dropped without rule")
+	require.True(t, m.DropIncoming(tcp("fd00:1234::2", "fd00:1234::1")), "IPv6 traffic should be dropped without rule")
+
+	require.False(t, m.DropIncoming(icmp("fd00:1234::2", "fd00:1234::1", 64, layers.ICMPv6TypePacketTooBig)),
+		"packet too big should be accepted for the path MTU discovery")
+	require.False(t, m.DropOutgoing(icmp("fd00:1234::1", "fd00:1234::2", 64, layers.ICMPv6TypeDestinationUnreachable)),
+		"the error messages should be accepted")
+	require.False(t, m.DropIncoming(icmp("fe80::2", "ff02::1:ff00:1", 255, layers.ICMPv6TypeNeighborSolicitation)),
+		"a neighbor solicitation should be accepted")
+	require.False(t, m.DropOutgoing(icmp("fe80::1", "fe80::2", 255, layers.ICMPv6TypeNeighborAdvertisement)),
+		"a neighbor advertisement should be accepted")
+	require.True(t, m.DropIncoming(icmp("fe80::2", "fe80::1", 64, layers.ICMPv6TypeRouterAdvertisement)),
+		"a forwarded neighbor discovery message should be dropped")
+
+	var logged []fw.PacketLog
+	m.SetPacketLogger(func(entry fw.PacketLog) {
+		logged = append(logged, entry)
+	})
+	exporter := &recordingExporter{}
+	aggregator := flow.NewAggregator(exporter, time.Hour)
+	m.SetFlowAggregator(aggregator)
+	_, err = m.AddFiltering(net.ParseIP("fd00:1234::3"), fw.ProtocolICMP, nil, nil, fw.RuleDirectionIN, fw.ActionLogDrop, "", "")
+	require.NoError(t, err)
+	require.False(t, m.DropIncoming(icmp("fd00:1234::3", "fd00:1234::1", 64, layers.ICMPv6TypePacketTooBig)),
+		"packet too big should be accepted over the rules")
+	require.Len(t, logged, 1, "the control message should be logged by the matching rule")
+	require.False(t, logged[0].Dropped, "the control message should be logged as accepted")
+	aggregator.Start(context.Background())
+	aggregator.Stop()
+	require.Len(t, exporter.records, 1, "the control message should be accounted")
+	require.False(t, exporter.records[0].Dropped)
+	require.Equal(t, uint8(layers.IPProtocolICMPv6), exporter.records[0].Protocol)
+	m.SetPacketLogger(nil)
+	m.SetFlowAggregator(nil)
+
+	_, err = m.AddFiltering(net.ParseIP("fd00:1234::2"), fw.ProtocolICMP, nil, nil, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	_, err = m.AddFiltering(net.ParseIP("fd00:1234::2"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{22}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	require.False(t, m.DropIncoming(echo), "an echo request should be accepted by an ICMP rule")
+	require.False(t, m.DropIncoming(tcp("fd00:1234::2", "fd00:1234::1")), "IPv6 traffic should be accepted by a rule")
+}
+
+type recordingExporter struct {
+	records []flow.Record
+}
+
+func (e *recordingExporter) Export(records []flow.Record) error {
+	e.records = append(e.records, records...)
+	return nil
+}
+
+func (e *recordingExporter) Close() error {
+	return nil
+}
+
+func TestManagerSCTPAndGRE(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	packet := func(protocol layers.IPProtocol, payload []byte) []byte {
+		ipv4 := &layers.IPv4{
+			TTL:      64,
+			Version:  4,
+			SrcIP:    net.ParseIP("100.10.0.2"),
+			DstIP:    net.ParseIP("100.10.0.1"),
+			Protocol: protocol,
+		}
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, gopacket.Payload(payload)))
+		return buf.Bytes()
+	}
+	sctp := func(dstPort uint16) []byte {
+		header := make([]byte, sctpHeaderLen)
+		binary.BigEndian.PutUint16(header[0:2], 40000)
+		binary.BigEndian.PutUint16(header[2:4], dstPort)
+		return packet(layers.IPProtocolSCTP, header)
+	}
+	gre := packet(layers.IPProtocolGRE, []byte{0, 0, 0x08, 0x00})
+
+	require.True(t, m.DropIncoming(sctp(3868)), "SCTP should be dropped without rule")
+	require.True(t, m.DropIncoming(gre), "GRE should be dropped without rule")
+
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolSCTP, nil, &fw.Port{Values: []int{3868}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	require.False(t, m.DropIncoming(sctp(3868)), "SCTP to the port of the rule should be accepted")
+	require.True(t, m.DropIncoming(sctp(3869)), "SCTP to another port should be dropped")
+	require.True(t, m.DropIncoming(gre), "GRE should not be accepted by an SCTP rule")
+
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolGRE, nil, nil, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+	require.False(t, m.DropIncoming(gre), "GRE should be accepted by a GRE rule")
+}
+
+func TestManagerPeerIdentities(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	ipv4 := &layers.IPv4{
+		TTL:      64,
+		Version:  4,
+		SrcIP:    net.ParseIP("100.10.0.2"),
+		DstIP:    net.ParseIP("100.10.0.1"),
+		Protocol: layers.IPProtocolUDP,
+	}
+	udp := &layers.UDP{SrcPort: 51334, DstPort: 53}
+	require.NoError(t, udp.SetNetworkLayerForChecksum(ipv4))
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+	require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, udp, gopacket.Payload("query")))
+	packet := buf.Bytes()
+
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolUDP, nil, &fw.Port{Values: []int{53}}, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+
+	filter := func(peer string) bool {
+		drop := []bool{false}
+		m.DropIncomingFromPeers([][]byte{packet}, []string{peer}, drop)
+		return drop[0]
+	}
+	require.False(t, filter("router"), "the address should not be checked without the peer identities")
+
+	m.SetPeerIdentities(map[netip.Addr]string{netip.MustParseAddr("100.10.0.2"): "peerA"})
+	require.False(t, filter("peerA"), "the packet of the peer owning the address should be accepted by the rule")
+	require.True(t, filter("router"), "the packet using the address of another peer should be dropped")
+	require.False(t, filter(""), "the packet of an unknown peer should only be checked by the rules")
+	require.False(t, m.DropIncoming(packet))
+}
+
+func TestManagerLogAction(t *testing.T) {
+	ifaceMock := &IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	}
+
+	m, err := Create(ifaceMock)
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	var logged []fw.PacketLog
+	m.SetPacketLogger(func(entry fw.PacketLog) {
+		logged = append(logged, entry)
+	})
+
+	packet := func(src string, dstPort layers.TCPPort) []byte {
+		ipv4 := &layers.IPv4{
+			TTL:      64,
+			Version:  4,
+			SrcIP:    net.ParseIP(src),
+			DstIP:    net.ParseIP("100.10.0.1"),
+			Protocol: layers.IPProtocolTCP,
+		}
+		tcp := &layers.TCP{SrcPort: 40000, DstPort: dstPort, SYN: true}
+		require.NoError(t, tcp.SetNetworkLayerForChecksum(ipv4))
+
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{ComputeChecksums: true, FixLengths: true}
+		require.NoError(t, gopacket.SerializeLayers(buf, opts, ipv4, tcp, gopacket.Payload("test")))
+		return buf.Bytes()
+	}
+
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{22}}, fw.RuleDirectionIN, fw.ActionLogDrop, "", "")
+	require.NoError(t, err)
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.2"), fw.ProtocolTCP, nil, &fw.Port{Values: []int{80}}, fw.RuleDirectionIN, fw.ActionLogAccept, "", "")
+	require.NoError(t, err)
+	_, err = m.AddFiltering(net.ParseIP("100.10.0.3"), fw.ProtocolALL, nil, nil, fw.RuleDirectionIN, fw.ActionAccept, "", "")
+	require.NoError(t, err)
+
+	require.True(t, m.DropIncoming(packet("100.10.0.2", 22)), "the packet should be dropped by the logged rule")
+	require.False(t, m.DropIncoming(packet("100.10.0.2", 80)), "the packet should be accepted by the logged rule")
+	require.False(t, m.DropIncoming(packet("100.10.0.3", 22)), "the packet of a rule which isn't logged should be accepted")
+
+	require.Len(t, logged, 2, "only the packets matched by the logged rules should be logged")
+	require.True(t, logged[0].Dropped)
+	require.Equal(t, fw.RuleDirectionIN, logged[0].Direction)
+	require.Equal(t, fw.ProtocolTCP, logged[0].Protocol)
+	require.Equal(t, "100.10.0.2", logged[0].SrcIP.String())
+	require.Equal(t, uint16(22), logged[0].DstPort)
+	require.False(t, logged[1].Dropped)
+	require.Equal(t, "dropped tcp packet 100.10.0.2:40000 -> 100.10.0.1:22", logged[0].String())
+
+	logged = nil
+	for i := 0; i < fw.LogBurst*2; i++ {
+		require.True(t, m.DropIncoming(packet("100.10.0.2", 22)))
+	}
+	require.LessOrEqual(t, len(logged), fw.LogBurst+1, "the logged packets should be rate limited")
+}
+
 // TestRemovePacketHook tests the functionality of the RemovePacketHook method
 func TestRemovePacketHook(t *testing.T) {
 	// creating mock iface
@@ -0,0 +1,118 @@
+package uspfilter
+
+import (
+	"runtime"
+	"sync"
+)
+
+const (
+	// minParallelBatch is the smallest batch spread over the workers, smaller batches are filtered by the caller as
+	// handing the packets over costs more than filtering them
+	minParallelBatch = 32
+	// minChunk is the smallest number of packets handed over to a worker
+	minChunk = 8
+)
+
+// workers filter the packets of large batches, one per CPU. They are shared by the managers and started with the
+// first large batch
+var workers struct {
+	once  sync.Once
+	count int
+	jobs  chan filterJob
+}
+
+// waitGroups are reused by the batches waiting for their chunks
+var waitGroups = sync.Pool{
+	New: func() any {
+		return &sync.WaitGroup{}
+	},
+}
+
+// filterJob is a chunk of a batch filtered with the state loaded for the whole batch
+type filterJob struct {
+	manager  *Manager
+	state    *filterState
+	packets  [][]byte
+	drop     []bool
+	incoming bool
+	done     *sync.WaitGroup
+
+	// peers are the peers which sent the incoming packets, nil if they are unknown
+	peers []string
+}
+
+func (j *filterJob) run() {
+	for i, packet := range j.packets {
+		var peer string
+		if j.peers != nil {
+			peer = j.peers[i]
+		}
+		j.drop[i] = j.manager.dropFilter(j.state, packet, j.incoming, peer)
+	}
+}
+
+func startWorkers() {
+	workers.count = runtime.NumCPU()
+	workers.jobs = make(chan filterJob, workers.count)
+	for i := 0; i < workers.count; i++ {
+		go func() {
+			for job := range workers.jobs {
+				job.run()
+				job.done.Done()
+			}
+		}()
+	}
+}
+
+// filterBatch sets drop[i] for the packets[i] to drop, peers[i] is the peer which sent the packet if peers isn't nil.
+// Large batches are split in chunks filtered in parallel by the workers and the caller
+func (m *Manager) filterBatch(packets [][]byte, peers []string, drop []bool, incoming bool) {
+	job := filterJob{
+		manager:  m,
+		state:    m.state.Load(),
+		packets:  packets,
+		peers:    peers,
+		drop:     drop,
+		incoming: incoming,
+	}
+	if len(packets) < minParallelBatch || runtime.GOMAXPROCS(0) == 1 {
+		job.run()
+		return
+	}
+
+	workers.once.Do(startWorkers)
+
+	// the caller filters a chunk as well
+	parts := workers.count + 1
+	size := (len(packets) + parts - 1) / parts
+	if size < minChunk {
+		size = minChunk
+	}
+
+	done := waitGroups.Get().(*sync.WaitGroup)
+	defer waitGroups.Put(done)
+
+	for start := size; start < len(packets); start += size {
+		end := start + size
+		if end > len(packets) {
+			end = len(packets)
+		}
+		chunk := job
+		chunk.packets = packets[start:end]
+		if peers != nil {
+			chunk.peers = peers[start:end]
+		}
+		chunk.drop = drop[start:end]
+		chunk.done = done
+		done.Add(1)
+		workers.jobs <- chunk
+	}
+
+	job.packets = packets[:size]
+	if peers != nil {
+		job.peers = peers[:size]
+	}
+	job.drop = drop[:size]
+	job.run()
+	done.Wait()
+}
@@ -0,0 +1,86 @@
+package uspfilter
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	fw "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func batchTestManager(t testing.TB) *Manager {
+	t.Helper()
+
+	m, err := Create(&IFaceMock{
+		SetFilterFunc: func(iface.PacketFilter) error { return nil },
+	})
+	require.NoError(t, err)
+	m.SetNetwork(&net.IPNet{
+		IP:   net.ParseIP("100.10.0.0"),
+		Mask: net.CIDRMask(16, 32),
+	})
+
+	for i := 0; i < 100; i++ {
+		peer := net.ParseIP(fmt.Sprintf("100.10.1.%d", i))
+		_, err = m.AddFiltering(peer, fw.ProtocolUDP, nil, nil, fw.RuleDirectionOUT, fw.ActionAccept, "", "allow peer")
+		require.NoError(t, err)
+	}
+	return m
+}
+
+func TestManagerDropOutgoingBatch(t *testing.T) {
+	m := batchTestManager(t)
+
+	for _, size := range []int{1, minParallelBatch - 1, minParallelBatch, 128, 1000} {
+		t.Run(fmt.Sprintf("batch of %d", size), func(t *testing.T) {
+			packets := make([][]byte, size)
+			for i := range packets {
+				// every other packet goes to a peer without rules
+				packets[i] = udpPacket(t, "100.10.0.1", fmt.Sprintf("100.10.%d.%d", i%2+1, i%100), 100)
+			}
+
+			drop := make([]bool, size)
+			m.DropOutgoingBatch(packets, drop)
+
+			for i, packet := range packets {
+				assert.Equal(t, m.DropOutgoing(packet), drop[i], "verdict of packet %d", i)
+				assert.Equal(t, i%2 == 1, drop[i], "verdict of packet %d", i)
+			}
+		})
+	}
+}
+
+func BenchmarkManagerDropOutgoing(b *testing.B) {
+	m := batchTestManager(b)
+	packet := udpPacket(b, "100.10.0.1", "100.10.1.50", 1300)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(packet)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.DropOutgoing(packet)
+	}
+}
+
+func BenchmarkManagerDropOutgoingBatch(b *testing.B) {
+	m := batchTestManager(b)
+	packet := udpPacket(b, "100.10.0.1", "100.10.1.50", 1300)
+
+	const batchSize = 128
+	packets := make([][]byte, batchSize)
+	for i := range packets {
+		packets[i] = packet
+	}
+	drop := make([]bool, batchSize)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(packet) * batchSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.DropOutgoingBatch(packets, drop)
+	}
+}
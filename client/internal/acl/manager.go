@@ -5,14 +5,15 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"net/netip"
 	"strconv"
 	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	nbacl "github.com/FlintyLemming/netbird/acl"
 	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
-	"github.com/FlintyLemming/netbird/client/ssh"
 	mgmProto "github.com/FlintyLemming/netbird/management/proto"
 )
 
@@ -59,55 +60,34 @@ func (d *DefaultManager) ApplyFiltering(networkMap *mgmProto.NetworkMap) {
 		return
 	}
 
-	defer func() {
-		if err := d.firewall.Flush(); err != nil {
-			log.Error("failed to flush firewall rules: ", err)
-		}
-	}()
-
-	rules, squashedProtocols := d.squashAcceptRules(networkMap)
-
-	enableSSH := (networkMap.PeerConfig != nil &&
-		networkMap.PeerConfig.SshConfig != nil &&
-		networkMap.PeerConfig.SshConfig.SshEnabled)
-	if _, ok := squashedProtocols[mgmProto.FirewallRule_ALL]; ok {
-		enableSSH = enableSSH && !ok
-	}
-	if _, ok := squashedProtocols[mgmProto.FirewallRule_TCP]; ok {
-		enableSSH = enableSSH && !ok
+	// the userspace firewall checks that the traffic of the peer addresses is sent by these peers
+	if identities, ok := d.firewall.(interface {
+		SetPeerIdentities(peers map[netip.Addr]string)
+	}); ok {
+		identities.SetPeerIdentities(peerIdentities(networkMap))
 	}
 
-	// if TCP protocol rules not squashed and SSH enabled
-	// we add default firewall rule which accepts connection to any peer
-	// in the network by SSH (TCP 22 port).
-	if enableSSH {
-		rules = append(rules, &mgmProto.FirewallRule{
-			PeerIP:    "0.0.0.0",
-			Direction: mgmProto.FirewallRule_IN,
-			Action:    mgmProto.FirewallRule_ACCEPT,
-			Protocol:  mgmProto.FirewallRule_TCP,
-			Port:      strconv.Itoa(ssh.DefaultSSHPort),
-		})
+	// the rules are applied at once in a transaction, if the firewall doesn't support it they are applied one by one
+	txErr := d.firewall.BeginTx()
+	if txErr != nil {
+		log.Warnf("failed to start firewall transaction, applying rules one by one: %v", txErr)
+		defer func() {
+			if err := d.firewall.Flush(); err != nil {
+				log.Error("failed to flush firewall rules: ", err)
+			}
+		}()
 	}
 
 	// if we got empty rules list but management not set networkMap.FirewallRulesIsEmpty flag
 	// we have old version of management without rules handling, we should allow all traffic
-	if len(networkMap.FirewallRules) == 0 && !networkMap.FirewallRulesIsEmpty {
+	if nbacl.FromLegacyManagement(networkMap) {
 		log.Warn("this peer is connected to a NetBird Management service with an older version. Allowing all traffic from connected peers")
-		rules = append(rules,
-			&mgmProto.FirewallRule{
-				PeerIP:    "0.0.0.0",
-				Direction: mgmProto.FirewallRule_IN,
-				Action:    mgmProto.FirewallRule_ACCEPT,
-				Protocol:  mgmProto.FirewallRule_ALL,
-			},
-			&mgmProto.FirewallRule{
-				PeerIP:    "0.0.0.0",
-				Direction: mgmProto.FirewallRule_OUT,
-				Action:    mgmProto.FirewallRule_ACCEPT,
-				Protocol:  mgmProto.FirewallRule_ALL,
-			},
-		)
+	}
+	rules := nbacl.NetworkMapRules(networkMap)
+
+	previousRulePairs := make(map[string][]firewall.Rule, len(d.rulesPairs))
+	for pairID, rules := range d.rulesPairs {
+		previousRulePairs[pairID] = rules
 	}
 
 	newRulePairs := make(map[string][]firewall.Rule)
@@ -126,8 +106,15 @@ func (d *DefaultManager) ApplyFiltering(networkMap *mgmProto.NetworkMap) {
 		pairID, rulePair, err := d.protoRuleToFirewallRule(r, ipsetName)
 		if err != nil {
 			log.Errorf("failed to apply firewall rule: %+v, %v", r, err)
-			d.rollBack(newRulePairs)
-			break
+			if txErr != nil {
+				d.rollBack(newRulePairs)
+				break
+			}
+			if err := d.firewall.Rollback(); err != nil {
+				log.Errorf("failed to roll back firewall transaction: %v", err)
+			}
+			d.rulesPairs = previousRulePairs
+			return
 		}
 		if len(rules) > 0 {
 			d.rulesPairs[pairID] = rulePair
@@ -146,6 +133,14 @@ func (d *DefaultManager) ApplyFiltering(networkMap *mgmProto.NetworkMap) {
 			delete(d.rulesPairs, pairID)
 		}
 	}
+
+	if txErr == nil {
+		if err := d.firewall.Commit(); err != nil {
+			log.Errorf("failed to commit firewall rules, keeping the previous rules: %v", err)
+			d.rulesPairs = previousRulePairs
+			return
+		}
+	}
 	d.rulesPairs = newRulePairs
 }
 
@@ -153,9 +148,9 @@ func (d *DefaultManager) protoRuleToFirewallRule(
 	r *mgmProto.FirewallRule,
 	ipsetName string,
 ) (string, []firewall.Rule, error) {
-	ip := net.ParseIP(r.PeerIP)
-	if ip == nil {
-		return "", nil, fmt.Errorf("invalid IP address, skipping firewall rule")
+	expanded, err := nbacl.ExpandRule(r)
+	if err != nil {
+		return "", nil, fmt.Errorf("skipping firewall rule: %s", err)
 	}
 
 	protocol, err := convertToFirewallProtocol(r.Protocol)
@@ -163,21 +158,14 @@ func (d *DefaultManager) protoRuleToFirewallRule(
 		return "", nil, fmt.Errorf("skipping firewall rule: %s", err)
 	}
 
-	action, err := convertFirewallAction(r.Action)
+	action, err := convertFirewallAction(r.Action, r.Log)
 	if err != nil {
 		return "", nil, fmt.Errorf("skipping firewall rule: %s", err)
 	}
 
-	var port *firewall.Port
-	if r.Port != "" {
-		value, err := strconv.Atoi(r.Port)
-		if err != nil {
-			return "", nil, fmt.Errorf("invalid port, skipping firewall rule")
-		}
-		port = &firewall.Port{
-			Values: []int{value},
-		}
-	}
+	// the first rule matches the packets in the direction of the firewall rule
+	ip := expanded[0].PeerIP
+	port := toFirewallPort(expanded[0].DstPort, expanded[0].DstPortEnd)
 
 	ruleID := d.getRuleID(ip, protocol, int(r.Direction), port, action, "")
 	if rulesPair, ok := d.rulesPairs[ruleID]; ok {
@@ -185,78 +173,49 @@ func (d *DefaultManager) protoRuleToFirewallRule(
 	}
 
 	var rules []firewall.Rule
-	switch r.Direction {
-	case mgmProto.FirewallRule_IN:
-		rules, err = d.addInRules(ip, protocol, port, action, ipsetName, "")
-	case mgmProto.FirewallRule_OUT:
-		rules, err = d.addOutRules(ip, protocol, port, action, ipsetName, "")
-	default:
-		return "", nil, fmt.Errorf("invalid direction, skipping firewall rule")
-	}
+	for _, e := range expanded {
+		direction := firewall.RuleDirectionIN
+		if e.Direction == mgmProto.FirewallRule_OUT {
+			direction = firewall.RuleDirectionOUT
+		}
 
-	if err != nil {
-		return "", nil, err
+		rule, err := d.firewall.AddFiltering(
+			e.PeerIP, protocol, toFirewallPort(e.SrcPort, e.SrcPortEnd), toFirewallPort(e.DstPort, e.DstPortEnd), direction, action, ipsetName, "")
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to add firewall rule: %v", err)
+		}
+		rules = append(rules, rule...)
 	}
 
 	return ruleID, rules, nil
 }
 
-func (d *DefaultManager) addInRules(
-	ip net.IP,
-	protocol firewall.Protocol,
-	port *firewall.Port,
-	action firewall.Action,
-	ipsetName string,
-	comment string,
-) ([]firewall.Rule, error) {
-	var rules []firewall.Rule
-	rule, err := d.firewall.AddFiltering(
-		ip, protocol, nil, port, firewall.RuleDirectionIN, action, ipsetName, comment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to add firewall rule: %v", err)
-	}
-	rules = append(rules, rule...)
-
-	if shouldSkipInvertedRule(protocol, port) {
-		return rules, nil
-	}
-
-	rule, err = d.firewall.AddFiltering(
-		ip, protocol, port, nil, firewall.RuleDirectionOUT, action, ipsetName, comment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to add firewall rule: %v", err)
+// peerIdentities returns the public keys of the remote peers, online or not, by address. Only the single addresses
+// of the allowed IPs are identities, the networks routed by the peers aren't
+func peerIdentities(networkMap *mgmProto.NetworkMap) map[netip.Addr]string {
+	identities := make(map[netip.Addr]string)
+	peers := append(append([]*mgmProto.RemotePeerConfig{}, networkMap.GetRemotePeers()...), networkMap.GetOfflinePeers()...)
+	for _, peer := range peers {
+		for _, allowedIP := range peer.GetAllowedIps() {
+			prefix, err := netip.ParsePrefix(allowedIP)
+			if err != nil || !prefix.IsSingleIP() {
+				continue
+			}
+			identities[prefix.Addr().Unmap()] = peer.GetWgPubKey()
+		}
 	}
-
-	return append(rules, rule...), nil
+	return identities
 }
 
-func (d *DefaultManager) addOutRules(
-	ip net.IP,
-	protocol firewall.Protocol,
-	port *firewall.Port,
-	action firewall.Action,
-	ipsetName string,
-	comment string,
-) ([]firewall.Rule, error) {
-	var rules []firewall.Rule
-	rule, err := d.firewall.AddFiltering(
-		ip, protocol, nil, port, firewall.RuleDirectionOUT, action, ipsetName, comment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to add firewall rule: %v", err)
-	}
-	rules = append(rules, rule...)
-
-	if shouldSkipInvertedRule(protocol, port) {
-		return rules, nil
+// toFirewallPort returns the port of a firewall rule, a range of ports when the end is set
+func toFirewallPort(port, end int) *firewall.Port {
+	if port == 0 {
+		return nil
 	}
-
-	rule, err = d.firewall.AddFiltering(
-		ip, protocol, port, nil, firewall.RuleDirectionIN, action, ipsetName, comment)
-	if err != nil {
-		return nil, fmt.Errorf("failed to add firewall rule: %v", err)
+	if end != 0 {
+		return &firewall.Port{IsRange: true, Values: []int{port, end}}
 	}
-
-	return append(rules, rule...), nil
+	return &firewall.Port{Values: []int{port}}
 }
 
 // getRuleID() returns unique ID for the rule based on its parameters.
@@ -276,140 +235,9 @@ func (d *DefaultManager) getRuleID(
 	return hex.EncodeToString(md5.New().Sum([]byte(idStr)))
 }
 
-// squashAcceptRules does complex logic to convert many rules which allows connection by traffic type
-// to all peers in the network map to one rule which just accepts that type of the traffic.
-//
-// NOTE: It will not squash two rules for same protocol if one covers all peers in the network,
-// but other has port definitions or has drop policy.
-func (d *DefaultManager) squashAcceptRules(
-	networkMap *mgmProto.NetworkMap,
-) ([]*mgmProto.FirewallRule, map[mgmProto.FirewallRuleProtocol]struct{}) {
-	totalIPs := 0
-	for _, p := range append(networkMap.RemotePeers, networkMap.OfflinePeers...) {
-		for range p.AllowedIps {
-			totalIPs++
-		}
-	}
-
-	type protoMatch map[mgmProto.FirewallRuleProtocol]map[string]int
-
-	in := protoMatch{}
-	out := protoMatch{}
-
-	// trace which type of protocols was squashed
-	squashedRules := []*mgmProto.FirewallRule{}
-	squashedProtocols := map[mgmProto.FirewallRuleProtocol]struct{}{}
-
-	// this function we use to do calculation, can we squash the rules by protocol or not.
-	// We summ amount of Peers IP for given protocol we found in original rules list.
-	// But we zeroed the IP's for protocol if:
-	// 1. Any of the rule has DROP action type.
-	// 2. Any of rule contains Port.
-	//
-	// We zeroed this to notify squash function that this protocol can't be squashed.
-	addRuleToCalculationMap := func(i int, r *mgmProto.FirewallRule, protocols protoMatch) {
-		drop := r.Action == mgmProto.FirewallRule_DROP || r.Port != ""
-		if drop {
-			protocols[r.Protocol] = map[string]int{}
-			return
-		}
-		if _, ok := protocols[r.Protocol]; !ok {
-			protocols[r.Protocol] = map[string]int{}
-		}
-
-		// special case, when we receive this all network IP address
-		// it means that rules for that protocol was already optimized on the
-		// management side
-		if r.PeerIP == "0.0.0.0" {
-			squashedRules = append(squashedRules, r)
-			squashedProtocols[r.Protocol] = struct{}{}
-			return
-		}
-
-		ipset := protocols[r.Protocol]
-
-		if _, ok := ipset[r.PeerIP]; ok {
-			return
-		}
-		ipset[r.PeerIP] = i
-	}
-
-	for i, r := range networkMap.FirewallRules {
-		// calculate squash for different directions
-		if r.Direction == mgmProto.FirewallRule_IN {
-			addRuleToCalculationMap(i, r, in)
-		} else {
-			addRuleToCalculationMap(i, r, out)
-		}
-	}
-
-	// order of squashing by protocol is important
-	// only for their first element ALL, it must be done first
-	protocolOrders := []mgmProto.FirewallRuleProtocol{
-		mgmProto.FirewallRule_ALL,
-		mgmProto.FirewallRule_ICMP,
-		mgmProto.FirewallRule_TCP,
-		mgmProto.FirewallRule_UDP,
-	}
-
-	squash := func(matches protoMatch, direction mgmProto.FirewallRuleDirection) {
-		for _, protocol := range protocolOrders {
-			if ipset, ok := matches[protocol]; !ok || len(ipset) != totalIPs || len(ipset) < 2 {
-				// don't squash if :
-				// 1. Rules not cover all peers in the network
-				// 2. Rules cover only one peer in the network.
-				continue
-			}
-
-			// add special rule 0.0.0.0 which allows all IP's in our firewall implementations
-			squashedRules = append(squashedRules, &mgmProto.FirewallRule{
-				PeerIP:    "0.0.0.0",
-				Direction: direction,
-				Action:    mgmProto.FirewallRule_ACCEPT,
-				Protocol:  protocol,
-			})
-			squashedProtocols[protocol] = struct{}{}
-
-			if protocol == mgmProto.FirewallRule_ALL {
-				// if we have ALL traffic type squashed rule
-				// it allows all other type of traffic, so we can stop processing
-				break
-			}
-		}
-	}
-
-	squash(in, mgmProto.FirewallRule_IN)
-	squash(out, mgmProto.FirewallRule_OUT)
-
-	// if all protocol was squashed everything is allow and we can ignore all other rules
-	if _, ok := squashedProtocols[mgmProto.FirewallRule_ALL]; ok {
-		return squashedRules, squashedProtocols
-	}
-
-	if len(squashedRules) == 0 {
-		return networkMap.FirewallRules, squashedProtocols
-	}
-
-	var rules []*mgmProto.FirewallRule
-	// filter out rules which was squashed from final list
-	// if we also have other not squashed rules.
-	for i, r := range networkMap.FirewallRules {
-		if _, ok := squashedProtocols[r.Protocol]; ok {
-			if m, ok := in[r.Protocol]; ok && m[r.PeerIP] == i {
-				continue
-			} else if m, ok := out[r.Protocol]; ok && m[r.PeerIP] == i {
-				continue
-			}
-		}
-		rules = append(rules, r)
-	}
-
-	return append(rules, squashedRules...), squashedProtocols
-}
-
 // getRuleGroupingSelector takes all rule properties except IP address to build selector
 func (d *DefaultManager) getRuleGroupingSelector(rule *mgmProto.FirewallRule) string {
-	return fmt.Sprintf("%v:%v:%v:%s", strconv.Itoa(int(rule.Direction)), rule.Action, rule.Protocol, rule.Port)
+	return fmt.Sprintf("%v:%v:%v:%s:%t", strconv.Itoa(int(rule.Direction)), rule.Action, rule.Protocol, rule.Port, rule.Log)
 }
 
 func (d *DefaultManager) rollBack(newRulePairs map[string][]firewall.Rule) {
@@ -431,6 +259,10 @@ func convertToFirewallProtocol(protocol mgmProto.FirewallRuleProtocol) (firewall
 		return firewall.ProtocolUDP, nil
 	case mgmProto.FirewallRule_ICMP:
 		return firewall.ProtocolICMP, nil
+	case mgmProto.FirewallRule_SCTP:
+		return firewall.ProtocolSCTP, nil
+	case mgmProto.FirewallRule_GRE:
+		return firewall.ProtocolGRE, nil
 	case mgmProto.FirewallRule_ALL:
 		return firewall.ProtocolALL, nil
 	default:
@@ -438,15 +270,16 @@ func convertToFirewallProtocol(protocol mgmProto.FirewallRuleProtocol) (firewall
 	}
 }
 
-func shouldSkipInvertedRule(protocol firewall.Protocol, port *firewall.Port) bool {
-	return protocol == firewall.ProtocolALL || protocol == firewall.ProtocolICMP || port == nil
-}
-
-func convertFirewallAction(action mgmProto.FirewallRuleAction) (firewall.Action, error) {
-	switch action {
-	case mgmProto.FirewallRule_ACCEPT:
+// convertFirewallAction returns the action of a rule, the logging variant of the action when the rule is logged
+func convertFirewallAction(action mgmProto.FirewallRuleAction, logged bool) (firewall.Action, error) {
+	switch {
+	case action == mgmProto.FirewallRule_ACCEPT && logged:
+		return firewall.ActionLogAccept, nil
+	case action == mgmProto.FirewallRule_ACCEPT:
 		return firewall.ActionAccept, nil
-	case mgmProto.FirewallRule_DROP:
+	case action == mgmProto.FirewallRule_DROP && logged:
+		return firewall.ActionLogDrop, nil
+	case action == mgmProto.FirewallRule_DROP:
 		return firewall.ActionDrop, nil
 	default:
 		return firewall.ActionDrop, fmt.Errorf("invalid action type: %d", action)
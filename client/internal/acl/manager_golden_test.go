@@ -0,0 +1,360 @@
+package acl
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	nbacl "github.com/FlintyLemming/netbird/acl"
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+	fwtest "github.com/FlintyLemming/netbird/client/firewall/test"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the ACL tests")
+
+// networkMapScenario generates the firewall rules of a network map with the given number of remote peers
+type networkMapScenario struct {
+	name     string
+	generate func(peers int) *mgmProto.NetworkMap
+}
+
+var networkMapScenarios = []networkMapScenario{
+	{
+		// all the traffic of all the peers is accepted, it is squashed to one rule per direction
+		name: "accept_all",
+		generate: func(peers int) *mgmProto.NetworkMap {
+			networkMap := generateRemotePeers(peers, 0)
+			for i := 0; i < peers; i++ {
+				networkMap.FirewallRules = append(networkMap.FirewallRules,
+					generateRule(i, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_ALL, ""),
+					generateRule(i, mgmProto.FirewallRule_OUT, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_ALL, ""),
+				)
+			}
+			return networkMap
+		},
+	},
+	{
+		// the TCP traffic of all the peers is accepted and squashed, a few peers have port and drop rules which
+		// can't be squashed
+		name: "mixed",
+		generate: func(peers int) *mgmProto.NetworkMap {
+			networkMap := generateRemotePeers(peers, 0)
+			networkMap.PeerConfig = &mgmProto.PeerConfig{SshConfig: &mgmProto.SSHConfig{SshEnabled: true}}
+			for i := 0; i < peers; i++ {
+				networkMap.FirewallRules = append(networkMap.FirewallRules,
+					generateRule(i, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_TCP, ""),
+					generateRule(i, mgmProto.FirewallRule_OUT, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_TCP, ""),
+				)
+				if i%100 == 0 {
+					networkMap.FirewallRules = append(networkMap.FirewallRules,
+						generateRule(i, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_UDP, "53"))
+				}
+				if i%250 == 0 {
+					networkMap.FirewallRules = append(networkMap.FirewallRules,
+						generateRule(i, mgmProto.FirewallRule_OUT, mgmProto.FirewallRule_DROP, mgmProto.FirewallRule_ICMP, ""))
+				}
+				if i%500 == 0 {
+					networkMap.FirewallRules = append(networkMap.FirewallRules,
+						generateRule(i, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_UDP, "8000-8080"))
+				}
+			}
+			return networkMap
+		},
+	},
+	{
+		// the rules don't cover the offline peers, so nothing is squashed and the SSH rule is added
+		name: "partial",
+		generate: func(peers int) *mgmProto.NetworkMap {
+			networkMap := generateRemotePeers(peers-peers/10, peers/10)
+			networkMap.PeerConfig = &mgmProto.PeerConfig{SshConfig: &mgmProto.SSHConfig{SshEnabled: true}}
+			for i := 0; i < peers-peers/10; i++ {
+				networkMap.FirewallRules = append(networkMap.FirewallRules,
+					generateRule(i, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_ALL, ""))
+				if i%2 == 0 {
+					networkMap.FirewallRules = append(networkMap.FirewallRules,
+						generateRule(i, mgmProto.FirewallRule_OUT, mgmProto.FirewallRule_ACCEPT, mgmProto.FirewallRule_TCP, "443"))
+				}
+			}
+			return networkMap
+		},
+	},
+}
+
+// goldenCases are the sizes of the network maps compared with the golden files, the partial scenario isn't squashed
+// so it is only checked with a small network map to keep the golden files readable
+var goldenCases = []struct {
+	scenario string
+	peers    int
+}{
+	{scenario: "accept_all", peers: 1000},
+	{scenario: "accept_all", peers: 10000},
+	{scenario: "mixed", peers: 1000},
+	{scenario: "mixed", peers: 10000},
+	{scenario: "partial", peers: 100},
+}
+
+func findScenario(t testing.TB, name string) networkMapScenario {
+	t.Helper()
+	for _, scenario := range networkMapScenarios {
+		if scenario.name == name {
+			return scenario
+		}
+	}
+	t.Fatalf("unknown network map scenario %s", name)
+	return networkMapScenario{}
+}
+
+// generatePeerIP returns the address of the n-th peer of the 100.64.0.0/10 network
+func generatePeerIP(n int) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.BigEndian.Uint32(net.IPv4(100, 64, 0, 1).To4())+uint32(n))
+	return ip.String()
+}
+
+func generateRemotePeers(online, offline int) *mgmProto.NetworkMap {
+	networkMap := &mgmProto.NetworkMap{}
+	for i := 0; i < online+offline; i++ {
+		peer := &mgmProto.RemotePeerConfig{AllowedIps: []string{generatePeerIP(i)}}
+		if i < online {
+			networkMap.RemotePeers = append(networkMap.RemotePeers, peer)
+		} else {
+			networkMap.OfflinePeers = append(networkMap.OfflinePeers, peer)
+		}
+	}
+	return networkMap
+}
+
+func generateRule(
+	peer int,
+	direction mgmProto.FirewallRuleDirection,
+	action mgmProto.FirewallRuleAction,
+	protocol mgmProto.FirewallRuleProtocol,
+	port string,
+) *mgmProto.FirewallRule {
+	return &mgmProto.FirewallRule{
+		PeerIP:    generatePeerIP(peer),
+		Direction: direction,
+		Action:    action,
+		Protocol:  protocol,
+		Port:      port,
+	}
+}
+
+func dumpProtoRules(rules []*mgmProto.FirewallRule) string {
+	var sb strings.Builder
+	for _, r := range rules {
+		port := r.Port
+		if port == "" {
+			port = "any"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s %s port=%s\n", r.Direction, r.Action, r.Protocol, r.PeerIP, port))
+	}
+	return sb.String()
+}
+
+// compareGolden compares the output with the golden file, or writes it when the tests run with -update
+func compareGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create the golden files directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatalf("failed to update the golden file %s: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the golden file %s, run the tests with -update to create it: %v", path, err)
+	}
+	if string(expected) == actual {
+		return
+	}
+
+	expectedLines := strings.Split(string(expected), "\n")
+	actualLines := strings.Split(actual, "\n")
+	for i := 0; i < len(expectedLines) && i < len(actualLines); i++ {
+		if expectedLines[i] != actualLines[i] {
+			t.Fatalf("output differs from the golden file %s at line %d:\nexpected: %s\nactual:   %s",
+				path, i+1, expectedLines[i], actualLines[i])
+		}
+	}
+	t.Fatalf("output differs from the golden file %s: expected %d lines, got %d",
+		path, len(expectedLines), len(actualLines))
+}
+
+func TestSquashAcceptRulesGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		name := fmt.Sprintf("%s_%d", c.scenario, c.peers)
+		t.Run(name, func(t *testing.T) {
+			networkMap := findScenario(t, c.scenario).generate(c.peers)
+
+			rules, _ := nbacl.SquashAcceptRules(networkMap)
+			compareGolden(t, filepath.Join("squash", name), dumpProtoRules(rules))
+		})
+	}
+}
+
+func TestApplyFilteringGolden(t *testing.T) {
+	for _, c := range goldenCases {
+		name := fmt.Sprintf("%s_%d", c.scenario, c.peers)
+		t.Run(name, func(t *testing.T) {
+			networkMap := findScenario(t, c.scenario).generate(c.peers)
+
+			fw := fwtest.NewManager()
+			acl := NewDefaultManager(fw)
+			acl.ApplyFiltering(networkMap)
+			compareGolden(t, filepath.Join("apply", name), fw.Dump())
+
+			// applying the same network map again must not change the firewall
+			fw.ClearOperations()
+			acl.ApplyFiltering(networkMap)
+			if count := fw.CountOperations(fwtest.OpAddFiltering); count != 0 {
+				t.Errorf("reapplying the rules should not add firewall rules, got %d", count)
+			}
+			if count := fw.CountOperations(fwtest.OpDeleteRule); count != 0 {
+				t.Errorf("reapplying the rules should not delete firewall rules, got %d", count)
+			}
+		})
+	}
+}
+
+func TestApplyFilteringRemovesRules(t *testing.T) {
+	fw := fwtest.NewManager()
+	acl := NewDefaultManager(fw)
+
+	acl.ApplyFiltering(findScenario(t, "partial").generate(100))
+	partial := len(fw.Rules())
+
+	acl.ApplyFiltering(findScenario(t, "accept_all").generate(100))
+	if rules := fw.Rules(); len(rules) != 2 {
+		t.Fatalf("the squashed rules should replace the previous ones, got %d rules", len(rules))
+	}
+
+	acl.ApplyFiltering(findScenario(t, "partial").generate(100))
+	// the rules get new ipset names, so only their number is compared
+	if count := len(fw.Rules()); count != partial {
+		t.Errorf("applying the network map again should restore %d rules, got %d", partial, count)
+	}
+}
+
+func TestApplyFilteringLoggedRules(t *testing.T) {
+	networkMap := generateRemotePeers(2, 0)
+	logged := generateRule(0, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_DROP, mgmProto.FirewallRule_TCP, "22")
+	logged.Log = true
+	networkMap.FirewallRules = append(networkMap.FirewallRules,
+		logged,
+		generateRule(1, mgmProto.FirewallRule_IN, mgmProto.FirewallRule_DROP, mgmProto.FirewallRule_TCP, "22"),
+	)
+
+	fw := fwtest.NewManager()
+	acl := NewDefaultManager(fw)
+	acl.ApplyFiltering(networkMap)
+
+	actions := make(map[string]firewall.Action)
+	ipsets := make(map[string]struct{})
+	for _, rule := range fw.Rules() {
+		actions[rule.IP.String()] = rule.Action
+		ipsets[rule.IPSetName] = struct{}{}
+	}
+	if action := actions[generatePeerIP(0)]; action != firewall.ActionLogDrop {
+		t.Errorf("the logged rule should log and drop the packets, got action %d", action)
+	}
+	if action := actions[generatePeerIP(1)]; action != firewall.ActionDrop {
+		t.Errorf("the rule which isn't logged should drop the packets, got action %d", action)
+	}
+	if len(ipsets) != 2 {
+		t.Errorf("the logged and the unlogged rules should not share an ipset, got %d ipsets", len(ipsets))
+	}
+}
+
+func TestApplyFilteringRollback(t *testing.T) {
+	fw := fwtest.NewManager()
+	acl := NewDefaultManager(fw)
+
+	acl.ApplyFiltering(findScenario(t, "mixed").generate(1000))
+	applied := fw.Dump()
+
+	fw.FailOn(fwtest.OpAddFiltering, errors.New("failed"))
+	acl.ApplyFiltering(findScenario(t, "partial").generate(100))
+	if count := fw.CountOperations(fwtest.OpRollback); count != 1 {
+		t.Fatalf("the transaction should be rolled back once, got %d", count)
+	}
+	if dump := fw.Dump(); dump != applied {
+		t.Fatalf("a failed update should keep the previous rules")
+	}
+
+	fw.FailOn(fwtest.OpAddFiltering, nil)
+	fw.ClearOperations()
+	acl.ApplyFiltering(findScenario(t, "mixed").generate(1000))
+	if count := fw.CountOperations(fwtest.OpAddFiltering); count != 0 {
+		t.Errorf("the rules kept after the rollback should not be added again, got %d additions", count)
+	}
+}
+
+func TestApplyFilteringWithoutTx(t *testing.T) {
+	fw := fwtest.NewManager()
+	fw.DisableTx()
+	acl := NewDefaultManager(fw)
+
+	acl.ApplyFiltering(findScenario(t, "partial").generate(100))
+	if count := fw.CountOperations(fwtest.OpFlush); count != 1 {
+		t.Errorf("the rules should be flushed once without transaction, got %d", count)
+	}
+	if count := fw.CountOperations(fwtest.OpCommit); count != 0 {
+		t.Errorf("the rules should not be committed without transaction, got %d", count)
+	}
+
+	withTx := fwtest.NewManager()
+	NewDefaultManager(withTx).ApplyFiltering(findScenario(t, "partial").generate(100))
+	if fw.Dump() != withTx.Dump() {
+		t.Errorf("the rules applied without transaction should be the same")
+	}
+}
+
+func BenchmarkSquashAcceptRules(b *testing.B) {
+	for _, scenario := range networkMapScenarios {
+		for _, peers := range []int{1000, 10000} {
+			networkMap := scenario.generate(peers)
+			b.Run(fmt.Sprintf("%s_%d", scenario.name, peers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					nbacl.SquashAcceptRules(networkMap)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkApplyFiltering(b *testing.B) {
+	for _, scenario := range networkMapScenarios {
+		for _, peers := range []int{1000, 10000} {
+			networkMap := scenario.generate(peers)
+
+			b.Run(fmt.Sprintf("%s_%d/initial", scenario.name, peers), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					NewDefaultManager(fwtest.NewManager()).ApplyFiltering(networkMap)
+				}
+			})
+
+			b.Run(fmt.Sprintf("%s_%d/reapply", scenario.name, peers), func(b *testing.B) {
+				acl := NewDefaultManager(fwtest.NewManager())
+				acl.ApplyFiltering(networkMap)
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					acl.ApplyFiltering(networkMap)
+				}
+			})
+		}
+	}
+}
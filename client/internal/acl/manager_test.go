@@ -3,10 +3,13 @@ package acl
 import (
 	"context"
 	"net"
+	"net/netip"
+	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 
+	nbacl "github.com/FlintyLemming/netbird/acl"
 	"github.com/FlintyLemming/netbird/client/firewall"
 	"github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/client/internal/acl/mocks"
@@ -52,7 +55,7 @@ func TestDefaultManager(t *testing.T) {
 	}).AnyTimes()
 
 	// we receive one rule from the management so for testing purposes ignore it
-	fw, err := firewall.NewFirewall(context.Background(), ifaceMock)
+	fw, err := firewall.NewFirewall(context.Background(), ifaceMock, manager.NftablesOptions{})
 	if err != nil {
 		t.Errorf("create firewall: %v", err)
 		return
@@ -187,8 +190,7 @@ func TestDefaultManagerSquashRules(t *testing.T) {
 		},
 	}
 
-	manager := &DefaultManager{}
-	rules, _ := manager.squashAcceptRules(networkMap)
+	rules, _ := nbacl.SquashAcceptRules(networkMap)
 	if len(rules) != 2 {
 		t.Errorf("rules should contain 2, got: %v", rules)
 		return
@@ -287,8 +289,7 @@ func TestDefaultManagerSquashRulesNoAffect(t *testing.T) {
 		},
 	}
 
-	manager := &DefaultManager{}
-	if rules, _ := manager.squashAcceptRules(networkMap); len(rules) != len(networkMap.FirewallRules) {
+	if rules, _ := nbacl.SquashAcceptRules(networkMap); len(rules) != len(networkMap.FirewallRules) {
 		t.Errorf("we should get the same amount of rules as output, got %v", len(rules))
 	}
 }
@@ -345,7 +346,7 @@ func TestDefaultManagerEnableSSHRules(t *testing.T) {
 	}).AnyTimes()
 
 	// we receive one rule from the management so for testing purposes ignore it
-	fw, err := firewall.NewFirewall(context.Background(), ifaceMock)
+	fw, err := firewall.NewFirewall(context.Background(), ifaceMock, manager.NftablesOptions{})
 	if err != nil {
 		t.Errorf("create firewall: %v", err)
 		return
@@ -362,3 +363,25 @@ func TestDefaultManagerEnableSSHRules(t *testing.T) {
 		return
 	}
 }
+
+func TestPeerIdentities(t *testing.T) {
+	networkMap := &mgmProto.NetworkMap{
+		RemotePeers: []*mgmProto.RemotePeerConfig{
+			{WgPubKey: "peerA", AllowedIps: []string{"100.64.0.2/32"}},
+			{WgPubKey: "router", AllowedIps: []string{"100.64.0.3/32", "10.0.0.0/8", "fd00::3/128"}},
+		},
+		OfflinePeers: []*mgmProto.RemotePeerConfig{
+			{WgPubKey: "offline", AllowedIps: []string{"100.64.0.4/32"}},
+		},
+	}
+
+	expected := map[netip.Addr]string{
+		netip.MustParseAddr("100.64.0.2"): "peerA",
+		netip.MustParseAddr("100.64.0.3"): "router",
+		netip.MustParseAddr("fd00::3"):    "router",
+		netip.MustParseAddr("100.64.0.4"): "offline",
+	}
+	if identities := peerIdentities(networkMap); !reflect.DeepEqual(identities, expected) {
+		t.Errorf("expected identities %v, got %v", expected, identities)
+	}
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// AdvertiseRoutes requests the Management service to route the networks through this peer and to remove the routes of
+// the withdrawn networks advertised with the same netID. The account settings decide if the peer is allowed to and if the routes wait for the approval of an admin
+func AdvertiseRoutes(ctx context.Context, config *Config, netID string, networks, withdrawn []string) ([]*mgmProto.AdvertisedRoute, error) {
+	mgmClient, err := getMgmClient(ctx, config.PrivateKey, config.ManagementURL, config.AttestationProvider)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = mgmClient.Close()
+		if err != nil {
+			cStatus, ok := status.FromError(err)
+			if !ok || ok && cStatus.Code() != codes.Canceled {
+				log.Warnf("failed to close the Management service client, err: %v", err)
+			}
+		}
+	}()
+
+	serverKey, err := mgmClient.GetServerPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("get Management Service public key: %w", err)
+	}
+
+	return mgmClient.AdvertiseRoutes(*serverKey, netID, networks, withdrawn)
+}
@@ -0,0 +1,36 @@
+// Package appliance adapts the client to routers with little memory and flash storage, e.g. ARM and MIPS devices
+// running OpenWrt
+package appliance
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+const (
+	// DefaultStateDir is the writable directory holding all the state of the client in appliance mode
+	DefaultStateDir = "/etc/netbird"
+	// MemoryLimit is the soft memory limit of the Go runtime in appliance mode
+	MemoryLimit = 32 << 20
+	// gcPercent makes the garbage collector run twice as often as with the default of 100
+	gcPercent = 50
+
+	openWrtReleaseFile = "/etc/openwrt_release"
+)
+
+// IsOpenWrt returns true when the client runs on OpenWrt
+func IsOpenWrt() bool {
+	_, err := os.Stat(openWrtReleaseFile)
+	return err == nil
+}
+
+// TuneMemory lowers the memory footprint of the client at the cost of more garbage collections. The GOGC and
+// GOMEMLIMIT environment variables take precedence
+func TuneMemory() {
+	if _, ok := os.LookupEnv("GOGC"); !ok {
+		debug.SetGCPercent(gcPercent)
+	}
+	if _, ok := os.LookupEnv("GOMEMLIMIT"); !ok {
+		debug.SetMemoryLimit(MemoryLimit)
+	}
+}
@@ -0,0 +1,60 @@
+package appliance
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	procdInitScript = "/etc/init.d/netbird"
+	// binaryPlaceholder is replaced with the path of the client binary in the init script
+	binaryPlaceholder = "@BINARY@"
+)
+
+//go:embed netbird.init
+var initScript string
+
+//go:embed netbird.config
+var defaultUCIConfig string
+
+// InstallProcdService installs the procd init script running the client binary, enabled at boot, and the default uci
+// config if there is none
+func InstallProcdService(binary string) error {
+	script := strings.ReplaceAll(initScript, binaryPlaceholder, binary)
+	if err := os.WriteFile(procdInitScript, []byte(script), 0755); err != nil { //nolint:gosec
+		return fmt.Errorf("write init script: %w", err)
+	}
+
+	if _, err := os.Stat(UCIConfigFile); errors.Is(err, os.ErrNotExist) {
+		// the setup key may be stored in the config
+		if err := os.WriteFile(UCIConfigFile, []byte(defaultUCIConfig), 0600); err != nil {
+			return fmt.Errorf("write uci config: %w", err)
+		}
+	}
+
+	return ProcdServiceAction("enable")
+}
+
+// UninstallProcdService disables and removes the procd init script, the uci config is kept
+func UninstallProcdService() error {
+	if err := ProcdServiceAction("disable"); err != nil {
+		return err
+	}
+	if err := os.Remove(procdInitScript); err != nil {
+		return fmt.Errorf("remove init script: %w", err)
+	}
+	return nil
+}
+
+// ProcdServiceAction runs an action of the init script, e.g. start, stop or restart
+func ProcdServiceAction(action string) error {
+	out, err := exec.Command(procdInitScript, action).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", procdInitScript, action, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package appliance
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// UCIConfigFile is the uci config of the client on OpenWrt
+	UCIConfigFile = "/etc/config/netbird"
+	// uciSectionType is the type of the sections holding the options of the client
+	uciSectionType = "netbird"
+)
+
+// ReadUCIOptions returns the options of the netbird sections of a uci config file. The values of a list are joined
+// with commas, the way the flags accepting several values expect them
+func ReadUCIOptions(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	options := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields, err := splitUCILine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "config":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("%s:%d: missing section type", path, line)
+			}
+			inSection = fields[1] == uciSectionType
+		case "option", "list":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("%s:%d: expected %s <name> <value>", path, line, fields[0])
+			}
+			if !inSection {
+				continue
+			}
+			name, value := fields[1], fields[2]
+			if previous, ok := options[name]; ok && fields[0] == "list" {
+				value = previous + "," + value
+			}
+			options[name] = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected keyword %q", path, line, fields[0])
+		}
+	}
+	return options, scanner.Err()
+}
+
+// splitUCILine splits a line of a uci config file into its words, the quotes around the words are removed and the
+// comments are skipped
+func splitUCILine(line string) ([]string, error) {
+	var fields []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	for _, char := range line {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+				continue
+			}
+			word.WriteRune(char)
+		case char == '\'' || char == '"':
+			quote = char
+			inWord = true
+		case char == '#':
+			if inWord {
+				word.WriteRune(char)
+				continue
+			}
+			return fields, nil
+		case char == ' ' || char == '\t':
+			if inWord {
+				fields = append(fields, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inWord {
+		fields = append(fields, word.String())
+	}
+	return fields, nil
+}
@@ -0,0 +1,48 @@
+package appliance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadUCIOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netbird")
+	config := `
+config other 'unrelated'
+	option log_level 'trace'
+
+config netbird 'main'
+	option enabled '1'
+	option management_url "https://netbird.example.com:443" # the self-hosted server
+	option hostname router
+	option setup_key 'key with # and spaces'
+	list allowed_local_ports 'tcp/22'
+	list allowed_local_ports 'udp/161'
+`
+	require.NoError(t, os.WriteFile(path, []byte(config), 0600))
+
+	options, err := ReadUCIOptions(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"enabled":             "1",
+		"management_url":      "https://netbird.example.com:443",
+		"hostname":            "router",
+		"setup_key":           "key with # and spaces",
+		"allowed_local_ports": "tcp/22,udp/161",
+	}, options)
+}
+
+func TestReadUCIOptions_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netbird")
+	require.NoError(t, os.WriteFile(path, []byte("config netbird 'main'\n\toption setup_key 'unterminated\n"), 0600))
+
+	_, err := ReadUCIOptions(path)
+	assert.Error(t, err)
+
+	_, err = ReadUCIOptions(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
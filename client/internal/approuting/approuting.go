@@ -0,0 +1,33 @@
+package approuting
+
+// Mode selects how the traffic of the applications is routed through the tunnel
+type Mode int
+
+const (
+	// ModeInclude sends only the traffic of the applications into the tunnel
+	ModeInclude Mode = iota
+	// ModeExclude keeps the traffic of the applications out of the tunnel
+	ModeExclude
+)
+
+func (m Mode) String() string {
+	if m == ModeExclude {
+		return "exclude"
+	}
+	return "include"
+}
+
+// Config is the app routing config received from the Management service
+type Config struct {
+	Mode Mode
+	// Applications are the executable paths the mode applies to
+	Applications []string
+}
+
+// Router restricts the applications sending traffic into the tunnel
+type Router interface {
+	// Apply replaces the rules of the current config, a nil config removes them
+	Apply(config *Config) error
+	// Close removes the rules
+	Close() error
+}
@@ -0,0 +1,14 @@
+//go:build !windows || !(amd64 || arm64)
+
+package approuting
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// New returns an error as app routing is not supported on this OS. On macOS per-app routing requires the
+// NetworkExtension per-app VPN rules, which are only available to MDM managed profiles, and pf can't match processes
+func New(ifaceName string) (Router, error) {
+	return nil, fmt.Errorf("app routing of interface %s is not supported on %s/%s", ifaceName, runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,239 @@
+//go:build amd64 || arm64
+
+package approuting
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows"
+	"golang.zx2c4.com/wireguard/windows/tunnel/winipcfg"
+)
+
+const (
+	// dnsPort is permitted in the include mode, the system resolver sends the queries of the applications
+	dnsPort = 53
+
+	sublayerWeight = ^uint16(0)
+
+	// filter weights in the sublayer, the highest weight is evaluated first
+	weightApplication = 15
+	weightService     = 14
+	weightInterface   = 0
+)
+
+// wfpRouter restricts the applications connecting through the tunnel interface with filters of the Windows Filtering
+// Platform at the ALE connect layers, matching the application ID and the local interface. The filters are added in a
+// dynamic session, they are removed when the session is closed, including when the process exits.
+// Without a callout driver the connections can't be rerouted: the applications kept out of the tunnel can't reach the
+// peers and routed networks, the other traffic is unaffected
+type wfpRouter struct {
+	mu        sync.Mutex
+	ifaceName string
+	luid      uint64
+	engine    uintptr
+}
+
+// New creates a Router for the interface based on the Windows Filtering Platform
+func New(ifaceName string) (Router, error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return nil, fmt.Errorf("get interface %s: %w", ifaceName, err)
+	}
+	luid, err := winipcfg.LUIDFromIndex(uint32(iface.Index))
+	if err != nil {
+		return nil, fmt.Errorf("get LUID of interface %s: %w", ifaceName, err)
+	}
+	return &wfpRouter{ifaceName: ifaceName, luid: uint64(luid)}, nil
+}
+
+// Apply opens a new session holding the filters of the config, closing the previous one
+func (r *wfpRouter) Apply(config *Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.closeSession(); err != nil {
+		return err
+	}
+	if config == nil {
+		return nil
+	}
+
+	engine, err := openSession()
+	if err != nil {
+		return err
+	}
+
+	if err := fwpmTransactionBegin0(engine); err != nil {
+		_ = fwpmEngineClose0(engine)
+		return err
+	}
+	if err := r.addFilters(engine, config); err != nil {
+		_ = fwpmTransactionAbort0(engine)
+		_ = fwpmEngineClose0(engine)
+		return err
+	}
+	if err := fwpmTransactionCommit0(engine); err != nil {
+		_ = fwpmTransactionAbort0(engine)
+		_ = fwpmEngineClose0(engine)
+		return err
+	}
+
+	r.engine = engine
+	return nil
+}
+
+// Close removes the filters by closing the session
+func (r *wfpRouter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.closeSession()
+}
+
+func (r *wfpRouter) closeSession() error {
+	if r.engine == 0 {
+		return nil
+	}
+	if err := fwpmEngineClose0(r.engine); err != nil {
+		return fmt.Errorf("close WFP session: %w", err)
+	}
+	r.engine = 0
+	return nil
+}
+
+func openSession() (uintptr, error) {
+	displayData, err := newDisplayData("NetBird app routing")
+	if err != nil {
+		return 0, err
+	}
+
+	session := fwpmSession0{
+		displayData:          displayData,
+		flags:                fwpmSessionFlagDynamic,
+		txnWaitTimeoutInMSec: windows.INFINITE,
+	}
+
+	var engine uintptr
+	if err := fwpmEngineOpen0(&session, &engine); err != nil {
+		return 0, fmt.Errorf("open WFP session: %w", err)
+	}
+	return engine, nil
+}
+
+func (r *wfpRouter) addFilters(engine uintptr, config *Config) error {
+	sublayerKey, err := windows.GenerateGUID()
+	if err != nil {
+		return err
+	}
+	displayData, err := newDisplayData("NetBird app routing")
+	if err != nil {
+		return err
+	}
+	sublayer := fwpmSublayer0{
+		subLayerKey: sublayerKey,
+		displayData: displayData,
+		weight:      sublayerWeight,
+	}
+	if err := fwpmSubLayerAdd0(engine, &sublayer); err != nil {
+		return fmt.Errorf("add WFP sublayer: %w", err)
+	}
+
+	// the condition value holds a pointer to the LUID, the router keeps it alive
+	ifaceCondition := fwpmFilterCondition0{
+		fieldKey:  fwpmConditionIPLocalInterface,
+		matchType: fwpMatchEqual,
+		conditionValue: fwpValue0{
+			valueType: fwpUint64,
+			value:     uintptr(unsafe.Pointer(&r.luid)),
+		},
+	}
+
+	action := uint32(fwpActionBlock)
+	if config.Mode == ModeInclude {
+		action = fwpActionPermit
+	}
+
+	for _, application := range config.Applications {
+		if err := addApplicationFilters(engine, sublayerKey, ifaceCondition, application, action, weightApplication); err != nil {
+			log.Warnf("failed routing application %s through interface %s: %v", application, r.ifaceName, err)
+		}
+	}
+
+	if config.Mode == ModeExclude {
+		return nil
+	}
+
+	// the service itself and the DNS queries of the system resolver stay in the tunnel
+	service, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("get service executable: %w", err)
+	}
+	if err := addApplicationFilters(engine, sublayerKey, ifaceCondition, service, fwpActionPermit, weightService); err != nil {
+		return err
+	}
+
+	dnsCondition := fwpmFilterCondition0{
+		fieldKey:  fwpmConditionIPRemotePort,
+		matchType: fwpMatchEqual,
+		conditionValue: fwpValue0{
+			valueType: fwpUint16,
+			value:     dnsPort,
+		},
+	}
+	conditions := []fwpmFilterCondition0{ifaceCondition, dnsCondition}
+	if err := addFilters(engine, sublayerKey, "NetBird app routing DNS", conditions, fwpActionPermit, weightService); err != nil {
+		return err
+	}
+
+	conditions = []fwpmFilterCondition0{ifaceCondition}
+	return addFilters(engine, sublayerKey, "NetBird app routing block", conditions, fwpActionBlock, weightInterface)
+}
+
+func addApplicationFilters(engine uintptr, sublayerKey windows.GUID, ifaceCondition fwpmFilterCondition0, application string, action uint32, weight uint8) error {
+	appID, err := fwpmGetAppIdFromFileName0(application)
+	if err != nil {
+		return fmt.Errorf("get application ID: %w", err)
+	}
+	defer fwpmFreeMemory0(&appID)
+
+	appCondition := fwpmFilterCondition0{
+		fieldKey:  fwpmConditionALEAppID,
+		matchType: fwpMatchEqual,
+		conditionValue: fwpValue0{
+			valueType: fwpByteBlobType,
+			value:     uintptr(unsafe.Pointer(appID)),
+		},
+	}
+	conditions := []fwpmFilterCondition0{ifaceCondition, appCondition}
+	return addFilters(engine, sublayerKey, "NetBird app routing "+application, conditions, action, weight)
+}
+
+// addFilters adds the filter to the IPv4 and IPv6 connect layers
+func addFilters(engine uintptr, sublayerKey windows.GUID, name string, conditions []fwpmFilterCondition0, action uint32, weight uint8) error {
+	displayData, err := newDisplayData(name)
+	if err != nil {
+		return err
+	}
+
+	filter := fwpmFilter0{
+		displayData:         displayData,
+		subLayerKey:         sublayerKey,
+		weight:              fwpValue0{valueType: fwpUint8, value: uintptr(weight)},
+		numFilterConditions: uint32(len(conditions)),
+		filterCondition:     &conditions[0],
+		action:              fwpmAction0{actionType: action},
+	}
+
+	for _, layer := range []windows.GUID{fwpmLayerALEAuthConnectV4, fwpmLayerALEAuthConnectV6} {
+		filter.layerKey = layer
+		if err := fwpmFilterAdd0(engine, &filter); err != nil {
+			return fmt.Errorf("add WFP filter %s: %w", name, err)
+		}
+	}
+	return nil
+}
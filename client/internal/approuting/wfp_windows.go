@@ -0,0 +1,216 @@
+//go:build amd64 || arm64
+
+package approuting
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The Windows Filtering Platform types and functions below follow fwptypes.h, fwpmtypes.h and fwpmu.h. The struct
+// layouts match the 64-bit ABI only
+
+const (
+	rpcCAuthnWinNT = 10
+
+	fwpmSessionFlagDynamic = 0x00000001
+
+	fwpActionBlock  = 0x00000001 | 0x00001000
+	fwpActionPermit = 0x00000002 | 0x00001000
+
+	fwpMatchEqual = 0
+
+	fwpUint8        = 1
+	fwpUint16       = 2
+	fwpUint64       = 4
+	fwpByteBlobType = 12
+)
+
+var (
+	// FWPM_LAYER_ALE_AUTH_CONNECT_V4
+	fwpmLayerALEAuthConnectV4 = windows.GUID{
+		Data1: 0xc38d57d1, Data2: 0x05a7, Data3: 0x4c33,
+		Data4: [8]byte{0x90, 0x4f, 0x7f, 0xbc, 0xee, 0xe6, 0x0e, 0x82},
+	}
+	// FWPM_LAYER_ALE_AUTH_CONNECT_V6
+	fwpmLayerALEAuthConnectV6 = windows.GUID{
+		Data1: 0x4a72393b, Data2: 0x319f, Data3: 0x44bc,
+		Data4: [8]byte{0x84, 0xc3, 0xba, 0x54, 0xdc, 0xb3, 0xb6, 0xb4},
+	}
+	// FWPM_CONDITION_ALE_APP_ID
+	fwpmConditionALEAppID = windows.GUID{
+		Data1: 0xd78e1e87, Data2: 0x8644, Data3: 0x4ea5,
+		Data4: [8]byte{0x94, 0x37, 0xd8, 0x09, 0xec, 0xef, 0xc9, 0x71},
+	}
+	// FWPM_CONDITION_IP_LOCAL_INTERFACE
+	fwpmConditionIPLocalInterface = windows.GUID{
+		Data1: 0x4cd62a49, Data2: 0x59c3, Data3: 0x4969,
+		Data4: [8]byte{0xb7, 0xf3, 0xbd, 0xa5, 0xd3, 0x28, 0x90, 0xa4},
+	}
+	// FWPM_CONDITION_IP_REMOTE_PORT
+	fwpmConditionIPRemotePort = windows.GUID{
+		Data1: 0xc35a604d, Data2: 0xd22b, Data3: 0x4e1a,
+		Data4: [8]byte{0x91, 0xb4, 0x68, 0xf6, 0x74, 0xee, 0x67, 0x4b},
+	}
+)
+
+var (
+	modFwpuclnt = windows.NewLazySystemDLL("fwpuclnt.dll")
+
+	procFwpmEngineOpen0           = modFwpuclnt.NewProc("FwpmEngineOpen0")
+	procFwpmEngineClose0          = modFwpuclnt.NewProc("FwpmEngineClose0")
+	procFwpmSubLayerAdd0          = modFwpuclnt.NewProc("FwpmSubLayerAdd0")
+	procFwpmFilterAdd0            = modFwpuclnt.NewProc("FwpmFilterAdd0")
+	procFwpmGetAppIdFromFileName0 = modFwpuclnt.NewProc("FwpmGetAppIdFromFileName0")
+	procFwpmFreeMemory0           = modFwpuclnt.NewProc("FwpmFreeMemory0")
+	procFwpmTransactionBegin0     = modFwpuclnt.NewProc("FwpmTransactionBegin0")
+	procFwpmTransactionCommit0    = modFwpuclnt.NewProc("FwpmTransactionCommit0")
+	procFwpmTransactionAbort0     = modFwpuclnt.NewProc("FwpmTransactionAbort0")
+)
+
+// FWP_BYTE_BLOB
+type fwpByteBlob struct {
+	size uint32
+	data *uint8
+}
+
+// FWP_VALUE0 and FWP_CONDITION_VALUE0
+type fwpValue0 struct {
+	valueType uint32
+	value     uintptr
+}
+
+// FWPM_DISPLAY_DATA0
+type fwpmDisplayData0 struct {
+	name        *uint16
+	description *uint16
+}
+
+// FWPM_SESSION0
+type fwpmSession0 struct {
+	sessionKey           windows.GUID
+	displayData          fwpmDisplayData0
+	flags                uint32
+	txnWaitTimeoutInMSec uint32
+	processID            uint32
+	sid                  *windows.SID
+	username             *uint16
+	kernelMode           uint8
+}
+
+// FWPM_SUBLAYER0
+type fwpmSublayer0 struct {
+	subLayerKey  windows.GUID
+	displayData  fwpmDisplayData0
+	flags        uint32
+	providerKey  *windows.GUID
+	providerData fwpByteBlob
+	weight       uint16
+}
+
+// FWPM_FILTER_CONDITION0
+type fwpmFilterCondition0 struct {
+	fieldKey       windows.GUID
+	matchType      uint32
+	conditionValue fwpValue0
+}
+
+// FWPM_ACTION0
+type fwpmAction0 struct {
+	actionType uint32
+	filterType windows.GUID
+}
+
+// FWPM_FILTER0
+type fwpmFilter0 struct {
+	filterKey           windows.GUID
+	displayData         fwpmDisplayData0
+	flags               uint32
+	providerKey         *windows.GUID
+	providerData        fwpByteBlob
+	layerKey            windows.GUID
+	subLayerKey         windows.GUID
+	weight              fwpValue0
+	numFilterConditions uint32
+	filterCondition     *fwpmFilterCondition0
+	action              fwpmAction0
+	// the provider context key is in a union aligned on 8 bytes
+	_                  [4]byte
+	providerContextKey windows.GUID
+	reserved           *windows.GUID
+	filterID           uint64
+	effectiveWeight    fwpValue0
+}
+
+func wfpError(proc *windows.LazyProc, r1 uintptr) error {
+	if r1 == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", proc.Name, syscall.Errno(r1))
+}
+
+func fwpmEngineOpen0(session *fwpmSession0, engine *uintptr) error {
+	r1, _, _ := procFwpmEngineOpen0.Call(0, rpcCAuthnWinNT, 0, uintptr(unsafe.Pointer(session)), uintptr(unsafe.Pointer(engine)))
+	return wfpError(procFwpmEngineOpen0, r1)
+}
+
+func fwpmEngineClose0(engine uintptr) error {
+	r1, _, _ := procFwpmEngineClose0.Call(engine)
+	return wfpError(procFwpmEngineClose0, r1)
+}
+
+func fwpmSubLayerAdd0(engine uintptr, subLayer *fwpmSublayer0) error {
+	r1, _, _ := procFwpmSubLayerAdd0.Call(engine, uintptr(unsafe.Pointer(subLayer)), 0)
+	return wfpError(procFwpmSubLayerAdd0, r1)
+}
+
+func fwpmFilterAdd0(engine uintptr, filter *fwpmFilter0) error {
+	var id uint64
+	r1, _, _ := procFwpmFilterAdd0.Call(engine, uintptr(unsafe.Pointer(filter)), 0, uintptr(unsafe.Pointer(&id)))
+	return wfpError(procFwpmFilterAdd0, r1)
+}
+
+// fwpmGetAppIdFromFileName0 returns the application ID of the executable, it has to be freed with fwpmFreeMemory0
+func fwpmGetAppIdFromFileName0(fileName string) (*fwpByteBlob, error) {
+	fileNamePtr, err := windows.UTF16PtrFromString(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var appID *fwpByteBlob
+	r1, _, _ := procFwpmGetAppIdFromFileName0.Call(uintptr(unsafe.Pointer(fileNamePtr)), uintptr(unsafe.Pointer(&appID)))
+	if err := wfpError(procFwpmGetAppIdFromFileName0, r1); err != nil {
+		return nil, err
+	}
+	return appID, nil
+}
+
+func fwpmFreeMemory0(appID **fwpByteBlob) {
+	_, _, _ = procFwpmFreeMemory0.Call(uintptr(unsafe.Pointer(appID)))
+}
+
+func fwpmTransactionBegin0(engine uintptr) error {
+	r1, _, _ := procFwpmTransactionBegin0.Call(engine, 0)
+	return wfpError(procFwpmTransactionBegin0, r1)
+}
+
+func fwpmTransactionCommit0(engine uintptr) error {
+	r1, _, _ := procFwpmTransactionCommit0.Call(engine)
+	return wfpError(procFwpmTransactionCommit0, r1)
+}
+
+func fwpmTransactionAbort0(engine uintptr) error {
+	r1, _, _ := procFwpmTransactionAbort0.Call(engine)
+	return wfpError(procFwpmTransactionAbort0, r1)
+}
+
+func newDisplayData(name string) (fwpmDisplayData0, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return fwpmDisplayData0{}, err
+	}
+	return fwpmDisplayData0{name: namePtr}, nil
+}
@@ -0,0 +1,88 @@
+package attestation
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FlintyLemming/netbird/encryption"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// Signer abstracts a hardware-backed key (TPM 2.0, Secure Enclave, Android Keystore) that never leaves the device.
+// The key is used to sign the peer's WireGuard public key on login so that a copied config file can't be used to
+// impersonate the device.
+type Signer interface {
+	// Provider returns the name of the key provider, e.g. tpm or android-keystore
+	Provider() string
+	// PublicKey returns the PKIX, ASN.1 DER encoded public key of the attestation key
+	PublicKey() ([]byte, error)
+	// Sign signs the digest with the attestation key.
+	// ECDSA signatures must be ASN.1 encoded, RSA signatures must use PKCS #1 v1.5 with SHA-256.
+	Sign(digest []byte) ([]byte, error)
+}
+
+// NewSignerFunc creates a Signer of a provider
+type NewSignerFunc func() (Signer, error)
+
+var (
+	providers     = make(map[string]NewSignerFunc)
+	providersLock sync.RWMutex
+)
+
+// RegisterProvider makes a key provider available by name.
+// Platform specific code (e.g. the mobile SDKs) registers the providers available on the device.
+func RegisterProvider(name string, newSigner NewSignerFunc) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[name] = newSigner
+}
+
+// CheckProvider returns an error if the provider isn't registered on this platform. An empty provider disables
+// attestation and is always accepted
+func CheckProvider(provider string) error {
+	if provider == "" {
+		return nil
+	}
+	providersLock.RLock()
+	_, ok := providers[provider]
+	providersLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("attestation provider %s is not available on this platform", provider)
+	}
+	return nil
+}
+
+// NewSigner returns a Signer of the registered provider
+func NewSigner(provider string) (Signer, error) {
+	providersLock.RLock()
+	newSigner, ok := providers[provider]
+	providersLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("attestation provider %s is not available on this platform", provider)
+	}
+	return newSigner()
+}
+
+// NewStatement signs the WireGuard public key with the signer and returns the statement to be sent to Management
+func NewStatement(signer Signer, wgPubKey string) (*mgmProto.PeerAttestation, error) {
+	publicKey, err := signer.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading attestation public key: %v", err)
+	}
+
+	now := time.Now().UTC()
+	signature, err := signer.Sign(encryption.AttestationDigest(wgPubKey, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed signing attestation statement: %v", err)
+	}
+
+	return &mgmProto.PeerAttestation{
+		Provider:  signer.Provider(),
+		PublicKey: publicKey,
+		Signature: signature,
+		Timestamp: timestamppb.New(now),
+	}, nil
+}
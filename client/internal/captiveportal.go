@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"runtime"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/client/internal/captiveportal"
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
+	"github.com/FlintyLemming/netbird/client/internal/netmonitor"
+	"github.com/FlintyLemming/netbird/client/internal/peer"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+const (
+	// captivePortalBypassDuration is the time the user has to authenticate with a captive portal
+	captivePortalBypassDuration = 5 * time.Minute
+	// captivePortalCheckInterval is the interval of the probes during the bypass, the bypass ends once they pass
+	captivePortalCheckInterval = 10 * time.Second
+)
+
+// captivePortalGuard owns the kill switch of the client. When the kill switch or the strict DNS mode is enabled it
+// probes for a captive portal at start and after every network change, and lifts both while the user authenticates
+// with the portal, e.g. on public Wi-Fi. The full tunnel enforcement is restored once the probes pass or the bypass
+// times out
+type captivePortalGuard struct {
+	ctx            context.Context
+	config         *Config
+	statusRecorder *peer.Status
+	detector       *captiveportal.Detector
+	monitor        *netmonitor.Monitor
+	// killSwitchGeneration is the kill switch generation at start, a release stops the guard from enabling it again
+	killSwitchGeneration int
+
+	mu           sync.Mutex
+	wtConfig     *mgmProto.WiretrusteeConfig
+	engine       *Engine
+	bypassing    bool
+	cancelBypass context.CancelFunc
+}
+
+func newCaptivePortalGuard(ctx context.Context, config *Config, statusRecorder *peer.Status) *captivePortalGuard {
+	return &captivePortalGuard{
+		ctx:                  ctx,
+		config:               config,
+		killSwitchGeneration: currentKillSwitchGeneration(),
+		statusRecorder:       statusRecorder,
+	}
+}
+
+// start resolves the probe host, so that the kill switch lets the probes through, and starts watching the network
+// changes. The mobile platforms have neither the kill switch nor the strict DNS mode
+func (g *captivePortalGuard) start() {
+	if !g.config.KillSwitch && !g.config.StrictDNS || runtime.GOOS == "android" || runtime.GOOS == "ios" {
+		return
+	}
+
+	detector, err := captiveportal.NewDetector(captiveportal.DefaultProbeURL, captiveportal.DefaultTimeout)
+	if err != nil {
+		log.Errorf("failed to create the captive portal detector: %v", err)
+		return
+	}
+	if _, err := detector.Resolve(g.ctx); err != nil {
+		log.Warnf("captive portal detection is disabled: %v", err)
+		return
+	}
+	g.detector = detector
+
+	ignored := append([]string{g.config.WgIface}, g.config.IFaceBlackList...)
+	g.monitor = netmonitor.New(ignored, func([]netip.Addr) {
+		go g.check()
+	})
+	g.monitor.Start(g.ctx)
+
+	go g.check()
+}
+
+// stop stops watching the network changes and restores the full tunnel enforcement if a bypass is running, the kill
+// switch must outlive the client
+func (g *captivePortalGuard) stop() {
+	if g.monitor != nil {
+		g.monitor.Stop()
+	}
+	g.restore()
+}
+
+// updateKillSwitch enables the kill switch with the control plane of the Wiretrustee config, nil keeps the last one.
+// The kill switch stays lifted while a captive portal bypass runs
+func (g *captivePortalGuard) updateKillSwitch(ctx context.Context, wtConfig *mgmProto.WiretrusteeConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if wtConfig != nil {
+		g.wtConfig = wtConfig
+	}
+	if !g.config.KillSwitch || g.bypassing {
+		return
+	}
+	enableKillSwitch(ctx, g.killSwitchGeneration, g.config, g.wtConfig, g.probeAddrs(ctx))
+}
+
+// setEngine sets the running engine whose DNS leak protection is lifted during a bypass, nil when it stops
+func (g *captivePortalGuard) setEngine(engine *Engine) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.engine = engine
+	if engine != nil && g.bypassing {
+		engine.PauseDNSLeakProtection(true)
+	}
+}
+
+// check probes for a captive portal and starts a bypass when one intercepts the probe
+func (g *captivePortalGuard) check() {
+	result, err := g.detector.Check(g.ctx)
+	if err != nil {
+		log.Debugf("captive portal probe failed: %v", err)
+		return
+	}
+	if result.Portal {
+		g.bypass(result.Location)
+	}
+}
+
+// bypass lifts the kill switch and the DNS leak protection and restores them when the probes pass again or when the
+// bypass times out
+func (g *captivePortalGuard) bypass(location string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.bypassing || g.ctx.Err() != nil {
+		return
+	}
+	g.bypassing = true
+
+	deadline := time.Now().Add(captivePortalBypassDuration)
+	ctx, cancel := context.WithDeadline(g.ctx, deadline)
+	g.cancelBypass = cancel
+
+	if g.config.KillSwitch {
+		if err := g.liftKillSwitch(); err != nil {
+			log.Errorf("failed to lift the kill switch for the captive portal: %v", err)
+		}
+	}
+	if g.engine != nil {
+		g.engine.PauseDNSLeakProtection(true)
+	}
+
+	if location == "" {
+		location = "unknown"
+	}
+	log.Warnf("captive portal detected, login page %s. The kill switch and the strict DNS mode are lifted for %s "+
+		"to authenticate with the portal", location, captivePortalBypassDuration)
+	g.statusRecorder.RecordEvent(eventlog.CategoryACL,
+		fmt.Sprintf("captive portal detected, tunnel enforcement lifted for %s", captivePortalBypassDuration))
+
+	go g.countdown(ctx, deadline)
+}
+
+// countdown probes the portal until the authentication succeeds or the bypass times out, then restores the full
+// tunnel enforcement
+func (g *captivePortalGuard) countdown(ctx context.Context, deadline time.Time) {
+	defer g.restore()
+
+	checks := time.NewTicker(captivePortalCheckInterval)
+	defer checks.Stop()
+	minutes := time.NewTicker(time.Minute)
+	defer minutes.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				log.Infof("captive portal bypass timed out")
+			}
+			return
+		case <-minutes.C:
+			log.Infof("captive portal bypass ends in %s", time.Until(deadline).Round(time.Second))
+		case <-checks.C:
+			result, err := g.detector.Check(ctx)
+			if err == nil && !result.Portal {
+				log.Infof("captive portal authentication completed")
+				return
+			}
+		}
+	}
+}
+
+// restore enables the kill switch and the DNS leak protection again after a bypass
+func (g *captivePortalGuard) restore() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.bypassing {
+		return
+	}
+	g.bypassing = false
+	g.cancelBypass()
+
+	if g.config.KillSwitch {
+		// the client context may be done when the client stops during the bypass
+		ctx, cancel := context.WithTimeout(context.Background(), captiveportal.DefaultTimeout)
+		enableKillSwitch(ctx, g.killSwitchGeneration, g.config, g.wtConfig, g.probeAddrs(ctx))
+		cancel()
+	}
+	if g.engine != nil {
+		g.engine.PauseDNSLeakProtection(false)
+	}
+
+	log.Infof("captive portal bypass ended, the full tunnel enforcement is restored")
+	g.statusRecorder.RecordEvent(eventlog.CategoryACL, "captive portal bypass ended, tunnel enforcement restored")
+}
+
+// probeAddrs returns the addresses of the probe host the kill switch lets through, none when the detection is off
+func (g *captivePortalGuard) probeAddrs(ctx context.Context) []net.IP {
+	if g.detector == nil {
+		return nil
+	}
+	addrs, err := g.detector.Resolve(ctx)
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
+
+// liftKillSwitch removes the kill switch rules for the bypass unless the kill switch was released meanwhile
+func (g *captivePortalGuard) liftKillSwitch() error {
+	killSwitchMu.Lock()
+	defer killSwitchMu.Unlock()
+
+	if g.killSwitchGeneration != killSwitchGeneration {
+		return nil
+	}
+	return disableKillSwitch()
+}
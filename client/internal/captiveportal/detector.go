@@ -0,0 +1,139 @@
+package captiveportal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeURL answers 204 No Content when the Internet is reachable, a captive portal answers it with a
+	// redirect to its login page or with the page itself
+	DefaultProbeURL = "http://connectivitycheck.gstatic.com/generate_204"
+	// DefaultTimeout is the time to wait for the answer of a probe
+	DefaultTimeout = 5 * time.Second
+)
+
+// Result is the outcome of a probe
+type Result struct {
+	// Portal is true when the answer of the probe was intercepted by a captive portal
+	Portal bool
+	// Location is the login page the portal redirected the probe to, if any
+	Location string
+}
+
+// Detector detects captive portals with plain HTTP requests to a URL answering 204 No Content. The addresses of the
+// probe host are resolved once and reused, so that the probes don't depend on the DNS of the network, which is
+// blocked in strict DNS mode, and so that a firewall can let the probes through
+type Detector struct {
+	url     *url.URL
+	timeout time.Duration
+	client  *http.Client
+
+	mu    sync.Mutex
+	addrs []net.IP
+}
+
+// NewDetector creates a Detector probing the URL, it must be a plain HTTP URL so that the portal can intercept it
+func NewDetector(probeURL string, timeout time.Duration) (*Detector, error) {
+	u, err := url.Parse(probeURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse probe URL: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("the probe URL %s must use the http scheme", probeURL)
+	}
+
+	d := &Detector{url: u, timeout: timeout}
+	d.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       d.dial,
+			DisableKeepAlives: true,
+		},
+		// the redirect is the answer of the portal, it isn't followed
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d, nil
+}
+
+// Resolve returns the addresses of the probe host, they are resolved on the first call only
+func (d *Detector) Resolve(ctx context.Context) ([]net.IP, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.addrs != nil {
+		return d.addrs, nil
+	}
+
+	if ip := net.ParseIP(d.url.Hostname()); ip != nil {
+		d.addrs = []net.IP{ip}
+		return d.addrs, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", d.url.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("resolve probe host %s: %w", d.url.Hostname(), err)
+	}
+	d.addrs = addrs
+	return d.addrs, nil
+}
+
+// Check probes the URL and reports whether a captive portal intercepted the request. An error means that the probe
+// got no answer, which doesn't tell whether there is a portal
+func (d *Detector) Check(ctx context.Context) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return Result{}, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("probe %s: %w", d.url, err)
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return Result{}, nil
+	}
+	return Result{Portal: true, Location: resp.Header.Get("Location")}, nil
+}
+
+// dial connects to the first answering address of the probe host
+func (d *Detector) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, ip := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no address for %s", addr)
+	}
+	return nil, lastErr
+}
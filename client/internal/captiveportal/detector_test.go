@@ -0,0 +1,76 @@
+package captiveportal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector_Check(t *testing.T) {
+	testCases := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected Result
+	}{
+		{
+			name: "no portal",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			expected: Result{},
+		},
+		{
+			name: "portal redirecting to its login page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "http://portal.example/login", http.StatusFound)
+			},
+			expected: Result{Portal: true, Location: "http://portal.example/login"},
+		},
+		{
+			name: "portal answering with its login page",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte("<html>login</html>"))
+			},
+			expected: Result{Portal: true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			server := httptest.NewServer(testCase.handler)
+			defer server.Close()
+
+			detector, err := NewDetector(server.URL+"/generate_204", time.Second)
+			require.NoError(t, err)
+
+			result, err := detector.Check(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, result)
+		})
+	}
+}
+
+func TestDetector_CheckUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	detector, err := NewDetector(url, time.Second)
+	require.NoError(t, err)
+
+	_, err = detector.Check(context.Background())
+	assert.Error(t, err, "a probe without answer should fail instead of reporting a portal")
+}
+
+func TestNewDetector_RequiresHTTP(t *testing.T) {
+	_, err := NewDetector("https://connectivitycheck.gstatic.com/generate_204", time.Second)
+	assert.Error(t, err)
+
+	_, err = NewDetector(DefaultProbeURL, DefaultTimeout)
+	assert.NoError(t, err)
+}
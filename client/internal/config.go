@@ -7,10 +7,13 @@ import (
 	"os"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/client/internal/attestation"
 	"github.com/FlintyLemming/netbird/client/ssh"
 	"github.com/FlintyLemming/netbird/iface"
 	mgm "github.com/FlintyLemming/netbird/management/client"
@@ -41,6 +44,41 @@ type ConfigInput struct {
 	PreSharedKey     *string
 	NATExternalIPs   []string
 	CustomDNSAddress []byte
+	// AttestationProvider sets the hardware-backed key provider, an empty value disables attestation. A provider not
+	// registered on the platform is rejected
+	AttestationProvider *string
+	// PostQuantumEnabled enables the post-quantum pre-shared key negotiation with capable peers
+	PostQuantumEnabled *bool
+	// FlowCollectorURL sets the collector of the firewall flow records, an empty value disables the export
+	FlowCollectorURL *string
+	// DeselectedRoutes sets the network IDs whose routes are ignored, nil keeps the current ones
+	DeselectedRoutes []string
+	// ImportBGPRoutes enables the import of the routes learned by a local BGP daemon
+	ImportBGPRoutes *bool
+	// MaxConcurrentConnections sets the number of peer connections establishing at the same time, 0 uses the default
+	MaxConcurrentConnections *int
+	// DefaultDeny enables the default deny mode of the firewall
+	DefaultDeny *bool
+	// AllowedLocalPorts sets the local ports accepted before the firewall rules, nil keeps the current ones
+	AllowedLocalPorts []string
+	// Services sets the services advertised to the other peers, nil keeps the current ones
+	Services []string
+	// DisableSharedConnection disables multiplexing the Signal service over the Management connection
+	DisableSharedConnection *bool
+	// DetectLANRoutes enables reporting the networks of the local LAN interfaces
+	DetectLANRoutes *bool
+	// LazyConnectionThreshold sets the number of peers above which the peer connections are created on demand
+	LazyConnectionThreshold *int
+	// StrictDNS enables blocking the DNS queries sent to other resolvers than the NetBird one
+	StrictDNS *bool
+	// KillSwitch enables dropping the traffic outside the tunnel, except the control plane
+	KillSwitch *bool
+	// InterfaceName sets the name of the WireGuard interface
+	InterfaceName *string
+	// ExtraAddresses sets the secondary addresses of the WireGuard interface, nil keeps the current ones
+	ExtraAddresses []string
+	// PriorityPeers sets the peers whose connections are kept warm, nil keeps the current ones
+	PriorityPeers []string
 }
 
 // Config Configuration type
@@ -75,6 +113,81 @@ type Config struct {
 	NATExternalIPs []string
 	// CustomDNSAddress sets the DNS resolver listening address in format ip:port
 	CustomDNSAddress string
+
+	// AttestationProvider is the name of the hardware-backed key provider (e.g. android-keystore) used to sign
+	// the WireGuard public key on login. Only the providers registered on the platform are accepted. Empty disables
+	// attestation
+	AttestationProvider string
+
+	// PostQuantumEnabled derives the WireGuard pre-shared key with a post-quantum key exchange when the remote peer
+	// supports it
+	PostQuantumEnabled bool
+
+	// FlowCollectorURL is the collector receiving per-flow packet and byte counts of the userspace firewall.
+	// Supported formats are ipfix://host:port (IPFIX over UDP) and json://host:port (JSON lines over TCP)
+	FlowCollectorURL string
+
+	// DeselectedRoutes holds the network IDs whose routes were deselected by the user and are ignored by the client
+	DeselectedRoutes []string
+
+	// ImportBGPRoutes advertises the routes learned by a BGP daemon running on the peer (e.g. FRR or BIRD) to the
+	// Management service as routes through this peer
+	ImportBGPRoutes bool
+
+	// MaxConcurrentConnections is the number of peer connections gathering candidates and running the connectivity
+	// checks at the same time, e.g. when all peers reconnect after a network change. 0 uses the default
+	MaxConcurrentConnections int
+
+	// DefaultDeny makes the firewall drop the traffic exchanged with the routed networks unless a firewall rule
+	// accepts it, even when the Management service doesn't enable it for the account
+	DefaultDeny bool
+
+	// AllowedLocalPorts are the ports of the services running on the peer, in the protocol/port format (e.g.
+	// tcp/9100), which accept the traffic of the NetBird interface before the firewall rules received from the
+	// Management service and the default deny mode
+	AllowedLocalPorts []string
+
+	// DisableSharedConnection makes the client connect to the Signal service separately when the Management server
+	// serves it too, instead of multiplexing both services over the Management connection
+	DisableSharedConnection bool
+
+	// Services are the services running on the peer advertised to the other peers, in the label:port/protocol
+	// format (e.g. http:8080/tcp), and published as DNS SRV records
+	Services []string
+
+	// DetectLANRoutes makes the client report the networks of its LAN interfaces to the Management service, which
+	// offers them as routes through this peer
+	DetectLANRoutes bool
+
+	// LazyConnectionThreshold is the number of remote peers above which the connections to the peers are created on
+	// demand instead of upfront, to limit the memory used in large networks. 0 uses the default, a negative value
+	// creates all the connections upfront
+	LazyConnectionThreshold int
+
+	// StrictDNS makes the firewall drop the DNS queries sent to other resolvers than the NetBird resolver and the
+	// nameservers of the account while the client is connected, so that no query leaks to the resolvers of the
+	// local network
+	StrictDNS bool
+
+	// KillSwitch makes the firewall drop the traffic which doesn't go through the tunnel, except the control plane,
+	// DNS, DHCP and the IPv6 neighbor discovery. The rules are kept when the client stops and are only removed with
+	// netbird down --release
+	KillSwitch bool
+
+	// ExtraAddresses are secondary addresses in the CIDR format assigned to the WireGuard interface along with the
+	// address of the peer, e.g. an IPv6 address or the addresses of the services running on the peer. They
+	// resolve with the DNS name of the peer
+	ExtraAddresses []string
+
+	// PriorityPeers are the FQDNs, NetBird IPs or WireGuard public keys of the critical peers, e.g. DNS or directory
+	// servers, whose connections are established right away, even with lazy connections, and kept warm with an
+	// aggressive keepalive, so that the first packets sent to them aren't delayed by the connection setup
+	PriorityPeers []string
+
+	// Nftables configures the table, the chains and the hook priorities used by the nftables firewall on Linux. Its
+	// coexistence mode inserts the NetBird chains into an existing table, e.g. the filter table managed by ufw,
+	// instead of hooking them to the input, output and forward hooks. It is only set in the configuration file
+	Nftables manager.NftablesOptions
 }
 
 // ReadConfig read config file and return with Config. If it is not exists create a new with default values
@@ -169,6 +282,77 @@ func createNewConfig(input ConfigInput) (*Config, error) {
 		config.PreSharedKey = *input.PreSharedKey
 	}
 
+	if input.AttestationProvider != nil {
+		if err := attestation.CheckProvider(*input.AttestationProvider); err != nil {
+			return nil, err
+		}
+		config.AttestationProvider = *input.AttestationProvider
+	}
+
+	if input.PostQuantumEnabled != nil {
+		config.PostQuantumEnabled = *input.PostQuantumEnabled
+	}
+
+	if input.FlowCollectorURL != nil {
+		config.FlowCollectorURL = *input.FlowCollectorURL
+	}
+
+	if input.DeselectedRoutes != nil {
+		config.DeselectedRoutes = input.DeselectedRoutes
+	}
+
+	if input.ImportBGPRoutes != nil {
+		config.ImportBGPRoutes = *input.ImportBGPRoutes
+	}
+
+	if input.MaxConcurrentConnections != nil {
+		config.MaxConcurrentConnections = *input.MaxConcurrentConnections
+	}
+
+	if input.DefaultDeny != nil {
+		config.DefaultDeny = *input.DefaultDeny
+	}
+
+	if input.AllowedLocalPorts != nil {
+		config.AllowedLocalPorts = input.AllowedLocalPorts
+	}
+
+	if input.Services != nil {
+		config.Services = input.Services
+	}
+
+	if input.DisableSharedConnection != nil {
+		config.DisableSharedConnection = *input.DisableSharedConnection
+	}
+
+	if input.DetectLANRoutes != nil {
+		config.DetectLANRoutes = *input.DetectLANRoutes
+	}
+
+	if input.LazyConnectionThreshold != nil {
+		config.LazyConnectionThreshold = *input.LazyConnectionThreshold
+	}
+
+	if input.StrictDNS != nil {
+		config.StrictDNS = *input.StrictDNS
+	}
+
+	if input.KillSwitch != nil {
+		config.KillSwitch = *input.KillSwitch
+	}
+
+	if input.InterfaceName != nil {
+		config.WgIface = *input.InterfaceName
+	}
+
+	if input.ExtraAddresses != nil {
+		config.ExtraAddresses = input.ExtraAddresses
+	}
+
+	if input.PriorityPeers != nil {
+		config.PriorityPeers = input.PriorityPeers
+	}
+
 	defaultAdminURL, err := parseURL("Admin URL", DefaultAdminURL)
 	if err != nil {
 		return nil, err
@@ -247,6 +431,113 @@ func update(input ConfigInput) (*Config, error) {
 		refresh = true
 	}
 
+	if input.AttestationProvider != nil && config.AttestationProvider != *input.AttestationProvider {
+		if err := attestation.CheckProvider(*input.AttestationProvider); err != nil {
+			return nil, err
+		}
+		log.Infof("new attestation provider provided, updated to %s (old value %s)",
+			*input.AttestationProvider, config.AttestationProvider)
+		config.AttestationProvider = *input.AttestationProvider
+		refresh = true
+	}
+
+	if input.PostQuantumEnabled != nil && config.PostQuantumEnabled != *input.PostQuantumEnabled {
+		log.Infof("switching post-quantum pre-shared key negotiation to %t", *input.PostQuantumEnabled)
+		config.PostQuantumEnabled = *input.PostQuantumEnabled
+		refresh = true
+	}
+
+	if input.FlowCollectorURL != nil && config.FlowCollectorURL != *input.FlowCollectorURL {
+		log.Infof("new flow collector provided, updated to %s (old value %s)",
+			*input.FlowCollectorURL, config.FlowCollectorURL)
+		config.FlowCollectorURL = *input.FlowCollectorURL
+		refresh = true
+	}
+
+	if input.DeselectedRoutes != nil && !slices.Equal(config.DeselectedRoutes, input.DeselectedRoutes) {
+		log.Infof("deselected routes updated to %v (old value %v)", input.DeselectedRoutes, config.DeselectedRoutes)
+		config.DeselectedRoutes = input.DeselectedRoutes
+		refresh = true
+	}
+
+	if input.ImportBGPRoutes != nil && config.ImportBGPRoutes != *input.ImportBGPRoutes {
+		log.Infof("switching BGP routes import to %t", *input.ImportBGPRoutes)
+		config.ImportBGPRoutes = *input.ImportBGPRoutes
+		refresh = true
+	}
+
+	if input.MaxConcurrentConnections != nil && config.MaxConcurrentConnections != *input.MaxConcurrentConnections {
+		log.Infof("switching the maximum of concurrent peer connections to %d", *input.MaxConcurrentConnections)
+		config.MaxConcurrentConnections = *input.MaxConcurrentConnections
+		refresh = true
+	}
+
+	if input.DefaultDeny != nil && config.DefaultDeny != *input.DefaultDeny {
+		log.Infof("switching the default deny mode of the firewall to %t", *input.DefaultDeny)
+		config.DefaultDeny = *input.DefaultDeny
+		refresh = true
+	}
+
+	if input.AllowedLocalPorts != nil && !slices.Equal(config.AllowedLocalPorts, input.AllowedLocalPorts) {
+		log.Infof("allowed local ports updated to %v (old value %v)", input.AllowedLocalPorts, config.AllowedLocalPorts)
+		config.AllowedLocalPorts = input.AllowedLocalPorts
+		refresh = true
+	}
+
+	if input.Services != nil && !slices.Equal(config.Services, input.Services) {
+		log.Infof("advertised services updated to %v (old value %v)", input.Services, config.Services)
+		config.Services = input.Services
+		refresh = true
+	}
+
+	if input.DisableSharedConnection != nil && config.DisableSharedConnection != *input.DisableSharedConnection {
+		log.Infof("switching the disabled shared Management and Signal connection to %t", *input.DisableSharedConnection)
+		config.DisableSharedConnection = *input.DisableSharedConnection
+		refresh = true
+	}
+
+	if input.DetectLANRoutes != nil && config.DetectLANRoutes != *input.DetectLANRoutes {
+		log.Infof("switching the LAN routes detection to %t", *input.DetectLANRoutes)
+		config.DetectLANRoutes = *input.DetectLANRoutes
+		refresh = true
+	}
+
+	if input.LazyConnectionThreshold != nil && config.LazyConnectionThreshold != *input.LazyConnectionThreshold {
+		log.Infof("switching the lazy peer connection threshold to %d", *input.LazyConnectionThreshold)
+		config.LazyConnectionThreshold = *input.LazyConnectionThreshold
+		refresh = true
+	}
+
+	if input.StrictDNS != nil && config.StrictDNS != *input.StrictDNS {
+		log.Infof("switching the strict DNS mode to %t", *input.StrictDNS)
+		config.StrictDNS = *input.StrictDNS
+		refresh = true
+	}
+
+	if input.KillSwitch != nil && config.KillSwitch != *input.KillSwitch {
+		log.Infof("switching the kill switch to %t", *input.KillSwitch)
+		config.KillSwitch = *input.KillSwitch
+		refresh = true
+	}
+
+	if input.InterfaceName != nil && config.WgIface != *input.InterfaceName {
+		log.Infof("new interface name provided, updated to %s (old value %s)", *input.InterfaceName, config.WgIface)
+		config.WgIface = *input.InterfaceName
+		refresh = true
+	}
+
+	if input.ExtraAddresses != nil && !slices.Equal(config.ExtraAddresses, input.ExtraAddresses) {
+		log.Infof("extra interface addresses updated to %v (old value %v)", input.ExtraAddresses, config.ExtraAddresses)
+		config.ExtraAddresses = input.ExtraAddresses
+		refresh = true
+	}
+
+	if input.PriorityPeers != nil && !slices.Equal(config.PriorityPeers, input.PriorityPeers) {
+		log.Infof("priority peers updated to %v (old value %v)", input.PriorityPeers, config.PriorityPeers)
+		config.PriorityPeers = input.PriorityPeers
+		refresh = true
+	}
+
 	if refresh {
 		// since we have new management URL, we need to update config file
 		if err := util.WriteJson(input.ConfigPath, config); err != nil {
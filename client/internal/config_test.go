@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/FlintyLemming/netbird/client/internal/attestation"
 	"github.com/FlintyLemming/netbird/util"
 )
 
@@ -179,3 +180,31 @@ func TestUpdateOldManagementURL(t *testing.T) {
 		})
 	}
 }
+
+func TestAttestationProviderConfig(t *testing.T) {
+	attestation.RegisterProvider("test-keystore", func() (attestation.Signer, error) {
+		return nil, errors.New("not used")
+	})
+
+	unknown := "tpm"
+	_, err := UpdateOrCreateConfig(ConfigInput{
+		ConfigPath:          filepath.Join(t.TempDir(), "config.json"),
+		AttestationProvider: &unknown,
+	})
+	require.Error(t, err, "a provider not registered on the platform should be rejected")
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	_, err = UpdateOrCreateConfig(ConfigInput{ConfigPath: path})
+	require.NoError(t, err)
+
+	_, err = UpdateOrCreateConfig(ConfigInput{ConfigPath: path, AttestationProvider: &unknown})
+	require.Error(t, err, "a provider not registered on the platform should be rejected")
+	config, err := ReadConfig(path)
+	require.NoError(t, err)
+	assert.Empty(t, config.AttestationProvider, "the rejected provider shouldn't be written")
+
+	registered := "test-keystore"
+	config, err = UpdateOrCreateConfig(ConfigInput{ConfigPath: path, AttestationProvider: &registered})
+	require.NoError(t, err)
+	assert.Equal(t, registered, config.AttestationProvider)
+}
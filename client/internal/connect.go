@@ -3,18 +3,22 @@ package internal
 import (
 	"context"
 	"fmt"
+	"net/netip"
 	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 	log "github.com/sirupsen/logrus"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	gstatus "google.golang.org/grpc/status"
 
+	"github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/client/internal/dns"
 	"github.com/FlintyLemming/netbird/client/internal/listener"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/client/internal/routeselector"
 	"github.com/FlintyLemming/netbird/client/internal/stdnet"
 	"github.com/FlintyLemming/netbird/client/ssh"
 	"github.com/FlintyLemming/netbird/client/system"
@@ -25,9 +29,10 @@ import (
 	"github.com/FlintyLemming/netbird/version"
 )
 
-// RunClient with main logic.
-func RunClient(ctx context.Context, config *Config, statusRecorder *peer.Status) error {
-	return runClient(ctx, config, statusRecorder, MobileDependency{})
+// RunClient with main logic. The routeSelector allows changing the accepted routes while the client runs,
+// if nil the routes deselected in the config are ignored
+func RunClient(ctx context.Context, config *Config, statusRecorder *peer.Status, routeSelector *routeselector.RouteSelector) error {
+	return runClient(ctx, config, statusRecorder, MobileDependency{}, routeSelector)
 }
 
 // RunClientMobile with main logic on mobile system
@@ -40,7 +45,7 @@ func RunClientMobile(ctx context.Context, config *Config, statusRecorder *peer.S
 		HostDNSAddresses:      dnsAddresses,
 		DnsReadyListener:      dnsReadyListener,
 	}
-	return runClient(ctx, config, statusRecorder, mobileDependency)
+	return runClient(ctx, config, statusRecorder, mobileDependency, nil)
 }
 
 func RunClientiOS(ctx context.Context, config *Config, statusRecorder *peer.Status, fileDescriptor int32, networkChangeListener listener.NetworkChangeListener, dnsManager dns.IosDnsManager) error {
@@ -49,12 +54,16 @@ func RunClientiOS(ctx context.Context, config *Config, statusRecorder *peer.Stat
 		NetworkChangeListener: networkChangeListener,
 		DnsManager:            dnsManager,
 	}
-	return runClient(ctx, config, statusRecorder, mobileDependency)
+	return runClient(ctx, config, statusRecorder, mobileDependency, nil)
 }
 
-func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status, mobileDependency MobileDependency) error {
+func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status, mobileDependency MobileDependency, routeSelector *routeselector.RouteSelector) error {
 	log.Infof("starting NetBird client version %s", version.NetbirdVersion())
 
+	if routeSelector == nil {
+		routeSelector = routeselector.New(config.DeselectedRoutes)
+	}
+
 	backOff := &backoff.ExponentialBackOff{
 		InitialInterval:     time.Second,
 		RandomizationFactor: 1,
@@ -90,6 +99,12 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 		return err
 	}
 
+	portalGuard := newCaptivePortalGuard(ctx, config, statusRecorder)
+	portalGuard.start()
+	defer portalGuard.stop()
+	// the rules of the previous run are replaced until the client logs in and gets the rest of the control plane
+	portalGuard.updateKillSwitch(ctx, nil)
+
 	defer statusRecorder.ClientStop()
 	operation := func() error {
 		// if context cancelled we not start new backoff cycle
@@ -108,11 +123,29 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 			cancel()
 		}()
 
+		// once the engine runs, the Management and Signal clients outlive the engine context,
+		// so that the engine can notify the peers and the Management service when it stops
+		clientsCtx, cancelClients := context.WithCancel(context.Background())
+		engineRunning := make(chan struct{})
+		go func() {
+			select {
+			case <-engineCtx.Done():
+				cancelClients()
+			case <-engineRunning:
+			}
+		}()
+
 		log.Debugf("connecting to the Management service %s", config.ManagementURL.Host)
-		mgmClient, err := mgm.NewClient(engineCtx, config.ManagementURL.Host, myPrivateKey, mgmTlsEnabled)
+		mgmClient, err := mgm.NewClient(clientsCtx, config.ManagementURL.Host, myPrivateKey, mgmTlsEnabled)
 		if err != nil {
 			return wrapErr(gstatus.Errorf(codes.FailedPrecondition, "failed connecting to Management Service : %s", err))
 		}
+		err = setAttestation(mgmClient, config.AttestationProvider)
+		if err != nil {
+			_ = mgmClient.Close()
+			statusRecorder.MarkPostureCheckFailed(fmt.Sprintf("failed to attest the device: %v", err))
+			return backoff.Permanent(wrapErr(err))
+		}
 		mgmNotifier := statusRecorderToMgmConnStateNotifier(statusRecorder)
 		mgmClient.SetConnStateListener(mgmNotifier)
 
@@ -125,17 +158,22 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 		}()
 
 		// connect (just a connection, no stream yet) and login to Management Service to get an initial global Wiretrustee config
-		loginResp, err := loginToManagement(engineCtx, mgmClient, publicSSHKey)
+		loginResp, err := loginToManagement(engineCtx, mgmClient, publicSSHKey, peerCapabilities(config), peerServices(config), peerLANNetworks(config))
 		if err != nil {
 			log.Debug(err)
 			if s, ok := gstatus.FromError(err); ok && (s.Code() == codes.PermissionDenied) {
 				state.Set(StatusNeedsLogin)
+				markLoginDenied(statusRecorder, s.Message())
 				return backoff.Permanent(wrapErr(err)) // unrecoverable error
 			}
 			return wrapErr(err)
 		}
 		statusRecorder.MarkManagementConnected()
 
+		if engineCtx.Err() == nil {
+			portalGuard.updateKillSwitch(engineCtx, loginResp.GetWiretrusteeConfig())
+		}
+
 		localPeerState := peer.LocalPeerState{
 			IP:              loginResp.GetPeerConfig().GetAddress(),
 			PubKey:          myPrivateKey.PublicKey().String(),
@@ -156,7 +194,7 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 		defer statusRecorder.MarkSignalDisconnected()
 
 		// with the global Wiretrustee config in hand connect (just a connection, no stream yet) Signal
-		signalClient, err := connectToSignal(engineCtx, loginResp.GetWiretrusteeConfig(), myPrivateKey)
+		signalClient, err := connectToSignal(clientsCtx, loginResp.GetWiretrusteeConfig(), myPrivateKey, shareableConn(config, mgmClient))
 		if err != nil {
 			log.Error(err)
 			return wrapErr(err)
@@ -167,6 +205,8 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 				log.Warnf("failed closing Signal service client %v", err)
 			}
 		}()
+		// stop the clients before closing them
+		defer cancelClients()
 
 		signalNotifier := statusRecorderToSignalConnStateNotifier(statusRecorder)
 		signalClient.SetConnStateListener(signalNotifier)
@@ -180,13 +220,17 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 			log.Error(err)
 			return wrapErr(err)
 		}
+		engineConfig.RouteSelector = routeSelector
 
 		engine := NewEngine(engineCtx, cancel, signalClient, mgmClient, engineConfig, mobileDependency, statusRecorder)
+		portalGuard.setEngine(engine)
 		err = engine.Start()
 		if err != nil {
+			portalGuard.setEngine(nil)
 			log.Errorf("error while starting Netbird Connection Engine: %s", err)
 			return wrapErr(err)
 		}
+		close(engineRunning)
 
 		log.Print("Netbird engine started, my IP is: ", peerConfig.Address)
 		state.Set(StatusConnected)
@@ -196,6 +240,7 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 
 		backOff.Reset()
 
+		portalGuard.setEngine(nil)
 		err = engine.Stop()
 		if err != nil {
 			log.Errorf("failed stopping engine %v", err)
@@ -226,15 +271,23 @@ func runClient(ctx context.Context, config *Config, statusRecorder *peer.Status,
 // createEngineConfig converts configuration received from Management Service to EngineConfig
 func createEngineConfig(key wgtypes.Key, config *Config, peerConfig *mgmProto.PeerConfig) (*EngineConfig, error) {
 	engineConf := &EngineConfig{
-		WgIfaceName:          config.WgIface,
-		WgAddr:               peerConfig.Address,
-		IFaceBlackList:       config.IFaceBlackList,
-		DisableIPv6Discovery: config.DisableIPv6Discovery,
-		WgPrivateKey:         key,
-		WgPort:               config.WgPort,
-		SSHKey:               []byte(config.SSHKey),
-		NATExternalIPs:       config.NATExternalIPs,
-		CustomDNSAddress:     config.CustomDNSAddress,
+		WgIfaceName:              config.WgIface,
+		WgAddr:                   peerConfig.Address,
+		IFaceBlackList:           config.IFaceBlackList,
+		DisableIPv6Discovery:     config.DisableIPv6Discovery,
+		WgPrivateKey:             key,
+		WgPort:                   config.WgPort,
+		SSHKey:                   []byte(config.SSHKey),
+		NATExternalIPs:           config.NATExternalIPs,
+		CustomDNSAddress:         config.CustomDNSAddress,
+		PostQuantumEnabled:       config.PostQuantumEnabled,
+		FlowCollectorURL:         config.FlowCollectorURL,
+		ImportBGPRoutes:          config.ImportBGPRoutes,
+		MaxConcurrentConnections: config.MaxConcurrentConnections,
+		DefaultDeny:              config.DefaultDeny,
+		LazyConnectionThreshold:  config.LazyConnectionThreshold,
+		StrictDNS:                config.StrictDNS,
+		Nftables:                 config.Nftables,
 	}
 
 	if config.PreSharedKey != "" {
@@ -245,11 +298,26 @@ func createEngineConfig(key wgtypes.Key, config *Config, peerConfig *mgmProto.Pe
 		engineConf.PreSharedKey = &preSharedKey
 	}
 
+	allowedLocalPorts, err := manager.ParseLocalPorts(config.AllowedLocalPorts)
+	if err != nil {
+		return nil, err
+	}
+	engineConf.AllowedLocalPorts = allowedLocalPorts
+	engineConf.Services = config.Services
+	engineConf.WgExtraAddrs = config.ExtraAddresses
+	engineConf.PriorityPeers = config.PriorityPeers
+
 	return engineConf, nil
 }
 
-// connectToSignal creates Signal Service client and established a connection
-func connectToSignal(ctx context.Context, wtConfig *mgmProto.WiretrusteeConfig, ourPrivateKey wgtypes.Key) (*signal.GrpcClient, error) {
+// connectToSignal creates Signal Service client and established a connection. The Signal service is reached over the
+// shared connection when the Management server serves it too
+func connectToSignal(ctx context.Context, wtConfig *mgmProto.WiretrusteeConfig, ourPrivateKey wgtypes.Key, sharedConn *grpc.ClientConn) (*signal.GrpcClient, error) {
+	if sharedConn != nil && wtConfig.GetSignalOverManagement() {
+		log.Infof("multiplexing the Signal service over the Management connection")
+		return signal.NewSharedClient(ctx, sharedConn, ourPrivateKey), nil
+	}
+
 	var sigTLSEnabled bool
 	if wtConfig.Signal.Protocol == mgmProto.HostConfig_HTTPS {
 		sigTLSEnabled = true
@@ -266,8 +334,16 @@ func connectToSignal(ctx context.Context, wtConfig *mgmProto.WiretrusteeConfig,
 	return signalClient, nil
 }
 
+// shareableConn returns the Management connection the Signal service may share, nil if it is disabled in the config
+func shareableConn(config *Config, mgmClient *mgm.GrpcClient) *grpc.ClientConn {
+	if config.DisableSharedConnection {
+		return nil
+	}
+	return mgmClient.Conn()
+}
+
 // loginToManagement creates Management Services client, establishes a connection, logs-in and gets a global Wiretrustee config (signal, turn, stun hosts, etc)
-func loginToManagement(ctx context.Context, client mgm.Client, pubSSHKey []byte) (*mgmProto.LoginResponse, error) {
+func loginToManagement(ctx context.Context, client mgm.Client, pubSSHKey []byte, capabilities []string, services []system.Service, lanNetworks []netip.Prefix) (*mgmProto.LoginResponse, error) {
 
 	serverPublicKey, err := client.GetServerPublicKey()
 	if err != nil {
@@ -275,6 +351,10 @@ func loginToManagement(ctx context.Context, client mgm.Client, pubSSHKey []byte)
 	}
 
 	sysInfo := system.GetInfo(ctx)
+	sysInfo.Capabilities = capabilities
+	sysInfo.FirewallCapabilities = firewallCapabilities()
+	sysInfo.Services = services
+	sysInfo.LANNetworks = lanNetworks
 	loginResp, err := client.Login(*serverPublicKey, sysInfo, pubSSHKey)
 	if err != nil {
 		return nil, err
@@ -283,6 +363,15 @@ func loginToManagement(ctx context.Context, client mgm.Client, pubSSHKey []byte)
 	return loginResp, nil
 }
 
+// markLoginDenied notifies the attention listener about the reason the Management service rejected the login
+func markLoginDenied(statusRecorder *peer.Status, message string) {
+	if strings.Contains(message, "attestation") {
+		statusRecorder.MarkPostureCheckFailed(message)
+		return
+	}
+	statusRecorder.MarkLoginExpired(true)
+}
+
 func statusRecorderToMgmConnStateNotifier(statusRecorder *peer.Status) mgm.ConnStateNotifier {
 	var sri interface{} = statusRecorder
 	mgmNotifier, _ := sri.(mgm.ConnStateNotifier)
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/stun/v2"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/client/internal/diagnostics"
+	"github.com/FlintyLemming/netbird/client/ssh"
+	"github.com/FlintyLemming/netbird/client/system"
+	"github.com/FlintyLemming/netbird/iface"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// RunDiagnostics checks the reachability and latency of the Management and Signal services and the conditions for
+// peer to peer connections with the STUN servers and relays provided by the Management service
+func RunDiagnostics(ctx context.Context, config *Config) (*diagnostics.Report, error) {
+	report := &diagnostics.Report{}
+
+	pubSSHKey, err := ssh.GeneratePublicKey([]byte(config.SSHKey))
+	if err != nil {
+		return nil, err
+	}
+
+	mgmClient, err := getMgmClient(ctx, config.PrivateKey, config.ManagementURL, config.AttestationProvider)
+	if err != nil {
+		report.Add("management", diagnostics.StatusFailed, err.Error())
+		report.AddFinding(fmt.Sprintf("The Management service %s is unreachable, check that outgoing TCP traffic to it is allowed", config.ManagementURL.Host))
+		return report, nil
+	}
+	defer func() {
+		err = mgmClient.Close()
+		if err != nil {
+			cStatus, ok := status.FromError(err)
+			if !ok || ok && cStatus.Code() != codes.Canceled {
+				log.Warnf("failed to close the Management service client, err: %v", err)
+			}
+		}
+	}()
+
+	start := time.Now()
+	serverKey, err := mgmClient.GetServerPublicKey()
+	if err != nil {
+		report.Add("management", diagnostics.StatusFailed, err.Error())
+		return report, nil
+	}
+	report.AddLatency("management", time.Since(start))
+
+	sysInfo := system.GetInfo(ctx)
+	sysInfo.Capabilities = peerCapabilities(config)
+	sysInfo.FirewallCapabilities = firewallCapabilities()
+	sysInfo.Services = peerServices(config)
+	sysInfo.LANNetworks = peerLANNetworks(config)
+	loginResp, err := mgmClient.Login(*serverKey, sysInfo, pubSSHKey)
+	if err != nil {
+		report.Add("login", diagnostics.StatusFailed, err.Error())
+		if isLoginNeeded(err) {
+			report.AddFinding("The peer isn't logged in, run netbird up to log in and get the STUN servers and relays to check")
+		}
+		return report, nil
+	}
+	report.Add("login", diagnostics.StatusOK, "the peer is logged in")
+
+	wtConfig := loginResp.GetWiretrusteeConfig()
+	checkSignal(wtConfig.GetSignal().GetUri(), report)
+
+	stuns, turns := diagnosticsURIs(wtConfig.GetStuns(), wtConfig.GetTurns(), report)
+	diagnostics.Run(ctx, diagnostics.Config{
+		STUNs:              stuns,
+		TURNs:              turns,
+		MTU:                iface.DefaultMTU,
+		Timeout:            diagnostics.DefaultTimeout,
+		InterfaceBlackList: config.IFaceBlackList,
+	}, report)
+
+	return report, nil
+}
+
+// checkSignal measures the latency to the Signal service with a TCP handshake
+func checkSignal(addr string, report *diagnostics.Report) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", addr, diagnostics.DefaultTimeout)
+	if err != nil {
+		report.Add("signal", diagnostics.StatusFailed, err.Error())
+		report.AddFinding(fmt.Sprintf("The Signal service %s is unreachable, peers can't exchange connection offers. "+
+			"Check that outgoing TCP traffic to it is allowed", addr))
+		return
+	}
+	latency := time.Since(start)
+	_ = conn.Close()
+
+	report.AddLatency("signal", latency)
+}
+
+// diagnosticsURIs parses the STUN servers and relays provided by the Management service
+func diagnosticsURIs(stuns []*mgmProto.HostConfig, turns []*mgmProto.ProtectedHostConfig, report *diagnostics.Report) ([]*stun.URI, []*stun.URI) {
+	var stunURIs, turnURIs []*stun.URI
+	for _, s := range stuns {
+		uri, err := stun.ParseURI(s.GetUri())
+		if err != nil {
+			report.Add(fmt.Sprintf("stun %s", s.GetUri()), diagnostics.StatusFailed, fmt.Sprintf("invalid URI: %v", err))
+			continue
+		}
+		stunURIs = append(stunURIs, uri)
+	}
+
+	for _, t := range turns {
+		uri, err := stun.ParseURI(t.GetHostConfig().GetUri())
+		if err != nil {
+			report.Add(fmt.Sprintf("relay %s", t.GetHostConfig().GetUri()), diagnostics.StatusFailed, fmt.Sprintf("invalid URI: %v", err))
+			continue
+		}
+		uri.Username = t.GetUser()
+		uri.Password = t.GetPassword()
+		turnURIs = append(turnURIs, uri)
+	}
+	return stunURIs, turnURIs
+}
@@ -0,0 +1,110 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pion/stun/v2"
+)
+
+// Status is the outcome of a check
+type Status string
+
+// Outcomes of the checks
+const (
+	StatusOK      Status = "ok"
+	StatusWarning Status = "warning"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+const (
+	// DefaultTimeout is the default time to wait for the response of a probe
+	DefaultTimeout = 3 * time.Second
+
+	// wireGuardOverhead is the size of the IPv4, UDP and WireGuard headers added to the packets of the tunnel
+	wireGuardOverhead = 60
+	// highLatency is the latency to the NetBird services above which the connection setup is noticeably slower
+	highLatency = 500 * time.Millisecond
+)
+
+// Check is the result of a single diagnostic
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Report holds the results of the checks and the actionable findings derived from them
+type Report struct {
+	Checks   []Check
+	Findings []string
+}
+
+// Add records the result of a check
+func (r *Report) Add(name string, status Status, detail string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: detail})
+}
+
+// AddFinding records an actionable finding
+func (r *Report) AddFinding(finding string) {
+	r.Findings = append(r.Findings, finding)
+}
+
+// AddLatency records the latency of a NetBird service and warns when it is high
+func (r *Report) AddLatency(name string, latency time.Duration) {
+	if latency > highLatency {
+		r.Add(name, StatusWarning, fmt.Sprintf("latency %s", latency.Round(time.Millisecond)))
+		r.AddFinding(fmt.Sprintf("The latency to the %s service is high (%s), connections between peers take longer to set up", name, latency.Round(time.Millisecond)))
+		return
+	}
+	r.Add(name, StatusOK, fmt.Sprintf("latency %s", latency.Round(time.Millisecond)))
+}
+
+// Config of the diagnostics
+type Config struct {
+	// STUNs are the STUN servers provided by the Management service
+	STUNs []*stun.URI
+	// TURNs are the relays provided by the Management service, including their credentials
+	TURNs []*stun.URI
+	// MTU of the NetBird interface, the path to the STUN servers is expected to carry the WireGuard packets of this size
+	MTU int
+	// Timeout is the time to wait for the response of a probe
+	Timeout time.Duration
+	// InterfaceBlackList holds the interfaces not used to gather the relay candidates
+	InterfaceBlackList []string
+}
+
+// Run checks the conditions for peer to peer connections: the reachability of the STUN servers over UDP, the NAT
+// mapping behaviour, the size of the packets reaching the STUN servers and the reachability of the relays
+func Run(ctx context.Context, config Config, report *Report) {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	reachable := checkSTUNs(ctx, config, report)
+
+	switch {
+	case len(config.STUNs) == 0:
+		report.Add("udp", StatusSkipped, "no STUN servers configured")
+	case reachable == nil:
+		report.Add("udp", StatusFailed, "no STUN server answered over UDP")
+		report.AddFinding("Outgoing UDP seems to be blocked, peers can only connect through relays over TCP or TLS. " +
+			"Allow outgoing UDP traffic in the firewall for direct connections")
+	default:
+		report.Add("udp", StatusOK, "outgoing UDP traffic is allowed")
+	}
+
+	if reachable != nil {
+		checkMTU(ctx, config, reachable, report)
+	} else {
+		report.Add("mtu", StatusSkipped, "no STUN server reachable to probe")
+	}
+
+	relays := checkRelays(ctx, config, report)
+	if relays == 0 && reachable == nil && len(config.STUNs) > 0 && len(config.TURNs) > 0 {
+		report.AddFinding("Neither the STUN servers nor the relays are reachable, peers can't connect to each other. " +
+			"Check the firewall and proxy settings of the network")
+	}
+}
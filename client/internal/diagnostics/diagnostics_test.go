@@ -0,0 +1,141 @@
+package diagnostics
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startSTUNServer answers the binding requests with the source address, shifting the port by portOffset to mimic a
+// NAT mapping each destination to a different port
+func startSTUNServer(t *testing.T, portOffset int) *stun.URI {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			req := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+			if err := req.Decode(); err != nil {
+				continue
+			}
+
+			resp, err := stun.Build(req, stun.BindingSuccess, &stun.XORMappedAddress{IP: addr.IP, Port: addr.Port + portOffset})
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(resp.Raw, addr)
+		}
+	}()
+
+	return &stun.URI{Scheme: stun.SchemeTypeSTUN, Host: "127.0.0.1", Port: conn.LocalAddr().(*net.UDPAddr).Port, Proto: stun.ProtoTypeUDP}
+}
+
+// unreachableSTUN returns the URI of a local port nothing answers on
+func unreachableSTUN(t *testing.T) *stun.URI {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return &stun.URI{Scheme: stun.SchemeTypeSTUN, Host: "127.0.0.1", Port: conn.LocalAddr().(*net.UDPAddr).Port, Proto: stun.ProtoTypeUDP}
+}
+
+func checkStatuses(report *Report) map[string]Status {
+	statuses := make(map[string]Status)
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name             string
+		stuns            func(t *testing.T) []*stun.URI
+		expectedStatuses map[string]Status
+		expectedFindings int
+	}{
+		{
+			name: "Reachable STUN servers behind endpoint independent mapping",
+			stuns: func(t *testing.T) []*stun.URI {
+				return []*stun.URI{startSTUNServer(t, 0), startSTUNServer(t, 0)}
+			},
+			expectedStatuses: map[string]Status{
+				"udp":   StatusOK,
+				"nat":   StatusOK,
+				"mtu":   StatusOK,
+				"relay": StatusSkipped,
+			},
+		},
+		{
+			name: "Symmetric NAT",
+			stuns: func(t *testing.T) []*stun.URI {
+				return []*stun.URI{startSTUNServer(t, 0), startSTUNServer(t, 1)}
+			},
+			expectedStatuses: map[string]Status{
+				"udp": StatusOK,
+				"nat": StatusWarning,
+			},
+			expectedFindings: 1,
+		},
+		{
+			name: "Blocked UDP",
+			stuns: func(t *testing.T) []*stun.URI {
+				return []*stun.URI{unreachableSTUN(t)}
+			},
+			expectedStatuses: map[string]Status{
+				"udp": StatusFailed,
+				"nat": StatusSkipped,
+				"mtu": StatusSkipped,
+			},
+			expectedFindings: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			report := &Report{}
+			Run(context.Background(), Config{
+				STUNs:   testCase.stuns(t),
+				MTU:     1280,
+				Timeout: 200 * time.Millisecond,
+			}, report)
+
+			statuses := checkStatuses(report)
+			for name, status := range testCase.expectedStatuses {
+				assert.Equal(t, status, statuses[name], "status of check %s", name)
+			}
+			assert.Len(t, report.Findings, testCase.expectedFindings, "findings: %v", report.Findings)
+		})
+	}
+}
+
+func TestReport_AddLatency(t *testing.T) {
+	report := &Report{}
+	report.AddLatency("management", 20*time.Millisecond)
+	report.AddLatency("signal", 2*time.Second)
+
+	statuses := checkStatuses(report)
+	assert.Equal(t, StatusOK, statuses["management"])
+	assert.Equal(t, StatusWarning, statuses["signal"])
+	assert.Len(t, report.Findings, 1, "high latency should be reported")
+}
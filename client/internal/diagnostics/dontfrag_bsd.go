@@ -0,0 +1,25 @@
+//go:build darwin || freebsd
+
+package diagnostics
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func setDontFragment(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_DONTFRAG, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
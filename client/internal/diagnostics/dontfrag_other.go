@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package diagnostics
+
+import (
+	"errors"
+	"net"
+)
+
+func setDontFragment(*net.UDPConn) error {
+	return errors.New("not supported on this platform")
+}
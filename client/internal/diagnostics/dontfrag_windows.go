@@ -0,0 +1,26 @@
+package diagnostics
+
+import (
+	"net"
+
+	"golang.org/x/sys/windows"
+)
+
+// ipDontFragment is the IP_DONTFRAGMENT socket option, not defined by x/sys/windows
+const ipDontFragment = 14
+
+func setDontFragment(conn *net.UDPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipDontFragment, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,97 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/pion/ice/v3"
+	"github.com/pion/stun/v2"
+
+	"github.com/FlintyLemming/netbird/client/internal/stdnet"
+)
+
+// relayAllocationFactor extends the timeout of the relay checks as an allocation takes several round trips
+const relayAllocationFactor = 3
+
+// checkRelays allocates a relay candidate on each relay the same way the peer connections do and returns the number of
+// relays reachable
+func checkRelays(ctx context.Context, config Config, report *Report) int {
+	if len(config.TURNs) == 0 {
+		report.Add("relay", StatusSkipped, "no relays configured")
+		return 0
+	}
+
+	reachable := 0
+	for _, uri := range config.TURNs {
+		name := fmt.Sprintf("relay %s/%s", net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)), uri.Proto)
+
+		addr, err := allocateRelay(ctx, config, uri)
+		if err != nil {
+			report.Add(name, StatusFailed, err.Error())
+			continue
+		}
+		report.Add(name, StatusOK, fmt.Sprintf("relayed address %s", addr))
+		reachable++
+	}
+
+	if reachable == 0 {
+		report.AddFinding("None of the relays is reachable, peers that can't connect directly won't be able to connect at all. " +
+			"Allow outgoing traffic to the relay ports in the firewall")
+	}
+	return reachable
+}
+
+// allocateRelay gathers the relay candidate of the relay with an ICE agent and returns the relayed address
+func allocateRelay(ctx context.Context, config Config, uri *stun.URI) (string, error) {
+	transportNet, err := stdnet.NewNet(config.InterfaceBlackList)
+	if err != nil {
+		return "", fmt.Errorf("list interfaces: %w", err)
+	}
+
+	agent, err := ice.NewAgent(&ice.AgentConfig{
+		MulticastDNSMode: ice.MulticastDNSModeDisabled,
+		NetworkTypes:     []ice.NetworkType{ice.NetworkTypeUDP4},
+		Urls:             []*stun.URI{uri},
+		CandidateTypes:   []ice.CandidateType{ice.CandidateTypeRelay},
+		InterfaceFilter:  stdnet.InterfaceFilter(config.InterfaceBlackList),
+		Net:              transportNet,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create ICE agent: %w", err)
+	}
+	defer func() {
+		_ = agent.Close()
+	}()
+
+	// the agent reports a nil candidate once the gathering is done
+	candidates := make(chan ice.Candidate, 1)
+	err = agent.OnCandidate(func(candidate ice.Candidate) {
+		select {
+		case candidates <- candidate:
+		default:
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := agent.GatherCandidates(); err != nil {
+		return "", fmt.Errorf("gather candidates: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, relayAllocationFactor*config.Timeout)
+	defer cancel()
+
+	select {
+	case candidate := <-candidates:
+		if candidate == nil {
+			return "", errors.New("no relayed address allocated, check the reachability of the relay and its credentials")
+		}
+		return net.JoinHostPort(candidate.Address(), strconv.Itoa(candidate.Port())), nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("no relayed address allocated within %s", relayAllocationFactor*config.Timeout)
+	}
+}
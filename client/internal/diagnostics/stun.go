@@ -0,0 +1,206 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pion/stun/v2"
+)
+
+const (
+	// probeAttempts is the number of binding requests sent before a server is considered unreachable
+	probeAttempts = 2
+	// probeHeaders is the size of the IPv4, UDP, STUN and PADDING attribute headers of a probe
+	probeHeaders = 20 + 8 + 20 + 4
+)
+
+// probeSizes are the IP packet sizes probed in addition to the size needed by the WireGuard packets
+var probeSizes = []int{1280, 1400, 1500}
+
+// checkSTUNs sends binding requests to the STUN servers from a single socket and compares the public addresses
+// returned to detect the NAT mapping behaviour. It returns the address of the first server answering
+func checkSTUNs(ctx context.Context, config Config, report *Report) *net.UDPAddr {
+	if len(config.STUNs) == 0 {
+		return nil
+	}
+
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		report.Add("stun", StatusFailed, fmt.Sprintf("open UDP socket: %v", err))
+		return nil
+	}
+	defer conn.Close()
+
+	var reachable *net.UDPAddr
+	var mapped []string
+	for _, uri := range config.STUNs {
+		name := fmt.Sprintf("stun %s", net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)))
+
+		addr, err := resolveSTUN(uri)
+		if err != nil {
+			report.Add(name, StatusFailed, err.Error())
+			continue
+		}
+
+		start := time.Now()
+		resp, err := binding(ctx, conn, addr, 0, config.Timeout)
+		if err != nil {
+			report.Add(name, StatusFailed, err.Error())
+			continue
+		}
+		rtt := time.Since(start)
+
+		var xorAddr stun.XORMappedAddress
+		if err := xorAddr.GetFrom(resp); err != nil {
+			report.Add(name, StatusFailed, fmt.Sprintf("invalid response: %v", err))
+			continue
+		}
+
+		report.Add(name, StatusOK, fmt.Sprintf("public address %s, round trip %s", xorAddr.String(), rtt.Round(time.Millisecond)))
+		mapped = append(mapped, xorAddr.String())
+		if reachable == nil {
+			reachable = addr
+		}
+	}
+
+	checkMapping(mapped, report)
+	return reachable
+}
+
+// checkMapping reports the NAT mapping behaviour from the public addresses of the same socket seen by the STUN servers
+func checkMapping(mapped []string, report *Report) {
+	if len(mapped) < 2 {
+		report.Add("nat", StatusSkipped, "two reachable STUN servers are needed to detect the NAT mapping")
+		return
+	}
+
+	for _, addr := range mapped[1:] {
+		if addr != mapped[0] {
+			report.Add("nat", StatusWarning, "the public address depends on the destination (symmetric NAT)")
+			report.AddFinding("The NAT of this network maps each destination to a different public port, direct connections " +
+				"are only possible with peers without NAT and most connections will be relayed. " +
+				"Enable endpoint-independent mapping on the router or forward the WireGuard port")
+			return
+		}
+	}
+	report.Add("nat", StatusOK, "the public address is the same for every destination")
+}
+
+// checkMTU sends binding requests of increasing size with fragmentation disabled to find the largest packet
+// reaching the STUN server. Any answer, including an error response, shows that the probe got through
+func checkMTU(ctx context.Context, config Config, server *net.UDPAddr, report *Report) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		report.Add("mtu", StatusFailed, fmt.Sprintf("open UDP socket: %v", err))
+		return
+	}
+	defer conn.Close()
+
+	if err := setDontFragment(conn); err != nil {
+		report.Add("mtu", StatusSkipped, fmt.Sprintf("can't disable fragmentation: %v", err))
+		return
+	}
+
+	required := config.MTU + wireGuardOverhead
+	sizes := append([]int{required}, probeSizes...)
+	sort.Ints(sizes)
+
+	largest := 0
+	for _, size := range sizes {
+		// the attribute value is padded to 4 bytes
+		padding := (size - probeHeaders) &^ 3
+		if padding <= 0 || padding+probeHeaders <= largest {
+			continue
+		}
+		if _, err := binding(ctx, conn, server, padding, config.Timeout); err != nil {
+			break
+		}
+		largest = padding + probeHeaders
+	}
+
+	switch {
+	case largest == 0:
+		report.Add("mtu", StatusWarning, fmt.Sprintf("no probe reached %s with fragmentation disabled", server))
+		report.AddFinding(fmt.Sprintf("Packets of %d bytes don't reach %s without fragmentation, the tunnel needs %d bytes. "+
+			"Large transfers between peers may stall, check the MTU of the uplink and of VPNs or PPPoE links in between", sizes[0], server, required))
+	case largest < required:
+		report.Add("mtu", StatusWarning, fmt.Sprintf("packets of up to %d bytes reach %s", largest, server))
+		report.AddFinding(fmt.Sprintf("Packets larger than %d bytes don't reach %s without fragmentation, the tunnel needs %d bytes. "+
+			"Large transfers between peers may stall, check the MTU of the uplink and of VPNs or PPPoE links in between", largest, server, required))
+	default:
+		report.Add("mtu", StatusOK, fmt.Sprintf("packets of %d bytes reach %s", largest, server))
+	}
+}
+
+func resolveSTUN(uri *stun.URI) (*net.UDPAddr, error) {
+	if uri.Proto != stun.ProtoTypeUDP {
+		return nil, fmt.Errorf("unsupported transport %s", uri.Proto)
+	}
+	addr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("resolve address: %w", err)
+	}
+	return addr, nil
+}
+
+// binding sends a binding request padded by the given number of bytes and waits for the response
+func binding(ctx context.Context, conn *net.UDPConn, server *net.UDPAddr, padding int, timeout time.Duration) (*stun.Message, error) {
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if padding > 0 {
+		setters = append(setters, stun.RawAttribute{Type: stun.AttrPadding, Value: make([]byte, padding)})
+	}
+
+	var err error
+	for attempt := 0; attempt < probeAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var req *stun.Message
+		req, err = stun.Build(setters...)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp *stun.Message
+		resp, err = exchange(conn, server, req, timeout)
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return nil, err
+}
+
+func exchange(conn *net.UDPConn, server *net.UDPAddr, req *stun.Message, timeout time.Duration) (*stun.Message, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.WriteToUDP(req.Raw, server); err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("no response within %s", timeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read response: %w", err)
+		}
+
+		resp := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+		// responses to previous attempts and other traffic are ignored
+		if err := resp.Decode(); err != nil || resp.TransactionID != req.TransactionID {
+			continue
+		}
+		return resp, nil
+	}
+}
@@ -1,4 +1,4 @@
-//go:build !android
+//go:build (linux && !android) || freebsd || openbsd
 
 package dns
 
@@ -67,6 +67,18 @@ func dnsConfigToHostDNSConfig(dnsConfig nbdns.Config, ip string, port int) HostD
 		ServerIP:   ip,
 		ServerPort: port,
 	}
+
+	// the search domains assigned to the peer come first, in their order of priority
+	assignedDomains := make(map[string]struct{})
+	for _, domain := range dnsConfig.SearchDomains {
+		domain = strings.TrimSuffix(domain, ".")
+		if _, ok := assignedDomains[domain]; ok {
+			continue
+		}
+		assignedDomains[domain] = struct{}{}
+		config.Domains = append(config.Domains, DomainConfig{Domain: domain})
+	}
+
 	for _, nsConfig := range dnsConfig.NameServerGroups {
 		if len(nsConfig.NameServers) == 0 {
 			continue
@@ -76,17 +88,26 @@ func dnsConfigToHostDNSConfig(dnsConfig nbdns.Config, ip string, port int) HostD
 		}
 
 		for _, domain := range nsConfig.Domains {
+			domain = strings.TrimSuffix(domain, ".")
+			// an assigned search domain is already in the list
+			if _, ok := assignedDomains[domain]; ok {
+				continue
+			}
 			config.Domains = append(config.Domains, DomainConfig{
-				Domain:    strings.TrimSuffix(domain, "."),
+				Domain:    domain,
 				MatchOnly: !nsConfig.SearchDomainsEnabled,
 			})
 		}
 	}
 
 	for _, customZone := range dnsConfig.CustomZones {
+		domain := strings.TrimSuffix(customZone.Domain, ".")
+		if _, ok := assignedDomains[domain]; ok {
+			continue
+		}
 		config.Domains = append(config.Domains, DomainConfig{
-			Domain:    strings.TrimSuffix(customZone.Domain, "."),
-			MatchOnly: false,
+			Domain:    domain,
+			MatchOnly: customZone.SearchDomainDisabled,
 		})
 	}
 
@@ -0,0 +1,11 @@
+//go:build freebsd || openbsd
+
+package dns
+
+const defaultResolvConfPath = "/etc/resolv.conf"
+
+// newHostManager returns the resolv.conf file manager, the BSD systems don't run a resolver daemon NetBird could
+// configure instead
+func newHostManager(_ WGIface) (hostManager, error) {
+	return newFileConfigurator()
+}
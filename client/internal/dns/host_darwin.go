@@ -15,17 +15,21 @@ import (
 
 const (
 	netbirdDNSStateKeyFormat            = "State:/Network/Service/NetBird-%s/DNS"
+	netbirdDNSStateKeyPattern           = "State:/Network/Service/NetBird-.*/DNS"
+	serviceSetupKeyPattern              = "Setup:/Network/Service/.*/DNS"
 	globalIPv4State                     = "State:/Network/Global/IPv4"
 	primaryServiceSetupKeyFormat        = "Setup:/Network/Service/%s/DNS"
 	keySupplementalMatchDomains         = "SupplementalMatchDomains"
 	keySupplementalMatchDomainsNoSearch = "SupplementalMatchDomainsNoSearch"
 	keyServerAddresses                  = "ServerAddresses"
 	keyServerPort                       = "ServerPort"
+	keyNetBirdManaged                   = "NetBirdManaged"
 	arraySymbol                         = "* "
 	digitSymbol                         = "# "
 	scutilPath                          = "/usr/sbin/scutil"
 	searchSuffix                        = "Search"
 	matchSuffix                         = "Match"
+	matchDomainKeyPrefix                = "State:/Network/Service/NetBird-" + matchSuffix + "-"
 )
 
 type systemConfigurator struct {
@@ -35,9 +39,15 @@ type systemConfigurator struct {
 }
 
 func newHostManager(_ WGIface) (hostManager, error) {
-	return &systemConfigurator{
+	s := &systemConfigurator{
 		createdKeys: make(map[string]struct{}),
-	}, nil
+	}
+
+	if err := s.removeStaleState(); err != nil {
+		log.Warnf("failed to remove the DNS configuration left by a previous run: %s", err)
+	}
+
+	return s, nil
 }
 
 func (s *systemConfigurator) supportCustomPort() bool {
@@ -77,13 +87,7 @@ func (s *systemConfigurator) applyDNSConfig(config HostDNSConfig) error {
 		searchDomains = append(searchDomains, dConf.Domain)
 	}
 
-	matchKey := getKeyWithInput(netbirdDNSStateKeyFormat, matchSuffix)
-	if len(matchDomains) != 0 {
-		err = s.addMatchDomains(matchKey, strings.Join(matchDomains, " "), config.ServerIP, config.ServerPort)
-	} else {
-		log.Infof("removing match domains from the system")
-		err = s.removeKeyFromSystemConfig(matchKey)
-	}
+	err = s.applyMatchDomains(matchDomains, config.ServerIP, config.ServerPort)
 	if err != nil {
 		return err
 	}
@@ -125,6 +129,67 @@ func (s *systemConfigurator) restoreHostDNS() error {
 	return nil
 }
 
+// removeStaleState removes the keys left in the dynamic store by a previous run that did not exit cleanly
+func (s *systemConfigurator) removeStaleState() error {
+	staleKeys, err := listSystemConfigKeys(netbirdDNSStateKeyPattern)
+	if err != nil {
+		return err
+	}
+
+	setupKeys, err := listSystemConfigKeys(serviceSetupKeyPattern)
+	if err != nil {
+		return err
+	}
+	for _, key := range setupKeys {
+		out, err := runSystemConfigCommand(wrapCommand(buildCommandLine("show", key, "")))
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(out, []byte(keyNetBirdManaged)) {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+
+	if len(staleKeys) == 0 {
+		return nil
+	}
+
+	lines := ""
+	for _, key := range staleKeys {
+		lines += buildRemoveKeyOperation(key)
+	}
+	log.Infof("found %d DNS configuration keys left by a previous run, removing them: %s", len(staleKeys), strings.Join(staleKeys, ", "))
+	_, err = runSystemConfigCommand(wrapCommand(lines))
+	return err
+}
+
+// applyMatchDomains adds a scoped resolver for every match domain, the dynamic store equivalent of an
+// /etc/resolver/<domain> file, and removes the resolvers of the domains no longer present
+func (s *systemConfigurator) applyMatchDomains(domains []string, dnsServer string, port int) error {
+	wanted := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		key := getMatchDomainKey(domain)
+		wanted[key] = struct{}{}
+		err := s.addMatchDomains(key, domain, dnsServer, port)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key := range s.createdKeys {
+		if _, ok := wanted[key]; ok || !isMatchDomainKey(key) {
+			continue
+		}
+		log.Infof("removing match domain resolver %s from the system", key)
+		err := s.removeKeyFromSystemConfig(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (s *systemConfigurator) removeKeyFromSystemConfig(key string) error {
 	line := buildRemoveKeyOperation(key)
 	_, err := runSystemConfigCommand(wrapCommand(line))
@@ -156,7 +221,7 @@ func (s *systemConfigurator) addMatchDomains(key, domains, dnsServer string, por
 		return err
 	}
 
-	log.Infof("added %d match domains to the state. Domain list: %s", len(strings.Split(domains, " ")), domains)
+	log.Infof("added scoped resolver for match domains: %s", domains)
 
 	s.createdKeys[key] = struct{}{}
 
@@ -224,6 +289,7 @@ func (s *systemConfigurator) addDNSSetup(setupKey, dnsServer string, port int, e
 	lines := buildAddCommandLine(keySupplementalMatchDomainsNoSearch, digitSymbol+strconv.Itoa(0))
 	lines += buildAddCommandLine(keyServerAddresses, arraySymbol+dnsServer+" "+existingDNSServer)
 	lines += buildAddCommandLine(keyServerPort, digitSymbol+strconv.Itoa(port))
+	lines += buildAddCommandLine(keyNetBirdManaged, digitSymbol+"1")
 	addDomainCommand := buildCreateStateWithOperation(setupKey, lines)
 	stdinCommands := wrapCommand(addDomainCommand)
 	_, err := runSystemConfigCommand(stdinCommands)
@@ -237,6 +303,36 @@ func getKeyWithInput(format, key string) string {
 	return fmt.Sprintf(format, key)
 }
 
+func getMatchDomainKey(domain string) string {
+	return getKeyWithInput(netbirdDNSStateKeyFormat, matchSuffix+"-"+strings.ToLower(strings.TrimSuffix(domain, ".")))
+}
+
+func isMatchDomainKey(key string) bool {
+	return strings.HasPrefix(key, matchDomainKeyPrefix)
+}
+
+func listSystemConfigKeys(pattern string) ([]string, error) {
+	out, err := runSystemConfigCommand(wrapCommand(buildCommandLine("list", pattern, "")))
+	if err != nil {
+		return nil, err
+	}
+	return parseListedKeys(out), nil
+}
+
+// parseListedKeys parses the keys from a scutil list output with lines like "subKey [0] = State:/Network/Global/IPv4"
+func parseListedKeys(out []byte) []string {
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		_, key, found := strings.Cut(scanner.Text(), " = ")
+		if !found {
+			continue
+		}
+		keys = append(keys, strings.TrimSpace(key))
+	}
+	return keys
+}
+
 func buildAddCommandLine(key, value string) string {
 	return buildCommandLine("d.add", key, value)
 }
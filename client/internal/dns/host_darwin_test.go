@@ -0,0 +1,41 @@
+//go:build !ios
+
+package dns
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseListedKeys(t *testing.T) {
+	out := []byte(`  subKey [0] = State:/Network/Service/NetBird-Match-netbird.cloud/DNS
+  subKey [1] = State:/Network/Service/NetBird-Search/DNS
+`)
+	expected := []string{
+		"State:/Network/Service/NetBird-Match-netbird.cloud/DNS",
+		"State:/Network/Service/NetBird-Search/DNS",
+	}
+
+	if keys := parseListedKeys(out); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("unexpected keys: got %v, want %v", keys, expected)
+	}
+
+	if keys := parseListedKeys([]byte("  No keys.\n")); len(keys) != 0 {
+		t.Errorf("expected no keys, got %v", keys)
+	}
+}
+
+func TestMatchDomainKey(t *testing.T) {
+	key := getMatchDomainKey("Example.COM.")
+	if key != "State:/Network/Service/NetBird-Match-example.com/DNS" {
+		t.Errorf("unexpected match domain key: %s", key)
+	}
+
+	if !isMatchDomainKey(key) {
+		t.Errorf("expected %s to be a match domain key", key)
+	}
+
+	if isMatchDomainKey(getKeyWithInput(netbirdDNSStateKeyFormat, searchSuffix)) {
+		t.Errorf("search key reported as a match domain key")
+	}
+}
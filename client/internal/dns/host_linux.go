@@ -6,6 +6,8 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -13,6 +15,9 @@ import (
 
 const (
 	defaultResolvConfPath = "/etc/resolv.conf"
+
+	systemdResolvedRuntimeDir = "/run/systemd/resolve/"
+	networkManagerRuntimeDir  = "/run/NetworkManager/"
 )
 
 const (
@@ -49,6 +54,19 @@ func newHostManager(wgInterface WGIface) (hostManager, error) {
 	}
 
 	log.Debugf("discovered mode is: %s", osManager)
+	manager, err := newOSManager(wgInterface, osManager)
+	if err == nil || !osManager.isDbus() {
+		return manager, err
+	}
+
+	// the D-Bus services can be reachable while refusing to manage our link, e.g. when the interface
+	// is unmanaged or the service version is too old, in which case we fall back to resolv.conf
+	fallback := resolvConfFallbackType()
+	log.Warnf("failed to set up the %s DNS manager, falling back to %s: %s", osManager, fallback, err)
+	return newOSManager(wgInterface, fallback)
+}
+
+func newOSManager(wgInterface WGIface, osManager osManagerType) (hostManager, error) {
 	switch osManager {
 	case networkManager:
 		return newNetworkManagerDbusConfigurator(wgInterface)
@@ -61,7 +79,63 @@ func newHostManager(wgInterface WGIface) (hostManager, error) {
 	}
 }
 
+// isDbus returns true for the managers configured natively over D-Bus instead of through resolv.conf
+func (t osManagerType) isDbus() bool {
+	return t == networkManager || t == systemdManager
+}
+
+// resolvConfFallbackType returns the manager used when the D-Bus integration is not available
+func resolvConfFallbackType() osManagerType {
+	if _, err := exec.LookPath(resolvconfCommand); err == nil {
+		return resolvConfManager
+	}
+	return fileManager
+}
+
 func getOSDNSManagerType() (osManagerType, error) {
+	osManager, err := getResolvConfManagerType()
+	if err != nil {
+		return 0, err
+	}
+
+	if osManager != fileManager {
+		return osManager, nil
+	}
+
+	// a resolv.conf without a recognizable header can still be a link to a file owned by a D-Bus service
+	target, err := filepath.EvalSymlinks(defaultResolvConfPath)
+	if err != nil {
+		log.Debugf("unable to resolve %s link: %s", defaultResolvConfPath, err)
+		return fileManager, nil
+	}
+
+	switch resolvConfLinkOwner(target) {
+	case systemdManager:
+		if isDbusListenerRunning(systemdResolvedDest, systemdDbusObjectNode) {
+			return systemdManager, nil
+		}
+	case networkManager:
+		if isDbusListenerRunning(networkManagerDest, networkManagerDbusObjectNode) && isNetworkManagerSupported() {
+			return networkManager, nil
+		}
+	}
+
+	return fileManager, nil
+}
+
+// resolvConfLinkOwner returns the manager owning the resolved /etc/resolv.conf link target or fileManager
+func resolvConfLinkOwner(target string) osManagerType {
+	switch {
+	case strings.HasPrefix(target, systemdResolvedRuntimeDir):
+		return systemdManager
+	case strings.HasPrefix(target, networkManagerRuntimeDir):
+		return networkManager
+	default:
+		return fileManager
+	}
+}
+
+func getResolvConfManagerType() (osManagerType, error) {
 
 	file, err := os.Open(defaultResolvConfPath)
 	if err != nil {
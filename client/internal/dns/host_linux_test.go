@@ -0,0 +1,27 @@
+//go:build !android
+
+package dns
+
+import "testing"
+
+func TestResolvConfLinkOwner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		target   string
+		expected osManagerType
+	}{
+		{name: "systemd-resolved stub", target: "/run/systemd/resolve/stub-resolv.conf", expected: systemdManager},
+		{name: "systemd-resolved uplink", target: "/run/systemd/resolve/resolv.conf", expected: systemdManager},
+		{name: "NetworkManager", target: "/run/NetworkManager/resolv.conf", expected: networkManager},
+		{name: "resolvconf", target: "/run/resolvconf/resolv.conf", expected: fileManager},
+		{name: "regular file", target: "/etc/resolv.conf", expected: fileManager},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if owner := resolvConfLinkOwner(testCase.target); owner != testCase.expected {
+				t.Errorf("unexpected owner for %s: got %s, want %s", testCase.target, owner, testCase.expected)
+			}
+		})
+	}
+}
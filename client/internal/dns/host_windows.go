@@ -9,7 +9,9 @@ import (
 )
 
 const (
-	dnsPolicyConfigMatchPath            = "SYSTEM\\CurrentControlSet\\Services\\Dnscache\\Parameters\\DnsPolicyConfig\\NetBird-Match"
+	dnsPolicyConfigPath                 = "SYSTEM\\CurrentControlSet\\Services\\Dnscache\\Parameters\\DnsPolicyConfig"
+	gpoDnsPolicyConfigPath              = "SOFTWARE\\Policies\\Microsoft\\Windows NT\\DNSClient\\DnsPolicyConfig"
+	dnsPolicyConfigMatchRulePrefix      = "NetBird-Match"
 	dnsPolicyConfigVersionKey           = "Version"
 	dnsPolicyConfigVersionValue         = 2
 	dnsPolicyConfigNameKey              = "Name"
@@ -27,6 +29,8 @@ const (
 type registryConfigurator struct {
 	guid       string
 	routingAll bool
+	// nrptRules holds the NRPT rule key paths created by this configurator
+	nrptRules map[string]struct{}
 }
 
 func newHostManager(wgInterface WGIface) (hostManager, error) {
@@ -34,9 +38,16 @@ func newHostManager(wgInterface WGIface) (hostManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &registryConfigurator{
-		guid: guid,
-	}, nil
+	r := &registryConfigurator{
+		guid:      guid,
+		nrptRules: make(map[string]struct{}),
+	}
+
+	if err := removeStaleNRPTRules(); err != nil {
+		log.Warnf("failed to remove the NRPT rules left by a previous run: %s", err)
+	}
+
+	return r, nil
 }
 
 func (s *registryConfigurator) supportCustomPort() bool {
@@ -74,11 +85,7 @@ func (r *registryConfigurator) applyDNSConfig(config HostDNSConfig) error {
 		matchDomains = append(matchDomains, "."+dConf.Domain)
 	}
 
-	if len(matchDomains) != 0 {
-		err = r.addDNSMatchPolicy(matchDomains, config.ServerIP)
-	} else {
-		err = removeRegistryKeyFromDNSPolicyConfig(dnsPolicyConfigMatchPath)
-	}
+	err = r.applyNRPTRules(matchDomains, config.ServerIP)
 	if err != nil {
 		return err
 	}
@@ -101,26 +108,62 @@ func (r *registryConfigurator) addDNSSetupForAll(ip string) error {
 	return nil
 }
 
-func (r *registryConfigurator) addDNSMatchPolicy(domains []string, ip string) error {
-	_, err := registry.OpenKey(registry.LOCAL_MACHINE, dnsPolicyConfigMatchPath, registry.QUERY_VALUE)
-	if err == nil {
-		err = registry.DeleteKey(registry.LOCAL_MACHINE, dnsPolicyConfigMatchPath)
+// applyNRPTRules adds a Name Resolution Policy Table rule for every match domain, so only queries for these
+// domains are sent to the NetBird resolver and the DNS settings of the other adapters stay untouched.
+// Rules of domains no longer present are removed.
+func (r *registryConfigurator) applyNRPTRules(domains []string, ip string) error {
+	wanted := make(map[string]struct{}, len(domains))
+	for _, domain := range domains {
+		for _, rulePath := range nrptRulePaths(domain) {
+			wanted[rulePath] = struct{}{}
+			err := addNRPTRule(rulePath, domain, ip)
+			if err != nil {
+				return err
+			}
+			r.nrptRules[rulePath] = struct{}{}
+		}
+	}
+
+	for rulePath := range r.nrptRules {
+		if _, ok := wanted[rulePath]; ok {
+			continue
+		}
+		err := removeRegistryKeyFromDNSPolicyConfig(rulePath)
 		if err != nil {
-			return fmt.Errorf("unable to remove existing key from registry, key: HKEY_LOCAL_MACHINE\\%s, error: %s", dnsPolicyConfigMatchPath, err)
+			return err
 		}
+		delete(r.nrptRules, rulePath)
 	}
 
-	regKey, _, err := registry.CreateKey(registry.LOCAL_MACHINE, dnsPolicyConfigMatchPath, registry.SET_VALUE)
+	log.Infof("configured NRPT rules for %d match domains. Domain list: %s", len(domains), domains)
+
+	return nil
+}
+
+func (r *registryConfigurator) removeNRPTRules() error {
+	for rulePath := range r.nrptRules {
+		err := removeRegistryKeyFromDNSPolicyConfig(rulePath)
+		if err != nil {
+			return err
+		}
+		delete(r.nrptRules, rulePath)
+	}
+	return nil
+}
+
+func addNRPTRule(rulePath, domain, ip string) error {
+	regKey, _, err := registry.CreateKey(registry.LOCAL_MACHINE, rulePath, registry.SET_VALUE)
 	if err != nil {
-		return fmt.Errorf("unable to create registry key, key: HKEY_LOCAL_MACHINE\\%s, error: %s", dnsPolicyConfigMatchPath, err)
+		return fmt.Errorf("unable to create registry key, key: HKEY_LOCAL_MACHINE\\%s, error: %s", rulePath, err)
 	}
+	defer regKey.Close()
 
 	err = regKey.SetDWordValue(dnsPolicyConfigVersionKey, dnsPolicyConfigVersionValue)
 	if err != nil {
 		return fmt.Errorf("unable to set registry value for %s, error: %s", dnsPolicyConfigVersionKey, err)
 	}
 
-	err = regKey.SetStringsValue(dnsPolicyConfigNameKey, domains)
+	err = regKey.SetStringsValue(dnsPolicyConfigNameKey, []string{domain})
 	if err != nil {
 		return fmt.Errorf("unable to set registry value for %s, error: %s", dnsPolicyConfigNameKey, err)
 	}
@@ -135,13 +178,57 @@ func (r *registryConfigurator) addDNSMatchPolicy(domains []string, ip string) er
 		return fmt.Errorf("unable to set registry value for %s, error: %s", dnsPolicyConfigConfigOptionsKey, err)
 	}
 
-	log.Infof("added %d match domains to the state. Domain list: %s", len(domains), domains)
+	return nil
+}
 
+// nrptRulePaths returns the registry paths of the NRPT rule for a domain. When the NRPT is managed by
+// a group policy the local rules are ignored by the DNS client, so the rule is added to the policy table as well
+func nrptRulePaths(domain string) []string {
+	paths := []string{nrptRulePath(dnsPolicyConfigPath, domain)}
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, gpoDnsPolicyConfigPath, registry.QUERY_VALUE); err == nil {
+		k.Close()
+		paths = append(paths, nrptRulePath(gpoDnsPolicyConfigPath, domain))
+	}
+	return paths
+}
+
+func nrptRulePath(base, domain string) string {
+	return base + "\\" + dnsPolicyConfigMatchRulePrefix + "-" + strings.ToLower(strings.Trim(domain, "."))
+}
+
+// removeStaleNRPTRules removes the NRPT rules left by a previous run that did not exit cleanly
+func removeStaleNRPTRules() error {
+	for _, base := range []string{dnsPolicyConfigPath, gpoDnsPolicyConfigPath} {
+		k, err := registry.OpenKey(registry.LOCAL_MACHINE, base, registry.ENUMERATE_SUB_KEYS)
+		if err != nil {
+			continue
+		}
+		names, err := k.ReadSubKeyNames(-1)
+		k.Close()
+		if err != nil {
+			return fmt.Errorf("unable to list the NRPT rules, key: HKEY_LOCAL_MACHINE\\%s, error: %s", base, err)
+		}
+
+		for _, name := range names {
+			if !isNRPTRuleName(name) {
+				continue
+			}
+			log.Infof("removing NRPT rule %s left by a previous run", name)
+			err = removeRegistryKeyFromDNSPolicyConfig(base + "\\" + name)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+func isNRPTRuleName(name string) bool {
+	return strings.HasPrefix(name, dnsPolicyConfigMatchRulePrefix)
+}
+
 func (r *registryConfigurator) restoreHostDNS() error {
-	err := removeRegistryKeyFromDNSPolicyConfig(dnsPolicyConfigMatchPath)
+	err := r.removeNRPTRules()
 	if err != nil {
 		log.Error(err)
 	}
@@ -10,6 +10,9 @@ import (
 	nbdns "github.com/FlintyLemming/netbird/dns"
 )
 
+// maxCNAMEChain is the number of CNAME records followed within the local records
+const maxCNAMEChain = 8
+
 type registrationMap map[string]struct{}
 
 type localResolver struct {
@@ -28,10 +31,7 @@ func (d *localResolver) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	replyMessage.RecursionAvailable = true
 	replyMessage.Rcode = dns.RcodeSuccess
 
-	response := d.lookupRecord(r)
-	if response != nil {
-		replyMessage.Answer = append(replyMessage.Answer, response)
-	}
+	replyMessage.Answer = append(replyMessage.Answer, d.lookupRecords(r)...)
 
 	err := w.WriteMsg(replyMessage)
 	if err != nil {
@@ -39,26 +39,64 @@ func (d *localResolver) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	}
 }
 
-func (d *localResolver) lookupRecord(r *dns.Msg) dns.RR {
+// lookupRecords returns the record answering the question. Without a record of the requested type, the CNAME record of
+// the name is returned followed by the records of its target found locally, e.g. for the DNS aliases of the peers
+func (d *localResolver) lookupRecords(r *dns.Msg) []dns.RR {
 	question := r.Question[0]
-	record, found := d.records.Load(buildRecordKey(question.Name, question.Qclass, question.Qtype))
-	if !found {
-		return nil
+	name := question.Name
+
+	var answers []dns.RR
+	for i := 0; i <= maxCNAMEChain; i++ {
+		records, found := d.records.Load(buildRecordKey(name, question.Qclass, question.Qtype))
+		if found {
+			return append(answers, records.([]dns.RR)...)
+		}
+		if question.Qtype == dns.TypeCNAME {
+			break
+		}
+
+		records, found = d.records.Load(buildRecordKey(name, question.Qclass, dns.TypeCNAME))
+		if !found {
+			break
+		}
+		cname, ok := records.([]dns.RR)[0].(*dns.CNAME)
+		if !ok {
+			break
+		}
+		answers = append(answers, cname)
+		name = cname.Target
 	}
 
-	return record.(dns.RR)
+	return answers
 }
 
 func (d *localResolver) registerRecord(record nbdns.SimpleRecord) error {
-	fullRecord, err := dns.NewRR(record.String())
-	if err != nil {
-		return err
-	}
+	return d.registerRecords([]nbdns.SimpleRecord{record})
+}
 
-	fullRecord.Header().Rdlength = record.Len()
+// registerRecords registers the records sharing a name, class and type, e.g. the SRV records of a service advertised
+// by several peers, replacing the records previously registered with the same key
+func (d *localResolver) registerRecords(records []nbdns.SimpleRecord) error {
+	var (
+		key         string
+		fullRecords []dns.RR
+	)
+	for _, record := range records {
+		fullRecord, err := dns.NewRR(record.String())
+		if err != nil {
+			return err
+		}
+
+		fullRecord.Header().Rdlength = record.Len()
+
+		header := fullRecord.Header()
+		key = buildRecordKey(header.Name, header.Class, header.Rrtype)
+		fullRecords = append(fullRecords, fullRecord)
+	}
 
-	header := fullRecord.Header()
-	d.records.Store(buildRecordKey(header.Name, header.Class, header.Rrtype), fullRecord)
+	if len(fullRecords) != 0 {
+		d.records.Store(key, fullRecords)
+	}
 
 	return nil
 }
@@ -86,3 +86,137 @@ func TestLocalResolver_ServeDNS(t *testing.T) {
 		})
 	}
 }
+
+func TestLocalResolver_ServeDNS_Alias(t *testing.T) {
+	recordA := nbdns.SimpleRecord{
+		Name:  "peera.netbird.cloud.",
+		Type:  int(dns.TypeA),
+		Class: nbdns.DefaultClass,
+		TTL:   300,
+		RData: "100.64.0.1",
+	}
+	alias := nbdns.SimpleRecord{
+		Name:  "db.prod.netbird.cloud.",
+		Type:  int(dns.TypeCNAME),
+		Class: nbdns.DefaultClass,
+		TTL:   300,
+		RData: "peera.netbird.cloud.",
+	}
+	external := nbdns.SimpleRecord{
+		Name:  "docs.netbird.cloud.",
+		Type:  int(dns.TypeCNAME),
+		Class: nbdns.DefaultClass,
+		TTL:   300,
+		RData: "docs.netbird.io.",
+	}
+
+	resolver := &localResolver{
+		registeredMap: make(registrationMap),
+	}
+	for _, record := range []nbdns.SimpleRecord{recordA, alias, external} {
+		if err := resolver.registerRecord(record); err != nil {
+			t.Fatalf("failed to register record %s: %v", record.Name, err)
+		}
+	}
+
+	testCases := []struct {
+		name            string
+		question        *dns.Msg
+		expectedAnswers []string
+	}{
+		{
+			name:            "Should Resolve Alias With Its Target",
+			question:        new(dns.Msg).SetQuestion(alias.Name, dns.TypeA),
+			expectedAnswers: []string{"CNAME\tpeera.netbird.cloud.", "A\t100.64.0.1"},
+		},
+		{
+			name:            "Should Resolve CNAME Question Only",
+			question:        new(dns.Msg).SetQuestion(alias.Name, dns.TypeCNAME),
+			expectedAnswers: []string{"CNAME\tpeera.netbird.cloud."},
+		},
+		{
+			name:            "Should Resolve Alias With External Target",
+			question:        new(dns.Msg).SetQuestion(external.Name, dns.TypeA),
+			expectedAnswers: []string{"CNAME\tdocs.netbird.io."},
+		},
+		{
+			name:            "Should Resolve Alias Without Target Of The Type",
+			question:        new(dns.Msg).SetQuestion(alias.Name, dns.TypeAAAA),
+			expectedAnswers: []string{"CNAME\tpeera.netbird.cloud."},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var responseMSG *dns.Msg
+			responseWriter := &mockResponseWriter{
+				WriteMsgFunc: func(m *dns.Msg) error {
+					responseMSG = m
+					return nil
+				},
+			}
+
+			resolver.ServeDNS(responseWriter, testCase.question)
+
+			if responseMSG == nil {
+				t.Fatalf("should write a response message")
+			}
+			if len(responseMSG.Answer) != len(testCase.expectedAnswers) {
+				t.Fatalf("unexpected answers: \nWant: %v\nGot: %v", testCase.expectedAnswers, responseMSG.Answer)
+			}
+			for i, expected := range testCase.expectedAnswers {
+				if !strings.HasSuffix(responseMSG.Answer[i].String(), expected) {
+					t.Errorf("unexpected answer %d: \nWant suffix: %s\nGot: %s", i, expected, responseMSG.Answer[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestLocalResolver_ServeDNS_SRV(t *testing.T) {
+	records := []nbdns.SimpleRecord{
+		{
+			Name:  "_http._tcp.netbird.cloud.",
+			Type:  int(dns.TypeSRV),
+			Class: nbdns.DefaultClass,
+			TTL:   300,
+			RData: "0 0 80 peera.netbird.cloud.",
+		},
+		{
+			Name:  "_http._tcp.netbird.cloud.",
+			Type:  int(dns.TypeSRV),
+			Class: nbdns.DefaultClass,
+			TTL:   300,
+			RData: "0 0 8080 peerb.netbird.cloud.",
+		},
+	}
+
+	resolver := &localResolver{
+		registeredMap: make(registrationMap),
+	}
+	if err := resolver.registerRecords(records); err != nil {
+		t.Fatalf("failed to register records: %v", err)
+	}
+
+	var responseMSG *dns.Msg
+	responseWriter := &mockResponseWriter{
+		WriteMsgFunc: func(m *dns.Msg) error {
+			responseMSG = m
+			return nil
+		},
+	}
+	resolver.ServeDNS(responseWriter, new(dns.Msg).SetQuestion("_http._tcp.netbird.cloud.", dns.TypeSRV))
+
+	if responseMSG == nil {
+		t.Fatalf("should write a response message")
+	}
+	expectedAnswers := []string{"SRV\t0 0 80 peera.netbird.cloud.", "SRV\t0 0 8080 peerb.netbird.cloud."}
+	if len(responseMSG.Answer) != len(expectedAnswers) {
+		t.Fatalf("unexpected answers: \nWant: %v\nGot: %v", expectedAnswers, responseMSG.Answer)
+	}
+	for i, expected := range expectedAnswers {
+		if !strings.HasSuffix(responseMSG.Answer[i].String(), expected) {
+			t.Errorf("unexpected answer %d: \nWant suffix: %s\nGot: %s", i, expected, responseMSG.Answer[i].String())
+		}
+	}
+}
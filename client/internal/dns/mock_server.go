@@ -48,3 +48,7 @@ func (m *MockServer) UpdateDNSServer(serial uint64, update nbdns.Config) error {
 func (m *MockServer) SearchDomains() []string {
 	return make([]string, 0)
 }
+
+// SetQueryObserver mock implementation of SetQueryObserver from Server interface
+func (m *MockServer) SetQueryObserver(QueryObserver) {
+}
@@ -0,0 +1,45 @@
+package dns
+
+import (
+	"github.com/miekg/dns"
+)
+
+// QueryObserver is notified of each query served by the DNS server with the response written to the client, the
+// response is nil when the query wasn't answered. It is called from the goroutine serving the query
+type QueryObserver func(query, response *dns.Msg)
+
+// observedResponseWriter keeps the response written to the client for the query observer
+type observedResponseWriter struct {
+	dns.ResponseWriter
+	response *dns.Msg
+}
+
+// WriteMsg writes a reply back to the client and keeps it
+func (w *observedResponseWriter) WriteMsg(msg *dns.Msg) error {
+	w.response = msg
+	return w.ResponseWriter.WriteMsg(msg)
+}
+
+// SetQueryObserver sets the observer notified of the served queries, nil removes it
+func (s *DefaultServer) SetQueryObserver(observer QueryObserver) {
+	if observer == nil {
+		s.queryObserver.Store(nil)
+		return
+	}
+	s.queryObserver.Store(&observer)
+}
+
+// registerMux registers the handler of the domain in the DNS service, the queries are reported to the query observer
+func (s *DefaultServer) registerMux(domain string, handler dns.Handler) {
+	s.service.RegisterMux(domain, dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		observer := s.queryObserver.Load()
+		if observer == nil {
+			handler.ServeDNS(w, r)
+			return
+		}
+
+		writer := &observedResponseWriter{ResponseWriter: w}
+		handler.ServeDNS(writer, r)
+		(*observer)(r, writer.response)
+	}))
+}
@@ -0,0 +1,75 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+type handlersService struct {
+	handlers map[string]dns.Handler
+}
+
+func (s *handlersService) Listen() error { return nil }
+func (s *handlersService) Stop()         {}
+func (s *handlersService) RegisterMux(domain string, handler dns.Handler) {
+	s.handlers[domain] = handler
+}
+func (s *handlersService) DeregisterMux(domain string) { delete(s.handlers, domain) }
+func (s *handlersService) RuntimePort() int            { return defaultPort }
+func (s *handlersService) RuntimeIP() string           { return "100.64.0.1" }
+
+func TestDefaultServer_QueryObserver(t *testing.T) {
+	service := &handlersService{handlers: make(map[string]dns.Handler)}
+	server := &DefaultServer{service: service}
+
+	server.registerMux("netbird.cloud.", dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Name == "unanswered.netbird.cloud." {
+			return
+		}
+		_ = w.WriteMsg(new(dns.Msg).SetReply(r))
+	}))
+
+	handler := service.handlers["netbird.cloud."]
+	if handler == nil {
+		t.Fatal("handler should be registered in the service")
+	}
+
+	query := new(dns.Msg).SetQuestion("peer.netbird.cloud.", dns.TypeA)
+	var written *dns.Msg
+	writer := &mockResponseWriter{WriteMsgFunc: func(m *dns.Msg) error {
+		written = m
+		return nil
+	}}
+
+	handler.ServeDNS(writer, query)
+	if written == nil {
+		t.Fatal("response should be written without observer")
+	}
+
+	var observedQuery, observedResponse *dns.Msg
+	server.SetQueryObserver(func(query, response *dns.Msg) {
+		observedQuery, observedResponse = query, response
+	})
+
+	written = nil
+	handler.ServeDNS(writer, query)
+	if observedQuery != query {
+		t.Errorf("observer should receive the query")
+	}
+	if observedResponse == nil || observedResponse != written {
+		t.Errorf("observer should receive the response written to the client")
+	}
+
+	handler.ServeDNS(writer, new(dns.Msg).SetQuestion("unanswered.netbird.cloud.", dns.TypeA))
+	if observedResponse != nil {
+		t.Errorf("observer should receive a nil response for an unanswered query, got %v", observedResponse)
+	}
+
+	server.SetQueryObserver(nil)
+	observedQuery = nil
+	handler.ServeDNS(writer, query)
+	if observedQuery != nil {
+		t.Errorf("removed observer shouldn't be notified")
+	}
+}
@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+)
+
+// addReverseZone returns the custom zones with a zone holding the PTR records of the A records within the NetBird
+// network, so reverse lookups of peer addresses return their FQDN. The custom zones are not modified
+func addReverseZone(customZones []nbdns.CustomZone, network *net.IPNet) []nbdns.CustomZone {
+	zoneDomain := reverseZoneDomain(network)
+	if zoneDomain == "" {
+		return customZones
+	}
+
+	var records []nbdns.SimpleRecord
+	seen := make(map[string]struct{})
+	for _, customZone := range customZones {
+		// management already provides the zone
+		if dns.Fqdn(customZone.Domain) == zoneDomain {
+			return customZones
+		}
+
+		for _, record := range customZone.Records {
+			if record.Type != int(dns.TypeA) {
+				continue
+			}
+			ip := net.ParseIP(record.RData)
+			if ip == nil || !network.Contains(ip) {
+				continue
+			}
+			name, err := dns.ReverseAddr(record.RData)
+			if err != nil {
+				continue
+			}
+			// the first record of an address wins
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+
+			records = append(records, nbdns.SimpleRecord{
+				Name:  name,
+				Type:  int(dns.TypePTR),
+				Class: nbdns.DefaultClass,
+				TTL:   record.TTL,
+				RData: dns.Fqdn(record.Name),
+			})
+		}
+	}
+
+	if len(records) == 0 {
+		return customZones
+	}
+
+	zones := make([]nbdns.CustomZone, 0, len(customZones)+1)
+	zones = append(zones, customZones...)
+	return append(zones, nbdns.CustomZone{
+		Domain:               zoneDomain,
+		Records:              records,
+		SearchDomainDisabled: true,
+	})
+}
+
+// reverseZoneDomain returns the in-addr.arpa domain of the IPv4 network, e.g. 64.100.in-addr.arpa. for 100.64.0.0/16.
+// Networks not aligned to an octet use the enclosing zone. An empty string is returned for other networks
+func reverseZoneDomain(network *net.IPNet) string {
+	if network == nil {
+		return ""
+	}
+	addr, ok := netip.AddrFromSlice(network.IP)
+	if !ok || !addr.Unmap().Is4() {
+		return ""
+	}
+	ones, _ := network.Mask.Size()
+	octets := ones / 8
+	if octets == 0 {
+		return ""
+	}
+
+	ip := addr.Unmap().As4()
+	labels := make([]string, 0, octets+2)
+	for i := octets - 1; i >= 0; i-- {
+		labels = append(labels, strconv.Itoa(int(ip[i])))
+	}
+	labels = append(labels, "in-addr", "arpa")
+	return dns.Fqdn(strings.Join(labels, "."))
+}
@@ -0,0 +1,87 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+)
+
+func TestReverseZoneDomain(t *testing.T) {
+	testCases := []struct {
+		network  string
+		expected string
+	}{
+		{network: "100.64.0.0/16", expected: "64.100.in-addr.arpa."},
+		{network: "100.64.0.0/10", expected: "100.in-addr.arpa."},
+		{network: "10.1.2.0/24", expected: "2.1.10.in-addr.arpa."},
+		{network: "0.0.0.0/0", expected: ""},
+		{network: "fd00::/64", expected: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.network, func(t *testing.T) {
+			_, network, err := net.ParseCIDR(testCase.network)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, reverseZoneDomain(network))
+		})
+	}
+}
+
+func TestAddReverseZone(t *testing.T) {
+	_, network, err := net.ParseCIDR("100.64.0.0/16")
+	require.NoError(t, err)
+
+	customZones := []nbdns.CustomZone{
+		{
+			Domain: "netbird.cloud.",
+			Records: []nbdns.SimpleRecord{
+				{Name: "peera.netbird.cloud.", Type: int(dns.TypeA), Class: nbdns.DefaultClass, TTL: 300, RData: "100.64.0.1"},
+				{Name: "peerb.netbird.cloud.", Type: int(dns.TypeA), Class: nbdns.DefaultClass, TTL: 300, RData: "100.64.0.2"},
+				{Name: "duplicate.netbird.cloud.", Type: int(dns.TypeA), Class: nbdns.DefaultClass, TTL: 300, RData: "100.64.0.2"},
+				{Name: "outside.netbird.cloud.", Type: int(dns.TypeA), Class: nbdns.DefaultClass, TTL: 300, RData: "10.0.0.1"},
+				{Name: "alias.netbird.cloud.", Type: int(dns.TypeCNAME), Class: nbdns.DefaultClass, TTL: 300, RData: "peera.netbird.cloud."},
+			},
+		},
+	}
+
+	zones := addReverseZone(customZones, network)
+	require.Len(t, zones, 2)
+	assert.Len(t, customZones, 1, "custom zones shouldn't be modified")
+
+	reverseZone := zones[1]
+	assert.Equal(t, "64.100.in-addr.arpa.", reverseZone.Domain)
+	assert.True(t, reverseZone.SearchDomainDisabled, "reverse zone shouldn't be a search domain")
+	assert.Equal(t, []nbdns.SimpleRecord{
+		{Name: "1.0.64.100.in-addr.arpa.", Type: int(dns.TypePTR), Class: nbdns.DefaultClass, TTL: 300, RData: "peera.netbird.cloud."},
+		{Name: "2.0.64.100.in-addr.arpa.", Type: int(dns.TypePTR), Class: nbdns.DefaultClass, TTL: 300, RData: "peerb.netbird.cloud."},
+	}, reverseZone.Records)
+
+	assert.Equal(t, zones, addReverseZone(zones, network), "existing reverse zone shouldn't be replaced")
+	assert.Empty(t, addReverseZone(nil, network), "reverse zone without records shouldn't be added")
+
+	resolver := &localResolver{
+		registeredMap: make(registrationMap),
+	}
+	for _, record := range reverseZone.Records {
+		require.NoError(t, resolver.registerRecord(record))
+	}
+
+	var responseMSG *dns.Msg
+	responseWriter := &mockResponseWriter{
+		WriteMsgFunc: func(m *dns.Msg) error {
+			responseMSG = m
+			return nil
+		},
+	}
+	resolver.ServeDNS(responseWriter, new(dns.Msg).SetQuestion("1.0.64.100.in-addr.arpa.", dns.TypePTR))
+	require.NotNil(t, responseMSG)
+	require.Len(t, responseMSG.Answer, 1)
+	ptr, ok := responseMSG.Answer[0].(*dns.PTR)
+	require.True(t, ok, "answer should be a PTR record")
+	assert.Equal(t, "peera.netbird.cloud.", ptr.Ptr)
+}
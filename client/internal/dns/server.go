@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"net/netip"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/miekg/dns"
 	"github.com/mitchellh/hashstructure/v2"
@@ -32,6 +35,7 @@ type Server interface {
 	UpdateDNSServer(serial uint64, update nbdns.Config) error
 	OnUpdatedHostDNSServer(strings []string)
 	SearchDomains() []string
+	SetQueryObserver(observer QueryObserver)
 }
 
 type registeredHandlerMap map[string]handlerWithStop
@@ -58,6 +62,8 @@ type DefaultServer struct {
 	// make sense on mobile only
 	searchDomainNotifier *notifier
 	iosDnsManager        IosDnsManager
+
+	queryObserver atomic.Pointer[QueryObserver]
 }
 
 type handlerWithStop interface {
@@ -257,6 +263,10 @@ func (s *DefaultServer) applyConfiguration(update nbdns.Config) error {
 		s.service.Stop()
 	}
 
+	if s.wgInterface != nil {
+		update.CustomZones = addReverseZone(update.CustomZones, s.wgInterface.Address().Network)
+	}
+
 	localMuxUpdates, localRecords, err := s.buildLocalHandlerUpdate(update.CustomZones)
 	if err != nil {
 		return fmt.Errorf("not applying dns update, error: %v", err)
@@ -289,9 +299,9 @@ func (s *DefaultServer) applyConfiguration(update nbdns.Config) error {
 	return nil
 }
 
-func (s *DefaultServer) buildLocalHandlerUpdate(customZones []nbdns.CustomZone) ([]muxUpdate, map[string]nbdns.SimpleRecord, error) {
+func (s *DefaultServer) buildLocalHandlerUpdate(customZones []nbdns.CustomZone) ([]muxUpdate, map[string][]nbdns.SimpleRecord, error) {
 	var muxUpdates []muxUpdate
-	localRecords := make(map[string]nbdns.SimpleRecord, 0)
+	localRecords := make(map[string][]nbdns.SimpleRecord, 0)
 
 	for _, customZone := range customZones {
 
@@ -310,7 +320,12 @@ func (s *DefaultServer) buildLocalHandlerUpdate(customZones []nbdns.CustomZone)
 				return nil, nil, fmt.Errorf("received an invalid class type: %s", record.Class)
 			}
 			key := buildRecordKey(record.Name, class, uint16(record.Type))
-			localRecords[key] = record
+			if record.Type == int(dns.TypeSRV) || record.Type == int(dns.TypeTXT) {
+				// a service advertised by several peers has one record per peer, a peer has one TXT record per label
+				localRecords[key] = append(localRecords[key], record)
+			} else {
+				localRecords[key] = []nbdns.SimpleRecord{record}
+			}
 		}
 	}
 	return muxUpdates, localRecords, nil
@@ -318,6 +333,10 @@ func (s *DefaultServer) buildLocalHandlerUpdate(customZones []nbdns.CustomZone)
 
 func (s *DefaultServer) buildUpstreamHandlerUpdate(nameServerGroups []*nbdns.NameServerGroup) ([]muxUpdate, error) {
 
+	// the groups with the lowest priority value are registered first and keep the domains shared with other groups
+	nameServerGroups = sortNSGroupsByPriority(nameServerGroups)
+	registeredDomains := make(map[string]struct{})
+
 	var muxUpdates []muxUpdate
 	for _, nsGroup := range nameServerGroups {
 		if len(nsGroup.NameServers) == 0 {
@@ -354,40 +373,68 @@ func (s *DefaultServer) buildUpstreamHandlerUpdate(nameServerGroups []*nbdns.Nam
 		// contains this upstream settings (temporal deactivation not removed it)
 		handler.deactivate, handler.reactivate = s.upstreamCallbacks(nsGroup, handler)
 
-		if nsGroup.Primary {
-			muxUpdates = append(muxUpdates, muxUpdate{
-				domain:  nbdns.RootZone,
-				handler: handler,
-			})
-			continue
+		if nsGroup.ForwardViaRoutes && !nsGroup.Primary && len(nsGroup.Domains) > 0 {
+			handler.startHealthCheck(nsGroup.Domains[0])
 		}
 
-		if len(nsGroup.Domains) == 0 {
-			handler.stop()
-			return nil, fmt.Errorf("received a non primary nameserver group with an empty domain list")
+		domains := []string{nbdns.RootZone}
+		if !nsGroup.Primary {
+			if len(nsGroup.Domains) == 0 {
+				handler.stop()
+				return nil, fmt.Errorf("received a non primary nameserver group with an empty domain list")
+			}
+
+			domains = nsGroup.Domains
+			for _, domain := range domains {
+				if domain == "" {
+					handler.stop()
+					return nil, fmt.Errorf("received a nameserver group with an empty domain element")
+				}
+			}
 		}
 
-		for _, domain := range nsGroup.Domains {
-			if domain == "" {
-				handler.stop()
-				return nil, fmt.Errorf("received a nameserver group with an empty domain element")
+		registered := false
+		for _, domain := range domains {
+			key := dns.Fqdn(strings.ToLower(domain))
+			if _, ok := registeredDomains[key]; ok {
+				log.Debugf("domain %s is resolved by a nameserver group with a higher priority, skipping nameservers %v",
+					domain, handler.upstreamServers)
+				continue
 			}
+			registeredDomains[key] = struct{}{}
+
 			muxUpdates = append(muxUpdates, muxUpdate{
 				domain:  domain,
 				handler: handler,
 			})
+			registered = true
+		}
+
+		if !registered {
+			handler.stop()
 		}
 	}
 	return muxUpdates, nil
 }
 
+// sortNSGroupsByPriority returns the nameserver groups ordered by priority, the order of the groups with the same
+// priority is kept
+func sortNSGroupsByPriority(nameServerGroups []*nbdns.NameServerGroup) []*nbdns.NameServerGroup {
+	sorted := make([]*nbdns.NameServerGroup, len(nameServerGroups))
+	copy(sorted, nameServerGroups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	return sorted
+}
+
 func (s *DefaultServer) updateMux(muxUpdates []muxUpdate) {
 	muxUpdateMap := make(registeredHandlerMap)
 
 	var isContainRootUpdate bool
 
 	for _, update := range muxUpdates {
-		s.service.RegisterMux(update.domain, update.handler)
+		s.registerMux(update.domain, update.handler)
 		muxUpdateMap[update.domain] = update.handler
 		if existingHandler, ok := s.dnsMuxMap[update.domain]; ok {
 			existingHandler.stop()
@@ -416,7 +463,7 @@ func (s *DefaultServer) updateMux(muxUpdates []muxUpdate) {
 	s.dnsMuxMap = muxUpdateMap
 }
 
-func (s *DefaultServer) updateLocalResolver(update map[string]nbdns.SimpleRecord) {
+func (s *DefaultServer) updateLocalResolver(update map[string][]nbdns.SimpleRecord) {
 	for key := range s.localResolver.registeredMap {
 		_, found := update[key]
 		if !found {
@@ -425,10 +472,10 @@ func (s *DefaultServer) updateLocalResolver(update map[string]nbdns.SimpleRecord
 	}
 
 	updatedMap := make(registrationMap)
-	for key, record := range update {
-		err := s.localResolver.registerRecord(record)
+	for key, records := range update {
+		err := s.localResolver.registerRecords(records)
 		if err != nil {
-			log.Warnf("got an error while registering the record (%s), error: %v", record.String(), err)
+			log.Warnf("got an error while registering the records of %s, error: %v", key, err)
 		}
 		updatedMap[key] = struct{}{}
 	}
@@ -484,7 +531,7 @@ func (s *DefaultServer) upstreamCallbacks(
 				continue
 			}
 			s.currentConfig.Domains[i].Disabled = false
-			s.service.RegisterMux(domain, handler)
+			s.registerMux(domain, handler)
 		}
 
 		l := log.WithField("nameservers", nsGroup.NameServers)
@@ -523,5 +570,5 @@ func (s *DefaultServer) addHostRootZone() {
 	}
 	handler.deactivate = func() {}
 	handler.reactivate = func() {}
-	s.service.RegisterMux(nbdns.RootZone, handler)
+	s.registerMux(nbdns.RootZone, handler)
 }
@@ -0,0 +1,7 @@
+//go:build freebsd || openbsd
+
+package dns
+
+func (s *DefaultServer) initialize() (manager hostManager, err error) {
+	return newHostManager(s.wgInterface)
+}
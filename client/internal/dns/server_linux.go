@@ -2,6 +2,21 @@
 
 package dns
 
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// envSkipHostDNS disables the host DNS configuration, e.g. when the client runs in the network namespace of a
+// container while sharing the file system of the host
+const envSkipHostDNS = "NB_SKIP_DNS_HOST_CONFIG"
+
 func (s *DefaultServer) initialize() (manager hostManager, err error) {
+	if strings.ToLower(os.Getenv(envSkipHostDNS)) == "true" {
+		log.Infof("host DNS configuration is disabled by %s", envSkipHostDNS)
+		return newNoopHostMocker(), nil
+	}
 	return newHostManager(s.wgInterface)
 }
@@ -0,0 +1,69 @@
+//go:build !ios
+
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+)
+
+type namedMocWGIface struct {
+	mocWGIface
+}
+
+func (w *namedMocWGIface) Name() string {
+	return "utun2301"
+}
+
+func TestBuildUpstreamHandlerUpdate_Priority(t *testing.T) {
+	server := DefaultServer{
+		ctx:         context.Background(),
+		wgInterface: &namedMocWGIface{},
+	}
+
+	newNSGroup := func(ip string, primary bool, domains []string, priority int) *nbdns.NameServerGroup {
+		return &nbdns.NameServerGroup{
+			NameServers: []nbdns.NameServer{{IP: netip.MustParseAddr(ip), NSType: nbdns.UDPNameServerType, Port: 53}},
+			Primary:     primary,
+			Domains:     domains,
+			Priority:    priority,
+		}
+	}
+
+	muxUpdates, err := server.buildUpstreamHandlerUpdate([]*nbdns.NameServerGroup{
+		newNSGroup("8.8.8.8", true, nil, 20),
+		newNSGroup("9.9.9.9", false, []string{"example.com", "other.com"}, 20),
+		newNSGroup("1.1.1.1", true, nil, 10),
+		newNSGroup("10.0.0.1", false, []string{"example.com"}, 0),
+	})
+	if err != nil {
+		t.Fatalf("failed to build the upstream handlers: %v", err)
+	}
+	defer func() {
+		for _, update := range muxUpdates {
+			update.handler.stop()
+		}
+	}()
+
+	expected := map[string]string{
+		"example.com":  "10.0.0.1:53",
+		nbdns.RootZone: "1.1.1.1:53",
+		"other.com":    "9.9.9.9:53",
+	}
+	if len(muxUpdates) != len(expected) {
+		t.Fatalf("expected %d mux updates, got %d", len(expected), len(muxUpdates))
+	}
+	for _, update := range muxUpdates {
+		handler, ok := update.handler.(*upstreamResolverNonIOS)
+		if !ok {
+			t.Fatalf("unexpected handler type %T", update.handler)
+		}
+		if got := strings.Join(handler.upstreamServers, ","); got != expected[update.domain] {
+			t.Errorf("expected domain %s to be resolved by %s, got %s", update.domain, expected[update.domain], got)
+		}
+	}
+}
@@ -825,3 +825,29 @@ func newDnsResolver(ip string, port int) *net.Resolver {
 		},
 	}
 }
+
+func TestDNSConfigToHostDNSConfig_SearchDomains(t *testing.T) {
+	hostConfig := dnsConfigToHostDNSConfig(nbdns.Config{
+		SearchDomains: []string{"corp.example.com.", "ns.example.com", "corp.example.com"},
+		NameServerGroups: []*nbdns.NameServerGroup{{
+			NameServers: []nbdns.NameServer{{IP: netip.MustParseAddr("10.0.0.1"), NSType: nbdns.UDPNameServerType, Port: 53}},
+			Domains:     []string{"ns.example.com", "match.example.com"},
+		}},
+		CustomZones: []nbdns.CustomZone{{Domain: "netbird.cloud."}},
+	}, "100.66.100.1", 53)
+
+	expected := []DomainConfig{
+		{Domain: "corp.example.com"},
+		{Domain: "ns.example.com"},
+		{Domain: "match.example.com", MatchOnly: true},
+		{Domain: "netbird.cloud"},
+	}
+	if len(hostConfig.Domains) != len(expected) {
+		t.Fatalf("expected domains %v, got %v", expected, hostConfig.Domains)
+	}
+	for i, domain := range expected {
+		if hostConfig.Domains[i] != domain {
+			t.Errorf("expected domain %v at position %d, got %v", domain, i, hostConfig.Domains[i])
+		}
+	}
+}
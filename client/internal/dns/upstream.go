@@ -16,9 +16,10 @@ import (
 )
 
 const (
-	failsTillDeact   = int32(5)
-	reactivatePeriod = 30 * time.Second
-	upstreamTimeout  = 15 * time.Second
+	failsTillDeact      = int32(5)
+	reactivatePeriod    = 30 * time.Second
+	upstreamTimeout     = 15 * time.Second
+	healthCheckInterval = 30 * time.Second
 )
 
 type upstreamClient interface {
@@ -42,6 +43,14 @@ type upstreamResolverBase struct {
 	reactivatePeriod time.Duration
 	upstreamTimeout  time.Duration
 
+	// forwardViaRoutes is set when the upstream servers are reachable only through the routes of the network. They
+	// are health checked over the tunnel and the responsive ones are queried first
+	forwardViaRoutes    bool
+	healthCheckInterval time.Duration
+	// unhealthy holds the upstream servers that failed the last health check or query
+	unhealthy   map[string]struct{}
+	healthMutex sync.Mutex
+
 	deactivate func()
 	reactivate func()
 }
@@ -50,11 +59,12 @@ func newUpstreamResolverBase(parentCTX context.Context) *upstreamResolverBase {
 	ctx, cancel := context.WithCancel(parentCTX)
 
 	return &upstreamResolverBase{
-		ctx:              ctx,
-		cancel:           cancel,
-		upstreamTimeout:  upstreamTimeout,
-		reactivatePeriod: reactivatePeriod,
-		failsTillDeact:   failsTillDeact,
+		ctx:                 ctx,
+		cancel:              cancel,
+		upstreamTimeout:     upstreamTimeout,
+		reactivatePeriod:    reactivatePeriod,
+		failsTillDeact:      failsTillDeact,
+		healthCheckInterval: healthCheckInterval,
 	}
 }
 
@@ -75,7 +85,7 @@ func (u *upstreamResolverBase) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	default:
 	}
 
-	for _, upstream := range u.upstreamServers {
+	for _, upstream := range u.orderedUpstreams() {
 
 		rm, t, err := u.upstreamClient.exchange(upstream, r)
 
@@ -83,6 +93,7 @@ func (u *upstreamResolverBase) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			if err == context.DeadlineExceeded || isTimeout(err) {
 				log.WithError(err).WithField("upstream", upstream).
 					Warn("got an error while connecting to upstream")
+				u.setHealth(upstream, false)
 				continue
 			}
 			u.failsCount.Add(1)
@@ -104,6 +115,7 @@ func (u *upstreamResolverBase) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		}
 
 		log.Tracef("took %s to query the upstream %s", t, upstream)
+		u.setHealth(upstream, true)
 
 		err = w.WriteMsg(rm)
 		if err != nil {
@@ -130,6 +142,12 @@ func (u *upstreamResolverBase) checkUpstreamFails() {
 		return
 	}
 
+	// the domains forwarded via routes can be resolved by these upstream servers only, deactivating them would send
+	// the queries to the host resolvers
+	if u.forwardViaRoutes {
+		return
+	}
+
 	select {
 	case <-u.ctx.Done():
 		return
@@ -190,6 +208,86 @@ func (u *upstreamResolverBase) waitUntilResponse() {
 	u.disabled = false
 }
 
+// startHealthCheck marks the upstream servers as reachable only through routes and checks, every health check
+// interval until the resolver is stopped, that they answer the queries for the domain over the tunnel
+func (u *upstreamResolverBase) startHealthCheck(domain string) {
+	u.forwardViaRoutes = true
+	r := new(dns.Msg).SetQuestion(dns.Fqdn(domain), dns.TypeSOA)
+
+	go func() {
+		ticker := time.NewTicker(u.healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			for _, upstream := range u.upstreamServers {
+				_, _, err := u.upstreamClient.exchange(upstream, r)
+				if u.ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					log.Tracef("health check of upstream %s failed: %v", upstream, err)
+				}
+				u.setHealth(upstream, err == nil)
+			}
+
+			select {
+			case <-u.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// setHealth records whether the upstream server answered the last health check or query
+func (u *upstreamResolverBase) setHealth(upstream string, healthy bool) {
+	if !u.forwardViaRoutes {
+		return
+	}
+
+	u.healthMutex.Lock()
+	defer u.healthMutex.Unlock()
+
+	_, wasUnhealthy := u.unhealthy[upstream]
+	switch {
+	case healthy && wasUnhealthy:
+		log.Infof("upstream %s is reachable via routes again", upstream)
+		delete(u.unhealthy, upstream)
+	case !healthy && !wasUnhealthy:
+		log.Warnf("upstream %s is not reachable via routes, using it as fallback only", upstream)
+		if u.unhealthy == nil {
+			u.unhealthy = make(map[string]struct{})
+		}
+		u.unhealthy[upstream] = struct{}{}
+	}
+}
+
+// orderedUpstreams returns the upstream servers to query in order. When forwarding via routes the healthy servers
+// come first and the unhealthy ones are kept as fallback, both in their configured order
+func (u *upstreamResolverBase) orderedUpstreams() []string {
+	if !u.forwardViaRoutes {
+		return u.upstreamServers
+	}
+
+	u.healthMutex.Lock()
+	defer u.healthMutex.Unlock()
+
+	if len(u.unhealthy) == 0 {
+		return u.upstreamServers
+	}
+
+	ordered := make([]string, 0, len(u.upstreamServers))
+	var fallback []string
+	for _, upstream := range u.upstreamServers {
+		if _, ok := u.unhealthy[upstream]; ok {
+			fallback = append(fallback, upstream)
+			continue
+		}
+		ordered = append(ordered, upstream)
+	}
+	return append(ordered, fallback...)
+}
+
 // isTimeout returns true if the given error is a network timeout error.
 //
 // Copied from k8s.io/apimachinery/pkg/util/net.IsTimeout
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -168,3 +169,85 @@ func TestUpstreamResolver_DeactivationReactivation(t *testing.T) {
 		t.Errorf("should be enabled")
 	}
 }
+
+type mockUpstreamClientFunc func(upstream string, r *dns.Msg) (*dns.Msg, time.Duration, error)
+
+func (f mockUpstreamClientFunc) exchange(upstream string, r *dns.Msg) (*dns.Msg, time.Duration, error) {
+	return f(upstream, r)
+}
+
+func TestUpstreamResolver_ForwardViaRoutes(t *testing.T) {
+	var mu sync.Mutex
+	reachable := map[string]bool{"10.10.0.1:53": false, "10.10.0.2:53": true}
+	var queried []string
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resolver := newUpstreamResolverBase(ctx)
+	resolver.upstreamServers = []string{"10.10.0.1:53", "10.10.0.2:53"}
+	resolver.healthCheckInterval = 10 * time.Millisecond
+	resolver.failsTillDeact = 0
+	resolver.deactivate = func() {
+		t.Errorf("upstreams forwarding via routes should not be deactivated")
+	}
+	resolver.upstreamClient = mockUpstreamClientFunc(func(upstream string, r *dns.Msg) (*dns.Msg, time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Question[0].Qtype == dns.TypeA {
+			queried = append(queried, upstream)
+		}
+		if !reachable[upstream] {
+			return nil, 0, context.DeadlineExceeded
+		}
+		rm := new(dns.Msg).SetReply(r)
+		return rm, time.Millisecond, nil
+	})
+
+	resolver.startHealthCheck("corp.example.com")
+
+	waitOrder := func(expected []string) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			order := resolver.orderedUpstreams()
+			if order[0] == expected[0] && order[1] == expected[1] {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("upstreams not ordered as %v, got %v", expected, resolver.orderedUpstreams())
+	}
+
+	waitOrder([]string{"10.10.0.2:53", "10.10.0.1:53"})
+
+	var responseMSG *dns.Msg
+	responseWriter := &mockResponseWriter{
+		WriteMsgFunc: func(m *dns.Msg) error {
+			responseMSG = m
+			return nil
+		},
+	}
+	resolver.ServeDNS(responseWriter, new(dns.Msg).SetQuestion("host.corp.example.com.", dns.TypeA))
+	if responseMSG == nil {
+		t.Fatalf("should write a response message")
+	}
+
+	mu.Lock()
+	if len(queried) != 1 || queried[0] != "10.10.0.2:53" {
+		t.Errorf("the healthy upstream should be queried first, queried %v", queried)
+	}
+	reachable = map[string]bool{"10.10.0.1:53": true, "10.10.0.2:53": false}
+	mu.Unlock()
+
+	waitOrder([]string{"10.10.0.1:53", "10.10.0.2:53"})
+
+	mu.Lock()
+	reachable = map[string]bool{}
+	mu.Unlock()
+
+	resolver.ServeDNS(responseWriter, new(dns.Msg).SetQuestion("host.corp.example.com.", dns.TypeA))
+	if resolver.disabled {
+		t.Errorf("upstreams forwarding via routes should not be disabled")
+	}
+}
@@ -0,0 +1,328 @@
+// Package docker implements a Docker network driver plugin. Every container attached to a network of the driver
+// joins the NetBird network with its own peer identity: the driver enrolls the container with the setup key of the
+// network or of the endpoint and runs a client engine in the network namespace of the container.
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSocketPath is where Docker discovers the driver plugin
+	DefaultSocketPath = "/run/docker/plugins/netbird.sock"
+	// DefaultStateDir holds the options of the networks and the configs of the container peers
+	DefaultStateDir = "/var/lib/netbird/docker"
+
+	// SetupKeyOption is the network or endpoint driver option holding the setup key enrolling the containers
+	SetupKeyOption = "setup-key"
+	// ManagementURLOption is the network or endpoint driver option holding the Management service URL
+	ManagementURLOption = "management-url"
+	// HostnameOption is the endpoint driver option holding the hostname of the container peer
+	HostnameOption = "hostname"
+
+	genericOptionsKey = "com.docker.network.generic"
+	contentType       = "application/vnd.docker.plugins.v1.2+json"
+
+	stopTimeout = 10 * time.Second
+)
+
+// StartFunc starts the client engine of a container in the network namespace at nsPath, writing its output to logFile
+type StartFunc func(args, env []string, logFile *os.File, nsPath string) (*os.Process, error)
+
+// Driver is a Docker network driver running a client engine per attached container
+type Driver struct {
+	stateDir string
+	start    StartFunc
+
+	mu        sync.Mutex
+	endpoints map[string]*endpoint
+}
+
+type endpoint struct {
+	process *os.Process
+	done    chan struct{}
+}
+
+// NewDriver returns a driver keeping its state in stateDir and starting the engines with start
+func NewDriver(stateDir string, start StartFunc) *Driver {
+	return &Driver{
+		stateDir:  stateDir,
+		start:     start,
+		endpoints: make(map[string]*endpoint),
+	}
+}
+
+// Handler returns the handler of the Docker network driver plugin protocol
+func (d *Driver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugin.Activate", reply(func() (any, error) {
+		return map[string][]string{"Implements": {"NetworkDriver"}}, nil
+	}))
+	mux.HandleFunc("/NetworkDriver.GetCapabilities", reply(func() (any, error) {
+		return map[string]string{"Scope": "local", "ConnectivityScope": "local"}, nil
+	}))
+	mux.HandleFunc("/NetworkDriver.CreateNetwork", d.handle(d.createNetwork))
+	mux.HandleFunc("/NetworkDriver.DeleteNetwork", d.handle(d.deleteNetwork))
+	mux.HandleFunc("/NetworkDriver.CreateEndpoint", d.handle(d.createEndpoint))
+	mux.HandleFunc("/NetworkDriver.DeleteEndpoint", d.handle(d.deleteEndpoint))
+	mux.HandleFunc("/NetworkDriver.EndpointOperInfo", reply(func() (any, error) {
+		return map[string]any{"Value": map[string]any{}}, nil
+	}))
+	mux.HandleFunc("/NetworkDriver.Join", d.handle(d.join))
+	mux.HandleFunc("/NetworkDriver.Leave", d.handle(d.leave))
+	for _, noop := range []string{"DiscoverNew", "DiscoverDelete", "ProgramExternalConnectivity", "RevokeExternalConnectivity"} {
+		mux.HandleFunc("/NetworkDriver."+noop, reply(func() (any, error) {
+			return map[string]any{}, nil
+		}))
+	}
+	return mux
+}
+
+// Stop stops the engines of all the joined containers
+func (d *Driver) Stop() {
+	d.mu.Lock()
+	endpoints := d.endpoints
+	d.endpoints = make(map[string]*endpoint)
+	d.mu.Unlock()
+
+	for endpointID, ep := range endpoints {
+		stopEndpoint(endpointID, ep)
+	}
+}
+
+// request holds the fields of the driver requests
+type request struct {
+	NetworkID  string
+	EndpointID string
+	SandboxKey string
+	Options    map[string]any
+}
+
+func (d *Driver) createNetwork(req request) error {
+	options := stringOptions(req.Options[genericOptionsKey])
+	if options[SetupKeyOption] == "" {
+		return fmt.Errorf("the %s driver option is required", SetupKeyOption)
+	}
+	return d.writeOptions(d.networkPath(req.NetworkID), options)
+}
+
+func (d *Driver) deleteNetwork(req request) error {
+	if err := os.Remove(d.networkPath(req.NetworkID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// createEndpoint stores the options of the endpoint over the ones of the network
+func (d *Driver) createEndpoint(req request) error {
+	options, err := d.readOptions(d.networkPath(req.NetworkID))
+	if err != nil {
+		return fmt.Errorf("network %s: %w", req.NetworkID, err)
+	}
+	for key, value := range stringOptions(req.Options) {
+		switch key {
+		case SetupKeyOption, ManagementURLOption, HostnameOption:
+			options[key] = value
+		}
+	}
+	if options[HostnameOption] == "" {
+		options[HostnameOption] = "docker-" + shortID(req.EndpointID)
+	}
+
+	if err := os.MkdirAll(d.endpointDir(req.EndpointID), 0700); err != nil {
+		return err
+	}
+	return d.writeOptions(filepath.Join(d.endpointDir(req.EndpointID), "options.json"), options)
+}
+
+// deleteEndpoint removes the peer identity of the container
+func (d *Driver) deleteEndpoint(req request) error {
+	d.stopEndpoint(req.EndpointID)
+	return os.RemoveAll(d.endpointDir(req.EndpointID))
+}
+
+// join starts the engine of the container in its network namespace
+func (d *Driver) join(req request) error {
+	if req.SandboxKey == "" {
+		return fmt.Errorf("missing sandbox key")
+	}
+
+	dir := d.endpointDir(req.EndpointID)
+	options, err := d.readOptions(filepath.Join(dir, "options.json"))
+	if err != nil {
+		return fmt.Errorf("endpoint %s: %w", req.EndpointID, err)
+	}
+
+	args := []string{"up", "--foreground-mode", "--log-file", "console",
+		"--config", filepath.Join(dir, "config.json"),
+		"--hostname", options[HostnameOption],
+	}
+	if options[ManagementURLOption] != "" {
+		args = append(args, "--management-url", options[ManagementURLOption])
+	}
+	// the setup key is passed in the environment to keep it out of the process list, the DNS configuration of the host
+	// is shared with the engine and must not be changed
+	env := append(os.Environ(), "NB_SETUP_KEY="+options[SetupKeyOption], "NB_SKIP_DNS_HOST_CONFIG=true")
+
+	logFile, err := os.OpenFile(filepath.Join(dir, "client.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	d.stopEndpoint(req.EndpointID)
+
+	process, err := d.start(args, env, logFile, req.SandboxKey)
+	if err != nil {
+		return fmt.Errorf("start the engine of endpoint %s: %w", req.EndpointID, err)
+	}
+
+	ep := &endpoint{process: process, done: make(chan struct{})}
+	go func() {
+		defer close(ep.done)
+		state, err := process.Wait()
+		if err != nil {
+			log.Debugf("failed waiting for the engine of endpoint %s: %v", shortID(req.EndpointID), err)
+			return
+		}
+		log.Infof("engine of endpoint %s exited: %s", shortID(req.EndpointID), state)
+	}()
+
+	d.mu.Lock()
+	d.endpoints[req.EndpointID] = ep
+	d.mu.Unlock()
+
+	log.Infof("started the engine of endpoint %s as peer %s", shortID(req.EndpointID), options[HostnameOption])
+	return nil
+}
+
+// leave stops the engine of the container, the peer identity is kept until the endpoint is deleted
+func (d *Driver) leave(req request) error {
+	d.stopEndpoint(req.EndpointID)
+	return nil
+}
+
+func (d *Driver) stopEndpoint(endpointID string) {
+	d.mu.Lock()
+	ep, ok := d.endpoints[endpointID]
+	delete(d.endpoints, endpointID)
+	d.mu.Unlock()
+
+	if ok {
+		stopEndpoint(endpointID, ep)
+	}
+}
+
+// stopEndpoint terminates the engine, killing it when it doesn't exit in time
+func stopEndpoint(endpointID string, ep *endpoint) {
+	if err := ep.process.Signal(syscall.SIGTERM); err != nil {
+		log.Debugf("failed terminating the engine of endpoint %s: %v", shortID(endpointID), err)
+	}
+
+	select {
+	case <-ep.done:
+	case <-time.After(stopTimeout):
+		log.Warnf("the engine of endpoint %s didn't stop in %s, killing it", shortID(endpointID), stopTimeout)
+		_ = ep.process.Kill()
+		<-ep.done
+	}
+}
+
+func (d *Driver) networkPath(networkID string) string {
+	return filepath.Join(d.stateDir, "networks", filepath.Base(networkID)+".json")
+}
+
+func (d *Driver) endpointDir(endpointID string) string {
+	return filepath.Join(d.stateDir, "endpoints", filepath.Base(endpointID))
+}
+
+// writeOptions persists the options so that the driver keeps working when restarted
+func (d *Driver) writeOptions(path string, options map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	content, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+func (d *Driver) readOptions(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	options := make(map[string]string)
+	if err := json.Unmarshal(content, &options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+func (d *Driver) handle(handler func(request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeResponse(w, http.StatusBadRequest, map[string]string{"Err": fmt.Sprintf("invalid request: %v", err)})
+			return
+		}
+		if err := handler(req); err != nil {
+			log.Warnf("failed handling %s: %v", r.URL.Path, err)
+			writeResponse(w, http.StatusInternalServerError, map[string]string{"Err": err.Error()})
+			return
+		}
+		writeResponse(w, http.StatusOK, map[string]any{})
+	}
+}
+
+func reply(handler func() (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := handler()
+		if err != nil {
+			writeResponse(w, http.StatusInternalServerError, map[string]string{"Err": err.Error()})
+			return
+		}
+		writeResponse(w, http.StatusOK, resp)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Debugf("failed writing the driver response: %v", err)
+	}
+}
+
+// stringOptions returns the string values of the driver options
+func stringOptions(value any) map[string]string {
+	options := make(map[string]string)
+	values, ok := value.(map[string]any)
+	if !ok {
+		return options
+	}
+	for key, v := range values {
+		if s, ok := v.(string); ok {
+			options[key] = s
+		}
+	}
+	return options
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
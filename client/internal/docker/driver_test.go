@@ -0,0 +1,109 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type startCall struct {
+	args   []string
+	env    []string
+	nsPath string
+}
+
+func post(t *testing.T, handler http.Handler, path string, body any) (int, map[string]any) {
+	t.Helper()
+
+	content, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(content)))
+
+	resp := make(map[string]any)
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&resp))
+	return recorder.Code, resp
+}
+
+func TestDriver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test engine process requires a POSIX shell")
+	}
+
+	stateDir := t.TempDir()
+	var calls []startCall
+	driver := NewDriver(stateDir, func(args, env []string, logFile *os.File, nsPath string) (*os.Process, error) {
+		calls = append(calls, startCall{args: args, env: env, nsPath: nsPath})
+		cmd := exec.Command("sleep", "30")
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return cmd.Process, nil
+	})
+	defer driver.Stop()
+	handler := driver.Handler()
+
+	code, resp := post(t, handler, "/Plugin.Activate", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{"NetworkDriver"}, resp["Implements"])
+
+	code, resp = post(t, handler, "/NetworkDriver.CreateNetwork", request{NetworkID: "net1"})
+	assert.Equal(t, http.StatusInternalServerError, code, "a network without setup key should be rejected")
+	assert.Contains(t, resp["Err"], SetupKeyOption)
+
+	code, _ = post(t, handler, "/NetworkDriver.CreateNetwork", request{
+		NetworkID: "net1",
+		Options: map[string]any{genericOptionsKey: map[string]any{
+			SetupKeyOption:      "network-key",
+			ManagementURLOption: "https://api.example.com",
+		}},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = post(t, handler, "/NetworkDriver.CreateEndpoint", request{
+		NetworkID:  "net1",
+		EndpointID: "0123456789abcdef",
+		Options:    map[string]any{SetupKeyOption: "container-key"},
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = post(t, handler, "/NetworkDriver.Join", request{
+		NetworkID:  "net1",
+		EndpointID: "0123456789abcdef",
+		SandboxKey: "/var/run/docker/netns/abc",
+	})
+	require.Equal(t, http.StatusOK, code)
+
+	require.Len(t, calls, 1)
+	assert.Equal(t, "/var/run/docker/netns/abc", calls[0].nsPath)
+	assert.Equal(t, []string{"up", "--foreground-mode", "--log-file", "console",
+		"--config", filepath.Join(stateDir, "endpoints", "0123456789abcdef", "config.json"),
+		"--hostname", "docker-0123456789ab",
+		"--management-url", "https://api.example.com",
+	}, calls[0].args)
+	assert.Contains(t, calls[0].env, "NB_SETUP_KEY=container-key", "the endpoint setup key should override the network one")
+	assert.Contains(t, calls[0].env, "NB_SKIP_DNS_HOST_CONFIG=true")
+	assert.Contains(t, driver.endpoints, "0123456789abcdef")
+
+	code, _ = post(t, handler, "/NetworkDriver.Leave", request{NetworkID: "net1", EndpointID: "0123456789abcdef"})
+	require.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, driver.endpoints, "0123456789abcdef")
+
+	code, _ = post(t, handler, "/NetworkDriver.DeleteEndpoint", request{NetworkID: "net1", EndpointID: "0123456789abcdef"})
+	require.Equal(t, http.StatusOK, code)
+	assert.NoDirExists(t, filepath.Join(stateDir, "endpoints", "0123456789abcdef"))
+
+	code, _ = post(t, handler, "/NetworkDriver.DeleteNetwork", request{NetworkID: "net1"})
+	require.Equal(t, http.StatusOK, code)
+	assert.NoFileExists(t, filepath.Join(stateDir, "networks", "net1.json"))
+}
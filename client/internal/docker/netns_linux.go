@@ -0,0 +1,46 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// StartInNetNS starts the binary in the network namespace at nsPath. The process is forked from a thread moved to
+// the namespace, the thread stays locked so that it is terminated instead of being reused in the namespace
+func StartInNetNS(binary string) StartFunc {
+	return func(args, env []string, logFile *os.File, nsPath string) (*os.Process, error) {
+		ns, err := os.Open(nsPath)
+		if err != nil {
+			return nil, fmt.Errorf("open network namespace: %w", err)
+		}
+		defer ns.Close()
+
+		cmd := exec.Command(binary, args...)
+		cmd.Env = env
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		// the signals sent to the process group of the driver, e.g. on Ctrl+C, don't reach the engines, the driver
+		// stops them
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+		errChan := make(chan error, 1)
+		go func() {
+			runtime.LockOSThread()
+			if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+				errChan <- fmt.Errorf("enter network namespace %s: %w", nsPath, err)
+				return
+			}
+			errChan <- cmd.Start()
+		}()
+
+		if err := <-errChan; err != nil {
+			return nil, err
+		}
+		return cmd.Process, nil
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package docker
+
+import (
+	"errors"
+	"os"
+)
+
+// StartInNetNS is only supported on Linux
+func StartInNetNS(string) StartFunc {
+	return func([]string, []string, *os.File, string) (*os.Process, error) {
+		return nil, errors.New("network namespaces are not supported on this platform")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"net/netip"
 	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,16 +17,37 @@ import (
 	"github.com/pion/ice/v3"
 	"github.com/pion/stun/v2"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slices"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"google.golang.org/protobuf/proto"
 
+	nbacl "github.com/FlintyLemming/netbird/acl"
+	"github.com/FlintyLemming/netbird/client/extension"
 	"github.com/FlintyLemming/netbird/client/firewall"
+	"github.com/FlintyLemming/netbird/client/firewall/flow"
 	"github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/client/firewall/shaper"
 	"github.com/FlintyLemming/netbird/client/internal/acl"
+	"github.com/FlintyLemming/netbird/client/internal/approuting"
 	"github.com/FlintyLemming/netbird/client/internal/dns"
+	"github.com/FlintyLemming/netbird/client/internal/eventbus"
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
+	"github.com/FlintyLemming/netbird/client/internal/health"
+	"github.com/FlintyLemming/netbird/client/internal/latency"
+	"github.com/FlintyLemming/netbird/client/internal/netmonitor"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/client/internal/postquantum"
+	"github.com/FlintyLemming/netbird/client/internal/routeimport"
 	"github.com/FlintyLemming/netbird/client/internal/routemanager"
+	"github.com/FlintyLemming/netbird/client/internal/routeselector"
+	"github.com/FlintyLemming/netbird/client/internal/usage"
 	"github.com/FlintyLemming/netbird/client/internal/wgproxy"
+	"github.com/FlintyLemming/netbird/client/internal/wol"
 	nbssh "github.com/FlintyLemming/netbird/client/ssh"
+	"github.com/FlintyLemming/netbird/client/system"
 	nbdns "github.com/FlintyLemming/netbird/dns"
 	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/iface/bind"
@@ -33,6 +56,7 @@ import (
 	"github.com/FlintyLemming/netbird/route"
 	signal "github.com/FlintyLemming/netbird/signal/client"
 	sProto "github.com/FlintyLemming/netbird/signal/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 	"github.com/FlintyLemming/netbird/util"
 )
 
@@ -45,6 +69,18 @@ const (
 	PeerConnectionTimeoutMin = 30000 // ms
 )
 
+const (
+	// activeRoutesReportDelay coalesces the route changes of a failover into a single report
+	activeRoutesReportDelay = 5 * time.Second
+	// activeRoutesReportInterval refreshes the report, the Management service keeps it for a limited time
+	activeRoutesReportInterval = 5 * time.Minute
+	// roamingSTUNTimeout is how long to wait for a STUN server to discover the mapped address of new local addresses
+	roamingSTUNTimeout = 3 * time.Second
+)
+
+// tracer traces the logins of the peer and the application of the network maps
+var tracer = otel.Tracer("github.com/FlintyLemming/netbird/client/internal")
+
 var ErrResetConnection = fmt.Errorf("reset connection")
 
 // EngineConfig is a config for the Engine
@@ -55,6 +91,13 @@ type EngineConfig struct {
 	// WgAddr is a Wireguard local address (Netbird Network IP)
 	WgAddr string
 
+	// PriorityPeers are the FQDNs, NetBird IPs or WireGuard public keys of the critical peers, e.g. DNS servers,
+	// whose connections are established right away, even with lazy connections, and kept warm
+	PriorityPeers []string
+
+	// WgExtraAddrs are secondary addresses in the CIDR format assigned to the WireGuard interface along with WgAddr
+	WgExtraAddrs []string
+
 	// WgPrivateKey is a Wireguard private key of our peer (it MUST never leave the machine)
 	WgPrivateKey wgtypes.Key
 
@@ -76,6 +119,43 @@ type EngineConfig struct {
 	NATExternalIPs []string
 
 	CustomDNSAddress string
+
+	// PostQuantumEnabled enables the post-quantum pre-shared key negotiation with peers supporting it
+	PostQuantumEnabled bool
+
+	// FlowCollectorURL is the collector receiving the flow records of the userspace firewall, e.g. ipfix://host:port
+	FlowCollectorURL string
+
+	// RouteSelector holds the networks whose routes are ignored, nil accepts all routes
+	RouteSelector *routeselector.RouteSelector
+
+	// ImportBGPRoutes advertises the routes learned by a local BGP daemon to the Management service
+	ImportBGPRoutes bool
+
+	// MaxConcurrentConnections is the number of peer connections establishing at the same time,
+	// 0 uses peer.DefaultMaxConcurrentConnections
+	MaxConcurrentConnections int
+
+	// DefaultDeny drops the traffic exchanged with the routed networks unless a firewall rule accepts it, regardless
+	// of the Management service setting
+	DefaultDeny bool
+
+	// AllowedLocalPorts accept the traffic of the NetBird interface before the firewall rules
+	AllowedLocalPorts []manager.LocalPort
+
+	// Services are the services advertised by this peer in the label:port/protocol format
+	Services []string
+
+	// LazyConnectionThreshold is the number of remote peers above which the peer connections are created on demand,
+	// 0 uses DefaultLazyConnectionThreshold and a negative value creates all the connections upfront
+	LazyConnectionThreshold int
+
+	// StrictDNS drops the DNS queries sent to other resolvers than the NetBird resolver and the nameservers of the
+	// DNS config while the engine runs
+	StrictDNS bool
+
+	// Nftables configures the table, the chains and the hook priorities of the nftables firewall
+	Nftables manager.NftablesOptions
 }
 
 // Engine is a mechanism responsible for reacting on Signal and Management stream events and managing connections to the remote peers.
@@ -86,6 +166,19 @@ type Engine struct {
 	mgmClient mgm.Client
 	// peerConns is a map that holds all the peers that are known to this peer
 	peerConns map[string]*peer.Conn
+	// connLimiter limits the peer connections establishing at the same time, prioritizing the peers active recently
+	connLimiter *peer.ConnLimiter
+
+	// lazyConnections is true when the network map has more peers than the lazy connection threshold
+	lazyConnections bool
+	// lazyPeers holds the config of the peers whose connection isn't created yet, lazyPeerIPs maps their NetBird IP
+	// to their key
+	lazyPeers   map[string]*mgmProto.RemotePeerConfig
+	lazyPeerIPs map[string]string
+	// routingPeers are the peers routing a network of the network map, they are always connected
+	routingPeers map[string]struct{}
+	// priorityPeers are the peers matching the priority peers of the config, they are connected right away and kept warm
+	priorityPeers map[string]struct{}
 
 	// syncMsgMux is used to guarantee sequential Management Service message processing
 	syncMsgMux *sync.Mutex
@@ -116,10 +209,57 @@ type Engine struct {
 	statusRecorder *peer.Status
 
 	firewall     manager.Manager
+	flows        *flow.Aggregator
 	routeManager routemanager.Manager
 	acl          acl.Manager
 
+	// shaper is created when the first bandwidth limit is received
+	shaper          shaper.Shaper
+	bandwidthLimits []shaper.Limit
+
 	dnsServer dns.Server
+
+	// bgpImporter advertises the routes learned by a local BGP daemon when the import is enabled
+	bgpImporter *routeimport.Importer
+
+	// wolRelay broadcasts the Wake-on-LAN packets of other peers to the networks routed by this peer
+	wolRelay *wol.Relay
+
+	// latencyMeasurer reports the latency to a sample of the connected peers when the account enables it
+	latencyMeasurer *latency.Measurer
+
+	// usageReporter reports the daily traffic over the tunnel when the account enables it
+	usageReporter *usage.Reporter
+
+	// networkMonitor roams the direct connections when the local addresses change, it isn't used on mobile platforms
+	networkMonitor *netmonitor.Monitor
+
+	// defaultDeny is the default deny mode applied to the firewall
+	defaultDeny bool
+	// dnsResolvers are the resolvers allowed by the DNS leak protection of the firewall, nil when it isn't applied
+	dnsResolvers []string
+	// fqdn is the domain name of the peer, the extra addresses of the interface resolve with it
+	fqdn string
+
+	// dnsLeakConfig is the last DNS config the DNS leak protection was applied for, nil before the first network map
+	dnsLeakConfig *nbdns.Config
+	// dnsLeakPaused lifts the DNS leak protection while the user authenticates with a captive portal
+	dnsLeakPaused bool
+
+	// appRouter is created when the first app routing config is received
+	appRouter  approuting.Router
+	appRouting *approuting.Config
+
+	// health tracks the components of the engine and restarts them individually when they fail
+	health *health.Registry
+	// latestNetworkMap is the last applied network map, restarted components are brought up to date with it
+	latestNetworkMap *mgmProto.NetworkMap
+
+	// events carries the engine events to the subsystems following them. Some events are published while holding
+	// the syncMsgMux lock, the subscribers taking it must not make the publishers wait
+	events *eventbus.Bus
+	// extensions are the modules compiled into the client following the engine events
+	extensions *extension.Manager
 }
 
 // Peer is an instance of the Connection Peer
@@ -134,13 +274,23 @@ func NewEngine(
 	signalClient signal.Client, mgmClient mgm.Client,
 	config *EngineConfig, mobileDep MobileDependency, statusRecorder *peer.Status,
 ) *Engine {
+	maxConnections := config.MaxConcurrentConnections
+	if maxConnections <= 0 {
+		maxConnections = peer.DefaultMaxConcurrentConnections
+	}
 
+	events := eventbus.New(ctx)
 	return &Engine{
 		ctx:            ctx,
 		cancel:         cancel,
 		signal:         signalClient,
 		mgmClient:      mgmClient,
 		peerConns:      make(map[string]*peer.Conn),
+		connLimiter:    peer.NewConnLimiter(maxConnections),
+		lazyPeers:      make(map[string]*mgmProto.RemotePeerConfig),
+		lazyPeerIPs:    make(map[string]string),
+		routingPeers:   make(map[string]struct{}),
+		priorityPeers:  make(map[string]struct{}),
 		syncMsgMux:     &sync.Mutex{},
 		config:         config,
 		mobileDep:      mobileDep,
@@ -150,6 +300,9 @@ func NewEngine(
 		sshServerFunc:  nbssh.DefaultSSHServer,
 		statusRecorder: statusRecorder,
 		wgProxyFactory: wgproxy.NewFactory(config.WgPort),
+		health:         health.NewRegistry(ctx),
+		events:         events,
+		extensions:     extension.NewManager(ctx, events),
 	}
 }
 
@@ -157,6 +310,8 @@ func (e *Engine) Stop() error {
 	e.syncMsgMux.Lock()
 	defer e.syncMsgMux.Unlock()
 
+	e.notifyGoingOffline()
+
 	err := e.removeAllPeers()
 	if err != nil {
 		return err
@@ -191,9 +346,11 @@ func (e *Engine) Start() error {
 		return err
 	}
 	e.dnsServer = dnsServer
+	e.observeDNSQueries()
 
-	e.routeManager = routemanager.NewManager(e.ctx, e.config.WgPrivateKey.PublicKey().String(), e.wgInterface, e.statusRecorder, initialRoutes)
+	e.routeManager = routemanager.NewManager(e.ctx, e.config.WgPrivateKey.PublicKey().String(), e.wgInterface, e.statusRecorder, e.config.RouteSelector, initialRoutes)
 	e.routeManager.SetRouteChangeListener(e.mobileDep.NetworkChangeListener)
+	e.routeManager.SetActiveRoutesListener(e.notifyActiveRoutesChanged)
 
 	err = e.wgInterfaceCreate()
 	if err != nil {
@@ -202,10 +359,13 @@ func (e *Engine) Start() error {
 		return err
 	}
 
-	e.firewall, err = firewall.NewFirewall(e.ctx, e.wgInterface)
+	e.firewall, err = firewall.NewFirewall(e.ctx, e.wgInterface, e.config.Nftables)
 	if err != nil {
 		log.Errorf("failed creating firewall manager: %s", err)
 	}
+	if e.firewall == nil && e.config.StrictDNS {
+		log.Warnf("the strict DNS mode requires a firewall, the DNS queries to other resolvers won't be blocked")
+	}
 
 	if e.firewall != nil && e.firewall.IsServerRouteSupported() {
 		err = e.routeManager.EnableServerRouter(e.firewall)
@@ -224,6 +384,21 @@ func (e *Engine) Start() error {
 
 	if e.firewall != nil {
 		e.acl = acl.NewDefaultManager(e.firewall)
+		e.updateDefaultDeny(false)
+		e.setAllowedLocalPorts()
+		e.setPacketLogger()
+	}
+
+	if e.firewall != nil && e.config.FlowCollectorURL != "" {
+		e.startFlowExport()
+	}
+
+	if e.config.ImportBGPRoutes {
+		e.startBGPRouteImport()
+	}
+
+	if e.firewall != nil && e.firewall.IsServerRouteSupported() {
+		e.startWakeOnLANRelay()
 	}
 
 	err = e.dnsServer.Initialize()
@@ -232,12 +407,241 @@ func (e *Engine) Start() error {
 		return err
 	}
 
+	e.registerComponents()
+	e.statusRecorder.SetPeerStateObserver(e.notifyPeerState)
+	e.statusRecorder.SetWgStatsGetter(e.wgInterface.GetAllStats)
+	go e.reportActiveRoutes(eventbus.Subscribe[eventbus.ActiveRoutesEvent](e.events, "active routes report", eventbus.Options{QueueSize: 1}))
+	e.subscribeLazyConnections()
+	go peer.NewKeepAliveScheduler(e.wgInterface, e.statusRecorder).Run(e.ctx)
+	go peer.NewWatchdog(e.wgInterface, e.statusRecorder, e.restartPeerConn).Run(e.ctx)
+	e.startNetworkMonitor()
+
 	e.receiveSignalEvents()
 	e.receiveManagementEvents()
 
 	return nil
 }
 
+// registerComponents registers the restart functions of the engine components and exposes their health
+func (e *Engine) registerComponents() {
+	e.health.Register(health.Management, func() error {
+		e.receiveManagementEvents()
+		return nil
+	})
+	e.health.Register(health.Signal, func() error {
+		e.receiveSignalEvents()
+		return nil
+	})
+	e.health.Register(health.DNS, e.restartDNSServer)
+	e.health.Register(health.Routes, e.restartRouteManager)
+	if e.acl != nil {
+		e.health.Register(health.ACL, e.reapplyFiltering)
+	}
+	e.statusRecorder.SetHealthRegistry(e.health)
+}
+
+// restartDNSServer replaces the DNS server with a new one configured from the latest network map
+func (e *Engine) restartDNSServer() error {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	return e.replaceDNSServer()
+}
+
+// replaceDNSServer replaces the DNS server, the caller must hold the syncMsgMux lock
+func (e *Engine) replaceDNSServer() error {
+	e.dnsServer.Stop()
+	e.dnsServer = nil
+
+	_, dnsServer, err := e.newDnsServer()
+	if err != nil {
+		return err
+	}
+	e.dnsServer = dnsServer
+	e.observeDNSQueries()
+
+	err = e.dnsServer.Initialize()
+	if err != nil {
+		return err
+	}
+
+	if e.latestNetworkMap == nil {
+		return nil
+	}
+	protoDNSConfig := e.latestNetworkMap.GetDNSConfig()
+	if protoDNSConfig == nil {
+		protoDNSConfig = &mgmProto.DNSConfig{}
+	}
+	return e.dnsServer.UpdateDNSServer(e.latestNetworkMap.GetSerial(), e.withExtraAddrRecords(toDNSConfig(protoDNSConfig)))
+}
+
+// restartRouteManager replaces the route manager with a new one configured from the latest network map
+func (e *Engine) restartRouteManager() error {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	e.routeManager.Stop()
+	return e.replaceRouteManager()
+}
+
+// replaceRouteManager creates a new route manager after the previous one was stopped, the caller must hold the
+// syncMsgMux lock
+func (e *Engine) replaceRouteManager() error {
+	e.routeManager = routemanager.NewManager(e.ctx, e.config.WgPrivateKey.PublicKey().String(), e.wgInterface, e.statusRecorder, e.config.RouteSelector, nil)
+	e.routeManager.SetRouteChangeListener(e.mobileDep.NetworkChangeListener)
+	e.routeManager.SetActiveRoutesListener(e.notifyActiveRoutesChanged)
+	e.notifyActiveRoutesChanged()
+
+	if e.firewall != nil && e.firewall.IsServerRouteSupported() {
+		err := e.routeManager.EnableServerRouter(e.firewall)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.latestNetworkMap == nil {
+		return nil
+	}
+	return e.routeManager.UpdateRoutes(e.latestNetworkMap.GetSerial(), toRoutes(e.latestNetworkMap.GetRoutes()))
+}
+
+// readdress moves the peer to the address assigned by the Management service without restarting the engine: once the
+// interface has the new address, the components bound to the previous one are recreated from the latest network
+// map. The network map being applied brings them up to date
+func (e *Engine) readdress(newAddr string) error {
+	oldAddr := e.wgInterface.Address().String()
+	log.Debugf("updating peer address from %s to %s", oldAddr, newAddr)
+
+	// the routes and the firewall are kept until the interface has the new address, so a failed update doesn't
+	// leave the peer without them
+	if err := e.wgInterface.UpdateAddr(newAddr, e.config.WgExtraAddrs...); err != nil {
+		// the interface can't be moved, e.g. the netstack one, the engine is started again with the new address
+		log.Warnf("failed updating the interface address to %s, restarting the engine: %v", newAddr, err)
+		_ = CtxGetState(e.ctx).Wrap(ErrResetConnection)
+		e.cancel()
+		return fmt.Errorf("update interface address: %w", err)
+	}
+	e.config.WgAddr = newAddr
+	if runtime.GOOS == "ios" {
+		e.mobileDep.NetworkChangeListener.SetInterfaceIP(newAddr)
+	}
+
+	// the routes and the firewall rules refer to the previous address
+	e.routeManager.Stop()
+	e.recreateFirewall()
+
+	if err := e.replaceRouteManager(); err != nil {
+		log.Errorf("failed recreating the route manager after changing the address: %v", err)
+	}
+	if err := e.replaceDNSServer(); err != nil {
+		log.Errorf("failed recreating the DNS server after changing the address: %v", err)
+	}
+
+	// the SSH server listens on the previous address, it is started again with the SSH config of the peer
+	if !isNil(e.sshServer) {
+		if err := e.sshServer.Stop(); err != nil {
+			log.Debugf("failed stopping the SSH server: %v", err)
+		}
+		e.sshServer = nil
+	}
+
+	if e.wolRelay != nil {
+		e.wolRelay.Stop()
+		e.wolRelay = nil
+		e.startWakeOnLANRelay()
+	}
+
+	// the BGP routes of the new NetBird network are excluded from the import
+	if e.bgpImporter != nil {
+		e.bgpImporter.Stop()
+		e.bgpImporter = nil
+		e.startBGPRouteImport()
+	}
+
+	log.Infof("updated peer address from %s to %s", oldAddr, newAddr)
+	e.statusRecorder.RecordEvent(eventlog.CategoryPeer, fmt.Sprintf("peer address changed from %s to %s", oldAddr, newAddr))
+	return nil
+}
+
+// recreateFirewall creates the firewall again for the current address with the settings of the previous one. The
+// firewall rules are installed again with the network map
+func (e *Engine) recreateFirewall() {
+	if e.firewall == nil {
+		return
+	}
+
+	if err := e.firewall.Reset(); err != nil {
+		log.Warnf("failed to reset firewall after changing the address: %v", err)
+	}
+
+	// the userspace firewall may shape the traffic, the limits are applied again with the network map
+	if s, ok := e.firewall.(shaper.Shaper); ok && e.shaper == s {
+		e.shaper = nil
+		e.bandwidthLimits = nil
+	}
+
+	fw, err := firewall.NewFirewall(e.ctx, e.wgInterface, e.config.Nftables)
+	if err != nil {
+		log.Errorf("failed recreating firewall manager: %s", err)
+		e.firewall = nil
+		e.acl = nil
+		return
+	}
+	e.firewall = fw
+	e.acl = acl.NewDefaultManager(e.firewall)
+
+	// the default deny mode and the DNS leak protection are applied again with the network map
+	e.defaultDeny = false
+	e.dnsResolvers = nil
+	e.setAllowedLocalPorts()
+	e.setPacketLogger()
+
+	if e.flows != nil {
+		if accounting, ok := e.firewall.(interface {
+			SetFlowAggregator(aggregator *flow.Aggregator)
+		}); ok {
+			accounting.SetFlowAggregator(e.flows)
+		}
+	}
+}
+
+// reapplyFiltering applies the firewall rules of the latest network map again. The ACL manager is kept because
+// it tracks the rules already installed in the firewall
+func (e *Engine) reapplyFiltering() error {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	if e.latestNetworkMap != nil {
+		e.acl.ApplyFiltering(e.withExtensionRules(e.latestNetworkMap))
+	}
+	return nil
+}
+
+// restartSSHServer starts the SSH server again if the latest network map enables it
+func (e *Engine) restartSSHServer() error {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	if !isNil(e.sshServer) {
+		if err := e.sshServer.Stop(); err != nil {
+			log.Debugf("failed stopping the failed SSH server: %v", err)
+		}
+		e.sshServer = nil
+	}
+
+	sshConf := e.latestNetworkMap.GetPeerConfig().GetSshConfig()
+	if !sshConf.GetSshEnabled() {
+		return nil
+	}
+
+	err := e.updateSSH(sshConf)
+	if err != nil {
+		return err
+	}
+	e.updateSSHAuthorizedKeys(e.latestNetworkMap.GetRemotePeers())
+	return nil
+}
+
 // modifyPeers updates peers that have been modified (e.g. IP address has been changed).
 // It closes the existing connection, removes it from the peerConns map, and creates a new one.
 func (e *Engine) modifyPeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
@@ -246,8 +650,18 @@ func (e *Engine) modifyPeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
 	var modified []*mgmProto.RemotePeerConfig
 	for _, p := range peersUpdate {
 		peerPubKey := p.GetWgPubKey()
-		if peerConn, ok := e.peerConns[peerPubKey]; ok {
-			if peerConn.WgConfig().AllowedIps != strings.Join(p.AllowedIps, ",") {
+		lazyPeer, lazy := e.lazyPeers[peerPubKey]
+		if lazy {
+			if strings.Join(lazyPeer.GetAllowedIps(), ",") != strings.Join(p.GetAllowedIps(), ",") {
+				modified = append(modified, p)
+				continue
+			}
+			// the connection is created with the latest config
+			e.lazyPeers[peerPubKey] = p
+		}
+		if peerConn, ok := e.peerConns[peerPubKey]; ok || lazy {
+			if ok && (peerConn.WgConfig().AllowedIps != strings.Join(p.AllowedIps, ",") ||
+				peerConn.GetConf().PostQuantum != e.postQuantumSupported(p)) {
 				modified = append(modified, p)
 				continue
 			}
@@ -255,6 +669,18 @@ func (e *Engine) modifyPeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
 			if err != nil {
 				log.Warnf("error updating peer's %s fqdn in the status recorder, got error: %v", peerPubKey, err)
 			}
+			err = e.statusRecorder.UpdatePeerGroups(peerPubKey, p.GetGroups())
+			if err != nil {
+				log.Warnf("error updating peer's %s groups in the status recorder, got error: %v", peerPubKey, err)
+			}
+			err = e.statusRecorder.UpdatePeerServices(peerPubKey, toPeerServices(p.GetServices()))
+			if err != nil {
+				log.Warnf("error updating peer's %s services in the status recorder, got error: %v", peerPubKey, err)
+			}
+			err = e.statusRecorder.UpdatePeerLabels(peerPubKey, p.GetLabels())
+			if err != nil {
+				log.Warnf("error updating peer's %s labels in the status recorder, got error: %v", peerPubKey, err)
+			}
 		}
 	}
 
@@ -278,10 +704,13 @@ func (e *Engine) modifyPeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
 // removePeers finds and removes peers that do not exist anymore in the network map received from the Management Service.
 // It also removes peers that have been modified (e.g. change of IP address). They will be added again in addPeers method.
 func (e *Engine) removePeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
-	currentPeers := make([]string, 0, len(e.peerConns))
+	currentPeers := make([]string, 0, len(e.peerConns)+len(e.lazyPeers))
 	for p := range e.peerConns {
 		currentPeers = append(currentPeers, p)
 	}
+	for p := range e.lazyPeers {
+		currentPeers = append(currentPeers, p)
+	}
 
 	newPeers := make([]string, 0, len(peersUpdate))
 	for _, p := range peersUpdate {
@@ -300,6 +729,29 @@ func (e *Engine) removePeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
 	return nil
 }
 
+// notifyGoingOffline tells the connected peers and the Management service that this peer is shutting down, so the
+// peers close their connections and drop the routes through it right away instead of waiting for them to time out
+func (e *Engine) notifyGoingOffline() {
+	var connected []string
+	for key, conn := range e.peerConns {
+		if conn.Status() == peer.StatusConnected {
+			connected = append(connected, key)
+		}
+	}
+
+	if len(connected) > 0 {
+		err := e.signal.SendGoingOffline(connected)
+		if err != nil {
+			log.Debugf("failed notifying peers that we are going offline: %v", err)
+		}
+	}
+
+	err := e.mgmClient.GoingOffline()
+	if err != nil {
+		log.Debugf("failed notifying Management Service that we are going offline: %v", err)
+	}
+}
+
 func (e *Engine) removeAllPeers() error {
 	log.Debugf("removing all peer connections")
 	for p := range e.peerConns {
@@ -308,6 +760,12 @@ func (e *Engine) removeAllPeers() error {
 			return err
 		}
 	}
+	for p := range e.lazyPeers {
+		err := e.removePeer(p)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -326,6 +784,10 @@ func (e *Engine) removePeer(peerKey string) error {
 		}
 	}()
 
+	if e.removeLazyPeer(peerKey) {
+		return nil
+	}
+
 	conn, exists := e.peerConns[peerKey]
 	if exists {
 		delete(e.peerConns, peerKey)
@@ -358,6 +820,23 @@ func signalCandidate(candidate ice.Candidate, myKey wgtypes.Key, remoteKey wgtyp
 	return nil
 }
 
+// signalRoam signals the candidates of the new local addresses to the remote peer
+func signalRoam(candidates []ice.Candidate, myKey wgtypes.Key, remoteKey wgtypes.Key, s signal.Client) error {
+	marshaled := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		marshaled = append(marshaled, candidate.Marshal())
+	}
+
+	return s.Send(&sProto.Message{
+		Key:       myKey.PublicKey().String(),
+		RemoteKey: remoteKey.String(),
+		Body: &sProto.Body{
+			Type:       sProto.Body_ROAM,
+			Candidates: marshaled,
+		},
+	})
+}
+
 func sendSignal(message *sProto.Message, s signal.Client) error {
 	return s.Send(message)
 }
@@ -379,6 +858,11 @@ func SignalOfferAnswer(offerAnswer peer.OfferAnswer, myKey wgtypes.Key, remoteKe
 		return err
 	}
 
+	if len(offerAnswer.PostQuantumKey) > 0 {
+		msg.Body.PostQuantum = &sProto.PostQuantum{PublicKey: offerAnswer.PostQuantumKey}
+	}
+	msg.Body.TraceContext = offerAnswer.TraceContext
+
 	err = s.Send(msg)
 	if err != nil {
 		return err
@@ -391,6 +875,12 @@ func (e *Engine) handleSync(update *mgmProto.SyncResponse) error {
 	e.syncMsgMux.Lock()
 	defer e.syncMsgMux.Unlock()
 
+	// the Management client outlives the engine so that it can notify the Management service on shutdown,
+	// the updates received meanwhile are ignored
+	if e.ctx.Err() != nil {
+		return nil
+	}
+
 	if update.GetWiretrusteeConfig() != nil {
 		err := e.updateTURNs(update.GetWiretrusteeConfig().GetTurns())
 		if err != nil {
@@ -402,12 +892,40 @@ func (e *Engine) handleSync(update *mgmProto.SyncResponse) error {
 			return err
 		}
 
+		// the connections being negotiated switch to the refreshed TURN credentials before gathering candidates,
+		// the established ones are left untouched
+		stunTurn := e.stunTurn()
+		for _, conn := range e.peerConns {
+			conn.UpdateStunTurn(stunTurn)
+		}
+
 		// todo update signal
 	}
 
+	// a maintenance notice comes alone, the last configuration is kept until the client reconnects
+	if notice := update.GetMaintenance(); notice != nil {
+		e.statusRecorder.MarkManagementMaintenance(notice.GetWindow().AsDuration(), notice.GetMessage())
+		return nil
+	}
+
+	if loginExpired := update.GetLoginExpired(); loginExpired != e.statusRecorder.IsLoginExpired() {
+		if loginExpired {
+			log.Warnf("peer login has expired, network access is limited until the peer logs in again")
+		} else {
+			log.Infof("peer logged in again, network access is restored")
+		}
+		e.statusRecorder.MarkLoginExpired(loginExpired)
+	}
+
 	if update.GetNetworkMap() != nil {
+		// the network map is applied in the trace of the change it was sent for
+		_, span := tracer.Start(tracing.Extract(e.ctx, update.GetTraceContext()), "updateNetworkMap", trace.WithAttributes(
+			attribute.Int64("network_map.serial", int64(update.GetNetworkMap().GetSerial())),
+			attribute.Int("network_map.remote_peers", len(update.GetNetworkMap().GetRemotePeers())),
+		))
 		// only apply new changes and ignore old ones
 		err := e.updateNetworkMap(update.GetNetworkMap())
+		tracing.End(span, err)
 		if err != nil {
 			return err
 		}
@@ -435,18 +953,24 @@ func (e *Engine) updateSSH(sshConf *mgmProto.SSHConfig) error {
 			if err != nil {
 				return err
 			}
-			go func() {
+			e.health.Register(health.SSH, e.restartSSHServer)
+			e.health.Alive(health.SSH)
+			server := e.sshServer
+			e.health.Go(health.SSH, func() {
 				// blocking
-				err = e.sshServer.Start()
+				err := server.Start()
 				if err != nil {
 					// will throw error when we stop it even if it is a graceful stop
 					log.Debugf("stopped SSH server with error %v", err)
 				}
 				e.syncMsgMux.Lock()
 				defer e.syncMsgMux.Unlock()
-				e.sshServer = nil
+				// the server may have been replaced after a restart or a change of address
+				if e.sshServer == server {
+					e.sshServer = nil
+				}
 				log.Infof("stopped SSH server")
-			}()
+			})
 		} else {
 			log.Debugf("SSH server is already running")
 		}
@@ -457,22 +981,20 @@ func (e *Engine) updateSSH(sshConf *mgmProto.SSHConfig) error {
 			log.Warnf("failed to stop SSH server %v", err)
 		}
 		e.sshServer = nil
+		e.health.Unregister(health.SSH)
 	}
 	return nil
 }
 
 func (e *Engine) updateConfig(conf *mgmProto.PeerConfig) error {
 	if e.wgInterface.Address().String() != conf.Address {
-		oldAddr := e.wgInterface.Address().String()
-		log.Debugf("updating peer address from %s to %s", oldAddr, conf.Address)
-		err := e.wgInterface.UpdateAddr(conf.Address)
-		if err != nil {
+		if err := e.readdress(conf.Address); err != nil {
 			return err
 		}
-		e.config.WgAddr = conf.Address
-		log.Infof("updated peer address from %s to %s", oldAddr, conf.Address)
 	}
 
+	e.fqdn = conf.GetFqdn()
+
 	if conf.GetSshConfig() != nil {
 		err := e.updateSSH(conf.GetSshConfig())
 		if err != nil {
@@ -481,21 +1003,31 @@ func (e *Engine) updateConfig(conf *mgmProto.PeerConfig) error {
 	}
 
 	e.statusRecorder.UpdateLocalPeerState(peer.LocalPeerState{
-		IP:              e.config.WgAddr,
-		PubKey:          e.config.WgPrivateKey.PublicKey().String(),
-		KernelInterface: iface.WireGuardModuleIsLoaded(),
-		FQDN:            conf.GetFqdn(),
+		IP:                e.config.WgAddr,
+		PubKey:            e.config.WgPrivateKey.PublicKey().String(),
+		KernelInterface:   iface.WireGuardModuleIsLoaded(),
+		FQDN:              conf.GetFqdn(),
+		AllowedLocalPorts: e.allowedLocalPorts(),
+		Services:          e.config.Services,
+		Labels:            conf.GetLabels(),
 	})
 
+	e.updateLatencyMeasurer(conf.GetLatencyReportsEnabled())
+	e.updateUsageReporter(conf.GetUsageReportsEnabled())
+	e.updateDefaultDeny(conf.GetDefaultDeny())
+	e.updateAppRouting(toAppRoutingConfig(conf.GetAppRouting()))
+
 	return nil
 }
 
 // receiveManagementEvents connects to the Management Service event stream to receive updates from the management service
 // E.g. when a new peer has been registered and we are allowed to connect to it.
 func (e *Engine) receiveManagementEvents() {
-	go func() {
+	e.health.Go(health.Management, func() {
 		err := e.mgmClient.Sync(func(update *mgmProto.SyncResponse) error {
-			return e.handleSync(update)
+			err := e.handleSync(update)
+			e.health.ReportError(health.Management, err)
+			return err
 		})
 		if err != nil {
 			// happens if management is unavailable for a long time.
@@ -505,7 +1037,7 @@ func (e *Engine) receiveManagementEvents() {
 			return
 		}
 		log.Debugf("stopped receiving updates from Management Service")
-	}()
+	})
 	log.Debugf("connecting to Management Service updates stream")
 }
 
@@ -541,12 +1073,43 @@ func (e *Engine) updateTURNs(turns []*mgmProto.ProtectedHostConfig) error {
 		url.Username = turn.User
 		url.Password = turn.Password
 		newTURNs = append(newTURNs, url)
+
+		if expiry, ok := turnCredentialsExpiry(turn.User); ok {
+			log.Debugf("TURN credentials of %s are valid until %s", turn.HostConfig.Uri, expiry.Format(time.RFC3339))
+		}
 	}
 	e.TURNs = newTURNs
 
 	return nil
 }
 
+// stunTurn returns the STUN servers and the TURN servers whose credentials didn't expire. Gathering relay candidates
+// with expired credentials fails, the connections rely on the other candidates until the Management service
+// refreshes them.
+func (e *Engine) stunTurn() []*stun.URI {
+	stunTurn := make([]*stun.URI, 0, len(e.STUNs)+len(e.TURNs))
+	stunTurn = append(stunTurn, e.STUNs...)
+	for _, turn := range e.TURNs {
+		if expiry, ok := turnCredentialsExpiry(turn.Username); ok && time.Now().After(expiry) {
+			log.Debugf("skipping TURN server %s, its credentials expired at %s", turn, expiry.Format(time.RFC3339))
+			continue
+		}
+		stunTurn = append(stunTurn, turn)
+	}
+	return stunTurn
+}
+
+// turnCredentialsExpiry returns the expiry of time based TURN credentials, whose username is the unix time they
+// expire at, optionally followed by a colon and a user name
+func turnCredentialsExpiry(username string) (time.Time, bool) {
+	timestamp, _, _ := strings.Cut(username, ":")
+	expiry, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || expiry <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(expiry, 0), true
+}
+
 func (e *Engine) updateNetworkMap(networkMap *mgmProto.NetworkMap) error {
 
 	// intentionally leave it before checking serial because for now it can happen that peer IP changed but serial didn't
@@ -575,6 +1138,8 @@ func (e *Engine) updateNetworkMap(networkMap *mgmProto.NetworkMap) error {
 			return err
 		}
 	} else {
+		e.updateLazyConnections(networkMap)
+
 		err := e.removePeers(networkMap.GetRemotePeers())
 		if err != nil {
 			return err
@@ -589,47 +1154,130 @@ func (e *Engine) updateNetworkMap(networkMap *mgmProto.NetworkMap) error {
 		if err != nil {
 			return err
 		}
+		e.materializeNeededPeers()
 
 		e.statusRecorder.FinishPeerListModifications()
 
-		// update SSHServer by adding remote peer SSH keys
-		if !isNil(e.sshServer) {
-			for _, config := range networkMap.GetRemotePeers() {
-				if config.GetSshConfig() != nil && config.GetSshConfig().GetSshPubKey() != nil {
-					err := e.sshServer.AddAuthorizedKey(config.WgPubKey, string(config.GetSshConfig().GetSshPubKey()))
-					if err != nil {
-						log.Warnf("failed adding authorized key to SSH DefaultServer %v", err)
-					}
-				}
-			}
-		}
+		e.updateSSHAuthorizedKeys(networkMap.GetRemotePeers())
 	}
 	protoRoutes := networkMap.GetRoutes()
 	if protoRoutes == nil {
 		protoRoutes = []*mgmProto.Route{}
 	}
-	err := e.routeManager.UpdateRoutes(serial, toRoutes(protoRoutes))
+	err := e.health.Run(health.Routes, func() error {
+		return e.routeManager.UpdateRoutes(serial, toRoutes(protoRoutes))
+	})
 	if err != nil {
 		log.Errorf("failed to update routes, err: %v", err)
 	}
 
+	if e.wolRelay != nil {
+		e.wolRelay.UpdateNetworks(e.routedNetworks(protoRoutes))
+	}
+
 	protoDNSConfig := networkMap.GetDNSConfig()
 	if protoDNSConfig == nil {
 		protoDNSConfig = &mgmProto.DNSConfig{}
 	}
 
-	err = e.dnsServer.UpdateDNSServer(serial, toDNSConfig(protoDNSConfig))
+	dnsConfig := e.withExtraAddrRecords(toDNSConfig(protoDNSConfig))
+	err = e.health.Run(health.DNS, func() error {
+		return e.dnsServer.UpdateDNSServer(serial, dnsConfig)
+	})
 	if err != nil {
 		log.Errorf("failed to update dns server, err: %v", err)
+	} else {
+		eventbus.Publish(e.events, eventbus.DNSConfigEvent{Serial: serial, Config: dnsConfig})
 	}
+	e.updateDNSLeakProtection(dnsConfig)
+
+	e.updateBandwidthLimits(toBandwidthLimits(networkMap.GetRemotePeers(), protoRoutes))
 
 	if e.acl != nil {
-		e.acl.ApplyFiltering(networkMap)
+		_ = e.health.Run(health.ACL, func() error {
+			e.acl.ApplyFiltering(e.withExtensionRules(networkMap))
+			return nil
+		})
+		if firewallRulesChanged(e.latestNetworkMap, networkMap) {
+			e.statusRecorder.RecordEvent(eventlog.CategoryACL, fmt.Sprintf("applied %d firewall rules of network map serial %d", len(networkMap.GetFirewallRules()), serial))
+		}
 	}
 	e.networkSerial = serial
+	e.latestNetworkMap = networkMap
+	eventbus.Publish(e.events, eventbus.NetworkMapEvent{Serial: serial, NetworkMap: networkMap})
 	return nil
 }
 
+// withExtensionRules returns the network map with the firewall rules contributed by the extensions appended, the
+// network map is returned as is when there are none
+func (e *Engine) withExtensionRules(networkMap *mgmProto.NetworkMap) *mgmProto.NetworkMap {
+	rules := e.extensions.FirewallRules(networkMap)
+	if len(rules) == 0 {
+		return networkMap
+	}
+
+	extended := proto.Clone(networkMap).(*mgmProto.NetworkMap)
+	if nbacl.FromLegacyManagement(networkMap) {
+		// the legacy allow all rules would be lost with the extension rules
+		extended.FirewallRules = append(extended.FirewallRules, nbacl.NetworkMapRules(networkMap)...)
+	}
+	extended.FirewallRules = append(extended.FirewallRules, rules...)
+	return extended
+}
+
+// observeDNSQueries publishes the queries served by the DNS server
+func (e *Engine) observeDNSQueries() {
+	e.dnsServer.SetQueryObserver(e.publishDNSQuery)
+}
+
+// notifyPeerState publishes the new connection state of a remote peer
+func (e *Engine) notifyPeerState(state peer.State) {
+	eventbus.Publish(e.events, eventbus.PeerStateEvent{
+		PubKey:  state.PubKey,
+		FQDN:    state.FQDN,
+		IP:      state.IP,
+		Status:  state.ConnStatus.String(),
+		Relayed: state.Relayed,
+		Direct:  state.Direct,
+		Groups:  state.Groups,
+	})
+}
+
+// updateSSHAuthorizedKeys adds the SSH keys of the remote peers to the running SSH server
+func (e *Engine) updateSSHAuthorizedKeys(remotePeers []*mgmProto.RemotePeerConfig) {
+	if isNil(e.sshServer) {
+		return
+	}
+	for _, config := range remotePeers {
+		if config.GetSshConfig() != nil && config.GetSshConfig().GetSshPubKey() != nil {
+			err := e.sshServer.AddAuthorizedKey(config.WgPubKey, string(config.GetSshConfig().GetSshPubKey()))
+			if err != nil {
+				log.Warnf("failed adding authorized key to SSH DefaultServer %v", err)
+			}
+		}
+	}
+}
+
+// firewallRulesChanged returns true if the firewall rules of the network map differ from the previous one
+func firewallRulesChanged(previous, current *mgmProto.NetworkMap) bool {
+	if previous == nil {
+		return true
+	}
+	if previous.GetFirewallRulesIsEmpty() != current.GetFirewallRulesIsEmpty() {
+		return true
+	}
+	previousRules, currentRules := previous.GetFirewallRules(), current.GetFirewallRules()
+	if len(previousRules) != len(currentRules) {
+		return true
+	}
+	for i := range currentRules {
+		if !proto.Equal(previousRules[i], currentRules[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 func toRoutes(protoRoutes []*mgmProto.Route) []*route.Route {
 	routes := make([]*route.Route, 0)
 	for _, protoRoute := range protoRoutes {
@@ -642,6 +1290,9 @@ func toRoutes(protoRoutes []*mgmProto.Route) []*route.Route {
 			Peer:        protoRoute.Peer,
 			Metric:      int(protoRoute.Metric),
 			Masquerade:  protoRoute.Masquerade,
+
+			DisablePreemption: protoRoute.DisablePreemption,
+			LoadBalance:       protoRoute.LoadBalance,
 		}
 		routes = append(routes, convertedRoute)
 	}
@@ -653,6 +1304,7 @@ func toDNSConfig(protoDNSConfig *mgmProto.DNSConfig) nbdns.Config {
 		ServiceEnable:    protoDNSConfig.GetServiceEnable(),
 		CustomZones:      make([]nbdns.CustomZone, 0),
 		NameServerGroups: make([]*nbdns.NameServerGroup, 0),
+		SearchDomains:    protoDNSConfig.GetSearchDomains(),
 	}
 
 	for _, zone := range protoDNSConfig.GetCustomZones() {
@@ -677,6 +1329,8 @@ func toDNSConfig(protoDNSConfig *mgmProto.DNSConfig) nbdns.Config {
 			Primary:              nsGroup.GetPrimary(),
 			Domains:              nsGroup.GetDomains(),
 			SearchDomainsEnabled: nsGroup.GetSearchDomainsEnabled(),
+			ForwardViaRoutes:     nsGroup.GetForwardViaRoutes(),
+			Priority:             int(nsGroup.GetPriority()),
 		}
 		for _, ns := range nsGroup.GetNameServers() {
 			dnsNS := nbdns.NameServer{
@@ -699,6 +1353,9 @@ func (e *Engine) updateOfflinePeers(offlinePeers []*mgmProto.RemotePeerConfig) {
 			IP:               strings.Join(offlinePeer.GetAllowedIps(), ","),
 			PubKey:           offlinePeer.GetWgPubKey(),
 			FQDN:             offlinePeer.GetFqdn(),
+			Groups:           offlinePeer.GetGroups(),
+			Services:         toPeerServices(offlinePeer.GetServices()),
+			Labels:           offlinePeer.GetLabels(),
 			ConnStatus:       peer.StatusDisconnected,
 			ConnStatusUpdate: time.Now(),
 		}
@@ -721,17 +1378,47 @@ func (e *Engine) addNewPeers(peersUpdate []*mgmProto.RemotePeerConfig) error {
 func (e *Engine) addNewPeer(peerConfig *mgmProto.RemotePeerConfig) error {
 	peerKey := peerConfig.GetWgPubKey()
 	peerIPs := peerConfig.GetAllowedIps()
+	if _, ok := e.lazyPeers[peerKey]; ok {
+		return nil
+	}
 	if _, ok := e.peerConns[peerKey]; !ok {
-		conn, err := e.createPeerConn(peerKey, strings.Join(peerIPs, ","))
-		if err != nil {
-			return err
+		var conn *peer.Conn
+		if !e.connectsLazily(peerKey) {
+			var err error
+			conn, err = e.createPeerConn(peerKey, strings.Join(peerIPs, ","), e.postQuantumSupported(peerConfig))
+			if err != nil {
+				return err
+			}
+			e.peerConns[peerKey] = conn
 		}
-		e.peerConns[peerKey] = conn
 
-		err = e.statusRecorder.AddPeer(peerKey, peerConfig.Fqdn)
+		err := e.statusRecorder.AddPeer(peerKey, peerConfig.Fqdn)
 		if err != nil {
 			log.Warnf("error adding peer %s to status recorder, got error: %v", peerKey, err)
 		}
+		err = e.statusRecorder.UpdatePeerGroups(peerKey, peerConfig.GetGroups())
+		if err != nil {
+			log.Warnf("error updating peer's %s groups in the status recorder, got error: %v", peerKey, err)
+		}
+		err = e.statusRecorder.UpdatePeerServices(peerKey, toPeerServices(peerConfig.GetServices()))
+		if err != nil {
+			log.Warnf("error updating peer's %s services in the status recorder, got error: %v", peerKey, err)
+		}
+		err = e.statusRecorder.UpdatePeerLabels(peerKey, peerConfig.GetLabels())
+		if err != nil {
+			log.Warnf("error updating peer's %s labels in the status recorder, got error: %v", peerKey, err)
+		}
+		if _, priority := e.priorityPeers[peerKey]; priority {
+			err = e.statusRecorder.UpdatePeerPriority(peerKey, true)
+			if err != nil {
+				log.Warnf("error marking peer %s priority in the status recorder, got error: %v", peerKey, err)
+			}
+		}
+
+		if conn == nil {
+			e.addLazyPeer(peerConfig)
+			return nil
+		}
 
 		go e.connWorker(conn, peerKey)
 	}
@@ -739,12 +1426,17 @@ func (e *Engine) addNewPeer(peerConfig *mgmProto.RemotePeerConfig) error {
 }
 
 func (e *Engine) connWorker(conn *peer.Conn, peerKey string) {
+	// the priority peers are connected right away, the retries are delayed like the other ones
+	delayed := !conn.GetConf().Priority
 	for {
 
 		// randomize starting time a bit
-		min := 500
-		max := 2000
-		time.Sleep(time.Duration(rand.Intn(max-min)+min) * time.Millisecond)
+		if delayed {
+			min := 500
+			max := 2000
+			time.Sleep(time.Duration(rand.Intn(max-min)+min) * time.Millisecond)
+		}
+		delayed = true
 
 		// if peer has been removed -> give up
 		if !e.peerExists(peerKey) {
@@ -759,7 +1451,7 @@ func (e *Engine) connWorker(conn *peer.Conn, peerKey string) {
 
 		// we might have received new STUN and TURN servers meanwhile, so update them
 		e.syncMsgMux.Lock()
-		conn.UpdateStunTurn(append(e.STUNs, e.TURNs...))
+		conn.UpdateStunTurn(e.stunTurn())
 		e.syncMsgMux.Unlock()
 
 		err := conn.Open()
@@ -775,6 +1467,18 @@ func (e *Engine) connWorker(conn *peer.Conn, peerKey string) {
 	}
 }
 
+// restartPeerConn negotiates the connection to the peer again, the connWorker of the peer opens it right away
+func (e *Engine) restartPeerConn(peerKey string) bool {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	conn, ok := e.peerConns[peerKey]
+	if !ok {
+		return false
+	}
+	return conn.Restart()
+}
+
 func (e *Engine) peerExists(peerKey string) bool {
 	e.syncMsgMux.Lock()
 	defer e.syncMsgMux.Unlock()
@@ -782,11 +1486,22 @@ func (e *Engine) peerExists(peerKey string) bool {
 	return ok
 }
 
-func (e *Engine) createPeerConn(pubKey string, allowedIPs string) (*peer.Conn, error) {
+// postQuantumSupported checks whether both peers can negotiate a post-quantum pre-shared key
+func (e *Engine) postQuantumSupported(peerConfig *mgmProto.RemotePeerConfig) bool {
+	if !e.config.PostQuantumEnabled {
+		return false
+	}
+	for _, capability := range peerConfig.GetCapabilities() {
+		if capability == postquantum.Capability {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) createPeerConn(pubKey string, allowedIPs string, postQuantum bool) (*peer.Conn, error) {
 	log.Debugf("creating peer connection %s", pubKey)
-	var stunTurn []*stun.URI
-	stunTurn = append(stunTurn, e.STUNs...)
-	stunTurn = append(stunTurn, e.TURNs...)
+	stunTurn := e.stunTurn()
 
 	wgConfig := peer.WgConfig{
 		RemoteKey:    pubKey,
@@ -811,6 +1526,12 @@ func (e *Engine) createPeerConn(pubKey string, allowedIPs string) (*peer.Conn, e
 		LocalWgPort:          e.config.WgPort,
 		NATExternalIPs:       e.parseNATExternalIPMappings(),
 		UserspaceBind:        e.wgInterface.IsUserspaceBind(),
+		PostQuantum:          postQuantum,
+		Limiter:              e.connLimiter,
+	}
+	if _, priority := e.priorityPeers[pubKey]; priority {
+		config.Priority = true
+		log.Debugf("peer %s is a priority peer, its connection is kept warm", pubKey)
 	}
 
 	peerConn, err := peer.NewConn(config, e.statusRecorder, e.wgProxyFactory, e.mobileDep.TunAdapter, e.mobileDep.IFaceDiscover)
@@ -835,9 +1556,14 @@ func (e *Engine) createPeerConn(pubKey string, allowedIPs string) (*peer.Conn, e
 		return SignalOfferAnswer(offerAnswer, e.config.WgPrivateKey, wgPubKey, e.signal, true)
 	}
 
+	signalRoam := func(candidates []ice.Candidate) error {
+		return signalRoam(candidates, e.config.WgPrivateKey, wgPubKey, e.signal)
+	}
+
 	peerConn.SetSignalCandidate(signalCandidate)
 	peerConn.SetSignalOffer(signalOffer)
 	peerConn.SetSignalAnswer(signalAnswer)
+	peerConn.SetSignalRoam(signalRoam)
 	peerConn.SetSendSignalMessage(func(message *sProto.Message) error {
 		return sendSignal(message, e.signal)
 	})
@@ -847,12 +1573,21 @@ func (e *Engine) createPeerConn(pubKey string, allowedIPs string) (*peer.Conn, e
 
 // receiveSignalEvents connects to the Signal Service event stream to negotiate connection with remote peers
 func (e *Engine) receiveSignalEvents() {
-	go func() {
+	e.health.Go(health.Signal, func() {
 		// connect to a stream of messages coming from the signal server
 		err := e.signal.Receive(func(msg *sProto.Message) error {
+			e.health.Alive(health.Signal)
+
 			e.syncMsgMux.Lock()
 			defer e.syncMsgMux.Unlock()
 
+			if _, ok := e.lazyPeers[msg.Key]; ok && msg.GetBody().GetType() == sProto.Body_OFFER {
+				// the remote peer reaches out, the connection is created to answer it
+				if err := e.materializePeer(msg.Key); err != nil {
+					return fmt.Errorf("create the connection to peer %s: %w", msg.Key, err)
+				}
+			}
+
 			conn := e.peerConns[msg.Key]
 			if conn == nil {
 				return fmt.Errorf("wrongly addressed message %s", msg.Key)
@@ -872,8 +1607,10 @@ func (e *Engine) receiveSignalEvents() {
 						UFrag: remoteCred.UFrag,
 						Pwd:   remoteCred.Pwd,
 					},
-					WgListenPort: int(msg.GetBody().GetWgListenPort()),
-					Version:      msg.GetBody().GetNetBirdVersion(),
+					WgListenPort:   int(msg.GetBody().GetWgListenPort()),
+					Version:        msg.GetBody().GetNetBirdVersion(),
+					PostQuantumKey: msg.GetBody().GetPostQuantum().GetPublicKey(),
+					TraceContext:   msg.GetBody().GetTraceContext(),
 				})
 			case sProto.Body_ANSWER:
 				remoteCred, err := signal.UnMarshalCredential(msg)
@@ -888,8 +1625,10 @@ func (e *Engine) receiveSignalEvents() {
 						UFrag: remoteCred.UFrag,
 						Pwd:   remoteCred.Pwd,
 					},
-					WgListenPort: int(msg.GetBody().GetWgListenPort()),
-					Version:      msg.GetBody().GetNetBirdVersion(),
+					WgListenPort:   int(msg.GetBody().GetWgListenPort()),
+					Version:        msg.GetBody().GetNetBirdVersion(),
+					PostQuantumKey: msg.GetBody().GetPostQuantum().GetPublicKey(),
+					TraceContext:   msg.GetBody().GetTraceContext(),
 				})
 			case sProto.Body_CANDIDATE:
 				candidate, err := ice.UnmarshalCandidate(msg.GetBody().Payload)
@@ -899,6 +1638,25 @@ func (e *Engine) receiveSignalEvents() {
 				}
 				conn.OnRemoteCandidate(candidate)
 			case sProto.Body_MODE:
+			case sProto.Body_POST_QUANTUM:
+				conn.OnRemotePostQuantum(peer.PostQuantumMessage{
+					Ciphertext:    msg.GetBody().GetPostQuantum().GetCiphertext(),
+					PublicKeyHash: msg.GetBody().GetPostQuantum().GetPublicKeyHash(),
+				})
+			case sProto.Body_GOING_OFFLINE:
+				log.Infof("peer %s is going offline", msg.Key)
+				conn.OnRemoteGoingOffline()
+			case sProto.Body_ROAM:
+				candidates := make([]ice.Candidate, 0, len(msg.GetBody().GetCandidates()))
+				for _, c := range msg.GetBody().GetCandidates() {
+					candidate, err := ice.UnmarshalCandidate(c)
+					if err != nil {
+						log.Debugf("failed on parsing roaming candidate %s of peer %s: %v", c, msg.Key, err)
+						continue
+					}
+					candidates = append(candidates, candidate)
+				}
+				conn.OnRemoteRoam(candidates)
 			}
 
 			return nil
@@ -910,7 +1668,7 @@ func (e *Engine) receiveSignalEvents() {
 			e.cancel()
 			return
 		}
-	}()
+	})
 
 	e.signal.WaitStreamConnected()
 }
@@ -968,6 +1726,15 @@ func (e *Engine) parseNATExternalIPMappings() []string {
 }
 
 func (e *Engine) close() {
+	e.statusRecorder.SetHealthRegistry(nil)
+	e.statusRecorder.SetPeerStateObserver(nil)
+	// the traffic since the last sample is accounted before the counters are gone with the interface
+	if err := e.statusRecorder.SampleUsage(); err != nil {
+		log.Debugf("failed sampling the usage: %v", err)
+	}
+	e.statusRecorder.SetWgStatsGetter(nil)
+	e.extensions.Close()
+
 	if err := e.wgProxyFactory.Free(); err != nil {
 		log.Errorf("failed closing ebpf proxy: %s", err)
 	}
@@ -1000,6 +1767,679 @@ func (e *Engine) close() {
 			log.Warnf("failed to reset firewall: %s", err)
 		}
 	}
+
+	if e.flows != nil {
+		e.flows.Stop()
+		e.flows = nil
+	}
+
+	if e.bgpImporter != nil {
+		e.bgpImporter.Stop()
+		e.bgpImporter = nil
+	}
+
+	if e.wolRelay != nil {
+		e.wolRelay.Stop()
+		e.wolRelay = nil
+	}
+
+	if e.latencyMeasurer != nil {
+		e.latencyMeasurer.Stop()
+		e.latencyMeasurer = nil
+	}
+
+	if e.usageReporter != nil {
+		e.usageReporter.Stop()
+		e.usageReporter = nil
+	}
+
+	if e.networkMonitor != nil {
+		e.networkMonitor.Stop()
+		e.networkMonitor = nil
+	}
+
+	if e.appRouter != nil {
+		if err := e.appRouter.Close(); err != nil {
+			log.Warnf("failed to remove the app routing rules: %v", err)
+		}
+		e.appRouter = nil
+		e.appRouting = nil
+	}
+}
+
+// updateBandwidthLimits applies the bandwidth limits if they changed since the last network map
+func (e *Engine) updateBandwidthLimits(limits []shaper.Limit) {
+	if reflect.DeepEqual(limits, e.bandwidthLimits) {
+		return
+	}
+
+	if e.shaper == nil {
+		if len(limits) == 0 {
+			return
+		}
+		// the userspace firewall shapes the traffic itself, otherwise rely on the OS
+		if s, ok := e.firewall.(shaper.Shaper); ok {
+			e.shaper = s
+		} else {
+			s, err := shaper.New(e.wgInterface.Name())
+			if err != nil {
+				log.Warnf("failed creating traffic shaper, ignoring bandwidth limits: %v", err)
+				return
+			}
+			e.shaper = s
+		}
+	}
+
+	if err := e.shaper.SetBandwidthLimits(limits); err != nil {
+		log.Errorf("failed applying bandwidth limits: %v", err)
+		return
+	}
+	e.bandwidthLimits = limits
+}
+
+// toBandwidthLimits returns the limits of the remote peers, covering the peer addresses and the networks they route
+func toBandwidthLimits(remotePeers []*mgmProto.RemotePeerConfig, protoRoutes []*mgmProto.Route) []shaper.Limit {
+	routedNetworks := make(map[string][]netip.Prefix)
+	for _, protoRoute := range protoRoutes {
+		prefix, err := netip.ParsePrefix(protoRoute.GetNetwork())
+		if err != nil {
+			continue
+		}
+		routedNetworks[protoRoute.GetPeer()] = append(routedNetworks[protoRoute.GetPeer()], prefix)
+	}
+
+	var limits []shaper.Limit
+	for _, remotePeer := range remotePeers {
+		if remotePeer.GetBandwidthLimit() == 0 {
+			continue
+		}
+
+		var prefixes []netip.Prefix
+		for _, allowedIP := range remotePeer.GetAllowedIps() {
+			prefix, err := netip.ParsePrefix(allowedIP)
+			if err != nil {
+				log.Warnf("failed parsing allowed IP %s of peer %s: %v", allowedIP, remotePeer.GetWgPubKey(), err)
+				continue
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		prefixes = append(prefixes, routedNetworks[remotePeer.GetWgPubKey()]...)
+		if len(prefixes) == 0 {
+			continue
+		}
+
+		sort.Slice(prefixes, func(i, j int) bool {
+			return prefixes[i].String() < prefixes[j].String()
+		})
+		limits = append(limits, shaper.Limit{Prefixes: prefixes, Rate: remotePeer.GetBandwidthLimit()})
+	}
+
+	// the network map doesn't keep the order of the peers
+	sort.Slice(limits, func(i, j int) bool {
+		return limits[i].Prefixes[0].String() < limits[j].Prefixes[0].String()
+	})
+	return limits
+}
+
+// setPacketLogger records the packets matched by the logged ACL rules of the userspace firewall, the kernel firewalls
+// send them to the netlink log group instead
+func (e *Engine) setPacketLogger() {
+	logging, ok := e.firewall.(interface {
+		SetPacketLogger(logger func(manager.PacketLog))
+	})
+	if !ok {
+		log.Debugf("the logged ACL rules are sent to netlink log group %d", manager.LogGroup)
+		return
+	}
+
+	logging.SetPacketLogger(func(entry manager.PacketLog) {
+		log.Infof("logged ACL rule matched: %s", entry)
+		e.statusRecorder.RecordEvent(eventlog.CategoryACL, entry.String())
+	})
+}
+
+// startFlowExport enables the flow accounting of the userspace firewall and the export to the configured collector
+func (e *Engine) startFlowExport() {
+	accounting, ok := e.firewall.(interface {
+		SetFlowAggregator(aggregator *flow.Aggregator)
+	})
+	if !ok {
+		log.Warnf("flow export is only supported with the userspace firewall, ignoring collector %s", e.config.FlowCollectorURL)
+		return
+	}
+
+	exporter, err := flow.NewExporter(e.config.FlowCollectorURL)
+	if err != nil {
+		log.Errorf("failed creating flow exporter: %v", err)
+		return
+	}
+
+	e.flows = flow.NewAggregator(exporter, flow.DefaultInterval)
+	e.flows.Start(e.ctx)
+	accounting.SetFlowAggregator(e.flows)
+	log.Infof("exporting flow records to %s", e.config.FlowCollectorURL)
+}
+
+// startBGPRouteImport advertises the routes learned by a local BGP daemon to the Management service, the NetBird
+// network is never imported
+func (e *Engine) startBGPRouteImport() {
+	var excluded []netip.Prefix
+	if wgPrefix, err := netip.ParsePrefix(e.config.WgAddr); err == nil {
+		excluded = append(excluded, wgPrefix.Masked())
+	}
+
+	e.bgpImporter = routeimport.NewImporter(e.advertiseRoutes, routeimport.DefaultInterval, excluded...)
+	e.bgpImporter.Start(e.ctx)
+	log.Infof("importing BGP routes from the routing table")
+}
+
+// advertiseRoutes requests the Management service to route the networks through this peer and to remove the routes
+// of the withdrawn networks. The routes use the peer's DNS label as network ID
+func (e *Engine) advertiseRoutes(networks, withdrawn []string) error {
+	serverKey, err := e.mgmClient.GetServerPublicKey()
+	if err != nil {
+		return fmt.Errorf("get Management Service public key: %w", err)
+	}
+
+	_, err = e.mgmClient.AdvertiseRoutes(*serverKey, "", networks, withdrawn)
+	return err
+}
+
+// startWakeOnLANRelay relays the Wake-on-LAN packets sent by other peers to the NetBird address of this peer to the
+// networks it routes
+func (e *Engine) startWakeOnLANRelay() {
+	address := e.wgInterface.Address()
+	relay := wol.NewRelay(address.IP, address.Network)
+	if err := relay.Start(); err != nil {
+		log.Warnf("failed starting the Wake-on-LAN relay: %v", err)
+		return
+	}
+	e.wolRelay = relay
+}
+
+// updateLatencyMeasurer starts or stops measuring the latency to other peers as requested by the Management service
+func (e *Engine) updateLatencyMeasurer(enabled bool) {
+	switch {
+	case enabled && e.latencyMeasurer == nil:
+		e.latencyMeasurer = latency.NewMeasurer(e.latencyPeers, e.reportLatency, latency.DefaultInterval, latency.DefaultSampleSize)
+		e.latencyMeasurer.Start(e.ctx)
+		log.Infof("measuring the latency to other peers")
+	case !enabled && e.latencyMeasurer != nil:
+		e.latencyMeasurer.Stop()
+		e.latencyMeasurer = nil
+		log.Infof("stopped measuring the latency to other peers")
+	}
+}
+
+// updateUsageReporter starts or stops reporting the daily traffic as requested by the Management service. The usage
+// is only accounted by the daemon, there is nothing to report without its tracker
+func (e *Engine) updateUsageReporter(enabled bool) {
+	tracker := e.statusRecorder.GetUsageTracker()
+	switch {
+	case enabled && e.usageReporter == nil && tracker != nil:
+		e.usageReporter = usage.NewReporter(tracker, e.reportUsage, usage.DefaultReportInterval)
+		e.usageReporter.Start(e.ctx)
+		log.Infof("reporting the usage to the Management service")
+	case !enabled && e.usageReporter != nil:
+		e.usageReporter.Stop()
+		e.usageReporter = nil
+		log.Infof("stopped reporting the usage to the Management service")
+	}
+}
+
+// startNetworkMonitor watches the local addresses to roam the direct connections when they change. The mobile platforms
+// restart the engine on network changes
+func (e *Engine) startNetworkMonitor() {
+	if runtime.GOOS == "android" || runtime.GOOS == "ios" {
+		return
+	}
+
+	ignored := append([]string{e.wgInterface.Name()}, e.config.IFaceBlackList...)
+	// the connections are roamed asynchronously, stopping the monitor while holding the lock must not wait for them
+	e.networkMonitor = netmonitor.New(ignored, func(addrs []netip.Addr) {
+		go e.roamConnections(addrs)
+	})
+	e.networkMonitor.Start(e.ctx)
+}
+
+// roamConnections moves the direct connections to the new local addresses, e.g. when a laptop switches from Wi-Fi to
+// Ethernet, so that the WireGuard sessions are kept instead of dropped until ICE negotiates the connections again
+func (e *Engine) roamConnections(addrs []netip.Addr) {
+	e.syncMsgMux.Lock()
+	stuns := append([]*stun.URI(nil), e.STUNs...)
+	udpMux := e.udpMux
+	stopped := e.networkMonitor == nil
+	e.syncMsgMux.Unlock()
+
+	if stopped || udpMux == nil {
+		return
+	}
+
+	// the STUN requests are sent without holding the lock
+	candidates := roamingCandidates(udpMux, stuns, addrs, e.config.DisableIPv6Discovery)
+	if len(candidates) == 0 {
+		log.Debugf("no local addresses to roam the connections to")
+		return
+	}
+
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	if e.ctx.Err() != nil || e.networkMonitor == nil {
+		return
+	}
+
+	var roamed int
+	for _, conn := range e.peerConns {
+		if conn.Roam(candidates) {
+			roamed++
+		}
+	}
+	if roamed > 0 {
+		log.Infof("local addresses changed, roamed %d direct connections", roamed)
+	}
+}
+
+// roamingCandidates returns the host candidates of the local addresses and the server reflexive candidate discovered
+// with the first STUN server that answers, on the port of the shared socket
+func roamingCandidates(udpMux *bind.UniversalUDPMuxDefault, stuns []*stun.URI, addrs []netip.Addr, disableIPv6 bool) []ice.Candidate {
+	localAddr, ok := udpMux.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+
+	var candidates []ice.Candidate
+	for _, addr := range addrs {
+		if addr.Is6() && disableIPv6 {
+			continue
+		}
+		candidate, err := ice.NewCandidateHost(&ice.CandidateHostConfig{
+			Network:   "udp",
+			Address:   addr.String(),
+			Port:      localAddr.Port,
+			Component: 1,
+		})
+		if err != nil {
+			log.Debugf("failed creating the host candidate of %s: %v", addr, err)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	// the NAT mapping changes with the local addresses
+	udpMux.ResetXORMappedAddrs()
+	for _, stunURI := range stuns {
+		serverAddr, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(stunURI.Host, strconv.Itoa(stunURI.Port)))
+		if err != nil {
+			log.Debugf("failed resolving STUN server %s: %v", stunURI, err)
+			continue
+		}
+		mapped, err := udpMux.GetXORMappedAddr(serverAddr, roamingSTUNTimeout)
+		if err != nil {
+			log.Debugf("failed discovering the mapped address with STUN server %s: %v", stunURI, err)
+			continue
+		}
+		candidate, err := ice.NewCandidateServerReflexive(&ice.CandidateServerReflexiveConfig{
+			Network:   "udp",
+			Address:   mapped.IP.String(),
+			Port:      mapped.Port,
+			Component: 1,
+			RelAddr:   localAddr.IP.String(),
+			RelPort:   localAddr.Port,
+		})
+		if err != nil {
+			log.Debugf("failed creating the server reflexive candidate of %s: %v", mapped, err)
+			continue
+		}
+		candidates = append(candidates, candidate)
+		break
+	}
+	return candidates
+}
+
+// updateDefaultDeny switches the default deny mode of the firewall, it is enabled by the Management service or the
+// local config
+func (e *Engine) updateDefaultDeny(managementDefaultDeny bool) {
+	if e.firewall == nil {
+		return
+	}
+
+	enabled := e.config.DefaultDeny || managementDefaultDeny
+	if enabled == e.defaultDeny {
+		return
+	}
+
+	if err := e.firewall.SetDefaultDeny(enabled); err != nil {
+		log.Errorf("failed to switch the default deny mode of the firewall: %v", err)
+		return
+	}
+	e.defaultDeny = enabled
+
+	if enabled {
+		log.Infof("dropping the traffic of the routed networks not allowed by the firewall rules")
+	} else {
+		log.Infof("accepting the traffic of the routed networks")
+	}
+	e.statusRecorder.RecordEvent(eventlog.CategoryACL, fmt.Sprintf("default deny mode set to %t", enabled))
+}
+
+// updateDNSLeakProtection allows the DNS queries to the NetBird resolver and to the nameservers of the DNS config
+// only, when the strict DNS mode is enabled. The firewall drops the queries sent to the other resolvers, e.g. the
+// ones of the local network, until it is reset when the engine stops
+func (e *Engine) updateDNSLeakProtection(config nbdns.Config) {
+	e.dnsLeakConfig = &config
+	if !e.config.StrictDNS || e.firewall == nil || e.dnsLeakPaused {
+		return
+	}
+
+	resolvers := dnsLeakResolvers(e.dnsServer.DnsIP(), config)
+	if e.dnsResolvers != nil && slices.Equal(e.dnsResolvers, resolvers) {
+		return
+	}
+
+	allowed := make([]net.IP, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		allowed = append(allowed, net.ParseIP(resolver))
+	}
+	if err := e.firewall.SetDNSLeakProtection(allowed); err != nil {
+		log.Errorf("failed to block the DNS queries to other resolvers: %v", err)
+		return
+	}
+	e.dnsResolvers = resolvers
+
+	if !hasPrimaryNameServerGroup(config) {
+		log.Warnf("no nameserver group of the account resolves all the domains, " +
+			"the domains it doesn't match can't be resolved in strict DNS mode")
+	}
+	log.Infof("blocking the DNS queries to other resolvers than %v", resolvers)
+	e.statusRecorder.RecordEvent(eventlog.CategoryACL, fmt.Sprintf("DNS queries allowed to resolvers %v only", resolvers))
+}
+
+// PauseDNSLeakProtection lifts the DNS leak protection, so that the resolvers of a captive portal can be reached
+// while the user authenticates with it, or applies it again with the last DNS config
+func (e *Engine) PauseDNSLeakProtection(paused bool) {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	if e.dnsLeakPaused == paused {
+		return
+	}
+	e.dnsLeakPaused = paused
+
+	if !paused {
+		if e.dnsLeakConfig != nil {
+			e.updateDNSLeakProtection(*e.dnsLeakConfig)
+		}
+		return
+	}
+
+	if e.dnsResolvers == nil || e.firewall == nil {
+		return
+	}
+	if err := e.firewall.SetDNSLeakProtection(nil); err != nil {
+		log.Errorf("failed to allow the DNS queries to other resolvers: %v", err)
+		return
+	}
+	e.dnsResolvers = nil
+	log.Infof("allowing the DNS queries to all the resolvers until the captive portal bypass ends")
+	e.statusRecorder.RecordEvent(eventlog.CategoryACL, "DNS queries allowed to all resolvers for the captive portal")
+}
+
+// dnsLeakResolvers returns the sorted addresses of the NetBird resolver and of the nameservers of the DNS config
+func dnsLeakResolvers(dnsIP string, config nbdns.Config) []string {
+	seen := make(map[string]struct{})
+	resolvers := []string{}
+	add := func(ip net.IP) {
+		if ip == nil {
+			return
+		}
+		if _, ok := seen[ip.String()]; ok {
+			return
+		}
+		seen[ip.String()] = struct{}{}
+		resolvers = append(resolvers, ip.String())
+	}
+
+	add(net.ParseIP(dnsIP))
+	for _, group := range config.NameServerGroups {
+		for _, ns := range group.NameServers {
+			add(net.IP(ns.IP.AsSlice()))
+		}
+	}
+	sort.Strings(resolvers)
+	return resolvers
+}
+
+// hasPrimaryNameServerGroup returns true when a nameserver group of the DNS config resolves all the domains
+func hasPrimaryNameServerGroup(config nbdns.Config) bool {
+	for _, group := range config.NameServerGroups {
+		if group.Primary {
+			return true
+		}
+	}
+	return false
+}
+
+// updateAppRouting applies the app routing config if it changed since the last network map
+func (e *Engine) updateAppRouting(config *approuting.Config) {
+	if reflect.DeepEqual(config, e.appRouting) {
+		return
+	}
+
+	if e.appRouter == nil {
+		if config == nil {
+			return
+		}
+		router, err := approuting.New(e.wgInterface.Name())
+		if err != nil {
+			log.Warnf("failed creating app router, ignoring app routing: %v", err)
+			return
+		}
+		e.appRouter = router
+	}
+
+	if err := e.appRouter.Apply(config); err != nil {
+		log.Errorf("failed applying app routing: %v", err)
+		return
+	}
+	e.appRouting = config
+
+	if config == nil {
+		log.Infof("stopped routing applications")
+		return
+	}
+	log.Infof("routing applications in %s mode: %v", config.Mode, config.Applications)
+	e.statusRecorder.RecordEvent(eventlog.CategoryRoute, fmt.Sprintf("app routing set to %s mode with %d applications", config.Mode, len(config.Applications)))
+}
+
+// toAppRoutingConfig returns the app routing config of the peer, nil when it is disabled
+func toAppRoutingConfig(protoConfig *mgmProto.AppRoutingConfig) *approuting.Config {
+	if protoConfig == nil {
+		return nil
+	}
+
+	mode := approuting.ModeInclude
+	if protoConfig.GetMode() == mgmProto.AppRoutingConfig_EXCLUDE {
+		mode = approuting.ModeExclude
+	}
+	return &approuting.Config{
+		Mode:         mode,
+		Applications: protoConfig.GetApplications(),
+	}
+}
+
+// setAllowedLocalPorts makes the firewall accept the traffic of the allowed local ports before the firewall rules
+func (e *Engine) setAllowedLocalPorts() {
+	if len(e.config.AllowedLocalPorts) == 0 {
+		return
+	}
+
+	if err := e.firewall.SetAllowedLocalPorts(e.config.AllowedLocalPorts); err != nil {
+		log.Errorf("failed to allow the local ports in the firewall: %v", err)
+		return
+	}
+	log.Infof("accepting the traffic of the local ports %v before the firewall rules", e.config.AllowedLocalPorts)
+}
+
+// allowedLocalPorts returns the allowed local ports in the protocol/port format, nil when the firewall isn't running
+func (e *Engine) allowedLocalPorts() []string {
+	if e.firewall == nil {
+		return nil
+	}
+
+	var ports []string
+	for _, port := range e.config.AllowedLocalPorts {
+		ports = append(ports, port.String())
+	}
+	return ports
+}
+
+// toPeerServices returns the services advertised by a remote peer in the label:port/protocol format
+func toPeerServices(protoServices []*mgmProto.PeerService) []string {
+	var services []string
+	for _, service := range protoServices {
+		services = append(services, system.Service{
+			Label:    service.GetLabel(),
+			Port:     int(service.GetPort()),
+			Protocol: service.GetProtocol(),
+		}.String())
+	}
+	return services
+}
+
+// latencyPeers returns the connected peers, only their latency over the tunnel can be measured
+func (e *Engine) latencyPeers() []latency.Peer {
+	var peers []latency.Peer
+	for _, state := range e.statusRecorder.GetFullStatus().Peers {
+		if state.ConnStatus != peer.StatusConnected {
+			continue
+		}
+		ip, err := netip.ParseAddr(state.IP)
+		if err != nil {
+			continue
+		}
+		peers = append(peers, latency.Peer{Key: state.PubKey, IP: ip})
+	}
+	return peers
+}
+
+// reportLatency sends the latency measured to other peers to the Management service
+func (e *Engine) reportLatency(results []latency.Result) error {
+	serverKey, err := e.mgmClient.GetServerPublicKey()
+	if err != nil {
+		return fmt.Errorf("get Management Service public key: %w", err)
+	}
+
+	latencies := make([]*mgmProto.PeerLatency, 0, len(results))
+	for _, result := range results {
+		latencies = append(latencies, &mgmProto.PeerLatency{
+			WgPubKey:  result.PeerKey,
+			RttMicros: result.RTT.Microseconds(),
+			Sent:      uint32(result.Sent),
+			Received:  uint32(result.Received),
+		})
+	}
+	return e.mgmClient.ReportLatency(*serverKey, latencies)
+}
+
+// reportUsage sends the daily traffic over the tunnel to the Management service
+func (e *Engine) reportUsage(days []usage.Day) error {
+	serverKey, err := e.mgmClient.GetServerPublicKey()
+	if err != nil {
+		return fmt.Errorf("get Management Service public key: %w", err)
+	}
+
+	dailyUsage := make([]*mgmProto.DailyUsage, 0, len(days))
+	for _, day := range days {
+		dailyUsage = append(dailyUsage, &mgmProto.DailyUsage{
+			Date:    day.Date,
+			RxBytes: day.RxBytes,
+			TxBytes: day.TxBytes,
+		})
+	}
+	return e.mgmClient.ReportUsage(*serverKey, dailyUsage)
+}
+
+// notifyActiveRoutesChanged schedules a report of the active routes without blocking the route manager, the report
+// subscription coalesces the pending notifications
+func (e *Engine) notifyActiveRoutesChanged() {
+	eventbus.Publish(e.events, eventbus.ActiveRoutesEvent{})
+}
+
+// reportActiveRoutes sends the routes chosen among the routes of the same network to the Management service when
+// they change and periodically while there are any, until the engine stops
+func (e *Engine) reportActiveRoutes(updates *eventbus.Subscription[eventbus.ActiveRoutesEvent]) {
+	defer updates.Close()
+	ticker := time.NewTicker(activeRoutesReportInterval)
+	defer ticker.Stop()
+
+	reported := false
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-updates.C():
+			// a failover changes the routes of several networks at once
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-time.After(activeRoutesReportDelay):
+			}
+		case <-ticker.C:
+		}
+
+		routes := e.activeRoutes()
+		if len(routes) == 0 && !reported {
+			continue
+		}
+
+		if err := e.sendActiveRoutes(routes); err != nil {
+			log.Debugf("failed to report the active routes: %v", err)
+			continue
+		}
+		reported = len(routes) > 0
+	}
+}
+
+// activeRoutes returns the routes chosen for the client networks by the current route manager
+func (e *Engine) activeRoutes() []*route.Route {
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	if e.routeManager == nil {
+		return nil
+	}
+	return e.routeManager.ActiveRoutes()
+}
+
+// sendActiveRoutes sends the routes chosen among the routes of the same network to the Management service
+func (e *Engine) sendActiveRoutes(routes []*route.Route) error {
+	serverKey, err := e.mgmClient.GetServerPublicKey()
+	if err != nil {
+		return fmt.Errorf("get Management Service public key: %w", err)
+	}
+
+	activeRoutes := make([]*mgmProto.ActiveRoute, 0, len(routes))
+	for _, r := range routes {
+		activeRoutes = append(activeRoutes, &mgmProto.ActiveRoute{
+			RouteID:  r.ID,
+			WgPubKey: r.Peer,
+		})
+	}
+	return e.mgmClient.ReportActiveRoutes(*serverKey, activeRoutes)
+}
+
+// routedNetworks returns the networks of the routes served by this peer
+func (e *Engine) routedNetworks(protoRoutes []*mgmProto.Route) []netip.Prefix {
+	pubKey := e.config.WgPrivateKey.PublicKey().String()
+
+	var networks []netip.Prefix
+	for _, r := range toRoutes(protoRoutes) {
+		if r.Peer == pubKey {
+			networks = append(networks, r.Network)
+		}
+	}
+	return networks
 }
 
 func (e *Engine) readInitialSettings() ([]*route.Route, *nbdns.Config, error) {
@@ -1044,6 +2484,12 @@ func (e *Engine) wgInterfaceCreate() (err error) {
 		err = e.wgInterface.Create()
 	default:
 		err = e.wgInterface.Create()
+		if err == nil && len(e.config.WgExtraAddrs) > 0 {
+			// the extra addresses are optional, the peer works with its NetBird address only
+			if err := e.wgInterface.UpdateAddr(e.config.WgAddr, e.config.WgExtraAddrs...); err != nil {
+				log.Warnf("failed to assign the extra addresses %v to the interface: %v", e.config.WgExtraAddrs, err)
+			}
+		}
 	}
 	return err
 }
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+)
+
+// extraAddrTTL is the TTL of the DNS records of the extra interface addresses, the one of the peer records
+const extraAddrTTL = 300
+
+// withExtraAddrRecords adds A and AAAA records resolving the domain name of the peer to the extra addresses of its
+// interface to the custom zone of the peer, so that the services bound to them are reachable by the name of the peer
+// from this peer too
+func (e *Engine) withExtraAddrRecords(config nbdns.Config) nbdns.Config {
+	if len(e.config.WgExtraAddrs) == 0 || e.fqdn == "" {
+		return config
+	}
+
+	name := dns.Fqdn(e.fqdn)
+	for i, zone := range config.CustomZones {
+		if !strings.HasSuffix(name, "."+dns.Fqdn(zone.Domain)) {
+			continue
+		}
+
+		records := append([]nbdns.SimpleRecord{}, zone.Records...)
+		for _, extraAddr := range e.config.WgExtraAddrs {
+			prefix, err := netip.ParsePrefix(extraAddr)
+			if err != nil {
+				log.Warnf("skipping the DNS record of the invalid extra address %s: %v", extraAddr, err)
+				continue
+			}
+			recordType := dns.TypeA
+			if prefix.Addr().Is6() {
+				recordType = dns.TypeAAAA
+			}
+			records = append(records, nbdns.SimpleRecord{
+				Name:  name,
+				Type:  int(recordType),
+				Class: nbdns.DefaultClass,
+				TTL:   extraAddrTTL,
+				RData: prefix.Addr().String(),
+			})
+		}
+		config.CustomZones = append([]nbdns.CustomZone{}, config.CustomZones...)
+		config.CustomZones[i].Records = records
+		break
+	}
+	return config
+}
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/client/internal/eventbus"
+	"github.com/FlintyLemming/netbird/client/internal/peer"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// DefaultLazyConnectionThreshold is the default number of remote peers above which the connections to the peers are
+// created on demand
+const DefaultLazyConnectionThreshold = 1000
+
+// lazyConnectionThreshold returns the number of remote peers above which the connections are created on demand,
+// a negative value disables the lazy connections
+func (e *Engine) lazyConnectionThreshold() int {
+	if e.config.LazyConnectionThreshold == 0 {
+		return DefaultLazyConnectionThreshold
+	}
+	return e.config.LazyConnectionThreshold
+}
+
+// updateLazyConnections decides whether the connections to the peers of the network map are created on demand, the
+// routing peers are always connected because the route manager needs their connection status to choose the routes,
+// and so are the priority peers. It must be called before the peers of the network map are added
+func (e *Engine) updateLazyConnections(networkMap *mgmProto.NetworkMap) {
+	threshold := e.lazyConnectionThreshold()
+	lazy := threshold >= 0 && len(networkMap.GetRemotePeers()) > threshold
+	if lazy != e.lazyConnections {
+		log.Infof("lazy peer connections %s, the network map has %d peers (threshold %d)",
+			enabledString(lazy), len(networkMap.GetRemotePeers()), threshold)
+	}
+	e.lazyConnections = lazy
+	e.statusRecorder.SetLazyConnections(lazy)
+
+	e.routingPeers = make(map[string]struct{})
+	for _, r := range networkMap.GetRoutes() {
+		e.routingPeers[r.GetPeer()] = struct{}{}
+	}
+
+	e.priorityPeers = make(map[string]struct{})
+	for _, p := range networkMap.GetRemotePeers() {
+		if matchesPriorityPeer(p, e.config.PriorityPeers) {
+			e.priorityPeers[p.GetWgPubKey()] = struct{}{}
+		}
+	}
+}
+
+// matchesPriorityPeer tells whether the FQDN, a NetBird IP or the public key of the peer is in the priority peers
+func matchesPriorityPeer(peerConfig *mgmProto.RemotePeerConfig, priorityPeers []string) bool {
+	for _, priorityPeer := range priorityPeers {
+		if priorityPeer == peerConfig.GetWgPubKey() ||
+			peerConfig.GetFqdn() != "" && strings.EqualFold(dns.Fqdn(priorityPeer), dns.Fqdn(peerConfig.GetFqdn())) {
+			return true
+		}
+		for _, ip := range lazyPeerAddrs(peerConfig) {
+			if ip == priorityPeer {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// materializeNeededPeers creates the connections of the lazy peers which are needed right away, i.e. all of them
+// when the lazy connections were disabled, otherwise the routing and the priority peers. The caller must hold the syncMsgMux lock
+func (e *Engine) materializeNeededPeers() {
+	for peerKey := range e.lazyPeers {
+		if e.connectsLazily(peerKey) {
+			continue
+		}
+		if err := e.materializePeer(peerKey); err != nil {
+			log.Errorf("failed to create the connection to peer %s: %v", peerKey, err)
+		}
+	}
+}
+
+// connectsLazily tells whether the connection to a new peer is created on demand
+func (e *Engine) connectsLazily(peerKey string) bool {
+	if !e.lazyConnections {
+		return false
+	}
+	_, routing := e.routingPeers[peerKey]
+	_, priority := e.priorityPeers[peerKey]
+	return !routing && !priority
+}
+
+// addLazyPeer keeps the config of a peer whose connection is created on demand, the peer must already be in the
+// status recorder
+func (e *Engine) addLazyPeer(peerConfig *mgmProto.RemotePeerConfig) {
+	peerKey := peerConfig.GetWgPubKey()
+	e.lazyPeers[peerKey] = peerConfig
+	for _, ip := range lazyPeerAddrs(peerConfig) {
+		e.lazyPeerIPs[ip] = peerKey
+	}
+
+	// the connection reports the IP of the peer once it is created
+	err := e.statusRecorder.UpdatePeerState(peer.State{
+		PubKey:     peerKey,
+		IP:         strings.Split(strings.Join(peerConfig.GetAllowedIps(), ","), "/")[0],
+		ConnStatus: peer.StatusDisconnected,
+	})
+	if err != nil {
+		log.Warnf("error updating peer's %s IP in the status recorder, got error: %v", peerKey, err)
+	}
+	if err := e.statusRecorder.UpdatePeerLazy(peerKey, true); err != nil {
+		log.Warnf("error marking peer %s lazy in the status recorder, got error: %v", peerKey, err)
+	}
+}
+
+// removeLazyPeer forgets the config of a lazy peer, it returns false if the peer isn't lazy
+func (e *Engine) removeLazyPeer(peerKey string) bool {
+	peerConfig, ok := e.lazyPeers[peerKey]
+	if !ok {
+		return false
+	}
+	delete(e.lazyPeers, peerKey)
+	for _, ip := range lazyPeerAddrs(peerConfig) {
+		if e.lazyPeerIPs[ip] == peerKey {
+			delete(e.lazyPeerIPs, ip)
+		}
+	}
+	return true
+}
+
+// materializePeer creates the connection of a lazy peer and starts connecting to it. The caller must hold the
+// syncMsgMux lock
+func (e *Engine) materializePeer(peerKey string) error {
+	peerConfig, ok := e.lazyPeers[peerKey]
+	if !ok {
+		return nil
+	}
+
+	conn, err := e.createPeerConn(peerKey, strings.Join(peerConfig.GetAllowedIps(), ","), e.postQuantumSupported(peerConfig))
+	if err != nil {
+		return err
+	}
+	e.removeLazyPeer(peerKey)
+	e.peerConns[peerKey] = conn
+
+	if err := e.statusRecorder.UpdatePeerLazy(peerKey, false); err != nil {
+		log.Warnf("error marking peer %s connected on demand in the status recorder, got error: %v", peerKey, err)
+	}
+	log.Debugf("created the connection to peer %s on demand, %d peers left without connection", peerKey, len(e.lazyPeers))
+
+	go e.connWorker(conn, peerKey)
+	return nil
+}
+
+// publishDNSQuery publishes a query served by the DNS server, the lazy connections and the extensions follow them
+func (e *Engine) publishDNSQuery(query, response *dns.Msg) {
+	eventbus.Publish(e.events, eventbus.DNSQueryEvent{Query: query, Response: response})
+}
+
+// subscribeLazyConnections creates the connections of the lazy peers resolved by the DNS queries, the peers are likely
+// reached right after. The queries are dropped rather than delayed when the connections are created slower than the
+// queries are served
+func (e *Engine) subscribeLazyConnections() {
+	eventbus.SubscribeFunc(e.events, "lazy connections", eventbus.Options{}, func(event eventbus.DNSQueryEvent) {
+		e.materializeResolvedPeers(event.Response)
+	})
+}
+
+// materializeResolvedPeers creates the connections of the lazy peers resolved by the DNS response
+func (e *Engine) materializeResolvedPeers(response *dns.Msg) {
+	if response == nil {
+		return
+	}
+
+	var addrs []string
+	for _, answer := range response.Answer {
+		if a, ok := answer.(*dns.A); ok {
+			addrs = append(addrs, a.A.String())
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	e.syncMsgMux.Lock()
+	defer e.syncMsgMux.Unlock()
+
+	for _, addr := range addrs {
+		peerKey, ok := e.lazyPeerIPs[addr]
+		if !ok {
+			continue
+		}
+		if err := e.materializePeer(peerKey); err != nil {
+			log.Errorf("failed to create the connection to peer %s resolved by DNS: %v", peerKey, err)
+		}
+	}
+}
+
+// lazyPeerAddrs returns the addresses of the single host allowed IPs of the peer, i.e. its NetBird IP
+func lazyPeerAddrs(peerConfig *mgmProto.RemotePeerConfig) []string {
+	var addrs []string
+	for _, allowedIP := range peerConfig.GetAllowedIps() {
+		prefix, err := netip.ParsePrefix(allowedIP)
+		if err != nil || !prefix.IsSingleIP() {
+			continue
+		}
+		addrs = append(addrs, prefix.Addr().String())
+	}
+	return addrs
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -21,6 +21,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 
+	"github.com/FlintyLemming/netbird/client/firewall/shaper"
+	"github.com/FlintyLemming/netbird/client/internal/approuting"
 	"github.com/FlintyLemming/netbird/client/internal/dns"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
 	"github.com/FlintyLemming/netbird/client/internal/routemanager"
@@ -217,7 +219,7 @@ func TestEngine_UpdateNetworkMap(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	engine.routeManager = routemanager.NewManager(ctx, key.PublicKey().String(), engine.wgInterface, engine.statusRecorder, nil)
+	engine.routeManager = routemanager.NewManager(ctx, key.PublicKey().String(), engine.wgInterface, engine.statusRecorder, nil, nil)
 	engine.dnsServer = &dns.MockServer{
 		UpdateDNSServerFunc: func(serial uint64, update nbdns.Config) error { return nil },
 	}
@@ -892,6 +894,156 @@ loop:
 	}
 }
 
+func Test_ToBandwidthLimits(t *testing.T) {
+	remotePeers := []*mgmtProto.RemotePeerConfig{
+		{WgPubKey: "router", AllowedIps: []string{"100.64.0.20/32"}, BandwidthLimit: 20_000},
+		{WgPubKey: "laptop", AllowedIps: []string{"100.64.0.30/32"}},
+		{WgPubKey: "backup", AllowedIps: []string{"100.64.0.10/32"}, BandwidthLimit: 100_000},
+	}
+	protoRoutes := []*mgmtProto.Route{
+		{ID: "a", Network: "192.168.1.0/24", Peer: "router"},
+		{ID: "b", Network: "10.0.0.0/8", Peer: "laptop"},
+	}
+
+	limits := toBandwidthLimits(remotePeers, protoRoutes)
+	expected := []shaper.Limit{
+		{Prefixes: []netip.Prefix{netip.MustParsePrefix("100.64.0.10/32")}, Rate: 100_000},
+		{
+			Prefixes: []netip.Prefix{netip.MustParsePrefix("100.64.0.20/32"), netip.MustParsePrefix("192.168.1.0/24")},
+			Rate:     20_000,
+		},
+	}
+	assert.Equal(t, expected, limits, "limits should cover the peer addresses and their routed networks")
+	assert.Nil(t, toBandwidthLimits(remotePeers[1:2], protoRoutes), "peers without limit should be skipped")
+}
+
+func Test_DNSLeakResolvers(t *testing.T) {
+	config := nbdns.Config{
+		NameServerGroups: []*nbdns.NameServerGroup{
+			{
+				Domains:     []string{"corp.example"},
+				NameServers: []nbdns.NameServer{{IP: netip.MustParseAddr("10.0.0.53"), Port: 53}},
+			},
+			{
+				Primary: true,
+				NameServers: []nbdns.NameServer{
+					{IP: netip.MustParseAddr("1.1.1.1"), Port: 853},
+					{IP: netip.MustParseAddr("10.0.0.53"), Port: 53},
+				},
+			},
+		},
+	}
+
+	resolvers := dnsLeakResolvers("100.64.0.1", config)
+	assert.Equal(t, []string{"1.1.1.1", "10.0.0.53", "100.64.0.1"}, resolvers, "the nameservers should be allowed once")
+	assert.True(t, hasPrimaryNameServerGroup(config))
+
+	resolvers = dnsLeakResolvers("", nbdns.Config{})
+	assert.NotNil(t, resolvers, "an empty list should keep the protection enabled")
+	assert.Empty(t, resolvers)
+	assert.False(t, hasPrimaryNameServerGroup(nbdns.Config{}))
+}
+
+func TestEngine_WithExtraAddrRecords(t *testing.T) {
+	engine := &Engine{
+		config: &EngineConfig{WgExtraAddrs: []string{"fd00:100::1/64", "100.64.100.1/32"}},
+		fqdn:   "peer-a.netbird.cloud",
+	}
+	peerRecord := nbdns.SimpleRecord{Name: "peer-a.netbird.cloud.", Type: 1, Class: nbdns.DefaultClass, TTL: 300, RData: "100.64.0.1"}
+	config := nbdns.Config{
+		CustomZones: []nbdns.CustomZone{
+			{Domain: "example.com.", Records: []nbdns.SimpleRecord{}},
+			{Domain: "netbird.cloud.", Records: []nbdns.SimpleRecord{peerRecord}},
+		},
+	}
+
+	updated := engine.withExtraAddrRecords(config)
+	assert.Empty(t, updated.CustomZones[0].Records, "the other zones should be left untouched")
+	assert.Equal(t, []nbdns.SimpleRecord{
+		peerRecord,
+		{Name: "peer-a.netbird.cloud.", Type: 28, Class: nbdns.DefaultClass, TTL: 300, RData: "fd00:100::1"},
+		{Name: "peer-a.netbird.cloud.", Type: 1, Class: nbdns.DefaultClass, TTL: 300, RData: "100.64.100.1"},
+	}, updated.CustomZones[1].Records)
+	assert.Len(t, config.CustomZones[1].Records, 1, "the config of the network map should not be modified")
+}
+
+func Test_MatchesPriorityPeer(t *testing.T) {
+	peerConfig := &mgmtProto.RemotePeerConfig{
+		WgPubKey:   "RRHf3Ma6z6mdLbriAJbqhX7+nM/B71lgw2+91q3LfhU=",
+		AllowedIps: []string{"100.64.0.10/32", "10.10.0.0/16"},
+		Fqdn:       "dns.netbird.cloud",
+	}
+
+	assert.True(t, matchesPriorityPeer(peerConfig, []string{"DNS.netbird.cloud."}), "the FQDN should match")
+	assert.True(t, matchesPriorityPeer(peerConfig, []string{"100.64.0.10"}), "the NetBird IP should match")
+	assert.True(t, matchesPriorityPeer(peerConfig, []string{"RRHf3Ma6z6mdLbriAJbqhX7+nM/B71lgw2+91q3LfhU="}))
+	assert.False(t, matchesPriorityPeer(peerConfig, []string{"10.10.0.0", "ad.netbird.cloud"}))
+	assert.False(t, matchesPriorityPeer(peerConfig, nil))
+}
+
+func Test_TURNCredentialsExpiry(t *testing.T) {
+	expiry, ok := turnCredentialsExpiry("1700000000")
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1700000000, 0), expiry)
+
+	expiry, ok = turnCredentialsExpiry("1700000000:peer")
+	assert.True(t, ok, "the user name following the timestamp should be ignored")
+	assert.Equal(t, time.Unix(1700000000, 0), expiry)
+
+	_, ok = turnCredentialsExpiry("netbird")
+	assert.False(t, ok, "static credentials don't expire")
+}
+
+func TestEngine_RefreshTURNCredentials(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	engine := &Engine{
+		ctx:            ctx,
+		syncMsgMux:     &sync.Mutex{},
+		peerConns:      make(map[string]*peer.Conn),
+		statusRecorder: peer.NewRecorder("https://mgm"),
+	}
+	conn, err := peer.NewConn(peer.ConnConfig{Key: "peer"}, engine.statusRecorder, nil, nil, nil)
+	require.NoError(t, err)
+	engine.peerConns["peer"] = conn
+
+	turnsUpdate := func(user string) *mgmtProto.SyncResponse {
+		return &mgmtProto.SyncResponse{
+			WiretrusteeConfig: &mgmtProto.WiretrusteeConfig{
+				Stuns: []*mgmtProto.HostConfig{{Uri: "stun:stun.example.com:3478"}},
+				Turns: []*mgmtProto.ProtectedHostConfig{{
+					HostConfig: &mgmtProto.HostConfig{Uri: "turn:turn.example.com:3478"},
+					User:       user,
+					Password:   "secret",
+				}},
+			},
+		}
+	}
+
+	expired := fmt.Sprint(time.Now().Add(-time.Minute).Unix())
+	require.NoError(t, engine.handleSync(turnsUpdate(expired)))
+	stunTurn := conn.GetConf().StunTurn
+	require.Len(t, stunTurn, 1, "the TURN server with expired credentials should be skipped")
+	assert.Equal(t, "stun.example.com", stunTurn[0].Host)
+
+	refreshed := fmt.Sprint(time.Now().Add(time.Hour).Unix())
+	require.NoError(t, engine.handleSync(turnsUpdate(refreshed)))
+	stunTurn = conn.GetConf().StunTurn
+	require.Len(t, stunTurn, 2, "the refreshed TURN credentials should be pushed to the connections")
+	assert.Equal(t, refreshed, stunTurn[1].Username)
+}
+
+func Test_ToAppRoutingConfig(t *testing.T) {
+	assert.Nil(t, toAppRoutingConfig(nil), "app routing should be disabled without config")
+
+	config := toAppRoutingConfig(&mgmtProto.AppRoutingConfig{Mode: mgmtProto.AppRoutingConfig_EXCLUDE, Applications: []string{`C:\Apps\game.exe`}})
+	assert.Equal(t, &approuting.Config{Mode: approuting.ModeExclude, Applications: []string{`C:\Apps\game.exe`}}, config)
+
+	config = toAppRoutingConfig(&mgmtProto.AppRoutingConfig{Applications: []string{`C:\Apps\browser.exe`}})
+	assert.Equal(t, approuting.ModeInclude, config.Mode)
+}
+
 func Test_ParseNATExternalIPMappings(t *testing.T) {
 	ifaceList, err := net.Interfaces()
 	if err != nil {
@@ -1011,7 +1163,11 @@ func startSignal() (*grpc.Server, string, error) {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	proto.RegisterSignalExchangeServer(s, signalServer.NewServer())
+	srv, err := signalServer.NewServer("", signalServer.RateLimitConfig{}, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	proto.RegisterSignalExchangeServer(s, srv)
 
 	go func() {
 		if err = s.Serve(lis); err != nil {
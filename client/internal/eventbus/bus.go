@@ -0,0 +1,221 @@
+// Package eventbus carries the events of the engine to the subsystems following them, like the extensions, the lazy
+// connections or the report of the active routes, so that a new subsystem subscribes to the events it needs instead
+// of adding a callback to the component publishing them.
+//
+// The subscriptions are typed: a subscriber receives the events of the type it subscribed to only.
+//
+//	sub := eventbus.SubscribeFunc(bus, "audit", eventbus.Options{}, func(event eventbus.PeerStateEvent) {
+//		log.Infof("peer %s is %s", event.FQDN, event.Status)
+//	})
+//	defer sub.Close()
+//
+//	eventbus.Publish(bus, eventbus.PeerStateEvent{FQDN: "peer.netbird.cloud", Status: "Connected"})
+//
+// Each subscription has a bounded queue. When it is full the publisher waits for the subscriber up to the MaxWait of
+// the subscription, then the event is dropped, so that a slow subscriber slows the publisher down without blocking
+// it forever.
+package eventbus
+
+import (
+	"context"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultQueueSize is the number of events waiting for a subscriber when the options don't set it
+	DefaultQueueSize = 256
+	// dropLogInterval is the number of dropped events between two warnings of a subscription
+	dropLogInterval = 100
+)
+
+// Options configure the queue of a subscription
+type Options struct {
+	// QueueSize is the number of events waiting for the subscriber, DefaultQueueSize when 0
+	QueueSize int
+	// MaxWait is the time a publisher waits for room in the queue before dropping the event. 0 drops the event right
+	// away, e.g. to coalesce notifications with a queue of one, a negative value waits until there is room
+	MaxWait time.Duration
+}
+
+// Bus dispatches the published events to the subscriptions of their type. The publishers stop waiting for the
+// subscribers once the context is done
+type Bus struct {
+	ctx context.Context
+
+	mu            sync.RWMutex
+	subscriptions map[reflect.Type][]subscription
+}
+
+// subscription is the part of a Subscription independent of its event type
+type subscription interface {
+	deliver(event any)
+}
+
+// New creates a Bus living until the context is done
+func New(ctx context.Context) *Bus {
+	return &Bus{
+		ctx:           ctx,
+		subscriptions: make(map[reflect.Type][]subscription),
+	}
+}
+
+// Subscription receives the events of type T published on a Bus until it is closed
+type Subscription[T any] struct {
+	bus     *Bus
+	name    string
+	maxWait time.Duration
+	events  chan T
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe subscribes to the events of type T, they are received from the channel returned by C. The name
+// identifies the subscriber in the logs
+func Subscribe[T any](b *Bus, name string, opts Options) *Subscription[T] {
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	s := &Subscription[T]{
+		bus:     b,
+		name:    name,
+		maxWait: opts.MaxWait,
+		events:  make(chan T, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := typeOf[T]()
+	b.subscriptions[t] = append(b.subscriptions[t], s)
+	return s
+}
+
+// SubscribeFunc subscribes to the events of type T and calls the handler with each event from a goroutine of the
+// subscription, until the subscription is closed or the context of the bus is done. A panic of the handler is
+// logged instead of crashing the client
+func SubscribeFunc[T any](b *Bus, name string, opts Options, handler func(T)) *Subscription[T] {
+	s := Subscribe[T](b, name, opts)
+	go func() {
+		for {
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-s.done:
+				return
+			case event := <-s.events:
+				s.handle(handler, event)
+			}
+		}
+	}()
+	return s
+}
+
+// Publish hands the event to the subscriptions of its type, waiting for the full queues up to their MaxWait. The
+// events published on a nil Bus are dropped
+func Publish[T any](b *Bus, event T) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	subscriptions := b.subscriptions[typeOf[T]()]
+	b.mu.RUnlock()
+
+	for _, s := range subscriptions {
+		s.deliver(event)
+	}
+}
+
+// HasSubscribers returns true when the events of type T have a subscriber, so that the events expensive to build are
+// only published when they are followed
+func HasSubscribers[T any](b *Bus) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscriptions[typeOf[T]()]) > 0
+}
+
+// C returns the channel of the events, it isn't closed when the subscription is
+func (s *Subscription[T]) C() <-chan T {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes, the events queued are not handled anymore
+func (s *Subscription[T]) Close() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.bus.mu.Lock()
+		defer s.bus.mu.Unlock()
+		t := typeOf[T]()
+		subscriptions := s.bus.subscriptions[t]
+		for i, sub := range subscriptions {
+			if sub == subscription(s) {
+				// the slice is copied, the publishers iterate over the previous one without the lock
+				s.bus.subscriptions[t] = append(append([]subscription{}, subscriptions[:i]...), subscriptions[i+1:]...)
+				break
+			}
+		}
+		if len(s.bus.subscriptions[t]) == 0 {
+			delete(s.bus.subscriptions, t)
+		}
+	})
+}
+
+func (s *Subscription[T]) deliver(event any) {
+	e := event.(T)
+	select {
+	case s.events <- e:
+		return
+	default:
+	}
+
+	if s.maxWait != 0 {
+		var timeout <-chan time.Time
+		if s.maxWait > 0 {
+			timer := time.NewTimer(s.maxWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case s.events <- e:
+			return
+		case <-s.done:
+			return
+		case <-s.bus.ctx.Done():
+			return
+		case <-timeout:
+		}
+	}
+
+	if dropped := s.dropped.Add(1); dropped%dropLogInterval == 1 {
+		log.Warnf("event queue of %s is full, dropped %d events so far", s.name, dropped)
+	}
+}
+
+func (s *Subscription[T]) handle(handler func(T), event T) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("event handler of %s panicked: %v\n%s", s.name, r, debug.Stack())
+		}
+	}()
+	handler(event)
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
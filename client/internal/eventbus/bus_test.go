@@ -0,0 +1,128 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	ID int
+}
+
+type otherEvent struct{}
+
+func TestBus_TypedSubscriptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := New(ctx)
+
+	sub := Subscribe[testEvent](bus, "test", Options{})
+	defer sub.Close()
+	assert.True(t, HasSubscribers[testEvent](bus))
+	assert.False(t, HasSubscribers[otherEvent](bus))
+
+	Publish(bus, otherEvent{})
+	Publish(bus, testEvent{ID: 1})
+
+	select {
+	case event := <-sub.C():
+		assert.Equal(t, testEvent{ID: 1}, event, "the events of the other types should not be received")
+	case <-time.After(time.Second):
+		t.Fatal("the event should be received")
+	}
+}
+
+func TestBus_DropWhenFull(t *testing.T) {
+	bus := New(context.Background())
+	sub := Subscribe[testEvent](bus, "coalescing", Options{QueueSize: 1})
+	defer sub.Close()
+
+	Publish(bus, testEvent{ID: 1})
+	Publish(bus, testEvent{ID: 2})
+
+	assert.Equal(t, testEvent{ID: 1}, <-sub.C())
+	assert.Equal(t, uint64(1), sub.Dropped())
+}
+
+func TestBus_BackPressure(t *testing.T) {
+	bus := New(context.Background())
+	sub := Subscribe[testEvent](bus, "blocking", Options{QueueSize: 1, MaxWait: -1})
+	defer sub.Close()
+
+	Publish(bus, testEvent{ID: 1})
+	published := make(chan struct{})
+	go func() {
+		Publish(bus, testEvent{ID: 2})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("the publisher should wait for room in the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.Equal(t, testEvent{ID: 1}, <-sub.C())
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("the publisher should be released once the subscriber caught up")
+	}
+	assert.Equal(t, testEvent{ID: 2}, <-sub.C())
+	assert.Zero(t, sub.Dropped())
+
+	// a bounded wait drops the event once it expires
+	bounded := Subscribe[otherEvent](bus, "bounded", Options{QueueSize: 1, MaxWait: 10 * time.Millisecond})
+	defer bounded.Close()
+	Publish(bus, otherEvent{})
+	Publish(bus, otherEvent{})
+	assert.Equal(t, uint64(1), bounded.Dropped())
+}
+
+func TestBus_Close(t *testing.T) {
+	bus := New(context.Background())
+	sub := Subscribe[testEvent](bus, "closed", Options{QueueSize: 1, MaxWait: -1})
+	other := Subscribe[testEvent](bus, "open", Options{})
+	defer other.Close()
+
+	sub.Close()
+	sub.Close()
+	assert.True(t, HasSubscribers[testEvent](bus))
+
+	Publish(bus, testEvent{ID: 1})
+	Publish(bus, testEvent{ID: 2})
+	assert.Empty(t, sub.C(), "a closed subscription should not receive events")
+	assert.Len(t, other.C(), 2)
+
+	other.Close()
+	assert.False(t, HasSubscribers[testEvent](bus))
+}
+
+func TestSubscribeFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus := New(ctx)
+
+	received := make(chan testEvent, 2)
+	sub := SubscribeFunc(bus, "handler", Options{}, func(event testEvent) {
+		if event.ID == 1 {
+			panic("boom")
+		}
+		received <- event
+	})
+	defer sub.Close()
+
+	Publish(bus, testEvent{ID: 1})
+	Publish(bus, testEvent{ID: 2})
+
+	select {
+	case event := <-received:
+		require.Equal(t, testEvent{ID: 2}, event, "the handler should keep running after a panic")
+	case <-time.After(time.Second):
+		t.Fatal("the event should be handled")
+	}
+}
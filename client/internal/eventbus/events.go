@@ -0,0 +1,42 @@
+package eventbus
+
+import (
+	"github.com/miekg/dns"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+// NetworkMapEvent is published once the engine applied a network map
+type NetworkMapEvent struct {
+	Serial     uint64
+	NetworkMap *mgmProto.NetworkMap
+}
+
+// PeerStateEvent is published when the connection status of a remote peer changes
+type PeerStateEvent struct {
+	PubKey string
+	FQDN   string
+	IP     string
+	// Status is one of Idle, Connecting, Connected or Disconnected
+	Status  string
+	Relayed bool
+	Direct  bool
+	Groups  []string
+}
+
+// ActiveRoutesEvent is published when the route chosen for a client network changes
+type ActiveRoutesEvent struct{}
+
+// DNSConfigEvent is published once the DNS server applied the DNS config of a network map
+type DNSConfigEvent struct {
+	Serial uint64
+	Config nbdns.Config
+}
+
+// DNSQueryEvent is published for each query served by the DNS server of the client, the response is nil when the
+// query wasn't answered
+type DNSQueryEvent struct {
+	Query    *dns.Msg
+	Response *dns.Msg
+}
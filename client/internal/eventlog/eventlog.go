@@ -0,0 +1,216 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Category groups the events by the part of the client they originate from
+type Category string
+
+// Categories of the recorded events
+const (
+	CategoryPeer       Category = "peer"
+	CategoryRoute      Category = "route"
+	CategoryACL        Category = "acl"
+	CategoryManagement Category = "management"
+)
+
+const (
+	// DefaultMaxEvents is the default number of events kept in the log
+	DefaultMaxEvents = 10000
+	// DefaultMaxAge is the default age after which events are dropped from the log
+	DefaultMaxAge = 7 * 24 * time.Hour
+)
+
+// Event is an entry of the log
+type Event struct {
+	Time     time.Time `json:"time"`
+	Category Category  `json:"category"`
+	Message  string    `json:"message"`
+}
+
+// Log is a rolling log of the client events persisted to a file as JSON lines. Events are appended to the file as they
+// are added, the file is rewritten with the kept events once it holds twice as many lines as the limit
+type Log struct {
+	path      string
+	maxEvents int
+	maxAge    time.Duration
+
+	mu     sync.Mutex
+	events []Event
+	// lines is the number of events in the file, including the ones dropped from the log
+	lines int
+	now   func() time.Time
+}
+
+// New creates a Log persisted to the file, an empty path keeps the events in memory only
+func New(path string, maxEvents int, maxAge time.Duration) *Log {
+	return &Log{
+		path:      path,
+		maxEvents: maxEvents,
+		maxAge:    maxAge,
+		now:       time.Now,
+	}
+}
+
+// Load reads the events persisted by a previous run, a missing file is not an error
+func (l *Log) Load() error {
+	if l.path == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		// a line can be cut short when the client was stopped while writing it
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read event log: %w", err)
+	}
+
+	l.events = events
+	l.prune()
+	return l.rewrite()
+}
+
+// Add records an event and persists it. Persistence failures are logged, the event is kept in memory
+func (l *Log) Add(category Category, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event := Event{
+		Time:     l.now().UTC(),
+		Category: category,
+		Message:  message,
+	}
+	l.events = append(l.events, event)
+	l.prune()
+
+	if err := l.persist(event); err != nil {
+		log.Warnf("failed persisting event: %v", err)
+	}
+}
+
+// Since returns the events recorded at or after the given time, oldest first
+func (l *Log) Since(since time.Time) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var events []Event
+	for _, event := range l.events {
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// prune drops the events over the limit and the expired ones
+func (l *Log) prune() {
+	if l.maxEvents > 0 && len(l.events) > l.maxEvents {
+		l.events = l.events[len(l.events)-l.maxEvents:]
+	}
+
+	if l.maxAge <= 0 {
+		return
+	}
+	expiry := l.now().Add(-l.maxAge)
+	i := 0
+	for i < len(l.events) && l.events[i].Time.Before(expiry) {
+		i++
+	}
+	l.events = l.events[i:]
+}
+
+func (l *Log) persist(event Event) error {
+	if l.path == "" {
+		return nil
+	}
+
+	if l.maxEvents > 0 && l.lines >= 2*l.maxEvents {
+		return l.rewrite()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	l.lines++
+	return nil
+}
+
+// rewrite replaces the file with the events kept in the log
+func (l *Log) rewrite() error {
+	if l.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(l.path), ".*"+filepath.Base(l.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	writer := bufio.NewWriter(tempFile)
+	encoder := json.NewEncoder(writer)
+	for _, event := range l.events {
+		if err := encoder.Encode(event); err != nil {
+			_ = tempFile.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
+	// closing the file before moving it as windows doesn't allow to move it otherwise
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempFile.Name(), l.path); err != nil {
+		return err
+	}
+	l.lines = len(l.events)
+	return nil
+}
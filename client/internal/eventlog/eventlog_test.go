@@ -0,0 +1,77 @@
+package eventlog
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog_Since(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := New("", 10, time.Hour)
+	events.now = func() time.Time { return now }
+
+	events.Add(CategoryManagement, "connected to management")
+	now = now.Add(10 * time.Minute)
+	events.Add(CategoryPeer, "peer a connected (direct)")
+
+	assert.Len(t, events.Since(time.Time{}), 2)
+	sinceEvents := events.Since(now.Add(-5 * time.Minute))
+	require.Len(t, sinceEvents, 1)
+	assert.Equal(t, Event{Time: now, Category: CategoryPeer, Message: "peer a connected (direct)"}, sinceEvents[0])
+
+	now = now.Add(55 * time.Minute)
+	events.Add(CategoryRoute, "route to 10.0.0.0/24 via peer a")
+	assert.Len(t, events.Since(time.Time{}), 2, "expired events should be dropped")
+}
+
+func TestLog_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	events := New(path, 2, time.Hour)
+	require.NoError(t, events.Load(), "missing file shouldn't fail")
+	events.Add(CategoryPeer, "peer a connected (direct)")
+	events.Add(CategoryPeer, "peer b connected (relayed)")
+	events.Add(CategoryPeer, "peer a disconnected")
+	assert.Len(t, events.Since(time.Time{}), 2, "events over the limit should be dropped")
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"time":"2024-01-`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	loaded := New(path, 2, time.Hour)
+	require.NoError(t, loaded.Load())
+	loadedEvents := loaded.Since(time.Time{})
+	require.Len(t, loadedEvents, 2, "a truncated line should be skipped")
+	assert.Equal(t, "peer b connected (relayed)", loadedEvents[0].Message)
+	assert.Equal(t, "peer a disconnected", loadedEvents[1].Message)
+	assert.Equal(t, 2, countLines(t, path), "the file should be compacted on load")
+
+	for i := 0; i < 3; i++ {
+		loaded.Add(CategoryACL, "applied 1 firewall rules")
+	}
+	assert.LessOrEqual(t, countLines(t, path), 4, "the file should be compacted once it holds twice the limit")
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
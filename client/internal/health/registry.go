@@ -0,0 +1,217 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Components of the client engine reporting their health
+const (
+	Management = "management"
+	Signal     = "signal"
+	DNS        = "dns"
+	Routes     = "routes"
+	ACL        = "acl"
+	SSH        = "ssh"
+)
+
+const (
+	initialRestartDelay = time.Second
+	maxRestartDelay     = time.Minute
+)
+
+// RestartFunc brings a failed component back to a working state
+type RestartFunc func() error
+
+// ComponentState is the health of a component at a point in time
+type ComponentState struct {
+	Name    string
+	Healthy bool
+	// LastSeen is the last time the component reported activity
+	LastSeen    time.Time
+	LastError   string
+	LastErrorAt time.Time
+	Restarts    int
+}
+
+type component struct {
+	state      ComponentState
+	restart    RestartFunc
+	restarting bool
+}
+
+// Registry tracks the liveness and last error of the engine components. Panics of a component are recovered and the
+// component is restarted on its own, leaving the rest of the client running
+type Registry struct {
+	ctx        context.Context
+	mu         sync.Mutex
+	components map[string]*component
+	// restartDelay is the delay before the first restart attempt, it is doubled on every failed attempt
+	restartDelay time.Duration
+}
+
+// NewRegistry creates a Registry, restarts are not attempted once the context is done
+func NewRegistry(ctx context.Context) *Registry {
+	return &Registry{
+		ctx:          ctx,
+		components:   make(map[string]*component),
+		restartDelay: initialRestartDelay,
+	}
+}
+
+// Register adds a component, restart is called when the component fails and can be nil if it can't be restarted
+func (r *Registry) Register(name string, restart RestartFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(name)
+	c.restart = restart
+}
+
+// Unregister removes a component that is no longer running
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.components, name)
+}
+
+// Alive marks the component as healthy
+func (r *Registry) Alive(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(name)
+	c.state.Healthy = true
+	c.state.LastSeen = time.Now()
+}
+
+// ReportError records the last error of the component and marks it as unhealthy. A nil error marks it as healthy
+func (r *Registry) ReportError(name string, err error) {
+	if err == nil {
+		r.Alive(name)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(name)
+	c.state.Healthy = false
+	c.state.LastError = err.Error()
+	c.state.LastErrorAt = time.Now()
+}
+
+// Run calls fn on behalf of the component and reports its result. A panic is returned as an error and restarts the component
+func (r *Registry) Run(name string, fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = r.recovered(name, p)
+		}
+	}()
+
+	err = fn()
+	r.ReportError(name, err)
+	return err
+}
+
+// Go runs fn in a goroutine on behalf of the component. A panic restarts the component
+func (r *Registry) Go(name string, fn func()) {
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				_ = r.recovered(name, p)
+			}
+		}()
+		fn()
+	}()
+}
+
+// Snapshot returns the state of the components that reported at least once, sorted by name
+func (r *Registry) Snapshot() []ComponentState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states := make([]ComponentState, 0, len(r.components))
+	for _, c := range r.components {
+		if c.state.LastSeen.IsZero() && c.state.LastErrorAt.IsZero() {
+			continue
+		}
+		states = append(states, c.state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+func (r *Registry) recovered(name string, p any) error {
+	err := fmt.Errorf("panic: %v", p)
+	log.Errorf("recovered %s component from %s\n%s", name, err, debug.Stack())
+	r.ReportError(name, err)
+	r.scheduleRestart(name)
+	return err
+}
+
+// scheduleRestart restarts the component with an exponential backoff until it succeeds or the registry context is done
+func (r *Registry) scheduleRestart(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(name)
+	if c.restart == nil || c.restarting {
+		return
+	}
+	c.restarting = true
+
+	go func() {
+		delay := r.restartDelay
+		for {
+			select {
+			case <-r.ctx.Done():
+				r.finishRestart(name, false)
+				return
+			case <-time.After(delay):
+			}
+
+			err := r.Run(name, c.restart)
+			if err == nil {
+				log.Infof("restarted %s component", name)
+				r.finishRestart(name, true)
+				return
+			}
+
+			delay *= 2
+			if delay > maxRestartDelay {
+				delay = maxRestartDelay
+			}
+			log.Warnf("failed restarting %s component, retrying in %s: %v", name, delay, err)
+		}
+	}()
+}
+
+func (r *Registry) finishRestart(name string, restarted bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c := r.get(name)
+	c.restarting = false
+	if restarted {
+		c.state.Restarts++
+	}
+}
+
+func (r *Registry) get(name string) *component {
+	c, ok := r.components[name]
+	if !ok {
+		c = &component{state: ComponentState{Name: name}}
+		r.components[name] = c
+	}
+	return c
+}
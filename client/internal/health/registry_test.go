@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Run(t *testing.T) {
+	registry := NewRegistry(context.Background())
+
+	err := registry.Run(DNS, func() error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = registry.Run(Routes, func() error {
+		return errors.New("failed applying routes")
+	})
+	require.Error(t, err)
+
+	states := registry.Snapshot()
+	require.Len(t, states, 2)
+
+	assert.Equal(t, DNS, states[0].Name)
+	assert.True(t, states[0].Healthy)
+	assert.False(t, states[0].LastSeen.IsZero())
+
+	assert.Equal(t, Routes, states[1].Name)
+	assert.False(t, states[1].Healthy)
+	assert.Equal(t, "failed applying routes", states[1].LastError)
+}
+
+func TestRegistry_RestartOnPanic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewRegistry(ctx)
+	registry.restartDelay = 10 * time.Millisecond
+
+	var restarts atomic.Int32
+	registry.Register(ACL, func() error {
+		// the first attempt fails and is retried
+		if restarts.Add(1) == 1 {
+			return errors.New("not ready")
+		}
+		return nil
+	})
+
+	err := registry.Run(ACL, func() error {
+		panic("nil map")
+	})
+	require.Error(t, err, "panic should be returned as an error")
+
+	states := registry.Snapshot()
+	require.Len(t, states, 1)
+	assert.False(t, states[0].Healthy)
+	assert.Contains(t, states[0].LastError, "nil map")
+
+	require.Eventually(t, func() bool {
+		return registry.Snapshot()[0].Restarts == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, int32(2), restarts.Load())
+	assert.True(t, registry.Snapshot()[0].Healthy, "component should be healthy after restarting")
+}
+
+func TestRegistry_GoRecoversPanic(t *testing.T) {
+	registry := NewRegistry(context.Background())
+
+	done := make(chan struct{})
+	registry.Register(SSH, func() error {
+		close(done)
+		return nil
+	})
+
+	registry.Go(SSH, func() {
+		panic("unexpected")
+	})
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("component wasn't restarted")
+	}
+}
@@ -0,0 +1,37 @@
+// Package jsonrpc serves a local JSON-RPC 2.0 API of the daemon for scripts and tools that can't use the gRPC API.
+//
+// The API listens on a Unix socket, by default /var/run/netbird-api.sock, and exchanges one JSON object per line.
+// Requests follow the JSON-RPC 2.0 specification and are answered in order on the same connection:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "v1.status", "params": {"getFullPeerStatus": true}}
+//	{"jsonrpc": "2.0", "id": 1, "result": {"status": "Connected", "fullStatus": {...}, "daemonVersion": "..."}}
+//
+// The methods are prefixed with the version of the API. Methods and fields of a version are only ever added,
+// never changed or removed. Params and results are the messages of the gRPC API in daemon.proto encoded
+// with the canonical protobuf JSON mapping, so field names are lower camel case and missing params mean defaults.
+//
+// Methods of version 1:
+//
+//	version              {}                                 → {"apiVersion": 1, "daemonVersion": "..."}
+//	v1.status            StatusRequest                      → StatusResponse
+//	v1.up                UpRequest                          → UpResponse
+//	v1.down              DownRequest                        → DownResponse
+//	v1.getConfig         GetConfigRequest                   → GetConfigResponse
+//	v1.selectRoutes      SelectRoutesRequest                → SelectRoutesResponse
+//	v1.deselectRoutes    SelectRoutesRequest                → SelectRoutesResponse
+//	v1.getEvents         GetEventsRequest                   → GetEventsResponse
+//	v1.getUsage          GetUsageRequest                    → GetUsageResponse
+//	v1.subscribeEvents   GetEventsRequest                   → {}
+//
+// After v1.subscribeEvents the connection receives a "v1.event" notification without id for the events
+// recorded since the requested time and for every new event, with the Event message as params:
+//
+//	{"jsonrpc": "2.0", "method": "v1.event", "params": {"time": "...", "category": "peer", "message": "..."}}
+//
+// Failed calls return the standard JSON-RPC error codes for malformed requests. Errors of the daemon use
+// code -32000 with the gRPC status code name as data, e.g. "FailedPrecondition".
+//
+// Example with socat:
+//
+//	echo '{"jsonrpc":"2.0","id":1,"method":"v1.status"}' | socat - UNIX-CONNECT:/var/run/netbird-api.sock
+package jsonrpc
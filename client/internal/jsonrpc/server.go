@@ -0,0 +1,378 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+	"github.com/FlintyLemming/netbird/version"
+)
+
+const (
+	// APIVersion is the latest version of the API served
+	APIVersion = 1
+
+	// maxMessageSize limits the length of a request line
+	maxMessageSize = 1 << 20
+	// eventPollInterval is how often the events of a subscribed connection are checked
+	eventPollInterval = time.Second
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeDaemonError    = -32000
+)
+
+var (
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+	marshalOptions   = protojson.MarshalOptions{EmitUnpopulated: true}
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// method is a call of the API with the request message of the gRPC API it forwards to
+type method struct {
+	newParams func() protobuf.Message
+	call      func(ctx context.Context, conn *connection, params protobuf.Message) (protobuf.Message, error)
+}
+
+// Server serves the JSON-RPC API on top of the gRPC API implementation of the daemon
+type Server struct {
+	daemon  proto.DaemonServiceServer
+	methods map[string]method
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewServer returns a Server forwarding the calls to the daemon
+func NewServer(daemon proto.DaemonServiceServer) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		daemon:    daemon,
+		ctx:       ctx,
+		cancel:    cancel,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	s.methods = s.newMethods()
+	return s
+}
+
+// ListenUnix listens on the Unix socket at path, replacing a socket left behind by a previous run.
+// The socket is accessible to all users like the socket of the gRPC API
+func ListenUnix(path string) (net.Listener, error) {
+	if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+		if err := os.Remove(path); err != nil {
+			log.Debugf("remove socket file: %v", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("set socket permissions: %w", err)
+	}
+	return listener, nil
+}
+
+// Serve accepts connections on the listener until Stop is called
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return listener.Close()
+	}
+	s.listeners[listener] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.ctx.Err() != nil {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+// Stop closes the listeners and the open connections and waits for the running calls to return
+func (s *Server) Stop() {
+	s.mu.Lock()
+	s.cancel()
+	for listener := range s.listeners {
+		_ = listener.Close()
+	}
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// connection is a client connection; writes are serialized since event notifications are sent concurrently to responses
+type connection struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	ctx        context.Context
+	subscribed bool
+}
+
+func (c *connection) write(v any) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.encoder.Encode(v); err != nil {
+		log.Debugf("failed writing to the JSON API client: %v", err)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer func() {
+		cancel()
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	c := &connection{conn: conn, encoder: json.NewEncoder(conn), ctx: ctx}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.handle(ctx, c, line); resp != nil {
+			c.write(resp)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		log.Debugf("failed reading from the JSON API client: %v", err)
+	}
+}
+
+// handle runs the request and returns its response, nil for notifications
+func (s *Server) handle(ctx context.Context, c *connection, line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, &rpcError{Code: codeParseError, Message: "couldn't parse the request"})
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &rpcError{Code: codeInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+	}
+
+	result, err := s.call(ctx, c, req)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rErr *rpcError
+		if !errors.As(err, &rErr) {
+			st := status.Convert(err)
+			rErr = &rpcError{Code: codeDaemonError, Message: st.Message(), Data: st.Code().String()}
+		}
+		return errorResponse(req.ID, rErr)
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func (s *Server) call(ctx context.Context, c *connection, req request) (json.RawMessage, error) {
+	if req.Method == "version" {
+		return json.Marshal(map[string]any{"apiVersion": APIVersion, "daemonVersion": version.NetbirdVersion()})
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("unknown method %s", req.Method)}
+	}
+
+	params := m.newParams()
+	if len(req.Params) > 0 && !bytes.Equal(req.Params, []byte("null")) {
+		if err := unmarshalOptions.Unmarshal(req.Params, params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+		}
+	}
+
+	result, err := m.call(ctx, c, params)
+	if err != nil {
+		return nil, err
+	}
+	return marshalOptions.Marshal(result)
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+func (s *Server) newMethods() map[string]method {
+	return map[string]method{
+		"v1.status": {
+			newParams: func() protobuf.Message { return &proto.StatusRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.Status(ctx, params.(*proto.StatusRequest))
+			},
+		},
+		"v1.up": {
+			newParams: func() protobuf.Message { return &proto.UpRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.Up(ctx, params.(*proto.UpRequest))
+			},
+		},
+		"v1.down": {
+			newParams: func() protobuf.Message { return &proto.DownRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.Down(ctx, params.(*proto.DownRequest))
+			},
+		},
+		"v1.getConfig": {
+			newParams: func() protobuf.Message { return &proto.GetConfigRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.GetConfig(ctx, params.(*proto.GetConfigRequest))
+			},
+		},
+		"v1.selectRoutes": {
+			newParams: func() protobuf.Message { return &proto.SelectRoutesRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.SelectRoutes(ctx, params.(*proto.SelectRoutesRequest))
+			},
+		},
+		"v1.deselectRoutes": {
+			newParams: func() protobuf.Message { return &proto.SelectRoutesRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.DeselectRoutes(ctx, params.(*proto.SelectRoutesRequest))
+			},
+		},
+		"v1.getEvents": {
+			newParams: func() protobuf.Message { return &proto.GetEventsRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.GetEvents(ctx, params.(*proto.GetEventsRequest))
+			},
+		},
+		"v1.getUsage": {
+			newParams: func() protobuf.Message { return &proto.GetUsageRequest{} },
+			call: func(ctx context.Context, _ *connection, params protobuf.Message) (protobuf.Message, error) {
+				return s.daemon.GetUsage(ctx, params.(*proto.GetUsageRequest))
+			},
+		},
+		"v1.subscribeEvents": {
+			newParams: func() protobuf.Message { return &proto.GetEventsRequest{} },
+			call: func(_ context.Context, c *connection, params protobuf.Message) (protobuf.Message, error) {
+				if c.subscribed {
+					return &proto.GetEventsResponse{}, nil
+				}
+				c.subscribed = true
+
+				var since time.Time
+				if req := params.(*proto.GetEventsRequest); req.GetSince() != nil {
+					since = req.GetSince().AsTime()
+				}
+				go s.streamEvents(c, since)
+				return &proto.GetEventsResponse{}, nil
+			},
+		},
+	}
+}
+
+// streamEvents sends the events recorded since the given time to the connection until it is closed
+func (s *Server) streamEvents(c *connection, since time.Time) {
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := s.daemon.GetEvents(c.ctx, &proto.GetEventsRequest{Since: timestamppb.New(since)})
+		if err != nil {
+			log.Debugf("failed getting the events for the JSON API client: %v", err)
+		}
+		for _, event := range resp.GetEvents() {
+			params, err := marshalOptions.Marshal(event)
+			if err != nil {
+				log.Debugf("failed encoding event: %v", err)
+				continue
+			}
+			c.write(&notification{JSONRPC: "2.0", Method: "v1.event", Params: params})
+			// the events are returned at or after the time, skip the last one in the next poll
+			since = event.GetTime().AsTime().Add(time.Nanosecond)
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/FlintyLemming/netbird/client/proto"
+)
+
+type mockDaemon struct {
+	proto.UnimplementedDaemonServiceServer
+
+	mu     sync.Mutex
+	events []*proto.Event
+}
+
+func (d *mockDaemon) Status(_ context.Context, req *proto.StatusRequest) (*proto.StatusResponse, error) {
+	resp := &proto.StatusResponse{Status: "Connected", DaemonVersion: "test"}
+	if req.GetGetFullPeerStatus() {
+		resp.FullStatus = &proto.FullStatus{}
+	}
+	return resp, nil
+}
+
+func (d *mockDaemon) Up(context.Context, *proto.UpRequest) (*proto.UpResponse, error) {
+	return nil, status.Errorf(codes.FailedPrecondition, "peer is not logged in")
+}
+
+func (d *mockDaemon) GetEvents(_ context.Context, req *proto.GetEventsRequest) (*proto.GetEventsResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	resp := &proto.GetEventsResponse{}
+	for _, event := range d.events {
+		if !event.GetTime().AsTime().Before(req.GetSince().AsTime()) {
+			resp.Events = append(resp.Events, event)
+		}
+	}
+	return resp, nil
+}
+
+func (d *mockDaemon) addEvent(message string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, &proto.Event{Time: timestamppb.Now(), Category: "peer", Message: message})
+}
+
+type testClient struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func (c *testClient) call(t *testing.T, line string) map[string]any {
+	t.Helper()
+	_, err := c.conn.Write([]byte(line + "\n"))
+	require.NoError(t, err)
+	return c.read(t)
+}
+
+func (c *testClient) read(t *testing.T) map[string]any {
+	t.Helper()
+	require.NoError(t, c.conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	require.True(t, c.scanner.Scan(), "expected a message: %v", c.scanner.Err())
+
+	var msg map[string]any
+	require.NoError(t, json.Unmarshal(c.scanner.Bytes(), &msg))
+	return msg
+}
+
+func startServer(t *testing.T, daemon proto.DaemonServiceServer) *testClient {
+	t.Helper()
+
+	// the temp dirs of the tests may exceed the length limit of socket paths
+	dir, err := os.MkdirTemp("", "nbrpc")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "api.sock")
+	listener, err := ListenUnix(path)
+	require.NoError(t, err)
+
+	server := NewServer(daemon)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &testClient{conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+func TestServer_Calls(t *testing.T) {
+	client := startServer(t, &mockDaemon{})
+
+	resp := client.call(t, `{"jsonrpc":"2.0","id":1,"method":"v1.status","params":{"getFullPeerStatus":true}}`)
+	assert.Equal(t, float64(1), resp["id"])
+	result := resp["result"].(map[string]any)
+	assert.Equal(t, "Connected", result["status"])
+	assert.NotNil(t, result["fullStatus"])
+
+	resp = client.call(t, `{"jsonrpc":"2.0","id":"v","method":"version"}`)
+	assert.Equal(t, "v", resp["id"])
+	assert.Equal(t, float64(APIVersion), resp["result"].(map[string]any)["apiVersion"])
+
+	resp = client.call(t, `{"jsonrpc":"2.0","id":2,"method":"v1.up"}`)
+	rpcErr := resp["error"].(map[string]any)
+	assert.Equal(t, float64(codeDaemonError), rpcErr["code"])
+	assert.Equal(t, "FailedPrecondition", rpcErr["data"])
+
+	resp = client.call(t, `{"jsonrpc":"2.0","id":3,"method":"v1.unknown"}`)
+	assert.Equal(t, float64(codeMethodNotFound), resp["error"].(map[string]any)["code"])
+
+	resp = client.call(t, `{"jsonrpc":"2.0","id":4,"method":"v1.status","params":{"getFullPeerStatus":"yes"}}`)
+	assert.Equal(t, float64(codeInvalidParams), resp["error"].(map[string]any)["code"])
+
+	resp = client.call(t, `{"jsonrpc":`)
+	assert.Nil(t, resp["id"])
+	assert.Equal(t, float64(codeParseError), resp["error"].(map[string]any)["code"])
+
+	// notifications aren't answered, the next response belongs to the following request
+	resp = client.call(t, `{"jsonrpc":"2.0","method":"v1.status"}`+"\n"+`{"jsonrpc":"2.0","id":5,"method":"v1.status"}`)
+	assert.Equal(t, float64(5), resp["id"])
+}
+
+func TestServer_SubscribeEvents(t *testing.T) {
+	daemon := &mockDaemon{}
+	daemon.addEvent("first")
+	client := startServer(t, daemon)
+
+	resp := client.call(t, `{"jsonrpc":"2.0","id":1,"method":"v1.subscribeEvents"}`)
+	require.Nil(t, resp["error"])
+
+	msg := client.read(t)
+	assert.Equal(t, "v1.event", msg["method"])
+	assert.Equal(t, "first", msg["params"].(map[string]any)["message"])
+
+	daemon.addEvent("second")
+	msg = client.read(t)
+	assert.Equal(t, "second", msg["params"].(map[string]any)["message"], "only the new events should be sent")
+}
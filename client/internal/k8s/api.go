@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FlintyLemming/netbird/management/server/http/api"
+)
+
+// APIClient manages the access policies of the Services through the Management service HTTP API
+type APIClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewAPIClient returns a client of the Management service HTTP API at baseURL, e.g. https://api.netbird.io,
+// authenticating with a personal access token
+func NewAPIClient(baseURL, token string) *APIClient {
+	return &APIClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ListGroups returns the groups of the account
+func (c *APIClient) ListGroups(ctx context.Context) ([]api.Group, error) {
+	var groups []api.Group
+	if err := c.do(ctx, http.MethodGet, "/api/groups", nil, &groups); err != nil {
+		return nil, fmt.Errorf("list groups: %w", err)
+	}
+	return groups, nil
+}
+
+// ListPolicies returns the policies of the account
+func (c *APIClient) ListPolicies(ctx context.Context) ([]api.Policy, error) {
+	var policies []api.Policy
+	if err := c.do(ctx, http.MethodGet, "/api/policies", nil, &policies); err != nil {
+		return nil, fmt.Errorf("list policies: %w", err)
+	}
+	return policies, nil
+}
+
+// CreatePolicy creates a policy
+func (c *APIClient) CreatePolicy(ctx context.Context, policy api.PolicyUpdate) error {
+	if err := c.do(ctx, http.MethodPost, "/api/policies", policy, nil); err != nil {
+		return fmt.Errorf("create policy %s: %w", policy.Name, err)
+	}
+	return nil
+}
+
+// UpdatePolicy replaces the policy with the given ID
+func (c *APIClient) UpdatePolicy(ctx context.Context, policyID string, policy api.PolicyUpdate) error {
+	if err := c.do(ctx, http.MethodPut, "/api/policies/"+policyID, policy, nil); err != nil {
+		return fmt.Errorf("update policy %s: %w", policy.Name, err)
+	}
+	return nil
+}
+
+// DeletePolicy deletes the policy with the given ID
+func (c *APIClient) DeletePolicy(ctx context.Context, policyID string) error {
+	if err := c.do(ctx, http.MethodDelete, "/api/policies/"+policyID, nil, nil); err != nil {
+		return fmt.Errorf("delete policy %s: %w", policyID, err)
+	}
+	return nil
+}
+
+func (c *APIClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		content, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Token "+c.token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(content)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
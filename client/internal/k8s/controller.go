@@ -0,0 +1,319 @@
+// Package k8s implements the Kubernetes operator mode of the client. The client runs as a DaemonSet and a controller
+// reconciles the NetBirdRoute custom resources and the annotated Services into routes advertised by the peer and
+// access policies of the Management service, making the pod and Service networks reachable over NetBird.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/http/api"
+)
+
+// DefaultInterval is the default interval at which the resources are reconciled
+const DefaultInterval = 30 * time.Second
+
+const (
+	// policyNamePrefix marks the policies managed by the controller
+	policyNamePrefix  = "k8s:"
+	policyDescription = "Managed by the NetBird Kubernetes operator"
+)
+
+// AdvertiseFunc requests the Management service to route the networks through this peer and to remove the routes of
+// the withdrawn networks with the same network ID
+type AdvertiseFunc func(netID string, networks, withdrawn []string) error
+
+// Controller reconciles the NetBirdRoutes and the annotated Services of the cluster
+type Controller struct {
+	kube      *KubeClient
+	advertise AdvertiseFunc
+	interval  time.Duration
+
+	// api manages the access policies, nil when the policies are not managed
+	api *APIClient
+	// peerGroup is the name of the group of the DaemonSet peers, the destination of the access policies
+	peerGroup string
+
+	// advertised holds the networks advertised successfully by network ID
+	advertised map[string]map[string]struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewController returns a controller reconciling the resources read from the Kubernetes API every interval.
+// The access policies requested by the Services are managed only when apiClient is not nil
+func NewController(kube *KubeClient, advertise AdvertiseFunc, interval time.Duration, apiClient *APIClient, peerGroup string) *Controller {
+	return &Controller{
+		kube:       kube,
+		advertise:  advertise,
+		interval:   interval,
+		api:        apiClient,
+		peerGroup:  peerGroup,
+		advertised: make(map[string]map[string]struct{}),
+	}
+}
+
+// Start reconciles the resources every interval until Stop is called or the context is canceled
+func (c *Controller) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := c.sync(ctx); err != nil {
+				log.Warnf("failed reconciling the Kubernetes resources: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops reconciling the resources. The routes and the policies already applied are kept
+func (c *Controller) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+}
+
+// sync applies the routes and the access policies requested by the resources of the cluster
+func (c *Controller) sync(ctx context.Context) error {
+	routes, err := c.kube.ListRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	services, err := c.kube.ListServices(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.syncRoutes(routes, services); err != nil {
+		return err
+	}
+
+	if c.api == nil {
+		return nil
+	}
+	return c.syncPolicies(ctx, desiredPolicies(services))
+}
+
+// syncRoutes advertises the networks not advertised yet and withdraws the advertised ones no longer requested
+func (c *Controller) syncRoutes(routes []NetBirdRoute, services []Service) error {
+	desired, err := desiredRoutes(routes, services)
+	if err != nil {
+		return err
+	}
+
+	netIDs := make(map[string]struct{}, len(desired)+len(c.advertised))
+	for netID := range desired {
+		netIDs[netID] = struct{}{}
+	}
+	for netID := range c.advertised {
+		netIDs[netID] = struct{}{}
+	}
+
+	for netID := range netIDs {
+		current := make(map[string]struct{}, len(desired[netID]))
+		var networks []string
+		for _, network := range desired[netID] {
+			current[network] = struct{}{}
+			if _, ok := c.advertised[netID][network]; !ok {
+				networks = append(networks, network)
+			}
+		}
+
+		var withdrawn []string
+		for network := range c.advertised[netID] {
+			if _, ok := current[network]; !ok {
+				withdrawn = append(withdrawn, network)
+			}
+		}
+
+		if len(networks) == 0 && len(withdrawn) == 0 {
+			continue
+		}
+
+		sort.Strings(withdrawn)
+		if err := c.advertise(netID, networks, withdrawn); err != nil {
+			return fmt.Errorf("advertise routes of network %s: %w", netID, err)
+		}
+
+		if len(networks) > 0 {
+			log.Infof("advertised routes %v of network %s", networks, netID)
+		}
+		if len(withdrawn) > 0 {
+			log.Infof("withdrew routes %v of network %s", withdrawn, netID)
+		}
+
+		if len(current) == 0 {
+			delete(c.advertised, netID)
+			continue
+		}
+		c.advertised[netID] = current
+	}
+	return nil
+}
+
+// syncPolicies creates or updates the policies requested by the Services and deletes the managed policies of the
+// Services removed or no longer annotated, as well as the duplicates
+func (c *Controller) syncPolicies(ctx context.Context, policies []servicePolicy) error {
+	groups, err := c.api.ListGroups(ctx)
+	if err != nil {
+		return err
+	}
+	groupIDs := make(map[string]string, len(groups))
+	for _, group := range groups {
+		groupIDs[group.Name] = group.Id
+	}
+
+	destinationID, ok := groupIDs[c.peerGroup]
+	if !ok {
+		return fmt.Errorf("peer group %s not found", c.peerGroup)
+	}
+
+	existingPolicies, err := c.api.ListPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]api.Policy)
+	var duplicates []api.Policy
+	for _, policy := range existingPolicies {
+		if policy.Id == nil || policy.Description != policyDescription {
+			continue
+		}
+		// the controllers of the other nodes may create the same policy concurrently
+		if _, ok := existing[policy.Name]; ok {
+			duplicates = append(duplicates, policy)
+			continue
+		}
+		existing[policy.Name] = policy
+	}
+
+	for _, policy := range policies {
+		update, err := toPolicyUpdate(policy, groupIDs, destinationID)
+		if err != nil {
+			log.Warnf("skipping policy %s: %v", policy.name, err)
+			// keep the existing policy until the groups are fixed
+			delete(existing, policy.name)
+			continue
+		}
+
+		current, ok := existing[policy.name]
+		delete(existing, policy.name)
+		switch {
+		case !ok:
+			if err := c.api.CreatePolicy(ctx, update); err != nil {
+				return err
+			}
+			log.Infof("created policy %s", policy.name)
+		case !policyMatches(current, update):
+			if err := c.api.UpdatePolicy(ctx, *current.Id, update); err != nil {
+				return err
+			}
+			log.Infof("updated policy %s", policy.name)
+		}
+	}
+
+	for _, policy := range existing {
+		duplicates = append(duplicates, policy)
+	}
+	for _, policy := range duplicates {
+		if err := c.api.DeletePolicy(ctx, *policy.Id); err != nil {
+			return err
+		}
+		log.Infof("deleted policy %s", policy.Name)
+	}
+	return nil
+}
+
+// toPolicyUpdate returns the policy allowing the source groups to access the ports of the Service through the peers
+// of the destination group, one rule per protocol
+func toPolicyUpdate(policy servicePolicy, groupIDs map[string]string, destinationID string) (api.PolicyUpdate, error) {
+	var sources []string
+	for _, name := range policy.sources {
+		id, ok := groupIDs[name]
+		if !ok {
+			return api.PolicyUpdate{}, fmt.Errorf("source group %s not found", name)
+		}
+		sources = append(sources, id)
+	}
+	sort.Strings(sources)
+
+	protocols := make([]string, 0, len(policy.ports))
+	for protocol := range policy.ports {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+
+	update := api.PolicyUpdate{
+		Name:        policy.name,
+		Description: policyDescription,
+		Enabled:     true,
+	}
+	for _, protocol := range protocols {
+		ports := policy.ports[protocol]
+		update.Rules = append(update.Rules, api.PolicyRuleUpdate{
+			Name:          policy.name + " " + protocol,
+			Action:        api.PolicyRuleUpdateActionAccept,
+			Enabled:       true,
+			Sources:       sources,
+			Destinations:  []string{destinationID},
+			Protocol:      api.PolicyRuleUpdateProtocol(protocol),
+			Ports:         &ports,
+			Bidirectional: false,
+		})
+	}
+	return update, nil
+}
+
+// policyMatches returns true when the rules of the existing policy are the ones of the update
+func policyMatches(current api.Policy, update api.PolicyUpdate) bool {
+	if !current.Enabled || len(current.Rules) != len(update.Rules) {
+		return false
+	}
+
+	for i, rule := range current.Rules {
+		expected := update.Rules[i]
+		if rule.Name != expected.Name || !rule.Enabled || rule.Bidirectional ||
+			string(rule.Action) != string(expected.Action) || string(rule.Protocol) != string(expected.Protocol) {
+			return false
+		}
+
+		var ports []string
+		if rule.Ports != nil {
+			ports = *rule.Ports
+		}
+		if !reflect.DeepEqual(dedupe(ports), *expected.Ports) ||
+			!reflect.DeepEqual(groupIDList(rule.Sources), expected.Sources) ||
+			!reflect.DeepEqual(groupIDList(rule.Destinations), expected.Destinations) {
+			return false
+		}
+	}
+	return true
+}
+
+// groupIDList returns the sorted IDs of the groups
+func groupIDList(groups []api.GroupMinimum) []string {
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, group.Id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,134 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/server/http/api"
+)
+
+type advertiseCall struct {
+	netID     string
+	networks  []string
+	withdrawn []string
+}
+
+func newKubeServer(t *testing.T, routes *[]NetBirdRoute, services *[]Service) *KubeClient {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(routesPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": *routes})
+	})
+	mux.HandleFunc(servicesPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"items": *services})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewKubeClient(server.URL, "", server.Client())
+}
+
+func TestController_SyncRoutes(t *testing.T) {
+	routes := []NetBirdRoute{
+		{
+			Metadata: ObjectMeta{Name: "pods", Namespace: "netbird"},
+			Spec:     NetBirdRouteSpec{Networks: []string{"10.244.0.0/16"}},
+		},
+	}
+	services := []Service{
+		{
+			Metadata: ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{ExposeAnnotation: "true"}},
+			Spec:     ServiceSpec{ClusterIP: "10.96.0.10"},
+		},
+		{
+			Metadata: ObjectMeta{Name: "db", Namespace: "default"},
+			Spec:     ServiceSpec{ClusterIP: "10.96.0.20"},
+		},
+	}
+
+	var calls []advertiseCall
+	advertise := func(netID string, networks, withdrawn []string) error {
+		calls = append(calls, advertiseCall{netID: netID, networks: networks, withdrawn: withdrawn})
+		return nil
+	}
+	controller := NewController(newKubeServer(t, &routes, &services), advertise, time.Minute, nil, "")
+
+	require.NoError(t, controller.sync(context.Background()))
+	assert.ElementsMatch(t, []advertiseCall{
+		{netID: "pods", networks: []string{"10.244.0.0/16"}},
+		{netID: "default-web", networks: []string{"10.96.0.10/32"}},
+	}, calls)
+
+	calls = nil
+	require.NoError(t, controller.sync(context.Background()))
+	assert.Empty(t, calls, "unchanged resources should not be advertised again")
+
+	services = services[1:]
+	require.NoError(t, controller.sync(context.Background()))
+	assert.Equal(t, []advertiseCall{{netID: "default-web", withdrawn: []string{"10.96.0.10/32"}}}, calls)
+	assert.NotContains(t, controller.advertised, "default-web")
+}
+
+func TestController_SyncPolicies(t *testing.T) {
+	services := []Service{
+		{
+			Metadata: ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{PolicySourcesAnnotation: "devs"}},
+			Spec:     ServiceSpec{ClusterIP: "10.96.0.10", Ports: []ServicePort{{Port: 443, Protocol: "TCP"}, {Port: 53, Protocol: "UDP"}}},
+		},
+	}
+	var routes []NetBirdRoute
+
+	policies := []api.Policy{
+		{Id: ptr("stale"), Name: policyNamePrefix + "default/old", Description: policyDescription},
+		{Id: ptr("admin"), Name: "admin policy", Description: "created by an admin"},
+	}
+	var created []api.PolicyUpdate
+	var deleted []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/groups", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]api.Group{{Id: "g1", Name: "devs"}, {Id: "g2", Name: "k8s-nodes"}})
+	})
+	mux.HandleFunc("/api/policies", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var policy api.PolicyUpdate
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&policy))
+			created = append(created, policy)
+		}
+		_ = json.NewEncoder(w).Encode(policies)
+	})
+	mux.HandleFunc("/api/policies/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deleted = append(deleted, r.URL.Path[len("/api/policies/"):])
+		}
+		_, _ = w.Write([]byte("{}"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	advertise := func(string, []string, []string) error { return nil }
+	controller := NewController(newKubeServer(t, &routes, &services), advertise, time.Minute, NewAPIClient(server.URL, "token"), "k8s-nodes")
+
+	require.NoError(t, controller.sync(context.Background()))
+	require.Len(t, created, 1)
+	assert.Equal(t, policyNamePrefix+"default/web", created[0].Name)
+	require.Len(t, created[0].Rules, 2)
+	assert.Equal(t, api.PolicyRuleUpdateProtocolTcp, created[0].Rules[0].Protocol)
+	assert.Equal(t, []string{"443"}, *created[0].Rules[0].Ports)
+	assert.Equal(t, []string{"g1"}, created[0].Rules[0].Sources)
+	assert.Equal(t, []string{"g2"}, created[0].Rules[0].Destinations)
+	assert.Equal(t, api.PolicyRuleUpdateProtocolUdp, created[0].Rules[1].Protocol)
+	assert.Equal(t, []string{"stale"}, deleted, "only the stale managed policy should be deleted")
+}
+
+func ptr(value string) *string {
+	return &value
+}
@@ -0,0 +1,125 @@
+package k8s
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	routesPath   = "/apis/netbird.io/v1alpha1/netbirdroutes"
+	servicesPath = "/api/v1/services"
+)
+
+// ErrNotInCluster is returned when the controller doesn't run in a Kubernetes pod
+var ErrNotInCluster = errors.New("not running in a Kubernetes cluster, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+
+// KubeClient reads the resources reconciled by the controller from the Kubernetes API
+type KubeClient struct {
+	baseURL    string
+	token      string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// NewKubeClient returns a client of the Kubernetes API at baseURL authenticating with the bearer token when not empty
+func NewKubeClient(baseURL, token string, httpClient *http.Client) *KubeClient {
+	return &KubeClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		httpClient: httpClient,
+	}
+}
+
+// NewInClusterKubeClient returns a client of the Kubernetes API authenticating with the service account of the pod
+func NewInClusterKubeClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+
+	caCert, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no certificate found in the service account CA")
+	}
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+	}
+	client := NewKubeClient("https://"+net.JoinHostPort(host, port), "", httpClient)
+	// the token is read on every request as the kubelet rotates it
+	client.tokenFile = serviceAccountDir + "/token"
+	return client, nil
+}
+
+// ListRoutes returns the NetBirdRoutes of all namespaces
+func (c *KubeClient) ListRoutes(ctx context.Context) ([]NetBirdRoute, error) {
+	var list struct {
+		Items []NetBirdRoute `json:"items"`
+	}
+	if err := c.get(ctx, routesPath, &list); err != nil {
+		return nil, fmt.Errorf("list NetBirdRoutes: %w", err)
+	}
+	return list.Items, nil
+}
+
+// ListServices returns the Services of all namespaces
+func (c *KubeClient) ListServices(ctx context.Context) ([]Service, error) {
+	var list struct {
+		Items []Service `json:"items"`
+	}
+	if err := c.get(ctx, servicesPath, &list); err != nil {
+		return nil, fmt.Errorf("list Services: %w", err)
+	}
+	return list.Items, nil
+}
+
+func (c *KubeClient) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		content, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("read service account token: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
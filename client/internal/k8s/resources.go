@@ -0,0 +1,191 @@
+package k8s
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+const (
+	// ExposeAnnotation set to "true" on a Service routes its cluster IPs through the peers of the DaemonSet
+	ExposeAnnotation = "netbird.io/expose"
+	// NetworkIDAnnotation overrides the network identifier of the routes of an exposed Service
+	NetworkIDAnnotation = "netbird.io/network-id"
+	// PolicySourcesAnnotation lists the comma separated names of the groups allowed to access the ports of a Service
+	PolicySourcesAnnotation = "netbird.io/policy-source-groups"
+)
+
+// ObjectMeta holds the metadata of the Kubernetes objects read by the controller
+type ObjectMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NetBirdRoute is a custom resource requesting the peers of the DaemonSet to route networks of the cluster,
+// e.g. the pod or the Service CIDR
+type NetBirdRoute struct {
+	Metadata ObjectMeta       `json:"metadata"`
+	Spec     NetBirdRouteSpec `json:"spec"`
+}
+
+// NetBirdRouteSpec is the desired state of a NetBirdRoute
+type NetBirdRouteSpec struct {
+	// Networks to route in CIDR notation
+	Networks []string `json:"networks"`
+	// NetworkID of the routes, the name of the resource is used when empty
+	NetworkID string `json:"networkId,omitempty"`
+}
+
+// Service is the subset of a Kubernetes Service used by the controller
+type Service struct {
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     ServiceSpec `json:"spec"`
+}
+
+// ServiceSpec is the subset of the spec of a Kubernetes Service used by the controller
+type ServiceSpec struct {
+	ClusterIP  string        `json:"clusterIP,omitempty"`
+	ClusterIPs []string      `json:"clusterIPs,omitempty"`
+	Ports      []ServicePort `json:"ports,omitempty"`
+}
+
+// ServicePort is a port exposed by a Kubernetes Service
+type ServicePort struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// servicePolicy is the access policy requested by the annotations of a Service
+type servicePolicy struct {
+	name    string
+	sources []string
+	// ports by lowercase protocol
+	ports map[string][]string
+}
+
+// desiredRoutes returns the networks to route by network ID, requested by the NetBirdRoutes and the exposed Services
+func desiredRoutes(routes []NetBirdRoute, services []Service) (map[string][]string, error) {
+	desired := make(map[string][]string)
+	for _, r := range routes {
+		netID := r.Spec.NetworkID
+		if netID == "" {
+			netID = r.Metadata.Name
+		}
+		for _, network := range r.Spec.Networks {
+			prefix, err := netip.ParsePrefix(network)
+			if err != nil {
+				return nil, fmt.Errorf("NetBirdRoute %s/%s: invalid network %s", r.Metadata.Namespace, r.Metadata.Name, network)
+			}
+			desired[netID] = append(desired[netID], prefix.Masked().String())
+		}
+	}
+
+	for _, service := range services {
+		if service.Metadata.Annotations[ExposeAnnotation] != "true" {
+			continue
+		}
+		netID := service.Metadata.Annotations[NetworkIDAnnotation]
+		if netID == "" {
+			netID = service.Metadata.Namespace + "-" + service.Metadata.Name
+		}
+		for _, ip := range serviceIPs(service) {
+			desired[netID] = append(desired[netID], netip.PrefixFrom(ip, ip.BitLen()).String())
+		}
+	}
+
+	for netID, networks := range desired {
+		desired[netID] = dedupe(networks)
+	}
+	return desired, nil
+}
+
+// serviceIPs returns the cluster IPs of the Service, headless Services have none
+func serviceIPs(service Service) []netip.Addr {
+	values := service.Spec.ClusterIPs
+	if len(values) == 0 && service.Spec.ClusterIP != "" {
+		values = []string{service.Spec.ClusterIP}
+	}
+
+	var ips []netip.Addr
+	for _, value := range values {
+		ip, err := netip.ParseAddr(value)
+		if err != nil {
+			continue
+		}
+		ips = append(ips, ip.Unmap())
+	}
+	return ips
+}
+
+// desiredPolicies returns the access policies requested by the annotations of the Services, sorted by name
+func desiredPolicies(services []Service) []servicePolicy {
+	var policies []servicePolicy
+	for _, service := range services {
+		sources := splitList(service.Metadata.Annotations[PolicySourcesAnnotation])
+		if len(sources) == 0 || len(service.Spec.Ports) == 0 {
+			continue
+		}
+
+		ports := make(map[string][]string)
+		for _, port := range service.Spec.Ports {
+			protocol := strings.ToLower(port.Protocol)
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			// SCTP is not supported by the NetBird firewall
+			if protocol != "tcp" && protocol != "udp" {
+				continue
+			}
+			ports[protocol] = append(ports[protocol], fmt.Sprint(port.Port))
+		}
+		if len(ports) == 0 {
+			continue
+		}
+		for protocol := range ports {
+			ports[protocol] = dedupe(ports[protocol])
+		}
+
+		policies = append(policies, servicePolicy{
+			name:    policyName(service),
+			sources: dedupe(sources),
+			ports:   ports,
+		})
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].name < policies[j].name
+	})
+	return policies
+}
+
+// policyName returns the name of the policy managed for the Service
+func policyName(service Service) string {
+	return policyNamePrefix + service.Metadata.Namespace + "/" + service.Metadata.Name
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// dedupe returns the sorted values without duplicates
+func dedupe(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	var unique []string
+	for _, value := range values {
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pion/stun/v2"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/client/firewall"
+	"github.com/FlintyLemming/netbird/client/firewall/manager"
+	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+)
+
+var (
+	killSwitchMu sync.Mutex
+	// killSwitchGeneration is increased by ReleaseKillSwitch, the clients started before a release don't enable the
+	// kill switch again while they stop
+	killSwitchGeneration int
+)
+
+// currentKillSwitchGeneration returns the generation a client started now enables the kill switch with
+func currentKillSwitchGeneration() int {
+	killSwitchMu.Lock()
+	defer killSwitchMu.Unlock()
+	return killSwitchGeneration
+}
+
+// enableKillSwitch installs the kill switch letting through the tunnel and the control plane the client knows of: the
+// Management service and, once logged in, the Signal service and the STUN/TURN servers of the Wiretrustee config.
+// The extra addresses are let through too, e.g. the captive portal probe host. The rules are kept when the client
+// stops, so that no traffic leaks while it reconnects
+func enableKillSwitch(ctx context.Context, generation int, config *Config, wtConfig *mgmProto.WiretrusteeConfig, extra []net.IP) {
+	hosts := []string{config.ManagementURL.Hostname()}
+	if wtConfig != nil {
+		hosts = append(hosts, controlPlaneHosts(wtConfig)...)
+	}
+
+	ksConfig := manager.KillSwitchConfig{
+		Interface:    config.WgIface,
+		WgPort:       config.WgPort,
+		ControlPlane: append(resolveHosts(ctx, hosts), extra...),
+	}
+
+	killSwitchMu.Lock()
+	defer killSwitchMu.Unlock()
+
+	if generation != killSwitchGeneration {
+		log.Debugf("the kill switch was released, not enabling it again")
+		return
+	}
+
+	ks, err := firewall.NewKillSwitch()
+	if err != nil {
+		log.Errorf("failed to create the kill switch: %v", err)
+		return
+	}
+	if err := ks.Enable(ksConfig); err != nil {
+		log.Errorf("failed to enable the kill switch: %v", err)
+		return
+	}
+	log.Infof("kill switch enabled, allowing %d control plane addresses", len(ksConfig.ControlPlane))
+}
+
+// ReleaseKillSwitch removes the kill switch rules, the traffic outside the tunnel is allowed again
+func ReleaseKillSwitch() error {
+	killSwitchMu.Lock()
+	defer killSwitchMu.Unlock()
+
+	killSwitchGeneration++
+	if err := disableKillSwitch(); err != nil {
+		return err
+	}
+	log.Info("kill switch released")
+	return nil
+}
+
+// disableKillSwitch removes the kill switch rules, the caller holds killSwitchMu
+func disableKillSwitch() error {
+	ks, err := firewall.NewKillSwitch()
+	if err != nil {
+		return err
+	}
+	return ks.Disable()
+}
+
+// controlPlaneHosts returns the hosts of the Signal service and the STUN/TURN servers
+func controlPlaneHosts(wtConfig *mgmProto.WiretrusteeConfig) []string {
+	var hosts []string
+	if signalHost, _, err := net.SplitHostPort(wtConfig.GetSignal().GetUri()); err == nil {
+		hosts = append(hosts, signalHost)
+	} else {
+		log.Warnf("failed to parse the Signal address %s: %v", wtConfig.GetSignal().GetUri(), err)
+	}
+
+	uris := make([]string, 0, len(wtConfig.GetStuns())+len(wtConfig.GetTurns()))
+	for _, s := range wtConfig.GetStuns() {
+		uris = append(uris, s.GetUri())
+	}
+	for _, t := range wtConfig.GetTurns() {
+		uris = append(uris, t.GetHostConfig().GetUri())
+	}
+	for _, uri := range uris {
+		url, err := stun.ParseURI(uri)
+		if err != nil {
+			log.Warnf("failed to parse the STUN/TURN URI %s: %v", uri, err)
+			continue
+		}
+		hosts = append(hosts, url.Host)
+	}
+	return hosts
+}
+
+// resolveHosts returns the addresses of the hosts, the hosts which fail to resolve are skipped
+func resolveHosts(ctx context.Context, hosts []string) []net.IP {
+	var ips []net.IP
+	seen := make(map[string]struct{})
+	for _, host := range hosts {
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			log.Warnf("failed to resolve the control plane host %s for the kill switch: %v", host, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if _, ok := seen[addr.String()]; ok {
+				continue
+			}
+			seen[addr.String()] = struct{}{}
+			ips = append(ips, addr)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,191 @@
+package latency
+
+import (
+	"context"
+	"math/rand"
+	"net/netip"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultInterval is the default interval at which a sample of the peers is measured
+	DefaultInterval = 5 * time.Minute
+	// DefaultSampleSize is the default number of peers measured every interval
+	DefaultSampleSize = 10
+
+	// pingCount is the number of pings sent to every peer of the sample
+	pingCount = 5
+	// pingTimeout is the time to wait for the reply of a ping
+	pingTimeout = time.Second
+)
+
+// Peer is a remote peer reachable over the tunnel
+type Peer struct {
+	// Key is the WireGuard public key of the peer
+	Key string
+	// IP is the NetBird address of the peer
+	IP netip.Addr
+}
+
+// Result is the latency and packet loss measured to a peer
+type Result struct {
+	// PeerKey is the WireGuard public key of the peer
+	PeerKey string
+	// RTT is the average round trip time of the answered pings
+	RTT time.Duration
+	// Sent is the number of pings sent
+	Sent int
+	// Received is the number of pings answered
+	Received int
+}
+
+// PeersFunc returns the peers that can be measured, usually the connected ones
+type PeersFunc func() []Peer
+
+// ReportFunc sends the results of a measurement round to the Management service
+type ReportFunc func(results []Result) error
+
+// PingFunc sends count pings to the address and returns the round trip time of the answered ones
+type PingFunc func(ctx context.Context, ip netip.Addr, count int, timeout time.Duration) ([]time.Duration, error)
+
+// Measurer pings a sample of the peers over the tunnel every interval and reports the latency and packet loss.
+// The peers measured the longest time ago are sampled first, so that every peer is measured over several rounds
+type Measurer struct {
+	peers      PeersFunc
+	report     ReportFunc
+	ping       PingFunc
+	interval   time.Duration
+	sampleSize int
+
+	// lastMeasured holds the time of the last measurement of the peers, keyed by WireGuard public key
+	lastMeasured map[string]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMeasurer creates a Measurer pinging sampleSize peers every interval with ICMP echo requests
+func NewMeasurer(peers PeersFunc, report ReportFunc, interval time.Duration, sampleSize int) *Measurer {
+	return newMeasurer(peers, report, Ping, interval, sampleSize)
+}
+
+func newMeasurer(peers PeersFunc, report ReportFunc, ping PingFunc, interval time.Duration, sampleSize int) *Measurer {
+	return &Measurer{
+		peers:        peers,
+		report:       report,
+		ping:         ping,
+		interval:     interval,
+		sampleSize:   sampleSize,
+		lastMeasured: make(map[string]time.Time),
+	}
+}
+
+// Start measures a sample of the peers every interval until Stop is called. The first round runs after an interval,
+// giving the peers time to connect
+func (m *Measurer) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			if err := m.measure(ctx); err != nil {
+				log.Warnf("failed reporting the latency to peers, retrying in %s: %v", m.interval, err)
+			}
+		}
+	}()
+}
+
+// Stop stops measuring, interrupting a measurement in progress
+func (m *Measurer) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// measure pings a sample of the peers and reports the results
+func (m *Measurer) measure(ctx context.Context) error {
+	sample := m.sample(m.peers())
+	if len(sample) == 0 {
+		return nil
+	}
+
+	results := make([]Result, 0, len(sample))
+	for _, peer := range sample {
+		rtts, err := m.ping(ctx, peer.IP, pingCount, pingTimeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Debugf("failed pinging peer %s: %v", peer.IP, err)
+			continue
+		}
+
+		m.lastMeasured[peer.Key] = time.Now()
+		results = append(results, newResult(peer.Key, rtts))
+	}
+
+	if len(results) == 0 {
+		return nil
+	}
+	return m.report(results)
+}
+
+// sample returns the peers measured the longest time ago, picking randomly among the peers measured at the same time
+func (m *Measurer) sample(peers []Peer) []Peer {
+	current := make(map[string]struct{}, len(peers))
+	for _, peer := range peers {
+		current[peer.Key] = struct{}{}
+	}
+	// forget the peers no longer available
+	for key := range m.lastMeasured {
+		if _, ok := current[key]; !ok {
+			delete(m.lastMeasured, key)
+		}
+	}
+
+	sample := make([]Peer, len(peers))
+	copy(sample, peers)
+	rand.Shuffle(len(sample), func(i, j int) {
+		sample[i], sample[j] = sample[j], sample[i]
+	})
+	sort.SliceStable(sample, func(i, j int) bool {
+		return m.lastMeasured[sample[i].Key].Before(m.lastMeasured[sample[j].Key])
+	})
+
+	if len(sample) > m.sampleSize {
+		sample = sample[:m.sampleSize]
+	}
+	return sample
+}
+
+func newResult(peerKey string, rtts []time.Duration) Result {
+	result := Result{
+		PeerKey:  peerKey,
+		Sent:     pingCount,
+		Received: len(rtts),
+	}
+	if len(rtts) == 0 {
+		return result
+	}
+
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	result.RTT = total / time.Duration(len(rtts))
+	return result
+}
@@ -0,0 +1,115 @@
+package latency
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPeers(count int) []Peer {
+	peers := make([]Peer, 0, count)
+	ip := netip.MustParseAddr("100.64.0.1")
+	for i := 0; i < count; i++ {
+		ip = ip.Next()
+		peers = append(peers, Peer{Key: ip.String(), IP: ip})
+	}
+	return peers
+}
+
+func TestMeasurer_SamplesLeastRecentlyMeasured(t *testing.T) {
+	peers := testPeers(5)
+	pinged := make(map[string]int)
+
+	m := newMeasurer(
+		func() []Peer { return peers },
+		func([]Result) error { return nil },
+		func(_ context.Context, ip netip.Addr, _ int, _ time.Duration) ([]time.Duration, error) {
+			pinged[ip.String()]++
+			return []time.Duration{time.Millisecond}, nil
+		},
+		time.Minute, 2,
+	)
+
+	for round := 0; round < 3; round++ {
+		require.NoError(t, m.measure(context.Background()))
+		// the rounds must be told apart by the time of the measurement
+		time.Sleep(time.Millisecond)
+	}
+
+	assert.Len(t, pinged, 5, "every peer should be measured within three rounds")
+	for ip, count := range pinged {
+		assert.LessOrEqual(t, count, 2, "peer %s measured too often", ip)
+	}
+
+	peers = peers[:1]
+	require.NoError(t, m.measure(context.Background()))
+	assert.Len(t, m.lastMeasured, 1, "peers no longer available should be forgotten")
+}
+
+func TestMeasurer_Results(t *testing.T) {
+	peers := testPeers(3)
+	var reported []Result
+
+	m := newMeasurer(
+		func() []Peer { return peers },
+		func(results []Result) error {
+			reported = results
+			return nil
+		},
+		func(_ context.Context, ip netip.Addr, _ int, _ time.Duration) ([]time.Duration, error) {
+			switch ip {
+			case peers[0].IP:
+				return []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}, nil
+			case peers[1].IP:
+				return nil, nil
+			default:
+				return nil, errors.New("no ICMP socket")
+			}
+		},
+		time.Minute, DefaultSampleSize,
+	)
+
+	require.NoError(t, m.measure(context.Background()))
+	require.Len(t, reported, 2, "peers failing to be pinged should not be reported")
+
+	results := make(map[string]Result)
+	for _, result := range reported {
+		results[result.PeerKey] = result
+	}
+
+	assert.Equal(t, Result{PeerKey: peers[0].Key, RTT: 20 * time.Millisecond, Sent: pingCount, Received: 3}, results[peers[0].Key])
+	assert.Equal(t, Result{PeerKey: peers[1].Key, Sent: pingCount}, results[peers[1].Key])
+}
+
+func TestMeasurer_StartStop(t *testing.T) {
+	reported := make(chan []Result, 1)
+
+	m := newMeasurer(
+		func() []Peer { return testPeers(1) },
+		func(results []Result) error {
+			select {
+			case reported <- results:
+			default:
+			}
+			return nil
+		},
+		func(context.Context, netip.Addr, int, time.Duration) ([]time.Duration, error) {
+			return []time.Duration{time.Millisecond}, nil
+		},
+		10*time.Millisecond, DefaultSampleSize,
+	)
+
+	m.Start(context.Background())
+	select {
+	case results := <-reported:
+		assert.Len(t, results, 1)
+	case <-time.After(time.Second):
+		t.Fatal("no latency reported")
+	}
+	m.Stop()
+}
@@ -0,0 +1,131 @@
+package latency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// protocolICMP is the IANA protocol number of ICMP for IPv4
+const protocolICMP = 1
+
+// Ping sends count ICMP echo requests to the address one after another and returns the round trip time of the
+// answered ones. A raw socket is used when permitted, otherwise an unprivileged ICMP datagram socket
+func Ping(ctx context.Context, ip netip.Addr, count int, timeout time.Duration) ([]time.Duration, error) {
+	if !ip.Is4() {
+		return nil, fmt.Errorf("unsupported address %s", ip)
+	}
+
+	privileged := true
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		privileged = false
+		conn, err = icmp.ListenPacket("udp4", "0.0.0.0")
+		if err != nil {
+			return nil, fmt.Errorf("open ICMP socket: %w", err)
+		}
+	}
+	defer conn.Close()
+
+	// interrupt the pending read when the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	var dst net.Addr = &net.IPAddr{IP: ip.AsSlice()}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip.AsSlice()}
+	}
+
+	// the kernel sets the identifier of the unprivileged sockets, it is only checked on raw sockets
+	id := rand.Intn(0xffff)
+	var rtts []time.Duration
+	for seq := 0; seq < count; seq++ {
+		if ctx.Err() != nil {
+			return rtts, ctx.Err()
+		}
+
+		request := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("netbird-latency")},
+		}
+		b, err := request.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(b, dst); err != nil {
+			return nil, fmt.Errorf("send echo request: %w", err)
+		}
+
+		answered, err := waitReply(conn, ip, id, seq, privileged, start.Add(timeout))
+		if err != nil {
+			return nil, err
+		}
+		if answered {
+			rtts = append(rtts, time.Since(start))
+		}
+	}
+	return rtts, nil
+}
+
+// waitReply reads until the echo reply with the sequence number arrives from the address or the deadline passes
+func waitReply(conn *icmp.PacketConn, ip netip.Addr, id, seq int, privileged bool, deadline time.Time) (bool, error) {
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return false, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("read echo reply: %w", err)
+		}
+
+		if !replyFrom(peer, ip) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || privileged && echo.ID != id {
+			continue
+		}
+		return true, nil
+	}
+}
+
+func replyFrom(addr net.Addr, ip netip.Addr) bool {
+	var from net.IP
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		from = a.IP
+	case *net.UDPAddr:
+		from = a.IP
+	default:
+		return false
+	}
+	fromAddr, ok := netip.AddrFromSlice(from)
+	return ok && fromAddr.Unmap() == ip
+}
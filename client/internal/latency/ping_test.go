@@ -0,0 +1,18 @@
+package latency
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing_Loopback(t *testing.T) {
+	rtts, err := Ping(context.Background(), netip.MustParseAddr("127.0.0.1"), 2, time.Second)
+	if err != nil {
+		t.Skipf("ICMP sockets not permitted: %v", err)
+	}
+	assert.Len(t, rtts, 2)
+}
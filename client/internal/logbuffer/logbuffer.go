@@ -0,0 +1,86 @@
+package logbuffer
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultSize is the default number of log lines kept in the buffer
+const DefaultSize = 5000
+
+// Buffer is a logrus hook keeping the latest formatted log lines in memory, overwriting the oldest ones once full
+type Buffer struct {
+	mu    sync.Mutex
+	lines []string
+	// next is the position of the next line written to the buffer
+	next int
+	full bool
+}
+
+// New creates a Buffer holding up to size lines
+func New(size int) *Buffer {
+	return &Buffer{
+		lines: make([]string, size),
+	}
+}
+
+// Levels returns the levels fired by the hook, the logger level filters the entries before they reach the hook
+func (b *Buffer) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire formats the entry with the formatter of its logger and adds it to the buffer
+func (b *Buffer) Fire(entry *log.Entry) error {
+	line := entry.Message
+	if entry.Logger != nil && entry.Logger.Formatter != nil {
+		formatted, err := entry.Logger.Formatter.Format(entry)
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(string(formatted), "\n")
+	}
+
+	b.add(line)
+	return nil
+}
+
+func (b *Buffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) == 0 {
+		return
+	}
+
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Lines returns up to n of the latest lines from the oldest to the newest, n <= 0 returns all kept lines
+func (b *Buffer) Lines(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := b.next
+	if b.full {
+		kept = len(b.lines)
+	}
+	if n <= 0 || n > kept {
+		n = kept
+	}
+
+	lines := make([]string, 0, n)
+	start := b.next - n
+	if start < 0 {
+		start += len(b.lines)
+	}
+	for i := 0; i < n; i++ {
+		lines = append(lines, b.lines[(start+i)%len(b.lines)])
+	}
+	return lines
+}
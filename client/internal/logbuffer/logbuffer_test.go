@@ -0,0 +1,45 @@
+package logbuffer
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer_Lines(t *testing.T) {
+	b := New(3)
+	assert.Empty(t, b.Lines(0))
+
+	b.add("1")
+	b.add("2")
+	assert.Equal(t, []string{"1", "2"}, b.Lines(0))
+	assert.Equal(t, []string{"2"}, b.Lines(1))
+
+	for i := 3; i <= 5; i++ {
+		b.add(fmt.Sprint(i))
+	}
+	assert.Equal(t, []string{"3", "4", "5"}, b.Lines(0))
+	assert.Equal(t, []string{"4", "5"}, b.Lines(2))
+	assert.Equal(t, []string{"3", "4", "5"}, b.Lines(10))
+}
+
+func TestBuffer_Fire(t *testing.T) {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.SetFormatter(&log.TextFormatter{DisableTimestamp: true})
+	logger.SetLevel(log.InfoLevel)
+
+	b := New(10)
+	logger.AddHook(b)
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+
+	lines := b.Lines(0)
+	require.Len(t, lines, 1)
+	assert.Equal(t, `level=info msg=shown`, lines[0])
+}
@@ -2,23 +2,33 @@ package internal
 
 import (
 	"context"
+	"net/netip"
 	"net/url"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/FlintyLemming/netbird/client/firewall"
+	"github.com/FlintyLemming/netbird/client/internal/attestation"
+	"github.com/FlintyLemming/netbird/client/internal/postquantum"
 	"github.com/FlintyLemming/netbird/client/ssh"
 	"github.com/FlintyLemming/netbird/client/system"
+	"github.com/FlintyLemming/netbird/iface"
+	"github.com/FlintyLemming/netbird/iface/netstack"
 	mgm "github.com/FlintyLemming/netbird/management/client"
 	mgmProto "github.com/FlintyLemming/netbird/management/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
 // IsLoginRequired check that the server is support SSO or not
-func IsLoginRequired(ctx context.Context, privateKey string, mgmURL *url.URL, sshKey string) (bool, error) {
-	mgmClient, err := getMgmClient(ctx, privateKey, mgmURL)
+func IsLoginRequired(ctx context.Context, config *Config) (bool, error) {
+	mgmURL := config.ManagementURL
+	mgmClient, err := getMgmClient(ctx, config.PrivateKey, mgmURL, config.AttestationProvider)
 	if err != nil {
 		return false, err
 	}
@@ -33,12 +43,12 @@ func IsLoginRequired(ctx context.Context, privateKey string, mgmURL *url.URL, ss
 	}()
 	log.Debugf("connected to the Management service %s", mgmURL.String())
 
-	pubSSHKey, err := ssh.GeneratePublicKey([]byte(sshKey))
+	pubSSHKey, err := ssh.GeneratePublicKey([]byte(config.SSHKey))
 	if err != nil {
 		return false, err
 	}
 
-	_, err = doMgmLogin(ctx, mgmClient, pubSSHKey)
+	_, err = doMgmLogin(ctx, mgmClient, pubSSHKey, peerCapabilities(config), peerServices(config), peerLANNetworks(config))
 	if isLoginNeeded(err) {
 		return true, nil
 	}
@@ -47,7 +57,15 @@ func IsLoginRequired(ctx context.Context, privateKey string, mgmURL *url.URL, ss
 
 // Login or register the client
 func Login(ctx context.Context, config *Config, setupKey string, jwtToken string) error {
-	mgmClient, err := getMgmClient(ctx, config.PrivateKey, config.ManagementURL)
+	ctx, span := tracer.Start(ctx, "Login", trace.WithAttributes(attribute.String("management.url", config.ManagementURL.String())))
+	err := login(ctx, config, setupKey, jwtToken)
+	tracing.End(span, err)
+	return err
+}
+
+// login logs in or registers the peer, the calls to the Management service are traced in the span of the context
+func login(ctx context.Context, config *Config, setupKey string, jwtToken string) error {
+	mgmClient, err := getMgmClient(ctx, config.PrivateKey, config.ManagementURL, config.AttestationProvider)
 	if err != nil {
 		return err
 	}
@@ -67,17 +85,20 @@ func Login(ctx context.Context, config *Config, setupKey string, jwtToken string
 		return err
 	}
 
-	serverKey, err := doMgmLogin(ctx, mgmClient, pubSSHKey)
+	capabilities := peerCapabilities(config)
+	services := peerServices(config)
+	lanNetworks := peerLANNetworks(config)
+	serverKey, err := doMgmLogin(ctx, mgmClient, pubSSHKey, capabilities, services, lanNetworks)
 	if isRegistrationNeeded(err) {
 		log.Debugf("peer registration required")
-		_, err = registerPeer(ctx, *serverKey, mgmClient, setupKey, jwtToken, pubSSHKey)
+		_, err = registerPeer(ctx, *serverKey, mgmClient, setupKey, jwtToken, pubSSHKey, capabilities, services, lanNetworks)
 		return err
 	}
 
 	return err
 }
 
-func getMgmClient(ctx context.Context, privateKey string, mgmURL *url.URL) (*mgm.GrpcClient, error) {
+func getMgmClient(ctx context.Context, privateKey string, mgmURL *url.URL, attestationProvider string) (*mgm.GrpcClient, error) {
 	// validate our peer's Wireguard PRIVATE key
 	myPrivateKey, err := wgtypes.ParseKey(privateKey)
 	if err != nil {
@@ -96,10 +117,75 @@ func getMgmClient(ctx context.Context, privateKey string, mgmURL *url.URL) (*mgm
 		log.Errorf("failed connecting to the Management service %s %v", mgmURL.String(), err)
 		return nil, err
 	}
+
+	err = setAttestation(mgmClient, attestationProvider)
+	if err != nil {
+		_ = mgmClient.Close()
+		return nil, err
+	}
 	return mgmClient, err
 }
 
-func doMgmLogin(ctx context.Context, mgmClient *mgm.GrpcClient, pubSSHKey []byte) (*wgtypes.Key, error) {
+// setAttestation makes the Management client attach a hardware-backed attestation statement to login requests
+func setAttestation(mgmClient *mgm.GrpcClient, provider string) error {
+	if provider == "" {
+		return nil
+	}
+
+	signer, err := attestation.NewSigner(provider)
+	if err != nil {
+		return err
+	}
+
+	mgmClient.SetAttestationFunc(func(wgPubKey string) (*mgmProto.PeerAttestation, error) {
+		return attestation.NewStatement(signer, wgPubKey)
+	})
+	return nil
+}
+
+// peerCapabilities returns the optional features enabled by the config to be advertised to other peers
+func peerCapabilities(config *Config) []string {
+	var capabilities []string
+	if config.PostQuantumEnabled {
+		capabilities = append(capabilities, postquantum.Capability)
+	}
+	return capabilities
+}
+
+// peerServices returns the services of the config advertised to the other peers
+func peerServices(config *Config) []system.Service {
+	services, err := system.ParseServices(config.Services)
+	if err != nil {
+		log.Warnf("not advertising the services of the config: %v", err)
+		return nil
+	}
+	return services
+}
+
+// peerLANNetworks returns the networks of the LAN interfaces reported to the Management service when the detection
+// is enabled in the config
+func peerLANNetworks(config *Config) []netip.Prefix {
+	if !config.DetectLANRoutes {
+		return nil
+	}
+	return system.DetectLANNetworks(config.WgIface)
+}
+
+// firewallCapabilities returns the features of the firewall enforcing the firewall rules, the Management service
+// optimizes the rules it sends to the peer for them
+func firewallCapabilities() system.FirewallCapabilities {
+	userspaceBind := netstack.IsEnabled() || !iface.WireGuardModuleIsLoaded()
+	capabilities := firewall.DetectCapabilities(userspaceBind)
+	return system.FirewallCapabilities{
+		IPSet:      capabilities.IPSet,
+		PortRanges: capabilities.PortRanges,
+		Reject:     capabilities.Reject,
+		IPv6:       capabilities.IPv6,
+		SCTPAndGRE: capabilities.SCTPAndGRE,
+	}
+}
+
+func doMgmLogin(ctx context.Context, mgmClient *mgm.GrpcClient, pubSSHKey []byte, capabilities []string, services []system.Service, lanNetworks []netip.Prefix) (*wgtypes.Key, error) {
 	serverKey, err := mgmClient.GetServerPublicKey()
 	if err != nil {
 		log.Errorf("failed while getting Management Service public key: %v", err)
@@ -107,13 +193,17 @@ func doMgmLogin(ctx context.Context, mgmClient *mgm.GrpcClient, pubSSHKey []byte
 	}
 
 	sysInfo := system.GetInfo(ctx)
+	sysInfo.Capabilities = capabilities
+	sysInfo.FirewallCapabilities = firewallCapabilities()
+	sysInfo.Services = services
+	sysInfo.LANNetworks = lanNetworks
 	_, err = mgmClient.Login(*serverKey, sysInfo, pubSSHKey)
 	return serverKey, err
 }
 
 // registerPeer checks whether setupKey was provided via cmd line and if not then it prompts user to enter a key.
 // Otherwise tries to register with the provided setupKey via command line.
-func registerPeer(ctx context.Context, serverPublicKey wgtypes.Key, client *mgm.GrpcClient, setupKey string, jwtToken string, pubSSHKey []byte) (*mgmProto.LoginResponse, error) {
+func registerPeer(ctx context.Context, serverPublicKey wgtypes.Key, client *mgm.GrpcClient, setupKey string, jwtToken string, pubSSHKey []byte, capabilities []string, services []system.Service, lanNetworks []netip.Prefix) (*mgmProto.LoginResponse, error) {
 	validSetupKey, err := uuid.Parse(setupKey)
 	if err != nil && jwtToken == "" {
 		return nil, status.Errorf(codes.InvalidArgument, "invalid setup-key or no sso information provided, err: %v", err)
@@ -121,6 +211,10 @@ func registerPeer(ctx context.Context, serverPublicKey wgtypes.Key, client *mgm.
 
 	log.Debugf("sending peer registration request to Management Service")
 	info := system.GetInfo(ctx)
+	info.Capabilities = capabilities
+	info.FirewallCapabilities = firewallCapabilities()
+	info.Services = services
+	info.LANNetworks = lanNetworks
 	loginResp, err := client.Register(serverPublicKey, validSetupKey.String(), jwtToken, info, pubSSHKey)
 	if err != nil {
 		log.Errorf("failed registering peer %v,%s", err, validSetupKey.String())
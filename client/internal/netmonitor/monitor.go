@@ -0,0 +1,147 @@
+package netmonitor
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultInterval is the default interval at which the local addresses are checked
+const DefaultInterval = 3 * time.Second
+
+// AddrsFunc returns the addresses of the local interfaces
+type AddrsFunc func() ([]netip.Addr, error)
+
+// ChangeFunc is called with the new local addresses when they changed
+type ChangeFunc func(addrs []netip.Addr)
+
+// Monitor checks the addresses of the local interfaces every interval and reports when they change, e.g. when a laptop
+// switches from Wi-Fi to Ethernet. Desktop platforms have no common notification for it, so the addresses are polled
+type Monitor struct {
+	addrs    AddrsFunc
+	onChange ChangeFunc
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a Monitor of the addresses of the local interfaces, the interfaces with a name starting with one of the
+// ignored prefixes are left out, like the tunnel interface
+func New(ignoredInterfaces []string, onChange ChangeFunc) *Monitor {
+	return newMonitor(func() ([]netip.Addr, error) {
+		return LocalAddresses(ignoredInterfaces)
+	}, onChange, DefaultInterval)
+}
+
+func newMonitor(addrs AddrsFunc, onChange ChangeFunc, interval time.Duration) *Monitor {
+	return &Monitor{
+		addrs:    addrs,
+		onChange: onChange,
+		interval: interval,
+	}
+}
+
+// Start checks the local addresses every interval until Stop is called. The addresses at start are the reference, they
+// are not reported
+func (m *Monitor) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+
+	last, err := m.addrs()
+	if err != nil {
+		log.Debugf("failed reading the local addresses: %v", err)
+	}
+
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			current, err := m.addrs()
+			if err != nil {
+				log.Debugf("failed reading the local addresses: %v", err)
+				continue
+			}
+			if slices.Equal(current, last) {
+				continue
+			}
+
+			log.Debugf("local addresses changed from %v to %v", last, current)
+			last = current
+			m.onChange(current)
+		}
+	}()
+}
+
+// Stop stops checking the local addresses
+func (m *Monitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// LocalAddresses returns the sorted unicast addresses of the local interfaces which are up, without the loopback and
+// link-local addresses and without the interfaces with a name starting with one of the ignored prefixes
+func LocalAddresses(ignoredInterfaces []string) ([]netip.Addr, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []netip.Addr
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || ignored(iface.Name, ignoredInterfaces) {
+			continue
+		}
+
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			log.Debugf("failed reading the addresses of interface %s: %v", iface.Name, err)
+			continue
+		}
+		for _, ifaceAddr := range ifaceAddrs {
+			ipNet, ok := ifaceAddr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipNet.IP)
+			if !ok {
+				continue
+			}
+			addr = addr.Unmap()
+			if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Less(addrs[j])
+	})
+	return addrs, nil
+}
+
+func ignored(name string, ignoredInterfaces []string) bool {
+	for _, prefix := range ignoredInterfaces {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
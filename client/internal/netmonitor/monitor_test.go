@@ -0,0 +1,76 @@
+package netmonitor
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor_ReportsChanges(t *testing.T) {
+	wifi := []netip.Addr{netip.MustParseAddr("192.168.1.10")}
+	ethernet := []netip.Addr{netip.MustParseAddr("10.0.0.10")}
+
+	var mu sync.Mutex
+	current := wifi
+	changes := make(chan []netip.Addr, 10)
+
+	m := newMonitor(
+		func() ([]netip.Addr, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return current, nil
+		},
+		func(addrs []netip.Addr) { changes <- addrs },
+		10*time.Millisecond,
+	)
+	m.Start(context.Background())
+	defer m.Stop()
+
+	select {
+	case addrs := <-changes:
+		t.Fatalf("the addresses at start should not be reported, got %v", addrs)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mu.Lock()
+	current = ethernet
+	mu.Unlock()
+
+	select {
+	case addrs := <-changes:
+		assert.Equal(t, ethernet, addrs)
+	case <-time.After(time.Second):
+		t.Fatal("the change of the addresses should be reported")
+	}
+
+	select {
+	case addrs := <-changes:
+		t.Fatalf("unchanged addresses should not be reported, got %v", addrs)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLocalAddresses(t *testing.T) {
+	addrs, err := LocalAddresses(nil)
+	require.NoError(t, err)
+
+	for i, addr := range addrs {
+		assert.False(t, addr.IsLoopback(), "loopback address %s should be left out", addr)
+		assert.False(t, addr.IsLinkLocalUnicast(), "link-local address %s should be left out", addr)
+		if i > 0 {
+			assert.True(t, addrs[i-1].Less(addr), "addresses should be sorted")
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	assert.True(t, ignored("wt0", []string{"wt", "utun"}))
+	assert.True(t, ignored("utun100", []string{"wt", "utun"}))
+	assert.False(t, ignored("eth0", []string{"wt", "utun"}))
+	assert.False(t, ignored("eth0", nil))
+}
@@ -0,0 +1,198 @@
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Reasons the client requires the attention of the user
+const (
+	// AttentionLoginExpired is raised when the login of the peer expired and the user has to log in again
+	AttentionLoginExpired = "login_expired"
+	// AttentionPostureCheckFailed is raised when the Management service rejected the device attestation of the peer
+	AttentionPostureCheckFailed = "posture_check_failed"
+	// AttentionManagementUnreachable is raised when the Management service is unreachable for longer than the threshold
+	AttentionManagementUnreachable = "management_unreachable"
+)
+
+// DefaultManagementUnreachableThreshold is the time without a connection to the Management service after which the
+// AttentionManagementUnreachable event is raised
+const DefaultManagementUnreachableThreshold = 5 * time.Minute
+
+// AttentionListener is notified when the client requires the attention of the user, e.g. to show an actionable
+// notification on mobile. The reason is one of the Attention constants, the message describes the issue.
+type AttentionListener interface {
+	OnAttentionRequired(reason string, message string)
+	OnAttentionResolved(reason string)
+}
+
+// attentionDispatcher raises each event once until it is resolved and tracks for how long the Management service
+// is unreachable while the client runs
+type attentionDispatcher struct {
+	mu       sync.Mutex
+	listener AttentionListener
+	// active holds the message of the raised events by reason
+	active map[string]string
+
+	unreachableThreshold time.Duration
+	unreachableTimer     *time.Timer
+	clientRunning        bool
+	managementConnected  bool
+	mgmAddress           string
+	// maintenanceUntil delays the unreachable event until the end of an announced maintenance
+	maintenanceUntil time.Time
+}
+
+func newAttentionDispatcher() *attentionDispatcher {
+	return &attentionDispatcher{
+		active:               make(map[string]string),
+		unreachableThreshold: DefaultManagementUnreachableThreshold,
+	}
+}
+
+// setListener sets the listener and notifies it of the events raised before
+func (a *attentionDispatcher) setListener(listener AttentionListener) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.listener = listener
+	for reason, message := range a.active {
+		listener.OnAttentionRequired(reason, message)
+	}
+}
+
+func (a *attentionDispatcher) removeListener() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.listener = nil
+}
+
+func (a *attentionDispatcher) setUnreachableThreshold(threshold time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.unreachableThreshold = threshold
+}
+
+func (a *attentionDispatcher) raise(reason, message string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.raiseLocked(reason, message)
+}
+
+func (a *attentionDispatcher) resolve(reason string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.resolveLocked(reason)
+}
+
+func (a *attentionDispatcher) raiseLocked(reason, message string) {
+	if _, ok := a.active[reason]; ok {
+		return
+	}
+	a.active[reason] = message
+
+	if a.listener != nil {
+		a.listener.OnAttentionRequired(reason, message)
+	}
+}
+
+func (a *attentionDispatcher) resolveLocked(reason string) {
+	if _, ok := a.active[reason]; !ok {
+		return
+	}
+	delete(a.active, reason)
+
+	if a.listener != nil {
+		a.listener.OnAttentionResolved(reason)
+	}
+}
+
+func (a *attentionDispatcher) clientStart(mgmAddress string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clientRunning = true
+	a.mgmAddress = mgmAddress
+	if !a.managementConnected {
+		a.startUnreachableTimer()
+	}
+}
+
+// clientStop stops tracking the Management service, it is not expected to be reachable when the user stopped the client
+func (a *attentionDispatcher) clientStop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.clientRunning = false
+	a.stopUnreachableTimer()
+	a.resolveLocked(AttentionManagementUnreachable)
+}
+
+// managementConnectionChanged tracks the connection to the Management service. A connection resolves the unreachable
+// event and the posture check failure, as the login of the peer was accepted
+func (a *attentionDispatcher) managementConnectionChanged(connected bool, mgmAddress string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.managementConnected = connected
+	a.mgmAddress = mgmAddress
+	if connected {
+		a.stopUnreachableTimer()
+		a.resolveLocked(AttentionManagementUnreachable)
+		a.resolveLocked(AttentionPostureCheckFailed)
+		return
+	}
+
+	if a.clientRunning {
+		a.startUnreachableTimer()
+	}
+}
+
+// maintenanceAnnounced delays the unreachable event until the end of the maintenance of the Management service
+func (a *attentionDispatcher) maintenanceAnnounced(until time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.maintenanceUntil = until
+}
+
+func (a *attentionDispatcher) startUnreachableTimer() {
+	if a.unreachableTimer != nil {
+		return
+	}
+
+	threshold := a.unreachableThreshold
+	if remaining := time.Until(a.maintenanceUntil); remaining > 0 {
+		threshold += remaining
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(threshold, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		// the timer might have been replaced while waiting for the lock
+		if a.unreachableTimer != timer {
+			return
+		}
+		a.unreachableTimer = nil
+		if !a.clientRunning || a.managementConnected {
+			return
+		}
+		a.raiseLocked(AttentionManagementUnreachable,
+			fmt.Sprintf("the Management service %s is unreachable for more than %s", a.mgmAddress, threshold))
+	})
+	a.unreachableTimer = timer
+}
+
+func (a *attentionDispatcher) stopUnreachableTimer() {
+	if a.unreachableTimer == nil {
+		return
+	}
+	a.unreachableTimer.Stop()
+	a.unreachableTimer = nil
+}
@@ -0,0 +1,135 @@
+package peer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAttentionListener struct {
+	mu       sync.Mutex
+	required map[string]string
+	resolved []string
+}
+
+func newMockAttentionListener() *mockAttentionListener {
+	return &mockAttentionListener{required: make(map[string]string)}
+}
+
+func (l *mockAttentionListener) OnAttentionRequired(reason string, message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.required[reason] = message
+}
+
+func (l *mockAttentionListener) OnAttentionResolved(reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.required, reason)
+	l.resolved = append(l.resolved, reason)
+}
+
+func (l *mockAttentionListener) isRequired(reason string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.required[reason]
+	return ok
+}
+
+type countingAttentionListener struct {
+	required int
+	resolved int
+}
+
+func (l *countingAttentionListener) OnAttentionRequired(string, string) { l.required++ }
+func (l *countingAttentionListener) OnAttentionResolved(string)         { l.resolved++ }
+
+func TestAttentionDispatcher_RaisesOnce(t *testing.T) {
+	dispatcher := newAttentionDispatcher()
+	listener := &countingAttentionListener{}
+	dispatcher.setListener(listener)
+
+	dispatcher.raise(AttentionLoginExpired, "expired")
+	dispatcher.raise(AttentionLoginExpired, "expired")
+	assert.Equal(t, 1, listener.required, "an event should be raised once until it is resolved")
+
+	dispatcher.resolve(AttentionLoginExpired)
+	dispatcher.resolve(AttentionLoginExpired)
+	assert.Equal(t, 1, listener.resolved, "an event should be resolved once")
+
+	dispatcher.raise(AttentionLoginExpired, "expired")
+	assert.Equal(t, 2, listener.required, "a resolved event should be raised again")
+}
+
+func TestAttentionDispatcher_ReplaysToNewListener(t *testing.T) {
+	dispatcher := newAttentionDispatcher()
+	dispatcher.raise(AttentionPostureCheckFailed, "attestation failed")
+
+	listener := newMockAttentionListener()
+	dispatcher.setListener(listener)
+	assert.True(t, listener.isRequired(AttentionPostureCheckFailed), "raised events should be replayed to the listener")
+
+	dispatcher.managementConnectionChanged(true, "mgm")
+	assert.False(t, listener.isRequired(AttentionPostureCheckFailed), "a login should resolve the posture check failure")
+}
+
+func TestAttentionDispatcher_ManagementUnreachable(t *testing.T) {
+	dispatcher := newAttentionDispatcher()
+	dispatcher.setUnreachableThreshold(50 * time.Millisecond)
+	listener := newMockAttentionListener()
+	dispatcher.setListener(listener)
+
+	dispatcher.clientStart("mgm")
+	require.Eventually(t, func() bool {
+		return listener.isRequired(AttentionManagementUnreachable)
+	}, time.Second, 10*time.Millisecond, "unreachable Management service should be raised after the threshold")
+
+	dispatcher.managementConnectionChanged(true, "mgm")
+	assert.False(t, listener.isRequired(AttentionManagementUnreachable), "a connection should resolve the event")
+
+	dispatcher.managementConnectionChanged(false, "mgm")
+	dispatcher.clientStop()
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, listener.isRequired(AttentionManagementUnreachable), "a stopped client should not raise the event")
+}
+
+func TestStatus_AttentionLoginExpired(t *testing.T) {
+	recorder := NewRecorder("mgm")
+	listener := newMockAttentionListener()
+	recorder.SetAttentionListener(listener)
+
+	recorder.MarkLoginExpired(true)
+	assert.True(t, listener.isRequired(AttentionLoginExpired))
+
+	recorder.MarkLoginExpired(false)
+	assert.False(t, listener.isRequired(AttentionLoginExpired))
+}
+
+func TestStatus_ManagementMaintenance(t *testing.T) {
+	recorder := NewRecorder("mgm")
+	recorder.SetManagementUnreachableThreshold(20 * time.Millisecond)
+	listener := newMockAttentionListener()
+	recorder.SetAttentionListener(listener)
+	recorder.ClientStart()
+	recorder.MarkManagementConnected()
+
+	recorder.MarkManagementMaintenance(200*time.Millisecond, "upgrading")
+	recorder.MarkManagementDisconnected()
+	state := recorder.GetFullStatus().ManagementState
+	assert.Equal(t, "upgrading", state.MaintenanceMessage)
+	assert.False(t, state.MaintenanceUntil.IsZero())
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, listener.isRequired(AttentionManagementUnreachable), "the event should wait for the end of the maintenance")
+	require.Eventually(t, func() bool {
+		return listener.isRequired(AttentionManagementUnreachable)
+	}, time.Second, 10*time.Millisecond, "unreachable Management service should be raised after the maintenance")
+
+	recorder.MarkManagementConnected()
+	state = recorder.GetFullStatus().ManagementState
+	assert.Empty(t, state.MaintenanceMessage, "a reconnection should end the maintenance")
+	assert.True(t, state.MaintenanceUntil.IsZero())
+}
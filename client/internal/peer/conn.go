@@ -12,14 +12,19 @@ import (
 	"github.com/pion/ice/v3"
 	"github.com/pion/stun/v2"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 
+	"github.com/FlintyLemming/netbird/client/internal/postquantum"
 	"github.com/FlintyLemming/netbird/client/internal/stdnet"
 	"github.com/FlintyLemming/netbird/client/internal/wgproxy"
 	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/iface/bind"
 	signal "github.com/FlintyLemming/netbird/signal/client"
 	sProto "github.com/FlintyLemming/netbird/signal/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 	"github.com/FlintyLemming/netbird/version"
 )
 
@@ -28,8 +33,14 @@ const (
 	iceDisconnectedTimeoutDefault = 6 * time.Second
 
 	defaultWgKeepAlive = 25 * time.Second
+	// priorityWgKeepAlive is the keepalive of the priority peers, it keeps the NAT mappings and the relay allocation
+	// of the connection warm
+	priorityWgKeepAlive = 10 * time.Second
 )
 
+// tracer traces the establishment of the connections to the remote peers
+var tracer = otel.Tracer("github.com/FlintyLemming/netbird/client/internal/peer")
+
 type WgConfig struct {
 	WgListenPort int
 	RemoteKey    string
@@ -67,6 +78,16 @@ type ConnConfig struct {
 
 	// UsesBind indicates whether the WireGuard interface is userspace and uses bind.ICEBind
 	UserspaceBind bool
+
+	// PostQuantum indicates that both peers can derive the WireGuard pre-shared key with a post-quantum key exchange
+	PostQuantum bool
+
+	// Limiter limits the connections establishing at the same time, nil doesn't limit them
+	Limiter *ConnLimiter
+
+	// Priority marks the connection to a critical peer, e.g. a DNS server: it bypasses the Limiter, gathers its
+	// candidates while the offer and the answer are exchanged and is kept warm with an aggressive keepalive
+	Priority bool
 }
 
 // OfferAnswer represents a session establishment offer or answer
@@ -79,6 +100,20 @@ type OfferAnswer struct {
 
 	// Version of NetBird Agent
 	Version string
+
+	// PostQuantumKey is the ephemeral KEM public key of the peer. Empty when the peer doesn't negotiate a post-quantum
+	// pre-shared key
+	PostQuantumKey []byte
+
+	// TraceContext is the trace context of the span the offer or answer was sent in, the receiver continues the trace
+	TraceContext map[string]string
+}
+
+// PostQuantumMessage carries the shared secret encapsulated by the controlling peer
+type PostQuantumMessage struct {
+	Ciphertext []byte
+	// PublicKeyHash identifies the KEM public key the ciphertext was encapsulated to
+	PublicKeyHash []byte
 }
 
 // IceCredentials ICE protocol credentials struct
@@ -91,12 +126,20 @@ type Conn struct {
 	config ConnConfig
 	mu     sync.Mutex
 
+	// stunTurnMu guards the STUN and TURN servers of the config, they are updated while the connection is open
+	stunTurnMu sync.Mutex
+	// stunTurnVersion counts the updates of the STUN and TURN servers, agentStunTurnVersion is the one of the agent
+	stunTurnVersion      uint64
+	agentStunTurnVersion uint64
+
 	// signalCandidate is a handler function to signal remote peer about local connection candidate
 	signalCandidate func(candidate ice.Candidate) error
 	// signalOffer is a handler function to signal remote peer our connection offer (credentials)
 	signalOffer       func(OfferAnswer) error
 	signalAnswer      func(OfferAnswer) error
 	sendSignalMessage func(message *sProto.Message) error
+	// signalRoam is a handler function to signal remote peer the candidates of our new local addresses
+	signalRoam func(candidates []ice.Candidate) error
 
 	// remoteOffersCh is a channel used to wait for remote credentials to proceed with the connection
 	remoteOffersCh chan OfferAnswer
@@ -117,8 +160,28 @@ type Conn struct {
 	remoteModeCh chan ModeMessage
 	meta         meta
 
+	// pqKeyPair is the ephemeral KEM key pair of the current connection attempt
+	pqKeyPair *postquantum.KeyPair
+	// remotePostQuantumCh is a channel used to wait for the encapsulated secret from the controlling peer
+	remotePostQuantumCh chan PostQuantumMessage
+
 	adapter       iface.TunAdapter
 	iFaceDiscover stdnet.ExternalIFaceDiscover
+
+	// lastActive is the time the connection was last up, it prioritizes the connection in the Limiter
+	lastActive time.Time
+
+	// resumeHint is set after a direct connection, it allows resuming the connection without ICE
+	resumeHint *resumeHint
+	// roamed is the last time either peer roamed, a failing ICE connection is kept on the WireGuard session shortly after
+	roamed time.Time
+
+	// candidatesMu guards the local candidates held back by a priority connection until the remote peer confirmed it
+	candidatesMu   sync.Mutex
+	holdCandidates bool
+	heldCandidates []ice.Candidate
+	// prewarmedAgent is the agent which started gathering its candidates before the offer and the answer were exchanged
+	prewarmedAgent *ice.Agent
 }
 
 // meta holds meta information about a connection
@@ -142,9 +205,14 @@ func (conn *Conn) WgConfig() WgConfig {
 	return conn.config.WgConfig
 }
 
-// UpdateStunTurn update the turn and stun addresses
+// UpdateStunTurn update the turn and stun addresses. An agent which didn't start gathering candidates yet is switched
+// to them, the established connections keep the servers they were established with
 func (conn *Conn) UpdateStunTurn(turnStun []*stun.URI) {
+	conn.stunTurnMu.Lock()
+	defer conn.stunTurnMu.Unlock()
+
 	conn.config.StunTurn = turnStun
+	conn.stunTurnVersion++
 }
 
 // NewConn creates a new not opened Conn to the remote peer.
@@ -162,6 +230,8 @@ func NewConn(config ConnConfig, statusRecorder *Status, wgProxyFactory *wgproxy.
 		wgProxyFactory: wgProxyFactory,
 		adapter:        adapter,
 		iFaceDiscover:  iFaceDiscover,
+
+		remotePostQuantumCh: make(chan PostQuantumMessage, 1),
 	}, nil
 }
 
@@ -169,6 +239,16 @@ func (conn *Conn) reCreateAgent() error {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 
+	return conn.newAgent("", "")
+}
+
+// newAgent creates the ICE agent with the current STUN and TURN servers, empty local credentials are generated
+func (conn *Conn) newAgent(localUfrag, localPwd string) error {
+	conn.stunTurnMu.Lock()
+	stunTurn := conn.config.StunTurn
+	conn.agentStunTurnVersion = conn.stunTurnVersion
+	conn.stunTurnMu.Unlock()
+
 	failedTimeout := 6 * time.Second
 
 	var err error
@@ -183,7 +263,7 @@ func (conn *Conn) reCreateAgent() error {
 	agentConfig := &ice.AgentConfig{
 		MulticastDNSMode:    ice.MulticastDNSModeDisabled,
 		NetworkTypes:        []ice.NetworkType{ice.NetworkTypeUDP4, ice.NetworkTypeUDP6},
-		Urls:                conn.config.StunTurn,
+		Urls:                stunTurn,
 		CandidateTypes:      conn.candidateTypes(),
 		FailedTimeout:       &failedTimeout,
 		InterfaceFilter:     stdnet.InterfaceFilter(conn.config.InterfaceBlackList),
@@ -193,6 +273,8 @@ func (conn *Conn) reCreateAgent() error {
 		Net:                 transportNet,
 		DisconnectedTimeout: &iceDisconnectedTimeout,
 		KeepaliveInterval:   &iceKeepAlive,
+		LocalUfrag:          localUfrag,
+		LocalPwd:            localPwd,
 	}
 
 	if conn.config.DisableIPv6Discovery {
@@ -237,7 +319,7 @@ func (conn *Conn) candidateTypes() []ice.CandidateType {
 // Open opens connection to the remote peer starting ICE candidate gathering process.
 // Blocks until connection has been closed or connection timeout.
 // ConnStatus will be set accordingly
-func (conn *Conn) Open() error {
+func (conn *Conn) Open() (err error) {
 	log.Debugf("trying to connect to peer %s", conn.config.Key)
 
 	peerState := State{
@@ -246,7 +328,7 @@ func (conn *Conn) Open() error {
 		ConnStatusUpdate: time.Now(),
 		ConnStatus:       conn.status,
 	}
-	err := conn.statusRecorder.UpdatePeerState(peerState)
+	err = conn.statusRecorder.UpdatePeerState(peerState)
 	if err != nil {
 		log.Warnf("error while updating the state of peer %s,err: %v", conn.config.Key, err)
 	}
@@ -259,16 +341,44 @@ func (conn *Conn) Open() error {
 		}
 	}()
 
+	resumed, err := conn.resume()
+	if err != nil {
+		return err
+	}
+	if resumed {
+		return conn.waitResumed()
+	}
+
+	// the span traces the signaling exchange and the ICE negotiation until the connection is established, it ends
+	// with the error when failing to connect
+	ctx, span := tracer.Start(context.Background(), "connect", trace.WithAttributes(attribute.String("peer.key", conn.config.Key)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	err = conn.reCreateAgent()
 	if err != nil {
 		return err
 	}
 
-	err = conn.sendOffer()
+	if conn.config.Priority {
+		err = conn.prewarm()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = conn.reCreatePostQuantumKeyPair()
 	if err != nil {
 		return err
 	}
 
+	err = conn.sendOffer(ctx)
+	if err != nil {
+		return err
+	}
+	span.AddEvent("offer sent")
+
 	log.Debugf("connection offer sent to peer %s, waiting for the confirmation", conn.config.Key)
 
 	// Only continue once we got a connection confirmation from the remote peer.
@@ -277,12 +387,19 @@ func (conn *Conn) Open() error {
 	var remoteOfferAnswer OfferAnswer
 	select {
 	case remoteOfferAnswer = <-conn.remoteOffersCh:
+		span.AddEvent("remote offer received")
 		// received confirmation from the remote peer -> ready to proceed
-		err = conn.sendAnswer()
+		// the answer continues the trace of the remote peer, linked to the local one
+		answerCtx, answerSpan := tracer.Start(tracing.Extract(ctx, remoteOfferAnswer.TraceContext), "answer",
+			trace.WithAttributes(attribute.String("peer.key", conn.config.Key)),
+			trace.WithLinks(trace.Link{SpanContext: span.SpanContext()}))
+		err = conn.sendAnswer(answerCtx)
+		tracing.End(answerSpan, err)
 		if err != nil {
 			return err
 		}
 	case remoteOfferAnswer = <-conn.remoteAnswerCh:
+		span.AddEvent("remote answer received")
 	case <-time.After(conn.config.Timeout):
 		return NewConnectionTimeoutError(conn.config.Key, conn.config.Timeout)
 	case <-conn.closeCh:
@@ -293,6 +410,26 @@ func (conn *Conn) Open() error {
 	log.Debugf("received connection confirmation from peer %s running version %s and with remote WireGuard listen port %d",
 		conn.config.Key, remoteOfferAnswer.Version, remoteOfferAnswer.WgListenPort)
 
+	isControlling := conn.config.LocalKey > conn.config.Key
+
+	preSharedKey, err := conn.negotiatePreSharedKey(remoteOfferAnswer, isControlling)
+	if err != nil {
+		return err
+	}
+
+	// limit the connections gathering candidates at the same time, e.g. when all peers reconnect after a network change
+	release, err := conn.acquireSlot()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// the TURN credentials might have been refreshed while waiting for the remote peer
+	err = conn.swapAgentStunTurn()
+	if err != nil {
+		return err
+	}
+
 	// at this point we received offer/answer and we are ready to gather candidates
 	conn.mu.Lock()
 	conn.status = StatusConnecting
@@ -310,7 +447,8 @@ func (conn *Conn) Open() error {
 		log.Warnf("error while updating the state of peer %s,err: %v", conn.config.Key, err)
 	}
 
-	err = conn.agent.GatherCandidates()
+	span.AddEvent("gathering candidates")
+	err = conn.signalCandidates()
 	if err != nil {
 		return err
 	}
@@ -318,7 +456,6 @@ func (conn *Conn) Open() error {
 	// will block until connection succeeded
 	// but it won't release if ICE Agent went into Disconnected or Failed state,
 	// so we have to cancel it with the provided context once agent detected a broken connection
-	isControlling := conn.config.LocalKey > conn.config.Key
 	var remoteConn *ice.Conn
 	if isControlling {
 		remoteConn, err = conn.agent.Dial(conn.ctx, remoteOfferAnswer.IceCredentials.UFrag, remoteOfferAnswer.IceCredentials.Pwd)
@@ -335,12 +472,21 @@ func (conn *Conn) Open() error {
 		remoteWgPort = remoteOfferAnswer.WgListenPort
 	}
 	// the ice connection has been established successfully so we are ready to start the proxy
-	remoteAddr, err := conn.configureConnection(remoteConn, remoteWgPort)
+	remoteAddr, err := conn.configureConnection(remoteConn, remoteWgPort, preSharedKey)
 	if err != nil {
 		return err
 	}
 
 	log.Infof("connected to peer %s, endpoint address: %s", conn.config.Key, remoteAddr.String())
+	if pair, err := conn.agent.GetSelectedCandidatePair(); err == nil {
+		span.SetAttributes(
+			attribute.String("ice.local_candidate_type", pair.Local.Type().String()),
+			attribute.String("ice.remote_candidate_type", pair.Remote.Type().String()),
+		)
+	}
+	span.End()
+	release()
+	defer conn.updateLastActive()
 
 	// wait until connection disconnected or has been closed externally (upper layer, e.g. engine)
 	select {
@@ -348,17 +494,63 @@ func (conn *Conn) Open() error {
 		// closed externally
 		return NewConnectionClosedError(conn.config.Key)
 	case <-conn.ctx.Done():
+		if conn.roamedRecently(time.Now()) {
+			return conn.waitRoamed()
+		}
 		// disconnected from the remote peer
 		return NewConnectionDisconnectedError(conn.config.Key)
 	}
 }
 
+// acquireSlot waits for the Limiter to admit the connection, giving up on timeout or when the connection is closed.
+// The priority connections are admitted right away
+func (conn *Conn) acquireSlot() (func(), error) {
+	if conn.config.Limiter == nil || conn.config.Priority {
+		return func() {}, nil
+	}
+
+	conn.mu.Lock()
+	lastActive := conn.lastActive
+	conn.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), conn.config.Timeout)
+	closed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-conn.closeCh:
+			cancel()
+			closed <- true
+		case <-ctx.Done():
+			closed <- false
+		}
+	}()
+
+	release, err := conn.config.Limiter.Acquire(ctx, lastActive)
+	cancel()
+	if <-closed {
+		if err == nil {
+			release()
+		}
+		return nil, NewConnectionClosedError(conn.config.Key)
+	}
+	if err != nil {
+		return nil, NewConnectionTimeoutError(conn.config.Key, conn.config.Timeout)
+	}
+	return release, nil
+}
+
+func (conn *Conn) updateLastActive() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.lastActive = time.Now()
+}
+
 func isRelayCandidate(candidate ice.Candidate) bool {
 	return candidate.Type() == ice.CandidateTypeRelay
 }
 
 // configureConnection starts proxying traffic from/to local Wireguard and sets connection status to StatusConnected
-func (conn *Conn) configureConnection(remoteConn net.Conn, remoteWgPort int) (net.Addr, error) {
+func (conn *Conn) configureConnection(remoteConn net.Conn, remoteWgPort int, preSharedKey *wgtypes.Key) (net.Addr, error) {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 
@@ -383,7 +575,7 @@ func (conn *Conn) configureConnection(remoteConn net.Conn, remoteWgPort int) (ne
 
 	endpointUdpAddr, _ := net.ResolveUDPAddr(endpoint.Network(), endpoint.String())
 
-	err = conn.config.WgConfig.WgInterface.UpdatePeer(conn.config.WgConfig.RemoteKey, conn.config.WgConfig.AllowedIps, defaultWgKeepAlive, endpointUdpAddr, conn.config.WgConfig.PreSharedKey)
+	err = conn.config.WgConfig.WgInterface.UpdatePeer(conn.config.WgConfig.RemoteKey, conn.config.WgConfig.AllowedIps, conn.wgKeepAlive(), endpointUdpAddr, preSharedKey)
 	if err != nil {
 		if conn.wgProxy != nil {
 			_ = conn.wgProxy.CloseConn()
@@ -392,6 +584,7 @@ func (conn *Conn) configureConnection(remoteConn net.Conn, remoteWgPort int) (ne
 	}
 
 	conn.status = StatusConnected
+	conn.lastActive = time.Now()
 
 	peerState := State{
 		PubKey:                 conn.config.Key,
@@ -405,6 +598,12 @@ func (conn *Conn) configureConnection(remoteConn net.Conn, remoteWgPort int) (ne
 		peerState.Relayed = true
 	}
 
+	if peerState.Relayed {
+		conn.resumeHint = nil
+	} else {
+		conn.setResumeHint(endpointUdpAddr, preSharedKey, peerState.LocalIceCandidateType, peerState.RemoteIceCandidateType)
+	}
+
 	err = conn.statusRecorder.UpdatePeerState(peerState)
 	if err != nil {
 		log.Warnf("unable to save peer's state, got error: %v", err)
@@ -452,14 +651,30 @@ func (conn *Conn) cleanup() error {
 		conn.wgProxy = nil
 	}
 
-	// todo: is it problem if we try to remove a peer what is never existed?
-	err3 = conn.config.WgConfig.WgInterface.RemovePeer(conn.config.WgConfig.RemoteKey)
+	if conn.resumeHint != nil && conn.status == StatusConnected {
+		conn.resumeHint.disconnected = time.Now()
+	}
+
+	// the WireGuard peer of a direct connection is kept, so that both peers can resume the connection without ICE
+	if conn.resumeHint.valid(time.Now()) {
+		log.Debugf("keeping WireGuard peer %s to resume the connection", conn.config.Key)
+	} else {
+		conn.resumeHint = nil
+		// todo: is it problem if we try to remove a peer what is never existed?
+		err3 = conn.config.WgConfig.WgInterface.RemovePeer(conn.config.WgConfig.RemoteKey)
+	}
 
 	if conn.notifyDisconnected != nil {
 		conn.notifyDisconnected()
 		conn.notifyDisconnected = nil
 	}
 
+	conn.candidatesMu.Lock()
+	conn.holdCandidates = false
+	conn.heldCandidates = nil
+	conn.prewarmedAgent = nil
+	conn.candidatesMu.Unlock()
+
 	conn.status = StatusDisconnected
 
 	peerState := State{
@@ -508,6 +723,9 @@ func (conn *Conn) SetSendSignalMessage(handler func(message *sProto.Message) err
 // and then signals them to the remote peer
 func (conn *Conn) onICECandidate(candidate ice.Candidate) {
 	if candidate != nil {
+		if conn.holdCandidate(candidate) {
+			return
+		}
 		// TODO: reported port is incorrect for CandidateTypeHost, makes understanding ICE use via logs confusing as port is ignored
 		log.Debugf("discovered local candidate %s", candidate.String())
 		go func() {
@@ -532,7 +750,7 @@ func (conn *Conn) onICEConnectionStateChange(state ice.ConnectionState) {
 	}
 }
 
-func (conn *Conn) sendAnswer() error {
+func (conn *Conn) sendAnswer(ctx context.Context) error {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 
@@ -546,6 +764,8 @@ func (conn *Conn) sendAnswer() error {
 		IceCredentials: IceCredentials{localUFrag, localPwd},
 		WgListenPort:   conn.config.LocalWgPort,
 		Version:        version.NetbirdVersion(),
+		PostQuantumKey: conn.postQuantumKey(),
+		TraceContext:   tracing.Inject(ctx),
 	})
 	if err != nil {
 		return err
@@ -555,7 +775,7 @@ func (conn *Conn) sendAnswer() error {
 }
 
 // sendOffer prepares local user credentials and signals them to the remote peer
-func (conn *Conn) sendOffer() error {
+func (conn *Conn) sendOffer(ctx context.Context) error {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
 
@@ -567,6 +787,8 @@ func (conn *Conn) sendOffer() error {
 		IceCredentials: IceCredentials{localUFrag, localPwd},
 		WgListenPort:   conn.config.LocalWgPort,
 		Version:        version.NetbirdVersion(),
+		PostQuantumKey: conn.postQuantumKey(),
+		TraceContext:   tracing.Inject(ctx),
 	})
 	if err != nil {
 		return err
@@ -578,6 +800,10 @@ func (conn *Conn) sendOffer() error {
 func (conn *Conn) Close() error {
 	conn.mu.Lock()
 	defer conn.mu.Unlock()
+
+	// the connection won't be resumed, the WireGuard peer is removed on cleanup
+	conn.resumeHint = nil
+
 	select {
 	case conn.closeCh <- struct{}{}:
 		return nil
@@ -655,6 +881,34 @@ func (conn *Conn) OnRemoteCandidate(candidate ice.Candidate) {
 	}()
 }
 
+// OnRemotePostQuantum handles the encapsulated secret sent by the controlling peer
+// doesn't block, discards the message if a previous one wasn't consumed yet
+func (conn *Conn) OnRemotePostQuantum(msg PostQuantumMessage) bool {
+	select {
+	case conn.remotePostQuantumCh <- msg:
+		return true
+	default:
+		log.Debugf("OnRemotePostQuantum skipping message from peer %s on status %s because is not ready", conn.config.Key, conn.status.String())
+		return false
+	}
+}
+
+// OnRemoteGoingOffline handles the notification of the remote peer shutting down. It closes the current connection
+// right away, so the routes through the peer are dropped, and waits for a new offer from the peer
+func (conn *Conn) OnRemoteGoingOffline() {
+	log.Debugf("OnRemoteGoingOffline from peer %s on status %s", conn.config.Key, conn.status.String())
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	// the remote peer forgets the connection, it can't be resumed
+	conn.resumeHint = nil
+
+	if conn.notifyDisconnected != nil {
+		conn.notifyDisconnected()
+	}
+}
+
 func (conn *Conn) GetKey() string {
 	return conn.config.Key
 }
@@ -1,6 +1,7 @@
 package peer
 
 import (
+	"context"
 	"sync"
 	"testing"
 	"time"
@@ -182,3 +183,26 @@ func TestConn_Close(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestConn_OnRemoteGoingOffline(t *testing.T) {
+	wgProxyFactory := wgproxy.NewFactory(connConf.LocalWgPort)
+	defer func() {
+		_ = wgProxyFactory.Free()
+	}()
+	conn, err := NewConn(connConf, NewRecorder("https://mgm"), wgProxyFactory, nil, nil)
+	if err != nil {
+		return
+	}
+
+	// not connecting yet, nothing to close
+	conn.OnRemoteGoingOffline()
+
+	conn.ctx, conn.notifyDisconnected = context.WithCancel(context.Background())
+	conn.OnRemoteGoingOffline()
+
+	select {
+	case <-conn.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("connection should be disconnected when the remote peer goes offline")
+	}
+}
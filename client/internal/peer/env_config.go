@@ -2,6 +2,7 @@ package peer
 
 import (
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +14,14 @@ const (
 	envICEKeepAliveIntervalSec   = "NB_ICE_KEEP_ALIVE_INTERVAL_SEC"
 	envICEDisconnectedTimeoutSec = "NB_ICE_DISCONNECTED_TIMEOUT_SEC"
 	envICEForceRelayConn         = "NB_ICE_FORCE_RELAY_CONN"
+	envWGIdleTimeoutSec          = "NB_WG_IDLE_TIMEOUT_SEC"
+	envWGIdleKeepAliveSec        = "NB_WG_IDLE_KEEP_ALIVE_SEC"
+	envPeerWatchdogDisabled      = "NB_PEER_WATCHDOG_DISABLED"
+
+	// wgIdleTimeoutMobileDefault is the idle timeout on mobile devices, the keepalive isn't adapted on the others
+	// unless NB_WG_IDLE_TIMEOUT_SEC is set
+	wgIdleTimeoutMobileDefault = 5 * time.Minute
+	wgIdleKeepAliveDefault     = 2 * time.Minute
 )
 
 func iceKeepAlive() time.Duration {
@@ -51,3 +60,44 @@ func hasICEForceRelayConn() bool {
 	disconnectedTimeoutEnv := os.Getenv(envICEForceRelayConn)
 	return strings.ToLower(disconnectedTimeoutEnv) == "true"
 }
+
+func isWatchdogDisabled() bool {
+	return strings.ToLower(os.Getenv(envPeerWatchdogDisabled)) == "true"
+}
+
+func wgIdleTimeout() time.Duration {
+	idleTimeoutDefault := time.Duration(0)
+	if runtime.GOOS == "android" || runtime.GOOS == "ios" {
+		idleTimeoutDefault = wgIdleTimeoutMobileDefault
+	}
+
+	idleTimeoutEnv := os.Getenv(envWGIdleTimeoutSec)
+	if idleTimeoutEnv == "" {
+		return idleTimeoutDefault
+	}
+
+	log.Debugf("setting WireGuard idle timeout to %s seconds", idleTimeoutEnv)
+	idleTimeoutSec, err := strconv.Atoi(idleTimeoutEnv)
+	if err != nil {
+		log.Warnf("invalid value %s set for %s, using default %v", idleTimeoutEnv, envWGIdleTimeoutSec, idleTimeoutDefault)
+		return idleTimeoutDefault
+	}
+
+	return time.Duration(idleTimeoutSec) * time.Second
+}
+
+func wgIdleKeepAlive() time.Duration {
+	idleKeepAliveEnv := os.Getenv(envWGIdleKeepAliveSec)
+	if idleKeepAliveEnv == "" {
+		return wgIdleKeepAliveDefault
+	}
+
+	log.Debugf("setting WireGuard idle keepalive to %s seconds", idleKeepAliveEnv)
+	idleKeepAliveSec, err := strconv.Atoi(idleKeepAliveEnv)
+	if err != nil || idleKeepAliveSec < 0 {
+		log.Warnf("invalid value %s set for %s, using default %v", idleKeepAliveEnv, envWGIdleKeepAliveSec, wgIdleKeepAliveDefault)
+		return wgIdleKeepAliveDefault
+	}
+
+	return time.Duration(idleKeepAliveSec) * time.Second
+}
@@ -0,0 +1,170 @@
+package peer
+
+import (
+	"context"
+	"time"
+
+	"github.com/pion/ice/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+const (
+	// keepAliveCheckInterval is how often the traffic of the peers is checked
+	keepAliveCheckInterval = 30 * time.Second
+	// idleTrafficThreshold is the number of bytes exchanged with a peer between two checks below which the peer is
+	// considered without data, it leaves room for the keepalives and the handshakes sent by WireGuard on its own
+	idleTrafficThreshold = 512
+
+	// keepAliveUnknown marks a peer whose keepalive might have been changed by a reconnection
+	keepAliveUnknown time.Duration = -1
+)
+
+// KeepAliveConfigurer reads the WireGuard statistics of the peers and changes their persistent keepalive
+type KeepAliveConfigurer interface {
+	GetAllStats() (map[string]iface.WGStats, error)
+	SetPeerKeepAlive(peerKey string, keepAlive time.Duration) error
+}
+
+// KeepAliveScheduler adapts the persistent keepalive of the connected peers to their traffic. The keepalive of a peer
+// without data for the idle timeout is raised to the idle keepalive, or disabled when no NAT is detected on the
+// connection, and restored as soon as data is exchanged again. It saves the battery and the radio usage of mobile
+// devices and cellular routers.
+type KeepAliveScheduler struct {
+	wgInterface    KeepAliveConfigurer
+	statusRecorder *Status
+	idleTimeout    time.Duration
+	idleKeepAlive  time.Duration
+
+	peers map[string]*keepAlivePeer
+}
+
+type keepAlivePeer struct {
+	rxBytes      int64
+	txBytes      int64
+	lastActivity time.Time
+	connUpdate   time.Time
+	keepAlive    time.Duration
+}
+
+// NewKeepAliveScheduler creates a scheduler configured from the environment, see NB_WG_IDLE_TIMEOUT_SEC and
+// NB_WG_IDLE_KEEP_ALIVE_SEC
+func NewKeepAliveScheduler(wgInterface KeepAliveConfigurer, statusRecorder *Status) *KeepAliveScheduler {
+	return newKeepAliveScheduler(wgInterface, statusRecorder, wgIdleTimeout(), wgIdleKeepAlive())
+}
+
+func newKeepAliveScheduler(wgInterface KeepAliveConfigurer, statusRecorder *Status, idleTimeout, idleKeepAlive time.Duration) *KeepAliveScheduler {
+	return &KeepAliveScheduler{
+		wgInterface:    wgInterface,
+		statusRecorder: statusRecorder,
+		idleTimeout:    idleTimeout,
+		idleKeepAlive:  idleKeepAlive,
+		peers:          make(map[string]*keepAlivePeer),
+	}
+}
+
+// Run checks the traffic of the peers periodically until the context is done, it returns right away when the idle
+// timeout is disabled
+func (s *KeepAliveScheduler) Run(ctx context.Context) {
+	if s.idleTimeout <= 0 {
+		return
+	}
+
+	log.Infof("raising the keepalive interval of the peers without data for %s", s.idleTimeout)
+	ticker := time.NewTicker(keepAliveCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check(time.Now())
+		}
+	}
+}
+
+// check updates the activity of the peers from their WireGuard statistics and applies the keepalive they need
+func (s *KeepAliveScheduler) check(now time.Time) {
+	allStats, err := s.wgInterface.GetAllStats()
+	if err != nil {
+		log.Debugf("failed to read the WireGuard statistics of the peers: %v", err)
+		return
+	}
+
+	for peerKey := range s.peers {
+		if _, ok := allStats[peerKey]; !ok {
+			delete(s.peers, peerKey)
+		}
+	}
+
+	for peerKey, stats := range allStats {
+		state, err := s.statusRecorder.GetPeer(peerKey)
+		if err != nil || state.ConnStatus != StatusConnected {
+			delete(s.peers, peerKey)
+			continue
+		}
+
+		p, ok := s.peers[peerKey]
+		if !ok {
+			// the connection configured the peer with its active keepalive
+			s.peers[peerKey] = &keepAlivePeer{
+				rxBytes:      stats.RxBytes,
+				txBytes:      stats.TxBytes,
+				lastActivity: now,
+				connUpdate:   state.ConnStatusUpdate,
+				keepAlive:    activeKeepAliveOf(state),
+			}
+			continue
+		}
+
+		// the peer was reconfigured or recreated since the last check, with its counters starting from zero
+		if !p.connUpdate.Equal(state.ConnStatusUpdate) || stats.RxBytes < p.rxBytes || stats.TxBytes < p.txBytes {
+			p.rxBytes, p.txBytes = 0, 0
+			p.lastActivity = now
+			p.connUpdate = state.ConnStatusUpdate
+			if p.keepAlive != activeKeepAliveOf(state) {
+				p.keepAlive = keepAliveUnknown
+			}
+		}
+
+		if stats.RxBytes-p.rxBytes+stats.TxBytes-p.txBytes > idleTrafficThreshold {
+			p.lastActivity = now
+		}
+		p.rxBytes, p.txBytes = stats.RxBytes, stats.TxBytes
+
+		// the priority peers are kept warm even without data
+		keepAlive := activeKeepAliveOf(state)
+		if now.Sub(p.lastActivity) >= s.idleTimeout && !state.Priority {
+			keepAlive = s.idleKeepAliveOf(state)
+		}
+		if keepAlive == p.keepAlive {
+			continue
+		}
+
+		if err := s.wgInterface.SetPeerKeepAlive(peerKey, keepAlive); err != nil {
+			log.Debugf("failed to set the keepalive of peer %s: %v", peerKey, err)
+			continue
+		}
+		log.Debugf("set the keepalive of peer %s to %s", peerKey, keepAlive)
+		p.keepAlive = keepAlive
+	}
+}
+
+// idleKeepAliveOf returns the keepalive of an idle peer: disabled on direct connections between host candidates,
+// without NAT mappings to keep open, raised otherwise
+func (s *KeepAliveScheduler) idleKeepAliveOf(state State) time.Duration {
+	if state.Direct && state.LocalIceCandidateType == ice.CandidateTypeHost.String() &&
+		state.RemoteIceCandidateType == ice.CandidateTypeHost.String() {
+		return 0
+	}
+	return s.idleKeepAlive
+}
+
+// activeKeepAliveOf returns the keepalive a connection configures its peer with
+func activeKeepAliveOf(state State) time.Duration {
+	if state.Priority {
+		return priorityWgKeepAlive
+	}
+	return defaultWgKeepAlive
+}
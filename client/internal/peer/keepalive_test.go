@@ -0,0 +1,159 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+type fakeKeepAliveConfigurer struct {
+	stats      map[string]iface.WGStats
+	keepAlives map[string]time.Duration
+	sets       int
+}
+
+func (f *fakeKeepAliveConfigurer) GetAllStats() (map[string]iface.WGStats, error) {
+	stats := make(map[string]iface.WGStats, len(f.stats))
+	for peerKey, s := range f.stats {
+		stats[peerKey] = s
+	}
+	return stats, nil
+}
+
+func (f *fakeKeepAliveConfigurer) SetPeerKeepAlive(peerKey string, keepAlive time.Duration) error {
+	f.keepAlives[peerKey] = keepAlive
+	f.sets++
+	return nil
+}
+
+func (f *fakeKeepAliveConfigurer) transfer(peerKey string, bytes int64) {
+	s := f.stats[peerKey]
+	s.RxBytes += bytes
+	s.TxBytes += bytes
+	f.stats[peerKey] = s
+}
+
+func connectPeer(t *testing.T, status *Status, peerKey, localCandidate, remoteCandidate string) {
+	t.Helper()
+
+	if _, err := status.GetPeer(peerKey); err != nil {
+		require.NoError(t, status.AddPeer(peerKey, ""))
+	}
+	require.NoError(t, status.UpdatePeerState(State{
+		PubKey:                 peerKey,
+		ConnStatus:             StatusConnected,
+		ConnStatusUpdate:       time.Now(),
+		Direct:                 true,
+		LocalIceCandidateType:  localCandidate,
+		RemoteIceCandidateType: remoteCandidate,
+	}))
+}
+
+func TestKeepAliveScheduler_IdlePeers(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "natted", "srflx", "host")
+	connectPeer(t, status, "lan", "host", "host")
+
+	wg := &fakeKeepAliveConfigurer{
+		stats:      map[string]iface.WGStats{"natted": {}, "lan": {}},
+		keepAlives: make(map[string]time.Duration),
+	}
+	scheduler := newKeepAliveScheduler(wg, status, 5*time.Minute, 2*time.Minute)
+
+	now := time.Now()
+	scheduler.check(now)
+	assert.Empty(t, wg.keepAlives, "the keepalive of new peers shouldn't change")
+
+	// keepalives and handshakes don't count as data
+	wg.transfer("natted", 100)
+	wg.transfer("lan", 100)
+	now = now.Add(4 * time.Minute)
+	scheduler.check(now)
+	assert.Empty(t, wg.keepAlives, "the peers aren't idle yet")
+
+	now = now.Add(time.Minute)
+	scheduler.check(now)
+	assert.Equal(t, 2*time.Minute, wg.keepAlives["natted"], "the keepalive behind NAT should be raised")
+	assert.Equal(t, time.Duration(0), wg.keepAlives["lan"], "the keepalive without NAT should be disabled")
+
+	sets := wg.sets
+	now = now.Add(time.Minute)
+	scheduler.check(now)
+	assert.Equal(t, sets, wg.sets, "the keepalive shouldn't be set again")
+
+	wg.transfer("natted", 10000)
+	now = now.Add(30 * time.Second)
+	scheduler.check(now)
+	assert.Equal(t, defaultWgKeepAlive, wg.keepAlives["natted"], "the keepalive should be restored with data")
+	assert.Equal(t, time.Duration(0), wg.keepAlives["lan"])
+}
+
+func TestKeepAliveScheduler_PriorityPeers(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "priority", "srflx", "host")
+	require.NoError(t, status.UpdatePeerPriority("priority", true))
+
+	wg := &fakeKeepAliveConfigurer{
+		stats:      map[string]iface.WGStats{"priority": {}},
+		keepAlives: make(map[string]time.Duration),
+	}
+	scheduler := newKeepAliveScheduler(wg, status, 5*time.Minute, 2*time.Minute)
+
+	now := time.Now()
+	scheduler.check(now)
+	scheduler.check(now.Add(time.Hour))
+	assert.Empty(t, wg.keepAlives, "the keepalive of a priority peer should stay aggressive without data")
+}
+
+func TestKeepAliveScheduler_Reconnection(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "peer", "relay", "srflx")
+
+	wg := &fakeKeepAliveConfigurer{
+		stats:      map[string]iface.WGStats{"peer": {RxBytes: 5000, TxBytes: 5000}},
+		keepAlives: make(map[string]time.Duration),
+	}
+	scheduler := newKeepAliveScheduler(wg, status, 5*time.Minute, 2*time.Minute)
+
+	now := time.Now()
+	scheduler.check(now)
+	now = now.Add(5 * time.Minute)
+	scheduler.check(now)
+	require.Equal(t, 2*time.Minute, wg.keepAlives["peer"])
+
+	// the reconnection recreates the WireGuard peer with the default keepalive and new counters
+	require.NoError(t, status.UpdatePeerState(State{PubKey: "peer", ConnStatus: StatusDisconnected, ConnStatusUpdate: time.Now()}))
+	scheduler.check(now)
+	connectPeer(t, status, "peer", "relay", "srflx")
+	wg.stats["peer"] = iface.WGStats{}
+	wg.keepAlives["peer"] = defaultWgKeepAlive
+
+	now = now.Add(30 * time.Second)
+	scheduler.check(now)
+	assert.Equal(t, defaultWgKeepAlive, wg.keepAlives["peer"], "the reconnected peer isn't idle yet")
+
+	now = now.Add(5 * time.Minute)
+	scheduler.check(now)
+	assert.Equal(t, 2*time.Minute, wg.keepAlives["peer"], "the reconnected peer should be idle again")
+}
+
+func TestKeepAliveScheduler_DisconnectedPeers(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	require.NoError(t, status.AddPeer("peer", ""))
+
+	wg := &fakeKeepAliveConfigurer{
+		stats:      map[string]iface.WGStats{"peer": {}, "unknown": {}},
+		keepAlives: make(map[string]time.Duration),
+	}
+	scheduler := newKeepAliveScheduler(wg, status, time.Minute, 2*time.Minute)
+
+	now := time.Now()
+	scheduler.check(now)
+	scheduler.check(now.Add(time.Hour))
+	assert.Empty(t, wg.keepAlives, "the keepalive of peers not connected shouldn't change")
+	assert.Empty(t, scheduler.peers)
+}
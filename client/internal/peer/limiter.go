@@ -0,0 +1,127 @@
+package peer
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMaxConcurrentConnections is the default number of peer connections establishing at the same time
+const DefaultMaxConcurrentConnections = 32
+
+// ConnLimiter limits the number of peer connections gathering candidates and running the connectivity checks at the
+// same time, e.g. when all peers reconnect after a network change. Waiting connections are admitted in order of
+// priority, the peers active most recently first
+type ConnLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiting connWaitQueue
+	// seq admits the waiting connections of the same priority in arrival order
+	seq uint64
+}
+
+// NewConnLimiter creates a ConnLimiter admitting up to limit connections at the same time
+func NewConnLimiter(limit int) *ConnLimiter {
+	return &ConnLimiter{limit: limit}
+}
+
+// Acquire waits until the connection can be established or the context is done. lastActive is the time the
+// connection to the peer was last up, zero if it never was. The returned function releases the slot of the connection
+// and must be called once it is established or failed. A nil ConnLimiter doesn't limit the connections
+func (l *ConnLimiter) Acquire(ctx context.Context, lastActive time.Time) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	if l.active < l.limit && l.waiting.Len() == 0 {
+		l.active++
+		l.mu.Unlock()
+		return l.releaseFunc(), nil
+	}
+
+	l.seq++
+	w := &connWaiter{lastActive: lastActive, seq: l.seq, ready: make(chan struct{})}
+	heap.Push(&l.waiting, w)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-w.ready:
+		// admitted meanwhile, pass the slot on
+		l.release()
+	default:
+		heap.Remove(&l.waiting, w.index)
+	}
+	return nil, ctx.Err()
+}
+
+func (l *ConnLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release()
+		})
+	}
+}
+
+// release hands the slot over to the waiting connection with the highest priority or frees it
+func (l *ConnLimiter) release() {
+	if l.waiting.Len() == 0 {
+		l.active--
+		return
+	}
+	w := heap.Pop(&l.waiting).(*connWaiter)
+	close(w.ready)
+}
+
+type connWaiter struct {
+	lastActive time.Time
+	seq        uint64
+	ready      chan struct{}
+	index      int
+}
+
+// connWaitQueue is a heap of the waiting connections ordered by priority
+type connWaitQueue []*connWaiter
+
+func (q connWaitQueue) Len() int { return len(q) }
+
+func (q connWaitQueue) Less(i, j int) bool {
+	if !q[i].lastActive.Equal(q[j].lastActive) {
+		return q[i].lastActive.After(q[j].lastActive)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q connWaitQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *connWaitQueue) Push(x any) {
+	w := x.(*connWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *connWaitQueue) Pop() any {
+	old := *q
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return w
+}
@@ -0,0 +1,84 @@
+package peer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnLimiter_PrioritizesRecentlyActive(t *testing.T) {
+	limiter := NewConnLimiter(1)
+
+	release, err := limiter.Acquire(context.Background(), time.Time{})
+	require.NoError(t, err)
+
+	now := time.Now()
+	priorities := []time.Time{{}, now.Add(-time.Hour), now, now.Add(-time.Minute)}
+	admitted := make(chan time.Time, len(priorities))
+	for i, lastActive := range priorities {
+		queued := i + 1
+		lastActive := lastActive
+		go func() {
+			release, err := limiter.Acquire(context.Background(), lastActive)
+			if err != nil {
+				return
+			}
+			admitted <- lastActive
+			release()
+		}()
+		// wait for the connection to queue up
+		require.Eventually(t, func() bool {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			return limiter.waiting.Len() == queued
+		}, time.Second, time.Millisecond)
+	}
+
+	release()
+	release()
+
+	expected := []time.Time{now, now.Add(-time.Minute), now.Add(-time.Hour), {}}
+	for _, lastActive := range expected {
+		select {
+		case got := <-admitted:
+			assert.True(t, lastActive.Equal(got), "expected %s, got %s", lastActive, got)
+		case <-time.After(time.Second):
+			t.Fatal("connection not admitted")
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		return limiter.active == 0
+	}, time.Second, time.Millisecond, "all slots should be released")
+}
+
+func TestConnLimiter_Canceled(t *testing.T) {
+	limiter := NewConnLimiter(1)
+
+	release, err := limiter.Acquire(context.Background(), time.Time{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Acquire(ctx, time.Now())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, limiter.waiting.Len(), "the canceled connection should leave the queue")
+
+	release()
+	release, err = limiter.Acquire(context.Background(), time.Time{})
+	require.NoError(t, err, "the slot should be free")
+	release()
+	assert.Equal(t, 0, limiter.active)
+}
+
+func TestConnLimiter_Nil(t *testing.T) {
+	var limiter *ConnLimiter
+	release, err := limiter.Acquire(context.Background(), time.Time{})
+	require.NoError(t, err)
+	release()
+}
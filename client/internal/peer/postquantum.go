@@ -0,0 +1,121 @@
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/client/internal/postquantum"
+	sProto "github.com/FlintyLemming/netbird/signal/proto"
+)
+
+// reCreatePostQuantumKeyPair generates a fresh KEM key pair for the connection attempt and drops secrets
+// encapsulated to the previous one
+func (conn *Conn) reCreatePostQuantumKeyPair() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.pqKeyPair = nil
+	select {
+	case <-conn.remotePostQuantumCh:
+	default:
+	}
+
+	if !conn.config.PostQuantum {
+		return nil
+	}
+
+	keyPair, err := postquantum.GenerateKeyPair()
+	if err != nil {
+		return fmt.Errorf("failed generating post-quantum key pair: %v", err)
+	}
+	conn.pqKeyPair = keyPair
+	return nil
+}
+
+// postQuantumKey returns the public key to be sent with the offer or answer. Must be called with conn.mu held
+func (conn *Conn) postQuantumKey() []byte {
+	if conn.pqKeyPair == nil {
+		return nil
+	}
+	return conn.pqKeyPair.PublicKey()
+}
+
+// negotiatePreSharedKey returns the pre-shared key to configure for the remote peer.
+// When both peers sent a KEM public key, the controlling peer encapsulates a secret to the remote key and the
+// controlled peer waits for it. Otherwise, the static pre-shared key is used.
+func (conn *Conn) negotiatePreSharedKey(remoteOfferAnswer OfferAnswer, isControlling bool) (*wgtypes.Key, error) {
+	conn.mu.Lock()
+	keyPair := conn.pqKeyPair
+	conn.mu.Unlock()
+
+	staticPSK := conn.config.WgConfig.PreSharedKey
+	if keyPair == nil {
+		return staticPSK, nil
+	}
+
+	if len(remoteOfferAnswer.PostQuantumKey) == 0 {
+		log.Warnf("peer %s didn't send a post-quantum key, falling back to the static pre-shared key", conn.config.Key)
+		return staticPSK, nil
+	}
+
+	var secret []byte
+	if isControlling {
+		ciphertext, sharedSecret, err := postquantum.Encapsulate(remoteOfferAnswer.PostQuantumKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed encapsulating post-quantum secret for peer %s: %v", conn.config.Key, err)
+		}
+
+		err = conn.sendSignalMessage(&sProto.Message{
+			Key:       conn.config.LocalKey,
+			RemoteKey: conn.config.Key,
+			Body: &sProto.Body{
+				Type: sProto.Body_POST_QUANTUM,
+				PostQuantum: &sProto.PostQuantum{
+					Ciphertext:    ciphertext,
+					PublicKeyHash: postquantum.PublicKeyHash(remoteOfferAnswer.PostQuantumKey),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		secret = sharedSecret
+	} else {
+		var err error
+		secret, err = conn.waitPostQuantumSecret(keyPair)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	psk, err := postquantum.DerivePSK(secret, conn.config.LocalKey, conn.config.Key, staticPSK)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("negotiated post-quantum pre-shared key with peer %s", conn.config.Key)
+	return psk, nil
+}
+
+// waitPostQuantumSecret blocks until the controlling peer sends the secret encapsulated to our key pair
+func (conn *Conn) waitPostQuantumSecret(keyPair *postquantum.KeyPair) ([]byte, error) {
+	timeout := time.After(conn.config.Timeout)
+	for {
+		select {
+		case msg := <-conn.remotePostQuantumCh:
+			if !bytes.Equal(msg.PublicKeyHash, keyPair.PublicKeyHash()) {
+				log.Debugf("ignoring post-quantum secret from peer %s encapsulated to a previous key", conn.config.Key)
+				continue
+			}
+			return keyPair.Decapsulate(msg.Ciphertext)
+		case <-timeout:
+			return nil, NewConnectionTimeoutError(conn.config.Key, conn.config.Timeout)
+		case <-conn.closeCh:
+			return nil, NewConnectionClosedError(conn.config.Key)
+		}
+	}
+}
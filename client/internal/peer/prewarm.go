@@ -0,0 +1,78 @@
+package peer
+
+import (
+	"time"
+
+	"github.com/pion/ice/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// prewarm starts gathering the candidates of a priority connection right after its agent is created, so that the
+// server reflexive addresses are discovered and the TURN relay is allocated while the offer and the answer are
+// exchanged. The connection falls back to the relay right away when no direct path works. The candidates are held
+// back until the remote peer confirmed the connection, it has no agent to add them to before
+func (conn *Conn) prewarm() error {
+	conn.mu.Lock()
+	agent := conn.agent
+	conn.mu.Unlock()
+
+	conn.candidatesMu.Lock()
+	conn.holdCandidates = true
+	conn.heldCandidates = nil
+	conn.prewarmedAgent = agent
+	conn.candidatesMu.Unlock()
+
+	if err := agent.GatherCandidates(); err != nil {
+		return err
+	}
+	log.Debugf("gathering the candidates of priority peer %s in advance", conn.config.Key)
+	return nil
+}
+
+// holdCandidate keeps a local candidate gathered in advance until the remote peer confirmed the connection, it returns
+// false when the candidate can be signaled right away
+func (conn *Conn) holdCandidate(candidate ice.Candidate) bool {
+	conn.candidatesMu.Lock()
+	defer conn.candidatesMu.Unlock()
+
+	if !conn.holdCandidates {
+		return false
+	}
+	conn.heldCandidates = append(conn.heldCandidates, candidate)
+	return true
+}
+
+// signalCandidates signals the local candidates to the remote peer: the ones gathered in advance when the current
+// agent was prewarmed, otherwise the agent starts gathering them now. The candidates of a prewarmed agent replaced
+// since, e.g. with updated TURN credentials, are dropped
+func (conn *Conn) signalCandidates() error {
+	conn.mu.Lock()
+	agent := conn.agent
+	conn.mu.Unlock()
+
+	conn.candidatesMu.Lock()
+	held := conn.heldCandidates
+	prewarmed := agent != nil && conn.prewarmedAgent == agent
+	conn.holdCandidates = false
+	conn.heldCandidates = nil
+	conn.prewarmedAgent = nil
+	conn.candidatesMu.Unlock()
+
+	if !prewarmed {
+		return agent.GatherCandidates()
+	}
+
+	log.Debugf("signaling %d candidates gathered in advance to priority peer %s", len(held), conn.config.Key)
+	for _, candidate := range held {
+		conn.onICECandidate(candidate)
+	}
+	return nil
+}
+
+// wgKeepAlive returns the persistent keepalive the WireGuard peer of the connection is configured with
+func (conn *Conn) wgKeepAlive() time.Duration {
+	if conn.config.Priority {
+		return priorityWgKeepAlive
+	}
+	return defaultWgKeepAlive
+}
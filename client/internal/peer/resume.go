@@ -0,0 +1,200 @@
+package peer
+
+import (
+	"context"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+const (
+	// resumeHintTTL is how long after a direct connection went down it can be resumed without ICE,
+	// it covers a host sleeping overnight
+	resumeHintTTL = 24 * time.Hour
+	// resumeProbeTimeout is how long a resumed connection has to prove it works before falling back to ICE
+	resumeProbeTimeout = 5 * time.Second
+	// resumeProbeInterval is how often the WireGuard statistics are checked while probing
+	resumeProbeInterval = 250 * time.Millisecond
+	// resumedCheckInterval is how often the WireGuard statistics are checked while the connection is resumed
+	resumedCheckInterval = 10 * time.Second
+	// wgSessionTimeout is the age of the last handshake after which WireGuard drops the session keys, the
+	// handshakes are renewed every 2 minutes while the peers exchange keepalives
+	wgSessionTimeout = 3 * time.Minute
+)
+
+// resumeHint holds what is needed to restore the last direct connection to the peer without ICE
+type resumeHint struct {
+	// endpoint is the WireGuard endpoint of the remote peer
+	endpoint     *net.UDPAddr
+	preSharedKey *wgtypes.Key
+
+	localIceCandidateType  string
+	remoteIceCandidateType string
+
+	// disconnected is the time the connection went down, zero while it is up
+	disconnected time.Time
+}
+
+// valid returns true if the connection went down recently enough to be resumed
+func (h *resumeHint) valid(now time.Time) bool {
+	return h != nil && !h.disconnected.IsZero() && now.Sub(h.disconnected) < resumeHintTTL
+}
+
+// resumeProbeSucceeded returns true if the WireGuard statistics prove the remote peer is reachable again: either a
+// handshake completed after the connection went down or data was received since the probe started
+func resumeProbeSucceeded(hint *resumeHint, stats iface.WGStats, rxBytesAtStart int64, now time.Time) bool {
+	if stats.RxBytes > rxBytesAtStart {
+		return true
+	}
+	return stats.LastHandshake.After(hint.disconnected) && now.Sub(stats.LastHandshake) < wgSessionTimeout
+}
+
+// resumedConnectionLost returns true when the WireGuard session of a resumed connection expired
+func resumedConnectionLost(stats iface.WGStats, now time.Time) bool {
+	return now.Sub(stats.LastHandshake) > wgSessionTimeout
+}
+
+// setResumeHint remembers the WireGuard endpoint of a direct connection, it must be called with the lock held
+func (conn *Conn) setResumeHint(endpoint *net.UDPAddr, preSharedKey *wgtypes.Key, localIceCandidateType, remoteIceCandidateType string) {
+	conn.resumeHint = &resumeHint{
+		endpoint:               endpoint,
+		preSharedKey:           preSharedKey,
+		localIceCandidateType:  localIceCandidateType,
+		remoteIceCandidateType: remoteIceCandidateType,
+	}
+}
+
+// resume restores the last direct connection to the peer without a new ICE negotiation, e.g. after the host woke up
+// from sleep. The WireGuard peer is kept configured with the last known endpoint and the connection is resumed when
+// the WireGuard statistics show the remote peer is reachable within resumeProbeTimeout.
+// It returns false when the connection has to be negotiated again
+func (conn *Conn) resume() (bool, error) {
+	conn.mu.Lock()
+	hint := conn.resumeHint
+	conn.mu.Unlock()
+
+	if !hint.valid(time.Now()) {
+		return false, nil
+	}
+
+	log.Debugf("trying to resume the connection to peer %s with endpoint %s", conn.config.Key, hint.endpoint)
+
+	resumed, err := conn.probeResume(hint, resumeProbeTimeout)
+	if err != nil || !resumed {
+		return false, err
+	}
+	conn.markResumed(hint)
+	return true, nil
+}
+
+// probeResume configures the WireGuard peer with the endpoint of the hint and returns true when the WireGuard
+// statistics show the remote peer is reachable within the timeout
+func (conn *Conn) probeResume(hint *resumeHint, timeout time.Duration) (bool, error) {
+	wgInterface := conn.config.WgConfig.WgInterface
+	remoteKey := conn.config.WgConfig.RemoteKey
+	before, err := wgInterface.GetStats(remoteKey)
+	if err != nil {
+		log.Debugf("failed reading the WireGuard statistics of peer %s: %v", conn.config.Key, err)
+		return false, nil
+	}
+
+	// reconfiguring the persistent keepalive sends a keepalive right away, which starts a handshake if the session
+	// expired meanwhile
+	err = wgInterface.UpdatePeer(remoteKey, conn.config.WgConfig.AllowedIps, conn.wgKeepAlive(), hint.endpoint, hint.preSharedKey)
+	if err != nil {
+		return false, err
+	}
+
+	ticker := time.NewTicker(resumeProbeInterval)
+	defer ticker.Stop()
+	timeoutCh := time.After(timeout)
+	for {
+		select {
+		case <-conn.closeCh:
+			return false, NewConnectionClosedError(conn.config.Key)
+		case <-timeoutCh:
+			log.Debugf("peer %s didn't answer on endpoint %s, negotiating a new connection", conn.config.Key, hint.endpoint)
+			return false, nil
+		case <-ticker.C:
+			stats, err := wgInterface.GetStats(remoteKey)
+			if err != nil {
+				log.Debugf("failed reading the WireGuard statistics of peer %s: %v", conn.config.Key, err)
+				continue
+			}
+			if resumeProbeSucceeded(hint, stats, before.RxBytes, time.Now()) {
+				return true, nil
+			}
+		}
+	}
+}
+
+// markResumed sets the connection state to connected with the endpoint of the hint
+func (conn *Conn) markResumed(hint *resumeHint) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	conn.status = StatusConnected
+	conn.lastActive = time.Now()
+	conn.ctx, conn.notifyDisconnected = context.WithCancel(context.Background())
+	hint.disconnected = time.Time{}
+	if conn.resumeHint != nil {
+		// the remote peer might have roamed meanwhile, replacing the hint
+		conn.resumeHint.disconnected = time.Time{}
+	}
+
+	peerState := State{
+		PubKey:                 conn.config.Key,
+		ConnStatus:             conn.status,
+		ConnStatusUpdate:       time.Now(),
+		LocalIceCandidateType:  hint.localIceCandidateType,
+		RemoteIceCandidateType: hint.remoteIceCandidateType,
+		Direct:                 true,
+	}
+	err := conn.statusRecorder.UpdatePeerState(peerState)
+	if err != nil {
+		log.Warnf("unable to save peer's state, got error: %v", err)
+	}
+
+	log.Infof("resumed connection to peer %s, endpoint address: %s", conn.config.Key, hint.endpoint)
+}
+
+// waitResumed blocks while the resumed connection is up. The connection is negotiated again when the WireGuard
+// session expires or when the remote peer sends an offer because it couldn't resume the connection on its side
+func (conn *Conn) waitResumed() error {
+	ticker := time.NewTicker(resumedCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.closeCh:
+			return NewConnectionClosedError(conn.config.Key)
+		case <-conn.ctx.Done():
+			// the remote peer went offline
+			return NewConnectionDisconnectedError(conn.config.Key)
+		case <-conn.remoteOffersCh:
+			log.Debugf("peer %s sent an offer to a resumed connection, negotiating a new connection", conn.config.Key)
+			conn.dropResumeHint()
+			return NewConnectionDisconnectedError(conn.config.Key)
+		case <-ticker.C:
+			stats, err := conn.config.WgConfig.WgInterface.GetStats(conn.config.WgConfig.RemoteKey)
+			if err != nil {
+				log.Debugf("failed reading the WireGuard statistics of peer %s: %v", conn.config.Key, err)
+				continue
+			}
+			if resumedConnectionLost(stats, time.Now()) {
+				log.Debugf("resumed connection to peer %s expired", conn.config.Key)
+				return NewConnectionDisconnectedError(conn.config.Key)
+			}
+			conn.updateLastActive()
+		}
+	}
+}
+
+func (conn *Conn) dropResumeHint() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.resumeHint = nil
+}
@@ -0,0 +1,51 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func TestResumeHint_Valid(t *testing.T) {
+	now := time.Now()
+
+	var missing *resumeHint
+	assert.Equal(t, missing.valid(now), false, "a missing hint should not be valid")
+	assert.Equal(t, (&resumeHint{}).valid(now), false, "a connection still up should not be resumed")
+	assert.Equal(t, (&resumeHint{disconnected: now.Add(-time.Hour)}).valid(now), true, "a recent connection should be resumed")
+	assert.Equal(t, (&resumeHint{disconnected: now.Add(-resumeHintTTL - time.Minute)}).valid(now), false, "an old connection should not be resumed")
+}
+
+func TestResumeProbeSucceeded(t *testing.T) {
+	now := time.Now()
+	hint := &resumeHint{disconnected: now.Add(-time.Hour)}
+
+	tables := []struct {
+		name  string
+		stats iface.WGStats
+		want  bool
+	}{
+		{"handshake after the disconnection", iface.WGStats{LastHandshake: now.Add(-time.Second), RxBytes: 100}, true},
+		{"received data", iface.WGStats{LastHandshake: now.Add(-2 * time.Hour), RxBytes: 200}, true},
+		{"handshake before the disconnection", iface.WGStats{LastHandshake: now.Add(-2 * time.Hour), RxBytes: 100}, false},
+		{"expired handshake after the disconnection", iface.WGStats{LastHandshake: now.Add(-30 * time.Minute), RxBytes: 100}, false},
+		{"no handshake", iface.WGStats{RxBytes: 100}, false},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			got := resumeProbeSucceeded(hint, table.stats, 100, now)
+			assert.Equal(t, got, table.want, "they should be equal")
+		})
+	}
+}
+
+func TestResumedConnectionLost(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, resumedConnectionLost(iface.WGStats{LastHandshake: now.Add(-2 * time.Minute)}, now), false, "a renewed session should be up")
+	assert.Equal(t, resumedConnectionLost(iface.WGStats{LastHandshake: now.Add(-5 * time.Minute)}, now), true, "an expired session should be lost")
+}
@@ -0,0 +1,185 @@
+package peer
+
+import (
+	"net"
+	"time"
+
+	"github.com/pion/ice/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/iface/bind"
+)
+
+const (
+	// roamGracePeriod is how long after either peer roamed a failing ICE connection is kept on the WireGuard session
+	// instead of being negotiated again, ICE notices the removed addresses within a few seconds
+	roamGracePeriod = 30 * time.Second
+	// roamProbeTimeout is how long the WireGuard session of a roamed connection has to prove it works, it covers a
+	// persistent keepalive of the remote peer
+	roamProbeTimeout = defaultWgKeepAlive + resumeProbeTimeout
+)
+
+// SetSignalRoam sets a handler function to be triggered by Conn when the candidates of the new local addresses have to
+// be signalled to the remote peer
+func (conn *Conn) SetSignalRoam(handler func(candidates []ice.Candidate) error) {
+	conn.signalRoam = handler
+}
+
+// Roam moves a direct connection to the new local addresses of this peer, e.g. after a laptop switched from Wi-Fi to
+// Ethernet. The candidates of the new addresses are signalled to the remote peer and a keepalive is sent right away to
+// its endpoint. WireGuard follows the source address of the authenticated packets, so the session moves to the new
+// address like when WireGuard roams natively, and reconfiguring the endpoint drops the old source address the peer
+// was bound to. Returns false if the connection is not direct
+func (conn *Conn) Roam(candidates []ice.Candidate) bool {
+	conn.mu.Lock()
+	hint := conn.resumeHint
+	if conn.status != StatusConnected || hint == nil {
+		conn.mu.Unlock()
+		return false
+	}
+	conn.roamed = time.Now()
+	conn.mu.Unlock()
+
+	log.Debugf("roaming the connection to peer %s", conn.config.Key)
+
+	err := conn.config.WgConfig.WgInterface.UpdatePeer(conn.config.WgConfig.RemoteKey, conn.config.WgConfig.AllowedIps, conn.wgKeepAlive(), hint.endpoint, hint.preSharedKey)
+	if err != nil {
+		log.Warnf("failed updating the WireGuard peer %s after roaming: %v", conn.config.Key, err)
+	}
+
+	if conn.signalRoam != nil {
+		go func() {
+			err := conn.signalRoam(candidates)
+			if err != nil {
+				log.Errorf("failed signaling the new local addresses to peer %s: %v", conn.config.Key, err)
+			}
+		}()
+	}
+	return true
+}
+
+// OnRemoteRoam handles the new local addresses of the remote peer. A direct connection is moved to the candidate of
+// the same type as the remote candidate of the connection and a punch packet is sent to every candidate to open the
+// NATs on the way, without negotiating ICE again. Other connections are left to ICE
+func (conn *Conn) OnRemoteRoam(candidates []ice.Candidate) {
+	log.Debugf("OnRemoteRoam from peer %s on status %s", conn.config.Key, conn.status.String())
+
+	conn.mu.Lock()
+	if conn.status != StatusConnected || conn.resumeHint == nil {
+		conn.mu.Unlock()
+		return
+	}
+	candidate := roamingCandidate(candidates, conn.resumeHint.remoteIceCandidateType)
+	if candidate == nil {
+		conn.mu.Unlock()
+		log.Debugf("peer %s roamed without a usable candidate", conn.config.Key)
+		return
+	}
+
+	// the hint is replaced rather than changed, a resume in progress reads it without the lock
+	hint := *conn.resumeHint
+	hint.endpoint = &net.UDPAddr{IP: net.ParseIP(candidate.Address()), Port: candidate.Port()}
+	hint.remoteIceCandidateType = candidate.Type().String()
+	conn.resumeHint = &hint
+	conn.roamed = time.Now()
+	conn.mu.Unlock()
+
+	go conn.punchRoamingCandidates(candidates)
+
+	err := conn.config.WgConfig.WgInterface.UpdatePeer(conn.config.WgConfig.RemoteKey, conn.config.WgConfig.AllowedIps, conn.wgKeepAlive(), hint.endpoint, hint.preSharedKey)
+	if err != nil {
+		log.Warnf("failed updating the WireGuard peer %s after it roamed: %v", conn.config.Key, err)
+		return
+	}
+
+	log.Infof("peer %s roamed, endpoint address: %s", conn.config.Key, hint.endpoint)
+}
+
+// roamingCandidate returns the first UDP candidate of the candidate type, falling back to a server reflexive candidate
+// reachable from other networks and then to the first UDP candidate
+func roamingCandidate(candidates []ice.Candidate, candidateType string) ice.Candidate {
+	var srflx, first ice.Candidate
+	for _, candidate := range candidates {
+		if candidate.NetworkType().IsTCP() || net.ParseIP(candidate.Address()) == nil {
+			continue
+		}
+		if candidate.Type().String() == candidateType {
+			return candidate
+		}
+		if srflx == nil && candidate.Type() == ice.CandidateTypeServerReflexive {
+			srflx = candidate
+		}
+		if first == nil {
+			first = candidate
+		}
+	}
+	if srflx != nil {
+		return srflx
+	}
+	return first
+}
+
+func (conn *Conn) punchRoamingCandidates(candidates []ice.Candidate) {
+	mux, ok := conn.config.UDPMuxSrflx.(*bind.UniversalUDPMuxDefault)
+	if !ok {
+		log.Warn("invalid udp mux conversion")
+		return
+	}
+
+	for _, candidate := range candidates {
+		if candidate.NetworkType().IsTCP() {
+			continue
+		}
+		addr := &net.UDPAddr{IP: net.ParseIP(candidate.Address()), Port: candidate.Port()}
+		if addr.IP == nil {
+			continue
+		}
+		_, err := mux.GetSharedConn().WriteTo([]byte{0x6e, 0x62}, addr)
+		if err != nil {
+			log.Debugf("failed sending the punch packet to %s: %v", addr, err)
+		}
+	}
+}
+
+// roamedRecently returns true if either peer roamed within roamGracePeriod
+func (conn *Conn) roamedRecently(now time.Time) bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return !conn.roamed.IsZero() && now.Sub(conn.roamed) < roamGracePeriod
+}
+
+// waitRoamed keeps a direct connection up on the WireGuard session when ICE failed after either peer roamed: the
+// candidate pair selected by ICE is gone but the WireGuard session followed the new addresses. The ICE agent is closed
+// and the connection continues as a resumed one, it is negotiated again if the WireGuard session doesn't prove it
+// works within roamProbeTimeout
+func (conn *Conn) waitRoamed() error {
+	conn.mu.Lock()
+	if conn.agent != nil {
+		if err := conn.agent.Close(); err != nil {
+			log.Debugf("failed closing the ICE agent of peer %s: %v", conn.config.Key, err)
+		}
+		conn.agent = nil
+	}
+	hint := conn.resumeHint
+	if hint != nil {
+		hint.disconnected = time.Now()
+	}
+	conn.mu.Unlock()
+
+	if hint == nil {
+		return NewConnectionDisconnectedError(conn.config.Key)
+	}
+
+	log.Debugf("ICE connection to peer %s failed after roaming, keeping the WireGuard session", conn.config.Key)
+
+	resumed, err := conn.probeResume(hint, roamProbeTimeout)
+	if err != nil {
+		return err
+	}
+	if !resumed {
+		return NewConnectionDisconnectedError(conn.config.Key)
+	}
+
+	conn.markResumed(hint)
+	return conn.waitResumed()
+}
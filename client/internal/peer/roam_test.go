@@ -0,0 +1,65 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magiconair/properties/assert"
+	"github.com/pion/ice/v3"
+)
+
+func mustCandidate(t *testing.T, raw string) ice.Candidate {
+	t.Helper()
+	candidate, err := ice.UnmarshalCandidate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return candidate
+}
+
+func TestRoamingCandidate(t *testing.T) {
+	host := mustCandidate(t, "candidate:1 1 udp 2130706431 192.168.1.10 51820 typ host")
+	srflx := mustCandidate(t, "candidate:2 1 udp 1694498815 203.0.113.10 40000 typ srflx raddr 0.0.0.0 rport 51820")
+	tcpHost := mustCandidate(t, "candidate:3 1 tcp 2130706431 192.168.1.10 51820 typ host tcptype passive")
+
+	tables := []struct {
+		name          string
+		candidates    []ice.Candidate
+		candidateType string
+		want          ice.Candidate
+	}{
+		{"same type as the connection", []ice.Candidate{srflx, host}, "host", host},
+		{"server reflexive fallback", []ice.Candidate{host, srflx}, "prflx", srflx},
+		{"first candidate fallback", []ice.Candidate{host}, "srflx", host},
+		{"tcp candidates are skipped", []ice.Candidate{tcpHost}, "host", nil},
+		{"no candidates", nil, "host", nil},
+	}
+
+	for _, table := range tables {
+		t.Run(table.name, func(t *testing.T) {
+			got := roamingCandidate(table.candidates, table.candidateType)
+			assert.Equal(t, got, table.want, "they should be equal")
+		})
+	}
+}
+
+func TestConn_RoamedRecently(t *testing.T) {
+	now := time.Now()
+	conn := &Conn{}
+
+	assert.Equal(t, conn.roamedRecently(now), false, "a connection which never roamed should not be kept")
+
+	conn.roamed = now.Add(-time.Second)
+	assert.Equal(t, conn.roamedRecently(now), true, "a connection which just roamed should be kept")
+
+	conn.roamed = now.Add(-roamGracePeriod - time.Second)
+	assert.Equal(t, conn.roamedRecently(now), false, "a connection which roamed long ago should not be kept")
+}
+
+func TestConn_RoamNotDirect(t *testing.T) {
+	conn := &Conn{status: StatusConnected}
+	assert.Equal(t, conn.Roam(nil), false, "a relayed connection should not roam")
+
+	conn = &Conn{status: StatusConnecting, resumeHint: &resumeHint{}}
+	assert.Equal(t, conn.Roam(nil), false, "a connection not established should not roam")
+}
@@ -2,8 +2,17 @@ package peer
 
 import (
 	"errors"
+	"fmt"
+	"net/netip"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
+	"github.com/FlintyLemming/netbird/client/internal/health"
+	"github.com/FlintyLemming/netbird/client/internal/usage"
+	"github.com/FlintyLemming/netbird/iface"
 )
 
 // State contains the latest state of a peer
@@ -17,6 +26,23 @@ type State struct {
 	Direct                 bool
 	LocalIceCandidateType  string
 	RemoteIceCandidateType string
+	// Groups are the names of the groups the peer belongs to, as sent by the Management service
+	Groups []string
+	// LastWireguardHandshake, BytesRx and BytesTx are the WireGuard statistics of the peer as of the last
+	// RefreshWgStats, the transferred bytes are counted since the last ResetWgStats
+	LastWireguardHandshake time.Time
+	BytesRx                int64
+	BytesTx                int64
+	// IceRestarts counts the restarts of the connection by the Watchdog after its WireGuard handshakes went stale
+	IceRestarts int
+	// Services are the services advertised by the peer in the label:port/protocol format, e.g. http:8080/tcp
+	Services []string
+	// Labels are the key/value pairs describing the peer, e.g. env=prod
+	Labels map[string]string
+	// Lazy indicates that the connection to the peer is created on demand and wasn't needed yet
+	Lazy bool
+	// Priority indicates that the connection to the peer is established right away and kept warm
+	Priority bool
 }
 
 // LocalPeerState contains the latest state of the local peer
@@ -25,6 +51,12 @@ type LocalPeerState struct {
 	PubKey          string
 	KernelInterface bool
 	FQDN            string
+	// AllowedLocalPorts are the local ports accepted by the firewall before its rules, e.g. tcp/9100
+	AllowedLocalPorts []string
+	// Services are the services advertised by the local peer, e.g. http:8080/tcp
+	Services []string
+	// Labels are the key/value pairs describing the local peer
+	Labels map[string]string
 }
 
 // SignalState contains the latest state of a signal connection
@@ -37,6 +69,23 @@ type SignalState struct {
 type ManagementState struct {
 	URL       string
 	Connected bool
+	// LoginExpired indicates that management limited the network access until the peer logs in again
+	LoginExpired bool
+	// MaintenanceUntil is the end of the maintenance announced by management, zero without a maintenance. The client
+	// keeps its last configuration until it reconnects
+	MaintenanceUntil   time.Time
+	MaintenanceMessage string
+}
+
+// RouteState contains the routing peer chosen for a client route
+type RouteState struct {
+	NetID   string
+	Network netip.Prefix
+	// Peer is the public key of the chosen routing peer
+	Peer string
+	// LoadBalanced indicates that the routing peer was assigned to this peer among the connected routing peers of the
+	// network, instead of being the best one
+	LoadBalanced bool
 }
 
 // FullStatus contains the full state held by the Status instance
@@ -45,6 +94,24 @@ type FullStatus struct {
 	ManagementState ManagementState
 	SignalState     SignalState
 	LocalPeerState  LocalPeerState
+	// Components holds the health of the engine components while the engine is running
+	Components []health.ComponentState
+	// Routes holds the chosen client routes sorted by network ID
+	Routes []RouteState
+	// Resources holds the peer connections allocated by the client and its memory consumption
+	Resources ResourceUsage
+}
+
+// ResourceUsage contains the peer connections allocated by the client and its memory consumption
+type ResourceUsage struct {
+	// Peers is the number of remote peers, MaterializedPeers the number of peers with a connection allocated
+	Peers             int
+	MaterializedPeers int
+	// LazyConnections indicates that the connections to the peers are created on demand
+	LazyConnections bool
+	// HeapAllocBytes is the memory allocated on the heap, SysBytes the memory obtained from the system
+	HeapAllocBytes uint64
+	SysBytes       uint64
 }
 
 // Status holds a state of peers, signal and management connections
@@ -54,11 +121,31 @@ type Status struct {
 	changeNotify    map[string]chan struct{}
 	signalState     bool
 	managementState bool
+	loginExpired    bool
 	localPeer       LocalPeerState
 	offlinePeers    []State
 	mgmAddress      string
 	signalAddress   string
 	notifier        *notifier
+	attention       *attentionDispatcher
+	health          *health.Registry
+	events          *eventlog.Log
+	// peerStateObserver is notified when the connection status of a peer changes
+	peerStateObserver func(State)
+	// wgStatsGetter reads the WireGuard statistics of the peers, nil when the engine is stopped
+	wgStatsGetter func() (map[string]iface.WGStats, error)
+	// wgStatsBaseline holds the counters of the peers at the last ResetWgStats by peer key
+	wgStatsBaseline map[string]iface.WGStats
+	// usage accounts the traffic with the peers from the samples of their WireGuard counters
+	usage *usage.Tracker
+	// routes holds the chosen client routes by HA unique ID
+	routes map[string]RouteState
+	// lazyConnections indicates that the engine creates the connections to the peers on demand
+	lazyConnections bool
+	// maintenanceUntil and maintenanceMessage describe the maintenance announced by management until the client
+	// reconnects
+	maintenanceUntil   time.Time
+	maintenanceMessage string
 
 	// To reduce the number of notification invocation this bool will be true when need to call the notification
 	// Some Peer actions mostly used by in a batch when the network map has been synchronized. In these type of events
@@ -72,7 +159,9 @@ func NewRecorder(mgmAddress string) *Status {
 		peers:        make(map[string]State),
 		changeNotify: make(map[string]chan struct{}),
 		offlinePeers: make([]State, 0),
+		routes:       make(map[string]RouteState),
 		notifier:     newNotifier(),
+		attention:    newAttentionDispatcher(),
 		mgmAddress:   mgmAddress,
 	}
 }
@@ -150,12 +239,16 @@ func (d *Status) UpdatePeerState(receivedState State) error {
 	skipNotification := shouldSkipNotify(receivedState, peerState)
 
 	if receivedState.ConnStatus != peerState.ConnStatus {
+		d.recordPeerEvent(peerState, receivedState)
 		peerState.ConnStatus = receivedState.ConnStatus
 		peerState.ConnStatusUpdate = receivedState.ConnStatusUpdate
 		peerState.Direct = receivedState.Direct
 		peerState.Relayed = receivedState.Relayed
 		peerState.LocalIceCandidateType = receivedState.LocalIceCandidateType
 		peerState.RemoteIceCandidateType = receivedState.RemoteIceCandidateType
+		if d.peerStateObserver != nil {
+			d.peerStateObserver(peerState)
+		}
 	}
 
 	d.peers[receivedState.PubKey] = peerState
@@ -174,6 +267,25 @@ func (d *Status) UpdatePeerState(receivedState State) error {
 	return nil
 }
 
+// recordPeerEvent records the peer getting connected or losing the connection
+func (d *Status) recordPeerEvent(curr, received State) {
+	name := curr.FQDN
+	if name == "" {
+		name = curr.PubKey
+	}
+
+	switch {
+	case received.ConnStatus == StatusConnected:
+		connType := "direct"
+		if received.Relayed {
+			connType = "relayed"
+		}
+		d.recordEvent(eventlog.CategoryPeer, fmt.Sprintf("peer %s connected (%s)", name, connType))
+	case curr.ConnStatus == StatusConnected:
+		d.recordEvent(eventlog.CategoryPeer, fmt.Sprintf("peer %s disconnected", name))
+	}
+}
+
 func shouldSkipNotify(received, curr State) bool {
 	switch {
 	case received.ConnStatus == StatusConnecting:
@@ -203,6 +315,113 @@ func (d *Status) UpdatePeerFQDN(peerPubKey, fqdn string) error {
 	return nil
 }
 
+// UpdatePeerGroups updates the names of the groups of the peer only
+func (d *Status) UpdatePeerGroups(peerPubKey string, groups []string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return errors.New("peer doesn't exist")
+	}
+
+	peerState.Groups = groups
+	d.peers[peerPubKey] = peerState
+
+	return nil
+}
+
+// UpdatePeerServices updates the services advertised by the peer only
+func (d *Status) UpdatePeerServices(peerPubKey string, services []string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return errors.New("peer doesn't exist")
+	}
+
+	peerState.Services = services
+	d.peers[peerPubKey] = peerState
+
+	return nil
+}
+
+// UpdatePeerLabels updates the labels of the peer only
+func (d *Status) UpdatePeerLabels(peerPubKey string, labels map[string]string) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return errors.New("peer doesn't exist")
+	}
+
+	peerState.Labels = labels
+	d.peers[peerPubKey] = peerState
+
+	return nil
+}
+
+// UpdatePeerLazy records whether the connection to the peer is created on demand and wasn't needed yet
+func (d *Status) UpdatePeerLazy(peerPubKey string, lazy bool) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return errors.New("peer doesn't exist")
+	}
+
+	peerState.Lazy = lazy
+	d.peers[peerPubKey] = peerState
+
+	return nil
+}
+
+// UpdatePeerPriority records whether the connection to the peer is a priority one, kept warm by the keepalive
+func (d *Status) UpdatePeerPriority(peerPubKey string, priority bool) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return errors.New("peer doesn't exist")
+	}
+
+	peerState.Priority = priority
+	d.peers[peerPubKey] = peerState
+
+	return nil
+}
+
+// SetLazyConnections records whether the engine creates the connections to the peers on demand
+func (d *Status) SetLazyConnections(enabled bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.lazyConnections = enabled
+}
+
+// RecordIceRestart counts a restart of the connection to the peer by the Watchdog
+func (d *Status) RecordIceRestart(peerPubKey string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	peerState, ok := d.peers[peerPubKey]
+	if !ok {
+		return
+	}
+	peerState.IceRestarts++
+	d.peers[peerPubKey] = peerState
+
+	name := peerState.FQDN
+	if name == "" {
+		name = peerState.PubKey
+	}
+	d.recordEvent(eventlog.CategoryPeer, fmt.Sprintf("connection to peer %s restarted, its WireGuard handshakes went stale", name))
+}
+
 // FinishPeerListModifications this event invoke the notification
 func (d *Status) FinishPeerListModifications() {
 	d.mux.Lock()
@@ -250,19 +469,112 @@ func (d *Status) CleanLocalPeerState() {
 // MarkManagementDisconnected sets ManagementState to disconnected
 func (d *Status) MarkManagementDisconnected() {
 	d.mux.Lock()
-	defer d.mux.Unlock()
-	defer d.onConnectionChanged()
-
+	if d.managementState {
+		d.recordEvent(eventlog.CategoryManagement, fmt.Sprintf("disconnected from management %s", d.mgmAddress))
+	}
 	d.managementState = false
+	mgmAddress := d.mgmAddress
+	d.onConnectionChanged()
+	d.mux.Unlock()
+
+	// the attention listener is notified without holding the lock
+	d.attention.managementConnectionChanged(false, mgmAddress)
 }
 
 // MarkManagementConnected sets ManagementState to connected
 func (d *Status) MarkManagementConnected() {
+	d.mux.Lock()
+	if !d.managementState {
+		d.recordEvent(eventlog.CategoryManagement, fmt.Sprintf("connected to management %s", d.mgmAddress))
+		d.maintenanceUntil = time.Time{}
+		d.maintenanceMessage = ""
+	}
+	d.managementState = true
+	mgmAddress := d.mgmAddress
+	d.onConnectionChanged()
+	d.mux.Unlock()
+
+	// the attention listener is notified without holding the lock
+	d.attention.managementConnectionChanged(true, mgmAddress)
+}
+
+// MarkManagementMaintenance records a maintenance of the Management service announced for the window. The
+// disconnection during the window is expected, the unreachable event is only raised once it is over
+func (d *Status) MarkManagementMaintenance(window time.Duration, message string) {
+	d.mux.Lock()
+	d.maintenanceUntil = time.Now().Add(window)
+	d.maintenanceMessage = message
+	d.recordEvent(eventlog.CategoryManagement, fmt.Sprintf("management announced a maintenance of %s: %s", window, message))
+	until := d.maintenanceUntil
+	d.mux.Unlock()
+
+	d.attention.maintenanceAnnounced(until)
+}
+
+// MarkLoginExpired sets whether management reported the login of the peer as expired
+func (d *Status) MarkLoginExpired(expired bool) {
+	d.mux.Lock()
+	d.loginExpired = expired
+	d.mux.Unlock()
+
+	if expired {
+		d.attention.raise(AttentionLoginExpired, "the login of the peer expired, log in again to restore the access to the network")
+		return
+	}
+	d.attention.resolve(AttentionLoginExpired)
+}
+
+// MarkPostureCheckFailed notifies the attention listener that the Management service rejected the device attestation
+// of the peer. It is resolved once the peer connects to the Management service
+func (d *Status) MarkPostureCheckFailed(message string) {
+	d.attention.raise(AttentionPostureCheckFailed, message)
+}
+
+// IsLoginExpired returns true if management reported the login of the peer as expired
+func (d *Status) IsLoginExpired() bool {
 	d.mux.Lock()
 	defer d.mux.Unlock()
-	defer d.onConnectionChanged()
 
-	d.managementState = true
+	return d.loginExpired
+}
+
+// SetHealthRegistry sets the registry of the running engine components, nil when the engine is stopped
+func (d *Status) SetHealthRegistry(registry *health.Registry) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.health = registry
+}
+
+// SetEventLog sets the log the connection changes are recorded to
+func (d *Status) SetEventLog(events *eventlog.Log) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.events = events
+}
+
+// SetPeerStateObserver sets the function notified when the connection status of a peer changes. It is called with
+// the lock of the status held, so it must not block nor call the status back
+func (d *Status) SetPeerStateObserver(observer func(State)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.peerStateObserver = observer
+}
+
+// RecordEvent adds an event to the event log, it is a no-op when no log is set
+func (d *Status) RecordEvent(category eventlog.Category, message string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.recordEvent(category, message)
+}
+
+func (d *Status) recordEvent(category eventlog.Category, message string) {
+	if d.events != nil {
+		d.events.Add(category, message)
+	}
 }
 
 // UpdateSignalAddress update the address of the signal server
@@ -299,6 +611,10 @@ func (d *Status) MarkSignalConnected() {
 
 // GetFullStatus gets full status
 func (d *Status) GetFullStatus() FullStatus {
+	// reading the memory statistics stops the world, it is done before locking the status
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
 	d.mux.Lock()
 	defer d.mux.Unlock()
 
@@ -306,31 +622,76 @@ func (d *Status) GetFullStatus() FullStatus {
 		ManagementState: ManagementState{
 			d.mgmAddress,
 			d.managementState,
+			d.loginExpired,
+			d.maintenanceUntil,
+			d.maintenanceMessage,
 		},
 		SignalState: SignalState{
 			d.signalAddress,
 			d.signalState,
 		},
 		LocalPeerState: d.localPeer,
+		Resources: ResourceUsage{
+			Peers:           len(d.peers),
+			LazyConnections: d.lazyConnections,
+			HeapAllocBytes:  memStats.HeapAlloc,
+			SysBytes:        memStats.Sys,
+		},
 	}
 
 	for _, status := range d.peers {
 		fullStatus.Peers = append(fullStatus.Peers, status)
+		if !status.Lazy {
+			fullStatus.Resources.MaterializedPeers++
+		}
 	}
 
 	fullStatus.Peers = append(fullStatus.Peers, d.offlinePeers...)
 
+	if d.health != nil {
+		fullStatus.Components = d.health.Snapshot()
+	}
+
+	for _, route := range d.routes {
+		fullStatus.Routes = append(fullStatus.Routes, route)
+	}
+	sort.Slice(fullStatus.Routes, func(i, j int) bool {
+		return fullStatus.Routes[i].NetID < fullStatus.Routes[j].NetID
+	})
+
 	return fullStatus
 }
 
+// UpdateRouteState records the routing peer chosen for the client route of the HA set
+func (d *Status) UpdateRouteState(haID string, state RouteState) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.routes[haID] = state
+}
+
+// RemoveRouteState removes the client route of the HA set
+func (d *Status) RemoveRouteState(haID string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	delete(d.routes, haID)
+}
+
 // ClientStart will notify all listeners about the new service state
 func (d *Status) ClientStart() {
 	d.notifier.clientStart()
+
+	d.mux.Lock()
+	mgmAddress := d.mgmAddress
+	d.mux.Unlock()
+	d.attention.clientStart(mgmAddress)
 }
 
 // ClientStop will notify all listeners about the new service state
 func (d *Status) ClientStop() {
 	d.notifier.clientStop()
+	d.attention.clientStop()
 }
 
 // ClientTeardown will notify all listeners about the service is under teardown
@@ -348,6 +709,23 @@ func (d *Status) RemoveConnectionListener() {
 	d.notifier.removeListener()
 }
 
+// SetAttentionListener sets the listener notified when the client requires the attention of the user, the events
+// raised before are replayed to it
+func (d *Status) SetAttentionListener(listener AttentionListener) {
+	d.attention.setListener(listener)
+}
+
+// RemoveAttentionListener removes the attention listener
+func (d *Status) RemoveAttentionListener() {
+	d.attention.removeListener()
+}
+
+// SetManagementUnreachableThreshold sets the time without a connection to the Management service after which the
+// attention listener is notified
+func (d *Status) SetManagementUnreachableThreshold(threshold time.Duration) {
+	d.attention.setUnreachableThreshold(threshold)
+}
+
 func (d *Status) onConnectionChanged() {
 	d.notifier.updateServerStates(d.managementState, d.signalState)
 }
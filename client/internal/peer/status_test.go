@@ -2,8 +2,12 @@ package peer
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
 )
 
 func TestAddPeer(t *testing.T) {
@@ -73,6 +77,27 @@ func TestStatus_UpdatePeerFQDN(t *testing.T) {
 	assert.Equal(t, fqdn, state.FQDN, "fqdn should be equal")
 }
 
+func TestStatus_UpdatePeerGroups(t *testing.T) {
+	key := "abc"
+	groups := []string{"databases", "servers"}
+	status := NewRecorder("https://mgm")
+	peerState := State{
+		PubKey: key,
+	}
+
+	status.peers[key] = peerState
+
+	err := status.UpdatePeerGroups(key, groups)
+	assert.NoError(t, err, "shouldn't return error")
+
+	state, exists := status.peers[key]
+	assert.True(t, exists, "state should be found")
+	assert.Equal(t, groups, state.Groups, "groups should be equal")
+
+	err = status.UpdatePeerGroups("missing", groups)
+	assert.Error(t, err, "should return error for an unknown peer")
+}
+
 func TestGetPeerStateChangeNotifierLogic(t *testing.T) {
 	key := "abc"
 	ip := "10.10.10.10"
@@ -197,6 +222,44 @@ func TestUpdateManagementState(t *testing.T) {
 	}
 }
 
+func TestMarkLoginExpired(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	assert.False(t, status.IsLoginExpired(), "login shouldn't be expired by default")
+
+	status.MarkLoginExpired(true)
+	assert.True(t, status.IsLoginExpired(), "login should be expired")
+	assert.True(t, status.GetFullStatus().ManagementState.LoginExpired, "full status should report the expired login")
+
+	status.MarkLoginExpired(false)
+	assert.False(t, status.IsLoginExpired(), "login shouldn't be expired after re-authentication")
+}
+
+func TestStatus_RecordsEvents(t *testing.T) {
+	key := "abc"
+	status := NewRecorder("https://mgm")
+	events := eventlog.New("", eventlog.DefaultMaxEvents, eventlog.DefaultMaxAge)
+	status.SetEventLog(events)
+
+	status.MarkManagementConnected()
+	status.MarkManagementConnected()
+	require.NoError(t, status.AddPeer(key, "abc.netbird"))
+	require.NoError(t, status.UpdatePeerState(State{PubKey: key, ConnStatus: StatusConnecting}))
+	require.NoError(t, status.UpdatePeerState(State{PubKey: key, ConnStatus: StatusConnected, Relayed: true}))
+	require.NoError(t, status.UpdatePeerState(State{PubKey: key, ConnStatus: StatusDisconnected}))
+	status.MarkManagementDisconnected()
+
+	var messages []string
+	for _, event := range events.Since(time.Time{}) {
+		messages = append(messages, event.Message)
+	}
+	assert.Equal(t, []string{
+		"connected to management https://mgm",
+		"peer abc.netbird connected (relayed)",
+		"peer abc.netbird disconnected",
+		"disconnected from management https://mgm",
+	}, messages, "only connection changes should be recorded")
+}
+
 func TestGetFullStatus(t *testing.T) {
 	key1 := "abc"
 	key2 := "def"
@@ -231,3 +294,19 @@ func TestGetFullStatus(t *testing.T) {
 	assert.Equal(t, signalState, fullStatus.SignalState, "signal status should be equal")
 	assert.ElementsMatch(t, []State{peerState1, peerState2}, fullStatus.Peers, "peers states should match")
 }
+
+func TestGetFullStatus_Resources(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	require.NoError(t, status.AddPeer("abc", "abc.netbird.cloud"))
+	require.NoError(t, status.AddPeer("def", "def.netbird.cloud"))
+	status.SetLazyConnections(true)
+
+	require.NoError(t, status.UpdatePeerLazy("def", true))
+	assert.Error(t, status.UpdatePeerLazy("missing", true), "should return error for an unknown peer")
+
+	resources := status.GetFullStatus().Resources
+	assert.Equal(t, 2, resources.Peers)
+	assert.Equal(t, 1, resources.MaterializedPeers)
+	assert.True(t, resources.LazyConnections)
+	assert.NotZero(t, resources.SysBytes)
+}
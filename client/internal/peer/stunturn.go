@@ -0,0 +1,52 @@
+package peer
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// swapAgentStunTurn recreates the ICE agent when the STUN and TURN servers were updated since its creation, e.g. with
+// the TURN credentials refreshed by the Management service. The new agent keeps the local ICE credentials already
+// sent to the remote peer and the remote candidates received meanwhile. It must be called before gathering candidates.
+func (conn *Conn) swapAgentStunTurn() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.agent == nil {
+		return nil
+	}
+
+	conn.stunTurnMu.Lock()
+	updated := conn.stunTurnVersion != conn.agentStunTurnVersion
+	conn.stunTurnMu.Unlock()
+	if !updated {
+		return nil
+	}
+
+	localUfrag, localPwd, err := conn.agent.GetLocalUserCredentials()
+	if err != nil {
+		return fmt.Errorf("get local ICE credentials: %w", err)
+	}
+	remoteCandidates, err := conn.agent.GetRemoteCandidates()
+	if err != nil {
+		return fmt.Errorf("get remote candidates: %w", err)
+	}
+
+	if err := conn.agent.Close(); err != nil {
+		return fmt.Errorf("close ICE agent: %w", err)
+	}
+	conn.agent = nil
+
+	if err := conn.newAgent(localUfrag, localPwd); err != nil {
+		return err
+	}
+	for _, candidate := range remoteCandidates {
+		if err := conn.agent.AddRemoteCandidate(candidate); err != nil {
+			log.Debugf("failed to add remote candidate %s of peer %s: %v", candidate, conn.config.Key, err)
+		}
+	}
+
+	log.Debugf("switched the connection to peer %s to the updated STUN and TURN servers", conn.config.Key)
+	return nil
+}
@@ -0,0 +1,40 @@
+package peer
+
+import (
+	"testing"
+
+	"github.com/pion/stun/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConn_SwapAgentStunTurn(t *testing.T) {
+	conn, err := NewConn(connConf, NewRecorder("https://mgm"), nil, nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.reCreateAgent())
+	defer func() {
+		_ = conn.agent.Close()
+	}()
+	agent := conn.agent
+	ufrag, pwd, err := agent.GetLocalUserCredentials()
+	require.NoError(t, err)
+
+	require.NoError(t, conn.swapAgentStunTurn())
+	assert.Same(t, agent, conn.agent, "the agent shouldn't be recreated without update")
+
+	turn, err := stun.ParseURI("turn:turn.example.com:3478")
+	require.NoError(t, err)
+	turn.Username = "1700000000"
+	turn.Password = "secret"
+	conn.UpdateStunTurn([]*stun.URI{turn})
+
+	require.NoError(t, conn.swapAgentStunTurn())
+	require.NotSame(t, agent, conn.agent, "the agent should be recreated with the updated servers")
+
+	newUfrag, newPwd, err := conn.agent.GetLocalUserCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, ufrag, newUfrag, "the local ICE credentials sent to the remote peer should be kept")
+	assert.Equal(t, pwd, newPwd, "the local ICE credentials sent to the remote peer should be kept")
+	assert.Equal(t, conn.stunTurnVersion, conn.agentStunTurnVersion)
+}
@@ -0,0 +1,184 @@
+package peer
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+const (
+	// watchdogCheckInterval is how often the handshakes of the connected peers are checked
+	watchdogCheckInterval = 30 * time.Second
+	// staleHandshakeTimeout is the age of the last handshake after which a peer WireGuard keeps sending to is
+	// considered unreachable, WireGuard renews the handshakes every 2 minutes while packets are sent
+	staleHandshakeTimeout = wgSessionTimeout
+	// watchdogBackoffMin and watchdogBackoffMax bound the delay between two restarts of the connection to a peer
+	watchdogBackoffMin = 30 * time.Second
+	watchdogBackoffMax = 10 * time.Minute
+	// watchdogJitter is the fraction of the backoff the delay is randomized by, so the peers behind the same NAT don't
+	// restart in lockstep
+	watchdogJitter = 0.2
+)
+
+// WgStatsReader reads the WireGuard statistics of the peers
+type WgStatsReader interface {
+	GetAllStats() (map[string]iface.WGStats, error)
+}
+
+// Watchdog restarts the connections which are up according to ICE but whose WireGuard handshakes went stale, e.g.
+// after a NAT rebinding dropped the mapping of a direct connection. A peer WireGuard keeps sending to without
+// completing a handshake is negotiated again with ICE, with a jittered exponential backoff between the restarts while
+// the handshakes don't recover.
+type Watchdog struct {
+	wgInterface    WgStatsReader
+	statusRecorder *Status
+	restart        func(peerKey string) bool
+	jitter         func() float64
+
+	peers map[string]*watchdogPeer
+}
+
+type watchdogPeer struct {
+	txBytes     int64
+	connUpdate  time.Time
+	backoff     time.Duration
+	nextRestart time.Time
+}
+
+// NewWatchdog creates a watchdog restarting the connections with the restart function, which returns false when the
+// connection to the peer can't be restarted
+func NewWatchdog(wgInterface WgStatsReader, statusRecorder *Status, restart func(peerKey string) bool) *Watchdog {
+	return &Watchdog{
+		wgInterface:    wgInterface,
+		statusRecorder: statusRecorder,
+		restart:        restart,
+		jitter:         rand.Float64,
+		peers:          make(map[string]*watchdogPeer),
+	}
+}
+
+// Run checks the handshakes of the peers periodically until the context is done, it returns right away when the
+// watchdog is disabled with NB_PEER_WATCHDOG_DISABLED
+func (w *Watchdog) Run(ctx context.Context) {
+	if isWatchdogDisabled() {
+		log.Infof("the peer connection watchdog is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(watchdogCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check(time.Now())
+		}
+	}
+}
+
+// check restarts the connected peers with a stale handshake whose backoff expired
+func (w *Watchdog) check(now time.Time) {
+	allStats, err := w.wgInterface.GetAllStats()
+	if err != nil {
+		log.Debugf("failed to read the WireGuard statistics of the peers: %v", err)
+		return
+	}
+
+	for peerKey := range w.peers {
+		if _, ok := allStats[peerKey]; !ok {
+			delete(w.peers, peerKey)
+		}
+	}
+
+	for peerKey, stats := range allStats {
+		state, err := w.statusRecorder.GetPeer(peerKey)
+		if err != nil || state.ConnStatus != StatusConnected {
+			continue
+		}
+
+		p, ok := w.peers[peerKey]
+		if !ok {
+			w.peers[peerKey] = &watchdogPeer{txBytes: stats.TxBytes, connUpdate: state.ConnStatusUpdate}
+			continue
+		}
+
+		// the connection was established again since the last check, the backoff is kept until the handshakes recover
+		if !p.connUpdate.Equal(state.ConnStatusUpdate) || stats.TxBytes < p.txBytes {
+			p.txBytes = stats.TxBytes
+			p.connUpdate = state.ConnStatusUpdate
+			continue
+		}
+
+		sending := stats.TxBytes > p.txBytes
+		p.txBytes = stats.TxBytes
+
+		if !handshakeStale(stats, state, sending, now) {
+			if now.Sub(stats.LastHandshake) < staleHandshakeTimeout {
+				p.backoff = 0
+			}
+			continue
+		}
+
+		if now.Before(p.nextRestart) {
+			continue
+		}
+
+		if !w.restart(peerKey) {
+			continue
+		}
+
+		p.backoff = nextWatchdogBackoff(p.backoff)
+		p.nextRestart = now.Add(w.jittered(p.backoff))
+		w.statusRecorder.RecordIceRestart(peerKey)
+		log.Infof("restarted the connection to peer %s, its last WireGuard handshake was at %s", peerKey, stats.LastHandshake)
+	}
+}
+
+// Restart negotiates the connection again with ICE. The endpoint of the stale session is dropped, the connection is
+// neither resumed nor kept after roaming. Returns false if the connection isn't up
+func (conn *Conn) Restart() bool {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	if conn.status != StatusConnected || conn.notifyDisconnected == nil {
+		return false
+	}
+
+	conn.resumeHint = nil
+	conn.roamed = time.Time{}
+	conn.notifyDisconnected()
+	return true
+}
+
+// handshakeStale returns true when WireGuard keeps sending to a connected peer without completing a handshake, a peer
+// without traffic, e.g. with the keepalive disabled, doesn't renew its handshakes and isn't considered stale
+func handshakeStale(stats iface.WGStats, state State, sending bool, now time.Time) bool {
+	if !sending {
+		return false
+	}
+	lastActivity := stats.LastHandshake
+	if state.ConnStatusUpdate.After(lastActivity) {
+		lastActivity = state.ConnStatusUpdate
+	}
+	return now.Sub(lastActivity) > staleHandshakeTimeout
+}
+
+func nextWatchdogBackoff(backoff time.Duration) time.Duration {
+	if backoff == 0 {
+		return watchdogBackoffMin
+	}
+	backoff *= 2
+	if backoff > watchdogBackoffMax {
+		return watchdogBackoffMax
+	}
+	return backoff
+}
+
+func (w *Watchdog) jittered(backoff time.Duration) time.Duration {
+	return backoff + time.Duration(float64(backoff)*watchdogJitter*(2*w.jitter()-1))
+}
@@ -0,0 +1,131 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func newTestWatchdog(wg WgStatsReader, status *Status, restarts *[]string) *Watchdog {
+	w := NewWatchdog(wg, status, func(peerKey string) bool {
+		*restarts = append(*restarts, peerKey)
+		return true
+	})
+	w.jitter = func() float64 { return 0.5 }
+	return w
+}
+
+func TestWatchdog_StaleHandshake(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "stale", "srflx", "srflx")
+	connectPeer(t, status, "idle", "host", "host")
+	connectPeer(t, status, "healthy", "host", "host")
+
+	now := time.Now()
+	wg := &fakeKeepAliveConfigurer{stats: map[string]iface.WGStats{
+		"stale":   {LastHandshake: now},
+		"idle":    {LastHandshake: now},
+		"healthy": {LastHandshake: now},
+	}}
+	var restarts []string
+	watchdog := newTestWatchdog(wg, status, &restarts)
+	watchdog.check(now)
+
+	now = now.Add(staleHandshakeTimeout + time.Minute)
+	wg.transfer("stale", 100)
+	wg.transfer("healthy", 100)
+	wg.stats["healthy"] = iface.WGStats{LastHandshake: now, RxBytes: 100, TxBytes: 100}
+	watchdog.check(now)
+
+	assert.Equal(t, []string{"stale"}, restarts, "only the peer sent to without handshakes should be restarted")
+
+	state, err := status.GetPeer("stale")
+	require.NoError(t, err)
+	assert.Equal(t, 1, state.IceRestarts, "the restart should be counted in the status")
+}
+
+func TestWatchdog_Backoff(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "stale", "srflx", "srflx")
+
+	now := time.Now()
+	wg := &fakeKeepAliveConfigurer{stats: map[string]iface.WGStats{"stale": {LastHandshake: now}}}
+	var restarts []string
+	watchdog := newTestWatchdog(wg, status, &restarts)
+	watchdog.check(now)
+
+	check := func(after time.Duration) {
+		now = now.Add(after)
+		wg.transfer("stale", 100)
+		watchdog.check(now)
+	}
+
+	check(staleHandshakeTimeout + time.Second)
+	require.Len(t, restarts, 1)
+
+	check(watchdogBackoffMin / 2)
+	assert.Len(t, restarts, 1, "the peer shouldn't be restarted before the backoff expired")
+
+	check(watchdogBackoffMin / 2)
+	assert.Len(t, restarts, 2, "the peer should be restarted once the backoff expired")
+
+	check(watchdogBackoffMin)
+	assert.Len(t, restarts, 2, "the backoff should be doubled")
+
+	check(watchdogBackoffMin)
+	assert.Len(t, restarts, 3)
+
+	// the handshakes recovered
+	wg.stats["stale"] = iface.WGStats{LastHandshake: now, TxBytes: wg.stats["stale"].TxBytes}
+	check(time.Second)
+	check(staleHandshakeTimeout)
+	assert.Len(t, restarts, 4, "the backoff should be reset once the handshakes recovered")
+}
+
+func TestWatchdog_Reconnected(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	connectPeer(t, status, "peer", "srflx", "srflx")
+
+	now := time.Now()
+	wg := &fakeKeepAliveConfigurer{stats: map[string]iface.WGStats{"peer": {LastHandshake: now.Add(-time.Hour)}}}
+	var restarts []string
+	watchdog := newTestWatchdog(wg, status, &restarts)
+	watchdog.check(now)
+
+	// the connection was restarted and established again, it has a grace period to complete a handshake
+	require.NoError(t, status.UpdatePeerState(State{PubKey: "peer", ConnStatus: StatusDisconnected}))
+	connectPeer(t, status, "peer", "srflx", "srflx")
+	state, err := status.GetPeer("peer")
+	require.NoError(t, err)
+	now = state.ConnStatusUpdate
+
+	wg.transfer("peer", 100)
+	watchdog.check(now.Add(time.Second))
+	wg.transfer("peer", 100)
+	watchdog.check(now.Add(time.Minute))
+	assert.Empty(t, restarts, "a new connection shouldn't be restarted before the handshake timeout")
+
+	wg.transfer("peer", 100)
+	watchdog.check(now.Add(staleHandshakeTimeout + time.Second))
+	assert.Equal(t, []string{"peer"}, restarts)
+}
+
+func TestHandshakeStale(t *testing.T) {
+	now := time.Now()
+	state := State{ConnStatusUpdate: now.Add(-time.Hour)}
+
+	assert.False(t, handshakeStale(iface.WGStats{LastHandshake: now.Add(-time.Minute)}, state, true, now))
+	assert.True(t, handshakeStale(iface.WGStats{LastHandshake: now.Add(-time.Hour)}, state, true, now))
+	assert.False(t, handshakeStale(iface.WGStats{LastHandshake: now.Add(-time.Hour)}, state, false, now),
+		"a peer without traffic shouldn't be stale")
+	assert.True(t, handshakeStale(iface.WGStats{}, state, true, now), "a peer without handshake should be stale")
+}
+
+func TestConn_RestartNotConnected(t *testing.T) {
+	conn := &Conn{status: StatusConnecting}
+	assert.False(t, conn.Restart(), "a connection not established shouldn't be restarted")
+}
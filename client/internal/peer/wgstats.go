@@ -0,0 +1,135 @@
+package peer
+
+import (
+	"github.com/FlintyLemming/netbird/client/internal/usage"
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+// SetWgStatsGetter sets the function reading the WireGuard statistics of the peers by peer key, nil when the engine
+// is stopped
+func (d *Status) SetWgStatsGetter(getter func() (map[string]iface.WGStats, error)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.wgStatsGetter = getter
+}
+
+// RefreshWgStats updates the last handshake and the transferred bytes of the peers from the WireGuard interface. It
+// is a no-op when the engine is stopped
+func (d *Status) RefreshWgStats() error {
+	d.mux.Lock()
+	getter := d.wgStatsGetter
+	d.mux.Unlock()
+
+	if getter == nil {
+		return nil
+	}
+
+	// the statistics are read without the lock, reading them from the kernel can take a while
+	allStats, err := getter()
+	if err != nil {
+		return err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for peerKey, state := range d.peers {
+		stats, ok := allStats[peerKey]
+		if !ok {
+			continue
+		}
+
+		baseline := d.wgStatsBaseline[peerKey]
+		// the counters restart from zero when the WireGuard peer is recreated on reconnection
+		if stats.RxBytes < baseline.RxBytes || stats.TxBytes < baseline.TxBytes {
+			baseline = iface.WGStats{}
+			delete(d.wgStatsBaseline, peerKey)
+		}
+
+		state.LastWireguardHandshake = stats.LastHandshake
+		state.BytesRx = stats.RxBytes - baseline.RxBytes
+		state.BytesTx = stats.TxBytes - baseline.TxBytes
+		d.peers[peerKey] = state
+	}
+	return nil
+}
+
+// ResetWgStats restarts the count of the bytes transferred with the peers from their current WireGuard counters
+func (d *Status) ResetWgStats() error {
+	d.mux.Lock()
+	getter := d.wgStatsGetter
+	d.mux.Unlock()
+
+	if getter == nil {
+		return nil
+	}
+
+	allStats, err := getter()
+	if err != nil {
+		return err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.wgStatsBaseline = make(map[string]iface.WGStats, len(allStats))
+	for peerKey, stats := range allStats {
+		d.wgStatsBaseline[peerKey] = stats
+
+		if state, ok := d.peers[peerKey]; ok {
+			state.BytesRx = 0
+			state.BytesTx = 0
+			d.peers[peerKey] = state
+		}
+	}
+	return nil
+}
+
+// SetUsageTracker sets the tracker accounting the traffic with the peers
+func (d *Status) SetUsageTracker(tracker *usage.Tracker) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.usage = tracker
+}
+
+// GetUsageTracker returns the tracker accounting the traffic with the peers, nil when none is set
+func (d *Status) GetUsageTracker() *usage.Tracker {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	return d.usage
+}
+
+// SampleUsage adds the traffic with the peers since the previous sample to the usage tracker. It is a no-op when the
+// engine is stopped or no tracker is set
+func (d *Status) SampleUsage() error {
+	d.mux.Lock()
+	getter := d.wgStatsGetter
+	tracker := d.usage
+	d.mux.Unlock()
+
+	if getter == nil || tracker == nil {
+		return nil
+	}
+
+	allStats, err := getter()
+	if err != nil {
+		return err
+	}
+
+	counters := make(map[string]usage.Counters, len(allStats))
+	fqdns := make(map[string]string, len(allStats))
+	d.mux.Lock()
+	for peerKey, stats := range allStats {
+		counters[peerKey] = usage.Counters{RxBytes: stats.RxBytes, TxBytes: stats.TxBytes}
+		if state, ok := d.peers[peerKey]; ok {
+			fqdns[peerKey] = state.FQDN
+		}
+	}
+	d.mux.Unlock()
+
+	tracker.Update(counters, fqdns)
+	return nil
+}
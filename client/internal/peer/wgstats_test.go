@@ -0,0 +1,53 @@
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func TestStatus_WgStats(t *testing.T) {
+	status := NewRecorder("https://mgm")
+	require.NoError(t, status.AddPeer("abc", "abc.netbird"))
+
+	require.NoError(t, status.RefreshWgStats(), "refreshing should be a no-op without getter")
+
+	handshake := time.Now().Add(-time.Minute)
+	allStats := map[string]iface.WGStats{
+		"abc": {LastHandshake: handshake, RxBytes: 100, TxBytes: 200},
+	}
+	status.SetWgStatsGetter(func() (map[string]iface.WGStats, error) {
+		return allStats, nil
+	})
+
+	require.NoError(t, status.RefreshWgStats())
+	state, err := status.GetPeer("abc")
+	require.NoError(t, err)
+	assert.Equal(t, handshake, state.LastWireguardHandshake)
+	assert.Equal(t, int64(100), state.BytesRx)
+	assert.Equal(t, int64(200), state.BytesTx)
+
+	require.NoError(t, status.ResetWgStats())
+	state, err = status.GetPeer("abc")
+	require.NoError(t, err)
+	assert.Zero(t, state.BytesRx, "reset should clear the counters")
+	assert.Equal(t, handshake, state.LastWireguardHandshake, "reset should keep the handshake")
+
+	allStats["abc"] = iface.WGStats{LastHandshake: handshake, RxBytes: 150, TxBytes: 260}
+	require.NoError(t, status.RefreshWgStats())
+	state, err = status.GetPeer("abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), state.BytesRx, "the bytes should be counted since the reset")
+	assert.Equal(t, int64(60), state.BytesTx, "the bytes should be counted since the reset")
+
+	allStats["abc"] = iface.WGStats{LastHandshake: handshake, RxBytes: 10, TxBytes: 20}
+	require.NoError(t, status.RefreshWgStats())
+	state, err = status.GetPeer("abc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), state.BytesRx, "restarted counters should be counted from zero")
+	assert.Equal(t, int64(20), state.BytesTx, "restarted counters should be counted from zero")
+}
@@ -0,0 +1,98 @@
+package postquantum
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/kyber/kyber768"
+	"golang.org/x/crypto/hkdf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Capability is advertised by peers able to negotiate a post-quantum pre-shared key
+const Capability = "post-quantum"
+
+const pskInfo = "netbird-post-quantum-psk-v1"
+
+var scheme = kyber768.Scheme()
+
+// KeyPair is an ephemeral KEM key pair used for a single connection attempt with a remote peer
+type KeyPair struct {
+	private kem.PrivateKey
+	public  []byte
+}
+
+// GenerateKeyPair creates a new ephemeral KEM key pair
+func GenerateKeyPair() (*KeyPair, error) {
+	pub, priv, err := scheme.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := pub.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{private: priv, public: public}, nil
+}
+
+// PublicKey returns the packed public key to be sent to the remote peer
+func (k *KeyPair) PublicKey() []byte {
+	return k.public
+}
+
+// PublicKeyHash returns a hash of the public key that identifies the key pair a ciphertext was encapsulated to
+func (k *KeyPair) PublicKeyHash() []byte {
+	return PublicKeyHash(k.public)
+}
+
+// Decapsulate returns the shared secret encapsulated in the ciphertext
+func (k *KeyPair) Decapsulate(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) != scheme.CiphertextSize() {
+		return nil, fmt.Errorf("invalid ciphertext size %d", len(ciphertext))
+	}
+	return scheme.Decapsulate(k.private, ciphertext)
+}
+
+// PublicKeyHash returns a hash of the packed public key
+func PublicKeyHash(publicKey []byte) []byte {
+	hash := sha256.Sum256(publicKey)
+	return hash[:]
+}
+
+// Encapsulate generates a shared secret for the remote public key and returns it together with the ciphertext
+// that has to be sent to the remote peer
+func Encapsulate(remotePublicKey []byte) (ciphertext, secret []byte, err error) {
+	pub, err := scheme.UnmarshalBinaryPublicKey(remotePublicKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	return scheme.Encapsulate(pub)
+}
+
+// DerivePSK derives a WireGuard pre-shared key from the KEM shared secret.
+// The keys of both peers are mixed in so that the result is bound to the pair, and the static pre-shared key,
+// when configured, is used as salt so the derived key is never weaker than the static one.
+func DerivePSK(secret []byte, localKey, remoteKey string, staticPSK *wgtypes.Key) (*wgtypes.Key, error) {
+	var salt []byte
+	if staticPSK != nil {
+		salt = staticPSK[:]
+	}
+
+	// order the keys so both sides derive the same value
+	first, second := localKey, remoteKey
+	if first > second {
+		first, second = second, first
+	}
+	info := []byte(pskInfo + first + second)
+
+	var psk wgtypes.Key
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), psk[:])
+	if err != nil {
+		return nil, err
+	}
+	return &psk, nil
+}
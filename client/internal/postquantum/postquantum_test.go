@@ -0,0 +1,54 @@
+package postquantum
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDerivePSK(t *testing.T) {
+	keyPair, err := GenerateKeyPair()
+	require.NoError(t, err)
+
+	ciphertext, secret, err := Encapsulate(keyPair.PublicKey())
+	require.NoError(t, err)
+
+	decapsulated, err := keyPair.Decapsulate(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, secret, decapsulated)
+
+	staticPSK, err := wgtypes.GenerateKey()
+	require.NoError(t, err)
+
+	localPSK, err := DerivePSK(secret, "peerA", "peerB", &staticPSK)
+	require.NoError(t, err)
+	remotePSK, err := DerivePSK(decapsulated, "peerB", "peerA", &staticPSK)
+	require.NoError(t, err)
+	assert.Equal(t, localPSK, remotePSK, "both peers should derive the same pre-shared key")
+
+	withoutStatic, err := DerivePSK(secret, "peerA", "peerB", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, localPSK, withoutStatic, "static pre-shared key should be mixed into the derived key")
+}
+
+func TestDecapsulateWithOtherKeyPair(t *testing.T) {
+	keyPair, err := GenerateKeyPair()
+	require.NoError(t, err)
+	otherKeyPair, err := GenerateKeyPair()
+	require.NoError(t, err)
+
+	ciphertext, secret, err := Encapsulate(keyPair.PublicKey())
+	require.NoError(t, err)
+
+	decapsulated, err := otherKeyPair.Decapsulate(ciphertext)
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, decapsulated)
+
+	_, err = keyPair.Decapsulate(ciphertext[1:])
+	assert.Error(t, err, "should reject truncated ciphertext")
+
+	_, _, err = Encapsulate([]byte("invalid"))
+	assert.Error(t, err, "should reject invalid public key")
+}
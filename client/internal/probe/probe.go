@@ -0,0 +1,83 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/FlintyLemming/netbird/client/internal/latency"
+)
+
+// DefaultTimeout is the time to wait for the answer of a probe
+const DefaultTimeout = 3 * time.Second
+
+// Protocol is the protocol of a probe
+type Protocol string
+
+const (
+	// ProtocolICMP probes a peer with an ICMP echo request
+	ProtocolICMP Protocol = "icmp"
+	// ProtocolTCP probes a port of a peer with a TCP handshake
+	ProtocolTCP Protocol = "tcp"
+)
+
+// ParseProtocol parses the protocol of a probe, an empty value is ICMP
+func ParseProtocol(value string) (Protocol, error) {
+	switch protocol := Protocol(strings.ToLower(value)); protocol {
+	case "", ProtocolICMP:
+		return ProtocolICMP, nil
+	case ProtocolTCP:
+		return protocol, nil
+	default:
+		return "", fmt.Errorf("unsupported protocol %s, expected icmp or tcp", value)
+	}
+}
+
+// Probe checks that the address answers over the tunnel and returns the round trip time. An ICMP probe waits for the
+// echo reply, a TCP probe for the completion of the handshake with the port
+func Probe(ctx context.Context, ip netip.Addr, protocol Protocol, port int, timeout time.Duration) (time.Duration, error) {
+	switch protocol {
+	case ProtocolICMP:
+		return probeICMP(ctx, ip, timeout)
+	case ProtocolTCP:
+		if port < 1 || port > 65535 {
+			return 0, fmt.Errorf("invalid port %d, expected a number between 1 and 65535", port)
+		}
+		return probeTCP(ctx, netip.AddrPortFrom(ip, uint16(port)), timeout)
+	default:
+		return 0, fmt.Errorf("unsupported protocol %s", protocol)
+	}
+}
+
+func probeICMP(ctx context.Context, ip netip.Addr, timeout time.Duration) (time.Duration, error) {
+	rtts, err := latency.Ping(ctx, ip, 1, timeout)
+	if err != nil {
+		return 0, err
+	}
+	if len(rtts) == 0 {
+		return 0, fmt.Errorf("no echo reply from %s within %s", ip, timeout)
+	}
+	return rtts[0], nil
+}
+
+func probeTCP(ctx context.Context, addr netip.AddrPort, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr.String())
+	if errors.Is(err, context.DeadlineExceeded) {
+		return 0, fmt.Errorf("no answer from %s within %s", addr, timeout)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	rtt := time.Since(start)
+	_ = conn.Close()
+	return rtt, nil
+}
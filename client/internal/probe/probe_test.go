@@ -0,0 +1,45 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProtocol(t *testing.T) {
+	protocol, err := ParseProtocol("")
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolICMP, protocol)
+
+	protocol, err = ParseProtocol("TCP")
+	require.NoError(t, err)
+	assert.Equal(t, ProtocolTCP, protocol)
+
+	_, err = ParseProtocol("udp")
+	assert.Error(t, err)
+}
+
+func TestProbe_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	rtt, err := Probe(context.Background(), netip.MustParseAddr("127.0.0.1"), ProtocolTCP, port, time.Second)
+	require.NoError(t, err)
+	assert.Greater(t, rtt, time.Duration(0))
+
+	// a closed port fails the probe
+	require.NoError(t, listener.Close())
+	_, err = Probe(context.Background(), netip.MustParseAddr("127.0.0.1"), ProtocolTCP, port, time.Second)
+	assert.Error(t, err)
+}
+
+func TestProbe_TCPInvalidPort(t *testing.T) {
+	_, err := Probe(context.Background(), netip.MustParseAddr("127.0.0.1"), ProtocolTCP, 0, time.Second)
+	assert.Error(t, err)
+}
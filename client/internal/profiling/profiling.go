@@ -0,0 +1,136 @@
+// Package profiling serves the pprof and expvar endpoints of the daemon on a loopback address and samples the CPU
+// consumed by the process, so that the profiles of a daemon using too much CPU or memory can be captured safely
+package profiling
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultAddress is the address of the endpoints when none is requested
+const DefaultAddress = "127.0.0.1:6060"
+
+// shutdownTimeout limits the time given to the running requests, e.g. a CPU profile, when the endpoints are disabled
+const shutdownTimeout = 5 * time.Second
+
+// Server serves the pprof and expvar endpoints until it is disabled. It is disabled by default
+type Server struct {
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+}
+
+// NewServer returns a disabled Server
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Enable serves the endpoints on the loopback address and returns the address listened on. The endpoints already
+// served on another address are moved to the new one
+func (s *Server) Enable(address string) (string, error) {
+	if address == "" {
+		address = DefaultAddress
+	}
+	if err := checkLoopback(address); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil {
+		if s.listener.Addr().String() == address {
+			return address, nil
+		}
+		s.stop()
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("listen on %s: %w", address, err)
+	}
+
+	s.listener = listener
+	s.server = &http.Server{
+		Handler:           newMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnf("profiling endpoints stopped: %v", err)
+		}
+	}(s.server)
+
+	log.Infof("profiling endpoints enabled on http://%s/debug/pprof/", listener.Addr())
+	return listener.Addr().String(), nil
+}
+
+// Disable stops serving the endpoints
+func (s *Server) Disable() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return
+	}
+	s.stop()
+	log.Infof("profiling endpoints disabled")
+}
+
+// Address returns the address of the endpoints, empty when they are disabled
+func (s *Server) Address() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+func (s *Server) stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Debugf("failed shutting down the profiling endpoints: %v", err)
+		_ = s.server.Close()
+	}
+	s.server = nil
+	s.listener = nil
+}
+
+// newMux registers the endpoints on their own mux, the default one may hold handlers of the dependencies
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// checkLoopback rejects the addresses reachable from other hosts, the profiles expose the memory of the daemon
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("the profiling endpoints can only listen on a loopback address, got %s", host)
+}
@@ -0,0 +1,55 @@
+package profiling
+
+import (
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_EnableDisable(t *testing.T) {
+	s := NewServer()
+	assert.Empty(t, s.Address(), "the endpoints should be disabled by default")
+
+	_, err := s.Enable("0.0.0.0:0")
+	assert.Error(t, err, "a non loopback address should be rejected")
+	_, err = s.Enable("192.0.2.1:6060")
+	assert.Error(t, err, "a non loopback address should be rejected")
+
+	address, err := s.Enable("127.0.0.1:0")
+	require.NoError(t, err)
+	defer s.Disable()
+	assert.Equal(t, address, s.Address())
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars"} {
+		resp, err := http.Get("http://" + address + path)
+		require.NoError(t, err, path)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+
+	s.Disable()
+	assert.Empty(t, s.Address())
+	_, err = http.Get("http://" + address + "/debug/vars")
+	assert.Error(t, err, "the endpoints should not be served once disabled")
+}
+
+func TestSampler_Sample(t *testing.T) {
+	s := NewSampler()
+	assert.True(t, s.Latest().SampledAt.IsZero())
+
+	// burn some CPU so that the sample isn't empty
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+
+	usage, err := s.Sample()
+	require.NoError(t, err)
+	assert.Greater(t, usage.CPUPercent, 0.0)
+	assert.Positive(t, usage.Goroutines)
+	assert.Equal(t, usage, s.Latest())
+}
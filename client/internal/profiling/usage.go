@@ -0,0 +1,66 @@
+package profiling
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultSampleInterval is the interval between the samples of the resources used by the process
+const DefaultSampleInterval = 15 * time.Second
+
+// Usage is the resources used by the process over the last sample interval
+type Usage struct {
+	// CPUPercent is the CPU time consumed over the interval relative to the interval, 100 is one full core
+	CPUPercent float64
+	// Goroutines is the number of goroutines at the time of the sample
+	Goroutines int
+	// SampledAt is the time of the sample, zero before the first one
+	SampledAt time.Time
+}
+
+// Sampler measures the resources used by the process between its samples
+type Sampler struct {
+	mu       sync.Mutex
+	lastCPU  time.Duration
+	lastTime time.Time
+	latest   Usage
+}
+
+// NewSampler returns a Sampler measuring from now on
+func NewSampler() *Sampler {
+	s := &Sampler{lastTime: time.Now()}
+	s.lastCPU, _ = processCPUTime()
+	return s
+}
+
+// Sample measures the resources used since the previous sample and keeps them as the latest usage
+func (s *Sampler) Sample() (Usage, error) {
+	cpu, err := processCPUTime()
+	if err != nil {
+		return Usage{}, err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := Usage{
+		Goroutines: runtime.NumGoroutine(),
+		SampledAt:  now,
+	}
+	if elapsed := now.Sub(s.lastTime); elapsed > 0 {
+		usage.CPUPercent = float64(cpu-s.lastCPU) / float64(elapsed) * 100
+	}
+	s.lastCPU = cpu
+	s.lastTime = now
+	s.latest = usage
+	return usage, nil
+}
+
+// Latest returns the usage of the latest sample
+func (s *Sampler) Latest() Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
@@ -0,0 +1,36 @@
+//go:build !android
+
+package routeimport
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// listBGPRoutes returns the networks of the main routing table installed by a BGP daemon, e.g. FRR or BIRD
+func listBGPRoutes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, family := range []int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		routes, err := netlink.RouteListFiltered(family, &netlink.Route{Protocol: unix.RTPROT_BGP}, netlink.RT_FILTER_PROTOCOL)
+		if err != nil {
+			return nil, fmt.Errorf("list routes: %w", err)
+		}
+
+		for _, route := range routes {
+			// default routes have no destination
+			if route.Dst == nil {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(route.Dst.IP)
+			if !ok {
+				continue
+			}
+			ones, _ := route.Dst.Mask.Size()
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), ones))
+		}
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux || android
+
+package routeimport
+
+import "net/netip"
+
+func listBGPRoutes() ([]netip.Prefix, error) {
+	return nil, ErrNotSupported
+}
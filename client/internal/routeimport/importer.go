@@ -0,0 +1,155 @@
+package routeimport
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultInterval is the default interval at which the routing table is checked for new or withdrawn routes
+const DefaultInterval = 30 * time.Second
+
+// ErrNotSupported is returned by the route listers of the platforms without a supported routing table
+var ErrNotSupported = errors.New("importing BGP routes is not supported on this platform")
+
+// AdvertiseFunc requests the Management service to route the networks through this peer and to remove the routes of
+// the withdrawn networks
+type AdvertiseFunc func(networks, withdrawn []string) error
+
+// ListFunc returns the networks to import
+type ListFunc func() ([]netip.Prefix, error)
+
+// Importer imports the routes learned by a BGP daemon running on the routing peer (e.g. FRR or BIRD) from the kernel
+// routing table and advertises them to the Management service, withdrawing the ones no longer learned
+type Importer struct {
+	advertise AdvertiseFunc
+	list      ListFunc
+	interval  time.Duration
+	// excluded networks are never imported, e.g. the NetBird network
+	excluded []netip.Prefix
+
+	// imported holds the networks advertised successfully
+	imported map[netip.Prefix]struct{}
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewImporter creates an Importer of the BGP routes of the kernel routing table checking for changes every interval
+func NewImporter(advertise AdvertiseFunc, interval time.Duration, excluded ...netip.Prefix) *Importer {
+	return newImporter(advertise, listBGPRoutes, interval, excluded...)
+}
+
+func newImporter(advertise AdvertiseFunc, list ListFunc, interval time.Duration, excluded ...netip.Prefix) *Importer {
+	return &Importer{
+		advertise: advertise,
+		list:      list,
+		interval:  interval,
+		excluded:  excluded,
+		imported:  make(map[netip.Prefix]struct{}),
+	}
+}
+
+// Start imports the routes every interval until Stop is called
+func (i *Importer) Start(ctx context.Context) {
+	ctx, i.cancel = context.WithCancel(ctx)
+	i.done = make(chan struct{})
+
+	go func() {
+		defer close(i.done)
+		ticker := time.NewTicker(i.interval)
+		defer ticker.Stop()
+
+		for {
+			err := i.sync()
+			if errors.Is(err, ErrNotSupported) {
+				log.Warn(err)
+				return
+			}
+			if err != nil {
+				log.Warnf("failed importing BGP routes, retrying in %s: %v", i.interval, err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops importing the routes. The routes already imported are kept
+func (i *Importer) Stop() {
+	if i.cancel != nil {
+		i.cancel()
+		<-i.done
+	}
+}
+
+// sync advertises the networks not imported yet and withdraws the imported ones no longer in the routing table
+func (i *Importer) sync() error {
+	prefixes, err := i.list()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[netip.Prefix]struct{}, len(prefixes))
+	var networks []string
+	for _, prefix := range prefixes {
+		prefix = prefix.Masked()
+		if !i.importable(prefix) {
+			continue
+		}
+		if _, ok := current[prefix]; ok {
+			continue
+		}
+		current[prefix] = struct{}{}
+		if _, ok := i.imported[prefix]; !ok {
+			networks = append(networks, prefix.String())
+		}
+	}
+
+	var withdrawn []string
+	for prefix := range i.imported {
+		if _, ok := current[prefix]; !ok {
+			withdrawn = append(withdrawn, prefix.String())
+		}
+	}
+
+	if len(networks) == 0 && len(withdrawn) == 0 {
+		return nil
+	}
+
+	sort.Strings(networks)
+	sort.Strings(withdrawn)
+	if err := i.advertise(networks, withdrawn); err != nil {
+		return err
+	}
+
+	if len(networks) > 0 {
+		log.Infof("imported BGP routes %v", networks)
+	}
+	if len(withdrawn) > 0 {
+		log.Infof("withdrew BGP routes %v", withdrawn)
+	}
+	i.imported = current
+	return nil
+}
+
+// importable returns false for default routes and networks overlapping the excluded ones
+func (i *Importer) importable(prefix netip.Prefix) bool {
+	if prefix.Bits() == 0 {
+		return false
+	}
+	for _, excluded := range i.excluded {
+		if excluded.Overlaps(prefix) {
+			return false
+		}
+	}
+	return true
+}
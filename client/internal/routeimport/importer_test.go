@@ -0,0 +1,53 @@
+package routeimport
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImporter_Sync(t *testing.T) {
+	var routes []netip.Prefix
+	list := func() ([]netip.Prefix, error) {
+		return routes, nil
+	}
+
+	var advertised, withdrawn []string
+	var advertiseErr error
+	advertise := func(networks, withdrawnNetworks []string) error {
+		advertised, withdrawn = networks, withdrawnNetworks
+		return advertiseErr
+	}
+
+	importer := newImporter(advertise, list, time.Minute, netip.MustParsePrefix("100.64.0.0/10"))
+
+	routes = []netip.Prefix{
+		netip.MustParsePrefix("10.2.0.0/16"),
+		netip.MustParsePrefix("10.1.1.1/24"),
+		netip.MustParsePrefix("0.0.0.0/0"),
+		netip.MustParsePrefix("100.64.1.0/24"),
+	}
+	require.NoError(t, importer.sync())
+	assert.Equal(t, []string{"10.1.1.0/24", "10.2.0.0/16"}, advertised, "default routes and excluded networks shouldn't be imported")
+	assert.Empty(t, withdrawn)
+
+	advertised, withdrawn = nil, nil
+	require.NoError(t, importer.sync())
+	assert.Nil(t, advertised, "nothing should be advertised without changes")
+
+	routes = []netip.Prefix{
+		netip.MustParsePrefix("10.2.0.0/16"),
+		netip.MustParsePrefix("10.3.0.0/16"),
+	}
+	advertiseErr = errors.New("management unavailable")
+	require.Error(t, importer.sync())
+
+	advertiseErr = nil
+	require.NoError(t, importer.sync())
+	assert.Equal(t, []string{"10.3.0.0/16"}, advertised)
+	assert.Equal(t, []string{"10.1.1.0/24"}, withdrawn, "changes should be retried after a failure")
+}
@@ -0,0 +1,95 @@
+package routemanager
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+type activeRoute struct {
+	owner *clientNetwork
+	route *route.Route
+}
+
+// activeRoutes tracks the route chosen by every client network among the routes of its HA set
+type activeRoutes struct {
+	mu       sync.Mutex
+	routes   map[string]activeRoute
+	listener func()
+	// statusRecorder shows the chosen routes in the status, it can be nil
+	statusRecorder *peer.Status
+}
+
+func newActiveRoutes(statusRecorder *peer.Status) *activeRoutes {
+	return &activeRoutes{
+		routes:         make(map[string]activeRoute),
+		statusRecorder: statusRecorder,
+	}
+}
+
+func (a *activeRoutes) setListener(listener func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.listener = listener
+}
+
+// set records the route chosen by the client network of the HA set, a nil route removes it. Only the client network
+// that recorded a route can remove it, a stopped client network can't remove the route of its replacement.
+func (a *activeRoutes) set(haID string, owner *clientNetwork, r *route.Route) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	current, found := a.routes[haID]
+	switch {
+	case r == nil && (!found || current.owner != owner):
+		return
+	case r == nil:
+		delete(a.routes, haID)
+		a.updateStatus(haID, owner, nil)
+	case found && current.route.ID == r.ID && current.route.Peer == r.Peer:
+		a.routes[haID] = activeRoute{owner: owner, route: r}
+		a.updateStatus(haID, owner, r)
+		return
+	default:
+		a.routes[haID] = activeRoute{owner: owner, route: r}
+		a.updateStatus(haID, owner, r)
+	}
+
+	if a.listener != nil {
+		go a.listener()
+	}
+}
+
+func (a *activeRoutes) updateStatus(haID string, owner *clientNetwork, r *route.Route) {
+	if a.statusRecorder == nil {
+		return
+	}
+	if r == nil {
+		a.statusRecorder.RemoveRouteState(haID)
+		return
+	}
+	a.statusRecorder.UpdateRouteState(haID, peer.RouteState{
+		NetID:        string(r.NetID),
+		Network:      r.Network,
+		Peer:         r.Peer,
+		LoadBalanced: owner.loadBalancingEnabled(),
+	})
+}
+
+// list returns the chosen routes sorted by ID
+func (a *activeRoutes) list() []*route.Route {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	routes := make([]*route.Route, 0, len(a.routes))
+	for _, active := range a.routes {
+		routes = append(routes, active.route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].ID < routes[j].ID
+	})
+	return routes
+}
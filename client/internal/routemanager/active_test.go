@@ -0,0 +1,43 @@
+package routemanager
+
+import (
+	"testing"
+
+	"github.com/FlintyLemming/netbird/route"
+)
+
+func TestActiveRoutes(t *testing.T) {
+	active := newActiveRoutes(nil)
+	notified := make(chan struct{}, 10)
+	active.setListener(func() {
+		notified <- struct{}{}
+	})
+
+	oldNetwork := &clientNetwork{}
+	newNetwork := &clientNetwork{}
+
+	active.set("net1", oldNetwork, &route.Route{ID: "route1", Peer: "peer1"})
+	<-notified
+
+	active.set("net1", newNetwork, &route.Route{ID: "route2", Peer: "peer2"})
+	<-notified
+
+	// the client network replaced by the new one stops after it
+	active.set("net1", oldNetwork, nil)
+
+	routes := active.list()
+	if len(routes) != 1 || routes[0].ID != "route2" {
+		t.Fatalf("expected the route of the new client network to be active, got %v", routes)
+	}
+
+	active.set("net1", newNetwork, nil)
+	<-notified
+
+	if routes := active.list(); len(routes) != 0 {
+		t.Errorf("expected no active routes, got %v", routes)
+	}
+
+	if len(notified) != 0 {
+		t.Errorf("expected no notification when a stopped client network doesn't own the route")
+	}
+}
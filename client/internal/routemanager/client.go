@@ -3,12 +3,13 @@ package routemanager
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"net/netip"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
-	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/route"
 )
 
@@ -29,7 +30,7 @@ type clientNetwork struct {
 	ctx                 context.Context
 	stop                context.CancelFunc
 	statusRecorder      *peer.Status
-	wgInterface         *iface.WGIface
+	systemOps           SystemOps
 	routes              map[string]*route.Route
 	routeUpdate         chan routesUpdate
 	peerStateUpdate     chan struct{}
@@ -37,20 +38,28 @@ type clientNetwork struct {
 	chosenRoute         *route.Route
 	network             netip.Prefix
 	updateSerial        uint64
+	// haID is the HA unique ID shared by the routes of the network
+	haID         string
+	activeRoutes *activeRoutes
+	// localPeerKey assigns this peer to a routing peer when the routes of the network are load balanced
+	localPeerKey string
 }
 
-func newClientNetworkWatcher(ctx context.Context, wgInterface *iface.WGIface, statusRecorder *peer.Status, network netip.Prefix) *clientNetwork {
+func newClientNetworkWatcher(ctx context.Context, systemOps SystemOps, statusRecorder *peer.Status, network netip.Prefix, haID string, activeRoutes *activeRoutes, localPeerKey string) *clientNetwork {
 	ctx, cancel := context.WithCancel(ctx)
 	client := &clientNetwork{
 		ctx:                 ctx,
 		stop:                cancel,
 		statusRecorder:      statusRecorder,
-		wgInterface:         wgInterface,
+		systemOps:           systemOps,
 		routes:              make(map[string]*route.Route),
 		routePeersNotifiers: make(map[string]chan struct{}),
 		routeUpdate:         make(chan routesUpdate),
 		peerStateUpdate:     make(chan struct{}),
 		network:             network,
+		haID:                haID,
+		activeRoutes:        activeRoutes,
+		localPeerKey:        localPeerKey,
 	}
 	return client
 }
@@ -73,14 +82,50 @@ func (c *clientNetwork) getRouterPeerStatuses() map[string]routerPeerStatus {
 }
 
 func (c *clientNetwork) getBestRouteFromStatuses(routePeerStatuses map[string]routerPeerStatus) string {
-	chosen := ""
-	chosenScore := 0
-
 	currID := ""
 	if c.chosenRoute != nil {
 		currID = c.chosenRoute.ID
 	}
 
+	if currID != "" && !c.preemptionEnabled() {
+		_, found := c.routes[currID]
+		if peerStatus := routePeerStatuses[currID]; found && peerStatus.connected {
+			return currID
+		}
+	}
+
+	var chosen string
+	if c.loadBalancingEnabled() {
+		chosen = c.getBalancedRoute(routePeerStatuses)
+		if chosen != "" && chosen != currID {
+			log.Infof("new chosen route is %s with peer %s balanced over the connected routing peers of network %s", chosen, c.routes[chosen].Peer, c.network)
+		}
+	} else {
+		var chosenScore int
+		chosen, chosenScore = c.getScoredRoute(routePeerStatuses, currID)
+		if chosen != "" && chosen != currID {
+			log.Infof("new chosen route is %s with peer %s with score %d for network %s", chosen, c.routes[chosen].Peer, chosenScore, c.network)
+		}
+	}
+
+	if chosen == "" {
+		var peers []string
+		for _, r := range c.routes {
+			peers = append(peers, r.Peer)
+		}
+
+		log.Warnf("the network %s has not been assigned a routing peer as no peers from the list %s are currently connected", c.network, peers)
+	}
+
+	return chosen
+}
+
+// getScoredRoute returns the route of the connected routing peer with the highest score, computed from the metric of
+// the route and the connection type of the routing peer, and the score
+func (c *clientNetwork) getScoredRoute(routePeerStatuses map[string]routerPeerStatus, currID string) (string, int) {
+	chosen := ""
+	chosenScore := 0
+
 	for _, r := range c.routes {
 		tempScore := 0
 		peerStatus, found := routePeerStatuses[r.ID]
@@ -112,21 +157,66 @@ func (c *clientNetwork) getBestRouteFromStatuses(routePeerStatuses map[string]ro
 		}
 	}
 
-	if chosen == "" {
-		var peers []string
-		for _, r := range c.routes {
-			peers = append(peers, r.Peer)
-		}
+	return chosen, chosenScore
+}
 
-		log.Warnf("the network %s has not been assigned a routing peer as no peers from the list %s are currently connected", c.network, peers)
+// getBalancedRoute assigns this peer to one of the connected routing peers with the lowest route metric by rendezvous
+// hashing: the route whose routing peer key has the highest hash combined with the local peer key wins. The peers of a
+// network are spread evenly over its routing peers, every peer keeps its routing peer while it is connected and only
+// the peers of a disconnected routing peer are reassigned, over the remaining ones
+func (c *clientNetwork) getBalancedRoute(routePeerStatuses map[string]routerPeerStatus) string {
+	chosen := ""
+	var chosenMetric int
+	var chosenHash uint64
 
-	} else if chosen != currID {
-		log.Infof("new chosen route is %s with peer %s with score %d for network %s", chosen, c.routes[chosen].Peer, chosenScore, c.network)
+	for _, r := range c.routes {
+		peerStatus, found := routePeerStatuses[r.ID]
+		if !found || !peerStatus.connected {
+			continue
+		}
+
+		hash := rendezvousHash(c.localPeerKey, r.Peer)
+		if chosen == "" || r.Metric < chosenMetric || (r.Metric == chosenMetric && hash > chosenHash) ||
+			(r.Metric == chosenMetric && hash == chosenHash && r.ID < chosen) {
+			chosen = r.ID
+			chosenMetric = r.Metric
+			chosenHash = hash
+		}
 	}
 
 	return chosen
 }
 
+// rendezvousHash returns the weight of the routing peer for the local peer
+func rendezvousHash(localPeerKey, routingPeerKey string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(localPeerKey))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(routingPeerKey))
+	return h.Sum64()
+}
+
+// loadBalancingEnabled returns true if any route of the network enables load balancing
+func (c *clientNetwork) loadBalancingEnabled() bool {
+	for _, r := range c.routes {
+		if r.LoadBalance {
+			return true
+		}
+	}
+	return false
+}
+
+// preemptionEnabled returns false if any route of the network disables preemption, the chosen route is then kept
+// while its routing peer is connected even if a route with a lower metric becomes available
+func (c *clientNetwork) preemptionEnabled() bool {
+	for _, r := range c.routes {
+		if r.DisablePreemption {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *clientNetwork) watchPeerStatusChanges(ctx context.Context, peerKey string, peerStateUpdate chan struct{}, closer chan struct{}) {
 	for {
 		select {
@@ -164,7 +254,7 @@ func (c *clientNetwork) removeRouteFromWireguardPeer(peerKey string) error {
 		return nil
 	}
 
-	err = c.wgInterface.RemoveAllowedIP(peerKey, c.network.String())
+	err = c.systemOps.RemoveAllowedIP(peerKey, c.network.String())
 	if err != nil {
 		return fmt.Errorf("couldn't remove allowed IP %s removed for peer %s, err: %v",
 			c.network, c.chosenRoute.Peer, err)
@@ -178,7 +268,7 @@ func (c *clientNetwork) removeRouteFromPeerAndSystem() error {
 		if err != nil {
 			return err
 		}
-		err = removeFromRouteTableIfNonSystem(c.network, c.wgInterface.Address().IP.String())
+		err = c.systemOps.RemoveRoute(c.network)
 		if err != nil {
 			return fmt.Errorf("couldn't remove route %s from system, err: %v",
 				c.network, err)
@@ -200,7 +290,11 @@ func (c *clientNetwork) recalculateRouteAndUpdatePeerAndSystem() error {
 			return err
 		}
 
+		if c.chosenRoute != nil {
+			c.statusRecorder.RecordEvent(eventlog.CategoryRoute, fmt.Sprintf("route to %s removed, no routing peer available", c.network))
+		}
 		c.chosenRoute = nil
+		c.activeRoutes.set(c.haID, c, nil)
 
 		return nil
 	}
@@ -217,15 +311,19 @@ func (c *clientNetwork) recalculateRouteAndUpdatePeerAndSystem() error {
 			return err
 		}
 	} else {
-		err = addToRouteTableIfNoExists(c.network, c.wgInterface.Address().IP.String())
+		err = c.systemOps.AddRoute(c.network)
 		if err != nil {
-			return fmt.Errorf("route %s couldn't be added for peer %s, err: %v",
-				c.network.String(), c.wgInterface.Address().IP.String(), err)
+			return fmt.Errorf("route %s couldn't be added, err: %v", c.network.String(), err)
 		}
 	}
 
+	if c.chosenRoute == nil || c.chosenRoute.Peer != c.routes[chosen].Peer {
+		c.statusRecorder.RecordEvent(eventlog.CategoryRoute, fmt.Sprintf("route to %s via peer %s", c.network, c.peerName(c.routes[chosen].Peer)))
+	}
+
 	c.chosenRoute = c.routes[chosen]
-	err = c.wgInterface.AddAllowedIP(c.chosenRoute.Peer, c.network.String())
+	c.activeRoutes.set(c.haID, c, c.chosenRoute)
+	err = c.systemOps.AddAllowedIP(c.chosenRoute.Peer, c.network.String())
 	if err != nil {
 		log.Errorf("couldn't add allowed IP %s added for peer %s, err: %v",
 			c.network, c.chosenRoute.Peer, err)
@@ -234,6 +332,15 @@ func (c *clientNetwork) recalculateRouteAndUpdatePeerAndSystem() error {
 	return nil
 }
 
+// peerName returns the FQDN of the peer, or its key when it is unknown
+func (c *clientNetwork) peerName(peerKey string) string {
+	state, err := c.statusRecorder.GetPeer(peerKey)
+	if err != nil || state.FQDN == "" {
+		return peerKey
+	}
+	return state.FQDN
+}
+
 func (c *clientNetwork) sendUpdateToClientNetworkWatcher(update routesUpdate) {
 	go func() {
 		c.routeUpdate <- update
@@ -268,7 +375,10 @@ func (c *clientNetwork) peersStateAndUpdateWatcher() {
 			err := c.removeRouteFromPeerAndSystem()
 			if err != nil {
 				log.Error(err)
+			} else if c.chosenRoute != nil {
+				c.statusRecorder.RecordEvent(eventlog.CategoryRoute, fmt.Sprintf("route to %s removed", c.network))
 			}
+			c.activeRoutes.set(c.haID, c, nil)
 			return
 		case <-c.peerStateUpdate:
 			err := c.recalculateRouteAndUpdatePeerAndSystem()
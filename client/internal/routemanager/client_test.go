@@ -1,6 +1,7 @@
 package routemanager
 
 import (
+	"fmt"
 	"net/netip"
 	"testing"
 
@@ -179,6 +180,106 @@ func TestGetBestrouteFromStatuses(t *testing.T) {
 			currentRoute:    nil,
 			expectedRouteID: "route1",
 		},
+		{
+			name: "preemption disabled keeps the current route",
+			statuses: map[string]routerPeerStatus{
+				"route1": {
+					connected: true,
+					relayed:   false,
+					direct:    true,
+				},
+				"route2": {
+					connected: true,
+					relayed:   true,
+					direct:    false,
+				},
+			},
+			existingRoutes: map[string]*route.Route{
+				"route1": {
+					ID:                "route1",
+					Metric:            route.MinMetric,
+					Peer:              "peer1",
+					DisablePreemption: true,
+				},
+				"route2": {
+					ID:                "route2",
+					Metric:            route.MaxMetric,
+					Peer:              "peer2",
+					DisablePreemption: true,
+				},
+			},
+			currentRoute: &route.Route{
+				ID:   "route2",
+				Peer: "peer2",
+			},
+			expectedRouteID: "route2",
+		},
+		{
+			name: "preemption disabled fails over when the current route is disconnected",
+			statuses: map[string]routerPeerStatus{
+				"route1": {
+					connected: true,
+					relayed:   false,
+					direct:    true,
+				},
+				"route2": {
+					connected: false,
+					relayed:   false,
+					direct:    false,
+				},
+			},
+			existingRoutes: map[string]*route.Route{
+				"route1": {
+					ID:                "route1",
+					Metric:            route.MaxMetric,
+					Peer:              "peer1",
+					DisablePreemption: true,
+				},
+				"route2": {
+					ID:                "route2",
+					Metric:            route.MinMetric,
+					Peer:              "peer2",
+					DisablePreemption: true,
+				},
+			},
+			currentRoute: &route.Route{
+				ID:   "route2",
+				Peer: "peer2",
+			},
+			expectedRouteID: "route1",
+		},
+		{
+			name: "preemption enabled fails back to the route with the lower metric",
+			statuses: map[string]routerPeerStatus{
+				"route1": {
+					connected: true,
+					relayed:   false,
+					direct:    true,
+				},
+				"route2": {
+					connected: true,
+					relayed:   false,
+					direct:    true,
+				},
+			},
+			existingRoutes: map[string]*route.Route{
+				"route1": {
+					ID:     "route1",
+					Metric: route.MinMetric,
+					Peer:   "peer1",
+				},
+				"route2": {
+					ID:     "route2",
+					Metric: route.MaxMetric,
+					Peer:   "peer2",
+				},
+			},
+			currentRoute: &route.Route{
+				ID:   "route2",
+				Peer: "peer2",
+			},
+			expectedRouteID: "route1",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -197,3 +298,68 @@ func TestGetBestrouteFromStatuses(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBalancedRoute(t *testing.T) {
+	routes := map[string]*route.Route{
+		"route1": {ID: "route1", Metric: route.MaxMetric, Peer: "peer1", LoadBalance: true},
+		"route2": {ID: "route2", Metric: route.MaxMetric, Peer: "peer2", LoadBalance: true},
+		"route3": {ID: "route3", Metric: route.MaxMetric, Peer: "peer3", LoadBalance: true},
+	}
+	allConnected := map[string]routerPeerStatus{
+		"route1": {connected: true},
+		"route2": {connected: true},
+		"route3": {connected: true},
+	}
+
+	assignments := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		client := &clientNetwork{
+			network:      netip.MustParsePrefix("0.0.0.0/0"),
+			routes:       routes,
+			localPeerKey: fmt.Sprintf("localPeer%d", i),
+		}
+
+		chosen := client.getBestRouteFromStatuses(allConnected)
+		if again := client.getBestRouteFromStatuses(allConnected); again != chosen {
+			t.Fatalf("expected the same route for peer %s, got %s and %s", client.localPeerKey, chosen, again)
+		}
+		assignments[chosen]++
+
+		// only the peers of the failed routing peer are reassigned
+		statuses := map[string]routerPeerStatus{}
+		for id, status := range allConnected {
+			statuses[id] = status
+		}
+		failed := "route1"
+		statuses[failed] = routerPeerStatus{}
+		reassigned := client.getBestRouteFromStatuses(statuses)
+		if reassigned == failed || (chosen != failed && reassigned != chosen) {
+			t.Fatalf("expected peer %s on route %s to be reassigned from %s, got %s", client.localPeerKey, chosen, failed, reassigned)
+		}
+	}
+
+	for id := range routes {
+		if assignments[id] < 50 {
+			t.Errorf("expected the peers to be spread over the routes, got %v", assignments)
+		}
+	}
+}
+
+func TestGetBalancedRoute_LowestMetric(t *testing.T) {
+	client := &clientNetwork{
+		network: netip.MustParsePrefix("0.0.0.0/0"),
+		routes: map[string]*route.Route{
+			"route1": {ID: "route1", Metric: route.MaxMetric, Peer: "peer1", LoadBalance: true},
+			"route2": {ID: "route2", Metric: route.MinMetric, Peer: "peer2", LoadBalance: true},
+		},
+		localPeerKey: "localPeer",
+	}
+
+	chosen := client.getBestRouteFromStatuses(map[string]routerPeerStatus{
+		"route1": {connected: true, direct: true},
+		"route2": {connected: true, relayed: true},
+	})
+	if chosen != "route2" {
+		t.Errorf("expected the route with the lowest metric route2, got %s", chosen)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
 	"github.com/FlintyLemming/netbird/client/internal/listener"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/client/internal/routeselector"
 	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/route"
 	"github.com/FlintyLemming/netbird/version"
@@ -21,6 +22,8 @@ type Manager interface {
 	SetRouteChangeListener(listener listener.NetworkChangeListener)
 	InitialRouteRange() []string
 	EnableServerRouter(firewall firewall.Manager) error
+	ActiveRoutes() []*route.Route
+	SetActiveRoutesListener(listener func())
 	Stop()
 }
 
@@ -33,26 +36,54 @@ type DefaultManager struct {
 	serverRouter   serverRouter
 	statusRecorder *peer.Status
 	wgInterface    *iface.WGIface
+	systemOps      SystemOps
 	pubKey         string
 	notifier       *notifier
+	routeSelector  *routeselector.RouteSelector
+	// lastClientRoutes holds the client routes of the latest update, including the deselected ones
+	lastClientRoutes map[string][]*route.Route
+	lastUpdateSerial uint64
+	activeRoutes     *activeRoutes
 }
 
-func NewManager(ctx context.Context, pubKey string, wgInterface *iface.WGIface, statusRecorder *peer.Status, initialRoutes []*route.Route) *DefaultManager {
+// NewManager creates a route manager. Routes of the networks deselected in the routeSelector are ignored,
+// a nil routeSelector accepts all of them
+func NewManager(ctx context.Context, pubKey string, wgInterface *iface.WGIface, statusRecorder *peer.Status, routeSelector *routeselector.RouteSelector, initialRoutes []*route.Route) *DefaultManager {
+	if err := cleanupStaleRoutes(); err != nil {
+		log.Errorf("failed to clean up stale routes: %v", err)
+	}
+
+	return newManager(ctx, pubKey, wgInterface, &defaultSystemOps{wgInterface: wgInterface}, statusRecorder, routeSelector, initialRoutes)
+}
+
+// newManager creates a route manager applying the routes of the client networks with the system operations
+func newManager(ctx context.Context, pubKey string, wgInterface *iface.WGIface, systemOps SystemOps, statusRecorder *peer.Status, routeSelector *routeselector.RouteSelector, initialRoutes []*route.Route) *DefaultManager {
+	if routeSelector == nil {
+		routeSelector = routeselector.New(nil)
+	}
+
 	mCTX, cancel := context.WithCancel(ctx)
 	dm := &DefaultManager{
-		ctx:            mCTX,
-		stop:           cancel,
-		clientNetworks: make(map[string]*clientNetwork),
-		statusRecorder: statusRecorder,
-		wgInterface:    wgInterface,
-		pubKey:         pubKey,
-		notifier:       newNotifier(),
+		ctx:              mCTX,
+		stop:             cancel,
+		clientNetworks:   make(map[string]*clientNetwork),
+		statusRecorder:   statusRecorder,
+		wgInterface:      wgInterface,
+		systemOps:        systemOps,
+		pubKey:           pubKey,
+		notifier:         newNotifier(),
+		routeSelector:    routeSelector,
+		lastClientRoutes: make(map[string][]*route.Route),
+		activeRoutes:     newActiveRoutes(statusRecorder),
 	}
 
 	if runtime.GOOS == "android" {
 		cr := dm.clientRoutes(initialRoutes)
 		dm.notifier.setInitialClientRoutes(cr)
 	}
+
+	updates, unsubscribe := routeSelector.Subscribe()
+	go dm.watchRouteSelection(mCTX, updates, unsubscribe)
 	return dm
 }
 
@@ -62,6 +93,9 @@ func (m *DefaultManager) EnableServerRouter(firewall firewall.Manager) error {
 	if err != nil {
 		return err
 	}
+
+	// poor forwarding throughput of Linux gateways mostly comes from the offloads of the network devices
+	tuneForwardingOffloads(m.wgInterface)
 	return nil
 }
 
@@ -86,11 +120,15 @@ func (m *DefaultManager) UpdateRoutes(updateSerial uint64, newRoutes []*route.Ro
 
 		newServerRoutesMap, newClientRoutesIDMap := m.classifiesRoutes(newRoutes)
 
-		m.updateClientNetworks(updateSerial, newClientRoutesIDMap)
-		m.notifier.onNewRoutes(newClientRoutesIDMap)
+		m.lastClientRoutes = newClientRoutesIDMap
+		m.lastUpdateSerial = updateSerial
+		selectedRoutes := m.routeSelector.FilterSelected(newClientRoutesIDMap)
+
+		m.updateClientNetworks(updateSerial, selectedRoutes)
+		m.notifier.onNewRoutes(selectedRoutes)
 
 		if m.serverRouter != nil {
-			err := m.serverRouter.updateRoutes(newServerRoutesMap)
+			err := m.serverRouter.updateRoutes(newServerRoutesMap, newClientRoutesIDMap)
 			if err != nil {
 				return err
 			}
@@ -105,11 +143,40 @@ func (m *DefaultManager) SetRouteChangeListener(listener listener.NetworkChangeL
 	m.notifier.setListener(listener)
 }
 
+// ActiveRoutes returns the routes chosen for the client networks among the routes of their HA sets
+func (m *DefaultManager) ActiveRoutes() []*route.Route {
+	return m.activeRoutes.list()
+}
+
+// SetActiveRoutesListener sets the listener called when the chosen route of a client network changes
+func (m *DefaultManager) SetActiveRoutesListener(listener func()) {
+	m.activeRoutes.setListener(listener)
+}
+
 // InitialRouteRange return the list of initial routes. It used by mobile systems
 func (m *DefaultManager) InitialRouteRange() []string {
 	return m.notifier.initialRouteRanges()
 }
 
+// watchRouteSelection applies the changes of the route selection to the client routes of the latest update
+func (m *DefaultManager) watchRouteSelection(ctx context.Context, updates <-chan struct{}, unsubscribe func()) {
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates:
+			m.mux.Lock()
+			selectedRoutes := m.routeSelector.FilterSelected(m.lastClientRoutes)
+			log.Infof("route selection changed, %d of %d networks selected", len(selectedRoutes), len(m.lastClientRoutes))
+			m.updateClientNetworks(m.lastUpdateSerial, selectedRoutes)
+			m.notifier.onNewRoutes(selectedRoutes)
+			m.mux.Unlock()
+		}
+	}
+}
+
 func (m *DefaultManager) updateClientNetworks(updateSerial uint64, networks map[string][]*route.Route) {
 	// removing routes that do not exist as per the update from the Management service.
 	for id, client := range m.clientNetworks {
@@ -124,7 +191,7 @@ func (m *DefaultManager) updateClientNetworks(updateSerial uint64, networks map[
 	for id, routes := range networks {
 		clientNetworkWatcher, found := m.clientNetworks[id]
 		if !found {
-			clientNetworkWatcher = newClientNetworkWatcher(m.ctx, m.wgInterface, m.statusRecorder, routes[0].Network)
+			clientNetworkWatcher = newClientNetworkWatcher(m.ctx, m.systemOps, m.statusRecorder, routes[0].Network, id, m.activeRoutes, m.pubKey)
 			m.clientNetworks[id] = clientNetworkWatcher
 			go clientNetworkWatcher.peersStateAndUpdateWatcher()
 		}
@@ -6,6 +6,7 @@ import (
 	"net/netip"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/pion/transport/v3/stdnet"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/client/internal/routeselector"
 	"github.com/FlintyLemming/netbird/iface"
 	"github.com/FlintyLemming/netbird/route"
 )
@@ -414,7 +416,7 @@ func TestManagerUpdateRoutes(t *testing.T) {
 
 			statusRecorder := peer.NewRecorder("https://mgm")
 			ctx := context.TODO()
-			routeManager := NewManager(ctx, localPeerKey, wgInterface, statusRecorder, nil)
+			routeManager := NewManager(ctx, localPeerKey, wgInterface, statusRecorder, nil, nil)
 			defer routeManager.Stop()
 
 			if testCase.removeSrvRouter {
@@ -438,3 +440,59 @@ func TestManagerUpdateRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestManagerRouteSelection(t *testing.T) {
+	peerPrivateKey, _ := wgtypes.GeneratePrivateKey()
+	newNet, err := stdnet.NewNet()
+	require.NoError(t, err)
+
+	wgInterface, err := iface.NewWGIFace("utun4399", "100.65.66.2/24", 33199, peerPrivateKey.String(), iface.DefaultMTU, newNet, nil)
+	require.NoError(t, err, "should create testing WGIface interface")
+	defer wgInterface.Close()
+
+	err = wgInterface.Create()
+	require.NoError(t, err, "should create testing wireguard interface")
+
+	routeSelector := routeselector.New([]string{"routeA"})
+	routeManager := NewManager(context.TODO(), localPeerKey, wgInterface, peer.NewRecorder("https://mgm"), routeSelector, nil)
+	defer routeManager.Stop()
+
+	routes := []*route.Route{
+		{
+			ID:          "a",
+			NetID:       "routeA",
+			Peer:        remotePeerKey1,
+			Network:     netip.MustParsePrefix("100.64.251.250/30"),
+			NetworkType: route.IPv4Network,
+			Metric:      9999,
+			Enabled:     true,
+		},
+		{
+			ID:          "b",
+			NetID:       "routeB",
+			Peer:        remotePeerKey1,
+			Network:     netip.MustParsePrefix("8.8.8.8/32"),
+			NetworkType: route.IPv4Network,
+			Metric:      9999,
+			Enabled:     true,
+		},
+	}
+
+	err = routeManager.UpdateRoutes(1, routes)
+	require.NoError(t, err, "should update routes")
+
+	clientNetworks := func() int {
+		routeManager.mux.Lock()
+		defer routeManager.mux.Unlock()
+		return len(routeManager.clientNetworks)
+	}
+	require.Equal(t, 1, clientNetworks(), "deselected networks should be ignored")
+
+	routeSelector.SelectRoutes([]string{"routeA"})
+	require.Eventually(t, func() bool { return clientNetworks() == 2 }, time.Second, 10*time.Millisecond,
+		"selected networks should be added")
+
+	routeSelector.DeselectRoutes([]string{"routeA", "routeB"})
+	require.Eventually(t, func() bool { return clientNetworks() == 0 }, time.Second, 10*time.Millisecond,
+		"deselected networks should be removed")
+}
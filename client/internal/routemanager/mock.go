@@ -12,12 +12,18 @@ import (
 
 // MockManager is the mock instance of a route manager
 type MockManager struct {
-	UpdateRoutesFunc func(updateSerial uint64, newRoutes []*route.Route) error
-	StopFunc         func()
+	UpdateRoutesFunc       func(updateSerial uint64, newRoutes []*route.Route) error
+	InitialRouteRangeFunc  func() []string
+	ActiveRoutesFunc       func() []*route.Route
+	EnableServerRouterFunc func(firewall firewall.Manager) error
+	StopFunc               func()
 }
 
 // InitialRouteRange mock implementation of InitialRouteRange from Manager interface
 func (m *MockManager) InitialRouteRange() []string {
+	if m.InitialRouteRangeFunc != nil {
+		return m.InitialRouteRangeFunc()
+	}
 	return nil
 }
 
@@ -38,8 +44,24 @@ func (m *MockManager) SetRouteChangeListener(listener listener.NetworkChangeList
 
 }
 
+// ActiveRoutes mock implementation of ActiveRoutes from Manager interface
+func (m *MockManager) ActiveRoutes() []*route.Route {
+	if m.ActiveRoutesFunc != nil {
+		return m.ActiveRoutesFunc()
+	}
+	return nil
+}
+
+// SetActiveRoutesListener mock implementation of SetActiveRoutesListener from Manager interface
+func (m *MockManager) SetActiveRoutesListener(listener func()) {
+}
+
+// EnableServerRouter mock implementation of EnableServerRouter from Manager interface
 func (m *MockManager) EnableServerRouter(firewall firewall.Manager) error {
-	panic("implement me")
+	if m.EnableServerRouterFunc != nil {
+		return m.EnableServerRouterFunc(firewall)
+	}
+	return fmt.Errorf("method EnableServerRouter is not implemented")
 }
 
 // Stop mock implementation of Stop from Manager interface
@@ -0,0 +1,248 @@
+//go:build !android
+
+package routemanager
+
+import (
+	"fmt"
+	"net"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+// ethtool ioctl commands, see include/uapi/linux/ethtool.h
+const (
+	ethtoolGStrings  = 0x1b
+	ethtoolGSSetInfo = 0x37
+	ethtoolGFeatures = 0x3a
+	ethtoolSFeatures = 0x3b
+
+	ethtoolStringSetFeatures = 4
+	ethtoolStringLen         = 32
+)
+
+// egressOffloads are the offloads of the interfaces of the default routes. UDP GRO forwarding lets the kernel aggregate
+// the forwarded WireGuard packets, the fraglist GRO would aggregate them in a way WireGuard can't split efficiently
+var egressOffloads = map[string]bool{
+	"rx-udp-gro-forwarding": true,
+	"rx-gro-list":           false,
+}
+
+// wgOffloads are the offloads of the WireGuard interface, the generic segmentation and receive offloads batch the
+// packets routed through the tunnel
+var wgOffloads = map[string]bool{
+	"tx-generic-segmentation": true,
+	"rx-gro":                  true,
+}
+
+// ethtoolFeature is a feature of a network device as reported by ETHTOOL_GFEATURES
+type ethtoolFeature struct {
+	name      string
+	available bool
+	active    bool
+	fixed     bool
+}
+
+type ifreqData struct {
+	name [unix.IFNAMSIZ]byte
+	data unsafe.Pointer
+	_    [24 - unsafe.Sizeof(uintptr(0))]byte
+}
+
+type ethtoolSSetInfo struct {
+	cmd      uint32
+	reserved uint32
+	ssetMask uint64
+	length   uint32
+}
+
+// tuneForwardingOffloads enables the offloads improving the forwarding throughput of a routing peer on the interfaces
+// of the default routes and on the WireGuard interface. The tuning is best effort, the offloads a driver doesn't
+// support are left out
+func tuneForwardingOffloads(wgInterface *iface.WGIface) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		log.Warnf("failed opening a socket to tune the offloads: %v", err)
+		return
+	}
+	defer unix.Close(fd) //nolint:errcheck
+
+	egressInterfaces, err := defaultRouteInterfaces()
+	if err != nil {
+		log.Warnf("failed getting the interfaces of the default routes: %v", err)
+	}
+	for _, name := range egressInterfaces {
+		tuneOffloads(fd, name, egressOffloads)
+	}
+
+	if wgInterface != nil {
+		tuneOffloads(fd, wgInterface.Name(), wgOffloads)
+	}
+}
+
+func tuneOffloads(fd int, ifaceName string, wanted map[string]bool) {
+	features, err := getFeatures(fd, ifaceName)
+	if err != nil {
+		log.Debugf("failed reading the offloads of interface %s: %v", ifaceName, err)
+		return
+	}
+
+	changes := offloadChanges(features, wanted)
+	if len(changes) == 0 {
+		log.Debugf("offloads of interface %s are already tuned for forwarding", ifaceName)
+		return
+	}
+
+	err = setFeatures(fd, ifaceName, len(features), changes)
+	if err != nil {
+		log.Warnf("failed tuning the offloads of interface %s for forwarding: %v", ifaceName, err)
+		return
+	}
+
+	for index, enable := range changes {
+		log.Infof("set %s to %t on interface %s to improve the forwarding throughput", features[index].name, enable, ifaceName)
+	}
+}
+
+// offloadChanges returns the state to set by feature index for the wanted features which are available and not fixed
+// by the driver and don't have the wanted state
+func offloadChanges(features []ethtoolFeature, wanted map[string]bool) map[int]bool {
+	changes := make(map[int]bool)
+	for index, feature := range features {
+		enable, found := wanted[feature.name]
+		if !found || !feature.available || feature.fixed || feature.active == enable {
+			continue
+		}
+		changes[index] = enable
+	}
+	return changes
+}
+
+// defaultRouteInterfaces returns the names of the interfaces of the IPv4 default routes of the main table
+func defaultRouteInterfaces() ([]string, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[int]struct{})
+	for _, route := range routes {
+		if route.Dst != nil && (!route.Dst.IP.IsUnspecified() || !isDefaultMask(route.Dst.Mask)) {
+			continue
+		}
+		if _, found := seen[route.LinkIndex]; found {
+			continue
+		}
+		seen[route.LinkIndex] = struct{}{}
+
+		link, err := netlink.LinkByIndex(route.LinkIndex)
+		if err != nil {
+			log.Debugf("failed getting the interface of the default route %s: %v", route, err)
+			continue
+		}
+		names = append(names, link.Attrs().Name)
+	}
+	return names, nil
+}
+
+func isDefaultMask(mask net.IPMask) bool {
+	ones, _ := mask.Size()
+	return ones == 0
+}
+
+// getFeatures reads the names and the state of the features of the network device
+func getFeatures(fd int, ifaceName string) ([]ethtoolFeature, error) {
+	ssetInfo := ethtoolSSetInfo{
+		cmd:      ethtoolGSSetInfo,
+		ssetMask: 1 << ethtoolStringSetFeatures,
+	}
+	if err := ethtoolIoctl(fd, ifaceName, unsafe.Pointer(&ssetInfo)); err != nil {
+		return nil, fmt.Errorf("get string set info: %w", err)
+	}
+	count := int(ssetInfo.length)
+	if ssetInfo.ssetMask == 0 || count == 0 {
+		return nil, fmt.Errorf("no features reported")
+	}
+
+	// struct ethtool_gstrings: cmd, string_set, len and the strings
+	gstrings := make([]uint32, 3+count*ethtoolStringLen/4)
+	gstrings[0] = ethtoolGStrings
+	gstrings[1] = ethtoolStringSetFeatures
+	gstrings[2] = uint32(count)
+	if err := ethtoolIoctl(fd, ifaceName, unsafe.Pointer(&gstrings[0])); err != nil {
+		return nil, fmt.Errorf("get feature names: %w", err)
+	}
+	names := unsafe.Slice((*byte)(unsafe.Pointer(&gstrings[3])), count*ethtoolStringLen)
+
+	// struct ethtool_gfeatures: cmd, size and the blocks of available, requested, active and never_changed
+	blocks := featureBlocks(count)
+	gfeatures := make([]uint32, 2+blocks*4)
+	gfeatures[0] = ethtoolGFeatures
+	gfeatures[1] = uint32(blocks)
+	if err := ethtoolIoctl(fd, ifaceName, unsafe.Pointer(&gfeatures[0])); err != nil {
+		return nil, fmt.Errorf("get features: %w", err)
+	}
+
+	features := make([]ethtoolFeature, count)
+	for i := range features {
+		block := gfeatures[2+i/32*4:]
+		bit := uint32(1) << (i % 32)
+		features[i] = ethtoolFeature{
+			name:      cString(names[i*ethtoolStringLen : (i+1)*ethtoolStringLen]),
+			available: block[0]&bit != 0,
+			active:    block[2]&bit != 0,
+			fixed:     block[3]&bit != 0,
+		}
+	}
+	return features, nil
+}
+
+// setFeatures requests the state of the features by feature index
+func setFeatures(fd int, ifaceName string, count int, changes map[int]bool) error {
+	// struct ethtool_sfeatures: cmd, size and the blocks of valid and requested
+	blocks := featureBlocks(count)
+	sfeatures := make([]uint32, 2+blocks*2)
+	sfeatures[0] = ethtoolSFeatures
+	sfeatures[1] = uint32(blocks)
+	for index, enable := range changes {
+		block := sfeatures[2+index/32*2:]
+		bit := uint32(1) << (index % 32)
+		block[0] |= bit
+		if enable {
+			block[1] |= bit
+		}
+	}
+	return ethtoolIoctl(fd, ifaceName, unsafe.Pointer(&sfeatures[0]))
+}
+
+func featureBlocks(count int) int {
+	return (count + 31) / 32
+}
+
+func ethtoolIoctl(fd int, ifaceName string, data unsafe.Pointer) error {
+	if len(ifaceName) >= unix.IFNAMSIZ {
+		return fmt.Errorf("interface name %s too long", ifaceName)
+	}
+
+	ifr := ifreqData{data: data}
+	copy(ifr.name[:], ifaceName)
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
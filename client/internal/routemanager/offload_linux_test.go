@@ -0,0 +1,50 @@
+//go:build !android
+
+package routemanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestOffloadChanges(t *testing.T) {
+	features := []ethtoolFeature{
+		{name: "rx-gro", available: true, active: true},
+		{name: "rx-udp-gro-forwarding", available: true, active: false},
+		{name: "rx-gro-list", available: true, active: true},
+		{name: "tx-generic-segmentation", available: false, active: false},
+		{name: "rx-checksum", available: true, active: false, fixed: true},
+	}
+	wanted := map[string]bool{
+		"rx-gro":                  true,
+		"rx-udp-gro-forwarding":   true,
+		"rx-gro-list":             false,
+		"tx-generic-segmentation": true,
+		"rx-checksum":             true,
+		"rx-vlan-filter":          true,
+	}
+
+	changes := offloadChanges(features, wanted)
+	assert.Equal(t, map[int]bool{1: true, 2: false}, changes,
+		"only the available features without the wanted state should be changed")
+}
+
+func TestGetFeatures(t *testing.T) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	require.NoError(t, err)
+	defer unix.Close(fd) //nolint:errcheck
+
+	features, err := getFeatures(fd, "lo")
+	if err != nil {
+		t.Skipf("the ethtool features of the loopback interface aren't readable: %v", err)
+	}
+
+	names := make(map[string]struct{}, len(features))
+	for _, feature := range features {
+		names[feature.name] = struct{}{}
+	}
+	assert.Contains(t, names, "rx-gro", "the generic receive offload should be reported")
+}
@@ -0,0 +1,10 @@
+//go:build !linux || android
+
+package routemanager
+
+import (
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+func tuneForwardingOffloads(*iface.WGIface) {
+}
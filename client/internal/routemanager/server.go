@@ -3,7 +3,9 @@ package routemanager
 import "github.com/FlintyLemming/netbird/route"
 
 type serverRouter interface {
-	updateRoutes(map[string]*route.Route) error
+	// updateRoutes applies the routes of the peer by ID, the routes of the other peers by network ID are the networks
+	// they advertise
+	updateRoutes(serverRoutes map[string]*route.Route, clientRoutes map[string][]*route.Route) error
 	removeFromServerNetwork(*route.Route) error
 	cleanUp()
 }
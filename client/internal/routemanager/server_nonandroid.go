@@ -4,7 +4,9 @@ package routemanager
 
 import (
 	"context"
+	"fmt"
 	"net/netip"
+	"sort"
 	"sync"
 
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,9 @@ type defaultServerRouter struct {
 	routes      map[string]*route.Route
 	firewall    firewall.Manager
 	wgInterface *iface.WGIface
+
+	// sourceValidation is true when the firewall validates the source of the forwarded traffic
+	sourceValidation bool
 }
 
 func newServerRouter(ctx context.Context, wgInterface *iface.WGIface, firewall firewall.Manager) (serverRouter, error) {
@@ -31,7 +36,7 @@ func newServerRouter(ctx context.Context, wgInterface *iface.WGIface, firewall f
 	}, nil
 }
 
-func (m *defaultServerRouter) updateRoutes(routesMap map[string]*route.Route) error {
+func (m *defaultServerRouter) updateRoutes(routesMap map[string]*route.Route, clientRoutes map[string][]*route.Route) error {
 	serverRoutesToRemove := make([]string, 0)
 
 	for routeID := range m.routes {
@@ -72,6 +77,51 @@ func (m *defaultServerRouter) updateRoutes(routesMap map[string]*route.Route) er
 		}
 	}
 
+	return m.updateSourceValidation(clientRoutes)
+}
+
+// updateSourceValidation makes the firewall drop the forwarded traffic whose source isn't allowed: the traffic
+// forwarded to the peers has to come from the routed networks and the traffic forwarded from a peer from its address
+// or the networks it advertises, so that neither the hosts of the routed networks nor the peers can spoof other
+// addresses through the peer
+func (m *defaultServerRouter) updateSourceValidation(clientRoutes map[string][]*route.Route) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if len(m.routes) == 0 {
+		return m.removeSourceValidation()
+	}
+
+	validation := &firewall.SourceValidation{AdvertisedNetworks: make(map[string][]string)}
+	for _, r := range m.routes {
+		validation.RoutedNetworks = append(validation.RoutedNetworks, r.Network.Masked().String())
+	}
+	sort.Strings(validation.RoutedNetworks)
+	for _, addr := range m.wgInterface.Address().All() {
+		network := netip.MustParsePrefix(addr.String()).Masked().String()
+		validation.PeerNetworks = append(validation.PeerNetworks, network)
+	}
+	for _, routes := range clientRoutes {
+		for _, r := range routes {
+			validation.AdvertisedNetworks[r.Peer] = append(validation.AdvertisedNetworks[r.Peer], r.Network.Masked().String())
+		}
+	}
+
+	if err := m.firewall.SetSourceValidation(validation); err != nil {
+		return fmt.Errorf("failed to validate the source of the routed traffic: %w", err)
+	}
+	m.sourceValidation = true
+	return nil
+}
+
+func (m *defaultServerRouter) removeSourceValidation() error {
+	if !m.sourceValidation {
+		return nil
+	}
+	if err := m.firewall.SetSourceValidation(nil); err != nil {
+		return fmt.Errorf("failed to remove the source validation of the routed traffic: %w", err)
+	}
+	m.sourceValidation = false
 	return nil
 }
 
@@ -118,6 +168,9 @@ func (m *defaultServerRouter) cleanUp() {
 			log.Warnf("failed to remove clean up route: %s", r.ID)
 		}
 	}
+	if err := m.removeSourceValidation(); err != nil {
+		log.Warnf("failed to clean up: %v", err)
+	}
 }
 
 func routeToRouterPair(source string, route *route.Route) firewall.RouterPair {
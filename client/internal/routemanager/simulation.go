@@ -0,0 +1,257 @@
+package routemanager
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	firewall "github.com/FlintyLemming/netbird/client/firewall/manager"
+	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+const (
+	// simulationSettleTime is the time without system changes after which the client networks are considered to have
+	// applied an event
+	simulationSettleTime = 50 * time.Millisecond
+	// simulationSettleTimeout is the maximum time waited for the client networks to apply an event
+	simulationSettleTimeout = 5 * time.Second
+)
+
+// SystemState is the state of the system routes applied by the route manager
+type SystemState struct {
+	// Routes are the networks routed through the WireGuard interface in the OS route table, sorted
+	Routes []netip.Prefix
+	// AllowedIPs are the routed networks in the allowed IPs of the WireGuard peers by peer key, sorted
+	AllowedIPs map[string][]string
+}
+
+// FakeSystemOps is a SystemOps keeping the routes and the allowed IPs in memory, so the route manager can run without
+// root privileges. It is safe for concurrent use.
+type FakeSystemOps struct {
+	mu         sync.Mutex
+	routes     map[netip.Prefix]struct{}
+	allowedIPs map[string]map[string]struct{}
+	lastChange time.Time
+}
+
+// NewFakeSystemOps returns a FakeSystemOps without routes
+func NewFakeSystemOps() *FakeSystemOps {
+	return &FakeSystemOps{
+		routes:     make(map[netip.Prefix]struct{}),
+		allowedIPs: make(map[string]map[string]struct{}),
+	}
+}
+
+// AddRoute adds the route of the network unless it already exists
+func (f *FakeSystemOps) AddRoute(network netip.Prefix) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.routes[network] = struct{}{}
+	f.lastChange = time.Now()
+	return nil
+}
+
+// RemoveRoute removes the route of the network
+func (f *FakeSystemOps) RemoveRoute(network netip.Prefix) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.routes, network)
+	f.lastChange = time.Now()
+	return nil
+}
+
+// AddAllowedIP adds the network to the allowed IPs of the peer
+func (f *FakeSystemOps) AddAllowedIP(peerKey string, allowedIP string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.allowedIPs[peerKey]; !ok {
+		f.allowedIPs[peerKey] = make(map[string]struct{})
+	}
+	f.allowedIPs[peerKey][allowedIP] = struct{}{}
+	f.lastChange = time.Now()
+	return nil
+}
+
+// RemoveAllowedIP removes the network from the allowed IPs of the peer
+func (f *FakeSystemOps) RemoveAllowedIP(peerKey string, allowedIP string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.allowedIPs[peerKey], allowedIP)
+	if len(f.allowedIPs[peerKey]) == 0 {
+		delete(f.allowedIPs, peerKey)
+	}
+	f.lastChange = time.Now()
+	return nil
+}
+
+// RemovePeer removes the allowed IPs of the peer, as the engine removes the WireGuard peer when it disconnects
+func (f *FakeSystemOps) RemovePeer(peerKey string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.allowedIPs, peerKey)
+	f.lastChange = time.Now()
+}
+
+// State returns the routes and the allowed IPs
+func (f *FakeSystemOps) State() SystemState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state := SystemState{
+		Routes:     make([]netip.Prefix, 0, len(f.routes)),
+		AllowedIPs: make(map[string][]string, len(f.allowedIPs)),
+	}
+	for network := range f.routes {
+		state.Routes = append(state.Routes, network)
+	}
+	sort.Slice(state.Routes, func(i, j int) bool {
+		return state.Routes[i].String() < state.Routes[j].String()
+	})
+	for peerKey, allowedIPs := range f.allowedIPs {
+		for allowedIP := range allowedIPs {
+			state.AllowedIPs[peerKey] = append(state.AllowedIPs[peerKey], allowedIP)
+		}
+		sort.Strings(state.AllowedIPs[peerKey])
+	}
+	return state
+}
+
+func (f *FakeSystemOps) sinceLastChange() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return time.Since(f.lastChange)
+}
+
+// SimulationEvent is an event replayed by the SimulatedManager
+type SimulationEvent interface {
+	apply(s *SimulatedManager) error
+}
+
+// RoutesUpdateEvent is an update of the routes of the network map
+type RoutesUpdateEvent struct {
+	Serial uint64
+	Routes []*route.Route
+}
+
+func (e RoutesUpdateEvent) apply(s *SimulatedManager) error {
+	// the engine adds the remote peers before updating the routes
+	for _, r := range e.Routes {
+		s.ensurePeer(r.Peer)
+	}
+	return s.UpdateRoutes(e.Serial, e.Routes)
+}
+
+// PeerConnectedEvent is a remote peer getting connected. The connection type of a connected peer is only updated
+// after a PeerDisconnectedEvent, as with the status recorder
+type PeerConnectedEvent struct {
+	PeerKey string
+	Relayed bool
+	Direct  bool
+}
+
+func (e PeerConnectedEvent) apply(s *SimulatedManager) error {
+	s.ensurePeer(e.PeerKey)
+	return s.StatusRecorder.UpdatePeerState(peer.State{
+		PubKey:           e.PeerKey,
+		ConnStatus:       peer.StatusConnected,
+		ConnStatusUpdate: time.Now(),
+		Relayed:          e.Relayed,
+		Direct:           e.Direct,
+	})
+}
+
+// PeerDisconnectedEvent is a remote peer losing the connection
+type PeerDisconnectedEvent struct {
+	PeerKey string
+}
+
+func (e PeerDisconnectedEvent) apply(s *SimulatedManager) error {
+	s.ensurePeer(e.PeerKey)
+	// the engine removes the WireGuard peer along with its allowed IPs when the connection is lost
+	s.SystemOps.RemovePeer(e.PeerKey)
+	return s.StatusRecorder.UpdatePeerState(peer.State{
+		PubKey:           e.PeerKey,
+		ConnStatus:       peer.StatusDisconnected,
+		ConnStatusUpdate: time.Now(),
+	})
+}
+
+// SimulatedManager is a route manager running against a FakeSystemOps and its own status recorder. It replays route
+// updates of the network map and connection changes of the remote peers, waiting after each event for the client
+// networks to apply it, so the failover and the HA logic can be tested without root privileges.
+type SimulatedManager struct {
+	*DefaultManager
+	SystemOps      *FakeSystemOps
+	StatusRecorder *peer.Status
+}
+
+// NewSimulatedManager creates a simulated route manager of the peer with the given public key
+func NewSimulatedManager(ctx context.Context, pubKey string) *SimulatedManager {
+	systemOps := NewFakeSystemOps()
+	statusRecorder := peer.NewRecorder("https://mgm")
+	return &SimulatedManager{
+		DefaultManager: newManager(ctx, pubKey, nil, systemOps, statusRecorder, nil, nil),
+		SystemOps:      systemOps,
+		StatusRecorder: statusRecorder,
+	}
+}
+
+// EnableServerRouter isn't supported by the simulation, only the client networks are simulated
+func (s *SimulatedManager) EnableServerRouter(_ firewall.Manager) error {
+	return fmt.Errorf("server routes are not supported by the simulation")
+}
+
+// Replay applies the events in order, waiting for the client networks to apply each of them
+func (s *SimulatedManager) Replay(events ...SimulationEvent) error {
+	for i, event := range events {
+		if err := event.apply(s); err != nil {
+			return fmt.Errorf("failed to apply event %d %T: %v", i, event, err)
+		}
+		if err := s.Settle(); err != nil {
+			return fmt.Errorf("event %d %T: %v", i, event, err)
+		}
+	}
+	return nil
+}
+
+// Settle waits until the system state stopped changing
+func (s *SimulatedManager) Settle() error {
+	timeout := time.After(simulationSettleTimeout)
+	ticker := time.NewTicker(simulationSettleTime / 5)
+	defer ticker.Stop()
+
+	// the client networks apply the changes asynchronously, give them time to start
+	time.Sleep(simulationSettleTime)
+	for {
+		if s.SystemOps.sinceLastChange() >= simulationSettleTime {
+			return nil
+		}
+		select {
+		case <-timeout:
+			return fmt.Errorf("the system state didn't settle within %s", simulationSettleTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// State returns the system state applied by the route manager
+func (s *SimulatedManager) State() SystemState {
+	return s.SystemOps.State()
+}
+
+func (s *SimulatedManager) ensurePeer(peerKey string) {
+	if _, err := s.StatusRecorder.GetPeer(peerKey); err != nil {
+		// the peer might have been added concurrently, it exists either way
+		_ = s.StatusRecorder.AddPeer(peerKey, "")
+	}
+}
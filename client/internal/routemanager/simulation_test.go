@@ -0,0 +1,160 @@
+package routemanager
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/route"
+)
+
+const (
+	simLocalPeerKey = "localPeerKey"
+	simPeer1Key     = "peer1Key"
+	simPeer2Key     = "peer2Key"
+)
+
+var simNetwork = netip.MustParsePrefix("192.168.0.0/24")
+
+func simRoutes(disablePreemption bool) []*route.Route {
+	return []*route.Route{
+		{
+			ID:                "route1",
+			NetID:             "net",
+			Network:           simNetwork,
+			Peer:              simPeer1Key,
+			Metric:            100,
+			Enabled:           true,
+			DisablePreemption: disablePreemption,
+		},
+		{
+			ID:                "route2",
+			NetID:             "net",
+			Network:           simNetwork,
+			Peer:              simPeer2Key,
+			Metric:            200,
+			Enabled:           true,
+			DisablePreemption: disablePreemption,
+		},
+	}
+}
+
+// requireRoutedVia checks that the network is routed through the WireGuard interface to the peer, or not routed at
+// all when the peer is empty
+func requireRoutedVia(t *testing.T, sim *SimulatedManager, peerKey string) {
+	t.Helper()
+
+	state := sim.State()
+	if peerKey == "" {
+		require.Empty(t, state.Routes, "the network should not be routed")
+		require.Empty(t, state.AllowedIPs, "no peer should be allowed the network")
+		return
+	}
+	require.Equal(t, []netip.Prefix{simNetwork}, state.Routes, "the network should be routed")
+	require.Equal(t, map[string][]string{peerKey: {simNetwork.String()}}, state.AllowedIPs,
+		"only the routing peer should be allowed the network")
+}
+
+func TestSimulation_Failover(t *testing.T) {
+	sim := NewSimulatedManager(context.Background(), simLocalPeerKey)
+	defer sim.Stop()
+
+	require.NoError(t, sim.Replay(
+		RoutesUpdateEvent{Serial: 1, Routes: simRoutes(false)},
+		PeerConnectedEvent{PeerKey: simPeer2Key, Direct: true},
+	))
+	requireRoutedVia(t, sim, simPeer2Key)
+
+	require.NoError(t, sim.Replay(PeerConnectedEvent{PeerKey: simPeer1Key, Direct: true}))
+	requireRoutedVia(t, sim, simPeer1Key)
+
+	require.NoError(t, sim.Replay(PeerDisconnectedEvent{PeerKey: simPeer1Key}))
+	requireRoutedVia(t, sim, simPeer2Key)
+
+	require.NoError(t, sim.Replay(PeerDisconnectedEvent{PeerKey: simPeer2Key}))
+	requireRoutedVia(t, sim, "")
+
+	require.NoError(t, sim.Replay(PeerConnectedEvent{PeerKey: simPeer2Key, Direct: true}))
+	requireRoutedVia(t, sim, simPeer2Key)
+}
+
+func TestSimulation_DisablePreemption(t *testing.T) {
+	sim := NewSimulatedManager(context.Background(), simLocalPeerKey)
+	defer sim.Stop()
+
+	require.NoError(t, sim.Replay(
+		RoutesUpdateEvent{Serial: 1, Routes: simRoutes(true)},
+		PeerConnectedEvent{PeerKey: simPeer2Key, Direct: true},
+		PeerConnectedEvent{PeerKey: simPeer1Key, Direct: true},
+	))
+	requireRoutedVia(t, sim, simPeer2Key)
+
+	require.NoError(t, sim.Replay(PeerDisconnectedEvent{PeerKey: simPeer2Key}))
+	requireRoutedVia(t, sim, simPeer1Key)
+}
+
+func TestSimulation_RoutesUpdate(t *testing.T) {
+	sim := NewSimulatedManager(context.Background(), simLocalPeerKey)
+	defer sim.Stop()
+
+	require.NoError(t, sim.Replay(
+		PeerConnectedEvent{PeerKey: simPeer1Key, Direct: true},
+		PeerConnectedEvent{PeerKey: simPeer2Key, Direct: true},
+		RoutesUpdateEvent{Serial: 1, Routes: simRoutes(false)},
+	))
+	requireRoutedVia(t, sim, simPeer1Key)
+
+	activeRoutes := sim.ActiveRoutes()
+	require.Len(t, activeRoutes, 1)
+	require.Equal(t, "route1", activeRoutes[0].ID)
+
+	require.NoError(t, sim.Replay(RoutesUpdateEvent{Serial: 2, Routes: simRoutes(false)[1:]}))
+	requireRoutedVia(t, sim, simPeer2Key)
+
+	require.NoError(t, sim.Replay(RoutesUpdateEvent{Serial: 3}))
+	requireRoutedVia(t, sim, "")
+	require.Empty(t, sim.ActiveRoutes())
+}
+
+func simBalancedRoutes() []*route.Route {
+	routes := simRoutes(false)
+	for _, r := range routes {
+		r.Metric = route.MaxMetric
+		r.LoadBalance = true
+	}
+	return routes
+}
+
+func TestSimulation_LoadBalance(t *testing.T) {
+	sim := NewSimulatedManager(context.Background(), simLocalPeerKey)
+	defer sim.Stop()
+
+	assigned, other := simPeer1Key, simPeer2Key
+	if rendezvousHash(simLocalPeerKey, simPeer2Key) > rendezvousHash(simLocalPeerKey, simPeer1Key) {
+		assigned, other = simPeer2Key, simPeer1Key
+	}
+
+	require.NoError(t, sim.Replay(
+		RoutesUpdateEvent{Serial: 1, Routes: simBalancedRoutes()},
+		PeerConnectedEvent{PeerKey: other, Direct: true},
+		PeerConnectedEvent{PeerKey: assigned, Relayed: true},
+	))
+	requireRoutedVia(t, sim, assigned)
+
+	routeStates := sim.StatusRecorder.GetFullStatus().Routes
+	require.Len(t, routeStates, 1)
+	require.Equal(t, assigned, routeStates[0].Peer)
+	require.True(t, routeStates[0].LoadBalanced, "the route should be shown as load balanced")
+
+	require.NoError(t, sim.Replay(PeerDisconnectedEvent{PeerKey: assigned}))
+	requireRoutedVia(t, sim, other)
+
+	require.NoError(t, sim.Replay(PeerConnectedEvent{PeerKey: assigned, Direct: true}))
+	requireRoutedVia(t, sim, assigned)
+
+	require.NoError(t, sim.Replay(RoutesUpdateEvent{Serial: 2}))
+	requireRoutedVia(t, sim, "")
+	require.Empty(t, sim.StatusRecorder.GetFullStatus().Routes)
+}
@@ -0,0 +1,41 @@
+package routemanager
+
+import (
+	"net/netip"
+
+	"github.com/FlintyLemming/netbird/iface"
+)
+
+// SystemOps applies the route chosen for a client network: the route of the network in the OS route table, pointing
+// to the WireGuard interface, and the network in the allowed IPs of the WireGuard peer of the routing peer
+type SystemOps interface {
+	// AddRoute adds the route of the network to the OS route table unless it already exists
+	AddRoute(network netip.Prefix) error
+	// RemoveRoute removes the route of the network from the OS route table unless it isn't managed by NetBird
+	RemoveRoute(network netip.Prefix) error
+	// AddAllowedIP adds the network to the allowed IPs of the WireGuard peer
+	AddAllowedIP(peerKey string, allowedIP string) error
+	// RemoveAllowedIP removes the network from the allowed IPs of the WireGuard peer
+	RemoveAllowedIP(peerKey string, allowedIP string) error
+}
+
+// defaultSystemOps changes the OS route table and the WireGuard interface
+type defaultSystemOps struct {
+	wgInterface *iface.WGIface
+}
+
+func (s *defaultSystemOps) AddRoute(network netip.Prefix) error {
+	return addToRouteTableIfNoExists(network, s.wgInterface.Address().IP.String())
+}
+
+func (s *defaultSystemOps) RemoveRoute(network netip.Prefix) error {
+	return removeFromRouteTableIfNonSystem(network, s.wgInterface.Address().IP.String())
+}
+
+func (s *defaultSystemOps) AddAllowedIP(peerKey string, allowedIP string) error {
+	return s.wgInterface.AddAllowedIP(peerKey, allowedIP)
+}
+
+func (s *defaultSystemOps) RemoveAllowedIP(peerKey string, allowedIP string) error {
+	return s.wgInterface.RemoveAllowedIP(peerKey, allowedIP)
+}
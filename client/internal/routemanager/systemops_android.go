@@ -11,3 +11,7 @@ func addToRouteTableIfNoExists(prefix netip.Prefix, addr string) error {
 func removeFromRouteTableIfNonSystem(prefix netip.Prefix, addr string) error {
 	return nil
 }
+
+func cleanupStaleRoutes() error {
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"unsafe"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 )
 
@@ -30,6 +31,9 @@ type routeInfoInMemory struct {
 
 const ipv4ForwardingPath = "/proc/sys/net/ipv4/ip_forward"
 
+// routeProtocol marks the routes added by NetBird in the routing table, so that they can be found after a crash
+const routeProtocol = 0x4e
+
 func addToRouteTable(prefix netip.Prefix, addr string) error {
 	_, ipNet, err := net.ParseCIDR(prefix.String())
 	if err != nil {
@@ -47,9 +51,10 @@ func addToRouteTable(prefix netip.Prefix, addr string) error {
 	}
 
 	route := &netlink.Route{
-		Scope: netlink.SCOPE_UNIVERSE,
-		Dst:   ipNet,
-		Gw:    ip,
+		Scope:    netlink.SCOPE_UNIVERSE,
+		Dst:      ipNet,
+		Gw:       ip,
+		Protocol: routeProtocol,
 	}
 
 	err = netlink.RouteAdd(route)
@@ -90,6 +95,23 @@ func removeFromRouteTable(prefix netip.Prefix, addr string) error {
 	return nil
 }
 
+// cleanupStaleRoutes removes the routes left behind by a client which didn't shut down cleanly
+func cleanupStaleRoutes() error {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Protocol: routeProtocol}, netlink.RT_FILTER_PROTOCOL)
+	if err != nil {
+		return err
+	}
+
+	for i := range routes {
+		if err := netlink.RouteDel(&routes[i]); err != nil {
+			log.Warnf("failed to remove stale route %s: %v", routes[i].Dst, err)
+			continue
+		}
+		log.Debugf("removed stale route %s via %s", routes[i].Dst, routes[i].Gw)
+	}
+	return nil
+}
+
 func getRoutesFromTable() ([]netip.Prefix, error) {
 	tab, err := syscall.NetlinkRIB(syscall.RTM_GETROUTE, syscall.AF_UNSPEC)
 	if err != nil {
@@ -1,5 +1,5 @@
-//go:build !linux
-// +build !linux
+//go:build !linux && !freebsd && !openbsd
+// +build !linux,!freebsd,!openbsd
 
 package routemanager
 
@@ -35,6 +35,10 @@ func removeFromRouteTable(prefix netip.Prefix, addr string) error {
 	return nil
 }
 
+func cleanupStaleRoutes() error {
+	return nil
+}
+
 func enableIPForwarding() error {
 	log.Infof("enable IP forwarding is not implemented on %s", runtime.GOOS)
 	return nil
@@ -0,0 +1,140 @@
+//go:build freebsd || openbsd
+
+package routemanager
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/route"
+	"golang.org/x/sys/unix"
+)
+
+const ipv4ForwardingSysctl = "net.inet.ip.forwarding"
+
+// routeFlagNetBird marks the routes added by NetBird in the routing table, so that they can be found after a crash
+const routeFlagNetBird = unix.RTF_PROTO3
+
+func addToRouteTable(prefix netip.Prefix, addr string) error {
+	return writeRouteMessage(unix.RTM_ADD, prefix, addr)
+}
+
+func removeFromRouteTable(prefix netip.Prefix, addr string) error {
+	return writeRouteMessage(unix.RTM_DELETE, prefix, addr)
+}
+
+// cleanupStaleRoutes removes the routes left behind by a client which didn't shut down cleanly
+func cleanupStaleRoutes() error {
+	tab, err := route.FetchRIB(unix.AF_UNSPEC, route.RIBTypeRoute, 0)
+	if err != nil {
+		return err
+	}
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, tab)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		m, ok := msg.(*route.RouteMessage)
+		if !ok || m.Flags&routeFlagNetBird == 0 || len(m.Addrs) <= unix.RTAX_NETMASK {
+			continue
+		}
+
+		addr, ok := toNetIPAddr(m.Addrs[unix.RTAX_DST])
+		if !ok {
+			continue
+		}
+		mask, ok := toNetIPMASK(m.Addrs[unix.RTAX_NETMASK])
+		if !ok {
+			continue
+		}
+		gateway, ok := toNetIPAddr(m.Addrs[unix.RTAX_GATEWAY])
+		if !ok {
+			continue
+		}
+		bits, _ := mask.Size()
+		prefix := netip.PrefixFrom(addr, bits)
+
+		if err := removeFromRouteTable(prefix, gateway.String()); err != nil {
+			log.Warnf("failed to remove stale route %s: %v", prefix, err)
+			continue
+		}
+		log.Debugf("removed stale route %s via %s", prefix, gateway)
+	}
+	return nil
+}
+
+// writeRouteMessage adds or deletes the route of the prefix through the gateway with a message on a routing socket
+func writeRouteMessage(action int, prefix netip.Prefix, addr string) error {
+	gateway, err := netip.ParseAddr(addr)
+	if err != nil {
+		return err
+	}
+	if !prefix.Addr().Is4() || !gateway.Is4() {
+		return fmt.Errorf("only IPv4 routes are supported, got %s via %s", prefix, gateway)
+	}
+
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("open routing socket: %w", err)
+	}
+	defer func() {
+		if err := unix.Close(fd); err != nil {
+			log.Warnf("failed to close routing socket: %v", err)
+		}
+	}()
+
+	prefix = prefix.Masked()
+	msg := route.RouteMessage{
+		Version: unix.RTM_VERSION,
+		Type:    action,
+		Flags:   unix.RTF_UP | unix.RTF_GATEWAY | unix.RTF_STATIC | routeFlagNetBird,
+		ID:      uintptr(os.Getpid()),
+		Seq:     1,
+		Addrs: []route.Addr{
+			unix.RTAX_DST:     &route.Inet4Addr{IP: prefix.Addr().As4()},
+			unix.RTAX_GATEWAY: &route.Inet4Addr{IP: gateway.As4()},
+			unix.RTAX_NETMASK: &route.Inet4Addr{IP: prefixMask(prefix)},
+		},
+	}
+	if prefix.IsSingleIP() {
+		msg.Flags |= unix.RTF_HOST
+		msg.Addrs = msg.Addrs[:unix.RTAX_NETMASK]
+	}
+
+	b, err := msg.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal route message: %w", err)
+	}
+	if _, err := unix.Write(fd, b); err != nil {
+		return fmt.Errorf("write route message for %s via %s: %w", prefix, gateway, err)
+	}
+	return nil
+}
+
+func prefixMask(prefix netip.Prefix) [4]byte {
+	var mask [4]byte
+	bits := uint32(0xffffffff) << (32 - prefix.Bits())
+	if prefix.Bits() == 0 {
+		bits = 0
+	}
+	mask[0], mask[1], mask[2], mask[3] = byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits)
+	return mask
+}
+
+func enableIPForwarding() error {
+	// check if it is already enabled
+	if enabled, err := unix.SysctlUint32(ipv4ForwardingSysctl); err == nil && enabled == 1 {
+		return nil
+	}
+
+	out, err := exec.Command("sysctl", ipv4ForwardingSysctl+"=1").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("enable IP forwarding: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package routeselector
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/FlintyLemming/netbird/route"
+)
+
+// RouteSelector keeps track of the networks whose routes were deselected by the user.
+// Networks are identified by their network ID and are selected unless deselected explicitly,
+// so new networks advertised by the management service are accepted by default
+type RouteSelector struct {
+	mu          sync.RWMutex
+	deselected  map[string]struct{}
+	subscribers map[chan struct{}]struct{}
+}
+
+// New creates a RouteSelector with the deselected network IDs
+func New(deselected []string) *RouteSelector {
+	rs := &RouteSelector{
+		deselected:  make(map[string]struct{}),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+	for _, netID := range deselected {
+		rs.deselected[netID] = struct{}{}
+	}
+	return rs
+}
+
+// SelectRoutes accepts the routes of the networks again
+func (rs *RouteSelector) SelectRoutes(netIDs []string) {
+	rs.mu.Lock()
+	for _, netID := range netIDs {
+		delete(rs.deselected, netID)
+	}
+	rs.mu.Unlock()
+
+	rs.notify()
+}
+
+// DeselectRoutes ignores the routes of the networks
+func (rs *RouteSelector) DeselectRoutes(netIDs []string) {
+	rs.mu.Lock()
+	for _, netID := range netIDs {
+		rs.deselected[netID] = struct{}{}
+	}
+	rs.mu.Unlock()
+
+	rs.notify()
+}
+
+// IsSelected returns true if the routes of the network are accepted
+func (rs *RouteSelector) IsSelected(netID string) bool {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	_, deselected := rs.deselected[netID]
+	return !deselected
+}
+
+// Deselected returns the sorted list of the deselected network IDs, never nil
+func (rs *RouteSelector) Deselected() []string {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	netIDs := make([]string, 0, len(rs.deselected))
+	for netID := range rs.deselected {
+		netIDs = append(netIDs, netID)
+	}
+	sort.Strings(netIDs)
+	return netIDs
+}
+
+// FilterSelected returns the routes of the selected networks, keyed by the same IDs as the input
+func (rs *RouteSelector) FilterSelected(routes map[string][]*route.Route) map[string][]*route.Route {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	filtered := make(map[string][]*route.Route, len(routes))
+	for id, networkRoutes := range routes {
+		if len(networkRoutes) == 0 {
+			continue
+		}
+		if _, deselected := rs.deselected[networkRoutes[0].NetID]; deselected {
+			continue
+		}
+		filtered[id] = networkRoutes
+	}
+	return filtered
+}
+
+// Subscribe returns a channel notified when the selection changes and a function to stop the notifications
+func (rs *RouteSelector) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	rs.mu.Lock()
+	rs.subscribers[ch] = struct{}{}
+	rs.mu.Unlock()
+
+	return ch, func() {
+		rs.mu.Lock()
+		delete(rs.subscribers, ch)
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *RouteSelector) notify() {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+
+	for ch := range rs.subscribers {
+		// a pending notification already covers this change
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package routeselector
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/route"
+)
+
+func TestRouteSelector(t *testing.T) {
+	rs := New([]string{"office"})
+	assert.False(t, rs.IsSelected("office"))
+	assert.True(t, rs.IsSelected("datacenter"), "networks should be selected by default")
+
+	updates, unsubscribe := rs.Subscribe()
+	defer unsubscribe()
+
+	rs.DeselectRoutes([]string{"datacenter"})
+	assert.Len(t, updates, 1, "subscribers should be notified of the change")
+	assert.Equal(t, []string{"datacenter", "office"}, rs.Deselected())
+
+	rs.SelectRoutes([]string{"office", "datacenter"})
+	assert.Len(t, updates, 1, "pending notifications should not block")
+	assert.Equal(t, []string{}, rs.Deselected())
+}
+
+func TestRouteSelector_FilterSelected(t *testing.T) {
+	office := &route.Route{ID: "a", NetID: "office", Network: netip.MustParsePrefix("192.168.1.0/24")}
+	datacenter := &route.Route{ID: "b", NetID: "datacenter", Network: netip.MustParsePrefix("10.0.0.0/16")}
+	routes := map[string][]*route.Route{
+		route.GetHAUniqueID(office):     {office},
+		route.GetHAUniqueID(datacenter): {datacenter},
+	}
+
+	rs := New([]string{"office"})
+	filtered := rs.FilterSelected(routes)
+	assert.Equal(t, map[string][]*route.Route{route.GetHAUniqueID(datacenter): {datacenter}}, filtered)
+
+	rs.SelectRoutes([]string{"office"})
+	assert.Equal(t, routes, rs.FilterSelected(routes))
+}
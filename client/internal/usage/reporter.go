@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultReportInterval is the default interval at which the usage is reported to the Management service
+	DefaultReportInterval = time.Hour
+	// reportDays is the number of days of every report, the previous day is reported again to complete it
+	reportDays = 2
+)
+
+// ReportFunc sends the usage of the last days to the Management service
+type ReportFunc func(days []Day) error
+
+// Reporter reports the usage of the current and previous day every interval. The reports hold the totals of the days
+// so far, a lost report is completed by the next one
+type Reporter struct {
+	tracker  *Tracker
+	report   ReportFunc
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReporter creates a Reporter sending the usage of the tracker every interval
+func NewReporter(tracker *Tracker, report ReportFunc, interval time.Duration) *Reporter {
+	return &Reporter{
+		tracker:  tracker,
+		report:   report,
+		interval: interval,
+	}
+}
+
+// Start reports the usage every interval until Stop is called. The first report is sent after an interval
+func (r *Reporter) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			days := r.tracker.Days(reportDays)
+			if len(days) == 0 {
+				continue
+			}
+			if err := r.report(days); err != nil {
+				log.Warnf("failed reporting the usage, retrying in %s: %v", r.interval, err)
+			}
+		}
+	}()
+}
+
+// Stop stops reporting
+func (r *Reporter) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+}
@@ -0,0 +1,211 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/maps"
+
+	"github.com/FlintyLemming/netbird/util"
+)
+
+const (
+	// DefaultMaxDays is the default number of days the usage is kept
+	DefaultMaxDays = 90
+	// DefaultSampleInterval is the default interval at which the WireGuard counters are sampled
+	DefaultSampleInterval = time.Minute
+
+	// dateLayout is the layout of the dates of the daily rollups, the days are in UTC
+	dateLayout = "2006-01-02"
+)
+
+// Counters are the cumulative bytes received and sent to a peer as read from the WireGuard interface
+type Counters struct {
+	RxBytes int64
+	TxBytes int64
+}
+
+// PeerUsage is the traffic with a peer during a day
+type PeerUsage struct {
+	// FQDN of the peer as of the last traffic with it
+	FQDN    string `json:"fqdn,omitempty"`
+	RxBytes uint64 `json:"rxBytes"`
+	TxBytes uint64 `json:"txBytes"`
+}
+
+// Day is the traffic over the NetBird interface during a day, in total and per peer keyed by WireGuard public key
+type Day struct {
+	Date    string               `json:"date"`
+	RxBytes uint64               `json:"rxBytes"`
+	TxBytes uint64               `json:"txBytes"`
+	Peers   map[string]PeerUsage `json:"peers,omitempty"`
+}
+
+type persistedUsage struct {
+	Days []*Day `json:"days"`
+}
+
+// Tracker accounts the traffic over the NetBird interface in daily rollups persisted to a JSON file. The traffic is
+// computed from the difference between consecutive samples of the WireGuard counters of the peers
+type Tracker struct {
+	path    string
+	maxDays int
+
+	mu sync.Mutex
+	// days are sorted by date, oldest first
+	days []*Day
+	// last holds the counters of the previous sample keyed by WireGuard public key
+	last  map[string]Counters
+	dirty bool
+	now   func() time.Time
+}
+
+// New creates a Tracker persisted to the file, an empty path keeps the usage in memory only
+func New(path string, maxDays int) *Tracker {
+	return &Tracker{
+		path:    path,
+		maxDays: maxDays,
+		last:    make(map[string]Counters),
+		now:     time.Now,
+	}
+}
+
+// Load reads the usage persisted by a previous run, a missing file is not an error
+func (t *Tracker) Load() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := os.Stat(t.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	var persisted persistedUsage
+	if _, err := util.ReadJson(t.path, &persisted); err != nil {
+		return fmt.Errorf("read usage: %w", err)
+	}
+
+	days := persisted.Days[:0]
+	for _, day := range persisted.Days {
+		if day != nil {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+	t.days = days
+	t.prune()
+	return nil
+}
+
+// Save persists the usage if it changed since the last save
+func (t *Tracker) Save() error {
+	if t.path == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return nil
+	}
+	if err := util.WriteJson(t.path, persistedUsage{Days: t.days}); err != nil {
+		return fmt.Errorf("write usage: %w", err)
+	}
+	t.dirty = false
+	return nil
+}
+
+// Update adds the traffic since the previous sample to the current day. The counters of a peer restart from zero when
+// the WireGuard peer is recreated, lower counters than the previous sample are counted from zero. fqdns holds the FQDN
+// of the peers keyed by WireGuard public key
+func (t *Tracker) Update(counters map[string]Counters, fqdns map[string]string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	day := t.currentDay()
+	for key, current := range counters {
+		previous := t.last[key]
+		if current.RxBytes < previous.RxBytes || current.TxBytes < previous.TxBytes {
+			previous = Counters{}
+		}
+		t.last[key] = current
+
+		rx := uint64(current.RxBytes - previous.RxBytes)
+		tx := uint64(current.TxBytes - previous.TxBytes)
+		if rx == 0 && tx == 0 {
+			continue
+		}
+
+		peerUsage := day.Peers[key]
+		peerUsage.RxBytes += rx
+		peerUsage.TxBytes += tx
+		if fqdn := fqdns[key]; fqdn != "" {
+			peerUsage.FQDN = fqdn
+		}
+		day.Peers[key] = peerUsage
+		day.RxBytes += rx
+		day.TxBytes += tx
+		t.dirty = true
+	}
+
+	// the counters of the removed peers restart from zero if they are added back
+	for key := range t.last {
+		if _, ok := counters[key]; !ok {
+			delete(t.last, key)
+		}
+	}
+}
+
+// Days returns a copy of the usage of the last n days, oldest first. n <= 0 returns all the kept days
+func (t *Tracker) Days(n int) []Day {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	days := t.days
+	if n > 0 && len(days) > n {
+		days = days[len(days)-n:]
+	}
+
+	result := make([]Day, 0, len(days))
+	for _, day := range days {
+		dayCopy := *day
+		dayCopy.Peers = maps.Clone(day.Peers)
+		result = append(result, dayCopy)
+	}
+	return result
+}
+
+// currentDay returns the rollup of the current day, creating it if needed. The traffic is added to the latest day if
+// the clock moved backwards. The lock must be held by the caller
+func (t *Tracker) currentDay() *Day {
+	date := t.now().UTC().Format(dateLayout)
+	if len(t.days) > 0 && t.days[len(t.days)-1].Date >= date {
+		day := t.days[len(t.days)-1]
+		if day.Peers == nil {
+			day.Peers = make(map[string]PeerUsage)
+		}
+		return day
+	}
+
+	day := &Day{Date: date, Peers: make(map[string]PeerUsage)}
+	t.days = append(t.days, day)
+	t.prune()
+	return day
+}
+
+// prune drops the oldest days above the limit. The lock must be held by the caller
+func (t *Tracker) prune() {
+	if t.maxDays > 0 && len(t.days) > t.maxDays {
+		t.days = t.days[len(t.days)-t.maxDays:]
+	}
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Update(t *testing.T) {
+	now := time.Date(2023, 11, 20, 23, 0, 0, 0, time.UTC)
+	tracker := New("", 2)
+	tracker.now = func() time.Time { return now }
+	fqdns := map[string]string{"peer-a": "a.netbird.cloud"}
+
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 100, TxBytes: 50}}, fqdns)
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 300, TxBytes: 80}, "peer-b": {RxBytes: 10}}, fqdns)
+	// the WireGuard peer was recreated
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 20, TxBytes: 5}, "peer-b": {RxBytes: 10}}, fqdns)
+
+	days := tracker.Days(0)
+	require.Len(t, days, 1)
+	assert.Equal(t, Day{
+		Date:    "2023-11-20",
+		RxBytes: 330,
+		TxBytes: 85,
+		Peers: map[string]PeerUsage{
+			"peer-a": {FQDN: "a.netbird.cloud", RxBytes: 320, TxBytes: 85},
+			"peer-b": {RxBytes: 10},
+		},
+	}, days[0])
+
+	now = now.Add(2 * time.Hour)
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 25, TxBytes: 5}}, fqdns)
+	now = now.Add(24 * time.Hour)
+	// peer-b was removed and added back
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 25, TxBytes: 5}, "peer-b": {RxBytes: 7}}, fqdns)
+
+	days = tracker.Days(0)
+	require.Len(t, days, 2, "the days above the limit should be dropped")
+	assert.Equal(t, "2023-11-21", days[0].Date)
+	assert.Equal(t, uint64(5), days[0].RxBytes)
+	assert.Equal(t, "2023-11-22", days[1].Date)
+	assert.Equal(t, map[string]PeerUsage{"peer-b": {RxBytes: 7}}, days[1].Peers)
+
+	days = tracker.Days(1)
+	require.Len(t, days, 1)
+	assert.Equal(t, "2023-11-22", days[0].Date)
+}
+
+func TestTracker_Persistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tracker := New(path, DefaultMaxDays)
+	require.NoError(t, tracker.Load(), "a missing file should not be an error")
+
+	tracker.Update(map[string]Counters{"peer-a": {RxBytes: 100, TxBytes: 50}}, nil)
+	require.NoError(t, tracker.Save())
+
+	loaded := New(path, DefaultMaxDays)
+	require.NoError(t, loaded.Load())
+	assert.Equal(t, tracker.Days(0), loaded.Days(0))
+}
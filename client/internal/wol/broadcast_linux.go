@@ -0,0 +1,35 @@
+package wol
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// newBroadcastConn opens a socket allowed to send to broadcast addresses
+func newBroadcastConn() (*net.UDPConn, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("enable broadcast: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package wol
+
+import (
+	"errors"
+	"net"
+)
+
+// newBroadcastConn is only supported on Linux, the only platform routing peers run on
+func newBroadcastConn() (*net.UDPConn, error) {
+	return nil, errors.New("relaying Wake-on-LAN packets is not supported on this platform")
+}
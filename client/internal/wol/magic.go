@@ -0,0 +1,59 @@
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// Port is the UDP port of the Wake-on-LAN magic packets, the relay listens on it on the NetBird address
+const Port = 9
+
+const (
+	macRepetitions = 16
+	// magicPacketSize is the size of the magic packet without the optional SecureOn password
+	magicPacketSize = 6 + macRepetitions*6
+)
+
+var syncStream = bytes.Repeat([]byte{0xff}, 6)
+
+// ParseMAC parses a 48-bit MAC address, e.g. 00:11:22:33:44:55 or 00-11-22-33-44-55
+func ParseMAC(s string) (net.HardwareAddr, error) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid MAC address %s, expected 6 bytes", s)
+	}
+	return mac, nil
+}
+
+// MagicPacket builds the magic packet waking up the machine with the MAC address
+func MagicPacket(mac net.HardwareAddr) []byte {
+	packet := make([]byte, 0, magicPacketSize)
+	packet = append(packet, syncStream...)
+	for i := 0; i < macRepetitions; i++ {
+		packet = append(packet, mac...)
+	}
+	return packet
+}
+
+// parseMagicPacket returns the MAC address of a valid magic packet. A trailing SecureOn password is accepted
+func parseMagicPacket(packet []byte) (net.HardwareAddr, bool) {
+	if len(packet) != magicPacketSize && len(packet) != magicPacketSize+4 && len(packet) != magicPacketSize+6 {
+		return nil, false
+	}
+	if !bytes.Equal(packet[:6], syncStream) {
+		return nil, false
+	}
+
+	mac := net.HardwareAddr(packet[6:12])
+	for i := 1; i < macRepetitions; i++ {
+		offset := 6 + i*6
+		if !bytes.Equal(packet[offset:offset+6], mac) {
+			return nil, false
+		}
+	}
+	return append(net.HardwareAddr{}, mac...), true
+}
@@ -0,0 +1,182 @@
+package wol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// sendRepetitions is the number of times a magic packet is sent, UDP delivery isn't guaranteed
+const sendRepetitions = 3
+
+// Relay receives the magic packets sent by peers to the NetBird address of this routing peer and broadcasts them to
+// the networks routed by it, waking up the machines on these networks across the mesh. Which peers may send them is
+// decided by the access control policies applied to the NetBird interface
+type Relay struct {
+	ip      net.IP
+	network *net.IPNet
+	port    int
+
+	mu       sync.Mutex
+	networks []netip.Prefix
+
+	conn    *net.UDPConn
+	sender  *net.UDPConn
+	writeTo func(packet []byte, addr *net.UDPAddr) error
+	done    chan struct{}
+}
+
+// NewRelay creates a Relay listening on the NetBird address of the peer. Only packets from the NetBird network are
+// relayed
+func NewRelay(ip net.IP, network *net.IPNet) *Relay {
+	return newRelay(ip, network, Port)
+}
+
+func newRelay(ip net.IP, network *net.IPNet, port int) *Relay {
+	return &Relay{
+		ip:      ip,
+		network: network,
+		port:    port,
+	}
+}
+
+// UpdateNetworks sets the networks routed by the peer, the magic packets are broadcast to the IPv4 ones
+func (r *Relay) UpdateNetworks(networks []netip.Prefix) {
+	var broadcastNetworks []netip.Prefix
+	for _, network := range networks {
+		// default routes and networks without a broadcast address are skipped
+		if !network.Addr().Is4() || network.Bits() == 0 || network.Bits() > 30 {
+			continue
+		}
+		broadcastNetworks = append(broadcastNetworks, network.Masked())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.networks = broadcastNetworks
+}
+
+// Start listens for the magic packets until Stop is called
+func (r *Relay) Start() error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: r.ip, Port: r.port})
+	if err != nil {
+		return fmt.Errorf("listen on %s:%d: %w", r.ip, r.port, err)
+	}
+
+	if r.writeTo == nil {
+		sender, err := newBroadcastConn()
+		if err != nil {
+			_ = conn.Close()
+			return err
+		}
+		r.sender = sender
+		r.writeTo = func(packet []byte, addr *net.UDPAddr) error {
+			_, err := sender.WriteToUDP(packet, addr)
+			return err
+		}
+	}
+
+	r.conn = conn
+	r.done = make(chan struct{})
+	go r.serve()
+
+	log.Infof("relaying Wake-on-LAN packets received on %s", conn.LocalAddr())
+	return nil
+}
+
+// Stop stops relaying the magic packets
+func (r *Relay) Stop() {
+	if r.conn == nil {
+		return
+	}
+
+	_ = r.conn.Close()
+	<-r.done
+	r.conn = nil
+
+	if r.sender != nil {
+		_ = r.sender.Close()
+		r.sender = nil
+		r.writeTo = nil
+	}
+}
+
+func (r *Relay) serve() {
+	defer close(r.done)
+
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Debugf("failed reading Wake-on-LAN packet: %v", err)
+			continue
+		}
+
+		if !r.network.Contains(src.IP) {
+			log.Debugf("ignoring Wake-on-LAN packet from %s outside of the NetBird network", src.IP)
+			continue
+		}
+
+		mac, ok := parseMagicPacket(buf[:n])
+		if !ok {
+			log.Debugf("ignoring invalid Wake-on-LAN packet from %s", src.IP)
+			continue
+		}
+
+		r.relay(buf[:n], mac, src.IP)
+	}
+}
+
+// relay broadcasts the magic packet to the routed networks
+func (r *Relay) relay(packet []byte, mac net.HardwareAddr, src net.IP) {
+	r.mu.Lock()
+	networks := r.networks
+	r.mu.Unlock()
+
+	if len(networks) == 0 {
+		log.Warnf("received Wake-on-LAN packet for %s from %s, but this peer routes no IPv4 network", mac, src)
+		return
+	}
+
+	for _, network := range networks {
+		addr := &net.UDPAddr{IP: broadcastAddr(network).AsSlice(), Port: Port}
+		if err := r.writeTo(packet, addr); err != nil {
+			log.Warnf("failed relaying Wake-on-LAN packet for %s to %s: %v", mac, addr, err)
+			continue
+		}
+		log.Infof("relayed Wake-on-LAN packet for %s from %s to %s", mac, src, addr)
+	}
+}
+
+// broadcastAddr returns the last address of the IPv4 network
+func broadcastAddr(network netip.Prefix) netip.Addr {
+	ip := network.Masked().Addr().As4()
+	for i := network.Bits(); i < 32; i++ {
+		ip[i/8] |= 1 << (7 - i%8)
+	}
+	return netip.AddrFrom4(ip)
+}
+
+// Send sends the magic packet of the MAC address to the relay of a routing peer
+func Send(relay netip.Addr, mac net.HardwareAddr) error {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: relay.AsSlice(), Port: Port})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	packet := MagicPacket(mac)
+	for i := 0; i < sendRepetitions; i++ {
+		if _, err := conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
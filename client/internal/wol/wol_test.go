@@ -0,0 +1,112 @@
+package wol
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, err := ParseMAC("00:11:22:33:44:55")
+	require.NoError(t, err)
+
+	packet := MagicPacket(mac)
+	assert.Len(t, packet, 102)
+
+	parsed, ok := parseMagicPacket(packet)
+	require.True(t, ok, "magic packet should be valid")
+	assert.Equal(t, mac, parsed)
+
+	_, ok = parseMagicPacket(append(packet, 1, 2, 3, 4, 5, 6))
+	assert.True(t, ok, "magic packet with a SecureOn password should be valid")
+
+	invalid := append([]byte{}, packet...)
+	invalid[50] = 0
+	_, ok = parseMagicPacket(invalid)
+	assert.False(t, ok, "magic packet with a different MAC repetition should be invalid")
+
+	_, err = ParseMAC("00:11:22:33:44:55:66:77")
+	assert.Error(t, err, "EUI-64 addresses shouldn't be accepted")
+}
+
+func TestBroadcastAddr(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddr("192.168.1.255"), broadcastAddr(netip.MustParsePrefix("192.168.1.0/24")))
+	assert.Equal(t, netip.MustParseAddr("10.1.255.255"), broadcastAddr(netip.MustParsePrefix("10.1.2.3/16")))
+	assert.Equal(t, netip.MustParseAddr("172.16.0.3"), broadcastAddr(netip.MustParsePrefix("172.16.0.0/30")))
+}
+
+func TestRelay(t *testing.T) {
+	testCases := []struct {
+		name     string
+		network  string
+		packet   []byte
+		expected []string
+	}{
+		{
+			name:     "Should Relay To The Routed Networks",
+			network:  "127.0.0.0/8",
+			packet:   MagicPacket(net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}),
+			expected: []string{"192.168.1.255:9", "10.1.255.255:9"},
+		},
+		{
+			name:    "Should Ignore Packets From Outside Of The NetBird Network",
+			network: "100.64.0.0/10",
+			packet:  MagicPacket(net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}),
+		},
+		{
+			name:    "Should Ignore Invalid Packets",
+			network: "127.0.0.0/8",
+			packet:  []byte("wake up"),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, network, err := net.ParseCIDR(testCase.network)
+			require.NoError(t, err)
+
+			relayed := make(chan string, 10)
+			relay := newRelay(net.IPv4(127, 0, 0, 1), network, 0)
+			relay.writeTo = func(packet []byte, addr *net.UDPAddr) error {
+				assert.Equal(t, testCase.packet, packet)
+				relayed <- addr.String()
+				return nil
+			}
+			relay.UpdateNetworks([]netip.Prefix{
+				netip.MustParsePrefix("192.168.1.0/24"),
+				netip.MustParsePrefix("0.0.0.0/0"),
+				netip.MustParsePrefix("fd00::/64"),
+				netip.MustParsePrefix("10.1.0.0/16"),
+			})
+			require.NoError(t, relay.Start())
+			defer relay.Stop()
+
+			conn, err := net.DialUDP("udp4", nil, relay.conn.LocalAddr().(*net.UDPAddr))
+			require.NoError(t, err)
+			defer conn.Close()
+			_, err = conn.Write(testCase.packet)
+			require.NoError(t, err)
+
+			var addrs []string
+			for range testCase.expected {
+				select {
+				case addr := <-relayed:
+					addrs = append(addrs, addr)
+				case <-time.After(time.Second):
+					t.Fatal("magic packet wasn't relayed")
+				}
+			}
+			assert.Equal(t, testCase.expected, addrs)
+
+			select {
+			case addr := <-relayed:
+				t.Fatalf("unexpected packet relayed to %s", addr)
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	}
+}
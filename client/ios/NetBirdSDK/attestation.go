@@ -0,0 +1,18 @@
+package NetBirdSDK
+
+import (
+	"github.com/FlintyLemming/netbird/client/internal/attestation"
+)
+
+// AttestationSigner export internal attestation Signer for mobile, e.g. a key held by the Secure Enclave
+type AttestationSigner interface {
+	attestation.Signer
+}
+
+// RegisterAttestationSigner makes the signer available as attestation provider under its provider name.
+// The provider has to be enabled in the Preferences to be used on login
+func RegisterAttestationSigner(signer AttestationSigner) {
+	attestation.RegisterProvider(signer.Provider(), func() (attestation.Signer, error) {
+		return signer, nil
+	})
+}
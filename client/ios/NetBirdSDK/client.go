@@ -21,6 +21,11 @@ type ConnectionListener interface {
 	peer.Listener
 }
 
+// AttentionListener export internal AttentionListener for mobile
+type AttentionListener interface {
+	peer.AttentionListener
+}
+
 // RouteListener export internal RouteListener for mobile
 type NetworkChangeListener interface {
 	listener.NetworkChangeListener
@@ -139,7 +144,12 @@ func (c *Client) GetStatusDetails() *StatusDetails {
 		}
 		peerInfos[n] = pi
 	}
-	return &StatusDetails{items: peerInfos, fqdn: fullStatus.LocalPeerState.FQDN, ip: fullStatus.LocalPeerState.IP}
+	return &StatusDetails{
+		items:        peerInfos,
+		fqdn:         fullStatus.LocalPeerState.FQDN,
+		ip:           fullStatus.LocalPeerState.IP,
+		loginExpired: fullStatus.ManagementState.LoginExpired,
+	}
 }
 
 // SetConnectionListener set the network connection listener
@@ -152,6 +162,22 @@ func (c *Client) RemoveConnectionListener() {
 	c.recorder.RemoveConnectionListener()
 }
 
+// SetAttentionListener set the listener notified when the client requires the attention of the user
+func (c *Client) SetAttentionListener(listener AttentionListener) {
+	c.recorder.SetAttentionListener(listener)
+}
+
+// RemoveAttentionListener remove attention listener
+func (c *Client) RemoveAttentionListener() {
+	c.recorder.RemoveAttentionListener()
+}
+
+// SetManagementUnreachableMinutes set the minutes without a connection to the Management service after which the
+// attention listener is notified
+func (c *Client) SetManagementUnreachableMinutes(minutes int) {
+	c.recorder.SetManagementUnreachableThreshold(time.Duration(minutes) * time.Minute)
+}
+
 func (c *Client) IsLoginRequired() bool {
 	var ctx context.Context
 	//nolint
@@ -168,7 +194,7 @@ func (c *Client) IsLoginRequired() bool {
 		ConfigPath: c.cfgFile,
 	})
 
-	needsLogin, _ := internal.IsLoginRequired(ctx, cfg.PrivateKey, cfg.ManagementURL, cfg.SSHKey)
+	needsLogin, _ := internal.IsLoginRequired(ctx, cfg)
 	return needsLogin
 }
 
@@ -18,9 +18,10 @@ type PeerInfoCollection interface {
 
 // StatusDetails is the implementation of the PeerInfoCollection
 type StatusDetails struct {
-	items []PeerInfo
-	fqdn  string
-	ip    string
+	items        []PeerInfo
+	fqdn         string
+	ip           string
+	loginExpired bool
 }
 
 // Add new PeerInfo to the collection
@@ -48,3 +49,8 @@ func (array StatusDetails) GetFQDN() string {
 func (array StatusDetails) GetIP() string {
 	return array.ip
 }
+
+// IsLoginExpired return true if management limited the network access until the user logs in again
+func (array StatusDetails) IsLoginExpired() bool {
+	return array.loginExpired
+}
@@ -71,6 +71,43 @@ func (p *Preferences) SetPreSharedKey(key string) {
 	p.configInput.PreSharedKey = &key
 }
 
+// GetAttestationProvider read the attestation provider from config file
+func (p *Preferences) GetAttestationProvider() (string, error) {
+	if p.configInput.AttestationProvider != nil {
+		return *p.configInput.AttestationProvider, nil
+	}
+
+	cfg, err := internal.ReadConfig(p.configInput.ConfigPath)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AttestationProvider, err
+}
+
+// SetAttestationProvider store the given provider name and wait for commit.
+// The signer of the provider must be registered with RegisterAttestationSigner before the commit
+func (p *Preferences) SetAttestationProvider(provider string) {
+	p.configInput.AttestationProvider = &provider
+}
+
+// GetPostQuantumEnabled read the post-quantum pre-shared key negotiation setting from config file
+func (p *Preferences) GetPostQuantumEnabled() (bool, error) {
+	if p.configInput.PostQuantumEnabled != nil {
+		return *p.configInput.PostQuantumEnabled, nil
+	}
+
+	cfg, err := internal.ReadConfig(p.configInput.ConfigPath)
+	if err != nil {
+		return false, err
+	}
+	return cfg.PostQuantumEnabled, err
+}
+
+// SetPostQuantumEnabled store the given setting and wait for commit
+func (p *Preferences) SetPostQuantumEnabled(enabled bool) {
+	p.configInput.PostQuantumEnabled = &enabled
+}
+
 // Commit write out the changes into config file
 func (p *Preferences) Commit() error {
 	_, err := internal.UpdateOrCreateConfig(p.configInput)
@@ -3,6 +3,10 @@ package server
 import (
 	"context"
 	"fmt"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,14 +17,29 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	gstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/FlintyLemming/netbird/client/internal"
+	"github.com/FlintyLemming/netbird/client/internal/eventlog"
+	"github.com/FlintyLemming/netbird/client/internal/logbuffer"
 	"github.com/FlintyLemming/netbird/client/internal/peer"
+	"github.com/FlintyLemming/netbird/client/internal/probe"
+	"github.com/FlintyLemming/netbird/client/internal/profiling"
+	"github.com/FlintyLemming/netbird/client/internal/routeselector"
+	"github.com/FlintyLemming/netbird/client/internal/usage"
+	"github.com/FlintyLemming/netbird/client/internal/wol"
 	"github.com/FlintyLemming/netbird/client/proto"
 	"github.com/FlintyLemming/netbird/version"
 )
 
+const (
+	// eventLogFile is the file of the daemon event log, stored in the directory of the config
+	eventLogFile = "events.jsonl"
+	// usageFile is the file of the daily traffic rollups, stored in the directory of the config
+	usageFile = "usage.json"
+)
+
 // Server for service control.
 type Server struct {
 	rootCtx   context.Context
@@ -37,6 +56,18 @@ type Server struct {
 	proto.UnimplementedDaemonServiceServer
 
 	statusRecorder *peer.Status
+	routeSelector  *routeselector.RouteSelector
+	eventLog       *eventlog.Log
+	usageTracker   *usage.Tracker
+
+	logBuffer *logbuffer.Buffer
+	// logLevelRevert restores previousLogLevel once a temporary log level change expires
+	logLevelRevert   *time.Timer
+	previousLogLevel log.Level
+
+	// profiling serves the pprof and expvar endpoints while enabled, resourceSampler measures the CPU of the daemon
+	profiling       *profiling.Server
+	resourceSampler *profiling.Sampler
 }
 
 type oauthAuthFlow struct {
@@ -48,12 +79,88 @@ type oauthAuthFlow struct {
 
 // New server instance constructor.
 func New(ctx context.Context, configPath, logFile string) *Server {
-	return &Server{
+	// the events are kept next to the config to survive restarts of the daemon
+	eventLog := eventlog.New(filepath.Join(filepath.Dir(configPath), eventLogFile), eventlog.DefaultMaxEvents, eventlog.DefaultMaxAge)
+	if err := eventLog.Load(); err != nil {
+		log.Warnf("failed loading the event log: %v", err)
+	}
+
+	usageTracker := usage.New(filepath.Join(filepath.Dir(configPath), usageFile), usage.DefaultMaxDays)
+	if err := usageTracker.Load(); err != nil {
+		log.Warnf("failed loading the usage: %v", err)
+	}
+
+	// the latest log lines are kept in memory to be retrieved without access to the log file
+	logBuffer := logbuffer.New(logbuffer.DefaultSize)
+	log.AddHook(logBuffer)
+
+	s := &Server{
 		rootCtx: ctx,
 		latestConfigInput: internal.ConfigInput{
 			ConfigPath: configPath,
 		},
-		logFile: logFile,
+		logFile:         logFile,
+		eventLog:        eventLog,
+		usageTracker:    usageTracker,
+		logBuffer:       logBuffer,
+		profiling:       profiling.NewServer(),
+		resourceSampler: profiling.NewSampler(),
+	}
+	go s.sampleUsage(ctx)
+	go s.sampleResources(ctx)
+	return s
+}
+
+// sampleResources measures the CPU used by the daemon every sample interval, reported in the status, until the
+// context is done. The profiling endpoints are disabled with it
+func (s *Server) sampleResources(ctx context.Context) {
+	ticker := time.NewTicker(profiling.DefaultSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.profiling.Disable()
+			return
+		}
+
+		usage, err := s.resourceSampler.Sample()
+		if err != nil {
+			log.Debugf("failed sampling the resources: %v", err)
+			continue
+		}
+		log.Tracef("daemon used %.1f%% CPU with %d goroutines", usage.CPUPercent, usage.Goroutines)
+	}
+}
+
+// sampleUsage accounts the traffic with the peers every sample interval and persists it until the context is done
+func (s *Server) sampleUsage(ctx context.Context) {
+	ticker := time.NewTicker(usage.DefaultSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := s.usageTracker.Save(); err != nil {
+				log.Warnf("failed saving the usage: %v", err)
+			}
+			return
+		}
+
+		s.mutex.Lock()
+		recorder := s.statusRecorder
+		s.mutex.Unlock()
+
+		if recorder != nil {
+			if err := recorder.SampleUsage(); err != nil {
+				log.Debugf("failed sampling the usage: %v", err)
+			}
+		}
+		if err := s.usageTracker.Save(); err != nil {
+			log.Warnf("failed saving the usage: %v", err)
+		}
 	}
 }
 
@@ -99,13 +206,14 @@ func (s *Server) Start() error {
 	s.config = config
 
 	if s.statusRecorder == nil {
-		s.statusRecorder = peer.NewRecorder(config.ManagementURL.String())
+		s.statusRecorder = s.newStatusRecorder(config.ManagementURL.String())
 	} else {
 		s.statusRecorder.UpdateManagementAddress(config.ManagementURL.String())
 	}
 
+	routeSelector := s.getRouteSelector()
 	go func() {
-		if err := internal.RunClient(ctx, config, s.statusRecorder); err != nil {
+		if err := internal.RunClient(ctx, config, s.statusRecorder, routeSelector); err != nil {
 			log.Errorf("init connections: %v", err)
 		}
 	}()
@@ -187,6 +295,95 @@ func (s *Server) Login(callerCtx context.Context, msg *proto.LoginRequest) (*pro
 		ctx = context.WithValue(ctx, system.DeviceNameCtxKey, msg.Hostname)
 	}
 
+	if msg.PostQuantumEnabled != nil {
+		inputConfig.PostQuantumEnabled = msg.PostQuantumEnabled
+		s.latestConfigInput.PostQuantumEnabled = msg.PostQuantumEnabled
+	}
+
+	if msg.FlowCollectorURL != nil {
+		inputConfig.FlowCollectorURL = msg.FlowCollectorURL
+		s.latestConfigInput.FlowCollectorURL = msg.FlowCollectorURL
+	}
+
+	if msg.ImportBGPRoutes != nil {
+		inputConfig.ImportBGPRoutes = msg.ImportBGPRoutes
+		s.latestConfigInput.ImportBGPRoutes = msg.ImportBGPRoutes
+	}
+
+	if msg.MaxConcurrentConnections != nil {
+		maxConnections := int(*msg.MaxConcurrentConnections)
+		inputConfig.MaxConcurrentConnections = &maxConnections
+		s.latestConfigInput.MaxConcurrentConnections = &maxConnections
+	}
+
+	if msg.DefaultDeny != nil {
+		inputConfig.DefaultDeny = msg.DefaultDeny
+		s.latestConfigInput.DefaultDeny = msg.DefaultDeny
+	}
+
+	if msg.DisableSharedConnection != nil {
+		inputConfig.DisableSharedConnection = msg.DisableSharedConnection
+		s.latestConfigInput.DisableSharedConnection = msg.DisableSharedConnection
+	}
+
+	if msg.DetectLANRoutes != nil {
+		inputConfig.DetectLANRoutes = msg.DetectLANRoutes
+		s.latestConfigInput.DetectLANRoutes = msg.DetectLANRoutes
+	}
+
+	if msg.LazyConnectionThreshold != nil {
+		lazyConnThreshold := int(*msg.LazyConnectionThreshold)
+		inputConfig.LazyConnectionThreshold = &lazyConnThreshold
+		s.latestConfigInput.LazyConnectionThreshold = &lazyConnThreshold
+	}
+
+	if msg.StrictDNS != nil {
+		inputConfig.StrictDNS = msg.StrictDNS
+		s.latestConfigInput.StrictDNS = msg.StrictDNS
+	}
+
+	if msg.KillSwitch != nil {
+		inputConfig.KillSwitch = msg.KillSwitch
+		s.latestConfigInput.KillSwitch = msg.KillSwitch
+	}
+
+	if msg.CleanAllowedLocalPorts {
+		inputConfig.AllowedLocalPorts = make([]string, 0)
+		s.latestConfigInput.AllowedLocalPorts = nil
+	} else if msg.AllowedLocalPorts != nil {
+		inputConfig.AllowedLocalPorts = msg.AllowedLocalPorts
+		s.latestConfigInput.AllowedLocalPorts = msg.AllowedLocalPorts
+	}
+
+	if msg.CleanServices {
+		inputConfig.Services = make([]string, 0)
+		s.latestConfigInput.Services = nil
+	} else if msg.Services != nil {
+		inputConfig.Services = msg.Services
+		s.latestConfigInput.Services = msg.Services
+	}
+
+	if msg.InterfaceName != nil {
+		inputConfig.InterfaceName = msg.InterfaceName
+		s.latestConfigInput.InterfaceName = msg.InterfaceName
+	}
+
+	if msg.CleanExtraAddresses {
+		inputConfig.ExtraAddresses = make([]string, 0)
+		s.latestConfigInput.ExtraAddresses = nil
+	} else if msg.ExtraAddresses != nil {
+		inputConfig.ExtraAddresses = msg.ExtraAddresses
+		s.latestConfigInput.ExtraAddresses = msg.ExtraAddresses
+	}
+
+	if msg.CleanPriorityPeers {
+		inputConfig.PriorityPeers = make([]string, 0)
+		s.latestConfigInput.PriorityPeers = nil
+	} else if msg.PriorityPeers != nil {
+		inputConfig.PriorityPeers = msg.PriorityPeers
+		s.latestConfigInput.PriorityPeers = msg.PriorityPeers
+	}
+
 	s.mutex.Unlock()
 
 	inputConfig.PreSharedKey = &msg.PreSharedKey
@@ -385,13 +582,14 @@ func (s *Server) Up(callerCtx context.Context, _ *proto.UpRequest) (*proto.UpRes
 	}
 
 	if s.statusRecorder == nil {
-		s.statusRecorder = peer.NewRecorder(s.config.ManagementURL.String())
+		s.statusRecorder = s.newStatusRecorder(s.config.ManagementURL.String())
 	} else {
 		s.statusRecorder.UpdateManagementAddress(s.config.ManagementURL.String())
 	}
 
+	routeSelector := s.getRouteSelector()
 	go func() {
-		if err := internal.RunClient(ctx, s.config, s.statusRecorder); err != nil {
+		if err := internal.RunClient(ctx, s.config, s.statusRecorder, routeSelector); err != nil {
 			log.Errorf("run client connection: %v", err)
 			return
 		}
@@ -401,20 +599,50 @@ func (s *Server) Up(callerCtx context.Context, _ *proto.UpRequest) (*proto.UpRes
 }
 
 // Down engine work in the daemon.
-func (s *Server) Down(_ context.Context, _ *proto.DownRequest) (*proto.DownResponse, error) {
+func (s *Server) Down(_ context.Context, msg *proto.DownRequest) (*proto.DownResponse, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.actCancel == nil {
+	if s.actCancel == nil && !msg.GetRelease() {
 		return nil, fmt.Errorf("service is not up")
 	}
-	s.actCancel()
-	state := internal.CtxGetState(s.rootCtx)
-	state.Set(internal.StatusIdle)
+	if s.actCancel != nil {
+		s.actCancel()
+		state := internal.CtxGetState(s.rootCtx)
+		state.Set(internal.StatusIdle)
+	}
+
+	if msg.GetRelease() {
+		if err := s.releaseKillSwitch(); err != nil {
+			return nil, err
+		}
+	}
 
 	return &proto.DownResponse{}, nil
 }
 
+// releaseKillSwitch removes the kill switch rules and disables the kill switch in the config, so that the next up
+// doesn't install them again
+func (s *Server) releaseKillSwitch() error {
+	disabled := false
+	s.latestConfigInput.KillSwitch = &disabled
+	if s.config != nil {
+		config, err := internal.UpdateConfig(internal.ConfigInput{
+			ConfigPath: s.latestConfigInput.ConfigPath,
+			KillSwitch: &disabled,
+		})
+		if err != nil {
+			return fmt.Errorf("persist the kill switch release: %w", err)
+		}
+		s.config = config
+	}
+
+	if err := internal.ReleaseKillSwitch(); err != nil {
+		return gstatus.Errorf(codes.Internal, "release the kill switch: %v", err)
+	}
+	return nil
+}
+
 // Status starts engine work in the daemon.
 func (s *Server) Status(
 	_ context.Context,
@@ -431,17 +659,32 @@ func (s *Server) Status(
 	statusResponse := proto.StatusResponse{Status: string(status), DaemonVersion: version.NetbirdVersion()}
 
 	if s.statusRecorder == nil {
-		s.statusRecorder = peer.NewRecorder(s.config.ManagementURL.String())
+		s.statusRecorder = s.newStatusRecorder(s.config.ManagementURL.String())
 	} else {
 		s.statusRecorder.UpdateManagementAddress(s.config.ManagementURL.String())
 	}
 
+	statusResponse.LoginExpired = status == internal.StatusConnected && s.statusRecorder.IsLoginExpired()
+
 	if msg.GetFullPeerStatus {
+		if err := s.statusRecorder.RefreshWgStats(); err != nil {
+			log.Debugf("failed to read the WireGuard statistics of the peers: %v", err)
+		}
 		fullStatus := s.statusRecorder.GetFullStatus()
 		pbFullStatus := toProtoFullStatus(fullStatus)
+		resources := s.resourceSampler.Latest()
+		pbFullStatus.Resources.CpuPercent = resources.CPUPercent
+		pbFullStatus.Resources.Goroutines = int32(resources.Goroutines)
+		pbFullStatus.Resources.ProfilingAddress = s.profiling.Address()
 		statusResponse.FullStatus = pbFullStatus
 	}
 
+	if msg.ResetPeerStats {
+		if err := s.statusRecorder.ResetWgStats(); err != nil {
+			return nil, gstatus.Errorf(codes.Internal, "failed to reset the peer statistics: %v", err)
+		}
+	}
+
 	return &statusResponse, nil
 }
 
@@ -479,6 +722,365 @@ func (s *Server) GetConfig(_ context.Context, _ *proto.GetConfigRequest) (*proto
 	}, nil
 }
 
+// SelectRoutes accepts the routes of the networks again.
+func (s *Server) SelectRoutes(_ context.Context, msg *proto.SelectRoutesRequest) (*proto.SelectRoutesResponse, error) {
+	return s.updateRouteSelection(msg.GetNetworkIDs(), true)
+}
+
+// DeselectRoutes ignores the routes of the networks.
+func (s *Server) DeselectRoutes(_ context.Context, msg *proto.SelectRoutesRequest) (*proto.SelectRoutesResponse, error) {
+	return s.updateRouteSelection(msg.GetNetworkIDs(), false)
+}
+
+// updateRouteSelection applies the selection to the running client and persists it in the config
+func (s *Server) updateRouteSelection(netIDs []string, selected bool) (*proto.SelectRoutesResponse, error) {
+	if len(netIDs) == 0 {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "no network IDs provided")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.config == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "config is not defined, please call login command first")
+	}
+
+	routeSelector := s.getRouteSelector()
+	if selected {
+		routeSelector.SelectRoutes(netIDs)
+	} else {
+		routeSelector.DeselectRoutes(netIDs)
+	}
+
+	deselected := routeSelector.Deselected()
+	config, err := internal.UpdateConfig(internal.ConfigInput{
+		ConfigPath:       s.latestConfigInput.ConfigPath,
+		DeselectedRoutes: deselected,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("persist route selection: %w", err)
+	}
+	s.config = config
+
+	return &proto.SelectRoutesResponse{DeselectedNetworkIDs: deselected}, nil
+}
+
+// AdvertiseRoutes requests the Management service to route the networks through this peer.
+func (s *Server) AdvertiseRoutes(callerCtx context.Context, msg *proto.AdvertiseRoutesRequest) (*proto.AdvertiseRoutesResponse, error) {
+	if len(msg.GetNetworks()) == 0 {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "no networks provided")
+	}
+
+	s.mutex.Lock()
+	config := s.config
+	s.mutex.Unlock()
+
+	if config == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "config is not defined, please call login command first")
+	}
+
+	routes, err := internal.AdvertiseRoutes(callerCtx, config, msg.GetNetworkID(), msg.GetNetworks(), nil)
+	if err != nil {
+		log.Errorf("failed advertising routes: %v", err)
+		return nil, err
+	}
+
+	resp := &proto.AdvertiseRoutesResponse{}
+	for _, r := range routes {
+		resp.Routes = append(resp.Routes, &proto.AdvertisedRoute{
+			Network:         r.GetNetwork(),
+			NetworkID:       r.GetNetID(),
+			PendingApproval: r.GetPendingApproval(),
+		})
+	}
+	return resp, nil
+}
+
+// GetEvents returns the events recorded by the daemon since the requested time.
+func (s *Server) GetEvents(_ context.Context, msg *proto.GetEventsRequest) (*proto.GetEventsResponse, error) {
+	var since time.Time
+	if msg.GetSince() != nil {
+		since = msg.GetSince().AsTime()
+	}
+
+	resp := &proto.GetEventsResponse{}
+	for _, event := range s.eventLog.Since(since) {
+		resp.Events = append(resp.Events, &proto.Event{
+			Time:     timestamppb.New(event.Time),
+			Category: string(event.Category),
+			Message:  event.Message,
+		})
+	}
+	return resp, nil
+}
+
+// RunDiagnostics checks the connectivity to the NetBird services and the conditions for peer to peer connections.
+func (s *Server) RunDiagnostics(callerCtx context.Context, _ *proto.RunDiagnosticsRequest) (*proto.RunDiagnosticsResponse, error) {
+	s.mutex.Lock()
+	config := s.config
+	s.mutex.Unlock()
+
+	if config == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "config is not defined, please call login command first")
+	}
+
+	report, err := internal.RunDiagnostics(callerCtx, config)
+	if err != nil {
+		log.Errorf("failed running diagnostics: %v", err)
+		return nil, err
+	}
+
+	resp := &proto.RunDiagnosticsResponse{Findings: report.Findings}
+	for _, check := range report.Checks {
+		resp.Checks = append(resp.Checks, &proto.DiagnosticCheck{
+			Name:   check.Name,
+			Status: string(check.Status),
+			Detail: check.Detail,
+		})
+	}
+	return resp, nil
+}
+
+// WakeOnLAN sends the magic packet of the machine to the Wake-on-LAN relay of the routing peer.
+func (s *Server) WakeOnLAN(_ context.Context, msg *proto.WakeOnLANRequest) (*proto.WakeOnLANResponse, error) {
+	mac, err := wol.ParseMAC(msg.GetMac())
+	if err != nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "invalid MAC address: %v", err)
+	}
+
+	s.mutex.Lock()
+	statusRecorder := s.statusRecorder
+	s.mutex.Unlock()
+
+	if statusRecorder == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "the client is not running, please call up command first")
+	}
+
+	relayPeer, ok := findPeer(statusRecorder.GetFullStatus().Peers, msg.GetVia())
+	if !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "peer %s not found", msg.GetVia())
+	}
+	if relayPeer.ConnStatus != peer.StatusConnected {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "peer %s is not connected", msg.GetVia())
+	}
+
+	relayIP, err := netip.ParseAddr(relayPeer.IP)
+	if err != nil {
+		return nil, gstatus.Errorf(codes.Internal, "invalid IP of peer %s: %v", msg.GetVia(), err)
+	}
+
+	if err := wol.Send(relayIP, mac); err != nil {
+		log.Errorf("failed sending Wake-on-LAN packet to %s: %v", relayIP, err)
+		return nil, gstatus.Errorf(codes.Internal, "send magic packet: %v", err)
+	}
+	log.Infof("sent Wake-on-LAN packet for %s to %s", mac, relayIP)
+
+	return &proto.WakeOnLANResponse{RelayIP: relayIP.String()}, nil
+}
+
+// ProbePeer sends a probe to the peer over the tunnel and reports the round trip time and the connection path.
+func (s *Server) ProbePeer(ctx context.Context, msg *proto.ProbePeerRequest) (*proto.ProbePeerResponse, error) {
+	protocol, err := probe.ParseProtocol(msg.GetProtocol())
+	if err != nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if protocol == probe.ProtocolTCP && (msg.GetPort() < 1 || msg.GetPort() > 65535) {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "a port between 1 and 65535 is required by TCP probes")
+	}
+
+	s.mutex.Lock()
+	statusRecorder := s.statusRecorder
+	s.mutex.Unlock()
+
+	if statusRecorder == nil {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "the client is not running, please call up command first")
+	}
+
+	probedPeer, ok := findPeer(statusRecorder.GetFullStatus().Peers, msg.GetPeer())
+	if !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "peer %s not found", msg.GetPeer())
+	}
+	if probedPeer.ConnStatus != peer.StatusConnected {
+		return nil, gstatus.Errorf(codes.FailedPrecondition, "peer %s is not connected", msg.GetPeer())
+	}
+
+	peerIP, err := netip.ParseAddr(probedPeer.IP)
+	if err != nil {
+		return nil, gstatus.Errorf(codes.Internal, "invalid IP of peer %s: %v", msg.GetPeer(), err)
+	}
+
+	rtt, err := probe.Probe(ctx, peerIP, protocol, int(msg.GetPort()), probe.DefaultTimeout)
+	if err != nil {
+		log.Debugf("probe of peer %s failed: %v", peerIP, err)
+		return nil, gstatus.Errorf(codes.Unavailable, "probe peer %s: %v", msg.GetPeer(), err)
+	}
+
+	// the path is read after the probe, the connection may have switched to a relay meanwhile
+	if probedPeer, ok = findPeer(statusRecorder.GetFullStatus().Peers, probedPeer.IP); !ok {
+		return nil, gstatus.Errorf(codes.NotFound, "peer %s not found", msg.GetPeer())
+	}
+
+	return &proto.ProbePeerResponse{
+		IP:      probedPeer.IP,
+		Fqdn:    probedPeer.FQDN,
+		Rtt:     durationpb.New(rtt),
+		Relayed: probedPeer.Relayed,
+	}, nil
+}
+
+// SetLogLevel changes the log level of the daemon. With a duration the level in place before the first of the
+// temporary changes is restored once the duration elapses.
+func (s *Server) SetLogLevel(_ context.Context, msg *proto.SetLogLevelRequest) (*proto.SetLogLevelResponse, error) {
+	level, err := log.ParseLevel(msg.GetLevel())
+	if err != nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	duration := msg.GetDuration().AsDuration()
+	if duration < 0 {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "the duration must not be negative")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previousLevel := log.GetLevel()
+	if s.logLevelRevert != nil {
+		// a temporary change is in place, the level to restore is the one before it
+		s.logLevelRevert.Stop()
+		s.logLevelRevert = nil
+		previousLevel = s.previousLogLevel
+	}
+
+	log.SetLevel(level)
+	resp := &proto.SetLogLevelResponse{PreviousLevel: previousLevel.String()}
+
+	if duration == 0 {
+		log.Infof("log level set to %s", level)
+		return resp, nil
+	}
+
+	s.previousLogLevel = previousLevel
+	var revertTimer *time.Timer
+	revertTimer = time.AfterFunc(duration, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if s.logLevelRevert != revertTimer {
+			return
+		}
+		s.logLevelRevert = nil
+		log.SetLevel(previousLevel)
+		log.Infof("log level restored to %s", previousLevel)
+	})
+	s.logLevelRevert = revertTimer
+
+	resp.RevertAt = timestamppb.New(time.Now().Add(duration))
+	log.Infof("log level set to %s for %s", level, duration)
+	return resp, nil
+}
+
+// GetLogs returns the latest log lines kept in memory by the daemon.
+func (s *Server) GetLogs(_ context.Context, msg *proto.GetLogsRequest) (*proto.GetLogsResponse, error) {
+	return &proto.GetLogsResponse{
+		Lines: s.logBuffer.Lines(int(msg.GetLines())),
+		Level: log.GetLevel().String(),
+	}, nil
+}
+
+// GetUsage returns the daily traffic over the NetBird interface, in total and per peer
+func (s *Server) GetUsage(_ context.Context, msg *proto.GetUsageRequest) (*proto.GetUsageResponse, error) {
+	s.mutex.Lock()
+	recorder := s.statusRecorder
+	s.mutex.Unlock()
+
+	// the traffic since the last sample is included
+	if recorder != nil {
+		if err := recorder.SampleUsage(); err != nil {
+			log.Debugf("failed sampling the usage: %v", err)
+		}
+	}
+
+	resp := &proto.GetUsageResponse{}
+	for _, day := range s.usageTracker.Days(int(msg.GetDays())) {
+		resp.Days = append(resp.Days, toProtoDailyUsage(day))
+	}
+	return resp, nil
+}
+
+// SetProfiling serves the pprof and expvar endpoints of the daemon on a loopback address, or stops serving them
+func (s *Server) SetProfiling(_ context.Context, msg *proto.SetProfilingRequest) (*proto.SetProfilingResponse, error) {
+	if !msg.GetEnabled() {
+		s.profiling.Disable()
+		return &proto.SetProfilingResponse{}, nil
+	}
+
+	address, err := s.profiling.Enable(msg.GetAddress())
+	if err != nil {
+		return nil, gstatus.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return &proto.SetProfilingResponse{Address: address}, nil
+}
+
+func toProtoDailyUsage(day usage.Day) *proto.DailyUsage {
+	pbDay := &proto.DailyUsage{
+		Date:    day.Date,
+		BytesRx: int64(day.RxBytes),
+		BytesTx: int64(day.TxBytes),
+	}
+	for pubKey, peerUsage := range day.Peers {
+		pbDay.Peers = append(pbDay.Peers, &proto.PeerUsage{
+			PubKey:  pubKey,
+			Fqdn:    peerUsage.FQDN,
+			BytesRx: int64(peerUsage.RxBytes),
+			BytesTx: int64(peerUsage.TxBytes),
+		})
+	}
+	sort.Slice(pbDay.Peers, func(i, j int) bool {
+		totalI := pbDay.Peers[i].BytesRx + pbDay.Peers[i].BytesTx
+		totalJ := pbDay.Peers[j].BytesRx + pbDay.Peers[j].BytesTx
+		if totalI != totalJ {
+			return totalI > totalJ
+		}
+		return pbDay.Peers[i].PubKey < pbDay.Peers[j].PubKey
+	})
+	return pbDay
+}
+
+// findPeer returns the peer with the NetBird IP, FQDN or DNS label
+func findPeer(peers []peer.State, name string) (peer.State, bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, p := range peers {
+		fqdn := strings.TrimSuffix(strings.ToLower(p.FQDN), ".")
+		label, _, _ := strings.Cut(fqdn, ".")
+		if p.IP == name || fqdn == name || label == name {
+			return p, true
+		}
+	}
+	return peer.State{}, false
+}
+
+// newStatusRecorder creates the status recorder of the daemon recording the connection changes to the event log
+func (s *Server) newStatusRecorder(mgmAddress string) *peer.Status {
+	recorder := peer.NewRecorder(mgmAddress)
+	recorder.SetEventLog(s.eventLog)
+	recorder.SetUsageTracker(s.usageTracker)
+	return recorder
+}
+
+// getRouteSelector returns the route selector shared with the running client, initialized from the config.
+// The mutex must be held by the caller
+func (s *Server) getRouteSelector() *routeselector.RouteSelector {
+	if s.routeSelector == nil {
+		var deselected []string
+		if s.config != nil {
+			deselected = s.config.DeselectedRoutes
+		}
+		s.routeSelector = routeselector.New(deselected)
+	}
+	return s.routeSelector
+}
+
 func toProtoFullStatus(fullStatus peer.FullStatus) *proto.FullStatus {
 	pbFullStatus := proto.FullStatus{
 		ManagementState: &proto.ManagementState{},
@@ -489,6 +1091,10 @@ func toProtoFullStatus(fullStatus peer.FullStatus) *proto.FullStatus {
 
 	pbFullStatus.ManagementState.URL = fullStatus.ManagementState.URL
 	pbFullStatus.ManagementState.Connected = fullStatus.ManagementState.Connected
+	if !fullStatus.ManagementState.MaintenanceUntil.IsZero() {
+		pbFullStatus.ManagementState.MaintenanceUntil = timestamppb.New(fullStatus.ManagementState.MaintenanceUntil)
+		pbFullStatus.ManagementState.MaintenanceMessage = fullStatus.ManagementState.MaintenanceMessage
+	}
 
 	pbFullStatus.SignalState.URL = fullStatus.SignalState.URL
 	pbFullStatus.SignalState.Connected = fullStatus.SignalState.Connected
@@ -497,6 +1103,9 @@ func toProtoFullStatus(fullStatus peer.FullStatus) *proto.FullStatus {
 	pbFullStatus.LocalPeerState.PubKey = fullStatus.LocalPeerState.PubKey
 	pbFullStatus.LocalPeerState.KernelInterface = fullStatus.LocalPeerState.KernelInterface
 	pbFullStatus.LocalPeerState.Fqdn = fullStatus.LocalPeerState.FQDN
+	pbFullStatus.LocalPeerState.AllowedLocalPorts = fullStatus.LocalPeerState.AllowedLocalPorts
+	pbFullStatus.LocalPeerState.Services = fullStatus.LocalPeerState.Services
+	pbFullStatus.LocalPeerState.Labels = fullStatus.LocalPeerState.Labels
 
 	for _, peerState := range fullStatus.Peers {
 		pbPeerState := &proto.PeerState{
@@ -509,8 +1118,51 @@ func toProtoFullStatus(fullStatus peer.FullStatus) *proto.FullStatus {
 			LocalIceCandidateType:  peerState.LocalIceCandidateType,
 			RemoteIceCandidateType: peerState.RemoteIceCandidateType,
 			Fqdn:                   peerState.FQDN,
+			Groups:                 peerState.Groups,
+			BytesRx:                peerState.BytesRx,
+			BytesTx:                peerState.BytesTx,
+			IceRestarts:            int32(peerState.IceRestarts),
+			Services:               peerState.Services,
+			Labels:                 peerState.Labels,
+			Lazy:                   peerState.Lazy,
+		}
+		if !peerState.LastWireguardHandshake.IsZero() {
+			pbPeerState.LastWireguardHandshake = timestamppb.New(peerState.LastWireguardHandshake)
 		}
 		pbFullStatus.Peers = append(pbFullStatus.Peers, pbPeerState)
 	}
+
+	for _, component := range fullStatus.Components {
+		pbComponent := &proto.ComponentHealth{
+			Name:      component.Name,
+			Healthy:   component.Healthy,
+			LastError: component.LastError,
+			Restarts:  int32(component.Restarts),
+		}
+		if !component.LastSeen.IsZero() {
+			pbComponent.LastSeen = timestamppb.New(component.LastSeen)
+		}
+		if !component.LastErrorAt.IsZero() {
+			pbComponent.LastErrorAt = timestamppb.New(component.LastErrorAt)
+		}
+		pbFullStatus.Components = append(pbFullStatus.Components, pbComponent)
+	}
+
+	for _, route := range fullStatus.Routes {
+		pbFullStatus.Routes = append(pbFullStatus.Routes, &proto.RouteState{
+			NetworkID:    route.NetID,
+			Network:      route.Network.String(),
+			Peer:         route.Peer,
+			LoadBalanced: route.LoadBalanced,
+		})
+	}
+
+	pbFullStatus.Resources = &proto.ResourceUsage{
+		Peers:             int32(fullStatus.Resources.Peers),
+		MaterializedPeers: int32(fullStatus.Resources.MaterializedPeers),
+		LazyConnections:   fullStatus.Resources.LazyConnections,
+		HeapAllocBytes:    fullStatus.Resources.HeapAllocBytes,
+		SysBytes:          fullStatus.Resources.SysBytes,
+	}
 	return &pbFullStatus
 }
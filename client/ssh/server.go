@@ -249,7 +249,11 @@ func (srv *DefaultServer) Start() error {
 
 	publicKeyOption := ssh.PublicKeyAuth(srv.publicKeyHandler)
 	hostKeyPEM := ssh.HostKeyPEM(srv.hostKeyPEM)
-	err := ssh.Serve(srv.listener, srv.sessionHandler, publicKeyOption, hostKeyPEM)
+	subsystems := func(server *ssh.Server) error {
+		server.SubsystemHandlers = map[string]ssh.SubsystemHandler{TransferSubsystem: srv.transferHandler}
+		return nil
+	}
+	err := ssh.Serve(srv.listener, srv.sessionHandler, publicKeyOption, hostKeyPEM, subsystems)
 	if err != nil {
 		return err
 	}
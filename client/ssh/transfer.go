@@ -0,0 +1,459 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/gliderlabs/ssh"
+	log "github.com/sirupsen/logrus"
+)
+
+// TransferSubsystem is the name of the SSH subsystem used by netbird cp to copy files between peers
+const TransferSubsystem = "netbird-cp"
+
+// maxFrameSize limits the size of a control message of the transfer protocol
+const maxFrameSize = 64 * 1024
+
+const (
+	transferOpStat = "stat"
+	transferOpPut  = "put"
+	transferOpGet  = "get"
+)
+
+// transferRequest is sent by the client to start an operation.
+// For put requests the file content from Offset to Size follows the request
+type transferRequest struct {
+	Op     string
+	Path   string
+	Offset int64
+	Size   int64
+	Mode   uint32
+}
+
+// transferResponse is the server reply to a transferRequest.
+// For get requests the file content from the requested offset to Size follows the response
+type transferResponse struct {
+	Error  string `json:",omitempty"`
+	Exists bool
+	IsDir  bool
+	Size   int64
+	Mode   uint32
+}
+
+// ProgressFunc is called while a file is being transferred with the number of bytes of the file already present
+// on the destination and the total size of the file
+type ProgressFunc func(transferred, total int64)
+
+func writeFrame(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	_, err = w.Write(append(header, data...))
+	return err
+}
+
+func readFrame(r io.Reader, v any) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxFrameSize {
+		return fmt.Errorf("transfer frame of %d bytes exceeds the limit", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// progressWriter reports the progress of a copy
+type progressWriter struct {
+	w           io.Writer
+	transferred int64
+	total       int64
+	progress    ProgressFunc
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.transferred += int64(n)
+	if pw.progress != nil {
+		pw.progress(pw.transferred, pw.total)
+	}
+	return n, err
+}
+
+// transferHandler serves the file copy subsystem. Relative paths are resolved against the home directory of the
+// session user and new files are owned by that user
+func (srv *DefaultServer) transferHandler(session ssh.Session) {
+	srv.mu.Lock()
+	srv.sessions = append(srv.sessions, session)
+	srv.mu.Unlock()
+
+	defer func() {
+		_ = session.Close()
+	}()
+
+	localUser, err := userNameLookup(session.User())
+	if err != nil {
+		_ = writeFrame(session, transferResponse{Error: fmt.Sprintf("remote SSH server couldn't find local user %s", session.User())})
+		_ = session.Exit(1)
+		return
+	}
+
+	var req transferRequest
+	err = readFrame(session, &req)
+	if err != nil {
+		log.Warnf("failed reading file transfer request from %s: %v", session.RemoteAddr(), err)
+		_ = session.Exit(1)
+		return
+	}
+
+	filePath := req.Path
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(localUser.HomeDir, filePath)
+	}
+
+	log.Debugf("file transfer %s of %s for %s from host %s", req.Op, filePath, session.User(), session.RemoteAddr())
+
+	switch req.Op {
+	case transferOpStat:
+		err = serveStat(session, filePath)
+	case transferOpPut:
+		err = servePut(session, filePath, req, localUser)
+	case transferOpGet:
+		err = serveGet(session, filePath, req)
+	default:
+		err = fmt.Errorf("unsupported operation %s", req.Op)
+	}
+
+	if err != nil {
+		log.Warnf("failed file transfer %s of %s from host %s: %v", req.Op, filePath, session.RemoteAddr(), err)
+		_ = writeFrame(session, transferResponse{Error: err.Error()})
+		_ = session.Exit(1)
+		return
+	}
+	_ = session.Exit(0)
+}
+
+func serveStat(w io.Writer, filePath string) error {
+	info, err := os.Stat(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return writeFrame(w, transferResponse{})
+	}
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, transferResponse{Exists: true, IsDir: info.IsDir(), Size: info.Size(), Mode: uint32(info.Mode().Perm())})
+}
+
+func servePut(session ssh.Session, filePath string, req transferRequest, localUser *user.User) error {
+	if req.Offset < 0 || req.Offset > req.Size {
+		return fmt.Errorf("invalid offset %d for file of %d bytes", req.Offset, req.Size)
+	}
+
+	_, statErr := os.Stat(filePath)
+	created := errors.Is(statErr, os.ErrNotExist)
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, os.FileMode(req.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < req.Offset {
+		return fmt.Errorf("can't resume at offset %d, remote file has %d bytes", req.Offset, info.Size())
+	}
+
+	// drop anything past the resume point, it will be sent again
+	err = file.Truncate(req.Offset)
+	if err != nil {
+		return err
+	}
+	_, err = file.Seek(req.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if created {
+		chownToUser(filePath, localUser)
+	}
+
+	err = writeFrame(session, transferResponse{Exists: true, Size: req.Offset})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(file, session, req.Size-req.Offset)
+	if err != nil {
+		return err
+	}
+
+	err = file.Sync()
+	if err != nil {
+		return err
+	}
+
+	return writeFrame(session, transferResponse{Exists: true, Size: req.Size, Mode: req.Mode})
+}
+
+func serveGet(w io.Writer, filePath string, req transferRequest) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", filePath)
+	}
+	if req.Offset < 0 || req.Offset > info.Size() {
+		return fmt.Errorf("can't resume at offset %d, remote file has %d bytes", req.Offset, info.Size())
+	}
+
+	_, err = file.Seek(req.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	err = writeFrame(w, transferResponse{Exists: true, Size: size, Mode: uint32(info.Mode().Perm())})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.CopyN(w, file, size-req.Offset)
+	return err
+}
+
+func chownToUser(filePath string, localUser *user.User) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	uid, err := strconv.Atoi(localUser.Uid)
+	if err != nil {
+		return
+	}
+	gid, err := strconv.Atoi(localUser.Gid)
+	if err != nil {
+		return
+	}
+	err = os.Chown(filePath, uid, gid)
+	if err != nil {
+		log.Debugf("failed changing owner of %s to %s: %v", filePath, localUser.Username, err)
+	}
+}
+
+// transferSession runs a single request of the file copy subsystem
+type transferSession struct {
+	stdin  io.WriteCloser
+	stdout io.Reader
+	close  func() error
+}
+
+func (c *Client) newTransferSession(req transferRequest) (*transferSession, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open new session: %v", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	err = session.RequestSubsystem(TransferSubsystem)
+	if err != nil {
+		_ = session.Close()
+		return nil, fmt.Errorf("remote peer doesn't support file transfers: %v", err)
+	}
+
+	err = writeFrame(stdin, req)
+	if err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	return &transferSession{stdin: stdin, stdout: stdout, close: session.Close}, nil
+}
+
+func (s *transferSession) response() (*transferResponse, error) {
+	var resp transferResponse
+	err := readFrame(s.stdout, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading response from remote peer: %v", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+func (c *Client) stat(remotePath string) (*transferResponse, error) {
+	s, err := c.newTransferSession(transferRequest{Op: transferOpStat, Path: remotePath})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = s.close()
+	}()
+	return s.response()
+}
+
+// Upload copies a local file to the remote peer. When remotePath is empty or a directory the file keeps its name.
+// With resume set, bytes already present in the remote file are not sent again
+func (c *Client) Upload(localPath, remotePath string, resume bool, progress ProgressFunc) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", localPath)
+	}
+
+	if remotePath == "" {
+		remotePath = "."
+	}
+	remote, err := c.stat(remotePath)
+	if err != nil {
+		return err
+	}
+	if remote.IsDir {
+		remotePath = path.Join(filepath.ToSlash(remotePath), filepath.Base(localPath))
+		remote, err = c.stat(remotePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var offset int64
+	if resume && remote.Exists && remote.Size <= info.Size() {
+		offset = remote.Size
+	}
+
+	s, err := c.newTransferSession(transferRequest{
+		Op:     transferOpPut,
+		Path:   remotePath,
+		Offset: offset,
+		Size:   info.Size(),
+		Mode:   uint32(info.Mode().Perm()),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.close()
+	}()
+
+	_, err = s.response()
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	pw := &progressWriter{w: s.stdin, transferred: offset, total: info.Size(), progress: progress}
+	_, err = io.CopyN(pw, file, info.Size()-offset)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.response()
+	return err
+}
+
+// Download copies a remote file to the local machine. When localPath is a directory the file keeps its name.
+// With resume set, bytes already present in the local file are not received again
+func (c *Client) Download(remotePath, localPath string, resume bool, progress ProgressFunc) error {
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(filepath.ToSlash(remotePath)))
+	}
+
+	var offset int64
+	if resume {
+		if info, err := os.Stat(localPath); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	s, err := c.newTransferSession(transferRequest{Op: transferOpGet, Path: remotePath, Offset: offset})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.close()
+	}()
+
+	remote, err := s.response()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(localPath, os.O_CREATE|os.O_WRONLY, os.FileMode(remote.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	err = file.Truncate(offset)
+	if err != nil {
+		return err
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	pw := &progressWriter{w: file, transferred: offset, total: remote.Size, progress: progress}
+	_, err = io.CopyN(pw, s.stdout, remote.Size-offset)
+	if err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTransferServer(t *testing.T) (*DefaultServer, []byte) {
+	t.Helper()
+
+	hostKey, err := GeneratePrivateKey(ED25519)
+	require.NoError(t, err)
+	server, err := newDefaultServer(hostKey, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	clientKey, err := GeneratePrivateKey(ED25519)
+	require.NoError(t, err)
+	clientPubKey, err := GeneratePublicKey(clientKey)
+	require.NoError(t, err)
+	require.NoError(t, server.AddAuthorizedKey("remotePeer", string(clientPubKey)))
+
+	go func() {
+		_ = server.Start()
+	}()
+	t.Cleanup(func() {
+		_ = server.Stop()
+	})
+
+	return server, clientKey
+}
+
+func TestClient_UploadDownload(t *testing.T) {
+	server, clientKey := startTransferServer(t)
+
+	currentUser, err := user.Current()
+	require.NoError(t, err)
+
+	client, err := DialWithKey(server.listener.Addr().String(), currentUser.Username, clientKey)
+	require.NoError(t, err)
+	defer client.Close()
+
+	content := make([]byte, 256*1024)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+
+	localDir := t.TempDir()
+	remoteDir := t.TempDir()
+	localFile := filepath.Join(localDir, "data.bin")
+	require.NoError(t, os.WriteFile(localFile, content, 0640))
+
+	var lastTransferred, lastTotal int64
+	progress := func(transferred, total int64) {
+		lastTransferred, lastTotal = transferred, total
+	}
+
+	err = client.Upload(localFile, remoteDir, false, progress)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), lastTransferred)
+	assert.Equal(t, int64(len(content)), lastTotal)
+
+	remoteFile := filepath.Join(remoteDir, "data.bin")
+	uploaded, err := os.ReadFile(remoteFile)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, uploaded), "uploaded file should match the local one")
+
+	downloadDir := t.TempDir()
+	err = client.Download(remoteFile, downloadDir, false, nil)
+	require.NoError(t, err)
+	downloaded, err := os.ReadFile(filepath.Join(downloadDir, "data.bin"))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, downloaded), "downloaded file should match the remote one")
+}
+
+func TestClient_UploadResume(t *testing.T) {
+	server, clientKey := startTransferServer(t)
+
+	currentUser, err := user.Current()
+	require.NoError(t, err)
+
+	client, err := DialWithKey(server.listener.Addr().String(), currentUser.Username, clientKey)
+	require.NoError(t, err)
+	defer client.Close()
+
+	content := make([]byte, 64*1024)
+	_, err = rand.Read(content)
+	require.NoError(t, err)
+
+	localFile := filepath.Join(t.TempDir(), "data.bin")
+	require.NoError(t, os.WriteFile(localFile, content, 0600))
+
+	// simulate an interrupted transfer
+	remoteFile := filepath.Join(t.TempDir(), "data.bin")
+	partial := len(content) / 3
+	require.NoError(t, os.WriteFile(remoteFile, content[:partial], 0600))
+
+	var firstTransferred int64 = -1
+	progress := func(transferred, total int64) {
+		if firstTransferred < 0 {
+			firstTransferred = transferred
+		}
+	}
+
+	err = client.Upload(localFile, remoteFile, true, progress)
+	require.NoError(t, err)
+	assert.Greater(t, firstTransferred, int64(partial), "already present bytes should not be sent again")
+
+	uploaded, err := os.ReadFile(remoteFile)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, uploaded), "resumed file should match the local one")
+}
@@ -2,6 +2,7 @@ package system
 
 import (
 	"context"
+	"net/netip"
 	"strings"
 
 	"google.golang.org/grpc/metadata"
@@ -31,6 +32,23 @@ type Info struct {
 	CPUs               int
 	WiretrusteeVersion string
 	UIVersion          string
+	// Capabilities is a list of optional features enabled on this peer
+	Capabilities []string
+	// FirewallCapabilities are the features of the firewall enforcing the firewall rules on this peer
+	FirewallCapabilities FirewallCapabilities
+	// Services are the services running on this peer advertised to the other peers
+	Services []Service
+	// LANNetworks are the networks of the LAN interfaces of this peer, offered as routes by the Management service
+	LANNetworks []netip.Prefix
+}
+
+// FirewallCapabilities are the features of the firewall of the peer reported to the Management service
+type FirewallCapabilities struct {
+	IPSet      bool
+	PortRanges bool
+	Reject     bool
+	IPv6       bool
+	SCTPAndGRE bool
 }
 
 // extractUserAgent extracts Netbird's agent (client) name and version from the outgoing context
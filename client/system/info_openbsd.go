@@ -0,0 +1,34 @@
+package system
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/version"
+)
+
+// GetInfo retrieves and parses the system information
+func GetInfo(ctx context.Context) *Info {
+	// the system name and its release, e.g. OpenBSD 7.4
+	out, err := exec.Command("uname", "-sr").Output()
+	if err != nil {
+		log.Warnf("failed to read the system information: %v", err)
+	}
+	osInfo := strings.Fields(string(out))
+	for len(osInfo) < 2 {
+		osInfo = append(osInfo, "unknown")
+	}
+
+	gio := &Info{Kernel: osInfo[0], OSVersion: osInfo[1], Core: osInfo[1], Platform: runtime.GOARCH, OS: osInfo[0], GoOS: runtime.GOOS, CPUs: runtime.NumCPU()}
+	systemHostname, _ := os.Hostname()
+	gio.Hostname = extractDeviceName(ctx, systemHostname)
+	gio.WiretrusteeVersion = version.NetbirdVersion()
+	gio.UIVersion = extractUserAgent(ctx)
+
+	return gio
+}
@@ -0,0 +1,99 @@
+package system
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// virtualInterfacePrefixes are the name prefixes of the interfaces of containers, virtual machines and VPNs.
+// Their networks aren't part of the LAN of the peer
+var virtualInterfacePrefixes = []string{
+	"wt", "nb", "utun", "tun", "tap", "docker", "br-", "bridge", "veth", "virbr", "vmnet", "vboxnet", "vEthernet",
+	"cni", "flannel", "cali", "cilium", "kube", "tailscale", "zt", "wg",
+}
+
+// lanInterface is a network interface with the prefixes of its addresses
+type lanInterface struct {
+	name     string
+	flags    net.Flags
+	prefixes []netip.Prefix
+}
+
+// DetectLANNetworks returns the private IPv4 networks of the physical interfaces of the host, skipping the
+// interface named excludedInterface, e.g. the NetBird interface
+func DetectLANNetworks(excludedInterface string) []netip.Prefix {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		log.Warnf("failed listing the network interfaces: %v", err)
+		return nil
+	}
+
+	interfaces := make([]lanInterface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Debugf("failed listing the addresses of the interface %s: %v", iface.Name, err)
+			continue
+		}
+
+		lanIface := lanInterface{name: iface.Name, flags: iface.Flags}
+		for _, addr := range addrs {
+			prefix, err := netip.ParsePrefix(addr.String())
+			if err != nil {
+				continue
+			}
+			lanIface.prefixes = append(lanIface.prefixes, prefix)
+		}
+		interfaces = append(interfaces, lanIface)
+	}
+
+	return lanNetworks(interfaces, excludedInterface)
+}
+
+// lanNetworks returns the sorted private IPv4 networks of the interfaces which are up, not virtual and broadcast
+// capable, without duplicates. Host and point-to-point prefixes, i.e. /31 and /32, aren't LAN networks
+func lanNetworks(interfaces []lanInterface, excludedInterface string) []netip.Prefix {
+	var networks []netip.Prefix
+	seen := make(map[netip.Prefix]struct{})
+	for _, iface := range interfaces {
+		if iface.name == excludedInterface || isVirtualInterface(iface.name) ||
+			iface.flags&net.FlagUp == 0 || iface.flags&net.FlagLoopback != 0 || iface.flags&net.FlagPointToPoint != 0 {
+			continue
+		}
+
+		for _, prefix := range iface.prefixes {
+			addr := prefix.Addr().Unmap()
+			if !addr.Is4() || !addr.IsPrivate() || prefix.Bits() > 30 {
+				continue
+			}
+
+			network := netip.PrefixFrom(addr, prefix.Bits()).Masked()
+			if _, ok := seen[network]; ok {
+				continue
+			}
+			seen[network] = struct{}{}
+			networks = append(networks, network)
+		}
+	}
+
+	sort.Slice(networks, func(i, j int) bool {
+		if networks[i].Addr() != networks[j].Addr() {
+			return networks[i].Addr().Less(networks[j].Addr())
+		}
+		return networks[i].Bits() < networks[j].Bits()
+	})
+	return networks
+}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
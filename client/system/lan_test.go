@@ -0,0 +1,36 @@
+package system
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLANNetworks(t *testing.T) {
+	up := net.FlagUp | net.FlagBroadcast
+	interfaces := []lanInterface{
+		{name: "lo", flags: up | net.FlagLoopback, prefixes: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/8")}},
+		{name: "eth0", flags: up, prefixes: []netip.Prefix{
+			netip.MustParsePrefix("192.168.1.10/24"),
+			netip.MustParsePrefix("fd00::10/64"),
+			netip.MustParsePrefix("10.0.0.5/32"),
+		}},
+		{name: "eth1", flags: up, prefixes: []netip.Prefix{
+			netip.MustParsePrefix("192.168.1.11/24"),
+			netip.MustParsePrefix("203.0.113.10/24"),
+			netip.MustParsePrefix("10.10.0.1/16"),
+		}},
+		{name: "eth2", flags: net.FlagBroadcast, prefixes: []netip.Prefix{netip.MustParsePrefix("172.16.5.1/24")}},
+		{name: "wt0", flags: up, prefixes: []netip.Prefix{netip.MustParsePrefix("100.64.0.1/10")}},
+		{name: "custom-nb", flags: up, prefixes: []netip.Prefix{netip.MustParsePrefix("10.99.0.1/24")}},
+		{name: "docker0", flags: up, prefixes: []netip.Prefix{netip.MustParsePrefix("172.17.0.1/16")}},
+		{name: "ppp0", flags: net.FlagUp | net.FlagPointToPoint, prefixes: []netip.Prefix{netip.MustParsePrefix("10.20.0.2/24")}},
+	}
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.10.0.0/16"),
+		netip.MustParsePrefix("192.168.1.0/24"),
+	}, lanNetworks(interfaces, "custom-nb"))
+}
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// serviceLabelMatcher matches a lower case DNS label, the label of a service is published in its SRV records
+var serviceLabelMatcher = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Service is a service running on the peer advertised to the other peers, e.g. a web server published as
+// _http._tcp.<peer fqdn>
+type Service struct {
+	Label    string
+	Port     int
+	Protocol string
+}
+
+// ParseService parses a service in the label:port/protocol format, e.g. http:8080/tcp
+func ParseService(value string) (Service, error) {
+	label, portProtocol, found := strings.Cut(value, ":")
+	if !found {
+		return Service{}, fmt.Errorf("invalid service %q, expected label:port/protocol, e.g. http:8080/tcp", value)
+	}
+	port, protocol, found := strings.Cut(portProtocol, "/")
+	if !found {
+		return Service{}, fmt.Errorf("invalid service %q, expected label:port/protocol, e.g. http:8080/tcp", value)
+	}
+
+	service := Service{
+		Label:    strings.ToLower(label),
+		Protocol: strings.ToLower(protocol),
+	}
+	if !serviceLabelMatcher.MatchString(service.Label) {
+		return Service{}, fmt.Errorf("invalid label of service %q, expected a DNS label of letters, numbers and hyphens", value)
+	}
+	if service.Protocol != "tcp" && service.Protocol != "udp" {
+		return Service{}, fmt.Errorf("invalid protocol of service %q, expected tcp or udp", value)
+	}
+
+	var err error
+	service.Port, err = strconv.Atoi(port)
+	if err != nil || service.Port < 1 || service.Port > 65535 {
+		return Service{}, fmt.Errorf("invalid port of service %q, expected a number between 1 and 65535", value)
+	}
+	return service, nil
+}
+
+// ParseServices parses a list of services in the label:port/protocol format
+func ParseServices(values []string) ([]Service, error) {
+	services := make([]Service, 0, len(values))
+	for _, value := range values {
+		service, err := ParseService(value)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// String returns the service in the label:port/protocol format
+func (s Service) String() string {
+	return fmt.Sprintf("%s:%d/%s", s.Label, s.Port, s.Protocol)
+}
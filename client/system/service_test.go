@@ -0,0 +1,68 @@
+package system
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseService(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected Service
+		wantErr  bool
+	}{
+		{
+			name:     "tcp service",
+			value:    "http:8080/tcp",
+			expected: Service{Label: "http", Port: 8080, Protocol: "tcp"},
+		},
+		{
+			name:     "upper case label and protocol",
+			value:    "Syslog:514/UDP",
+			expected: Service{Label: "syslog", Port: 514, Protocol: "udp"},
+		},
+		{
+			name:    "missing label",
+			value:   "8080/tcp",
+			wantErr: true,
+		},
+		{
+			name:    "missing protocol",
+			value:   "http:8080",
+			wantErr: true,
+		},
+		{
+			name:    "invalid label",
+			value:   "_http:8080/tcp",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported protocol",
+			value:   "http:8080/sctp",
+			wantErr: true,
+		},
+		{
+			name:    "out of range port",
+			value:   "http:0/tcp",
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			service, err := ParseService(testCase.value)
+			if testCase.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, testCase.expected, service)
+		})
+	}
+}
+
+func TestServiceString(t *testing.T) {
+	require.Equal(t, "http:8080/tcp", Service{Label: "http", Port: 8080, Protocol: "tcp"}.String())
+}
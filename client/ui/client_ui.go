@@ -143,6 +143,7 @@ type serviceClient struct {
 	daemonVersion        string
 	updateIndicationLock sync.Mutex
 	isUpdateIconActive   bool
+	loginExpired         bool
 }
 
 // newServiceClient instance constructor
@@ -309,7 +310,7 @@ func (s *serviceClient) menuUpClick() error {
 		return err
 	}
 
-	if status.Status == string(internal.StatusConnected) {
+	if status.Status == string(internal.StatusConnected) && !status.LoginExpired {
 		log.Warnf("already connected")
 		return err
 	}
@@ -363,7 +364,7 @@ func (s *serviceClient) updateStatus() error {
 		defer s.updateIndicationLock.Unlock()
 
 		var systrayIconState bool
-		if status.Status == string(internal.StatusConnected) && !s.mUp.Disabled() {
+		if status.Status == string(internal.StatusConnected) && !status.LoginExpired && !s.mUp.Disabled() {
 			if !s.isUpdateIconActive {
 				systray.SetIcon(s.icConnected)
 			}
@@ -383,6 +384,22 @@ func (s *serviceClient) updateStatus() error {
 			systrayIconState = false
 		}
 
+		if status.LoginExpired != s.loginExpired {
+			s.loginExpired = status.LoginExpired
+			if s.loginExpired {
+				systray.SetTooltip("NetBird (Login expired)")
+				s.mStatus.SetTitle("Login expired")
+				// connecting again goes through the login flow
+				s.mUp.Enable()
+				s.app.SendNotification(fyne.NewNotification("NetBird login expired",
+					"Network access is limited until you log in again, click Connect to log in"))
+			} else if status.Status != string(internal.StatusConnected) {
+				systray.SetTooltip("NetBird (Disconnected)")
+				s.mStatus.SetTitle("Disconnected")
+				s.mDown.Disable()
+			}
+		}
+
 		// the updater struct notify by the upgrades available only, but if meanwhile the daemon has successfully
 		// updated must reset the mUpdate visibility state
 		if s.daemonVersion != status.DaemonVersion {
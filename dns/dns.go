@@ -31,6 +31,8 @@ type Config struct {
 	NameServerGroups []*NameServerGroup
 	// CustomZones contains a list of custom zone
 	CustomZones []CustomZone
+	// SearchDomains are the search domains assigned to the peer, ordered by priority
+	SearchDomains []string
 }
 
 // CustomZone represents a custom zone to be resolved by the dns server
@@ -39,13 +41,15 @@ type CustomZone struct {
 	Domain string
 	// Records custom zone records
 	Records []SimpleRecord
+	// SearchDomainDisabled indicates that the zone is only used to match queries and not added as search domain
+	SearchDomainDisabled bool
 }
 
-// SimpleRecord provides a simple DNS record specification for CNAME, A and AAAA records
+// SimpleRecord provides a simple DNS record specification for CNAME, A, AAAA, PTR, SRV and TXT records
 type SimpleRecord struct {
 	// Name domain name
 	Name string
-	// Type of record, 1 for A, 5 for CNAME, 28 for AAAA. see https://pkg.go.dev/github.com/miekg/dns@v1.1.41#pkg-constants
+	// Type of record, 1 for A, 5 for CNAME, 12 for PTR, 16 for TXT, 28 for AAAA, 33 for SRV. see https://pkg.go.dev/github.com/miekg/dns@v1.1.41#pkg-constants
 	Type int
 	// Class dns class, currently use the DefaultClass for all records
 	Class string
@@ -71,16 +75,30 @@ func (s SimpleRecord) Len() uint16 {
 			return 0
 		}
 		return net.IPv4len
-	case 5:
+	case 5, 12:
 		if emptyString || s.RData == "." {
 			return 1
 		}
 		return uint16(len(s.RData) + 1)
+	case 16:
+		// quoted strings without spaces, e.g. "env=prod", each one prefixed with its length
+		var length int
+		for _, field := range strings.Fields(s.RData) {
+			length += len(strings.Trim(field, `"`)) + 1
+		}
+		return uint16(length)
 	case 28:
 		if emptyString {
 			return 0
 		}
 		return net.IPv6len
+	case 33:
+		// priority, weight and port followed by the target, e.g. "0 0 8080 peer.netbird.cloud."
+		fields := strings.Fields(s.RData)
+		if len(fields) != 4 {
+			return 0
+		}
+		return uint16(6 + len(fields[3]) + 1)
 	default:
 		return 0
 	}
@@ -69,6 +69,12 @@ type NameServerGroup struct {
 	Enabled bool
 	// SearchDomainsEnabled indicates whether to add match domains to search domains list or not
 	SearchDomainsEnabled bool
+	// ForwardViaRoutes indicates that the nameservers are reachable only through the routes of the network, the
+	// queries for the domains are forwarded to them over the tunnel
+	ForwardViaRoutes bool
+	// Priority orders the nameserver groups distributed to a peer, the group with the lowest value is used when
+	// several groups are primary or share a domain
+	Priority int
 }
 
 // NameServer represents a DNS nameserver
@@ -144,6 +150,8 @@ func (g *NameServerGroup) Copy() *NameServerGroup {
 		Primary:              g.Primary,
 		Domains:              make([]string, len(g.Domains)),
 		SearchDomainsEnabled: g.SearchDomainsEnabled,
+		ForwardViaRoutes:     g.ForwardViaRoutes,
+		Priority:             g.Priority,
 	}
 
 	copy(nsGroup.NameServers, g.NameServers)
@@ -160,6 +168,8 @@ func (g *NameServerGroup) IsEqual(other *NameServerGroup) bool {
 		other.Description == g.Description &&
 		other.Primary == g.Primary &&
 		other.SearchDomainsEnabled == g.SearchDomainsEnabled &&
+		other.ForwardViaRoutes == g.ForwardViaRoutes &&
+		other.Priority == g.Priority &&
 		compareNameServerList(g.NameServers, other.NameServers) &&
 		compareGroupsList(g.Groups, other.Groups) &&
 		compareGroupsList(g.Domains, other.Domains)
@@ -0,0 +1,57 @@
+package encryption
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// attestationContext separates attestation signatures from any other signature made with the same key
+const attestationContext = "netbird-peer-attestation-v1"
+
+// AttestationDigest returns a SHA-256 digest binding the WireGuard public key of a peer to the time of signing.
+// The digest is what a hardware-backed key (TPM, Secure Enclave, Android Keystore) signs during login.
+func AttestationDigest(wgPubKey string, timestamp time.Time) []byte {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(timestamp.Unix()))
+
+	h := sha256.New()
+	h.Write([]byte(attestationContext))
+	h.Write([]byte(wgPubKey))
+	h.Write(ts[:])
+	return h.Sum(nil)
+}
+
+// VerifyAttestation checks that signature is a valid signature of the attestation digest made with the
+// PKIX, ASN.1 DER encoded public key. ECDSA (ASN.1), RSA (PKCS #1 v1.5) and Ed25519 keys are supported.
+func VerifyAttestation(publicKey []byte, wgPubKey string, timestamp time.Time, signature []byte) error {
+	pub, err := x509.ParsePKIXPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("failed parsing attestation public key: %v", err)
+	}
+
+	digest := AttestationDigest(wgPubKey, timestamp)
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest, signature) {
+			return fmt.Errorf("invalid attestation signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature); err != nil {
+			return fmt.Errorf("invalid attestation signature: %v", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest, signature) {
+			return fmt.Errorf("invalid attestation signature")
+		}
+	default:
+		return fmt.Errorf("unsupported attestation key type %T", pub)
+	}
+	return nil
+}
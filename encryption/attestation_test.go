@@ -0,0 +1,59 @@
+package encryption_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/encryption"
+)
+
+var _ = Describe("Attestation", func() {
+
+	var (
+		attestationKey *ecdsa.PrivateKey
+		publicKey      []byte
+		wgKey          wgtypes.Key
+	)
+
+	BeforeEach(func() {
+		var err error
+		attestationKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		publicKey, err = x509.MarshalPKIXPublicKey(attestationKey.Public())
+		Expect(err).NotTo(HaveOccurred())
+		wgKey, err = wgtypes.GenerateKey()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("verifying an attestation signature", func() {
+		Specify("should be successful when signed with the attestation key", func() {
+			now := time.Now()
+			digest := encryption.AttestationDigest(wgKey.PublicKey().String(), now)
+			signature, err := ecdsa.SignASN1(rand.Reader, attestationKey, digest)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encryption.VerifyAttestation(publicKey, wgKey.PublicKey().String(), now, signature)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Specify("should fail when the WireGuard key differs", func() {
+			now := time.Now()
+			digest := encryption.AttestationDigest(wgKey.PublicKey().String(), now)
+			signature, err := ecdsa.SignASN1(rand.Reader, attestationKey, digest)
+			Expect(err).NotTo(HaveOccurred())
+
+			otherKey, err := wgtypes.GenerateKey()
+			Expect(err).NotTo(HaveOccurred())
+
+			err = encryption.VerifyAttestation(publicKey, otherKey.PublicKey().String(), now, signature)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
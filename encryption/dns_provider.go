@@ -0,0 +1,328 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// challengeTTL is the TTL of the TXT records of the DNS-01 challenges
+const challengeTTL = 120
+
+// DNSProvider publishes the TXT records of the DNS-01 challenges in the zone of the domain. The fqdn of the record ends
+// with a dot
+type DNSProvider interface {
+	// Present creates the TXT record fqdn with the value
+	Present(ctx context.Context, fqdn, value string) error
+	// CleanUp removes the TXT record fqdn with the value
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+// DNSProviderFactory creates a DNS provider configured by its environment variables
+type DNSProviderFactory func() (DNSProvider, error)
+
+var (
+	dnsProvidersMu sync.RWMutex
+	dnsProviders   = map[string]DNSProviderFactory{
+		"cloudflare": newCloudflareProvider,
+		"exec":       newExecProvider,
+		"rfc2136":    newRFC2136Provider,
+	}
+)
+
+// RegisterDNSProvider makes a DNS provider available under the name, it replaces the provider registered with the same
+// name
+func RegisterDNSProvider(name string, factory DNSProviderFactory) {
+	dnsProvidersMu.Lock()
+	defer dnsProvidersMu.Unlock()
+	dnsProviders[name] = factory
+}
+
+// NewDNSProvider creates the DNS provider registered under the name
+func NewDNSProvider(name string) (DNSProvider, error) {
+	dnsProvidersMu.RLock()
+	factory, ok := dnsProviders[name]
+	dnsProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS provider %q, supported providers: %s", name, strings.Join(dnsProviderNames(), ", "))
+	}
+
+	provider, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("configure DNS provider %s: %w", name, err)
+	}
+	return provider, nil
+}
+
+func dnsProviderNames() []string {
+	dnsProvidersMu.RLock()
+	defer dnsProvidersMu.RUnlock()
+
+	var names []string
+	for name := range dnsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// execProvider runs the program of EXEC_PATH with the arguments "present" or "cleanup", the fqdn and the value of the
+// record, e.g. to update a DNS service without a supported provider
+type execProvider struct {
+	path string
+}
+
+func newExecProvider() (DNSProvider, error) {
+	path := os.Getenv("EXEC_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("EXEC_PATH is not set")
+	}
+	return &execProvider{path: path}, nil
+}
+
+func (p *execProvider) Present(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "present", fqdn, value)
+}
+
+func (p *execProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.run(ctx, "cleanup", fqdn, value)
+}
+
+func (p *execProvider) run(ctx context.Context, action, fqdn, value string) error {
+	out, err := exec.CommandContext(ctx, p.path, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("run %s %s: %w: %s", p.path, action, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// rfc2136Provider updates the zone with dynamic updates signed with a TSIG key, e.g. on BIND or Knot. The zone is
+// looked up on the name server when RFC2136_ZONE isn't set
+type rfc2136Provider struct {
+	nameserver    string
+	zone          string
+	tsigKey       string
+	tsigSecret    string
+	tsigAlgorithm string
+}
+
+func newRFC2136Provider() (DNSProvider, error) {
+	nameserver := os.Getenv("RFC2136_NAMESERVER")
+	if nameserver == "" {
+		return nil, fmt.Errorf("RFC2136_NAMESERVER is not set")
+	}
+	if _, _, err := net.SplitHostPort(nameserver); err != nil {
+		nameserver = net.JoinHostPort(nameserver, "53")
+	}
+
+	p := &rfc2136Provider{
+		nameserver:    nameserver,
+		tsigAlgorithm: dns.HmacSHA256,
+	}
+	if zone := os.Getenv("RFC2136_ZONE"); zone != "" {
+		p.zone = dns.Fqdn(zone)
+	}
+	if key := os.Getenv("RFC2136_TSIG_KEY"); key != "" {
+		p.tsigKey = dns.Fqdn(key)
+		p.tsigSecret = os.Getenv("RFC2136_TSIG_SECRET")
+	}
+	if algorithm := os.Getenv("RFC2136_TSIG_ALGORITHM"); algorithm != "" {
+		p.tsigAlgorithm = dns.Fqdn(algorithm)
+	}
+	return p, nil
+}
+
+func (p *rfc2136Provider) Present(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, true)
+}
+
+func (p *rfc2136Provider) CleanUp(ctx context.Context, fqdn, value string) error {
+	return p.update(ctx, fqdn, value, false)
+}
+
+func (p *rfc2136Provider) update(ctx context.Context, fqdn, value string, insert bool) error {
+	zone, err := p.findZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+
+	rr := &dns.TXT{
+		Hdr: dns.RR_Header{Name: fqdn, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: challengeTTL},
+		Txt: []string{value},
+	}
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+	if insert {
+		msg.Insert([]dns.RR{rr})
+	} else {
+		msg.Remove([]dns.RR{rr})
+	}
+
+	resp, err := p.exchange(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("update zone %s: %w", zone, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("update zone %s: %s", zone, dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// findZone returns the closest zone of the fqdn the name server is authoritative for
+func (p *rfc2136Provider) findZone(ctx context.Context, fqdn string) (string, error) {
+	if p.zone != "" {
+		return p.zone, nil
+	}
+
+	labels := dns.SplitDomainName(fqdn)
+	for i := range labels {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+		msg := new(dns.Msg)
+		msg.SetQuestion(name, dns.TypeSOA)
+		resp, err := p.exchange(ctx, msg)
+		if err != nil {
+			return "", fmt.Errorf("look up zone of %s: %w", fqdn, err)
+		}
+		for _, rr := range resp.Answer {
+			if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, name) {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no zone of %s found on %s", fqdn, p.nameserver)
+}
+
+func (p *rfc2136Provider) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	client := &dns.Client{Net: "tcp", Timeout: 10 * time.Second}
+	if p.tsigKey != "" {
+		client.TsigSecret = map[string]string{p.tsigKey: p.tsigSecret}
+		msg.SetTsig(p.tsigKey, p.tsigAlgorithm, 300, time.Now().Unix())
+	}
+	resp, _, err := client.ExchangeContext(ctx, msg, p.nameserver)
+	return resp, err
+}
+
+// cloudflareAPI is the base URL of the Cloudflare API
+var cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// cloudflareProvider manages the records with the Cloudflare API, the token of CLOUDFLARE_DNS_API_TOKEN requires
+// the Zone:Read and DNS:Edit permissions
+type cloudflareProvider struct {
+	token  string
+	client *http.Client
+}
+
+type cloudflareResponse struct {
+	Success bool              `json:"success"`
+	Errors  []json.RawMessage `json:"errors"`
+	Result  json.RawMessage   `json:"result"`
+}
+
+type cloudflareObject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func newCloudflareProvider() (DNSProvider, error) {
+	token := os.Getenv("CLOUDFLARE_DNS_API_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("CLOUDFLARE_DNS_API_TOKEN is not set")
+	}
+	return &cloudflareProvider{token: token, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (p *cloudflareProvider) Present(ctx context.Context, fqdn, value string) error {
+	zoneID, err := p.findZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+
+	record := map[string]interface{}{
+		"type":    "TXT",
+		"name":    strings.TrimSuffix(fqdn, "."),
+		"content": value,
+		"ttl":     challengeTTL,
+	}
+	return p.request(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", record, nil)
+}
+
+func (p *cloudflareProvider) CleanUp(ctx context.Context, fqdn, value string) error {
+	zoneID, err := p.findZone(ctx, fqdn)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"type": {"TXT"}, "name": {strings.TrimSuffix(fqdn, ".")}, "content": {value}}
+	var records []cloudflareObject
+	if err := p.request(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+query.Encode(), nil, &records); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := p.request(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+record.ID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findZone returns the ID of the closest zone of the fqdn in the account
+func (p *cloudflareProvider) findZone(ctx context.Context, fqdn string) (string, error) {
+	labels := dns.SplitDomainName(fqdn)
+	for i := range labels {
+		name := strings.Join(labels[i:], ".")
+		var zones []cloudflareObject
+		if err := p.request(ctx, http.MethodGet, "/zones?"+url.Values{"name": {name}}.Encode(), nil, &zones); err != nil {
+			return "", err
+		}
+		if len(zones) > 0 {
+			return zones[0].ID, nil
+		}
+	}
+	return "", fmt.Errorf("no zone of %s found in the Cloudflare account", fqdn)
+}
+
+func (p *cloudflareProvider) request(ctx context.Context, method, path string, body, result interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareAPI+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var cfResp cloudflareResponse
+	if err := json.NewDecoder(resp.Body).Decode(&cfResp); err != nil {
+		return fmt.Errorf("decode Cloudflare response of %s %s: %w", method, path, err)
+	}
+	if !cfResp.Success {
+		return fmt.Errorf("cloudflare request %s %s failed: %s", method, path, cfResp.Errors)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(cfResp.Result, result)
+}
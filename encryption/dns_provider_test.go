@@ -0,0 +1,243 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DNSProvider", func() {
+
+	const (
+		fqdn  = "_acme-challenge.netbird.example.com."
+		value = "challenge-value"
+	)
+
+	var cleanups []func()
+	cleanup := func(f func()) {
+		cleanups = append(cleanups, f)
+	}
+
+	AfterEach(func() {
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+		cleanups = nil
+	})
+
+	setEnv := func(key, value string) {
+		previous, ok := os.LookupEnv(key)
+		Expect(os.Setenv(key, value)).To(Succeed())
+		cleanup(func() {
+			if ok {
+				_ = os.Setenv(key, previous)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		})
+	}
+
+	Context("creating a provider", func() {
+		Specify("should fail for an unknown provider", func() {
+			_, err := NewDNSProvider("unknown")
+			Expect(err).To(HaveOccurred())
+		})
+
+		Specify("should fail when the provider isn't configured", func() {
+			setEnv("EXEC_PATH", "")
+			_, err := NewDNSProvider("exec")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("with the exec provider", func() {
+		Specify("should run the program with the action, the record name and its value", func() {
+			dir, err := os.MkdirTemp("", "netbird-exec-provider")
+			Expect(err).NotTo(HaveOccurred())
+			cleanup(func() { _ = os.RemoveAll(dir) })
+			out := filepath.Join(dir, "out")
+			script := filepath.Join(dir, "hook.sh")
+			Expect(os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+out+"\n"), 0700)).To(Succeed())
+			setEnv("EXEC_PATH", script)
+
+			provider, err := NewDNSProvider("exec")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Present(context.Background(), fqdn, value)).To(Succeed())
+			Expect(provider.CleanUp(context.Background(), fqdn, value)).To(Succeed())
+
+			data, err := os.ReadFile(out)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("present " + fqdn + " " + value + "\ncleanup " + fqdn + " " + value + "\n"))
+		})
+	})
+
+	Context("with the rfc2136 provider", func() {
+		const (
+			tsigKey    = "netbird."
+			tsigSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+		)
+
+		var (
+			mu      sync.Mutex
+			updates []*dns.Msg
+			addr    string
+		)
+
+		BeforeEach(func() {
+			updates = nil
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			addr = listener.Addr().String()
+
+			handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+				resp := new(dns.Msg)
+				resp.SetReply(r)
+				if r.IsTsig() != nil && w.TsigStatus() != nil {
+					resp.Rcode = dns.RcodeNotAuth
+				} else if r.Opcode == dns.OpcodeUpdate {
+					mu.Lock()
+					updates = append(updates, r)
+					mu.Unlock()
+				} else if r.Question[0].Name == "example.com." {
+					soa, _ := dns.NewRR("example.com. 3600 IN SOA ns.example.com. admin.example.com. 1 7200 3600 1209600 3600")
+					resp.Answer = append(resp.Answer, soa)
+				}
+				if r.IsTsig() != nil {
+					resp.SetTsig(tsigKey, dns.HmacSHA256, 300, int64(r.IsTsig().TimeSigned))
+				}
+				_ = w.WriteMsg(resp)
+			})
+			server := &dns.Server{
+				Listener:   listener,
+				Handler:    handler,
+				TsigSecret: map[string]string{tsigKey: tsigSecret},
+				// the default accept func rejects the updates
+				MsgAcceptFunc: func(dns.Header) dns.MsgAcceptAction { return dns.MsgAccept },
+			}
+			go func() { _ = server.ActivateAndServe() }()
+			cleanup(func() { _ = server.Shutdown() })
+
+			setEnv("RFC2136_NAMESERVER", addr)
+			setEnv("RFC2136_TSIG_KEY", tsigKey)
+			setEnv("RFC2136_TSIG_SECRET", tsigSecret)
+		})
+
+		Specify("should update the zone of the record with a signed update", func() {
+			provider, err := NewDNSProvider("rfc2136")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Present(context.Background(), fqdn, value)).To(Succeed())
+			Expect(provider.CleanUp(context.Background(), fqdn, value)).To(Succeed())
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(updates).To(HaveLen(2))
+			Expect(updates[0].Question[0].Name).To(Equal("example.com."))
+			Expect(updates[0].Ns).To(HaveLen(1))
+			Expect(updates[0].Ns[0].Header().Class).To(Equal(uint16(dns.ClassINET)))
+			Expect(updates[0].Ns[0].(*dns.TXT).Txt).To(Equal([]string{value}))
+			Expect(updates[1].Ns[0].Header().Class).To(Equal(uint16(dns.ClassNONE)), "the record should be removed")
+		})
+
+		Specify("should fail with an invalid TSIG secret", func() {
+			setEnv("RFC2136_TSIG_SECRET", "aW52YWxpZA==")
+			provider, err := NewDNSProvider("rfc2136")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Present(context.Background(), fqdn, value)).NotTo(Succeed())
+		})
+	})
+
+	Context("with the cloudflare provider", func() {
+		var (
+			mu      sync.Mutex
+			records map[string]map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			records = map[string]map[string]interface{}{}
+
+			reply := func(w http.ResponseWriter, result interface{}) {
+				data, _ := json.Marshal(result)
+				_ = json.NewEncoder(w).Encode(cloudflareResponse{Success: true, Result: data})
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if r.Header.Get("Authorization") != "Bearer token" {
+					w.WriteHeader(http.StatusForbidden)
+					_, _ = w.Write([]byte(`{"success":false,"errors":[{"code":9109,"message":"Invalid access token"}]}`))
+					return
+				}
+
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/zones":
+					var zones []cloudflareObject
+					if r.URL.Query().Get("name") == "example.com" {
+						zones = append(zones, cloudflareObject{ID: "zone", Name: "example.com"})
+					}
+					reply(w, zones)
+				case r.Method == http.MethodPost && r.URL.Path == "/zones/zone/dns_records":
+					var record map[string]interface{}
+					_ = json.NewDecoder(r.Body).Decode(&record)
+					records["record"] = record
+					reply(w, nil)
+				case r.Method == http.MethodGet && r.URL.Path == "/zones/zone/dns_records":
+					var found []cloudflareObject
+					for id, record := range records {
+						if record["name"] == r.URL.Query().Get("name") && record["content"] == r.URL.Query().Get("content") {
+							found = append(found, cloudflareObject{ID: id})
+						}
+					}
+					reply(w, found)
+				case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/zones/zone/dns_records/"):
+					delete(records, strings.TrimPrefix(r.URL.Path, "/zones/zone/dns_records/"))
+					reply(w, nil)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			cleanup(server.Close)
+
+			previousAPI := cloudflareAPI
+			cloudflareAPI = server.URL
+			cleanup(func() { cloudflareAPI = previousAPI })
+			setEnv("CLOUDFLARE_DNS_API_TOKEN", "token")
+		})
+
+		Specify("should create the record in the zone of the domain and remove it", func() {
+			provider, err := NewDNSProvider("cloudflare")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(provider.Present(context.Background(), fqdn, value)).To(Succeed())
+			mu.Lock()
+			Expect(records).To(HaveKey("record"))
+			Expect(records["record"]["type"]).To(Equal("TXT"))
+			Expect(records["record"]["name"]).To(Equal("_acme-challenge.netbird.example.com"))
+			Expect(records["record"]["content"]).To(Equal(value))
+			mu.Unlock()
+
+			Expect(provider.CleanUp(context.Background(), fqdn, value)).To(Succeed())
+			mu.Lock()
+			Expect(records).To(BeEmpty())
+			mu.Unlock()
+		})
+
+		Specify("should fail with an invalid token", func() {
+			setEnv("CLOUDFLARE_DNS_API_TOKEN", "invalid")
+			provider, err := NewDNSProvider("cloudflare")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.Present(context.Background(), fqdn, value)).NotTo(Succeed())
+		})
+	})
+})
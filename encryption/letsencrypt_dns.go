@@ -0,0 +1,372 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	// dnsCertRenewBefore is how long before its expiration the certificate is renewed
+	dnsCertRenewBefore = 30 * 24 * time.Hour
+	// dnsCertCheckInterval is how often the expiration of the certificate is checked
+	dnsCertCheckInterval = 12 * time.Hour
+	// dnsPropagationWait is the time the TXT records of the challenges are given to reach the authoritative name
+	// servers before Let's Encrypt is asked to validate them
+	dnsPropagationWait = 30 * time.Second
+	// acmeAccountKey is the cache entry of the ACME account key, it is shared with autocert
+	acmeAccountKey = "acme_account+key"
+)
+
+// DNSCertManager obtains and renews a Let's Encrypt certificate with the DNS-01 challenge, which doesn't require the
+// server to be reachable on port 80 or 443 by Let's Encrypt. The TXT records of the challenges are published by the
+// DNS provider. The certificate is stored in the same cache as the certificates of autocert
+type DNSCertManager struct {
+	domain          string
+	provider        DNSProvider
+	cache           autocert.Cache
+	client          *acme.Client
+	propagationWait time.Duration
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// CreateDNSCertManager wraps common logic of generating Let's Encrypt certificate with the DNS-01 challenge of the
+// named DNS provider, configured by its environment variables
+func CreateDNSCertManager(datadir string, letsencryptDomain string, dnsProvider string) (*DNSCertManager, error) {
+	provider, err := NewDNSProvider(dnsProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	certDir := filepath.Join(datadir, "letsencrypt")
+	if _, err := os.Stat(certDir); os.IsNotExist(err) {
+		err = os.MkdirAll(certDir, os.ModeDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("running with LetsEncrypt (%s) using the DNS-01 challenge of %s. Cert will be stored in %s",
+		letsencryptDomain, dnsProvider, certDir)
+
+	return &DNSCertManager{
+		domain:          letsencryptDomain,
+		provider:        provider,
+		cache:           autocert.DirCache(certDir),
+		client:          &acme.Client{DirectoryURL: acme.LetsEncryptURL},
+		propagationWait: dnsPropagationWait,
+	}, nil
+}
+
+// Start loads the cached certificate and obtains a new one when it is missing or about to expire, it fails when no
+// valid certificate is available. The certificate is renewed in the background until the context is done
+func (m *DNSCertManager) Start(ctx context.Context) error {
+	if err := m.loadCached(ctx); err != nil && !errors.Is(err, autocert.ErrCacheMiss) {
+		log.Warnf("failed loading the cached certificate of %s: %v", m.domain, err)
+	}
+
+	if m.needsRenewal(time.Now()) {
+		if err := m.obtain(ctx); err != nil {
+			if m.certificate() == nil {
+				return fmt.Errorf("obtain certificate of %s: %w", m.domain, err)
+			}
+			log.Warnf("failed renewing the certificate of %s, using the cached one: %v", m.domain, err)
+		}
+	}
+
+	go m.renew(ctx)
+	return nil
+}
+
+// TLSConfig returns a TLS config serving the certificate of the manager
+func (m *DNSCertManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: m.getCertificate,
+		NextProtos: []string{
+			"h2", "http/1.1", // enable HTTP/2
+		},
+	}
+}
+
+func (m *DNSCertManager) renew(ctx context.Context) {
+	ticker := time.NewTicker(dnsCertCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !m.needsRenewal(time.Now()) {
+				continue
+			}
+			if err := m.obtain(ctx); err != nil {
+				log.Errorf("failed renewing the certificate of %s: %v", m.domain, err)
+			}
+		}
+	}
+}
+
+func (m *DNSCertManager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := m.certificate()
+	if cert == nil {
+		return nil, fmt.Errorf("no certificate available for %s", m.domain)
+	}
+	return cert, nil
+}
+
+func (m *DNSCertManager) certificate() *tls.Certificate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cert
+}
+
+func (m *DNSCertManager) setCertificate(cert *tls.Certificate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cert = cert
+}
+
+// needsRenewal returns true when there is no certificate or it expires within dnsCertRenewBefore
+func (m *DNSCertManager) needsRenewal(now time.Time) bool {
+	cert := m.certificate()
+	if cert == nil || cert.Leaf == nil {
+		return true
+	}
+	return now.Add(dnsCertRenewBefore).After(cert.Leaf.NotAfter)
+}
+
+// obtain orders a new certificate, publishing the TXT records of the DNS-01 challenges with the DNS provider
+func (m *DNSCertManager) obtain(ctx context.Context) error {
+	if err := m.register(ctx); err != nil {
+		return err
+	}
+
+	order, err := m.client.AuthorizeOrder(ctx, acme.DomainIDs(m.domain))
+	if err != nil {
+		return fmt.Errorf("authorize order: %w", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		if err := m.authorize(ctx, authzURL); err != nil {
+			return err
+		}
+	}
+
+	order, err = m.client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return fmt.Errorf("wait for order: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate certificate key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{m.domain}}, key)
+	if err != nil {
+		return fmt.Errorf("create certificate request: %w", err)
+	}
+	der, _, err := m.client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("finalize order: %w", err)
+	}
+
+	cert, err := newCertificate(der, key)
+	if err != nil {
+		return err
+	}
+	if err := m.storeCached(ctx, cert); err != nil {
+		log.Warnf("failed caching the certificate of %s: %v", m.domain, err)
+	}
+	m.setCertificate(cert)
+	log.Infof("obtained a certificate for %s valid until %s", m.domain, cert.Leaf.NotAfter)
+	return nil
+}
+
+// authorize fulfills the DNS-01 challenge of an authorization, the TXT record is removed once it is validated
+func (m *DNSCertManager) authorize(ctx context.Context, authzURL string) error {
+	authz, err := m.client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	value, err := m.client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return fmt.Errorf("compute challenge record: %w", err)
+	}
+	fqdn := "_acme-challenge." + strings.TrimPrefix(authz.Identifier.Value, "*.") + "."
+
+	if err := m.provider.Present(ctx, fqdn, value); err != nil {
+		return fmt.Errorf("publish TXT record %s: %w", fqdn, err)
+	}
+	defer func() {
+		if err := m.provider.CleanUp(ctx, fqdn, value); err != nil {
+			log.Warnf("failed removing TXT record %s: %v", fqdn, err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(m.propagationWait):
+	}
+
+	if _, err := m.client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("accept challenge: %w", err)
+	}
+	if _, err := m.client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("wait for authorization: %w", err)
+	}
+	return nil
+}
+
+// register loads or creates the ACME account key and registers the account, an existing account is reused
+func (m *DNSCertManager) register(ctx context.Context) error {
+	if m.client.Key != nil {
+		return nil
+	}
+
+	key, err := m.accountKey(ctx)
+	if err != nil {
+		return err
+	}
+	m.client.Key = key
+
+	_, err = m.client.Register(ctx, &acme.Account{}, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		m.client.Key = nil
+		return fmt.Errorf("register ACME account: %w", err)
+	}
+	return nil
+}
+
+func (m *DNSCertManager) accountKey(ctx context.Context) (crypto.Signer, error) {
+	data, err := m.cache.Get(ctx, acmeAccountKey)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid ACME account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, fmt.Errorf("read ACME account key: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate ACME account key: %w", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.cache.Put(ctx, acmeAccountKey, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, fmt.Errorf("store ACME account key: %w", err)
+	}
+	return key, nil
+}
+
+// loadCached loads the certificate from the cache, it is stored as the private key followed by the certificate chain
+// in PEM like autocert does
+func (m *DNSCertManager) loadCached(ctx context.Context) error {
+	data, err := m.cache.Get(ctx, m.domain)
+	if err != nil {
+		return err
+	}
+
+	keyBlock, rest := pem.Decode(data)
+	if keyBlock == nil || !strings.Contains(keyBlock.Type, "PRIVATE") {
+		return fmt.Errorf("invalid private key")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse private key: %w", err)
+	}
+
+	var der [][]byte
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		der = append(der, block.Bytes)
+	}
+
+	cert, err := newCertificate(der, key)
+	if err != nil {
+		return err
+	}
+	m.setCertificate(cert)
+	return nil
+}
+
+func (m *DNSCertManager) storeCached(ctx context.Context, cert *tls.Certificate) error {
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return fmt.Errorf("unsupported private key type %T", cert.PrivateKey)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}); err != nil {
+		return err
+	}
+	for _, der := range cert.Certificate {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+			return err
+		}
+	}
+	return m.cache.Put(ctx, m.domain, buf.Bytes())
+}
+
+func newCertificate(der [][]byte, key *ecdsa.PrivateKey) (*tls.Certificate, error) {
+	if len(der) == 0 {
+		return nil, fmt.Errorf("no certificate in the chain")
+	}
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate: %w", err)
+	}
+	return &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
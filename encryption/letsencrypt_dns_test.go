@@ -0,0 +1,110 @@
+package encryption
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var _ = Describe("DNSCertManager", func() {
+
+	const domain = "netbird.example.com"
+
+	var (
+		dir     string
+		manager *DNSCertManager
+	)
+
+	selfSigned := func(notAfter time.Time) *tls.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: domain},
+			DNSNames:     []string{domain},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		Expect(err).NotTo(HaveOccurred())
+		cert, err := newCertificate([][]byte{der}, key)
+		Expect(err).NotTo(HaveOccurred())
+		return cert
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "netbird-letsencrypt")
+		Expect(err).NotTo(HaveOccurred())
+		manager = &DNSCertManager{domain: domain, cache: autocert.DirCache(dir)}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	Context("without a certificate", func() {
+		Specify("should need a renewal", func() {
+			Expect(manager.needsRenewal(time.Now())).To(BeTrue())
+		})
+
+		Specify("should fail the TLS handshake", func() {
+			_, err := manager.TLSConfig().GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+			Expect(err).To(HaveOccurred())
+		})
+
+		Specify("should miss the cache", func() {
+			Expect(manager.loadCached(context.Background())).To(MatchError(autocert.ErrCacheMiss))
+		})
+	})
+
+	Context("with a certificate", func() {
+		Specify("should need a renewal when it expires soon", func() {
+			manager.setCertificate(selfSigned(time.Now().Add(90 * 24 * time.Hour)))
+			Expect(manager.needsRenewal(time.Now())).To(BeFalse())
+			Expect(manager.needsRenewal(time.Now().Add(61 * 24 * time.Hour))).To(BeTrue())
+		})
+
+		Specify("should serve it in the TLS handshake", func() {
+			cert := selfSigned(time.Now().Add(90 * 24 * time.Hour))
+			manager.setCertificate(cert)
+			served, err := manager.TLSConfig().GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(served).To(Equal(cert))
+		})
+
+		Specify("should load it from the cache", func() {
+			cert := selfSigned(time.Now().Add(90 * 24 * time.Hour))
+			Expect(manager.storeCached(context.Background(), cert)).To(Succeed())
+
+			loaded := &DNSCertManager{domain: domain, cache: autocert.DirCache(dir)}
+			Expect(loaded.loadCached(context.Background())).To(Succeed())
+			Expect(loaded.certificate().Certificate).To(Equal(cert.Certificate))
+			Expect(loaded.certificate().PrivateKey.(*ecdsa.PrivateKey).Equal(cert.PrivateKey)).To(BeTrue())
+			Expect(loaded.certificate().Leaf.NotAfter).To(Equal(cert.Leaf.NotAfter))
+		})
+	})
+
+	Context("starting", func() {
+		Specify("should use the cached certificate when it is valid", func() {
+			cert := selfSigned(time.Now().Add(90 * 24 * time.Hour))
+			Expect(manager.storeCached(context.Background(), cert)).To(Succeed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			Expect(manager.Start(ctx)).To(Succeed())
+			Expect(manager.certificate().Certificate).To(Equal(cert.Certificate))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const (
+	sessionKeysInfo = "netbird signal session v1"
+	// replayWindowSize is the number of counters below the highest one received that are accepted out of order
+	replayWindowSize = 64
+)
+
+// SessionKeys encrypt the messages exchanged by two peers with ChaCha20-Poly1305. The keys are derived from the
+// ephemeral X25519 keys of the peers, which provide forward secrecy, and from their Wireguard keys, which
+// authenticate them: once the ephemeral keys are discarded, the messages can't be decrypted even with the Wireguard
+// keys. Each direction has its own key, the nonce is a counter and the replayed messages are rejected.
+type SessionKeys struct {
+	send    cipher.AEAD
+	receive cipher.AEAD
+	counter atomic.Uint64
+	replay  replayFilter
+}
+
+// NewSessionKeys derives the session keys of the local and the remote peer from the local private keys and the
+// remote public keys
+func NewSessionKeys(localEphemeral, remoteEphemeral, localStatic, remoteStatic wgtypes.Key) (*SessionKeys, error) {
+	ephemeralSecret, err := curve25519.X25519(localEphemeral[:], remoteEphemeral[:])
+	if err != nil {
+		return nil, fmt.Errorf("ephemeral key exchange: %w", err)
+	}
+	staticSecret, err := curve25519.X25519(localStatic[:], remoteStatic[:])
+	if err != nil {
+		return nil, fmt.Errorf("static key exchange: %w", err)
+	}
+
+	// both peers order the keys the same way, the lower ephemeral key sends with the first derived key
+	localEphemeralPub := localEphemeral.PublicKey()
+	localFirst := bytes.Compare(localEphemeralPub[:], remoteEphemeral[:]) < 0
+	info := []byte(sessionKeysInfo)
+	if localFirst {
+		info = append(append(info, localEphemeralPub[:]...), remoteEphemeral[:]...)
+	} else {
+		info = append(append(info, remoteEphemeral[:]...), localEphemeralPub[:]...)
+	}
+
+	secret := append(ephemeralSecret, staticSecret...)
+	kdf := hkdf.New(sha256.New, secret, nil, info)
+	first := make([]byte, chacha20poly1305.KeySize)
+	second := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(kdf, first); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(kdf, second); err != nil {
+		return nil, err
+	}
+	if !localFirst {
+		first, second = second, first
+	}
+
+	send, err := chacha20poly1305.New(first)
+	if err != nil {
+		return nil, err
+	}
+	receive, err := chacha20poly1305.New(second)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionKeys{send: send, receive: receive}, nil
+}
+
+// Seal encrypts and authenticates the plaintext and the additional data, it returns the counter the receiver needs
+// to open the ciphertext
+func (s *SessionKeys) Seal(plaintext, additionalData []byte) (uint64, []byte) {
+	counter := s.counter.Add(1)
+	return counter, s.send.Seal(nil, counterNonce(counter), plaintext, additionalData)
+}
+
+// Open decrypts and authenticates a ciphertext sealed by the remote peer, it fails if the counter was already
+// received or is too old
+func (s *SessionKeys) Open(counter uint64, ciphertext, additionalData []byte) ([]byte, error) {
+	if !s.replay.valid(counter) {
+		return nil, fmt.Errorf("replayed message counter %d", counter)
+	}
+	plaintext, err := s.receive.Open(nil, counterNonce(counter), ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt session message: %w", err)
+	}
+	// the counter is only recorded once the message is authenticated, so a forged one can't block a valid one
+	if !s.replay.accept(counter) {
+		return nil, fmt.Errorf("replayed message counter %d", counter)
+	}
+	return plaintext, nil
+}
+
+func counterNonce(counter uint64) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[chacha20poly1305.NonceSize-8:], counter)
+	return nonce
+}
+
+// replayFilter accepts each counter once, within a window below the highest counter accepted
+type replayFilter struct {
+	mu      sync.Mutex
+	highest uint64
+	window  uint64
+}
+
+func (r *replayFilter) valid(counter uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.validLocked(counter)
+}
+
+func (r *replayFilter) validLocked(counter uint64) bool {
+	if counter == 0 {
+		return false
+	}
+	if counter > r.highest {
+		return true
+	}
+	diff := r.highest - counter
+	if diff >= replayWindowSize {
+		return false
+	}
+	return r.window&(1<<diff) == 0
+}
+
+func (r *replayFilter) accept(counter uint64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.validLocked(counter) {
+		return false
+	}
+	if counter > r.highest {
+		shift := counter - r.highest
+		if shift >= replayWindowSize {
+			r.window = 0
+		} else {
+			r.window <<= shift
+		}
+		r.window |= 1
+		r.highest = counter
+		return true
+	}
+	r.window |= 1 << (r.highest - counter)
+	return true
+}
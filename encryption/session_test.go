@@ -0,0 +1,96 @@
+package encryption_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/encryption"
+)
+
+var _ = Describe("SessionKeys", func() {
+
+	var (
+		aliceStatic, aliceEphemeral wgtypes.Key
+		bobStatic, bobEphemeral     wgtypes.Key
+		alice, bob                  *encryption.SessionKeys
+	)
+
+	generateKey := func() wgtypes.Key {
+		key, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		return key
+	}
+
+	BeforeEach(func() {
+		var err error
+		aliceStatic, aliceEphemeral = generateKey(), generateKey()
+		bobStatic, bobEphemeral = generateKey(), generateKey()
+
+		alice, err = encryption.NewSessionKeys(aliceEphemeral, bobEphemeral.PublicKey(), aliceStatic, bobStatic.PublicKey())
+		Expect(err).NotTo(HaveOccurred())
+		bob, err = encryption.NewSessionKeys(bobEphemeral, aliceEphemeral.PublicKey(), bobStatic, aliceStatic.PublicKey())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("opening a sealed message", func() {
+		Specify("should be successful in both directions", func() {
+			counter, sealed := alice.Seal([]byte("offer"), []byte("alice/bob"))
+			opened, err := bob.Open(counter, sealed, []byte("alice/bob"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(opened)).To(Equal("offer"))
+
+			counter, sealed = bob.Seal([]byte("answer"), []byte("bob/alice"))
+			opened, err = alice.Open(counter, sealed, []byte("bob/alice"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(opened)).To(Equal("answer"))
+		})
+
+		Specify("should accept the messages out of order", func() {
+			counter1, sealed1 := alice.Seal([]byte("first"), nil)
+			counter2, sealed2 := alice.Seal([]byte("second"), nil)
+			Expect(counter2).NotTo(Equal(counter1))
+
+			_, err := bob.Open(counter2, sealed2, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = bob.Open(counter1, sealed1, nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Specify("should fail when the message is replayed", func() {
+			counter, sealed := alice.Seal([]byte("offer"), nil)
+			_, err := bob.Open(counter, sealed, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = bob.Open(counter, sealed, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		Specify("should fail when the message is too old", func() {
+			oldCounter, oldSealed := alice.Seal([]byte("old"), nil)
+			for i := 0; i < 100; i++ {
+				counter, sealed := alice.Seal([]byte("new"), nil)
+				_, err := bob.Open(counter, sealed, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			_, err := bob.Open(oldCounter, oldSealed, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		Specify("should fail when the additional data doesn't match", func() {
+			counter, sealed := alice.Seal([]byte("offer"), []byte("alice/bob"))
+			_, err := bob.Open(counter, sealed, []byte("mallory/bob"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		Specify("should fail when the keys were derived without the Wireguard key of the sender", func() {
+			mallory, err := encryption.NewSessionKeys(generateKey(), bobEphemeral.PublicKey(), generateKey(), bobStatic.PublicKey())
+			Expect(err).NotTo(HaveOccurred())
+
+			counter, sealed := mallory.Seal([]byte("offer"), nil)
+			_, err = bob.Open(counter, sealed, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
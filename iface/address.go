@@ -9,6 +9,9 @@ import (
 type WGAddress struct {
 	IP      net.IP
 	Network *net.IPNet
+	// Extra are the secondary addresses assigned to the interface along with the primary one, e.g. an IPv6 address
+	// or the addresses of the services running on the peer
+	Extra []WGAddress
 }
 
 // parseWGAddress parse a string ("1.2.3.4/24") address to WG Address
@@ -23,6 +26,46 @@ func parseWGAddress(address string) (WGAddress, error) {
 	}, nil
 }
 
+// parseWGAddresses parses the primary address and the secondary ones, the duplicates of the secondary addresses are
+// skipped
+func parseWGAddresses(address string, extraAddresses []string) (WGAddress, error) {
+	addr, err := parseWGAddress(address)
+	if err != nil {
+		return WGAddress{}, err
+	}
+
+	seen := map[string]struct{}{addr.String(): {}}
+	for _, extraAddress := range extraAddresses {
+		extra, err := parseWGAddress(extraAddress)
+		if err != nil {
+			return WGAddress{}, fmt.Errorf("parse extra address %s: %w", extraAddress, err)
+		}
+		if _, ok := seen[extra.String()]; ok {
+			continue
+		}
+		seen[extra.String()] = struct{}{}
+		addr.Extra = append(addr.Extra, extra)
+	}
+	return addr, nil
+}
+
+// All returns the primary address followed by the secondary ones
+func (addr WGAddress) All() []WGAddress {
+	primary := addr
+	primary.Extra = nil
+	return append([]WGAddress{primary}, addr.Extra...)
+}
+
+// ExtraStrings returns the secondary addresses in the "1.2.3.4/24" format
+func (addr WGAddress) ExtraStrings() []string {
+	extra := make([]string, 0, len(addr.Extra))
+	for _, a := range addr.Extra {
+		extra = append(extra, a.String())
+	}
+	return extra
+}
+
+// String returns the primary address in the "1.2.3.4/24" format
 func (addr WGAddress) String() string {
 	maskSize, _ := addr.Network.Mask.Size()
 	return fmt.Sprintf("%s/%d", addr.IP.String(), maskSize)
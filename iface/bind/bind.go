@@ -17,8 +17,8 @@ type receiverCreator struct {
 	iceBind *ICEBind
 }
 
-func (rc receiverCreator) CreateIPv4ReceiverFn(msgPool *sync.Pool, pc *ipv4.PacketConn, conn *net.UDPConn) wgConn.ReceiveFunc {
-	return rc.iceBind.createIPv4ReceiverFn(msgPool, pc, conn)
+func (rc receiverCreator) CreateIPv4ReceiverFn(_ *sync.Pool, pc *ipv4.PacketConn, conn *net.UDPConn) wgConn.ReceiveFunc {
+	return rc.iceBind.createIPv4ReceiverFn(pc, conn)
 }
 
 type ICEBind struct {
@@ -28,11 +28,41 @@ type ICEBind struct {
 
 	transportNet transport.Net
 	udpMux       *UniversalUDPMuxDefault
+
+	// muConn protects the IPv4 socket state below, set when the bind is opened
+	muConn        sync.Mutex
+	ipv4PC        *ipv4.PacketConn
+	ipv4TxOffload bool
+
+	// peers attributes the received transport messages to the peers by the indexes of the handshakes sent to them
+	peers PeerIndexes
+
+	// these pools are not guarded by muConn
+	udpAddrPool  sync.Pool
+	ipv4MsgsPool sync.Pool
 }
 
 func NewICEBind(transportNet transport.Net) *ICEBind {
 	ib := &ICEBind{
 		transportNet: transportNet,
+		udpAddrPool: sync.Pool{
+			New: func() any {
+				return &net.UDPAddr{
+					IP: make([]byte, 4),
+				}
+			},
+		},
+		ipv4MsgsPool: sync.Pool{
+			New: func() any {
+				msgs := make([]ipv4.Message, wgConn.IdealBatchSize)
+				for i := range msgs {
+					// a message holds up to udpSegmentMaxDatagrams buffers when the packets are coalesced
+					msgs[i].Buffers = make(net.Buffers, 1, udpSegmentMaxDatagrams)
+					msgs[i].OOB = make([]byte, wgConn.SrcControlSize+gsoControlSize)
+				}
+				return &msgs
+			},
+		},
 	}
 
 	rc := receiverCreator{
@@ -53,7 +83,72 @@ func (s *ICEBind) GetICEMux() (*UniversalUDPMuxDefault, error) {
 	return s.udpMux, nil
 }
 
-func (s *ICEBind) createIPv4ReceiverFn(ipv4MsgsPool *sync.Pool, pc *ipv4.PacketConn, conn *net.UDPConn) wgConn.ReceiveFunc {
+// Peers returns the attribution of the received transport messages to the peers
+func (s *ICEBind) Peers() *PeerIndexes {
+	return &s.peers
+}
+
+// Send writes the packets to the endpoint. On Linux, consecutive packets of the same size sent to IPv4 endpoints are
+// coalesced into a single datagram segmented by the kernel (UDP GSO) when the socket supports it
+func (s *ICEBind) Send(bufs [][]byte, ep wgConn.Endpoint) error {
+	for _, buf := range bufs {
+		s.peers.handshakeSent(buf, ep)
+	}
+
+	s.muConn.Lock()
+	pc := s.ipv4PC
+	txOffload := s.ipv4TxOffload
+	s.muConn.Unlock()
+
+	stdEp, ok := ep.(*wgConn.StdNetEndpoint)
+	if !ok || !txOffload || pc == nil || len(bufs) < 2 || !ep.DstIP().Is4() {
+		return s.StdNetBind.Send(bufs, ep)
+	}
+
+	err := s.sendCoalesced(pc, stdEp, bufs)
+	if err == nil || !errShouldDisableGSO(err) {
+		return err
+	}
+
+	log.Warnf("disabling UDP GSO, the network device doesn't support it: %v", err)
+	s.muConn.Lock()
+	s.ipv4TxOffload = false
+	s.muConn.Unlock()
+	return s.StdNetBind.Send(bufs, ep)
+}
+
+// Close closes the sockets of the bind
+func (s *ICEBind) Close() error {
+	s.muConn.Lock()
+	s.ipv4PC = nil
+	s.ipv4TxOffload = false
+	s.muConn.Unlock()
+
+	return s.StdNetBind.Close()
+}
+
+func (s *ICEBind) sendCoalesced(pc *ipv4.PacketConn, ep *wgConn.StdNetEndpoint, bufs [][]byte) error {
+	ua := s.udpAddrPool.Get().(*net.UDPAddr)
+	defer s.udpAddrPool.Put(ua)
+	as4 := ep.DstIP().As4()
+	copy(ua.IP, as4[:])
+	ua.Port = int(ep.Port())
+
+	msgs := s.ipv4MsgsPool.Get().(*[]ipv4.Message)
+	defer s.ipv4MsgsPool.Put(msgs)
+
+	numMsgs := coalesceMessages(ua, ep, bufs, *msgs, setGSOSize)
+	for start := 0; start < numMsgs; {
+		n, err := pc.WriteBatch((*msgs)[start:numMsgs], 0)
+		if err != nil {
+			return err
+		}
+		start += n
+	}
+	return nil
+}
+
+func (s *ICEBind) createIPv4ReceiverFn(pc *ipv4.PacketConn, conn *net.UDPConn) wgConn.ReceiveFunc {
 	s.muUDPMux.Lock()
 	defer s.muUDPMux.Unlock()
 
@@ -63,15 +158,38 @@ func (s *ICEBind) createIPv4ReceiverFn(ipv4MsgsPool *sync.Pool, pc *ipv4.PacketC
 			Net:     s.transportNet,
 		},
 	)
+
+	var rxOffload bool
+	s.muConn.Lock()
+	s.ipv4PC = pc
+	s.ipv4TxOffload = false
+	if pc != nil {
+		s.ipv4TxOffload, rxOffload = supportsUDPOffload(conn)
+	}
+	log.Debugf("UDP offload of the WireGuard socket: GSO %t, GRO %t", s.ipv4TxOffload, rxOffload)
+	s.muConn.Unlock()
+
 	return func(bufs [][]byte, sizes []int, eps []wgConn.Endpoint) (n int, err error) {
-		msgs := ipv4MsgsPool.Get().(*[]ipv4.Message)
-		defer ipv4MsgsPool.Put(msgs)
+		msgs := s.ipv4MsgsPool.Get().(*[]ipv4.Message)
+		defer s.ipv4MsgsPool.Put(msgs)
 		for i := range bufs {
-			(*msgs)[i].Buffers[0] = bufs[i]
+			(*msgs)[i].Buffers = append((*msgs)[i].Buffers[:0], bufs[i])
+			(*msgs)[i].OOB = (*msgs)[i].OOB[:cap((*msgs)[i].OOB)]
 		}
 		var numMsgs int
-		if runtime.GOOS == "linux" {
-			numMsgs, err = pc.ReadBatch(*msgs, 0)
+		if runtime.GOOS == "linux" && rxOffload {
+			// read the coalesced datagrams at the end of the batch, the segments are moved to its start
+			readAt := len(bufs) - len(bufs)/udpSegmentMaxDatagrams
+			if readAt == len(bufs) {
+				readAt--
+			}
+			numMsgs, err = pc.ReadBatch((*msgs)[readAt:len(bufs)], 0)
+			if err != nil {
+				return 0, err
+			}
+			numMsgs = splitCoalescedMessages((*msgs)[:len(bufs)], readAt, numMsgs, getGSOSize)
+		} else if runtime.GOOS == "linux" {
+			numMsgs, err = pc.ReadBatch((*msgs)[:len(bufs)], 0)
 			if err != nil {
 				return 0, err
 			}
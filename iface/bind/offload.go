@@ -0,0 +1,91 @@
+package bind
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+const (
+	// udpSegmentMaxDatagrams is the maximum number of segments the kernel coalesces into a single datagram
+	udpSegmentMaxDatagrams = 64
+	// maxIPv4PayloadLen is the maximum size of the payload of an UDP datagram sent over IPv4
+	maxIPv4PayloadLen = 1<<16 - 1 - 20 - 8
+)
+
+// setGSOFunc adds the segment size of a coalesced message to its control message
+type setGSOFunc func(control *[]byte, gsoSize uint16)
+
+// getGSOFunc returns the segment size of a message coalesced by the kernel from its control message, 0 if not coalesced
+type getGSOFunc func(control []byte) int
+
+// coalesceMessages fills msgs with the packets, coalescing consecutive packets of the same size into a single message
+// that is segmented by the kernel. Only the last packet of a message can be smaller than the others.
+// It returns the number of messages to send
+func coalesceMessages(addr *net.UDPAddr, ep *wgConn.StdNetEndpoint, bufs [][]byte, msgs []ipv4.Message, setGSO setGSOFunc) int {
+	var (
+		// base is the index of the message the packets are coalesced into
+		base = -1
+		// gsoSize is the size of the segments of the base message
+		gsoSize int
+		// baseLen is the total size of the base message
+		baseLen int
+		// endBatch tells whether a smaller packet ended the base message
+		endBatch bool
+	)
+	for _, buf := range bufs {
+		if base >= 0 && !endBatch &&
+			len(buf) <= gsoSize &&
+			baseLen+len(buf) <= maxIPv4PayloadLen &&
+			len(msgs[base].Buffers) < udpSegmentMaxDatagrams {
+			msgs[base].Buffers = append(msgs[base].Buffers, buf)
+			baseLen += len(buf)
+			endBatch = len(buf) < gsoSize
+			continue
+		}
+
+		if base >= 0 && len(msgs[base].Buffers) > 1 {
+			setGSO(&msgs[base].OOB, uint16(gsoSize))
+		}
+		base++
+		msgs[base].Buffers = append(msgs[base].Buffers[:0], buf)
+		msgs[base].Addr = addr
+		setSrcControl(&msgs[base].OOB, ep)
+		gsoSize, baseLen, endBatch = len(buf), len(buf), false
+	}
+	if base >= 0 && len(msgs[base].Buffers) > 1 {
+		setGSO(&msgs[base].OOB, uint16(gsoSize))
+	}
+	return base + 1
+}
+
+// splitCoalescedMessages splits the numMsgs messages read starting at firstMsgAt into one message per segment,
+// moving them to the start of msgs. The messages must be read at the end of msgs, leaving room for the segments.
+// It returns the number of messages after splitting, the segments not fitting in msgs are dropped
+func splitCoalescedMessages(msgs []ipv4.Message, firstMsgAt, numMsgs int, getGSO getGSOFunc) int {
+	n := 0
+	for i := firstMsgAt; i < firstMsgAt+numMsgs; i++ {
+		// the message is overwritten when it receives its own last segment
+		src, size, control, addr := msgs[i].Buffers[0], msgs[i].N, msgs[i].OOB[:msgs[i].NN], msgs[i].Addr
+
+		gsoSize := getGSO(control)
+		if gsoSize <= 0 || gsoSize > size {
+			gsoSize = size
+		}
+
+		for start := 0; start < size && n <= i; start += gsoSize {
+			end := start + gsoSize
+			if end > size {
+				end = size
+			}
+
+			dst := &msgs[n]
+			dst.N = copy(dst.Buffers[0], src[start:end])
+			dst.NN = copy(dst.OOB, control)
+			dst.Addr = addr
+			n++
+		}
+	}
+	return n
+}
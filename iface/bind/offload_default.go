@@ -0,0 +1,29 @@
+//go:build !linux || android
+
+package bind
+
+import (
+	"net"
+
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+const gsoControlSize = 0
+
+func supportsUDPOffload(*net.UDPConn) (txOffload, rxOffload bool) {
+	return false, false
+}
+
+func setGSOSize(*[]byte, uint16) {}
+
+func getGSOSize([]byte) int {
+	return 0
+}
+
+func setSrcControl(control *[]byte, _ *wgConn.StdNetEndpoint) {
+	*control = (*control)[:0]
+}
+
+func errShouldDisableGSO(error) bool {
+	return false
+}
@@ -0,0 +1,105 @@
+//go:build linux && !android
+
+package bind
+
+import (
+	"errors"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+// gsoControlSize is the size of the UDP_SEGMENT and UDP_GRO control messages, carrying an uint16 and an int
+var gsoControlSize = unix.CmsgSpace(4)
+
+// supportsUDPOffload tells whether the socket supports UDP GSO and enables UDP GRO on it
+func supportsUDPOffload(conn *net.UDPConn) (txOffload, rxOffload bool) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return false, false
+	}
+
+	err = rc.Control(func(fd uintptr) {
+		_, errSyscall := unix.GetsockoptInt(int(fd), unix.IPPROTO_UDP, unix.UDP_SEGMENT)
+		txOffload = errSyscall == nil
+		rxOffload = unix.SetsockoptInt(int(fd), unix.IPPROTO_UDP, unix.UDP_GRO, 1) == nil
+	})
+	if err != nil {
+		return false, false
+	}
+	return txOffload, rxOffload
+}
+
+// setGSOSize appends an UDP_SEGMENT control message with the segment size to the control
+func setGSOSize(control *[]byte, gsoSize uint16) {
+	existingLen := len(*control)
+	space := unix.CmsgSpace(2)
+	if cap(*control)-existingLen < space {
+		return
+	}
+
+	*control = (*control)[:existingLen+space]
+	hdr := (*unix.Cmsghdr)(unsafe.Pointer(&(*control)[existingLen]))
+	hdr.Level = unix.SOL_UDP
+	hdr.Type = unix.UDP_SEGMENT
+	hdr.SetLen(unix.CmsgLen(2))
+	*(*uint16)(unsafe.Pointer(&(*control)[existingLen+unix.SizeofCmsghdr])) = gsoSize
+}
+
+// getGSOSize returns the segment size of the UDP_GRO control message, 0 if the datagram was not coalesced
+func getGSOSize(control []byte) int {
+	rem := control
+	for len(rem) > unix.SizeofCmsghdr {
+		hdr, data, next, err := unix.ParseOneSocketControlMessage(rem)
+		if err != nil {
+			return 0
+		}
+		if hdr.Level == unix.SOL_UDP && hdr.Type == unix.UDP_GRO && len(data) >= 2 {
+			return int(*(*uint16)(unsafe.Pointer(&data[0])))
+		}
+		rem = next
+	}
+	return 0
+}
+
+// setSrcControl sets an IP_PKTINFO control message with the sticky source of the endpoint, leaving the control empty
+// when the endpoint has none
+func setSrcControl(control *[]byte, ep *wgConn.StdNetEndpoint) {
+	*control = (*control)[:0]
+	if ep.SrcIfidx() == 0 && !ep.SrcIP().IsValid() {
+		return
+	}
+
+	space := unix.CmsgSpace(unix.SizeofInet4Pktinfo)
+	if cap(*control) < space {
+		return
+	}
+	*control = (*control)[:space]
+	for i := range *control {
+		(*control)[i] = 0
+	}
+
+	hdr := (*unix.Cmsghdr)(unsafe.Pointer(&(*control)[0]))
+	hdr.Level = unix.IPPROTO_IP
+	hdr.Type = unix.IP_PKTINFO
+	hdr.SetLen(unix.CmsgLen(unix.SizeofInet4Pktinfo))
+
+	info := (*unix.Inet4Pktinfo)(unsafe.Pointer(&(*control)[unix.SizeofCmsghdr]))
+	info.Ifindex = ep.SrcIfidx()
+	if ep.SrcIP().Is4() {
+		info.Spec_dst = ep.SrcIP().As4()
+	}
+}
+
+// errShouldDisableGSO tells whether sending failed because the network device doesn't support UDP GSO.
+// The kernel returns EIO when the device has no checksum offload, which UDP_SEGMENT requires
+func errShouldDisableGSO(err error) bool {
+	var syscallErr *os.SyscallError
+	if errors.As(err, &syscallErr) {
+		return syscallErr.Err == unix.EIO
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+//go:build linux && !android
+
+package bind
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/transport/v3/stdnet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICEBind_SendCoalesced(t *testing.T) {
+	receiver, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer receiver.Close()
+
+	transportNet, err := stdnet.NewNet()
+	require.NoError(t, err)
+
+	iceBind := NewICEBind(transportNet)
+	_, _, err = iceBind.Open(0)
+	require.NoError(t, err)
+	defer iceBind.Close()
+
+	if !iceBind.ipv4TxOffload {
+		t.Skip("UDP GSO is not supported")
+	}
+
+	ep, err := iceBind.ParseEndpoint(receiver.LocalAddr().String())
+	require.NoError(t, err)
+
+	sizes := []int{1000, 1000, 1000, 600}
+	bufs := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		bufs[i] = make([]byte, size)
+	}
+	require.NoError(t, iceBind.Send(bufs, ep))
+
+	buf := make([]byte, 1<<16)
+	require.NoError(t, receiver.SetReadDeadline(time.Now().Add(time.Second)))
+	for _, size := range sizes {
+		n, _, err := receiver.ReadFromUDP(buf)
+		require.NoError(t, err)
+		assert.Equal(t, size, n, "the kernel should send every packet as a separate datagram")
+	}
+}
@@ -0,0 +1,117 @@
+package bind
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv4"
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+// testSetGSO stores the segment size at the end of the control
+func testSetGSO(control *[]byte, gsoSize uint16) {
+	*control = binary.LittleEndian.AppendUint16(*control, gsoSize)
+}
+
+func testGetGSO(control []byte) int {
+	if len(control) < 2 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint16(control[len(control)-2:]))
+}
+
+func testMessages(count, bufSize int) []ipv4.Message {
+	msgs := make([]ipv4.Message, count)
+	for i := range msgs {
+		msgs[i].Buffers = make(net.Buffers, 1, udpSegmentMaxDatagrams)
+		msgs[i].Buffers[0] = make([]byte, bufSize)
+		msgs[i].OOB = make([]byte, 0, 8)
+	}
+	return msgs
+}
+
+func TestCoalesceMessages(t *testing.T) {
+	sizes := []int{100, 100, 100, 50, 100, 100, 200}
+	bufs := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		bufs[i] = bytes.Repeat([]byte{byte(i)}, size)
+	}
+
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 51820}
+	msgs := testMessages(len(bufs), 0)
+	n := coalesceMessages(addr, &wgConn.StdNetEndpoint{}, bufs, msgs, testSetGSO)
+	require.Equal(t, 3, n)
+
+	assert.Len(t, msgs[0].Buffers, 4, "a smaller packet should end the message")
+	assert.Equal(t, 100, testGetGSO(msgs[0].OOB))
+	assert.Len(t, msgs[1].Buffers, 2, "a bigger packet should start a new message")
+	assert.Equal(t, 100, testGetGSO(msgs[1].OOB))
+	assert.Len(t, msgs[2].Buffers, 1)
+	assert.Empty(t, msgs[2].OOB, "a single packet should not be segmented")
+
+	for i := 0; i < n; i++ {
+		assert.Equal(t, addr, msgs[i].Addr)
+	}
+}
+
+func TestCoalesceMessages_MaxDatagrams(t *testing.T) {
+	bufs := make([][]byte, udpSegmentMaxDatagrams+1)
+	for i := range bufs {
+		bufs[i] = make([]byte, 10)
+	}
+
+	msgs := testMessages(len(bufs), 0)
+	n := coalesceMessages(&net.UDPAddr{}, &wgConn.StdNetEndpoint{}, bufs, msgs, testSetGSO)
+	require.Equal(t, 2, n)
+	assert.Len(t, msgs[0].Buffers, udpSegmentMaxDatagrams)
+	assert.Len(t, msgs[1].Buffers, 1)
+}
+
+func TestSplitCoalescedMessages(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 51820}
+	msgs := testMessages(8, 64)
+
+	// three segments of 10 bytes and a last one of 5 bytes
+	coalesced := append(bytes.Repeat([]byte{1}, 30), bytes.Repeat([]byte{2}, 5)...)
+	msgs[6].N = copy(msgs[6].Buffers[0], coalesced)
+	msgs[6].OOB = msgs[6].OOB[:0]
+	testSetGSO(&msgs[6].OOB, 10)
+	msgs[6].NN = len(msgs[6].OOB)
+	msgs[6].Addr = addr
+
+	msgs[7].N = copy(msgs[7].Buffers[0], bytes.Repeat([]byte{3}, 7))
+	msgs[7].NN = 0
+	msgs[7].Addr = addr
+
+	n := splitCoalescedMessages(msgs, 6, 2, testGetGSO)
+	require.Equal(t, 5, n)
+
+	expected := [][]byte{
+		bytes.Repeat([]byte{1}, 10),
+		bytes.Repeat([]byte{1}, 10),
+		bytes.Repeat([]byte{1}, 10),
+		bytes.Repeat([]byte{2}, 5),
+		bytes.Repeat([]byte{3}, 7),
+	}
+	for i, packet := range expected {
+		assert.Equal(t, packet, msgs[i].Buffers[0][:msgs[i].N], "message %d", i)
+		assert.Equal(t, addr, msgs[i].Addr)
+	}
+}
+
+func TestSplitCoalescedMessages_DropsOverflow(t *testing.T) {
+	msgs := testMessages(2, 64)
+	msgs[1].N = copy(msgs[1].Buffers[0], bytes.Repeat([]byte{1}, 30))
+	msgs[1].OOB = msgs[1].OOB[:0]
+	testSetGSO(&msgs[1].OOB, 10)
+	msgs[1].NN = len(msgs[1].OOB)
+
+	n := splitCoalescedMessages(msgs, 1, 1, testGetGSO)
+	assert.Equal(t, 2, n, "the segments not fitting should be dropped")
+	assert.Equal(t, 10, msgs[0].N)
+	assert.Equal(t, 10, msgs[1].N)
+}
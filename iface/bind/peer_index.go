@@ -0,0 +1,151 @@
+package bind
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+const (
+	messageInitiationType = 1
+	messageResponseType   = 2
+	messageTransportType  = 4
+
+	messageInitiationSize = 148
+	messageResponseSize   = 92
+	// messageTransportHeaderSize is the size of the header preceding the content of a transport message: the type, the
+	// receiver index and the counter
+	messageTransportHeaderSize = 16
+
+	// peerIndexesKept is the number of indexes kept per peer, the device keeps the keypairs of the previous, the
+	// current and the next session
+	peerIndexesKept = 3
+)
+
+// PeerIndexes attributes the transport messages received by the device to the peers which sent them. The device picks
+// a random local index for every handshake it sends and the peer puts this index in the header of the transport
+// messages encrypted with the resulting keypair. The handshakes are sent to the endpoints of the peers, so the index
+// of a message tells which peer it was decrypted for. A peer whose endpoint isn't known, e.g. after it roamed, is
+// unknown
+type PeerIndexes struct {
+	mutex     sync.Mutex
+	endpoints map[netip.AddrPort]string
+	recent    map[string][]uint32
+	// table is replaced as a whole on change and read without locking on the packet path
+	table atomic.Pointer[map[uint32]string]
+}
+
+// SetEndpoint sets the endpoint of the peer the handshakes are sent to, nil keeps the current one like the device does
+func (p *PeerIndexes) SetEndpoint(peerKey string, endpoint *net.UDPAddr) {
+	if endpoint == nil {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.endpoints == nil {
+		p.endpoints = make(map[netip.AddrPort]string)
+	}
+	for addr, peer := range p.endpoints {
+		if peer == peerKey {
+			delete(p.endpoints, addr)
+		}
+	}
+	p.endpoints[unmapAddrPort(endpoint.AddrPort())] = peerKey
+}
+
+// RemovePeer forgets the endpoint and the indexes of the peer
+func (p *PeerIndexes) RemovePeer(peerKey string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for addr, peer := range p.endpoints {
+		if peer == peerKey {
+			delete(p.endpoints, addr)
+		}
+	}
+	if _, ok := p.recent[peerKey]; !ok {
+		return
+	}
+	delete(p.recent, peerKey)
+	p.storeTable()
+}
+
+// Lookup returns the public key of the peer which sent the transport message preceding the content at the offset of
+// the buffer, empty if the buffer holds no transport header or the index is unknown
+func (p *PeerIndexes) Lookup(buf []byte, offset int) string {
+	if p == nil || offset < messageTransportHeaderSize || len(buf) < offset {
+		return ""
+	}
+	header := buf[offset-messageTransportHeaderSize : offset]
+	if binary.LittleEndian.Uint32(header[0:4]) != messageTransportType {
+		return ""
+	}
+	table := p.table.Load()
+	if table == nil {
+		return ""
+	}
+	return (*table)[binary.LittleEndian.Uint32(header[4:8])]
+}
+
+// handshakeSent records the local index of a handshake message sent to the endpoint
+func (p *PeerIndexes) handshakeSent(msg []byte, ep wgConn.Endpoint) {
+	if len(msg) < 8 {
+		return
+	}
+	switch binary.LittleEndian.Uint32(msg[0:4]) {
+	case messageInitiationType:
+		if len(msg) != messageInitiationSize {
+			return
+		}
+	case messageResponseType:
+		if len(msg) != messageResponseSize {
+			return
+		}
+	default:
+		return
+	}
+
+	addr, err := netip.ParseAddrPort(ep.DstToString())
+	if err != nil {
+		return
+	}
+	index := binary.LittleEndian.Uint32(msg[4:8])
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	peerKey, ok := p.endpoints[unmapAddrPort(addr)]
+	if !ok {
+		return
+	}
+	if p.recent == nil {
+		p.recent = make(map[string][]uint32)
+	}
+	indexes := append(p.recent[peerKey], index)
+	if len(indexes) > peerIndexesKept {
+		indexes = indexes[len(indexes)-peerIndexesKept:]
+	}
+	p.recent[peerKey] = indexes
+	p.storeTable()
+}
+
+// storeTable replaces the table with the recent indexes of the peers, the mutex has to be held
+func (p *PeerIndexes) storeTable() {
+	table := make(map[uint32]string)
+	for peerKey, indexes := range p.recent {
+		for _, index := range indexes {
+			table[index] = peerKey
+		}
+	}
+	p.table.Store(&table)
+}
+
+func unmapAddrPort(addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+}
@@ -0,0 +1,64 @@
+package bind
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	wgConn "golang.zx2c4.com/wireguard/conn"
+)
+
+func testHandshake(msgType uint32, size int, index uint32) []byte {
+	msg := make([]byte, size)
+	binary.LittleEndian.PutUint32(msg[0:4], msgType)
+	binary.LittleEndian.PutUint32(msg[4:8], index)
+	return msg
+}
+
+// testTransport returns a buffer holding the header of a transport message and an IPv4 packet sent from src
+func testTransport(index uint32, src net.IP) []byte {
+	buf := make([]byte, messageTransportHeaderSize+20)
+	binary.LittleEndian.PutUint32(buf[0:4], messageTransportType)
+	binary.LittleEndian.PutUint32(buf[4:8], index)
+	packet := buf[messageTransportHeaderSize:]
+	packet[0] = 0x45
+	copy(packet[12:16], src.To4())
+	return buf
+}
+
+func TestPeerIndexes(t *testing.T) {
+	endpoint := func(addr string) wgConn.Endpoint {
+		ep, err := wgConn.NewStdNetBind().ParseEndpoint(addr)
+		require.NoError(t, err)
+		return ep
+	}
+
+	var peers PeerIndexes
+	peers.SetEndpoint("peerA", &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 51820})
+	peers.SetEndpoint("peerB", &net.UDPAddr{IP: net.ParseIP("192.168.1.11"), Port: 51820})
+
+	peers.handshakeSent(testHandshake(messageInitiationType, messageInitiationSize, 1), endpoint("192.168.1.10:51820"))
+	peers.handshakeSent(testHandshake(messageResponseType, messageResponseSize, 2), endpoint("192.168.1.11:51820"))
+	peers.handshakeSent(testHandshake(messageInitiationType, messageInitiationSize, 3), endpoint("192.168.1.12:51820"))
+
+	// peerA sends a packet from the allowed IP of peerB, the packet is still attributed to peerA
+	assert.Equal(t, "peerA", peers.Lookup(testTransport(1, net.IP{100, 64, 0, 11}), messageTransportHeaderSize),
+		"the packet should be attributed to the peer of the index, not to the owner of the source address")
+	assert.Equal(t, "peerB", peers.Lookup(testTransport(2, net.IP{100, 64, 0, 11}), messageTransportHeaderSize))
+	assert.Empty(t, peers.Lookup(testTransport(3, net.IP{100, 64, 0, 12}), messageTransportHeaderSize),
+		"a handshake sent to an unknown endpoint should not be attributed")
+	assert.Empty(t, peers.Lookup(testTransport(1, net.IP{100, 64, 0, 10}), 0), "a buffer without header is unknown")
+
+	for index := uint32(10); index < 10+peerIndexesKept; index++ {
+		peers.handshakeSent(testHandshake(messageInitiationType, messageInitiationSize, index), endpoint("192.168.1.10:51820"))
+	}
+	assert.Empty(t, peers.Lookup(testTransport(1, net.IP{100, 64, 0, 10}), messageTransportHeaderSize),
+		"the old indexes should be forgotten")
+	assert.Equal(t, "peerA", peers.Lookup(testTransport(10, net.IP{100, 64, 0, 10}), messageTransportHeaderSize))
+
+	peers.RemovePeer("peerB")
+	assert.Empty(t, peers.Lookup(testTransport(2, net.IP{100, 64, 0, 11}), messageTransportHeaderSize),
+		"the indexes of a removed peer should be forgotten")
+}
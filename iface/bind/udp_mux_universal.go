@@ -238,6 +238,20 @@ func (m *UniversalUDPMuxDefault) GetXORMappedAddr(serverAddr net.Addr, deadline
 	}
 }
 
+// ResetXORMappedAddrs drops the discovered mapped addresses, so that the next calls of GetXORMappedAddr discover them
+// again. It is used when the local addresses changed, the mapping of the NAT changes with them.
+// The pending requests are kept.
+func (m *UniversalUDPMuxDefault) ResetXORMappedAddrs() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for serverAddr, mappedAddr := range m.xorMappedMap {
+		if !mappedAddr.pending() {
+			delete(m.xorMappedMap, serverAddr)
+		}
+	}
+}
+
 // sendSTUN sends a STUN request via UDP conn.
 //
 // The returned channel is closed when the STUN response has been received.
@@ -5,6 +5,8 @@ import (
 	"sync"
 
 	"golang.zx2c4.com/wireguard/tun"
+
+	"github.com/FlintyLemming/netbird/iface/bind"
 )
 
 // PacketFilter interface for firewall abilities
@@ -28,17 +30,113 @@ type PacketFilter interface {
 	SetNetwork(*net.IPNet)
 }
 
+// BatchPacketFilter is a PacketFilter filtering a batch of packets at once, e.g. spreading it over the CPUs
+type BatchPacketFilter interface {
+	PacketFilter
+
+	// DropOutgoingBatch sets drop[i] for the outgoing packets[i] to drop
+	DropOutgoingBatch(packets [][]byte, drop []bool)
+
+	// DropIncomingBatch sets drop[i] for the incoming packets[i] to drop
+	DropIncomingBatch(packets [][]byte, drop []bool)
+}
+
+// PeerPacketFilter is a PacketFilter checking the incoming packets against the WireGuard peer which sent them, so
+// that a peer can't use the address of another one
+type PeerPacketFilter interface {
+	PacketFilter
+
+	// DropIncomingFromPeers sets drop[i] for the incoming packets[i] to drop. peers[i] is the public key of the peer
+	// which sent the packet, empty if it is unknown
+	DropIncomingFromPeers(packets [][]byte, peers []string, drop []bool)
+}
+
+// packetBatch holds the packets of a batch and the verdicts of the filter. Batches are reused to avoid allocations
+// on the packet path
+type packetBatch struct {
+	packets [][]byte
+	drop    []bool
+	kept    [][]byte
+	// peers are the peers which sent the incoming packets, only set for a PeerPacketFilter
+	peers []string
+}
+
+var batches = sync.Pool{
+	New: func() any {
+		return &packetBatch{}
+	},
+}
+
+func getBatch(size int) *packetBatch {
+	b := batches.Get().(*packetBatch)
+	if cap(b.packets) < size {
+		b.packets = make([][]byte, size)
+		b.drop = make([]bool, size)
+		b.kept = make([][]byte, 0, size)
+		b.peers = make([]string, size)
+	}
+	b.packets = b.packets[:size]
+	b.drop = b.drop[:size]
+	b.peers = b.peers[:size]
+	b.kept = b.kept[:0]
+	return b
+}
+
+func putBatch(b *packetBatch) {
+	// don't keep the packet buffers alive
+	for i := range b.packets {
+		b.packets[i] = nil
+	}
+	for i := range b.kept {
+		b.kept[i] = nil
+	}
+	batches.Put(b)
+}
+
+// filter sets the verdicts of the packets of the batch. The peers which sent the incoming packets are taken from the
+// transport headers preceding the packets in the buffers when the filter checks them
+func (b *packetBatch) filter(filter PacketFilter, incoming bool, bufs [][]byte, offset int, peers *bind.PeerIndexes) {
+	if peerFilter, ok := filter.(PeerPacketFilter); ok && incoming {
+		for i := range b.packets {
+			b.peers[i] = peers.Lookup(bufs[i], offset)
+		}
+		peerFilter.DropIncomingFromPeers(b.packets, b.peers, b.drop)
+		return
+	}
+
+	if batchFilter, ok := filter.(BatchPacketFilter); ok {
+		if incoming {
+			batchFilter.DropIncomingBatch(b.packets, b.drop)
+		} else {
+			batchFilter.DropOutgoingBatch(b.packets, b.drop)
+		}
+		return
+	}
+
+	for i, packet := range b.packets {
+		if incoming {
+			b.drop[i] = filter.DropIncoming(packet)
+		} else {
+			b.drop[i] = filter.DropOutgoing(packet)
+		}
+	}
+}
+
 // DeviceWrapper to override Read or Write of packets
 type DeviceWrapper struct {
 	tun.Device
 	filter PacketFilter
 	mutex  sync.RWMutex
+
+	// peers tells which peer sent an incoming packet, nil if the bind doesn't track it
+	peers *bind.PeerIndexes
 }
 
 // newDeviceWrapper constructor function
-func newDeviceWrapper(device tun.Device) *DeviceWrapper {
+func newDeviceWrapper(device tun.Device, peers *bind.PeerIndexes) *DeviceWrapper {
 	return &DeviceWrapper{
 		Device: device,
+		peers:  peers,
 	}
 }
 
@@ -55,16 +153,27 @@ func (d *DeviceWrapper) Read(bufs [][]byte, sizes []int, offset int) (n int, err
 		return
 	}
 
+	batch := getBatch(n)
+	defer putBatch(batch)
+
+	for i := 0; i < n; i++ {
+		batch.packets[i] = bufs[i][offset : offset+sizes[i]]
+	}
+	batch.filter(filter, false, bufs, offset, d.peers)
+
+	kept := 0
 	for i := 0; i < n; i++ {
-		if filter.DropOutgoing(bufs[i][offset : offset+sizes[i]]) {
-			bufs = append(bufs[:i], bufs[i+1:]...)
-			sizes = append(sizes[:i], sizes[i+1:]...)
-			n--
-			i--
+		if batch.drop[i] {
+			continue
+		}
+		// the caller owns the buffers by index, the packets following a dropped one are moved to the free buffer
+		if kept != i {
+			sizes[kept] = copy(bufs[kept][offset:], batch.packets[i])
 		}
+		kept++
 	}
 
-	return n, nil
+	return kept, nil
 }
 
 // Write wraps write method with filtering feature
@@ -77,17 +186,22 @@ func (d *DeviceWrapper) Write(bufs [][]byte, offset int) (int, error) {
 		return d.Device.Write(bufs, offset)
 	}
 
-	filteredBufs := make([][]byte, 0, len(bufs))
-	dropped := 0
-	for _, buf := range bufs {
-		if !filter.DropIncoming(buf[offset:]) {
-			filteredBufs = append(filteredBufs, buf)
-			dropped++
+	batch := getBatch(len(bufs))
+	defer putBatch(batch)
+
+	for i, buf := range bufs {
+		batch.packets[i] = buf[offset:]
+	}
+	batch.filter(filter, true, bufs, offset, d.peers)
+
+	for i, buf := range bufs {
+		if !batch.drop[i] {
+			batch.kept = append(batch.kept, buf)
 		}
 	}
 
-	n, err := d.Device.Write(filteredBufs, offset)
-	n += dropped
+	n, err := d.Device.Write(batch.kept, offset)
+	n += len(batch.kept)
 	return n, err
 }
 
@@ -51,12 +51,13 @@ func (w *WGIface) Up() (*bind.UniversalUDPMuxDefault, error) {
 	return w.tun.Up()
 }
 
-// UpdateAddr updates address of the interface
-func (w *WGIface) UpdateAddr(newAddr string) error {
+// UpdateAddr updates address of the interface, the extra addresses are assigned to it too and replace the previous
+// ones
+func (w *WGIface) UpdateAddr(newAddr string, extraAddrs ...string) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	addr, err := parseWGAddress(newAddr)
+	addr, err := parseWGAddresses(newAddr, extraAddrs)
 	if err != nil {
 		return err
 	}
@@ -71,7 +72,22 @@ func (w *WGIface) UpdatePeer(peerKey string, allowedIps string, keepAlive time.D
 	defer w.mu.Unlock()
 
 	log.Debugf("updating interface %s peer %s, endpoint %s", w.tun.DeviceName(), peerKey, endpoint)
-	return w.configurer.updatePeer(peerKey, allowedIps, keepAlive, endpoint, preSharedKey)
+	if err := w.configurer.updatePeer(peerKey, allowedIps, keepAlive, endpoint, preSharedKey); err != nil {
+		return err
+	}
+	if wrapper := w.tun.Wrapper(); wrapper != nil && wrapper.peers != nil {
+		wrapper.peers.SetEndpoint(peerKey, endpoint)
+	}
+	return nil
+}
+
+// SetPeerKeepAlive changes the persistent keepalive interval of an existing Wireguard Peer, 0 disables it
+func (w *WGIface) SetPeerKeepAlive(peerKey string, keepAlive time.Duration) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	log.Debugf("setting the keepalive of interface %s peer %s to %s", w.tun.DeviceName(), peerKey, keepAlive)
+	return w.configurer.setPeerKeepAlive(peerKey, keepAlive)
 }
 
 // RemovePeer removes a Wireguard Peer from the interface iface
@@ -80,7 +96,13 @@ func (w *WGIface) RemovePeer(peerKey string) error {
 	defer w.mu.Unlock()
 
 	log.Debugf("Removing peer %s from interface %s ", peerKey, w.tun.DeviceName())
-	return w.configurer.removePeer(peerKey)
+	if err := w.configurer.removePeer(peerKey); err != nil {
+		return err
+	}
+	if wrapper := w.tun.Wrapper(); wrapper != nil && wrapper.peers != nil {
+		wrapper.peers.RemovePeer(peerKey)
+	}
+	return nil
 }
 
 // AddAllowedIP adds a prefix to the allowed IPs list of peer
@@ -101,6 +123,22 @@ func (w *WGIface) RemoveAllowedIP(peerKey string, allowedIP string) error {
 	return w.configurer.removeAllowedIP(peerKey, allowedIP)
 }
 
+// GetStats returns the last handshake time and the transferred bytes of a WireGuard peer
+func (w *WGIface) GetStats(peerKey string) (WGStats, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.configurer.getStats(peerKey)
+}
+
+// GetAllStats returns the last handshake time and the transferred bytes of all the WireGuard peers by public key
+func (w *WGIface) GetAllStats() (map[string]WGStats, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.configurer.getAllStats()
+}
+
 // Close closes the tunnel interface
 func (w *WGIface) Close() error {
 	w.mu.Lock()
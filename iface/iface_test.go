@@ -83,6 +83,24 @@ func TestWGIface_UpdateAddr(t *testing.T) {
 
 }
 
+func Test_ParseWGAddresses(t *testing.T) {
+	addr, err := parseWGAddresses("100.64.0.1/16", []string{"fd00:100::1/64", "100.64.100.1/32", "fd00:100::1/64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "100.64.0.1/16", addr.String())
+	assert.Equal(t, []string{"fd00:100::1/64", "100.64.100.1/32"}, addr.ExtraStrings(), "the duplicates should be skipped")
+
+	all := addr.All()
+	assert.Len(t, all, 3)
+	assert.Equal(t, "100.64.0.1/16", all[0].String())
+	assert.Empty(t, all[0].Extra)
+
+	_, err = parseWGAddresses("100.64.0.1/16", []string{"fd00:100::1"})
+	assert.Error(t, err, "an extra address without prefix length should be rejected")
+}
+
 func getIfaceAddrs(ifaceName string) ([]net.Addr, error) {
 	ief, err := net.InterfaceByName(ifaceName)
 	if err != nil {
@@ -271,6 +289,78 @@ func Test_UpdatePeer(t *testing.T) {
 	}
 }
 
+func Test_SetPeerKeepAlive(t *testing.T) {
+	ifaceName := fmt.Sprintf("utun%d", WgIntNumber+4)
+	wgIP := "10.99.99.17/30"
+	newNet, err := stdnet.NewNet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	iface, err := NewWGIFace(ifaceName, wgIP, 33100, key, DefaultMTU, newNet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = iface.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = iface.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+
+	_, err = iface.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedIP := "10.99.99.18/32"
+	endpoint, err := net.ResolveUDPAddr("udp", "127.0.0.1:9900")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = iface.UpdatePeer(peerPubKey, allowedIP, 15*time.Second, endpoint, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keepAlive := range []time.Duration{3 * time.Minute, 0} {
+		err = iface.SetPeerKeepAlive(peerPubKey, keepAlive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peer, err := getPeer(ifaceName, peerPubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if peer.PersistentKeepaliveInterval != keepAlive {
+			t.Fatalf("expected keepalive interval %s, got %s", keepAlive, peer.PersistentKeepaliveInterval)
+		}
+		if peer.Endpoint.String() != endpoint.String() {
+			t.Fatal("setting the keepalive changed the endpoint")
+		}
+		if len(peer.AllowedIPs) != 1 || peer.AllowedIPs[0].String() != allowedIP {
+			t.Fatal("setting the keepalive changed the allowed IPs")
+		}
+	}
+
+	// the keepalive of a missing peer doesn't create it
+	missingKey, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missingPubKey := missingKey.PublicKey().String()
+	err = iface.SetPeerKeepAlive(missingPubKey, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = getPeer(ifaceName, missingPubKey); err == nil {
+		t.Fatal("setting the keepalive of a missing peer created it")
+	}
+}
+
 func Test_RemovePeer(t *testing.T) {
 	ifaceName := fmt.Sprintf("utun%d", WgIntNumber+4)
 	wgIP := "10.99.99.13/30"
@@ -1,7 +1,29 @@
-//go:build linux || windows
-// +build linux windows
+//go:build linux || windows || freebsd
+// +build linux windows freebsd
 
 package iface
 
+import (
+	"fmt"
+	"strings"
+)
+
 // WgInterfaceDefault is a default interface name of Wiretrustee
 const WgInterfaceDefault = "wt0"
+
+// maxInterfaceNameLen is the maximum length of an interface name on Linux, IFNAMSIZ without the trailing NUL
+const maxInterfaceNameLen = 15
+
+// ValidateInterfaceName returns an error when the name can't be used for the WireGuard interface
+func ValidateInterfaceName(name string) error {
+	if name == "" {
+		return fmt.Errorf("interface name is empty")
+	}
+	if len(name) > maxInterfaceNameLen {
+		return fmt.Errorf("interface name %s is longer than %d characters", name, maxInterfaceNameLen)
+	}
+	if strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("interface name %s contains invalid characters", name)
+	}
+	return nil
+}
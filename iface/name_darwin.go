@@ -3,5 +3,21 @@
 
 package iface
 
+import (
+	"fmt"
+	"regexp"
+)
+
 // WgInterfaceDefault is a default interface name of Wiretrustee
 const WgInterfaceDefault = "utun100"
+
+var utunName = regexp.MustCompile(`^utun[0-9]+$`)
+
+// ValidateInterfaceName returns an error when the name can't be used for the WireGuard interface, macOS only allows
+// utun interfaces
+func ValidateInterfaceName(name string) error {
+	if !utunName.MatchString(name) {
+		return fmt.Errorf("interface name %s is invalid, it must match utun[0-9]+, e.g. %s", name, WgInterfaceDefault)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build openbsd
+
+package iface
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// WgInterfaceDefault is a default interface name of Wiretrustee, the tun driver of OpenBSD only creates tun
+// interfaces
+const WgInterfaceDefault = "tun100"
+
+var tunName = regexp.MustCompile(`^tun[0-9]+$`)
+
+// ValidateInterfaceName returns an error when the name can't be used for the WireGuard interface, OpenBSD only allows
+// tun interfaces
+func ValidateInterfaceName(name string) error {
+	if !tunName.MatchString(name) {
+		return fmt.Errorf("interface name %s is invalid, it must match tun[0-9]+, e.g. %s", name, WgInterfaceDefault)
+	}
+	return nil
+}
@@ -61,7 +61,7 @@ func (t *wgTunDevice) Create(routes []string, dns string, searchDomains []string
 		return nil, err
 	}
 	t.name = name
-	t.wrapper = newDeviceWrapper(tunDevice)
+	t.wrapper = newDeviceWrapper(tunDevice, t.iceBind.Peers())
 
 	log.Debugf("attaching to interface %v", name)
 	t.device = device.NewDevice(t.wrapper, t.iceBind, device.NewLogger(device.LogLevelSilent, "[wiretrustee] "))
@@ -94,7 +94,10 @@ func (t *wgTunDevice) Up() (*bind.UniversalUDPMuxDefault, error) {
 }
 
 func (t *wgTunDevice) UpdateAddr(addr WGAddress) error {
-	// todo implement
+	if err := t.tunAdapter.UpdateAddr(addr.String()); err != nil {
+		return err
+	}
+	t.address = addr
 	return nil
 }
 
@@ -0,0 +1,149 @@
+//go:build freebsd || openbsd
+
+package iface
+
+import (
+	"os/exec"
+	"strconv"
+
+	"github.com/pion/transport/v3"
+	log "github.com/sirupsen/logrus"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun"
+
+	"github.com/FlintyLemming/netbird/iface/bind"
+)
+
+type tunDevice struct {
+	name    string
+	address WGAddress
+	port    int
+	key     string
+	mtu     int
+	iceBind *bind.ICEBind
+
+	device     *device.Device
+	wrapper    *DeviceWrapper
+	udpMux     *bind.UniversalUDPMuxDefault
+	configurer wgConfigurer
+}
+
+func newTunDevice(name string, address WGAddress, port int, key string, mtu int, transportNet transport.Net) wgTunDevice {
+	return &tunDevice{
+		name:    name,
+		address: address,
+		port:    port,
+		key:     key,
+		mtu:     mtu,
+		iceBind: bind.NewICEBind(transportNet),
+	}
+}
+
+func (t *tunDevice) Create() (wgConfigurer, error) {
+	tunDevice, err := tun.CreateTUN(t.name, t.mtu)
+	if err != nil {
+		return nil, err
+	}
+	t.wrapper = newDeviceWrapper(tunDevice, t.iceBind.Peers())
+
+	// We need to create a wireguard-go device and listen to configuration requests
+	t.device = device.NewDevice(
+		t.wrapper,
+		t.iceBind,
+		device.NewLogger(device.LogLevelSilent, "[netbird] "),
+	)
+
+	err = t.assignAddr()
+	if err != nil {
+		t.device.Close()
+		return nil, err
+	}
+
+	t.configurer = newWGUSPConfigurer(t.device, t.name)
+	err = t.configurer.configureInterface(t.key, t.port)
+	if err != nil {
+		t.device.Close()
+		t.configurer.close()
+		return nil, err
+	}
+	return t.configurer, nil
+}
+
+func (t *tunDevice) Up() (*bind.UniversalUDPMuxDefault, error) {
+	err := t.device.Up()
+	if err != nil {
+		return nil, err
+	}
+
+	udpMux, err := t.iceBind.GetICEMux()
+	if err != nil {
+		return nil, err
+	}
+	t.udpMux = udpMux
+	log.Debugf("device is ready to use: %s", t.name)
+	return udpMux, nil
+}
+
+func (t *tunDevice) UpdateAddr(address WGAddress) error {
+	t.address = address
+	return t.assignAddr()
+}
+
+func (t *tunDevice) Close() error {
+	if t.configurer != nil {
+		t.configurer.close()
+	}
+
+	if t.device != nil {
+		t.device.Close()
+		t.device = nil
+	}
+
+	if t.udpMux != nil {
+		return t.udpMux.Close()
+	}
+	return nil
+}
+
+func (t *tunDevice) WgAddress() WGAddress {
+	return t.address
+}
+
+func (t *tunDevice) DeviceName() string {
+	return t.name
+}
+
+func (t *tunDevice) Wrapper() *DeviceWrapper {
+	return t.wrapper
+}
+
+// assignAddr Adds IP addresses to the tunnel interface and network routes based on the ranges provided. The first
+// IPv4 address replaces the address of the point to point interface, the other ones are added as aliases
+func (t *tunDevice) assignAddr() error {
+	for i, addr := range t.address.All() {
+		args := []string{t.name, "inet", addr.IP.String(), addr.IP.String()}
+		family := "-inet"
+		if addr.IP.To4() == nil {
+			maskSize, _ := addr.Network.Mask.Size()
+			args = []string{t.name, "inet6", addr.IP.String(), "prefixlen", strconv.Itoa(maskSize)}
+			family = "-inet6"
+		}
+		if i > 0 {
+			args = append(args, "alias")
+		}
+
+		cmd := exec.Command("ifconfig", args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Infof(`adding address command "%v" failed with output %s and error: `, cmd.String(), out)
+			return err
+		}
+
+		// the gateway is the address of the point to point interface
+		routeCmd := exec.Command("route", "-q", "add", family, "-net", addr.Network.String(), addr.IP.String(), "-iface")
+		if out, err := routeCmd.CombinedOutput(); err != nil {
+			log.Printf(`adding route command "%v" failed with output %s and error: `, routeCmd.String(), out)
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ package iface
 
 import (
 	"os/exec"
+	"strconv"
 
 	"github.com/pion/transport/v3"
 	log "github.com/sirupsen/logrus"
@@ -44,7 +45,7 @@ func (t *tunDevice) Create() (wgConfigurer, error) {
 	if err != nil {
 		return nil, err
 	}
-	t.wrapper = newDeviceWrapper(tunDevice)
+	t.wrapper = newDeviceWrapper(tunDevice, t.iceBind.Peers())
 
 	// We need to create a wireguard-go device and listen to configuration requests
 	t.device = device.NewDevice(
@@ -117,18 +118,32 @@ func (t *tunDevice) Wrapper() *DeviceWrapper {
 	return t.wrapper
 }
 
-// assignAddr Adds IP address to the tunnel interface and network route based on the range provided
+// assignAddr Adds IP addresses to the tunnel interface and network routes based on the ranges provided. The first
+// IPv4 address replaces the address of the point to point interface, the other ones are added as aliases
 func (t *tunDevice) assignAddr() error {
-	cmd := exec.Command("ifconfig", t.name, "inet", t.address.IP.String(), t.address.IP.String())
-	if out, err := cmd.CombinedOutput(); err != nil {
-		log.Infof(`adding address command "%v" failed with output %s and error: `, cmd.String(), out)
-		return err
-	}
-
-	routeCmd := exec.Command("route", "add", "-net", t.address.Network.String(), "-interface", t.name)
-	if out, err := routeCmd.CombinedOutput(); err != nil {
-		log.Printf(`adding route command "%v" failed with output %s and error: `, routeCmd.String(), out)
-		return err
+	for i, addr := range t.address.All() {
+		args := []string{t.name, "inet", addr.IP.String(), addr.IP.String()}
+		family := "-inet"
+		if addr.IP.To4() == nil {
+			maskSize, _ := addr.Network.Mask.Size()
+			args = []string{t.name, "inet6", addr.IP.String(), "prefixlen", strconv.Itoa(maskSize)}
+			family = "-inet6"
+		}
+		if i > 0 {
+			args = append(args, "alias")
+		}
+
+		cmd := exec.Command("ifconfig", args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Infof(`adding address command "%v" failed with output %s and error: `, cmd.String(), out)
+			return err
+		}
+
+		routeCmd := exec.Command("route", "add", family, "-net", addr.Network.String(), "-interface", t.name)
+		if out, err := routeCmd.CombinedOutput(); err != nil {
+			log.Printf(`adding route command "%v" failed with output %s and error: `, routeCmd.String(), out)
+			return err
+		}
 	}
 	return nil
 }
@@ -62,7 +62,7 @@ func (t *tunDevice) Create() (wgConfigurer, error) {
 		return nil, err
 	}
 
-	t.wrapper = newDeviceWrapper(tunDevice)
+	t.wrapper = newDeviceWrapper(tunDevice, t.iceBind.Peers())
 	log.Debug("Attaching to interface")
 	t.device = device.NewDevice(t.wrapper, t.iceBind, device.NewLogger(device.LogLevelSilent, "[wiretrustee] "))
 	// without this property mobile devices can discover remote endpoints if the configured one was wrong.
@@ -123,8 +123,9 @@ func (t *tunDevice) WgAddress() WGAddress {
 	return t.address
 }
 
+// UpdateAddr keeps track of the new address, the address of the tunnel is changed by the network extension
 func (t *tunDevice) UpdateAddr(addr WGAddress) error {
-	// todo implement
+	t.address = addr
 	return nil
 }
 
@@ -2,7 +2,12 @@
 
 package iface
 
-import "github.com/vishvananda/netlink"
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
 
 type wgLink struct {
 	attrs *netlink.LinkAttrs
@@ -31,3 +36,38 @@ func (l *wgLink) Type() string {
 func (l *wgLink) Close() error {
 	return netlink.LinkDel(l)
 }
+
+// assignLinkAddrs replaces the addresses of the interface with the primary and the secondary addresses and brings it
+// up
+func assignLinkAddrs(name string, address WGAddress) error {
+	link := newWGLink(name)
+
+	//delete existing addresses
+	list, err := netlink.AddrList(link, 0)
+	if err != nil {
+		return err
+	}
+	for _, a := range list {
+		addr := a
+		err = netlink.AddrDel(link, &addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, a := range address.All() {
+		log.Debugf("adding address %s to interface: %s", a.String(), name)
+		addr, err := netlink.ParseAddr(a.String())
+		if err != nil {
+			return err
+		}
+		err = netlink.AddrAdd(link, addr)
+		if os.IsExist(err) {
+			log.Infof("interface %s already has the address: %s", name, a.String())
+		} else if err != nil {
+			return err
+		}
+	}
+	// On linux, the link must be brought up
+	return netlink.LinkSetUp(link)
+}
@@ -49,7 +49,7 @@ func (t *tunNetstackDevice) Create() (wgConfigurer, error) {
 	if err != nil {
 		return nil, err
 	}
-	t.wrapper = newDeviceWrapper(tunIface)
+	t.wrapper = newDeviceWrapper(tunIface, t.iceBind.Peers())
 
 	t.device = device.NewDevice(
 		t.wrapper,
@@ -87,8 +87,9 @@ func (t *tunNetstackDevice) Up() (*bind.UniversalUDPMuxDefault, error) {
 	return udpMux, nil
 }
 
-func (t *tunNetstackDevice) UpdateAddr(WGAddress) error {
-	return nil
+// UpdateAddr fails, the address of the netstack interface can't be changed once the stack is created
+func (t *tunNetstackDevice) UpdateAddr(address WGAddress) error {
+	return fmt.Errorf("the netstack interface can't be moved to the address %s", address)
 }
 
 func (t *tunNetstackDevice) Close() error {
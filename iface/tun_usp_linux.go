@@ -4,11 +4,9 @@ package iface
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/pion/transport/v3"
 	log "github.com/sirupsen/logrus"
-	"github.com/vishvananda/netlink"
 	"golang.zx2c4.com/wireguard/device"
 	"golang.zx2c4.com/wireguard/tun"
 
@@ -47,7 +45,7 @@ func (t *tunUSPDevice) Create() (wgConfigurer, error) {
 	if err != nil {
 		return nil, err
 	}
-	t.wrapper = newDeviceWrapper(tunIface)
+	t.wrapper = newDeviceWrapper(tunIface, t.iceBind.Peers())
 
 	// We need to create a wireguard-go device and listen to configuration requests
 	t.device = device.NewDevice(
@@ -124,34 +122,7 @@ func (t *tunUSPDevice) Wrapper() *DeviceWrapper {
 	return t.wrapper
 }
 
-// assignAddr Adds IP address to the tunnel interface
+// assignAddr Adds IP addresses to the tunnel interface
 func (t *tunUSPDevice) assignAddr() error {
-	link := newWGLink(t.name)
-
-	//delete existing addresses
-	list, err := netlink.AddrList(link, 0)
-	if err != nil {
-		return err
-	}
-	if len(list) > 0 {
-		for _, a := range list {
-			addr := a
-			err = netlink.AddrDel(link, &addr)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	log.Debugf("adding address %s to interface: %s", t.address.String(), t.name)
-	addr, _ := netlink.ParseAddr(t.address.String())
-	err = netlink.AddrAdd(link, addr)
-	if os.IsExist(err) {
-		log.Infof("interface %s already has the address: %s", t.name, t.address.String())
-	} else if err != nil {
-		return err
-	}
-	// On linux, the link must be brought up
-	err = netlink.LinkSetUp(link)
-	return err
+	return assignLinkAddrs(t.name, t.address)
 }
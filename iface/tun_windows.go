@@ -46,7 +46,7 @@ func (t *tunDevice) Create() (wgConfigurer, error) {
 		return nil, err
 	}
 	t.nativeTunDevice = tunDevice.(*tun.NativeTun)
-	t.wrapper = newDeviceWrapper(tunDevice)
+	t.wrapper = newDeviceWrapper(tunDevice, t.iceBind.Peers())
 
 	// We need to create a wireguard-go device and listen to configuration requests
 	t.device = device.NewDevice(
@@ -147,9 +147,13 @@ func (t *tunDevice) getInterfaceGUIDString() (string, error) {
 	return guid.String(), nil
 }
 
-// assignAddr Adds IP address to the tunnel interface and network route based on the range provided
+// assignAddr Adds IP addresses to the tunnel interface and network routes based on the ranges provided
 func (t *tunDevice) assignAddr() error {
 	luid := winipcfg.LUID(t.nativeTunDevice.LUID())
-	log.Debugf("adding address %s to interface: %s", t.address.IP, t.name)
-	return luid.SetIPAddresses([]netip.Prefix{netip.MustParsePrefix(t.address.String())})
+	prefixes := make([]netip.Prefix, 0, len(t.address.Extra)+1)
+	for _, addr := range t.address.All() {
+		log.Debugf("adding address %s to interface: %s", addr.IP, t.name)
+		prefixes = append(prefixes, netip.MustParsePrefix(addr.String()))
+	}
+	return luid.SetIPAddresses(prefixes)
 }
@@ -11,7 +11,17 @@ type wgConfigurer interface {
 	configureInterface(privateKey string, port int) error
 	updatePeer(peerKey string, allowedIps string, keepAlive time.Duration, endpoint *net.UDPAddr, preSharedKey *wgtypes.Key) error
 	removePeer(peerKey string) error
+	setPeerKeepAlive(peerKey string, keepAlive time.Duration) error
 	addAllowedIP(peerKey string, allowedIP string) error
 	removeAllowedIP(peerKey string, allowedIP string) error
+	getStats(peerKey string) (WGStats, error)
+	getAllStats() (map[string]WGStats, error)
 	close()
 }
+
+// WGStats are the statistics of a WireGuard peer
+type WGStats struct {
+	LastHandshake time.Time
+	TxBytes       int64
+	RxBytes       int64
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -91,6 +92,24 @@ func (c *wgUSPConfigurer) removePeer(peerKey string) error {
 	return c.device.IpcSet(toWgUserspaceString(config))
 }
 
+func (c *wgUSPConfigurer) setPeerKeepAlive(peerKey string, keepAlive time.Duration) error {
+	peerKeyParsed, err := wgtypes.ParseKey(peerKey)
+	if err != nil {
+		return err
+	}
+
+	peer := wgtypes.PeerConfig{
+		PublicKey:                   peerKeyParsed,
+		UpdateOnly:                  true,
+		PersistentKeepaliveInterval: &keepAlive,
+	}
+
+	config := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{peer},
+	}
+	return c.device.IpcSet(toWgUserspaceString(config))
+}
+
 func (c *wgUSPConfigurer) addAllowedIP(peerKey string, allowedIP string) error {
 	_, ipNet, err := net.ParseCIDR(allowedIP)
 	if err != nil {
@@ -168,6 +187,105 @@ func (c *wgUSPConfigurer) removeAllowedIP(peerKey string, ip string) error {
 	}
 }
 
+func (c *wgUSPConfigurer) getStats(peerKey string) (WGStats, error) {
+	ipc, err := c.device.IpcGet()
+	if err != nil {
+		return WGStats{}, err
+	}
+
+	return parseStats(ipc, peerKey)
+}
+
+func (c *wgUSPConfigurer) getAllStats() (map[string]WGStats, error) {
+	ipc, err := c.device.IpcGet()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseAllStats(ipc)
+}
+
+// parseStats reads the statistics of the peer from the output of the UAPI get operation
+func parseStats(ipc string, peerKey string) (WGStats, error) {
+	if _, err := wgtypes.ParseKey(peerKey); err != nil {
+		return WGStats{}, err
+	}
+
+	allStats, err := parseAllStats(ipc)
+	if err != nil {
+		return WGStats{}, err
+	}
+
+	stats, ok := allStats[peerKey]
+	if !ok {
+		return WGStats{}, fmt.Errorf("peer not found")
+	}
+	return stats, nil
+}
+
+// parseAllStats reads the statistics of all the peers from the output of the UAPI get operation by peer public key
+func parseAllStats(ipc string) (map[string]WGStats, error) {
+	allStats := make(map[string]WGStats)
+
+	var peerKey string
+	var stats WGStats
+	var sec, nsec int64
+	savePeer := func() {
+		if peerKey == "" {
+			return
+		}
+		if sec != 0 || nsec != 0 {
+			stats.LastHandshake = time.Unix(sec, nsec)
+		}
+		allStats[peerKey] = stats
+	}
+
+	for _, line := range strings.Split(ipc, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+
+		if key == "public_key" {
+			savePeer()
+
+			keyBytes, err := hex.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("parse public key: %w", err)
+			}
+			parsedKey, err := wgtypes.NewKey(keyBytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse public key: %w", err)
+			}
+			peerKey = parsedKey.String()
+			stats = WGStats{}
+			sec, nsec = 0, 0
+			continue
+		}
+		if peerKey == "" {
+			continue
+		}
+
+		var err error
+		switch key {
+		case "last_handshake_time_sec":
+			sec, err = strconv.ParseInt(value, 10, 64)
+		case "last_handshake_time_nsec":
+			nsec, err = strconv.ParseInt(value, 10, 64)
+		case "tx_bytes":
+			stats.TxBytes, err = strconv.ParseInt(value, 10, 64)
+		case "rx_bytes":
+			stats.RxBytes, err = strconv.ParseInt(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", key, err)
+		}
+	}
+	savePeer()
+
+	return allStats, nil
+}
+
 // startUAPI starts the UAPI listener for managing the WireGuard interface via external tool
 func (t *wgUSPConfigurer) startUAPI() {
 	var err error
@@ -230,6 +348,10 @@ func toWgUserspaceString(wgCfg wgtypes.Config) string {
 		hexKey := hex.EncodeToString(p.PublicKey[:])
 		sb.WriteString(fmt.Sprintf("public_key=%s\n", hexKey))
 
+		if p.UpdateOnly {
+			sb.WriteString("update_only=true\n")
+		}
+
 		if p.PresharedKey != nil {
 			preSharedHexKey := hex.EncodeToString(p.PresharedKey[:])
 			sb.WriteString(fmt.Sprintf("preshared_key=%s\n", preSharedHexKey))
@@ -0,0 +1,93 @@
+package iface
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseStats(t *testing.T) {
+	peerKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	otherKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	hexKey := func(k wgtypes.Key) string {
+		pub := k.PublicKey()
+		return hex.EncodeToString(pub[:])
+	}
+
+	ipc := fmt.Sprintf(`private_key=0000
+listen_port=51820
+public_key=%s
+endpoint=10.0.0.1:51820
+last_handshake_time_sec=100
+last_handshake_time_nsec=0
+tx_bytes=1
+rx_bytes=2
+public_key=%s
+endpoint=10.0.0.2:51820
+last_handshake_time_sec=1700000000
+last_handshake_time_nsec=500
+tx_bytes=300
+rx_bytes=400
+persistent_keepalive_interval=25
+`, hexKey(otherKey), hexKey(peerKey))
+
+	stats, err := parseStats(ipc, peerKey.PublicKey().String())
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1700000000, 500), stats.LastHandshake)
+	assert.Equal(t, int64(300), stats.TxBytes)
+	assert.Equal(t, int64(400), stats.RxBytes)
+
+	stats, err = parseStats(fmt.Sprintf("public_key=%s\nlast_handshake_time_sec=0\nlast_handshake_time_nsec=0\n", hexKey(peerKey)), peerKey.PublicKey().String())
+	require.NoError(t, err)
+	assert.True(t, stats.LastHandshake.IsZero(), "a peer without handshake should have a zero handshake time")
+
+	missingKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	_, err = parseStats(ipc, missingKey.PublicKey().String())
+	assert.Error(t, err)
+}
+
+func TestParseAllStats(t *testing.T) {
+	peerKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+	otherKey, err := wgtypes.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	hexKey := func(k wgtypes.Key) string {
+		pub := k.PublicKey()
+		return hex.EncodeToString(pub[:])
+	}
+
+	ipc := fmt.Sprintf(`private_key=0000
+listen_port=51820
+public_key=%s
+last_handshake_time_sec=0
+last_handshake_time_nsec=0
+tx_bytes=1
+rx_bytes=2
+public_key=%s
+last_handshake_time_sec=1700000000
+last_handshake_time_nsec=500
+tx_bytes=300
+rx_bytes=400
+`, hexKey(otherKey), hexKey(peerKey))
+
+	allStats, err := parseAllStats(ipc)
+	require.NoError(t, err)
+	require.Len(t, allStats, 2)
+
+	assert.Equal(t, WGStats{TxBytes: 1, RxBytes: 2}, allStats[otherKey.PublicKey().String()])
+	assert.Equal(t, WGStats{LastHandshake: time.Unix(1700000000, 500), TxBytes: 300, RxBytes: 400},
+		allStats[peerKey.PublicKey().String()])
+
+	_, err = parseAllStats("public_key=invalid\n")
+	assert.Error(t, err)
+}
@@ -17,4 +17,9 @@ type Client interface {
 	GetDeviceAuthorizationFlow(serverKey wgtypes.Key) (*proto.DeviceAuthorizationFlow, error)
 	GetPKCEAuthorizationFlow(serverKey wgtypes.Key) (*proto.PKCEAuthorizationFlow, error)
 	GetNetworkMap() (*proto.NetworkMap, error)
+	AdvertiseRoutes(serverKey wgtypes.Key, netID string, networks, withdrawn []string) ([]*proto.AdvertisedRoute, error)
+	ReportLatency(serverKey wgtypes.Key, latencies []*proto.PeerLatency) error
+	ReportActiveRoutes(serverKey wgtypes.Key, routes []*proto.ActiveRoute) error
+	ReportUsage(serverKey wgtypes.Key, days []*proto.DailyUsage) error
+	GoingOffline() error
 }
@@ -337,6 +337,7 @@ func Test_SystemMetaDataFromClient(t *testing.T) {
 	}
 
 	info := system.GetInfo(context.TODO())
+	info.FirewallCapabilities = system.FirewallCapabilities{IPSet: true, PortRanges: true}
 	_, err = testClient.Register(*key, ValidKey, "", info, nil)
 	if err != nil {
 		t.Errorf("error while trying to register client: %v", err)
@@ -352,6 +353,10 @@ func Test_SystemMetaDataFromClient(t *testing.T) {
 		Platform:           info.Platform,
 		OS:                 info.OS,
 		WiretrusteeVersion: info.WiretrusteeVersion,
+		FirewallCapabilities: &mgmtProto.FirewallCapabilities{
+			Ipset:      true,
+			PortRanges: true,
+		},
 	}
 
 	assert.Equal(t, ValidKey, actualValidKey)
@@ -447,3 +452,47 @@ func Test_GetPKCEAuthorizationFlow(t *testing.T) {
 	assert.Equal(t, expectedFlowInfo.ProviderConfig.ClientID, flowInfo.ProviderConfig.ClientID, "provider configured client ID should match")
 	assert.Equal(t, expectedFlowInfo.ProviderConfig.ClientSecret, flowInfo.ProviderConfig.ClientSecret, "provider configured client secret should match")
 }
+
+func Test_GoingOffline(t *testing.T) {
+	s, lis, mgmtMockServer, serverKey := startMockManagement(t)
+	defer s.GracefulStop()
+
+	testKey, err := wgtypes.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverAddr := lis.Addr().String()
+	ctx := context.Background()
+
+	client, err := NewClient(ctx, serverAddr, testKey, false)
+	if err != nil {
+		t.Fatalf("error while creating testClient: %v", err)
+	}
+
+	var wgPubKey string
+	mgmtMockServer.GoingOfflineFunc = func(ctx context.Context, req *mgmtProto.EncryptedMessage) (*mgmtProto.EncryptedMessage, error) {
+		err := encryption.DecryptMessage(testKey.PublicKey(), serverKey, req.Body, &mgmtProto.Empty{})
+		if err != nil {
+			return nil, err
+		}
+		wgPubKey = req.WgPubKey
+
+		encryptedResp, err := encryption.EncryptMessage(testKey.PublicKey(), serverKey, &mgmtProto.Empty{})
+		if err != nil {
+			return nil, err
+		}
+
+		return &mgmtProto.EncryptedMessage{
+			WgPubKey: serverKey.PublicKey().String(),
+			Body:     encryptedResp,
+		}, nil
+	}
+
+	err = client.GoingOffline()
+	if err != nil {
+		t.Fatalf("error while notifying going offline: %v", err)
+	}
+
+	assert.Equal(t, testKey.PublicKey().String(), wgPubKey, "the request should come from the peer")
+}
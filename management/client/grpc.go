@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net/netip"
 	"sync"
 	"time"
 
@@ -24,14 +25,21 @@ import (
 	"github.com/FlintyLemming/netbird/client/system"
 	"github.com/FlintyLemming/netbird/encryption"
 	"github.com/FlintyLemming/netbird/management/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
+// goingOfflineTimeout limits the time spent notifying the Management service on shutdown
+const goingOfflineTimeout = 2 * time.Second
+
 // ConnStateNotifier is a wrapper interface of the status recorders
 type ConnStateNotifier interface {
 	MarkManagementDisconnected()
 	MarkManagementConnected()
 }
 
+// AttestationFunc returns a signed attestation statement of the peer's WireGuard public key
+type AttestationFunc func(wgPubKey string) (*proto.PeerAttestation, error)
+
 type GrpcClient struct {
 	key                   wgtypes.Key
 	realClient            proto.ManagementServiceClient
@@ -39,6 +47,8 @@ type GrpcClient struct {
 	conn                  *grpc.ClientConn
 	connStateCallback     ConnStateNotifier
 	connStateCallbackLock sync.RWMutex
+	attestationFunc       AttestationFunc
+	maintenance           maintenance
 }
 
 // NewClient creates a new client to Management service
@@ -59,7 +69,9 @@ func NewClient(ctx context.Context, addr string, ourPrivateKey wgtypes.Key, tlsE
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:    30 * time.Second,
 			Timeout: 10 * time.Second,
-		}))
+		}),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor()))
 	if err != nil {
 		log.Errorf("failed creating connection to Management Service %v", err)
 		return nil, err
@@ -81,6 +93,12 @@ func (c *GrpcClient) Close() error {
 	return c.conn.Close()
 }
 
+// Conn returns the connection to the Management Service, the other services served by the Management server can be
+// reached over it. It is closed with the client
+func (c *GrpcClient) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
 // SetConnStateListener set the ConnStateNotifier
 func (c *GrpcClient) SetConnStateListener(notifier ConnStateNotifier) {
 	c.connStateCallbackLock.Lock()
@@ -88,6 +106,24 @@ func (c *GrpcClient) SetConnStateListener(notifier ConnStateNotifier) {
 	c.connStateCallback = notifier
 }
 
+// SetAttestationFunc sets the function used to attach an attestation statement to Login, Register and Sync requests
+func (c *GrpcClient) SetAttestationFunc(attestationFunc AttestationFunc) {
+	c.attestationFunc = attestationFunc
+}
+
+// attestation returns a new attestation statement or nil if attestation is not enabled
+func (c *GrpcClient) attestation() (*proto.PeerAttestation, error) {
+	if c.attestationFunc == nil {
+		return nil, nil
+	}
+	attestation, err := c.attestationFunc(c.key.PublicKey().String())
+	if err != nil {
+		log.Errorf("failed to create attestation statement: %s", err)
+		return nil, err
+	}
+	return attestation, nil
+}
+
 // defaultBackoff is a basic backoff mechanism for general issues
 func defaultBackoff(ctx context.Context) backoff.BackOff {
 	return backoff.WithContext(&backoff.ExponentialBackOff{
@@ -110,7 +146,7 @@ func (c *GrpcClient) ready() bool {
 // Sync wraps the real client's Sync endpoint call and takes care of retries and encryption/decryption of messages
 // Blocking request. The result will be sent via msgHandler callback function
 func (c *GrpcClient) Sync(msgHandler func(msg *proto.SyncResponse) error) error {
-	backOff := defaultBackoff(c.ctx)
+	backOff := backoff.WithContext(&maintenanceBackOff{BackOff: defaultBackoff(c.ctx), maintenance: &c.maintenance}, c.ctx)
 
 	operation := func() error {
 		log.Debugf("management connection state %v", c.conn.GetState())
@@ -141,6 +177,7 @@ func (c *GrpcClient) Sync(msgHandler func(msg *proto.SyncResponse) error) error
 		}
 
 		log.Infof("connected to the Management Service stream")
+		c.maintenance.end()
 		c.notifyConnected()
 		// blocking until error
 		err = c.receiveEvents(stream, *serverPubKey, msgHandler)
@@ -155,7 +192,7 @@ func (c *GrpcClient) Sync(msgHandler func(msg *proto.SyncResponse) error) error
 			default:
 				backOff.Reset() // reset backoff counter after successful connection
 				c.notifyDisconnected()
-				log.Warnf("disconnected from the Management service but will retry silently. Reason: %v", err)
+				c.maintenance.logDisconnected(err)
 				return err
 			}
 		}
@@ -216,7 +253,11 @@ func (c *GrpcClient) GetNetworkMap() (*proto.NetworkMap, error) {
 }
 
 func (c *GrpcClient) connectToStream(ctx context.Context, serverPubKey wgtypes.Key) (proto.ManagementService_SyncClient, error) {
-	req := &proto.SyncRequest{}
+	attestation, err := c.attestation()
+	if err != nil {
+		return nil, err
+	}
+	req := &proto.SyncRequest{Attestation: attestation, ChunksSupported: true}
 
 	myPrivateKey := c.key
 	myPublicKey := myPrivateKey.PublicKey()
@@ -235,6 +276,8 @@ func (c *GrpcClient) connectToStream(ctx context.Context, serverPubKey wgtypes.K
 }
 
 func (c *GrpcClient) receiveEvents(stream proto.ManagementService_SyncClient, serverPubKey wgtypes.Key, msgHandler func(msg *proto.SyncResponse) error) error {
+	// the chunks of a large response are reassembled before handling it, a new stream starts a new response
+	assembler := &proto.SyncResponseAssembler{}
 	for {
 		update, err := stream.Recv()
 		if err == io.EOF {
@@ -254,6 +297,21 @@ func (c *GrpcClient) receiveEvents(stream proto.ManagementService_SyncClient, se
 			return err
 		}
 
+		decryptedResp, err = assembler.Add(decryptedResp)
+		if err != nil {
+			log.Errorf("failed reassembling update message from Management Service: %s", err)
+			return err
+		}
+		if decryptedResp == nil {
+			continue
+		}
+
+		if notice := decryptedResp.GetMaintenance(); notice != nil {
+			log.Infof("the Management service announced a maintenance of %s: %s", notice.GetWindow().AsDuration(),
+				notice.GetMessage())
+			c.maintenance.start(notice.GetWindow().AsDuration())
+		}
+
 		err = msgHandler(decryptedResp)
 		if err != nil {
 			log.Errorf("failed handling an update message received from Management Service: %v", err.Error())
@@ -287,6 +345,11 @@ func (c *GrpcClient) login(serverKey wgtypes.Key, req *proto.LoginRequest) (*pro
 	if !c.ready() {
 		return nil, fmt.Errorf("no connection to management")
 	}
+	attestation, err := c.attestation()
+	if err != nil {
+		return nil, err
+	}
+	req.Attestation = attestation
 	loginReq, err := encryption.EncryptMessage(serverKey, c.key, req)
 	if err != nil {
 		log.Errorf("failed to encrypt message: %s", err)
@@ -400,6 +463,124 @@ func (c *GrpcClient) GetPKCEAuthorizationFlow(serverKey wgtypes.Key) (*proto.PKC
 	return flowInfoResp, nil
 }
 
+// AdvertiseRoutes requests routes to the networks through this peer and removes the routes of the withdrawn networks.
+// The routes returned may wait for the approval of an admin before being distributed
+func (c *GrpcClient) AdvertiseRoutes(serverKey wgtypes.Key, netID string, networks, withdrawn []string) ([]*proto.AdvertisedRoute, error) {
+	if !c.ready() {
+		return nil, fmt.Errorf("no connection to management in order to advertise routes")
+	}
+	mgmCtx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+	defer cancel()
+
+	message := &proto.AdvertiseRoutesRequest{
+		Networks:          networks,
+		NetID:             netID,
+		WithdrawnNetworks: withdrawn,
+	}
+	encryptedMSG, err := encryption.EncryptMessage(serverKey, c.key, message)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.realClient.AdvertiseRoutes(mgmCtx, &proto.EncryptedMessage{
+		WgPubKey: c.key.PublicKey().String(),
+		Body:     encryptedMSG,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	advertiseResp := &proto.AdvertiseRoutesResponse{}
+	err = encryption.DecryptMessage(serverKey, c.key, resp.Body, advertiseResp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt advertise routes response: %w", err)
+	}
+
+	return advertiseResp.GetRoutes(), nil
+}
+
+// ReportLatency sends the latency measured to other peers over the tunnel to the Management service
+func (c *GrpcClient) ReportLatency(serverKey wgtypes.Key, latencies []*proto.PeerLatency) error {
+	if !c.ready() {
+		return fmt.Errorf("no connection to management in order to report latency")
+	}
+	mgmCtx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+	defer cancel()
+
+	encryptedMSG, err := encryption.EncryptMessage(serverKey, c.key, &proto.LatencyReport{Latencies: latencies})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.realClient.ReportLatency(mgmCtx, &proto.EncryptedMessage{
+		WgPubKey: c.key.PublicKey().String(),
+		Body:     encryptedMSG,
+	})
+	return err
+}
+
+// ReportActiveRoutes sends the routes chosen among the routes of the same network to the Management service
+func (c *GrpcClient) ReportActiveRoutes(serverKey wgtypes.Key, routes []*proto.ActiveRoute) error {
+	if !c.ready() {
+		return fmt.Errorf("no connection to management in order to report active routes")
+	}
+	mgmCtx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+	defer cancel()
+
+	encryptedMSG, err := encryption.EncryptMessage(serverKey, c.key, &proto.ActiveRoutesReport{Routes: routes})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.realClient.ReportActiveRoutes(mgmCtx, &proto.EncryptedMessage{
+		WgPubKey: c.key.PublicKey().String(),
+		Body:     encryptedMSG,
+	})
+	return err
+}
+
+// ReportUsage sends the daily traffic of the peer over the tunnel to the Management service
+func (c *GrpcClient) ReportUsage(serverKey wgtypes.Key, days []*proto.DailyUsage) error {
+	if !c.ready() {
+		return fmt.Errorf("no connection to management in order to report usage")
+	}
+	mgmCtx, cancel := context.WithTimeout(c.ctx, time.Second*10)
+	defer cancel()
+
+	encryptedMSG, err := encryption.EncryptMessage(serverKey, c.key, &proto.UsageReport{Days: days})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.realClient.ReportUsage(mgmCtx, &proto.EncryptedMessage{
+		WgPubKey: c.key.PublicKey().String(),
+		Body:     encryptedMSG,
+	})
+	return err
+}
+
+// GoingOffline notifies the Management service that the peer is shutting down, so it is marked disconnected right away
+func (c *GrpcClient) GoingOffline() error {
+	serverKey, err := c.GetServerPublicKey()
+	if err != nil {
+		return err
+	}
+
+	mgmCtx, cancel := context.WithTimeout(c.ctx, goingOfflineTimeout)
+	defer cancel()
+
+	encryptedMSG, err := encryption.EncryptMessage(*serverKey, c.key, &proto.Empty{})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.realClient.GoingOffline(mgmCtx, &proto.EncryptedMessage{
+		WgPubKey: c.key.PublicKey().String(),
+		Body:     encryptedMSG,
+	})
+	return err
+}
+
 func (c *GrpcClient) notifyDisconnected() {
 	c.connStateCallbackLock.RLock()
 	defer c.connStateCallbackLock.RUnlock()
@@ -433,5 +614,35 @@ func infoToMetaData(info *system.Info) *proto.PeerSystemMeta {
 		Kernel:             info.Kernel,
 		WiretrusteeVersion: info.WiretrusteeVersion,
 		UiVersion:          info.UIVersion,
+		Capabilities:       info.Capabilities,
+		FirewallCapabilities: &proto.FirewallCapabilities{
+			Ipset:      info.FirewallCapabilities.IPSet,
+			PortRanges: info.FirewallCapabilities.PortRanges,
+			Reject:     info.FirewallCapabilities.Reject,
+			Ipv6:       info.FirewallCapabilities.IPv6,
+			SctpGre:    info.FirewallCapabilities.SCTPAndGRE,
+		},
+		Services:    infoToServices(info.Services),
+		LanNetworks: infoToLANNetworks(info.LANNetworks),
+	}
+}
+
+func infoToLANNetworks(networks []netip.Prefix) []string {
+	var protoNetworks []string
+	for _, network := range networks {
+		protoNetworks = append(protoNetworks, network.String())
+	}
+	return protoNetworks
+}
+
+func infoToServices(services []system.Service) []*proto.PeerService {
+	var protoServices []*proto.PeerService
+	for _, service := range services {
+		protoServices = append(protoServices, &proto.PeerService{
+			Label:    service.Label,
+			Port:     uint32(service.Port),
+			Protocol: service.Protocol,
+		})
 	}
+	return protoServices
 }
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+// maintenanceRetryInterval is the interval of the reconnection attempts during an announced maintenance once the
+// first attempt, spread over the window, failed
+const maintenanceRetryInterval = 30 * time.Second
+
+// maintenance tracks the maintenance window announced by the Management service
+type maintenance struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// start records a maintenance ending after the window
+func (m *maintenance) start(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.until = time.Now().Add(window)
+}
+
+// end clears the maintenance, e.g. once the client is connected again
+func (m *maintenance) end() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.until = time.Time{}
+}
+
+// remaining returns the time left in the maintenance window, 0 when there is no maintenance
+func (m *maintenance) remaining() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if remaining := time.Until(m.until); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// logDisconnected logs the disconnection from the Management service, quietly during an announced maintenance
+func (m *maintenance) logDisconnected(err error) {
+	if remaining := m.remaining(); remaining > 0 {
+		log.Infof("disconnected from the Management service for the announced maintenance, keeping the last "+
+			"configuration and reconnecting within %s. Reason: %v", remaining.Round(time.Second), err)
+		return
+	}
+	log.Warnf("disconnected from the Management service but will retry silently. Reason: %v", err)
+}
+
+// maintenanceBackOff extends the backoff during an announced maintenance: the first attempt waits for a random
+// part of the window, so that the clients don't all reconnect at once when the service is back, the next ones wait
+// for maintenanceRetryInterval. The wrapped backoff is used outside the maintenance window
+type maintenanceBackOff struct {
+	backoff.BackOff
+	maintenance *maintenance
+	spread      bool
+}
+
+// NextBackOff returns the delay before the next attempt
+func (b *maintenanceBackOff) NextBackOff() time.Duration {
+	remaining := b.maintenance.remaining()
+	if remaining == 0 {
+		return b.BackOff.NextBackOff()
+	}
+	if !b.spread {
+		b.spread = true
+		return time.Duration(rand.Int63n(int64(remaining)))
+	}
+	return maintenanceRetryInterval
+}
+
+// Reset resets the wrapped backoff, the next attempt during a maintenance is spread over the window again
+func (b *maintenanceBackOff) Reset() {
+	b.spread = false
+	b.BackOff.Reset()
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+func TestMaintenanceBackOff(t *testing.T) {
+	m := &maintenance{}
+	b := &maintenanceBackOff{BackOff: backoff.NewConstantBackOff(time.Second), maintenance: m}
+
+	if next := b.NextBackOff(); next != time.Second {
+		t.Errorf("expected the wrapped backoff outside a maintenance, got %s", next)
+	}
+
+	m.start(time.Minute)
+	if next := b.NextBackOff(); next < 0 || next >= time.Minute {
+		t.Errorf("expected the first attempt to be spread over the window, got %s", next)
+	}
+	if next := b.NextBackOff(); next != maintenanceRetryInterval {
+		t.Errorf("expected the next attempts to wait for %s, got %s", maintenanceRetryInterval, next)
+	}
+
+	b.Reset()
+	if next := b.NextBackOff(); next >= time.Minute {
+		t.Errorf("expected the first attempt after a reset to be spread over the window, got %s", next)
+	}
+
+	m.end()
+	if next := b.NextBackOff(); next != time.Second {
+		t.Errorf("expected the wrapped backoff once the maintenance ended, got %s", next)
+	}
+}
@@ -14,6 +14,11 @@ type MockClient struct {
 	LoginFunc                      func(serverKey wgtypes.Key, info *system.Info, sshKey []byte) (*proto.LoginResponse, error)
 	GetDeviceAuthorizationFlowFunc func(serverKey wgtypes.Key) (*proto.DeviceAuthorizationFlow, error)
 	GetPKCEAuthorizationFlowFunc   func(serverKey wgtypes.Key) (*proto.PKCEAuthorizationFlow, error)
+	AdvertiseRoutesFunc            func(serverKey wgtypes.Key, netID string, networks, withdrawn []string) ([]*proto.AdvertisedRoute, error)
+	ReportLatencyFunc              func(serverKey wgtypes.Key, latencies []*proto.PeerLatency) error
+	ReportActiveRoutesFunc         func(serverKey wgtypes.Key, routes []*proto.ActiveRoute) error
+	ReportUsageFunc                func(serverKey wgtypes.Key, days []*proto.DailyUsage) error
+	GoingOfflineFunc               func() error
 }
 
 func (m *MockClient) Close() error {
@@ -69,3 +74,38 @@ func (m *MockClient) GetPKCEAuthorizationFlow(serverKey wgtypes.Key) (*proto.PKC
 func (m *MockClient) GetNetworkMap() (*proto.NetworkMap, error) {
 	return nil, nil
 }
+
+func (m *MockClient) AdvertiseRoutes(serverKey wgtypes.Key, netID string, networks, withdrawn []string) ([]*proto.AdvertisedRoute, error) {
+	if m.AdvertiseRoutesFunc == nil {
+		return nil, nil
+	}
+	return m.AdvertiseRoutesFunc(serverKey, netID, networks, withdrawn)
+}
+
+func (m *MockClient) ReportLatency(serverKey wgtypes.Key, latencies []*proto.PeerLatency) error {
+	if m.ReportLatencyFunc == nil {
+		return nil
+	}
+	return m.ReportLatencyFunc(serverKey, latencies)
+}
+
+func (m *MockClient) ReportActiveRoutes(serverKey wgtypes.Key, routes []*proto.ActiveRoute) error {
+	if m.ReportActiveRoutesFunc == nil {
+		return nil
+	}
+	return m.ReportActiveRoutesFunc(serverKey, routes)
+}
+
+func (m *MockClient) ReportUsage(serverKey wgtypes.Key, days []*proto.DailyUsage) error {
+	if m.ReportUsageFunc == nil {
+		return nil
+	}
+	return m.ReportUsageFunc(serverKey, days)
+}
+
+func (m *MockClient) GoingOffline() error {
+	if m.GoingOfflineFunc == nil {
+		return nil
+	}
+	return m.GoingOfflineFunc()
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/management/server"
+	"github.com/FlintyLemming/netbird/util"
+)
+
+var (
+	bundleConfig    string
+	bundleDataDir   string
+	exportAccountID string
+	exportOutput    string
+	bundleFormat    string
+)
+
+var shortExport = "Export the configuration of an account as a versioned JSON or YAML bundle."
+
+var exportCmd = &cobra.Command{
+	Use:   "export [--account ID] [--output file] [--format json|yaml]",
+	Short: shortExport,
+	Long: shortExport +
+		"\n\n" +
+		"The bundle holds the peers, groups, policies, routes, DNS and settings of the account, for a backup, a migration " +
+		"between store engines or a review of the configuration. The setup keys and the personal access tokens are not " +
+		"exported. The account is optional when the store has a single account. Stop the Management service before " +
+		"running this command with the JSON file store.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flag.Parse()
+		if err := util.InitLog(logLevel, logFile); err != nil {
+			return fmt.Errorf("failed initializing log %v", err)
+		}
+
+		store, err := openBundleStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close() //nolint
+
+		account, err := exportedAccount(store)
+		if err != nil {
+			return err
+		}
+
+		data, err := server.MarshalAccountBundle(server.ExportAccount(account), bundleFileFormat(exportOutput))
+		if err != nil {
+			return fmt.Errorf("failed encoding the account bundle: %v", err)
+		}
+
+		if exportOutput == "" || exportOutput == "-" {
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		}
+		if err := os.WriteFile(exportOutput, data, 0600); err != nil {
+			return fmt.Errorf("failed writing the account bundle: %v", err)
+		}
+		log.Infof("exported account %s to %s", account.Id, exportOutput)
+		return nil
+	},
+}
+
+var shortImport = "Import an account bundle created by the export command."
+
+var importCmd = &cobra.Command{
+	Use:   "import <file> [--format json|yaml]",
+	Short: shortImport,
+	Long: shortImport +
+		"\n\n" +
+		"An existing account with the ID of the bundle is replaced but keeps its setup keys and the personal access " +
+		"tokens of its users, a missing one is created. The bundle is validated before the store is changed. Stop " +
+		"the Management service before running this command.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flag.Parse()
+		if err := util.InitLog(logLevel, logFile); err != nil {
+			return fmt.Errorf("failed initializing log %v", err)
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed reading the account bundle: %v", err)
+		}
+		bundle, err := server.UnmarshalAccountBundle(data, bundleFileFormat(args[0]))
+		if err != nil {
+			return fmt.Errorf("failed decoding the account bundle: %v", err)
+		}
+		if err := bundle.Validate(); err != nil {
+			return fmt.Errorf("invalid account bundle: %v", err)
+		}
+
+		store, err := openBundleStore()
+		if err != nil {
+			return err
+		}
+		defer store.Close() //nolint
+
+		account, err := server.ImportAccount(store, bundle)
+		if err != nil {
+			return fmt.Errorf("failed importing the account bundle: %v", err)
+		}
+		log.Infof("imported account %s with %d peers, %d groups and %d policies", account.Id, len(account.Peers),
+			len(account.Groups), len(account.Policies))
+		return nil
+	},
+}
+
+// openBundleStore opens the store of the Management config. The config is read as is, without the OIDC discovery of
+// the Management service
+func openBundleStore() (server.Store, error) {
+	config := &server.Config{}
+	if _, err := util.ReadJson(bundleConfig, config); err != nil {
+		return nil, fmt.Errorf("failed reading the Management config %s: %v", bundleConfig, err)
+	}
+	if bundleDataDir != "" {
+		config.Datadir = bundleDataDir
+	}
+
+	store, err := server.NewStore(config.StoreConfig.Engine, config.Datadir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening the store %s: %v", config.Datadir, err)
+	}
+	return store, nil
+}
+
+// exportedAccount returns the account to export, the only account of the store when none is set
+func exportedAccount(store server.Store) (*server.Account, error) {
+	if exportAccountID != "" {
+		return store.GetAccount(exportAccountID)
+	}
+
+	accounts := store.GetAllAccounts()
+	if len(accounts) != 1 {
+		return nil, fmt.Errorf("the store has %d accounts, set the account to export with --account", len(accounts))
+	}
+	return accounts[0], nil
+}
+
+// bundleFileFormat returns the format set by the flag, otherwise the one of the file extension, JSON by default
+func bundleFileFormat(file string) string {
+	if bundleFormat != "" {
+		return bundleFormat
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return server.AccountBundleYAML
+	default:
+		return server.AccountBundleJSON
+	}
+}
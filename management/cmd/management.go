@@ -33,10 +33,14 @@ import (
 	mgmtProto "github.com/FlintyLemming/netbird/management/proto"
 	"github.com/FlintyLemming/netbird/management/server"
 	httpapi "github.com/FlintyLemming/netbird/management/server/http"
+	"github.com/FlintyLemming/netbird/management/server/http/dashboard"
 	"github.com/FlintyLemming/netbird/management/server/idp"
 	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
 	"github.com/FlintyLemming/netbird/management/server/metrics"
 	"github.com/FlintyLemming/netbird/management/server/telemetry"
+	signalProto "github.com/FlintyLemming/netbird/signal/proto"
+	signalServer "github.com/FlintyLemming/netbird/signal/server"
+	"github.com/FlintyLemming/netbird/tracing"
 	"github.com/FlintyLemming/netbird/util"
 )
 
@@ -48,6 +52,7 @@ var (
 	mgmtPort                int
 	mgmtMetricsPort         int
 	mgmtLetsencryptDomain   string
+	mgmtLetsencryptDNS      string
 	mgmtSingleAccModeDomain string
 	certFile                string
 	certKey                 string
@@ -87,6 +92,14 @@ var (
 				config.HttpConfig.IdpSignKeyRefreshEnabled = idpSignKeyRefreshEnabled
 			}
 
+			if cmd.Flag(embeddedSignalFlagName).Changed {
+				config.EmbeddedSignal = embeddedSignal
+			}
+
+			if cmd.Flag(embeddedDashboardFlagName).Changed {
+				config.HttpConfig.EmbeddedDashboard = embeddedDashboard
+			}
+
 			tlsEnabled := false
 			if mgmtLetsencryptDomain != "" || (config.HttpConfig.CertFile != "" && config.HttpConfig.CertKey != "") {
 				tlsEnabled = true
@@ -120,6 +133,33 @@ var (
 					return fmt.Errorf("failed creating datadir: %s: %v", config.Datadir, err)
 				}
 			}
+
+			tracingConfig := config.Tracing
+			if tracingConfig == nil {
+				envConfig, err := tracing.ConfigFromEnv()
+				if err != nil {
+					return fmt.Errorf("failed reading the tracing config: %v", err)
+				}
+				tracingConfig = &envConfig
+			}
+			shutdownTracing, err := tracing.Init("netbird-management", *tracingConfig)
+			if err != nil {
+				return fmt.Errorf("failed initializing tracing: %v", err)
+			}
+			defer func() {
+				if err := shutdownTracing(context.Background()); err != nil {
+					log.Warnf("failed flushing the spans: %v", err)
+				}
+			}()
+
+			if embeddedRelay != nil {
+				relay, err := embeddedRelay(config)
+				if err != nil {
+					return fmt.Errorf("failed starting the relay: %v", err)
+				}
+				defer relay.Close() //nolint:errcheck
+			}
+
 			appMetrics, err := telemetry.NewDefaultAppMetrics(cmd.Context())
 			if err != nil {
 				return err
@@ -164,14 +204,52 @@ var (
 			if err != nil {
 				return fmt.Errorf("failed to build default manager: %v", err)
 			}
+			accountManager.SetIdentityProviders(config.IdentityProviders)
+
+			if config.SignalPresenceToken != "" {
+				signalPresence, err := server.NewSignalPresence(config.Signal, config.SignalPresenceToken)
+				if err != nil {
+					return fmt.Errorf("failed creating the Signal presence client: %v", err)
+				}
+				defer signalPresence.Close()
+				accountManager.SetSignalPresence(signalPresence)
+			}
+
+			if config.GitOps != nil {
+				gitOpsSyncer, err := server.NewGitOpsSyncer(*config.GitOps, config.Datadir, accountManager)
+				if err != nil {
+					return fmt.Errorf("failed creating the GitOps sync: %v", err)
+				}
+				gitOpsSyncer.Start(context.Background())
+				defer gitOpsSyncer.Stop()
+			}
 
 			turnManager := server.NewTimeBasedAuthSecretsManager(peersUpdateManager, config.TURNConfig)
 
-			gRPCOpts := []grpc.ServerOption{grpc.KeepaliveEnforcementPolicy(kaep), grpc.KeepaliveParams(kasp)}
+			gRPCOpts := []grpc.ServerOption{
+				grpc.KeepaliveEnforcementPolicy(kaep),
+				grpc.KeepaliveParams(kasp),
+				grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),
+				grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()),
+			}
 			var certManager *autocert.Manager
 			var tlsConfig *tls.Config
 			tlsEnabled := false
-			if config.HttpConfig.LetsEncryptDomain != "" {
+			if config.HttpConfig.LetsEncryptDomain != "" && config.HttpConfig.LetsEncryptDNSProvider != "" {
+				dnsCertManager, err := encryption.CreateDNSCertManager(config.Datadir, config.HttpConfig.LetsEncryptDomain, config.HttpConfig.LetsEncryptDNSProvider)
+				if err != nil {
+					return fmt.Errorf("failed creating LetsEncrypt cert manager: %v", err)
+				}
+				certCtx, cancelCert := context.WithCancel(context.Background())
+				defer cancelCert()
+				if err := dnsCertManager.Start(certCtx); err != nil {
+					return fmt.Errorf("failed obtaining LetsEncrypt certificate: %v", err)
+				}
+				tlsConfig = dnsCertManager.TLSConfig()
+				transportCredentials := credentials.NewTLS(tlsConfig)
+				gRPCOpts = append(gRPCOpts, grpc.Creds(transportCredentials))
+				tlsEnabled = true
+			} else if config.HttpConfig.LetsEncryptDomain != "" {
 				certManager, err = encryption.CreateCertManager(config.Datadir, config.HttpConfig.LetsEncryptDomain)
 				if err != nil {
 					return fmt.Errorf("failed creating LetsEncrypt cert manager: %v", err)
@@ -199,6 +277,11 @@ var (
 			if err != nil {
 				return fmt.Errorf("failed creating JWT validator: %v", err)
 			}
+			for _, issuer := range config.GetIdentityProviderIssuers() {
+				if err := jwtValidator.AddIssuer(issuer, config.HttpConfig.AuthUserIDClaim); err != nil {
+					return fmt.Errorf("failed adding identity provider %s to JWT validator: %v", issuer.Issuer, err)
+				}
+			}
 
 			httpAPIAuthCfg := httpapi.AuthCfg{
 				Issuer:       config.HttpConfig.AuthIssuer,
@@ -210,6 +293,10 @@ var (
 			if err != nil {
 				return fmt.Errorf("failed creating HTTP API handler: %v", err)
 			}
+			if config.HttpConfig.EmbeddedDashboard {
+				log.Infof("serving the embedded dashboard under %s", dashboard.PathPrefix)
+				httpAPIHandler = dashboard.Handler(httpAPIHandler)
+			}
 
 			ephemeralManager := server.NewEphemeralManager(store, accountManager)
 			ephemeralManager.LoadInitialPeers()
@@ -220,6 +307,18 @@ var (
 				return fmt.Errorf("failed creating gRPC API handler: %v", err)
 			}
 			mgmtProto.RegisterManagementServiceServer(gRPCAPIHandler, srv)
+			if config.EmbeddedSignal {
+				rateLimits := signalServer.DefaultRateLimitConfig()
+				if config.SignalRateLimits != nil {
+					rateLimits = *config.SignalRateLimits
+				}
+				sigServer, err := signalServer.NewServer(config.SignalPresenceToken, rateLimits, appMetrics.GetMeter())
+				if err != nil {
+					return fmt.Errorf("failed creating the Signal service: %v", err)
+				}
+				signalProto.RegisterSignalExchangeServer(gRPCAPIHandler, sigServer)
+				log.Infof("serving the Signal service on the Management gRPC server")
+			}
 
 			installationID, err := getInstallationID(store)
 			if err != nil {
@@ -284,6 +383,11 @@ var (
 			SetupCloseHandler()
 
 			<-stopCh
+			if maintenanceWindow > 0 {
+				peersUpdateManager.AnnounceMaintenance(maintenanceWindow, "the Management service is restarting")
+				// let the streams flush the notice before they are closed
+				time.Sleep(maintenanceFlushDelay)
+			}
 			ephemeralManager.Stop()
 			_ = appMetrics.Close()
 			_ = listener.Close()
@@ -300,6 +404,9 @@ var (
 	}
 )
 
+// maintenanceFlushDelay is the time the streams have to send the maintenance notice before the server stops
+const maintenanceFlushDelay = time.Second
+
 func notifyStop(msg string) {
 	select {
 	case stopCh <- 1:
@@ -392,6 +499,9 @@ func loadMgmtConfig(mgmtConfigPath string) (*server.Config, error) {
 	if mgmtLetsencryptDomain != "" {
 		loadedConfig.HttpConfig.LetsEncryptDomain = mgmtLetsencryptDomain
 	}
+	if mgmtLetsencryptDNS != "" {
+		loadedConfig.HttpConfig.LetsEncryptDNSProvider = mgmtLetsencryptDNS
+	}
 	if mgmtDataDir != "" {
 		loadedConfig.Datadir = mgmtDataDir
 	}
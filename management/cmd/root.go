@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,6 +15,8 @@ const (
 	// ExitSetupFailed defines exit code
 	ExitSetupFailed                  = 1
 	idpSignKeyRefreshEnabledFlagName = "idp-sign-key-refresh-enabled"
+	embeddedSignalFlagName           = "embedded-signal"
+	embeddedDashboardFlagName        = "embedded-dashboard"
 )
 
 var (
@@ -26,6 +29,9 @@ var (
 	disableSingleAccMode     bool
 	idpSignKeyRefreshEnabled bool
 	userDeleteFromIDPEnabled bool
+	embeddedSignal           bool
+	embeddedDashboard        bool
+	maintenanceWindow        time.Duration
 
 	rootCmd = &cobra.Command{
 		Use:          "netbird-mgmt",
@@ -57,6 +63,7 @@ func init() {
 	mgmtCmd.Flags().StringVar(&mgmtDataDir, "datadir", defaultMgmtDataDir, "server data directory location")
 	mgmtCmd.Flags().StringVar(&mgmtConfig, "config", defaultMgmtConfig, "Netbird config file location. Config params specified via command line (e.g. datadir) have a precedence over configuration from this file")
 	mgmtCmd.Flags().StringVar(&mgmtLetsencryptDomain, "letsencrypt-domain", "", "a domain to issue Let's Encrypt certificate for. Enables TLS using Let's Encrypt. Will fetch and renew certificate, and run the server with TLS")
+	mgmtCmd.Flags().StringVar(&mgmtLetsencryptDNS, "letsencrypt-dns-provider", "", "a DNS provider to fulfill the Let's Encrypt DNS-01 challenge with instead of the HTTP-01 one, so the server doesn't have to be reachable on port 80 or 443. Supported providers: cloudflare, rfc2136 and exec, configured by their environment variables. Requires letsencrypt-domain")
 	mgmtCmd.Flags().StringVar(&mgmtSingleAccModeDomain, "single-account-mode-domain", defaultSingleAccModeDomain, "Enables single account mode. This means that all the users will be under the same account grouped by the specified domain. If the installation has more than one account, the property is ineffective. Enabled by default with the default domain "+defaultSingleAccModeDomain)
 	mgmtCmd.Flags().BoolVar(&disableSingleAccMode, "disable-single-account-mode", false, "If set to true, disables single account mode. The --single-account-mode-domain property will be ignored and every new user will have a separate NetBird account.")
 	mgmtCmd.Flags().StringVar(&certFile, "cert-file", "", "Location of your SSL certificate. Can be used when you have an existing certificate and don't want a new certificate be generated automatically. If letsencrypt-domain is specified this property has no effect")
@@ -65,6 +72,9 @@ func init() {
 	mgmtCmd.Flags().StringVar(&dnsDomain, "dns-domain", defaultSingleAccModeDomain, fmt.Sprintf("Domain used for peer resolution. This is appended to the peer's name, e.g. pi-server. %s. Max length is 192 characters to allow appending to a peer name with up to 63 characters.", defaultSingleAccModeDomain))
 	mgmtCmd.Flags().BoolVar(&idpSignKeyRefreshEnabled, idpSignKeyRefreshEnabledFlagName, false, "Enable cache headers evaluation to determine signing key rotation period. This will refresh the signing key upon expiry.")
 	mgmtCmd.Flags().BoolVar(&userDeleteFromIDPEnabled, "user-delete-from-idp", false, "Allows to delete user from IDP when user is deleted from account")
+	mgmtCmd.Flags().BoolVar(&embeddedSignal, embeddedSignalFlagName, false, "Serves the Signal service on the Management gRPC server, the clients multiplex both services over a single connection. The Signal URI of the config must point to this server for the clients which connect to the Signal service separately")
+	mgmtCmd.Flags().BoolVar(&embeddedDashboard, embeddedDashboardFlagName, false, "Serves a minimal dashboard with the peers, routes, policies and setup keys under /ui/, for the deployments without the dashboard project. The users log in with a personal access token or a JWT")
+	mgmtCmd.Flags().DurationVar(&maintenanceWindow, "maintenance-window", 0, "Announces a maintenance of this duration to the connected clients when the server stops, e.g. for an upgrade. The clients keep their last configuration and spread their reconnections over the window instead of reporting errors. Disabled when 0")
 	rootCmd.MarkFlagRequired("config") //nolint
 
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "")
@@ -78,6 +88,15 @@ func init() {
 	migrationCmd.AddCommand(downCmd)
 
 	rootCmd.AddCommand(migrationCmd)
+
+	for _, bundleCmd := range []*cobra.Command{exportCmd, importCmd} {
+		bundleCmd.Flags().StringVar(&bundleConfig, "config", defaultMgmtConfig, "Netbird config file location, the store engine and the data directory are read from it")
+		bundleCmd.Flags().StringVar(&bundleDataDir, "datadir", "", "server data directory location, overrides the one of the config")
+		bundleCmd.Flags().StringVar(&bundleFormat, "format", "", "format of the bundle, json or yaml. Defaults to the file extension, otherwise json")
+		rootCmd.AddCommand(bundleCmd)
+	}
+	exportCmd.Flags().StringVar(&exportAccountID, "account", "", "ID of the account to export, optional when the store has a single account")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "file to write the bundle to, the standard output by default")
 }
 
 // SetupCloseHandler handles SIGTERM signal and exits with success
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/FlintyLemming/netbird/management/server"
+	"github.com/FlintyLemming/netbird/version"
+)
+
+// RelayStarter starts the relay running next to the Management service with the loaded config, before the Management
+// service hands out the TURN credentials. The returned relay is closed when the Management service stops
+type RelayStarter func(config *server.Config) (io.Closer, error)
+
+// embeddedRelay starts the relay of the single-binary server, nil when the Management service runs alone
+var embeddedRelay RelayStarter
+
+// NewServerCommand returns the command of the single-binary server. It runs the Management service with the Signal
+// service embedded in its gRPC server and the relay started by startRelay, all configured by the Management config
+// file and sharing its TLS and Let's Encrypt handling
+func NewServerCommand(startRelay RelayStarter) *cobra.Command {
+	embeddedRelay = startRelay
+
+	serverCmd := &cobra.Command{
+		Use:          "netbird-server",
+		Short:        "start the NetBird Management, Signal and relay services in a single process",
+		Version:      version.NetbirdVersion(),
+		SilenceUsage: true,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := mgmtCmd.PreRunE(cmd, args); err != nil {
+				return err
+			}
+			return embedSignal(config)
+		},
+		RunE: mgmtCmd.RunE,
+	}
+	serverCmd.Flags().AddFlagSet(mgmtCmd.Flags())
+	serverCmd.PersistentFlags().AddFlagSet(rootCmd.PersistentFlags())
+	return serverCmd
+}
+
+// embedSignal serves the Signal service on the Management gRPC server. The peers are pointed to the Management server
+// for the Signal service unless the config sets another address, e.g. of a reverse proxy
+func embedSignal(config *server.Config) error {
+	config.EmbeddedSignal = true
+	if config.Signal != nil && config.Signal.URI != "" {
+		return nil
+	}
+
+	domain := config.HttpConfig.LetsEncryptDomain
+	if domain == "" {
+		return fmt.Errorf("the Signal URI must be set in the config when Let's Encrypt isn't used")
+	}
+	config.Signal = &server.Host{
+		Proto: server.HTTPS,
+		URI:   fmt.Sprintf("%s:%d", domain, mgmtPort),
+	}
+	log.Infof("pointing the peers to %s for the Signal service", config.Signal.URI)
+	return nil
+}
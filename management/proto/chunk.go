@@ -0,0 +1,137 @@
+package proto
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	pb "google.golang.org/protobuf/proto"
+)
+
+const (
+	// ChunkThreshold is the serialized size of a sync response above which it is compressed and chunked
+	ChunkThreshold = 64 * 1024
+	// ChunkSize is the maximum size of the compressed data of a chunk
+	ChunkSize = 512 * 1024
+	// maxChunkedResponseSize limits the memory used to decompress a chunked sync response
+	maxChunkedResponseSize = 256 * 1024 * 1024
+)
+
+var (
+	chunkID atomic.Uint64
+
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// zstdCodec returns the shared encoder and decoder, they are created on first use as both hold buffers
+func zstdCodec() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxChunkedResponseSize))
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// ChunkSyncResponse compresses the sync response and splits it into chunks of at most chunkSize bytes when its
+// serialized size exceeds ChunkThreshold. Smaller responses are returned as they are
+func ChunkSyncResponse(resp *SyncResponse, chunkSize int) ([]*SyncResponse, error) {
+	if pb.Size(resp) <= ChunkThreshold {
+		return []*SyncResponse{resp}, nil
+	}
+
+	encoder, _, err := zstdCodec()
+	if err != nil {
+		return nil, fmt.Errorf("create zstd encoder: %w", err)
+	}
+
+	serialized, err := pb.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("marshal sync response: %w", err)
+	}
+	compressed := encoder.EncodeAll(serialized, nil)
+
+	id := chunkID.Add(1)
+	total := (len(compressed) + chunkSize - 1) / chunkSize
+	chunks := make([]*SyncResponse, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(compressed) {
+			end = len(compressed)
+		}
+		chunks = append(chunks, &SyncResponse{
+			Chunk: &SyncResponseChunk{
+				Id:    id,
+				Index: uint32(i),
+				Total: uint32(total),
+				Data:  compressed[i*chunkSize : end],
+			},
+		})
+	}
+	return chunks, nil
+}
+
+// SyncResponseAssembler reassembles the sync responses split by ChunkSyncResponse. The chunks of a response are
+// expected in order, without chunks of other responses in between
+type SyncResponseAssembler struct {
+	id     uint64
+	next   uint32
+	buffer []byte
+}
+
+// Add returns the sync response once its last chunk is added, nil before. Responses which are not chunked are
+// returned as they are
+func (a *SyncResponseAssembler) Add(resp *SyncResponse) (*SyncResponse, error) {
+	chunk := resp.GetChunk()
+	if chunk == nil {
+		return resp, nil
+	}
+
+	if chunk.GetIndex() == 0 {
+		a.reset()
+		a.id = chunk.GetId()
+	}
+	if chunk.GetId() != a.id || chunk.GetIndex() != a.next || chunk.GetIndex() >= chunk.GetTotal() {
+		a.reset()
+		return nil, fmt.Errorf("unexpected chunk %d/%d of sync response %d", chunk.GetIndex()+1, chunk.GetTotal(), chunk.GetId())
+	}
+	if len(a.buffer)+len(chunk.GetData()) > maxChunkedResponseSize {
+		a.reset()
+		return nil, fmt.Errorf("chunked sync response %d exceeds %d bytes", chunk.GetId(), maxChunkedResponseSize)
+	}
+
+	a.buffer = append(a.buffer, chunk.GetData()...)
+	a.next++
+	if a.next < chunk.GetTotal() {
+		return nil, nil
+	}
+
+	defer a.reset()
+
+	_, decoder, err := zstdCodec()
+	if err != nil {
+		return nil, fmt.Errorf("create zstd decoder: %w", err)
+	}
+	serialized, err := decoder.DecodeAll(a.buffer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompress sync response %d: %w", chunk.GetId(), err)
+	}
+
+	assembled := &SyncResponse{}
+	if err := pb.Unmarshal(serialized, assembled); err != nil {
+		return nil, fmt.Errorf("unmarshal sync response %d: %w", chunk.GetId(), err)
+	}
+	return assembled, nil
+}
+
+func (a *SyncResponseAssembler) reset() {
+	a.id = 0
+	a.next = 0
+	a.buffer = nil
+}
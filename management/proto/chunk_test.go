@@ -0,0 +1,81 @@
+package proto
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pb "google.golang.org/protobuf/proto"
+)
+
+func largeSyncResponse(peers int) *SyncResponse {
+	resp := &SyncResponse{NetworkMap: &NetworkMap{Serial: 42}}
+	for i := 0; i < peers; i++ {
+		resp.NetworkMap.RemotePeers = append(resp.NetworkMap.RemotePeers, &RemotePeerConfig{
+			WgPubKey:   fmt.Sprintf("peer-key-%d", i),
+			AllowedIps: []string{fmt.Sprintf("100.64.%d.%d/32", i/256, i%256)},
+			Fqdn:       fmt.Sprintf("peer-%d.netbird.cloud", i),
+		})
+	}
+	return resp
+}
+
+func TestChunkSyncResponse(t *testing.T) {
+	resp := largeSyncResponse(20_000)
+	chunks, err := ChunkSyncResponse(resp, 16*1024)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 1, "a large response should be split")
+
+	var compressed int
+	for i, chunk := range chunks {
+		assert.Nil(t, chunk.GetNetworkMap(), "chunks should only carry the compressed data")
+		assert.Equal(t, uint32(i), chunk.GetChunk().GetIndex())
+		assert.Equal(t, uint32(len(chunks)), chunk.GetChunk().GetTotal())
+		assert.LessOrEqual(t, len(chunk.GetChunk().GetData()), 16*1024)
+		compressed += len(chunk.GetChunk().GetData())
+	}
+	assert.Less(t, compressed, pb.Size(resp)/2, "the response should be compressed")
+
+	assembler := &SyncResponseAssembler{}
+	for _, chunk := range chunks[:len(chunks)-1] {
+		assembled, err := assembler.Add(chunk)
+		require.NoError(t, err)
+		require.Nil(t, assembled, "the response shouldn't be returned before the last chunk")
+	}
+	assembled, err := assembler.Add(chunks[len(chunks)-1])
+	require.NoError(t, err)
+	assert.True(t, pb.Equal(resp, assembled), "the reassembled response should match the original")
+
+	small := &SyncResponse{NetworkMap: &NetworkMap{Serial: 1}}
+	chunks, err = ChunkSyncResponse(small, ChunkSize)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Same(t, small, chunks[0], "small responses shouldn't be chunked")
+
+	assembled, err = assembler.Add(small)
+	require.NoError(t, err)
+	assert.Same(t, small, assembled)
+}
+
+func TestSyncResponseAssembler_UnexpectedChunk(t *testing.T) {
+	chunks, err := ChunkSyncResponse(largeSyncResponse(20_000), 16*1024)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 2)
+
+	assembler := &SyncResponseAssembler{}
+	_, err = assembler.Add(chunks[1])
+	require.Error(t, err, "a chunk without its predecessors should be rejected")
+
+	_, err = assembler.Add(chunks[0])
+	require.NoError(t, err)
+	_, err = assembler.Add(chunks[2])
+	require.Error(t, err, "a missing chunk should be detected")
+
+	other, err := ChunkSyncResponse(largeSyncResponse(20_000), 16*1024)
+	require.NoError(t, err)
+	_, err = assembler.Add(chunks[0])
+	require.NoError(t, err)
+	_, err = assembler.Add(other[1])
+	require.Error(t, err, "chunks of another response should be rejected")
+}
@@ -43,6 +43,30 @@ type ManagementServiceClient interface {
 	// EncryptedMessage of the request has a body of PKCEAuthorizationFlowRequest.
 	// EncryptedMessage of the response has a body of PKCEAuthorizationFlow.
 	GetPKCEAuthorizationFlow(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
+	// AdvertiseRoutes requests routes to networks reachable through the peer, subject to the account settings,
+	// and removes the routes of networks the peer withdraws.
+	// EncryptedMessage of the request has a body of AdvertiseRoutesRequest.
+	// EncryptedMessage of the response has a body of AdvertiseRoutesResponse.
+	AdvertiseRoutes(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
+	// ReportLatency stores the latency and packet loss measured by the peer to other peers over the tunnel.
+	// The reports are rejected unless enabled in the account settings.
+	// EncryptedMessage of the request has a body of LatencyReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportLatency(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
+	// GoingOffline marks the peer disconnected right away when it shuts down gracefully, instead of waiting for its
+	// Sync stream to time out.
+	// EncryptedMessage of the request has a body of Empty.
+	// EncryptedMessage of the response has a body of Empty.
+	GoingOffline(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
+	// ReportActiveRoutes stores the routes chosen by the peer among the routes of the same network (HA set).
+	// EncryptedMessage of the request has a body of ActiveRoutesReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportActiveRoutes(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
+	// ReportUsage stores the daily traffic of the peer over the tunnel.
+	// The reports are rejected unless enabled in the account settings.
+	// EncryptedMessage of the request has a body of UsageReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportUsage(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
 }
 
 type managementServiceClient struct {
@@ -130,6 +154,51 @@ func (c *managementServiceClient) GetPKCEAuthorizationFlow(ctx context.Context,
 	return out, nil
 }
 
+func (c *managementServiceClient) AdvertiseRoutes(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error) {
+	out := new(EncryptedMessage)
+	err := c.cc.Invoke(ctx, "/management.ManagementService/AdvertiseRoutes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementServiceClient) ReportLatency(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error) {
+	out := new(EncryptedMessage)
+	err := c.cc.Invoke(ctx, "/management.ManagementService/ReportLatency", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementServiceClient) GoingOffline(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error) {
+	out := new(EncryptedMessage)
+	err := c.cc.Invoke(ctx, "/management.ManagementService/GoingOffline", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementServiceClient) ReportActiveRoutes(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error) {
+	out := new(EncryptedMessage)
+	err := c.cc.Invoke(ctx, "/management.ManagementService/ReportActiveRoutes", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementServiceClient) ReportUsage(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error) {
+	out := new(EncryptedMessage)
+	err := c.cc.Invoke(ctx, "/management.ManagementService/ReportUsage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ManagementServiceServer is the server API for ManagementService service.
 // All implementations must embed UnimplementedManagementServiceServer
 // for forward compatibility
@@ -159,6 +228,30 @@ type ManagementServiceServer interface {
 	// EncryptedMessage of the request has a body of PKCEAuthorizationFlowRequest.
 	// EncryptedMessage of the response has a body of PKCEAuthorizationFlow.
 	GetPKCEAuthorizationFlow(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
+	// AdvertiseRoutes requests routes to networks reachable through the peer, subject to the account settings,
+	// and removes the routes of networks the peer withdraws.
+	// EncryptedMessage of the request has a body of AdvertiseRoutesRequest.
+	// EncryptedMessage of the response has a body of AdvertiseRoutesResponse.
+	AdvertiseRoutes(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
+	// ReportLatency stores the latency and packet loss measured by the peer to other peers over the tunnel.
+	// The reports are rejected unless enabled in the account settings.
+	// EncryptedMessage of the request has a body of LatencyReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportLatency(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
+	// GoingOffline marks the peer disconnected right away when it shuts down gracefully, instead of waiting for its
+	// Sync stream to time out.
+	// EncryptedMessage of the request has a body of Empty.
+	// EncryptedMessage of the response has a body of Empty.
+	GoingOffline(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
+	// ReportActiveRoutes stores the routes chosen by the peer among the routes of the same network (HA set).
+	// EncryptedMessage of the request has a body of ActiveRoutesReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportActiveRoutes(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
+	// ReportUsage stores the daily traffic of the peer over the tunnel.
+	// The reports are rejected unless enabled in the account settings.
+	// EncryptedMessage of the request has a body of UsageReport.
+	// EncryptedMessage of the response has a body of Empty.
+	ReportUsage(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
 	mustEmbedUnimplementedManagementServiceServer()
 }
 
@@ -184,6 +277,21 @@ func (UnimplementedManagementServiceServer) GetDeviceAuthorizationFlow(context.C
 func (UnimplementedManagementServiceServer) GetPKCEAuthorizationFlow(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPKCEAuthorizationFlow not implemented")
 }
+func (UnimplementedManagementServiceServer) AdvertiseRoutes(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AdvertiseRoutes not implemented")
+}
+func (UnimplementedManagementServiceServer) ReportLatency(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportLatency not implemented")
+}
+func (UnimplementedManagementServiceServer) GoingOffline(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GoingOffline not implemented")
+}
+func (UnimplementedManagementServiceServer) ReportActiveRoutes(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportActiveRoutes not implemented")
+}
+func (UnimplementedManagementServiceServer) ReportUsage(context.Context, *EncryptedMessage) (*EncryptedMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportUsage not implemented")
+}
 func (UnimplementedManagementServiceServer) mustEmbedUnimplementedManagementServiceServer() {}
 
 // UnsafeManagementServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -308,6 +416,96 @@ func _ManagementService_GetPKCEAuthorizationFlow_Handler(srv interface{}, ctx co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ManagementService_AdvertiseRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptedMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServiceServer).AdvertiseRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/management.ManagementService/AdvertiseRoutes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServiceServer).AdvertiseRoutes(ctx, req.(*EncryptedMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagementService_ReportLatency_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptedMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServiceServer).ReportLatency(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/management.ManagementService/ReportLatency",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServiceServer).ReportLatency(ctx, req.(*EncryptedMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagementService_GoingOffline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptedMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServiceServer).GoingOffline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/management.ManagementService/GoingOffline",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServiceServer).GoingOffline(ctx, req.(*EncryptedMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagementService_ReportActiveRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptedMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServiceServer).ReportActiveRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/management.ManagementService/ReportActiveRoutes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServiceServer).ReportActiveRoutes(ctx, req.(*EncryptedMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagementService_ReportUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EncryptedMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServiceServer).ReportUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/management.ManagementService/ReportUsage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServiceServer).ReportUsage(ctx, req.(*EncryptedMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ManagementService_ServiceDesc is the grpc.ServiceDesc for ManagementService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -335,6 +533,26 @@ var ManagementService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetPKCEAuthorizationFlow",
 			Handler:    _ManagementService_GetPKCEAuthorizationFlow_Handler,
 		},
+		{
+			MethodName: "AdvertiseRoutes",
+			Handler:    _ManagementService_AdvertiseRoutes_Handler,
+		},
+		{
+			MethodName: "ReportLatency",
+			Handler:    _ManagementService_ReportLatency_Handler,
+		},
+		{
+			MethodName: "GoingOffline",
+			Handler:    _ManagementService_GoingOffline_Handler,
+		},
+		{
+			MethodName: "ReportActiveRoutes",
+			Handler:    _ManagementService_ReportActiveRoutes_Handler,
+		},
+		{
+			MethodName: "ReportUsage",
+			Handler:    _ManagementService_ReportUsage_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
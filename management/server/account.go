@@ -11,6 +11,7 @@ import (
 	"net/netip"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,6 +21,7 @@ import (
 	gocache "github.com/patrickmn/go-cache"
 	"github.com/rs/xid"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
 	"github.com/netbirdio/management-integrations/additions"
 
@@ -41,6 +43,7 @@ const (
 	GroupIssuedAPI             = "api"
 	GroupIssuedJWT             = "jwt"
 	GroupIssuedIntegration     = "integration"
+	GroupIssuedIdP             = "idp"
 	CacheExpirationMax         = 7 * 24 * 3600 * time.Second // 7 days
 	CacheExpirationMin         = 3 * 24 * 3600 * time.Second // 3 days
 	DefaultPeerLoginExpiration = 24 * time.Hour
@@ -56,7 +59,7 @@ func cacheEntryExpiration() time.Duration {
 type AccountManager interface {
 	GetOrCreateAccountByUser(userId, domain string) (*Account, error)
 	CreateSetupKey(accountID string, keyName string, keyType SetupKeyType, expiresIn time.Duration,
-		autoGroups []string, usageLimit int, userID string, ephemeral bool) (*SetupKey, error)
+		autoGroups []string, usageLimit int, userID string, ephemeral bool, externalID string, peerType nbpeer.PeerType) (*SetupKey, error)
 	SaveSetupKey(accountID string, key *SetupKey, userID string) (*SetupKey, error)
 	CreateUser(accountID, initiatorUserID string, key *UserInfo) (*UserInfo, error)
 	DeleteUser(accountID, initiatorUserID string, targetUserID string) error
@@ -96,22 +99,42 @@ type AccountManager interface {
 	SavePolicy(accountID, userID string, policy *Policy) error
 	DeletePolicy(accountID, policyID, userID string) error
 	ListPolicies(accountID, userID string) ([]*Policy, error)
+	GetService(accountID, serviceID, userID string) (*Service, error)
+	SaveService(accountID, userID string, service *Service) error
+	DeleteService(accountID, serviceID, userID string) error
+	ListServices(accountID, userID string) ([]*Service, error)
 	GetRoute(accountID, routeID, userID string) (*route.Route, error)
-	CreateRoute(accountID, prefix, peerID string, peerGroupIDs []string, description, netID string, masquerade bool, metric int, groups []string, enabled bool, userID string) (*route.Route, error)
+	CreateRoute(accountID, prefix, peerID string, peerGroupIDs []string, description, netID string, masquerade bool, metric int, disablePreemption, loadBalance bool, groups []string, enabled bool, userID, externalID string) (*route.Route, error)
 	SaveRoute(accountID, userID string, route *route.Route) error
+	SaveRoutes(accountID, userID string, routes []*route.Route) ([]*route.Route, error)
 	DeleteRoute(accountID, routeID, userID string) error
 	ListRoutes(accountID, userID string) ([]*route.Route, error)
+	AdvertiseRoutes(peerPubKey, netID string, networks, withdrawn []string) ([]*route.Route, error) // used by peer gRPC API
+	ReportPeerLatency(peerPubKey string, measurements []PeerLatencyMeasurement) error               // used by peer gRPC API
+	GetPeerLatencies(accountID, userID string) ([]*PeerLatency, error)
+	ReportPeerUsage(peerPubKey string, days []PeerDailyUsage) error // used by peer gRPC API
+	GetPeerUsage(accountID, userID string, days int) ([]*PeerDailyUsage, error)
+	EnablePeer(accountID, peerID, userID string) (*nbpeer.Peer, error)
+	ReportActiveRoutes(peerPubKey string, reports []ActiveRouteReport) error // used by peer gRPC API
+	GetActiveRoutes(accountID, userID string) ([]*ActiveRoute, error)
+	GetRouteSuggestions(accountID, userID string) ([]*RouteSuggestion, error)
+	GetPeersSignalPresence(accountID string) map[string]bool
 	GetNameServerGroup(accountID, nsGroupID string) (*nbdns.NameServerGroup, error)
-	CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool) (*nbdns.NameServerGroup, error)
+	CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool, forwardViaRoutes bool, priority int) (*nbdns.NameServerGroup, error)
 	SaveNameServerGroup(accountID, userID string, nsGroupToSave *nbdns.NameServerGroup) error
 	DeleteNameServerGroup(accountID, nsGroupID, userID string) error
 	ListNameServerGroups(accountID string) ([]*nbdns.NameServerGroup, error)
 	GetDNSDomain() string
 	StoreEvent(initiatorID, targetID, accountID string, activityID activity.Activity, meta map[string]any)
 	GetEvents(accountID, userID string) ([]*activity.Event, error)
+	SubscribeAccountEvents(accountID, userID string) (*AccountEventSubscription, error)
 	GetDNSSettings(accountID string, userID string) (*DNSSettings, error)
 	SaveDNSSettings(accountID string, userID string, dnsSettingsToSave *DNSSettings) error
 	GetPeer(accountID, peerID, userID string) (*nbpeer.Peer, error)
+	GetPeerNetworkMap(accountID, peerID, userID string) (*NetworkMap, error)
+	StartBulkPeerOperation(accountID, userID string, operation BulkPeerOperation) (*BulkPeerJob, error)
+	GetBulkPeerJob(accountID, userID, jobID string) (*BulkPeerJob, error)
+	SimulatePolicy(accountID, userID string, query PolicySimulationQuery) (*PolicySimulation, error)
 	UpdateAccountSettings(accountID, userID string, newSettings *Settings) (*Account, error)
 	LoginPeer(login PeerLogin) (*nbpeer.Peer, *NetworkMap, error) // used by peer gRPC API
 	SyncPeer(sync PeerSync) (*nbpeer.Peer, *NetworkMap, error)    // used by peer gRPC API
@@ -142,6 +165,22 @@ type DefaultAccountManager struct {
 	// dnsDomain is used for peer resolution. This is appended to the peer's name
 	dnsDomain       string
 	peerLoginExpiry Scheduler
+	// peerInactivityExpiry disables or deletes the peers of the accounts which didn't connect for too long
+	peerInactivityExpiry Scheduler
+	// peerLatencies keeps the latest latency reports of the peers
+	peerLatencies peerLatencyStore
+	// peerUsage keeps the daily traffic reported by the peers
+	peerUsage peerUsageStore
+	// activeRoutes keeps the latest routes the peers reported as active for their HA route sets
+	activeRoutes activeRouteStore
+	// signalPresence looks up the peers connected to the Signal service, nil if the lookups are disabled
+	signalPresence *SignalPresence
+	// bulkPeerJobs keeps the progress of the bulk peer operations
+	bulkPeerJobs bulkPeerJobStore
+	// accountEvents delivers the live events of the accounts to their subscribers
+	accountEvents AccountEventsManager
+	// identityProviders are the additional identity providers by issuer
+	identityProviders map[string]IdentityProviderConfig
 
 	// userDeleteFromIDPEnabled allows to delete user from IDP when user is deleted from account
 	userDeleteFromIDPEnabled bool
@@ -156,6 +195,16 @@ type Settings struct {
 	// Applies to all peers that have Peer.LoginExpirationEnabled set to true.
 	PeerLoginExpiration time.Duration
 
+	// PeerInactivityExpirationEnabled globally enables or disables the expiration of the peers which didn't connect to
+	// the management service for longer than PeerInactivityExpiration
+	PeerInactivityExpirationEnabled bool
+
+	// PeerInactivityExpiration is the period without connection after which a peer is disabled or deleted
+	PeerInactivityExpiration time.Duration
+
+	// PeerInactivityAction is applied to the inactive peers, PeerInactivityActionDisable or PeerInactivityActionDelete
+	PeerInactivityAction string
+
 	// GroupsPropagationEnabled allows to propagate auto groups from the user to the peer
 	GroupsPropagationEnabled bool
 
@@ -169,6 +218,42 @@ type Settings struct {
 	// JWTAllowGroups list of groups to which users are allowed access
 	JWTAllowGroups []string `gorm:"serializer:json"`
 
+	// JWTGroupMappingRules map the JWT claims to the JWT groups of the users instead of taking the values of the
+	// JWTGroupsClaimName claim as they are
+	JWTGroupMappingRules []JWTGroupMappingRule `gorm:"serializer:json"`
+
+	// PeerLoginExpiredAccessGroups list of group IDs whose peers stay reachable by peers with an expired login
+	// until they log in again. Policies still apply, peers outside these groups are disconnected.
+	PeerLoginExpiredAccessGroups []string `gorm:"serializer:json"`
+
+	// RouteAdvertisementEnabled allows peers to request routes to their networks from the client
+	RouteAdvertisementEnabled bool
+
+	// RouteAdvertisementApprovalRequired creates the routes advertised by peers disabled until an admin enables them
+	RouteAdvertisementApprovalRequired bool
+
+	// RouteAdvertisementGroups list of group IDs the routes advertised by peers are distributed to.
+	// The All group is used when empty.
+	RouteAdvertisementGroups []string `gorm:"serializer:json"`
+
+	// PeerLatencyReportsEnabled makes the peers measure the latency to a sample of the other peers and report it
+	PeerLatencyReportsEnabled bool
+
+	// PeerUsageReportsEnabled makes the peers report their daily traffic over the tunnel
+	PeerUsageReportsEnabled bool
+
+	// PeerDefaultDenyEnabled makes the peers drop all the traffic of their NetBird interface which isn't allowed by a
+	// policy, including the traffic exchanged with the routed networks which is accepted otherwise
+	PeerDefaultDenyEnabled bool
+
+	// NetworkRange is the network range of the account chosen by an admin, the peers are re-addressed when it
+	// changes. Invalid when the account keeps the range assigned at its creation
+	NetworkRange netip.Prefix `gorm:"serializer:json"`
+
+	// IPPools are the ranges of the network the peer IPs are allocated from, in order. The whole network is used
+	// when empty. The static IPs assigned to peers may be outside the pools
+	IPPools []netip.Prefix `gorm:"serializer:json"`
+
 	// Extra is a dictionary of Account settings
 	Extra *account.ExtraSettings `gorm:"embedded;embeddedPrefix:extra_"`
 }
@@ -182,6 +267,38 @@ func (s *Settings) Copy() *Settings {
 		JWTGroupsClaimName:         s.JWTGroupsClaimName,
 		GroupsPropagationEnabled:   s.GroupsPropagationEnabled,
 		JWTAllowGroups:             s.JWTAllowGroups,
+
+		PeerInactivityExpirationEnabled: s.PeerInactivityExpirationEnabled,
+		PeerInactivityExpiration:        s.PeerInactivityExpiration,
+		PeerInactivityAction:            s.PeerInactivityAction,
+
+		RouteAdvertisementEnabled:          s.RouteAdvertisementEnabled,
+		RouteAdvertisementApprovalRequired: s.RouteAdvertisementApprovalRequired,
+
+		PeerLatencyReportsEnabled: s.PeerLatencyReportsEnabled,
+		PeerUsageReportsEnabled:   s.PeerUsageReportsEnabled,
+		PeerDefaultDenyEnabled:    s.PeerDefaultDenyEnabled,
+
+		NetworkRange: s.NetworkRange,
+	}
+	if s.IPPools != nil {
+		settings.IPPools = make([]netip.Prefix, len(s.IPPools))
+		copy(settings.IPPools, s.IPPools)
+	}
+	if s.PeerLoginExpiredAccessGroups != nil {
+		settings.PeerLoginExpiredAccessGroups = make([]string, len(s.PeerLoginExpiredAccessGroups))
+		copy(settings.PeerLoginExpiredAccessGroups, s.PeerLoginExpiredAccessGroups)
+	}
+	if s.RouteAdvertisementGroups != nil {
+		settings.RouteAdvertisementGroups = make([]string, len(s.RouteAdvertisementGroups))
+		copy(settings.RouteAdvertisementGroups, s.RouteAdvertisementGroups)
+	}
+	if s.JWTGroupMappingRules != nil {
+		settings.JWTGroupMappingRules = make([]JWTGroupMappingRule, 0, len(s.JWTGroupMappingRules))
+		for _, rule := range s.JWTGroupMappingRules {
+			rule.Groups = slices.Clone(rule.Groups)
+			settings.JWTGroupMappingRules = append(settings.JWTGroupMappingRules, rule)
+		}
 	}
 	if s.Extra != nil {
 		settings.Extra = s.Extra.Copy()
@@ -215,6 +332,8 @@ type Account struct {
 	RoutesG                []route.Route                     `json:"-" gorm:"foreignKey:AccountID;references:id"`
 	NameServerGroups       map[string]*nbdns.NameServerGroup `gorm:"-"`
 	NameServerGroupsG      []nbdns.NameServerGroup           `json:"-" gorm:"foreignKey:AccountID;references:id"`
+	Services               map[string]*Service               `gorm:"-"`
+	ServicesG              []Service                         `json:"-" gorm:"foreignKey:AccountID;references:id"`
 	DNSSettings            DNSSettings                       `gorm:"embedded;embeddedPrefix:dns_settings_"`
 	// Settings is a dictionary of Account settings
 	Settings *Settings `gorm:"embedded;embeddedPrefix:settings_"`
@@ -232,6 +351,7 @@ type UserInfo struct {
 	NonDeletable         bool                 `json:"non_deletable"`
 	LastLogin            time.Time            `json:"last_login"`
 	Issued               string               `json:"issued"`
+	IdentityProvider     string               `json:"identity_provider"`
 	IntegrationReference IntegrationReference `json:"-"`
 }
 
@@ -369,18 +489,31 @@ func (a *Account) GetPeerNetworkMap(peerID, dnsDomain string) *NetworkMap {
 		}
 	}
 	validatedPeers := additions.ValidatePeers([]*nbpeer.Peer{peer})
-	if len(validatedPeers) == 0 {
+	if len(validatedPeers) == 0 || peer.Status.Disabled {
 		return &NetworkMap{
 			Network: a.Network.Copy(),
 		}
 	}
 	aclPeers, firewallRules := a.getPeerConnectionResources(peerID)
-	// exclude expired peers
-	var peersToConnect []*nbpeer.Peer
+	aclPeers, firewallRules = withoutDisabledPeers(aclPeers, firewallRules)
 	var expiredPeers []*nbpeer.Peer
+	loginExpired := a.Settings.PeerLoginExpirationEnabled && peerLoginExpired(peer, a)
+	if loginExpired {
+		aclPeers, expiredPeers, firewallRules = a.getExpiredPeerConnectionResources(aclPeers, firewallRules)
+	}
+	if peer.Meta.FirewallCapabilities.PortRanges {
+		firewallRules = mergePortRanges(firewallRules)
+	}
+	if !peer.Meta.FirewallCapabilities.SCTPAndGRE {
+		firewallRules = withoutSCTPAndGRE(firewallRules)
+	}
+	// exclude expired peers unless this peer stays reachable for them
+	reachableWhenExpired := a.isReachableByExpiredPeers(peerID)
+	loginExpirations := a.getPeersLoginExpiration()
+	var peersToConnect []*nbpeer.Peer
 	for _, p := range aclPeers {
-		expired, _ := p.LoginExpired(a.Settings.PeerLoginExpiration)
-		if a.Settings.PeerLoginExpirationEnabled && expired {
+		expired, _ := p.LoginExpired(loginExpirations.get(p.ID))
+		if a.Settings.PeerLoginExpirationEnabled && expired && !reachableWhenExpired {
 			expiredPeers = append(expiredPeers, p)
 			continue
 		}
@@ -402,23 +535,83 @@ func (a *Account) GetPeerNetworkMap(peerID, dnsDomain string) *NetworkMap {
 		}
 		dnsUpdate.CustomZones = zones
 		dnsUpdate.NameServerGroups = getPeerNSGroups(a, peerID)
+		dnsUpdate.SearchDomains = getPeerSearchDomains(a, peerID)
 	}
 
 	return &NetworkMap{
-		Peers:         peersToConnect,
-		Network:       a.Network.Copy(),
-		Routes:        routesUpdate,
-		DNSConfig:     dnsUpdate,
-		OfflinePeers:  expiredPeers,
-		FirewallRules: firewallRules,
+		Peers:           peersToConnect,
+		Network:         a.Network.Copy(),
+		Routes:          routesUpdate,
+		DNSConfig:       dnsUpdate,
+		OfflinePeers:    expiredPeers,
+		FirewallRules:   firewallRules,
+		BandwidthLimits: a.getBandwidthLimits(peerID, peersToConnect),
+		PeerGroups:      a.getPeersGroupNames(peersToConnect, expiredPeers),
+		LoginExpired:    loginExpired,
+
+		LatencyReportsEnabled: a.Settings.PeerLatencyReportsEnabled,
+		UsageReportsEnabled:   a.Settings.PeerUsageReportsEnabled,
+		DefaultDeny:           a.Settings.PeerDefaultDenyEnabled,
+		AppRouting:            a.getPeerAppRouting(peerID),
+	}
+}
+
+// getExpiredPeerConnectionResources limits the connection resources of a peer with an expired login to the peers
+// of the Settings.PeerLoginExpiredAccessGroups. It returns the reachable peers, the peers to show as offline and
+// the firewall rules of the reachable peers.
+func (a *Account) getExpiredPeerConnectionResources(aclPeers []*nbpeer.Peer, firewallRules []*FirewallRule) ([]*nbpeer.Peer, []*nbpeer.Peer, []*FirewallRule) {
+	var peers, offlinePeers []*nbpeer.Peer
+	allowedIPs := make(map[string]struct{})
+	for _, p := range aclPeers {
+		if !a.isReachableByExpiredPeers(p.ID) {
+			offlinePeers = append(offlinePeers, p)
+			continue
+		}
+		peers = append(peers, p)
+		allowedIPs[p.IP.String()] = struct{}{}
+	}
+
+	var rules []*FirewallRule
+	for _, rule := range firewallRules {
+		if rule.PeerIP != "0.0.0.0" {
+			if _, ok := allowedIPs[rule.PeerIP]; ok {
+				rules = append(rules, rule)
+			}
+			continue
+		}
+		// rules for all peers are narrowed down to the reachable ones
+		for _, p := range peers {
+			allowedRule := *rule
+			allowedRule.PeerIP = p.IP.String()
+			rules = append(rules, &allowedRule)
+		}
+	}
+
+	return peers, offlinePeers, rules
+}
+
+// isReachableByExpiredPeers returns true if the peer belongs to one of the Settings.PeerLoginExpiredAccessGroups
+func (a *Account) isReachableByExpiredPeers(peerID string) bool {
+	for _, groupID := range a.Settings.PeerLoginExpiredAccessGroups {
+		group := a.GetGroup(groupID)
+		if group == nil {
+			continue
+		}
+		for _, id := range group.Peers {
+			if id == peerID {
+				return true
+			}
+		}
 	}
+	return false
 }
 
 // GetExpiredPeers returns peers that have been expired
 func (a *Account) GetExpiredPeers() []*nbpeer.Peer {
 	var peers []*nbpeer.Peer
+	loginExpirations := a.getPeersLoginExpiration()
 	for _, peer := range a.GetPeersWithExpiration() {
-		expired, _ := peer.LoginExpired(a.Settings.PeerLoginExpiration)
+		expired, _ := peer.LoginExpired(loginExpirations.get(peer.ID))
 		if expired {
 			peers = append(peers, peer)
 		}
@@ -436,12 +629,13 @@ func (a *Account) GetNextPeerExpiration() (time.Duration, bool) {
 		return 0, false
 	}
 	var nextExpiry *time.Duration
+	loginExpirations := a.getPeersLoginExpiration()
 	for _, peer := range peersWithExpiry {
 		// consider only connected peers because others will require login on connecting to the management server
 		if peer.Status.LoginExpired || !peer.Status.Connected {
 			continue
 		}
-		_, duration := peer.LoginExpired(a.Settings.PeerLoginExpiration)
+		_, duration := peer.LoginExpired(loginExpirations.get(peer.ID))
 		if nextExpiry == nil || duration < *nextExpiry {
 			nextExpiry = &duration
 		}
@@ -586,6 +780,30 @@ func (a *Account) getPeerGroups(peerID string) lookupMap {
 	return groupList
 }
 
+// getPeersGroupNames returns the sorted names of the groups of each of the given peers, keyed by peer ID.
+// Peers without groups are omitted
+func (a *Account) getPeersGroupNames(peerLists ...[]*nbpeer.Peer) map[string][]string {
+	wanted := make(lookupMap)
+	for _, peers := range peerLists {
+		for _, p := range peers {
+			wanted[p.ID] = struct{}{}
+		}
+	}
+
+	names := make(map[string][]string)
+	for _, group := range a.Groups {
+		for _, id := range group.Peers {
+			if _, ok := wanted[id]; ok {
+				names[id] = append(names[id], group.Name)
+			}
+		}
+	}
+	for _, groupNames := range names {
+		slices.Sort(groupNames)
+	}
+	return names
+}
+
 func (a *Account) getSetupKeyGroups(setupKey string) ([]string, error) {
 	key, err := a.FindSetupKey(setupKey)
 	if err != nil {
@@ -603,12 +821,68 @@ func (a *Account) getTakenIPs() []net.IP {
 	return takenIps
 }
 
+// validateStaticPeerIP checks that the IP can be assigned to the peer: it has to be an assignable IP of the network
+// which no other peer uses. The IP may be outside the IP pools
+func (a *Account) validateStaticPeerIP(peerID string, ip net.IP) error {
+	ip = ip.To4()
+	if ip == nil || !isAssignablePeerIP(a.Network.Net, ip) {
+		return status.Errorf(status.InvalidArgument, "IP %s can't be assigned to a peer of the network %s", ip, a.Network.Net.String())
+	}
+	for _, peer := range a.Peers {
+		if peer.ID != peerID && peer.IP.Equal(ip) {
+			return status.Errorf(status.AlreadyExists, "IP %s is already assigned to peer %s", ip, peer.Name)
+		}
+	}
+	return nil
+}
+
+// readdressPeers moves the account to the network range, the peers whose IP isn't assignable in the range get a new
+// IP from the pools. The other peers, including the ones with a static IP in the range, keep their IP
+func (a *Account) readdressPeers(networkRange netip.Prefix, pools []netip.Prefix) error {
+	ipNet := net.IPNet{IP: networkRange.Addr().AsSlice(), Mask: net.CIDRMask(networkRange.Bits(), 32)}
+
+	var taken []net.IP
+	var moved []*nbpeer.Peer
+	for _, peer := range a.Peers {
+		if isAssignablePeerIP(ipNet, peer.IP) {
+			taken = append(taken, peer.IP)
+			continue
+		}
+		moved = append(moved, peer)
+	}
+	// the peers are re-addressed in a stable order
+	sort.Slice(moved, func(i, j int) bool {
+		return moved[i].ID < moved[j].ID
+	})
+
+	newIPs := make([]net.IP, len(moved))
+	for i := range moved {
+		ip, err := AllocatePeerIPFromPools(ipNet, pools, taken)
+		if err != nil {
+			return err
+		}
+		newIPs[i] = ip
+		taken = append(taken, ip)
+	}
+
+	for i, peer := range moved {
+		peer.IP = newIPs[i]
+	}
+	a.Network.Net = ipNet
+	a.Network.IncSerial()
+	return nil
+}
+
 func (a *Account) getPeerDNSLabels() lookupMap {
 	existingLabels := make(lookupMap)
 	for _, peer := range a.Peers {
 		if peer.DNSLabel != "" {
 			existingLabels[peer.DNSLabel] = struct{}{}
 		}
+		// the new labels shouldn't shadow the aliases
+		for _, alias := range peer.DNSAliases {
+			existingLabels[alias] = struct{}{}
+		}
 	}
 	return existingLabels
 }
@@ -654,6 +928,11 @@ func (a *Account) Copy() *Account {
 		nsGroups[id] = nsGroup.Copy()
 	}
 
+	services := map[string]*Service{}
+	for id, service := range a.Services {
+		services[id] = service.Copy()
+	}
+
 	dnsSettings := a.DNSSettings.Copy()
 
 	var settings *Settings
@@ -676,6 +955,7 @@ func (a *Account) Copy() *Account {
 		Policies:               policies,
 		Routes:                 routes,
 		NameServerGroups:       nsGroups,
+		Services:               services,
 		DNSSettings:            dnsSettings,
 		Settings:               settings,
 	}
@@ -697,6 +977,17 @@ func (a *Account) GetPeer(peerID string) *nbpeer.Peer {
 
 // SetJWTGroups to account and to user autoassigned groups
 func (a *Account) SetJWTGroups(userID string, groupsNames []string) bool {
+	return a.setIssuedGroups(userID, groupsNames, GroupIssuedJWT)
+}
+
+// SetIdPGroups sets the auto groups of the identity provider the user logged in with
+func (a *Account) SetIdPGroups(userID string, groupsNames []string) bool {
+	return a.setIssuedGroups(userID, groupsNames, GroupIssuedIdP)
+}
+
+// setIssuedGroups syncs the user autoassigned groups issued by the issuer with the groups names, the missing groups
+// are created
+func (a *Account) setIssuedGroups(userID string, groupsNames []string, issued string) bool {
 	user, ok := a.Users[userID]
 	if !ok {
 		return false
@@ -707,18 +998,18 @@ func (a *Account) SetJWTGroups(userID string, groupsNames []string) bool {
 		existedGroupsByName[group.Name] = group
 	}
 
-	// remove JWT groups from the autogroups, to sync them again
+	// remove the issued groups from the autogroups, to sync them again
 	removed := 0
-	jwtAutoGroups := make(map[string]struct{})
+	issuedAutoGroups := make(map[string]struct{})
 	for i, id := range user.AutoGroups {
-		if group, ok := a.Groups[id]; ok && group.Issued == GroupIssuedJWT {
-			jwtAutoGroups[group.Name] = struct{}{}
+		if group, ok := a.Groups[id]; ok && group.Issued == issued {
+			issuedAutoGroups[group.Name] = struct{}{}
 			user.AutoGroups = append(user.AutoGroups[:i-removed], user.AutoGroups[i-removed+1:]...)
 			removed++
 		}
 	}
 
-	// create JWT groups if they doesn't exist
+	// create the issued groups if they doesn't exist
 	// and all of them to the autogroups
 	var modified bool
 	for _, name := range groupsNames {
@@ -727,22 +1018,22 @@ func (a *Account) SetJWTGroups(userID string, groupsNames []string) bool {
 			group = &Group{
 				ID:     xid.New().String(),
 				Name:   name,
-				Issued: GroupIssuedJWT,
+				Issued: issued,
 			}
 			a.Groups[group.ID] = group
 		}
-		// only JWT groups will be synced
-		if group.Issued == GroupIssuedJWT {
+		// only the groups of the issuer will be synced
+		if group.Issued == issued {
 			user.AutoGroups = append(user.AutoGroups, group.ID)
-			if _, ok := jwtAutoGroups[name]; !ok {
+			if _, ok := issuedAutoGroups[name]; !ok {
 				modified = true
 			}
-			delete(jwtAutoGroups, name)
+			delete(issuedAutoGroups, name)
 		}
 	}
 
 	// if not empty it means we removed some groups
-	if len(jwtAutoGroups) > 0 {
+	if len(issuedAutoGroups) > 0 {
 		modified = true
 	}
 
@@ -815,6 +1106,7 @@ func BuildManager(store Store, peersUpdateManager *PeersUpdateManager, idpManage
 		dnsDomain:                dnsDomain,
 		eventStore:               eventStore,
 		peerLoginExpiry:          NewDefaultScheduler(),
+		peerInactivityExpiry:     NewDefaultScheduler(),
 		userDeleteFromIDPEnabled: userDeleteFromIDPEnabled,
 	}
 	allAccounts := store.GetAllAccounts()
@@ -850,6 +1142,10 @@ func BuildManager(store Store, peersUpdateManager *PeersUpdateManager, idpManage
 				return nil, err
 			}
 		}
+
+		if account.Settings.PeerInactivityExpirationEnabled {
+			am.checkAndSchedulePeerInactivityExpiration(account)
+		}
 	}
 
 	goCacheClient := gocache.New(CacheExpirationMax, 30*time.Minute)
@@ -884,15 +1180,22 @@ func (am *DefaultAccountManager) GetExternalCacheManager() ExternalCacheManager
 // User that performs the update has to belong to the account.
 // Returns an updated Account
 func (am *DefaultAccountManager) UpdateAccountSettings(accountID, userID string, newSettings *Settings) (*Account, error) {
-	halfYearLimit := 180 * 24 * time.Hour
-	if newSettings.PeerLoginExpiration > halfYearLimit {
+	if newSettings.PeerLoginExpiration > maxPeerLoginExpiration {
 		return nil, status.Errorf(status.InvalidArgument, "peer login expiration can't be larger than 180 days")
 	}
 
-	if newSettings.PeerLoginExpiration < time.Hour {
+	if newSettings.PeerLoginExpiration < minPeerLoginExpiration {
 		return nil, status.Errorf(status.InvalidArgument, "peer login expiration can't be smaller than one hour")
 	}
 
+	if err := validatePeerInactivitySettings(newSettings); err != nil {
+		return nil, err
+	}
+
+	if err := validateJWTGroupMappingRules(newSettings.JWTGroupMappingRules); err != nil {
+		return nil, err
+	}
+
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
 
@@ -927,11 +1230,110 @@ func (am *DefaultAccountManager) UpdateAccountSettings(accountID, userID string,
 		am.StoreEvent(userID, accountID, accountID, event, nil)
 	}
 
+	inactivityExpirationUpdated := oldSettings.PeerInactivityExpirationEnabled != newSettings.PeerInactivityExpirationEnabled
+	if inactivityExpirationUpdated {
+		event := activity.AccountPeerInactivityExpirationEnabled
+		if !newSettings.PeerInactivityExpirationEnabled {
+			event = activity.AccountPeerInactivityExpirationDisabled
+		}
+		am.StoreEvent(userID, accountID, accountID, event, nil)
+	} else if newSettings.PeerInactivityExpirationEnabled &&
+		(oldSettings.PeerInactivityExpiration != newSettings.PeerInactivityExpiration ||
+			oldSettings.PeerInactivityAction != newSettings.PeerInactivityAction) {
+		inactivityExpirationUpdated = true
+		am.StoreEvent(userID, accountID, accountID, activity.AccountPeerInactivityExpirationUpdated,
+			map[string]any{"expiration": newSettings.PeerInactivityExpiration.String(), "action": newSettings.PeerInactivityAction})
+	}
+
 	if oldSettings.PeerLoginExpiration != newSettings.PeerLoginExpiration {
 		am.StoreEvent(userID, accountID, accountID, activity.AccountPeerLoginExpirationDurationUpdated, nil)
 		am.checkAndSchedulePeerLoginExpiration(account)
 	}
 
+	expiredAccessGroupsUpdated := !slices.Equal(oldSettings.PeerLoginExpiredAccessGroups, newSettings.PeerLoginExpiredAccessGroups)
+	if expiredAccessGroupsUpdated {
+		if len(newSettings.PeerLoginExpiredAccessGroups) > 0 {
+			err = validateGroups(newSettings.PeerLoginExpiredAccessGroups, account.Groups)
+			if err != nil {
+				return nil, err
+			}
+		}
+		am.StoreEvent(userID, accountID, accountID, activity.AccountExpiredPeerAccessGroupsUpdated, nil)
+	}
+
+	if oldSettings.RouteAdvertisementEnabled != newSettings.RouteAdvertisementEnabled ||
+		oldSettings.RouteAdvertisementApprovalRequired != newSettings.RouteAdvertisementApprovalRequired ||
+		!slices.Equal(oldSettings.RouteAdvertisementGroups, newSettings.RouteAdvertisementGroups) {
+		if len(newSettings.RouteAdvertisementGroups) > 0 {
+			err = validateGroups(newSettings.RouteAdvertisementGroups, account.Groups)
+			if err != nil {
+				return nil, err
+			}
+		}
+		am.StoreEvent(userID, accountID, accountID, activity.AccountRouteAdvertisementUpdated, nil)
+	}
+
+	latencyReportsUpdated := oldSettings.PeerLatencyReportsEnabled != newSettings.PeerLatencyReportsEnabled
+	if latencyReportsUpdated {
+		event := activity.AccountPeerLatencyReportsEnabled
+		if !newSettings.PeerLatencyReportsEnabled {
+			event = activity.AccountPeerLatencyReportsDisabled
+			am.peerLatencies.deleteAccount(accountID)
+		}
+		am.StoreEvent(userID, accountID, accountID, event, nil)
+	}
+
+	usageReportsUpdated := oldSettings.PeerUsageReportsEnabled != newSettings.PeerUsageReportsEnabled
+	if usageReportsUpdated {
+		event := activity.AccountPeerUsageReportsEnabled
+		if !newSettings.PeerUsageReportsEnabled {
+			event = activity.AccountPeerUsageReportsDisabled
+			am.peerUsage.deleteAccount(accountID)
+		}
+		am.StoreEvent(userID, accountID, accountID, event, nil)
+	}
+
+	if !reflect.DeepEqual(oldSettings.JWTGroupMappingRules, newSettings.JWTGroupMappingRules) {
+		am.StoreEvent(userID, accountID, accountID, activity.AccountJWTGroupMappingUpdated,
+			map[string]any{"rules": len(newSettings.JWTGroupMappingRules)})
+	}
+
+	defaultDenyUpdated := oldSettings.PeerDefaultDenyEnabled != newSettings.PeerDefaultDenyEnabled
+	if defaultDenyUpdated {
+		event := activity.AccountPeerDefaultDenyEnabled
+		if !newSettings.PeerDefaultDenyEnabled {
+			event = activity.AccountPeerDefaultDenyDisabled
+		}
+		am.StoreEvent(userID, accountID, accountID, event, nil)
+	}
+
+	// the network range is kept when it isn't set
+	if !newSettings.NetworkRange.IsValid() {
+		newSettings.NetworkRange = oldSettings.NetworkRange
+	}
+	networkRange := account.Network.Prefix()
+	networkRangeUpdated := newSettings.NetworkRange.IsValid() && newSettings.NetworkRange != networkRange
+	if networkRangeUpdated {
+		networkRange = newSettings.NetworkRange
+	}
+	ipPoolsUpdated := !slices.Equal(oldSettings.IPPools, newSettings.IPPools)
+	if networkRangeUpdated || ipPoolsUpdated {
+		err = validateNetworkRange(networkRange, newSettings.IPPools)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if networkRangeUpdated {
+		err = account.readdressPeers(networkRange, newSettings.IPPools)
+		if err != nil {
+			return nil, err
+		}
+		am.StoreEvent(userID, accountID, accountID, activity.AccountNetworkRangeUpdated, map[string]any{"range": networkRange.String()})
+	}
+	if ipPoolsUpdated {
+		am.StoreEvent(userID, accountID, accountID, activity.AccountIPPoolsUpdated, nil)
+	}
+
 	updatedAccount := account.UpdateSettings(newSettings)
 
 	err = am.Store.SaveAccount(account)
@@ -939,6 +1341,17 @@ func (am *DefaultAccountManager) UpdateAccountSettings(accountID, userID string,
 		return nil, err
 	}
 
+	if inactivityExpirationUpdated {
+		am.peerInactivityExpiry.Cancel([]string{accountID})
+		if newSettings.PeerInactivityExpirationEnabled {
+			am.checkAndSchedulePeerInactivityExpiration(account)
+		}
+	}
+
+	if expiredAccessGroupsUpdated || latencyReportsUpdated || usageReportsUpdated || defaultDenyUpdated || networkRangeUpdated {
+		am.updateAccountPeers(account)
+	}
+
 	return updatedAccount, nil
 }
 
@@ -1088,6 +1501,14 @@ func (am *DefaultAccountManager) DeleteAccount(accountID, userID string) error {
 	}
 	// cancel peer login expiry job
 	am.peerLoginExpiry.Cancel([]string{account.Id})
+	am.peerInactivityExpiry.Cancel([]string{account.Id})
+	am.peerLatencies.deleteAccount(account.Id)
+	am.peerUsage.deleteAccount(account.Id)
+	am.activeRoutes.deleteAccount(account.Id)
+	am.bulkPeerJobs.deleteAccount(account.Id)
+	if am.signalPresence != nil {
+		am.signalPresence.deleteAccount(account.Id)
+	}
 
 	log.Debugf("account %s deleted", accountID)
 	return nil
@@ -1561,76 +1982,98 @@ func (am *DefaultAccountManager) GetAccountFromToken(claims jwtclaims.Authorizat
 		}
 	}
 
+	oldGroups := make([]string, len(user.AutoGroups))
+	copy(oldGroups, user.AutoGroups)
+
+	// if groups were added or modified, save the account
+	modified := am.syncIdentityProvider(account, user, claims.Issuer)
+
 	if account.Settings.JWTGroupsEnabled {
-		if account.Settings.JWTGroupsClaimName == "" {
+		if account.Settings.JWTGroupsClaimName == "" && len(account.Settings.JWTGroupMappingRules) == 0 {
 			log.Errorf("JWT groups are enabled but no claim name is set")
-			return account, user, nil
-		}
-		if claim, ok := claims.Raw[account.Settings.JWTGroupsClaimName]; ok {
-			if slice, ok := claim.([]interface{}); ok {
-				var groupsNames []string
-				for _, item := range slice {
-					if g, ok := item.(string); ok {
-						groupsNames = append(groupsNames, g)
-					} else {
-						log.Errorf("JWT claim %q is not a string: %v", account.Settings.JWTGroupsClaimName, item)
-					}
-				}
-
-				oldGroups := make([]string, len(user.AutoGroups))
-				copy(oldGroups, user.AutoGroups)
-				// if groups were added or modified, save the account
-				if account.SetJWTGroups(claims.UserId, groupsNames) {
-					if account.Settings.GroupsPropagationEnabled {
-						if user, err := account.FindUser(claims.UserId); err == nil {
-							addNewGroups := difference(user.AutoGroups, oldGroups)
-							removeOldGroups := difference(oldGroups, user.AutoGroups)
-							account.UserGroupsAddToPeers(claims.UserId, addNewGroups...)
-							account.UserGroupsRemoveFromPeers(claims.UserId, removeOldGroups...)
-							account.Network.IncSerial()
-							if err := am.Store.SaveAccount(account); err != nil {
-								log.Errorf("failed to save account: %v", err)
-							} else {
-								am.updateAccountPeers(account)
-								for _, g := range addNewGroups {
-									if group := account.GetGroup(g); group != nil {
-										am.StoreEvent(user.Id, user.Id, account.Id, activity.GroupAddedToUser,
-											map[string]any{
-												"group":           group.Name,
-												"group_id":        group.ID,
-												"is_service_user": user.IsServiceUser,
-												"user_name":       user.ServiceUserName})
-									}
-								}
-								for _, g := range removeOldGroups {
-									if group := account.GetGroup(g); group != nil {
-										am.StoreEvent(user.Id, user.Id, account.Id, activity.GroupRemovedFromUser,
-											map[string]any{
-												"group":           group.Name,
-												"group_id":        group.ID,
-												"is_service_user": user.IsServiceUser,
-												"user_name":       user.ServiceUserName})
-									}
-								}
-							}
-						}
-					} else {
-						if err := am.Store.SaveAccount(account); err != nil {
-							log.Errorf("failed to save account: %v", err)
-						}
-					}
-				}
-			} else {
-				log.Debugf("JWT claim %q is not a string array", account.Settings.JWTGroupsClaimName)
+		} else if groupsNames, ok := jwtGroupNames(account.Settings, claims); ok {
+			if account.SetJWTGroups(claims.UserId, groupsNames) {
+				modified = true
 			}
-		} else {
-			log.Debugf("JWT claim %q not found", account.Settings.JWTGroupsClaimName)
 		}
 	}
 
+	if modified {
+		am.saveUserGroupsSync(account, user, oldGroups)
+	}
+
 	return account, user, nil
 }
 
+// syncIdentityProvider records the identity provider the user logged in with and syncs the auto groups of the
+// provider, it returns true when the user was modified
+func (am *DefaultAccountManager) syncIdentityProvider(account *Account, user *User, issuer string) bool {
+	if len(am.identityProviders) == 0 {
+		return false
+	}
+
+	// the users of the default provider have no provider groups
+	provider := am.identityProviders[issuer]
+	modified := account.SetIdPGroups(user.Id, provider.AutoGroups)
+	if user.IdentityProvider != provider.Name {
+		user.IdentityProvider = provider.Name
+		modified = true
+	}
+	return modified
+}
+
+// saveUserGroupsSync saves the account after the auto groups of the user were synced from the login claims, the
+// groups are propagated to the peers of the user when the account enables it
+func (am *DefaultAccountManager) saveUserGroupsSync(account *Account, user *User, oldGroups []string) {
+	if !account.Settings.GroupsPropagationEnabled {
+		if err := am.Store.SaveAccount(account); err != nil {
+			log.Errorf("failed to save account: %v", err)
+		}
+		return
+	}
+
+	addNewGroups := difference(user.AutoGroups, oldGroups)
+	removeOldGroups := difference(oldGroups, user.AutoGroups)
+	account.UserGroupsAddToPeers(user.Id, addNewGroups...)
+	account.UserGroupsRemoveFromPeers(user.Id, removeOldGroups...)
+	account.Network.IncSerial()
+	if err := am.Store.SaveAccount(account); err != nil {
+		log.Errorf("failed to save account: %v", err)
+		return
+	}
+
+	am.updateAccountPeers(account)
+	for _, g := range addNewGroups {
+		if group := account.GetGroup(g); group != nil {
+			am.StoreEvent(user.Id, user.Id, account.Id, activity.GroupAddedToUser,
+				map[string]any{
+					"group":           group.Name,
+					"group_id":        group.ID,
+					"is_service_user": user.IsServiceUser,
+					"user_name":       user.ServiceUserName})
+		}
+	}
+	for _, g := range removeOldGroups {
+		if group := account.GetGroup(g); group != nil {
+			am.StoreEvent(user.Id, user.Id, account.Id, activity.GroupRemovedFromUser,
+				map[string]any{
+					"group":           group.Name,
+					"group_id":        group.ID,
+					"is_service_user": user.IsServiceUser,
+					"user_name":       user.ServiceUserName})
+		}
+	}
+}
+
+// SetIdentityProviders accepts the users of the additional identity providers and tags them with the auto groups of
+// their provider
+func (am *DefaultAccountManager) SetIdentityProviders(providers []IdentityProviderConfig) {
+	am.identityProviders = make(map[string]IdentityProviderConfig, len(providers))
+	for _, provider := range providers {
+		am.identityProviders[provider.Issuer] = provider
+	}
+}
+
 // getAccountWithAuthorizationClaims retrievs an account using JWT Claims.
 // if domain is of the PrivateCategory category, it will evaluate
 // if account is new, existing or if there is another account with the same domain
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+// AccountBundleVersion is the version of the account bundle format, it is increased on incompatible changes
+const AccountBundleVersion = 1
+
+const (
+	// AccountBundleJSON encodes the account bundles as JSON
+	AccountBundleJSON = "json"
+	// AccountBundleYAML encodes the account bundles as YAML
+	AccountBundleYAML = "yaml"
+)
+
+// AccountBundle is the configuration of an account exported for a backup, a migration between store engines or a
+// review of the configuration. The setup keys and the personal access tokens are secrets and are not exported
+type AccountBundle struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Account    *AccountConfig `json:"account"`
+}
+
+// AccountConfig is the exported configuration of an account
+type AccountConfig struct {
+	ID                     string                            `json:"id"`
+	CreatedBy              string                            `json:"createdBy"`
+	Domain                 string                            `json:"domain"`
+	DomainCategory         string                            `json:"domainCategory"`
+	IsDomainPrimaryAccount bool                              `json:"isDomainPrimaryAccount"`
+	Network                *Network                          `json:"network"`
+	Settings               *Settings                         `json:"settings"`
+	DNSSettings            DNSSettings                       `json:"dnsSettings"`
+	Users                  map[string]*User                  `json:"users"`
+	Peers                  map[string]*nbpeer.Peer           `json:"peers"`
+	Groups                 map[string]*Group                 `json:"groups"`
+	Policies               []*Policy                         `json:"policies"`
+	Routes                 map[string]*route.Route           `json:"routes"`
+	NameServerGroups       map[string]*nbdns.NameServerGroup `json:"nameServerGroups"`
+	Services               map[string]*Service               `json:"services"`
+}
+
+// ExportAccount returns the bundle of the configuration of the account
+func ExportAccount(account *Account) *AccountBundle {
+	account = account.Copy()
+
+	for _, user := range account.Users {
+		user.PATs = nil
+	}
+
+	return &AccountBundle{
+		Version:    AccountBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Account: &AccountConfig{
+			ID:                     account.Id,
+			CreatedBy:              account.CreatedBy,
+			Domain:                 account.Domain,
+			DomainCategory:         account.DomainCategory,
+			IsDomainPrimaryAccount: account.IsDomainPrimaryAccount,
+			Network:                account.Network,
+			Settings:               account.Settings,
+			DNSSettings:            account.DNSSettings,
+			Users:                  account.Users,
+			Peers:                  account.Peers,
+			Groups:                 account.Groups,
+			Policies:               account.Policies,
+			Routes:                 account.Routes,
+			NameServerGroups:       account.NameServerGroups,
+			Services:               account.Services,
+		},
+	}
+}
+
+// Validate checks that the bundle has a supported version and that the groups referenced by the users, the
+// policies, the routes and the nameserver groups exist
+func (b *AccountBundle) Validate() error {
+	if b.Version < 1 || b.Version > AccountBundleVersion {
+		return fmt.Errorf("unsupported account bundle version %d, supported up to %d", b.Version, AccountBundleVersion)
+	}
+
+	config := b.Account
+	if config == nil || config.ID == "" {
+		return fmt.Errorf("the account bundle has no account ID")
+	}
+	if config.Network == nil {
+		return fmt.Errorf("the account bundle has no network")
+	}
+
+	checkGroups := func(kind, id string, groups []string) error {
+		for _, groupID := range groups {
+			if _, ok := config.Groups[groupID]; !ok {
+				return fmt.Errorf("%s %s references the unknown group %s", kind, id, groupID)
+			}
+		}
+		return nil
+	}
+	for id, user := range config.Users {
+		if err := checkGroups("user", id, user.AutoGroups); err != nil {
+			return err
+		}
+	}
+	for _, policy := range config.Policies {
+		for _, rule := range policy.Rules {
+			if err := checkGroups("policy", policy.ID, rule.Sources); err != nil {
+				return err
+			}
+			if err := checkGroups("policy", policy.ID, rule.Destinations); err != nil {
+				return err
+			}
+			for _, serviceID := range rule.Services {
+				if _, ok := config.Services[serviceID]; !ok {
+					return fmt.Errorf("policy %s references the unknown service %s", policy.ID, serviceID)
+				}
+			}
+		}
+	}
+	for id, r := range config.Routes {
+		if err := checkGroups("route", id, r.Groups); err != nil {
+			return err
+		}
+		if err := checkGroups("route", id, r.PeerGroups); err != nil {
+			return err
+		}
+	}
+	for id, nsGroup := range config.NameServerGroups {
+		if err := checkGroups("nameserver group", id, nsGroup.Groups); err != nil {
+			return err
+		}
+	}
+	for id, group := range config.Groups {
+		for _, peerID := range group.Peers {
+			if _, ok := config.Peers[peerID]; !ok {
+				return fmt.Errorf("group %s references the unknown peer %s", id, peerID)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportAccount restores the configuration of the bundle in the store. An existing account is replaced but keeps its
+// setup keys and the personal access tokens of its users, a missing one is created without setup keys
+func ImportAccount(store Store, bundle *AccountBundle) (*Account, error) {
+	if err := bundle.Validate(); err != nil {
+		return nil, err
+	}
+	config := bundle.Account
+
+	unlock := store.AcquireAccountLock(config.ID)
+	defer unlock()
+
+	account, err := store.GetAccount(config.ID)
+	if err != nil {
+		if s, ok := status.FromError(err); !ok || s.Type() != status.NotFound {
+			return nil, err
+		}
+		account = &Account{
+			Id:        config.ID,
+			SetupKeys: make(map[string]*SetupKey),
+			Rules:     make(map[string]*Rule),
+		}
+	}
+
+	for id, user := range config.Users {
+		if user.PATs == nil {
+			user.PATs = make(map[string]*PersonalAccessToken)
+		}
+		if existing, ok := account.Users[id]; ok {
+			for tokenID, pat := range existing.PATs {
+				user.PATs[tokenID] = pat
+			}
+		}
+	}
+
+	account.CreatedBy = config.CreatedBy
+	account.Domain = config.Domain
+	account.DomainCategory = config.DomainCategory
+	account.IsDomainPrimaryAccount = config.IsDomainPrimaryAccount
+	account.Network = config.Network
+	account.Settings = config.Settings
+	account.DNSSettings = config.DNSSettings
+	account.Users = nonNilMap(config.Users)
+	account.Peers = nonNilMap(config.Peers)
+	account.Groups = nonNilMap(config.Groups)
+	account.Policies = config.Policies
+	account.Routes = nonNilMap(config.Routes)
+	account.NameServerGroups = nonNilMap(config.NameServerGroups)
+	account.Services = nonNilMap(config.Services)
+	if account.Settings == nil {
+		account.Settings = &Settings{
+			PeerLoginExpirationEnabled: true,
+			PeerLoginExpiration:        DefaultPeerLoginExpiration,
+		}
+	}
+
+	if err := store.SaveAccount(account); err != nil {
+		return nil, fmt.Errorf("failed saving account %s: %v", account.Id, err)
+	}
+	return account, nil
+}
+
+// MarshalAccountBundle encodes the bundle in the format, AccountBundleJSON or AccountBundleYAML
+func MarshalAccountBundle(bundle *AccountBundle, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(format) {
+	case AccountBundleJSON:
+		return data, nil
+	case AccountBundleYAML:
+		// the YAML document mirrors the JSON one, so that both use the same field names
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var document any
+		if err := decoder.Decode(&document); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(yamlNumbers(document))
+	default:
+		return nil, fmt.Errorf("unsupported account bundle format %s", format)
+	}
+}
+
+// UnmarshalAccountBundle decodes a bundle encoded in the format, AccountBundleJSON or AccountBundleYAML
+func UnmarshalAccountBundle(data []byte, format string) (*AccountBundle, error) {
+	switch strings.ToLower(format) {
+	case AccountBundleJSON:
+	case AccountBundleYAML:
+		var document any
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return nil, err
+		}
+		var err error
+		if data, err = json.Marshal(document); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported account bundle format %s", format)
+	}
+
+	bundle := &AccountBundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// yamlNumbers replaces the JSON numbers of the document with integers or floats, so that they are encoded as YAML
+// numbers and the large integers keep their precision
+func yamlNumbers(document any) any {
+	switch v := document.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = yamlNumbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = yamlNumbers(value)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return document
+}
+
+func nonNilMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return make(map[K]V)
+	}
+	return m
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func newBundleTestAccount() *Account {
+	account := newAccountWithId("account-id", "owner", "netbird.io")
+	account.Peers["peer-a"] = &nbpeer.Peer{
+		ID:       "peer-a",
+		Key:      "peer-a-key",
+		IP:       net.ParseIP("100.64.0.1"),
+		Name:     "peer-a",
+		DNSLabel: "peer-a",
+		Status:   &nbpeer.PeerStatus{},
+	}
+	account.Groups["dev"] = &Group{ID: "dev", Name: "dev", Peers: []string{"peer-a"}}
+	account.Policies = append(account.Policies, &Policy{
+		ID:      "policy",
+		Name:    "dev to dev",
+		Enabled: true,
+		Rules: []*PolicyRule{{
+			ID:            "rule",
+			Enabled:       true,
+			Action:        PolicyTrafficActionAccept,
+			Protocol:      PolicyRuleProtocolALL,
+			Bidirectional: true,
+			Sources:       []string{"dev"},
+			Destinations:  []string{"dev"},
+		}},
+	})
+	account.Users["owner"].PATs = map[string]*PersonalAccessToken{
+		"token": {ID: "token", HashedToken: "secret"},
+	}
+	return account
+}
+
+func TestAccountBundle_RoundTrip(t *testing.T) {
+	account := newBundleTestAccount()
+	bundle := ExportAccount(account)
+	assert.Empty(t, bundle.Account.Users["owner"].PATs, "the personal access tokens should not be exported")
+	assert.NotEmpty(t, account.Users["owner"].PATs, "the exported account should not be modified")
+
+	for _, format := range []string{AccountBundleJSON, AccountBundleYAML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := MarshalAccountBundle(bundle, format)
+			require.NoError(t, err)
+
+			decoded, err := UnmarshalAccountBundle(data, format)
+			require.NoError(t, err)
+			require.NoError(t, decoded.Validate())
+
+			store := newStore(t)
+			imported, err := ImportAccount(store, decoded)
+			require.NoError(t, err)
+
+			restored, err := store.GetAccount(account.Id)
+			require.NoError(t, err)
+			assert.Equal(t, imported.Id, restored.Id)
+			assert.Equal(t, account.Network.Net.String(), restored.Network.Net.String())
+			assert.Equal(t, account.Settings.PeerLoginExpiration, restored.Settings.PeerLoginExpiration)
+			assert.Equal(t, "100.64.0.1", restored.Peers["peer-a"].IP.String())
+			assert.Equal(t, []string{"peer-a"}, restored.Groups["dev"].Peers)
+			var policy *Policy
+			for _, p := range restored.Policies {
+				if p.ID == "policy" {
+					policy = p
+				}
+			}
+			require.NotNil(t, policy, "the policy should be imported")
+			assert.Equal(t, []string{"dev"}, policy.Rules[0].Sources)
+			assert.Empty(t, restored.SetupKeys, "the setup keys should not be imported")
+		})
+	}
+}
+
+func TestImportAccount_KeepsSecrets(t *testing.T) {
+	account := newBundleTestAccount()
+	account.SetupKeys["key"] = &SetupKey{Id: "key", Key: "setup-key"}
+	store := newStore(t)
+	require.NoError(t, store.SaveAccount(account))
+
+	bundle := ExportAccount(account)
+	bundle.Account.Groups["ops"] = &Group{ID: "ops", Name: "ops"}
+
+	_, err := ImportAccount(store, bundle)
+	require.NoError(t, err)
+
+	restored, err := store.GetAccount(account.Id)
+	require.NoError(t, err)
+	assert.Contains(t, restored.Groups, "ops")
+	assert.Contains(t, restored.SetupKeys, "key", "the setup keys of the existing account should be kept")
+	assert.Contains(t, restored.Users["owner"].PATs, "token", "the tokens of the existing users should be kept")
+}
+
+func TestAccountBundle_Validate(t *testing.T) {
+	bundle := ExportAccount(newBundleTestAccount())
+	require.NoError(t, bundle.Validate())
+
+	bundle.Version = AccountBundleVersion + 1
+	assert.Error(t, bundle.Validate(), "a newer version should be rejected")
+
+	bundle = ExportAccount(newBundleTestAccount())
+	bundle.Account.Policies[0].Rules[0].Destinations = []string{"missing"}
+	assert.Error(t, bundle.Validate(), "a policy referencing an unknown group should be rejected")
+
+	bundle = ExportAccount(newBundleTestAccount())
+	bundle.Account.Groups["dev"].Peers = []string{"missing"}
+	assert.Error(t, bundle.Validate(), "a group referencing an unknown peer should be rejected")
+
+	_, err := UnmarshalAccountBundle([]byte("{}"), "toml")
+	assert.Error(t, err, "an unsupported format should be rejected")
+}
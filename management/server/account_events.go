@@ -0,0 +1,148 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// AccountEventType is the type of the live events of an account
+type AccountEventType string
+
+const (
+	// AccountEventPeerStatus is sent when a peer connects to or disconnects from the Management service
+	AccountEventPeerStatus AccountEventType = "peer_status"
+	// AccountEventActivity is sent when an activity event is stored
+	AccountEventActivity AccountEventType = "activity"
+)
+
+// AccountEvent is a live event of an account delivered to the subscribers of the account
+type AccountEvent struct {
+	Type      AccountEventType
+	Timestamp time.Time
+	// Peer is a copy of the peer of the AccountEventPeerStatus events
+	Peer *nbpeer.Peer
+	// Activity is the stored event of the AccountEventActivity events
+	Activity *activity.Event
+}
+
+// AccountEventSubscription delivers the live events of an account the user is allowed to see. The events are dropped
+// when the subscriber doesn't keep up with them
+type AccountEventSubscription struct {
+	accountID string
+	// peerOwnerID limits the peer events to the peers of the user, empty for the users with admin power
+	peerOwnerID string
+	events      chan *AccountEvent
+	closeOnce   sync.Once
+	manager     *AccountEventsManager
+}
+
+// Events returns the channel of the events, it is closed when the subscription is closed
+func (s *AccountEventSubscription) Events() <-chan *AccountEvent {
+	return s.events
+}
+
+// Close stops the delivery of the events
+func (s *AccountEventSubscription) Close() {
+	s.closeOnce.Do(func() {
+		s.manager.unsubscribe(s)
+	})
+}
+
+func (s *AccountEventSubscription) allowed(event *AccountEvent) bool {
+	if s.peerOwnerID == "" || event.Type != AccountEventPeerStatus {
+		return true
+	}
+	return event.Peer.UserID == s.peerOwnerID
+}
+
+// AccountEventsManager fans out the live events to the subscribers of the accounts, the zero value is ready to use
+type AccountEventsManager struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*AccountEventSubscription]struct{}
+}
+
+// Subscribe subscribes to the events of the account, the peer events are limited to the peers of peerOwnerID unless
+// it is empty
+func (m *AccountEventsManager) Subscribe(accountID, peerOwnerID string) *AccountEventSubscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.subscribers == nil {
+		m.subscribers = make(map[string]map[*AccountEventSubscription]struct{})
+	}
+	accountSubscribers, ok := m.subscribers[accountID]
+	if !ok {
+		accountSubscribers = make(map[*AccountEventSubscription]struct{})
+		m.subscribers[accountID] = accountSubscribers
+	}
+
+	subscription := &AccountEventSubscription{
+		accountID:   accountID,
+		peerOwnerID: peerOwnerID,
+		events:      make(chan *AccountEvent, channelBufferSize),
+		manager:     m,
+	}
+	accountSubscribers[subscription] = struct{}{}
+	return subscription
+}
+
+func (m *AccountEventsManager) unsubscribe(subscription *AccountEventSubscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	accountSubscribers := m.subscribers[subscription.accountID]
+	if _, ok := accountSubscribers[subscription]; !ok {
+		return
+	}
+	delete(accountSubscribers, subscription)
+	if len(accountSubscribers) == 0 {
+		delete(m.subscribers, subscription.accountID)
+	}
+	close(subscription.events)
+}
+
+// Publish delivers the event to the subscribers of the account
+func (m *AccountEventsManager) Publish(accountID string, event *AccountEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for subscription := range m.subscribers[accountID] {
+		if !subscription.allowed(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			log.Warnf("dropped a live %s event of account %s, the subscriber doesn't keep up", event.Type, accountID)
+		}
+	}
+}
+
+// SubscribeAccountEvents subscribes the user to the live events of the account. The users without admin power only
+// receive the status changes of their own peers
+func (am *DefaultAccountManager) SubscribeAccountEvents(accountID, userID string) (*AccountEventSubscription, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsBlocked() {
+		return nil, status.Errorf(status.PermissionDenied, "the user is blocked")
+	}
+
+	peerOwnerID := ""
+	if !user.HasAdminPower() {
+		peerOwnerID = user.Id
+	}
+	return am.accountEvents.Subscribe(accountID, peerOwnerID), nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestSubscribeAccountEvents(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	subscription, err := am.SubscribeAccountEvents(account.Id, userID)
+	require.NoError(t, err)
+	defer subscription.Close()
+
+	err = am.MarkPeerConnected(peer1Key, true)
+	require.NoError(t, err)
+
+	event := receiveAccountEvent(t, subscription)
+	assert.Equal(t, AccountEventPeerStatus, event.Type)
+	require.NotNil(t, event.Peer)
+	assert.Equal(t, peer1ID, event.Peer.ID)
+	assert.True(t, event.Peer.Status.Connected)
+
+	am.StoreEvent(userID, peer1ID, account.Id, activity.PeerRenamed, nil)
+
+	event = receiveAccountEvent(t, subscription)
+	assert.Equal(t, AccountEventActivity, event.Type)
+	require.NotNil(t, event.Activity)
+	assert.Equal(t, activity.PeerRenamed, event.Activity.Activity)
+	assert.Equal(t, peer1ID, event.Activity.TargetID)
+
+	_, err = am.SubscribeAccountEvents(account.Id, "unknown")
+	assert.Error(t, err, "should fail for an unknown user")
+}
+
+func TestAccountEventsManager(t *testing.T) {
+	manager := &AccountEventsManager{}
+	regular := manager.Subscribe("account", "user1")
+	admin := manager.Subscribe("account", "")
+	other := manager.Subscribe("other", "")
+
+	manager.Publish("account", &AccountEvent{Type: AccountEventPeerStatus, Peer: &nbpeer.Peer{ID: "peer2", UserID: "user2"}})
+	manager.Publish("account", &AccountEvent{Type: AccountEventPeerStatus, Peer: &nbpeer.Peer{ID: "peer1", UserID: "user1"}})
+	manager.Publish("account", &AccountEvent{Type: AccountEventActivity, Activity: &activity.Event{ID: 1}})
+
+	assert.Equal(t, "peer1", receiveAccountEvent(t, regular).Peer.ID, "the regular users should only receive the events of their peers")
+	assert.Equal(t, AccountEventActivity, receiveAccountEvent(t, regular).Type)
+	assert.Equal(t, "peer2", receiveAccountEvent(t, admin).Peer.ID)
+	assert.Equal(t, "peer1", receiveAccountEvent(t, admin).Peer.ID)
+	assert.Equal(t, AccountEventActivity, receiveAccountEvent(t, admin).Type)
+	assert.Len(t, other.Events(), 0, "the events of other accounts should not be received")
+
+	for i := 0; i < channelBufferSize+1; i++ {
+		manager.Publish("other", &AccountEvent{Type: AccountEventActivity})
+	}
+	assert.Len(t, other.Events(), channelBufferSize, "the events should be dropped when the subscriber doesn't keep up")
+
+	regular.Close()
+	regular.Close()
+	_, ok := <-regular.Events()
+	assert.False(t, ok, "the events channel should be closed")
+
+	manager.Publish("account", &AccountEvent{Type: AccountEventActivity, Activity: &activity.Event{ID: 2}})
+	assert.Equal(t, uint64(2), receiveAccountEvent(t, admin).Activity.ID, "the other subscribers should keep receiving the events")
+}
+
+func receiveAccountEvent(t *testing.T, subscription *AccountEventSubscription) *AccountEvent {
+	t.Helper()
+
+	select {
+	case event := <-subscription.Events():
+		require.NotNil(t, event)
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an account event")
+		return nil
+	}
+}
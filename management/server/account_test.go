@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/netip"
 	"reflect"
 	"sync"
 	"testing"
@@ -942,7 +943,7 @@ func TestAccountManager_AddPeer(t *testing.T) {
 
 	serial := account.Network.CurrentSerial() // should be 0
 
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -1085,7 +1086,7 @@ func TestAccountManager_NetworkUpdates(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -1264,7 +1265,7 @@ func TestAccountManager_DeletePeer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userID, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -1557,6 +1558,13 @@ func TestAccount_Copy(t *testing.T) {
 				NameServers: []nbdns.NameServer{},
 			},
 		},
+		Services: map[string]*Service{
+			"service1": {
+				ID:        "service1",
+				Name:      "web",
+				Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"80", "443"}}},
+			},
+		},
 		DNSSettings: DNSSettings{DisabledManagementGroups: []string{}},
 		Settings:    &Settings{},
 	}
@@ -1792,6 +1800,103 @@ func TestDefaultAccountManager_UpdateAccountSettings(t *testing.T) {
 	require.Error(t, err, "expecting to fail when providing PeerLoginExpiration more than 180 days")
 }
 
+func TestDefaultAccountManager_UpdateAccountSettings_NetworkRange(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err, "unable to create account manager")
+	account, err := manager.GetAccountByUserOrAccountID(userID, "", "")
+	require.NoError(t, err, "unable to create an account")
+
+	var peers []*nbpeer.Peer
+	for i := 0; i < 3; i++ {
+		key, err := wgtypes.GenerateKey()
+		require.NoError(t, err, "unable to generate WireGuard key")
+		peer, _, err := manager.AddPeer("", userID, &nbpeer.Peer{
+			Key:  key.PublicKey().String(),
+			Meta: nbpeer.PeerSystemMeta{Hostname: fmt.Sprintf("test-peer-%d", i)},
+		})
+		require.NoError(t, err, "unable to add peer")
+		peers = append(peers, peer)
+	}
+
+	networkRange := netip.MustParsePrefix("10.10.0.0/16")
+	pool := netip.MustParsePrefix("10.10.1.0/24")
+	updated, err := manager.UpdateAccountSettings(account.Id, userID, &Settings{
+		PeerLoginExpiration: time.Hour,
+		NetworkRange:        networkRange,
+		IPPools:             []netip.Prefix{pool},
+	})
+	require.NoError(t, err, "expecting to update account settings successfully but got error")
+	assert.Equal(t, networkRange, updated.Network.Prefix())
+
+	account, err = manager.Store.GetAccount(account.Id)
+	require.NoError(t, err, "unable to get account")
+	assert.Equal(t, networkRange, account.Network.Prefix())
+	for _, peer := range peers {
+		addr, _ := netip.AddrFromSlice(account.Peers[peer.ID].IP.To4())
+		assert.True(t, pool.Contains(addr), "peer IP %s should be re-allocated from the pool %s", addr, pool)
+	}
+
+	// a static IP in the range is kept when the pools change
+	update := account.Peers[peers[0].ID].Copy()
+	update.IP = net.ParseIP("10.10.5.5")
+	_, err = manager.UpdatePeer(account.Id, userID, update)
+	require.NoError(t, err, "unable to assign a static IP to the peer")
+
+	_, err = manager.UpdateAccountSettings(account.Id, userID, &Settings{
+		PeerLoginExpiration: time.Hour,
+		NetworkRange:        networkRange,
+		IPPools:             []netip.Prefix{netip.MustParsePrefix("10.10.2.0/24")},
+	})
+	require.NoError(t, err, "expecting to update account settings successfully but got error")
+	account, err = manager.Store.GetAccount(account.Id)
+	require.NoError(t, err, "unable to get account")
+	assert.Equal(t, "10.10.5.5", account.Peers[peers[0].ID].IP.String())
+
+	// a range the peers can't be moved to is rejected
+	_, err = manager.UpdateAccountSettings(account.Id, userID, &Settings{
+		PeerLoginExpiration: time.Hour,
+		NetworkRange:        netip.MustParsePrefix("10.20.0.0/16"),
+		IPPools:             []netip.Prefix{netip.MustParsePrefix("10.10.0.0/24")},
+	})
+	require.Error(t, err, "expecting to fail when the IP pools aren't in the network range")
+}
+
+func TestDefaultAccountManager_UpdatePeer_StaticIP(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err, "unable to create account manager")
+	account, err := manager.GetAccountByUserOrAccountID(userID, "", "")
+	require.NoError(t, err, "unable to create an account")
+
+	var peers []*nbpeer.Peer
+	for i := 0; i < 2; i++ {
+		key, err := wgtypes.GenerateKey()
+		require.NoError(t, err, "unable to generate WireGuard key")
+		peer, _, err := manager.AddPeer("", userID, &nbpeer.Peer{
+			Key:  key.PublicKey().String(),
+			Meta: nbpeer.PeerSystemMeta{Hostname: fmt.Sprintf("test-peer-%d", i)},
+		})
+		require.NoError(t, err, "unable to add peer")
+		peers = append(peers, peer)
+	}
+
+	update := peers[0].Copy()
+	update.IP = peers[1].IP
+	_, err = manager.UpdatePeer(account.Id, userID, update)
+	require.Error(t, err, "expecting to fail when assigning the IP of another peer")
+
+	update.IP = net.ParseIP("192.168.0.1")
+	_, err = manager.UpdatePeer(account.Id, userID, update)
+	require.Error(t, err, "expecting to fail when assigning an IP outside of the network")
+
+	ip := make(net.IP, len(account.Network.Net.IP.To4()))
+	copy(ip, account.Network.Net.IP.To4())
+	ip[3] = 200
+	update.IP = ip
+	updated, err := manager.UpdatePeer(account.Id, userID, update)
+	require.NoError(t, err, "unable to assign a static IP to the peer")
+	assert.Equal(t, ip.String(), updated.IP.String())
+}
+
 func TestAccount_GetExpiredPeers(t *testing.T) {
 	type test struct {
 		name          string
@@ -1877,6 +1982,79 @@ func TestAccount_GetExpiredPeers(t *testing.T) {
 	}
 }
 
+func TestAccount_GetPeerNetworkMap_LoginExpired(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"expired": {
+				ID:                     "expired",
+				IP:                     net.ParseIP("100.65.14.88"),
+				Status:                 &nbpeer.PeerStatus{},
+				LoginExpirationEnabled: true,
+				LastLogin:              time.Now().UTC().Add(-2 * time.Hour),
+				UserID:                 userID,
+			},
+			"helpdesk": {
+				ID:     "helpdesk",
+				IP:     net.ParseIP("100.65.80.39"),
+				Status: &nbpeer.PeerStatus{},
+			},
+			"server": {
+				ID:     "server",
+				IP:     net.ParseIP("100.65.254.139"),
+				Status: &nbpeer.PeerStatus{},
+			},
+		},
+		Groups: map[string]*Group{
+			"GroupAll": {
+				ID:    "GroupAll",
+				Name:  "All",
+				Peers: []string{"expired", "helpdesk", "server"},
+			},
+			"GroupHelpdesk": {
+				ID:    "GroupHelpdesk",
+				Name:  "helpdesk",
+				Peers: []string{"helpdesk"},
+			},
+		},
+		Network: &Network{
+			Identifier: "net",
+			Net:        net.IPNet{IP: net.ParseIP("100.65.0.0"), Mask: net.CIDRMask(16, 32)},
+		},
+		Settings: &Settings{
+			PeerLoginExpirationEnabled:   true,
+			PeerLoginExpiration:          time.Hour,
+			PeerLoginExpiredAccessGroups: []string{"GroupHelpdesk"},
+		},
+	}
+
+	policy, err := RuleToPolicy(&Rule{
+		ID:          "RuleDefault",
+		Name:        "Default",
+		Source:      []string{"GroupAll"},
+		Destination: []string{"GroupAll"},
+	})
+	require.NoError(t, err)
+	account.Policies = append(account.Policies, policy)
+
+	networkMap := account.GetPeerNetworkMap("expired", "netbird.cloud")
+	assert.True(t, networkMap.LoginExpired, "the login of the peer should be expired")
+	require.Len(t, networkMap.Peers, 1, "only the access groups should be reachable")
+	assert.Equal(t, "helpdesk", networkMap.Peers[0].ID)
+	for _, rule := range networkMap.FirewallRules {
+		assert.Equal(t, "100.65.80.39", rule.PeerIP, "rules for all peers should be narrowed down to the access groups")
+	}
+
+	networkMap = account.GetPeerNetworkMap("helpdesk", "netbird.cloud")
+	assert.False(t, networkMap.LoginExpired)
+	assert.Len(t, networkMap.Peers, 2, "the access groups should keep the expired peer")
+
+	networkMap = account.GetPeerNetworkMap("server", "netbird.cloud")
+	require.Len(t, networkMap.Peers, 1, "other peers should disconnect from the expired peer")
+	assert.Equal(t, "helpdesk", networkMap.Peers[0].ID)
+	require.Len(t, networkMap.OfflinePeers, 1)
+	assert.Equal(t, "expired", networkMap.OfflinePeers[0].ID)
+}
+
 func TestAccount_GetPeersWithExpiration(t *testing.T) {
 	type test struct {
 		name          string
@@ -2156,6 +2334,32 @@ func TestAccount_SetJWTGroups(t *testing.T) {
 	})
 }
 
+func TestAccount_SetIdPGroups(t *testing.T) {
+	account := &Account{
+		Groups: map[string]*Group{
+			"jwt": {ID: "jwt", Name: "staff", Issued: GroupIssuedJWT},
+		},
+		Settings: &Settings{},
+		Users: map[string]*User{
+			"user1": {Id: "user1", AutoGroups: []string{"jwt"}},
+		},
+	}
+
+	updated := account.SetIdPGroups("user1", []string{"contractors"})
+	assert.True(t, updated, "account should be updated")
+	require.Len(t, account.Users["user1"].AutoGroups, 2, "the provider group should be added")
+	group := account.Groups[account.Users["user1"].AutoGroups[1]]
+	assert.Equal(t, "contractors", group.Name)
+	assert.Equal(t, GroupIssuedIdP, group.Issued, "the provider group should be issued by the provider")
+
+	updated = account.SetIdPGroups("user1", []string{"contractors", "staff"})
+	assert.False(t, updated, "the JWT group should not be synced as a provider group")
+
+	updated = account.SetIdPGroups("user1", nil)
+	assert.True(t, updated, "the provider group should be removed")
+	assert.Equal(t, []string{"jwt"}, account.Users["user1"].AutoGroups, "the JWT group should be kept")
+}
+
 func TestAccount_UserGroupsAddToPeers(t *testing.T) {
 	account := &Account{
 		Peers: map[string]*nbpeer.Peer{
@@ -2255,3 +2459,33 @@ func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
 		return true
 	}
 }
+
+func TestAccount_getPeersGroupNames(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"router":  {ID: "router"},
+			"laptop":  {ID: "laptop"},
+			"desktop": {ID: "desktop"},
+		},
+		Groups: map[string]*Group{
+			"group1": {ID: "group1", Name: "servers", Peers: []string{"router"}},
+			"group2": {ID: "group2", Name: "all", Peers: []string{"router", "laptop", "desktop"}},
+			"group3": {ID: "group3", Name: "office", Peers: []string{"desktop"}},
+		},
+	}
+
+	names := account.getPeersGroupNames([]*nbpeer.Peer{account.Peers["router"]}, []*nbpeer.Peer{account.Peers["laptop"]})
+	assert.Equal(t, map[string][]string{
+		"router": {"all", "servers"},
+		"laptop": {"all"},
+	}, names, "only the given peers should be returned with their sorted group names")
+
+	networkMap := &NetworkMap{
+		Peers:      []*nbpeer.Peer{account.Peers["router"]},
+		Network:    &Network{},
+		PeerGroups: names,
+	}
+	response := toSyncResponse(nil, account.Peers["desktop"], nil, networkMap, "netbird.cloud")
+	require.Len(t, response.NetworkMap.RemotePeers, 1)
+	assert.Equal(t, []string{"all", "servers"}, response.NetworkMap.RemotePeers[0].Groups)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// maxActiveRoutesReported is the maximum number of routes accepted in a single report of a peer
+	maxActiveRoutesReported = 500
+	// activeRouteMaxAge is the age after which a report is no longer returned, the peers refresh it periodically
+	activeRouteMaxAge = 15 * time.Minute
+)
+
+// ActiveRouteReport is a route chosen by a peer among the routes of the same network as reported by the peer
+type ActiveRouteReport struct {
+	// RouteID is the ID of the route as sent in the network map of the peer
+	RouteID string
+	// PeerKey is the WireGuard public key of the routing peer of the route
+	PeerKey string
+}
+
+// ActiveRoute is the route a peer uses for a network of an HA set of routes
+type ActiveRoute struct {
+	PeerID        string
+	RouteID       string
+	NetID         string
+	Network       string
+	RoutingPeerID string
+	ReportedAt    time.Time
+}
+
+// activeRouteStore keeps the latest active routes reported by the peers of the accounts in memory
+type activeRouteStore struct {
+	mu       sync.Mutex
+	accounts map[string]map[string][]*ActiveRoute
+}
+
+// save replaces the active routes of the peer
+func (s *activeRouteStore) save(accountID, peerID string, routes []*ActiveRoute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accounts == nil {
+		s.accounts = make(map[string]map[string][]*ActiveRoute)
+	}
+	accountRoutes, ok := s.accounts[accountID]
+	if !ok {
+		accountRoutes = make(map[string][]*ActiveRoute)
+		s.accounts[accountID] = accountRoutes
+	}
+
+	accountRoutes[peerID] = routes
+}
+
+// get returns the active routes of the account reported after the given time, removing the older ones
+func (s *activeRouteStore) get(accountID string, after time.Time) []*ActiveRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var routes []*ActiveRoute
+	for peerID, peerRoutes := range s.accounts[accountID] {
+		if len(peerRoutes) == 0 || peerRoutes[0].ReportedAt.Before(after) {
+			delete(s.accounts[accountID], peerID)
+			continue
+		}
+		for _, activeRoute := range peerRoutes {
+			routeCopy := *activeRoute
+			routes = append(routes, &routeCopy)
+		}
+	}
+	return routes
+}
+
+func (s *activeRouteStore) deleteAccount(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.accounts, accountID)
+}
+
+// ReportActiveRoutes stores the routes chosen by the peer with the given WireGuard public key for the networks it
+// routes traffic to, replacing its previous report. Routes and routing peers unknown to the account are ignored.
+func (am *DefaultAccountManager) ReportActiveRoutes(peerPubKey string, reports []ActiveRouteReport) error {
+	if len(reports) > maxActiveRoutesReported {
+		return status.Errorf(status.InvalidArgument, "too many routes, the maximum is %d", maxActiveRoutesReported)
+	}
+
+	account, err := am.Store.GetAccountByPeerPubKey(peerPubKey)
+	if err != nil {
+		if errStatus, ok := status.FromError(err); ok && errStatus.Type() == status.NotFound {
+			return status.Errorf(status.Unauthenticated, "peer is not registered")
+		}
+		return err
+	}
+
+	peer, err := account.FindPeerByPubKey(peerPubKey)
+	if err != nil {
+		return status.Errorf(status.Unauthenticated, "peer is not registered")
+	}
+
+	now := time.Now().UTC()
+	routes := make([]*ActiveRoute, 0, len(reports))
+	for _, report := range reports {
+		// the routes of peer groups are distributed with the ID of the routing peer appended
+		routeID, _, _ := strings.Cut(report.RouteID, ":")
+		accountRoute, ok := account.Routes[routeID]
+		if !ok {
+			continue
+		}
+
+		routingPeer, err := account.FindPeerByPubKey(report.PeerKey)
+		if err != nil {
+			continue
+		}
+
+		routes = append(routes, &ActiveRoute{
+			PeerID:        peer.ID,
+			RouteID:       accountRoute.ID,
+			NetID:         accountRoute.NetID,
+			Network:       accountRoute.Network.String(),
+			RoutingPeerID: routingPeer.ID,
+			ReportedAt:    now,
+		})
+	}
+
+	am.activeRoutes.save(account.Id, peer.ID, routes)
+
+	return nil
+}
+
+// GetActiveRoutes returns the routes the peers of the account reported as active within the last minutes
+func (am *DefaultAccountManager) GetActiveRoutes(accountID, userID string) ([]*ActiveRoute, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view active routes")
+	}
+
+	routes := make([]*ActiveRoute, 0)
+	for _, activeRoute := range am.activeRoutes.get(accountID, time.Now().UTC().Add(-activeRouteMaxAge)) {
+		// the peers or the route might have been deleted since the report
+		if account.GetPeer(activeRoute.PeerID) == nil || account.GetPeer(activeRoute.RoutingPeerID) == nil {
+			continue
+		}
+		if _, ok := account.Routes[activeRoute.RouteID]; !ok {
+			continue
+		}
+		routes = append(routes, activeRoute)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].PeerID != routes[j].PeerID {
+			return routes[i].PeerID < routes[j].PeerID
+		}
+		return routes[i].RouteID < routes[j].RouteID
+	})
+
+	return routes, nil
+}
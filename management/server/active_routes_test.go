@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportActiveRoutes(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	haRoute, err := am.CreateRoute(account.Id, "192.168.0.0/16", "", []string{routeGroupHA2}, "ha", "ha", false,
+		9999, true, false, []string{routeGroup2}, true, userID, "")
+	require.NoError(t, err)
+
+	reports := []ActiveRouteReport{
+		{RouteID: haRoute.ID + ":" + peer4ID, PeerKey: peer4Key},
+		{RouteID: "unknown", PeerKey: peer4Key},
+		{RouteID: haRoute.ID, PeerKey: "unknown"},
+	}
+
+	err = am.ReportActiveRoutes(peer2Key, reports)
+	require.NoError(t, err)
+
+	activeRoutes, err := am.GetActiveRoutes(account.Id, userID)
+	require.NoError(t, err)
+	require.Len(t, activeRoutes, 1, "unknown routes and routing peers should be ignored")
+
+	assert.Equal(t, peer2ID, activeRoutes[0].PeerID)
+	assert.Equal(t, haRoute.ID, activeRoutes[0].RouteID)
+	assert.Equal(t, "ha", activeRoutes[0].NetID)
+	assert.Equal(t, "192.168.0.0/16", activeRoutes[0].Network)
+	assert.Equal(t, peer4ID, activeRoutes[0].RoutingPeerID)
+
+	err = am.ReportActiveRoutes(peer2Key, []ActiveRouteReport{{RouteID: haRoute.ID + ":" + peer1ID, PeerKey: peer1Key}})
+	require.NoError(t, err)
+
+	activeRoutes, err = am.GetActiveRoutes(account.Id, userID)
+	require.NoError(t, err)
+	require.Len(t, activeRoutes, 1)
+	assert.Equal(t, peer1ID, activeRoutes[0].RoutingPeerID, "the latest report should replace the previous one")
+
+	err = am.ReportActiveRoutes(peer2Key, nil)
+	require.NoError(t, err)
+
+	activeRoutes, err = am.GetActiveRoutes(account.Id, userID)
+	require.NoError(t, err)
+	assert.Empty(t, activeRoutes, "an empty report should clear the active routes of the peer")
+
+	err = am.ReportActiveRoutes("unknown", reports)
+	require.Error(t, err, "should fail for unregistered peers")
+
+	tooMany := make([]ActiveRouteReport, maxActiveRoutesReported+1)
+	err = am.ReportActiveRoutes(peer2Key, tooMany)
+	require.Error(t, err, "should fail with too many routes")
+}
+
+func TestActiveRouteStore_ExpiresReports(t *testing.T) {
+	store := &activeRouteStore{}
+	store.save("account", "a", []*ActiveRoute{{PeerID: "a", RouteID: "r1", ReportedAt: time.Now().Add(-2 * activeRouteMaxAge)}})
+	store.save("account", "b", []*ActiveRoute{{PeerID: "b", RouteID: "r1", ReportedAt: time.Now()}})
+
+	routes := store.get("account", time.Now().Add(-activeRouteMaxAge))
+	require.Len(t, routes, 1)
+	assert.Equal(t, "b", routes[0].PeerID)
+	assert.Len(t, store.accounts["account"], 1, "expired reports should be removed")
+
+	store.deleteAccount("account")
+	assert.Empty(t, store.get("account", time.Time{}))
+}
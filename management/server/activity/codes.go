@@ -130,6 +130,70 @@ const (
 	PeerApprovalRevoked
 	// TransferredOwnerRole indicates that the user transferred the owner role of the account
 	TransferredOwnerRole
+	// PeerBandwidthLimitUpdated indicates that a user updated the bandwidth limit of a peer
+	PeerBandwidthLimitUpdated
+	// AccountExpiredPeerAccessGroupsUpdated indicates that a user updated the groups reachable by peers with an expired login
+	AccountExpiredPeerAccessGroupsUpdated
+	// AccountRouteAdvertisementUpdated indicates that a user updated the route advertisement settings of the account
+	AccountRouteAdvertisementUpdated
+	// RouteAdvertisedByPeer indicates that a peer created a route to one of its networks
+	RouteAdvertisedByPeer
+	// AccountPeerLatencyReportsEnabled indicates that the user enabled the latency reports of the peers for the account
+	AccountPeerLatencyReportsEnabled
+	// AccountPeerLatencyReportsDisabled indicates that the user disabled the latency reports of the peers for the account
+	AccountPeerLatencyReportsDisabled
+	// AccountPeerDefaultDenyEnabled indicates that the user made the peers of the account deny the traffic by default
+	AccountPeerDefaultDenyEnabled
+	// AccountPeerDefaultDenyDisabled indicates that the user made the peers of the account accept the traffic of the
+	// routed networks by default again
+	AccountPeerDefaultDenyDisabled
+	// DNSSearchDomainGroupsUpdated indicates that a user updated the search domains assigned to groups
+	DNSSearchDomainGroupsUpdated
+	// PeerDNSAliasesUpdated indicates that a user updated the DNS aliases of a peer
+	PeerDNSAliasesUpdated
+	// AccountNetworkRangeUpdated indicates that a user changed the network range of the account and re-addressed the peers
+	AccountNetworkRangeUpdated
+	// AccountIPPoolsUpdated indicates that a user updated the address pools the peer IPs are allocated from
+	AccountIPPoolsUpdated
+	// PeerIPUpdated indicates that a user assigned a static IP to a peer
+	PeerIPUpdated
+	// PeerLabelsUpdated indicates that a user updated the labels of a peer
+	PeerLabelsUpdated
+	// AccountPeerUsageReportsEnabled indicates that the user enabled the usage reports of the peers for the account
+	AccountPeerUsageReportsEnabled
+	// AccountPeerUsageReportsDisabled indicates that the user disabled the usage reports of the peers for the account
+	AccountPeerUsageReportsDisabled
+	// AccountPeerInactivityExpirationEnabled indicates that the user enabled the inactivity expiration of the peers
+	AccountPeerInactivityExpirationEnabled
+	// AccountPeerInactivityExpirationDisabled indicates that the user disabled the inactivity expiration of the peers
+	AccountPeerInactivityExpirationDisabled
+	// AccountPeerInactivityExpirationUpdated indicates that the user updated the inactivity period or action
+	AccountPeerInactivityExpirationUpdated
+	// PeerInactivityWarning indicates that the peer will be disabled or deleted soon if it doesn't connect
+	PeerInactivityWarning
+	// PeerDisabledForInactivity indicates that the peer was disabled because it didn't connect for too long
+	PeerDisabledForInactivity
+	// PeerDeletedForInactivity indicates that the peer was deleted because it didn't connect for too long
+	PeerDeletedForInactivity
+	// PeerEnabled indicates that the user re-enabled a disabled peer
+	PeerEnabled
+	// AccountJWTGroupMappingUpdated indicates that the user updated the rules mapping the JWT claims to groups
+	AccountJWTGroupMappingUpdated
+	// PeerTypeUpdated indicates that the user changed a peer from a user device to a service or the opposite
+	PeerTypeUpdated
+	// AccountGitOpsSynced indicates that the groups, policies and routes were reconciled with the git repository
+	AccountGitOpsSynced
+	// AccountGitOpsDriftDetected indicates that the groups, policies and routes differ from the git repository while
+	// the sync only reports the drift
+	AccountGitOpsDriftDetected
+	// GroupLoginExpirationUpdated indicates that the user updated the peer login expiration of a group
+	GroupLoginExpirationUpdated
+	// ServiceCreated indicates that a user created a service
+	ServiceCreated
+	// ServiceUpdated indicates that a user updated a service
+	ServiceUpdated
+	// ServiceDeleted indicates that a user deleted a service
+	ServiceDeleted
 )
 
 var activityMap = map[Activity]Code{
@@ -193,6 +257,37 @@ var activityMap = map[Activity]Code{
 	PeerApproved:                              {"Peer approved", "peer.approve"},
 	PeerApprovalRevoked:                       {"Peer approval revoked", "peer.approval.revoke"},
 	TransferredOwnerRole:                      {"Transferred owner role", "transferred.owner.role"},
+	PeerBandwidthLimitUpdated:                 {"Peer bandwidth limit updated", "peer.bandwidth.limit.update"},
+	AccountExpiredPeerAccessGroupsUpdated:     {"Account expired peer access groups updated", "account.setting.expired.peer.access.groups.update"},
+	AccountRouteAdvertisementUpdated:          {"Account route advertisement settings updated", "account.setting.route.advertisement.update"},
+	RouteAdvertisedByPeer:                     {"Route advertised by peer", "peer.route.advertise"},
+	AccountPeerLatencyReportsEnabled:          {"Account peer latency reports enabled", "account.setting.peer.latency.reports.enable"},
+	AccountPeerLatencyReportsDisabled:         {"Account peer latency reports disabled", "account.setting.peer.latency.reports.disable"},
+	AccountPeerDefaultDenyEnabled:             {"Account peer default deny enabled", "account.setting.peer.default.deny.enable"},
+	AccountPeerDefaultDenyDisabled:            {"Account peer default deny disabled", "account.setting.peer.default.deny.disable"},
+	DNSSearchDomainGroupsUpdated:              {"DNS search domain groups updated", "dns.setting.search.domain.groups.update"},
+	PeerDNSAliasesUpdated:                     {"Peer DNS aliases updated", "peer.dns.aliases.update"},
+	AccountNetworkRangeUpdated:                {"Account network range updated", "account.setting.network.range.update"},
+	AccountIPPoolsUpdated:                     {"Account IP pools updated", "account.setting.ip.pools.update"},
+	PeerIPUpdated:                             {"Peer IP updated", "peer.ip.update"},
+	PeerLabelsUpdated:                         {"Peer labels updated", "peer.labels.update"},
+	AccountPeerUsageReportsEnabled:            {"Account peer usage reports enabled", "account.setting.peer.usage.reports.enable"},
+	AccountPeerUsageReportsDisabled:           {"Account peer usage reports disabled", "account.setting.peer.usage.reports.disable"},
+	AccountPeerInactivityExpirationEnabled:    {"Account peer inactivity expiration enabled", "account.setting.peer.inactivity.expiration.enable"},
+	AccountPeerInactivityExpirationDisabled:   {"Account peer inactivity expiration disabled", "account.setting.peer.inactivity.expiration.disable"},
+	AccountPeerInactivityExpirationUpdated:    {"Account peer inactivity expiration updated", "account.setting.peer.inactivity.expiration.update"},
+	PeerInactivityWarning:                     {"Peer inactive", "peer.inactivity.warn"},
+	PeerDisabledForInactivity:                 {"Peer disabled for inactivity", "peer.inactivity.disable"},
+	PeerDeletedForInactivity:                  {"Peer deleted for inactivity", "peer.inactivity.delete"},
+	PeerEnabled:                               {"Peer enabled", "peer.enable"},
+	AccountJWTGroupMappingUpdated:             {"Account JWT group mapping updated", "account.setting.jwt.group.mapping.update"},
+	PeerTypeUpdated:                           {"Peer type updated", "peer.type.update"},
+	AccountGitOpsSynced:                       {"Account configuration synced from git", "account.gitops.sync"},
+	AccountGitOpsDriftDetected:                {"Account configuration drift detected", "account.gitops.drift"},
+	GroupLoginExpirationUpdated:               {"Group peer login expiration updated", "group.login.expiration.update"},
+	ServiceCreated:                            {"Service created", "service.add"},
+	ServiceUpdated:                            {"Service updated", "service.update"},
+	ServiceDeleted:                            {"Service deleted", "service.delete"},
 }
 
 // StringCode returns a string code of the activity
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/FlintyLemming/netbird/management/proto"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// AppRoutingMode selects how the applications of a group are routed through the tunnel
+type AppRoutingMode string
+
+const (
+	// AppRoutingModeInclude sends only the traffic of the listed applications into the tunnel
+	AppRoutingModeInclude AppRoutingMode = "include"
+	// AppRoutingModeExclude keeps the traffic of the listed applications out of the tunnel
+	AppRoutingModeExclude AppRoutingMode = "exclude"
+)
+
+// maxAppRoutingApplications is the maximum number of applications of a group
+const maxAppRoutingApplications = 64
+
+// AppRouting is the app routing config of a peer, merged from its groups
+type AppRouting struct {
+	Mode         AppRoutingMode
+	Applications []string
+}
+
+// validateAppRouting returns the trimmed applications of the app routing config of a group without duplicates.
+// The applications are required by the include and exclude modes and dropped when the mode is empty
+func validateAppRouting(mode AppRoutingMode, applications []string) ([]string, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case AppRoutingModeInclude, AppRoutingModeExclude:
+	default:
+		return nil, status.Errorf(status.InvalidArgument, "invalid app routing mode %q, expected %s or %s",
+			mode, AppRoutingModeInclude, AppRoutingModeExclude)
+	}
+
+	if len(applications) == 0 {
+		return nil, status.Errorf(status.InvalidArgument, "app routing mode %s requires at least one application", mode)
+	}
+	if len(applications) > maxAppRoutingApplications {
+		return nil, status.Errorf(status.InvalidArgument, "a group can have up to %d app routing applications", maxAppRoutingApplications)
+	}
+
+	validated := make([]string, 0, len(applications))
+	added := make(lookupMap)
+	for _, application := range applications {
+		application = strings.TrimSpace(application)
+		if application == "" || strings.IndexFunc(application, unicode.IsControl) != -1 {
+			return nil, status.Errorf(status.InvalidArgument, "invalid app routing application %q", application)
+		}
+		if _, ok := added[application]; ok {
+			continue
+		}
+		added[application] = struct{}{}
+		validated = append(validated, application)
+	}
+	return validated, nil
+}
+
+// getPeerAppRouting returns the app routing config of the peer merged from its groups, nil when none of them enables
+// it. The include mode wins over the exclude mode, the applications of the groups with the winning mode are merged
+func (a *Account) getPeerAppRouting(peerID string) *AppRouting {
+	applications := make(map[AppRoutingMode]lookupMap)
+	for _, group := range a.Groups {
+		if group.AppRoutingMode == "" || !slices.Contains(group.Peers, peerID) {
+			continue
+		}
+		if applications[group.AppRoutingMode] == nil {
+			applications[group.AppRoutingMode] = make(lookupMap)
+		}
+		for _, application := range group.AppRoutingApplications {
+			applications[group.AppRoutingMode][application] = struct{}{}
+		}
+	}
+
+	mode := AppRoutingModeInclude
+	if applications[mode] == nil {
+		mode = AppRoutingModeExclude
+		if applications[mode] == nil {
+			return nil
+		}
+	}
+
+	appRouting := &AppRouting{Mode: mode}
+	for application := range applications[mode] {
+		appRouting.Applications = append(appRouting.Applications, application)
+	}
+	sort.Strings(appRouting.Applications)
+	return appRouting
+}
+
+func toProtocolAppRouting(appRouting *AppRouting) *proto.AppRoutingConfig {
+	if appRouting == nil {
+		return nil
+	}
+
+	mode := proto.AppRoutingConfig_INCLUDE
+	if appRouting.Mode == AppRoutingModeExclude {
+		mode = proto.AppRoutingConfig_EXCLUDE
+	}
+	return &proto.AppRoutingConfig{
+		Mode:         mode,
+		Applications: appRouting.Applications,
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/proto"
+)
+
+func TestValidateAppRouting(t *testing.T) {
+	applications, err := validateAppRouting(AppRoutingModeInclude, []string{` C:\Apps\app.exe `, `C:\Apps\app.exe`, `C:\Apps\other.exe`})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`C:\Apps\app.exe`, `C:\Apps\other.exe`}, applications, "the applications should be trimmed without duplicates")
+
+	applications, err = validateAppRouting("", []string{`C:\Apps\app.exe`})
+	require.NoError(t, err)
+	assert.Nil(t, applications, "the applications should be dropped without mode")
+
+	testCases := []struct {
+		name         string
+		mode         AppRoutingMode
+		applications []string
+	}{
+		{"unknown mode", "split", []string{`C:\Apps\app.exe`}},
+		{"no applications", AppRoutingModeExclude, nil},
+		{"empty application", AppRoutingModeExclude, []string{" "}},
+		{"control character", AppRoutingModeExclude, []string{"app\n.exe"}},
+		{"too many applications", AppRoutingModeInclude, make([]string, maxAppRoutingApplications+1)},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := validateAppRouting(testCase.mode, testCase.applications)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAccount_getPeerAppRouting(t *testing.T) {
+	account := &Account{
+		Groups: map[string]*Group{
+			"browsers": {ID: "browsers", Peers: []string{"laptop", "desktop"}, AppRoutingMode: AppRoutingModeInclude, AppRoutingApplications: []string{"firefox.exe", "chrome.exe"}},
+			"rdp":      {ID: "rdp", Peers: []string{"laptop"}, AppRoutingMode: AppRoutingModeInclude, AppRoutingApplications: []string{"mstsc.exe", "chrome.exe"}},
+			"games":    {ID: "games", Peers: []string{"laptop", "server"}, AppRoutingMode: AppRoutingModeExclude, AppRoutingApplications: []string{"game.exe"}},
+			"all":      {ID: "all", Peers: []string{"laptop", "desktop", "server", "router"}},
+		},
+	}
+
+	assert.Equal(t, &AppRouting{Mode: AppRoutingModeInclude, Applications: []string{"chrome.exe", "firefox.exe", "mstsc.exe"}},
+		account.getPeerAppRouting("laptop"), "the include mode should win and merge the applications")
+	assert.Equal(t, &AppRouting{Mode: AppRoutingModeExclude, Applications: []string{"game.exe"}}, account.getPeerAppRouting("server"))
+	assert.Nil(t, account.getPeerAppRouting("router"), "groups without app routing should be ignored")
+
+	assert.Nil(t, toProtocolAppRouting(nil))
+	assert.Equal(t, &proto.AppRoutingConfig{Mode: proto.AppRoutingConfig_EXCLUDE, Applications: []string{"game.exe"}},
+		toProtocolAppRouting(account.getPeerAppRouting("server")))
+}
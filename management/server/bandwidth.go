@@ -0,0 +1,52 @@
+package server
+
+import (
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+// getBandwidthLimits returns the limit in kbit/s of the traffic between the peer and each of the given peers, keyed by
+// peer ID. The limit of a pair of peers is the lowest limit set on any of the two peers or on any of their groups
+func (a *Account) getBandwidthLimits(peerID string, peers []*nbpeer.Peer) map[string]uint64 {
+	peerLimits := a.getPeersBandwidthLimits()
+	if len(peerLimits) == 0 {
+		return nil
+	}
+
+	limits := make(map[string]uint64)
+	for _, p := range peers {
+		limit := minBandwidthLimit(peerLimits[peerID], peerLimits[p.ID])
+		if limit != 0 {
+			limits[p.ID] = limit
+		}
+	}
+	return limits
+}
+
+// getPeersBandwidthLimits returns the lowest limit set on each peer or on its groups, keyed by peer ID.
+// Peers without limit are omitted
+func (a *Account) getPeersBandwidthLimits() map[string]uint64 {
+	limits := make(map[string]uint64)
+	for id, peer := range a.Peers {
+		if peer.BandwidthLimit != 0 {
+			limits[id] = peer.BandwidthLimit
+		}
+	}
+
+	for _, group := range a.Groups {
+		if group.BandwidthLimit == 0 {
+			continue
+		}
+		for _, id := range group.Peers {
+			limits[id] = minBandwidthLimit(limits[id], group.BandwidthLimit)
+		}
+	}
+	return limits
+}
+
+// minBandwidthLimit returns the lowest of two limits, where 0 means no limit
+func minBandwidthLimit(a, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
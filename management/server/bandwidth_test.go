@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestAccount_getBandwidthLimits(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"backup":  {ID: "backup", BandwidthLimit: 100_000},
+			"router":  {ID: "router"},
+			"laptop":  {ID: "laptop"},
+			"desktop": {ID: "desktop"},
+		},
+		Groups: map[string]*Group{
+			"branch": {ID: "branch", Peers: []string{"router", "desktop"}, BandwidthLimit: 20_000},
+			"slow":   {ID: "slow", Peers: []string{"desktop"}, BandwidthLimit: 5_000},
+			"all":    {ID: "all", Peers: []string{"backup", "router", "laptop", "desktop"}},
+		},
+	}
+
+	peers := func(ids ...string) []*nbpeer.Peer {
+		var result []*nbpeer.Peer
+		for _, id := range ids {
+			result = append(result, account.Peers[id])
+		}
+		return result
+	}
+
+	limits := account.getBandwidthLimits("backup", peers("router", "laptop", "desktop"))
+	assert.Equal(t, map[string]uint64{"router": 20_000, "laptop": 100_000, "desktop": 5_000}, limits,
+		"the lowest limit of both peers should be applied")
+
+	limits = account.getBandwidthLimits("laptop", peers("backup", "router"))
+	assert.Equal(t, map[string]uint64{"backup": 100_000, "router": 20_000}, limits)
+
+	account.Peers["backup"].BandwidthLimit = 0
+	account.Groups["branch"].BandwidthLimit = 0
+	account.Groups["slow"].BandwidthLimit = 0
+	assert.Nil(t, account.getBandwidthLimits("laptop", peers("backup", "router")), "no limits should be returned without limits")
+}
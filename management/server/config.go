@@ -4,6 +4,9 @@ import (
 	"net/url"
 
 	"github.com/FlintyLemming/netbird/management/server/idp"
+	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	signalServer "github.com/FlintyLemming/netbird/signal/server"
+	"github.com/FlintyLemming/netbird/tracing"
 	"github.com/FlintyLemming/netbird/util"
 )
 
@@ -34,6 +37,17 @@ type Config struct {
 	Stuns      []*Host
 	TURNConfig *TURNConfig
 	Signal     *Host
+	// SignalPresenceToken is the presence token of the Signal service used to look up which peers are connected to it.
+	// Empty disables the lookups
+	SignalPresenceToken string
+	// EmbeddedSignal serves the Signal service on the Management gRPC server, the clients multiplex both services over
+	// the Management connection
+	EmbeddedSignal bool
+	// SignalRateLimits limits the messages forwarded by the embedded Signal service, nil uses the defaults
+	SignalRateLimits *signalServer.RateLimitConfig
+	// Relay is the config of the TURN relay embedded in the single-binary server, it is ignored by the Management
+	// service running alone
+	Relay *RelayConfig
 
 	Datadir                string
 	DataStoreEncryptionKey string
@@ -47,6 +61,72 @@ type Config struct {
 	PKCEAuthorizationFlow *PKCEAuthorizationFlow
 
 	StoreConfig StoreConfig
+
+	// Tracing is the config of the export of the OpenTelemetry spans, it is read from the standard OTEL_* environment
+	// variables when absent
+	Tracing *tracing.Config
+
+	// IdentityProviders are the identity providers accepted besides the one of HttpConfig, e.g. a provider for the
+	// staff and another one for the contractors
+	IdentityProviders []IdentityProviderConfig
+
+	// GitOps reconciles the groups, policies and routes of an account with the YAML files of a git repository or of
+	// a local directory, nil disables the sync
+	GitOps *GitOpsConfig
+}
+
+// GitOpsConfig is the config of the sync of an account with a declarative config
+type GitOpsConfig struct {
+	// Repository is the URL of the git repository, the Directory is read from the local filesystem when it is empty
+	Repository string
+	// Branch is the branch of the repository, the default branch when empty
+	Branch string
+	// Directory is the directory of the YAML files, relative to the root of the repository when Repository is set
+	Directory string
+	// AccountID is the account reconciled with the files, optional when the store has a single account
+	AccountID string
+	// Interval is the interval between the syncs, DefaultGitOpsInterval when 0
+	Interval util.Duration
+	// DryRun only reports the drift between the account and the files without changing the account
+	DryRun bool
+}
+
+// IdentityProviderConfig is an additional identity provider whose users can log in
+type IdentityProviderConfig struct {
+	// Name identifies the provider in the users API and in the logs
+	Name string
+	// Issuer identifies principal that issued the JWT (iss in JWT)
+	Issuer string
+	// Audience identifies the recipients that the JWT is intended for (aud in JWT)
+	Audience string
+	// UserIDClaim is the name of the claim that used as user ID, sub when empty
+	UserIDClaim string
+	// KeysLocation is a location of JWT key set containing the public keys used to verify JWT
+	KeysLocation string
+	// IdpSignKeyRefreshEnabled refreshes the key set when its cache expires
+	IdpSignKeyRefreshEnabled bool
+	// AutoGroups are the names of the groups the users of the provider are added to on every login, they are
+	// created when missing
+	AutoGroups []string
+}
+
+// GetIdentityProviderIssuers returns the validator config of the additional identity providers
+func (c Config) GetIdentityProviderIssuers() []jwtclaims.IssuerConfig {
+	issuers := make([]jwtclaims.IssuerConfig, 0, len(c.IdentityProviders))
+	for _, provider := range c.IdentityProviders {
+		userIDClaim := provider.UserIDClaim
+		if userIDClaim == "" {
+			userIDClaim = jwtclaims.UserIDClaim
+		}
+		issuers = append(issuers, jwtclaims.IssuerConfig{
+			Issuer:            provider.Issuer,
+			Audiences:         []string{provider.Audience},
+			KeysLocation:      provider.KeysLocation,
+			KeyRefreshEnabled: provider.IdpSignKeyRefreshEnabled,
+			UserIDClaim:       userIDClaim,
+		})
+	}
+	return issuers
 }
 
 // GetAuthAudiences returns the audience from the http config and device authorization flow config
@@ -60,6 +140,22 @@ func (c Config) GetAuthAudiences() []string {
 	return audiences
 }
 
+// RelayConfig is a config of the TURN relay embedded in the single-binary server
+type RelayConfig struct {
+	// Port is the UDP port the relay listens on
+	Port int
+	// PublicIP is the IP the peers reach the relay and its relayed connections on
+	PublicIP string
+	// Realm of the TURN credentials
+	Realm string
+	// MinPort and MaxPort bound the ports of the relayed connections, 0 allocates any port
+	MinPort uint16
+	MaxPort uint16
+	// PeerBandwidthLimit is the limit in kbit/s of the traffic each peer relays, counting both directions. 0 disables
+	// the limit
+	PeerBandwidthLimit uint64
+}
+
 // TURNConfig is a config of the TURNCredentialsManager
 type TURNConfig struct {
 	TimeBasedCredentials bool
@@ -71,6 +167,9 @@ type TURNConfig struct {
 // HttpServerConfig is a config of the HTTP Management service server
 type HttpServerConfig struct {
 	LetsEncryptDomain string
+	// LetsEncryptDNSProvider is the DNS provider fulfilling the DNS-01 challenge of the LetsEncryptDomain, the HTTP-01
+	// challenge is used when it is empty
+	LetsEncryptDNSProvider string
 	// CertFile is the location of the certificate
 	CertFile string
 	// CertKey is the location of the certificate private key
@@ -87,6 +186,8 @@ type HttpServerConfig struct {
 	OIDCConfigEndpoint string
 	// IdpSignKeyRefreshEnabled identifies the signing key is currently being rotated or not
 	IdpSignKeyRefreshEnabled bool
+	// EmbeddedDashboard serves a minimal dashboard under /ui/ for the deployments without the dashboard project
+	EmbeddedDashboard bool
 }
 
 // Host represents a Wiretrustee host (e.g. STUN, TURN, Signal)
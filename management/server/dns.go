@@ -2,6 +2,8 @@ package server
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
 
 	"github.com/miekg/dns"
@@ -22,6 +24,18 @@ type lookupMap map[string]struct{}
 type DNSSettings struct {
 	// DisabledManagementGroups groups whose DNS management is disabled
 	DisabledManagementGroups []string `gorm:"serializer:json"`
+	// SearchDomainGroups assign search domains to the peers of groups
+	SearchDomainGroups []SearchDomainGroup `gorm:"serializer:json"`
+}
+
+// SearchDomainGroup assigns search domains to the peers of a list of groups
+type SearchDomainGroup struct {
+	// Domains are the search domains added to the DNS configuration of the peers
+	Domains []string
+	// Groups are the IDs of the peer groups receiving the search domains
+	Groups []string
+	// Priority orders the search domains of a peer, the domains with the lowest value come first
+	Priority int
 }
 
 // Copy returns a copy of the DNS settings
@@ -30,9 +44,25 @@ func (d DNSSettings) Copy() DNSSettings {
 		DisabledManagementGroups: make([]string, len(d.DisabledManagementGroups)),
 	}
 	copy(settings.DisabledManagementGroups, d.DisabledManagementGroups)
+
+	for _, searchDomainGroup := range d.SearchDomainGroups {
+		settings.SearchDomainGroups = append(settings.SearchDomainGroups, searchDomainGroup.Copy())
+	}
 	return settings
 }
 
+// Copy returns a copy of the search domain group
+func (g SearchDomainGroup) Copy() SearchDomainGroup {
+	searchDomainGroup := SearchDomainGroup{
+		Domains:  make([]string, len(g.Domains)),
+		Groups:   make([]string, len(g.Groups)),
+		Priority: g.Priority,
+	}
+	copy(searchDomainGroup.Domains, g.Domains)
+	copy(searchDomainGroup.Groups, g.Groups)
+	return searchDomainGroup
+}
+
 // GetDNSSettings validates a user role and returns the DNS settings for the provided account ID
 func (am *DefaultAccountManager) GetDNSSettings(accountID string, userID string) (*DNSSettings, error) {
 	unlock := am.Store.AcquireAccountLock(accountID)
@@ -85,6 +115,10 @@ func (am *DefaultAccountManager) SaveDNSSettings(accountID string, userID string
 		}
 	}
 
+	if err = validateSearchDomainGroups(dnsSettingsToSave.SearchDomainGroups, account.Groups); err != nil {
+		return err
+	}
+
 	oldSettings := account.DNSSettings.Copy()
 	account.DNSSettings = dnsSettingsToSave.Copy()
 
@@ -107,13 +141,39 @@ func (am *DefaultAccountManager) SaveDNSSettings(accountID string, userID string
 		am.StoreEvent(userID, accountID, accountID, activity.GroupRemovedFromDisabledManagementGroups, meta)
 	}
 
+	if !reflect.DeepEqual(oldSettings.SearchDomainGroups, account.DNSSettings.SearchDomainGroups) {
+		am.StoreEvent(userID, accountID, accountID, activity.DNSSearchDomainGroupsUpdated, nil)
+	}
+
 	am.updateAccountPeers(account)
 
 	return nil
 }
 
+// validateSearchDomainGroups checks that the search domain groups assign valid domains to existing groups
+func validateSearchDomainGroups(searchDomainGroups []SearchDomainGroup, groups map[string]*Group) error {
+	for _, searchDomainGroup := range searchDomainGroups {
+		if len(searchDomainGroup.Domains) == 0 {
+			return status.Errorf(status.InvalidArgument, "search domain group should have at least one domain")
+		}
+		for _, domain := range searchDomainGroup.Domains {
+			if err := validateDomain(domain); err != nil {
+				return status.Errorf(status.InvalidArgument, "search domain group got an invalid domain: %s %q", domain, err)
+			}
+		}
+
+		if err := validateGroups(searchDomainGroup.Groups, groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func toProtocolDNSConfig(update nbdns.Config) *proto.DNSConfig {
-	protoUpdate := &proto.DNSConfig{ServiceEnable: update.ServiceEnable}
+	protoUpdate := &proto.DNSConfig{
+		ServiceEnable: update.ServiceEnable,
+		SearchDomains: update.SearchDomains,
+	}
 
 	for _, zone := range update.CustomZones {
 		protoZone := &proto.CustomZone{Domain: zone.Domain}
@@ -134,6 +194,8 @@ func toProtocolDNSConfig(update nbdns.Config) *proto.DNSConfig {
 			Primary:              nsGroup.Primary,
 			Domains:              nsGroup.Domains,
 			SearchDomainsEnabled: nsGroup.SearchDomainsEnabled,
+			ForwardViaRoutes:     nsGroup.ForwardViaRoutes,
+			Priority:             int64(nsGroup.Priority),
 		}
 		for _, ns := range nsGroup.NameServers {
 			protoNS := &proto.NameServer{
@@ -174,6 +236,10 @@ func getPeersCustomZone(account *Account, dnsDomain string) nbdns.CustomZone {
 		})
 	}
 
+	customZone.Records = append(customZone.Records, getPeerAliasRecords(account, dnsDomain)...)
+	customZone.Records = append(customZone.Records, getPeerServiceRecords(account, dnsDomain)...)
+	customZone.Records = append(customZone.Records, getPeerLabelRecords(account, dnsDomain)...)
+
 	return customZone
 }
 
@@ -197,9 +263,49 @@ func getPeerNSGroups(account *Account, peerID string) []*nbdns.NameServerGroup {
 		}
 	}
 
+	// the groups are stored in a map, they are sorted to send the same order on every update
+	sort.Slice(peerNSGroups, func(i, j int) bool {
+		if peerNSGroups[i].Priority != peerNSGroups[j].Priority {
+			return peerNSGroups[i].Priority < peerNSGroups[j].Priority
+		}
+		return peerNSGroups[i].ID < peerNSGroups[j].ID
+	})
+
 	return peerNSGroups
 }
 
+// getPeerSearchDomains returns the search domains assigned to the groups of the peer, ordered by priority
+func getPeerSearchDomains(account *Account, peerID string) []string {
+	groupList := account.getPeerGroups(peerID)
+
+	var searchDomainGroups []SearchDomainGroup
+	for _, searchDomainGroup := range account.DNSSettings.SearchDomainGroups {
+		for _, gID := range searchDomainGroup.Groups {
+			if _, found := groupList[gID]; found {
+				searchDomainGroups = append(searchDomainGroups, searchDomainGroup)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(searchDomainGroups, func(i, j int) bool {
+		return searchDomainGroups[i].Priority < searchDomainGroups[j].Priority
+	})
+
+	var searchDomains []string
+	added := make(map[string]struct{})
+	for _, searchDomainGroup := range searchDomainGroups {
+		for _, domain := range searchDomainGroup.Domains {
+			if _, ok := added[domain]; ok {
+				continue
+			}
+			added[domain] = struct{}{}
+			searchDomains = append(searchDomains, domain)
+		}
+	}
+	return searchDomains
+}
+
 // peerIsNameserver returns true if the peer is a nameserver for a nsGroup
 func peerIsNameserver(peer *nbpeer.Peer, nsGroup *nbdns.NameServerGroup) bool {
 	for _, ns := range nsGroup.NameServers {
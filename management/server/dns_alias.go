@@ -0,0 +1,140 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// maxPeerDNSAliases is the maximum number of DNS aliases of a peer
+const maxPeerDNSAliases = 10
+
+// dnsLabelMatcher matches a lower case DNS label of letters, numbers and hyphens without leading or trailing hyphen
+var dnsLabelMatcher = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// validatePeerDNSAliases returns the lower cased aliases of the peer without duplicates. Each alias has to be a single
+// DNS label not used by the other peers of the account, as label or alias.
+func validatePeerDNSAliases(account *Account, peerID string, aliases []string) ([]string, error) {
+	if len(aliases) > maxPeerDNSAliases {
+		return nil, status.Errorf(status.InvalidArgument, "a peer can have up to %d DNS aliases", maxPeerDNSAliases)
+	}
+
+	taken := make(lookupMap)
+	for _, peer := range account.Peers {
+		if peer.ID == peerID {
+			continue
+		}
+		taken[peer.DNSLabel] = struct{}{}
+		for _, alias := range peer.DNSAliases {
+			taken[alias] = struct{}{}
+		}
+	}
+
+	peerLabel := ""
+	if peer := account.GetPeer(peerID); peer != nil {
+		peerLabel = peer.DNSLabel
+	}
+
+	validated := make([]string, 0, len(aliases))
+	added := make(lookupMap)
+	for _, alias := range aliases {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if !dnsLabelMatcher.MatchString(alias) {
+			return nil, status.Errorf(status.InvalidArgument, "DNS alias %q should be a single label of letters, numbers and hyphens", alias)
+		}
+		if _, ok := taken[alias]; ok {
+			return nil, status.Errorf(status.AlreadyExists, "DNS alias %q is already used by another peer", alias)
+		}
+		if _, ok := added[alias]; ok || alias == peerLabel {
+			continue
+		}
+		added[alias] = struct{}{}
+		validated = append(validated, alias)
+	}
+	return validated, nil
+}
+
+// validateGroupDNSSuffix returns the lower cased suffix of a group, made of one or more DNS labels. An empty suffix
+// disables the group names.
+func validateGroupDNSSuffix(suffix string) (string, error) {
+	suffix = strings.Trim(strings.ToLower(strings.TrimSpace(suffix)), ".")
+	if suffix == "" {
+		return "", nil
+	}
+	if _, ok := dns.IsDomainName(suffix); !ok {
+		return "", status.Errorf(status.InvalidArgument, "invalid group DNS suffix %q", suffix)
+	}
+	for _, label := range dns.SplitDomainName(suffix) {
+		if !dnsLabelMatcher.MatchString(label) {
+			return "", status.Errorf(status.InvalidArgument, "group DNS suffix %q should consist of letters, numbers and hyphens", suffix)
+		}
+	}
+	return suffix, nil
+}
+
+// getPeerAliasRecords returns the CNAME records of the additional names of the peers: their aliases and the names
+// under the DNS suffixes of their groups, i.e. <label>.<suffix> and <alias>.<suffix>. The records point to the peer
+// FQDN. A name already taken by a peer label or by another peer is skipped, the peers are ordered by ID so the same
+// peer keeps a disputed name on every update.
+func getPeerAliasRecords(account *Account, dnsDomain string) []nbdns.SimpleRecord {
+	taken := make(lookupMap)
+	peers := make([]*nbpeer.Peer, 0, len(account.Peers))
+	for _, peer := range account.Peers {
+		if peer.DNSLabel == "" {
+			continue
+		}
+		taken[dns.Fqdn(peer.DNSLabel+"."+dnsDomain)] = struct{}{}
+		peers = append(peers, peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+
+	suffixes := make(map[string][]string)
+	for _, group := range account.Groups {
+		if group.DNSSuffix == "" {
+			continue
+		}
+		for _, peerID := range group.Peers {
+			suffixes[peerID] = append(suffixes[peerID], group.DNSSuffix)
+		}
+	}
+
+	var records []nbdns.SimpleRecord
+	for _, peer := range peers {
+		target := dns.Fqdn(peer.DNSLabel + "." + dnsDomain)
+
+		labels := append([]string{peer.DNSLabel}, peer.DNSAliases...)
+		names := append([]string(nil), peer.DNSAliases...)
+		peerSuffixes := suffixes[peer.ID]
+		sort.Strings(peerSuffixes)
+		for _, suffix := range peerSuffixes {
+			for _, label := range labels {
+				names = append(names, label+"."+suffix)
+			}
+		}
+
+		for _, name := range names {
+			fqdn := dns.Fqdn(name + "." + dnsDomain)
+			if _, ok := taken[fqdn]; ok {
+				continue
+			}
+			taken[fqdn] = struct{}{}
+
+			records = append(records, nbdns.SimpleRecord{
+				Name:  fqdn,
+				Type:  int(dns.TypeCNAME),
+				Class: nbdns.DefaultClass,
+				TTL:   defaultTTL,
+				RData: target,
+			})
+		}
+	}
+	return records
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+func TestPeerDNSAliases(t *testing.T) {
+	am, err := createDNSManager(t)
+	require.NoError(t, err)
+
+	account, err := initTestDNSAccount(t, am)
+	require.NoError(t, err)
+
+	peer1, err := account.FindPeerByPubKey(dnsPeer1Key)
+	require.NoError(t, err)
+	peer2, err := account.FindPeerByPubKey(dnsPeer2Key)
+	require.NoError(t, err)
+
+	update := peer1.Copy()
+	update.DNSAliases = []string{"DB", "db", "cache", peer1.DNSLabel}
+	updated, err := am.UpdatePeer(account.Id, dnsAdminUserID, update)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache"}, updated.DNSAliases, "the aliases should be lower cased without duplicates")
+
+	for _, aliases := range [][]string{{"db"}, {peer1.DNSLabel}, {"-db"}, {"db.prod"}, strings.Split("a,b,c,d,e,f,g,h,i,j,k", ",")} {
+		update = peer2.Copy()
+		update.DNSAliases = aliases
+		_, err = am.UpdatePeer(account.Id, dnsAdminUserID, update)
+		require.Error(t, err, "aliases %v should be rejected", aliases)
+	}
+
+	update = peer2.Copy()
+	update.DNSAliases = []string{"db"}
+	_, err = am.UpdatePeer(account.Id, dnsAdminUserID, update)
+	sErr, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, status.AlreadyExists, sErr.Type(), "an alias of another peer should be rejected")
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	labels := account.getPeerDNSLabels()
+	assert.Contains(t, labels, "db", "new peer labels shouldn't shadow the aliases")
+
+	group := account.Groups[dnsGroup1ID].Copy()
+	group.DNSSuffix = "Prod.EU."
+	require.NoError(t, am.SaveGroup(account.Id, dnsAdminUserID, group))
+	assert.Equal(t, "prod.eu", group.DNSSuffix)
+
+	group = account.Groups[dnsGroup2ID].Copy()
+	group.DNSSuffix = "prod_eu"
+	require.Error(t, am.SaveGroup(account.Id, dnsAdminUserID, group), "invalid suffixes should be rejected")
+
+	networkMap, err := am.GetNetworkMap(peer2.ID)
+	require.NoError(t, err)
+	require.Len(t, networkMap.DNSConfig.CustomZones, 1)
+
+	cnames := make(map[string]string)
+	for _, record := range networkMap.DNSConfig.CustomZones[0].Records {
+		if record.Type == int(dns.TypeCNAME) {
+			cnames[record.Name] = record.RData
+		}
+	}
+
+	target := dns.Fqdn(peer1.DNSLabel + ".netbird.test")
+	assert.Equal(t, map[string]string{
+		"db.netbird.test.":                        target,
+		"cache.netbird.test.":                     target,
+		peer1.DNSLabel + ".prod.eu.netbird.test.": target,
+		"db.prod.eu.netbird.test.":                target,
+		"cache.prod.eu.netbird.test.":             target,
+	}, cnames)
+}
+
+func TestGetPeerAliasRecords_Conflicts(t *testing.T) {
+	account := newAccountWithId("account", "user", "example.com")
+	account.Peers["peer-a"] = &nbpeer.Peer{ID: "peer-a", DNSLabel: "a", DNSAliases: []string{"web"}}
+	account.Peers["peer-b"] = &nbpeer.Peer{ID: "peer-b", DNSLabel: "b", DNSAliases: []string{"web", "a"}}
+	account.Groups["prod"] = &Group{ID: "prod", Name: "prod", DNSSuffix: "prod", Peers: []string{"peer-a", "peer-b"}}
+
+	records := getPeerAliasRecords(account, "netbird.test")
+
+	names := make(map[string]string)
+	for _, record := range records {
+		_, exists := names[record.Name]
+		require.False(t, exists, "the name %s should have a single record", record.Name)
+		names[record.Name] = record.RData
+	}
+
+	assert.Equal(t, "a.netbird.test.", names["web.netbird.test."], "the first peer by ID should keep a disputed alias")
+	assert.NotContains(t, names, "a.netbird.test.", "an alias shouldn't shadow a peer label")
+	assert.Equal(t, "a.netbird.test.", names["a.prod.netbird.test."])
+	assert.Equal(t, "b.netbird.test.", names["b.prod.netbird.test."])
+}
@@ -100,6 +100,29 @@ func TestSaveDNSSettings(t *testing.T) {
 			inputSettings: nil,
 			shouldFail:    true,
 		},
+		{
+			name:   "Saving Search Domain Groups Should Be OK",
+			userID: dnsAdminUserID,
+			inputSettings: &DNSSettings{
+				SearchDomainGroups: []SearchDomainGroup{{Domains: []string{"corp.example.com"}, Groups: []string{dnsGroup1ID}}},
+			},
+		},
+		{
+			name:   "Should Not Update Settings If Search Domain Is Invalid",
+			userID: dnsAdminUserID,
+			inputSettings: &DNSSettings{
+				SearchDomainGroups: []SearchDomainGroup{{Domains: []string{"-corp"}, Groups: []string{dnsGroup1ID}}},
+			},
+			shouldFail: true,
+		},
+		{
+			name:   "Should Not Update Settings If Search Domain Group Has No Domain",
+			userID: dnsAdminUserID,
+			inputSettings: &DNSSettings{
+				SearchDomainGroups: []SearchDomainGroup{{Groups: []string{dnsGroup1ID}}},
+			},
+			shouldFail: true,
+		},
 		{
 			name:   "Should Not Update Settings If Group Is Invalid",
 			userID: dnsAdminUserID,
@@ -136,6 +159,8 @@ func TestSaveDNSSettings(t *testing.T) {
 
 			require.ElementsMatchf(t, testCase.inputSettings.DisabledManagementGroups, updatedAccount.DNSSettings.DisabledManagementGroups,
 				"resulting DNS settings should match input")
+			require.Equal(t, testCase.inputSettings.SearchDomainGroups, updatedAccount.DNSSettings.SearchDomainGroups,
+				"resulting search domain groups should match input")
 
 		})
 	}
@@ -186,6 +211,54 @@ func TestGetNetworkMap_DNSConfigSync(t *testing.T) {
 	require.Len(t, peer2AccountDNSConfig.DNSConfig.NameServerGroups, 1, "updated DNS config should have 1 nameserver groups since peer 2 is part of the group All")
 }
 
+func TestGetNetworkMap_DNSPriorities(t *testing.T) {
+	am, err := createDNSManager(t)
+	require.NoError(t, err)
+
+	account, err := initTestDNSAccount(t, am)
+	require.NoError(t, err)
+
+	peer2, err := account.FindPeerByPubKey(dnsPeer2Key)
+	require.NoError(t, err)
+
+	allGroup, err := account.GetGroupAll()
+	require.NoError(t, err)
+
+	for id, priority := range map[string]int{"ns-low": 20, "ns-high": 10} {
+		account.NameServerGroups[id] = &dns.NameServerGroup{
+			ID:   id,
+			Name: id,
+			NameServers: []dns.NameServer{{
+				IP:     netip.MustParseAddr("1.1.1.1"),
+				NSType: dns.UDPNameServerType,
+				Port:   dns.DefaultDNSPort,
+			}},
+			Domains:  []string{"example.com"},
+			Enabled:  true,
+			Groups:   []string{allGroup.ID},
+			Priority: priority,
+		}
+	}
+
+	account.DNSSettings.SearchDomainGroups = []SearchDomainGroup{
+		{Domains: []string{"second.example.com", "first.example.com"}, Groups: []string{allGroup.ID}, Priority: 20},
+		{Domains: []string{"first.example.com"}, Groups: []string{allGroup.ID}, Priority: 10},
+		{Domains: []string{"other.example.com"}, Groups: []string{dnsGroup1ID}},
+	}
+	require.NoError(t, am.Store.SaveAccount(account))
+
+	networkMap, err := am.GetNetworkMap(peer2.ID)
+	require.NoError(t, err)
+
+	var nsGroupIDs []string
+	for _, nsGroup := range networkMap.DNSConfig.NameServerGroups {
+		nsGroupIDs = append(nsGroupIDs, nsGroup.ID)
+	}
+	require.Equal(t, []string{dnsNSGroup1, "ns-high", "ns-low"}, nsGroupIDs, "nameserver groups should be ordered by priority")
+	require.Equal(t, []string{"first.example.com", "second.example.com"}, networkMap.DNSConfig.SearchDomains,
+		"search domains of the peer groups should be ordered by priority without duplicates")
+}
+
 func createDNSManager(t *testing.T) (*DefaultAccountManager, error) {
 	t.Helper()
 	store, err := createDNSStore(t)
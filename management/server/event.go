@@ -40,7 +40,7 @@ func (am *DefaultAccountManager) StoreEvent(initiatorID, targetID, accountID str
 	meta map[string]any) {
 
 	go func() {
-		_, err := am.eventStore.Save(&activity.Event{
+		event, err := am.eventStore.Save(&activity.Event{
 			Timestamp:   time.Now().UTC(),
 			Activity:    activityID,
 			InitiatorID: initiatorID,
@@ -51,7 +51,14 @@ func (am *DefaultAccountManager) StoreEvent(initiatorID, targetID, accountID str
 		if err != nil {
 			// todo add metric
 			log.Errorf("received an error while storing an activity event, error: %s", err)
+			return
 		}
+
+		am.accountEvents.Publish(accountID, &AccountEvent{
+			Type:      AccountEventActivity,
+			Timestamp: event.Timestamp,
+			Activity:  event,
+		})
 	}()
 
 }
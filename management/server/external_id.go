@@ -0,0 +1,132 @@
+package server
+
+import (
+	"unicode/utf8"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+// maxExternalIDLength is the maximum number of characters of the external ID of an object
+const maxExternalIDLength = 255
+
+// FindGroupByExternalID returns the group with the external ID or nil when there is none
+func (a *Account) FindGroupByExternalID(externalID string) *Group {
+	if externalID == "" {
+		return nil
+	}
+	for _, group := range a.Groups {
+		if group.ExternalID == externalID {
+			return group
+		}
+	}
+	return nil
+}
+
+// FindPolicyByExternalID returns the policy with the external ID or nil when there is none
+func (a *Account) FindPolicyByExternalID(externalID string) *Policy {
+	if externalID == "" {
+		return nil
+	}
+	for _, policy := range a.Policies {
+		if policy.ExternalID == externalID {
+			return policy
+		}
+	}
+	return nil
+}
+
+// FindRouteByExternalID returns the route with the external ID or nil when there is none
+func (a *Account) FindRouteByExternalID(externalID string) *route.Route {
+	if externalID == "" {
+		return nil
+	}
+	for _, r := range a.Routes {
+		if r.ExternalID == externalID {
+			return r
+		}
+	}
+	return nil
+}
+
+// FindSetupKeyByExternalID returns the setup key with the external ID or nil when there is none
+func (a *Account) FindSetupKeyByExternalID(externalID string) *SetupKey {
+	if externalID == "" {
+		return nil
+	}
+	for _, key := range a.SetupKeys {
+		if key.ExternalID == externalID {
+			return key
+		}
+	}
+	return nil
+}
+
+// FindPeerByExternalID returns the peer with the external ID or nil when there is none
+func (a *Account) FindPeerByExternalID(externalID string) *nbpeer.Peer {
+	if externalID == "" {
+		return nil
+	}
+	for _, peer := range a.Peers {
+		if peer.ExternalID == externalID {
+			return peer
+		}
+	}
+	return nil
+}
+
+// validateExternalID checks the length of the external ID of an object and that no other object of the same kind,
+// found by the external ID, holds it
+func validateExternalID(kind, externalID, objectID, holderID string) error {
+	if externalID == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(externalID) > maxExternalIDLength {
+		return status.Errorf(status.InvalidArgument, "%s external ID should be at most %d characters", kind, maxExternalIDLength)
+	}
+	if holderID != "" && holderID != objectID {
+		return status.Errorf(status.AlreadyExists, "%s with external ID %s already exists", kind, externalID)
+	}
+	return nil
+}
+
+func (a *Account) validateGroupExternalID(group *Group) error {
+	var holderID string
+	if holder := a.FindGroupByExternalID(group.ExternalID); holder != nil {
+		holderID = holder.ID
+	}
+	return validateExternalID("group", group.ExternalID, group.ID, holderID)
+}
+
+func (a *Account) validatePolicyExternalID(policy *Policy) error {
+	var holderID string
+	if holder := a.FindPolicyByExternalID(policy.ExternalID); holder != nil {
+		holderID = holder.ID
+	}
+	return validateExternalID("policy", policy.ExternalID, policy.ID, holderID)
+}
+
+func (a *Account) validateRouteExternalID(r *route.Route) error {
+	var holderID string
+	if holder := a.FindRouteByExternalID(r.ExternalID); holder != nil {
+		holderID = holder.ID
+	}
+	return validateExternalID("route", r.ExternalID, r.ID, holderID)
+}
+
+func (a *Account) validateSetupKeyExternalID(key *SetupKey) error {
+	var holderID string
+	if holder := a.FindSetupKeyByExternalID(key.ExternalID); holder != nil {
+		holderID = holder.Id
+	}
+	return validateExternalID("setup key", key.ExternalID, key.Id, holderID)
+}
+
+func (a *Account) validatePeerExternalID(peer *nbpeer.Peer) error {
+	var holderID string
+	if holder := a.FindPeerByExternalID(peer.ExternalID); holder != nil {
+		holderID = holder.ID
+	}
+	return validateExternalID("peer", peer.ExternalID, peer.ID, holderID)
+}
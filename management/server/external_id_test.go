@@ -0,0 +1,52 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+func TestExternalIDs(t *testing.T) {
+	am, err := createManager(t)
+	require.NoError(t, err)
+
+	account, err := initTestGroupAccount(am)
+	require.NoError(t, err)
+
+	group := &Group{ID: "grp-external", Name: "External", Issued: GroupIssuedAPI, ExternalID: "tf-group"}
+	require.NoError(t, am.SaveGroup(account.Id, groupAdminUserID, group))
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	found := account.FindGroupByExternalID("tf-group")
+	require.NotNil(t, found)
+	assert.Equal(t, group.ID, found.ID)
+	assert.Nil(t, account.FindGroupByExternalID(""), "an empty external ID shouldn't match objects without one")
+
+	// saving the holder again keeps its external ID valid
+	require.NoError(t, am.SaveGroup(account.Id, groupAdminUserID, found.Copy()))
+
+	other := &Group{ID: "grp-other", Name: "Other", Issued: GroupIssuedAPI, ExternalID: "tf-group"}
+	err = am.SaveGroup(account.Id, groupAdminUserID, other)
+	sErr, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, status.AlreadyExists, sErr.Type(), "an external ID of another group should be rejected")
+
+	other.ExternalID = strings.Repeat("a", maxExternalIDLength+1)
+	err = am.SaveGroup(account.Id, groupAdminUserID, other)
+	sErr, ok = status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, status.InvalidArgument, sErr.Type(), "a too long external ID should be rejected")
+
+	key, err := am.CreateSetupKey(account.Id, "terraform", SetupKeyReusable, time.Hour, nil, 0, groupAdminUserID, false, "tf-key", "")
+	require.NoError(t, err)
+	assert.Equal(t, "tf-key", key.ExternalID)
+
+	_, err = am.CreateSetupKey(account.Id, "terraform", SetupKeyReusable, time.Hour, nil, 0, groupAdminUserID, false, "tf-key", "")
+	require.Error(t, err, "a second setup key with the same external ID should be rejected")
+}
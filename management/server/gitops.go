@@ -0,0 +1,672 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/xid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+// gitOpsExternalIDPrefix marks the groups, policies and routes managed by the GitOps sync in their external ID. The
+// other objects of the account are left untouched by the sync
+const gitOpsExternalIDPrefix = "gitops:"
+
+// DeclarativeConfig is the desired state of the groups, policies and routes of an account, read from the YAML files
+// of a directory. The objects are identified by their name and reference the groups by name, so that the files can
+// be written by hand and reviewed, unlike the account bundles of the export command keyed by the generated IDs
+//
+//	groups:
+//	  - name: dev
+//	policies:
+//	  - name: dev to servers
+//	    rules:
+//	      - sources: [dev]
+//	        destinations: [servers]
+//	        protocol: tcp
+//	        ports: ["22"]
+//	routes:
+//	  - network_id: office
+//	    network: 10.0.0.0/24
+//	    peer_groups: [office routers]
+//	    groups: [dev]
+type DeclarativeConfig struct {
+	Groups   []DeclarativeGroup  `yaml:"groups"`
+	Policies []DeclarativePolicy `yaml:"policies"`
+	Routes   []DeclarativeRoute  `yaml:"routes"`
+}
+
+// DeclarativeGroup is a group of the declarative config
+type DeclarativeGroup struct {
+	Name string `yaml:"name"`
+	// Peers are the DNS labels or the IPs of the peers of the group. The members are managed in the dashboard when
+	// the peers are not set, e.g. for the groups filled by the setup keys
+	Peers []string `yaml:"peers"`
+}
+
+// DeclarativePolicy is a policy of the declarative config
+type DeclarativePolicy struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Enabled is true when not set
+	Enabled *bool                   `yaml:"enabled"`
+	Rules   []DeclarativePolicyRule `yaml:"rules"`
+}
+
+// DeclarativePolicyRule is a rule of a declarative policy
+type DeclarativePolicyRule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// Enabled is true when not set
+	Enabled *bool `yaml:"enabled"`
+	// Action is accept or drop, accept when not set
+	Action string `yaml:"action"`
+	// Protocol is all, tcp, udp or icmp, all when not set
+	Protocol      string   `yaml:"protocol"`
+	Bidirectional bool     `yaml:"bidirectional"`
+	Ports         []string `yaml:"ports"`
+	// Sources and Destinations are the names of the groups
+	Sources      []string `yaml:"sources"`
+	Destinations []string `yaml:"destinations"`
+	Log          bool     `yaml:"log"`
+}
+
+// DeclarativeRoute is a route of the declarative config
+type DeclarativeRoute struct {
+	// Name identifies the route in the declarative config, the network ID when not set. The routes of a high
+	// availability set share their network ID and need a name each
+	Name        string `yaml:"name"`
+	NetworkID   string `yaml:"network_id"`
+	Network     string `yaml:"network"`
+	Description string `yaml:"description"`
+	// Peer is the DNS label or the IP of the routing peer, exclusive with PeerGroups
+	Peer       string   `yaml:"peer"`
+	PeerGroups []string `yaml:"peer_groups"`
+	// Groups are the names of the groups the route is distributed to
+	Groups []string `yaml:"groups"`
+	// Metric is route.MaxMetric when not set
+	Metric     int  `yaml:"metric"`
+	Masquerade bool `yaml:"masquerade"`
+	// Enabled is true when not set
+	Enabled *bool `yaml:"enabled"`
+}
+
+// key returns the name identifying the route in the declarative config
+func (r DeclarativeRoute) key() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.NetworkID
+}
+
+// GitOpsAction is the change of an object needed to reach the declarative config
+type GitOpsAction string
+
+const (
+	GitOpsCreate GitOpsAction = "create"
+	GitOpsUpdate GitOpsAction = "update"
+	GitOpsDelete GitOpsAction = "delete"
+)
+
+// GitOpsChange is a difference between the account and the declarative config
+type GitOpsChange struct {
+	// Kind is group, policy or route
+	Kind   string
+	Name   string
+	Action GitOpsAction
+}
+
+// String returns the change in a human-readable form
+func (c GitOpsChange) String() string {
+	return fmt.Sprintf("%s %s %q", c.Action, c.Kind, c.Name)
+}
+
+// GitOpsReport is the result of a sync of an account with a declarative config
+type GitOpsReport struct {
+	// Revision is the revision of the declarative config, e.g. the git commit
+	Revision string
+	// Changes are the differences between the account and the declarative config, they are applied unless DryRun
+	Changes []GitOpsChange
+	DryRun  bool
+}
+
+// LoadDeclarativeConfig reads and merges the YAML files of the directory and of its subdirectories, the hidden
+// directories like .git are skipped
+func LoadDeclarativeConfig(dir string) (*DeclarativeConfig, error) {
+	config := &DeclarativeConfig{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := config.merge(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// merge appends the objects of the YAML documents of a file, the unknown fields are rejected to catch the typos
+func (c *DeclarativeConfig) merge(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	for {
+		var document DeclarativeConfig
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.Groups = append(c.Groups, document.Groups...)
+		c.Policies = append(c.Policies, document.Policies...)
+		c.Routes = append(c.Routes, document.Routes...)
+	}
+}
+
+// validate checks that the objects have a unique name and that the values can be applied
+func (c *DeclarativeConfig) validate() error {
+	names := make(map[string]struct{})
+	unique := func(kind, name string) error {
+		if name == "" {
+			return fmt.Errorf("a %s has no name", kind)
+		}
+		if _, ok := names[kind+"/"+name]; ok {
+			return fmt.Errorf("the %s %q is declared more than once", kind, name)
+		}
+		names[kind+"/"+name] = struct{}{}
+		return nil
+	}
+
+	for _, group := range c.Groups {
+		if err := unique("group", group.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range c.Policies {
+		if err := unique("policy", policy.Name); err != nil {
+			return err
+		}
+		if len(policy.Rules) == 0 {
+			return fmt.Errorf("the policy %q has no rule", policy.Name)
+		}
+		for _, rule := range policy.Rules {
+			switch PolicyTrafficActionType(rule.Action) {
+			case "", PolicyTrafficActionAccept, PolicyTrafficActionDrop:
+			default:
+				return fmt.Errorf("the policy %q has an invalid action %q", policy.Name, rule.Action)
+			}
+			switch PolicyRuleProtocolType(rule.Protocol) {
+			case "", PolicyRuleProtocolALL, PolicyRuleProtocolTCP, PolicyRuleProtocolUDP, PolicyRuleProtocolICMP,
+				PolicyRuleProtocolSCTP, PolicyRuleProtocolGRE:
+			default:
+				return fmt.Errorf("the policy %q has an invalid protocol %q", policy.Name, rule.Protocol)
+			}
+			if len(rule.Sources) == 0 || len(rule.Destinations) == 0 {
+				return fmt.Errorf("a rule of the policy %q has no source or no destination", policy.Name)
+			}
+		}
+	}
+
+	for _, r := range c.Routes {
+		if err := unique("route", r.key()); err != nil {
+			return err
+		}
+		if r.NetworkID == "" || utf8.RuneCountInString(r.NetworkID) > route.MaxNetIDChar {
+			return fmt.Errorf("the network ID of the route %q should be between 1 and %d characters", r.key(), route.MaxNetIDChar)
+		}
+		if _, _, err := route.ParseNetwork(r.Network); err != nil {
+			return fmt.Errorf("the route %q has an invalid network %q", r.key(), r.Network)
+		}
+		if (r.Peer == "") == (len(r.PeerGroups) == 0) {
+			return fmt.Errorf("the route %q should have either a peer or peer groups", r.key())
+		}
+		if r.Metric != 0 && (r.Metric < route.MinMetric || r.Metric > route.MaxMetric) {
+			return fmt.Errorf("the metric of the route %q should be between %d and %d", r.key(), route.MinMetric, route.MaxMetric)
+		}
+	}
+	return nil
+}
+
+// SyncDeclarativeConfig reconciles the groups, policies and routes of the account with the declarative config. The
+// objects created by a previous sync and removed from the config are deleted, the objects created in the dashboard
+// are left untouched unless the config declares a group or a policy of the same name, which is then managed by the
+// sync. With dryRun the changes are only reported
+func (am *DefaultAccountManager) SyncDeclarativeConfig(accountID string, desired *DeclarativeConfig, revision string, dryRun bool) (*GitOpsReport, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &gitOpsSync{account: account}
+	if err := s.apply(desired); err != nil {
+		return nil, err
+	}
+
+	report := &GitOpsReport{Revision: revision, Changes: s.changes, DryRun: dryRun}
+	if dryRun || len(s.changes) == 0 {
+		return report, nil
+	}
+
+	account.Network.IncSerial()
+	if err := am.Store.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	for _, event := range s.events {
+		am.StoreEvent(activity.SystemInitiator, event.targetID, accountID, event.activity, event.meta)
+	}
+	am.StoreEvent(activity.SystemInitiator, accountID, accountID, activity.AccountGitOpsSynced,
+		map[string]any{"revision": revision, "changes": len(s.changes)})
+
+	am.updateAccountPeers(account)
+	return report, nil
+}
+
+// gitOpsEvent is an activity event stored once the account is saved
+type gitOpsEvent struct {
+	targetID string
+	activity activity.Activity
+	meta     map[string]any
+}
+
+// gitOpsSync applies a declarative config to an account
+type gitOpsSync struct {
+	account *Account
+	changes []GitOpsChange
+	events  []gitOpsEvent
+}
+
+func (s *gitOpsSync) record(kind, name string, action GitOpsAction, targetID string, event activity.Activity, meta map[string]any) {
+	s.changes = append(s.changes, GitOpsChange{Kind: kind, Name: name, Action: action})
+	s.events = append(s.events, gitOpsEvent{targetID: targetID, activity: event, meta: meta})
+}
+
+func (s *gitOpsSync) apply(desired *DeclarativeConfig) error {
+	if err := s.applyGroups(desired.Groups); err != nil {
+		return err
+	}
+	if err := s.applyPolicies(desired.Policies); err != nil {
+		return err
+	}
+	if err := s.applyRoutes(desired.Routes); err != nil {
+		return err
+	}
+	return s.deleteGroups(desired.Groups)
+}
+
+func (s *gitOpsSync) applyGroups(groups []DeclarativeGroup) error {
+	for _, declared := range groups {
+		externalID := gitOpsExternalIDPrefix + declared.Name
+		group := s.account.FindGroupByExternalID(externalID)
+		if group == nil {
+			group = s.groupByName(declared.Name)
+		}
+
+		desired := &Group{ID: xid.New().String(), Name: declared.Name, Issued: GroupIssuedAPI, Peers: []string{}}
+		if group != nil {
+			desired = group.Copy()
+			desired.Name = declared.Name
+		}
+		desired.ExternalID = externalID
+		if declared.Peers != nil {
+			peers, err := s.peerIDs(declared.Peers)
+			if err != nil {
+				return fmt.Errorf("group %q: %w", declared.Name, err)
+			}
+			desired.Peers = peers
+		}
+
+		switch {
+		case group == nil:
+			s.record("group", declared.Name, GitOpsCreate, desired.ID, activity.GroupCreated, desired.EventMeta())
+		case group.Name != desired.Name || group.ExternalID != desired.ExternalID || !equalSets(group.Peers, desired.Peers):
+			s.record("group", declared.Name, GitOpsUpdate, desired.ID, activity.GroupUpdated, desired.EventMeta())
+		default:
+			continue
+		}
+		s.account.Groups[desired.ID] = desired
+	}
+	return nil
+}
+
+// deleteGroups deletes the managed groups which are not declared anymore, unless an object still references them
+func (s *gitOpsSync) deleteGroups(groups []DeclarativeGroup) error {
+	declared := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		declared[gitOpsExternalIDPrefix+group.Name] = struct{}{}
+	}
+
+	for _, id := range sortedKeys(s.account.Groups) {
+		group := s.account.Groups[id]
+		if !strings.HasPrefix(group.ExternalID, gitOpsExternalIDPrefix) {
+			continue
+		}
+		if _, ok := declared[group.ExternalID]; ok {
+			continue
+		}
+		if link := s.groupLink(id); link != "" {
+			return fmt.Errorf("group %q was removed from the config but is still used by %s", group.Name, link)
+		}
+		delete(s.account.Groups, id)
+		s.record("group", group.Name, GitOpsDelete, id, activity.GroupDeleted, group.EventMeta())
+	}
+	return nil
+}
+
+func (s *gitOpsSync) applyPolicies(policies []DeclarativePolicy) error {
+	declared := make(map[string]struct{}, len(policies))
+	for _, p := range policies {
+		externalID := gitOpsExternalIDPrefix + p.Name
+		declared[externalID] = struct{}{}
+
+		policy := s.account.FindPolicyByExternalID(externalID)
+		if policy == nil {
+			policy = s.policyByName(p.Name)
+		}
+
+		desired := &Policy{ID: xid.New().String()}
+		if policy != nil {
+			desired.ID = policy.ID
+		}
+		desired.Name = p.Name
+		desired.Description = p.Description
+		desired.Enabled = enabledOrDefault(p.Enabled)
+		desired.ExternalID = externalID
+		for i, r := range p.Rules {
+			rule := &PolicyRule{
+				ID:            fmt.Sprintf("%s-%d", desired.ID, i),
+				PolicyID:      desired.ID,
+				Name:          r.Name,
+				Description:   r.Description,
+				Enabled:       enabledOrDefault(r.Enabled),
+				Action:        PolicyTrafficActionType(r.Action),
+				Protocol:      PolicyRuleProtocolType(r.Protocol),
+				Bidirectional: r.Bidirectional,
+				Ports:         r.Ports,
+				Log:           r.Log,
+			}
+			if rule.Name == "" {
+				rule.Name = p.Name
+			}
+			if rule.Action == "" {
+				rule.Action = PolicyTrafficActionAccept
+			}
+			if rule.Protocol == "" {
+				rule.Protocol = PolicyRuleProtocolALL
+			}
+			var err error
+			if rule.Sources, err = s.groupIDs(r.Sources); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+			if rule.Destinations, err = s.groupIDs(r.Destinations); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+			desired.Rules = append(desired.Rules, rule)
+		}
+
+		switch {
+		case policy == nil:
+			s.account.Policies = append(s.account.Policies, desired)
+			s.record("policy", p.Name, GitOpsCreate, desired.ID, activity.PolicyAdded, desired.EventMeta())
+		case !equalPolicies(policy, desired):
+			for i := range s.account.Policies {
+				if s.account.Policies[i].ID == desired.ID {
+					s.account.Policies[i] = desired
+				}
+			}
+			s.record("policy", p.Name, GitOpsUpdate, desired.ID, activity.PolicyUpdated, desired.EventMeta())
+		}
+	}
+
+	kept := s.account.Policies[:0]
+	for _, policy := range s.account.Policies {
+		if _, ok := declared[policy.ExternalID]; !ok && strings.HasPrefix(policy.ExternalID, gitOpsExternalIDPrefix) {
+			s.record("policy", policy.Name, GitOpsDelete, policy.ID, activity.PolicyRemoved, policy.EventMeta())
+			continue
+		}
+		kept = append(kept, policy)
+	}
+	s.account.Policies = kept
+	return nil
+}
+
+func (s *gitOpsSync) applyRoutes(routes []DeclarativeRoute) error {
+	declared := make(map[string]struct{}, len(routes))
+	for _, r := range routes {
+		externalID := gitOpsExternalIDPrefix + r.key()
+		declared[externalID] = struct{}{}
+
+		current := s.account.FindRouteByExternalID(externalID)
+
+		networkType, network, _ := route.ParseNetwork(r.Network)
+		desired := &route.Route{
+			ID:          xid.New().String(),
+			NetID:       r.NetworkID,
+			Network:     network,
+			NetworkType: networkType,
+			Description: r.Description,
+			Masquerade:  r.Masquerade,
+			Metric:      r.Metric,
+			Enabled:     enabledOrDefault(r.Enabled),
+			ExternalID:  externalID,
+		}
+		if current != nil {
+			desired.ID = current.ID
+			desired.DisablePreemption = current.DisablePreemption
+			desired.LoadBalance = current.LoadBalance
+		}
+		if desired.Metric == 0 {
+			desired.Metric = route.MaxMetric
+		}
+
+		var err error
+		if r.Peer != "" {
+			peers, err := s.peerIDs([]string{r.Peer})
+			if err != nil {
+				return fmt.Errorf("route %q: %w", r.key(), err)
+			}
+			desired.Peer = peers[0]
+		}
+		if desired.PeerGroups, err = s.groupIDs(r.PeerGroups); err != nil {
+			return fmt.Errorf("route %q: %w", r.key(), err)
+		}
+		if desired.Groups, err = s.groupIDs(r.Groups); err != nil {
+			return fmt.Errorf("route %q: %w", r.key(), err)
+		}
+
+		switch {
+		case current == nil:
+			s.record("route", r.key(), GitOpsCreate, desired.ID, activity.RouteCreated, desired.EventMeta())
+		case !current.IsEqual(desired):
+			s.record("route", r.key(), GitOpsUpdate, desired.ID, activity.RouteUpdated, desired.EventMeta())
+		default:
+			continue
+		}
+		s.account.Routes[desired.ID] = desired
+	}
+
+	for _, id := range sortedKeys(s.account.Routes) {
+		r := s.account.Routes[id]
+		if _, ok := declared[r.ExternalID]; ok || !strings.HasPrefix(r.ExternalID, gitOpsExternalIDPrefix) {
+			continue
+		}
+		delete(s.account.Routes, id)
+		s.record("route", strings.TrimPrefix(r.ExternalID, gitOpsExternalIDPrefix), GitOpsDelete, id,
+			activity.RouteRemoved, r.EventMeta())
+	}
+	return nil
+}
+
+// groupByName returns the unmanaged group of the name, nil when there is none
+func (s *gitOpsSync) groupByName(name string) *Group {
+	for _, group := range s.account.Groups {
+		if group.Name == name && group.ExternalID == "" {
+			return group
+		}
+	}
+	return nil
+}
+
+// policyByName returns the unmanaged policy of the name, nil when there is none
+func (s *gitOpsSync) policyByName(name string) *Policy {
+	for _, policy := range s.account.Policies {
+		if policy.Name == name && policy.ExternalID == "" {
+			return policy
+		}
+	}
+	return nil
+}
+
+// groupIDs returns the IDs of the groups of the names, the managed groups first
+func (s *gitOpsSync) groupIDs(names []string) ([]string, error) {
+	ids := make([]string, 0, len(names))
+	for _, name := range names {
+		group := s.account.FindGroupByExternalID(gitOpsExternalIDPrefix + name)
+		if group == nil {
+			for _, g := range s.account.Groups {
+				if g.Name == name {
+					group = g
+					break
+				}
+			}
+		}
+		if group == nil {
+			return nil, status.Errorf(status.NotFound, "group %q not found", name)
+		}
+		ids = append(ids, group.ID)
+	}
+	return ids, nil
+}
+
+// peerIDs returns the IDs of the peers of the DNS labels or IPs
+func (s *gitOpsSync) peerIDs(refs []string) ([]string, error) {
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		found := false
+		for id, peer := range s.account.Peers {
+			if peer.DNSLabel == ref || peer.IP.String() == ref {
+				ids = append(ids, id)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, status.Errorf(status.NotFound, "peer %q not found", ref)
+		}
+	}
+	return ids, nil
+}
+
+// groupLink returns the object referencing the group, empty when there is none
+func (s *gitOpsSync) groupLink(groupID string) string {
+	for _, policy := range s.account.Policies {
+		for _, rule := range policy.Rules {
+			if slices.Contains(rule.Sources, groupID) || slices.Contains(rule.Destinations, groupID) {
+				return fmt.Sprintf("the policy %q", policy.Name)
+			}
+		}
+	}
+	for _, r := range s.account.Routes {
+		if slices.Contains(r.Groups, groupID) || slices.Contains(r.PeerGroups, groupID) {
+			return fmt.Sprintf("the route %q", r.NetID)
+		}
+	}
+	for _, nsGroup := range s.account.NameServerGroups {
+		if slices.Contains(nsGroup.Groups, groupID) {
+			return fmt.Sprintf("the nameserver group %q", nsGroup.Name)
+		}
+	}
+	for _, key := range s.account.SetupKeys {
+		if slices.Contains(key.AutoGroups, groupID) {
+			return fmt.Sprintf("the setup key %q", key.Name)
+		}
+	}
+	for _, user := range s.account.Users {
+		if slices.Contains(user.AutoGroups, groupID) {
+			return fmt.Sprintf("the user %s", user.Id)
+		}
+	}
+	return ""
+}
+
+// equalPolicies compares the fields of the policies set by the declarative config
+func equalPolicies(a, b *Policy) bool {
+	if a.Name != b.Name || a.Description != b.Description || a.Enabled != b.Enabled ||
+		a.ExternalID != b.ExternalID || len(a.Rules) != len(b.Rules) {
+		return false
+	}
+	for i := range a.Rules {
+		ra, rb := a.Rules[i], b.Rules[i]
+		if ra.ID != rb.ID || ra.Name != rb.Name || ra.Description != rb.Description || ra.Enabled != rb.Enabled ||
+			ra.Action != rb.Action || ra.Protocol != rb.Protocol || ra.Bidirectional != rb.Bidirectional ||
+			ra.Log != rb.Log || !slices.Equal(ra.Ports, rb.Ports) || !slices.Equal(ra.Sources, rb.Sources) ||
+			!slices.Equal(ra.Destinations, rb.Destinations) || !slices.Equal(ra.Services, rb.Services) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalSets returns true when the lists hold the same elements regardless of their order
+func equalSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(a, b)
+}
+
+func enabledOrDefault(enabled *bool) bool {
+	return enabled == nil || *enabled
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+)
+
+const (
+	// DefaultGitOpsInterval is the interval between the syncs when the config doesn't set it
+	DefaultGitOpsInterval = 5 * time.Minute
+	// gitOpsCheckoutDir is the directory of the checkout of the repository in the data directory
+	gitOpsCheckoutDir = "gitops"
+	// gitTimeout limits the time of a git command
+	gitTimeout = 2 * time.Minute
+)
+
+// GitOpsSyncer periodically reconciles an account with the declarative config of a git repository or of a local
+// directory and reports the drift
+type GitOpsSyncer struct {
+	config         GitOpsConfig
+	checkoutDir    string
+	accountManager *DefaultAccountManager
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu         sync.Mutex
+	lastReport *GitOpsReport
+}
+
+// NewGitOpsSyncer creates the syncer of the config, the repository is checked out in the data directory
+func NewGitOpsSyncer(config GitOpsConfig, dataDir string, accountManager *DefaultAccountManager) (*GitOpsSyncer, error) {
+	if config.Repository == "" && config.Directory == "" {
+		return nil, fmt.Errorf("the GitOps sync needs a repository or a directory")
+	}
+	if config.Repository != "" {
+		if _, err := exec.LookPath("git"); err != nil {
+			return nil, fmt.Errorf("the GitOps sync of a repository needs git: %v", err)
+		}
+	}
+	if config.Interval.Duration <= 0 {
+		config.Interval.Duration = DefaultGitOpsInterval
+	}
+
+	return &GitOpsSyncer{
+		config:         config,
+		checkoutDir:    filepath.Join(dataDir, gitOpsCheckoutDir),
+		accountManager: accountManager,
+	}, nil
+}
+
+// Start syncs the account now and then at every interval until Stop is called
+func (s *GitOpsSyncer) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.config.Interval.Duration)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Sync(ctx); err != nil && ctx.Err() == nil {
+				log.Errorf("GitOps sync failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic syncs and waits for the running one
+func (s *GitOpsSyncer) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// LastReport returns the report of the last successful sync, nil before the first one
+func (s *GitOpsSyncer) LastReport() *GitOpsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastReport
+}
+
+// Sync updates the checkout of the repository and reconciles the account with its declarative config
+func (s *GitOpsSyncer) Sync(ctx context.Context) (*GitOpsReport, error) {
+	dir, revision, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	desired, err := LoadDeclarativeConfig(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading the declarative config of revision %s: %v", revision, err)
+	}
+
+	accountID, err := s.accountID()
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.accountManager.SyncDeclarativeConfig(accountID, desired, revision, s.config.DryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed syncing account %s with revision %s: %v", accountID, revision, err)
+	}
+
+	s.mu.Lock()
+	previous := s.lastReport
+	s.lastReport = report
+	s.mu.Unlock()
+
+	s.logReport(accountID, report, previous)
+	return report, nil
+}
+
+// logReport logs the changes of the sync. In dry run the drift is logged and stored as an event when it changes
+func (s *GitOpsSyncer) logReport(accountID string, report, previous *GitOpsReport) {
+	if len(report.Changes) == 0 {
+		log.Debugf("account %s is in sync with revision %s", accountID, report.Revision)
+		return
+	}
+
+	changes := make([]string, 0, len(report.Changes))
+	for _, change := range report.Changes {
+		changes = append(changes, change.String())
+	}
+
+	if !report.DryRun {
+		log.Infof("synced account %s with revision %s: %s", accountID, report.Revision, strings.Join(changes, ", "))
+		return
+	}
+
+	if previous != nil && previous.Revision == report.Revision && slices.Equal(previous.Changes, report.Changes) {
+		return
+	}
+	log.Warnf("account %s drifted from revision %s: %s", accountID, report.Revision, strings.Join(changes, ", "))
+	s.accountManager.StoreEvent(activity.SystemInitiator, accountID, accountID, activity.AccountGitOpsDriftDetected,
+		map[string]any{"revision": report.Revision, "changes": changes})
+}
+
+// accountID returns the account of the config, the only account of the store when none is set
+func (s *GitOpsSyncer) accountID() (string, error) {
+	if s.config.AccountID != "" {
+		return s.config.AccountID, nil
+	}
+
+	accounts := s.accountManager.Store.GetAllAccounts()
+	if len(accounts) != 1 {
+		return "", fmt.Errorf("the store has %d accounts, set the account of the GitOps sync", len(accounts))
+	}
+	return accounts[0].Id, nil
+}
+
+// fetch updates the checkout of the repository and returns the directory of the declarative config with its
+// revision. A local directory is read as is, its revision is the time of the read
+func (s *GitOpsSyncer) fetch(ctx context.Context) (string, string, error) {
+	if s.config.Repository == "" {
+		return s.config.Directory, time.Now().UTC().Format(time.RFC3339), nil
+	}
+
+	if _, err := os.Stat(filepath.Join(s.checkoutDir, ".git")); err != nil {
+		if err := os.RemoveAll(s.checkoutDir); err != nil {
+			return "", "", err
+		}
+		args := []string{"clone", "--depth", "1"}
+		if s.config.Branch != "" {
+			args = append(args, "--branch", s.config.Branch)
+		}
+		// the repository comes from the config, it can't be taken for an option
+		if _, err := git(ctx, "", append(args, "--", s.config.Repository, s.checkoutDir)...); err != nil {
+			return "", "", err
+		}
+	} else {
+		ref := s.config.Branch
+		if ref == "" {
+			ref = "HEAD"
+		}
+		if _, err := git(ctx, s.checkoutDir, "fetch", "--depth", "1", "origin", ref); err != nil {
+			return "", "", err
+		}
+		if _, err := git(ctx, s.checkoutDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", "", err
+		}
+	}
+
+	revision, err := git(ctx, s.checkoutDir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	return filepath.Join(s.checkoutDir, s.config.Directory), revision, nil
+}
+
+// git runs a git command in the directory and returns its trimmed output
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// the sync runs unattended, git must fail instead of prompting for credentials
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+const testDeclarativeConfig = `
+groups:
+  - name: dev
+    peers: [peer-a]
+  - name: routers
+policies:
+  - name: dev to all
+    rules:
+      - sources: [dev]
+        destinations: [All]
+        protocol: tcp
+        ports: ["22"]
+routes:
+  - network_id: office
+    network: 10.0.0.0/24
+    peer_groups: [routers]
+    groups: [dev]
+`
+
+func writeDeclarativeConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestLoadDeclarativeConfig(t *testing.T) {
+	dir := writeDeclarativeConfig(t, map[string]string{
+		"config.yaml":        testDeclarativeConfig,
+		"extra/more.yml":     "groups:\n  - name: ops\n---\ngroups:\n  - name: qa\n",
+		".git/ignored.yaml":  "groups:\n  - name: dev\n",
+		"README.md":          "not a config",
+		"extra/invalid.json": "{",
+	})
+
+	config, err := LoadDeclarativeConfig(dir)
+	require.NoError(t, err)
+	assert.Len(t, config.Groups, 4, "the groups of all the documents should be merged, the hidden directories skipped")
+	assert.Len(t, config.Policies, 1)
+	assert.Len(t, config.Routes, 1)
+
+	_, err = LoadDeclarativeConfig(writeDeclarativeConfig(t, map[string]string{
+		"a.yaml": "groups:\n  - name: dev\n",
+		"b.yaml": "groups:\n  - name: dev\n",
+	}))
+	assert.Error(t, err, "a duplicated name should be rejected")
+
+	_, err = LoadDeclarativeConfig(writeDeclarativeConfig(t, map[string]string{
+		"a.yaml": "groups:\n  - name: dev\n    peer: [peer-a]\n",
+	}))
+	assert.Error(t, err, "an unknown field should be rejected")
+
+	_, err = LoadDeclarativeConfig(writeDeclarativeConfig(t, map[string]string{
+		"a.yaml": "routes:\n  - network_id: office\n    network: 10.0.0.0/24\n",
+	}))
+	assert.Error(t, err, "a route without routing peer should be rejected")
+}
+
+func TestSyncDeclarativeConfig(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err)
+
+	account := newAccountWithId("account-id", "owner", "netbird.io")
+	account.Peers["peer-a"] = &nbpeer.Peer{
+		ID:       "peer-a",
+		Key:      "peer-a-key",
+		IP:       net.ParseIP("100.64.0.1"),
+		DNSLabel: "peer-a",
+		Status:   &nbpeer.PeerStatus{},
+	}
+	account.Groups["manual"] = &Group{ID: "manual", Name: "manual", Issued: GroupIssuedAPI}
+	require.NoError(t, manager.Store.SaveAccount(account))
+
+	desired, err := LoadDeclarativeConfig(writeDeclarativeConfig(t, map[string]string{"config.yaml": testDeclarativeConfig}))
+	require.NoError(t, err)
+
+	report, err := manager.SyncDeclarativeConfig(account.Id, desired, "rev1", true)
+	require.NoError(t, err)
+	assert.Len(t, report.Changes, 4, "the dry run should report the missing groups, policy and route")
+	unchanged, err := manager.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	assert.Nil(t, unchanged.FindPolicyByExternalID(gitOpsExternalIDPrefix+"dev to all"), "the dry run should not change the account")
+
+	_, err = manager.SyncDeclarativeConfig(account.Id, desired, "rev1", false)
+	require.NoError(t, err)
+
+	synced, err := manager.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	dev := synced.FindGroupByExternalID(gitOpsExternalIDPrefix + "dev")
+	require.NotNil(t, dev)
+	assert.Equal(t, []string{"peer-a"}, dev.Peers)
+	policy := synced.FindPolicyByExternalID(gitOpsExternalIDPrefix + "dev to all")
+	require.NotNil(t, policy)
+	assert.Equal(t, []string{dev.ID}, policy.Rules[0].Sources)
+	office := synced.FindRouteByExternalID(gitOpsExternalIDPrefix + "office")
+	require.NotNil(t, office)
+	assert.Equal(t, []string{dev.ID}, office.Groups)
+	assert.Contains(t, synced.Groups, "manual", "the unmanaged objects should be left untouched")
+
+	report, err = manager.SyncDeclarativeConfig(account.Id, desired, "rev1", false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Changes, "an account in sync should not change")
+
+	// a change made in the dashboard is reverted
+	synced.FindPolicyByExternalID(gitOpsExternalIDPrefix + "dev to all").Enabled = false
+	require.NoError(t, manager.Store.SaveAccount(synced))
+	report, err = manager.SyncDeclarativeConfig(account.Id, desired, "rev1", false)
+	require.NoError(t, err)
+	assert.Equal(t, []GitOpsChange{{Kind: "policy", Name: "dev to all", Action: GitOpsUpdate}}, report.Changes)
+
+	// the objects removed from the config are deleted
+	desired.Policies = nil
+	desired.Routes = nil
+	desired.Groups = desired.Groups[:1]
+	report, err = manager.SyncDeclarativeConfig(account.Id, desired, "rev2", false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []GitOpsChange{
+		{Kind: "policy", Name: "dev to all", Action: GitOpsDelete},
+		{Kind: "route", Name: "office", Action: GitOpsDelete},
+		{Kind: "group", Name: "routers", Action: GitOpsDelete},
+	}, report.Changes)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -35,7 +36,27 @@ type Group struct {
 	// Peers list of the group
 	Peers []string `gorm:"serializer:json"`
 
+	// BandwidthLimit is the limit in kbit/s of the traffic of the group peers. 0 means no limit
+	BandwidthLimit uint64
+
+	// DNSSuffix makes the peers of the group resolvable under the suffix, e.g. peer.prod.netbird.cloud for prod
+	DNSSuffix string
+
+	// AppRoutingMode selects the applications of the group peers allowed in the tunnel: only the AppRoutingApplications
+	// when it is AppRoutingModeInclude, all but them when it is AppRoutingModeExclude. Empty disables app routing
+	AppRoutingMode AppRoutingMode
+	// AppRoutingApplications is the list of executable paths the AppRoutingMode applies to
+	AppRoutingApplications []string `gorm:"serializer:json"`
+
 	IntegrationReference IntegrationReference `gorm:"embedded;embeddedPrefix:integration_ref_"`
+
+	// ExternalID is an identifier set by an external tool managing the group, e.g. a Terraform provider. It is unique
+	// among the groups of the account
+	ExternalID string `gorm:"index"`
+
+	// LoginExpiration overrides the account peer login expiration for the group peers. The shortest one of the groups
+	// of a peer applies. 0 keeps the account one
+	LoginExpiration time.Duration
 }
 
 // EventMeta returns activity event meta related to the group
@@ -49,9 +70,18 @@ func (g *Group) Copy() *Group {
 		Name:                 g.Name,
 		Issued:               g.Issued,
 		Peers:                make([]string, len(g.Peers)),
+		BandwidthLimit:       g.BandwidthLimit,
+		DNSSuffix:            g.DNSSuffix,
+		AppRoutingMode:       g.AppRoutingMode,
 		IntegrationReference: g.IntegrationReference,
+		ExternalID:           g.ExternalID,
+		LoginExpiration:      g.LoginExpiration,
 	}
 	copy(group.Peers, g.Peers)
+	if g.AppRoutingApplications != nil {
+		group.AppRoutingApplications = make([]string, len(g.AppRoutingApplications))
+		copy(group.AppRoutingApplications, g.AppRoutingApplications)
+	}
 	return group
 }
 
@@ -82,6 +112,26 @@ func (am *DefaultAccountManager) SaveGroup(accountID, userID string, newGroup *G
 	if err != nil {
 		return err
 	}
+	suffix, err := validateGroupDNSSuffix(newGroup.DNSSuffix)
+	if err != nil {
+		return err
+	}
+	newGroup.DNSSuffix = suffix
+
+	applications, err := validateAppRouting(newGroup.AppRoutingMode, newGroup.AppRoutingApplications)
+	if err != nil {
+		return err
+	}
+	newGroup.AppRoutingApplications = applications
+
+	if err := account.validateGroupExternalID(newGroup); err != nil {
+		return err
+	}
+
+	if err := validateGroupLoginExpiration(newGroup.LoginExpiration); err != nil {
+		return err
+	}
+
 	oldGroup, exists := account.Groups[newGroup.ID]
 	account.Groups[newGroup.ID] = newGroup
 
@@ -92,6 +142,10 @@ func (am *DefaultAccountManager) SaveGroup(accountID, userID string, newGroup *G
 
 	am.updateAccountPeers(account)
 
+	if groupLoginExpirationChanged(oldGroup, newGroup) {
+		am.checkAndSchedulePeerLoginExpiration(account)
+	}
+
 	// the following snippet tracks the activity and stores the group events in the event store.
 	// It has to happen after all the operations have been successfully performed.
 	addedPeers := make([]string, 0)
@@ -99,6 +153,11 @@ func (am *DefaultAccountManager) SaveGroup(accountID, userID string, newGroup *G
 	if exists {
 		addedPeers = difference(newGroup.Peers, oldGroup.Peers)
 		removedPeers = difference(oldGroup.Peers, newGroup.Peers)
+		if oldGroup.LoginExpiration != newGroup.LoginExpiration {
+			meta := newGroup.EventMeta()
+			meta["login_expiration"] = newGroup.LoginExpiration.String()
+			am.StoreEvent(userID, newGroup.ID, accountID, activity.GroupLoginExpirationUpdated, meta)
+		}
 	} else {
 		addedPeers = append(addedPeers, newGroup.Peers...)
 		am.StoreEvent(userID, newGroup.ID, accountID, activity.GroupCreated, newGroup.EventMeta())
@@ -234,6 +293,29 @@ func (am *DefaultAccountManager) DeleteGroup(accountId, userId, groupID string)
 		}
 	}
 
+	// check SearchDomainGroups
+	for _, searchDomainGroup := range account.DNSSettings.SearchDomainGroups {
+		for _, grp := range searchDomainGroup.Groups {
+			if grp == groupID {
+				return &GroupLinkError{"DNS search domain groups", g.Name}
+			}
+		}
+	}
+
+	// check PeerLoginExpiredAccessGroups
+	for _, accessGroup := range account.Settings.PeerLoginExpiredAccessGroups {
+		if accessGroup == groupID {
+			return &GroupLinkError{"expired peers access groups", g.Name}
+		}
+	}
+
+	// check RouteAdvertisementGroups
+	for _, advertisementGroup := range account.Settings.RouteAdvertisementGroups {
+		if advertisementGroup == groupID {
+			return &GroupLinkError{"route advertisement groups", g.Name}
+		}
+	}
+
 	delete(account.Groups, groupID)
 
 	account.Network.IncSerial()
@@ -245,6 +327,10 @@ func (am *DefaultAccountManager) DeleteGroup(accountId, userId, groupID string)
 
 	am.updateAccountPeers(account)
 
+	if g.LoginExpiration != 0 {
+		am.checkAndSchedulePeerLoginExpiration(account)
+	}
+
 	return nil
 }
 
@@ -299,6 +385,10 @@ func (am *DefaultAccountManager) GroupAddPeer(accountID, groupID, peerID string)
 
 	am.updateAccountPeers(account)
 
+	if add && group.LoginExpiration != 0 {
+		am.checkAndSchedulePeerLoginExpiration(account)
+	}
+
 	return nil
 }
 
@@ -324,6 +414,9 @@ func (am *DefaultAccountManager) GroupDeletePeer(accountID, groupID, peerID stri
 			if err := am.Store.SaveAccount(account); err != nil {
 				return err
 			}
+			if group.LoginExpiration != 0 {
+				am.checkAndSchedulePeerLoginExpiration(account)
+			}
 		}
 	}
 
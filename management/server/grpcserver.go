@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"strings"
 	"time"
@@ -9,6 +10,9 @@ import (
 	pb "github.com/golang/protobuf/proto" // nolint
 	"github.com/golang/protobuf/ptypes/timestamp"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"google.golang.org/grpc/codes"
 	gRPCPeer "google.golang.org/grpc/peer"
@@ -20,8 +24,15 @@ import (
 	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 	internalStatus "github.com/FlintyLemming/netbird/management/server/status"
 	"github.com/FlintyLemming/netbird/management/server/telemetry"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
+// attestationMaxSkew is the maximum age (or clock skew into the future) of a peer attestation statement
+const attestationMaxSkew = 5 * time.Minute
+
+// tracer traces the logins of the peers and the propagation of the network maps to them
+var tracer = otel.Tracer("github.com/FlintyLemming/netbird/management/server")
+
 // GRPCServer an instance of a Management gRPC API server
 type GRPCServer struct {
 	accountManager AccountManager
@@ -55,6 +66,11 @@ func NewServer(config *Config, accountManager AccountManager, peersUpdateManager
 		if err != nil {
 			return nil, status.Errorf(codes.Internal, "unable to create new jwt middleware, err: %v", err)
 		}
+		for _, issuer := range config.GetIdentityProviderIssuers() {
+			if err := jwtValidator.AddIssuer(issuer, config.HttpConfig.AuthUserIDClaim); err != nil {
+				return nil, status.Errorf(codes.Internal, "unable to add identity provider to jwt middleware, err: %v", err)
+			}
+		}
 	} else {
 		log.Debug("unable to use http config to create new jwt middleware")
 	}
@@ -127,12 +143,22 @@ func (s *GRPCServer) Sync(req *proto.EncryptedMessage, srv proto.ManagementServi
 		return err
 	}
 
-	peer, netMap, err := s.accountManager.SyncPeer(PeerSync{WireGuardPubKey: peerKey.String()})
+	_, attestationKey, err := verifyAttestation(peerKey, syncReq.GetAttestation())
+	if err != nil {
+		log.Warnf("failed verifying attestation of peer %s: %v", peerKey, err)
+		return err
+	}
+
+	ctx, span := tracer.Start(srv.Context(), "SyncPeer", trace.WithAttributes(attribute.String("peer.key", peerKey.String())))
+	peer, netMap, err := s.accountManager.SyncPeer(PeerSync{WireGuardPubKey: peerKey.String(), AttestationKey: attestationKey})
 	if err != nil {
+		tracing.End(span, err)
 		return mapError(err)
 	}
 
-	err = s.sendInitialSync(peerKey, peer, netMap, srv)
+	chunksSupported := syncReq.GetChunksSupported()
+	err = s.sendInitialSync(ctx, peerKey, peer, netMap, chunksSupported, srv)
+	tracing.End(span, err)
 	if err != nil {
 		log.Debugf("error while sending initial sync for %s: %v", peerKey.String(), err)
 		return err
@@ -172,17 +198,13 @@ func (s *GRPCServer) Sync(req *proto.EncryptedMessage, srv proto.ManagementServi
 			}
 			log.Debugf("received an update for peer %s", peerKey.String())
 
-			encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, update.Update)
-			if err != nil {
-				s.cancelPeerRoutines(peer)
-				return status.Errorf(codes.Internal, "failed processing update message")
-			}
-
-			err = srv.SendMsg(&proto.EncryptedMessage{
-				WgPubKey: s.wgKey.PublicKey().String(),
-				Body:     encryptedResp,
-			})
+			// the update continues the trace of the change of the account it was sent for
+			_, span := tracer.Start(tracing.Extract(srv.Context(), update.Update.GetTraceContext()), "SendUpdate",
+				trace.WithAttributes(attribute.String("peer.key", peerKey.String())))
+			err := s.sendSyncResponse(peerKey, update.Update, chunksSupported, srv)
+			tracing.End(span, err)
 			if err != nil {
+				log.Debugf("failed sending an update to peer %s: %v", peerKey.String(), err)
 				s.cancelPeerRoutines(peer)
 				return status.Errorf(codes.Internal, "failed sending update message")
 			}
@@ -250,17 +272,47 @@ func mapError(err error) error {
 
 func extractPeerMeta(loginReq *proto.LoginRequest) nbpeer.PeerSystemMeta {
 	return nbpeer.PeerSystemMeta{
-		Hostname:  loginReq.GetMeta().GetHostname(),
-		GoOS:      loginReq.GetMeta().GetGoOS(),
-		Kernel:    loginReq.GetMeta().GetKernel(),
-		Core:      loginReq.GetMeta().GetCore(),
-		Platform:  loginReq.GetMeta().GetPlatform(),
-		OS:        loginReq.GetMeta().GetOS(),
-		WtVersion: loginReq.GetMeta().GetWiretrusteeVersion(),
-		UIVersion: loginReq.GetMeta().GetUiVersion(),
+		Hostname:     loginReq.GetMeta().GetHostname(),
+		GoOS:         loginReq.GetMeta().GetGoOS(),
+		Kernel:       loginReq.GetMeta().GetKernel(),
+		Core:         loginReq.GetMeta().GetCore(),
+		Platform:     loginReq.GetMeta().GetPlatform(),
+		OS:           loginReq.GetMeta().GetOS(),
+		WtVersion:    loginReq.GetMeta().GetWiretrusteeVersion(),
+		UIVersion:    loginReq.GetMeta().GetUiVersion(),
+		Capabilities: loginReq.GetMeta().GetCapabilities(),
+		FirewallCapabilities: nbpeer.FirewallCapabilities{
+			IPSet:      loginReq.GetMeta().GetFirewallCapabilities().GetIpset(),
+			PortRanges: loginReq.GetMeta().GetFirewallCapabilities().GetPortRanges(),
+			Reject:     loginReq.GetMeta().GetFirewallCapabilities().GetReject(),
+			IPv6:       loginReq.GetMeta().GetFirewallCapabilities().GetIpv6(),
+			SCTPAndGRE: loginReq.GetMeta().GetFirewallCapabilities().GetSctpGre(),
+		},
+		Services:    toPeerServices(loginReq.GetMeta().GetServices()),
+		LANNetworks: toPeerLANNetworks(loginReq.GetMeta().GetLanNetworks()),
 	}
 }
 
+// verifyAttestation validates the attestation statement of a peer and returns its provider and base64 encoded public key.
+// Empty values are returned when no statement was provided
+func verifyAttestation(peerKey wgtypes.Key, attestation *proto.PeerAttestation) (string, string, error) {
+	if attestation == nil {
+		return "", "", nil
+	}
+
+	signedAt := attestation.GetTimestamp().AsTime()
+	if time.Since(signedAt) > attestationMaxSkew || time.Until(signedAt) > attestationMaxSkew {
+		return "", "", status.Errorf(codes.InvalidArgument, "attestation statement has expired, check the system clock")
+	}
+
+	err := encryption.VerifyAttestation(attestation.GetPublicKey(), peerKey.String(), signedAt, attestation.GetSignature())
+	if err != nil {
+		return "", "", status.Errorf(codes.PermissionDenied, "invalid attestation statement")
+	}
+
+	return attestation.GetProvider(), base64.StdEncoding.EncodeToString(attestation.GetPublicKey()), nil
+}
+
 func (s *GRPCServer) parseRequest(req *proto.EncryptedMessage, parsed pb.Message) (wgtypes.Key, error) {
 	peerKey, err := wgtypes.ParseKey(req.GetWgPubKey())
 	if err != nil {
@@ -300,6 +352,7 @@ func (s *GRPCServer) Login(ctx context.Context, req *proto.EncryptedMessage) (*p
 	if err != nil {
 		return nil, err
 	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("peer.key", peerKey.String()))
 
 	if loginReq.GetMeta() == nil {
 		msg := status.Errorf(codes.FailedPrecondition,
@@ -324,13 +377,23 @@ func (s *GRPCServer) Login(ctx context.Context, req *proto.EncryptedMessage) (*p
 		sshKey = loginReq.GetPeerKeys().GetSshPubKey()
 	}
 
+	attestationProvider, attestationKey, err := verifyAttestation(peerKey, loginReq.GetAttestation())
+	if err != nil {
+		log.Warnf("failed verifying attestation of peer %s: %v", peerKey, err)
+		return nil, err
+	}
+
+	_, span := tracer.Start(ctx, "LoginPeer")
 	peer, netMap, err := s.accountManager.LoginPeer(PeerLogin{
-		WireGuardPubKey: peerKey.String(),
-		SSHKey:          string(sshKey),
-		Meta:            extractPeerMeta(loginReq),
-		UserID:          userID,
-		SetupKey:        loginReq.GetSetupKey(),
+		WireGuardPubKey:     peerKey.String(),
+		SSHKey:              string(sshKey),
+		Meta:                extractPeerMeta(loginReq),
+		UserID:              userID,
+		SetupKey:            loginReq.GetSetupKey(),
+		AttestationProvider: attestationProvider,
+		AttestationKey:      attestationKey,
 	})
+	tracing.End(span, err)
 
 	if err != nil {
 		log.Warnf("failed logging in peer %s", peerKey)
@@ -415,6 +478,7 @@ func toWiretrusteeConfig(config *Config, turnCredentials *TURNCredentials) *prot
 			Uri:      config.Signal.URI,
 			Protocol: ToResponseProto(config.Signal.Proto),
 		},
+		SignalOverManagement: config.EmbeddedSignal,
 	}
 }
 
@@ -425,18 +489,24 @@ func toPeerConfig(peer *nbpeer.Peer, network *Network, dnsName string) *proto.Pe
 		Address:   fmt.Sprintf("%s/%d", peer.IP.String(), netmask), // take it from the network
 		SshConfig: &proto.SSHConfig{SshEnabled: peer.SSHEnabled},
 		Fqdn:      fqdn,
+		Labels:    peer.Labels,
 	}
 }
 
-func toRemotePeerConfig(peers []*nbpeer.Peer, dnsName string) []*proto.RemotePeerConfig {
+func toRemotePeerConfig(peers []*nbpeer.Peer, dnsName string, bandwidthLimits map[string]uint64, peerGroups map[string][]string) []*proto.RemotePeerConfig {
 	remotePeers := []*proto.RemotePeerConfig{}
 	for _, rPeer := range peers {
 		fqdn := rPeer.FQDN(dnsName)
 		remotePeers = append(remotePeers, &proto.RemotePeerConfig{
-			WgPubKey:   rPeer.Key,
-			AllowedIps: []string{fmt.Sprintf(AllowedIPsFormat, rPeer.IP)},
-			SshConfig:  &proto.SSHConfig{SshPubKey: []byte(rPeer.SSHKey)},
-			Fqdn:       fqdn,
+			WgPubKey:       rPeer.Key,
+			AllowedIps:     []string{fmt.Sprintf(AllowedIPsFormat, rPeer.IP)},
+			SshConfig:      &proto.SSHConfig{SshPubKey: []byte(rPeer.SSHKey)},
+			Fqdn:           fqdn,
+			Capabilities:   rPeer.Meta.Capabilities,
+			BandwidthLimit: bandwidthLimits[rPeer.ID],
+			Groups:         peerGroups[rPeer.ID],
+			Services:       toProtocolPeerServices(rPeer.Meta.Services),
+			Labels:         rPeer.Labels,
 		})
 	}
 	return remotePeers
@@ -446,14 +516,18 @@ func toSyncResponse(config *Config, peer *nbpeer.Peer, turnCredentials *TURNCred
 	wtConfig := toWiretrusteeConfig(config, turnCredentials)
 
 	pConfig := toPeerConfig(peer, networkMap.Network, dnsName)
+	pConfig.LatencyReportsEnabled = networkMap.LatencyReportsEnabled
+	pConfig.UsageReportsEnabled = networkMap.UsageReportsEnabled
+	pConfig.DefaultDeny = networkMap.DefaultDeny
+	pConfig.AppRouting = toProtocolAppRouting(networkMap.AppRouting)
 
-	remotePeers := toRemotePeerConfig(networkMap.Peers, dnsName)
+	remotePeers := toRemotePeerConfig(networkMap.Peers, dnsName, networkMap.BandwidthLimits, networkMap.PeerGroups)
 
 	routesUpdate := toProtocolRoutes(networkMap.Routes)
 
 	dnsUpdate := toProtocolDNSConfig(networkMap.DNSConfig)
 
-	offlinePeers := toRemotePeerConfig(networkMap.OfflinePeers, dnsName)
+	offlinePeers := toRemotePeerConfig(networkMap.OfflinePeers, dnsName, nil, networkMap.PeerGroups)
 
 	firewallRules := toProtocolFirewallRules(networkMap.FirewallRules)
 
@@ -473,6 +547,7 @@ func toSyncResponse(config *Config, peer *nbpeer.Peer, turnCredentials *TURNCred
 			FirewallRules:        firewallRules,
 			FirewallRulesIsEmpty: len(firewallRules) == 0,
 		},
+		LoginExpired: networkMap.LoginExpired,
 	}
 }
 
@@ -482,32 +557,52 @@ func (s *GRPCServer) IsHealthy(ctx context.Context, req *proto.Empty) (*proto.Em
 }
 
 // sendInitialSync sends initial proto.SyncResponse to the peer requesting synchronization
-func (s *GRPCServer) sendInitialSync(peerKey wgtypes.Key, peer *nbpeer.Peer, networkMap *NetworkMap, srv proto.ManagementService_SyncServer) error {
+func (s *GRPCServer) sendInitialSync(ctx context.Context, peerKey wgtypes.Key, peer *nbpeer.Peer, networkMap *NetworkMap, chunksSupported bool, srv proto.ManagementService_SyncServer) error {
 	// make secret time based TURN credentials optional
 	var turnCredentials *TURNCredentials
 	if s.config.TURNConfig.TimeBasedCredentials {
-		creds := s.turnCredentialsManager.GenerateCredentials()
+		creds := s.turnCredentialsManager.GenerateCredentials(peer.ID)
 		turnCredentials = &creds
 	} else {
 		turnCredentials = nil
 	}
 	plainResp := toSyncResponse(s.config, peer, turnCredentials, networkMap, s.accountManager.GetDNSDomain())
+	plainResp.TraceContext = tracing.Inject(ctx)
 
-	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, plainResp)
-	if err != nil {
+	if err := s.sendSyncResponse(peerKey, plainResp, chunksSupported, srv); err != nil {
+		log.Errorf("failed sending SyncResponse %v", err)
 		return status.Errorf(codes.Internal, "error handling request")
 	}
 
-	err = srv.Send(&proto.EncryptedMessage{
-		WgPubKey: s.wgKey.PublicKey().String(),
-		Body:     encryptedResp,
-	})
+	return nil
+}
 
-	if err != nil {
-		log.Errorf("failed sending SyncResponse %v", err)
-		return status.Errorf(codes.Internal, "error handling request")
+// sendSyncResponse encrypts and sends the sync response to the peer. Large responses are compressed and sent in
+// chunks to the clients supporting them
+func (s *GRPCServer) sendSyncResponse(peerKey wgtypes.Key, resp *proto.SyncResponse, chunksSupported bool, srv proto.ManagementService_SyncServer) error {
+	messages := []*proto.SyncResponse{resp}
+	if chunksSupported {
+		var err error
+		messages, err = proto.ChunkSyncResponse(resp, proto.ChunkSize)
+		if err != nil {
+			return err
+		}
 	}
 
+	for _, message := range messages {
+		encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, message)
+		if err != nil {
+			return fmt.Errorf("encrypt sync response: %w", err)
+		}
+
+		err = srv.Send(&proto.EncryptedMessage{
+			WgPubKey: s.wgKey.PublicKey().String(),
+			Body:     encryptedResp,
+		})
+		if err != nil {
+			return fmt.Errorf("send sync response: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -608,3 +703,183 @@ func (s *GRPCServer) GetPKCEAuthorizationFlow(_ context.Context, req *proto.Encr
 		Body:     encryptedResp,
 	}, nil
 }
+
+// AdvertiseRoutes creates routes to the networks reachable through the requesting peer and removes the withdrawn ones.
+// The account settings decide if peers can advertise routes and if the routes wait for the approval of an admin
+func (s *GRPCServer) AdvertiseRoutes(_ context.Context, req *proto.EncryptedMessage) (*proto.EncryptedMessage, error) {
+	advertiseReq := &proto.AdvertiseRoutesRequest{}
+	peerKey, err := s.parseRequest(req, advertiseReq)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := s.accountManager.AdvertiseRoutes(peerKey.String(), advertiseReq.GetNetID(), advertiseReq.GetNetworks(), advertiseReq.GetWithdrawnNetworks())
+	if err != nil {
+		log.Debugf("failed advertising routes of peer %s: %v", peerKey, err)
+		// validation errors of the networks are reported back to the peer
+		if e, ok := internalStatus.FromError(err); ok && (e.Type() == internalStatus.InvalidArgument || e.Type() == internalStatus.AlreadyExists) {
+			return nil, status.Errorf(codes.InvalidArgument, e.Message)
+		}
+		return nil, mapError(err)
+	}
+
+	advertiseResp := &proto.AdvertiseRoutesResponse{}
+	for _, r := range routes {
+		advertiseResp.Routes = append(advertiseResp.Routes, &proto.AdvertisedRoute{
+			ID:              r.ID,
+			Network:         r.Network.String(),
+			NetID:           r.NetID,
+			PendingApproval: !r.Enabled,
+		})
+	}
+
+	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, advertiseResp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encrypt advertise routes response")
+	}
+
+	return &proto.EncryptedMessage{
+		WgPubKey: s.wgKey.PublicKey().String(),
+		Body:     encryptedResp,
+	}, nil
+}
+
+// ReportLatency stores the latency measured by the requesting peer to other peers.
+// The reports are rejected unless the account of the peer enables them.
+func (s *GRPCServer) ReportLatency(_ context.Context, req *proto.EncryptedMessage) (*proto.EncryptedMessage, error) {
+	report := &proto.LatencyReport{}
+	peerKey, err := s.parseRequest(req, report)
+	if err != nil {
+		return nil, err
+	}
+
+	measurements := make([]PeerLatencyMeasurement, 0, len(report.GetLatencies()))
+	for _, latency := range report.GetLatencies() {
+		measurements = append(measurements, PeerLatencyMeasurement{
+			PeerKey:  latency.GetWgPubKey(),
+			RTT:      time.Duration(latency.GetRttMicros()) * time.Microsecond,
+			Sent:     latency.GetSent(),
+			Received: latency.GetReceived(),
+		})
+	}
+
+	err = s.accountManager.ReportPeerLatency(peerKey.String(), measurements)
+	if err != nil {
+		log.Debugf("failed storing latency report of peer %s: %v", peerKey, err)
+		if e, ok := internalStatus.FromError(err); ok && e.Type() == internalStatus.InvalidArgument {
+			return nil, status.Errorf(codes.InvalidArgument, e.Message)
+		}
+		return nil, mapError(err)
+	}
+
+	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, &proto.Empty{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encrypt latency report response")
+	}
+
+	return &proto.EncryptedMessage{
+		WgPubKey: s.wgKey.PublicKey().String(),
+		Body:     encryptedResp,
+	}, nil
+}
+
+// ReportActiveRoutes stores the routes chosen by the requesting peer among the routes of the same network
+func (s *GRPCServer) ReportActiveRoutes(_ context.Context, req *proto.EncryptedMessage) (*proto.EncryptedMessage, error) {
+	report := &proto.ActiveRoutesReport{}
+	peerKey, err := s.parseRequest(req, report)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]ActiveRouteReport, 0, len(report.GetRoutes()))
+	for _, activeRoute := range report.GetRoutes() {
+		reports = append(reports, ActiveRouteReport{
+			RouteID: activeRoute.GetRouteID(),
+			PeerKey: activeRoute.GetWgPubKey(),
+		})
+	}
+
+	err = s.accountManager.ReportActiveRoutes(peerKey.String(), reports)
+	if err != nil {
+		log.Debugf("failed storing active routes report of peer %s: %v", peerKey, err)
+		if e, ok := internalStatus.FromError(err); ok && e.Type() == internalStatus.InvalidArgument {
+			return nil, status.Errorf(codes.InvalidArgument, e.Message)
+		}
+		return nil, mapError(err)
+	}
+
+	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, &proto.Empty{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encrypt active routes report response")
+	}
+
+	return &proto.EncryptedMessage{
+		WgPubKey: s.wgKey.PublicKey().String(),
+		Body:     encryptedResp,
+	}, nil
+}
+
+// ReportUsage stores the daily traffic of the requesting peer over the tunnel.
+// The reports are rejected unless the account of the peer enables them.
+func (s *GRPCServer) ReportUsage(_ context.Context, req *proto.EncryptedMessage) (*proto.EncryptedMessage, error) {
+	report := &proto.UsageReport{}
+	peerKey, err := s.parseRequest(req, report)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]PeerDailyUsage, 0, len(report.GetDays()))
+	for _, day := range report.GetDays() {
+		days = append(days, PeerDailyUsage{
+			Date:    day.GetDate(),
+			RxBytes: day.GetRxBytes(),
+			TxBytes: day.GetTxBytes(),
+		})
+	}
+
+	err = s.accountManager.ReportPeerUsage(peerKey.String(), days)
+	if err != nil {
+		log.Debugf("failed storing usage report of peer %s: %v", peerKey, err)
+		if e, ok := internalStatus.FromError(err); ok && e.Type() == internalStatus.InvalidArgument {
+			return nil, status.Errorf(codes.InvalidArgument, e.Message)
+		}
+		return nil, mapError(err)
+	}
+
+	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, &proto.Empty{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encrypt usage report response")
+	}
+
+	return &proto.EncryptedMessage{
+		WgPubKey: s.wgKey.PublicKey().String(),
+		Body:     encryptedResp,
+	}, nil
+}
+
+// GoingOffline marks the requesting peer disconnected when it shuts down gracefully, without waiting for its Sync
+// stream to be closed
+func (s *GRPCServer) GoingOffline(_ context.Context, req *proto.EncryptedMessage) (*proto.EncryptedMessage, error) {
+	peerKey, err := s.parseRequest(req, &proto.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("peer %s is going offline", peerKey)
+
+	err = s.accountManager.MarkPeerConnected(peerKey.String(), false)
+	if err != nil {
+		log.Debugf("failed marking peer %s as disconnected: %v", peerKey, err)
+		return nil, mapError(err)
+	}
+
+	encryptedResp, err := encryption.EncryptMessage(peerKey, s.wgKey, &proto.Empty{})
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to encrypt going offline response")
+	}
+
+	return &proto.EncryptedMessage{
+		WgPubKey: s.wgKey.PublicKey().String(),
+		Body:     encryptedResp,
+	}, nil
+}
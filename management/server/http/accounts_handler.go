@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"net/netip"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -94,6 +95,56 @@ func (h *AccountsHandler) UpdateAccount(w http.ResponseWriter, r *http.Request)
 	if req.Settings.JwtAllowGroups != nil {
 		settings.JWTAllowGroups = *req.Settings.JwtAllowGroups
 	}
+	if req.Settings.JwtGroupMappingRules != nil {
+		settings.JWTGroupMappingRules = toJWTGroupMappingRules(*req.Settings.JwtGroupMappingRules)
+	}
+	if req.Settings.PeerLoginExpiredAccessGroups != nil {
+		settings.PeerLoginExpiredAccessGroups = *req.Settings.PeerLoginExpiredAccessGroups
+	}
+	if req.Settings.RouteAdvertisementEnabled != nil {
+		settings.RouteAdvertisementEnabled = *req.Settings.RouteAdvertisementEnabled
+	}
+	if req.Settings.RouteAdvertisementApprovalRequired != nil {
+		settings.RouteAdvertisementApprovalRequired = *req.Settings.RouteAdvertisementApprovalRequired
+	}
+	if req.Settings.RouteAdvertisementGroups != nil {
+		settings.RouteAdvertisementGroups = *req.Settings.RouteAdvertisementGroups
+	}
+	if req.Settings.PeerLatencyReportsEnabled != nil {
+		settings.PeerLatencyReportsEnabled = *req.Settings.PeerLatencyReportsEnabled
+	}
+	if req.Settings.PeerDefaultDenyEnabled != nil {
+		settings.PeerDefaultDenyEnabled = *req.Settings.PeerDefaultDenyEnabled
+	}
+	if req.Settings.PeerUsageReportsEnabled != nil {
+		settings.PeerUsageReportsEnabled = *req.Settings.PeerUsageReportsEnabled
+	}
+	if req.Settings.PeerInactivityExpirationEnabled != nil {
+		settings.PeerInactivityExpirationEnabled = *req.Settings.PeerInactivityExpirationEnabled
+	}
+	if req.Settings.PeerInactivityExpiration != nil {
+		settings.PeerInactivityExpiration = time.Duration(*req.Settings.PeerInactivityExpiration) * time.Second
+	}
+	if req.Settings.PeerInactivityAction != nil {
+		settings.PeerInactivityAction = string(*req.Settings.PeerInactivityAction)
+	}
+	if req.Settings.NetworkRange != nil {
+		settings.NetworkRange, err = netip.ParsePrefix(*req.Settings.NetworkRange)
+		if err != nil {
+			util.WriteError(status.Errorf(status.InvalidArgument, "invalid network range %s", *req.Settings.NetworkRange), w)
+			return
+		}
+	}
+	if req.Settings.IpPools != nil {
+		for _, pool := range *req.Settings.IpPools {
+			prefix, err := netip.ParsePrefix(pool)
+			if err != nil {
+				util.WriteError(status.Errorf(status.InvalidArgument, "invalid IP pool %s", pool), w)
+				return
+			}
+			settings.IPPools = append(settings.IPPools, prefix)
+		}
+	}
 
 	updatedAccount, err := h.accountManager.UpdateAccountSettings(accountID, user.Id, settings)
 	if err != nil {
@@ -143,6 +194,46 @@ func toAccountResponse(account *server.Account) *api.Account {
 		JwtGroupsEnabled:           &account.Settings.JWTGroupsEnabled,
 		JwtGroupsClaimName:         &account.Settings.JWTGroupsClaimName,
 		JwtAllowGroups:             &jwtAllowGroups,
+
+		RouteAdvertisementEnabled:          &account.Settings.RouteAdvertisementEnabled,
+		RouteAdvertisementApprovalRequired: &account.Settings.RouteAdvertisementApprovalRequired,
+
+		PeerLatencyReportsEnabled: &account.Settings.PeerLatencyReportsEnabled,
+		PeerDefaultDenyEnabled:    &account.Settings.PeerDefaultDenyEnabled,
+		PeerUsageReportsEnabled:   &account.Settings.PeerUsageReportsEnabled,
+
+		PeerInactivityExpirationEnabled: &account.Settings.PeerInactivityExpirationEnabled,
+	}
+
+	if account.Settings.PeerInactivityExpiration > 0 {
+		inactivityExpiration := int(account.Settings.PeerInactivityExpiration.Seconds())
+		settings.PeerInactivityExpiration = &inactivityExpiration
+	}
+	if account.Settings.PeerInactivityAction != "" {
+		inactivityAction := api.AccountSettingsPeerInactivityAction(account.Settings.PeerInactivityAction)
+		settings.PeerInactivityAction = &inactivityAction
+	}
+
+	if len(account.Settings.JWTGroupMappingRules) > 0 {
+		rules := toJWTGroupMappingRulesResponse(account.Settings.JWTGroupMappingRules)
+		settings.JwtGroupMappingRules = &rules
+	}
+	if len(account.Settings.PeerLoginExpiredAccessGroups) > 0 {
+		settings.PeerLoginExpiredAccessGroups = &account.Settings.PeerLoginExpiredAccessGroups
+	}
+	if len(account.Settings.RouteAdvertisementGroups) > 0 {
+		settings.RouteAdvertisementGroups = &account.Settings.RouteAdvertisementGroups
+	}
+	if account.Network != nil {
+		networkRange := account.Network.Prefix().String()
+		settings.NetworkRange = &networkRange
+	}
+	if len(account.Settings.IPPools) > 0 {
+		pools := make([]string, 0, len(account.Settings.IPPools))
+		for _, pool := range account.Settings.IPPools {
+			pools = append(pools, pool.String())
+		}
+		settings.IpPools = &pools
 	}
 
 	if account.Settings.Extra != nil {
@@ -154,3 +245,39 @@ func toAccountResponse(account *server.Account) *api.Account {
 		Settings: settings,
 	}
 }
+
+func toJWTGroupMappingRules(apiRules []api.JWTGroupMappingRule) []server.JWTGroupMappingRule {
+	rules := make([]server.JWTGroupMappingRule, 0, len(apiRules))
+	for _, apiRule := range apiRules {
+		rule := server.JWTGroupMappingRule{
+			Match:  apiRule.Match,
+			Groups: apiRule.Groups,
+		}
+		if apiRule.Claim != nil {
+			rule.Claim = *apiRule.Claim
+		}
+		if apiRule.Priority != nil {
+			rule.Priority = *apiRule.Priority
+		}
+		if apiRule.Final != nil {
+			rule.Final = *apiRule.Final
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func toJWTGroupMappingRulesResponse(rules []server.JWTGroupMappingRule) []api.JWTGroupMappingRule {
+	apiRules := make([]api.JWTGroupMappingRule, 0, len(rules))
+	for _, rule := range rules {
+		rule := rule
+		apiRules = append(apiRules, api.JWTGroupMappingRule{
+			Claim:    &rule.Claim,
+			Match:    rule.Match,
+			Groups:   rule.Groups,
+			Priority: &rule.Priority,
+			Final:    &rule.Final,
+		})
+	}
+	return apiRules
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -58,11 +59,15 @@ func TestAccounts_AccountsHandler(t *testing.T) {
 
 	sr := func(v string) *string { return &v }
 	br := func(v bool) *bool { return &v }
+	ir := func(v int) *int { return &v }
+	ar := func(v api.AccountSettingsPeerInactivityAction) *api.AccountSettingsPeerInactivityAction { return &v }
 
 	handler := initAccountsTestData(&server.Account{
-		Id:      accountID,
-		Domain:  "hotmail.com",
-		Network: server.NewNetwork(),
+		Id:     accountID,
+		Domain: "hotmail.com",
+		Network: &server.Network{
+			Net: net.IPNet{IP: net.ParseIP("100.70.0.0").To4(), Mask: net.CIDRMask(16, 32)},
+		},
 		Users: map[string]*server.User{
 			adminUser.Id: adminUser,
 		},
@@ -90,12 +95,19 @@ func TestAccounts_AccountsHandler(t *testing.T) {
 			requestPath:    "/api/accounts",
 			expectedStatus: http.StatusOK,
 			expectedSettings: api.AccountSettings{
-				PeerLoginExpiration:        int(time.Hour.Seconds()),
-				PeerLoginExpirationEnabled: false,
-				GroupsPropagationEnabled:   br(false),
-				JwtGroupsClaimName:         sr(""),
-				JwtGroupsEnabled:           br(false),
-				JwtAllowGroups:             &[]string{},
+				PeerLoginExpiration:                int(time.Hour.Seconds()),
+				PeerLoginExpirationEnabled:         false,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
 			},
 			expectedArray: true,
 			expectedID:    accountID,
@@ -108,12 +120,19 @@ func TestAccounts_AccountsHandler(t *testing.T) {
 			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 15552000,\"peer_login_expiration_enabled\": true}}"),
 			expectedStatus: http.StatusOK,
 			expectedSettings: api.AccountSettings{
-				PeerLoginExpiration:        15552000,
-				PeerLoginExpirationEnabled: true,
-				GroupsPropagationEnabled:   br(false),
-				JwtGroupsClaimName:         sr(""),
-				JwtGroupsEnabled:           br(false),
-				JwtAllowGroups:             &[]string{},
+				PeerLoginExpiration:                15552000,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
 			},
 			expectedArray: false,
 			expectedID:    accountID,
@@ -126,12 +145,19 @@ func TestAccounts_AccountsHandler(t *testing.T) {
 			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 15552000,\"peer_login_expiration_enabled\": false,\"jwt_groups_enabled\":true,\"jwt_groups_claim_name\":\"roles\",\"jwt_allow_groups\":[\"test\"]}}"),
 			expectedStatus: http.StatusOK,
 			expectedSettings: api.AccountSettings{
-				PeerLoginExpiration:        15552000,
-				PeerLoginExpirationEnabled: false,
-				GroupsPropagationEnabled:   br(false),
-				JwtGroupsClaimName:         sr("roles"),
-				JwtGroupsEnabled:           br(true),
-				JwtAllowGroups:             &[]string{"test"},
+				PeerLoginExpiration:                15552000,
+				PeerLoginExpirationEnabled:         false,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr("roles"),
+				JwtGroupsEnabled:                   br(true),
+				JwtAllowGroups:                     &[]string{"test"},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
 			},
 			expectedArray: false,
 			expectedID:    accountID,
@@ -144,16 +170,187 @@ func TestAccounts_AccountsHandler(t *testing.T) {
 			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"jwt_groups_enabled\":true,\"jwt_groups_claim_name\":\"groups\",\"groups_propagation_enabled\":true}}"),
 			expectedStatus: http.StatusOK,
 			expectedSettings: api.AccountSettings{
-				PeerLoginExpiration:        554400,
-				PeerLoginExpirationEnabled: true,
-				GroupsPropagationEnabled:   br(true),
-				JwtGroupsClaimName:         sr("groups"),
-				JwtGroupsEnabled:           br(true),
-				JwtAllowGroups:             &[]string{},
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(true),
+				JwtGroupsClaimName:                 sr("groups"),
+				JwtGroupsEnabled:                   br(true),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "PutAccount OK with expired peer access groups",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"peer_login_expired_access_groups\":[\"helpdesk\"]}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+				PeerLoginExpiredAccessGroups:       &[]string{"helpdesk"},
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "PutAccount OK with route advertisement",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"route_advertisement_enabled\":true,\"route_advertisement_approval_required\":true,\"route_advertisement_groups\":[\"gateways\"]}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(true),
+				RouteAdvertisementApprovalRequired: br(true),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+				RouteAdvertisementGroups:           &[]string{"gateways"},
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "PutAccount OK with peer latency reports",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"peer_latency_reports_enabled\":true}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(true),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "PutAccount OK with peer default deny",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"peer_default_deny_enabled\":true}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(true),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "PutAccount OK with peer inactivity expiration",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"peer_inactivity_expiration_enabled\":true,\"peer_inactivity_expiration\":7776000,\"peer_inactivity_action\":\"delete\"}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(true),
+				PeerInactivityExpiration:           ir(7776000),
+				PeerInactivityAction:               ar(api.AccountSettingsPeerInactivityActionDelete),
+				NetworkRange:                       sr("100.70.0.0/16"),
 			},
 			expectedArray: false,
 			expectedID:    accountID,
 		},
+		{
+			name:           "PutAccount OK with IP pools",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"ip_pools\":[\"100.70.1.0/24\"]}}"),
+			expectedStatus: http.StatusOK,
+			expectedSettings: api.AccountSettings{
+				PeerLoginExpiration:                554400,
+				PeerLoginExpirationEnabled:         true,
+				GroupsPropagationEnabled:           br(false),
+				JwtGroupsClaimName:                 sr(""),
+				JwtGroupsEnabled:                   br(false),
+				JwtAllowGroups:                     &[]string{},
+				RouteAdvertisementEnabled:          br(false),
+				RouteAdvertisementApprovalRequired: br(false),
+				PeerLatencyReportsEnabled:          br(false),
+				PeerDefaultDenyEnabled:             br(false),
+				PeerUsageReportsEnabled:            br(false),
+				PeerInactivityExpirationEnabled:    br(false),
+				NetworkRange:                       sr("100.70.0.0/16"),
+				IpPools:                            &[]string{"100.70.1.0/24"},
+			},
+			expectedArray: false,
+			expectedID:    accountID,
+		},
+		{
+			name:           "Update account failure with invalid network range",
+			expectedBody:   true,
+			requestType:    http.MethodPut,
+			requestPath:    "/api/accounts/" + accountID,
+			requestBody:    bytes.NewBufferString("{\"settings\": {\"peer_login_expiration\": 554400,\"peer_login_expiration_enabled\": true,\"network_range\":\"100.70.0.0\"}}"),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedArray:  false,
+		},
 		{
 			name:           "Update account failure with high peer_login_expiration more than 180 days",
 			expectedBody:   true,
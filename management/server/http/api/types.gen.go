@@ -12,6 +12,12 @@ const (
 	TokenAuthScopes  = "TokenAuth.Scopes"
 )
 
+// Defines values for AccountSettingsPeerInactivityAction.
+const (
+	AccountSettingsPeerInactivityActionDelete  AccountSettingsPeerInactivityAction = "delete"
+	AccountSettingsPeerInactivityActionDisable AccountSettingsPeerInactivityAction = "disable"
+)
+
 // Defines values for EventActivityCode.
 const (
 	EventActivityCodeAccountCreate                            EventActivityCode = "account.create"
@@ -63,11 +69,68 @@ const (
 	EventActivityCodeUserUnblock                              EventActivityCode = "user.unblock"
 )
 
+// Defines values for GroupAppRoutingMode.
+const (
+	GroupAppRoutingModeDisabled GroupAppRoutingMode = "disabled"
+	GroupAppRoutingModeExclude  GroupAppRoutingMode = "exclude"
+	GroupAppRoutingModeInclude  GroupAppRoutingMode = "include"
+)
+
+// Defines values for LiveEventType.
+const (
+	LiveEventTypeActivity   LiveEventType = "activity"
+	LiveEventTypePeerStatus LiveEventType = "peer_status"
+)
+
 // Defines values for NameserverNsType.
 const (
 	NameserverNsTypeUdp NameserverNsType = "udp"
 )
 
+// Defines values for NetworkMapFirewallRuleAction.
+const (
+	NetworkMapFirewallRuleActionAccept NetworkMapFirewallRuleAction = "accept"
+	NetworkMapFirewallRuleActionDrop   NetworkMapFirewallRuleAction = "drop"
+)
+
+// Defines values for NetworkMapFirewallRuleDirection.
+const (
+	NetworkMapFirewallRuleDirectionIn  NetworkMapFirewallRuleDirection = "in"
+	NetworkMapFirewallRuleDirectionOut NetworkMapFirewallRuleDirection = "out"
+)
+
+// Defines values for NetworkMapFirewallRuleProtocol.
+const (
+	NetworkMapFirewallRuleProtocolAll  NetworkMapFirewallRuleProtocol = "all"
+	NetworkMapFirewallRuleProtocolGre  NetworkMapFirewallRuleProtocol = "gre"
+	NetworkMapFirewallRuleProtocolIcmp NetworkMapFirewallRuleProtocol = "icmp"
+	NetworkMapFirewallRuleProtocolSctp NetworkMapFirewallRuleProtocol = "sctp"
+	NetworkMapFirewallRuleProtocolTcp  NetworkMapFirewallRuleProtocol = "tcp"
+	NetworkMapFirewallRuleProtocolUdp  NetworkMapFirewallRuleProtocol = "udp"
+)
+
+// Defines values for PeerBulkJobStatus.
+const (
+	PeerBulkJobStatusCompleted PeerBulkJobStatus = "completed"
+	PeerBulkJobStatusFailed    PeerBulkJobStatus = "failed"
+	PeerBulkJobStatusPending   PeerBulkJobStatus = "pending"
+	PeerBulkJobStatusRunning   PeerBulkJobStatus = "running"
+)
+
+// Defines values for PeerBulkRequestAction.
+const (
+	PeerBulkRequestActionDelete         PeerBulkRequestAction = "delete"
+	PeerBulkRequestActionExpireSessions PeerBulkRequestAction = "expire_sessions"
+	PeerBulkRequestActionMoveToGroup    PeerBulkRequestAction = "move_to_group"
+	PeerBulkRequestActionSetSshEnabled  PeerBulkRequestAction = "set_ssh_enabled"
+)
+
+// Defines values for PeerType.
+const (
+	PeerTypeService    PeerType = "service"
+	PeerTypeUserDevice PeerType = "user_device"
+)
+
 // Defines values for PolicyRuleAction.
 const (
 	PolicyRuleActionAccept PolicyRuleAction = "accept"
@@ -77,7 +140,9 @@ const (
 // Defines values for PolicyRuleProtocol.
 const (
 	PolicyRuleProtocolAll  PolicyRuleProtocol = "all"
+	PolicyRuleProtocolGre  PolicyRuleProtocol = "gre"
 	PolicyRuleProtocolIcmp PolicyRuleProtocol = "icmp"
+	PolicyRuleProtocolSctp PolicyRuleProtocol = "sctp"
 	PolicyRuleProtocolTcp  PolicyRuleProtocol = "tcp"
 	PolicyRuleProtocolUdp  PolicyRuleProtocol = "udp"
 )
@@ -91,7 +156,9 @@ const (
 // Defines values for PolicyRuleMinimumProtocol.
 const (
 	PolicyRuleMinimumProtocolAll  PolicyRuleMinimumProtocol = "all"
+	PolicyRuleMinimumProtocolGre  PolicyRuleMinimumProtocol = "gre"
 	PolicyRuleMinimumProtocolIcmp PolicyRuleMinimumProtocol = "icmp"
+	PolicyRuleMinimumProtocolSctp PolicyRuleMinimumProtocol = "sctp"
 	PolicyRuleMinimumProtocolTcp  PolicyRuleMinimumProtocol = "tcp"
 	PolicyRuleMinimumProtocolUdp  PolicyRuleMinimumProtocol = "udp"
 )
@@ -105,11 +172,38 @@ const (
 // Defines values for PolicyRuleUpdateProtocol.
 const (
 	PolicyRuleUpdateProtocolAll  PolicyRuleUpdateProtocol = "all"
+	PolicyRuleUpdateProtocolGre  PolicyRuleUpdateProtocol = "gre"
 	PolicyRuleUpdateProtocolIcmp PolicyRuleUpdateProtocol = "icmp"
+	PolicyRuleUpdateProtocolSctp PolicyRuleUpdateProtocol = "sctp"
 	PolicyRuleUpdateProtocolTcp  PolicyRuleUpdateProtocol = "tcp"
 	PolicyRuleUpdateProtocolUdp  PolicyRuleUpdateProtocol = "udp"
 )
 
+// Defines values for PolicySimulationMatchAction.
+const (
+	PolicySimulationMatchActionAccept PolicySimulationMatchAction = "accept"
+	PolicySimulationMatchActionDrop   PolicySimulationMatchAction = "drop"
+)
+
+// Defines values for PolicySimulationRequestProtocol.
+const (
+	PolicySimulationRequestProtocolGre  PolicySimulationRequestProtocol = "gre"
+	PolicySimulationRequestProtocolIcmp PolicySimulationRequestProtocol = "icmp"
+	PolicySimulationRequestProtocolSctp PolicySimulationRequestProtocol = "sctp"
+	PolicySimulationRequestProtocolTcp  PolicySimulationRequestProtocol = "tcp"
+	PolicySimulationRequestProtocolUdp  PolicySimulationRequestProtocol = "udp"
+)
+
+// Defines values for ServiceProtocolProtocol.
+const (
+	ServiceProtocolProtocolAll  ServiceProtocolProtocol = "all"
+	ServiceProtocolProtocolGre  ServiceProtocolProtocol = "gre"
+	ServiceProtocolProtocolIcmp ServiceProtocolProtocol = "icmp"
+	ServiceProtocolProtocolSctp ServiceProtocolProtocol = "sctp"
+	ServiceProtocolProtocolTcp  ServiceProtocolProtocol = "tcp"
+	ServiceProtocolProtocolUdp  ServiceProtocolProtocol = "udp"
+)
+
 // Defines values for UserStatus.
 const (
 	UserStatusActive  UserStatus = "active"
@@ -160,26 +254,94 @@ type AccountSettings struct {
 	// GroupsPropagationEnabled Allows propagate the new user auto groups to peers that belongs to the user
 	GroupsPropagationEnabled *bool `json:"groups_propagation_enabled,omitempty"`
 
+	// IpPools Ranges of the network range the peer IPs are allocated from, in order. The whole network range is used when empty. Static IPs assigned to peers may be outside the pools.
+	IpPools *[]string `json:"ip_pools,omitempty"`
+
 	// JwtAllowGroups List of groups to which users are allowed access
 	JwtAllowGroups *[]string `json:"jwt_allow_groups,omitempty"`
 
+	// JwtGroupMappingRules Rules mapping the JWT claims to the JWT groups of the users, evaluated on every login and token refresh.
+	// The values of the groups claim are used as they are when empty.
+	JwtGroupMappingRules *[]JWTGroupMappingRule `json:"jwt_group_mapping_rules,omitempty"`
+
 	// JwtGroupsClaimName Name of the claim from which we extract groups names to add it to account groups.
 	JwtGroupsClaimName *string `json:"jwt_groups_claim_name,omitempty"`
 
 	// JwtGroupsEnabled Allows extract groups from JWT claim and add it to account groups.
 	JwtGroupsEnabled *bool `json:"jwt_groups_enabled,omitempty"`
 
+	// NetworkRange IPv4 network range of the account in CIDR format. The peers whose IP is outside a new range get a new IP, which is pushed to them without re-enrollment. The range is kept when it isn't set.
+	NetworkRange *string `json:"network_range,omitempty"`
+
+	// PeerInactivityAction Action applied to the peers which didn't connect for longer than the inactivity expiration, disable or delete. Defaults to disable.
+	PeerInactivityAction *AccountSettingsPeerInactivityAction `json:"peer_inactivity_action,omitempty"`
+
+	// PeerInactivityExpiration Period of time without connection after which a peer is disabled or deleted (seconds). Between one day and 365 days.
+	PeerInactivityExpiration *int `json:"peer_inactivity_expiration,omitempty"`
+
+	// PeerInactivityExpirationEnabled Enables or disables the expiration of the peers which didn't connect to the Management service for longer than the inactivity expiration. A warning event is stored before the action.
+	PeerInactivityExpirationEnabled *bool `json:"peer_inactivity_expiration_enabled,omitempty"`
+
 	// PeerLoginExpiration Period of time after which peer login expires (seconds).
 	PeerLoginExpiration int `json:"peer_login_expiration"`
 
 	// PeerLoginExpirationEnabled Enables or disables peer login expiration globally. After peer's login has expired the user has to log in (authenticate). Applies only to peers that were added by a user (interactive SSO login).
 	PeerLoginExpirationEnabled bool `json:"peer_login_expiration_enabled"`
+
+	// PeerLatencyReportsEnabled Makes the peers periodically measure the latency to a sample of the other peers and report it for the network health overview.
+	PeerLatencyReportsEnabled *bool `json:"peer_latency_reports_enabled,omitempty"`
+
+	// PeerDefaultDenyEnabled Makes the peers drop all the traffic of their NetBird interface which isn't allowed by a policy, including the traffic exchanged with the routed networks.
+	PeerDefaultDenyEnabled *bool `json:"peer_default_deny_enabled,omitempty"`
+
+	// PeerUsageReportsEnabled Makes the peers report their daily traffic over the tunnel for the usage overview.
+	PeerUsageReportsEnabled *bool `json:"peer_usage_reports_enabled,omitempty"`
+
+	// PeerLoginExpiredAccessGroups List of group IDs whose peers stay reachable by peers with an expired login until they log in again.
+	// Policies still apply, the other peers are disconnected and the peer is asked to log in.
+	PeerLoginExpiredAccessGroups *[]string `json:"peer_login_expired_access_groups,omitempty"`
+
+	// RouteAdvertisementApprovalRequired Creates the routes advertised by peers disabled until an admin enables them.
+	RouteAdvertisementApprovalRequired *bool `json:"route_advertisement_approval_required,omitempty"`
+
+	// RouteAdvertisementEnabled Allows peers to request routes to their networks with the netbird routes advertise command.
+	RouteAdvertisementEnabled *bool `json:"route_advertisement_enabled,omitempty"`
+
+	// RouteAdvertisementGroups List of group IDs the routes advertised by peers are distributed to. The All group is used when empty.
+	RouteAdvertisementGroups *[]string `json:"route_advertisement_groups,omitempty"`
+}
+
+// AccountSettingsPeerInactivityAction Action applied to the peers which didn't connect for longer than the inactivity expiration, disable or delete. Defaults to disable.
+type AccountSettingsPeerInactivityAction string
+
+// ActiveRoute defines model for ActiveRoute.
+type ActiveRoute struct {
+	// Network Network range of the route in CIDR format
+	Network string `json:"network"`
+
+	// NetworkId Network identifier of the route, grouping the HA routes
+	NetworkId string `json:"network_id"`
+
+	// PeerId ID of the peer that reported the route
+	PeerId string `json:"peer_id"`
+
+	// ReportedAt Time of the report
+	ReportedAt time.Time `json:"reported_at"`
+
+	// RouteId ID of the route chosen by the peer
+	RouteId string `json:"route_id"`
+
+	// RoutingPeerId ID of the routing peer the peer sends the traffic of the network to
+	RoutingPeerId string `json:"routing_peer_id"`
 }
 
 // DNSSettings defines model for DNSSettings.
 type DNSSettings struct {
 	// DisabledManagementGroups Groups whose DNS management is disabled
 	DisabledManagementGroups []string `json:"disabled_management_groups"`
+
+	// SearchDomainGroups Search domains assigned to the peers of groups
+	SearchDomainGroups *[]SearchDomainGroup `json:"search_domain_groups,omitempty"`
 }
 
 // Event defines model for Event.
@@ -217,12 +379,27 @@ type EventActivityCode string
 
 // Group defines model for Group.
 type Group struct {
+	// AppRouting Application based split tunneling of the group peers, applied by the Windows clients
+	AppRouting *GroupAppRouting `json:"app_routing,omitempty"`
+
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic of the group peers. 0 means no limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
+	// DnsSuffix DNS suffix under which the group peers are also resolvable, e.g. peer.prod.netbird.cloud for prod
+	DnsSuffix *string `json:"dns_suffix,omitempty"`
+
+	// ExternalId Identifier of the group in an external tool managing it, e.g. a Terraform provider
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Id Group ID
 	Id string `json:"id"`
 
 	// Issued How group was issued by API or from JWT token
 	Issued *string `json:"issued,omitempty"`
 
+	// LoginExpiration Period of time after which the login of the group peers expires (seconds), overriding the account peer_login_expiration. The shortest one of the groups of a peer applies
+	LoginExpiration *int `json:"login_expiration,omitempty"`
+
 	// Name Group Name identifier
 	Name string `json:"name"`
 
@@ -233,14 +410,38 @@ type Group struct {
 	PeersCount int `json:"peers_count"`
 }
 
+// GroupAppRouting Application based split tunneling of the group peers, applied by the Windows clients
+type GroupAppRouting struct {
+	// Applications Executable paths the mode applies to
+	Applications *[]string `json:"applications,omitempty"`
+
+	// Mode Sends only the traffic of the applications into the tunnel when include, all but their traffic when exclude. disabled turns app routing off
+	Mode GroupAppRoutingMode `json:"mode"`
+}
+
+// GroupAppRoutingMode Sends only the traffic of the applications into the tunnel when include, all but their traffic when exclude. disabled turns app routing off
+type GroupAppRoutingMode string
+
 // GroupMinimum defines model for GroupMinimum.
 type GroupMinimum struct {
+	// AppRouting Application based split tunneling of the group peers, applied by the Windows clients
+	AppRouting *GroupAppRouting `json:"app_routing,omitempty"`
+
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic of the group peers. 0 means no limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
+	// DnsSuffix DNS suffix under which the group peers are also resolvable, e.g. peer.prod.netbird.cloud for prod
+	DnsSuffix *string `json:"dns_suffix,omitempty"`
+
 	// Id Group ID
 	Id string `json:"id"`
 
 	// Issued How group was issued by API or from JWT token
 	Issued *string `json:"issued,omitempty"`
 
+	// LoginExpiration Period of time after which the login of the group peers expires (seconds), overriding the account peer_login_expiration. The shortest one of the groups of a peer applies
+	LoginExpiration *int `json:"login_expiration,omitempty"`
+
 	// Name Group Name identifier
 	Name string `json:"name"`
 
@@ -250,6 +451,21 @@ type GroupMinimum struct {
 
 // GroupRequest defines model for GroupRequest.
 type GroupRequest struct {
+	// AppRouting Application based split tunneling of the group peers, applied by the Windows clients
+	AppRouting *GroupAppRouting `json:"app_routing,omitempty"`
+
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic of the group peers. 0 disables the limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
+	// DnsSuffix DNS suffix under which the group peers are also resolvable, e.g. peer.prod.netbird.cloud for prod. An empty suffix disables it
+	DnsSuffix *string `json:"dns_suffix,omitempty"`
+
+	// ExternalId Identifier of the group in an external tool managing it, e.g. a Terraform provider. It must be unique among the groups of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
+	// LoginExpiration Period of time after which the login of the group peers expires (seconds), overriding the account peer_login_expiration. It must be between one hour and 180 days, 0 keeps the account one
+	LoginExpiration *int `json:"login_expiration,omitempty"`
+
 	// Name Group name identifier
 	Name string `json:"name"`
 
@@ -257,6 +473,60 @@ type GroupRequest struct {
 	Peers *[]string `json:"peers,omitempty"`
 }
 
+// JWTGroupMappingRule defines model for JWTGroupMappingRule.
+type JWTGroupMappingRule struct {
+	// Claim Name of the claim holding a string or an array of strings, the groups claim name when empty.
+	Claim *string `json:"claim,omitempty"`
+
+	// Final Stops the evaluation of the following rules when the rule matches a value.
+	Final *bool `json:"final,omitempty"`
+
+	// Groups Names of the groups a matching value maps to, they may reference the submatches of the expression.
+	Groups []string `json:"groups"`
+
+	// Match Regular expression matched against each whole value of the claim.
+	Match string `json:"match"`
+
+	// Priority Order of evaluation of the rule, the lowest first. The rules of the same priority are evaluated in their order.
+	Priority *int `json:"priority,omitempty"`
+}
+
+// LiveEvent defines model for LiveEvent.
+type LiveEvent struct {
+	Event *Event          `json:"event,omitempty"`
+	Peer  *LivePeerStatus `json:"peer,omitempty"`
+
+	// Timestamp The date and time when the event occurred
+	Timestamp time.Time `json:"timestamp"`
+
+	// Type The type of the event, peer_status when a peer connects or disconnects and activity when an activity event is stored
+	Type LiveEventType `json:"type"`
+}
+
+// LiveEventType The type of the event, peer_status when a peer connects or disconnects and activity when an activity event is stored
+type LiveEventType string
+
+// LivePeerStatus defines model for LivePeerStatus.
+type LivePeerStatus struct {
+	// Connected Peer to Management connection status
+	Connected bool `json:"connected"`
+
+	// Id Peer ID
+	Id string `json:"id"`
+
+	// Ip Peer's IP address
+	Ip string `json:"ip"`
+
+	// LastSeen Last time peer connected to Netbird's management service
+	LastSeen time.Time `json:"last_seen"`
+
+	// LoginExpired Indicates whether peer login is expired or not
+	LoginExpired bool `json:"login_expired"`
+
+	// Name Peer's hostname
+	Name string `json:"name"`
+}
+
 // Nameserver defines model for Nameserver.
 type Nameserver struct {
 	// Ip Nameserver IP
@@ -283,6 +553,9 @@ type NameserverGroup struct {
 	// Enabled Nameserver group status
 	Enabled bool `json:"enabled"`
 
+	// ForwardViaRoutes Indicates that the nameservers are reachable only through routes, the queries for the match domains are forwarded to them over the tunnel. It should be true only if domains list is not empty.
+	ForwardViaRoutes bool `json:"forward_via_routes"`
+
 	// Groups Distribution group IDs that defines group of peers that will use this nameserver group
 	Groups []string `json:"groups"`
 
@@ -298,6 +571,9 @@ type NameserverGroup struct {
 	// Primary Defines if a nameserver group is primary that resolves all domains. It should be true only if domains list is empty.
 	Primary bool `json:"primary"`
 
+	// Priority Priority of the nameserver group among the groups distributed to a peer. The group with the lowest value is used when several groups are primary or share a match domain. Defaults to 0.
+	Priority *int `json:"priority,omitempty"`
+
 	// SearchDomainsEnabled Search domain status for match domains. It should be true only if domains list is not empty.
 	SearchDomainsEnabled bool `json:"search_domains_enabled"`
 }
@@ -313,6 +589,9 @@ type NameserverGroupRequest struct {
 	// Enabled Nameserver group status
 	Enabled bool `json:"enabled"`
 
+	// ForwardViaRoutes Indicates that the nameservers are reachable only through routes, the queries for the match domains are forwarded to them over the tunnel. It should be true only if domains list is not empty.
+	ForwardViaRoutes bool `json:"forward_via_routes"`
+
 	// Groups Distribution group IDs that defines group of peers that will use this nameserver group
 	Groups []string `json:"groups"`
 
@@ -325,10 +604,130 @@ type NameserverGroupRequest struct {
 	// Primary Defines if a nameserver group is primary that resolves all domains. It should be true only if domains list is empty.
 	Primary bool `json:"primary"`
 
+	// Priority Priority of the nameserver group among the groups distributed to a peer. The group with the lowest value is used when several groups are primary or share a match domain. Defaults to 0.
+	Priority *int `json:"priority,omitempty"`
+
 	// SearchDomainsEnabled Search domain status for match domains. It should be true only if domains list is not empty.
 	SearchDomainsEnabled bool `json:"search_domains_enabled"`
 }
 
+// NetworkMapDNSConfig defines model for NetworkMapDNSConfig.
+type NetworkMapDNSConfig struct {
+	// CustomZones Zones resolved by the peer, e.g. the zone of the peers of the account
+	CustomZones []NetworkMapDNSZone `json:"custom_zones"`
+
+	// Enabled Indicates whether NetBird manages the DNS of the peer
+	Enabled bool `json:"enabled"`
+
+	// NameserverGroups Nameserver groups the peer forwards the queries to
+	NameserverGroups []NetworkMapNameserverGroup `json:"nameserver_groups"`
+
+	// SearchDomains Search domains assigned to the groups of the peer, ordered by priority
+	SearchDomains []string `json:"search_domains"`
+}
+
+// NetworkMapDNSRecord defines model for NetworkMapDNSRecord.
+type NetworkMapDNSRecord struct {
+	// Class Class of the record
+	Class string `json:"class"`
+
+	// Name Domain name of the record
+	Name string `json:"name"`
+
+	// Rdata Value of the record
+	Rdata string `json:"rdata"`
+
+	// Ttl Time to live of the record in seconds
+	Ttl int `json:"ttl"`
+
+	// Type Type of the record, 1 for A, 5 for CNAME, 12 for PTR and 28 for AAAA
+	Type int `json:"type"`
+}
+
+// NetworkMapDNSZone defines model for NetworkMapDNSZone.
+type NetworkMapDNSZone struct {
+	// Domain Domain of the zone
+	Domain string `json:"domain"`
+
+	// Records Records of the zone
+	Records []NetworkMapDNSRecord `json:"records"`
+}
+
+// NetworkMapFirewallRule defines model for NetworkMapFirewallRule.
+type NetworkMapFirewallRule struct {
+	// Action Action applied to the traffic
+	Action NetworkMapFirewallRuleAction `json:"action"`
+
+	// Direction Direction of the traffic
+	Direction NetworkMapFirewallRuleDirection `json:"direction"`
+
+	// PeerIp IP of the remote peer, 0.0.0.0 matches all peers
+	PeerIp string `json:"peer_ip"`
+
+	// Port Port of the traffic, empty for all ports
+	Port string `json:"port"`
+
+	// Protocol Protocol of the traffic
+	Protocol NetworkMapFirewallRuleProtocol `json:"protocol"`
+}
+
+// NetworkMapFirewallRuleAction Action applied to the traffic
+type NetworkMapFirewallRuleAction string
+
+// NetworkMapFirewallRuleDirection Direction of the traffic
+type NetworkMapFirewallRuleDirection string
+
+// NetworkMapFirewallRuleProtocol Protocol of the traffic
+type NetworkMapFirewallRuleProtocol string
+
+// NetworkMapNameserverGroup defines model for NetworkMapNameserverGroup.
+type NetworkMapNameserverGroup struct {
+	// Domains Domains resolved by the group
+	Domains []string `json:"domains"`
+
+	// ForwardViaRoutes Indicates whether the queries for the domains are forwarded to the nameservers over the routes
+	ForwardViaRoutes bool `json:"forward_via_routes"`
+
+	// Id Nameserver group ID
+	Id string `json:"id"`
+
+	// Name Nameserver group name
+	Name string `json:"name"`
+
+	// Nameservers Nameservers of the group
+	Nameservers []Nameserver `json:"nameservers"`
+
+	// Primary Indicates that the group resolves all queries not matching the domains of the other groups
+	Primary bool `json:"primary"`
+
+	// Priority Priority of the group, the group with the lowest value is used for a domain served by several groups
+	Priority int `json:"priority"`
+
+	// SearchDomainsEnabled Indicates whether the domains are added to the search domains of the peer
+	SearchDomainsEnabled bool `json:"search_domains_enabled"`
+}
+
+// NetworkMapRoute defines model for NetworkMapRoute.
+type NetworkMapRoute struct {
+	// Id Route Id
+	Id string `json:"id"`
+
+	// Masquerade Indicate if peer should masquerade traffic to this route's prefix
+	Masquerade bool `json:"masquerade"`
+
+	// Metric Route metric number. Lowest number has higher priority
+	Metric int `json:"metric"`
+
+	// Network Network range in CIDR format
+	Network string `json:"network"`
+
+	// NetworkId Route network identifier, to group HA routes
+	NetworkId string `json:"network_id"`
+
+	// Peer ID of the peer routing the network
+	Peer string `json:"peer"`
+}
+
 // Peer defines model for Peer.
 type Peer struct {
 	// AccessiblePeers List of accessible peers
@@ -337,12 +736,24 @@ type Peer struct {
 	// ApprovalRequired (Cloud only) Indicates whether peer needs approval
 	ApprovalRequired *bool `json:"approval_required,omitempty"`
 
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic between this peer and the other peers. 0 means no limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
 	// Connected Peer to Management connection status
 	Connected bool `json:"connected"`
 
+	// Disabled Indicates whether the peer was disabled because it didn't connect for longer than the inactivity expiration of the account
+	Disabled *bool `json:"disabled,omitempty"`
+
+	// DnsAliases Additional DNS labels resolving to the peer within the account domain, also combined with the DNS suffixes of the peer groups
+	DnsAliases *[]string `json:"dns_aliases,omitempty"`
+
 	// DnsLabel Peer's DNS label is the parsed peer name for domain resolution. It is used to form an FQDN by appending the account's domain to the peer label. e.g. peer-dns-label.netbird.cloud
 	DnsLabel string `json:"dns_label"`
 
+	// ExternalId Identifier of the peer in an external tool managing it, e.g. a Terraform provider
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Groups Groups that the peer belongs to
 	Groups []GroupMinimum `json:"groups"`
 
@@ -355,6 +766,12 @@ type Peer struct {
 	// Ip Peer's IP address
 	Ip string `json:"ip"`
 
+	// Labels Key/value pairs describing the peer, e.g. env=prod, sent to the other peers and published as DNS TXT records of the peer
+	Labels *map[string]string `json:"labels,omitempty"`
+
+	// LanNetworks LAN networks detected by the peer, reported when the peer was started with LAN route detection enabled
+	LanNetworks *[]string `json:"lan_networks,omitempty"`
+
 	// LastLogin Last time this peer performed log in (authentication). E.g., user authenticated.
 	LastLogin time.Time `json:"last_login"`
 
@@ -373,9 +790,15 @@ type Peer struct {
 	// Os Peer's operating system and version
 	Os string `json:"os"`
 
+	// SignalConnected Indicates whether the peer is connected to the Signal service. Only returned when the Management service is configured to look up the presence of the peers from Signal
+	SignalConnected *bool `json:"signal_connected,omitempty"`
+
 	// SshEnabled Indicates whether SSH server is enabled on this peer
 	SshEnabled bool `json:"ssh_enabled"`
 
+	// Type Type of the peer, user devices are registered with an SSO login and services with a setup key unless the key sets another type
+	Type PeerType `json:"type"`
+
 	// UiVersion Peer's desktop UI version
 	UiVersion *string `json:"ui_version,omitempty"`
 
@@ -391,9 +814,18 @@ type PeerBase struct {
 	// ApprovalRequired (Cloud only) Indicates whether peer needs approval
 	ApprovalRequired *bool `json:"approval_required,omitempty"`
 
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic between this peer and the other peers. 0 means no limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
 	// Connected Peer to Management connection status
 	Connected bool `json:"connected"`
 
+	// Disabled Indicates whether the peer was disabled because it didn't connect for longer than the inactivity expiration of the account
+	Disabled *bool `json:"disabled,omitempty"`
+
+	// DnsAliases Additional DNS labels resolving to the peer within the account domain, also combined with the DNS suffixes of the peer groups
+	DnsAliases *[]string `json:"dns_aliases,omitempty"`
+
 	// DnsLabel Peer's DNS label is the parsed peer name for domain resolution. It is used to form an FQDN by appending the account's domain to the peer label. e.g. peer-dns-label.netbird.cloud
 	DnsLabel string `json:"dns_label"`
 
@@ -409,6 +841,12 @@ type PeerBase struct {
 	// Ip Peer's IP address
 	Ip string `json:"ip"`
 
+	// Labels Key/value pairs describing the peer, e.g. env=prod, sent to the other peers and published as DNS TXT records of the peer
+	Labels *map[string]string `json:"labels,omitempty"`
+
+	// LanNetworks LAN networks detected by the peer, reported when the peer was started with LAN route detection enabled
+	LanNetworks *[]string `json:"lan_networks,omitempty"`
+
 	// LastLogin Last time this peer performed log in (authentication). E.g., user authenticated.
 	LastLogin time.Time `json:"last_login"`
 
@@ -427,9 +865,15 @@ type PeerBase struct {
 	// Os Peer's operating system and version
 	Os string `json:"os"`
 
+	// SignalConnected Indicates whether the peer is connected to the Signal service. Only returned when the Management service is configured to look up the presence of the peers from Signal
+	SignalConnected *bool `json:"signal_connected,omitempty"`
+
 	// SshEnabled Indicates whether SSH server is enabled on this peer
 	SshEnabled bool `json:"ssh_enabled"`
 
+	// Type Type of the peer, user devices are registered with an SSO login and services with a setup key unless the key sets another type
+	Type PeerType `json:"type"`
+
 	// UiVersion Peer's desktop UI version
 	UiVersion *string `json:"ui_version,omitempty"`
 
@@ -448,12 +892,24 @@ type PeerBatch struct {
 	// ApprovalRequired (Cloud only) Indicates whether peer needs approval
 	ApprovalRequired *bool `json:"approval_required,omitempty"`
 
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic between this peer and the other peers. 0 means no limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
 	// Connected Peer to Management connection status
 	Connected bool `json:"connected"`
 
+	// Disabled Indicates whether the peer was disabled because it didn't connect for longer than the inactivity expiration of the account
+	Disabled *bool `json:"disabled,omitempty"`
+
+	// DnsAliases Additional DNS labels resolving to the peer within the account domain, also combined with the DNS suffixes of the peer groups
+	DnsAliases *[]string `json:"dns_aliases,omitempty"`
+
 	// DnsLabel Peer's DNS label is the parsed peer name for domain resolution. It is used to form an FQDN by appending the account's domain to the peer label. e.g. peer-dns-label.netbird.cloud
 	DnsLabel string `json:"dns_label"`
 
+	// ExternalId Identifier of the peer in an external tool managing it, e.g. a Terraform provider
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Groups Groups that the peer belongs to
 	Groups []GroupMinimum `json:"groups"`
 
@@ -466,6 +922,12 @@ type PeerBatch struct {
 	// Ip Peer's IP address
 	Ip string `json:"ip"`
 
+	// Labels Key/value pairs describing the peer, e.g. env=prod, sent to the other peers and published as DNS TXT records of the peer
+	Labels *map[string]string `json:"labels,omitempty"`
+
+	// LanNetworks LAN networks detected by the peer, reported when the peer was started with LAN route detection enabled
+	LanNetworks *[]string `json:"lan_networks,omitempty"`
+
 	// LastLogin Last time this peer performed log in (authentication). E.g., user authenticated.
 	LastLogin time.Time `json:"last_login"`
 
@@ -484,9 +946,15 @@ type PeerBatch struct {
 	// Os Peer's operating system and version
 	Os string `json:"os"`
 
+	// SignalConnected Indicates whether the peer is connected to the Signal service. Only returned when the Management service is configured to look up the presence of the peers from Signal
+	SignalConnected *bool `json:"signal_connected,omitempty"`
+
 	// SshEnabled Indicates whether SSH server is enabled on this peer
 	SshEnabled bool `json:"ssh_enabled"`
 
+	// Type Type of the peer, user devices are registered with an SSO login and services with a setup key unless the key sets another type
+	Type PeerType `json:"type"`
+
 	// UiVersion Peer's desktop UI version
 	UiVersion *string `json:"ui_version,omitempty"`
 
@@ -497,6 +965,102 @@ type PeerBatch struct {
 	Version string `json:"version"`
 }
 
+// PeerBulkError defines model for PeerBulkError.
+type PeerBulkError struct {
+	// Message Reason of the failure
+	Message string `json:"message"`
+
+	// PeerId ID of the peer the action couldn't be applied to
+	PeerId string `json:"peer_id"`
+}
+
+// PeerBulkJob defines model for PeerBulkJob.
+type PeerBulkJob struct {
+	// Action Action applied to the peers
+	Action string `json:"action"`
+
+	// CreatedAt Time the job was created
+	CreatedAt time.Time `json:"created_at"`
+
+	// Errors Peers the action couldn't be applied to
+	Errors []PeerBulkError `json:"errors"`
+
+	// FinishedAt Time the job finished. Finished jobs are kept for an hour
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Id Bulk job ID
+	Id string `json:"id"`
+
+	// Message Reason a failed job stopped
+	Message *string `json:"message,omitempty"`
+
+	// Processed Number of peers processed so far
+	Processed int `json:"processed"`
+
+	// Status Progress of the job. A completed job processed all the peers, possibly with errors, a failed job stopped before
+	Status PeerBulkJobStatus `json:"status"`
+
+	// Total Number of peers of the job
+	Total int `json:"total"`
+}
+
+// PeerBulkJobStatus Progress of the job. A completed job processed all the peers, possibly with errors, a failed job stopped before
+type PeerBulkJobStatus string
+
+// PeerBulkRequest defines model for PeerBulkRequest.
+type PeerBulkRequest struct {
+	// Action Action applied to the peers. move_to_group adds the peers to the group and removes them from the other groups managed by the API except All, expire_sessions requires the peers added with the SSO login to log in again
+	Action PeerBulkRequestAction `json:"action"`
+
+	// GroupId ID of the destination group, required for move_to_group
+	GroupId *string `json:"group_id,omitempty"`
+
+	// Peers IDs of the peers, up to 10000
+	Peers []string `json:"peers"`
+
+	// SshEnabled SSH server state set on the peers, required for set_ssh_enabled
+	SshEnabled *bool `json:"ssh_enabled,omitempty"`
+}
+
+// PeerBulkRequestAction Action applied to the peers. move_to_group adds the peers to the group and removes them from the other groups managed by the API except All, expire_sessions requires the peers added with the SSO login to log in again
+type PeerBulkRequestAction string
+
+// PeerLatency defines model for PeerLatency.
+type PeerLatency struct {
+	// DestinationPeerId ID of the peer pinged over the tunnel
+	DestinationPeerId string `json:"destination_peer_id"`
+
+	// Loss Fraction of the pings not answered, between 0 and 1
+	Loss float64 `json:"loss"`
+
+	// MeasuredAt Time of the measurement
+	MeasuredAt time.Time `json:"measured_at"`
+
+	// RttMs Average round trip time of the answered pings in milliseconds, 0 when no ping was answered
+	RttMs float64 `json:"rtt_ms"`
+
+	// SourcePeerId ID of the peer that measured the latency
+	SourcePeerId string `json:"source_peer_id"`
+}
+
+// PeerUsage defines model for PeerUsage.
+type PeerUsage struct {
+	// Date Day of the traffic in UTC in the YYYY-MM-DD format
+	Date string `json:"date"`
+
+	// PeerId ID of the peer that reported the traffic
+	PeerId string `json:"peer_id"`
+
+	// ReportedAt Time of the latest report of the day
+	ReportedAt time.Time `json:"reported_at"`
+
+	// RxBytes Bytes received by the peer from the other peers during the day
+	RxBytes int64 `json:"rx_bytes"`
+
+	// TxBytes Bytes sent by the peer to the other peers during the day
+	TxBytes int64 `json:"tx_bytes"`
+}
+
 // PeerMinimum defines model for PeerMinimum.
 type PeerMinimum struct {
 	// Id Peer ID
@@ -506,15 +1070,59 @@ type PeerMinimum struct {
 	Name string `json:"name"`
 }
 
+// PeerNetworkMap defines model for PeerNetworkMap.
+type PeerNetworkMap struct {
+	Dns NetworkMapDNSConfig `json:"dns"`
+
+	// FirewallRules Firewall rules applied by the peer to the traffic with the other peers
+	FirewallRules []NetworkMapFirewallRule `json:"firewall_rules"`
+
+	// LoginExpired Indicates that the login of the peer expired and it only receives the peers of the login expired access groups
+	LoginExpired bool `json:"login_expired"`
+
+	// OfflinePeers Peers shown to the peer as offline, e.g. because their login expired
+	OfflinePeers []AccessiblePeer `json:"offline_peers"`
+
+	// Peers Peers the peer connects to
+	Peers []AccessiblePeer `json:"peers"`
+
+	// Routes Routes the peer receives, including the ones it routes itself
+	Routes []NetworkMapRoute `json:"routes"`
+
+	// Serial Serial of the network, incremented on every change of the network
+	Serial int `json:"serial"`
+}
+
 // PeerRequest defines model for PeerRequest.
 type PeerRequest struct {
 	// ApprovalRequired (Cloud only) Indicates whether peer needs approval
-	ApprovalRequired       *bool  `json:"approval_required,omitempty"`
-	LoginExpirationEnabled bool   `json:"login_expiration_enabled"`
-	Name                   string `json:"name"`
-	SshEnabled             bool   `json:"ssh_enabled"`
+	ApprovalRequired *bool `json:"approval_required,omitempty"`
+
+	// BandwidthLimit Bandwidth limit in kbit/s applied to the traffic between this peer and the other peers. 0 disables the limit
+	BandwidthLimit *int `json:"bandwidth_limit,omitempty"`
+
+	// DnsAliases Additional DNS labels resolving to the peer within the account domain, also combined with the DNS suffixes of the peer groups. An empty list removes the aliases
+	DnsAliases *[]string `json:"dns_aliases,omitempty"`
+
+	// ExternalId Identifier of the peer in an external tool managing it, e.g. a Terraform provider. It must be unique among the peers of the account. The current one is kept when it isn't set and an empty one removes it
+	ExternalId *string `json:"external_id,omitempty"`
+
+	// Ip Static IP assigned to the peer, it must be an unused IP of the network range of the account. The current IP is kept when it isn't set
+	Ip *string `json:"ip,omitempty"`
+
+	// Labels Key/value pairs describing the peer, e.g. env=prod. Keys and values have up to 63 letters, numbers, dots, underscores and hyphens, values also colons and slashes. The current labels are kept when they aren't set and an empty object removes them
+	Labels                 *map[string]string `json:"labels,omitempty"`
+	LoginExpirationEnabled bool               `json:"login_expiration_enabled"`
+	Name                   string             `json:"name"`
+	SshEnabled             bool               `json:"ssh_enabled"`
+
+	// Type Changes the type of the peer, the login expiration is reset to the default of the new type. The current type is kept when it isn't set
+	Type *PeerType `json:"type,omitempty"`
 }
 
+// PeerType Type of a peer, a device of a user or a headless service. The login of the services doesn't expire by default
+type PeerType string
+
 // PersonalAccessToken defines model for PersonalAccessToken.
 type PersonalAccessToken struct {
 	// CreatedAt Date the token was created
@@ -561,6 +1169,9 @@ type Policy struct {
 	// Enabled Policy status
 	Enabled bool `json:"enabled"`
 
+	// ExternalId Identifier of the policy in an external tool managing it, e.g. a Terraform provider. It must be unique among the policies of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Id Policy ID
 	Id *string `json:"id,omitempty"`
 
@@ -582,6 +1193,9 @@ type PolicyMinimum struct {
 	// Enabled Policy status
 	Enabled bool `json:"enabled"`
 
+	// ExternalId Identifier of the policy in an external tool managing it, e.g. a Terraform provider. It must be unique among the policies of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Id Policy ID
 	Id *string `json:"id,omitempty"`
 
@@ -603,6 +1217,9 @@ type PolicyRule struct {
 	// Description Policy rule friendly description
 	Description *string `json:"description,omitempty"`
 
+	// DestinationPeerTypes Limits the peers of the destination groups to the peers of these types, all the peers when empty
+	DestinationPeerTypes *[]PeerType `json:"destination_peer_types,omitempty"`
+
 	// Destinations Policy rule destination group IDs
 	Destinations []GroupMinimum `json:"destinations"`
 
@@ -612,6 +1229,9 @@ type PolicyRule struct {
 	// Id Policy rule ID
 	Id *string `json:"id,omitempty"`
 
+	// Log Log the traffic matched by the rule on the peers, with rate limiting. Accepted and dropped packets are logged depending on the rule action
+	Log *bool `json:"log,omitempty"`
+
 	// Name Policy rule name identifier
 	Name string `json:"name"`
 
@@ -621,6 +1241,12 @@ type PolicyRule struct {
 	// Protocol Policy rule type of the traffic
 	Protocol PolicyRuleProtocol `json:"protocol"`
 
+	// Services IDs of the services whose protocols and ports the rule matches instead of its protocol and ports
+	Services *[]string `json:"services,omitempty"`
+
+	// SourcePeerTypes Limits the peers of the source groups to the peers of these types, all the peers when empty
+	SourcePeerTypes *[]PeerType `json:"source_peer_types,omitempty"`
+
 	// Sources Policy rule source group IDs
 	Sources []GroupMinimum `json:"sources"`
 }
@@ -642,12 +1268,18 @@ type PolicyRuleMinimum struct {
 	// Description Policy rule friendly description
 	Description *string `json:"description,omitempty"`
 
+	// DestinationPeerTypes Limits the peers of the destination groups to the peers of these types, all the peers when empty
+	DestinationPeerTypes *[]PeerType `json:"destination_peer_types,omitempty"`
+
 	// Enabled Policy rule status
 	Enabled bool `json:"enabled"`
 
 	// Id Policy rule ID
 	Id *string `json:"id,omitempty"`
 
+	// Log Log the traffic matched by the rule on the peers, with rate limiting. Accepted and dropped packets are logged depending on the rule action
+	Log *bool `json:"log,omitempty"`
+
 	// Name Policy rule name identifier
 	Name string `json:"name"`
 
@@ -656,6 +1288,12 @@ type PolicyRuleMinimum struct {
 
 	// Protocol Policy rule type of the traffic
 	Protocol PolicyRuleMinimumProtocol `json:"protocol"`
+
+	// Services IDs of the services whose protocols and ports the rule matches instead of its protocol and ports
+	Services *[]string `json:"services,omitempty"`
+
+	// SourcePeerTypes Limits the peers of the source groups to the peers of these types, all the peers when empty
+	SourcePeerTypes *[]PeerType `json:"source_peer_types,omitempty"`
 }
 
 // PolicyRuleMinimumAction Policy rule accept or drops packets
@@ -675,6 +1313,9 @@ type PolicyRuleUpdate struct {
 	// Description Policy rule friendly description
 	Description *string `json:"description,omitempty"`
 
+	// DestinationPeerTypes Limits the peers of the destination groups to the peers of these types, all the peers when empty
+	DestinationPeerTypes *[]PeerType `json:"destination_peer_types,omitempty"`
+
 	// Destinations Policy rule destination group IDs
 	Destinations []string `json:"destinations"`
 
@@ -684,6 +1325,9 @@ type PolicyRuleUpdate struct {
 	// Id Policy rule ID
 	Id *string `json:"id,omitempty"`
 
+	// Log Log the traffic matched by the rule on the peers, with rate limiting. Accepted and dropped packets are logged depending on the rule action
+	Log *bool `json:"log,omitempty"`
+
 	// Name Policy rule name identifier
 	Name string `json:"name"`
 
@@ -693,6 +1337,12 @@ type PolicyRuleUpdate struct {
 	// Protocol Policy rule type of the traffic
 	Protocol PolicyRuleUpdateProtocol `json:"protocol"`
 
+	// Services IDs of the services whose protocols and ports the rule matches instead of its protocol and ports
+	Services *[]string `json:"services,omitempty"`
+
+	// SourcePeerTypes Limits the peers of the source groups to the peers of these types, all the peers when empty
+	SourcePeerTypes *[]PeerType `json:"source_peer_types,omitempty"`
+
 	// Sources Policy rule source group IDs
 	Sources []string `json:"sources"`
 }
@@ -703,6 +1353,60 @@ type PolicyRuleUpdateAction string
 // PolicyRuleUpdateProtocol Policy rule type of the traffic
 type PolicyRuleUpdateProtocol string
 
+// PolicySimulation defines model for PolicySimulation.
+type PolicySimulation struct {
+	// Allowed Indicates whether the traffic is accepted by the firewall of both peers
+	Allowed bool `json:"allowed"`
+
+	// DestinationRule Firewall rule of the destination peer deciding the incoming traffic, missing when the traffic is dropped by default
+	DestinationRule *NetworkMapFirewallRule `json:"destination_rule,omitempty"`
+
+	// Matches Enabled policy rules applying to the traffic in the order of the policies
+	Matches []PolicySimulationMatch `json:"matches"`
+
+	// SourceRule Firewall rule of the source peer deciding the outgoing traffic, missing when the traffic is dropped by default
+	SourceRule *NetworkMapFirewallRule `json:"source_rule,omitempty"`
+}
+
+// PolicySimulationMatch defines model for PolicySimulationMatch.
+type PolicySimulationMatch struct {
+	// Action Policy rule accept or drops packets
+	Action PolicySimulationMatchAction `json:"action"`
+
+	// PolicyId Policy ID
+	PolicyId string `json:"policy_id"`
+
+	// PolicyName Policy name
+	PolicyName string `json:"policy_name"`
+
+	// RuleId Policy rule ID
+	RuleId string `json:"rule_id"`
+
+	// RuleName Policy rule name
+	RuleName string `json:"rule_name"`
+}
+
+// PolicySimulationMatchAction Policy rule accept or drops packets
+type PolicySimulationMatchAction string
+
+// PolicySimulationRequest defines model for PolicySimulationRequest.
+type PolicySimulationRequest struct {
+	// DestinationPeerId ID of the peer receiving the traffic
+	DestinationPeerId string `json:"destination_peer_id"`
+
+	// Port Destination port of the traffic, required for tcp and udp
+	Port *int `json:"port,omitempty"`
+
+	// Protocol Protocol of the traffic
+	Protocol PolicySimulationRequestProtocol `json:"protocol"`
+
+	// SourcePeerId ID of the peer sending the traffic
+	SourcePeerId string `json:"source_peer_id"`
+}
+
+// PolicySimulationRequestProtocol Protocol of the traffic
+type PolicySimulationRequestProtocol string
+
 // PolicyUpdate defines model for PolicyUpdate.
 type PolicyUpdate struct {
 	// Description Policy friendly description
@@ -711,6 +1415,9 @@ type PolicyUpdate struct {
 	// Enabled Policy status
 	Enabled bool `json:"enabled"`
 
+	// ExternalId Identifier of the policy in an external tool managing it, e.g. a Terraform provider. It must be unique among the policies of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Id Policy ID
 	Id *string `json:"id,omitempty"`
 
@@ -729,15 +1436,24 @@ type Route struct {
 	// Description Route description
 	Description string `json:"description"`
 
+	// DisablePreemption Keep the peers on the route they chose among the routes with the same network identifier and network range while its routing peer is connected, instead of failing back to a route with a lower metric. Preemption is disabled for the network if any of its routes disables it
+	DisablePreemption *bool `json:"disable_preemption,omitempty"`
+
 	// Enabled Route status
 	Enabled bool `json:"enabled"`
 
+	// ExternalId Identifier of the route in an external tool managing it, e.g. a Terraform provider. It must be unique among the routes of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Groups Group IDs containing routing peers
 	Groups []string `json:"groups"`
 
 	// Id Route Id
 	Id string `json:"id"`
 
+	// LoadBalance Spread the peers over the connected routing peers of the routes with the same network identifier and network range, e.g. an exit node group. Every peer is assigned to a routing peer by the hash of its public key and reassigned when that routing peer disconnects. Load balancing is enabled for the network if any of its routes enables it
+	LoadBalance *bool `json:"load_balance,omitempty"`
+
 	// Masquerade Indicate if peer should masquerade traffic to this route's prefix
 	Masquerade bool `json:"masquerade"`
 
@@ -760,17 +1476,83 @@ type Route struct {
 	PeerGroups *[]string `json:"peer_groups,omitempty"`
 }
 
+// RouteImportRequest defines model for RouteImportRequest.
+type RouteImportRequest struct {
+	// Description Route description
+	Description string `json:"description"`
+
+	// DisablePreemption Keep the peers on the route they chose among the routes with the same network identifier and network range while its routing peer is connected, instead of failing back to a route with a lower metric. Preemption is disabled for the network if any of its routes disables it
+	DisablePreemption *bool `json:"disable_preemption,omitempty"`
+
+	// Enabled Route status
+	Enabled bool `json:"enabled"`
+
+	// ExternalId Identifier of the route in an external tool managing it, e.g. a Terraform provider. It must be unique among the routes of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
+	// Groups Group IDs containing routing peers
+	Groups []string `json:"groups"`
+
+	// Id Identifier of an existing Route to update. A new Route is created when omitted
+	Id *string `json:"id,omitempty"`
+
+	// LoadBalance Spread the peers over the connected routing peers of the routes with the same network identifier and network range, e.g. an exit node group. Every peer is assigned to a routing peer by the hash of its public key and reassigned when that routing peer disconnects. Load balancing is enabled for the network if any of its routes enables it
+	LoadBalance *bool `json:"load_balance,omitempty"`
+
+	// Masquerade Indicate if peer should masquerade traffic to this route's prefix
+	Masquerade bool `json:"masquerade"`
+
+	// Metric Route metric number. Lowest number has higher priority
+	Metric int `json:"metric"`
+
+	// Network Network range in CIDR format
+	Network string `json:"network"`
+
+	// NetworkId Route network identifier, to group HA routes
+	NetworkId string `json:"network_id"`
+
+	// Peer Peer Identifier associated with route. This property can not be set together with `peer_groups`
+	Peer *string `json:"peer,omitempty"`
+
+	// PeerGroups Peers Group Identifier associated with route. This property can not be set together with `peer`
+	PeerGroups *[]string `json:"peer_groups,omitempty"`
+}
+
+// RouteSuggestion defines model for RouteSuggestion.
+type RouteSuggestion struct {
+	// Network LAN network detected by the peer in CIDR format
+	Network string `json:"network"`
+
+	// NetworkId Suggested network identifier of the route
+	NetworkId string `json:"network_id"`
+
+	// PeerId ID of the peer that detected the network, the suggested routing peer
+	PeerId string `json:"peer_id"`
+
+	// PeerName Name of the peer that detected the network
+	PeerName string `json:"peer_name"`
+}
+
 // RouteRequest defines model for RouteRequest.
 type RouteRequest struct {
 	// Description Route description
 	Description string `json:"description"`
 
+	// DisablePreemption Keep the peers on the route they chose among the routes with the same network identifier and network range while its routing peer is connected, instead of failing back to a route with a lower metric. Preemption is disabled for the network if any of its routes disables it
+	DisablePreemption *bool `json:"disable_preemption,omitempty"`
+
 	// Enabled Route status
 	Enabled bool `json:"enabled"`
 
+	// ExternalId Identifier of the route in an external tool managing it, e.g. a Terraform provider. It must be unique among the routes of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Groups Group IDs containing routing peers
 	Groups []string `json:"groups"`
 
+	// LoadBalance Spread the peers over the connected routing peers of the routes with the same network identifier and network range, e.g. an exit node group. Every peer is assigned to a routing peer by the hash of its public key and reassigned when that routing peer disconnects. Load balancing is enabled for the network if any of its routes enables it
+	LoadBalance *bool `json:"load_balance,omitempty"`
+
 	// Masquerade Indicate if peer should masquerade traffic to this route's prefix
 	Masquerade bool `json:"masquerade"`
 
@@ -850,6 +1632,57 @@ type RuleRequest struct {
 	Sources *[]string `json:"sources,omitempty"`
 }
 
+// SearchDomainGroup defines model for SearchDomainGroup.
+type SearchDomainGroup struct {
+	// Domains Search domains added to the DNS configuration of the peers
+	Domains []string `json:"domains"`
+
+	// Groups Group IDs of the peers receiving the search domains
+	Groups []string `json:"groups"`
+
+	// Priority Priority of the search domains, the domains with the lowest value come first in the search list of the peers
+	Priority int `json:"priority"`
+}
+
+// Service defines model for Service.
+type Service struct {
+	// Description Service friendly description
+	Description string `json:"description"`
+
+	// Id Service ID
+	Id string `json:"id"`
+
+	// Name Service name identifier, unique in the account
+	Name string `json:"name"`
+
+	// Protocols Protocols and ports of the service traffic
+	Protocols []ServiceProtocol `json:"protocols"`
+}
+
+// ServiceProtocol defines model for ServiceProtocol.
+type ServiceProtocol struct {
+	// Ports Ports of the service, all the ports of the protocol when empty
+	Ports *[]string `json:"ports,omitempty"`
+
+	// Protocol Protocol of the traffic of the service
+	Protocol ServiceProtocolProtocol `json:"protocol"`
+}
+
+// ServiceProtocolProtocol Protocol of the traffic of the service
+type ServiceProtocolProtocol string
+
+// ServiceRequest defines model for ServiceRequest.
+type ServiceRequest struct {
+	// Description Service friendly description
+	Description string `json:"description"`
+
+	// Name Service name identifier, unique in the account
+	Name string `json:"name"`
+
+	// Protocols Protocols and ports of the service traffic
+	Protocols []ServiceProtocol `json:"protocols"`
+}
+
 // SetupKey defines model for SetupKey.
 type SetupKey struct {
 	// AutoGroups List of group IDs to auto-assign to peers registered with this key
@@ -861,6 +1694,9 @@ type SetupKey struct {
 	// Expires Setup Key expiration date
 	Expires time.Time `json:"expires"`
 
+	// ExternalId Identifier of the setup key in an external tool managing it, e.g. a Terraform provider
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Id Setup Key ID
 	Id string `json:"id"`
 
@@ -873,6 +1709,9 @@ type SetupKey struct {
 	// Name Setup key name identifier
 	Name string `json:"name"`
 
+	// PeerType Type of the peers registered with this key
+	PeerType PeerType `json:"peer_type"`
+
 	// Revoked Setup key revocation status
 	Revoked bool `json:"revoked"`
 
@@ -906,9 +1745,15 @@ type SetupKeyRequest struct {
 	// ExpiresIn Expiration time in seconds
 	ExpiresIn int `json:"expires_in"`
 
+	// ExternalId Identifier of the setup key in an external tool managing it, e.g. a Terraform provider. It must be unique among the setup keys of the account. The current one is kept on updates when it isn't set
+	ExternalId *string `json:"external_id,omitempty"`
+
 	// Name Setup Key name
 	Name string `json:"name"`
 
+	// PeerType Type of the peers registered with this key, services by default. The current type is kept on updates when it isn't set
+	PeerType *PeerType `json:"peer_type,omitempty"`
+
 	// Revoked Setup key revocation status
 	Revoked bool `json:"revoked"`
 
@@ -930,6 +1775,9 @@ type User struct {
 	// Id User ID
 	Id string `json:"id"`
 
+	// IdentityProvider Name of the identity provider the user last logged in with, empty for the default provider
+	IdentityProvider *string `json:"identity_provider,omitempty"`
+
 	// IsBlocked Is true if this user is blocked. Blocked users can't use the system
 	IsBlocked bool `json:"is_blocked"`
 
@@ -988,12 +1836,30 @@ type UserRequest struct {
 	Role string `json:"role"`
 }
 
+// GetApiEventsStreamParams defines parameters for GetApiEventsStream.
+type GetApiEventsStreamParams struct {
+	// Types Comma-separated types of the events to send, all the types when empty
+	Types *string `form:"types,omitempty" json:"types,omitempty"`
+
+	// AccessToken The JWT or personal access token of the user, when the Authorization header can't be set
+	AccessToken *string `form:"access_token,omitempty" json:"access_token,omitempty"`
+}
+
+// GetApiPeersUsageParams defines parameters for GetApiPeersUsage.
+type GetApiPeersUsageParams struct {
+	// Days Number of latest days returned, up to 31, all the kept days when unset
+	Days *int `form:"days,omitempty" json:"days,omitempty"`
+}
+
 // GetApiUsersParams defines parameters for GetApiUsers.
 type GetApiUsersParams struct {
 	// ServiceUser Filters users and returns either regular users or service users
 	ServiceUser *bool `form:"service_user,omitempty" json:"service_user,omitempty"`
 }
 
+// PostApiRoutesImportJSONBody defines parameters for PostApiRoutesImport.
+type PostApiRoutesImportJSONBody = []RouteImportRequest
+
 // PutApiAccountsAccountIdJSONRequestBody defines body for PutApiAccountsAccountId for application/json ContentType.
 type PutApiAccountsAccountIdJSONRequestBody = AccountRequest
 
@@ -1009,21 +1875,39 @@ type PutApiDnsSettingsJSONRequestBody = DNSSettings
 // PostApiGroupsJSONRequestBody defines body for PostApiGroups for application/json ContentType.
 type PostApiGroupsJSONRequestBody = GroupRequest
 
+// PutApiGroupsExternalExternalIdJSONRequestBody defines body for PutApiGroupsExternalExternalId for application/json ContentType.
+type PutApiGroupsExternalExternalIdJSONRequestBody = GroupRequest
+
 // PutApiGroupsGroupIdJSONRequestBody defines body for PutApiGroupsGroupId for application/json ContentType.
 type PutApiGroupsGroupIdJSONRequestBody = GroupRequest
 
+// PostApiPeersBulkJSONRequestBody defines body for PostApiPeersBulk for application/json ContentType.
+type PostApiPeersBulkJSONRequestBody = PeerBulkRequest
+
 // PutApiPeersPeerIdJSONRequestBody defines body for PutApiPeersPeerId for application/json ContentType.
 type PutApiPeersPeerIdJSONRequestBody = PeerRequest
 
 // PostApiPoliciesJSONRequestBody defines body for PostApiPolicies for application/json ContentType.
 type PostApiPoliciesJSONRequestBody = PolicyUpdate
 
+// PutApiPoliciesExternalExternalIdJSONRequestBody defines body for PutApiPoliciesExternalExternalId for application/json ContentType.
+type PutApiPoliciesExternalExternalIdJSONRequestBody = PolicyUpdate
+
+// PostApiPoliciesSimulateJSONRequestBody defines body for PostApiPoliciesSimulate for application/json ContentType.
+type PostApiPoliciesSimulateJSONRequestBody = PolicySimulationRequest
+
 // PutApiPoliciesPolicyIdJSONRequestBody defines body for PutApiPoliciesPolicyId for application/json ContentType.
 type PutApiPoliciesPolicyIdJSONRequestBody = PolicyUpdate
 
 // PostApiRoutesJSONRequestBody defines body for PostApiRoutes for application/json ContentType.
 type PostApiRoutesJSONRequestBody = RouteRequest
 
+// PutApiRoutesExternalExternalIdJSONRequestBody defines body for PutApiRoutesExternalExternalId for application/json ContentType.
+type PutApiRoutesExternalExternalIdJSONRequestBody = RouteRequest
+
+// PostApiRoutesImportJSONRequestBody defines body for PostApiRoutesImport for application/json ContentType.
+type PostApiRoutesImportJSONRequestBody = PostApiRoutesImportJSONBody
+
 // PutApiRoutesRouteIdJSONRequestBody defines body for PutApiRoutesRouteId for application/json ContentType.
 type PutApiRoutesRouteIdJSONRequestBody = RouteRequest
 
@@ -1033,9 +1917,18 @@ type PostApiRulesJSONRequestBody = RuleRequest
 // PutApiRulesRuleIdJSONRequestBody defines body for PutApiRulesRuleId for application/json ContentType.
 type PutApiRulesRuleIdJSONRequestBody = RuleRequest
 
+// PostApiServicesJSONRequestBody defines body for PostApiServices for application/json ContentType.
+type PostApiServicesJSONRequestBody = ServiceRequest
+
+// PutApiServicesServiceIdJSONRequestBody defines body for PutApiServicesServiceId for application/json ContentType.
+type PutApiServicesServiceIdJSONRequestBody = ServiceRequest
+
 // PostApiSetupKeysJSONRequestBody defines body for PostApiSetupKeys for application/json ContentType.
 type PostApiSetupKeysJSONRequestBody = SetupKeyRequest
 
+// PutApiSetupKeysExternalExternalIdJSONRequestBody defines body for PutApiSetupKeysExternalExternalId for application/json ContentType.
+type PutApiSetupKeysExternalExternalIdJSONRequestBody = SetupKeyRequest
+
 // PutApiSetupKeysKeyIdJSONRequestBody defines body for PutApiSetupKeysKeyId for application/json ContentType.
 type PutApiSetupKeysKeyIdJSONRequestBody = SetupKeyRequest
 
@@ -0,0 +1,50 @@
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// PathPrefix is the path the embedded dashboard is served under
+const PathPrefix = "/ui/"
+
+//go:embed static
+var static embed.FS
+
+// contentSecurityPolicy only allows the assets of the dashboard and the requests to the Management API of the same
+// origin, the dashboard is never rendered in a frame
+const contentSecurityPolicy = "default-src 'self'; connect-src 'self' ws: wss:; img-src 'self' data:; frame-ancestors 'none'"
+
+// Handler serves the embedded dashboard under PathPrefix and passes the other requests to the next handler, e.g. the
+// HTTP API. The dashboard is a static page which calls the HTTP API with the token of the user, the API keeps
+// authenticating and authorizing every request
+func Handler(next http.Handler) http.Handler {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		// the embedded directory always exists
+		panic(err)
+	}
+	fileServer := http.StripPrefix(PathPrefix, http.FileServer(http.FS(assets)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == strings.TrimSuffix(PathPrefix, "/") {
+			http.Redirect(w, r, PathPrefix, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, PathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		fileServer.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package dashboard
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := Handler(next)
+
+	tt := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		expectedType   string
+	}{
+		{
+			name:           "Index",
+			method:         http.MethodGet,
+			path:           "/ui/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<title>NetBird</title>",
+			expectedType:   "text/html; charset=utf-8",
+		},
+		{
+			name:           "Script",
+			method:         http.MethodGet,
+			path:           "/ui/app.js",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/api/events/stream",
+			expectedType:   "text/javascript; charset=utf-8",
+		},
+		{
+			name:           "Redirect Without Trailing Slash",
+			method:         http.MethodGet,
+			path:           "/ui",
+			expectedStatus: http.StatusMovedPermanently,
+		},
+		{
+			name:           "Unknown Asset",
+			method:         http.MethodGet,
+			path:           "/ui/unknown.js",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Modifying Request",
+			method:         http.MethodPost,
+			path:           "/ui/",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "API Request",
+			method:         http.MethodGet,
+			path:           "/api/peers",
+			expectedStatus: http.StatusTeapot,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			handler.ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+			if tc.expectedBody == "" {
+				return
+			}
+
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), tc.expectedBody)
+			assert.Equal(t, tc.expectedType, res.Header.Get("Content-Type"))
+			assert.Equal(t, contentSecurityPolicy, res.Header.Get("Content-Security-Policy"))
+		})
+	}
+}
@@ -0,0 +1,333 @@
+"use strict";
+
+// The embedded dashboard calls the Management HTTP API of the same origin with the token of the user. The token is
+// kept in the session storage of the tab, the API authenticates and authorizes every request.
+
+const tokenKey = "netbird-token";
+
+const state = {
+    tab: "peers",
+    isAdmin: false,
+    peers: new Map(),
+    groups: new Map(),
+    events: null,
+};
+
+function $(selector) {
+    return document.querySelector(selector);
+}
+
+function token() {
+    return sessionStorage.getItem(tokenKey);
+}
+
+function authorization() {
+    const value = token();
+    return value.startsWith("nbp_") ? "Token " + value : "Bearer " + value;
+}
+
+async function api(method, path, body) {
+    const options = {method: method, headers: {"Authorization": authorization()}};
+    if (body !== undefined) {
+        options.headers["Content-Type"] = "application/json";
+        options.body = JSON.stringify(body);
+    }
+
+    const resp = await fetch("/api" + path, options);
+    if (resp.status === 401) {
+        logout();
+        throw new Error("The token is invalid or expired, log in again");
+    }
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+        throw new Error(data.message || resp.statusText);
+    }
+    return data;
+}
+
+function showError(err) {
+    const error = $("#error");
+    error.textContent = err ? err.message : "";
+    error.hidden = !err;
+}
+
+function cell(content) {
+    const td = document.createElement("td");
+    if (content instanceof Node) {
+        td.appendChild(content);
+    } else {
+        td.textContent = content === undefined || content === null ? "" : String(content);
+    }
+    return td;
+}
+
+function button(label, onClick, className) {
+    const b = document.createElement("button");
+    b.textContent = label;
+    if (className) {
+        b.className = className;
+    }
+    b.addEventListener("click", () => Promise.resolve(onClick()).catch(showError));
+    return b;
+}
+
+function fillTable(section, rows) {
+    const tbody = $("#" + section + " tbody");
+    tbody.replaceChildren(...rows.map((cells) => {
+        const tr = document.createElement("tr");
+        tr.append(...cells.map(cell));
+        return tr;
+    }));
+}
+
+function formatTime(value) {
+    if (!value || value.startsWith("0001-")) {
+        return "never";
+    }
+    return new Date(value).toLocaleString();
+}
+
+function groupNames(ids) {
+    return (ids || []).map((id) => state.groups.has(id) ? state.groups.get(id) : id).join(", ");
+}
+
+function peerStatus(peer) {
+    const span = document.createElement("span");
+    if (peer.login_expired) {
+        span.textContent = "login expired";
+        span.className = "offline";
+    } else {
+        span.textContent = peer.connected ? "online" : "offline";
+        span.className = peer.connected ? "online" : "offline";
+    }
+    return span;
+}
+
+function renderPeers() {
+    const peers = Array.from(state.peers.values()).sort((a, b) => a.name.localeCompare(b.name));
+    fillTable("peers", peers.map((peer) => [
+        peer.name,
+        peer.ip,
+        peer.dns_label,
+        peer.os,
+        peer.version,
+        (peer.groups || []).map((g) => g.name).join(", "),
+        peerStatus(peer),
+        peer.connected ? "now" : formatTime(peer.last_seen),
+        state.isAdmin ? button("Delete", () => deletePeer(peer), "danger") : "",
+    ]));
+}
+
+async function loadPeers() {
+    const peers = await api("GET", "/peers");
+    state.peers = new Map(peers.map((peer) => [peer.id, peer]));
+    renderPeers();
+    subscribePeerStatus();
+}
+
+async function deletePeer(peer) {
+    if (!confirm("Delete the peer " + peer.name + "?")) {
+        return;
+    }
+    await api("DELETE", "/peers/" + encodeURIComponent(peer.id));
+    await loadPeers();
+}
+
+// subscribePeerStatus keeps the status of the peers up to date with the live events of the account
+function subscribePeerStatus() {
+    if (state.events) {
+        return;
+    }
+
+    const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+    const url = scheme + "//" + location.host + "/api/events/stream?types=peer_status&access_token=" + encodeURIComponent(token());
+    const events = new WebSocket(url);
+    state.events = events;
+
+    events.onopen = () => {
+        $("#live").hidden = false;
+    };
+    events.onmessage = (msg) => {
+        const event = JSON.parse(msg.data);
+        const peer = event.peer && state.peers.get(event.peer.id);
+        if (!peer) {
+            return;
+        }
+        peer.connected = event.peer.connected;
+        peer.last_seen = event.peer.last_seen;
+        peer.login_expired = event.peer.login_expired;
+        if (state.tab === "peers") {
+            renderPeers();
+        }
+    };
+    events.onclose = () => {
+        $("#live").hidden = true;
+        state.events = null;
+    };
+}
+
+async function loadRoutes() {
+    const routes = await api("GET", "/routes");
+    fillTable("routes", routes.map((route) => [
+        route.network_id,
+        route.network,
+        route.description,
+        route.peer ? (state.peers.has(route.peer) ? state.peers.get(route.peer).name : route.peer) : groupNames(route.peer_groups),
+        groupNames(route.groups),
+        route.metric,
+        route.masquerade ? "yes" : "no",
+        route.enabled ? "yes" : "no",
+    ]));
+}
+
+async function loadPolicies() {
+    const policies = await api("GET", "/policies");
+    const rows = [];
+    for (const policy of policies) {
+        for (const rule of policy.rules) {
+            rows.push([
+                policy.name,
+                policy.description,
+                (rule.sources || []).map((g) => g.name).join(", "),
+                (rule.destinations || []).map((g) => g.name).join(", "),
+                rule.protocol,
+                (rule.ports || []).join(", ") || "all",
+                rule.action + (rule.bidirectional ? " (bidirectional)" : ""),
+                policy.enabled && rule.enabled ? "yes" : "no",
+            ]);
+        }
+    }
+    fillTable("policies", rows);
+}
+
+async function loadSetupKeys() {
+    const keys = await api("GET", "/setup-keys");
+    keys.sort((a, b) => a.name.localeCompare(b.name));
+    fillTable("setup-keys", keys.map((key) => [
+        key.name,
+        key.key,
+        key.type + (key.ephemeral ? ", ephemeral" : ""),
+        key.state,
+        key.usage_limit ? key.used_times + " / " + key.usage_limit : key.used_times,
+        formatTime(key.last_used),
+        formatTime(key.expires),
+        state.isAdmin && key.valid ? button("Revoke", () => revokeSetupKey(key), "danger") : "",
+    ]));
+}
+
+async function createSetupKey(form) {
+    const field = (name) => form.elements.namedItem(name);
+    const key = await api("POST", "/setup-keys", {
+        name: field("name").value,
+        type: field("type").value,
+        expires_in: Number(field("expires_in_days").value) * 24 * 60 * 60,
+        auto_groups: [],
+        revoked: false,
+        usage_limit: 0,
+        ephemeral: field("ephemeral").checked,
+    });
+    form.reset();
+
+    const notice = $("#new-key");
+    notice.textContent = "Created the setup key " + key.name + ": " + key.key;
+    notice.hidden = false;
+    await loadSetupKeys();
+}
+
+async function revokeSetupKey(key) {
+    if (!confirm("Revoke the setup key " + key.name + "?")) {
+        return;
+    }
+    await api("PUT", "/setup-keys/" + encodeURIComponent(key.id), {
+        name: key.name,
+        type: key.type,
+        expires_in: 0,
+        auto_groups: key.auto_groups,
+        revoked: true,
+        usage_limit: key.usage_limit,
+    });
+    await loadSetupKeys();
+}
+
+const loaders = {
+    "peers": loadPeers,
+    "routes": loadRoutes,
+    "policies": loadPolicies,
+    "setup-keys": loadSetupKeys,
+};
+
+async function showTab(tab) {
+    state.tab = tab;
+    for (const section of document.querySelectorAll("section.tab")) {
+        section.hidden = section.id !== tab;
+    }
+    for (const b of document.querySelectorAll("#tabs button")) {
+        b.classList.toggle("active", b.dataset.tab === tab);
+    }
+    try {
+        showError(null);
+        await loaders[tab]();
+    } catch (err) {
+        showError(err);
+    }
+}
+
+async function start() {
+    $("#login").hidden = true;
+    $("#tabs").hidden = false;
+    $("#logout").hidden = false;
+
+    try {
+        const users = await api("GET", "/users");
+        const current = users.find((user) => user.is_current);
+        state.isAdmin = !!current && (current.role === "admin" || current.role === "owner");
+        const groups = await api("GET", "/groups");
+        state.groups = new Map(groups.map((group) => [group.id, group.name]));
+        const peers = await api("GET", "/peers");
+        state.peers = new Map(peers.map((peer) => [peer.id, peer]));
+    } catch (err) {
+        showError(err);
+        return;
+    }
+
+    for (const form of document.querySelectorAll("form.admin")) {
+        form.hidden = !state.isAdmin;
+    }
+    await showTab(state.tab);
+}
+
+function logout() {
+    sessionStorage.removeItem(tokenKey);
+    if (state.events) {
+        state.events.close();
+    }
+    $("#tabs").hidden = true;
+    $("#logout").hidden = true;
+    for (const section of document.querySelectorAll("section.tab")) {
+        section.hidden = true;
+    }
+    $("#login").hidden = false;
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+    $("#login-form").addEventListener("submit", (e) => {
+        e.preventDefault();
+        sessionStorage.setItem(tokenKey, $("#token").value.trim());
+        $("#token").value = "";
+        start();
+    });
+    $("#logout").addEventListener("click", logout);
+    for (const b of document.querySelectorAll("#tabs button")) {
+        b.addEventListener("click", () => showTab(b.dataset.tab));
+    }
+    $("#setup-key-form").addEventListener("submit", (e) => {
+        e.preventDefault();
+        createSetupKey(e.target).catch(showError);
+    });
+
+    if (token()) {
+        start();
+    } else {
+        logout();
+    }
+});
@@ -45,11 +45,7 @@ func (h *DNSSettingsHandler) GetDNSSettings(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	apiDNSSettings := &api.DNSSettings{
-		DisabledManagementGroups: dnsSettings.DisabledManagementGroups,
-	}
-
-	util.WriteJSONObject(w, apiDNSSettings)
+	util.WriteJSONObject(w, toDNSSettingsResponse(dnsSettings))
 }
 
 // UpdateDNSSettings handles update to DNS settings of an account
@@ -72,15 +68,37 @@ func (h *DNSSettingsHandler) UpdateDNSSettings(w http.ResponseWriter, r *http.Re
 		DisabledManagementGroups: req.DisabledManagementGroups,
 	}
 
+	if req.SearchDomainGroups != nil {
+		for _, searchDomainGroup := range *req.SearchDomainGroups {
+			updateDNSSettings.SearchDomainGroups = append(updateDNSSettings.SearchDomainGroups, server.SearchDomainGroup{
+				Domains:  searchDomainGroup.Domains,
+				Groups:   searchDomainGroup.Groups,
+				Priority: searchDomainGroup.Priority,
+			})
+		}
+	}
+
 	err = h.accountManager.SaveDNSSettings(account.Id, user.Id, updateDNSSettings)
 	if err != nil {
 		util.WriteError(err, w)
 		return
 	}
 
-	resp := api.DNSSettings{
-		DisabledManagementGroups: updateDNSSettings.DisabledManagementGroups,
+	util.WriteJSONObject(w, toDNSSettingsResponse(updateDNSSettings))
+}
+
+func toDNSSettingsResponse(dnsSettings *server.DNSSettings) *api.DNSSettings {
+	searchDomainGroups := make([]api.SearchDomainGroup, 0, len(dnsSettings.SearchDomainGroups))
+	for _, searchDomainGroup := range dnsSettings.SearchDomainGroups {
+		searchDomainGroups = append(searchDomainGroups, api.SearchDomainGroup{
+			Domains:  searchDomainGroup.Domains,
+			Groups:   searchDomainGroup.Groups,
+			Priority: searchDomainGroup.Priority,
+		})
 	}
 
-	util.WriteJSONObject(w, &resp)
+	return &api.DNSSettings{
+		DisabledManagementGroups: dnsSettings.DisabledManagementGroups,
+		SearchDomainGroups:       &searchDomainGroups,
+	}
 }
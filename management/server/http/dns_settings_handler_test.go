@@ -85,6 +85,7 @@ func TestDNSSettingsHandlers(t *testing.T) {
 			expectedBody:   true,
 			expectedDNSSettings: &api.DNSSettings{
 				DisabledManagementGroups: baseExistingDNSSettings.DisabledManagementGroups,
+				SearchDomainGroups:       &[]api.SearchDomainGroup{},
 			},
 		},
 		{
@@ -97,6 +98,22 @@ func TestDNSSettingsHandlers(t *testing.T) {
 			expectedBody:   true,
 			expectedDNSSettings: &api.DNSSettings{
 				DisabledManagementGroups: []string{"group1", "group2"},
+				SearchDomainGroups:       &[]api.SearchDomainGroup{},
+			},
+		},
+		{
+			name:        "Update DNS Settings With Search Domain Groups",
+			requestType: http.MethodPut,
+			requestPath: "/api/dns/settings",
+			requestBody: bytes.NewBuffer(
+				[]byte("{\"disabled_management_groups\":[],\"search_domain_groups\":[{\"domains\":[\"corp.example.com\"],\"groups\":[\"group1\"],\"priority\":10}]}")),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedDNSSettings: &api.DNSSettings{
+				DisabledManagementGroups: []string{},
+				SearchDomainGroups: &[]api.SearchDomainGroup{
+					{Domains: []string{"corp.example.com"}, Groups: []string{"group1"}, Priority: 10},
+				},
 			},
 		},
 		{
@@ -107,7 +124,7 @@ func TestDNSSettingsHandlers(t *testing.T) {
 				[]byte("{}")),
 			expectedStatus:      http.StatusOK,
 			expectedBody:        true,
-			expectedDNSSettings: &api.DNSSettings{},
+			expectedDNSSettings: &api.DNSSettings{SearchDomainGroups: &[]api.SearchDomainGroup{}},
 		},
 	}
 
@@ -3,14 +3,17 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
 
 	"github.com/FlintyLemming/netbird/management/server"
 	"github.com/FlintyLemming/netbird/management/server/activity"
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	"github.com/FlintyLemming/netbird/management/server/http/util"
 	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	"github.com/FlintyLemming/netbird/management/server/status"
 )
 
 // EventsHandler HTTP handler
@@ -59,6 +62,122 @@ func (h *EventsHandler) GetAllEvents(w http.ResponseWriter, r *http.Request) {
 	util.WriteJSONObject(w, events)
 }
 
+// StreamEvents upgrades the connection to a WebSocket and sends the live events of the account until it is closed
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	types, err := parseLiveEventTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	subscription, err := h.accountManager.SubscribeAccountEvents(account.Id, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+	defer subscription.Close()
+
+	wsServer := websocket.Server{
+		// the requests are authenticated by a token rather than by cookies, any origin is allowed like by the CORS policy
+		Handshake: func(*websocket.Config, *http.Request) error {
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			h.sendLiveEvents(conn, subscription, types, account.Id, user.Id)
+		},
+	}
+	wsServer.ServeHTTP(w, r)
+}
+
+// sendLiveEvents sends the events of the subscription until the subscriber closes the connection
+func (h *EventsHandler) sendLiveEvents(conn *websocket.Conn, subscription *server.AccountEventSubscription,
+	types map[server.AccountEventType]struct{}, accountID, userID string) {
+	defer conn.Close() //nolint:errcheck
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		// the subscriber doesn't send messages, reading detects when it closes the connection
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if _, ok := types[event.Type]; len(types) > 0 && !ok {
+				continue
+			}
+
+			resp := toLiveEventResponse(event)
+			if resp.Event != nil {
+				if err := h.fillEventsWithUserInfo([]*api.Event{resp.Event}, accountID, userID); err != nil {
+					log.Warnf("failed filling the user info of a live event: %v", err)
+				}
+			}
+			if err := websocket.JSON.Send(conn, resp); err != nil {
+				log.Debugf("failed sending a live event of account %s: %v", accountID, err)
+				return
+			}
+		}
+	}
+}
+
+func parseLiveEventTypes(param string) (map[server.AccountEventType]struct{}, error) {
+	types := make(map[server.AccountEventType]struct{})
+	if param == "" {
+		return types, nil
+	}
+
+	for _, t := range strings.Split(param, ",") {
+		eventType := server.AccountEventType(strings.TrimSpace(t))
+		switch eventType {
+		case server.AccountEventPeerStatus, server.AccountEventActivity:
+			types[eventType] = struct{}{}
+		default:
+			return nil, status.Errorf(status.InvalidArgument, "invalid event type %s", eventType)
+		}
+	}
+	return types, nil
+}
+
+func toLiveEventResponse(event *server.AccountEvent) *api.LiveEvent {
+	resp := &api.LiveEvent{
+		Type:      api.LiveEventType(event.Type),
+		Timestamp: event.Timestamp,
+	}
+	if event.Peer != nil {
+		resp.Peer = &api.LivePeerStatus{
+			Id:           event.Peer.ID,
+			Name:         event.Peer.Name,
+			Ip:           event.Peer.IP.String(),
+			Connected:    event.Peer.Status.Connected,
+			LastSeen:     event.Peer.Status.LastSeen,
+			LoginExpired: event.Peer.Status.LoginExpired,
+		}
+	}
+	if event.Activity != nil {
+		resp.Event = toEventResponse(event.Activity)
+	}
+	return resp
+}
+
 func (h *EventsHandler) fillEventsWithUserInfo(events []*api.Event, accountId, userId string) error {
 	// build email, name maps based on users
 	userInfos, err := h.accountManager.GetUsersFromAccount(accountId, userId)
@@ -3,20 +3,25 @@ package http
 import (
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
 
 	"github.com/FlintyLemming/netbird/management/server"
 	"github.com/FlintyLemming/netbird/management/server/activity"
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
 	"github.com/FlintyLemming/netbird/management/server/mock_server"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 )
 
 func initEventsTestData(account string, user *server.User, events ...*activity.Event) *EventsHandler {
@@ -251,3 +256,75 @@ func TestEvents_GetEvents(t *testing.T) {
 		})
 	}
 }
+
+func TestEvents_StreamEvents(t *testing.T) {
+	accountID := "test_account"
+	adminUser := server.NewAdminUser("test_user")
+	handler := initEventsTestData(accountID, adminUser)
+
+	accountEvents := &server.AccountEventsManager{}
+	subscribed := make(chan struct{}, 1)
+	handler.accountManager.(*mock_server.MockAccountManager).SubscribeAccountEventsFunc = func(accountID, userID string) (*server.AccountEventSubscription, error) {
+		defer func() { subscribed <- struct{}{} }()
+		return accountEvents.Subscribe(accountID, ""), nil
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/events/stream", handler.StreamEvents).Methods("GET")
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/events/stream?types=peer_status"
+	conn, err := websocket.Dial(wsURL, "", srv.URL)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the subscription")
+	}
+
+	lastSeen := time.Now().UTC()
+	accountEvents.Publish(accountID, &server.AccountEvent{
+		Type:     server.AccountEventActivity,
+		Activity: &activity.Event{ID: 1, Activity: activity.PeerRenamed, Timestamp: lastSeen},
+	})
+	accountEvents.Publish(accountID, &server.AccountEvent{
+		Type:      server.AccountEventPeerStatus,
+		Timestamp: lastSeen,
+		Peer: &nbpeer.Peer{
+			ID:     "peer1",
+			Name:   "peer-one",
+			IP:     net.ParseIP("100.64.0.1"),
+			Status: &nbpeer.PeerStatus{Connected: true, LastSeen: lastSeen},
+		},
+	})
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	var got api.LiveEvent
+	require.NoError(t, websocket.JSON.Receive(conn, &got))
+	assert.Equal(t, api.LiveEventTypePeerStatus, got.Type, "the events of the other types should be filtered out")
+	require.NotNil(t, got.Peer)
+	assert.Equal(t, "peer1", got.Peer.Id)
+	assert.Equal(t, "peer-one", got.Peer.Name)
+	assert.Equal(t, "100.64.0.1", got.Peer.Ip)
+	assert.True(t, got.Peer.Connected)
+	assert.True(t, lastSeen.Equal(got.Peer.LastSeen))
+	assert.Nil(t, got.Event)
+}
+
+func TestEvents_StreamEvents_InvalidType(t *testing.T) {
+	accountID := "test_account"
+	adminUser := server.NewAdminUser("test_user")
+	handler := initEventsTestData(accountID, adminUser)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/events/stream?types=unknown", nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/events/stream", handler.StreamEvents).Methods("GET")
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+}
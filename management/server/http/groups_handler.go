@@ -3,6 +3,7 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	"github.com/FlintyLemming/netbird/management/server/http/util"
@@ -78,16 +79,6 @@ func (h *GroupsHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	allGroup, err := account.GetGroupAll()
-	if err != nil {
-		util.WriteError(err, w)
-		return
-	}
-	if allGroup.ID == groupID {
-		util.WriteError(status.Errorf(status.InvalidArgument, "updating group ALL is not allowed"), w)
-		return
-	}
-
 	var req api.PutApiGroupsGroupIdJSONRequestBody
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
@@ -95,36 +86,35 @@ func (h *GroupsHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Name == "" {
-		util.WriteError(status.Errorf(status.InvalidArgument, "group name shouldn't be empty"), w)
+	h.saveGroup(w, account, user, eg, req)
+}
+
+// CreateGroup handles group creation request. A request with the external ID of an existing group updates it
+func (h *GroupsHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
 		return
 	}
 
-	var peers []string
-	if req.Peers == nil {
-		peers = make([]string, 0)
-	} else {
-		peers = *req.Peers
-	}
-	group := server.Group{
-		ID:                   groupID,
-		Name:                 req.Name,
-		Peers:                peers,
-		Issued:               eg.Issued,
-		IntegrationReference: eg.IntegrationReference,
+	var req api.PostApiGroupsJSONRequestBody
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
 	}
 
-	if err := h.accountManager.SaveGroup(account.Id, user.Id, &group); err != nil {
-		log.Errorf("failed updating group %s under account %s %v", groupID, account.Id, err)
-		util.WriteError(err, w)
-		return
+	var eg *server.Group
+	if req.ExternalId != nil {
+		eg = account.FindGroupByExternalID(*req.ExternalId)
 	}
 
-	util.WriteJSONObject(w, toGroupResponse(account, &group))
+	h.saveGroup(w, account, user, eg, req)
 }
 
-// CreateGroup handles group creation request
-func (h *GroupsHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+// UpsertGroup creates the group with the external ID of the path or updates it when it exists
+func (h *GroupsHandler) UpsertGroup(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
 	account, user, err := h.accountManager.GetAccountFromToken(claims)
 	if err != nil {
@@ -132,33 +122,91 @@ func (h *GroupsHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req api.PostApiGroupsJSONRequestBody
+	externalID := mux.Vars(r)["externalId"]
+	if len(externalID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "external ID can't be empty"), w)
+		return
+	}
+
+	var req api.PutApiGroupsExternalExternalIdJSONRequestBody
 	err = json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
 		return
 	}
+	req.ExternalId = &externalID
 
+	h.saveGroup(w, account, user, account.FindGroupByExternalID(externalID), req)
+}
+
+// saveGroup creates a group from the request when eg is nil, otherwise it updates eg keeping the values the request
+// doesn't set
+func (h *GroupsHandler) saveGroup(w http.ResponseWriter, account *server.Account, user *server.User, eg *server.Group, req api.GroupRequest) {
 	if req.Name == "" {
 		util.WriteError(status.Errorf(status.InvalidArgument, "group name shouldn't be empty"), w)
 		return
 	}
 
-	var peers []string
-	if req.Peers == nil {
-		peers = make([]string, 0)
-	} else {
-		peers = *req.Peers
-	}
 	group := server.Group{
 		ID:     xid.New().String(),
-		Name:   req.Name,
-		Peers:  peers,
 		Issued: server.GroupIssuedAPI,
 	}
+	if eg != nil {
+		allGroup, err := account.GetGroupAll()
+		if err != nil {
+			util.WriteError(err, w)
+			return
+		}
+		if allGroup.ID == eg.ID {
+			util.WriteError(status.Errorf(status.InvalidArgument, "updating group ALL is not allowed"), w)
+			return
+		}
+
+		group = *eg.Copy()
+	}
+	group.Name = req.Name
 
-	err = h.accountManager.SaveGroup(account.Id, user.Id, &group)
-	if err != nil {
+	if req.Peers == nil {
+		group.Peers = make([]string, 0)
+	} else {
+		group.Peers = *req.Peers
+	}
+
+	// keep the current limit when the request doesn't set it
+	if req.BandwidthLimit != nil {
+		if *req.BandwidthLimit < 0 {
+			util.WriteError(status.Errorf(status.InvalidArgument, "bandwidth limit can't be negative"), w)
+			return
+		}
+		group.BandwidthLimit = uint64(*req.BandwidthLimit)
+	}
+
+	// keep the current login expiration when the request doesn't set it
+	if req.LoginExpiration != nil {
+		if *req.LoginExpiration < 0 {
+			util.WriteError(status.Errorf(status.InvalidArgument, "login expiration can't be negative"), w)
+			return
+		}
+		group.LoginExpiration = time.Duration(*req.LoginExpiration) * time.Second
+	}
+
+	// keep the current suffix when the request doesn't set it
+	if req.DnsSuffix != nil {
+		group.DNSSuffix = *req.DnsSuffix
+	}
+
+	// keep the current app routing when the request doesn't set it
+	if req.AppRouting != nil {
+		group.AppRoutingMode, group.AppRoutingApplications = toAppRouting(req.AppRouting)
+	}
+
+	// keep the current external ID when the request doesn't set it
+	if req.ExternalId != nil {
+		group.ExternalID = *req.ExternalId
+	}
+
+	if err := h.accountManager.SaveGroup(account.Id, user.Id, &group); err != nil {
+		log.Errorf("failed saving group %s under account %s %v", group.ID, account.Id, err)
 		util.WriteError(err, w)
 		return
 	}
@@ -240,10 +288,15 @@ func (h *GroupsHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
 func toGroupResponse(account *server.Account, group *server.Group) *api.Group {
 	cache := make(map[string]api.PeerMinimum)
 	gr := api.Group{
-		Id:         group.ID,
-		Name:       group.Name,
-		PeersCount: len(group.Peers),
-		Issued:     &group.Issued,
+		Id:              group.ID,
+		Name:            group.Name,
+		PeersCount:      len(group.Peers),
+		Issued:          &group.Issued,
+		BandwidthLimit:  bandwidthLimitResponse(group.BandwidthLimit),
+		DnsSuffix:       dnsSuffixResponse(group.DNSSuffix),
+		AppRouting:      appRoutingResponse(group),
+		ExternalId:      externalIDResponse(group.ExternalID),
+		LoginExpiration: loginExpirationResponse(group.LoginExpiration),
 	}
 
 	for _, pid := range group.Peers {
@@ -263,3 +316,55 @@ func toGroupResponse(account *server.Account, group *server.Group) *api.Group {
 	}
 	return &gr
 }
+
+// externalIDResponse returns the external ID for API responses, omitting it when there is none
+func externalIDResponse(externalID string) *string {
+	if externalID == "" {
+		return nil
+	}
+	return &externalID
+}
+
+// loginExpirationResponse returns the login expiration in seconds for API responses, omitting it when the account one
+// applies
+func loginExpirationResponse(expiration time.Duration) *int {
+	if expiration == 0 {
+		return nil
+	}
+	seconds := int(expiration.Seconds())
+	return &seconds
+}
+
+// dnsSuffixResponse returns the suffix for API responses, omitting it when there is none
+func dnsSuffixResponse(suffix string) *string {
+	if suffix == "" {
+		return nil
+	}
+	return &suffix
+}
+
+// toAppRouting returns the app routing mode and applications of a group request, the disabled mode clears them
+func toAppRouting(req *api.GroupAppRouting) (server.AppRoutingMode, []string) {
+	if req.Mode == api.GroupAppRoutingModeDisabled {
+		return "", nil
+	}
+
+	var applications []string
+	if req.Applications != nil {
+		applications = *req.Applications
+	}
+	return server.AppRoutingMode(req.Mode), applications
+}
+
+// appRoutingResponse returns the app routing of the group for API responses, omitting it when it is disabled
+func appRoutingResponse(group *server.Group) *api.GroupAppRouting {
+	if group.AppRoutingMode == "" {
+		return nil
+	}
+
+	applications := group.AppRoutingApplications
+	return &api.GroupAppRouting{
+		Mode:         api.GroupAppRoutingMode(group.AppRoutingMode),
+		Applications: &applications,
+	}
+}
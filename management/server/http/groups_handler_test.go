@@ -190,6 +190,24 @@ func TestWriteGroup(t *testing.T) {
 				Issued: &groupIssuedAPI,
 			},
 		},
+		{
+			name:        "Write Group POST With App Routing",
+			requestType: http.MethodPost,
+			requestPath: "/api/groups",
+			requestBody: bytes.NewBuffer(
+				[]byte(`{"name":"Apps","app_routing":{"mode":"include","applications":["C:\\Apps\\app.exe"]}}`)),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedGroup: &api.Group{
+				Id:     "id-was-set",
+				Name:   "Apps",
+				Issued: &groupIssuedAPI,
+				AppRouting: &api.GroupAppRouting{
+					Mode:         api.GroupAppRoutingModeInclude,
+					Applications: &[]string{`C:\Apps\app.exe`},
+				},
+			},
+		},
 		{
 			name:        "Write Group POST Invalid Name",
 			requestType: http.MethodPost,
@@ -76,6 +76,7 @@ func APIHandler(accountManager s.AccountManager, jwtValidator jwtclaims.JWTValid
 	api.addSetupKeysEndpoint()
 	api.addRulesEndpoint()
 	api.addPoliciesEndpoint()
+	api.addServicesEndpoint()
 	api.addGroupsEndpoint()
 	api.addRoutesEndpoint()
 	api.addDNSNameserversEndpoint()
@@ -116,8 +117,14 @@ func (apiHandler *apiHandler) addAccountsEndpoint() {
 func (apiHandler *apiHandler) addPeersEndpoint() {
 	peersHandler := NewPeersHandler(apiHandler.AccountManager, apiHandler.AuthCfg)
 	apiHandler.Router.HandleFunc("/peers", peersHandler.GetAllPeers).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/latency", peersHandler.GetPeerLatencies).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/usage", peersHandler.GetPeerUsage).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/bulk", peersHandler.StartBulkOperation).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/bulk/{jobId}", peersHandler.GetBulkOperation).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/peers/{peerId}", peersHandler.HandlePeer).
 		Methods("GET", "PUT", "DELETE", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/{peerId}/enable", peersHandler.EnablePeer).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/peers/{peerId}/network-map", peersHandler.GetPeerNetworkMap).Methods("GET", "OPTIONS")
 }
 
 func (apiHandler *apiHandler) addUsersEndpoint() {
@@ -143,6 +150,7 @@ func (apiHandler *apiHandler) addSetupKeysEndpoint() {
 	apiHandler.Router.HandleFunc("/setup-keys", keysHandler.CreateSetupKey).Methods("POST", "OPTIONS")
 	apiHandler.Router.HandleFunc("/setup-keys/{keyId}", keysHandler.GetSetupKey).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/setup-keys/{keyId}", keysHandler.UpdateSetupKey).Methods("PUT", "OPTIONS")
+	apiHandler.Router.HandleFunc("/setup-keys/external/{externalId}", keysHandler.UpsertSetupKey).Methods("PUT", "OPTIONS")
 }
 
 func (apiHandler *apiHandler) addRulesEndpoint() {
@@ -158,9 +166,20 @@ func (apiHandler *apiHandler) addPoliciesEndpoint() {
 	policiesHandler := NewPoliciesHandler(apiHandler.AccountManager, apiHandler.AuthCfg)
 	apiHandler.Router.HandleFunc("/policies", policiesHandler.GetAllPolicies).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/policies", policiesHandler.CreatePolicy).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/policies/simulate", policiesHandler.SimulatePolicy).Methods("POST", "OPTIONS")
 	apiHandler.Router.HandleFunc("/policies/{policyId}", policiesHandler.UpdatePolicy).Methods("PUT", "OPTIONS")
 	apiHandler.Router.HandleFunc("/policies/{policyId}", policiesHandler.GetPolicy).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/policies/{policyId}", policiesHandler.DeletePolicy).Methods("DELETE", "OPTIONS")
+	apiHandler.Router.HandleFunc("/policies/external/{externalId}", policiesHandler.UpsertPolicy).Methods("PUT", "OPTIONS")
+}
+
+func (apiHandler *apiHandler) addServicesEndpoint() {
+	servicesHandler := NewServicesHandler(apiHandler.AccountManager, apiHandler.AuthCfg)
+	apiHandler.Router.HandleFunc("/services", servicesHandler.GetAllServices).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/services", servicesHandler.CreateService).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/services/{serviceId}", servicesHandler.UpdateService).Methods("PUT", "OPTIONS")
+	apiHandler.Router.HandleFunc("/services/{serviceId}", servicesHandler.GetService).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/services/{serviceId}", servicesHandler.DeleteService).Methods("DELETE", "OPTIONS")
 }
 
 func (apiHandler *apiHandler) addGroupsEndpoint() {
@@ -170,15 +189,20 @@ func (apiHandler *apiHandler) addGroupsEndpoint() {
 	apiHandler.Router.HandleFunc("/groups/{groupId}", groupsHandler.UpdateGroup).Methods("PUT", "OPTIONS")
 	apiHandler.Router.HandleFunc("/groups/{groupId}", groupsHandler.GetGroup).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/groups/{groupId}", groupsHandler.DeleteGroup).Methods("DELETE", "OPTIONS")
+	apiHandler.Router.HandleFunc("/groups/external/{externalId}", groupsHandler.UpsertGroup).Methods("PUT", "OPTIONS")
 }
 
 func (apiHandler *apiHandler) addRoutesEndpoint() {
 	routesHandler := NewRoutesHandler(apiHandler.AccountManager, apiHandler.AuthCfg)
 	apiHandler.Router.HandleFunc("/routes", routesHandler.GetAllRoutes).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/routes", routesHandler.CreateRoute).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/routes/import", routesHandler.ImportRoutes).Methods("POST", "OPTIONS")
+	apiHandler.Router.HandleFunc("/routes/active", routesHandler.GetActiveRoutes).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/routes/suggestions", routesHandler.GetRouteSuggestions).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/routes/{routeId}", routesHandler.UpdateRoute).Methods("PUT", "OPTIONS")
 	apiHandler.Router.HandleFunc("/routes/{routeId}", routesHandler.GetRoute).Methods("GET", "OPTIONS")
 	apiHandler.Router.HandleFunc("/routes/{routeId}", routesHandler.DeleteRoute).Methods("DELETE", "OPTIONS")
+	apiHandler.Router.HandleFunc("/routes/external/{externalId}", routesHandler.UpsertRoute).Methods("PUT", "OPTIONS")
 }
 
 func (apiHandler *apiHandler) addDNSNameserversEndpoint() {
@@ -199,4 +223,5 @@ func (apiHandler *apiHandler) addDNSSettingEndpoint() {
 func (apiHandler *apiHandler) addEventsEndpoint() {
 	eventsHandler := NewEventsHandler(apiHandler.AccountManager, apiHandler.AuthCfg)
 	apiHandler.Router.HandleFunc("/events", eventsHandler.GetAllEvents).Methods("GET", "OPTIONS")
+	apiHandler.Router.HandleFunc("/events/stream", eventsHandler.StreamEvents).Methods("GET", "OPTIONS")
 }
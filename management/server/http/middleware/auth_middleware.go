@@ -42,6 +42,8 @@ type AuthMiddleware struct {
 
 const (
 	userProperty = "user"
+	// accessTokenParam is the query parameter of the token of the WebSocket requests
+	accessTokenParam = "access_token"
 )
 
 // NewAuthMiddleware instance constructor
@@ -66,7 +68,7 @@ func NewAuthMiddleware(getAccountFromPAT GetAccountFromPATFunc, validateAndParse
 // Handler method of the middleware which authenticates a user either by JWT claims or by PAT
 func (m *AuthMiddleware) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		auth := strings.Split(r.Header.Get("Authorization"), " ")
+		auth := strings.Split(authorizationHeader(r), " ")
 		authType := strings.ToLower(auth[0])
 
 		// fallback to token when receive pat as bearer
@@ -99,6 +101,28 @@ func (m *AuthMiddleware) Handler(h http.Handler) http.Handler {
 	})
 }
 
+// authorizationHeader returns the Authorization header of the request. Browsers can't set it on WebSocket requests,
+// they pass the token in the access_token query parameter instead, which is removed from the URL so it isn't logged
+func authorizationHeader(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if header != "" || !isWebSocketUpgrade(r) {
+		return header
+	}
+
+	query := r.URL.Query()
+	token := query.Get(accessTokenParam)
+	if token == "" {
+		return ""
+	}
+	query.Del(accessTokenParam)
+	r.URL.RawQuery = query.Encode()
+	return "Bearer " + token
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 // CheckJWTFromRequest checks if the JWT is valid
 func (m *AuthMiddleware) checkJWTFromRequest(w http.ResponseWriter, r *http.Request, auth []string) error {
 	token, err := getTokenFromJWTRequest(auth)
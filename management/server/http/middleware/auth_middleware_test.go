@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -161,3 +162,81 @@ func TestAuthMiddleware_Handler(t *testing.T) {
 	}
 
 }
+
+func TestAuthMiddleware_Handler_WebSocketAccessToken(t *testing.T) {
+	tt := []struct {
+		name               string
+		url                string
+		upgrade            bool
+		expectedStatusCode int
+	}{
+		{
+			name:               "Valid JWT Token",
+			url:                "http://testing/api/events/stream?access_token=" + JWT + "&types=activity",
+			upgrade:            true,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Valid PAT Token",
+			url:                "http://testing/api/events/stream?access_token=" + PAT,
+			upgrade:            true,
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "Invalid Token",
+			url:                "http://testing/api/events/stream?access_token=" + wrongToken,
+			upgrade:            true,
+			expectedStatusCode: 401,
+		},
+		{
+			name:               "Not a WebSocket Request",
+			url:                "http://testing/api/events?access_token=" + JWT,
+			expectedStatusCode: 401,
+		},
+	}
+
+	var rawQuery string
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+	})
+
+	claimsExtractor := jwtclaims.NewClaimsExtractor(
+		jwtclaims.WithAudience(audience),
+		jwtclaims.WithUserIDClaim(userIDClaim),
+	)
+
+	authMiddleware := NewAuthMiddleware(
+		mockGetAccountFromPAT,
+		mockValidateAndParseToken,
+		mockMarkPATUsed,
+		mockCheckUserAccessByJWTGroups,
+		claimsExtractor,
+		audience,
+		userIDClaim,
+	)
+
+	handlerToTest := authMiddleware.Handler(nextHandler)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rawQuery = ""
+			req := httptest.NewRequest("GET", tc.url, nil)
+			if tc.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			rec := httptest.NewRecorder()
+
+			handlerToTest.ServeHTTP(rec, req)
+
+			result := rec.Result()
+			defer result.Body.Close()
+			if result.StatusCode != tc.expectedStatusCode {
+				t.Errorf("expected status code %d, got %d", tc.expectedStatusCode, result.StatusCode)
+			}
+			if strings.Contains(rawQuery, "access_token") {
+				t.Errorf("expected the access token to be removed from the query, got %s", rawQuery)
+			}
+		})
+	}
+}
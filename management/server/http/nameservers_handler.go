@@ -79,7 +79,12 @@ func (h *NameserversHandler) CreateNameserverGroup(w http.ResponseWriter, r *htt
 		return
 	}
 
-	nsGroup, err := h.accountManager.CreateNameServerGroup(account.Id, req.Name, req.Description, nsList, req.Groups, req.Primary, req.Domains, req.Enabled, user.Id, req.SearchDomainsEnabled)
+	var priority int
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+
+	nsGroup, err := h.accountManager.CreateNameServerGroup(account.Id, req.Name, req.Description, nsList, req.Groups, req.Primary, req.Domains, req.Enabled, user.Id, req.SearchDomainsEnabled, req.ForwardViaRoutes, priority)
 	if err != nil {
 		util.WriteError(err, w)
 		return
@@ -128,6 +133,10 @@ func (h *NameserversHandler) UpdateNameserverGroup(w http.ResponseWriter, r *htt
 		Groups:               req.Groups,
 		Enabled:              req.Enabled,
 		SearchDomainsEnabled: req.SearchDomainsEnabled,
+		ForwardViaRoutes:     req.ForwardViaRoutes,
+	}
+	if req.Priority != nil {
+		updatedNSGroup.Priority = *req.Priority
 	}
 
 	err = h.accountManager.SaveNameServerGroup(account.Id, user.Id, updatedNSGroup)
@@ -216,6 +225,7 @@ func toNameserverGroupResponse(serverNSGroup *nbdns.NameServerGroup) *api.Namese
 		nsList = append(nsList, apiNS)
 	}
 
+	priority := serverNSGroup.Priority
 	return &api.NameserverGroup{
 		Id:                   serverNSGroup.ID,
 		Name:                 serverNSGroup.Name,
@@ -226,5 +236,7 @@ func toNameserverGroupResponse(serverNSGroup *nbdns.NameServerGroup) *api.Namese
 		Nameservers:          nsList,
 		Enabled:              serverNSGroup.Enabled,
 		SearchDomainsEnabled: serverNSGroup.SearchDomainsEnabled,
+		ForwardViaRoutes:     serverNSGroup.ForwardViaRoutes,
+		Priority:             &priority,
 	}
 }
@@ -67,7 +67,7 @@ func initNameserversTestData() *NameserversHandler {
 				}
 				return nil, status.Errorf(status.NotFound, "nameserver group with ID %s not found", nsGroupID)
 			},
-			CreateNameServerGroupFunc: func(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, _ string, searchDomains bool) (*nbdns.NameServerGroup, error) {
+			CreateNameServerGroupFunc: func(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, _ string, searchDomains bool, forwardViaRoutes bool, priority int) (*nbdns.NameServerGroup, error) {
 				return &nbdns.NameServerGroup{
 					ID:                   existingNSGroupID,
 					Name:                 name,
@@ -78,6 +78,8 @@ func initNameserversTestData() *NameserversHandler {
 					Primary:              primary,
 					Domains:              domains,
 					SearchDomainsEnabled: searchDomains,
+					ForwardViaRoutes:     forwardViaRoutes,
+					Priority:             priority,
 				}, nil
 			},
 			DeleteNameServerGroupFunc: func(accountID, nsGroupID, _ string) error {
@@ -106,6 +108,8 @@ func initNameserversTestData() *NameserversHandler {
 }
 
 func TestNameserversHandlers(t *testing.T) {
+	ir := func(v int) *int { return &v }
+
 	tt := []struct {
 		name            string
 		expectedStatus  int
@@ -134,7 +138,7 @@ func TestNameserversHandlers(t *testing.T) {
 			requestType: http.MethodPost,
 			requestPath: "/api/dns/nameservers",
 			requestBody: bytes.NewBuffer(
-				[]byte("{\"name\":\"name\",\"Description\":\"Post\",\"nameservers\":[{\"ip\":\"1.1.1.1\",\"ns_type\":\"udp\",\"port\":53}],\"groups\":[\"group\"],\"enabled\":true,\"primary\":true}")),
+				[]byte("{\"name\":\"name\",\"Description\":\"Post\",\"nameservers\":[{\"ip\":\"1.1.1.1\",\"ns_type\":\"udp\",\"port\":53}],\"groups\":[\"group\"],\"enabled\":true,\"primary\":true,\"priority\":10}")),
 			expectedStatus: http.StatusOK,
 			expectedBody:   true,
 			expectedNSGroup: &api.NameserverGroup{
@@ -148,9 +152,10 @@ func TestNameserversHandlers(t *testing.T) {
 						Port:   53,
 					},
 				},
-				Groups:  []string{"group"},
-				Enabled: true,
-				Primary: true,
+				Groups:   []string{"group"},
+				Enabled:  true,
+				Primary:  true,
+				Priority: ir(10),
 			},
 		},
 		{
@@ -181,9 +186,10 @@ func TestNameserversHandlers(t *testing.T) {
 						Port:   53,
 					},
 				},
-				Groups:  []string{"group"},
-				Enabled: true,
-				Primary: true,
+				Groups:   []string{"group"},
+				Enabled:  true,
+				Primary:  true,
+				Priority: ir(0),
 			},
 		},
 		{
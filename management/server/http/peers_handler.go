@@ -3,7 +3,9 @@ package http
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 
@@ -64,7 +66,10 @@ func (h *PeersHandler) getPeer(account *server.Account, peerID, userID string, w
 	netMap := account.GetPeerNetworkMap(peerID, h.accountManager.GetDNSDomain())
 	accessiblePeers := toAccessiblePeers(netMap, dnsDomain)
 
-	util.WriteJSONObject(w, toSinglePeerResponse(peerToReturn, groupsInfo, dnsDomain, accessiblePeers))
+	resp := toSinglePeerResponse(peerToReturn, groupsInfo, dnsDomain, accessiblePeers)
+	resp.SignalConnected = signalConnected(h.accountManager.GetPeersSignalPresence(account.Id), peer.ID)
+
+	util.WriteJSONObject(w, resp)
 }
 
 func (h *PeersHandler) updatePeer(account *server.Account, user *server.User, peerID string, w http.ResponseWriter, r *http.Request) {
@@ -82,6 +87,51 @@ func (h *PeersHandler) updatePeer(account *server.Account, user *server.User, pe
 		update.Status = &nbpeer.PeerStatus{RequiresApproval: *req.ApprovalRequired}
 	}
 
+	// keep the current limit when the request doesn't set it
+	if req.BandwidthLimit != nil {
+		if *req.BandwidthLimit < 0 {
+			util.WriteError(status.Errorf(status.InvalidArgument, "bandwidth limit can't be negative"), w)
+			return
+		}
+		update.BandwidthLimit = uint64(*req.BandwidthLimit)
+	} else if existing, ok := account.Peers[peerID]; ok {
+		update.BandwidthLimit = existing.BandwidthLimit
+	}
+
+	// keep the current IP when the request doesn't set it
+	if req.Ip != nil {
+		update.IP = net.ParseIP(*req.Ip)
+		if update.IP == nil {
+			util.WriteError(status.Errorf(status.InvalidArgument, "invalid IP %s", *req.Ip), w)
+			return
+		}
+	}
+
+	// keep the current aliases when the request doesn't set them
+	if req.DnsAliases != nil {
+		update.DNSAliases = *req.DnsAliases
+	} else if existing, ok := account.Peers[peerID]; ok {
+		update.DNSAliases = existing.DNSAliases
+	}
+
+	// keep the current labels when the request doesn't set them
+	if req.Labels != nil {
+		update.Labels = *req.Labels
+	} else if existing, ok := account.Peers[peerID]; ok {
+		update.Labels = existing.Labels
+	}
+
+	// keep the current external ID when the request doesn't set it
+	if req.ExternalId != nil {
+		update.ExternalID = *req.ExternalId
+	} else if existing, ok := account.Peers[peerID]; ok {
+		update.ExternalID = existing.ExternalID
+	}
+
+	if req.Type != nil {
+		update.Type = nbpeer.PeerType(*req.Type)
+	}
+
 	peer, err := h.accountManager.UpdatePeer(account.Id, user.Id, update)
 	if err != nil {
 		util.WriteError(err, w)
@@ -94,7 +144,10 @@ func (h *PeersHandler) updatePeer(account *server.Account, user *server.User, pe
 	netMap := account.GetPeerNetworkMap(peerID, h.accountManager.GetDNSDomain())
 	accessiblePeers := toAccessiblePeers(netMap, dnsDomain)
 
-	util.WriteJSONObject(w, toSinglePeerResponse(peer, groupMinimumInfo, dnsDomain, accessiblePeers))
+	resp := toSinglePeerResponse(peer, groupMinimumInfo, dnsDomain, accessiblePeers)
+	resp.SignalConnected = signalConnected(h.accountManager.GetPeersSignalPresence(account.Id), peer.ID)
+
+	util.WriteJSONObject(w, resp)
 }
 
 func (h *PeersHandler) deletePeer(accountID, userID string, peerID string, w http.ResponseWriter) {
@@ -154,6 +207,7 @@ func (h *PeersHandler) GetAllPeers(w http.ResponseWriter, r *http.Request) {
 		}
 
 		dnsDomain := h.accountManager.GetDNSDomain()
+		signalPresence := h.accountManager.GetPeersSignalPresence(account.Id)
 
 		respBody := make([]*api.PeerBatch, 0, len(peers))
 		for _, peer := range peers {
@@ -166,7 +220,9 @@ func (h *PeersHandler) GetAllPeers(w http.ResponseWriter, r *http.Request) {
 
 			accessiblePeerNumbers := h.accessiblePeersNumber(account, peer.ID)
 
-			respBody = append(respBody, toPeerListItemResponse(peerToReturn, groupMinimumInfo, dnsDomain, accessiblePeerNumbers))
+			peerResp := toPeerListItemResponse(peerToReturn, groupMinimumInfo, dnsDomain, accessiblePeerNumbers)
+			peerResp.SignalConnected = signalConnected(signalPresence, peer.ID)
+			respBody = append(respBody, peerResp)
 		}
 		util.WriteJSONObject(w, respBody)
 		return
@@ -175,35 +231,332 @@ func (h *PeersHandler) GetAllPeers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetPeerLatencies returns the latest latency measured between the peers of the account
+func (h *PeersHandler) GetPeerLatencies(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	latencies, err := h.accountManager.GetPeerLatencies(account.Id, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	respBody := make([]*api.PeerLatency, 0, len(latencies))
+	for _, latency := range latencies {
+		respBody = append(respBody, &api.PeerLatency{
+			SourcePeerId:      latency.SourcePeerID,
+			DestinationPeerId: latency.DestinationPeerID,
+			RttMs:             float64(latency.RTT.Microseconds()) / 1000,
+			Loss:              latency.Loss,
+			MeasuredAt:        latency.MeasuredAt,
+		})
+	}
+	util.WriteJSONObject(w, respBody)
+}
+
+// GetPeerUsage returns the daily traffic reported by the peers of the account
+func (h *PeersHandler) GetPeerUsage(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	var days int
+	if daysParam := r.URL.Query().Get("days"); daysParam != "" {
+		days, err = strconv.Atoi(daysParam)
+		if err != nil || days < 1 {
+			util.WriteError(status.Errorf(status.InvalidArgument, "days should be a positive number"), w)
+			return
+		}
+	}
+
+	usage, err := h.accountManager.GetPeerUsage(account.Id, user.Id, days)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	respBody := make([]*api.PeerUsage, 0, len(usage))
+	for _, day := range usage {
+		respBody = append(respBody, &api.PeerUsage{
+			PeerId:     day.PeerID,
+			Date:       day.Date,
+			RxBytes:    int64(day.RxBytes),
+			TxBytes:    int64(day.TxBytes),
+			ReportedAt: day.ReportedAt,
+		})
+	}
+	util.WriteJSONObject(w, respBody)
+}
+
+// StartBulkOperation starts applying an action to many peers in the background and returns the job tracking it
+func (h *PeersHandler) StartBulkOperation(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	var req api.PostApiPeersBulkJSONRequestBody
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+
+	operation := server.BulkPeerOperation{
+		Action:  server.BulkPeerAction(req.Action),
+		PeerIDs: req.Peers,
+	}
+	switch req.Action {
+	case api.PeerBulkRequestActionMoveToGroup:
+		if req.GroupId == nil || *req.GroupId == "" {
+			util.WriteError(status.Errorf(status.InvalidArgument, "group_id is required to move peers"), w)
+			return
+		}
+		operation.GroupID = *req.GroupId
+	case api.PeerBulkRequestActionSetSshEnabled:
+		if req.SshEnabled == nil {
+			util.WriteError(status.Errorf(status.InvalidArgument, "ssh_enabled is required to set the SSH server state"), w)
+			return
+		}
+		operation.SSHEnabled = *req.SshEnabled
+	}
+
+	job, err := h.accountManager.StartBulkPeerOperation(account.Id, user.Id, operation)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toPeerBulkJobResponse(job))
+}
+
+// GetBulkOperation returns the progress of a bulk peer operation
+func (h *PeersHandler) GetBulkOperation(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	jobID := mux.Vars(r)["jobId"]
+	if len(jobID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid job ID"), w)
+		return
+	}
+
+	job, err := h.accountManager.GetBulkPeerJob(account.Id, user.Id, jobID)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toPeerBulkJobResponse(job))
+}
+
+func toPeerBulkJobResponse(job *server.BulkPeerJob) *api.PeerBulkJob {
+	errors := make([]api.PeerBulkError, 0, len(job.Errors))
+	for _, peerError := range job.Errors {
+		errors = append(errors, api.PeerBulkError{PeerId: peerError.PeerID, Message: peerError.Message})
+	}
+
+	resp := &api.PeerBulkJob{
+		Id:        job.ID,
+		Action:    string(job.Action),
+		Status:    api.PeerBulkJobStatus(job.Status),
+		Total:     job.Total,
+		Processed: job.Processed,
+		Errors:    errors,
+		CreatedAt: job.CreatedAt,
+	}
+	if !job.FinishedAt.IsZero() {
+		finishedAt := job.FinishedAt
+		resp.FinishedAt = &finishedAt
+	}
+	if job.Message != "" {
+		message := job.Message
+		resp.Message = &message
+	}
+	return resp
+}
+
+// GetPeerNetworkMap returns the network map sent to a peer, to troubleshoot its connectivity
+func (h *PeersHandler) GetPeerNetworkMap(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	peerID := mux.Vars(r)["peerId"]
+	if len(peerID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid peer ID"), w)
+		return
+	}
+
+	netMap, err := h.accountManager.GetPeerNetworkMap(account.Id, peerID, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toPeerNetworkMapResponse(account, netMap, h.accountManager.GetDNSDomain()))
+}
+
+// EnablePeer enables a peer disabled for inactivity
+func (h *PeersHandler) EnablePeer(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	peerID := mux.Vars(r)["peerId"]
+	if len(peerID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid peer ID"), w)
+		return
+	}
+
+	peer, err := h.accountManager.EnablePeer(account.Id, peerID, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	// the account was fetched before the peer was enabled, its network map was empty
+	netMap, err := h.accountManager.GetPeerNetworkMap(account.Id, peer.ID, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	dnsDomain := h.accountManager.GetDNSDomain()
+	groupsInfo := toGroupsInfo(account.Groups, peer.ID)
+
+	util.WriteJSONObject(w, toSinglePeerResponse(peer, groupsInfo, dnsDomain, toAccessiblePeers(netMap, dnsDomain)))
+}
+
 func (h *PeersHandler) accessiblePeersNumber(account *server.Account, peerID string) int {
 	netMap := account.GetPeerNetworkMap(peerID, h.accountManager.GetDNSDomain())
 	return len(netMap.Peers) + len(netMap.OfflinePeers)
 }
 
 func toAccessiblePeers(netMap *server.NetworkMap, dnsDomain string) []api.AccessiblePeer {
-	accessiblePeers := make([]api.AccessiblePeer, 0, len(netMap.Peers)+len(netMap.OfflinePeers))
-	for _, p := range netMap.Peers {
-		ap := api.AccessiblePeer{
+	return append(toAccessiblePeerList(netMap.Peers, dnsDomain), toAccessiblePeerList(netMap.OfflinePeers, dnsDomain)...)
+}
+
+func toAccessiblePeerList(peers []*nbpeer.Peer, dnsDomain string) []api.AccessiblePeer {
+	result := make([]api.AccessiblePeer, 0, len(peers))
+	for _, p := range peers {
+		result = append(result, api.AccessiblePeer{
 			Id:       p.ID,
 			Name:     p.Name,
 			Ip:       p.IP.String(),
 			DnsLabel: fqdn(p, dnsDomain),
 			UserId:   p.UserID,
+		})
+	}
+	return result
+}
+
+func toNetworkMapFirewallRule(rule *server.FirewallRule) api.NetworkMapFirewallRule {
+	direction := api.NetworkMapFirewallRuleDirectionIn
+	if rule.IsOutgoing() {
+		direction = api.NetworkMapFirewallRuleDirectionOut
+	}
+	return api.NetworkMapFirewallRule{
+		PeerIp:    rule.PeerIP,
+		Direction: direction,
+		Action:    api.NetworkMapFirewallRuleAction(rule.Action),
+		Protocol:  api.NetworkMapFirewallRuleProtocol(rule.Protocol),
+		Port:      rule.Port,
+	}
+}
+
+func toPeerNetworkMapResponse(account *server.Account, netMap *server.NetworkMap, dnsDomain string) *api.PeerNetworkMap {
+	firewallRules := make([]api.NetworkMapFirewallRule, 0, len(netMap.FirewallRules))
+	for _, rule := range netMap.FirewallRules {
+		firewallRules = append(firewallRules, toNetworkMapFirewallRule(rule))
+	}
+
+	routes := make([]api.NetworkMapRoute, 0, len(netMap.Routes))
+	for _, r := range netMap.Routes {
+		// the routes of the network map reference the routing peer by its WireGuard public key
+		routingPeerID := r.Peer
+		if routingPeer, err := account.FindPeerByPubKey(r.Peer); err == nil {
+			routingPeerID = routingPeer.ID
 		}
-		accessiblePeers = append(accessiblePeers, ap)
+		routes = append(routes, api.NetworkMapRoute{
+			Id:         r.ID,
+			NetworkId:  r.NetID,
+			Network:    r.Network.String(),
+			Peer:       routingPeerID,
+			Metric:     r.Metric,
+			Masquerade: r.Masquerade,
+		})
 	}
 
-	for _, p := range netMap.OfflinePeers {
-		ap := api.AccessiblePeer{
-			Id:       p.ID,
-			Name:     p.Name,
-			Ip:       p.IP.String(),
-			DnsLabel: fqdn(p, dnsDomain),
-			UserId:   p.UserID,
+	dnsConfig := api.NetworkMapDNSConfig{
+		Enabled:          netMap.DNSConfig.ServiceEnable,
+		CustomZones:      make([]api.NetworkMapDNSZone, 0, len(netMap.DNSConfig.CustomZones)),
+		NameserverGroups: make([]api.NetworkMapNameserverGroup, 0, len(netMap.DNSConfig.NameServerGroups)),
+		SearchDomains:    append([]string{}, netMap.DNSConfig.SearchDomains...),
+	}
+	for _, zone := range netMap.DNSConfig.CustomZones {
+		records := make([]api.NetworkMapDNSRecord, 0, len(zone.Records))
+		for _, record := range zone.Records {
+			records = append(records, api.NetworkMapDNSRecord{
+				Name:  record.Name,
+				Type:  record.Type,
+				Class: record.Class,
+				Ttl:   record.TTL,
+				Rdata: record.RData,
+			})
+		}
+		dnsConfig.CustomZones = append(dnsConfig.CustomZones, api.NetworkMapDNSZone{Domain: zone.Domain, Records: records})
+	}
+	for _, nsGroup := range netMap.DNSConfig.NameServerGroups {
+		nameservers := make([]api.Nameserver, 0, len(nsGroup.NameServers))
+		for _, ns := range nsGroup.NameServers {
+			nameservers = append(nameservers, api.Nameserver{
+				Ip:     ns.IP.String(),
+				NsType: api.NameserverNsType(ns.NSType.String()),
+				Port:   ns.Port,
+			})
 		}
-		accessiblePeers = append(accessiblePeers, ap)
+		dnsConfig.NameserverGroups = append(dnsConfig.NameserverGroups, api.NetworkMapNameserverGroup{
+			Id:                   nsGroup.ID,
+			Name:                 nsGroup.Name,
+			Nameservers:          nameservers,
+			Primary:              nsGroup.Primary,
+			Domains:              append([]string{}, nsGroup.Domains...),
+			SearchDomainsEnabled: nsGroup.SearchDomainsEnabled,
+			ForwardViaRoutes:     nsGroup.ForwardViaRoutes,
+			Priority:             nsGroup.Priority,
+		})
+	}
+
+	return &api.PeerNetworkMap{
+		Serial:        int(netMap.Network.CurrentSerial()),
+		LoginExpired:  netMap.LoginExpired,
+		Peers:         toAccessiblePeerList(netMap.Peers, dnsDomain),
+		OfflinePeers:  toAccessiblePeerList(netMap.OfflinePeers, dnsDomain),
+		FirewallRules: firewallRules,
+		Routes:        routes,
+		Dns:           dnsConfig,
 	}
-	return accessiblePeers
 }
 
 func toGroupsInfo(groups map[string]*server.Group, peerID string) []api.GroupMinimum {
@@ -250,6 +603,13 @@ func toSinglePeerResponse(peer *nbpeer.Peer, groupsInfo []api.GroupMinimum, dnsD
 		LoginExpired:           peer.Status.LoginExpired,
 		AccessiblePeers:        accessiblePeer,
 		ApprovalRequired:       &peer.Status.RequiresApproval,
+		BandwidthLimit:         bandwidthLimitResponse(peer.BandwidthLimit),
+		Disabled:               &peer.Status.Disabled,
+		DnsAliases:             dnsAliasesResponse(peer.DNSAliases),
+		ExternalId:             externalIDResponse(peer.ExternalID),
+		Labels:                 labelsResponse(peer.Labels),
+		LanNetworks:            lanNetworksResponse(peer.Meta.LANNetworks),
+		Type:                   api.PeerType(peer.GetType()),
 	}
 }
 
@@ -273,7 +633,56 @@ func toPeerListItemResponse(peer *nbpeer.Peer, groupsInfo []api.GroupMinimum, dn
 		LoginExpired:           peer.Status.LoginExpired,
 		AccessiblePeersCount:   accessiblePeersCount,
 		ApprovalRequired:       &peer.Status.RequiresApproval,
+		BandwidthLimit:         bandwidthLimitResponse(peer.BandwidthLimit),
+		Disabled:               &peer.Status.Disabled,
+		DnsAliases:             dnsAliasesResponse(peer.DNSAliases),
+		ExternalId:             externalIDResponse(peer.ExternalID),
+		Labels:                 labelsResponse(peer.Labels),
+		LanNetworks:            lanNetworksResponse(peer.Meta.LANNetworks),
+		Type:                   api.PeerType(peer.GetType()),
+	}
+}
+
+// signalConnected returns the Signal presence of the peer for API responses, omitting it when it is unknown
+func signalConnected(presence map[string]bool, peerID string) *bool {
+	connected, ok := presence[peerID]
+	if !ok {
+		return nil
+	}
+	return &connected
+}
+
+// dnsAliasesResponse returns the aliases for API responses, omitting them when there are none
+func dnsAliasesResponse(aliases []string) *[]string {
+	if len(aliases) == 0 {
+		return nil
+	}
+	return &aliases
+}
+
+// labelsResponse returns the labels for API responses, omitting them when there are none
+func labelsResponse(labels map[string]string) *map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return &labels
+}
+
+// lanNetworksResponse returns the detected LAN networks for API responses, omitting them when there are none
+func lanNetworksResponse(networks []string) *[]string {
+	if len(networks) == 0 {
+		return nil
+	}
+	return &networks
+}
+
+// bandwidthLimitResponse returns the limit for API responses, omitting it when there is none
+func bandwidthLimitResponse(limit uint64) *int {
+	if limit == 0 {
+		return nil
 	}
+	l := int(limit)
+	return &l
 }
 
 func fqdn(peer *nbpeer.Peer, dnsDomain string) string {
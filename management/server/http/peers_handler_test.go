@@ -7,11 +7,13 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	nbdns "github.com/FlintyLemming/netbird/dns"
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 
@@ -21,10 +23,13 @@ import (
 
 	"github.com/FlintyLemming/netbird/management/server"
 	"github.com/FlintyLemming/netbird/management/server/mock_server"
+	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
 )
 
 const testPeerID = "test_peer"
 const noUpdateChannelTestPeerID = "no-update-channel"
+const testBulkJobID = "test_bulk_job"
 
 func initTestMetaData(peers ...*nbpeer.Peer) *PeersHandler {
 	return &PeersHandler{
@@ -55,6 +60,61 @@ func initTestMetaData(peers ...*nbpeer.Peer) *PeersHandler {
 			GetPeersFunc: func(accountID, userID string) ([]*nbpeer.Peer, error) {
 				return peers, nil
 			},
+			GetPeerLatenciesFunc: func(accountID, userID string) ([]*server.PeerLatency, error) {
+				return []*server.PeerLatency{
+					{
+						SourcePeerID:      peers[0].ID,
+						DestinationPeerID: peers[1].ID,
+						RTT:               12500 * time.Microsecond,
+						Loss:              0.2,
+						MeasuredAt:        time.Date(2023, 5, 5, 9, 0, 0, 0, time.UTC),
+					},
+				}, nil
+			},
+			GetPeerNetworkMapFunc: func(accountID, peerID, userID string) (*server.NetworkMap, error) {
+				return &server.NetworkMap{
+					Peers:   []*nbpeer.Peer{peers[1]},
+					Network: &server.Network{Serial: 51},
+					FirewallRules: []*server.FirewallRule{
+						{PeerIP: peers[1].IP.String(), Direction: 1, Action: "accept", Protocol: "tcp", Port: "22"},
+					},
+					Routes: []*route.Route{
+						{ID: "route1", NetID: "office", Network: netip.MustParsePrefix("10.0.0.0/24"), Peer: peers[1].Key, Metric: 9999},
+					},
+					DNSConfig: nbdns.Config{ServiceEnable: true},
+				}, nil
+			},
+			StartBulkPeerOperationFunc: func(accountID, userID string, operation server.BulkPeerOperation) (*server.BulkPeerJob, error) {
+				if operation.Action == server.BulkPeerActionMoveToGroup && operation.GroupID != "group1" {
+					return nil, status.Errorf(status.NotFound, "group with ID %s not found", operation.GroupID)
+				}
+				return &server.BulkPeerJob{
+					ID:        testBulkJobID,
+					AccountID: accountID,
+					UserID:    userID,
+					Action:    operation.Action,
+					Status:    server.BulkPeerJobStatusPending,
+					Total:     len(operation.PeerIDs),
+					CreatedAt: time.Date(2023, 5, 5, 9, 0, 0, 0, time.UTC),
+				}, nil
+			},
+			GetBulkPeerJobFunc: func(accountID, userID, jobID string) (*server.BulkPeerJob, error) {
+				if jobID != testBulkJobID {
+					return nil, status.Errorf(status.NotFound, "bulk peer job %s not found", jobID)
+				}
+				return &server.BulkPeerJob{
+					ID:         testBulkJobID,
+					AccountID:  accountID,
+					UserID:     userID,
+					Action:     server.BulkPeerActionDelete,
+					Status:     server.BulkPeerJobStatusCompleted,
+					Total:      2,
+					Processed:  2,
+					Errors:     []server.BulkPeerError{{PeerID: "unknown", Message: "peer not found"}},
+					CreatedAt:  time.Date(2023, 5, 5, 9, 0, 0, 0, time.UTC),
+					FinishedAt: time.Date(2023, 5, 5, 9, 0, 1, 0, time.UTC),
+				}, nil
+			},
 			GetAccountFromTokenFunc: func(claims jwtclaims.AuthorizationClaims) (*server.Account, *server.User, error) {
 				user := server.NewAdminUser("test_user")
 				return &server.Account{
@@ -245,3 +305,256 @@ func TestGetPeers(t *testing.T) {
 		})
 	}
 }
+
+// Tests the GetPeerLatencies endpoint reachable in the route /api/peers/latency
+func TestGetPeerLatencies(t *testing.T) {
+	peer := &nbpeer.Peer{ID: testPeerID, IP: net.ParseIP("100.64.0.1"), Status: &nbpeer.PeerStatus{}}
+	peer1 := &nbpeer.Peer{ID: noUpdateChannelTestPeerID, IP: net.ParseIP("100.64.0.2"), Status: &nbpeer.PeerStatus{}}
+
+	p := initTestMetaData(peer, peer1)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/peers/latency", nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/peers/latency", p.GetPeerLatencies).Methods("GET")
+	router.HandleFunc("/api/peers/{peerId}", p.HandlePeer).Methods("GET")
+	router.ServeHTTP(recorder, req)
+
+	res := recorder.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("I don't know what I expected; %v", err)
+	}
+
+	var got []*api.PeerLatency
+	err = json.Unmarshal(content, &got)
+	if err != nil {
+		t.Fatalf("Sent content is not in correct json format; %v", err)
+	}
+
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0].SourcePeerId, testPeerID)
+	assert.Equal(t, got[0].DestinationPeerId, noUpdateChannelTestPeerID)
+	assert.Equal(t, got[0].RttMs, 12.5)
+	assert.Equal(t, got[0].Loss, 0.2)
+}
+
+// Tests the StartBulkOperation endpoint reachable in the route /api/peers/bulk
+func TestStartBulkPeerOperation(t *testing.T) {
+	tt := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+		expectedTotal  int
+	}{
+		{
+			name:           "Delete Peers",
+			requestBody:    `{"action":"delete","peers":["test_peer","no-update-channel"]}`,
+			expectedStatus: http.StatusOK,
+			expectedTotal:  2,
+		},
+		{
+			name:           "Move Peers To Group",
+			requestBody:    `{"action":"move_to_group","peers":["test_peer"],"group_id":"group1"}`,
+			expectedStatus: http.StatusOK,
+			expectedTotal:  1,
+		},
+		{
+			name:           "Move Peers Without Group",
+			requestBody:    `{"action":"move_to_group","peers":["test_peer"]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Move Peers To Unknown Group",
+			requestBody:    `{"action":"move_to_group","peers":["test_peer"],"group_id":"unknown"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Set SSH Without State",
+			requestBody:    `{"action":"set_ssh_enabled","peers":["test_peer"]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Invalid JSON",
+			requestBody:    `{"action":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	peer := &nbpeer.Peer{ID: testPeerID, IP: net.ParseIP("100.64.0.1"), Status: &nbpeer.PeerStatus{}}
+	peer1 := &nbpeer.Peer{ID: noUpdateChannelTestPeerID, IP: net.ParseIP("100.64.0.2"), Status: &nbpeer.PeerStatus{}}
+
+	p := initTestMetaData(peer, peer1)
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/peers/bulk", bytes.NewBufferString(tc.requestBody))
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/peers/bulk", p.StartBulkOperation).Methods("POST")
+			router.HandleFunc("/api/peers/{peerId}", p.HandlePeer).Methods("GET", "PUT", "DELETE")
+			router.ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, res.StatusCode, tc.expectedStatus)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			content, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("I don't know what I expected; %v", err)
+			}
+
+			var got api.PeerBulkJob
+			err = json.Unmarshal(content, &got)
+			if err != nil {
+				t.Fatalf("Sent content is not in correct json format; %v", err)
+			}
+
+			assert.Equal(t, got.Id, testBulkJobID)
+			assert.Equal(t, got.Status, api.PeerBulkJobStatusPending)
+			assert.Equal(t, got.Total, tc.expectedTotal)
+			assert.Equal(t, got.Processed, 0)
+			assert.Equal(t, got.FinishedAt == nil, true)
+		})
+	}
+}
+
+// Tests the GetBulkOperation endpoint reachable in the route /api/peers/bulk/{jobId}
+func TestGetBulkPeerOperation(t *testing.T) {
+	peer := &nbpeer.Peer{ID: testPeerID, IP: net.ParseIP("100.64.0.1"), Status: &nbpeer.PeerStatus{}}
+	peer1 := &nbpeer.Peer{ID: noUpdateChannelTestPeerID, IP: net.ParseIP("100.64.0.2"), Status: &nbpeer.PeerStatus{}}
+
+	p := initTestMetaData(peer, peer1)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/peers/bulk/{jobId}", p.GetBulkOperation).Methods("GET")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/peers/bulk/unknown", nil))
+	assert.Equal(t, recorder.Result().StatusCode, http.StatusNotFound)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/peers/bulk/"+testBulkJobID, nil))
+
+	res := recorder.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("I don't know what I expected; %v", err)
+	}
+
+	var got api.PeerBulkJob
+	err = json.Unmarshal(content, &got)
+	if err != nil {
+		t.Fatalf("Sent content is not in correct json format; %v", err)
+	}
+
+	assert.Equal(t, got.Action, "delete")
+	assert.Equal(t, got.Status, api.PeerBulkJobStatusCompleted)
+	assert.Equal(t, got.Processed, 2)
+	assert.Equal(t, len(got.Errors), 1)
+	assert.Equal(t, got.Errors[0].PeerId, "unknown")
+	assert.Equal(t, got.FinishedAt != nil, true)
+}
+
+func TestGetPeerNetworkMap(t *testing.T) {
+	peer := &nbpeer.Peer{ID: testPeerID, IP: net.ParseIP("100.64.0.1"), Status: &nbpeer.PeerStatus{}}
+	peer1 := &nbpeer.Peer{ID: noUpdateChannelTestPeerID, Key: "peer1-key", IP: net.ParseIP("100.64.0.2"), Status: &nbpeer.PeerStatus{}}
+
+	p := initTestMetaData(peer, peer1)
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/peers/"+testPeerID+"/network-map", nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/peers/{peerId}", p.HandlePeer).Methods("GET")
+	router.HandleFunc("/api/peers/{peerId}/network-map", p.GetPeerNetworkMap).Methods("GET")
+	router.ServeHTTP(recorder, req)
+
+	res := recorder.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("I don't know what I expected; %v", err)
+	}
+
+	var got api.PeerNetworkMap
+	err = json.Unmarshal(content, &got)
+	if err != nil {
+		t.Fatalf("Sent content is not in correct json format; %v", err)
+	}
+
+	assert.Equal(t, got.Serial, 51)
+	assert.Equal(t, len(got.Peers), 1)
+	assert.Equal(t, got.Peers[0].Id, noUpdateChannelTestPeerID)
+	assert.Equal(t, len(got.OfflinePeers), 0)
+
+	assert.Equal(t, len(got.FirewallRules), 1)
+	assert.Equal(t, got.FirewallRules[0].Direction, api.NetworkMapFirewallRuleDirectionOut)
+	assert.Equal(t, got.FirewallRules[0].Protocol, api.NetworkMapFirewallRuleProtocolTcp)
+	assert.Equal(t, got.FirewallRules[0].Port, "22")
+
+	assert.Equal(t, len(got.Routes), 1)
+	assert.Equal(t, got.Routes[0].Network, "10.0.0.0/24")
+	assert.Equal(t, got.Routes[0].Peer, noUpdateChannelTestPeerID, "the routing peer should be referenced by ID")
+
+	assert.Equal(t, got.Dns.Enabled, true)
+}
+
+// Tests that the peers list reports the Signal presence only for the peers it is known for
+func TestGetPeersSignalPresence(t *testing.T) {
+	peer := &nbpeer.Peer{ID: testPeerID, IP: net.ParseIP("100.64.0.1"), Status: &nbpeer.PeerStatus{}}
+	peer1 := &nbpeer.Peer{ID: noUpdateChannelTestPeerID, IP: net.ParseIP("100.64.0.2"), Status: &nbpeer.PeerStatus{}}
+
+	p := initTestMetaData(peer, peer1)
+	p.accountManager.(*mock_server.MockAccountManager).GetPeersSignalPresenceFunc = func(accountID string) map[string]bool {
+		return map[string]bool{testPeerID: true}
+	}
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/peers", nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/peers", p.GetAllPeers).Methods("GET")
+	router.ServeHTTP(recorder, req)
+
+	res := recorder.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("I don't know what I expected; %v", err)
+	}
+
+	var got []*api.PeerBatch
+	if err = json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("Sent content is not in correct json format; %v", err)
+	}
+
+	assert.Equal(t, len(got), 2)
+	for _, peerResp := range got {
+		if peerResp.Id == testPeerID {
+			assert.Equal(t, *peerResp.SignalConnected, true)
+			continue
+		}
+		assert.Equal(t, peerResp.SignalConnected, (*bool)(nil))
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	"github.com/FlintyLemming/netbird/management/server/http/util"
 	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 	"github.com/FlintyLemming/netbird/management/server/status"
 )
 
@@ -76,22 +77,28 @@ func (h *Policies) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policyIdx := -1
-	for i, policy := range account.Policies {
+	var existing *server.Policy
+	for _, policy := range account.Policies {
 		if policy.ID == policyID {
-			policyIdx = i
+			existing = policy
 			break
 		}
 	}
-	if policyIdx < 0 {
+	if existing == nil {
 		util.WriteError(status.Errorf(status.NotFound, "couldn't find policy id %s", policyID), w)
 		return
 	}
 
-	h.savePolicy(w, r, account, user, policyID)
+	var req api.PutApiPoliciesPolicyIdJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+
+	h.savePolicy(w, account, user, existing, req)
 }
 
-// CreatePolicy handles policy creation request
+// CreatePolicy handles policy creation request. A request with the external ID of an existing policy updates it
 func (h *Policies) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
 	account, user, err := h.accountManager.GetAccountFromToken(claims)
@@ -100,23 +107,86 @@ func (h *Policies) CreatePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.savePolicy(w, r, account, user, "")
+	var req api.PostApiPoliciesJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+
+	var existing *server.Policy
+	if req.ExternalId != nil {
+		existing = account.FindPolicyByExternalID(*req.ExternalId)
+	}
+
+	h.savePolicy(w, account, user, existing, req)
 }
 
-// savePolicy handles policy creation and update
-func (h *Policies) savePolicy(
-	w http.ResponseWriter,
-	r *http.Request,
-	account *server.Account,
-	user *server.User,
-	policyID string,
-) {
-	var req api.PutApiPoliciesPolicyIdJSONRequestBody
+// UpsertPolicy creates the policy with the external ID of the path or updates it when it exists
+func (h *Policies) UpsertPolicy(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	externalID := mux.Vars(r)["externalId"]
+	if len(externalID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "external ID can't be empty"), w)
+		return
+	}
+
+	var req api.PutApiPoliciesExternalExternalIdJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+	req.ExternalId = &externalID
+
+	h.savePolicy(w, account, user, account.FindPolicyByExternalID(externalID), req)
+}
+
+// SimulatePolicy evaluates the traffic between two peers against the firewall rules sent to them
+func (h *Policies) SimulatePolicy(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	var req api.PostApiPoliciesSimulateJSONRequestBody
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
 		return
 	}
 
+	query := server.PolicySimulationQuery{
+		SourcePeerID:      req.SourcePeerId,
+		DestinationPeerID: req.DestinationPeerId,
+		Protocol:          server.PolicyRuleProtocolType(req.Protocol),
+	}
+	if req.Port != nil {
+		query.Port = *req.Port
+	}
+
+	simulation, err := h.accountManager.SimulatePolicy(account.Id, user.Id, query)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toPolicySimulationResponse(simulation))
+}
+
+// savePolicy handles policy creation when existing is nil and the update of existing otherwise
+func (h *Policies) savePolicy(
+	w http.ResponseWriter,
+	account *server.Account,
+	user *server.User,
+	existing *server.Policy,
+	req api.PolicyUpdate,
+) {
 	if req.Name == "" {
 		util.WriteError(status.Errorf(status.InvalidArgument, "policy name shouldn't be empty"), w)
 		return
@@ -127,8 +197,15 @@ func (h *Policies) savePolicy(
 		return
 	}
 
-	if policyID == "" {
-		policyID = xid.New().String()
+	policyID := xid.New().String()
+	var externalID string
+	if existing != nil {
+		policyID = existing.ID
+		externalID = existing.ExternalID
+	}
+	// keep the current external ID when the request doesn't set it
+	if req.ExternalId != nil {
+		externalID = *req.ExternalId
 	}
 
 	policy := server.Policy{
@@ -136,6 +213,7 @@ func (h *Policies) savePolicy(
 		Name:        req.Name,
 		Enabled:     req.Enabled,
 		Description: req.Description,
+		ExternalID:  externalID,
 	}
 	for _, r := range req.Rules {
 		pr := server.PolicyRule{
@@ -150,6 +228,18 @@ func (h *Policies) savePolicy(
 		if r.Description != nil {
 			pr.Description = *r.Description
 		}
+		if r.Log != nil {
+			pr.Log = *r.Log
+		}
+		if r.SourcePeerTypes != nil {
+			pr.SourcePeerTypes = toPeerTypes(*r.SourcePeerTypes)
+		}
+		if r.DestinationPeerTypes != nil {
+			pr.DestinationPeerTypes = toPeerTypes(*r.DestinationPeerTypes)
+		}
+		if r.Services != nil && len(*r.Services) != 0 {
+			pr.Services = *r.Services
+		}
 
 		switch r.Action {
 		case api.PolicyRuleUpdateActionAccept:
@@ -170,6 +260,10 @@ func (h *Policies) savePolicy(
 			pr.Protocol = server.PolicyRuleProtocolUDP
 		case api.PolicyRuleUpdateProtocolIcmp:
 			pr.Protocol = server.PolicyRuleProtocolICMP
+		case api.PolicyRuleUpdateProtocolSctp:
+			pr.Protocol = server.PolicyRuleProtocolSCTP
+		case api.PolicyRuleUpdateProtocolGre:
+			pr.Protocol = server.PolicyRuleProtocolGRE
 		default:
 			util.WriteError(status.Errorf(status.InvalidArgument, "unknown protocol type: %v", r.Protocol), w)
 			return
@@ -185,18 +279,19 @@ func (h *Policies) savePolicy(
 			}
 		}
 
-		// validate policy object
-		switch pr.Protocol {
-		case server.PolicyRuleProtocolALL, server.PolicyRuleProtocolICMP:
+		// validate policy object, the flow of the rules using services is validated with the services protocols
+		switch {
+		case len(pr.Services) != 0:
+		case pr.Protocol == server.PolicyRuleProtocolALL, pr.Protocol == server.PolicyRuleProtocolICMP, pr.Protocol == server.PolicyRuleProtocolGRE:
 			if len(pr.Ports) != 0 {
-				util.WriteError(status.Errorf(status.InvalidArgument, "for ALL or ICMP protocol ports is not allowed"), w)
+				util.WriteError(status.Errorf(status.InvalidArgument, "for ALL, ICMP or GRE protocol ports is not allowed"), w)
 				return
 			}
 			if !pr.Bidirectional {
-				util.WriteError(status.Errorf(status.InvalidArgument, "for ALL or ICMP protocol type flow can be only bi-directional"), w)
+				util.WriteError(status.Errorf(status.InvalidArgument, "for ALL, ICMP or GRE protocol type flow can be only bi-directional"), w)
 				return
 			}
-		case server.PolicyRuleProtocolTCP, server.PolicyRuleProtocolUDP:
+		case pr.Protocol == server.PolicyRuleProtocolTCP, pr.Protocol == server.PolicyRuleProtocolUDP, pr.Protocol == server.PolicyRuleProtocolSCTP:
 			if !pr.Bidirectional && len(pr.Ports) == 0 {
 				util.WriteError(status.Errorf(status.InvalidArgument, "for ALL or ICMP protocol type flow can be only bi-directional"), w)
 				return
@@ -288,6 +383,7 @@ func toPolicyResponse(account *server.Account, policy *server.Policy) *api.Polic
 		Name:        policy.Name,
 		Description: policy.Description,
 		Enabled:     policy.Enabled,
+		ExternalId:  externalIDResponse(policy.ExternalID),
 	}
 	for _, r := range policy.Rules {
 		rID := r.ID
@@ -305,6 +401,22 @@ func toPolicyResponse(account *server.Account, policy *server.Policy) *api.Polic
 			portsCopy := r.Ports
 			rule.Ports = &portsCopy
 		}
+		if len(r.Services) != 0 {
+			servicesCopy := r.Services
+			rule.Services = &servicesCopy
+		}
+		if r.Log {
+			rLog := r.Log
+			rule.Log = &rLog
+		}
+		if len(r.SourcePeerTypes) != 0 {
+			sourcePeerTypes := toPeerTypesResponse(r.SourcePeerTypes)
+			rule.SourcePeerTypes = &sourcePeerTypes
+		}
+		if len(r.DestinationPeerTypes) != 0 {
+			destinationPeerTypes := toPeerTypesResponse(r.DestinationPeerTypes)
+			rule.DestinationPeerTypes = &destinationPeerTypes
+		}
 		for _, gid := range r.Sources {
 			_, ok := cache[gid]
 			if ok {
@@ -351,3 +463,44 @@ func groupMinimumsToStrings(account *server.Account, gm []string) []string {
 	}
 	return result
 }
+
+func toPolicySimulationResponse(simulation *server.PolicySimulation) *api.PolicySimulation {
+	resp := &api.PolicySimulation{
+		Allowed: simulation.Allowed,
+		Matches: make([]api.PolicySimulationMatch, 0, len(simulation.Matches)),
+	}
+	if simulation.SourceRule != nil {
+		rule := toNetworkMapFirewallRule(simulation.SourceRule)
+		resp.SourceRule = &rule
+	}
+	if simulation.DestinationRule != nil {
+		rule := toNetworkMapFirewallRule(simulation.DestinationRule)
+		resp.DestinationRule = &rule
+	}
+	for _, match := range simulation.Matches {
+		resp.Matches = append(resp.Matches, api.PolicySimulationMatch{
+			PolicyId:   match.PolicyID,
+			PolicyName: match.PolicyName,
+			RuleId:     match.RuleID,
+			RuleName:   match.RuleName,
+			Action:     api.PolicySimulationMatchAction(match.Action),
+		})
+	}
+	return resp
+}
+
+func toPeerTypes(apiTypes []api.PeerType) []nbpeer.PeerType {
+	peerTypes := make([]nbpeer.PeerType, 0, len(apiTypes))
+	for _, peerType := range apiTypes {
+		peerTypes = append(peerTypes, nbpeer.PeerType(peerType))
+	}
+	return peerTypes
+}
+
+func toPeerTypesResponse(peerTypes []nbpeer.PeerType) []api.PeerType {
+	apiTypes := make([]api.PeerType, 0, len(peerTypes))
+	for _, peerType := range peerTypes {
+		apiTypes = append(apiTypes, api.PeerType(peerType))
+	}
+	return apiTypes
+}
@@ -62,6 +62,20 @@ func initPoliciesTestData(policies ...*server.Policy) *Policies {
 					Flow:        server.TrafficFlowBidirect,
 				}, nil
 			},
+			SimulatePolicyFunc: func(_, _ string, query server.PolicySimulationQuery) (*server.PolicySimulation, error) {
+				if query.SourcePeerID != "idofsrcpeer" {
+					return nil, status.Errorf(status.NotFound, "peer not found")
+				}
+				if query.Protocol != server.PolicyRuleProtocolTCP || query.Port != 22 {
+					return nil, status.Errorf(status.InvalidArgument, "unexpected traffic")
+				}
+				return &server.PolicySimulation{
+					SourceRule: &server.FirewallRule{PeerIP: "100.64.0.2", Direction: 1, Action: "accept", Protocol: "tcp", Port: "22"},
+					Matches: []server.PolicySimulationMatch{
+						{PolicyID: "idofthepolicy", PolicyName: "Policy", RuleID: "idoftherule", RuleName: "Rule", Action: server.PolicyTrafficActionAccept},
+					},
+				}, nil
+			},
 			GetAccountFromTokenFunc: func(claims jwtclaims.AuthorizationClaims) (*server.Account, *server.User, error) {
 				user := server.NewAdminUser("test_user")
 				return &server.Account{
@@ -166,6 +180,7 @@ func TestPoliciesGetPolicy(t *testing.T) {
 
 func TestPoliciesWritePolicy(t *testing.T) {
 	str := func(s string) *string { return &s }
+	logged := true
 	tt := []struct {
 		name           string
 		expectedStatus int
@@ -208,6 +223,41 @@ func TestPoliciesWritePolicy(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:        "WritePolicy POST Log OK",
+			requestType: http.MethodPost,
+			requestPath: "/api/policies",
+			requestBody: bytes.NewBuffer(
+				[]byte(`{
+                    "Name":"Logged Policy",
+                    "Rules":[
+                        {
+                            "Name":"Logged Policy",
+                            "Description": "Description",
+                            "Protocol": "tcp",
+                            "Action": "drop",
+                            "Log": true,
+                            "Bidirectional":true
+                        }
+                ]}`)),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedPolicy: &api.Policy{
+				Id:   str("id-was-set"),
+				Name: "Logged Policy",
+				Rules: []api.PolicyRule{
+					{
+						Id:            str("id-was-set"),
+						Name:          "Logged Policy",
+						Description:   str("Description"),
+						Protocol:      "tcp",
+						Action:        "drop",
+						Log:           &logged,
+						Bidirectional: true,
+					},
+				},
+			},
+		},
 		{
 			name:        "WritePolicy POST Invalid Name",
 			requestType: http.MethodPost,
@@ -313,3 +363,73 @@ func TestPoliciesWritePolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestPoliciesSimulatePolicy(t *testing.T) {
+	tt := []struct {
+		name           string
+		expectedStatus int
+		expectedBody   bool
+		requestBody    io.Reader
+	}{
+		{
+			name:           "SimulatePolicy OK",
+			expectedBody:   true,
+			requestBody:    bytes.NewBufferString(`{"source_peer_id":"idofsrcpeer","destination_peer_id":"idofdstpeer","protocol":"tcp","port":22}`),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "SimulatePolicy unknown peer",
+			requestBody:    bytes.NewBufferString(`{"source_peer_id":"notexists","destination_peer_id":"idofdstpeer","protocol":"tcp","port":22}`),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "SimulatePolicy invalid body",
+			requestBody:    bytes.NewBufferString(`{"source_peer_id":`),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	p := initPoliciesTestData()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/policies/simulate", tc.requestBody)
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/policies/simulate", p.SimulatePolicy).Methods("POST")
+			router.ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+			defer res.Body.Close()
+
+			if status := recorder.Code; status != tc.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v",
+					status, tc.expectedStatus)
+				return
+			}
+
+			if !tc.expectedBody {
+				return
+			}
+
+			content, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("I don't know what I expected; %v", err)
+			}
+
+			var got api.PolicySimulation
+			if err = json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("Sent content is not in correct json format; %v", err)
+			}
+
+			assert.Equal(t, got.Allowed, false)
+			assert.Equal(t, got.DestinationRule == nil, true)
+			assert.Equal(t, got.SourceRule.Direction, api.NetworkMapFirewallRuleDirectionOut)
+			assert.Equal(t, got.SourceRule.Port, "22")
+			assert.Equal(t, len(got.Matches), 1)
+			assert.Equal(t, got.Matches[0].PolicyId, "idofthepolicy")
+			assert.Equal(t, got.Matches[0].Action, api.PolicySimulationMatchActionAccept)
+		})
+	}
+}
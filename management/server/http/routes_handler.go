@@ -1,8 +1,14 @@
 package http
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/gorilla/mux"
@@ -54,7 +60,7 @@ func (h *RoutesHandler) GetAllRoutes(w http.ResponseWriter, r *http.Request) {
 	util.WriteJSONObject(w, apiRoutes)
 }
 
-// CreateRoute handles route creation request
+// CreateRoute handles route creation request. A request with the external ID of an existing route updates it
 func (h *RoutesHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
 	account, user, err := h.accountManager.GetAccountFromToken(claims)
@@ -70,6 +76,48 @@ func (h *RoutesHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.ExternalId != nil {
+		if existing := account.FindRouteByExternalID(*req.ExternalId); existing != nil {
+			h.updateRoute(w, account, user, existing, req)
+			return
+		}
+	}
+
+	h.createRoute(w, account, user, req)
+}
+
+// UpsertRoute creates the route with the external ID of the path or updates it when it exists
+func (h *RoutesHandler) UpsertRoute(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	externalID := mux.Vars(r)["externalId"]
+	if len(externalID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "external ID can't be empty"), w)
+		return
+	}
+
+	var req api.PutApiRoutesExternalExternalIdJSONRequestBody
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+	req.ExternalId = &externalID
+
+	if existing := account.FindRouteByExternalID(externalID); existing != nil {
+		h.updateRoute(w, account, user, existing, req)
+		return
+	}
+
+	h.createRoute(w, account, user, req)
+}
+
+func (h *RoutesHandler) createRoute(w http.ResponseWriter, account *server.Account, user *server.User, req api.RouteRequest) {
 	_, newPrefix, err := route.ParseNetwork(req.Network)
 	if err != nil {
 		util.WriteError(err, w)
@@ -92,6 +140,16 @@ func (h *RoutesHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
 		peerGroupIds = *req.PeerGroups
 	}
 
+	disablePreemption := false
+	if req.DisablePreemption != nil {
+		disablePreemption = *req.DisablePreemption
+	}
+
+	loadBalance := false
+	if req.LoadBalance != nil {
+		loadBalance = *req.LoadBalance
+	}
+
 	if (peerId != "" && len(peerGroupIds) > 0) || (peerId == "" && len(peerGroupIds) == 0) {
 		util.WriteError(status.Errorf(status.InvalidArgument, "only one peer or peer_groups should be provided"), w)
 		return
@@ -105,9 +163,15 @@ func (h *RoutesHandler) CreateRoute(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	externalID := ""
+	if req.ExternalId != nil {
+		externalID = *req.ExternalId
+	}
+
 	newRoute, err := h.accountManager.CreateRoute(
 		account.Id, newPrefix.String(), peerId, peerGroupIds,
-		req.Description, req.NetworkId, req.Masquerade, req.Metric, req.Groups, req.Enabled, user.Id,
+		req.Description, req.NetworkId, req.Masquerade, req.Metric, disablePreemption, loadBalance, req.Groups, req.Enabled, user.Id,
+		externalID,
 	)
 	if err != nil {
 		util.WriteError(err, w)
@@ -135,7 +199,7 @@ func (h *RoutesHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.accountManager.GetRoute(account.Id, routeID, user.Id)
+	existing, err := h.accountManager.GetRoute(account.Id, routeID, user.Id)
 	if err != nil {
 		util.WriteError(err, w)
 		return
@@ -148,6 +212,11 @@ func (h *RoutesHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.updateRoute(w, account, user, existing, req)
+}
+
+func (h *RoutesHandler) updateRoute(w http.ResponseWriter, account *server.Account, user *server.User, existing *route.Route, req api.RouteRequest) {
+	routeID := existing.ID
 	prefixType, newPrefix, err := route.ParseNetwork(req.Network)
 	if err != nil {
 		util.WriteError(status.Errorf(status.InvalidArgument, "couldn't parse update prefix %s for route ID %s",
@@ -194,6 +263,17 @@ func (h *RoutesHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		Enabled:     req.Enabled,
 		Groups:      req.Groups,
+		ExternalID:  existing.ExternalID,
+	}
+
+	if req.ExternalId != nil {
+		newRoute.ExternalID = *req.ExternalId
+	}
+	if req.DisablePreemption != nil {
+		newRoute.DisablePreemption = *req.DisablePreemption
+	}
+	if req.LoadBalance != nil {
+		newRoute.LoadBalance = *req.LoadBalance
 	}
 
 	if req.Peer != nil {
@@ -215,6 +295,60 @@ func (h *RoutesHandler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
 	util.WriteJSONObject(w, &resp)
 }
 
+// ImportRoutes handles the creation and update of multiple routes from a JSON or CSV request
+func (h *RoutesHandler) ImportRoutes(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	var req api.PostApiRoutesImportJSONRequestBody
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		req, err = parseRoutesCSV(r.Body)
+		if err != nil {
+			util.WriteErrorResponse(fmt.Sprintf("couldn't parse CSV request: %v", err), http.StatusBadRequest, w)
+			return
+		}
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+			return
+		}
+	}
+
+	if len(req) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "no routes provided"), w)
+		return
+	}
+
+	routes := make([]*route.Route, 0, len(req))
+	for i, item := range req {
+		newRoute, err := toImportedRoute(account, item)
+		if err != nil {
+			util.WriteError(status.Errorf(status.InvalidArgument, "route %d: %v", i, err), w)
+			return
+		}
+		routes = append(routes, newRoute)
+	}
+
+	savedRoutes, err := h.accountManager.SaveRoutes(account.Id, user.Id, routes)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	apiRoutes := make([]*api.Route, 0, len(savedRoutes))
+	for _, savedRoute := range savedRoutes {
+		apiRoutes = append(apiRoutes, toRouteResponse(savedRoute))
+	}
+
+	util.WriteJSONObject(w, apiRoutes)
+}
+
 // DeleteRoute handles route deletion request
 func (h *RoutesHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
@@ -239,6 +373,62 @@ func (h *RoutesHandler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
 	util.WriteJSONObject(w, emptyObject{})
 }
 
+// GetActiveRoutes returns the routes the peers of the account reported as chosen for their networks
+func (h *RoutesHandler) GetActiveRoutes(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	activeRoutes, err := h.accountManager.GetActiveRoutes(account.Id, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	respBody := make([]*api.ActiveRoute, 0, len(activeRoutes))
+	for _, activeRoute := range activeRoutes {
+		respBody = append(respBody, &api.ActiveRoute{
+			PeerId:        activeRoute.PeerID,
+			RouteId:       activeRoute.RouteID,
+			NetworkId:     activeRoute.NetID,
+			Network:       activeRoute.Network,
+			RoutingPeerId: activeRoute.RoutingPeerID,
+			ReportedAt:    activeRoute.ReportedAt,
+		})
+	}
+	util.WriteJSONObject(w, respBody)
+}
+
+// GetRouteSuggestions returns the LAN networks detected by the peers of the account which can be routed through them
+func (h *RoutesHandler) GetRouteSuggestions(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	suggestions, err := h.accountManager.GetRouteSuggestions(account.Id, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	respBody := make([]*api.RouteSuggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		respBody = append(respBody, &api.RouteSuggestion{
+			PeerId:    suggestion.PeerID,
+			PeerName:  suggestion.PeerName,
+			Network:   suggestion.Network.String(),
+			NetworkId: suggestion.NetID,
+		})
+	}
+	util.WriteJSONObject(w, respBody)
+}
+
 // GetRoute handles a route Get request identified by ID
 func (h *RoutesHandler) GetRoute(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
@@ -275,10 +465,181 @@ func toRouteResponse(serverRoute *route.Route) *api.Route {
 		Masquerade:  serverRoute.Masquerade,
 		Metric:      serverRoute.Metric,
 		Groups:      serverRoute.Groups,
+		ExternalId:  externalIDResponse(serverRoute.ExternalID),
 	}
 
 	if len(serverRoute.PeerGroups) > 0 {
 		route.PeerGroups = &serverRoute.PeerGroups
 	}
+	if serverRoute.DisablePreemption {
+		route.DisablePreemption = &serverRoute.DisablePreemption
+	}
+	if serverRoute.LoadBalance {
+		route.LoadBalance = &serverRoute.LoadBalance
+	}
 	return route
 }
+
+// toImportedRoute validates an imported route the same way as CreateRoute and UpdateRoute do
+func toImportedRoute(account *server.Account, req api.RouteImportRequest) (*route.Route, error) {
+	prefixType, newPrefix, err := route.ParseNetwork(req.Network)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse prefix %s", req.Network)
+	}
+
+	if utf8.RuneCountInString(req.NetworkId) > route.MaxNetIDChar || req.NetworkId == "" {
+		return nil, fmt.Errorf("identifier should be between 1 and %d", route.MaxNetIDChar)
+	}
+
+	peerID := ""
+	if req.Peer != nil {
+		peerID = *req.Peer
+	}
+
+	var peerGroupIDs []string
+	if req.PeerGroups != nil {
+		peerGroupIDs = *req.PeerGroups
+	}
+
+	if (peerID != "" && len(peerGroupIDs) > 0) || (peerID == "" && len(peerGroupIDs) == 0) {
+		return nil, fmt.Errorf("only one peer or peer_groups should be provided")
+	}
+
+	// do not allow non Linux peers
+	if peer := account.GetPeer(peerID); peer != nil {
+		if peer.Meta.GoOS != "linux" {
+			return nil, fmt.Errorf("non-linux peers are non supported as network routes")
+		}
+	}
+
+	newRoute := &route.Route{
+		Network:     newPrefix,
+		NetID:       req.NetworkId,
+		NetworkType: prefixType,
+		Masquerade:  req.Masquerade,
+		Metric:      req.Metric,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Groups:      req.Groups,
+		Peer:        peerID,
+		PeerGroups:  peerGroupIDs,
+	}
+	if req.ExternalId != nil {
+		newRoute.ExternalID = *req.ExternalId
+		// update the route holding the external ID unless the import names a route explicitly
+		if existing := account.FindRouteByExternalID(newRoute.ExternalID); existing != nil {
+			newRoute.ID = existing.ID
+		}
+	}
+	if req.Id != nil {
+		newRoute.ID = *req.Id
+	}
+	if req.DisablePreemption != nil {
+		newRoute.DisablePreemption = *req.DisablePreemption
+	}
+	if req.LoadBalance != nil {
+		newRoute.LoadBalance = *req.LoadBalance
+	}
+
+	return newRoute, nil
+}
+
+// parseRoutesCSV reads routes from CSV with a header row naming the columns.
+// Group lists are separated by ";", metric defaults to route.MaxMetric and enabled to true.
+func parseRoutesCSV(r io.Reader) ([]api.RouteImportRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "id", "network_id", "network", "description", "peer", "peer_groups", "groups", "metric", "masquerade", "enabled":
+		default:
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"network_id", "network", "groups"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var routes []api.RouteImportRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		value := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		item := api.RouteImportRequest{
+			Description: value("description"),
+			Groups:      splitCSVList(value("groups")),
+			Network:     value("network"),
+			NetworkId:   value("network_id"),
+			Metric:      route.MaxMetric,
+			Enabled:     true,
+		}
+
+		if id := value("id"); id != "" {
+			item.Id = &id
+		}
+		if peer := value("peer"); peer != "" {
+			item.Peer = &peer
+		}
+		if peerGroups := splitCSVList(value("peer_groups")); len(peerGroups) > 0 {
+			item.PeerGroups = &peerGroups
+		}
+
+		if metric := value("metric"); metric != "" {
+			item.Metric, err = strconv.Atoi(metric)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid metric %q", line, metric)
+			}
+		}
+		if masquerade := value("masquerade"); masquerade != "" {
+			item.Masquerade, err = strconv.ParseBool(masquerade)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid masquerade %q", line, masquerade)
+			}
+		}
+		if enabled := value("enabled"); enabled != "" {
+			item.Enabled, err = strconv.ParseBool(enabled)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid enabled %q", line, enabled)
+			}
+		}
+
+		routes = append(routes, item)
+	}
+
+	return routes, nil
+}
+
+func splitCSVList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
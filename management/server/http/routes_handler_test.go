@@ -93,7 +93,7 @@ func initRoutesTestData() *RoutesHandler {
 				}
 				return nil, status.Errorf(status.NotFound, "route with ID %s not found", routeID)
 			},
-			CreateRouteFunc: func(accountID, network, peerID string, peerGroups []string, description, netID string, masquerade bool, metric int, groups []string, enabled bool, _ string) (*route.Route, error) {
+			CreateRouteFunc: func(accountID, network, peerID string, peerGroups []string, description, netID string, masquerade bool, metric int, disablePreemption, loadBalance bool, groups []string, enabled bool, _, externalID string) (*route.Route, error) {
 				if peerID == notFoundPeerID {
 					return nil, status.Errorf(status.InvalidArgument, "peer with ID %s not found", peerID)
 				}
@@ -112,6 +112,10 @@ func initRoutesTestData() *RoutesHandler {
 					Masquerade:  masquerade,
 					Enabled:     enabled,
 					Groups:      groups,
+
+					DisablePreemption: disablePreemption,
+					LoadBalance:       loadBalance,
+					ExternalID:        externalID,
 				}, nil
 			},
 			SaveRouteFunc: func(_, _ string, r *route.Route) error {
@@ -120,12 +124,34 @@ func initRoutesTestData() *RoutesHandler {
 				}
 				return nil
 			},
+			SaveRoutesFunc: func(_, _ string, routes []*route.Route) ([]*route.Route, error) {
+				for i, r := range routes {
+					if r.Peer == notFoundPeerID {
+						return nil, status.Errorf(status.InvalidArgument, "route %d: peer with ID %s not found", i, r.Peer)
+					}
+					if r.ID == "" {
+						r.ID = fmt.Sprintf("importedRouteID%d", i)
+					}
+				}
+				return routes, nil
+			},
 			DeleteRouteFunc: func(_ string, routeID string, _ string) error {
 				if routeID != existingRouteID {
 					return status.Errorf(status.NotFound, "Peer with ID %s not found", routeID)
 				}
 				return nil
 			},
+			GetActiveRoutesFunc: func(_, _ string) ([]*server.ActiveRoute, error) {
+				return []*server.ActiveRoute{
+					{
+						PeerID:        "peer",
+						RouteID:       existingRouteID,
+						NetID:         baseExistingRoute.NetID,
+						Network:       baseExistingRoute.Network.String(),
+						RoutingPeerID: existingPeerID,
+					},
+				}, nil
+			},
 			GetAccountFromTokenFunc: func(_ jwtclaims.AuthorizationClaims) (*server.Account, *server.User, error) {
 				return testingAccount, testingAccount.Users["test_user"], nil
 			},
@@ -145,6 +171,8 @@ func initRoutesTestData() *RoutesHandler {
 func TestRoutesHandlers(t *testing.T) {
 	baseExistingRouteWithPeerGroups := baseExistingRoute.Copy()
 	baseExistingRouteWithPeerGroups.PeerGroups = []string{existingGroupID}
+	preemptionDisabled := true
+	loadBalanced := true
 
 	tt := []struct {
 		name           string
@@ -210,6 +238,45 @@ func TestRoutesHandlers(t *testing.T) {
 				Groups:      []string{existingGroupID},
 			},
 		},
+		{
+			name:        "POST OK With Preemption Disabled",
+			requestType: http.MethodPost,
+			requestPath: "/api/routes",
+			requestBody: bytes.NewBuffer(
+				[]byte(fmt.Sprintf("{\"Description\":\"Post\",\"Network\":\"192.168.0.0/16\",\"network_id\":\"awesomeNet\",\"Peer\":\"%s\",\"disable_preemption\":true,\"groups\":[\"%s\"]}", existingPeerID, existingGroupID))),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedRoute: &api.Route{
+				Id:                existingRouteID,
+				Description:       "Post",
+				NetworkId:         "awesomeNet",
+				Network:           "192.168.0.0/16",
+				Peer:              &existingPeerID,
+				NetworkType:       route.IPv4NetworkString,
+				Groups:            []string{existingGroupID},
+				DisablePreemption: &preemptionDisabled,
+			},
+		},
+		{
+			name:        "POST OK With Load Balance",
+			requestType: http.MethodPost,
+			requestPath: "/api/routes",
+			requestBody: bytes.NewBuffer(
+				[]byte(fmt.Sprintf("{\"Description\":\"Post\",\"Network\":\"0.0.0.0/0\",\"network_id\":\"exitNodes\",\"peer_groups\":[\"%s\"],\"load_balance\":true,\"groups\":[\"%s\"]}", existingGroupID, existingGroupID))),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedRoute: &api.Route{
+				Id:          existingRouteID,
+				Description: "Post",
+				NetworkId:   "exitNodes",
+				Network:     "0.0.0.0/0",
+				Peer:        &emptyString,
+				PeerGroups:  &[]string{existingGroupID},
+				NetworkType: route.IPv4NetworkString,
+				Groups:      []string{existingGroupID},
+				LoadBalance: &loadBalanced,
+			},
+		},
 		{
 			name:           "POST Non Linux Peer",
 			requestType:    http.MethodPost,
@@ -399,3 +466,171 @@ func TestRoutesHandlers(t *testing.T) {
 		})
 	}
 }
+
+func TestGetActiveRoutes(t *testing.T) {
+	p := initRoutesTestData()
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/routes/active", nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/routes/active", p.GetActiveRoutes).Methods("GET")
+	router.HandleFunc("/api/routes/{routeId}", p.GetRoute).Methods("GET")
+	router.ServeHTTP(recorder, req)
+
+	res := recorder.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("I don't know what I expected; %v", err)
+	}
+
+	var got []*api.ActiveRoute
+	err = json.Unmarshal(content, &got)
+	if err != nil {
+		t.Fatalf("Sent content is not in correct json format; %v", err)
+	}
+
+	assert.Equal(t, len(got), 1)
+	assert.Equal(t, got[0].PeerId, "peer")
+	assert.Equal(t, got[0].RouteId, existingRouteID)
+	assert.Equal(t, got[0].NetworkId, baseExistingRoute.NetID)
+	assert.Equal(t, got[0].RoutingPeerId, existingPeerID)
+}
+
+func TestImportRoutes(t *testing.T) {
+	importedRouteID := "importedRouteID0"
+
+	tt := []struct {
+		name           string
+		expectedStatus int
+		expectedRoutes []*api.Route
+		contentType    string
+		requestBody    string
+	}{
+		{
+			name:           "JSON OK",
+			contentType:    "application/json",
+			requestBody:    fmt.Sprintf("[{\"network\":\"10.1.0.0/16\",\"network_id\":\"site1\",\"peer\":\"%s\",\"groups\":[\"%s\"],\"metric\":100},{\"id\":\"%s\",\"network\":\"10.2.0.0/16\",\"network_id\":\"site2\",\"peer_groups\":[\"%s\"],\"groups\":[\"%s\"],\"metric\":9999}]", existingPeerID, existingGroupID, existingRouteID, existingGroupID, existingGroupID),
+			expectedStatus: http.StatusOK,
+			expectedRoutes: []*api.Route{
+				{
+					Id:          importedRouteID,
+					NetworkId:   "site1",
+					Network:     "10.1.0.0/16",
+					Peer:        &existingPeerID,
+					NetworkType: route.IPv4NetworkString,
+					Metric:      100,
+					Groups:      []string{existingGroupID},
+				},
+				{
+					Id:          existingRouteID,
+					NetworkId:   "site2",
+					Network:     "10.2.0.0/16",
+					Peer:        &emptyString,
+					PeerGroups:  &[]string{existingGroupID},
+					NetworkType: route.IPv4NetworkString,
+					Metric:      9999,
+					Groups:      []string{existingGroupID},
+				},
+			},
+		},
+		{
+			name:           "CSV OK",
+			contentType:    "text/csv; charset=utf-8",
+			requestBody:    fmt.Sprintf("network_id,network,description,peer,groups,masquerade\nsite1,10.1.0.0/16,Site 1,%s,%s;%s,true\n", existingPeerID, existingGroupID, existingGroupID),
+			expectedStatus: http.StatusOK,
+			expectedRoutes: []*api.Route{
+				{
+					Id:          importedRouteID,
+					Description: "Site 1",
+					NetworkId:   "site1",
+					Network:     "10.1.0.0/16",
+					Peer:        &existingPeerID,
+					NetworkType: route.IPv4NetworkString,
+					Masquerade:  true,
+					Enabled:     true,
+					Metric:      route.MaxMetric,
+					Groups:      []string{existingGroupID, existingGroupID},
+				},
+			},
+		},
+		{
+			name:           "CSV Unknown Column",
+			contentType:    "text/csv",
+			requestBody:    fmt.Sprintf("network_id,network,peer,groups,via\nsite1,10.1.0.0/16,%s,%s,x\n", existingPeerID, existingGroupID),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "CSV Invalid Metric",
+			contentType:    "text/csv",
+			requestBody:    fmt.Sprintf("network_id,network,peer,groups,metric\nsite1,10.1.0.0/16,%s,%s,high\n", existingPeerID, existingGroupID),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Empty List",
+			contentType:    "application/json",
+			requestBody:    "[]",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Invalid Network",
+			contentType:    "application/json",
+			requestBody:    fmt.Sprintf("[{\"network\":\"10.1.0.0/34\",\"network_id\":\"site1\",\"peer\":\"%s\",\"groups\":[\"%s\"]}]", existingPeerID, existingGroupID),
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Non Linux Peer",
+			contentType:    "application/json",
+			requestBody:    fmt.Sprintf("[{\"network\":\"10.1.0.0/16\",\"network_id\":\"site1\",\"peer\":\"%s\",\"groups\":[\"%s\"]}]", nonLinuxExistingPeerID, existingGroupID),
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Not Found Peer",
+			contentType:    "application/json",
+			requestBody:    fmt.Sprintf("[{\"network\":\"10.1.0.0/16\",\"network_id\":\"site1\",\"peer\":\"%s\",\"groups\":[\"%s\"]}]", notFoundPeerID, existingGroupID),
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	p := initRoutesTestData()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/routes/import", bytes.NewBufferString(tc.requestBody))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/routes/import", p.ImportRoutes).Methods("POST")
+			router.ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+			defer res.Body.Close()
+
+			content, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("I don't know what I expected; %v", err)
+			}
+
+			if status := recorder.Code; status != tc.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v, content: %s",
+					status, tc.expectedStatus, string(content))
+				return
+			}
+
+			if tc.expectedRoutes == nil {
+				return
+			}
+
+			var got []*api.Route
+			if err = json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("Sent content is not in correct json format; %v", err)
+			}
+			assert.Equal(t, got, tc.expectedRoutes)
+		})
+	}
+}
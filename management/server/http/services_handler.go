@@ -0,0 +1,190 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/xid"
+
+	"github.com/FlintyLemming/netbird/management/server"
+	"github.com/FlintyLemming/netbird/management/server/http/api"
+	"github.com/FlintyLemming/netbird/management/server/http/util"
+	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// Services is a handler that returns the services of the account
+type Services struct {
+	accountManager  server.AccountManager
+	claimsExtractor *jwtclaims.ClaimsExtractor
+}
+
+// NewServicesHandler creates a new Services handler
+func NewServicesHandler(accountManager server.AccountManager, authCfg AuthCfg) *Services {
+	return &Services{
+		accountManager: accountManager,
+		claimsExtractor: jwtclaims.NewClaimsExtractor(
+			jwtclaims.WithAudience(authCfg.Audience),
+			jwtclaims.WithUserIDClaim(authCfg.UserIDClaim),
+		),
+	}
+}
+
+// GetAllServices list for the account
+func (h *Services) GetAllServices(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	accountServices, err := h.accountManager.ListServices(account.Id, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	services := make([]*api.Service, 0, len(accountServices))
+	for _, service := range accountServices {
+		services = append(services, toServiceResponse(service))
+	}
+
+	util.WriteJSONObject(w, services)
+}
+
+// CreateService handles service creation request
+func (h *Services) CreateService(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	var req api.PostApiServicesJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+
+	h.saveService(w, account, user, xid.New().String(), req)
+}
+
+// UpdateService handles update to a service identified by a given ID
+func (h *Services) UpdateService(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	serviceID := mux.Vars(r)["serviceId"]
+	if len(serviceID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid service ID"), w)
+		return
+	}
+	if _, ok := account.Services[serviceID]; !ok {
+		util.WriteError(status.Errorf(status.NotFound, "couldn't find service id %s", serviceID), w)
+		return
+	}
+
+	var req api.PutApiServicesServiceIdJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+
+	h.saveService(w, account, user, serviceID, req)
+}
+
+// saveService saves the service of the request with the given ID
+func (h *Services) saveService(w http.ResponseWriter, account *server.Account, user *server.User, serviceID string, req api.ServiceRequest) {
+	service := &server.Service{
+		ID:          serviceID,
+		Name:        req.Name,
+		Description: req.Description,
+		Protocols:   make([]server.ServiceProtocol, 0, len(req.Protocols)),
+	}
+	for _, p := range req.Protocols {
+		protocol := server.ServiceProtocol{Protocol: server.PolicyRuleProtocolType(p.Protocol)}
+		if p.Ports != nil && len(*p.Ports) != 0 {
+			protocol.Ports = *p.Ports
+		}
+		service.Protocols = append(service.Protocols, protocol)
+	}
+
+	if err := h.accountManager.SaveService(account.Id, user.Id, service); err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toServiceResponse(service))
+}
+
+// DeleteService handles service deletion request
+func (h *Services) DeleteService(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	serviceID := mux.Vars(r)["serviceId"]
+	if len(serviceID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid service ID"), w)
+		return
+	}
+
+	if err = h.accountManager.DeleteService(account.Id, serviceID, user.Id); err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, emptyObject{})
+}
+
+// GetService handles a service Get request identified by ID
+func (h *Services) GetService(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	serviceID := mux.Vars(r)["serviceId"]
+	if len(serviceID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "invalid service ID"), w)
+		return
+	}
+
+	service, err := h.accountManager.GetService(account.Id, serviceID, user.Id)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	util.WriteJSONObject(w, toServiceResponse(service))
+}
+
+func toServiceResponse(service *server.Service) *api.Service {
+	resp := &api.Service{
+		Id:          service.ID,
+		Name:        service.Name,
+		Description: service.Description,
+		Protocols:   make([]api.ServiceProtocol, 0, len(service.Protocols)),
+	}
+	for _, p := range service.Protocols {
+		protocol := api.ServiceProtocol{Protocol: api.ServiceProtocolProtocol(p.Protocol)}
+		if len(p.Ports) != 0 {
+			ports := p.Ports
+			protocol.Ports = &ports
+		}
+		resp.Protocols = append(resp.Protocols, protocol)
+	}
+	return resp
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/management/server"
+	"github.com/FlintyLemming/netbird/management/server/http/api"
+	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	"github.com/FlintyLemming/netbird/management/server/mock_server"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	existingServiceID = "existingServiceID"
+	notFoundServiceID = "notFoundServiceID"
+)
+
+var baseExistingService = &server.Service{
+	ID:          existingServiceID,
+	Name:        "web",
+	Description: "web servers",
+	Protocols: []server.ServiceProtocol{
+		{Protocol: server.PolicyRuleProtocolTCP, Ports: []string{"80", "443"}},
+		{Protocol: server.PolicyRuleProtocolICMP},
+	},
+}
+
+func initServicesTestData() *Services {
+	testingServicesAccount := &server.Account{
+		Id:     "test_id",
+		Domain: "hotmail.com",
+		Users: map[string]*server.User{
+			"test_user": server.NewAdminUser("test_user"),
+		},
+		Services: map[string]*server.Service{
+			existingServiceID: baseExistingService,
+		},
+	}
+
+	return &Services{
+		accountManager: &mock_server.MockAccountManager{
+			GetServiceFunc: func(_, serviceID, _ string) (*server.Service, error) {
+				if serviceID == existingServiceID {
+					return baseExistingService.Copy(), nil
+				}
+				return nil, status.Errorf(status.NotFound, "service with ID %s not found", serviceID)
+			},
+			SaveServiceFunc: func(_, _ string, service *server.Service) error {
+				if len(service.Protocols) == 0 {
+					return status.Errorf(status.InvalidArgument, "service %s has no protocol", service.Name)
+				}
+				return nil
+			},
+			DeleteServiceFunc: func(_, _, _ string) error {
+				return nil
+			},
+			ListServicesFunc: func(_, _ string) ([]*server.Service, error) {
+				return []*server.Service{baseExistingService.Copy()}, nil
+			},
+			GetAccountFromTokenFunc: func(_ jwtclaims.AuthorizationClaims) (*server.Account, *server.User, error) {
+				return testingServicesAccount, testingServicesAccount.Users["test_user"], nil
+			},
+		},
+		claimsExtractor: jwtclaims.NewClaimsExtractor(
+			jwtclaims.WithFromRequestContext(func(r *http.Request) jwtclaims.AuthorizationClaims {
+				return jwtclaims.AuthorizationClaims{
+					UserId:    "test_user",
+					Domain:    "hotmail.com",
+					AccountId: "test_id",
+				}
+			}),
+		),
+	}
+}
+
+func TestServicesHandlers(t *testing.T) {
+	ports := []string{"8080"}
+
+	tt := []struct {
+		name            string
+		expectedStatus  int
+		expectedBody    bool
+		expectedService *api.Service
+		requestType     string
+		requestPath     string
+		requestBody     io.Reader
+	}{
+		{
+			name:            "Get Existing Service",
+			requestType:     http.MethodGet,
+			requestPath:     "/api/services/" + existingServiceID,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    true,
+			expectedService: toServiceResponse(baseExistingService),
+		},
+		{
+			name:           "Get Not Existing Service",
+			requestType:    http.MethodGet,
+			requestPath:    "/api/services/" + notFoundServiceID,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:        "POST OK",
+			requestType: http.MethodPost,
+			requestPath: "/api/services",
+			requestBody: bytes.NewBufferString(
+				`{"name":"proxy","description":"","protocols":[{"protocol":"tcp","ports":["8080"]}]}`),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedService: &api.Service{
+				Name:      "proxy",
+				Protocols: []api.ServiceProtocol{{Protocol: api.ServiceProtocolProtocolTcp, Ports: &ports}},
+			},
+		},
+		{
+			name:           "POST Without Protocols",
+			requestType:    http.MethodPost,
+			requestPath:    "/api/services",
+			requestBody:    bytes.NewBufferString(`{"name":"proxy","description":"","protocols":[]}`),
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "PUT OK",
+			requestType: http.MethodPut,
+			requestPath: "/api/services/" + existingServiceID,
+			requestBody: bytes.NewBufferString(
+				`{"name":"web","description":"proxy","protocols":[{"protocol":"tcp","ports":["8080"]}]}`),
+			expectedStatus: http.StatusOK,
+			expectedBody:   true,
+			expectedService: &api.Service{
+				Id:          existingServiceID,
+				Name:        "web",
+				Description: "proxy",
+				Protocols:   []api.ServiceProtocol{{Protocol: api.ServiceProtocolProtocolTcp, Ports: &ports}},
+			},
+		},
+		{
+			name:        "PUT Not Existing Service",
+			requestType: http.MethodPut,
+			requestPath: "/api/services/" + notFoundServiceID,
+			requestBody: bytes.NewBufferString(
+				`{"name":"web","description":"","protocols":[{"protocol":"tcp","ports":["8080"]}]}`),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "DELETE OK",
+			requestType:    http.MethodDelete,
+			requestPath:    "/api/services/" + existingServiceID,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	p := initServicesTestData()
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.requestType, tc.requestPath, tc.requestBody)
+
+			router := mux.NewRouter()
+			router.HandleFunc("/api/services", p.CreateService).Methods("POST")
+			router.HandleFunc("/api/services/{serviceId}", p.GetService).Methods("GET")
+			router.HandleFunc("/api/services/{serviceId}", p.UpdateService).Methods("PUT")
+			router.HandleFunc("/api/services/{serviceId}", p.DeleteService).Methods("DELETE")
+			router.ServeHTTP(recorder, req)
+
+			res := recorder.Result()
+			defer res.Body.Close()
+
+			content, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("I don't know what I expected; %v", err)
+			}
+
+			if status := recorder.Code; status != tc.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v, content: %s",
+					status, tc.expectedStatus, string(content))
+				return
+			}
+
+			if !tc.expectedBody {
+				return
+			}
+
+			got := &api.Service{}
+			if err = json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("Sent content is not in correct json format; %v", err)
+			}
+
+			if tc.requestType == http.MethodPost {
+				assert.NotEmpty(t, got.Id, "the created service should get an ID")
+				got.Id = ""
+			}
+			assert.Equal(t, tc.expectedService, got)
+		})
+	}
+}
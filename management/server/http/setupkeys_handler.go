@@ -11,6 +11,7 @@ import (
 	"github.com/FlintyLemming/netbird/management/server/http/api"
 	"github.com/FlintyLemming/netbird/management/server/http/util"
 	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 	"github.com/FlintyLemming/netbird/management/server/status"
 )
 
@@ -31,7 +32,8 @@ func NewSetupKeysHandler(accountManager server.AccountManager, authCfg AuthCfg)
 	}
 }
 
-// CreateSetupKey is a POST requests that creates a new SetupKey
+// CreateSetupKey is a POST requests that creates a new SetupKey.
+// A request with the external ID of an existing key updates it.
 func (h *SetupKeysHandler) CreateSetupKey(w http.ResponseWriter, r *http.Request) {
 	claims := h.claimsExtractor.FromRequestContext(r)
 	account, user, err := h.accountManager.GetAccountFromToken(claims)
@@ -47,6 +49,49 @@ func (h *SetupKeysHandler) CreateSetupKey(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if req.ExternalId != nil {
+		if existing := account.FindSetupKeyByExternalID(*req.ExternalId); existing != nil {
+			h.updateSetupKey(w, account, user, existing, req)
+			return
+		}
+	}
+
+	h.createSetupKey(w, account, user, req)
+}
+
+// UpsertSetupKey is a PUT request that creates the SetupKey with the external ID of the path
+// or updates it when it exists
+func (h *SetupKeysHandler) UpsertSetupKey(w http.ResponseWriter, r *http.Request) {
+	claims := h.claimsExtractor.FromRequestContext(r)
+	account, user, err := h.accountManager.GetAccountFromToken(claims)
+	if err != nil {
+		util.WriteError(err, w)
+		return
+	}
+
+	externalID := mux.Vars(r)["externalId"]
+	if len(externalID) == 0 {
+		util.WriteError(status.Errorf(status.InvalidArgument, "external ID can't be empty"), w)
+		return
+	}
+
+	req := &api.PutApiSetupKeysExternalExternalIdJSONRequestBody{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		util.WriteErrorResponse("couldn't parse JSON request", http.StatusBadRequest, w)
+		return
+	}
+	req.ExternalId = &externalID
+
+	if existing := account.FindSetupKeyByExternalID(externalID); existing != nil {
+		h.updateSetupKey(w, account, user, existing, req)
+		return
+	}
+
+	h.createSetupKey(w, account, user, req)
+}
+
+func (h *SetupKeysHandler) createSetupKey(w http.ResponseWriter, account *server.Account, user *server.User, req *api.SetupKeyRequest) {
 	if req.Name == "" {
 		util.WriteError(status.Errorf(status.InvalidArgument, "setup key name shouldn't be empty"), w)
 		return
@@ -75,8 +120,16 @@ func (h *SetupKeysHandler) CreateSetupKey(w http.ResponseWriter, r *http.Request
 	if req.Ephemeral != nil {
 		ephemeral = *req.Ephemeral
 	}
+	var externalID string
+	if req.ExternalId != nil {
+		externalID = *req.ExternalId
+	}
+	var peerType nbpeer.PeerType
+	if req.PeerType != nil {
+		peerType = nbpeer.PeerType(*req.PeerType)
+	}
 	setupKey, err := h.accountManager.CreateSetupKey(account.Id, req.Name, server.SetupKeyType(req.Type), expiresIn,
-		req.AutoGroups, req.UsageLimit, user.Id, ephemeral)
+		req.AutoGroups, req.UsageLimit, user.Id, ephemeral, externalID, peerType)
 	if err != nil {
 		util.WriteError(err, w)
 		return
@@ -133,6 +186,18 @@ func (h *SetupKeysHandler) UpdateSetupKey(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	existing := &server.SetupKey{Id: keyID}
+	for _, key := range account.SetupKeys {
+		if key.Id == keyID {
+			existing = key
+			break
+		}
+	}
+
+	h.updateSetupKey(w, account, user, existing, req)
+}
+
+func (h *SetupKeysHandler) updateSetupKey(w http.ResponseWriter, account *server.Account, user *server.User, existing *server.SetupKey, req *api.SetupKeyRequest) {
 	if req.Name == "" {
 		util.WriteError(status.Errorf(status.InvalidArgument, "setup key name field is invalid: %s", req.Name), w)
 		return
@@ -147,9 +212,19 @@ func (h *SetupKeysHandler) UpdateSetupKey(w http.ResponseWriter, r *http.Request
 	newKey.AutoGroups = req.AutoGroups
 	newKey.Revoked = req.Revoked
 	newKey.Name = req.Name
-	newKey.Id = keyID
+	newKey.Id = existing.Id
+	// keep the current external ID when the request doesn't set it
+	newKey.ExternalID = existing.ExternalID
+	if req.ExternalId != nil {
+		newKey.ExternalID = *req.ExternalId
+	}
+	// keep the current peer type when the request doesn't set it
+	newKey.PeerType = existing.PeerType
+	if req.PeerType != nil {
+		newKey.PeerType = nbpeer.PeerType(*req.PeerType)
+	}
 
-	newKey, err = h.accountManager.SaveSetupKey(account.Id, newKey, user.Id)
+	newKey, err := h.accountManager.SaveSetupKey(account.Id, newKey, user.Id)
 	if err != nil {
 		util.WriteError(err, w)
 		return
@@ -218,5 +293,7 @@ func toResponseBody(key *server.SetupKey) *api.SetupKey {
 		UpdatedAt:  key.UpdatedAt,
 		UsageLimit: key.UsageLimit,
 		Ephemeral:  key.Ephemeral,
+		ExternalId: externalIDResponse(key.ExternalID),
+		PeerType:   api.PeerType(key.GetPeerType()),
 	}
 }
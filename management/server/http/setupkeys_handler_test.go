@@ -20,6 +20,7 @@ import (
 
 	"github.com/FlintyLemming/netbird/management/server"
 	"github.com/FlintyLemming/netbird/management/server/mock_server"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 )
 
 const (
@@ -51,7 +52,7 @@ func initSetupKeysTestMetaData(defaultKey *server.SetupKey, newKey *server.Setup
 				}, user, nil
 			},
 			CreateSetupKeyFunc: func(_ string, keyName string, typ server.SetupKeyType, _ time.Duration, _ []string,
-				_ int, _ string, ephemeral bool,
+				_ int, _ string, ephemeral bool, _ string, _ nbpeer.PeerType,
 			) (*server.SetupKey, error) {
 				if keyName == newKey.Name || typ != newKey.Type {
 					nk := newKey.Copy()
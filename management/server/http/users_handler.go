@@ -276,7 +276,7 @@ func toUserResponse(user *server.UserInfo, currenUserID string) *api.User {
 	}
 
 	isCurrent := user.ID == currenUserID
-	return &api.User{
+	response := &api.User{
 		Id:            user.ID,
 		Name:          user.Name,
 		Email:         user.Email,
@@ -289,4 +289,8 @@ func toUserResponse(user *server.UserInfo, currenUserID string) *api.User {
 		LastLogin:     &user.LastLogin,
 		Issued:        &user.Issued,
 	}
+	if user.IdentityProvider != "" {
+		response.IdentityProvider = &user.IdentityProvider
+	}
+	return response
 }
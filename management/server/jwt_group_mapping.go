@@ -0,0 +1,144 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// maxJWTGroupMappingRules is the maximum number of group mapping rules of an account
+const maxJWTGroupMappingRules = 100
+
+// JWTGroupMappingRule maps the values of a JWT claim matching a regular expression to groups. The rules are evaluated
+// on every login and token refresh, the JWT groups of the user are replaced with the groups they map to
+type JWTGroupMappingRule struct {
+	// Claim is the name of the claim holding a string or an array of strings, JWTGroupsClaimName when empty
+	Claim string
+	// Match is a regular expression matched against each value of the claim, it is anchored to the whole value
+	Match string
+	// Groups are the names of the groups a matching value maps to, they may reference the submatches of Match,
+	// e.g. "team-${1}"
+	Groups []string
+	// Priority orders the rules, the lowest first. The rules of the same priority are evaluated in their order
+	Priority int
+	// Final stops the evaluation of the following rules when the rule matches a value
+	Final bool
+}
+
+// compiledJWTGroupMappingRule is a rule with its regular expression compiled
+type compiledJWTGroupMappingRule struct {
+	JWTGroupMappingRule
+	match *regexp.Regexp
+}
+
+// validateJWTGroupMappingRules checks that the rules can be evaluated
+func validateJWTGroupMappingRules(rules []JWTGroupMappingRule) error {
+	if len(rules) > maxJWTGroupMappingRules {
+		return status.Errorf(status.InvalidArgument, "too many JWT group mapping rules, the maximum is %d", maxJWTGroupMappingRules)
+	}
+	_, err := compileJWTGroupMappingRules(rules)
+	return err
+}
+
+// compileJWTGroupMappingRules compiles the regular expressions of the rules and sorts them by priority
+func compileJWTGroupMappingRules(rules []JWTGroupMappingRule) ([]compiledJWTGroupMappingRule, error) {
+	compiled := make([]compiledJWTGroupMappingRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.Groups) == 0 {
+			return nil, status.Errorf(status.InvalidArgument, "JWT group mapping rule %d has no group", i)
+		}
+		match, err := regexp.Compile("^(?:" + rule.Match + ")$")
+		if err != nil {
+			return nil, status.Errorf(status.InvalidArgument, "JWT group mapping rule %d has an invalid expression: %v", i, err)
+		}
+		compiled = append(compiled, compiledJWTGroupMappingRule{JWTGroupMappingRule: rule, match: match})
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		return compiled[i].Priority < compiled[j].Priority
+	})
+	return compiled, nil
+}
+
+// jwtGroupNames returns the names of the JWT groups of the user, i.e. the values of the groups claim or, when the
+// account has group mapping rules, the groups they map the claims to. It returns false when the groups claim is
+// missing and no rule matched, the JWT groups of the user are kept then
+func jwtGroupNames(settings *Settings, claims jwtclaims.AuthorizationClaims) ([]string, bool) {
+	if len(settings.JWTGroupMappingRules) == 0 {
+		return claimValues(claims, settings.JWTGroupsClaimName)
+	}
+
+	rules, err := compileJWTGroupMappingRules(settings.JWTGroupMappingRules)
+	if err != nil {
+		// the rules are validated when they are saved
+		log.Errorf("failed evaluating the JWT group mapping rules: %v", err)
+		return nil, false
+	}
+
+	var names []string
+	seen := make(map[string]struct{})
+	found := false
+	for _, rule := range rules {
+		claimName := rule.Claim
+		if claimName == "" {
+			claimName = settings.JWTGroupsClaimName
+		}
+		values, ok := claimValues(claims, claimName)
+		if !ok {
+			continue
+		}
+		found = true
+
+		matched := false
+		for _, value := range values {
+			submatches := rule.match.FindStringSubmatchIndex(value)
+			if submatches == nil {
+				continue
+			}
+			matched = true
+			for _, group := range rule.Groups {
+				name := string(rule.match.ExpandString(nil, group, value, submatches))
+				if _, ok := seen[name]; ok || name == "" {
+					continue
+				}
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+		if matched && rule.Final {
+			break
+		}
+	}
+	return names, found
+}
+
+// claimValues returns the values of a claim holding a string or an array of strings
+func claimValues(claims jwtclaims.AuthorizationClaims, name string) ([]string, bool) {
+	claim, ok := claims.Raw[name]
+	if !ok {
+		log.Debugf("JWT claim %q not found", name)
+		return nil, false
+	}
+
+	switch value := claim.(type) {
+	case string:
+		return []string{value}, true
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			} else {
+				log.Errorf("JWT claim %q is not a string: %v", name, item)
+			}
+		}
+		return values, true
+	default:
+		log.Debugf("JWT claim %q is not a string array: %T", name, claim)
+		return nil, false
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/management/server/jwtclaims"
+)
+
+func TestJWTGroupNames(t *testing.T) {
+	claims := jwtclaims.AuthorizationClaims{
+		Raw: jwt.MapClaims{
+			"groups":     []interface{}{"team-dev", "team-ops", "staff"},
+			"department": "finance",
+		},
+	}
+
+	names, ok := jwtGroupNames(&Settings{JWTGroupsClaimName: "groups"}, claims)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"team-dev", "team-ops", "staff"}, names, "the claim values should be used without rules")
+
+	settings := &Settings{
+		JWTGroupsClaimName: "groups",
+		JWTGroupMappingRules: []JWTGroupMappingRule{
+			{Match: "team-(.+)", Groups: []string{"${1}-members", "engineering"}, Priority: 10},
+			{Claim: "department", Match: "finance|legal", Groups: []string{"back-office"}, Priority: 5},
+			{Claim: "missing", Match: ".*", Groups: []string{"never"}},
+		},
+	}
+	names, ok = jwtGroupNames(settings, claims)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"back-office", "dev-members", "engineering", "ops-members"}, names,
+		"the rules should be evaluated by priority")
+
+	settings.JWTGroupMappingRules[1].Final = true
+	names, _ = jwtGroupNames(settings, claims)
+	assert.Equal(t, []string{"back-office"}, names, "a final rule should stop the evaluation")
+
+	settings.JWTGroupMappingRules[1].Match = "fin"
+	names, _ = jwtGroupNames(settings, claims)
+	assert.Equal(t, []string{"dev-members", "engineering", "ops-members"}, names, "the expression should match whole values")
+
+	_, ok = jwtGroupNames(settings, jwtclaims.AuthorizationClaims{Raw: jwt.MapClaims{}})
+	assert.False(t, ok, "the groups should be kept without the claims")
+}
+
+func TestValidateJWTGroupMappingRules(t *testing.T) {
+	assert.NoError(t, validateJWTGroupMappingRules(nil))
+	assert.NoError(t, validateJWTGroupMappingRules([]JWTGroupMappingRule{{Match: "team-(.+)", Groups: []string{"$1"}}}))
+	assert.Error(t, validateJWTGroupMappingRules([]JWTGroupMappingRule{{Match: "team-(", Groups: []string{"dev"}}}))
+	assert.Error(t, validateJWTGroupMappingRules([]JWTGroupMappingRule{{Match: "team"}}), "a rule should map to groups")
+}
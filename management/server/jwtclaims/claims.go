@@ -13,6 +13,8 @@ type AuthorizationClaims struct {
 	Domain         string
 	DomainCategory string
 	LastLogin      time.Time
+	// Issuer is the identity provider which issued the token
+	Issuer string
 
 	Raw jwt.MapClaims
 }
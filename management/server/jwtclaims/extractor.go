@@ -84,6 +84,9 @@ func (c *ClaimsExtractor) FromToken(token *jwt.Token) AuthorizationClaims {
 		return jwtClaims
 	}
 	jwtClaims.UserId = userID
+	if issuer, ok := claims["iss"].(string); ok {
+		jwtClaims.Issuer = issuer
+	}
 	accountIDClaim, ok := claims[c.authAudience+AccountIDSuffix]
 	if ok {
 		jwtClaims.AccountId = accountIDClaim.(string)
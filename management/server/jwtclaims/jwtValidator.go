@@ -66,6 +66,36 @@ type JSONWebKey struct {
 // JWTValidator struct to handle token validation and parsing
 type JWTValidator struct {
 	options Options
+	// issuers are the identity providers whose tokens are accepted, the first one is the primary provider
+	issuers []*jwtIssuer
+}
+
+// IssuerConfig is an additional identity provider whose tokens are accepted by the validator
+type IssuerConfig struct {
+	// Issuer identifies the provider (iss in JWT)
+	Issuer string
+	// Audiences are the accepted recipients of the tokens (aud in JWT)
+	Audiences []string
+	// KeysLocation is the location of the JWT key set of the provider
+	KeysLocation string
+	// KeyRefreshEnabled refreshes the key set when its cache expires
+	KeyRefreshEnabled bool
+	// UserIDClaim is the claim holding the user ID in the tokens of the provider
+	UserIDClaim string
+}
+
+// jwtIssuer keeps the key set of an identity provider
+type jwtIssuer struct {
+	issuer            string
+	audiences         []string
+	keysLocation      string
+	keyRefreshEnabled bool
+	// userIDClaim is copied to targetUserIDClaim when they differ, so the claims extractors find the user ID
+	userIDClaim       string
+	targetUserIDClaim string
+
+	mu   sync.Mutex
+	keys *Jwks
 }
 
 // NewJWTValidator constructor
@@ -75,64 +105,121 @@ func NewJWTValidator(issuer string, audienceList []string, keysLocation string,
 		return nil, err
 	}
 
-	var lock sync.Mutex
-	options := Options{
-		ValidationKeyGetter: func(token *jwt.Token) (interface{}, error) {
-			// Verify 'aud' claim
-			var checkAud bool
-			for _, audience := range audienceList {
-				checkAud = token.Claims.(jwt.MapClaims).VerifyAudience(audience, false)
-				if checkAud {
-					break
-				}
-			}
-			if !checkAud {
-				return token, errors.New("invalid audience")
-			}
-			// Verify 'issuer' claim
-			checkIss := token.Claims.(jwt.MapClaims).VerifyIssuer(issuer, false)
-			if !checkIss {
-				return token, errors.New("invalid issuer")
-			}
+	validator := &JWTValidator{
+		issuers: []*jwtIssuer{{
+			issuer:            issuer,
+			audiences:         audienceList,
+			keysLocation:      keysLocation,
+			keyRefreshEnabled: idpSignkeyRefreshEnabled,
+			keys:              keys,
+		}},
+	}
+	validator.options = Options{
+		ValidationKeyGetter: validator.validationKey,
+		SigningMethod:       jwt.SigningMethodRS256,
+		EnableAuthOnOptions: false,
+	}
 
-			// If keys are rotated, verify the keys prior to token validation
-			if idpSignkeyRefreshEnabled {
-				// If the keys are invalid, retrieve new ones
-				if !keys.stillValid() {
-					lock.Lock()
-					defer lock.Unlock()
+	if validator.options.UserProperty == "" {
+		validator.options.UserProperty = "user"
+	}
 
-					refreshedKeys, err := getPemKeys(keysLocation)
-					if err != nil {
-						log.Debugf("cannot get JSONWebKey: %v, falling back to old keys", err)
-						refreshedKeys = keys
-					}
+	return validator, nil
+}
 
-					log.Debugf("keys refreshed, new UTC expiration time: %s", refreshedKeys.expiresInTime.UTC())
+// AddIssuer accepts the tokens of another identity provider. The user ID of its tokens is copied to userIDClaim, the
+// claim the claims extractors read it from, when the provider stores it in another claim. It must be called before
+// the validator is used
+func (m *JWTValidator) AddIssuer(config IssuerConfig, userIDClaim string) error {
+	if config.Issuer == "" {
+		return errors.New("the issuer of an additional identity provider is required")
+	}
 
-					keys = refreshedKeys
-				}
-			}
+	keys, err := getPemKeys(config.KeysLocation)
+	if err != nil {
+		return fmt.Errorf("get the keys of issuer %s: %w", config.Issuer, err)
+	}
 
-			cert, err := getPemCert(token, keys)
-			if err != nil {
-				return nil, err
-			}
+	if userIDClaim == "" {
+		userIDClaim = UserIDClaim
+	}
 
-			result, _ := jwt.ParseRSAPublicKeyFromPEM([]byte(cert))
-			return result, nil
-		},
-		SigningMethod:       jwt.SigningMethodRS256,
-		EnableAuthOnOptions: false,
+	m.issuers = append(m.issuers, &jwtIssuer{
+		issuer:            config.Issuer,
+		audiences:         config.Audiences,
+		keysLocation:      config.KeysLocation,
+		keyRefreshEnabled: config.KeyRefreshEnabled,
+		userIDClaim:       config.UserIDClaim,
+		targetUserIDClaim: userIDClaim,
+		keys:              keys,
+	})
+	return nil
+}
+
+// validationKey returns the key verifying the token of the identity provider which issued it
+func (m *JWTValidator) validationKey(token *jwt.Token) (interface{}, error) {
+	claims := token.Claims.(jwt.MapClaims)
+
+	iss := m.issuerOf(claims)
+	if iss == nil {
+		return token, errors.New("invalid issuer")
+	}
+
+	// Verify 'aud' claim
+	var checkAud bool
+	for _, audience := range iss.audiences {
+		checkAud = claims.VerifyAudience(audience, false)
+		if checkAud {
+			break
+		}
+	}
+	if !checkAud {
+		return token, errors.New("invalid audience")
+	}
+
+	cert, err := getPemCert(token, iss.currentKeys())
+	if err != nil {
+		return nil, err
 	}
 
-	if options.UserProperty == "" {
-		options.UserProperty = "user"
+	result, _ := jwt.ParseRSAPublicKeyFromPEM([]byte(cert))
+	return result, nil
+}
+
+// issuerOf returns the identity provider which issued the token, the tokens without issuer are verified by the
+// primary provider
+func (m *JWTValidator) issuerOf(claims jwt.MapClaims) *jwtIssuer {
+	iss, _ := claims["iss"].(string)
+	if iss == "" {
+		return m.issuers[0]
+	}
+	for _, issuer := range m.issuers {
+		if claims.VerifyIssuer(issuer.issuer, true) {
+			return issuer
+		}
 	}
+	return nil
+}
 
-	return &JWTValidator{
-		options: options,
-	}, nil
+// currentKeys returns the key set of the provider, it is refreshed when the keys are rotated and the cache expired
+func (i *jwtIssuer) currentKeys() *Jwks {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// If keys are rotated, verify the keys prior to token validation
+	if i.keyRefreshEnabled && !i.keys.stillValid() {
+		// If the keys are invalid, retrieve new ones
+		refreshedKeys, err := getPemKeys(i.keysLocation)
+		if err != nil {
+			log.Debugf("cannot get JSONWebKey: %v, falling back to old keys", err)
+			refreshedKeys = i.keys
+		}
+
+		log.Debugf("keys refreshed, new UTC expiration time: %s", refreshedKeys.expiresInTime.UTC())
+
+		i.keys = refreshedKeys
+	}
+	return i.keys
 }
 
 // ValidateAndParse validates the token and returns the parsed token
@@ -176,6 +263,12 @@ func (m *JWTValidator) ValidateAndParse(token string) (*jwt.Token, error) {
 		return nil, errors.New(errorMsg)
 	}
 
+	claims := parsedToken.Claims.(jwt.MapClaims)
+	if iss := m.issuerOf(claims); iss != nil && iss.userIDClaim != "" && iss.targetUserIDClaim != "" &&
+		iss.userIDClaim != iss.targetUserIDClaim {
+		claims[iss.targetUserIDClaim] = claims[iss.userIDClaim]
+	}
+
 	return parsedToken, nil
 }
 
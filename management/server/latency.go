@@ -0,0 +1,179 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// maxLatencyMeasurements is the maximum number of measurements accepted in a single report of a peer
+	maxLatencyMeasurements = 100
+	// peerLatencyMaxAge is the age after which a measurement is no longer returned
+	peerLatencyMaxAge = time.Hour
+)
+
+// PeerLatencyMeasurement is the result of pinging a remote peer over the tunnel as reported by a peer
+type PeerLatencyMeasurement struct {
+	// PeerKey is the WireGuard public key of the remote peer
+	PeerKey string
+	// RTT is the average round trip time of the answered pings
+	RTT time.Duration
+	// Sent is the number of pings sent
+	Sent uint32
+	// Received is the number of pings answered
+	Received uint32
+}
+
+// PeerLatency is the latest latency and packet loss measured from a peer to another one
+type PeerLatency struct {
+	SourcePeerID      string
+	DestinationPeerID string
+	RTT               time.Duration
+	// Loss is the fraction of the pings not answered, between 0 and 1
+	Loss       float64
+	MeasuredAt time.Time
+}
+
+type peerLatencyKey struct {
+	source      string
+	destination string
+}
+
+// peerLatencyStore keeps the latest measurements between the peers of the accounts in memory
+type peerLatencyStore struct {
+	mu       sync.Mutex
+	accounts map[string]map[peerLatencyKey]*PeerLatency
+}
+
+func (s *peerLatencyStore) save(accountID string, latencies []*PeerLatency) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accounts == nil {
+		s.accounts = make(map[string]map[peerLatencyKey]*PeerLatency)
+	}
+	accountLatencies, ok := s.accounts[accountID]
+	if !ok {
+		accountLatencies = make(map[peerLatencyKey]*PeerLatency)
+		s.accounts[accountID] = accountLatencies
+	}
+
+	for _, latency := range latencies {
+		accountLatencies[peerLatencyKey{source: latency.SourcePeerID, destination: latency.DestinationPeerID}] = latency
+	}
+}
+
+// get returns the measurements of the account taken after the given time, removing the older ones
+func (s *peerLatencyStore) get(accountID string, after time.Time) []*PeerLatency {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latencies []*PeerLatency
+	for key, latency := range s.accounts[accountID] {
+		if latency.MeasuredAt.Before(after) {
+			delete(s.accounts[accountID], key)
+			continue
+		}
+		latencyCopy := *latency
+		latencies = append(latencies, &latencyCopy)
+	}
+	return latencies
+}
+
+func (s *peerLatencyStore) deleteAccount(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.accounts, accountID)
+}
+
+// ReportPeerLatency stores the latency measured by the peer with the given WireGuard public key to other peers of its
+// account. The reports are rejected unless the account enables them, measurements of unknown peers are ignored.
+func (am *DefaultAccountManager) ReportPeerLatency(peerPubKey string, measurements []PeerLatencyMeasurement) error {
+	if len(measurements) > maxLatencyMeasurements {
+		return status.Errorf(status.InvalidArgument, "too many measurements, the maximum is %d", maxLatencyMeasurements)
+	}
+
+	account, err := am.Store.GetAccountByPeerPubKey(peerPubKey)
+	if err != nil {
+		if errStatus, ok := status.FromError(err); ok && errStatus.Type() == status.NotFound {
+			return status.Errorf(status.Unauthenticated, "peer is not registered")
+		}
+		return err
+	}
+
+	peer, err := account.FindPeerByPubKey(peerPubKey)
+	if err != nil {
+		return status.Errorf(status.Unauthenticated, "peer is not registered")
+	}
+
+	if !account.Settings.PeerLatencyReportsEnabled {
+		return status.Errorf(status.PermissionDenied, "peer latency reports are disabled for the account")
+	}
+
+	now := time.Now().UTC()
+	latencies := make([]*PeerLatency, 0, len(measurements))
+	for _, measurement := range measurements {
+		if measurement.Sent == 0 || measurement.Received > measurement.Sent {
+			continue
+		}
+
+		remotePeer, err := account.FindPeerByPubKey(measurement.PeerKey)
+		if err != nil || remotePeer.ID == peer.ID {
+			continue
+		}
+
+		latency := &PeerLatency{
+			SourcePeerID:      peer.ID,
+			DestinationPeerID: remotePeer.ID,
+			Loss:              1 - float64(measurement.Received)/float64(measurement.Sent),
+			MeasuredAt:        now,
+		}
+		if measurement.Received > 0 {
+			latency.RTT = measurement.RTT
+		}
+		latencies = append(latencies, latency)
+	}
+
+	am.peerLatencies.save(account.Id, latencies)
+
+	return nil
+}
+
+// GetPeerLatencies returns the latest latency measured between the peers of the account within the last hour
+func (am *DefaultAccountManager) GetPeerLatencies(accountID, userID string) ([]*PeerLatency, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view peer latencies")
+	}
+
+	latencies := make([]*PeerLatency, 0)
+	for _, latency := range am.peerLatencies.get(accountID, time.Now().UTC().Add(-peerLatencyMaxAge)) {
+		// the peers might have been deleted since the measurement
+		if account.GetPeer(latency.SourcePeerID) == nil || account.GetPeer(latency.DestinationPeerID) == nil {
+			continue
+		}
+		latencies = append(latencies, latency)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		if latencies[i].SourcePeerID != latencies[j].SourcePeerID {
+			return latencies[i].SourcePeerID < latencies[j].SourcePeerID
+		}
+		return latencies[i].DestinationPeerID < latencies[j].DestinationPeerID
+	})
+
+	return latencies, nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportPeerLatency(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	measurements := []PeerLatencyMeasurement{
+		{PeerKey: peer2Key, RTT: 20 * time.Millisecond, Sent: 5, Received: 4},
+		{PeerKey: peer3Key, Sent: 5, Received: 0},
+		{PeerKey: "unknown", RTT: time.Millisecond, Sent: 5, Received: 5},
+		{PeerKey: peer1Key, RTT: time.Millisecond, Sent: 5, Received: 5},
+	}
+
+	err = am.ReportPeerLatency(peer1Key, measurements)
+	require.Error(t, err, "should fail when peer latency reports are disabled")
+
+	account.Settings.PeerLatencyReportsEnabled = true
+	err = am.Store.SaveAccount(account)
+	require.NoError(t, err, "failed to save account")
+
+	err = am.ReportPeerLatency(peer1Key, measurements)
+	require.NoError(t, err)
+
+	latencies, err := am.GetPeerLatencies(account.Id, userID)
+	require.NoError(t, err)
+	require.Len(t, latencies, 2, "unknown peers and the reporting peer should be ignored")
+
+	assert.Equal(t, peer1ID, latencies[0].SourcePeerID)
+	assert.Equal(t, peer2ID, latencies[0].DestinationPeerID)
+	assert.Equal(t, 20*time.Millisecond, latencies[0].RTT)
+	assert.InDelta(t, 0.2, latencies[0].Loss, 0.001)
+
+	assert.Equal(t, peer3ID, latencies[1].DestinationPeerID)
+	assert.Equal(t, time.Duration(0), latencies[1].RTT)
+	assert.Equal(t, 1.0, latencies[1].Loss)
+
+	err = am.ReportPeerLatency(peer1Key, []PeerLatencyMeasurement{{PeerKey: peer2Key, RTT: 30 * time.Millisecond, Sent: 5, Received: 5}})
+	require.NoError(t, err)
+
+	latencies, err = am.GetPeerLatencies(account.Id, userID)
+	require.NoError(t, err)
+	require.Len(t, latencies, 2)
+	assert.Equal(t, 30*time.Millisecond, latencies[0].RTT, "the latest measurement should replace the previous one")
+	assert.Equal(t, 0.0, latencies[0].Loss)
+
+	tooMany := make([]PeerLatencyMeasurement, maxLatencyMeasurements+1)
+	err = am.ReportPeerLatency(peer1Key, tooMany)
+	require.Error(t, err, "should fail with too many measurements")
+}
+
+func TestPeerLatencyStore_ExpiresMeasurements(t *testing.T) {
+	store := &peerLatencyStore{}
+	store.save("account", []*PeerLatency{
+		{SourcePeerID: "a", DestinationPeerID: "b", MeasuredAt: time.Now().Add(-2 * peerLatencyMaxAge)},
+		{SourcePeerID: "a", DestinationPeerID: "c", MeasuredAt: time.Now()},
+	})
+
+	latencies := store.get("account", time.Now().Add(-peerLatencyMaxAge))
+	require.Len(t, latencies, 1)
+	assert.Equal(t, "c", latencies[0].DestinationPeerID)
+	assert.Len(t, store.accounts["account"], 1, "expired measurements should be removed")
+
+	store.deleteAccount("account")
+	assert.Empty(t, store.get("account", time.Time{}))
+}
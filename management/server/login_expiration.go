@@ -0,0 +1,80 @@
+package server
+
+import (
+	"time"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// minPeerLoginExpiration is the shortest peer login expiration of the account and of the groups
+	minPeerLoginExpiration = time.Hour
+	// maxPeerLoginExpiration is the longest peer login expiration of the account and of the groups
+	maxPeerLoginExpiration = 180 * 24 * time.Hour
+)
+
+// peerLoginExpirations are the login expirations of the peers of an account
+type peerLoginExpirations struct {
+	// groups is the shortest expiration set on the groups of each peer, keyed by peer ID
+	groups map[string]time.Duration
+	// account is the expiration of the peers whose groups don't set one
+	account time.Duration
+}
+
+// get returns the login expiration of the peer, the account one when none of its groups sets one
+func (e peerLoginExpirations) get(peerID string) time.Duration {
+	if expiration, ok := e.groups[peerID]; ok {
+		return expiration
+	}
+	return e.account
+}
+
+// getPeersLoginExpiration returns the login expirations of the peers. The expiration of a peer is the shortest one set
+// on its groups, the account one when none of its groups sets it
+func (a *Account) getPeersLoginExpiration() peerLoginExpirations {
+	expirations := peerLoginExpirations{
+		groups:  make(map[string]time.Duration),
+		account: a.Settings.PeerLoginExpiration,
+	}
+	for _, group := range a.Groups {
+		if group.LoginExpiration == 0 {
+			continue
+		}
+		for _, id := range group.Peers {
+			if current, ok := expirations.groups[id]; !ok || group.LoginExpiration < current {
+				expirations.groups[id] = group.LoginExpiration
+			}
+		}
+	}
+	return expirations
+}
+
+// validateGroupLoginExpiration checks that the group login expiration is within the limits of the account one,
+// 0 keeps the account one
+func validateGroupLoginExpiration(expiration time.Duration) error {
+	if expiration == 0 {
+		return nil
+	}
+	if expiration > maxPeerLoginExpiration {
+		return status.Errorf(status.InvalidArgument, "group peer login expiration can't be larger than 180 days")
+	}
+	if expiration < minPeerLoginExpiration {
+		return status.Errorf(status.InvalidArgument, "group peer login expiration can't be smaller than one hour")
+	}
+	return nil
+}
+
+// groupLoginExpirationChanged returns true when the saved group changes the login expiration of any peer: its
+// expiration changed or it set one and its peers changed
+func groupLoginExpirationChanged(oldGroup, newGroup *Group) bool {
+	if oldGroup == nil {
+		return newGroup.LoginExpiration != 0
+	}
+	if oldGroup.LoginExpiration != newGroup.LoginExpiration {
+		return true
+	}
+	if newGroup.LoginExpiration == 0 {
+		return false
+	}
+	return len(difference(oldGroup.Peers, newGroup.Peers)) > 0 || len(difference(newGroup.Peers, oldGroup.Peers)) > 0
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestAccount_getPeersLoginExpiration(t *testing.T) {
+	account := &Account{
+		Settings: &Settings{PeerLoginExpiration: 24 * time.Hour},
+		Peers: map[string]*nbpeer.Peer{
+			"kiosk":   {ID: "kiosk"},
+			"laptop":  {ID: "laptop"},
+			"desktop": {ID: "desktop"},
+		},
+		Groups: map[string]*Group{
+			"kiosks":    {ID: "kiosks", Peers: []string{"kiosk"}, LoginExpiration: 90 * 24 * time.Hour},
+			"laptops":   {ID: "laptops", Peers: []string{"laptop"}, LoginExpiration: 8 * time.Hour},
+			"sensitive": {ID: "sensitive", Peers: []string{"laptop"}, LoginExpiration: 2 * time.Hour},
+			"all":       {ID: "all", Peers: []string{"kiosk", "laptop", "desktop"}},
+		},
+	}
+
+	expected := map[string]time.Duration{
+		"kiosk":   90 * 24 * time.Hour,
+		"laptop":  2 * time.Hour,
+		"desktop": 24 * time.Hour,
+	}
+	expirations := account.getPeersLoginExpiration()
+	for id, expiration := range expected {
+		assert.Equal(t, expiration, expirations.get(id),
+			"the shortest group expiration should apply to %s, the account one without group expiration", id)
+	}
+	assert.Equal(t, 24*time.Hour, expirations.get("unknown"), "a peer without groups should get the account expiration")
+}
+
+func TestValidateGroupLoginExpiration(t *testing.T) {
+	assert.NoError(t, validateGroupLoginExpiration(0), "0 should keep the account expiration")
+	assert.NoError(t, validateGroupLoginExpiration(time.Hour))
+	assert.NoError(t, validateGroupLoginExpiration(180*24*time.Hour))
+	assert.Error(t, validateGroupLoginExpiration(time.Minute))
+	assert.Error(t, validateGroupLoginExpiration(181*24*time.Hour))
+}
+
+func TestGroupLoginExpirationChanged(t *testing.T) {
+	group := &Group{ID: "group", Peers: []string{"peer-a"}, LoginExpiration: time.Hour}
+	assert.True(t, groupLoginExpirationChanged(nil, group))
+	assert.False(t, groupLoginExpirationChanged(group, group.Copy()))
+
+	updated := group.Copy()
+	updated.Peers = append(updated.Peers, "peer-b")
+	assert.True(t, groupLoginExpirationChanged(group, updated), "new peers should get the group expiration")
+
+	updated = group.Copy()
+	updated.LoginExpiration = 0
+	assert.True(t, groupLoginExpirationChanged(group, updated))
+
+	updated.Peers = nil
+	assert.False(t, groupLoginExpirationChanged(updated.Copy(), updated), "peers of a group without expiration don't change")
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/FlintyLemming/netbird/management/proto"
+)
+
+// AnnounceMaintenance tells the connected peers that the Management service will be unavailable for up to the window,
+// e.g. before a restart for an upgrade. The peers keep their last configuration and spread their reconnections over
+// the window instead of reconnecting all at once. It returns the number of peers the notice was sent to
+func (p *PeersUpdateManager) AnnounceMaintenance(window time.Duration, message string) int {
+	update := &UpdateMessage{Update: &proto.SyncResponse{
+		Maintenance: &proto.MaintenanceNotice{
+			Window:  durationpb.New(window),
+			Message: message,
+		},
+	}}
+
+	peers := p.GetAllConnectedPeers()
+	for peerID := range peers {
+		p.SendUpdate(peerID, update)
+	}
+	log.Infof("announced a maintenance of %s to %d connected peers", window, len(peers))
+	return len(peers)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnnounceMaintenance(t *testing.T) {
+	peersUpdater := NewPeersUpdateManager(nil)
+	channels := map[string]chan *UpdateMessage{
+		"peer-a": peersUpdater.CreateChannel("peer-a"),
+		"peer-b": peersUpdater.CreateChannel("peer-b"),
+	}
+	defer peersUpdater.CloseChannels([]string{"peer-a", "peer-b"})
+
+	if sent := peersUpdater.AnnounceMaintenance(5*time.Minute, "upgrading"); sent != 2 {
+		t.Fatalf("expected the notice to be sent to 2 peers, got %d", sent)
+	}
+
+	for peerID, channel := range channels {
+		select {
+		case update := <-channel:
+			notice := update.Update.GetMaintenance()
+			if notice == nil {
+				t.Fatalf("expected peer %s to receive a maintenance notice", peerID)
+			}
+			if notice.GetWindow().AsDuration() != 5*time.Minute || notice.GetMessage() != "upgrading" {
+				t.Errorf("unexpected maintenance notice for peer %s: %v", peerID, notice)
+			}
+			if update.Update.GetNetworkMap() != nil {
+				t.Errorf("the maintenance notice of peer %s should not carry a network map", peerID)
+			}
+		default:
+			t.Errorf("peer %s didn't receive the maintenance notice", peerID)
+		}
+	}
+}
@@ -17,7 +17,8 @@ import (
 type MockAccountManager struct {
 	GetOrCreateAccountByUserFunc func(userId, domain string) (*server.Account, error)
 	CreateSetupKeyFunc           func(accountId string, keyName string, keyType server.SetupKeyType,
-		expiresIn time.Duration, autoGroups []string, usageLimit int, userID string, ephemeral bool) (*server.SetupKey, error)
+		expiresIn time.Duration, autoGroups []string, usageLimit int, userID string, ephemeral bool, externalID string,
+		peerType nbpeer.PeerType) (*server.SetupKey, error)
 	GetSetupKeyFunc                 func(accountID, userID, keyID string) (*server.SetupKey, error)
 	GetAccountByUserOrAccountIdFunc func(userId, accountId, domain string) (*server.Account, error)
 	GetUserFunc                     func(claims jwtclaims.AuthorizationClaims) (*server.User, error)
@@ -42,17 +43,32 @@ type MockAccountManager struct {
 	SavePolicyFunc                  func(accountID, userID string, policy *server.Policy) error
 	DeletePolicyFunc                func(accountID, policyID, userID string) error
 	ListPoliciesFunc                func(accountID, userID string) ([]*server.Policy, error)
+	GetServiceFunc                  func(accountID, serviceID, userID string) (*server.Service, error)
+	SaveServiceFunc                 func(accountID, userID string, service *server.Service) error
+	DeleteServiceFunc               func(accountID, serviceID, userID string) error
+	ListServicesFunc                func(accountID, userID string) ([]*server.Service, error)
 	GetUsersFromAccountFunc         func(accountID, userID string) ([]*server.UserInfo, error)
 	GetAccountFromPATFunc           func(pat string) (*server.Account, *server.User, *server.PersonalAccessToken, error)
 	MarkPATUsedFunc                 func(pat string) error
 	UpdatePeerMetaFunc              func(peerID string, meta nbpeer.PeerSystemMeta) error
 	UpdatePeerSSHKeyFunc            func(peerID string, sshKey string) error
 	UpdatePeerFunc                  func(accountID, userID string, peer *nbpeer.Peer) (*nbpeer.Peer, error)
-	CreateRouteFunc                 func(accountID, prefix, peer string, peerGroups []string, description, netID string, masquerade bool, metric int, groups []string, enabled bool, userID string) (*route.Route, error)
+	CreateRouteFunc                 func(accountID, prefix, peer string, peerGroups []string, description, netID string, masquerade bool, metric int, disablePreemption, loadBalance bool, groups []string, enabled bool, userID, externalID string) (*route.Route, error)
 	GetRouteFunc                    func(accountID, routeID, userID string) (*route.Route, error)
 	SaveRouteFunc                   func(accountID, userID string, route *route.Route) error
+	SaveRoutesFunc                  func(accountID, userID string, routes []*route.Route) ([]*route.Route, error)
 	DeleteRouteFunc                 func(accountID, routeID, userID string) error
 	ListRoutesFunc                  func(accountID, userID string) ([]*route.Route, error)
+	AdvertiseRoutesFunc             func(peerPubKey, netID string, networks, withdrawn []string) ([]*route.Route, error)
+	ReportPeerLatencyFunc           func(peerPubKey string, measurements []server.PeerLatencyMeasurement) error
+	GetPeerLatenciesFunc            func(accountID, userID string) ([]*server.PeerLatency, error)
+	ReportPeerUsageFunc             func(peerPubKey string, days []server.PeerDailyUsage) error
+	GetPeerUsageFunc                func(accountID, userID string, days int) ([]*server.PeerDailyUsage, error)
+	EnablePeerFunc                  func(accountID, peerID, userID string) (*nbpeer.Peer, error)
+	ReportActiveRoutesFunc          func(peerPubKey string, reports []server.ActiveRouteReport) error
+	GetActiveRoutesFunc             func(accountID, userID string) ([]*server.ActiveRoute, error)
+	GetRouteSuggestionsFunc         func(accountID, userID string) ([]*server.RouteSuggestion, error)
+	GetPeersSignalPresenceFunc      func(accountID string) map[string]bool
 	SaveSetupKeyFunc                func(accountID string, key *server.SetupKey, userID string) (*server.SetupKey, error)
 	ListSetupKeysFunc               func(accountID, userID string) ([]*server.SetupKey, error)
 	SaveUserFunc                    func(accountID, userID string, user *server.User) (*server.UserInfo, error)
@@ -63,7 +79,7 @@ type MockAccountManager struct {
 	GetPATFunc                      func(accountID string, initiatorUserID string, targetUserId string, tokenID string) (*server.PersonalAccessToken, error)
 	GetAllPATsFunc                  func(accountID string, initiatorUserID string, targetUserId string) ([]*server.PersonalAccessToken, error)
 	GetNameServerGroupFunc          func(accountID, nsGroupID string) (*nbdns.NameServerGroup, error)
-	CreateNameServerGroupFunc       func(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool) (*nbdns.NameServerGroup, error)
+	CreateNameServerGroupFunc       func(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool, forwardViaRoutes bool, priority int) (*nbdns.NameServerGroup, error)
 	SaveNameServerGroupFunc         func(accountID, userID string, nsGroupToSave *nbdns.NameServerGroup) error
 	DeleteNameServerGroupFunc       func(accountID, nsGroupID, userID string) error
 	ListNameServerGroupsFunc        func(accountID string) ([]*nbdns.NameServerGroup, error)
@@ -74,9 +90,14 @@ type MockAccountManager struct {
 	GetDNSDomainFunc                func() string
 	StoreEventFunc                  func(initiatorID, targetID, accountID string, activityID activity.Activity, meta map[string]any)
 	GetEventsFunc                   func(accountID, userID string) ([]*activity.Event, error)
+	SubscribeAccountEventsFunc      func(accountID, userID string) (*server.AccountEventSubscription, error)
 	GetDNSSettingsFunc              func(accountID, userID string) (*server.DNSSettings, error)
 	SaveDNSSettingsFunc             func(accountID, userID string, dnsSettingsToSave *server.DNSSettings) error
 	GetPeerFunc                     func(accountID, peerID, userID string) (*nbpeer.Peer, error)
+	GetPeerNetworkMapFunc           func(accountID, peerID, userID string) (*server.NetworkMap, error)
+	StartBulkPeerOperationFunc      func(accountID, userID string, operation server.BulkPeerOperation) (*server.BulkPeerJob, error)
+	GetBulkPeerJobFunc              func(accountID, userID, jobID string) (*server.BulkPeerJob, error)
+	SimulatePolicyFunc              func(accountID, userID string, query server.PolicySimulationQuery) (*server.PolicySimulation, error)
 	UpdateAccountSettingsFunc       func(accountID, userID string, newSettings *server.Settings) (*server.Account, error)
 	LoginPeerFunc                   func(login server.PeerLogin) (*nbpeer.Peer, *server.NetworkMap, error)
 	SyncPeerFunc                    func(sync server.PeerSync) (*nbpeer.Peer, *server.NetworkMap, error)
@@ -125,9 +146,11 @@ func (am *MockAccountManager) CreateSetupKey(
 	usageLimit int,
 	userID string,
 	ephemeral bool,
+	externalID string,
+	peerType nbpeer.PeerType,
 ) (*server.SetupKey, error) {
 	if am.CreateSetupKeyFunc != nil {
-		return am.CreateSetupKeyFunc(accountID, keyName, keyType, expiresIn, autoGroups, usageLimit, userID, ephemeral)
+		return am.CreateSetupKeyFunc(accountID, keyName, keyType, expiresIn, autoGroups, usageLimit, userID, ephemeral, externalID, peerType)
 	}
 	return nil, status.Errorf(codes.Unimplemented, "method CreateSetupKey is not implemented")
 }
@@ -349,6 +372,38 @@ func (am *MockAccountManager) ListPolicies(accountID, userID string) ([]*server.
 	return nil, status.Errorf(codes.Unimplemented, "method ListPolicies is not implemented")
 }
 
+// GetService mock implementation of GetService from server.AccountManager interface
+func (am *MockAccountManager) GetService(accountID, serviceID, userID string) (*server.Service, error) {
+	if am.GetServiceFunc != nil {
+		return am.GetServiceFunc(accountID, serviceID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetService is not implemented")
+}
+
+// SaveService mock implementation of SaveService from server.AccountManager interface
+func (am *MockAccountManager) SaveService(accountID, userID string, service *server.Service) error {
+	if am.SaveServiceFunc != nil {
+		return am.SaveServiceFunc(accountID, userID, service)
+	}
+	return status.Errorf(codes.Unimplemented, "method SaveService is not implemented")
+}
+
+// DeleteService mock implementation of DeleteService from server.AccountManager interface
+func (am *MockAccountManager) DeleteService(accountID, serviceID, userID string) error {
+	if am.DeleteServiceFunc != nil {
+		return am.DeleteServiceFunc(accountID, serviceID, userID)
+	}
+	return status.Errorf(codes.Unimplemented, "method DeleteService is not implemented")
+}
+
+// ListServices mock implementation of ListServices from server.AccountManager interface
+func (am *MockAccountManager) ListServices(accountID, userID string) ([]*server.Service, error) {
+	if am.ListServicesFunc != nil {
+		return am.ListServicesFunc(accountID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices is not implemented")
+}
+
 // UpdatePeerMeta mock implementation of UpdatePeerMeta from server.AccountManager interface
 func (am *MockAccountManager) UpdatePeerMeta(peerID string, meta nbpeer.PeerSystemMeta) error {
 	if am.UpdatePeerMetaFunc != nil {
@@ -389,9 +444,9 @@ func (am *MockAccountManager) UpdatePeer(accountID, userID string, peer *nbpeer.
 }
 
 // CreateRoute mock implementation of CreateRoute from server.AccountManager interface
-func (am *MockAccountManager) CreateRoute(accountID, network, peerID string, peerGroups []string, description, netID string, masquerade bool, metric int, groups []string, enabled bool, userID string) (*route.Route, error) {
+func (am *MockAccountManager) CreateRoute(accountID, network, peerID string, peerGroups []string, description, netID string, masquerade bool, metric int, disablePreemption, loadBalance bool, groups []string, enabled bool, userID, externalID string) (*route.Route, error) {
 	if am.CreateRouteFunc != nil {
-		return am.CreateRouteFunc(accountID, network, peerID, peerGroups, description, netID, masquerade, metric, groups, enabled, userID)
+		return am.CreateRouteFunc(accountID, network, peerID, peerGroups, description, netID, masquerade, metric, disablePreemption, loadBalance, groups, enabled, userID, externalID)
 	}
 	return nil, status.Errorf(codes.Unimplemented, "method CreateRoute is not implemented")
 }
@@ -420,6 +475,14 @@ func (am *MockAccountManager) DeleteRoute(accountID, routeID, userID string) err
 	return status.Errorf(codes.Unimplemented, "method DeleteRoute is not implemented")
 }
 
+// SaveRoutes mock implementation of SaveRoutes from server.AccountManager interface
+func (am *MockAccountManager) SaveRoutes(accountID, userID string, routes []*route.Route) ([]*route.Route, error) {
+	if am.SaveRoutesFunc != nil {
+		return am.SaveRoutesFunc(accountID, userID, routes)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method SaveRoutes is not implemented")
+}
+
 // ListRoutes mock implementation of ListRoutes from server.AccountManager interface
 func (am *MockAccountManager) ListRoutes(accountID, userID string) ([]*route.Route, error) {
 	if am.ListRoutesFunc != nil {
@@ -428,6 +491,86 @@ func (am *MockAccountManager) ListRoutes(accountID, userID string) ([]*route.Rou
 	return nil, status.Errorf(codes.Unimplemented, "method ListRoutes is not implemented")
 }
 
+// AdvertiseRoutes mock implementation of AdvertiseRoutes from server.AccountManager interface
+func (am *MockAccountManager) AdvertiseRoutes(peerPubKey, netID string, networks, withdrawn []string) ([]*route.Route, error) {
+	if am.AdvertiseRoutesFunc != nil {
+		return am.AdvertiseRoutesFunc(peerPubKey, netID, networks, withdrawn)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method AdvertiseRoutes is not implemented")
+}
+
+// ReportPeerLatency mock implementation of ReportPeerLatency from server.AccountManager interface
+func (am *MockAccountManager) ReportPeerLatency(peerPubKey string, measurements []server.PeerLatencyMeasurement) error {
+	if am.ReportPeerLatencyFunc != nil {
+		return am.ReportPeerLatencyFunc(peerPubKey, measurements)
+	}
+	return status.Errorf(codes.Unimplemented, "method ReportPeerLatency is not implemented")
+}
+
+// GetPeerLatencies mock implementation of GetPeerLatencies from server.AccountManager interface
+func (am *MockAccountManager) GetPeerLatencies(accountID, userID string) ([]*server.PeerLatency, error) {
+	if am.GetPeerLatenciesFunc != nil {
+		return am.GetPeerLatenciesFunc(accountID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeerLatencies is not implemented")
+}
+
+// ReportPeerUsage mock implementation of ReportPeerUsage from server.AccountManager interface
+func (am *MockAccountManager) ReportPeerUsage(peerPubKey string, days []server.PeerDailyUsage) error {
+	if am.ReportPeerUsageFunc != nil {
+		return am.ReportPeerUsageFunc(peerPubKey, days)
+	}
+	return status.Errorf(codes.Unimplemented, "method ReportPeerUsage is not implemented")
+}
+
+// GetPeerUsage mock implementation of GetPeerUsage from server.AccountManager interface
+func (am *MockAccountManager) GetPeerUsage(accountID, userID string, days int) ([]*server.PeerDailyUsage, error) {
+	if am.GetPeerUsageFunc != nil {
+		return am.GetPeerUsageFunc(accountID, userID, days)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeerUsage is not implemented")
+}
+
+// EnablePeer mock implementation of EnablePeer from server.AccountManager interface
+func (am *MockAccountManager) EnablePeer(accountID, peerID, userID string) (*nbpeer.Peer, error) {
+	if am.EnablePeerFunc != nil {
+		return am.EnablePeerFunc(accountID, peerID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method EnablePeer is not implemented")
+}
+
+// ReportActiveRoutes mock implementation of ReportActiveRoutes from server.AccountManager interface
+func (am *MockAccountManager) ReportActiveRoutes(peerPubKey string, reports []server.ActiveRouteReport) error {
+	if am.ReportActiveRoutesFunc != nil {
+		return am.ReportActiveRoutesFunc(peerPubKey, reports)
+	}
+	return status.Errorf(codes.Unimplemented, "method ReportActiveRoutes is not implemented")
+}
+
+// GetActiveRoutes mock implementation of GetActiveRoutes from server.AccountManager interface
+func (am *MockAccountManager) GetActiveRoutes(accountID, userID string) ([]*server.ActiveRoute, error) {
+	if am.GetActiveRoutesFunc != nil {
+		return am.GetActiveRoutesFunc(accountID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetActiveRoutes is not implemented")
+}
+
+// GetRouteSuggestions mock implementation of GetRouteSuggestions from server.AccountManager interface
+func (am *MockAccountManager) GetRouteSuggestions(accountID, userID string) ([]*server.RouteSuggestion, error) {
+	if am.GetRouteSuggestionsFunc != nil {
+		return am.GetRouteSuggestionsFunc(accountID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetRouteSuggestions is not implemented")
+}
+
+// GetPeersSignalPresence mock implementation of GetPeersSignalPresence from server.AccountManager interface
+func (am *MockAccountManager) GetPeersSignalPresence(accountID string) map[string]bool {
+	if am.GetPeersSignalPresenceFunc != nil {
+		return am.GetPeersSignalPresenceFunc(accountID)
+	}
+	return nil
+}
+
 // SaveSetupKey mocks SaveSetupKey of the AccountManager interface
 func (am *MockAccountManager) SaveSetupKey(accountID string, key *server.SetupKey, userID string) (*server.SetupKey, error) {
 	if am.SaveSetupKeyFunc != nil {
@@ -495,9 +638,9 @@ func (am *MockAccountManager) GetNameServerGroup(accountID, nsGroupID string) (*
 }
 
 // CreateNameServerGroup mocks CreateNameServerGroup of the AccountManager interface
-func (am *MockAccountManager) CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool) (*nbdns.NameServerGroup, error) {
+func (am *MockAccountManager) CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainsEnabled bool, forwardViaRoutes bool, priority int) (*nbdns.NameServerGroup, error) {
 	if am.CreateNameServerGroupFunc != nil {
-		return am.CreateNameServerGroupFunc(accountID, name, description, nameServerList, groups, primary, domains, enabled, userID, searchDomainsEnabled)
+		return am.CreateNameServerGroupFunc(accountID, name, description, nameServerList, groups, primary, domains, enabled, userID, searchDomainsEnabled, forwardViaRoutes, priority)
 	}
 	return nil, nil
 }
@@ -575,6 +718,14 @@ func (am *MockAccountManager) GetEvents(accountID, userID string) ([]*activity.E
 	return nil, status.Errorf(codes.Unimplemented, "method GetEvents is not implemented")
 }
 
+// SubscribeAccountEvents mocks SubscribeAccountEvents of the AccountManager interface
+func (am *MockAccountManager) SubscribeAccountEvents(accountID, userID string) (*server.AccountEventSubscription, error) {
+	if am.SubscribeAccountEventsFunc != nil {
+		return am.SubscribeAccountEventsFunc(accountID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method SubscribeAccountEvents is not implemented")
+}
+
 // GetDNSSettings mocks GetDNSSettings of the AccountManager interface
 func (am *MockAccountManager) GetDNSSettings(accountID string, userID string) (*server.DNSSettings, error) {
 	if am.GetDNSSettingsFunc != nil {
@@ -599,6 +750,38 @@ func (am *MockAccountManager) GetPeer(accountID, peerID, userID string) (*nbpeer
 	return nil, status.Errorf(codes.Unimplemented, "method GetPeer is not implemented")
 }
 
+// GetPeerNetworkMap mocks GetPeerNetworkMap of the AccountManager interface
+func (am *MockAccountManager) GetPeerNetworkMap(accountID, peerID, userID string) (*server.NetworkMap, error) {
+	if am.GetPeerNetworkMapFunc != nil {
+		return am.GetPeerNetworkMapFunc(accountID, peerID, userID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetPeerNetworkMap is not implemented")
+}
+
+// StartBulkPeerOperation mocks StartBulkPeerOperation of the AccountManager interface
+func (am *MockAccountManager) StartBulkPeerOperation(accountID, userID string, operation server.BulkPeerOperation) (*server.BulkPeerJob, error) {
+	if am.StartBulkPeerOperationFunc != nil {
+		return am.StartBulkPeerOperationFunc(accountID, userID, operation)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method StartBulkPeerOperation is not implemented")
+}
+
+// GetBulkPeerJob mocks GetBulkPeerJob of the AccountManager interface
+func (am *MockAccountManager) GetBulkPeerJob(accountID, userID, jobID string) (*server.BulkPeerJob, error) {
+	if am.GetBulkPeerJobFunc != nil {
+		return am.GetBulkPeerJobFunc(accountID, userID, jobID)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method GetBulkPeerJob is not implemented")
+}
+
+// SimulatePolicy mocks SimulatePolicy of the AccountManager interface
+func (am *MockAccountManager) SimulatePolicy(accountID, userID string, query server.PolicySimulationQuery) (*server.PolicySimulation, error) {
+	if am.SimulatePolicyFunc != nil {
+		return am.SimulatePolicyFunc(accountID, userID, query)
+	}
+	return nil, status.Errorf(codes.Unimplemented, "method SimulatePolicy is not implemented")
+}
+
 // UpdateAccountSettings mocks UpdateAccountSettings of the AccountManager interface
 func (am *MockAccountManager) UpdateAccountSettings(accountID, userID string, newSettings *server.Settings) (*server.Account, error) {
 	if am.UpdateAccountSettingsFunc != nil {
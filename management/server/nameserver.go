@@ -11,6 +11,7 @@ import (
 	nbdns "github.com/FlintyLemming/netbird/dns"
 	"github.com/FlintyLemming/netbird/management/server/activity"
 	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
 )
 
 const domainPattern = `^(?i)[a-z0-9]+([\-\.]{1}[a-z0-9]+)*\.[a-z]{2,}$`
@@ -35,7 +36,7 @@ func (am *DefaultAccountManager) GetNameServerGroup(accountID, nsGroupID string)
 }
 
 // CreateNameServerGroup creates and saves a new nameserver group
-func (am *DefaultAccountManager) CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainEnabled bool) (*nbdns.NameServerGroup, error) {
+func (am *DefaultAccountManager) CreateNameServerGroup(accountID string, name, description string, nameServerList []nbdns.NameServer, groups []string, primary bool, domains []string, enabled bool, userID string, searchDomainEnabled bool, forwardViaRoutes bool, priority int) (*nbdns.NameServerGroup, error) {
 
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
@@ -55,6 +56,8 @@ func (am *DefaultAccountManager) CreateNameServerGroup(accountID string, name, d
 		Primary:              primary,
 		Domains:              domains,
 		SearchDomainsEnabled: searchDomainEnabled,
+		ForwardViaRoutes:     forwardViaRoutes,
+		Priority:             priority,
 	}
 
 	err = validateNameServerGroup(false, newNSGroup, account)
@@ -180,6 +183,10 @@ func validateNameServerGroup(existingGroup bool, nameserverGroup *nbdns.NameServ
 		return err
 	}
 
+	if nameserverGroup.Priority < 0 {
+		return status.Errorf(status.InvalidArgument, "nameserver group priority should be a positive number or 0")
+	}
+
 	err = validateNSGroupName(nameserverGroup.Name, nsGroupID, account.NameServerGroups)
 	if err != nil {
 		return err
@@ -195,6 +202,37 @@ func validateNameServerGroup(existingGroup bool, nameserverGroup *nbdns.NameServ
 		return err
 	}
 
+	if nameserverGroup.ForwardViaRoutes {
+		err = validateForwardViaRoutes(nameserverGroup, account.Routes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateForwardViaRoutes checks that the queries of the group are forwarded for its domains only and that its
+// nameservers are reachable through the routes of the account
+func validateForwardViaRoutes(nameserverGroup *nbdns.NameServerGroup, routes map[string]*route.Route) error {
+	if nameserverGroup.Primary {
+		return status.Errorf(status.InvalidArgument, "nameserver group primary status is true and forwarding via routes is enabled,"+
+			" only the queries for the domains of the group can be forwarded via routes")
+	}
+
+	for _, ns := range nameserverGroup.NameServers {
+		routed := false
+		for _, r := range routes {
+			if r.Network.Contains(ns.IP) {
+				routed = true
+				break
+			}
+		}
+		if !routed {
+			return status.Errorf(status.InvalidArgument, "nameserver %s is not part of any route network,"+
+				" it can't be reached via routes", ns.IP)
+		}
+	}
 	return nil
 }
 
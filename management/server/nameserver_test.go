@@ -9,6 +9,7 @@ import (
 	nbdns "github.com/FlintyLemming/netbird/dns"
 	"github.com/FlintyLemming/netbird/management/server/activity"
 	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/route"
 )
 
 const (
@@ -24,14 +25,16 @@ const (
 
 func TestCreateNameServerGroup(t *testing.T) {
 	type input struct {
-		name          string
-		description   string
-		enabled       bool
-		groups        []string
-		nameServers   []nbdns.NameServer
-		primary       bool
-		domains       []string
-		searchDomains bool
+		name             string
+		description      string
+		enabled          bool
+		groups           []string
+		nameServers      []nbdns.NameServer
+		primary          bool
+		domains          []string
+		searchDomains    bool
+		forwardViaRoutes bool
+		priority         int
 	}
 
 	testCases := []struct {
@@ -338,6 +341,86 @@ func TestCreateNameServerGroup(t *testing.T) {
 			errFunc:      require.Error,
 			shouldCreate: false,
 		},
+		{
+			name: "Create A NS Group Forwarding Via Routes",
+			inputArgs: input{
+				name:        "super",
+				description: "super",
+				groups:      []string{group1ID},
+				domains:     []string{validDomain},
+				nameServers: []nbdns.NameServer{
+					{
+						IP:     netip.MustParseAddr("10.10.0.53"),
+						NSType: nbdns.UDPNameServerType,
+						Port:   nbdns.DefaultDNSPort,
+					},
+				},
+				enabled:          true,
+				forwardViaRoutes: true,
+			},
+			errFunc:      require.NoError,
+			shouldCreate: true,
+			expectedNSGroup: &nbdns.NameServerGroup{
+				Name:        "super",
+				Description: "super",
+				Domains:     []string{validDomain},
+				Groups:      []string{group1ID},
+				NameServers: []nbdns.NameServer{
+					{
+						IP:     netip.MustParseAddr("10.10.0.53"),
+						NSType: nbdns.UDPNameServerType,
+						Port:   nbdns.DefaultDNSPort,
+					},
+				},
+				Enabled:          true,
+				ForwardViaRoutes: true,
+			},
+		},
+		{
+			name: "Should Not Create If Nameservers Forwarding Via Routes Are Not Routed",
+			inputArgs: input{
+				name:        "super",
+				description: "super",
+				groups:      []string{group1ID},
+				domains:     []string{validDomain},
+				nameServers: []nbdns.NameServer{
+					{
+						IP:     netip.MustParseAddr("10.10.0.53"),
+						NSType: nbdns.UDPNameServerType,
+						Port:   nbdns.DefaultDNSPort,
+					},
+					{
+						IP:     netip.MustParseAddr("1.1.1.1"),
+						NSType: nbdns.UDPNameServerType,
+						Port:   nbdns.DefaultDNSPort,
+					},
+				},
+				enabled:          true,
+				forwardViaRoutes: true,
+			},
+			errFunc:      require.Error,
+			shouldCreate: false,
+		},
+		{
+			name: "Should Not Create If Primary Forwards Via Routes",
+			inputArgs: input{
+				name:        "super",
+				description: "super",
+				groups:      []string{group1ID},
+				primary:     true,
+				nameServers: []nbdns.NameServer{
+					{
+						IP:     netip.MustParseAddr("10.10.0.53"),
+						NSType: nbdns.UDPNameServerType,
+						Port:   nbdns.DefaultDNSPort,
+					},
+				},
+				enabled:          true,
+				forwardViaRoutes: true,
+			},
+			errFunc:      require.Error,
+			shouldCreate: false,
+		},
 		{
 			name: "Should Not Create If Domain List Is Invalid",
 			inputArgs: input{
@@ -386,6 +469,8 @@ func TestCreateNameServerGroup(t *testing.T) {
 				testCase.inputArgs.enabled,
 				userID,
 				testCase.inputArgs.searchDomains,
+				testCase.inputArgs.forwardViaRoutes,
+				testCase.inputArgs.priority,
 			)
 
 			testCase.errFunc(t, err)
@@ -833,6 +918,16 @@ func initTestNSAccount(t *testing.T, am *DefaultAccountManager) (*Account, error
 	account.Groups[newGroup1.ID] = newGroup1
 	account.Groups[newGroup2.ID] = newGroup2
 
+	account.Routes["corpRoute"] = &route.Route{
+		ID:          "corpRoute",
+		Network:     netip.MustParsePrefix("10.10.0.0/16"),
+		NetID:       "corp",
+		NetworkType: route.IPv4Network,
+		Metric:      route.MaxMetric,
+		Enabled:     true,
+		Groups:      []string{group2ID},
+	}
+
 	err := am.Store.SaveAccount(account)
 	if err != nil {
 		return nil, err
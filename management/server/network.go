@@ -3,6 +3,7 @@ package server
 import (
 	"math/rand"
 	"net"
+	"net/netip"
 	"sync"
 	"time"
 
@@ -32,6 +33,21 @@ type NetworkMap struct {
 	DNSConfig     nbdns.Config
 	OfflinePeers  []*nbpeer.Peer
 	FirewallRules []*FirewallRule
+	// BandwidthLimits holds the limit in kbit/s of the traffic with the peers, keyed by peer ID
+	BandwidthLimits map[string]uint64
+	// PeerGroups holds the names of the groups of the peers and the offline peers, keyed by peer ID
+	PeerGroups map[string][]string
+	// LoginExpired indicates that the login of the peer expired and the map is limited to the peers of the
+	// Settings.PeerLoginExpiredAccessGroups
+	LoginExpired bool
+	// LatencyReportsEnabled indicates that the peer measures the latency to other peers and reports it
+	LatencyReportsEnabled bool
+	// UsageReportsEnabled indicates that the peer reports its daily traffic over the tunnel
+	UsageReportsEnabled bool
+	// DefaultDeny indicates that the peer drops all the traffic of its NetBird interface not allowed by a policy
+	DefaultDeny bool
+	// AppRouting is the app routing config of the peer, nil when its groups don't enable it
+	AppRouting *AppRouting
 }
 
 type Network struct {
@@ -86,6 +102,13 @@ func (n *Network) Copy() *Network {
 	}
 }
 
+// Prefix returns the network range in the prefix form
+func (n *Network) Prefix() netip.Prefix {
+	addr, _ := netip.AddrFromSlice(n.Net.IP.To4())
+	bits, _ := n.Net.Mask.Size()
+	return netip.PrefixFrom(addr, bits)
+}
+
 // AllocatePeerIP pics an available IP from an net.IPNet.
 // This method considers already taken IPs and reuses IPs if there are gaps in takenIps
 // E.g. if ipNet=100.30.0.0/16 and takenIps=[100.30.0.1, 100.30.0.4] then the result would be 100.30.0.2 or 100.30.0.3
@@ -110,6 +133,79 @@ func AllocatePeerIP(ipNet net.IPNet, takenIps []net.IP) (net.IP, error) {
 	return ips[intn], nil
 }
 
+// AllocatePeerIPFromPools picks an available IP of the network from the first pool which has one, the pools are
+// ranges of the network. The IP is picked from the whole network when there are no pools
+func AllocatePeerIPFromPools(ipNet net.IPNet, pools []netip.Prefix, takenIps []net.IP) (net.IP, error) {
+	if len(pools) == 0 {
+		return AllocatePeerIP(ipNet, takenIps)
+	}
+
+	takenIPMap := make(map[string]struct{})
+	takenIPMap[ipNet.IP.String()] = struct{}{}
+	for _, ip := range takenIps {
+		takenIPMap[ip.String()] = struct{}{}
+	}
+
+	ips, _ := generateIPs(&ipNet, takenIPMap)
+
+	r := rand.New(rand.NewSource(time.Now().Unix()))
+	for _, pool := range pools {
+		var available []net.IP
+		for _, ip := range ips {
+			addr, ok := netip.AddrFromSlice(ip.To4())
+			if ok && pool.Contains(addr) {
+				available = append(available, ip)
+			}
+		}
+		if len(available) > 0 {
+			return available[r.Intn(len(available))], nil
+		}
+	}
+
+	return nil, status.Errorf(status.PreconditionFailed, "failed allocating new IP for the ipNet %s - the IP pools are out of IPs", ipNet.String())
+}
+
+// isAssignablePeerIP returns true if the IP can be assigned to a peer of the network, the network address, the
+// broadcast address and the addresses reserved for NetBird can't be assigned
+func isAssignablePeerIP(ipNet net.IPNet, ip net.IP) bool {
+	if !ipNet.Contains(ip) {
+		return false
+	}
+	ips, _ := generateIPs(&ipNet, map[string]struct{}{ipNet.IP.String(): {}})
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateNetworkRange checks that the network range can hold the peers of an account and that the pools are
+// disjoint ranges of it
+func validateNetworkRange(networkRange netip.Prefix, pools []netip.Prefix) error {
+	if !networkRange.Addr().Is4() || networkRange.Bits() < 8 || networkRange.Bits() > 29 {
+		return status.Errorf(status.InvalidArgument, "network range %s must be an IPv4 range between /8 and /29", networkRange)
+	}
+	if networkRange != networkRange.Masked() {
+		return status.Errorf(status.InvalidArgument, "network range %s must be the address of the network, e.g. %s", networkRange, networkRange.Masked())
+	}
+
+	for i, pool := range pools {
+		if pool != pool.Masked() {
+			return status.Errorf(status.InvalidArgument, "IP pool %s must be the address of the range, e.g. %s", pool, pool.Masked())
+		}
+		if !pool.Addr().Is4() || pool.Bits() < networkRange.Bits() || !networkRange.Contains(pool.Addr()) {
+			return status.Errorf(status.InvalidArgument, "IP pool %s must be a range of the network range %s", pool, networkRange)
+		}
+		for _, other := range pools[:i] {
+			if pool.Overlaps(other) {
+				return status.Errorf(status.InvalidArgument, "IP pools %s and %s overlap", other, pool)
+			}
+		}
+	}
+	return nil
+}
+
 // generateIPs generates a list of all possible IPs of the given network excluding IPs specified in the exclusion list
 func generateIPs(ipNet *net.IPNet, exclusions map[string]struct{}) ([]net.IP, int) {
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -49,3 +50,61 @@ func TestGenerateIPs(t *testing.T) {
 		t.Errorf("expected last ip to be: 100.64.0.253, got %s", ips[len(ips)-1].String())
 	}
 }
+
+func TestAllocatePeerIPFromPools(t *testing.T) {
+	ipNet := net.IPNet{IP: net.ParseIP("100.64.0.0"), Mask: net.IPMask{255, 255, 255, 0}}
+	pools := []netip.Prefix{netip.MustParsePrefix("100.64.0.8/30"), netip.MustParsePrefix("100.64.0.16/30")}
+
+	var ips []net.IP
+	for i := 0; i < 8; i++ {
+		ip, err := AllocatePeerIPFromPools(ipNet, pools, ips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ips = append(ips, ip)
+	}
+
+	for i, ip := range ips {
+		addr, _ := netip.AddrFromSlice(ip.To4())
+		// the first pool is used up before the second one
+		assert.True(t, pools[i/4].Contains(addr), "IP %s should be allocated from pool %s", ip, pools[i/4])
+	}
+
+	_, err := AllocatePeerIPFromPools(ipNet, pools, ips)
+	assert.Error(t, err, "expecting an error when the pools are out of IPs")
+}
+
+func TestValidateNetworkRange(t *testing.T) {
+	tt := []struct {
+		name         string
+		networkRange string
+		pools        []string
+		valid        bool
+	}{
+		{name: "valid range", networkRange: "10.10.0.0/16", valid: true},
+		{name: "valid range with pools", networkRange: "10.10.0.0/16", pools: []string{"10.10.1.0/24", "10.10.2.0/24"}, valid: true},
+		{name: "range too large", networkRange: "10.0.0.0/7"},
+		{name: "range too small", networkRange: "10.10.0.0/30"},
+		{name: "IPv6 range", networkRange: "fd00::/64"},
+		{name: "unmasked range", networkRange: "10.10.0.1/16"},
+		{name: "pool outside of the range", networkRange: "10.10.0.0/16", pools: []string{"10.11.0.0/24"}},
+		{name: "pool larger than the range", networkRange: "10.10.0.0/16", pools: []string{"10.0.0.0/8"}},
+		{name: "unmasked pool", networkRange: "10.10.0.0/16", pools: []string{"10.10.1.1/24"}},
+		{name: "overlapping pools", networkRange: "10.10.0.0/16", pools: []string{"10.10.0.0/23", "10.10.1.0/24"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var pools []netip.Prefix
+			for _, pool := range tc.pools {
+				pools = append(pools, netip.MustParsePrefix(pool))
+			}
+			err := validateNetworkRange(netip.MustParsePrefix(tc.networkRange), pools)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
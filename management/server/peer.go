@@ -1,12 +1,17 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/netbirdio/management-integrations/additions"
 	"github.com/rs/xid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 
 	"github.com/FlintyLemming/netbird/management/server/activity"
 	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
@@ -15,12 +20,15 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/FlintyLemming/netbird/management/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
 // PeerSync used as a data object between the gRPC API and AccountManager on Sync request.
 type PeerSync struct {
 	// WireGuardPubKey is a peers WireGuard public key
 	WireGuardPubKey string
+	// AttestationKey is the verified base64 encoded public key of the peer's hardware-backed key. Empty if absent
+	AttestationKey string
 }
 
 // PeerLogin used as a data object between the gRPC API and AccountManager on Login request.
@@ -35,6 +43,10 @@ type PeerLogin struct {
 	UserID string
 	// SetupKey references to a server.SetupKey to log in. Can be empty when UserID is used or auth is not required.
 	SetupKey string
+	// AttestationProvider is the name of the hardware-backed key provider. Empty if no attestation was provided
+	AttestationProvider string
+	// AttestationKey is the verified base64 encoded public key of the peer's hardware-backed key. Empty if absent
+	AttestationKey string
 }
 
 // GetPeers returns a list of peers under the given account filtering out peers that do not belong to a user if
@@ -106,6 +118,7 @@ func (am *DefaultAccountManager) MarkPeerConnected(peerPubKey string, connected
 	// whenever peer got connected that means that it logged in successfully
 	if newStatus.Connected {
 		newStatus.LoginExpired = false
+		newStatus.InactivityWarned = false
 	}
 	peer.Status = newStatus
 	account.UpdatePeer(peer)
@@ -115,6 +128,12 @@ func (am *DefaultAccountManager) MarkPeerConnected(peerPubKey string, connected
 		return err
 	}
 
+	am.accountEvents.Publish(account.Id, &AccountEvent{
+		Type:      AccountEventPeerStatus,
+		Timestamp: newStatus.LastSeen,
+		Peer:      peer.Copy(),
+	})
+
 	if peer.AddedWithSSOLogin() && peer.LoginExpirationEnabled && account.Settings.PeerLoginExpirationEnabled {
 		am.checkAndSchedulePeerLoginExpiration(account)
 	}
@@ -129,6 +148,7 @@ func (am *DefaultAccountManager) MarkPeerConnected(peerPubKey string, connected
 }
 
 // UpdatePeer updates peer. Only Peer.Name, Peer.SSHEnabled, and Peer.LoginExpirationEnabled can be updated.
+// Changing Peer.Type applies the login expiration default of the new type instead of Peer.LoginExpirationEnabled.
 func (am *DefaultAccountManager) UpdatePeer(accountID, userID string, update *nbpeer.Peer) (*nbpeer.Peer, error) {
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
@@ -172,6 +192,17 @@ func (am *DefaultAccountManager) UpdatePeer(accountID, userID string, update *nb
 		am.StoreEvent(userID, peer.ID, accountID, activity.PeerRenamed, peer.EventMeta(am.GetDNSDomain()))
 	}
 
+	if update.Type != "" && update.Type != peer.GetType() {
+		if !update.Type.IsValid() {
+			return nil, status.Errorf(status.InvalidArgument, "unknown peer type %s", update.Type)
+		}
+		peer.Type = update.Type
+		// the login of the services doesn't expire, the one of the user devices does when they can log in again
+		update.LoginExpirationEnabled = peer.Type == nbpeer.PeerTypeUserDevice && peer.AddedWithSSOLogin()
+		account.Network.IncSerial()
+		am.StoreEvent(userID, peer.IP.String(), accountID, activity.PeerTypeUpdated, peer.EventMeta(am.GetDNSDomain()))
+	}
+
 	if peer.LoginExpirationEnabled != update.LoginExpirationEnabled {
 
 		if !peer.AddedWithSSOLogin() {
@@ -191,6 +222,49 @@ func (am *DefaultAccountManager) UpdatePeer(accountID, userID string, update *nb
 		}
 	}
 
+	if peer.BandwidthLimit != update.BandwidthLimit {
+		peer.BandwidthLimit = update.BandwidthLimit
+		am.StoreEvent(userID, peer.IP.String(), accountID, activity.PeerBandwidthLimitUpdated, peer.EventMeta(am.GetDNSDomain()))
+	}
+
+	if update.IP != nil && !update.IP.Equal(peer.IP) {
+		if err := account.validateStaticPeerIP(peer.ID, update.IP); err != nil {
+			return nil, err
+		}
+		peer.IP = update.IP
+		account.Network.IncSerial()
+		am.StoreEvent(userID, peer.ID, accountID, activity.PeerIPUpdated, peer.EventMeta(am.GetDNSDomain()))
+	}
+
+	if !slices.Equal(peer.DNSAliases, update.DNSAliases) {
+		aliases, err := validatePeerDNSAliases(account, peer.ID, update.DNSAliases)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Equal(peer.DNSAliases, aliases) {
+			peer.DNSAliases = aliases
+			am.StoreEvent(userID, peer.IP.String(), accountID, activity.PeerDNSAliasesUpdated, peer.EventMeta(am.GetDNSDomain()))
+		}
+	}
+
+	if !maps.Equal(peer.Labels, update.Labels) {
+		labels, err := validatePeerLabels(update.Labels)
+		if err != nil {
+			return nil, err
+		}
+		if !maps.Equal(peer.Labels, labels) {
+			peer.Labels = labels
+			am.StoreEvent(userID, peer.IP.String(), accountID, activity.PeerLabelsUpdated, peer.EventMeta(am.GetDNSDomain()))
+		}
+	}
+
+	if peer.ExternalID != update.ExternalID {
+		peer.ExternalID = update.ExternalID
+		if err := account.validatePeerExternalID(peer); err != nil {
+			return nil, err
+		}
+	}
+
 	account.UpdatePeer(peer)
 
 	err = am.Store.SaveAccount(account)
@@ -205,6 +279,11 @@ func (am *DefaultAccountManager) UpdatePeer(accountID, userID string, update *nb
 
 // deletePeers will delete all specified peers and send updates to the remote peers. Don't call without acquiring account lock
 func (am *DefaultAccountManager) deletePeers(account *Account, peerIDs []string, userID string) error {
+	return am.deletePeersWithEvent(account, peerIDs, userID, activity.PeerRemovedByUser)
+}
+
+// deletePeersWithEvent deletes the peers like deletePeers, storing the event for each of them
+func (am *DefaultAccountManager) deletePeersWithEvent(account *Account, peerIDs []string, userID string, event activity.Activity) error {
 
 	// the first loop is needed to ensure all peers present under the account before modifying, otherwise
 	// we might have some inconsistencies
@@ -238,7 +317,7 @@ func (am *DefaultAccountManager) deletePeers(account *Account, peerIDs []string,
 				},
 			})
 		am.peersUpdateManager.CloseChannel(peer.ID)
-		am.StoreEvent(userID, peer.ID, account.Id, activity.PeerRemovedByUser, peer.EventMeta(am.GetDNSDomain()))
+		am.StoreEvent(userID, peer.ID, account.Id, event, peer.EventMeta(am.GetDNSDomain()))
 	}
 
 	return nil
@@ -355,6 +434,7 @@ func (am *DefaultAccountManager) AddPeer(setupKey, userID string, peer *nbpeer.P
 
 	var ephemeral bool
 	setupKeyName := ""
+	peerType := nbpeer.PeerTypeUserDevice
 	if !addedByUser {
 		// validate the setup key if adding with a key
 		sk, err := account.FindSetupKey(upperKey)
@@ -371,6 +451,7 @@ func (am *DefaultAccountManager) AddPeer(setupKey, userID string, peer *nbpeer.P
 		opEvent.Activity = activity.PeerAddedWithSetupKey
 		ephemeral = sk.Ephemeral
 		setupKeyName = sk.Name
+		peerType = sk.GetPeerType()
 	} else {
 		opEvent.InitiatorID = userID
 		opEvent.Activity = activity.PeerAddedByUser
@@ -386,7 +467,7 @@ func (am *DefaultAccountManager) AddPeer(setupKey, userID string, peer *nbpeer.P
 
 	peer.DNSLabel = newLabel
 	network := account.Network
-	nextIp, err := AllocatePeerIP(network.Net, takenIps)
+	nextIp, err := AllocatePeerIPFromPools(network.Net, account.Settings.IPPools, takenIps)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -404,8 +485,11 @@ func (am *DefaultAccountManager) AddPeer(setupKey, userID string, peer *nbpeer.P
 		SSHEnabled:             false,
 		SSHKey:                 peer.SSHKey,
 		LastLogin:              time.Now().UTC(),
-		LoginExpirationEnabled: addedByUser,
+		LoginExpirationEnabled: addedByUser && peerType == nbpeer.PeerTypeUserDevice,
 		Ephemeral:              ephemeral,
+		AttestationProvider:    peer.AttestationProvider,
+		AttestationKey:         peer.AttestationKey,
+		Type:                   peerType,
 	}
 
 	if account.Settings.Extra != nil {
@@ -491,6 +575,16 @@ func (am *DefaultAccountManager) SyncPeer(sync PeerSync) (*nbpeer.Peer, *Network
 		return nil, nil, err
 	}
 
+	err = checkPeerAttestation(peer, sync.AttestationKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = checkIfPeerIsDisabled(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	if peerLoginExpired(peer, account) {
 		return nil, nil, status.Errorf(status.PermissionDenied, "peer login has expired, please log in once more")
 	}
@@ -507,9 +601,11 @@ func (am *DefaultAccountManager) LoginPeer(login PeerLogin) (*nbpeer.Peer, *Netw
 			// we couldn't find this peer by its public key which can mean that peer hasn't been registered yet.
 			// Try registering it.
 			return am.AddPeer(login.SetupKey, login.UserID, &nbpeer.Peer{
-				Key:    login.WireGuardPubKey,
-				Meta:   login.Meta,
-				SSHKey: login.SSHKey,
+				Key:                 login.WireGuardPubKey,
+				Meta:                login.Meta,
+				SSHKey:              login.SSHKey,
+				AttestationProvider: login.AttestationProvider,
+				AttestationKey:      login.AttestationKey,
 			})
 		}
 		log.Errorf("failed while logging in peer %s: %v", login.WireGuardPubKey, err)
@@ -536,8 +632,26 @@ func (am *DefaultAccountManager) LoginPeer(login PeerLogin) (*nbpeer.Peer, *Netw
 		return nil, nil, err
 	}
 
+	err = checkPeerAttestation(peer, login.AttestationKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = checkIfPeerIsDisabled(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// this flag prevents unnecessary calls to the persistent store.
 	shouldStoreAccount := false
+	if peer.AttestationKey == "" && login.AttestationKey != "" {
+		// peers registered before enabling attestation get bound to the key on their next login
+		log.Infof("binding peer %s to %s attestation key", peer.ID, login.AttestationProvider)
+		peer.AttestationProvider = login.AttestationProvider
+		peer.AttestationKey = login.AttestationKey
+		account.UpdatePeer(peer)
+		shouldStoreAccount = true
+	}
 	updateRemotePeers := false
 	if peerLoginExpired(peer, account) {
 		err = checkAuth(login.UserID, peer)
@@ -553,9 +667,14 @@ func (am *DefaultAccountManager) LoginPeer(login PeerLogin) (*nbpeer.Peer, *Netw
 		am.StoreEvent(login.UserID, peer.ID, account.Id, activity.UserLoggedInPeer, peer.EventMeta(am.GetDNSDomain()))
 	}
 
+	oldServices := peer.Meta.Services
 	peer, updated := updatePeerMeta(peer, login.Meta, account)
 	if updated {
 		shouldStoreAccount = true
+		// the other peers receive the advertised services in their network map and DNS records
+		if !slices.Equal(oldServices, peer.Meta.Services) {
+			updateRemotePeers = true
+		}
 	}
 
 	peer, err = am.checkAndUpdatePeerSSHKey(peer, account, login.SSHKey)
@@ -589,6 +708,26 @@ func checkIfPeerOwnerIsBlocked(peer *nbpeer.Peer, account *Account) error {
 	return nil
 }
 
+// checkIfPeerIsDisabled rejects the peers disabled for inactivity until an admin enables them
+func checkIfPeerIsDisabled(peer *nbpeer.Peer) error {
+	if peer.Status.Disabled {
+		return status.Errorf(status.PermissionDenied, "peer is disabled for inactivity, ask an admin to enable it")
+	}
+	return nil
+}
+
+// checkPeerAttestation rejects peers bound to a hardware-backed key that didn't prove possession of that key
+func checkPeerAttestation(peer *nbpeer.Peer, attestationKey string) error {
+	if peer.AttestationKey == "" {
+		return nil
+	}
+	if attestationKey != peer.AttestationKey {
+		log.Warnf("peer %s didn't provide a valid attestation of its %s key", peer.ID, peer.AttestationProvider)
+		return status.Errorf(status.PermissionDenied, "peer attestation is missing or doesn't match the registered key")
+	}
+	return nil
+}
+
 func checkAuth(loginUserID string, peer *nbpeer.Peer) error {
 	if loginUserID == "" {
 		// absence of a user ID indicates that JWT wasn't provided.
@@ -602,7 +741,7 @@ func checkAuth(loginUserID string, peer *nbpeer.Peer) error {
 }
 
 func peerLoginExpired(peer *nbpeer.Peer, account *Account) bool {
-	expired, expiresIn := peer.LoginExpired(account.Settings.PeerLoginExpiration)
+	expired, expiresIn := peer.LoginExpired(account.getPeersLoginExpiration().get(peer.ID))
 	expired = account.Settings.PeerLoginExpirationEnabled && expired
 	if expired || peer.Status.LoginExpired {
 		log.Debugf("peer's %s login expired %v ago", peer.ID, expiresIn)
@@ -730,6 +869,34 @@ func (am *DefaultAccountManager) GetPeer(accountID, peerID, userID string) (*nbp
 	return nil, status.Errorf(status.Internal, "user %s has no access to peer %s under account %s", userID, peerID, accountID)
 }
 
+// GetPeerNetworkMap returns the network map sent to the peer, i.e. the peers, firewall rules, routes and DNS
+// configuration it receives. Only users with admin power can inspect the network map of a peer.
+func (am *DefaultAccountManager) GetPeerNetworkMap(accountID, peerID, userID string) (*NetworkMap, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view the network map of a peer")
+	}
+
+	peer := account.GetPeer(peerID)
+	if peer == nil {
+		return nil, status.Errorf(status.NotFound, "peer with %s not found under account %s", peerID, accountID)
+	}
+
+	return account.GetPeerNetworkMap(peer.ID, am.dnsDomain), nil
+}
+
 func updatePeerMeta(peer *nbpeer.Peer, meta nbpeer.PeerSystemMeta, account *Account) (*nbpeer.Peer, bool) {
 	if peer.UpdateMetaIfNew(meta) {
 		account.UpdatePeer(peer)
@@ -743,9 +910,18 @@ func updatePeerMeta(peer *nbpeer.Peer, meta nbpeer.PeerSystemMeta, account *Acco
 func (am *DefaultAccountManager) updateAccountPeers(account *Account) {
 	peers := account.GetPeers()
 
+	ctx, span := tracer.Start(context.Background(), "updateAccountPeers", trace.WithAttributes(
+		attribute.String("account.id", account.Id),
+		attribute.Int("account.peers", len(peers)),
+	))
+	defer span.End()
+	// the peers continue the trace when they apply the update
+	traceContext := tracing.Inject(ctx)
+
 	for _, peer := range peers {
 		remotePeerNetworkMap := account.GetPeerNetworkMap(peer.ID, am.dnsDomain)
 		update := toSyncResponse(nil, peer, nil, remotePeerNetworkMap, am.GetDNSDomain())
+		update.TraceContext = traceContext
 		am.peersUpdateManager.SendUpdate(peer.ID, &UpdateMessage{Update: update})
 	}
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"net"
 	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 )
 
 // Peer represents a machine connected to the network.
@@ -26,6 +29,12 @@ type Peer struct {
 	// DNSLabel is the parsed peer name for domain resolution. It is used to form an FQDN by appending the account's
 	// domain to the peer label. e.g. peer-dns-label.netbird.cloud
 	DNSLabel string
+	// DNSAliases are additional labels resolving to the peer, e.g. db for db.netbird.cloud. They are also combined with
+	// the DNS suffixes of the peer groups
+	DNSAliases []string `gorm:"serializer:json"`
+	// Labels are key/value pairs describing the peer, e.g. env=prod. They are sent to the other peers and published as
+	// DNS TXT records of the peer to group and filter peers in monitoring tools
+	Labels map[string]string `gorm:"serializer:json"`
 	// Status peer's management connection status
 	Status *PeerStatus `gorm:"embedded;embeddedPrefix:peer_status_"`
 	// The user ID that registered the peer
@@ -41,6 +50,34 @@ type Peer struct {
 	LastLogin time.Time
 	// Indicate ephemeral peer attribute
 	Ephemeral bool
+	// AttestationProvider is the name of the hardware-backed key provider the peer attested with (e.g. tpm)
+	AttestationProvider string
+	// AttestationKey is the base64 encoded PKIX public key of the hardware-backed key bound to the peer.
+	// Once set, every login and sync of the peer has to be signed with this key
+	AttestationKey string
+	// BandwidthLimit is the limit in kbit/s of the traffic between the peer and the other peers. 0 means no limit
+	BandwidthLimit uint64
+	// ExternalID is an identifier set by an external tool managing the peer, e.g. a Terraform provider. It is unique
+	// among the peers of the account
+	ExternalID string `gorm:"index"`
+	// Type tells whether the peer is a device of a user or a headless service, see GetType for the peers registered
+	// before the types were introduced
+	Type PeerType
+}
+
+// PeerType tells whether the peer is a device of a user or a headless service
+type PeerType string
+
+const (
+	// PeerTypeUserDevice is a laptop or a phone of a user, its login expires by default
+	PeerTypeUserDevice PeerType = "user_device"
+	// PeerTypeService is a server or a container running unattended, its login never expires by default
+	PeerTypeService PeerType = "service"
+)
+
+// IsValid tells whether the type is a known peer type
+func (t PeerType) IsValid() bool {
+	return t == PeerTypeUserDevice || t == PeerTypeService
 }
 
 type PeerStatus struct {
@@ -52,6 +89,13 @@ type PeerStatus struct {
 	LoginExpired bool
 	// RequiresApproval indicates whether peer requires approval or not
 	RequiresApproval bool
+	// Disabled indicates that the peer was disabled because it didn't connect for longer than the inactivity period
+	// of the account. A disabled peer is removed from the network maps and can't connect until an admin enables it
+	Disabled bool
+	// InactivityWarned indicates that the inactivity warning event was stored, it is reset when the peer connects
+	InactivityWarned bool
+	// EnabledAt is the last time an admin enabled the peer, the inactivity period starts over from it
+	EnabledAt time.Time
 }
 
 // PeerSystemMeta is a metadata of a Peer machine system
@@ -64,6 +108,32 @@ type PeerSystemMeta struct {
 	OS        string
 	WtVersion string
 	UIVersion string
+	// Capabilities is a list of optional features supported by the peer
+	Capabilities []string `gorm:"serializer:json"`
+	// FirewallCapabilities are the features of the firewall of the peer, the firewall rules are optimized for them
+	FirewallCapabilities FirewallCapabilities `gorm:"serializer:json"`
+	// Services are the services advertised by the peer to the other peers
+	Services []PeerService `gorm:"serializer:json"`
+	// LANNetworks are the private networks of the LAN interfaces of the peer, e.g. 192.168.1.0/24, offered as routes
+	// through the peer. Only reported by peers with the detection enabled
+	LANNetworks []string `gorm:"serializer:json"`
+}
+
+// PeerService is a service running on the peer, published as DNS SRV records
+type PeerService struct {
+	// Label names the service in the SRV records, e.g. http for _http._tcp.<peer fqdn>
+	Label    string
+	Port     uint16
+	Protocol string
+}
+
+// FirewallCapabilities are the features of the firewall enforcing the firewall rules on the peer
+type FirewallCapabilities struct {
+	IPSet      bool
+	PortRanges bool
+	Reject     bool
+	IPv6       bool
+	SCTPAndGRE bool
 }
 
 func (p PeerSystemMeta) isEqual(other PeerSystemMeta) bool {
@@ -74,7 +144,11 @@ func (p PeerSystemMeta) isEqual(other PeerSystemMeta) bool {
 		p.Platform == other.Platform &&
 		p.OS == other.OS &&
 		p.WtVersion == other.WtVersion &&
-		p.UIVersion == other.UIVersion
+		p.UIVersion == other.UIVersion &&
+		slices.Equal(p.Capabilities, other.Capabilities) &&
+		p.FirewallCapabilities == other.FirewallCapabilities &&
+		slices.Equal(p.Services, other.Services) &&
+		slices.Equal(p.LANNetworks, other.LANNetworks)
 }
 
 // AddedWithSSOLogin indicates whether this peer has been added with an SSO login by a user.
@@ -82,12 +156,28 @@ func (p *Peer) AddedWithSSOLogin() bool {
 	return p.UserID != ""
 }
 
+// GetType returns the type of the peer. The peers registered before the types were introduced are user devices when
+// they were added with an SSO login and services otherwise
+func (p *Peer) GetType() PeerType {
+	if p.Type != "" {
+		return p.Type
+	}
+	if p.AddedWithSSOLogin() {
+		return PeerTypeUserDevice
+	}
+	return PeerTypeService
+}
+
 // Copy copies Peer object
 func (p *Peer) Copy() *Peer {
 	peerStatus := p.Status
 	if peerStatus != nil {
 		peerStatus = p.Status.Copy()
 	}
+	var labels map[string]string
+	if p.Labels != nil {
+		labels = maps.Clone(p.Labels)
+	}
 	return &Peer{
 		ID:                     p.ID,
 		AccountID:              p.AccountID,
@@ -97,6 +187,8 @@ func (p *Peer) Copy() *Peer {
 		Meta:                   p.Meta,
 		Name:                   p.Name,
 		DNSLabel:               p.DNSLabel,
+		DNSAliases:             slices.Clone(p.DNSAliases),
+		Labels:                 labels,
 		Status:                 peerStatus,
 		UserID:                 p.UserID,
 		SSHKey:                 p.SSHKey,
@@ -104,6 +196,11 @@ func (p *Peer) Copy() *Peer {
 		LoginExpirationEnabled: p.LoginExpirationEnabled,
 		LastLogin:              p.LastLogin,
 		Ephemeral:              p.Ephemeral,
+		AttestationProvider:    p.AttestationProvider,
+		AttestationKey:         p.AttestationKey,
+		BandwidthLimit:         p.BandwidthLimit,
+		ExternalID:             p.ExternalID,
+		Type:                   p.Type,
 	}
 }
 
@@ -168,7 +265,22 @@ func (p *PeerStatus) Copy() *PeerStatus {
 		Connected:        p.Connected,
 		LoginExpired:     p.LoginExpired,
 		RequiresApproval: p.RequiresApproval,
+		Disabled:         p.Disabled,
+		InactivityWarned: p.InactivityWarned,
+		EnabledAt:        p.EnabledAt,
+	}
+}
+
+// LastActivity returns the last time the peer connected to the management service, logged in or was enabled
+func (p *Peer) LastActivity() time.Time {
+	last := p.LastLogin
+	if p.Status.LastSeen.After(last) {
+		last = p.Status.LastSeen
+	}
+	if p.Status.EnabledAt.After(last) {
+		last = p.Status.EnabledAt
 	}
+	return last
 }
 
 // UpdateLastLogin and set login expired false
@@ -0,0 +1,434 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// maxBulkPeers is the maximum number of peers of a single bulk operation
+	maxBulkPeers = 10000
+	// bulkPeerBatchSize is the number of peers processed under a single account lock, the lock is released between
+	// the batches so the other requests of the account aren't blocked for the whole operation
+	bulkPeerBatchSize = 100
+	// bulkPeerJobRetention is how long a finished job can be retrieved
+	bulkPeerJobRetention = time.Hour
+)
+
+// BulkPeerAction is an action applied to many peers of an account at once
+type BulkPeerAction string
+
+const (
+	// BulkPeerActionDelete deletes the peers
+	BulkPeerActionDelete BulkPeerAction = "delete"
+	// BulkPeerActionMoveToGroup adds the peers to a group and removes them from the other groups managed by the API
+	BulkPeerActionMoveToGroup BulkPeerAction = "move_to_group"
+	// BulkPeerActionExpireSessions expires the login of the peers added with the SSO login, forcing them to log in again
+	BulkPeerActionExpireSessions BulkPeerAction = "expire_sessions"
+	// BulkPeerActionSetSSHEnabled enables or disables the SSH server of the peers
+	BulkPeerActionSetSSHEnabled BulkPeerAction = "set_ssh_enabled"
+)
+
+// BulkPeerOperation is an action to apply to a list of peers
+type BulkPeerOperation struct {
+	Action  BulkPeerAction
+	PeerIDs []string
+	// GroupID is the destination group of BulkPeerActionMoveToGroup
+	GroupID string
+	// SSHEnabled is the SSH server state set by BulkPeerActionSetSSHEnabled
+	SSHEnabled bool
+}
+
+// BulkPeerJobStatus is the progress of a bulk peer operation
+type BulkPeerJobStatus string
+
+const (
+	BulkPeerJobStatusPending   BulkPeerJobStatus = "pending"
+	BulkPeerJobStatusRunning   BulkPeerJobStatus = "running"
+	BulkPeerJobStatusCompleted BulkPeerJobStatus = "completed"
+	BulkPeerJobStatusFailed    BulkPeerJobStatus = "failed"
+)
+
+// BulkPeerError is the reason the action couldn't be applied to a peer
+type BulkPeerError struct {
+	PeerID  string
+	Message string
+}
+
+// BulkPeerJob tracks a bulk peer operation running in the background. A job is completed once all the peers were
+// processed, even when the action failed for some of them, and failed when it had to stop before.
+type BulkPeerJob struct {
+	ID        string
+	AccountID string
+	UserID    string
+	Action    BulkPeerAction
+	Status    BulkPeerJobStatus
+	// Total is the number of peers of the operation
+	Total int
+	// Processed is the number of peers processed so far, with or without error
+	Processed int
+	// Errors lists the peers the action couldn't be applied to
+	Errors []BulkPeerError
+	// Message is the reason a failed job stopped
+	Message    string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Copy returns a copy of the job
+func (j *BulkPeerJob) Copy() *BulkPeerJob {
+	jobCopy := *j
+	jobCopy.Errors = append([]BulkPeerError(nil), j.Errors...)
+	return &jobCopy
+}
+
+// bulkPeerJobStore keeps the jobs of the accounts in memory, the finished ones are removed after
+// bulkPeerJobRetention
+type bulkPeerJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*BulkPeerJob
+}
+
+func (s *bulkPeerJobStore) add(job *BulkPeerJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = make(map[string]*BulkPeerJob)
+	}
+	s.jobs[job.ID] = job
+}
+
+// update applies the change to the stored job
+func (s *bulkPeerJobStore) update(jobID string, change func(job *BulkPeerJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[jobID]; ok {
+		change(job)
+	}
+}
+
+// get returns a copy of the job of the account, removing the expired jobs
+func (s *bulkPeerJobStore) get(accountID, jobID string, now time.Time) (*BulkPeerJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, job := range s.jobs {
+		if !job.FinishedAt.IsZero() && now.Sub(job.FinishedAt) >= bulkPeerJobRetention {
+			delete(s.jobs, id)
+		}
+	}
+
+	job, ok := s.jobs[jobID]
+	if !ok || job.AccountID != accountID {
+		return nil, false
+	}
+	return job.Copy(), true
+}
+
+func (s *bulkPeerJobStore) deleteAccount(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, job := range s.jobs {
+		if job.AccountID == accountID {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// StartBulkPeerOperation validates the operation and starts applying it to the peers in the background. The returned
+// job can be polled with GetBulkPeerJob. Only users with admin power can run bulk operations.
+func (am *DefaultAccountManager) StartBulkPeerOperation(accountID, userID string, operation BulkPeerOperation) (*BulkPeerJob, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can run bulk peer operations")
+	}
+
+	switch operation.Action {
+	case BulkPeerActionDelete, BulkPeerActionExpireSessions, BulkPeerActionSetSSHEnabled:
+	case BulkPeerActionMoveToGroup:
+		if err := validateBulkMoveGroup(account, operation.GroupID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, status.Errorf(status.InvalidArgument, "unknown bulk peer action %q", operation.Action)
+	}
+
+	peerIDs := make([]string, 0, len(operation.PeerIDs))
+	seen := make(map[string]struct{}, len(operation.PeerIDs))
+	for _, peerID := range operation.PeerIDs {
+		if _, ok := seen[peerID]; ok {
+			continue
+		}
+		seen[peerID] = struct{}{}
+		peerIDs = append(peerIDs, peerID)
+	}
+
+	if len(peerIDs) == 0 {
+		return nil, status.Errorf(status.InvalidArgument, "no peers provided")
+	}
+	if len(peerIDs) > maxBulkPeers {
+		return nil, status.Errorf(status.InvalidArgument, "too many peers, the maximum is %d", maxBulkPeers)
+	}
+	operation.PeerIDs = peerIDs
+
+	job := &BulkPeerJob{
+		ID:        xid.New().String(),
+		AccountID: accountID,
+		UserID:    userID,
+		Action:    operation.Action,
+		Status:    BulkPeerJobStatusPending,
+		Total:     len(peerIDs),
+		CreatedAt: time.Now().UTC(),
+	}
+	am.bulkPeerJobs.add(job)
+	jobCopy := job.Copy()
+
+	go am.runBulkPeerJob(jobCopy.ID, accountID, userID, operation)
+
+	return jobCopy, nil
+}
+
+// GetBulkPeerJob returns the progress of a bulk peer operation of the account
+func (am *DefaultAccountManager) GetBulkPeerJob(accountID, userID, jobID string) (*BulkPeerJob, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view bulk peer operations")
+	}
+
+	job, ok := am.bulkPeerJobs.get(accountID, jobID, time.Now().UTC())
+	if !ok {
+		return nil, status.Errorf(status.NotFound, "bulk peer job %s not found", jobID)
+	}
+	return job, nil
+}
+
+// validateBulkMoveGroup checks that the peers can be moved to the group: the JWT and the integration groups are
+// synchronized from their source and the All group contains all the peers anyway
+func validateBulkMoveGroup(account *Account, groupID string) error {
+	group, ok := account.Groups[groupID]
+	if !ok {
+		return status.Errorf(status.NotFound, "group with ID %s not found", groupID)
+	}
+	if group.Name == "All" {
+		return status.Errorf(status.InvalidArgument, "peers can't be moved to the All group")
+	}
+	if !isAPIGroup(group) {
+		return status.Errorf(status.InvalidArgument, "peers can only be moved to groups managed by the API")
+	}
+	return nil
+}
+
+func isAPIGroup(group *Group) bool {
+	return group.Issued == "" || group.Issued == GroupIssuedAPI
+}
+
+// runBulkPeerJob applies the operation to the peers batch by batch, recording the progress in the job
+func (am *DefaultAccountManager) runBulkPeerJob(jobID, accountID, userID string, operation BulkPeerOperation) {
+	am.bulkPeerJobs.update(jobID, func(job *BulkPeerJob) {
+		job.Status = BulkPeerJobStatusRunning
+	})
+
+	for start := 0; start < len(operation.PeerIDs); start += bulkPeerBatchSize {
+		end := start + bulkPeerBatchSize
+		if end > len(operation.PeerIDs) {
+			end = len(operation.PeerIDs)
+		}
+		batch := operation.PeerIDs[start:end]
+
+		peerErrors, err := am.applyBulkPeerBatch(accountID, userID, operation, batch)
+		if err != nil {
+			log.Errorf("bulk peer job %s of account %s failed: %v", jobID, accountID, err)
+			am.bulkPeerJobs.update(jobID, func(job *BulkPeerJob) {
+				job.Status = BulkPeerJobStatusFailed
+				job.Message = err.Error()
+				job.FinishedAt = time.Now().UTC()
+			})
+			return
+		}
+
+		am.bulkPeerJobs.update(jobID, func(job *BulkPeerJob) {
+			job.Processed += len(batch)
+			job.Errors = append(job.Errors, peerErrors...)
+		})
+	}
+
+	am.bulkPeerJobs.update(jobID, func(job *BulkPeerJob) {
+		job.Status = BulkPeerJobStatusCompleted
+		job.FinishedAt = time.Now().UTC()
+	})
+}
+
+// applyBulkPeerBatch applies the operation to a batch of peers under the account lock and sends a single update to
+// the peers of the account. The peers the action can't be applied to are returned with the reason, the error stops
+// the job.
+func (am *DefaultAccountManager) applyBulkPeerBatch(accountID, userID string, operation BulkPeerOperation, peerIDs []string) ([]BulkPeerError, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var peerErrors []BulkPeerError
+	peers := make([]*nbpeer.Peer, 0, len(peerIDs))
+	for _, peerID := range peerIDs {
+		peer := account.GetPeer(peerID)
+		if peer == nil {
+			peerErrors = append(peerErrors, BulkPeerError{PeerID: peerID, Message: "peer not found"})
+			continue
+		}
+		peers = append(peers, peer)
+	}
+
+	var updated bool
+	switch operation.Action {
+	case BulkPeerActionDelete:
+		ids := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			ids = append(ids, peer.ID)
+		}
+		if err := am.deletePeers(account, ids, userID); err != nil {
+			return nil, err
+		}
+		updated = len(ids) > 0
+	case BulkPeerActionMoveToGroup:
+		// the group might have been deleted or changed since the job started
+		if err := validateBulkMoveGroup(account, operation.GroupID); err != nil {
+			return nil, err
+		}
+		updated = am.movePeersToGroup(account, userID, peers, operation.GroupID)
+	case BulkPeerActionExpireSessions:
+		for _, peer := range peers {
+			if !peer.AddedWithSSOLogin() {
+				peerErrors = append(peerErrors, BulkPeerError{PeerID: peer.ID, Message: "peer hasn't been added with the SSO login"})
+				continue
+			}
+			if peer.Status.LoginExpired {
+				continue
+			}
+			peer.MarkLoginExpired(true)
+			account.UpdatePeer(peer)
+			am.StoreEvent(userID, peer.ID, accountID, activity.PeerLoginExpired, peer.EventMeta(am.GetDNSDomain()))
+			updated = true
+		}
+	case BulkPeerActionSetSSHEnabled:
+		event := activity.PeerSSHEnabled
+		if !operation.SSHEnabled {
+			event = activity.PeerSSHDisabled
+		}
+		for _, peer := range peers {
+			if peer.SSHEnabled == operation.SSHEnabled {
+				continue
+			}
+			peer.SSHEnabled = operation.SSHEnabled
+			account.UpdatePeer(peer)
+			am.StoreEvent(userID, peer.IP.String(), accountID, event, peer.EventMeta(am.GetDNSDomain()))
+			updated = true
+		}
+	}
+
+	if !updated {
+		return peerErrors, nil
+	}
+
+	if err := am.Store.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	am.updateAccountPeers(account)
+
+	return peerErrors, nil
+}
+
+// movePeersToGroup adds the peers to the group and removes them from the other API groups, except the All group. It
+// returns whether the groups changed.
+func (am *DefaultAccountManager) movePeersToGroup(account *Account, userID string, peers []*nbpeer.Peer, groupID string) bool {
+	moved := make(map[string]struct{}, len(peers))
+	for _, peer := range peers {
+		moved[peer.ID] = struct{}{}
+	}
+
+	groupIDs := make([]string, 0, len(account.Groups))
+	for id := range account.Groups {
+		groupIDs = append(groupIDs, id)
+	}
+	sort.Strings(groupIDs)
+
+	changed := false
+	for _, id := range groupIDs {
+		group := account.Groups[id]
+		if id == groupID || group.Name == "All" || !isAPIGroup(group) {
+			continue
+		}
+
+		kept := make([]string, 0, len(group.Peers))
+		for _, peerID := range group.Peers {
+			if _, ok := moved[peerID]; !ok {
+				kept = append(kept, peerID)
+				continue
+			}
+			am.StoreEvent(userID, peerID, account.Id, activity.GroupRemovedFromPeer, am.bulkGroupEventMeta(account, group, peerID))
+		}
+		if len(kept) != len(group.Peers) {
+			group.Peers = kept
+			changed = true
+		}
+	}
+
+	group := account.Groups[groupID]
+	members := make(map[string]struct{}, len(group.Peers))
+	for _, peerID := range group.Peers {
+		members[peerID] = struct{}{}
+	}
+	for _, peer := range peers {
+		if _, ok := members[peer.ID]; ok {
+			continue
+		}
+		group.Peers = append(group.Peers, peer.ID)
+		am.StoreEvent(userID, peer.ID, account.Id, activity.GroupAddedToPeer, am.bulkGroupEventMeta(account, group, peer.ID))
+		changed = true
+	}
+
+	if changed {
+		account.Network.IncSerial()
+	}
+	return changed
+}
+
+func (am *DefaultAccountManager) bulkGroupEventMeta(account *Account, group *Group, peerID string) map[string]any {
+	peer := account.GetPeer(peerID)
+	return map[string]any{
+		"group": group.Name, "group_id": group.ID, "peer_ip": peer.IP.String(),
+		"peer_fqdn": peer.FQDN(am.GetDNSDomain()),
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitBulkPeerJob(t *testing.T, am *DefaultAccountManager, accountID, jobID string) *BulkPeerJob {
+	t.Helper()
+
+	var job *BulkPeerJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = am.GetBulkPeerJob(accountID, userID, jobID)
+		require.NoError(t, err)
+		return job.Status == BulkPeerJobStatusCompleted || job.Status == BulkPeerJobStatusFailed
+	}, 5*time.Second, 10*time.Millisecond, "the bulk peer job should finish")
+	return job
+}
+
+func TestBulkPeerOperation_MoveToGroup(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	job, err := am.StartBulkPeerOperation(account.Id, userID, BulkPeerOperation{
+		Action:  BulkPeerActionMoveToGroup,
+		PeerIDs: []string{peer1ID, peer4ID, peer1ID, "unknown"},
+		GroupID: routeGroup2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Total, "duplicated peers should be ignored")
+
+	job = waitBulkPeerJob(t, am, account.Id, job.ID)
+	assert.Equal(t, BulkPeerJobStatusCompleted, job.Status)
+	assert.Equal(t, 3, job.Processed)
+	assert.Equal(t, []BulkPeerError{{PeerID: "unknown", Message: "peer not found"}}, job.Errors)
+	assert.False(t, job.FinishedAt.IsZero())
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{peer2ID, peer1ID, peer4ID}, account.Groups[routeGroup2].Peers)
+	assert.Empty(t, account.Groups[routeGroup1].Peers)
+	assert.ElementsMatch(t, []string{peer2ID, peer3ID}, account.Groups[routeGroupHA1].Peers)
+	assert.Empty(t, account.Groups[routeGroupHA2].Peers)
+
+	groupAll, err := account.GetGroupAll()
+	require.NoError(t, err)
+	assert.Contains(t, groupAll.Peers, peer1ID, "the peers should stay in the All group")
+}
+
+func TestBulkPeerOperation_PeerSettings(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	job, err := am.StartBulkPeerOperation(account.Id, userID, BulkPeerOperation{
+		Action:     BulkPeerActionSetSSHEnabled,
+		PeerIDs:    []string{peer1ID, peer2ID},
+		SSHEnabled: true,
+	})
+	require.NoError(t, err)
+	job = waitBulkPeerJob(t, am, account.Id, job.ID)
+	assert.Equal(t, BulkPeerJobStatusCompleted, job.Status)
+	assert.Empty(t, job.Errors)
+
+	job, err = am.StartBulkPeerOperation(account.Id, userID, BulkPeerOperation{
+		Action:  BulkPeerActionExpireSessions,
+		PeerIDs: []string{peer2ID, peer3ID},
+	})
+	require.NoError(t, err)
+	job = waitBulkPeerJob(t, am, account.Id, job.ID)
+	assert.Equal(t, BulkPeerJobStatusCompleted, job.Status)
+	assert.Empty(t, job.Errors)
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	assert.True(t, account.Peers[peer1ID].SSHEnabled)
+	assert.True(t, account.Peers[peer2ID].SSHEnabled)
+	assert.False(t, account.Peers[peer3ID].SSHEnabled)
+	assert.False(t, account.Peers[peer1ID].Status.LoginExpired)
+	assert.True(t, account.Peers[peer2ID].Status.LoginExpired)
+	assert.True(t, account.Peers[peer3ID].Status.LoginExpired)
+
+	job, err = am.StartBulkPeerOperation(account.Id, userID, BulkPeerOperation{
+		Action:  BulkPeerActionDelete,
+		PeerIDs: []string{peer1ID, peer2ID},
+	})
+	require.NoError(t, err)
+	job = waitBulkPeerJob(t, am, account.Id, job.ID)
+	assert.Equal(t, BulkPeerJobStatusCompleted, job.Status)
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err)
+	assert.Nil(t, account.GetPeer(peer1ID))
+	assert.Nil(t, account.GetPeer(peer2ID))
+	assert.NotNil(t, account.GetPeer(peer3ID))
+}
+
+func TestBulkPeerOperation_Validation(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	regularUser := NewRegularUser("regularUser")
+	account.Users[regularUser.Id] = regularUser
+	groupAll, err := account.GetGroupAll()
+	require.NoError(t, err)
+	account.Groups["jwtGroup"] = &Group{ID: "jwtGroup", Name: "jwtGroup", Issued: GroupIssuedJWT}
+	require.NoError(t, am.Store.SaveAccount(account))
+
+	tooManyPeers := make([]string, maxBulkPeers+1)
+	for i := range tooManyPeers {
+		tooManyPeers[i] = fmt.Sprintf("peer-%d", i)
+	}
+
+	testCases := []struct {
+		name      string
+		userID    string
+		operation BulkPeerOperation
+	}{
+		{"regular user", regularUser.Id, BulkPeerOperation{Action: BulkPeerActionDelete, PeerIDs: []string{peer1ID}}},
+		{"unknown action", userID, BulkPeerOperation{Action: "reboot", PeerIDs: []string{peer1ID}}},
+		{"no peers", userID, BulkPeerOperation{Action: BulkPeerActionDelete}},
+		{"too many peers", userID, BulkPeerOperation{Action: BulkPeerActionDelete, PeerIDs: tooManyPeers}},
+		{"unknown group", userID, BulkPeerOperation{Action: BulkPeerActionMoveToGroup, PeerIDs: []string{peer1ID}, GroupID: "unknown"}},
+		{"All group", userID, BulkPeerOperation{Action: BulkPeerActionMoveToGroup, PeerIDs: []string{peer1ID}, GroupID: groupAll.ID}},
+		{"JWT group", userID, BulkPeerOperation{Action: BulkPeerActionMoveToGroup, PeerIDs: []string{peer1ID}, GroupID: "jwtGroup"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := am.StartBulkPeerOperation(account.Id, testCase.userID, testCase.operation)
+			require.Error(t, err)
+		})
+	}
+
+	job, err := am.StartBulkPeerOperation(account.Id, userID, BulkPeerOperation{Action: BulkPeerActionDelete, PeerIDs: []string{"unknown"}})
+	require.NoError(t, err)
+
+	_, err = am.GetBulkPeerJob(account.Id, regularUser.Id, job.ID)
+	require.Error(t, err, "regular users shouldn't view the jobs")
+
+	_, err = am.GetBulkPeerJob(account.Id, userID, "unknown")
+	require.Error(t, err)
+}
+
+func TestBulkPeerJobStore_ExpiresJobs(t *testing.T) {
+	store := &bulkPeerJobStore{}
+	now := time.Now()
+	store.add(&BulkPeerJob{ID: "finished", AccountID: "account", FinishedAt: now.Add(-2 * bulkPeerJobRetention)})
+	store.add(&BulkPeerJob{ID: "running", AccountID: "account", CreatedAt: now.Add(-2 * bulkPeerJobRetention)})
+
+	_, ok := store.get("account", "finished", now)
+	assert.False(t, ok, "the finished job should be expired")
+
+	job, ok := store.get("account", "running", now)
+	require.True(t, ok, "the running job shouldn't be expired")
+	job.Errors = append(job.Errors, BulkPeerError{PeerID: "peer"})
+
+	job, ok = store.get("account", "running", now)
+	require.True(t, ok)
+	assert.Empty(t, job.Errors, "the returned job should be a copy")
+
+	_, ok = store.get("other", "running", now)
+	assert.False(t, ok, "the job shouldn't be visible to other accounts")
+
+	store.deleteAccount("account")
+	_, ok = store.get("account", "running", now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// PeerInactivityActionDisable disables the inactive peers until an admin enables them
+	PeerInactivityActionDisable = "disable"
+	// PeerInactivityActionDelete deletes the inactive peers
+	PeerInactivityActionDelete = "delete"
+
+	minPeerInactivityExpiration = 24 * time.Hour
+	maxPeerInactivityExpiration = 365 * 24 * time.Hour
+	// maxPeerInactivityGracePeriod is the longest period between the inactivity warning and the action
+	maxPeerInactivityGracePeriod = 7 * 24 * time.Hour
+	// maxPeerInactivityCheckInterval is the longest period between two checks of the peers of an account
+	maxPeerInactivityCheckInterval = 24 * time.Hour
+)
+
+// validatePeerInactivitySettings checks the inactivity settings when the inactivity expiration is enabled, the action
+// defaults to PeerInactivityActionDisable
+func validatePeerInactivitySettings(settings *Settings) error {
+	if !settings.PeerInactivityExpirationEnabled {
+		return nil
+	}
+
+	if settings.PeerInactivityExpiration < minPeerInactivityExpiration {
+		return status.Errorf(status.InvalidArgument, "peer inactivity expiration can't be smaller than one day")
+	}
+	if settings.PeerInactivityExpiration > maxPeerInactivityExpiration {
+		return status.Errorf(status.InvalidArgument, "peer inactivity expiration can't be larger than 365 days")
+	}
+
+	switch settings.PeerInactivityAction {
+	case "":
+		settings.PeerInactivityAction = PeerInactivityActionDisable
+	case PeerInactivityActionDisable, PeerInactivityActionDelete:
+	default:
+		return status.Errorf(status.InvalidArgument, "invalid peer inactivity action %s, expected %s or %s",
+			settings.PeerInactivityAction, PeerInactivityActionDisable, PeerInactivityActionDelete)
+	}
+	return nil
+}
+
+// peerInactivityGracePeriod returns the period between the inactivity warning of a peer and the action, half of the
+// expiration up to a week
+func peerInactivityGracePeriod(expiration time.Duration) time.Duration {
+	grace := expiration / 2
+	if grace > maxPeerInactivityGracePeriod {
+		grace = maxPeerInactivityGracePeriod
+	}
+	return grace
+}
+
+// peersSubjectToInactivity returns the peers the inactivity expiration applies to. The connected peers are active,
+// the disabled peers were already handled and the ephemeral peers are deleted by the ephemeral manager
+func (a *Account) peersSubjectToInactivity() []*nbpeer.Peer {
+	var peers []*nbpeer.Peer
+	for _, peer := range a.Peers {
+		if peer.Status.Connected || peer.Status.Disabled || peer.Ephemeral {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// GetInactivePeers returns the peers to warn about the upcoming inactivity action and the peers which didn't connect
+// for longer than Settings.PeerInactivityExpiration
+func (a *Account) GetInactivePeers(now time.Time) (warn []*nbpeer.Peer, expired []*nbpeer.Peer) {
+	if !a.Settings.PeerInactivityExpirationEnabled {
+		return nil, nil
+	}
+
+	expiration := a.Settings.PeerInactivityExpiration
+	warnAfter := expiration - peerInactivityGracePeriod(expiration)
+	for _, peer := range a.peersSubjectToInactivity() {
+		inactive := now.Sub(peer.LastActivity())
+		switch {
+		case inactive >= expiration:
+			expired = append(expired, peer)
+		case inactive >= warnAfter && !peer.Status.InactivityWarned:
+			warn = append(warn, peer)
+		}
+	}
+	return warn, expired
+}
+
+// GetNextPeerInactivityCheck returns the duration until the next warning or action on an inactive peer of the account.
+// The peers may disconnect meanwhile, the duration is capped so that they are still warned before the action.
+// It returns false when the inactivity expiration is disabled.
+func (a *Account) GetNextPeerInactivityCheck(now time.Time) (time.Duration, bool) {
+	if !a.Settings.PeerInactivityExpirationEnabled {
+		return 0, false
+	}
+
+	expiration := a.Settings.PeerInactivityExpiration
+	grace := peerInactivityGracePeriod(expiration)
+	next := grace
+	if next > maxPeerInactivityCheckInterval {
+		next = maxPeerInactivityCheckInterval
+	}
+
+	for _, peer := range a.peersSubjectToInactivity() {
+		at := peer.LastActivity().Add(expiration)
+		if !peer.Status.InactivityWarned {
+			at = at.Add(-grace)
+		}
+		if in := at.Sub(now); in < next {
+			next = in
+		}
+	}
+
+	if next < 0 {
+		next = 0
+	}
+	return next, true
+}
+
+// EnablePeer enables a peer disabled for inactivity, the inactivity period of the peer starts over
+func (am *DefaultAccountManager) EnablePeer(accountID, peerID, userID string) (*nbpeer.Peer, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can enable peers")
+	}
+
+	peer := account.GetPeer(peerID)
+	if peer == nil {
+		return nil, status.Errorf(status.NotFound, "peer %s not found", peerID)
+	}
+
+	if !peer.Status.Disabled {
+		return peer, nil
+	}
+
+	newStatus := peer.Status.Copy()
+	newStatus.Disabled = false
+	newStatus.InactivityWarned = false
+	newStatus.EnabledAt = time.Now().UTC()
+	peer.Status = newStatus
+	account.UpdatePeer(peer)
+
+	err = am.Store.SavePeerStatus(account.Id, peer.ID, *newStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	am.StoreEvent(userID, peer.ID, account.Id, activity.PeerEnabled, peer.EventMeta(am.GetDNSDomain()))
+
+	am.updateAccountPeers(account)
+
+	return peer, nil
+}
+
+func (am *DefaultAccountManager) peerInactivityExpirationJob(accountID string) func() (time.Duration, bool) {
+	return func() (time.Duration, bool) {
+		unlock := am.Store.AcquireAccountLock(accountID)
+		defer unlock()
+
+		account, err := am.Store.GetAccount(accountID)
+		if err != nil {
+			log.Errorf("failed getting account %s checking inactive peers: %v", accountID, err)
+			return maxPeerInactivityCheckInterval, true
+		}
+
+		now := time.Now().UTC()
+		warn, expired := account.GetInactivePeers(now)
+
+		log.Debugf("discovered %d inactive peers to warn and %d to expire for account %s", len(warn), len(expired), account.Id)
+
+		if err := am.warnInactivePeers(account, warn, now); err != nil {
+			log.Errorf("failed warning inactive peers of account %s: %v", account.Id, err)
+		}
+
+		if err := am.expireInactivePeers(account, expired); err != nil {
+			log.Errorf("failed expiring inactive peers of account %s: %v", account.Id, err)
+		}
+
+		return account.GetNextPeerInactivityCheck(time.Now().UTC())
+	}
+}
+
+// warnInactivePeers stores an event for the peers which will be disabled or deleted at the end of the grace period
+func (am *DefaultAccountManager) warnInactivePeers(account *Account, peers []*nbpeer.Peer, now time.Time) error {
+	for _, peer := range peers {
+		newStatus := peer.Status.Copy()
+		newStatus.InactivityWarned = true
+		peer.Status = newStatus
+		account.UpdatePeer(peer)
+
+		if err := am.Store.SavePeerStatus(account.Id, peer.ID, *newStatus); err != nil {
+			return err
+		}
+
+		meta := peer.EventMeta(am.GetDNSDomain())
+		meta["action"] = account.Settings.PeerInactivityAction
+		meta["last_activity"] = peer.LastActivity()
+		meta["action_at"] = peer.LastActivity().Add(account.Settings.PeerInactivityExpiration)
+		meta["inactive_days"] = int(now.Sub(peer.LastActivity()).Hours() / 24)
+		am.StoreEvent(activity.SystemInitiator, peer.ID, account.Id, activity.PeerInactivityWarning, meta)
+	}
+	return nil
+}
+
+// expireInactivePeers disables or deletes the inactive peers depending on Settings.PeerInactivityAction and updates the
+// other peers
+func (am *DefaultAccountManager) expireInactivePeers(account *Account, peers []*nbpeer.Peer) error {
+	if len(peers) == 0 {
+		return nil
+	}
+
+	if account.Settings.PeerInactivityAction == PeerInactivityActionDelete {
+		peerIDs := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			peerIDs = append(peerIDs, peer.ID)
+		}
+		err := am.deletePeersWithEvent(account, peerIDs, activity.SystemInitiator, activity.PeerDeletedForInactivity)
+		if err != nil {
+			return err
+		}
+		if err := am.Store.SaveAccount(account); err != nil {
+			return err
+		}
+		am.updateAccountPeers(account)
+		return nil
+	}
+
+	for _, peer := range peers {
+		newStatus := peer.Status.Copy()
+		newStatus.Disabled = true
+		newStatus.Connected = false
+		peer.Status = newStatus
+		account.UpdatePeer(peer)
+
+		if err := am.Store.SavePeerStatus(account.Id, peer.ID, *newStatus); err != nil {
+			return err
+		}
+		am.StoreEvent(activity.SystemInitiator, peer.ID, account.Id, activity.PeerDisabledForInactivity,
+			peer.EventMeta(am.GetDNSDomain()))
+	}
+
+	// the disabled peers are removed from the network maps of the other peers
+	am.updateAccountPeers(account)
+	return nil
+}
+
+func (am *DefaultAccountManager) checkAndSchedulePeerInactivityExpiration(account *Account) {
+	am.peerInactivityExpiry.Cancel([]string{account.Id})
+	if nextRun, ok := account.GetNextPeerInactivityCheck(time.Now().UTC()); ok {
+		go am.peerInactivityExpiry.Schedule(nextRun, account.Id, am.peerInactivityExpirationJob(account.Id))
+	}
+}
+
+// withoutDisabledPeers removes the peers disabled for inactivity and their firewall rules from the connection
+// resources of a peer
+func withoutDisabledPeers(aclPeers []*nbpeer.Peer, firewallRules []*FirewallRule) ([]*nbpeer.Peer, []*FirewallRule) {
+	disabledIPs := make(map[string]struct{})
+	peers := make([]*nbpeer.Peer, 0, len(aclPeers))
+	for _, p := range aclPeers {
+		if p.Status.Disabled {
+			disabledIPs[p.IP.String()] = struct{}{}
+			continue
+		}
+		peers = append(peers, p)
+	}
+	if len(disabledIPs) == 0 {
+		return aclPeers, firewallRules
+	}
+
+	rules := make([]*FirewallRule, 0, len(firewallRules))
+	for _, rule := range firewallRules {
+		if _, ok := disabledIPs[rule.PeerIP]; ok {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return peers, rules
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestAccount_GetInactivePeers(t *testing.T) {
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	newPeer := func(id string, lastSeen time.Time, status nbpeer.PeerStatus) *nbpeer.Peer {
+		status.LastSeen = lastSeen
+		return &nbpeer.Peer{ID: id, Status: &status}
+	}
+
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"active":    newPeer("active", now.Add(-day), nbpeer.PeerStatus{}),
+			"warn":      newPeer("warn", now.Add(-25*day), nbpeer.PeerStatus{}),
+			"warned":    newPeer("warned", now.Add(-25*day), nbpeer.PeerStatus{InactivityWarned: true}),
+			"expired":   newPeer("expired", now.Add(-31*day), nbpeer.PeerStatus{InactivityWarned: true}),
+			"connected": newPeer("connected", now.Add(-40*day), nbpeer.PeerStatus{Connected: true}),
+			"disabled":  newPeer("disabled", now.Add(-40*day), nbpeer.PeerStatus{Disabled: true}),
+			"enabled":   newPeer("enabled", now.Add(-40*day), nbpeer.PeerStatus{EnabledAt: now.Add(-day)}),
+		},
+		Settings: &Settings{
+			PeerInactivityExpiration: 30 * day,
+			PeerInactivityAction:     PeerInactivityActionDisable,
+		},
+	}
+
+	warn, expired := account.GetInactivePeers(now)
+	assert.Empty(t, warn, "no peer should expire while the inactivity expiration is disabled")
+	assert.Empty(t, expired)
+	_, ok := account.GetNextPeerInactivityCheck(now)
+	assert.False(t, ok)
+
+	account.Settings.PeerInactivityExpirationEnabled = true
+	warn, expired = account.GetInactivePeers(now)
+	require.Len(t, warn, 1)
+	assert.Equal(t, "warn", warn[0].ID, "the peers should be warned a week before the action")
+	require.Len(t, expired, 1)
+	assert.Equal(t, "expired", expired[0].ID)
+
+	next, ok := account.GetNextPeerInactivityCheck(now)
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(0), next, "the peers to handle now should be checked at once")
+
+	delete(account.Peers, "warn")
+	delete(account.Peers, "expired")
+	next, ok = account.GetNextPeerInactivityCheck(now)
+	require.True(t, ok)
+	assert.Equal(t, 24*time.Hour, next, "the checks should run at least daily")
+
+	account.Peers["warned"].Status.LastSeen = now.Add(-30*day + 12*time.Hour)
+	next, _ = account.GetNextPeerInactivityCheck(now)
+	assert.Equal(t, 12*time.Hour, next, "the warned peer should be checked at the end of the grace period")
+}
+
+func TestValidatePeerInactivitySettings(t *testing.T) {
+	settings := &Settings{PeerInactivityExpirationEnabled: true, PeerInactivityExpiration: 90 * 24 * time.Hour}
+	require.NoError(t, validatePeerInactivitySettings(settings))
+	assert.Equal(t, PeerInactivityActionDisable, settings.PeerInactivityAction, "the peers should be disabled by default")
+
+	settings.PeerInactivityAction = "archive"
+	assert.Error(t, validatePeerInactivitySettings(settings))
+
+	settings.PeerInactivityAction = PeerInactivityActionDelete
+	settings.PeerInactivityExpiration = time.Hour
+	assert.Error(t, validatePeerInactivitySettings(settings))
+
+	settings.PeerInactivityExpirationEnabled = false
+	assert.NoError(t, validatePeerInactivitySettings(settings), "the settings should be ignored while disabled")
+}
+
+func TestWithoutDisabledPeers(t *testing.T) {
+	peers := []*nbpeer.Peer{
+		{ID: "enabled", IP: net.IP{100, 64, 0, 1}, Status: &nbpeer.PeerStatus{}},
+		{ID: "disabled", IP: net.IP{100, 64, 0, 2}, Status: &nbpeer.PeerStatus{Disabled: true}},
+	}
+	rules := []*FirewallRule{{PeerIP: "100.64.0.1"}, {PeerIP: "100.64.0.2"}, {PeerIP: "0.0.0.0"}}
+
+	peers, rules = withoutDisabledPeers(peers, rules)
+	require.Len(t, peers, 1)
+	assert.Equal(t, "enabled", peers[0].ID)
+	assert.Equal(t, []*FirewallRule{{PeerIP: "100.64.0.1"}, {PeerIP: "0.0.0.0"}}, rules)
+}
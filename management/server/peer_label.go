@@ -0,0 +1,88 @@
+package server
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// maxPeerLabels is the maximum number of labels of a peer
+const maxPeerLabels = 32
+
+var (
+	// peerLabelKeyMatcher matches a lower case label key of letters, numbers, dots, underscores and hyphens,
+	// starting and ending with a letter or number
+	peerLabelKeyMatcher = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,61}[a-z0-9])?$`)
+	// peerLabelValueMatcher matches an empty label value or one of letters, numbers, dots, underscores, colons,
+	// slashes and hyphens, starting and ending with a letter or number
+	peerLabelValueMatcher = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9._:/-]{0,61}[a-zA-Z0-9])?)?$`)
+)
+
+// validatePeerLabels returns the labels of a peer with lower cased keys. The keys and values are restricted to
+// characters safe in DNS TXT records and shell scripts.
+func validatePeerLabels(labels map[string]string) (map[string]string, error) {
+	if len(labels) > maxPeerLabels {
+		return nil, status.Errorf(status.InvalidArgument, "a peer can have up to %d labels", maxPeerLabels)
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+
+	validated := make(map[string]string, len(labels))
+	for key, value := range labels {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !peerLabelKeyMatcher.MatchString(key) {
+			return nil, status.Errorf(status.InvalidArgument, "label key %q should have up to 63 letters, numbers, dots, underscores and hyphens", key)
+		}
+		value = strings.TrimSpace(value)
+		if !peerLabelValueMatcher.MatchString(value) {
+			return nil, status.Errorf(status.InvalidArgument, "value of the label %s should have up to 63 letters, numbers, dots, underscores, colons, slashes and hyphens", key)
+		}
+		if _, ok := validated[key]; ok {
+			return nil, status.Errorf(status.InvalidArgument, "label key %s is duplicated", key)
+		}
+		validated[key] = value
+	}
+	return validated, nil
+}
+
+// getPeerLabelRecords returns the TXT records of the peer labels, one key=value record per label under the peer FQDN
+func getPeerLabelRecords(account *Account, dnsDomain string) []nbdns.SimpleRecord {
+	peers := make([]*nbpeer.Peer, 0, len(account.Peers))
+	for _, peer := range account.Peers {
+		if peer.DNSLabel == "" || len(peer.Labels) == 0 {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	// the peers and labels are sorted to send the same records on every update
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+
+	var records []nbdns.SimpleRecord
+	for _, peer := range peers {
+		keys := make([]string, 0, len(peer.Labels))
+		for key := range peer.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			records = append(records, nbdns.SimpleRecord{
+				Name:  dns.Fqdn(peer.DNSLabel + "." + dnsDomain),
+				Type:  int(dns.TypeTXT),
+				Class: nbdns.DefaultClass,
+				TTL:   defaultTTL,
+				RData: `"` + key + "=" + peer.Labels[key] + `"`,
+			})
+		}
+	}
+	return records
+}
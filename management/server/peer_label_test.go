@@ -0,0 +1,77 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestValidatePeerLabels(t *testing.T) {
+	labels, err := validatePeerLabels(map[string]string{"Env": "prod", "role": "db", "k8s.io/zone": "", "site": "eu-west:1"})
+	require.Error(t, err, "a key with a slash should be rejected")
+	assert.Nil(t, labels)
+
+	labels, err = validatePeerLabels(map[string]string{"Env": " prod ", "role": "db", "zone": "", "site": "eu-west:1/a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "role": "db", "zone": "", "site": "eu-west:1/a"}, labels)
+
+	labels, err = validatePeerLabels(map[string]string{})
+	require.NoError(t, err)
+	assert.Nil(t, labels, "no labels should be stored as nil")
+
+	for _, invalid := range []map[string]string{
+		{"env": "prod", "ENV": "stage"},
+		{"": "prod"},
+		{"-env": "prod"},
+		{"env": "prod value"},
+		{"env": `"prod"`},
+		{"env": strings.Repeat("a", 64)},
+	} {
+		_, err = validatePeerLabels(invalid)
+		assert.Error(t, err, "labels %v should be rejected", invalid)
+	}
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= maxPeerLabels; i++ {
+		tooMany[strings.Repeat("a", i+1)] = "b"
+	}
+	_, err = validatePeerLabels(tooMany)
+	assert.Error(t, err, "more than %d labels should be rejected", maxPeerLabels)
+}
+
+func TestGetPeerLabelRecords(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"peer-a": {
+				ID:       "peer-a",
+				DNSLabel: "db",
+				Labels:   map[string]string{"role": "db", "env": "prod"},
+			},
+			"peer-b": {
+				ID:       "peer-b",
+				DNSLabel: "web",
+			},
+		},
+	}
+
+	records := getPeerLabelRecords(account, "netbird.test")
+
+	var rData []string
+	for _, record := range records {
+		assert.Equal(t, int(dns.TypeTXT), record.Type)
+		assert.Equal(t, nbdns.DefaultClass, record.Class)
+		assert.Equal(t, "db.netbird.test.", record.Name)
+		rData = append(rData, record.RData)
+
+		rr, err := dns.NewRR(record.String())
+		require.NoError(t, err)
+		assert.Equal(t, dns.Len(rr)-len(rr.Header().Name)-11, int(record.Len()), "the record length should match the RDATA")
+	}
+	assert.Equal(t, []string{`"env=prod"`, `"role=db"`}, rData, "the records should be sorted by key")
+}
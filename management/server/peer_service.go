@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	"github.com/FlintyLemming/netbird/management/proto"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+// maxPeerServices is the maximum number of services advertised by a peer
+const maxPeerServices = 32
+
+// toPeerServices returns the valid services advertised by a peer without duplicates. A service needs a DNS label,
+// a port and the tcp or udp protocol, the other ones are dropped.
+func toPeerServices(protoServices []*proto.PeerService) []nbpeer.PeerService {
+	var services []nbpeer.PeerService
+	added := make(lookupMap)
+	for _, protoService := range protoServices {
+		service := nbpeer.PeerService{
+			Label:    strings.ToLower(protoService.GetLabel()),
+			Port:     uint16(protoService.GetPort()),
+			Protocol: strings.ToLower(protoService.GetProtocol()),
+		}
+		if err := validatePeerService(service, protoService.GetPort()); err != nil {
+			log.Warnf("ignoring the service %s/%s advertised by a peer: %v", protoService.GetLabel(), protoService.GetProtocol(), err)
+			continue
+		}
+
+		key := service.Label + "/" + service.Protocol
+		if _, ok := added[key]; ok {
+			continue
+		}
+		if len(services) == maxPeerServices {
+			log.Warnf("a peer advertised more than %d services, ignoring the others", maxPeerServices)
+			break
+		}
+		added[key] = struct{}{}
+		services = append(services, service)
+	}
+	return services
+}
+
+func validatePeerService(service nbpeer.PeerService, port uint32) error {
+	if !dnsLabelMatcher.MatchString(service.Label) {
+		return fmt.Errorf("label should be a single DNS label of letters, numbers and hyphens")
+	}
+	if port == 0 || port > 65535 {
+		return fmt.Errorf("port %d is not between 1 and 65535", port)
+	}
+	if service.Protocol != "tcp" && service.Protocol != "udp" {
+		return fmt.Errorf("protocol should be tcp or udp")
+	}
+	return nil
+}
+
+func toProtocolPeerServices(services []nbpeer.PeerService) []*proto.PeerService {
+	var protoServices []*proto.PeerService
+	for _, service := range services {
+		protoServices = append(protoServices, &proto.PeerService{
+			Label:    service.Label,
+			Port:     uint32(service.Port),
+			Protocol: service.Protocol,
+		})
+	}
+	return protoServices
+}
+
+// getPeerServiceRecords returns the SRV records of the services advertised by the peers. Every service is published
+// under the peer FQDN, i.e. _<label>._<protocol>.<peer fqdn>, and under the DNS domain, i.e.
+// _<label>._<protocol>.<domain>, with one record for each peer advertising the service.
+func getPeerServiceRecords(account *Account, dnsDomain string) []nbdns.SimpleRecord {
+	peers := make([]*nbpeer.Peer, 0, len(account.Peers))
+	for _, peer := range account.Peers {
+		if peer.DNSLabel == "" || len(peer.Meta.Services) == 0 {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	// the peers are sorted to send the same records on every update
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].ID < peers[j].ID
+	})
+
+	var records []nbdns.SimpleRecord
+	for _, peer := range peers {
+		target := dns.Fqdn(peer.DNSLabel + "." + dnsDomain)
+		for _, service := range peer.Meta.Services {
+			serviceName := "_" + service.Label + "._" + service.Protocol
+			rData := fmt.Sprintf("0 0 %d %s", service.Port, target)
+
+			records = append(records,
+				nbdns.SimpleRecord{
+					Name:  dns.Fqdn(serviceName + "." + target),
+					Type:  int(dns.TypeSRV),
+					Class: nbdns.DefaultClass,
+					TTL:   defaultTTL,
+					RData: rData,
+				},
+				nbdns.SimpleRecord{
+					Name:  dns.Fqdn(serviceName + "." + dnsDomain),
+					Type:  int(dns.TypeSRV),
+					Class: nbdns.DefaultClass,
+					TTL:   defaultTTL,
+					RData: rData,
+				},
+			)
+		}
+	}
+	return records
+}
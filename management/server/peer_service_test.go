@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	nbdns "github.com/FlintyLemming/netbird/dns"
+	"github.com/FlintyLemming/netbird/management/proto"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestToPeerServices(t *testing.T) {
+	services := toPeerServices([]*proto.PeerService{
+		{Label: "HTTP", Port: 8080, Protocol: "TCP"},
+		{Label: "http", Port: 8081, Protocol: "tcp"},
+		{Label: "http", Port: 8080, Protocol: "udp"},
+		{Label: "_http", Port: 80, Protocol: "tcp"},
+		{Label: "dns", Port: 0, Protocol: "udp"},
+		{Label: "dns", Port: 70000, Protocol: "udp"},
+		{Label: "sctp", Port: 80, Protocol: "sctp"},
+	})
+
+	assert.Equal(t, []nbpeer.PeerService{
+		{Label: "http", Port: 8080, Protocol: "tcp"},
+		{Label: "http", Port: 8080, Protocol: "udp"},
+	}, services, "invalid and duplicated services should be dropped")
+}
+
+func TestGetPeerServiceRecords(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"peer-b": {
+				ID:       "peer-b",
+				DNSLabel: "web-b",
+				Meta:     nbpeer.PeerSystemMeta{Services: []nbpeer.PeerService{{Label: "http", Port: 8080, Protocol: "tcp"}}},
+			},
+			"peer-a": {
+				ID:       "peer-a",
+				DNSLabel: "web-a",
+				Meta:     nbpeer.PeerSystemMeta{Services: []nbpeer.PeerService{{Label: "http", Port: 80, Protocol: "tcp"}}},
+			},
+			"peer-c": {
+				ID:       "peer-c",
+				DNSLabel: "db",
+			},
+		},
+	}
+
+	records := getPeerServiceRecords(account, "netbird.test")
+
+	srv := make(map[string][]string)
+	for _, record := range records {
+		assert.Equal(t, int(dns.TypeSRV), record.Type)
+		assert.Equal(t, nbdns.DefaultClass, record.Class)
+		srv[record.Name] = append(srv[record.Name], record.RData)
+	}
+
+	assert.Equal(t, map[string][]string{
+		"_http._tcp.web-a.netbird.test.": {"0 0 80 web-a.netbird.test."},
+		"_http._tcp.web-b.netbird.test.": {"0 0 8080 web-b.netbird.test."},
+		"_http._tcp.netbird.test.":       {"0 0 80 web-a.netbird.test.", "0 0 8080 web-b.netbird.test."},
+	}, srv)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/rs/xid"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -80,7 +81,7 @@ func TestAccountManager_GetNetworkMap(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userId, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userId, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -329,7 +330,7 @@ func TestAccountManager_GetPeerNetwork(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userId, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userId, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -399,7 +400,7 @@ func TestDefaultAccountManager_GetPeer(t *testing.T) {
 	}
 
 	// two peers one added by a regular user and one with a setup key
-	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, adminUser, false)
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, adminUser, false, "", "")
 	if err != nil {
 		t.Fatal("error creating setup key")
 		return
@@ -480,3 +481,110 @@ func TestDefaultAccountManager_GetPeer(t *testing.T) {
 	}
 	assert.NotNil(t, peer)
 }
+
+func TestDefaultAccountManager_GetPeerNetworkMap(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err)
+
+	accountID := "test_account"
+	adminUser := "account_creator"
+	someUser := "some_user"
+	account := newAccountWithId(accountID, adminUser, "")
+	account.Users[someUser] = &User{
+		Id:   someUser,
+		Role: UserRoleUser,
+	}
+	err = manager.Store.SaveAccount(account)
+	require.NoError(t, err)
+
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, adminUser, false, "", "")
+	require.NoError(t, err)
+
+	var peers []*nbpeer.Peer
+	for _, hostname := range []string{"test-peer-1", "test-peer-2"} {
+		key, err := wgtypes.GeneratePrivateKey()
+		require.NoError(t, err)
+		peer, _, err := manager.AddPeer(setupKey.Key, "", &nbpeer.Peer{
+			Key:  key.PublicKey().String(),
+			Meta: nbpeer.PeerSystemMeta{Hostname: hostname},
+		})
+		require.NoError(t, err)
+		peers = append(peers, peer)
+	}
+
+	networkMap, err := manager.GetPeerNetworkMap(accountID, peers[0].ID, adminUser)
+	require.NoError(t, err)
+	require.Len(t, networkMap.Peers, 1)
+	assert.Equal(t, peers[1].ID, networkMap.Peers[0].ID)
+	assert.NotEmpty(t, networkMap.FirewallRules, "the default all-to-all policy should allow the traffic")
+
+	_, err = manager.GetPeerNetworkMap(accountID, peers[0].ID, someUser)
+	assert.Error(t, err, "regular users shouldn't view the network map of a peer")
+
+	_, err = manager.GetPeerNetworkMap(accountID, "unknown", adminUser)
+	assert.Error(t, err, "should fail for an unknown peer")
+}
+
+func TestDefaultAccountManager_LoginPeerWithAttestation(t *testing.T) {
+	manager, err := createManager(t)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	userId := "account_creator"
+	account, err := createAccount(manager, "test_account", userId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, userId, false, "", "")
+	if err != nil {
+		t.Fatal("error creating setup key")
+		return
+	}
+
+	peerKey, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	peer, _, err := manager.LoginPeer(PeerLogin{
+		WireGuardPubKey:     peerKey.PublicKey().String(),
+		Meta:                nbpeer.PeerSystemMeta{Hostname: "test-peer"},
+		SetupKey:            setupKey.Key,
+		AttestationProvider: "tpm",
+		AttestationKey:      "attestation-key",
+	})
+	if err != nil {
+		t.Fatalf("expecting peer to be registered, got failure %v", err)
+	}
+	assert.Equal(t, "tpm", peer.AttestationProvider)
+	assert.Equal(t, "attestation-key", peer.AttestationKey)
+
+	_, _, err = manager.LoginPeer(PeerLogin{
+		WireGuardPubKey: peerKey.PublicKey().String(),
+		Meta:            nbpeer.PeerSystemMeta{Hostname: "test-peer"},
+	})
+	assert.Error(t, err, "expecting login without attestation to fail")
+
+	_, _, err = manager.LoginPeer(PeerLogin{
+		WireGuardPubKey:     peerKey.PublicKey().String(),
+		Meta:                nbpeer.PeerSystemMeta{Hostname: "test-peer"},
+		AttestationProvider: "tpm",
+		AttestationKey:      "another-key",
+	})
+	assert.Error(t, err, "expecting login with a different attestation key to fail")
+
+	_, _, err = manager.SyncPeer(PeerSync{WireGuardPubKey: peerKey.PublicKey().String()})
+	assert.Error(t, err, "expecting sync without attestation to fail")
+
+	_, _, err = manager.LoginPeer(PeerLogin{
+		WireGuardPubKey:     peerKey.PublicKey().String(),
+		Meta:                nbpeer.PeerSystemMeta{Hostname: "test-peer"},
+		AttestationProvider: "tpm",
+		AttestationKey:      "attestation-key",
+	})
+	assert.NoError(t, err, "expecting login with the registered attestation key to succeed")
+}
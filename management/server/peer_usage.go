@@ -0,0 +1,177 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+const (
+	// maxUsageReportDays is the maximum number of days accepted in a single report of a peer
+	maxUsageReportDays = 7
+	// peerUsageMaxDays is the number of days the usage of the peers is kept
+	peerUsageMaxDays = 31
+	// usageDateLayout is the layout of the dates of the reported days, the days are in UTC
+	usageDateLayout = "2006-01-02"
+)
+
+// PeerDailyUsage is the traffic of a peer over the tunnel during a day in UTC as reported by the peer
+type PeerDailyUsage struct {
+	PeerID string
+	// Date of the day in the YYYY-MM-DD format
+	Date       string
+	RxBytes    uint64
+	TxBytes    uint64
+	ReportedAt time.Time
+}
+
+type peerUsageKey struct {
+	peerID string
+	date   string
+}
+
+// peerUsageStore keeps the daily traffic of the peers of the accounts in memory. The peers report the totals of the
+// days so far, a report replaces the previous one of the same day
+type peerUsageStore struct {
+	mu       sync.Mutex
+	accounts map[string]map[peerUsageKey]*PeerDailyUsage
+}
+
+func (s *peerUsageStore) save(accountID string, usage []*PeerDailyUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accounts == nil {
+		s.accounts = make(map[string]map[peerUsageKey]*PeerDailyUsage)
+	}
+	accountUsage, ok := s.accounts[accountID]
+	if !ok {
+		accountUsage = make(map[peerUsageKey]*PeerDailyUsage)
+		s.accounts[accountID] = accountUsage
+	}
+
+	for _, day := range usage {
+		accountUsage[peerUsageKey{peerID: day.PeerID, date: day.Date}] = day
+	}
+}
+
+// get returns the usage of the account of the days since the given date, removing the older ones
+func (s *peerUsageStore) get(accountID string, since string) []*PeerDailyUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var usage []*PeerDailyUsage
+	for key, day := range s.accounts[accountID] {
+		if day.Date < since {
+			delete(s.accounts[accountID], key)
+			continue
+		}
+		dayCopy := *day
+		usage = append(usage, &dayCopy)
+	}
+	return usage
+}
+
+func (s *peerUsageStore) deleteAccount(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.accounts, accountID)
+}
+
+// ReportPeerUsage stores the daily traffic reported by the peer with the given WireGuard public key. The reports are
+// rejected unless the account enables them, the days outside the kept period are ignored.
+func (am *DefaultAccountManager) ReportPeerUsage(peerPubKey string, days []PeerDailyUsage) error {
+	if len(days) > maxUsageReportDays {
+		return status.Errorf(status.InvalidArgument, "too many days, the maximum is %d", maxUsageReportDays)
+	}
+
+	account, err := am.Store.GetAccountByPeerPubKey(peerPubKey)
+	if err != nil {
+		if errStatus, ok := status.FromError(err); ok && errStatus.Type() == status.NotFound {
+			return status.Errorf(status.Unauthenticated, "peer is not registered")
+		}
+		return err
+	}
+
+	peer, err := account.FindPeerByPubKey(peerPubKey)
+	if err != nil {
+		return status.Errorf(status.Unauthenticated, "peer is not registered")
+	}
+
+	if !account.Settings.PeerUsageReportsEnabled {
+		return status.Errorf(status.PermissionDenied, "peer usage reports are disabled for the account")
+	}
+
+	now := time.Now().UTC()
+	oldest := now.AddDate(0, 0, -peerUsageMaxDays)
+	// the clock of the peer may be slightly ahead
+	newest := now.AddDate(0, 0, 1)
+
+	usage := make([]*PeerDailyUsage, 0, len(days))
+	for _, day := range days {
+		date, err := time.Parse(usageDateLayout, day.Date)
+		if err != nil {
+			return status.Errorf(status.InvalidArgument, "invalid date %q, the format is YYYY-MM-DD", day.Date)
+		}
+		if date.Before(oldest) || date.After(newest) {
+			continue
+		}
+		usage = append(usage, &PeerDailyUsage{
+			PeerID:     peer.ID,
+			Date:       day.Date,
+			RxBytes:    day.RxBytes,
+			TxBytes:    day.TxBytes,
+			ReportedAt: now,
+		})
+	}
+
+	am.peerUsage.save(account.Id, usage)
+
+	return nil
+}
+
+// GetPeerUsage returns the daily traffic reported by the peers of the account during the last days, up to a month.
+// days <= 0 returns all the kept days
+func (am *DefaultAccountManager) GetPeerUsage(accountID, userID string, days int) ([]*PeerDailyUsage, error) {
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view peer usage")
+	}
+
+	now := time.Now().UTC()
+	removeBefore := now.AddDate(0, 0, -peerUsageMaxDays).Format(usageDateLayout)
+	since := removeBefore
+	if days > 0 && days <= peerUsageMaxDays {
+		since = now.AddDate(0, 0, 1-days).Format(usageDateLayout)
+	}
+
+	usage := make([]*PeerDailyUsage, 0)
+	for _, day := range am.peerUsage.get(accountID, removeBefore) {
+		// the peers might have been deleted since the report
+		if day.Date < since || account.GetPeer(day.PeerID) == nil {
+			continue
+		}
+		usage = append(usage, day)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Date != usage[j].Date {
+			return usage[i].Date < usage[j].Date
+		}
+		return usage[i].PeerID < usage[j].PeerID
+	})
+
+	return usage, nil
+}
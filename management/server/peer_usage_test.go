@@ -0,0 +1,77 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportPeerUsage(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	today := time.Now().UTC().Format(usageDateLayout)
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(usageDateLayout)
+	days := []PeerDailyUsage{
+		{Date: yesterday, RxBytes: 100, TxBytes: 50},
+		{Date: today, RxBytes: 10, TxBytes: 5},
+		{Date: "2000-01-01", RxBytes: 1, TxBytes: 1},
+	}
+
+	err = am.ReportPeerUsage(peer1Key, days)
+	require.Error(t, err, "should fail when peer usage reports are disabled")
+
+	account.Settings.PeerUsageReportsEnabled = true
+	err = am.Store.SaveAccount(account)
+	require.NoError(t, err, "failed to save account")
+
+	err = am.ReportPeerUsage(peer1Key, days)
+	require.NoError(t, err)
+	err = am.ReportPeerUsage(peer2Key, []PeerDailyUsage{{Date: today, RxBytes: 7, TxBytes: 3}})
+	require.NoError(t, err)
+
+	usage, err := am.GetPeerUsage(account.Id, userID, 0)
+	require.NoError(t, err)
+	require.Len(t, usage, 3, "the days outside the kept period should be ignored")
+	assert.Equal(t, yesterday, usage[0].Date)
+	assert.Equal(t, peer1ID, usage[0].PeerID)
+	assert.Equal(t, today, usage[1].Date)
+	assert.Equal(t, peer1ID, usage[1].PeerID)
+	assert.Equal(t, peer2ID, usage[2].PeerID)
+
+	err = am.ReportPeerUsage(peer1Key, []PeerDailyUsage{{Date: today, RxBytes: 20, TxBytes: 8}})
+	require.NoError(t, err)
+
+	usage, err = am.GetPeerUsage(account.Id, userID, 1)
+	require.NoError(t, err)
+	require.Len(t, usage, 2, "only the days requested should be returned")
+	assert.Equal(t, uint64(20), usage[0].RxBytes, "the latest report of a day should replace the previous one")
+	assert.Equal(t, uint64(8), usage[0].TxBytes)
+
+	err = am.ReportPeerUsage(peer1Key, []PeerDailyUsage{{Date: "yesterday"}})
+	require.Error(t, err, "should fail with an invalid date")
+
+	err = am.ReportPeerUsage(peer1Key, make([]PeerDailyUsage, maxUsageReportDays+1))
+	require.Error(t, err, "should fail with too many days")
+}
+
+func TestPeerUsageStore_ExpiresDays(t *testing.T) {
+	store := &peerUsageStore{}
+	store.save("account", []*PeerDailyUsage{
+		{PeerID: "a", Date: "2023-01-01"},
+		{PeerID: "a", Date: "2023-02-01"},
+	})
+
+	usage := store.get("account", "2023-01-15")
+	require.Len(t, usage, 1)
+	assert.Equal(t, "2023-02-01", usage[0].Date)
+	assert.Len(t, store.accounts["account"], 1, "expired days should be removed")
+
+	store.deleteAccount("account")
+	assert.Empty(t, store.get("account", ""))
+}
@@ -2,11 +2,14 @@ package server
 
 import (
 	_ "embed"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/netbirdio/management-integrations/additions"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
 	"github.com/FlintyLemming/netbird/management/proto"
 	"github.com/FlintyLemming/netbird/management/server/activity"
@@ -42,6 +45,10 @@ const (
 	PolicyRuleProtocolUDP = PolicyRuleProtocolType("udp")
 	// PolicyRuleProtocolICMP type of traffic
 	PolicyRuleProtocolICMP = PolicyRuleProtocolType("icmp")
+	// PolicyRuleProtocolSCTP type of traffic
+	PolicyRuleProtocolSCTP = PolicyRuleProtocolType("sctp")
+	// PolicyRuleProtocolGRE type of traffic, it has no ports
+	PolicyRuleProtocolGRE = PolicyRuleProtocolType("gre")
 )
 
 const (
@@ -96,6 +103,20 @@ type PolicyRule struct {
 
 	// Ports or it ranges list
 	Ports []string `gorm:"serializer:json"`
+
+	// Log indicates that the peers log the traffic matched by the rule, with rate limiting
+	Log bool
+
+	// SourcePeerTypes limits the peers of the source groups to the peers of these types, all the peers when empty
+	SourcePeerTypes []nbpeer.PeerType `gorm:"serializer:json"`
+
+	// DestinationPeerTypes limits the peers of the destination groups to the peers of these types, all the peers
+	// when empty
+	DestinationPeerTypes []nbpeer.PeerType `gorm:"serializer:json"`
+
+	// Services are the IDs of the services whose protocols and ports the rule matches instead of its protocol and
+	// ports
+	Services []string `gorm:"serializer:json"`
 }
 
 // Copy returns a copy of a policy rule
@@ -111,10 +132,20 @@ func (pm *PolicyRule) Copy() *PolicyRule {
 		Bidirectional: pm.Bidirectional,
 		Protocol:      pm.Protocol,
 		Ports:         make([]string, len(pm.Ports)),
+		Log:           pm.Log,
 	}
 	copy(rule.Destinations, pm.Destinations)
 	copy(rule.Sources, pm.Sources)
 	copy(rule.Ports, pm.Ports)
+	if len(pm.SourcePeerTypes) > 0 {
+		rule.SourcePeerTypes = slices.Clone(pm.SourcePeerTypes)
+	}
+	if len(pm.DestinationPeerTypes) > 0 {
+		rule.DestinationPeerTypes = slices.Clone(pm.DestinationPeerTypes)
+	}
+	if len(pm.Services) > 0 {
+		rule.Services = slices.Clone(pm.Services)
+	}
 	return rule
 }
 
@@ -150,6 +181,10 @@ type Policy struct {
 
 	// Rules of the policy
 	Rules []*PolicyRule `gorm:"foreignKey:PolicyID;references:id"`
+
+	// ExternalID is an identifier set by an external tool managing the policy, e.g. a Terraform provider. It is unique
+	// among the policies of the account
+	ExternalID string `gorm:"index"`
 }
 
 // Copy returns a copy of the policy.
@@ -160,6 +195,7 @@ func (p *Policy) Copy() *Policy {
 		Description: p.Description,
 		Enabled:     p.Enabled,
 		Rules:       make([]*PolicyRule, len(p.Rules)),
+		ExternalID:  p.ExternalID,
 	}
 	for i, r := range p.Rules {
 		c.Rules[i] = r.Copy()
@@ -202,6 +238,14 @@ type FirewallRule struct {
 
 	// Port of the traffic
 	Port string
+
+	// Log indicates that the peer logs the traffic matched by the rule
+	Log bool
+}
+
+// IsOutgoing indicates that the rule applies to the traffic sent by the peer to the remote one
+func (r *FirewallRule) IsOutgoing() bool {
+	return r.Direction == firewallRuleDirectionOUT
 }
 
 // getPeerConnectionResources for a given peer
@@ -219,8 +263,8 @@ func (a *Account) getPeerConnectionResources(peerID string) ([]*nbpeer.Peer, []*
 				continue
 			}
 
-			sourcePeers, peerInSources := getAllPeersFromGroups(a, rule.Sources, peerID)
-			destinationPeers, peerInDestinations := getAllPeersFromGroups(a, rule.Destinations, peerID)
+			sourcePeers, peerInSources := getAllPeersFromGroups(a, rule.Sources, rule.SourcePeerTypes, peerID)
+			destinationPeers, peerInDestinations := getAllPeersFromGroups(a, rule.Destinations, rule.DestinationPeerTypes, peerID)
 			sourcePeers = additions.ValidatePeers(sourcePeers)
 			destinationPeers = additions.ValidatePeers(destinationPeers)
 
@@ -265,6 +309,8 @@ func (a *Account) connResourcesGenerator() (func(*PolicyRule, []*nbpeer.Peer, in
 
 	return func(rule *PolicyRule, groupPeers []*nbpeer.Peer, direction int) {
 			isAll := (len(all.Peers) - 1) == len(groupPeers)
+			// the services of the rule are expanded into a firewall rule per protocol
+			protocols := a.ruleProtocols(rule)
 			for _, peer := range groupPeers {
 				if peer == nil {
 					continue
@@ -274,38 +320,124 @@ func (a *Account) connResourcesGenerator() (func(*PolicyRule, []*nbpeer.Peer, in
 					peersExists[peer.ID] = struct{}{}
 				}
 
-				fr := FirewallRule{
-					PeerIP:    peer.IP.String(),
-					Direction: direction,
-					Action:    string(rule.Action),
-					Protocol:  string(rule.Protocol),
-				}
-
-				if isAll {
-					fr.PeerIP = "0.0.0.0"
+				for _, protocol := range protocols {
+					fr := FirewallRule{
+						PeerIP:    peer.IP.String(),
+						Direction: direction,
+						Action:    string(rule.Action),
+						Protocol:  string(protocol.Protocol),
+						Log:       rule.Log,
+					}
+
+					if isAll {
+						fr.PeerIP = "0.0.0.0"
+					}
+
+					ruleID := (rule.ID + fr.PeerIP + strconv.Itoa(direction) +
+						fr.Protocol + fr.Action + strings.Join(protocol.Ports, ","))
+					if _, ok := rulesExists[ruleID]; ok {
+						continue
+					}
+					rulesExists[ruleID] = struct{}{}
+
+					if len(protocol.Ports) == 0 {
+						rules = append(rules, &fr)
+						continue
+					}
+
+					for _, port := range protocol.Ports {
+						pr := fr // clone rule and add set new port
+						pr.Port = port
+						rules = append(rules, &pr)
+					}
 				}
+			}
+		}, func() ([]*nbpeer.Peer, []*FirewallRule) {
+			return peers, rules
+		}
+}
 
-				ruleID := (rule.ID + fr.PeerIP + strconv.Itoa(direction) +
-					fr.Protocol + fr.Action + strings.Join(rule.Ports, ","))
-				if _, ok := rulesExists[ruleID]; ok {
-					continue
-				}
-				rulesExists[ruleID] = struct{}{}
+// mergePortRanges merges the rules of consecutive ports that only differ by their port into rules of port ranges
+// (e.g. 8000-8080), for the peers whose firewall accepts them. A merged rule takes the position of the first of its
+// rules, the rules without a port are kept as they are
+func mergePortRanges(rules []*FirewallRule) []*FirewallRule {
+	type ruleKey struct {
+		peerIP    string
+		direction int
+		action    string
+		protocol  string
+		log       bool
+	}
+	type portRange struct {
+		start, end int
+	}
 
-				if len(rule.Ports) == 0 {
-					rules = append(rules, &fr)
-					continue
-				}
+	portsByKey := make(map[ruleKey][]int)
+	for _, rule := range rules {
+		port, err := strconv.Atoi(rule.Port)
+		if err != nil {
+			continue
+		}
+		key := ruleKey{rule.PeerIP, rule.Direction, rule.Action, rule.Protocol, rule.Log}
+		portsByKey[key] = append(portsByKey[key], port)
+	}
 
-				for _, port := range rule.Ports {
-					pr := fr // clone rule and add set new port
-					pr.Port = port
-					rules = append(rules, &pr)
-				}
+	rangeOfPort := make(map[ruleKey]map[int]*portRange)
+	for key, ports := range portsByKey {
+		sort.Ints(ports)
+		ranges := make(map[int]*portRange, len(ports))
+		var current *portRange
+		for _, port := range ports {
+			if current == nil || port > current.end+1 {
+				current = &portRange{start: port, end: port}
 			}
-		}, func() ([]*nbpeer.Peer, []*FirewallRule) {
-			return peers, rules
+			if port > current.end {
+				current.end = port
+			}
+			ranges[port] = current
+		}
+		rangeOfPort[key] = ranges
+	}
+
+	merged := make([]*FirewallRule, 0, len(rules))
+	added := make(map[*portRange]struct{})
+	for _, rule := range rules {
+		port, err := strconv.Atoi(rule.Port)
+		if err != nil {
+			merged = append(merged, rule)
+			continue
+		}
+
+		r := rangeOfPort[ruleKey{rule.PeerIP, rule.Direction, rule.Action, rule.Protocol, rule.Log}][port]
+		if _, ok := added[r]; ok {
+			continue
 		}
+		added[r] = struct{}{}
+
+		if r.start == r.end {
+			merged = append(merged, rule)
+			continue
+		}
+		rangeRule := *rule
+		rangeRule.Port = fmt.Sprintf("%d-%d", r.start, r.end)
+		merged = append(merged, &rangeRule)
+	}
+
+	return merged
+}
+
+// withoutSCTPAndGRE removes the SCTP and GRE rules, for the peers whose firewall doesn't filter these protocols. The
+// traffic of these protocols stays dropped by default, only the rules of all the protocols apply to it
+func withoutSCTPAndGRE(rules []*FirewallRule) []*FirewallRule {
+	filtered := make([]*FirewallRule, 0, len(rules))
+	for _, rule := range rules {
+		switch PolicyRuleProtocolType(rule.Protocol) {
+		case PolicyRuleProtocolSCTP, PolicyRuleProtocolGRE:
+			continue
+		}
+		filtered = append(filtered, rule)
+	}
+	return filtered
 }
 
 // GetPolicy from the store
@@ -346,6 +478,18 @@ func (am *DefaultAccountManager) SavePolicy(accountID, userID string, policy *Po
 		return err
 	}
 
+	if err := account.validatePolicyExternalID(policy); err != nil {
+		return err
+	}
+
+	if err := validatePolicyPeerTypes(policy); err != nil {
+		return err
+	}
+
+	if err := account.validatePolicyServices(policy); err != nil {
+		return err
+	}
+
 	exists := am.savePolicy(account, policy)
 
 	account.Network.IncSerial()
@@ -466,6 +610,10 @@ func toProtocolFirewallRules(update []*FirewallRule) []*proto.FirewallRule {
 			protocol = proto.FirewallRule_UDP
 		case PolicyRuleProtocolICMP:
 			protocol = proto.FirewallRule_ICMP
+		case PolicyRuleProtocolSCTP:
+			protocol = proto.FirewallRule_SCTP
+		case PolicyRuleProtocolGRE:
+			protocol = proto.FirewallRule_GRE
 		}
 
 		result[i] = &proto.FirewallRule{
@@ -474,15 +622,28 @@ func toProtocolFirewallRules(update []*FirewallRule) []*proto.FirewallRule {
 			Action:    action,
 			Protocol:  protocol,
 			Port:      update[i].Port,
+			Log:       update[i].Log,
 		}
 	}
 	return result
 }
 
-// getAllPeersFromGroups for given peer ID and list of groups
+// validatePolicyPeerTypes checks that the rules of the policy select known peer types
+func validatePolicyPeerTypes(policy *Policy) error {
+	for _, rule := range policy.Rules {
+		for _, peerType := range append(slices.Clone(rule.SourcePeerTypes), rule.DestinationPeerTypes...) {
+			if !peerType.IsValid() {
+				return status.Errorf(status.InvalidArgument, "unknown peer type %s in policy rule %s", peerType, rule.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// getAllPeersFromGroups for given peer ID and list of groups, limited to the peers of the peer types when not empty
 //
 // Returns list of peers and boolean indicating if peer is in any of the groups
-func getAllPeersFromGroups(account *Account, groups []string, peerID string) ([]*nbpeer.Peer, bool) {
+func getAllPeersFromGroups(account *Account, groups []string, peerTypes []nbpeer.PeerType, peerID string) ([]*nbpeer.Peer, bool) {
 	peerInGroups := false
 	filteredPeers := make([]*nbpeer.Peer, 0, len(groups))
 	for _, g := range groups {
@@ -497,6 +658,10 @@ func getAllPeersFromGroups(account *Account, groups []string, peerID string) ([]
 				continue
 			}
 
+			if len(peerTypes) > 0 && !slices.Contains(peerTypes, peer.GetType()) {
+				continue
+			}
+
 			if peer.ID == peerID {
 				peerInGroups = true
 				continue
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/FlintyLemming/netbird/acl"
+	"github.com/FlintyLemming/netbird/management/proto"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// PolicySimulationQuery is the traffic from a peer to another one whose verdict is simulated
+type PolicySimulationQuery struct {
+	SourcePeerID      string
+	DestinationPeerID string
+	// Protocol is the protocol of the traffic, one of tcp, udp or icmp
+	Protocol PolicyRuleProtocolType
+	// Port is the destination port of the traffic, ignored for icmp
+	Port int
+}
+
+// PolicySimulationMatch is a policy rule applying to the simulated traffic
+type PolicySimulationMatch struct {
+	PolicyID   string
+	PolicyName string
+	RuleID     string
+	RuleName   string
+	Action     PolicyTrafficActionType
+}
+
+// PolicySimulation is the verdict of the simulated traffic
+type PolicySimulation struct {
+	// Allowed is true when the traffic is accepted by the firewall of both peers
+	Allowed bool
+	// SourceRule is the firewall rule of the source peer deciding the outgoing traffic, nil when dropped by default
+	SourceRule *FirewallRule
+	// DestinationRule is the firewall rule of the destination peer deciding the incoming traffic, nil when dropped
+	// by default
+	DestinationRule *FirewallRule
+	// Matches are the enabled policy rules applying to the traffic in the order of the policies
+	Matches []PolicySimulationMatch
+}
+
+// SimulatePolicy evaluates the traffic of the query against the firewall rules sent to the source and the destination
+// peers, the same way the peers do when applying them
+func (am *DefaultAccountManager) SimulatePolicy(accountID, userID string, query PolicySimulationQuery) (*PolicySimulation, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power are allowed to simulate policies")
+	}
+
+	var protocol proto.FirewallRuleProtocol
+	switch query.Protocol {
+	case PolicyRuleProtocolTCP:
+		protocol = proto.FirewallRule_TCP
+	case PolicyRuleProtocolUDP:
+		protocol = proto.FirewallRule_UDP
+	case PolicyRuleProtocolSCTP:
+		protocol = proto.FirewallRule_SCTP
+	case PolicyRuleProtocolICMP:
+		protocol = proto.FirewallRule_ICMP
+		query.Port = 0
+	case PolicyRuleProtocolGRE:
+		protocol = proto.FirewallRule_GRE
+		query.Port = 0
+	default:
+		return nil, status.Errorf(status.InvalidArgument, "invalid protocol %s, expected tcp, udp, sctp, icmp or gre", query.Protocol)
+	}
+
+	if protocol != proto.FirewallRule_ICMP && protocol != proto.FirewallRule_GRE && (query.Port < 1 || query.Port > 65535) {
+		return nil, status.Errorf(status.InvalidArgument, "invalid port %d", query.Port)
+	}
+
+	source := account.GetPeer(query.SourcePeerID)
+	if source == nil {
+		return nil, status.Errorf(status.NotFound, "peer with %s not found under account %s", query.SourcePeerID, accountID)
+	}
+
+	destination := account.GetPeer(query.DestinationPeerID)
+	if destination == nil {
+		return nil, status.Errorf(status.NotFound, "peer with %s not found under account %s", query.DestinationPeerID, accountID)
+	}
+
+	sourceRule, err := am.simulatePeerFirewall(account, query.SourcePeerID, acl.Packet{
+		PeerIP:    destination.IP,
+		Direction: proto.FirewallRule_OUT,
+		Protocol:  protocol,
+		DstPort:   query.Port,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	destinationRule, err := am.simulatePeerFirewall(account, query.DestinationPeerID, acl.Packet{
+		PeerIP:    source.IP,
+		Direction: proto.FirewallRule_IN,
+		Protocol:  protocol,
+		DstPort:   query.Port,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicySimulation{
+		Allowed:         isAccepted(sourceRule) && isAccepted(destinationRule),
+		SourceRule:      sourceRule,
+		DestinationRule: destinationRule,
+		Matches:         account.matchPolicyRules(query),
+	}, nil
+}
+
+// simulatePeerFirewall returns the firewall rule of the peer deciding the packet, nil if the packet is dropped by
+// default
+func (am *DefaultAccountManager) simulatePeerFirewall(account *Account, peerID string, packet acl.Packet) (*FirewallRule, error) {
+	peer := account.GetPeer(peerID)
+	networkMap := toSyncResponse(nil, peer, nil, account.GetPeerNetworkMap(peerID, am.dnsDomain), am.dnsDomain).NetworkMap
+
+	var rules []acl.Rule
+	for _, rule := range acl.NetworkMapRules(networkMap) {
+		expanded, err := acl.ExpandRule(rule)
+		if err != nil {
+			return nil, status.Errorf(status.Internal, "invalid firewall rule of peer %s: %v", peerID, err)
+		}
+		rules = append(rules, expanded...)
+	}
+
+	matched := acl.Match(rules, packet)
+	if matched == nil {
+		return nil, nil
+	}
+	return fromProtocolFirewallRule(matched.Origin), nil
+}
+
+// matchPolicyRules returns the enabled policy rules applying to the traffic of the query
+func (a *Account) matchPolicyRules(query PolicySimulationQuery) []PolicySimulationMatch {
+	matches := make([]PolicySimulationMatch, 0)
+	for _, policy := range a.Policies {
+		if !policy.Enabled {
+			continue
+		}
+
+		for _, rule := range policy.Rules {
+			if !rule.Enabled || !a.ruleMatchesTraffic(rule, query.Protocol, query.Port) {
+				continue
+			}
+
+			_, sourceInSources := getAllPeersFromGroups(a, rule.Sources, rule.SourcePeerTypes, query.SourcePeerID)
+			_, destinationInDestinations := getAllPeersFromGroups(a, rule.Destinations, rule.DestinationPeerTypes, query.DestinationPeerID)
+			matched := sourceInSources && destinationInDestinations
+			if !matched && rule.Bidirectional {
+				_, sourceInDestinations := getAllPeersFromGroups(a, rule.Destinations, rule.DestinationPeerTypes, query.SourcePeerID)
+				_, destinationInSources := getAllPeersFromGroups(a, rule.Sources, rule.SourcePeerTypes, query.DestinationPeerID)
+				matched = sourceInDestinations && destinationInSources
+			}
+			if !matched {
+				continue
+			}
+
+			matches = append(matches, PolicySimulationMatch{
+				PolicyID:   policy.ID,
+				PolicyName: policy.Name,
+				RuleID:     rule.ID,
+				RuleName:   rule.Name,
+				Action:     rule.Action,
+			})
+		}
+	}
+	return matches
+}
+
+// ruleMatchesTraffic returns true when the protocol and the ports of the rule, or of one of its services, cover the
+// traffic
+func (a *Account) ruleMatchesTraffic(rule *PolicyRule, protocol PolicyRuleProtocolType, port int) bool {
+	for _, p := range a.ruleProtocols(rule) {
+		if p.matchesTraffic(protocol, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAccepted(rule *FirewallRule) bool {
+	return rule != nil && rule.Action == string(PolicyTrafficActionAccept)
+}
+
+// fromProtocolFirewallRule converts the firewall rule sent to the peers back, it is the reverse of
+// toProtocolFirewallRules
+func fromProtocolFirewallRule(rule *proto.FirewallRule) *FirewallRule {
+	direction := firewallRuleDirectionIN
+	if rule.Direction == proto.FirewallRule_OUT {
+		direction = firewallRuleDirectionOUT
+	}
+	action := PolicyTrafficActionAccept
+	if rule.Action == proto.FirewallRule_DROP {
+		action = PolicyTrafficActionDrop
+	}
+
+	return &FirewallRule{
+		PeerIP:    rule.PeerIP,
+		Direction: direction,
+		Action:    string(action),
+		Protocol:  strings.ToLower(rule.Protocol.String()),
+		Port:      rule.Port,
+		Log:       rule.Log,
+	}
+}
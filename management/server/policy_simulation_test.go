@@ -0,0 +1,127 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+)
+
+func TestDefaultAccountManager_SimulatePolicy(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err)
+
+	accountID := "test_account"
+	adminUser := "account_creator"
+	someUser := "some_user"
+	account := newAccountWithId(accountID, adminUser, "")
+	account.Users[someUser] = &User{
+		Id:   someUser,
+		Role: UserRoleUser,
+	}
+	err = manager.Store.SaveAccount(account)
+	require.NoError(t, err)
+
+	setupKey, err := manager.CreateSetupKey(account.Id, "test-key", SetupKeyReusable, time.Hour, nil, 999, adminUser, false, "", "")
+	require.NoError(t, err)
+
+	var peers []*nbpeer.Peer
+	for _, hostname := range []string{"test-peer-1", "test-peer-2", "test-peer-3"} {
+		key, err := wgtypes.GeneratePrivateKey()
+		require.NoError(t, err)
+		peer, _, err := manager.AddPeer(setupKey.Key, "", &nbpeer.Peer{
+			Key:  key.PublicKey().String(),
+			Meta: nbpeer.PeerSystemMeta{Hostname: hostname},
+		})
+		require.NoError(t, err)
+		peers = append(peers, peer)
+	}
+
+	query := PolicySimulationQuery{
+		SourcePeerID:      peers[0].ID,
+		DestinationPeerID: peers[1].ID,
+		Protocol:          PolicyRuleProtocolTCP,
+		Port:              80,
+	}
+
+	simulation, err := manager.SimulatePolicy(accountID, adminUser, query)
+	require.NoError(t, err)
+	assert.True(t, simulation.Allowed, "the default all-to-all policy should allow the traffic")
+	require.NotNil(t, simulation.SourceRule)
+	assert.True(t, simulation.SourceRule.IsOutgoing())
+	require.NotNil(t, simulation.DestinationRule)
+	assert.False(t, simulation.DestinationRule.IsOutgoing())
+	require.Len(t, simulation.Matches, 1)
+	assert.Equal(t, PolicyTrafficActionAccept, simulation.Matches[0].Action)
+
+	account, err = manager.Store.GetAccount(accountID)
+	require.NoError(t, err)
+	for _, policy := range account.Policies {
+		require.NoError(t, manager.DeletePolicy(accountID, policy.ID, adminUser))
+	}
+	require.NoError(t, manager.SaveGroup(accountID, adminUser, &Group{ID: "clients", Name: "clients", Peers: []string{peers[0].ID}}))
+	require.NoError(t, manager.SaveGroup(accountID, adminUser, &Group{ID: "servers", Name: "servers", Peers: []string{peers[1].ID, peers[2].ID}}))
+	require.NoError(t, manager.SavePolicy(accountID, adminUser, &Policy{
+		ID:      "ssh",
+		Name:    "ssh",
+		Enabled: true,
+		Rules: []*PolicyRule{{
+			ID:           "ssh",
+			Name:         "ssh",
+			Enabled:      true,
+			Sources:      []string{"clients"},
+			Destinations: []string{"servers"},
+			Action:       PolicyTrafficActionAccept,
+			Protocol:     PolicyRuleProtocolTCP,
+			Ports:        []string{"22"},
+		}},
+	}))
+
+	query.Port = 22
+	simulation, err = manager.SimulatePolicy(accountID, adminUser, query)
+	require.NoError(t, err)
+	assert.True(t, simulation.Allowed)
+	require.NotNil(t, simulation.DestinationRule)
+	assert.Equal(t, peers[0].IP.String(), simulation.DestinationRule.PeerIP)
+	assert.Equal(t, "22", simulation.DestinationRule.Port)
+	require.Len(t, simulation.Matches, 1)
+	assert.Equal(t, "ssh", simulation.Matches[0].PolicyID)
+
+	query.Port = 80
+	simulation, err = manager.SimulatePolicy(accountID, adminUser, query)
+	require.NoError(t, err)
+	assert.False(t, simulation.Allowed, "the traffic to other ports should be dropped")
+	assert.Nil(t, simulation.DestinationRule)
+	assert.Empty(t, simulation.Matches)
+
+	simulation, err = manager.SimulatePolicy(accountID, adminUser, PolicySimulationQuery{
+		SourcePeerID:      peers[1].ID,
+		DestinationPeerID: peers[0].ID,
+		Protocol:          PolicyRuleProtocolTCP,
+		Port:              22,
+	})
+	require.NoError(t, err)
+	assert.False(t, simulation.Allowed, "the policy isn't bidirectional")
+	assert.Nil(t, simulation.SourceRule)
+
+	_, err = manager.SimulatePolicy(accountID, someUser, query)
+	assert.Error(t, err, "regular users shouldn't simulate policies")
+
+	_, err = manager.SimulatePolicy(accountID, adminUser, PolicySimulationQuery{
+		SourcePeerID:      peers[0].ID,
+		DestinationPeerID: peers[1].ID,
+		Protocol:          PolicyRuleProtocolALL,
+	})
+	assert.Error(t, err, "should fail for a protocol other than tcp, udp or icmp")
+
+	_, err = manager.SimulatePolicy(accountID, adminUser, PolicySimulationQuery{
+		SourcePeerID:      "unknown",
+		DestinationPeerID: peers[1].ID,
+		Protocol:          PolicyRuleProtocolICMP,
+	})
+	assert.Error(t, err, "should fail for an unknown peer")
+}
@@ -448,3 +448,128 @@ func sortFunc() func(a *FirewallRule, b *FirewallRule) bool {
 		return a.PeerIP+fmt.Sprintf("%d", a.Direction) < b.PeerIP+fmt.Sprintf("%d", b.Direction)
 	}
 }
+
+func TestMergePortRanges(t *testing.T) {
+	rule := func(peerIP, action, port string) *FirewallRule {
+		return &FirewallRule{
+			PeerIP:    peerIP,
+			Direction: firewallRuleDirectionIN,
+			Action:    action,
+			Protocol:  "tcp",
+			Port:      port,
+		}
+	}
+
+	rules := []*FirewallRule{
+		rule("100.65.0.1", "accept", "8001"),
+		rule("100.65.0.1", "accept", "8000"),
+		{PeerIP: "100.65.0.1", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "all"},
+		rule("100.65.0.1", "accept", "8002"),
+		rule("100.65.0.1", "accept", "8002"),
+		rule("100.65.0.1", "accept", "9000"),
+		rule("100.65.0.1", "drop", "8003"),
+		rule("100.65.0.2", "accept", "8003"),
+		rule("100.65.0.2", "accept", "8004"),
+	}
+
+	merged := mergePortRanges(rules)
+
+	expected := []*FirewallRule{
+		rule("100.65.0.1", "accept", "8000-8002"),
+		{PeerIP: "100.65.0.1", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "all"},
+		rule("100.65.0.1", "accept", "9000"),
+		rule("100.65.0.1", "drop", "8003"),
+		rule("100.65.0.2", "accept", "8003-8004"),
+	}
+	assert.Equal(t, expected, merged, "consecutive ports of the same peer and action should be merged")
+	assert.Equal(t, "8001", rules[0].Port, "the original rules should not be modified")
+}
+
+func TestMergePortRanges_Log(t *testing.T) {
+	rule := func(port string, logged bool) *FirewallRule {
+		return &FirewallRule{
+			PeerIP:    "100.65.0.1",
+			Direction: firewallRuleDirectionIN,
+			Action:    "drop",
+			Protocol:  "tcp",
+			Port:      port,
+			Log:       logged,
+		}
+	}
+
+	merged := mergePortRanges([]*FirewallRule{
+		rule("8000", true),
+		rule("8001", false),
+		rule("8002", true),
+		rule("8001", true),
+	})
+
+	expected := []*FirewallRule{
+		rule("8000-8002", true),
+		rule("8001", false),
+	}
+	assert.Equal(t, expected, merged, "the logged ports should not be merged with the ports which aren't logged")
+
+	protoRules := toProtocolFirewallRules(merged)
+	assert.True(t, protoRules[0].Log, "the logged rule should be logged by the peer")
+	assert.False(t, protoRules[1].Log)
+}
+
+func TestWithoutSCTPAndGRE(t *testing.T) {
+	rule := func(protocol PolicyRuleProtocolType, port string) *FirewallRule {
+		return &FirewallRule{
+			PeerIP:    "100.65.0.1",
+			Direction: firewallRuleDirectionIN,
+			Action:    "accept",
+			Protocol:  string(protocol),
+			Port:      port,
+		}
+	}
+
+	rules := []*FirewallRule{
+		rule(PolicyRuleProtocolTCP, "22"),
+		rule(PolicyRuleProtocolSCTP, "3868"),
+		rule(PolicyRuleProtocolGRE, ""),
+		rule(PolicyRuleProtocolALL, ""),
+	}
+
+	expected := []*FirewallRule{
+		rule(PolicyRuleProtocolTCP, "22"),
+		rule(PolicyRuleProtocolALL, ""),
+	}
+	assert.Equal(t, expected, withoutSCTPAndGRE(rules), "the SCTP and GRE rules should be removed")
+}
+
+func TestGetAllPeersFromGroups_PeerTypes(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"laptop":  {ID: "laptop", UserID: "user1"},
+			"server":  {ID: "server", Type: nbpeer.PeerTypeService},
+			"desktop": {ID: "desktop", Type: nbpeer.PeerTypeUserDevice},
+			"legacy":  {ID: "legacy"},
+		},
+		Groups: map[string]*Group{
+			"all": {ID: "all", Peers: []string{"laptop", "server", "desktop", "legacy"}},
+		},
+	}
+
+	peerIDs := func(peers []*nbpeer.Peer) []string {
+		ids := make([]string, 0, len(peers))
+		for _, peer := range peers {
+			ids = append(ids, peer.ID)
+		}
+		return ids
+	}
+
+	peers, inGroups := getAllPeersFromGroups(account, []string{"all"}, nil, "laptop")
+	assert.True(t, inGroups)
+	assert.ElementsMatch(t, []string{"server", "desktop", "legacy"}, peerIDs(peers), "all the peers should be selected without types")
+
+	peers, inGroups = getAllPeersFromGroups(account, []string{"all"}, []nbpeer.PeerType{nbpeer.PeerTypeService}, "laptop")
+	assert.False(t, inGroups, "the user device should not be selected by a services rule")
+	assert.ElementsMatch(t, []string{"server", "legacy"}, peerIDs(peers), "the legacy peer added with a setup key should be a service")
+
+	peers, inGroups = getAllPeersFromGroups(account, []string{"all"}, []nbpeer.PeerType{nbpeer.PeerTypeUserDevice}, "laptop")
+	assert.True(t, inGroups)
+	assert.ElementsMatch(t, []string{"desktop"}, peerIDs(peers))
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net/netip"
 	"unicode/utf8"
 
@@ -114,7 +115,7 @@ func (am *DefaultAccountManager) checkRoutePrefixExistsForPeers(account *Account
 }
 
 // CreateRoute creates and saves a new route
-func (am *DefaultAccountManager) CreateRoute(accountID, network, peerID string, peerGroupIDs []string, description, netID string, masquerade bool, metric int, groups []string, enabled bool, userID string) (*route.Route, error) {
+func (am *DefaultAccountManager) CreateRoute(accountID, network, peerID string, peerGroupIDs []string, description, netID string, masquerade bool, metric int, disablePreemption, loadBalance bool, groups []string, enabled bool, userID, externalID string) (*route.Route, error) {
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
 
@@ -171,8 +172,15 @@ func (am *DefaultAccountManager) CreateRoute(accountID, network, peerID string,
 	newRoute.NetID = netID
 	newRoute.Masquerade = masquerade
 	newRoute.Metric = metric
+	newRoute.DisablePreemption = disablePreemption
+	newRoute.LoadBalance = loadBalance
 	newRoute.Enabled = enabled
 	newRoute.Groups = groups
+	newRoute.ExternalID = externalID
+
+	if err := account.validateRouteExternalID(&newRoute); err != nil {
+		return nil, err
+	}
 
 	if account.Routes == nil {
 		account.Routes = make(map[string]*route.Route)
@@ -201,6 +209,252 @@ func (am *DefaultAccountManager) SaveRoute(accountID, userID string, routeToSave
 		return status.Errorf(status.InvalidArgument, "route provided is nil")
 	}
 
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = am.validateRoute(account, routeToSave)
+	if err != nil {
+		return err
+	}
+
+	account.Routes[routeToSave.ID] = routeToSave
+
+	account.Network.IncSerial()
+	if err = am.Store.SaveAccount(account); err != nil {
+		return err
+	}
+
+	am.updateAccountPeers(account)
+
+	am.StoreEvent(userID, routeToSave.ID, accountID, activity.RouteUpdated, routeToSave.EventMeta())
+
+	return nil
+}
+
+// SaveRoutes creates or updates multiple routes at once. Routes without an ID are created, the rest must exist.
+// Besides the validation of SaveRoute, a route is rejected if its prefix overlaps a prefix routed by the same peer.
+// Either all routes are saved or none of them.
+func (am *DefaultAccountManager) SaveRoutes(accountID, userID string, routesToSave []*route.Route) ([]*route.Route, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	if len(routesToSave) == 0 {
+		return nil, status.Errorf(status.InvalidArgument, "no routes provided")
+	}
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can import Network Routes")
+	}
+
+	if account.Routes == nil {
+		account.Routes = make(map[string]*route.Route)
+	}
+
+	events := make([]activity.Activity, 0, len(routesToSave))
+	for i, routeToSave := range routesToSave {
+		if routeToSave == nil {
+			return nil, status.Errorf(status.InvalidArgument, "route %d: route provided is nil", i)
+		}
+
+		event := activity.RouteUpdated
+		if routeToSave.ID == "" {
+			routeToSave.ID = xid.New().String()
+			event = activity.RouteCreated
+		} else if _, ok := account.Routes[routeToSave.ID]; !ok {
+			return nil, status.Errorf(status.NotFound, "route %d: route with ID %s doesn't exist", i, routeToSave.ID)
+		}
+
+		// routes saved earlier in the list are already part of the account and validated against
+		err = am.validateRoute(account, routeToSave)
+		if err == nil {
+			err = checkRoutePrefixOverlaps(account, routeToSave)
+		}
+		if err != nil {
+			if sErr, ok := status.FromError(err); ok {
+				return nil, status.Errorf(sErr.Type(), "route %d: %s", i, sErr.Message)
+			}
+			return nil, err
+		}
+
+		account.Routes[routeToSave.ID] = routeToSave
+		events = append(events, event)
+	}
+
+	account.Network.IncSerial()
+	if err = am.Store.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	am.updateAccountPeers(account)
+
+	for i, savedRoute := range routesToSave {
+		am.StoreEvent(userID, savedRoute.ID, accountID, events[i], savedRoute.EventMeta())
+	}
+
+	return routesToSave, nil
+}
+
+// AdvertiseRoutes creates routes to the networks through the peer with the given WireGuard public key when the account
+// allows peers to advertise routes. The routes are created disabled when they require the approval of an admin.
+// Networks already routed by the peer return the existing route. The routes of the withdrawn networks are removed
+// when they are routed by the peer with the same netID. Either all changes are applied or none of them.
+func (am *DefaultAccountManager) AdvertiseRoutes(peerPubKey, netID string, networks, withdrawn []string) ([]*route.Route, error) {
+	if len(networks) == 0 && len(withdrawn) == 0 {
+		return nil, status.Errorf(status.InvalidArgument, "no networks provided")
+	}
+
+	account, err := am.Store.GetAccountByPeerPubKey(peerPubKey)
+	if err != nil {
+		if errStatus, ok := status.FromError(err); ok && errStatus.Type() == status.NotFound {
+			return nil, status.Errorf(status.Unauthenticated, "peer is not registered")
+		}
+		return nil, err
+	}
+
+	unlock := am.Store.AcquireAccountLock(account.Id)
+	defer unlock()
+
+	account, err = am.Store.GetAccount(account.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	peer, err := account.FindPeerByPubKey(peerPubKey)
+	if err != nil {
+		return nil, status.Errorf(status.Unauthenticated, "peer is not registered")
+	}
+
+	if !account.Settings.RouteAdvertisementEnabled {
+		return nil, status.Errorf(status.PermissionDenied, "route advertisement is disabled for the account")
+	}
+
+	if peer.Status.RequiresApproval || peerLoginExpired(peer, account) {
+		return nil, status.Errorf(status.PermissionDenied, "peer is not allowed to advertise routes until it is approved and logged in")
+	}
+
+	if netID == "" {
+		netID = peer.DNSLabel
+	}
+	if utf8.RuneCountInString(netID) > route.MaxNetIDChar {
+		netID = string([]rune(netID)[:route.MaxNetIDChar])
+	}
+
+	groups := account.Settings.RouteAdvertisementGroups
+	if len(groups) == 0 {
+		allGroup, err := account.GetGroupAll()
+		if err != nil {
+			return nil, err
+		}
+		groups = []string{allGroup.ID}
+	}
+
+	if account.Routes == nil {
+		account.Routes = make(map[string]*route.Route)
+	}
+
+	var removed []*route.Route
+	for i, network := range withdrawn {
+		_, prefix, err := route.ParseNetwork(network)
+		if err != nil {
+			return nil, status.Errorf(status.InvalidArgument, "withdrawn network %d: failed to parse %s", i, network)
+		}
+
+		// routes created by an admin for the same network are kept
+		existingRoute := getPeerRouteByPrefix(account, peer.ID, prefix)
+		if existingRoute == nil || existingRoute.NetID != netID {
+			continue
+		}
+		delete(account.Routes, existingRoute.ID)
+		removed = append(removed, existingRoute)
+	}
+
+	advertised := make([]*route.Route, 0, len(networks))
+	var created []*route.Route
+	for i, network := range networks {
+		prefixType, prefix, err := route.ParseNetwork(network)
+		if err != nil {
+			return nil, status.Errorf(status.InvalidArgument, "network %d: failed to parse %s", i, network)
+		}
+
+		if existingRoute := getPeerRouteByPrefix(account, peer.ID, prefix); existingRoute != nil {
+			advertised = append(advertised, existingRoute)
+			continue
+		}
+
+		newRoute := &route.Route{
+			ID:          xid.New().String(),
+			Network:     prefix,
+			NetworkType: prefixType,
+			NetID:       netID,
+			Description: fmt.Sprintf("Advertised by peer %s", peer.Name),
+			Peer:        peer.ID,
+			Masquerade:  true,
+			Metric:      route.MaxMetric,
+			Enabled:     !account.Settings.RouteAdvertisementApprovalRequired,
+			Groups:      append([]string{}, groups...),
+		}
+
+		err = am.validateRoute(account, newRoute)
+		if err == nil {
+			err = checkRoutePrefixOverlaps(account, newRoute)
+		}
+		if err != nil {
+			if sErr, ok := status.FromError(err); ok {
+				return nil, status.Errorf(sErr.Type(), "network %d: %s", i, sErr.Message)
+			}
+			return nil, err
+		}
+
+		account.Routes[newRoute.ID] = newRoute
+		advertised = append(advertised, newRoute)
+		created = append(created, newRoute)
+	}
+
+	if len(created) == 0 && len(removed) == 0 {
+		return advertised, nil
+	}
+
+	account.Network.IncSerial()
+	if err = am.Store.SaveAccount(account); err != nil {
+		return nil, err
+	}
+
+	am.updateAccountPeers(account)
+
+	for _, removedRoute := range removed {
+		am.StoreEvent(peer.ID, removedRoute.ID, account.Id, activity.RouteRemoved, removedRoute.EventMeta())
+	}
+	for _, createdRoute := range created {
+		am.StoreEvent(peer.ID, createdRoute.ID, account.Id, activity.RouteAdvertisedByPeer, createdRoute.EventMeta())
+	}
+
+	return advertised, nil
+}
+
+// getPeerRouteByPrefix returns the route of the prefix set directly on the peer or nil if there is none
+func getPeerRouteByPrefix(account *Account, peerID string, prefix netip.Prefix) *route.Route {
+	for _, r := range account.Routes {
+		if r.Peer == peerID && r.Network == prefix {
+			return r
+		}
+	}
+	return nil
+}
+
+// validateRoute checks that the route can be saved in the account
+func (am *DefaultAccountManager) validateRoute(account *Account, routeToSave *route.Route) error {
 	if !routeToSave.Network.IsValid() {
 		return status.Errorf(status.InvalidArgument, "invalid Prefix %s", routeToSave.Network.String())
 	}
@@ -213,46 +467,74 @@ func (am *DefaultAccountManager) SaveRoute(accountID, userID string, routeToSave
 		return status.Errorf(status.InvalidArgument, "identifier should be between 1 and %d", route.MaxNetIDChar)
 	}
 
-	account, err := am.Store.GetAccount(accountID)
-	if err != nil {
-		return err
-	}
-
 	if routeToSave.Peer != "" && len(routeToSave.PeerGroups) != 0 {
 		return status.Errorf(status.InvalidArgument, "peer with ID and peer groups should not be provided at the same time")
 	}
 
 	if len(routeToSave.PeerGroups) > 0 {
-		err = validateGroups(routeToSave.PeerGroups, account.Groups)
+		err := validateGroups(routeToSave.PeerGroups, account.Groups)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = am.checkRoutePrefixExistsForPeers(account, routeToSave.Peer, routeToSave.ID, routeToSave.Copy().PeerGroups, routeToSave.Network)
+	err := am.checkRoutePrefixExistsForPeers(account, routeToSave.Peer, routeToSave.ID, routeToSave.Copy().PeerGroups, routeToSave.Network)
 	if err != nil {
 		return err
 	}
 
-	err = validateGroups(routeToSave.Groups, account.Groups)
-	if err != nil {
+	if err := account.validateRouteExternalID(routeToSave); err != nil {
 		return err
 	}
 
-	account.Routes[routeToSave.ID] = routeToSave
+	return validateGroups(routeToSave.Groups, account.Groups)
+}
 
-	account.Network.IncSerial()
-	if err = am.Store.SaveAccount(account); err != nil {
-		return err
-	}
+// checkRoutePrefixOverlaps checks that none of the routing peers of the route already routes a prefix overlapping
+// the route's one
+func checkRoutePrefixOverlaps(account *Account, routeToCheck *route.Route) error {
+	routingPeers := getRoutingPeers(account, routeToCheck)
 
-	am.updateAccountPeers(account)
+	for _, existingRoute := range account.Routes {
+		if existingRoute.ID == routeToCheck.ID || !existingRoute.Network.Overlaps(routeToCheck.Network) {
+			continue
+		}
 
-	am.StoreEvent(userID, routeToSave.ID, accountID, activity.RouteUpdated, routeToSave.EventMeta())
+		for peerID := range getRoutingPeers(account, existingRoute) {
+			if _, ok := routingPeers[peerID]; !ok {
+				continue
+			}
+			peerName := peerID
+			if peer := account.GetPeer(peerID); peer != nil {
+				peerName = peer.Name
+			}
+			return status.Errorf(status.InvalidArgument,
+				"prefix %s overlaps prefix %s of route %s routed by the same peer %s",
+				routeToCheck.Network, existingRoute.Network, existingRoute.NetID, peerName)
+		}
+	}
 
 	return nil
 }
 
+// getRoutingPeers returns the IDs of the peers routing the route, either set directly or through peer groups
+func getRoutingPeers(account *Account, r *route.Route) map[string]struct{} {
+	peers := make(map[string]struct{})
+	if r.Peer != "" {
+		peers[r.Peer] = struct{}{}
+	}
+	for _, groupID := range r.PeerGroups {
+		group := account.GetGroup(groupID)
+		if group == nil {
+			continue
+		}
+		for _, peerID := range group.Peers {
+			peers[peerID] = struct{}{}
+		}
+	}
+	return peers
+}
+
 // DeleteRoute deletes route with routeID
 func (am *DefaultAccountManager) DeleteRoute(accountID, routeID, userID string) error {
 	unlock := am.Store.AcquireAccountLock(accountID)
@@ -317,6 +599,9 @@ func toProtocolRoute(route *route.Route) *proto.Route {
 		Peer:        route.Peer,
 		Metric:      int64(route.Metric),
 		Masquerade:  route.Masquerade,
+
+		DisablePreemption: route.DisablePreemption,
+		LoadBalance:       route.LoadBalance,
 	}
 }
 
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server/status"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+// maxPeerLANNetworks is the maximum number of LAN networks reported by a peer
+const maxPeerLANNetworks = 32
+
+// RouteSuggestion is a LAN network detected by a peer which isn't routed through the peer yet
+type RouteSuggestion struct {
+	PeerID   string
+	PeerName string
+	Network  netip.Prefix
+	NetID    string
+}
+
+// toPeerLANNetworks returns the valid LAN networks reported by a peer, masked and without duplicates.
+// Only private IPv4 networks are kept, the other ones are dropped.
+func toPeerLANNetworks(networks []string) []string {
+	var lanNetworks []string
+	added := make(lookupMap)
+	for _, network := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			log.Warnf("ignoring the LAN network %q reported by a peer: %v", network, err)
+			continue
+		}
+		if !prefix.Addr().Is4() || !prefix.Addr().IsPrivate() {
+			log.Warnf("ignoring the LAN network %s reported by a peer: not a private IPv4 network", network)
+			continue
+		}
+
+		masked := prefix.Masked().String()
+		if _, ok := added[masked]; ok {
+			continue
+		}
+		if len(lanNetworks) == maxPeerLANNetworks {
+			log.Warnf("a peer reported more than %d LAN networks, ignoring the others", maxPeerLANNetworks)
+			break
+		}
+		added[masked] = struct{}{}
+		lanNetworks = append(lanNetworks, masked)
+	}
+	return lanNetworks
+}
+
+// GetRouteSuggestions returns the LAN networks detected by the peers of the account which don't overlap the
+// NetBird network and aren't routed through the detecting peer yet
+func (am *DefaultAccountManager) GetRouteSuggestions(accountID, userID string) ([]*RouteSuggestion, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.HasAdminPower() {
+		return nil, status.Errorf(status.PermissionDenied, "only users with admin power can view Network Routes")
+	}
+
+	return getRouteSuggestions(account), nil
+}
+
+// getRouteSuggestions returns the route suggestions of the account sorted by peer name and network
+func getRouteSuggestions(account *Account) []*RouteSuggestion {
+	var accountNetwork netip.Prefix
+	if account.Network != nil {
+		if addr, ok := netip.AddrFromSlice(account.Network.Net.IP.To4()); ok {
+			ones, _ := account.Network.Net.Mask.Size()
+			accountNetwork = netip.PrefixFrom(addr, ones).Masked()
+		}
+	}
+
+	var suggestions []*RouteSuggestion
+	for _, peer := range account.Peers {
+		peerGroups := account.getPeerGroups(peer.ID)
+		for _, network := range peer.Meta.LANNetworks {
+			prefix, err := netip.ParsePrefix(network)
+			if err != nil {
+				continue
+			}
+			if accountNetwork.IsValid() && accountNetwork.Overlaps(prefix) {
+				continue
+			}
+			if isRoutedThroughPeer(account, prefix, peer.ID, peerGroups) {
+				continue
+			}
+
+			suggestions = append(suggestions, &RouteSuggestion{
+				PeerID:   peer.ID,
+				PeerName: peer.Name,
+				Network:  prefix,
+				NetID:    suggestedNetID(peer.DNSLabel, prefix),
+			})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].PeerName != suggestions[j].PeerName {
+			return suggestions[i].PeerName < suggestions[j].PeerName
+		}
+		return suggestions[i].Network.String() < suggestions[j].Network.String()
+	})
+	return suggestions
+}
+
+// isRoutedThroughPeer returns true if a route of the network exists with the peer as routing peer,
+// directly or through one of its groups
+func isRoutedThroughPeer(account *Account, network netip.Prefix, peerID string, peerGroups lookupMap) bool {
+	for _, r := range account.Routes {
+		if r.Network != network {
+			continue
+		}
+		if r.Peer == peerID {
+			return true
+		}
+		for _, groupID := range r.PeerGroups {
+			if _, ok := peerGroups[groupID]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// suggestedNetID returns a network identifier from the DNS label of the peer and the network,
+// e.g. gateway-192-168-1-0-24
+func suggestedNetID(dnsLabel string, network netip.Prefix) string {
+	netID := strings.NewReplacer(".", "-", "/", "-").Replace(network.String())
+	if dnsLabel != "" {
+		netID = dnsLabel + "-" + netID
+	}
+	if len(netID) > route.MaxNetIDChar {
+		netID = netID[:route.MaxNetIDChar]
+	}
+	return netID
+}
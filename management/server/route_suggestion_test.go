@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/route"
+)
+
+func TestToPeerLANNetworks(t *testing.T) {
+	networks := toPeerLANNetworks([]string{
+		"192.168.1.10/24",
+		"192.168.1.0/24",
+		"10.0.0.0/8",
+		"203.0.113.0/24",
+		"fd00::/64",
+		"not-a-network",
+	})
+	assert.Equal(t, []string{"192.168.1.0/24", "10.0.0.0/8"}, networks)
+}
+
+func TestGetRouteSuggestions(t *testing.T) {
+	account := &Account{
+		Network: &Network{Net: net.IPNet{IP: net.ParseIP("100.64.0.0"), Mask: net.CIDRMask(10, 32)}},
+		Peers: map[string]*nbpeer.Peer{
+			"peer-a": {
+				ID:       "peer-a",
+				Name:     "office",
+				DNSLabel: "office",
+				Meta:     nbpeer.PeerSystemMeta{LANNetworks: []string{"192.168.1.0/24", "10.10.0.0/16", "100.64.10.0/24"}},
+			},
+			"peer-b": {
+				ID:       "peer-b",
+				Name:     "branch",
+				DNSLabel: "branch",
+				Meta:     nbpeer.PeerSystemMeta{LANNetworks: []string{"192.168.1.0/24", "172.16.0.0/24"}},
+			},
+		},
+		Groups: map[string]*Group{
+			"group-b": {ID: "group-b", Peers: []string{"peer-b"}},
+		},
+		Routes: map[string]*route.Route{
+			"route-a": {ID: "route-a", Network: netip.MustParsePrefix("10.10.0.0/16"), Peer: "peer-a"},
+			"route-b": {ID: "route-b", Network: netip.MustParsePrefix("172.16.0.0/24"), PeerGroups: []string{"group-b"}},
+		},
+	}
+
+	suggestions := getRouteSuggestions(account)
+
+	assert.Equal(t, []*RouteSuggestion{
+		{PeerID: "peer-b", PeerName: "branch", Network: netip.MustParsePrefix("192.168.1.0/24"), NetID: "branch-192-168-1-0-24"},
+		{PeerID: "peer-a", PeerName: "office", Network: netip.MustParsePrefix("192.168.1.0/24"), NetID: "office-192-168-1-0-24"},
+	}, suggestions, "routed and overlapping networks shouldn't be suggested")
+}
@@ -38,15 +38,17 @@ const (
 
 func TestCreateRoute(t *testing.T) {
 	type input struct {
-		network      string
-		netID        string
-		peerKey      string
-		peerGroupIDs []string
-		description  string
-		masquerade   bool
-		metric       int
-		enabled      bool
-		groups       []string
+		network           string
+		netID             string
+		peerKey           string
+		peerGroupIDs      []string
+		description       string
+		masquerade        bool
+		metric            int
+		disablePreemption bool
+		loadBalance       bool
+		enabled           bool
+		groups            []string
 	}
 
 	testCases := []struct {
@@ -83,6 +85,58 @@ func TestCreateRoute(t *testing.T) {
 				Groups:      []string{routeGroup1},
 			},
 		},
+		{
+			name: "Happy Path Preemption Disabled",
+			inputArgs: input{
+				network:           "192.168.0.0/16",
+				netID:             "happy",
+				peerKey:           peer1ID,
+				description:       "super",
+				metric:            9999,
+				disablePreemption: true,
+				enabled:           true,
+				groups:            []string{routeGroup1},
+			},
+			errFunc:      require.NoError,
+			shouldCreate: true,
+			expectedRoute: &route.Route{
+				Network:           netip.MustParsePrefix("192.168.0.0/16"),
+				NetworkType:       route.IPv4Network,
+				NetID:             "happy",
+				Peer:              peer1ID,
+				Description:       "super",
+				Metric:            9999,
+				DisablePreemption: true,
+				Enabled:           true,
+				Groups:            []string{routeGroup1},
+			},
+		},
+		{
+			name: "Happy Path Load Balance",
+			inputArgs: input{
+				network:      "0.0.0.0/0",
+				netID:        "exit",
+				peerGroupIDs: []string{routeGroupHA1, routeGroupHA2},
+				description:  "super",
+				metric:       9999,
+				loadBalance:  true,
+				enabled:      true,
+				groups:       []string{routeGroup1},
+			},
+			errFunc:      require.NoError,
+			shouldCreate: true,
+			expectedRoute: &route.Route{
+				Network:     netip.MustParsePrefix("0.0.0.0/0"),
+				NetworkType: route.IPv4Network,
+				NetID:       "exit",
+				PeerGroups:  []string{routeGroupHA1, routeGroupHA2},
+				Description: "super",
+				Metric:      9999,
+				LoadBalance: true,
+				Enabled:     true,
+				Groups:      []string{routeGroup1},
+			},
+		},
 		{
 			name: "Happy Path Peer Groups",
 			inputArgs: input{
@@ -337,7 +391,7 @@ func TestCreateRoute(t *testing.T) {
 					t.Errorf("failed to get group all: %s", errInit)
 				}
 				_, errInit = am.CreateRoute(account.Id, existingNetwork, "", []string{routeGroup3, routeGroup4},
-					"", existingRouteID, false, 1000, []string{groupAll.ID}, true, userID)
+					"", existingRouteID, false, 1000, false, false, []string{groupAll.ID}, true, userID, "")
 				if errInit != nil {
 					t.Errorf("failed to create init route: %s", errInit)
 				}
@@ -352,9 +406,12 @@ func TestCreateRoute(t *testing.T) {
 				testCase.inputArgs.netID,
 				testCase.inputArgs.masquerade,
 				testCase.inputArgs.metric,
+				testCase.inputArgs.disablePreemption,
+				testCase.inputArgs.loadBalance,
 				testCase.inputArgs.groups,
 				testCase.inputArgs.enabled,
 				userID,
+				"",
 			)
 
 			testCase.errFunc(t, err)
@@ -811,6 +868,176 @@ func TestDeleteRoute(t *testing.T) {
 	}
 }
 
+func TestSaveRoutes(t *testing.T) {
+	newRoute := func(id, network, peerID string, peerGroups []string) *route.Route {
+		return &route.Route{
+			ID:          id,
+			Network:     netip.MustParsePrefix(network),
+			NetID:       "imported",
+			NetworkType: route.IPv4Network,
+			Peer:        peerID,
+			PeerGroups:  peerGroups,
+			Metric:      9999,
+			Enabled:     true,
+			Groups:      []string{routeGroup1},
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		routes        []*route.Route
+		errFunc       require.ErrorAssertionFunc
+		expectedCount int
+	}{
+		{
+			name: "Create and update routes",
+			routes: []*route.Route{
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+				newRoute("", "10.2.0.0/16", "", []string{routeGroupHA2}),
+				newRoute(existingRouteID, "10.3.0.0/16", peer2ID, nil),
+			},
+			errFunc:       require.NoError,
+			expectedCount: 3,
+		},
+		{
+			name: "Overlapping prefixes of the same peer",
+			routes: []*route.Route{
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+				newRoute("", "10.1.1.0/24", "", []string{routeGroupHA2}),
+			},
+			errFunc:       require.Error,
+			expectedCount: 1,
+		},
+		{
+			name: "Overlapping prefixes of different peers",
+			routes: []*route.Route{
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+				newRoute("", "10.1.1.0/24", peer2ID, nil),
+			},
+			errFunc:       require.NoError,
+			expectedCount: 3,
+		},
+		{
+			name: "Overlapping prefix of an existing route",
+			routes: []*route.Route{
+				newRoute("", "10.10.0.0/16", peer2ID, nil),
+			},
+			errFunc:       require.Error,
+			expectedCount: 1,
+		},
+		{
+			name: "Duplicate prefix of the same peer",
+			routes: []*route.Route{
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+			},
+			errFunc:       require.Error,
+			expectedCount: 1,
+		},
+		{
+			name: "Not existing route ID",
+			routes: []*route.Route{
+				newRoute("", "10.1.0.0/16", peer1ID, nil),
+				newRoute("nonExisting", "10.2.0.0/16", peer1ID, nil),
+			},
+			errFunc:       require.Error,
+			expectedCount: 1,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			am, err := createRouterManager(t)
+			require.NoError(t, err, "failed to create account manager")
+
+			account, err := initTestRouteAccount(t, am)
+			require.NoError(t, err, "failed to init testing account")
+
+			account.Routes[existingRouteID] = newRoute(existingRouteID, existingNetwork, peer2ID, nil)
+			err = am.Store.SaveAccount(account)
+			require.NoError(t, err, "failed to save account")
+
+			savedRoutes, saveErr := am.SaveRoutes(account.Id, userID, testCase.routes)
+			testCase.errFunc(t, saveErr)
+
+			account, err = am.Store.GetAccount(account.Id)
+			require.NoError(t, err, "failed to get account")
+			require.Len(t, account.Routes, testCase.expectedCount, "either all or none of the routes should be saved")
+
+			if saveErr != nil {
+				return
+			}
+
+			require.Len(t, savedRoutes, len(testCase.routes))
+			for _, savedRoute := range savedRoutes {
+				require.NotEmpty(t, savedRoute.ID)
+				assert.Equal(t, savedRoute.Network, account.Routes[savedRoute.ID].Network)
+			}
+		})
+	}
+}
+
+func TestAdvertiseRoutes(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	_, err = am.AdvertiseRoutes(peer1Key, "gateway", []string{"10.1.0.0/24"}, nil)
+	require.Error(t, err, "should fail when route advertisement is disabled")
+
+	account.Settings.RouteAdvertisementEnabled = true
+	account.Settings.RouteAdvertisementApprovalRequired = true
+	err = am.Store.SaveAccount(account)
+	require.NoError(t, err, "failed to save account")
+
+	advertised, err := am.AdvertiseRoutes(peer1Key, "gateway", []string{"10.1.0.0/24", "10.2.0.0/24"}, nil)
+	require.NoError(t, err)
+	require.Len(t, advertised, 2)
+
+	allGroup, err := account.GetGroupAll()
+	require.NoError(t, err)
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err, "failed to get account")
+	require.Len(t, account.Routes, 2)
+	for _, advertisedRoute := range advertised {
+		savedRoute := account.Routes[advertisedRoute.ID]
+		require.NotNil(t, savedRoute)
+		assert.Equal(t, peer1ID, savedRoute.Peer)
+		assert.Equal(t, "gateway", savedRoute.NetID)
+		assert.False(t, savedRoute.Enabled, "route should wait for approval")
+		assert.Equal(t, []string{allGroup.ID}, savedRoute.Groups)
+	}
+
+	again, err := am.AdvertiseRoutes(peer1Key, "gateway", []string{"10.1.0.0/24"}, nil)
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, advertised[0].ID, again[0].ID, "advertising a routed network should return the existing route")
+
+	_, err = am.AdvertiseRoutes(peer1Key, "gateway", []string{"10.3.0.0/24", "10.1.0.0/16"}, nil)
+	require.Error(t, err, "should fail with a prefix overlapping one routed by the peer")
+
+	_, err = am.AdvertiseRoutes(peer1Key, "gateway", []string{"not-a-network"}, nil)
+	require.Error(t, err, "should fail with an invalid network")
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err, "failed to get account")
+	require.Len(t, account.Routes, 2, "failed advertisements shouldn't create routes")
+
+	_, err = am.AdvertiseRoutes(peer1Key, "other", nil, []string{"10.1.0.0/24"})
+	require.NoError(t, err)
+	_, err = am.AdvertiseRoutes(peer1Key, "gateway", []string{"10.3.0.0/24"}, []string{"10.2.0.0/24"})
+	require.NoError(t, err)
+
+	account, err = am.Store.GetAccount(account.Id)
+	require.NoError(t, err, "failed to get account")
+	require.Len(t, account.Routes, 2, "withdrawn route should be replaced by the new one")
+	assert.NotNil(t, account.Routes[advertised[0].ID], "route with another network ID shouldn't be withdrawn")
+	assert.Nil(t, account.Routes[advertised[1].ID], "withdrawn route should be removed")
+}
+
 func TestGetNetworkMap_RouteSyncPeerGroups(t *testing.T) {
 	baseRoute := &route.Route{
 		Network:     netip.MustParsePrefix("192.168.0.0/16"),
@@ -840,7 +1067,8 @@ func TestGetNetworkMap_RouteSyncPeerGroups(t *testing.T) {
 
 	newRoute, err := am.CreateRoute(
 		account.Id, baseRoute.Network.String(), baseRoute.Peer, baseRoute.PeerGroups, baseRoute.Description,
-		baseRoute.NetID, baseRoute.Masquerade, baseRoute.Metric, baseRoute.Groups, baseRoute.Enabled, userID)
+		baseRoute.NetID, baseRoute.Masquerade, baseRoute.Metric, baseRoute.DisablePreemption, baseRoute.LoadBalance, baseRoute.Groups,
+		baseRoute.Enabled, userID, "")
 	require.NoError(t, err)
 	require.Equal(t, newRoute.Enabled, true)
 
@@ -932,8 +1160,8 @@ func TestGetNetworkMap_RouteSync(t *testing.T) {
 	require.Len(t, newAccountRoutes.Routes, 0, "new accounts should have no routes")
 
 	createdRoute, err := am.CreateRoute(account.Id, baseRoute.Network.String(), peer1ID, []string{},
-		baseRoute.Description, baseRoute.NetID, baseRoute.Masquerade, baseRoute.Metric, baseRoute.Groups, false,
-		userID)
+		baseRoute.Description, baseRoute.NetID, baseRoute.Masquerade, baseRoute.Metric, baseRoute.DisablePreemption,
+		baseRoute.LoadBalance, baseRoute.Groups, false, userID, "")
 	require.NoError(t, err)
 
 	noDisabledRoutes, err := am.GetNetworkMap(peer1ID)
@@ -0,0 +1,306 @@
+package server
+
+import (
+	"strconv"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/FlintyLemming/netbird/management/server/activity"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+// ServiceProtocol is a protocol and the ports of the traffic of a service
+type ServiceProtocol struct {
+	Protocol PolicyRuleProtocolType
+	// Ports of the traffic, all the ports of the protocol when empty
+	Ports []string
+}
+
+// Service is a named list of protocols and ports the policy rules reference instead of repeating them, e.g. HTTPS for
+// TCP 443. The rules are expanded into the firewall rules of the service protocols when the network map is generated
+type Service struct {
+	// ID of the service
+	ID string `gorm:"primaryKey"`
+
+	// AccountID is a reference to Account that this object belongs
+	AccountID string `json:"-" gorm:"index"`
+
+	// Name of the service, unique in the account
+	Name string
+
+	// Description of the service visible in the UI
+	Description string
+
+	// Protocols and ports of the traffic of the service
+	Protocols []ServiceProtocol `gorm:"serializer:json"`
+}
+
+// Copy returns a copy of the service
+func (s *Service) Copy() *Service {
+	c := *s
+	c.Protocols = make([]ServiceProtocol, len(s.Protocols))
+	for i, p := range s.Protocols {
+		c.Protocols[i] = ServiceProtocol{Protocol: p.Protocol, Ports: slices.Clone(p.Ports)}
+	}
+	return &c
+}
+
+// EventMeta returns activity event meta related to this service
+func (s *Service) EventMeta() map[string]any {
+	return map[string]any{"name": s.Name}
+}
+
+// matchesTraffic returns true when the protocol and the ports cover the traffic
+func (p ServiceProtocol) matchesTraffic(protocol PolicyRuleProtocolType, port int) bool {
+	if p.Protocol != PolicyRuleProtocolALL && p.Protocol != protocol {
+		return false
+	}
+	if protocol == PolicyRuleProtocolICMP || protocol == PolicyRuleProtocolGRE || len(p.Ports) == 0 {
+		return true
+	}
+	for _, v := range p.Ports {
+		if v == strconv.Itoa(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleProtocols returns the protocols and the ports of the traffic matched by the rule: the ones of its services when
+// it references services, its own otherwise
+func (a *Account) ruleProtocols(rule *PolicyRule) []ServiceProtocol {
+	if len(rule.Services) == 0 {
+		return []ServiceProtocol{{Protocol: rule.Protocol, Ports: rule.Ports}}
+	}
+
+	var protocols []ServiceProtocol
+	for _, serviceID := range rule.Services {
+		service, ok := a.Services[serviceID]
+		if !ok {
+			continue
+		}
+		protocols = append(protocols, service.Protocols...)
+	}
+	return protocols
+}
+
+// GetService from the store
+func (am *DefaultAccountManager) GetService(accountID, serviceID, userID string) (*Service, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAdminPower(account, userID, "view services"); err != nil {
+		return nil, err
+	}
+
+	service, ok := account.Services[serviceID]
+	if !ok {
+		return nil, status.Errorf(status.NotFound, "service with ID %s not found", serviceID)
+	}
+	return service.Copy(), nil
+}
+
+// SaveService creates the service or updates it when it exists, the peers get the firewall rules of the policies
+// using the updated service
+func (am *DefaultAccountManager) SaveService(accountID, userID string, service *Service) error {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	if service == nil {
+		return status.Errorf(status.InvalidArgument, "service provided is nil")
+	}
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAdminPower(account, userID, "update services"); err != nil {
+		return err
+	}
+
+	if err := account.validateService(service); err != nil {
+		return err
+	}
+
+	if account.Services == nil {
+		account.Services = make(map[string]*Service)
+	}
+	_, exists := account.Services[service.ID]
+	account.Services[service.ID] = service
+
+	// the rules using the service must stay valid with its new protocols
+	for _, policy := range account.Policies {
+		if !policy.usesService(service.ID) {
+			continue
+		}
+		if err := account.validatePolicyServices(policy); err != nil {
+			return err
+		}
+	}
+
+	account.Network.IncSerial()
+	if err = am.Store.SaveAccount(account); err != nil {
+		return err
+	}
+
+	action := activity.ServiceCreated
+	if exists {
+		action = activity.ServiceUpdated
+	}
+	am.StoreEvent(userID, service.ID, accountID, action, service.EventMeta())
+
+	if exists {
+		am.updateAccountPeers(account)
+	}
+
+	return nil
+}
+
+// DeleteService from the store, a service used by a policy can't be deleted
+func (am *DefaultAccountManager) DeleteService(accountID, serviceID, userID string) error {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAdminPower(account, userID, "delete services"); err != nil {
+		return err
+	}
+
+	service, ok := account.Services[serviceID]
+	if !ok {
+		return status.Errorf(status.NotFound, "service with ID %s not found", serviceID)
+	}
+
+	for _, policy := range account.Policies {
+		if policy.usesService(serviceID) {
+			return status.Errorf(status.PreconditionFailed, "service %s is used by policy %s", service.Name, policy.Name)
+		}
+	}
+
+	delete(account.Services, serviceID)
+
+	account.Network.IncSerial()
+	if err = am.Store.SaveAccount(account); err != nil {
+		return err
+	}
+
+	am.StoreEvent(userID, serviceID, accountID, activity.ServiceDeleted, service.EventMeta())
+
+	return nil
+}
+
+// ListServices from the store
+func (am *DefaultAccountManager) ListServices(accountID, userID string) ([]*Service, error) {
+	unlock := am.Store.AcquireAccountLock(accountID)
+	defer unlock()
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkAdminPower(account, userID, "view services"); err != nil {
+		return nil, err
+	}
+
+	services := make([]*Service, 0, len(account.Services))
+	for _, service := range account.Services {
+		services = append(services, service.Copy())
+	}
+	return services, nil
+}
+
+// checkAdminPower returns an error when the user doesn't have admin power
+func checkAdminPower(account *Account, userID, action string) error {
+	user, err := account.FindUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.HasAdminPower() {
+		return status.Errorf(status.PermissionDenied, "only users with admin power are allowed to %s", action)
+	}
+	return nil
+}
+
+// validateService checks that the service has a unique name and valid protocols and ports
+func (a *Account) validateService(service *Service) error {
+	if service.Name == "" {
+		return status.Errorf(status.InvalidArgument, "service name shouldn't be empty")
+	}
+	for _, s := range a.Services {
+		if s.ID != service.ID && s.Name == service.Name {
+			return status.Errorf(status.AlreadyExists, "service with name %s already exists", service.Name)
+		}
+	}
+
+	if len(service.Protocols) == 0 {
+		return status.Errorf(status.InvalidArgument, "service %s has no protocol", service.Name)
+	}
+	for _, p := range service.Protocols {
+		switch p.Protocol {
+		case PolicyRuleProtocolALL, PolicyRuleProtocolICMP, PolicyRuleProtocolGRE:
+			if len(p.Ports) != 0 {
+				return status.Errorf(status.InvalidArgument, "for ALL, ICMP or GRE protocol ports is not allowed")
+			}
+		case PolicyRuleProtocolTCP, PolicyRuleProtocolUDP, PolicyRuleProtocolSCTP:
+			for _, v := range p.Ports {
+				if port, err := strconv.Atoi(v); err != nil || port < 1 || port > 65535 {
+					return status.Errorf(status.InvalidArgument, "valid port value is in 1..65535 range")
+				}
+			}
+		default:
+			return status.Errorf(status.InvalidArgument, "unknown protocol type: %v", p.Protocol)
+		}
+	}
+	return nil
+}
+
+// validatePolicyServices checks that the services referenced by the rules of the policy exist and that the flow of
+// the rules suits their protocols, like it is checked for the protocol and the ports of a rule
+func (a *Account) validatePolicyServices(policy *Policy) error {
+	for _, rule := range policy.Rules {
+		if len(rule.Services) == 0 {
+			continue
+		}
+		if len(rule.Ports) != 0 {
+			return status.Errorf(status.InvalidArgument, "policy rule %s can't have both ports and services", rule.Name)
+		}
+
+		for _, serviceID := range rule.Services {
+			service, ok := a.Services[serviceID]
+			if !ok {
+				return status.Errorf(status.InvalidArgument, "policy rule %s references the unknown service %s", rule.Name, serviceID)
+			}
+			if rule.Bidirectional {
+				continue
+			}
+			for _, p := range service.Protocols {
+				if len(p.Ports) == 0 {
+					return status.Errorf(status.InvalidArgument,
+						"service %s has protocols without ports, policy rule %s using it can be only bi-directional", service.Name, rule.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// usesService returns true when a rule of the policy references the service
+func (p *Policy) usesService(serviceID string) bool {
+	for _, rule := range p.Rules {
+		if slices.Contains(rule.Services, serviceID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
+	"github.com/FlintyLemming/netbird/management/server/status"
+)
+
+func TestAccount_getPeerConnectionResources_Services(t *testing.T) {
+	account := &Account{
+		Peers: map[string]*nbpeer.Peer{
+			"peerA": {ID: "peerA", IP: net.ParseIP("100.65.14.88"), Status: &nbpeer.PeerStatus{}},
+			"peerB": {ID: "peerB", IP: net.ParseIP("100.65.80.39"), Status: &nbpeer.PeerStatus{}},
+			"peerC": {ID: "peerC", IP: net.ParseIP("100.65.254.139"), Status: &nbpeer.PeerStatus{}},
+		},
+		Groups: map[string]*Group{
+			"GroupAll":     {ID: "GroupAll", Name: "All", Peers: []string{"peerA", "peerB", "peerC"}},
+			"GroupClients": {ID: "GroupClients", Name: "clients", Peers: []string{"peerA"}},
+			"GroupServers": {ID: "GroupServers", Name: "servers", Peers: []string{"peerB"}},
+		},
+		Services: map[string]*Service{
+			"web": {
+				ID:        "web",
+				Name:      "web",
+				Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"80", "443"}}},
+			},
+			"dns": {
+				ID:   "dns",
+				Name: "dns",
+				Protocols: []ServiceProtocol{
+					{Protocol: PolicyRuleProtocolUDP, Ports: []string{"53"}},
+					{Protocol: PolicyRuleProtocolTCP, Ports: []string{"53"}},
+				},
+			},
+		},
+		Policies: []*Policy{{
+			ID:      "servers",
+			Name:    "servers",
+			Enabled: true,
+			Rules: []*PolicyRule{{
+				ID:           "servers",
+				Name:         "servers",
+				Enabled:      true,
+				Action:       PolicyTrafficActionAccept,
+				Protocol:     PolicyRuleProtocolALL,
+				Sources:      []string{"GroupClients"},
+				Destinations: []string{"GroupServers"},
+				Services:     []string{"web", "dns"},
+			}},
+		}},
+	}
+
+	_, firewallRules := account.getPeerConnectionResources("peerA")
+	assert.ElementsMatch(t, []*FirewallRule{
+		{PeerIP: "100.65.80.39", Direction: firewallRuleDirectionOUT, Action: "accept", Protocol: "tcp", Port: "80"},
+		{PeerIP: "100.65.80.39", Direction: firewallRuleDirectionOUT, Action: "accept", Protocol: "tcp", Port: "443"},
+		{PeerIP: "100.65.80.39", Direction: firewallRuleDirectionOUT, Action: "accept", Protocol: "udp", Port: "53"},
+		{PeerIP: "100.65.80.39", Direction: firewallRuleDirectionOUT, Action: "accept", Protocol: "tcp", Port: "53"},
+	}, firewallRules, "the rule should be expanded into the protocols and ports of its services")
+
+	_, firewallRules = account.getPeerConnectionResources("peerB")
+	assert.ElementsMatch(t, []*FirewallRule{
+		{PeerIP: "100.65.14.88", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "tcp", Port: "80"},
+		{PeerIP: "100.65.14.88", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "tcp", Port: "443"},
+		{PeerIP: "100.65.14.88", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "udp", Port: "53"},
+		{PeerIP: "100.65.14.88", Direction: firewallRuleDirectionIN, Action: "accept", Protocol: "tcp", Port: "53"},
+	}, firewallRules)
+}
+
+func TestDefaultAccountManager_Services(t *testing.T) {
+	manager, err := createManager(t)
+	require.NoError(t, err)
+
+	accountID := "test_account"
+	adminUser := "account_creator"
+	someUser := "some_user"
+	account := newAccountWithId(accountID, adminUser, "")
+	account.Users[someUser] = &User{
+		Id:   someUser,
+		Role: UserRoleUser,
+	}
+	err = manager.Store.SaveAccount(account)
+	require.NoError(t, err)
+
+	web := &Service{
+		ID:        "web",
+		Name:      "web",
+		Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"80", "443"}}},
+	}
+	require.NoError(t, manager.SaveService(accountID, adminUser, web))
+	assert.Error(t, manager.SaveService(accountID, someUser, web), "regular users shouldn't save services")
+
+	err = manager.SaveService(accountID, adminUser, &Service{
+		ID:        "other",
+		Name:      "web",
+		Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"8080"}}},
+	})
+	sErr, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, status.AlreadyExists, sErr.Type(), "the service names should be unique")
+
+	for _, invalid := range []*Service{
+		{ID: "empty", Name: "empty"},
+		{ID: "icmp", Name: "icmp", Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolICMP, Ports: []string{"1"}}}},
+		{ID: "port", Name: "port", Protocols: []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"65536"}}}},
+		{ID: "proto", Name: "proto", Protocols: []ServiceProtocol{{Protocol: "ipip"}}},
+	} {
+		assert.Error(t, manager.SaveService(accountID, adminUser, invalid), "service %s should be rejected", invalid.ID)
+	}
+
+	policy := &Policy{
+		ID:      "web",
+		Name:    "web",
+		Enabled: true,
+		Rules: []*PolicyRule{{
+			ID:           "web",
+			Name:         "web",
+			Enabled:      true,
+			Action:       PolicyTrafficActionAccept,
+			Protocol:     PolicyRuleProtocolALL,
+			Sources:      []string{account.Policies[0].Rules[0].Sources[0]},
+			Destinations: []string{account.Policies[0].Rules[0].Destinations[0]},
+			Services:     []string{"unknown"},
+		}},
+	}
+	assert.Error(t, manager.SavePolicy(accountID, adminUser, policy), "a rule shouldn't reference an unknown service")
+
+	policy.Rules[0].Services = []string{"web"}
+	policy.Rules[0].Ports = []string{"22"}
+	assert.Error(t, manager.SavePolicy(accountID, adminUser, policy), "a rule shouldn't have both ports and services")
+
+	policy.Rules[0].Ports = nil
+	require.NoError(t, manager.SavePolicy(accountID, adminUser, policy))
+
+	web.Protocols = []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP}}
+	assert.Error(t, manager.SaveService(accountID, adminUser, web),
+		"a service used by a one-way rule shouldn't get protocols without ports")
+
+	web.Protocols = []ServiceProtocol{{Protocol: PolicyRuleProtocolTCP, Ports: []string{"8443"}}}
+	require.NoError(t, manager.SaveService(accountID, adminUser, web))
+
+	service, err := manager.GetService(accountID, "web", adminUser)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"8443"}, service.Protocols[0].Ports)
+
+	services, err := manager.ListServices(accountID, adminUser)
+	require.NoError(t, err)
+	assert.Len(t, services, 1)
+
+	err = manager.DeleteService(accountID, "web", adminUser)
+	sErr, ok = status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, status.PreconditionFailed, sErr.Type(), "a service used by a policy shouldn't be deleted")
+
+	require.NoError(t, manager.DeletePolicy(accountID, policy.ID, adminUser))
+	require.NoError(t, manager.DeleteService(accountID, "web", adminUser))
+
+	_, err = manager.GetService(accountID, "web", adminUser)
+	assert.Error(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/FlintyLemming/netbird/management/server/activity"
+	nbpeer "github.com/FlintyLemming/netbird/management/server/peer"
 	"github.com/FlintyLemming/netbird/management/server/status"
 )
 
@@ -90,6 +91,11 @@ type SetupKey struct {
 	UsageLimit int
 	// Ephemeral indicate if the peers will be ephemeral or not
 	Ephemeral bool
+	// ExternalID is an identifier set by an external tool managing the key, e.g. a Terraform provider. It is unique
+	// among the setup keys of the account
+	ExternalID string `gorm:"index"`
+	// PeerType is the type of the peers registered with this key, services when empty
+	PeerType nbpeer.PeerType
 }
 
 // Copy copies SetupKey to a new object
@@ -114,9 +120,19 @@ func (key *SetupKey) Copy() *SetupKey {
 		AutoGroups: autoGroups,
 		UsageLimit: key.UsageLimit,
 		Ephemeral:  key.Ephemeral,
+		ExternalID: key.ExternalID,
+		PeerType:   key.PeerType,
 	}
 }
 
+// GetPeerType returns the type of the peers registered with the key
+func (key *SetupKey) GetPeerType() nbpeer.PeerType {
+	if key.PeerType == "" {
+		return nbpeer.PeerTypeService
+	}
+	return key.PeerType
+}
+
 // EventMeta returns activity event meta related to the setup key
 func (key *SetupKey) EventMeta() map[string]any {
 	return map[string]any{"name": key.Name, "type": key.Type, "key": key.HiddenCopy(1).Key}
@@ -208,7 +224,8 @@ func Hash(s string) uint32 {
 // CreateSetupKey generates a new setup key with a given name, type, list of groups IDs to auto-assign to peers registered with this key,
 // and adds it to the specified account. A list of autoGroups IDs can be empty.
 func (am *DefaultAccountManager) CreateSetupKey(accountID string, keyName string, keyType SetupKeyType,
-	expiresIn time.Duration, autoGroups []string, usageLimit int, userID string, ephemeral bool) (*SetupKey, error) {
+	expiresIn time.Duration, autoGroups []string, usageLimit int, userID string, ephemeral bool, externalID string,
+	peerType nbpeer.PeerType) (*SetupKey, error) {
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
 
@@ -228,7 +245,16 @@ func (am *DefaultAccountManager) CreateSetupKey(accountID string, keyName string
 		}
 	}
 
+	if peerType != "" && !peerType.IsValid() {
+		return nil, status.Errorf(status.InvalidArgument, "unknown peer type %s", peerType)
+	}
+
 	setupKey := GenerateSetupKey(keyName, keyType, keyDuration, autoGroups, usageLimit, ephemeral)
+	setupKey.ExternalID = externalID
+	setupKey.PeerType = peerType
+	if err := account.validateSetupKeyExternalID(setupKey); err != nil {
+		return nil, err
+	}
 	account.SetupKeys[setupKey.Key] = setupKey
 	err = am.Store.SaveAccount(account)
 	if err != nil {
@@ -253,7 +279,8 @@ func (am *DefaultAccountManager) CreateSetupKey(accountID string, keyName string
 // SaveSetupKey saves the provided SetupKey to the database overriding the existing one.
 // Due to the unique nature of a SetupKey certain properties must not be overwritten
 // (e.g. the key itself, creation date, ID, etc).
-// These properties are overwritten: Name, AutoGroups, Revoked. The rest is copied from the existing key.
+// These properties are overwritten: Name, AutoGroups, Revoked, ExternalID, PeerType. The rest is copied from the
+// existing key.
 func (am *DefaultAccountManager) SaveSetupKey(accountID string, keyToSave *SetupKey, userID string) (*SetupKey, error) {
 	unlock := am.Store.AcquireAccountLock(accountID)
 	defer unlock()
@@ -283,8 +310,18 @@ func (am *DefaultAccountManager) SaveSetupKey(accountID string, keyToSave *Setup
 	newKey.Name = keyToSave.Name
 	newKey.AutoGroups = keyToSave.AutoGroups
 	newKey.Revoked = keyToSave.Revoked
+	newKey.ExternalID = keyToSave.ExternalID
+	newKey.PeerType = keyToSave.PeerType
 	newKey.UpdatedAt = time.Now().UTC()
 
+	if newKey.PeerType != "" && !newKey.PeerType.IsValid() {
+		return nil, status.Errorf(status.InvalidArgument, "unknown peer type %s", newKey.PeerType)
+	}
+
+	if err := account.validateSetupKeyExternalID(newKey); err != nil {
+		return nil, err
+	}
+
 	account.SetupKeys[newKey.Key] = newKey
 
 	if err = am.Store.SaveAccount(account); err != nil {
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	sigProto "github.com/FlintyLemming/netbird/signal/proto"
+)
+
+const (
+	// signalPresenceCacheTTL is the time the presence of the peers of an account is served before it is looked up again
+	signalPresenceCacheTTL = 30 * time.Second
+	// signalPresenceTimeout is the maximum time of a presence lookup
+	signalPresenceTimeout = 5 * time.Second
+)
+
+// SignalPresence looks up which peers are connected to the Signal service. The Signal service only answers for the
+// requested peers and when the presence token matches, so only the peers of an account are ever looked up
+type SignalPresence struct {
+	conn   *grpc.ClientConn
+	client sigProto.SignalExchangeClient
+	token  string
+
+	mu sync.Mutex
+	// accounts caches the lookups by account ID
+	accounts map[string]*accountPresence
+}
+
+type accountPresence struct {
+	// connected holds the WireGuard public keys of the peers connected to the Signal service
+	connected map[string]struct{}
+	// failed indicates that the lookup failed, the presence is unknown until it is looked up again
+	failed    bool
+	updatedAt time.Time
+}
+
+// NewSignalPresence creates a client of the presence API of the Signal service. The connection is established lazily
+func NewSignalPresence(signal *Host, token string) (*SignalPresence, error) {
+	if signal == nil || signal.URI == "" {
+		return nil, fmt.Errorf("the Signal service is not configured")
+	}
+
+	transportOption := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if signal.Proto == HTTPS {
+		transportOption = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{}))
+	}
+
+	// the URI can be configured with the scheme the clients use
+	addr := strings.TrimPrefix(strings.TrimPrefix(signal.URI, "https://"), "http://")
+	conn, err := grpc.Dial(addr, transportOption)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating the Signal service client: %v", err)
+	}
+
+	return &SignalPresence{
+		conn:     conn,
+		client:   sigProto.NewSignalExchangeClient(conn),
+		token:    token,
+		accounts: make(map[string]*accountPresence),
+	}, nil
+}
+
+// Close closes the connection to the Signal service
+func (s *SignalPresence) Close() error {
+	return s.conn.Close()
+}
+
+// getConnected returns which of the given WireGuard public keys of the account peers are connected to the Signal
+// service. It returns false when the presence is unknown
+func (s *SignalPresence) getConnected(accountID string, keys []string) (map[string]struct{}, bool) {
+	s.mu.Lock()
+	cached, ok := s.accounts[accountID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.updatedAt) < signalPresenceCacheTTL {
+		return cached.connected, !cached.failed
+	}
+
+	presence := &accountPresence{updatedAt: time.Now()}
+	connected, err := s.lookup(keys)
+	if err != nil {
+		log.Debugf("failed looking up the Signal presence of the peers of account %s: %v", accountID, err)
+		presence.failed = true
+	}
+	presence.connected = connected
+
+	s.mu.Lock()
+	s.accounts[accountID] = presence
+	s.mu.Unlock()
+
+	return presence.connected, !presence.failed
+}
+
+func (s *SignalPresence) lookup(keys []string) (map[string]struct{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signalPresenceTimeout)
+	defer cancel()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, sigProto.HeaderPresenceToken, s.token)
+	resp, err := s.client.GetPresence(ctx, &sigProto.PresenceRequest{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	connected := make(map[string]struct{}, len(resp.GetConnectedKeys()))
+	for _, key := range resp.GetConnectedKeys() {
+		connected[key] = struct{}{}
+	}
+	return connected, nil
+}
+
+func (s *SignalPresence) deleteAccount(accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.accounts, accountID)
+}
+
+// SetSignalPresence enables the lookups of the presence of the peers from the Signal service
+func (am *DefaultAccountManager) SetSignalPresence(presence *SignalPresence) {
+	am.signalPresence = presence
+}
+
+// GetPeersSignalPresence returns whether the peers of the account are connected to the Signal service by peer ID.
+// It returns nil when the lookups are disabled or the presence is unknown
+func (am *DefaultAccountManager) GetPeersSignalPresence(accountID string) map[string]bool {
+	if am.signalPresence == nil {
+		return nil
+	}
+
+	account, err := am.Store.GetAccount(accountID)
+	if err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(account.Peers))
+	for _, peer := range account.Peers {
+		keys = append(keys, peer.Key)
+	}
+
+	connected, ok := am.signalPresence.getConnected(accountID, keys)
+	if !ok {
+		return nil
+	}
+
+	presence := make(map[string]bool, len(account.Peers))
+	for _, peer := range account.Peers {
+		_, presence[peer.ID] = connected[peer.Key]
+	}
+	return presence
+}
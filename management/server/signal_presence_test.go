@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	sigProto "github.com/FlintyLemming/netbird/signal/proto"
+)
+
+type mockPresenceClient struct {
+	sigProto.SignalExchangeClient
+	connected []string
+	err       error
+	calls     int
+	token     string
+}
+
+func (c *mockPresenceClient) GetPresence(ctx context.Context, in *sigProto.PresenceRequest, _ ...grpc.CallOption) (*sigProto.PresenceResponse, error) {
+	c.calls++
+	if meta, ok := metadata.FromOutgoingContext(ctx); ok {
+		if tokens := meta.Get(sigProto.HeaderPresenceToken); len(tokens) > 0 {
+			c.token = tokens[0]
+		}
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	resp := &sigProto.PresenceResponse{}
+	for _, key := range in.GetKeys() {
+		for _, connected := range c.connected {
+			if key == connected {
+				resp.ConnectedKeys = append(resp.ConnectedKeys, key)
+			}
+		}
+	}
+	return resp, nil
+}
+
+func TestGetPeersSignalPresence(t *testing.T) {
+	am, err := createRouterManager(t)
+	require.NoError(t, err, "failed to create account manager")
+
+	account, err := initTestRouteAccount(t, am)
+	require.NoError(t, err, "failed to init testing account")
+
+	assert.Nil(t, am.GetPeersSignalPresence(account.Id), "presence should be unknown when the lookups are disabled")
+
+	client := &mockPresenceClient{connected: []string{peer1Key}}
+	am.SetSignalPresence(&SignalPresence{client: client, token: "token", accounts: make(map[string]*accountPresence)})
+
+	presence := am.GetPeersSignalPresence(account.Id)
+	require.Len(t, presence, len(account.Peers))
+	assert.True(t, presence[peer1ID])
+	assert.False(t, presence[peer2ID])
+	assert.Equal(t, "token", client.token, "the presence token should be sent")
+
+	am.GetPeersSignalPresence(account.Id)
+	assert.Equal(t, 1, client.calls, "the presence should be cached")
+
+	failing := &mockPresenceClient{err: errors.New("unavailable")}
+	am.SetSignalPresence(&SignalPresence{client: failing, accounts: make(map[string]*accountPresence)})
+	assert.Nil(t, am.GetPeersSignalPresence(account.Id), "presence should be unknown when the lookup fails")
+}
@@ -63,7 +63,7 @@ func NewSqliteStore(dataDir string, metrics telemetry.AppMetrics) (*SqliteStore,
 	err = db.AutoMigrate(
 		&SetupKey{}, &nbpeer.Peer{}, &User{}, &PersonalAccessToken{}, &Group{}, &Rule{},
 		&Account{}, &Policy{}, &PolicyRule{}, &route.Route{}, &nbdns.NameServerGroup{},
-		&installation{}, &account.ExtraSettings{},
+		&installation{}, &account.ExtraSettings{}, &Service{},
 	)
 	if err != nil {
 		return nil, err
@@ -171,6 +171,11 @@ func (s *SqliteStore) SaveAccount(account *Account) error {
 		account.NameServerGroupsG = append(account.NameServerGroupsG, *ns)
 	}
 
+	for id, service := range account.Services {
+		service.ID = id
+		account.ServicesG = append(account.ServicesG, *service)
+	}
+
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		result := tx.Select(clause.Associations).Delete(account.Policies, "account_id = ?", account.Id)
 		if result.Error != nil {
@@ -421,6 +426,12 @@ func (s *SqliteStore) GetAccount(accountID string) (*Account, error) {
 	}
 	account.NameServerGroupsG = nil
 
+	account.Services = make(map[string]*Service, len(account.ServicesG))
+	for _, service := range account.ServicesG {
+		account.Services[service.ID] = service.Copy()
+	}
+	account.ServicesG = nil
+
 	return &account, nil
 }
 
@@ -5,6 +5,8 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"fmt"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,7 +17,7 @@ import (
 
 // TURNCredentialsManager used to manage TURN credentials
 type TURNCredentialsManager interface {
-	GenerateCredentials() TURNCredentials
+	GenerateCredentials(peerID string) TURNCredentials
 	SetupRefresh(peerKey string)
 	CancelRefresh(peerKey string)
 }
@@ -42,27 +44,41 @@ func NewTimeBasedAuthSecretsManager(updateManager *PeersUpdateManager, config *T
 	}
 }
 
-// GenerateCredentials generates new time-based secret credentials - basically username is a unix timestamp and password is a HMAC hash of a timestamp with a preshared TURN secret
-func (m *TimeBasedAuthSecretsManager) GenerateCredentials() TURNCredentials {
-	mac := hmac.New(sha1.New, []byte(m.config.Secret))
-
+// GenerateCredentials generates new time-based secret credentials of a peer - the username is the expiration unix
+// timestamp and the peer ID separated by a colon, as in the TURN REST API, and the password is a HMAC hash of the
+// username with a preshared TURN secret. The peer ID lets the relay enforce per-peer quotas
+func (m *TimeBasedAuthSecretsManager) GenerateCredentials(peerID string) TURNCredentials {
 	timeAuth := time.Now().Add(m.config.CredentialsTTL.Duration).Unix()
+	username := fmt.Sprintf("%d:%s", timeAuth, peerID)
 
-	username := fmt.Sprint(timeAuth)
+	return TURNCredentials{
+		Username: username,
+		Password: TURNPassword(m.config.Secret, username),
+	}
+}
 
+// TURNPassword returns the password of the time-based credentials with the username, the base64 encoded HMAC hash of
+// the username with the TURN secret
+func TURNPassword(secret, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
 	_, err := mac.Write([]byte(username))
 	if err != nil {
 		log.Errorln("Generating turn password failed with error: ", err)
 	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
 
-	bytePassword := mac.Sum(nil)
-	password := base64.StdEncoding.EncodeToString(bytePassword)
-
-	return TURNCredentials{
-		Username: username,
-		Password: password,
+// ParseTURNUsername returns the expiration time and the peer ID of the username of time-based credentials
+func ParseTURNUsername(username string) (time.Time, string, error) {
+	timestamp, peerID, found := strings.Cut(username, ":")
+	if !found || peerID == "" {
+		return time.Time{}, "", fmt.Errorf("username %q has no peer ID", username)
 	}
-
+	expiresAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid expiration of the username %q: %w", username, err)
+	}
+	return time.Unix(expiresAt, 0), peerID, nil
 }
 
 func (m *TimeBasedAuthSecretsManager) cancel(peerID string) {
@@ -99,7 +115,7 @@ func (m *TimeBasedAuthSecretsManager) SetupRefresh(peerID string) {
 				log.Debugf("stopping turn refresh for %s", peerID)
 				return
 			case <-ticker.C:
-				c := m.GenerateCredentials()
+				c := m.GenerateCredentials(peerID)
 				var turns []*proto.ProtectedHostConfig
 				for _, host := range m.config.Turns {
 					turns = append(turns, &proto.ProtectedHostConfig{
@@ -28,7 +28,7 @@ func TestTimeBasedAuthSecretsManager_GenerateCredentials(t *testing.T) {
 		Turns:          []*Host{TurnTestHost},
 	})
 
-	credentials := tested.GenerateCredentials()
+	credentials := tested.GenerateCredentials("some_peer")
 
 	if credentials.Username == "" {
 		t.Errorf("expected generated TURN username not to be empty, got empty")
@@ -39,6 +39,24 @@ func TestTimeBasedAuthSecretsManager_GenerateCredentials(t *testing.T) {
 
 	validateMAC(t, credentials.Username, credentials.Password, []byte(secret))
 
+	expiresAt, peerID, err := ParseTURNUsername(credentials.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peerID != "some_peer" {
+		t.Errorf("expected the username to name the peer some_peer, got %s", peerID)
+	}
+	if expiresAt.Before(time.Now().Add(ttl.Duration - time.Minute)) {
+		t.Errorf("expected the credentials to expire after the TTL, got %s", expiresAt)
+	}
+}
+
+func TestParseTURNUsername(t *testing.T) {
+	for _, username := range []string{"1700000000", "1700000000:", "peer:1700000000", ""} {
+		if _, _, err := ParseTURNUsername(username); err == nil {
+			t.Errorf("expected the username %q to be rejected", username)
+		}
+	}
 }
 
 func TestTimeBasedAuthSecretsManager_SetupRefresh(t *testing.T) {
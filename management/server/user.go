@@ -88,6 +88,9 @@ type User struct {
 
 	// Issued of the user
 	Issued string `gorm:"default:api"`
+	// IdentityProvider is the name of the additional identity provider the user last logged in with, empty for the
+	// default provider
+	IdentityProvider string
 
 	IntegrationReference IntegrationReference `gorm:"embedded;embeddedPrefix:integration_ref_"`
 }
@@ -115,16 +118,17 @@ func (u *User) ToUserInfo(userData *idp.UserData) (*UserInfo, error) {
 
 	if userData == nil {
 		return &UserInfo{
-			ID:            u.Id,
-			Email:         "",
-			Name:          u.ServiceUserName,
-			Role:          string(u.Role),
-			AutoGroups:    u.AutoGroups,
-			Status:        string(UserStatusActive),
-			IsServiceUser: u.IsServiceUser,
-			IsBlocked:     u.Blocked,
-			LastLogin:     u.LastLogin,
-			Issued:        u.Issued,
+			ID:               u.Id,
+			Email:            "",
+			Name:             u.ServiceUserName,
+			Role:             string(u.Role),
+			AutoGroups:       u.AutoGroups,
+			Status:           string(UserStatusActive),
+			IsServiceUser:    u.IsServiceUser,
+			IsBlocked:        u.Blocked,
+			LastLogin:        u.LastLogin,
+			Issued:           u.Issued,
+			IdentityProvider: u.IdentityProvider,
 		}, nil
 	}
 	if userData.ID != u.Id {
@@ -137,16 +141,17 @@ func (u *User) ToUserInfo(userData *idp.UserData) (*UserInfo, error) {
 	}
 
 	return &UserInfo{
-		ID:            u.Id,
-		Email:         userData.Email,
-		Name:          userData.Name,
-		Role:          string(u.Role),
-		AutoGroups:    autoGroups,
-		Status:        string(userStatus),
-		IsServiceUser: u.IsServiceUser,
-		IsBlocked:     u.Blocked,
-		LastLogin:     u.LastLogin,
-		Issued:        u.Issued,
+		ID:               u.Id,
+		Email:            userData.Email,
+		Name:             userData.Name,
+		Role:             string(u.Role),
+		AutoGroups:       autoGroups,
+		Status:           string(userStatus),
+		IsServiceUser:    u.IsServiceUser,
+		IsBlocked:        u.Blocked,
+		LastLogin:        u.LastLogin,
+		Issued:           u.Issued,
+		IdentityProvider: u.IdentityProvider,
 	}, nil
 }
 
@@ -170,6 +175,7 @@ func (u *User) Copy() *User {
 		Blocked:              u.Blocked,
 		LastLogin:            u.LastLogin,
 		Issued:               u.Issued,
+		IdentityProvider:     u.IdentityProvider,
 		IntegrationReference: u.IntegrationReference,
 	}
 }
@@ -817,6 +823,13 @@ func (am *DefaultAccountManager) SaveOrAddUser(accountID, initiatorUserID string
 			return nil, err
 		}
 
+		// this will trigger peer disconnect from the management service
+		var blockedPeerIDs []string
+		for _, peer := range blockedPeers {
+			blockedPeerIDs = append(blockedPeerIDs, peer.ID)
+		}
+		am.peersUpdateManager.CloseChannels(blockedPeerIDs)
+
 		if err := am.expireAndUpdatePeers(account, blockedPeers); err != nil {
 			log.Errorf("failed update expired peers: %s", err)
 			return nil, err
@@ -1054,8 +1067,8 @@ func (am *DefaultAccountManager) expireAndUpdatePeers(account *Account, peers []
 	}
 
 	if len(peerIDs) != 0 {
-		// this will trigger peer disconnect from the management service
-		am.peersUpdateManager.CloseChannels(peerIDs)
+		// expired peers stay connected to the management service and receive a network map limited to the
+		// Settings.PeerLoginExpiredAccessGroups together with the request to log in again
 		am.updateAccountPeers(account)
 	}
 	return nil
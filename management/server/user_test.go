@@ -272,9 +272,10 @@ func TestUser_Copy(t *testing.T) {
 				LastUsed:       time.Now(),
 			},
 		},
-		Blocked:   false,
-		LastLogin: time.Now(),
-		Issued:    "test",
+		Blocked:          false,
+		LastLogin:        time.Now(),
+		Issued:           "test",
+		IdentityProvider: "partner-idp",
 		IntegrationReference: IntegrationReference{
 			ID:              0,
 			IntegrationType: "test",
@@ -78,6 +78,15 @@ type Route struct {
 	Metric      int
 	Enabled     bool
 	Groups      []string `gorm:"serializer:json"`
+	// DisablePreemption keeps the clients on the chosen route of the HA set while its routing peer is connected,
+	// instead of failing back to a route with a lower metric when it becomes available again
+	DisablePreemption bool
+	// LoadBalance spreads the clients over the connected routing peers of the HA set, e.g. an exit node group. Every
+	// client is assigned to a routing peer by the hash of its key and reassigned when that routing peer disconnects
+	LoadBalance bool
+	// ExternalID is an identifier set by an external tool managing the route, e.g. a Terraform provider. It is unique
+	// among the routes of the account
+	ExternalID string `gorm:"index"`
 }
 
 // EventMeta returns activity event meta related to the route
@@ -99,6 +108,10 @@ func (r *Route) Copy() *Route {
 		Masquerade:  r.Masquerade,
 		Enabled:     r.Enabled,
 		Groups:      make([]string, len(r.Groups)),
+
+		DisablePreemption: r.DisablePreemption,
+		LoadBalance:       r.LoadBalance,
+		ExternalID:        r.ExternalID,
 	}
 	copy(route.Groups, r.Groups)
 	copy(route.PeerGroups, r.PeerGroups)
@@ -116,6 +129,9 @@ func (r *Route) IsEqual(other *Route) bool {
 		other.Metric == r.Metric &&
 		other.Masquerade == r.Masquerade &&
 		other.Enabled == r.Enabled &&
+		other.DisablePreemption == r.DisablePreemption &&
+		other.LoadBalance == r.LoadBalance &&
+		other.ExternalID == r.ExternalID &&
 		compareList(r.Groups, other.Groups) &&
 		compareList(r.PeerGroups, other.PeerGroups)
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pion/turn/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server"
+	"github.com/FlintyLemming/netbird/util"
+)
+
+const (
+	defaultRelayPort  = 3478
+	defaultRelayRealm = "netbird"
+	// defaultCredentialsTTL is the validity of the TURN credentials handed out to the peers when the config doesn't
+	// set it, the Management service renews them before they expire
+	defaultCredentialsTTL = time.Hour
+)
+
+// relay is the TURN relay embedded in the single-binary server
+type relay struct {
+	server *turn.Server
+}
+
+// Close stops the relay and closes the relayed connections
+func (r *relay) Close() error {
+	if r.server == nil {
+		return nil
+	}
+	return r.server.Close()
+}
+
+// startRelay starts the TURN relay of the Relay config and adds it to the TURN servers handed out to the peers. The
+// relay accepts the per-peer time-based credentials the Management service generates with the TURN secret and limits
+// the traffic of each peer to the PeerBandwidthLimit of the config
+func startRelay(config *server.Config) (io.Closer, error) {
+	if config.Relay == nil {
+		log.Infof("no relay configured, the peers use the TURN servers of the config")
+		return &relay{}, nil
+	}
+
+	relayConfig := *config.Relay
+	if relayConfig.Port == 0 {
+		relayConfig.Port = defaultRelayPort
+	}
+	if relayConfig.Realm == "" {
+		relayConfig.Realm = defaultRelayRealm
+	}
+	publicIP := net.ParseIP(relayConfig.PublicIP)
+	if publicIP == nil {
+		return nil, fmt.Errorf("invalid public IP %q of the relay", relayConfig.PublicIP)
+	}
+
+	if err := addRelayToTURNConfig(config, relayConfig); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", relayConfig.Port))
+	if err != nil {
+		return nil, fmt.Errorf("listen on UDP port %d: %w", relayConfig.Port, err)
+	}
+
+	quotaConn := &quotaPacketConn{
+		PacketConn: conn,
+		secret:     config.TURNConfig.Secret,
+		quotas:     newPeerQuotas(relayConfig.PeerBandwidthLimit),
+	}
+	turnServer, err := turn.NewServer(turn.ServerConfig{
+		Realm:       relayConfig.Realm,
+		AuthHandler: peerAuthHandler(config.TURNConfig.Secret),
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            quotaConn,
+			RelayAddressGenerator: relayAddressGenerator(publicIP, relayConfig),
+		}},
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("create TURN server: %w", err)
+	}
+
+	log.Infof("running the relay on UDP port %d with public IP %s", relayConfig.Port, publicIP)
+	return &relay{server: turnServer}, nil
+}
+
+// addRelayToTURNConfig hands out the relay to the peers with time-based credentials. A TURN secret is generated when
+// the config doesn't set one, the credentials of the peers are renewed when they reconnect after a restart
+func addRelayToTURNConfig(config *server.Config, relayConfig server.RelayConfig) error {
+	if config.TURNConfig == nil {
+		config.TURNConfig = &server.TURNConfig{}
+	}
+	turnConfig := config.TURNConfig
+
+	if !turnConfig.TimeBasedCredentials && len(turnConfig.Turns) > 0 {
+		return fmt.Errorf("the relay requires time-based TURN credentials, the other TURN servers of the config use static ones")
+	}
+	turnConfig.TimeBasedCredentials = true
+
+	if turnConfig.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("generate TURN secret: %w", err)
+		}
+		turnConfig.Secret = base64.StdEncoding.EncodeToString(secret)
+	}
+	if turnConfig.CredentialsTTL.Duration == 0 {
+		turnConfig.CredentialsTTL = util.Duration{Duration: defaultCredentialsTTL}
+	}
+
+	uri := fmt.Sprintf("turn:%s:%d", relayConfig.PublicIP, relayConfig.Port)
+	for _, host := range turnConfig.Turns {
+		if host.URI == uri {
+			return nil
+		}
+	}
+	turnConfig.Turns = append(turnConfig.Turns, &server.Host{Proto: server.UDP, URI: uri})
+	return nil
+}
+
+func relayAddressGenerator(publicIP net.IP, relayConfig server.RelayConfig) turn.RelayAddressGenerator {
+	if relayConfig.MinPort == 0 && relayConfig.MaxPort == 0 {
+		return &turn.RelayAddressGeneratorStatic{
+			RelayAddress: publicIP,
+			Address:      "0.0.0.0",
+		}
+	}
+	return &turn.RelayAddressGeneratorPortRange{
+		RelayAddress: publicIP,
+		Address:      "0.0.0.0",
+		MinPort:      relayConfig.MinPort,
+		MaxPort:      relayConfig.MaxPort,
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/stun/v2"
+	"github.com/pion/turn/v3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/FlintyLemming/netbird/management/server"
+)
+
+// quotaIdleTimeout is the time after which the quota of a peer without relayed traffic is forgotten
+const quotaIdleTimeout = 10 * time.Minute
+
+// peerQuotas limits the traffic each peer relays, the peers are identified by the per-peer TURN credentials they
+// authenticate with. The traffic of addresses which haven't authenticated isn't limited, they can't relay anything
+type peerQuotas struct {
+	mu sync.Mutex
+	// bytesPerSecond is the quota of each peer, 0 disables the quotas
+	bytesPerSecond float64
+	// peers maps the client addresses to the peers authenticated from them
+	peers   map[string]string
+	buckets map[string]*quotaBucket
+	now     func() time.Time
+}
+
+// quotaBucket is a token bucket of the bytes a peer can relay, refilled at the rate of the quota and holding up to one
+// second of traffic
+type quotaBucket struct {
+	tokens   float64
+	updated  time.Time
+	lastUsed time.Time
+}
+
+// newPeerQuotas returns the quotas of peers limited to limit kbit/s, 0 disables the quotas
+func newPeerQuotas(limit uint64) *peerQuotas {
+	return &peerQuotas{
+		bytesPerSecond: float64(limit) * 1000 / 8,
+		peers:          make(map[string]string),
+		buckets:        make(map[string]*quotaBucket),
+		now:            time.Now,
+	}
+}
+
+// bind attributes the traffic of the client address to the peer
+func (q *peerQuotas) bind(addr net.Addr, peerID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	q.peers[addr.String()] = peerID
+	if _, ok := q.buckets[peerID]; !ok {
+		q.buckets[peerID] = &quotaBucket{tokens: q.bytesPerSecond, updated: now}
+	}
+	q.buckets[peerID].lastUsed = now
+	q.removeIdle(now)
+}
+
+// allow returns true if the peer of the client address is within its quota after relaying size bytes
+func (q *peerQuotas) allow(addr net.Addr, size int) bool {
+	if q.bytesPerSecond == 0 {
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	peerID, ok := q.peers[addr.String()]
+	if !ok {
+		return true
+	}
+	bucket := q.buckets[peerID]
+
+	now := q.now()
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * q.bytesPerSecond
+	if bucket.tokens > q.bytesPerSecond {
+		bucket.tokens = q.bytesPerSecond
+	}
+	bucket.updated = now
+	bucket.lastUsed = now
+
+	if bucket.tokens < float64(size) {
+		return false
+	}
+	bucket.tokens -= float64(size)
+	return true
+}
+
+func (q *peerQuotas) removeIdle(now time.Time) {
+	for peerID, bucket := range q.buckets {
+		if now.Sub(bucket.lastUsed) < quotaIdleTimeout {
+			continue
+		}
+		delete(q.buckets, peerID)
+		for addr, id := range q.peers {
+			if id == peerID {
+				delete(q.peers, addr)
+			}
+		}
+	}
+}
+
+// quotaPacketConn drops the packets from and to the peers exceeding their quota, both directions count towards it.
+// The client addresses are attributed to a peer once they send a request with valid credentials of the peer
+type quotaPacketConn struct {
+	net.PacketConn
+	secret string
+	quotas *peerQuotas
+}
+
+// ReadFrom reads the next packet within the quota of its peer
+func (c *quotaPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(p)
+		if err != nil {
+			return n, addr, err
+		}
+		c.bindAuthenticated(p[:n], addr)
+		if c.quotas.allow(addr, n) {
+			return n, addr, nil
+		}
+	}
+}
+
+// WriteTo writes the packet if it is within the quota of its peer, dropped packets are reported as written like the
+// packets lost on the way
+func (c *quotaPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !c.quotas.allow(addr, len(p)) {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+// bindAuthenticated attributes the client address to the peer of the credentials of the packet if it is a STUN
+// request with a valid message integrity. The TURN server checks the integrity after asking for the key, a request
+// with credentials of another peer and an invalid integrity isn't attributed to that peer
+func (c *quotaPacketConn) bindAuthenticated(packet []byte, addr net.Addr) {
+	if c.quotas.bytesPerSecond == 0 || !stun.IsMessage(packet) {
+		return
+	}
+
+	// the integrity check rewrites the length in the raw message temporarily, the packet is left untouched
+	msg := &stun.Message{Raw: append([]byte(nil), packet...)}
+	if err := msg.Decode(); err != nil {
+		return
+	}
+	var username stun.Username
+	var realm stun.Realm
+	if username.GetFrom(msg) != nil || realm.GetFrom(msg) != nil {
+		return
+	}
+
+	key, peerID, err := credentialsKey(c.secret, username.String(), realm.String())
+	if err != nil {
+		return
+	}
+	if stun.MessageIntegrity(key).Check(msg) != nil {
+		return
+	}
+	c.quotas.bind(addr, peerID)
+}
+
+// credentialsKey returns the long-term key and the peer of the unexpired per-peer time-based credentials of the
+// Management service with the username
+func credentialsKey(secret, username, realm string) ([]byte, string, error) {
+	expiresAt, peerID, err := server.ParseTURNUsername(username)
+	if err != nil {
+		return nil, "", err
+	}
+	if expiresAt.Before(time.Now()) {
+		return nil, "", fmt.Errorf("credentials of peer %s expired at %s", peerID, expiresAt)
+	}
+	return turn.GenerateAuthKey(username, realm, server.TURNPassword(secret, username)), peerID, nil
+}
+
+// peerAuthHandler accepts the unexpired per-peer time-based credentials of the Management service
+func peerAuthHandler(secret string) turn.AuthHandler {
+	return func(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+		key, _, err := credentialsKey(secret, username, realm)
+		if err != nil {
+			log.Debugf("rejecting TURN credentials from %s: %v", srcAddr, err)
+			return nil, false
+		}
+		return key, true
+	}
+}
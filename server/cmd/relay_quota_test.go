@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/server"
+)
+
+func TestPeerQuotas(t *testing.T) {
+	now := time.Now()
+	quotas := newPeerQuotas(8) // 1000 bytes per second
+	quotas.now = func() time.Time { return now }
+
+	first := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1000}
+	second := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 2000}
+	other := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 2), Port: 1000}
+	quotas.bind(first, "peer-a")
+	quotas.bind(second, "peer-a")
+
+	assert.True(t, quotas.allow(first, 600))
+	assert.False(t, quotas.allow(second, 600), "the addresses of a peer should share its quota")
+	assert.True(t, quotas.allow(second, 400))
+	assert.True(t, quotas.allow(other, 10000), "the traffic of unauthenticated addresses shouldn't be limited")
+
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, quotas.allow(first, 500), "the quota should be refilled over time")
+	assert.False(t, quotas.allow(first, 1))
+
+	now = now.Add(time.Hour)
+	assert.False(t, quotas.allow(first, 1001), "the quota should hold up to one second of traffic")
+
+	now = now.Add(quotaIdleTimeout)
+	quotas.bind(other, "peer-b")
+	assert.NotContains(t, quotas.buckets, "peer-a", "the idle peers should be forgotten")
+	assert.NotContains(t, quotas.peers, first.String())
+}
+
+func TestQuotaPacketConn_BindAuthenticated(t *testing.T) {
+	secret := "secret"
+	conn := &quotaPacketConn{secret: secret, quotas: newPeerQuotas(8)}
+	addr := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1000}
+	username := fmt.Sprintf("%d:peer-a", time.Now().Add(time.Hour).Unix())
+
+	conn.bindAuthenticated(allocateRequest(t, username, "invalid"), addr)
+	assert.Empty(t, conn.quotas.peers, "a request with an invalid integrity shouldn't be attributed to the peer")
+
+	conn.bindAuthenticated(allocateRequest(t, username, server.TURNPassword(secret, username)), addr)
+	assert.Equal(t, map[string]string{addr.String(): "peer-a"}, conn.quotas.peers)
+}
+
+func allocateRequest(t *testing.T, username, password string) []byte {
+	t.Helper()
+
+	msg, err := stun.Build(
+		stun.TransactionID,
+		stun.NewType(stun.MethodAllocate, stun.ClassRequest),
+		stun.NewUsername(username),
+		stun.NewRealm(defaultRelayRealm),
+		stun.NewNonce("nonce"),
+		stun.NewLongTermIntegrity(username, defaultRelayRealm, password),
+		stun.Fingerprint,
+	)
+	require.NoError(t, err)
+	return msg.Raw
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/pion/turn/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/FlintyLemming/netbird/management/server"
+)
+
+func TestAddRelayToTURNConfig(t *testing.T) {
+	config := &server.Config{}
+	relayConfig := server.RelayConfig{Port: 3478, PublicIP: "192.0.2.1"}
+
+	err := addRelayToTURNConfig(config, relayConfig)
+	require.NoError(t, err)
+	assert.True(t, config.TURNConfig.TimeBasedCredentials)
+	assert.NotEmpty(t, config.TURNConfig.Secret, "a TURN secret should be generated")
+	assert.Equal(t, defaultCredentialsTTL, config.TURNConfig.CredentialsTTL.Duration)
+	require.Len(t, config.TURNConfig.Turns, 1)
+	assert.Equal(t, "turn:192.0.2.1:3478", config.TURNConfig.Turns[0].URI)
+	assert.Equal(t, server.UDP, config.TURNConfig.Turns[0].Proto)
+
+	secret := config.TURNConfig.Secret
+	err = addRelayToTURNConfig(config, relayConfig)
+	require.NoError(t, err)
+	assert.Equal(t, secret, config.TURNConfig.Secret, "the TURN secret should be kept")
+	assert.Len(t, config.TURNConfig.Turns, 1, "the relay should be added once")
+
+	config = &server.Config{TURNConfig: &server.TURNConfig{
+		Turns: []*server.Host{{Proto: server.UDP, URI: "turn:turn.example.com:3478", Username: "user", Password: "pass"}},
+	}}
+	err = addRelayToTURNConfig(config, relayConfig)
+	assert.Error(t, err, "the relay should require time-based credentials")
+}
+
+func TestStartRelay(t *testing.T) {
+	port := freeUDPPort(t)
+	config := &server.Config{Relay: &server.RelayConfig{Port: port, PublicIP: "127.0.0.1"}}
+
+	relay, err := startRelay(config)
+	require.NoError(t, err)
+	defer relay.Close() //nolint:errcheck
+
+	credentials := server.NewTimeBasedAuthSecretsManager(nil, config.TURNConfig).GenerateCredentials("peer-a")
+	relayConn, err := allocate(t, config.TURNConfig.Turns[0].URI[len("turn:"):], credentials.Username, credentials.Password)
+	require.NoError(t, err, "the relay should accept the credentials of the Management service")
+	assert.Equal(t, "127.0.0.1", relayConn.LocalAddr().(*net.UDPAddr).IP.String())
+	_ = relayConn.Close()
+
+	_, err = allocate(t, config.TURNConfig.Turns[0].URI[len("turn:"):], credentials.Username, "invalid")
+	assert.Error(t, err, "the relay should reject invalid credentials")
+
+	username := strings.Split(credentials.Username, ":")[0]
+	_, err = allocate(t, config.TURNConfig.Turns[0].URI[len("turn:"):], username, server.TURNPassword(config.TURNConfig.Secret, username))
+	assert.Error(t, err, "the relay should reject credentials without peer")
+}
+
+func TestStartRelay_NotConfigured(t *testing.T) {
+	config := &server.Config{}
+
+	relay, err := startRelay(config)
+	require.NoError(t, err)
+	assert.NoError(t, relay.Close())
+	assert.Nil(t, config.TURNConfig, "the TURN config should be left as is")
+}
+
+func allocate(t *testing.T, addr, username, password string) (net.PacketConn, error) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: addr,
+		TURNServerAddr: addr,
+		Conn:           conn,
+		Username:       username,
+		Password:       password,
+		Realm:          defaultRelayRealm,
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	require.NoError(t, client.Listen())
+
+	return client.Allocate()
+}
+
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close() //nolint:errcheck
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
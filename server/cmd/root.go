@@ -0,0 +1,10 @@
+package cmd
+
+import (
+	mgmtCmd "github.com/FlintyLemming/netbird/management/cmd"
+)
+
+// Execute executes the command of the single-binary server
+func Execute() error {
+	return mgmtCmd.NewServerCommand(startRelay).Execute()
+}
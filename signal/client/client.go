@@ -38,6 +38,7 @@ type Client interface {
 	WaitStreamConnected()
 	SendToStream(msg *proto.EncryptedMessage) error
 	Send(msg *proto.Message) error
+	SendGoingOffline(remoteKeys []string) error
 }
 
 // UnMarshalCredential parses the credentials from the message and returns a Credential instance
@@ -12,6 +12,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
@@ -115,6 +116,53 @@ var _ = Describe("GrpcClient", func() {
 		})
 	})
 
+	Describe("Notifying peers on shutdown", func() {
+		Context("between connected peers", func() {
+			It("should deliver the going offline message", func() {
+
+				received := make(chan *sigProto.Message, 1)
+
+				// connect PeerA to Signal
+				keyA, _ := wgtypes.GenerateKey()
+				clientA := createSignalClient(addr, keyA)
+				go func() {
+					err := clientA.Receive(func(msg *sigProto.Message) error {
+						return nil
+					})
+					if err != nil {
+						return
+					}
+				}()
+				clientA.WaitStreamConnected()
+
+				// connect PeerB to Signal
+				keyB, _ := wgtypes.GenerateKey()
+				clientB := createSignalClient(addr, keyB)
+				go func() {
+					err := clientB.Receive(func(msg *sigProto.Message) error {
+						received <- msg
+						return nil
+					})
+					if err != nil {
+						return
+					}
+				}()
+				clientB.WaitStreamConnected()
+
+				err := clientA.SendGoingOffline([]string{keyB.PublicKey().String()})
+				Expect(err).NotTo(HaveOccurred())
+
+				select {
+				case msg := <-received:
+					Expect(msg.GetKey()).To(BeEquivalentTo(keyA.PublicKey().String()))
+					Expect(msg.GetBody().GetType()).To(BeEquivalentTo(sigProto.Body_GOING_OFFLINE))
+				case <-time.After(3 * time.Second):
+					Fail("test timed out on waiting for the going offline message")
+				}
+			})
+		})
+	})
+
 	Describe("Connecting to the Signal stream channel", func() {
 		Context("with a signal client", func() {
 			It("should be successful", func() {
@@ -134,6 +182,35 @@ var _ = Describe("GrpcClient", func() {
 			})
 		})
 
+		Context("with a shared connection", func() {
+			It("should be successful and leave the connection open on close", func() {
+
+				conn, err := grpc.DialContext(context.Background(), addr,
+					grpc.WithTransportCredentials(insecure.NewCredentials()),
+					grpc.WithBlock())
+				if err != nil {
+					Fail("failed creating the shared connection")
+				}
+				defer conn.Close()
+
+				key, _ := wgtypes.GenerateKey()
+				client := NewSharedClient(context.Background(), conn, key)
+				go func() {
+					err := client.Receive(func(msg *sigProto.Message) error {
+						return nil
+					})
+					if err != nil {
+						return
+					}
+				}()
+				client.WaitStreamConnected()
+				Expect(client.StreamConnected()).To(BeTrue())
+
+				Expect(client.Close()).To(Succeed())
+				Expect(conn.GetState()).NotTo(Equal(connectivity.Shutdown))
+			})
+		})
+
 		Context("with a raw client and no Id header", func() {
 			It("should fail", func() {
 
@@ -234,7 +311,11 @@ func startSignal() (*grpc.Server, net.Listener) {
 		panic(err)
 	}
 	s := grpc.NewServer()
-	sigProto.RegisterSignalExchangeServer(s, server.NewServer())
+	signalServer, err := server.NewServer("", server.RateLimitConfig{}, nil)
+	if err != nil {
+		panic(err)
+	}
+	sigProto.RegisterSignalExchangeServer(s, signalServer)
 	go func() {
 		if err := s.Serve(lis); err != nil {
 			log.Fatalf("failed to serve: %v", err)
@@ -19,13 +19,18 @@ import (
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	pb "google.golang.org/protobuf/proto"
 
 	"github.com/FlintyLemming/netbird/encryption"
 	"github.com/FlintyLemming/netbird/signal/proto"
+	"github.com/FlintyLemming/netbird/tracing"
 )
 
 const defaultSendTimeout = 5 * time.Second
 
+// goingOfflineTimeout limits the time spent notifying the remote peers on shutdown
+const goingOfflineTimeout = 2 * time.Second
+
 // ConnStateNotifier is a wrapper interface of the status recorder
 type ConnStateNotifier interface {
 	MarkSignalDisconnected()
@@ -37,6 +42,8 @@ type GrpcClient struct {
 	key        wgtypes.Key
 	realClient proto.SignalExchangeClient
 	signalConn *grpc.ClientConn
+	// sharedConn is set when the connection belongs to another client, e.g. the Management one, and isn't closed
+	sharedConn bool
 	ctx        context.Context
 	stream     proto.SignalExchange_ConnectStreamClient
 	// connectedCh used to notify goroutines waiting for the connection to the Signal stream
@@ -47,6 +54,9 @@ type GrpcClient struct {
 
 	connStateCallback     ConnStateNotifier
 	connStateCallbackLock sync.RWMutex
+
+	// sessions encrypt the messages exchanged with the remote peers with forward secrecy
+	sessions *sessions
 }
 
 func (c *GrpcClient) StreamConnected() bool {
@@ -57,8 +67,11 @@ func (c *GrpcClient) GetStatus() Status {
 	return c.status
 }
 
-// Close Closes underlying connections to the Signal Exchange
+// Close Closes underlying connections to the Signal Exchange, a shared connection is left to its owner
 func (c *GrpcClient) Close() error {
+	if c.sharedConn {
+		return nil
+	}
 	return c.signalConn.Close()
 }
 
@@ -81,7 +94,9 @@ func NewClient(ctx context.Context, addr string, key wgtypes.Key, tlsEnabled boo
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:    30 * time.Second,
 			Timeout: 10 * time.Second,
-		}))
+		}),
+		grpc.WithChainUnaryInterceptor(tracing.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(tracing.StreamClientInterceptor()))
 
 	if err != nil {
 		log.Errorf("failed to connect to the signalling server %v", err)
@@ -90,6 +105,23 @@ func NewClient(ctx context.Context, addr string, key wgtypes.Key, tlsEnabled boo
 
 	log.Debugf("connected to Signal Service: %v", conn.Target())
 
+	return newClient(ctx, conn, key), nil
+}
+
+// NewSharedClient creates a new Signal client over a connection established by another client to a server which also
+// serves the Signal service, e.g. the Management server. The connection isn't closed with the Signal client
+func NewSharedClient(ctx context.Context, conn *grpc.ClientConn, key wgtypes.Key) *GrpcClient {
+	log.Debugf("sharing the connection to %v with the Signal Service", conn.Target())
+
+	client := newClient(ctx, conn, key)
+	client.sharedConn = true
+	return client
+}
+
+func newClient(ctx context.Context, conn *grpc.ClientConn, key wgtypes.Key) *GrpcClient {
+	sessions := newSessions(key)
+	go sessions.expire(ctx)
+
 	return &GrpcClient{
 		realClient:            proto.NewSignalExchangeClient(conn),
 		ctx:                   ctx,
@@ -98,7 +130,8 @@ func NewClient(ctx context.Context, addr string, key wgtypes.Key, tlsEnabled boo
 		mux:                   sync.Mutex{},
 		status:                StreamDisconnected,
 		connStateCallbackLock: sync.RWMutex{},
-	}, nil
+		sessions:              sessions,
+	}
 }
 
 // SetConnStateListener set the ConnStateNotifier
@@ -272,7 +305,8 @@ func (c *GrpcClient) SendToStream(msg *proto.EncryptedMessage) error {
 	return nil
 }
 
-// decryptMessage decrypts the body of the msg using Wireguard private key and Remote peer's public key
+// decryptMessage decrypts the body of the msg using the session keys it was encrypted with, or using Wireguard private
+// key and Remote peer's public key
 func (c *GrpcClient) decryptMessage(msg *proto.EncryptedMessage) (*proto.Message, error) {
 	remoteKey, err := wgtypes.ParseKey(msg.GetKey())
 	if err != nil {
@@ -280,7 +314,14 @@ func (c *GrpcClient) decryptMessage(msg *proto.EncryptedMessage) (*proto.Message
 	}
 
 	body := &proto.Body{}
-	err = encryption.DecryptMessage(remoteKey, c.key, msg.GetBody(), body)
+	if session := msg.GetSession(); session != nil {
+		err = c.decryptSessionBody(msg, remoteKey, session, body)
+	} else {
+		err = encryption.DecryptMessage(remoteKey, c.key, msg.GetBody(), body)
+		if err == nil && len(body.GetEphemeralKey()) == wgtypes.KeyLen {
+			c.sessions.learnRemoteKey(msg.GetKey(), wgtypes.Key(body.GetEphemeralKey()), time.Now())
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -292,7 +333,33 @@ func (c *GrpcClient) decryptMessage(msg *proto.EncryptedMessage) (*proto.Message
 	}, nil
 }
 
-// encryptMessage encrypts the body of the msg using Wireguard private key and Remote peer's public key
+func (c *GrpcClient) decryptSessionBody(msg *proto.EncryptedMessage, remoteKey wgtypes.Key, session *proto.Session, body *proto.Body) error {
+	if len(session.GetSenderKey()) != wgtypes.KeyLen || len(session.GetReceiverKey()) != wgtypes.KeyLen {
+		return fmt.Errorf("invalid session keys")
+	}
+	senderKey := wgtypes.Key(session.GetSenderKey())
+	receiverKey := wgtypes.Key(session.GetReceiverKey())
+
+	now := time.Now()
+	keys, err := c.sessions.receiveKeys(msg.GetKey(), remoteKey, senderKey, receiverKey, now)
+	if err != nil {
+		return err
+	}
+	plaintext, err := keys.Open(session.GetCounter(), msg.GetBody(), sessionAdditionalData(msg.GetKey(), msg.GetRemoteKey()))
+	if err != nil {
+		return err
+	}
+	if err := pb.Unmarshal(plaintext, body); err != nil {
+		return err
+	}
+
+	// the message is authenticated, the sender uses this ephemeral key now
+	c.sessions.learnRemoteKey(msg.GetKey(), senderKey, now)
+	return nil
+}
+
+// encryptMessage encrypts the body of the msg using the session keys when the ephemeral key of the remote peer is
+// known, using Wireguard private key and Remote peer's public key otherwise
 func (c *GrpcClient) encryptMessage(msg *proto.Message) (*proto.EncryptedMessage, error) {
 
 	remoteKey, err := wgtypes.ParseKey(msg.RemoteKey)
@@ -300,18 +367,56 @@ func (c *GrpcClient) encryptMessage(msg *proto.Message) (*proto.EncryptedMessage
 		return nil, err
 	}
 
-	encryptedBody, err := encryption.EncryptMessage(remoteKey, c.key, msg.Body)
+	now := time.Now()
+	localEphemeral, err := c.sessions.localKey(msg.GetRemoteKey(), now)
 	if err != nil {
 		return nil, err
 	}
+	body, ok := pb.Clone(msg.GetBody()).(*proto.Body)
+	if !ok || body == nil {
+		body = &proto.Body{}
+	}
+	body.EphemeralKey = localEphemeral[:]
+
+	keys, id, ok, err := c.sessions.sendKeys(msg.GetRemoteKey(), remoteKey, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		encryptedBody, err := encryption.EncryptMessage(remoteKey, c.key, body)
+		if err != nil {
+			return nil, err
+		}
+		return &proto.EncryptedMessage{
+			Key:       msg.GetKey(),
+			RemoteKey: msg.GetRemoteKey(),
+			Body:      encryptedBody,
+		}, nil
+	}
+
+	plaintext, err := pb.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	counter, encryptedBody := keys.Seal(plaintext, sessionAdditionalData(msg.GetKey(), msg.GetRemoteKey()))
 
 	return &proto.EncryptedMessage{
 		Key:       msg.GetKey(),
 		RemoteKey: msg.GetRemoteKey(),
 		Body:      encryptedBody,
+		Session: &proto.Session{
+			SenderKey:   id.local[:],
+			ReceiverKey: id.remote[:],
+			Counter:     counter,
+		},
 	}, nil
 }
 
+// sessionAdditionalData binds the session encrypted body to the sender and the receiver of the message
+func sessionAdditionalData(key, remoteKey string) []byte {
+	return []byte(key + "/" + remoteKey)
+}
+
 // Send sends a message to the remote Peer through the Signal Exchange.
 func (c *GrpcClient) Send(msg *proto.Message) error {
 
@@ -326,11 +431,14 @@ func (c *GrpcClient) Send(msg *proto.Message) error {
 
 	attemptTimeout := defaultSendTimeout
 
+	// the message is sent in the trace of the exchange it belongs to
+	sendCtx := tracing.Extract(c.ctx, msg.GetBody().GetTraceContext())
+
 	for attempt := 0; attempt < 4; attempt++ {
 		if attempt > 1 {
 			attemptTimeout = time.Duration(attempt) * 5 * time.Second
 		}
-		ctx, cancel := context.WithTimeout(c.ctx, attemptTimeout)
+		ctx, cancel := context.WithTimeout(sendCtx, attemptTimeout)
 
 		_, err = c.realClient.Send(ctx, encryptedMessage)
 
@@ -348,6 +456,41 @@ func (c *GrpcClient) Send(msg *proto.Message) error {
 	return err
 }
 
+// SendGoingOffline notifies the remote peers that this peer is shutting down, so they close their connections to it
+// right away instead of waiting for them to time out. The messages are sent once, without retries, to not delay the
+// shutdown
+func (c *GrpcClient) SendGoingOffline(remoteKeys []string) error {
+	if !c.Ready() {
+		return fmt.Errorf("no connection to signal")
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, goingOfflineTimeout)
+	defer cancel()
+
+	var failed int
+	var lastErr error
+	for _, remoteKey := range remoteKeys {
+		encryptedMessage, err := c.encryptMessage(&proto.Message{
+			Key:       c.key.PublicKey().String(),
+			RemoteKey: remoteKey,
+			Body:      &proto.Body{Type: proto.Body_GOING_OFFLINE},
+		})
+		if err == nil {
+			_, err = c.realClient.Send(ctx, encryptedMessage)
+		}
+		if err != nil {
+			log.Debugf("failed notifying peer %s that we are going offline: %v", remoteKey, err)
+			failed++
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		return fmt.Errorf("failed notifying %d of %d peers: %w", failed, len(remoteKeys), lastErr)
+	}
+	return nil
+}
+
 // receive receives messages from other peers coming through the Signal Exchange
 func (c *GrpcClient) receive(stream proto.SignalExchange_ConnectStreamClient,
 	msgHandler func(msg *proto.Message) error) error {
@@ -372,6 +515,7 @@ func (c *GrpcClient) receive(stream proto.SignalExchange_ConnectStreamClient,
 		decryptedMessage, err := c.decryptMessage(msg)
 		if err != nil {
 			log.Errorf("failed decrypting message of Peer [key: %s] error: [%s]", msg.Key, err.Error())
+			continue
 		}
 
 		err = msgHandler(decryptedMessage)
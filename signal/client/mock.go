@@ -13,6 +13,7 @@ type MockClient struct {
 	ReceiveFunc             func(msgHandler func(msg *proto.Message) error) error
 	SendToStreamFunc        func(msg *proto.EncryptedMessage) error
 	SendFunc                func(msg *proto.Message) error
+	SendGoingOfflineFunc    func(remoteKeys []string) error
 }
 
 func (sm *MockClient) Close() error {
@@ -70,3 +71,10 @@ func (sm *MockClient) Send(msg *proto.Message) error {
 	}
 	return sm.SendFunc(msg)
 }
+
+func (sm *MockClient) SendGoingOffline(remoteKeys []string) error {
+	if sm.SendGoingOfflineFunc == nil {
+		return nil
+	}
+	return sm.SendGoingOfflineFunc(remoteKeys)
+}
@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/encryption"
+)
+
+const (
+	// ephemeralKeyLifetime is the time an ephemeral key encrypts new messages, it is kept for another lifetime to
+	// decrypt the messages encrypted to it meanwhile
+	ephemeralKeyLifetime = 10 * time.Minute
+	// sessionsExpiryInterval is how often the expired ephemeral keys are discarded
+	sessionsExpiryInterval = time.Minute
+)
+
+type ephemeralKey struct {
+	private wgtypes.Key
+	public  wgtypes.Key
+	created time.Time
+}
+
+func newEphemeralKey(now time.Time) (*ephemeralKey, error) {
+	private, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &ephemeralKey{private: private, public: private.PublicKey(), created: now}, nil
+}
+
+type sessionID struct {
+	local  wgtypes.Key
+	remote wgtypes.Key
+}
+
+// peerSession holds the ephemeral keys exchanged with a remote peer
+type peerSession struct {
+	local *ephemeralKey
+	// previous is the local key before the last rotation, the remote peer might still encrypt to it
+	previous *ephemeralKey
+	// remote is the latest ephemeral key of the remote peer, learned at remoteLearned
+	remote        *wgtypes.Key
+	remoteLearned time.Time
+
+	keys map[sessionID]*encryption.SessionKeys
+}
+
+// sessions manages the ephemeral X25519 keys used to encrypt the Signal messages with forward secrecy. A message is
+// encrypted with the session keys once the ephemeral key of the remote peer is known, with the Wireguard keys
+// otherwise, e.g. for the first message to a peer or to a peer that doesn't support sessions. Every message carries
+// the ephemeral key of the sender so the receiver can answer with the session keys. The ephemeral keys are rotated
+// and discarded after two lifetimes, the messages encrypted with them can't be decrypted afterwards.
+type sessions struct {
+	mu        sync.Mutex
+	staticKey wgtypes.Key
+	peers     map[string]*peerSession
+}
+
+func newSessions(staticKey wgtypes.Key) *sessions {
+	return &sessions{
+		staticKey: staticKey,
+		peers:     make(map[string]*peerSession),
+	}
+}
+
+// localKey returns the current ephemeral public key used with the remote peer, rotating it when expired
+func (s *sessions) localKey(remoteKey string, now time.Time) (wgtypes.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessionLocked(remoteKey, now)
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+	return session.local.public, nil
+}
+
+// sendKeys returns the session keys encrypting the messages to the remote peer along with the local and the remote
+// ephemeral public keys, ok is false when the ephemeral key of the remote peer isn't known
+func (s *sessions) sendKeys(remoteKey string, remoteStatic wgtypes.Key, now time.Time) (*encryption.SessionKeys, sessionID, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessionLocked(remoteKey, now)
+	if err != nil {
+		return nil, sessionID{}, false, err
+	}
+	if session.remote == nil {
+		// fall back to the Wireguard keys until the remote peer sends its ephemeral key
+		return nil, sessionID{}, false, nil
+	}
+
+	id := sessionID{local: session.local.public, remote: *session.remote}
+	keys, err := s.keysLocked(session, session.local, *session.remote, remoteStatic)
+	if err != nil {
+		return nil, sessionID{}, false, err
+	}
+	return keys, id, true, nil
+}
+
+// receiveKeys returns the session keys decrypting a message of the remote peer encrypted from its ephemeral key to
+// one of the local ephemeral keys
+func (s *sessions) receiveKeys(remoteKey string, remoteStatic, senderKey, receiverKey wgtypes.Key, now time.Time) (*encryption.SessionKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.peers[remoteKey]
+	if !ok {
+		return nil, fmt.Errorf("no session with peer %s", remoteKey)
+	}
+	s.expireLocked(session, now)
+
+	var local *ephemeralKey
+	switch {
+	case session.local != nil && session.local.public == receiverKey:
+		local = session.local
+	case session.previous != nil && session.previous.public == receiverKey:
+		local = session.previous
+	default:
+		return nil, fmt.Errorf("unknown or expired session key of peer %s", remoteKey)
+	}
+	return s.keysLocked(session, local, senderKey, remoteStatic)
+}
+
+// learnRemoteKey records the ephemeral key of an authenticated message of the remote peer
+func (s *sessions) learnRemoteKey(remoteKey string, ephemeral wgtypes.Key, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.sessionLocked(remoteKey, now)
+	if err != nil {
+		log.Debugf("failed to create the session with peer %s: %v", remoteKey, err)
+		return
+	}
+	session.remote = &ephemeral
+	session.remoteLearned = now
+}
+
+// expire discards the expired ephemeral keys until the context is done
+func (s *sessions) expire(ctx context.Context) {
+	ticker := time.NewTicker(sessionsExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for remoteKey, session := range s.peers {
+				s.expireLocked(session, now)
+				if session.local == nil && session.previous == nil {
+					delete(s.peers, remoteKey)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// sessionLocked returns the session with the remote peer with a valid local key, creating or rotating it as needed
+func (s *sessions) sessionLocked(remoteKey string, now time.Time) (*peerSession, error) {
+	session, ok := s.peers[remoteKey]
+	if !ok {
+		session = &peerSession{keys: make(map[sessionID]*encryption.SessionKeys)}
+		s.peers[remoteKey] = session
+	}
+	s.expireLocked(session, now)
+
+	if session.local == nil || now.Sub(session.local.created) >= ephemeralKeyLifetime {
+		local, err := newEphemeralKey(now)
+		if err != nil {
+			return nil, err
+		}
+		session.previous = session.local
+		session.local = local
+		s.pruneKeysLocked(session)
+	}
+	return session, nil
+}
+
+// expireLocked discards the local keys older than two lifetimes and the remote key not refreshed for a lifetime, which
+// the remote peer might have discarded
+func (s *sessions) expireLocked(session *peerSession, now time.Time) {
+	pruned := false
+	if session.previous != nil && now.Sub(session.previous.created) >= 2*ephemeralKeyLifetime {
+		session.previous = nil
+		pruned = true
+	}
+	if session.local != nil && now.Sub(session.local.created) >= 2*ephemeralKeyLifetime {
+		session.local = nil
+		pruned = true
+	}
+	if session.remote != nil && now.Sub(session.remoteLearned) >= ephemeralKeyLifetime {
+		session.remote = nil
+	}
+	if pruned {
+		s.pruneKeysLocked(session)
+	}
+}
+
+func (s *sessions) keysLocked(session *peerSession, local *ephemeralKey, remote, remoteStatic wgtypes.Key) (*encryption.SessionKeys, error) {
+	id := sessionID{local: local.public, remote: remote}
+	if keys, ok := session.keys[id]; ok {
+		return keys, nil
+	}
+
+	keys, err := encryption.NewSessionKeys(local.private, remote, s.staticKey, remoteStatic)
+	if err != nil {
+		return nil, err
+	}
+	session.keys[id] = keys
+	return keys, nil
+}
+
+// pruneKeysLocked discards the session keys of the discarded local keys. The keys of the previous remote keys are
+// kept along with the local key, so the replayed messages of the remote peer are still detected
+func (s *sessions) pruneKeysLocked(session *peerSession) {
+	for id := range session.keys {
+		localKept := (session.local != nil && id.local == session.local.public) ||
+			(session.previous != nil && id.local == session.previous.public)
+		if !localKept {
+			delete(session.keys, id)
+		}
+	}
+}
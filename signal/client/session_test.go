@@ -0,0 +1,124 @@
+package client
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	"github.com/FlintyLemming/netbird/encryption"
+	sigProto "github.com/FlintyLemming/netbird/signal/proto"
+)
+
+var _ = Describe("Signal sessions", func() {
+
+	var alice, bob *GrpcClient
+
+	newSessionClient := func() *GrpcClient {
+		key, err := wgtypes.GeneratePrivateKey()
+		Expect(err).NotTo(HaveOccurred())
+		return &GrpcClient{key: key, sessions: newSessions(key)}
+	}
+
+	message := func(from, to *GrpcClient, payload string) *sigProto.Message {
+		return &sigProto.Message{
+			Key:       from.key.PublicKey().String(),
+			RemoteKey: to.key.PublicKey().String(),
+			Body:      &sigProto.Body{Type: sigProto.Body_OFFER, Payload: payload},
+		}
+	}
+
+	exchange := func(from, to *GrpcClient, payload string) *sigProto.EncryptedMessage {
+		encrypted, err := from.encryptMessage(message(from, to, payload))
+		Expect(err).NotTo(HaveOccurred())
+		decrypted, err := to.decryptMessage(encrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decrypted.GetBody().GetPayload()).To(Equal(payload))
+		return encrypted
+	}
+
+	BeforeEach(func() {
+		alice = newSessionClient()
+		bob = newSessionClient()
+	})
+
+	Context("exchanging messages", func() {
+		It("should switch from the Wireguard keys to the session keys", func() {
+			first := exchange(alice, bob, "offer")
+			Expect(first.GetSession()).To(BeNil(), "the first message can only be encrypted with the Wireguard keys")
+
+			answer := exchange(bob, alice, "answer")
+			Expect(answer.GetSession()).NotTo(BeNil(), "the answer should be encrypted with the session keys")
+
+			next := exchange(alice, bob, "candidate")
+			Expect(next.GetSession()).NotTo(BeNil(), "the next messages should be encrypted with the session keys")
+		})
+
+		It("should keep the Wireguard keys with peers without session support", func() {
+			// a peer without session support doesn't send its ephemeral key
+			body, err := encryption.EncryptMessage(alice.key.PublicKey(), bob.key, &sigProto.Body{Payload: "offer"})
+			Expect(err).NotTo(HaveOccurred())
+			decrypted, err := alice.decryptMessage(&sigProto.EncryptedMessage{
+				Key:       bob.key.PublicKey().String(),
+				RemoteKey: alice.key.PublicKey().String(),
+				Body:      body,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decrypted.GetBody().GetPayload()).To(Equal("offer"))
+
+			encrypted, err := alice.encryptMessage(message(alice, bob, "answer"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encrypted.GetSession()).To(BeNil())
+		})
+
+		It("should not modify the body of the caller", func() {
+			msg := message(alice, bob, "offer")
+			_, err := alice.encryptMessage(msg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg.GetBody().GetEphemeralKey()).To(BeEmpty())
+		})
+
+		It("should reject the replayed session messages", func() {
+			exchange(alice, bob, "offer")
+			answer := exchange(bob, alice, "answer")
+
+			_, err := alice.decryptMessage(answer)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("rotating the ephemeral keys", func() {
+		It("should not decrypt the messages after the ephemeral keys are discarded", func() {
+			exchange(alice, bob, "offer")
+			answer, err := bob.encryptMessage(message(bob, alice, "answer"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(answer.GetSession()).NotTo(BeNil())
+
+			// the key of the answer is still accepted after one rotation
+			now := time.Now()
+			_, err = alice.sessions.localKey(bob.key.PublicKey().String(), now.Add(ephemeralKeyLifetime))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = alice.sessions.receiveKeys(bob.key.PublicKey().String(), bob.key.PublicKey(),
+				wgtypes.Key(answer.GetSession().GetSenderKey()), wgtypes.Key(answer.GetSession().GetReceiverKey()),
+				now.Add(ephemeralKeyLifetime))
+			Expect(err).NotTo(HaveOccurred())
+
+			// and discarded after the next one
+			_, err = alice.sessions.localKey(bob.key.PublicKey().String(), now.Add(2*ephemeralKeyLifetime))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = alice.decryptMessage(answer)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fall back to the Wireguard keys when the remote key wasn't refreshed", func() {
+			exchange(alice, bob, "offer")
+			exchange(bob, alice, "answer")
+
+			_, _, ok, err := alice.sessions.sendKeys(bob.key.PublicKey().String(), bob.key.PublicKey(),
+				time.Now().Add(ephemeralKeyLifetime))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	prometheus2 "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/exporters/prometheus"
+	metric2 "go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+// serveMetrics exposes the metrics of the Signal service in the Prometheus format under host:port/metrics, it
+// returns the meter creating them
+func serveMetrics(port int) (metric2.Meter, net.Listener, error) {
+	exporter, err := prometheus.New()
+	if err != nil {
+		return nil, nil, err
+	}
+	meter := metric.NewMeterProvider(metric.WithReader(exporter)).Meter("github.com/FlintyLemming/netbird/signal")
+
+	listener, err := net.Listen("tcp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(prometheus2.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	go func() {
+		err := http.Serve(listener, mux)
+		if err != nil {
+			log.Debugf("stopped serving the metrics: %v", err)
+		}
+	}()
+
+	log.Infof("exposing the metrics on http://%s/metrics", listener.Addr().String())
+	return meter, listener, nil
+}
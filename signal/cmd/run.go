@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -17,9 +18,11 @@ import (
 	"github.com/FlintyLemming/netbird/encryption"
 	"github.com/FlintyLemming/netbird/signal/proto"
 	"github.com/FlintyLemming/netbird/signal/server"
+	"github.com/FlintyLemming/netbird/tracing"
 	"github.com/FlintyLemming/netbird/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/metric"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
@@ -28,9 +31,13 @@ import (
 var (
 	signalPort              int
 	signalLetsencryptDomain string
+	signalLetsencryptDNS    string
 	signalSSLDir            string
 	defaultSignalSSLDir     string
 	tlsEnabled              bool
+	presenceToken           string
+	metricsPort             int
+	rateLimits              = server.DefaultRateLimitConfig()
 
 	signalKaep = grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
 		MinTime:             5 * time.Second,
@@ -80,9 +87,39 @@ var (
 				}
 			}
 
-			var opts []grpc.ServerOption
+			tracingConfig, err := tracing.ConfigFromEnv()
+			if err != nil {
+				return fmt.Errorf("failed reading the tracing config: %v", err)
+			}
+			shutdownTracing, err := tracing.Init("netbird-signal", tracingConfig)
+			if err != nil {
+				return fmt.Errorf("failed initializing tracing: %v", err)
+			}
+			defer func() {
+				if err := shutdownTracing(context.Background()); err != nil {
+					log.Warnf("failed flushing the spans: %v", err)
+				}
+			}()
+
+			opts := []grpc.ServerOption{
+				grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor()),
+				grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor()),
+			}
 			var certManager *autocert.Manager
-			if tlsEnabled {
+			if tlsEnabled && signalLetsencryptDNS != "" {
+				// Let's encrypt enabled with the DNS-01 challenge -> no HTTP challenge handler required
+				dnsCertManager, err := encryption.CreateDNSCertManager(signalSSLDir, signalLetsencryptDomain, signalLetsencryptDNS)
+				if err != nil {
+					return err
+				}
+				certCtx, cancelCert := context.WithCancel(context.Background())
+				defer cancelCert()
+				if err := dnsCertManager.Start(certCtx); err != nil {
+					return err
+				}
+				transportCredentials := credentials.NewTLS(dnsCertManager.TLSConfig())
+				opts = append(opts, grpc.Creds(transportCredentials))
+			} else if tlsEnabled {
 				// Let's encrypt enabled -> generate certificate automatically
 				certManager, err = encryption.CreateCertManager(signalSSLDir, signalLetsencryptDomain)
 				if err != nil {
@@ -92,9 +129,23 @@ var (
 				opts = append(opts, grpc.Creds(transportCredentials))
 			}
 
+			var meter metric.Meter
+			var metricsListener net.Listener
+			if metricsPort > 0 {
+				meter, metricsListener, err = serveMetrics(metricsPort)
+				if err != nil {
+					return fmt.Errorf("failed exposing the metrics: %v", err)
+				}
+			}
+
+			signalServer, err := server.NewServer(presenceToken, rateLimits, meter)
+			if err != nil {
+				return err
+			}
+
 			opts = append(opts, signalKaep, signalKasp)
 			grpcServer := grpc.NewServer(opts...)
-			proto.RegisterSignalExchangeServer(grpcServer, server.NewServer())
+			proto.RegisterSignalExchangeServer(grpcServer, signalServer)
 
 			var compatListener net.Listener
 			if signalPort != 10000 {
@@ -109,7 +160,7 @@ var (
 
 			var grpcListener net.Listener
 			var httpListener net.Listener
-			if tlsEnabled {
+			if certManager != nil {
 				httpListener = certManager.Listener()
 				if signalPort == 443 {
 					// running gRPC and HTTP cert manager on the same port
@@ -121,7 +172,7 @@ var (
 				}
 			}
 
-			if signalPort != 443 || !tlsEnabled {
+			if signalPort != 443 || certManager == nil {
 				grpcListener, err = serveGRPC(grpcServer, signalPort)
 				if err != nil {
 					return err
@@ -146,6 +197,9 @@ var (
 				_ = compatListener.Close()
 				log.Infof("stopped gRPC backward compatibility server")
 			}
+			if metricsListener != nil {
+				_ = metricsListener.Close()
+			}
 			log.Infof("stopped Signal Service")
 
 			return nil
@@ -286,4 +340,13 @@ func init() {
 	runCmd.PersistentFlags().IntVar(&signalPort, "port", 80, "Server port to listen on (defaults to 443 if TLS is enabled, 80 otherwise")
 	runCmd.Flags().StringVar(&signalSSLDir, "ssl-dir", defaultSignalSSLDir, "server ssl directory location. *Required only for Let's Encrypt certificates.")
 	runCmd.Flags().StringVar(&signalLetsencryptDomain, "letsencrypt-domain", "", "a domain to issue Let's Encrypt certificate for. Enables TLS using Let's Encrypt. Will fetch and renew certificate, and run the server with TLS")
+	runCmd.Flags().StringVar(&signalLetsencryptDNS, "letsencrypt-dns-provider", "", "a DNS provider to fulfill the Let's Encrypt DNS-01 challenge with instead of the HTTP-01 one, so the server doesn't have to be reachable on port 80 or 443. Supported providers: cloudflare, rfc2136 and exec, configured by their environment variables. Requires letsencrypt-domain")
+	runCmd.Flags().StringVar(&presenceToken, "presence-token", "", "token the Management service uses to look up which peers are connected. Leave empty to disable the lookups")
+	runCmd.Flags().IntVar(&metricsPort, "metrics-port", 9090, "metrics endpoint http port. Metrics are accessible under host:metrics-port/metrics. 0 disables the metrics")
+	runCmd.Flags().Float64Var(&rateLimits.PeerRate, "peer-rate-limit", rateLimits.PeerRate, "messages per second a peer can send on average. 0 disables the limit")
+	runCmd.Flags().IntVar(&rateLimits.PeerBurst, "peer-rate-burst", rateLimits.PeerBurst, "messages a peer can send at once above its rate limit")
+	runCmd.Flags().Float64Var(&rateLimits.IPRate, "ip-rate-limit", rateLimits.IPRate, "messages per second the peers behind an IP address can send altogether on average. 0 disables the limit")
+	runCmd.Flags().IntVar(&rateLimits.IPBurst, "ip-rate-burst", rateLimits.IPBurst, "messages the peers behind an IP address can send at once above their rate limit")
+	runCmd.Flags().IntVar(&rateLimits.BanThreshold, "ban-threshold", rateLimits.BanThreshold, "rate limited messages within a minute after which the peer or the IP address is banned. 0 disables the bans")
+	runCmd.Flags().DurationVar(&rateLimits.BanDuration.Duration, "ban-duration", rateLimits.BanDuration.Duration, "how long a peer or an IP address exceeding the ban threshold is banned")
 }
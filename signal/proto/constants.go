@@ -3,3 +3,4 @@ package proto
 // protocol constants, field names that can be used by both client and server
 const HeaderId = "x-wiretrustee-peer-id"
 const HeaderRegistered = "x-wiretrustee-peer-registered"
+const HeaderPresenceToken = "x-netbird-presence-token"
@@ -22,6 +22,8 @@ type SignalExchangeClient interface {
 	Send(ctx context.Context, in *EncryptedMessage, opts ...grpc.CallOption) (*EncryptedMessage, error)
 	// Connect to the Signal Exchange service offering connection candidates and maintain a channel for receiving candidates from the other party (remote peer)
 	ConnectStream(ctx context.Context, opts ...grpc.CallOption) (SignalExchange_ConnectStreamClient, error)
+	// Returns which of the requested peers are connected to the Signal Exchange service. Requires the presence token
+	GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error)
 }
 
 type signalExchangeClient struct {
@@ -72,6 +74,15 @@ func (x *signalExchangeConnectStreamClient) Recv() (*EncryptedMessage, error) {
 	return m, nil
 }
 
+func (c *signalExchangeClient) GetPresence(ctx context.Context, in *PresenceRequest, opts ...grpc.CallOption) (*PresenceResponse, error) {
+	out := new(PresenceResponse)
+	err := c.cc.Invoke(ctx, "/signalexchange.SignalExchange/GetPresence", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // SignalExchangeServer is the server API for SignalExchange service.
 // All implementations must embed UnimplementedSignalExchangeServer
 // for forward compatibility
@@ -80,6 +91,8 @@ type SignalExchangeServer interface {
 	Send(context.Context, *EncryptedMessage) (*EncryptedMessage, error)
 	// Connect to the Signal Exchange service offering connection candidates and maintain a channel for receiving candidates from the other party (remote peer)
 	ConnectStream(SignalExchange_ConnectStreamServer) error
+	// Returns which of the requested peers are connected to the Signal Exchange service. Requires the presence token
+	GetPresence(context.Context, *PresenceRequest) (*PresenceResponse, error)
 	mustEmbedUnimplementedSignalExchangeServer()
 }
 
@@ -93,6 +106,9 @@ func (UnimplementedSignalExchangeServer) Send(context.Context, *EncryptedMessage
 func (UnimplementedSignalExchangeServer) ConnectStream(SignalExchange_ConnectStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method ConnectStream not implemented")
 }
+func (UnimplementedSignalExchangeServer) GetPresence(context.Context, *PresenceRequest) (*PresenceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPresence not implemented")
+}
 func (UnimplementedSignalExchangeServer) mustEmbedUnimplementedSignalExchangeServer() {}
 
 // UnsafeSignalExchangeServer may be embedded to opt out of forward compatibility for this service.
@@ -150,6 +166,24 @@ func (x *signalExchangeConnectStreamServer) Recv() (*EncryptedMessage, error) {
 	return m, nil
 }
 
+func _SignalExchange_GetPresence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PresenceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SignalExchangeServer).GetPresence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/signalexchange.SignalExchange/GetPresence",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SignalExchangeServer).GetPresence(ctx, req.(*PresenceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // SignalExchange_ServiceDesc is the grpc.ServiceDesc for SignalExchange service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -161,6 +195,10 @@ var SignalExchange_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Send",
 			Handler:    _SignalExchange_Send_Handler,
 		},
+		{
+			MethodName: "GetPresence",
+			Handler:    _SignalExchange_GetPresence_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
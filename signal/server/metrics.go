@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+)
+
+// rateLimitMetrics count the messages rejected by the rate limits and the bans
+type rateLimitMetrics struct {
+	rejectedCounter syncint64.Counter
+	bansCounter     syncint64.Counter
+}
+
+// newRateLimitMetrics registers the rate limit metrics, a nil meter disables them
+func newRateLimitMetrics(meter metric.Meter) (*rateLimitMetrics, error) {
+	if meter == nil {
+		return nil, nil
+	}
+
+	rejectedCounter, err := meter.SyncInt64().Counter("signal.ratelimit.rejected.messages", instrument.WithUnit("1"))
+	if err != nil {
+		return nil, err
+	}
+
+	bansCounter, err := meter.SyncInt64().Counter("signal.ratelimit.bans", instrument.WithUnit("1"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &rateLimitMetrics{
+		rejectedCounter: rejectedCounter,
+		bansCounter:     bansCounter,
+	}, nil
+}
+
+// countRejected counts a message rejected for the reason of the verdict
+func (m *rateLimitMetrics) countRejected(verdict limitVerdict) {
+	if m == nil {
+		return
+	}
+	m.rejectedCounter.Add(context.Background(), 1, attribute.String("reason", verdict.String()))
+}
+
+// countBan counts a ban of a peer or an IP address
+func (m *rateLimitMetrics) countBan(kind string) {
+	if m == nil {
+		return
+	}
+	m.bansCounter.Add(context.Background(), 1, attribute.String("kind", kind))
+}
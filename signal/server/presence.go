@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/signal/proto"
+)
+
+// maxPresenceKeys is the maximum number of peers looked up in a single request
+const maxPresenceKeys = 10000
+
+// GetPresence returns the requested peers that are connected to the Signal service
+func (s *Server) GetPresence(ctx context.Context, req *proto.PresenceRequest) (*proto.PresenceResponse, error) {
+	if s.presenceToken == "" {
+		return nil, status.Errorf(codes.Unimplemented, "presence lookups are disabled")
+	}
+
+	if !s.isPresenceAuthorized(ctx) {
+		return nil, status.Errorf(codes.PermissionDenied, "invalid presence token")
+	}
+
+	if len(req.GetKeys()) > maxPresenceKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "too many keys, the maximum is %d", maxPresenceKeys)
+	}
+
+	resp := &proto.PresenceResponse{}
+	for _, key := range req.GetKeys() {
+		if s.registry.IsPeerRegistered(key) {
+			resp.ConnectedKeys = append(resp.ConnectedKeys, key)
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) isPresenceAuthorized(ctx context.Context) bool {
+	meta, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	tokens := meta.Get(proto.HeaderPresenceToken)
+	if len(tokens) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.presenceToken)) == 1
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/FlintyLemming/netbird/signal/peer"
+	"github.com/FlintyLemming/netbird/signal/proto"
+)
+
+func TestServer_GetPresence(t *testing.T) {
+	s, err := NewServer("token", RateLimitConfig{}, nil)
+	require.NoError(t, err)
+	s.registry.Register(peer.NewPeer("connected", nil))
+
+	req := &proto.PresenceRequest{Keys: []string{"connected", "disconnected"}}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(proto.HeaderPresenceToken, "token"))
+
+	resp, err := s.GetPresence(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"connected"}, resp.GetConnectedKeys())
+
+	invalidCtx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(proto.HeaderPresenceToken, "invalid"))
+	_, err = s.GetPresence(invalidCtx, req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.GetPresence(context.Background(), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "the token should be required")
+
+	disabled, err := NewServer("", RateLimitConfig{}, nil)
+	require.NoError(t, err)
+	_, err = disabled.GetPresence(ctx, req)
+	assert.Equal(t, codes.Unimplemented, status.Code(err), "lookups should be disabled without a token")
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/FlintyLemming/netbird/util"
+)
+
+const (
+	// rateLimitWindow is the period over which the rejected messages are counted to decide a ban
+	rateLimitWindow = time.Minute
+	// rateLimitCleanupInterval is how often the state of the idle peers and IP addresses is dropped
+	rateLimitCleanupInterval = 5 * time.Minute
+)
+
+// RateLimitConfig limits the messages forwarded by the Signal service per peer and per source IP address, protecting
+// it from being used to flood the peers
+type RateLimitConfig struct {
+	// PeerRate is the number of messages per second a peer can send, PeerBurst the number of messages it can send at
+	// once. A PeerRate of 0 disables the limit
+	PeerRate  float64
+	PeerBurst int
+	// IPRate is the number of messages per second the peers behind an IP address can send altogether, IPBurst the
+	// number of messages they can send at once. An IPRate of 0 disables the limit
+	IPRate  float64
+	IPBurst int
+	// BanThreshold is the number of rejected messages within a minute after which the peer or the IP address is
+	// banned for BanDuration. A BanThreshold of 0 disables the bans
+	BanThreshold int
+	BanDuration  util.Duration
+}
+
+// DefaultRateLimitConfig returns limits a well-behaved peer never reaches, the connection negotiation of a peer
+// exchanges a handful of messages with each remote peer
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		PeerRate:     50,
+		PeerBurst:    500,
+		IPRate:       500,
+		IPBurst:      5000,
+		BanThreshold: 1000,
+		BanDuration:  util.Duration{Duration: 10 * time.Minute},
+	}
+}
+
+// limitVerdict is the outcome of the rate limiting of a message
+type limitVerdict int
+
+const (
+	verdictAllowed limitVerdict = iota
+	// verdictPeerLimited and verdictIPLimited reject the message because the sender exceeded its rate
+	verdictPeerLimited
+	verdictIPLimited
+	// verdictBanned rejects the message because the peer or the IP address is banned
+	verdictBanned
+)
+
+// String returns the reason of the verdict reported in the metrics
+func (v limitVerdict) String() string {
+	switch v {
+	case verdictPeerLimited:
+		return "peer"
+	case verdictIPLimited:
+		return "ip"
+	case verdictBanned:
+		return "banned"
+	default:
+		return "allowed"
+	}
+}
+
+// tokenBucket allows rate messages per second on average with bursts of up to burst messages
+type tokenBucket struct {
+	tokens   float64
+	lastFill time.Time
+	// rejected counts the rejected messages of the current window, it decides the bans
+	rejected    int
+	windowStart time.Time
+	// bannedUntil is zero when the sender isn't banned
+	bannedUntil time.Time
+}
+
+// rateLimiter rate limits the messages per peer and per source IP address and bans the senders exceeding their
+// rate persistently
+type rateLimiter struct {
+	config RateLimitConfig
+
+	mu    sync.Mutex
+	peers map[string]*tokenBucket
+	ips   map[string]*tokenBucket
+	// lastCleanup is when the idle buckets were last dropped
+	lastCleanup time.Time
+	// onBan is called without holding the lock when a peer or an IP address gets banned
+	onBan func(kind, id string)
+	now   func() time.Time
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config: config,
+		peers:  make(map[string]*tokenBucket),
+		ips:    make(map[string]*tokenBucket),
+		now:    time.Now,
+	}
+}
+
+// enabled tells whether any limit is configured
+func (l *rateLimiter) enabled() bool {
+	return l != nil && (l.config.PeerRate > 0 || l.config.IPRate > 0)
+}
+
+// isBanned tells whether the peer or the IP address is banned, it is checked when a peer connects
+func (l *rateLimiter) isBanned(peerKey, ip string) bool {
+	if !l.enabled() {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	return l.banned(l.peers[peerKey], now) || l.banned(l.ips[ip], now)
+}
+
+// allow takes a token of the peer and of its IP address, the message is rejected when either has none left
+func (l *rateLimiter) allow(peerKey, ip string) limitVerdict {
+	if !l.enabled() {
+		return verdictAllowed
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	l.cleanup(now)
+
+	var peerBucket, ipBucket *tokenBucket
+	if l.config.PeerRate > 0 {
+		peerBucket = l.bucket(l.peers, peerKey, l.config.PeerBurst, now)
+	}
+	if l.config.IPRate > 0 && ip != "" {
+		ipBucket = l.bucket(l.ips, ip, l.config.IPBurst, now)
+	}
+
+	if l.banned(peerBucket, now) || l.banned(ipBucket, now) {
+		l.mu.Unlock()
+		return verdictBanned
+	}
+
+	verdict := verdictAllowed
+	var bannedKind, bannedID string
+	if peerBucket != nil && !l.take(peerBucket, l.config.PeerRate, l.config.PeerBurst, now) {
+		verdict = verdictPeerLimited
+		if l.reject(peerBucket, now) {
+			bannedKind, bannedID = "peer", peerKey
+		}
+	} else if ipBucket != nil && !l.take(ipBucket, l.config.IPRate, l.config.IPBurst, now) {
+		verdict = verdictIPLimited
+		if l.reject(ipBucket, now) {
+			bannedKind, bannedID = "ip", ip
+		}
+	}
+	onBan := l.onBan
+	l.mu.Unlock()
+
+	if bannedKind != "" && onBan != nil {
+		onBan(bannedKind, bannedID)
+	}
+	return verdict
+}
+
+func (l *rateLimiter) bucket(buckets map[string]*tokenBucket, id string, burst int, now time.Time) *tokenBucket {
+	b, ok := buckets[id]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), lastFill: now, windowStart: now}
+		buckets[id] = b
+	}
+	return b
+}
+
+// take refills the bucket for the time elapsed since the last message and takes a token if there is one left
+func (l *rateLimiter) take(b *tokenBucket, rate float64, burst int, now time.Time) bool {
+	b.tokens += now.Sub(b.lastFill).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reject counts a rejected message and bans the sender when it reached the ban threshold, it returns true when the
+// sender got banned
+func (l *rateLimiter) reject(b *tokenBucket, now time.Time) bool {
+	if l.config.BanThreshold <= 0 {
+		return false
+	}
+
+	if now.Sub(b.windowStart) > rateLimitWindow {
+		b.windowStart = now
+		b.rejected = 0
+	}
+	b.rejected++
+	if b.rejected < l.config.BanThreshold {
+		return false
+	}
+
+	b.rejected = 0
+	b.bannedUntil = now.Add(l.config.BanDuration.Duration)
+	return true
+}
+
+func (l *rateLimiter) banned(b *tokenBucket, now time.Time) bool {
+	return b != nil && now.Before(b.bannedUntil)
+}
+
+// cleanup drops the buckets which are full again and aren't banned, they are recreated full on the next message
+func (l *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < rateLimitCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+
+	drop := func(buckets map[string]*tokenBucket, rate float64, burst int) {
+		for id, b := range buckets {
+			refilled := b.tokens + now.Sub(b.lastFill).Seconds()*rate
+			if refilled >= float64(burst) && !l.banned(b, now) {
+				delete(buckets, id)
+			}
+		}
+	}
+	drop(l.peers, l.config.PeerRate, l.config.PeerBurst)
+	drop(l.ips, l.config.IPRate, l.config.IPBurst)
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/FlintyLemming/netbird/util"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(RateLimitConfig{
+		PeerRate:     1,
+		PeerBurst:    2,
+		IPRate:       10,
+		IPBurst:      2,
+		BanThreshold: 2,
+		BanDuration:  util.Duration{Duration: time.Minute},
+	})
+	limiter.now = func() time.Time { return now }
+	var banned []string
+	limiter.onBan = func(kind, id string) { banned = append(banned, kind+" "+id) }
+
+	assert.Equal(t, verdictAllowed, limiter.allow("a", "192.0.2.1"))
+	assert.Equal(t, verdictAllowed, limiter.allow("a", "192.0.2.1"))
+	assert.Equal(t, verdictPeerLimited, limiter.allow("a", "192.0.2.1"), "the peer burst should be exhausted")
+
+	assert.Equal(t, verdictIPLimited, limiter.allow("b", "192.0.2.1"), "the IP address burst should be exhausted")
+	assert.Equal(t, verdictAllowed, limiter.allow("b", "192.0.2.2"), "other IP addresses shouldn't be limited")
+
+	now = now.Add(time.Second)
+	assert.Equal(t, verdictAllowed, limiter.allow("a", "192.0.2.3"), "the peer bucket should refill")
+
+	assert.Equal(t, verdictPeerLimited, limiter.allow("a", "192.0.2.3"))
+	assert.Equal(t, []string{"peer a"}, banned, "the peer should be banned at the threshold")
+	assert.Equal(t, verdictBanned, limiter.allow("a", "192.0.2.3"))
+	assert.True(t, limiter.isBanned("a", "192.0.2.4"))
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, limiter.isBanned("a", "192.0.2.4"), "the ban should expire")
+	assert.Equal(t, verdictAllowed, limiter.allow("a", "192.0.2.3"))
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{})
+	for i := 0; i < 1000; i++ {
+		assert.Equal(t, verdictAllowed, limiter.allow("a", "192.0.2.1"))
+	}
+}
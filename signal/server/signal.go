@@ -6,23 +6,59 @@ import (
 	"github.com/FlintyLemming/netbird/signal/peer"
 	"github.com/FlintyLemming/netbird/signal/proto"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	grpcPeer "google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"io"
+	"net"
 )
 
 // Server an instance of a Signal server
 type Server struct {
 	registry *peer.Registry
+	// presenceToken authorizes the presence lookups, an empty token disables them
+	presenceToken string
+	// limiter rate limits the forwarded messages per peer and per source IP address
+	limiter *rateLimiter
+	metrics *rateLimitMetrics
 	proto.UnimplementedSignalExchangeServer
 }
 
-// NewServer creates a new Signal server
-func NewServer() *Server {
+// NewServer creates a new Signal server. The presence of the peers can be looked up with the presenceToken,
+// an empty token disables the lookups. The forwarded messages are limited by rateLimits, the rejected messages
+// and the bans are counted with the meter when it isn't nil
+func NewServer(presenceToken string, rateLimits RateLimitConfig, meter metric.Meter) (*Server, error) {
+	metrics, err := newRateLimitMetrics(meter)
+	if err != nil {
+		return nil, fmt.Errorf("register the rate limit metrics: %w", err)
+	}
+
+	limiter := newRateLimiter(rateLimits)
+	limiter.onBan = func(kind, id string) {
+		log.Warnf("banned %s %s for %s, it exceeded its message rate %d times within a minute",
+			kind, id, rateLimits.BanDuration, rateLimits.BanThreshold)
+		metrics.countBan(kind)
+	}
+
 	return &Server{
-		registry: peer.NewRegistry(),
+		registry:      peer.NewRegistry(),
+		presenceToken: presenceToken,
+		limiter:       limiter,
+		metrics:       metrics,
+	}, nil
+}
+
+// allowMessage rate limits a message sent by the peer, the rejected messages are counted
+func (s *Server) allowMessage(ctx context.Context, peerKey string) limitVerdict {
+	verdict := s.limiter.allow(peerKey, remoteIP(ctx))
+	if verdict != verdictAllowed {
+		s.metrics.countRejected(verdict)
 	}
+	return verdict
 }
 
 // Send forwards a message to the signal peer
@@ -32,15 +68,27 @@ func (s *Server) Send(ctx context.Context, msg *proto.EncryptedMessage) (*proto.
 		return nil, fmt.Errorf("peer %s is not registered", msg.Key)
 	}
 
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("peer.key", msg.Key), attribute.String("peer.remote_key", msg.RemoteKey))
+
+	if verdict := s.allowMessage(ctx, msg.Key); verdict != verdictAllowed {
+		span.AddEvent("rate limited")
+		return nil, status.Errorf(codes.ResourceExhausted, "message rate limit exceeded (%s)", verdict)
+	}
+
 	if dstPeer, found := s.registry.Get(msg.RemoteKey); found {
 		//forward the message to the target peer
 		err := dstPeer.Stream.Send(msg)
 		if err != nil {
 			log.Errorf("error while forwarding message from peer [%s] to peer [%s] %v", msg.Key, msg.RemoteKey, err)
+			span.RecordError(err)
 			//todo respond to the sender?
+		} else {
+			span.AddEvent("forwarded")
 		}
 	} else {
 		log.Debugf("message from peer [%s] can't be forwarded to peer [%s] because destination peer is not connected", msg.Key, msg.RemoteKey)
+		span.AddEvent("destination peer not connected")
 		//todo respond to the sender?
 	}
 	return &proto.EncryptedMessage{}, nil
@@ -77,6 +125,16 @@ func (s *Server) ConnectStream(stream proto.SignalExchange_ConnectStreamServer)
 			return err
 		}
 		log.Debugf("received a new message from peer [%s] to peer [%s]", p.Id, msg.RemoteKey)
+
+		switch s.allowMessage(stream.Context(), p.Id) {
+		case verdictAllowed:
+		case verdictBanned:
+			return status.Errorf(codes.ResourceExhausted, "peer is banned for exceeding the message rate limit")
+		default:
+			log.Debugf("dropped a message from peer [%s] to peer [%s] exceeding the rate limit", p.Id, msg.RemoteKey)
+			continue
+		}
+
 		// lookup the target peer where the message is going to
 		if dstPeer, found := s.registry.Get(msg.RemoteKey); found {
 			//forward the message to the target peer
@@ -100,6 +158,9 @@ func (s *Server) ConnectStream(stream proto.SignalExchange_ConnectStreamServer)
 func (s Server) connectPeer(stream proto.SignalExchange_ConnectStreamServer) (*peer.Peer, error) {
 	if meta, hasMeta := metadata.FromIncomingContext(stream.Context()); hasMeta {
 		if id, found := meta[proto.HeaderId]; found {
+			if s.limiter.isBanned(id[0], remoteIP(stream.Context())) {
+				return nil, status.Errorf(codes.ResourceExhausted, "peer is banned for exceeding the message rate limit")
+			}
 			p := peer.NewPeer(id[0], stream)
 			s.registry.Register(p)
 			return p, nil
@@ -110,3 +171,16 @@ func (s Server) connectPeer(stream proto.SignalExchange_ConnectStreamServer) (*p
 		return nil, status.Errorf(codes.FailedPrecondition, "missing connection stream meta")
 	}
 }
+
+// remoteIP returns the IP address of the client of the request, it is empty when unknown
+func remoteIP(ctx context.Context) string {
+	p, ok := grpcPeer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
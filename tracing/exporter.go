@@ -0,0 +1,323 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const exportTimeout = 10 * time.Second
+
+// newExporter creates the exporter of the config
+func newExporter(config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterOTLP:
+		endpoint := config.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultOTLPEndpoint
+		}
+		return &otlpExporter{
+			endpoint: endpoint,
+			headers:  config.Headers,
+			client:   &http.Client{Timeout: exportTimeout},
+		}, nil
+	case ExporterFile:
+		if config.Endpoint == "" {
+			return nil, fmt.Errorf("the file exporter requires the path of the file as endpoint")
+		}
+		file, err := os.OpenFile(config.Endpoint, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed opening the traces file: %v", err)
+		}
+		return &fileExporter{file: file}, nil
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter %q, expected %s, %s or %s",
+			config.Exporter, ExporterOTLP, ExporterFile, ExporterNone)
+	}
+}
+
+// otlpExporter sends the spans to an OpenTelemetry collector with OTLP/HTTP, encoded as JSON
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// ExportSpans sends a batch of spans to the collector
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	body, err := json.Marshal(toOTLP(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed sending spans to %s: %v", e.endpoint, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed sending spans to %s: %s %s", e.endpoint, resp.Status, msg)
+	}
+	return nil
+}
+
+// Shutdown releases the connections to the collector
+func (e *otlpExporter) Shutdown(context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// fileExporter appends the spans to a file, a batch per line
+type fileExporter struct {
+	file *os.File
+}
+
+// ExportSpans appends a batch of spans to the file
+func (e *fileExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	line, err := json.Marshal(toOTLP(spans))
+	if err != nil {
+		return err
+	}
+	_, err = e.file.Write(append(line, '\n'))
+	return err
+}
+
+// Shutdown closes the file
+func (e *fileExporter) Shutdown(context.Context) error {
+	return e.file.Close()
+}
+
+// OTLP JSON encoding of the spans, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding. The trace and
+// span IDs are hex encoded and the 64-bit integers are strings
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+	SchemaURL  string            `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope     otlpScope  `json:"scope"`
+	Spans     []otlpSpan `json:"spans"`
+	SchemaURL string     `json:"schemaUrl,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Links             []otlpLink     `json:"links,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID    string         `json:"traceId"`
+	SpanID     string         `json:"spanId"`
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+// otlpStatus codes differ from the codes of the API: unset is 0, ok 1 and error 2
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// toOTLP groups the spans by resource and instrumentation scope
+func toOTLP(spans []sdktrace.ReadOnlySpan) *otlpTraces {
+	traces := &otlpTraces{}
+	resources := make(map[*resource.Resource]*otlpResourceSpans)
+	scopes := make(map[*resource.Resource]map[instrumentation.Scope]*otlpScopeSpans)
+
+	for _, span := range spans {
+		res := span.Resource()
+		resourceSpans, ok := resources[res]
+		if !ok {
+			resourceSpans = &otlpResourceSpans{
+				Resource:  otlpResource{Attributes: toOTLPAttributes(res.Attributes())},
+				SchemaURL: res.SchemaURL(),
+			}
+			resources[res] = resourceSpans
+			scopes[res] = make(map[instrumentation.Scope]*otlpScopeSpans)
+			traces.ResourceSpans = append(traces.ResourceSpans, resourceSpans)
+		}
+
+		scope := span.InstrumentationScope()
+		scopeSpans, ok := scopes[res][scope]
+		if !ok {
+			scopeSpans = &otlpScopeSpans{
+				Scope:     otlpScope{Name: scope.Name, Version: scope.Version},
+				SchemaURL: scope.SchemaURL,
+			}
+			scopes[res][scope] = scopeSpans
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+
+		scopeSpans.Spans = append(scopeSpans.Spans, toOTLPSpan(span))
+	}
+
+	return traces
+}
+
+func toOTLPSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	spanContext := span.SpanContext()
+	converted := otlpSpan{
+		TraceID:           spanContext.TraceID().String(),
+		SpanID:            spanContext.SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: unixNano(span.StartTime()),
+		EndTimeUnixNano:   unixNano(span.EndTime()),
+		Attributes:        toOTLPAttributes(span.Attributes()),
+		Status:            otlpStatus{Message: span.Status().Description},
+	}
+	if parent := span.Parent(); parent.HasSpanID() {
+		converted.ParentSpanID = parent.SpanID().String()
+	}
+
+	switch span.Status().Code {
+	case codes.Ok:
+		converted.Status.Code = 1
+	case codes.Error:
+		converted.Status.Code = 2
+	}
+
+	for _, event := range span.Events() {
+		converted.Events = append(converted.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   toOTLPAttributes(event.Attributes),
+		})
+	}
+
+	for _, link := range span.Links() {
+		converted.Links = append(converted.Links, otlpLink{
+			TraceID:    link.SpanContext.TraceID().String(),
+			SpanID:     link.SpanContext.SpanID().String(),
+			Attributes: toOTLPAttributes(link.Attributes),
+		})
+	}
+
+	return converted
+}
+
+func toOTLPAttributes(attributes []attribute.KeyValue) []otlpKeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	converted := make([]otlpKeyValue, 0, len(attributes))
+	for _, kv := range attributes {
+		converted = append(converted, otlpKeyValue{Key: string(kv.Key), Value: toOTLPValue(kv.Value)})
+	}
+	return converted
+}
+
+func toOTLPValue(value attribute.Value) otlpAnyValue {
+	switch value.Type() {
+	case attribute.BOOL:
+		v := value.AsBool()
+		return otlpAnyValue{BoolValue: &v}
+	case attribute.INT64:
+		v := strconv.FormatInt(value.AsInt64(), 10)
+		return otlpAnyValue{IntValue: &v}
+	case attribute.FLOAT64:
+		v := value.AsFloat64()
+		return otlpAnyValue{DoubleValue: &v}
+	case attribute.BOOLSLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, v := range value.AsBoolSlice() {
+			values = append(values, toOTLPValue(attribute.BoolValue(v)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.INT64SLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, v := range value.AsInt64Slice() {
+			values = append(values, toOTLPValue(attribute.Int64Value(v)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.FLOAT64SLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, v := range value.AsFloat64Slice() {
+			values = append(values, toOTLPValue(attribute.Float64Value(v)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.STRINGSLICE:
+		values := make([]otlpAnyValue, 0)
+		for _, v := range value.AsStringSlice() {
+			values = append(values, toOTLPValue(attribute.StringValue(v)))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		v := value.Emit()
+		return otlpAnyValue{StringValue: &v}
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,173 @@
+// Package tracing traces the requests across the client, Signal and Management services with OpenTelemetry. The
+// spans are propagated between the services in the gRPC metadata and between the peers in the Signal messages
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/FlintyLemming/netbird/version"
+)
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone = "none"
+	// ExporterOTLP sends the spans to an OpenTelemetry collector with OTLP over HTTP, encoded as JSON
+	ExporterOTLP = "otlp"
+	// ExporterFile appends the spans to a file, one OTLP JSON document per line
+	ExporterFile = "file"
+
+	// DefaultOTLPEndpoint is the traces endpoint of a local OpenTelemetry collector
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+	instrumentationName = "github.com/FlintyLemming/netbird/tracing"
+)
+
+// Config of the export of the spans
+type Config struct {
+	// Exporter of the spans, ExporterOTLP or ExporterFile. Tracing is disabled when empty or ExporterNone
+	Exporter string
+	// Endpoint is the URL of the OTLP/HTTP traces endpoint with ExporterOTLP, DefaultOTLPEndpoint when empty, and the
+	// path of the file with ExporterFile
+	Endpoint string
+	// Headers are added to the OTLP/HTTP requests, e.g. to authenticate to the collector
+	Headers map[string]string
+	// SampleRatio is the ratio of the traces started by the service which are exported, 1 when 0. The traces started
+	// by another service follow the sampling decision of that service
+	SampleRatio float64
+}
+
+// Enabled returns true if the spans are exported
+func (c Config) Enabled() bool {
+	return c.Exporter != "" && c.Exporter != ExporterNone
+}
+
+// ConfigFromEnv reads the config from the standard OpenTelemetry environment variables: OTEL_TRACES_EXPORTER (otlp,
+// file or none), OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS and
+// OTEL_TRACES_SAMPLER_ARG. The path of the file exporter is read from NB_TRACES_FILE
+func ConfigFromEnv() (Config, error) {
+	config := Config{Exporter: os.Getenv("OTEL_TRACES_EXPORTER")}
+
+	switch config.Exporter {
+	case ExporterOTLP:
+		config.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+		if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); config.Endpoint == "" && endpoint != "" {
+			// the signal path is appended to the base endpoint shared by all signals
+			config.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+		}
+	case ExporterFile:
+		config.Endpoint = os.Getenv("NB_TRACES_FILE")
+	}
+
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	if headers != "" {
+		config.Headers = make(map[string]string)
+		for _, header := range strings.Split(headers, ",") {
+			key, value, found := strings.Cut(header, "=")
+			if !found {
+				return Config{}, fmt.Errorf("invalid OTLP header %q, expected key=value", header)
+			}
+			value, err := url.QueryUnescape(strings.TrimSpace(value))
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid OTLP header %q: %v", header, err)
+			}
+			config.Headers[strings.TrimSpace(key)] = value
+		}
+	}
+
+	if ratio := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); ratio != "" {
+		parsed, err := strconv.ParseFloat(ratio, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid sample ratio %q: %v", ratio, err)
+		}
+		config.SampleRatio = parsed
+	}
+
+	return config, nil
+}
+
+// Init installs the global tracer provider exporting the spans of the service as configured and the W3C trace context
+// propagator. It returns a function flushing the pending spans and stopping the export. Nothing is installed when
+// tracing is disabled, the spans are then neither recorded nor propagated
+func Init(serviceName string, config Config) (func(context.Context) error, error) {
+	if !config.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid sample ratio %v, expected a value between 0 and 1", config.SampleRatio)
+	}
+	ratio := config.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	exporter, err := newExporter(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(version.NetbirdVersion()),
+		)),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Inject returns the trace context of the span in the context, to be carried in a message to another service or peer.
+// It returns nil when tracing is disabled or the context has no span
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns a copy of the context with the remote span of the trace context carried in a message, the spans
+// started with the returned context continue the trace of the sender. The context is returned as is without a trace
+// context
+func Extract(ctx context.Context, traceContext map[string]string) context.Context {
+	if len(traceContext) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(traceContext))
+}
+
+// End ends the span, marking it as failed with the error when not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracer returns the tracer of the package from the current global tracer provider
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
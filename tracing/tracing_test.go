@@ -0,0 +1,378 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tt := []struct {
+		name        string
+		env         map[string]string
+		expected    Config
+		expectedErr bool
+	}{
+		{
+			name:     "Disabled",
+			env:      map[string]string{},
+			expected: Config{},
+		},
+		{
+			name: "OTLP Traces Endpoint",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":               "otlp",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://collector:4318/custom",
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://other:4318",
+				"OTEL_TRACES_SAMPLER_ARG":            "0.25",
+			},
+			expected: Config{Exporter: ExporterOTLP, Endpoint: "https://collector:4318/custom", SampleRatio: 0.25},
+		},
+		{
+			name: "OTLP Base Endpoint With Headers",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":        "otlp",
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "https://collector:4318/",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "Authorization=Bearer%20token, X-Tenant=netbird",
+			},
+			expected: Config{
+				Exporter: ExporterOTLP,
+				Endpoint: "https://collector:4318/v1/traces",
+				Headers:  map[string]string{"Authorization": "Bearer token", "X-Tenant": "netbird"},
+			},
+		},
+		{
+			name: "File",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER": "file",
+				"NB_TRACES_FILE":       "/var/log/netbird/traces.json",
+			},
+			expected: Config{Exporter: ExporterFile, Endpoint: "/var/log/netbird/traces.json"},
+		},
+		{
+			name: "Invalid Header",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":       "otlp",
+				"OTEL_EXPORTER_OTLP_HEADERS": "Authorization",
+			},
+			expectedErr: true,
+		},
+		{
+			name: "Invalid Sample Ratio",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":    "otlp",
+				"OTEL_TRACES_SAMPLER_ARG": "all",
+			},
+			expectedErr: true,
+		},
+	}
+
+	variables := []string{"OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_TRACES_HEADERS", "OTEL_EXPORTER_OTLP_HEADERS", "OTEL_TRACES_SAMPLER_ARG", "NB_TRACES_FILE"}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, variable := range variables {
+				t.Setenv(variable, tc.env[variable])
+			}
+
+			config, err := ConfigFromEnv()
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, config)
+		})
+	}
+}
+
+func TestInit_Disabled(t *testing.T) {
+	shutdown, err := Init("netbird-test", Config{Exporter: ExporterNone})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Init("netbird-test", Config{Exporter: "jaeger"})
+	assert.Error(t, err, "unsupported exporter")
+}
+
+// recordSpans creates a parent span with a failed child span and exports them with the exporter
+func recordSpans(t *testing.T, exporter sdktrace.SpanExporter) {
+	t.Helper()
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceNameKey.String("netbird-test"))),
+	)
+	tracer := provider.Tracer("netbird-test-scope")
+
+	ctx, parent := tracer.Start(context.Background(), "parent", trace.WithAttributes(
+		attribute.String("peer.key", "key"),
+		attribute.Int("account.peers", 3),
+	))
+	_, child := tracer.Start(ctx, "child", trace.WithSpanKind(trace.SpanKindClient))
+	child.AddEvent("offer sent")
+	End(child, errors.New("connection timeout"))
+	parent.End()
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+}
+
+// assertSpans checks the OTLP JSON document of the spans recorded by recordSpans
+func assertSpans(t *testing.T, traces []otlpTraces) {
+	t.Helper()
+
+	spans := make(map[string]otlpSpan)
+	for _, batch := range traces {
+		require.Len(t, batch.ResourceSpans, 1)
+		resourceSpans := batch.ResourceSpans[0]
+		require.Len(t, resourceSpans.Resource.Attributes, 1)
+		assert.Equal(t, "service.name", resourceSpans.Resource.Attributes[0].Key)
+		assert.Equal(t, "netbird-test", *resourceSpans.Resource.Attributes[0].Value.StringValue)
+
+		require.Len(t, resourceSpans.ScopeSpans, 1)
+		assert.Equal(t, "netbird-test-scope", resourceSpans.ScopeSpans[0].Scope.Name)
+		for _, span := range resourceSpans.ScopeSpans[0].Spans {
+			spans[span.Name] = span
+		}
+	}
+	require.Len(t, spans, 2)
+
+	parent, child := spans["parent"], spans["child"]
+	assert.Len(t, parent.TraceID, 32)
+	assert.Len(t, parent.SpanID, 16)
+	assert.Empty(t, parent.ParentSpanID)
+	assert.Equal(t, parent.TraceID, child.TraceID)
+	assert.Equal(t, parent.SpanID, child.ParentSpanID)
+
+	assert.Equal(t, int(trace.SpanKindInternal), parent.Kind)
+	assert.Equal(t, int(trace.SpanKindClient), child.Kind)
+	assert.Equal(t, otlpStatus{}, parent.Status)
+	assert.Equal(t, otlpStatus{Code: 2, Message: "connection timeout"}, child.Status)
+
+	require.Len(t, parent.Attributes, 2)
+	assert.Equal(t, "key", *parent.Attributes[0].Value.StringValue)
+	assert.Equal(t, "3", *parent.Attributes[1].Value.IntValue)
+
+	require.Len(t, child.Events, 2)
+	assert.Equal(t, "offer sent", child.Events[0].Name)
+	assert.Equal(t, "exception", child.Events[1].Name)
+	assert.NotEmpty(t, child.StartTimeUnixNano)
+	assert.NotEmpty(t, child.EndTimeUnixNano)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var traces []otlpTraces
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var batch otlpTraces
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		traces = append(traces, batch)
+	}))
+	defer collector.Close()
+
+	exporter, err := newExporter(Config{
+		Exporter: ExporterOTLP,
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	require.NoError(t, err)
+
+	recordSpans(t, exporter)
+	assertSpans(t, traces)
+}
+
+func TestOTLPExporter_Rejected(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer collector.Close()
+
+	exporter, err := newExporter(Config{Exporter: ExporterOTLP, Endpoint: collector.URL})
+	require.NoError(t, err)
+
+	provider := sdktrace.NewTracerProvider()
+	_, span := provider.Tracer("netbird-test").Start(context.Background(), "span")
+	span.End()
+	readOnly, ok := span.(sdktrace.ReadOnlySpan)
+	require.True(t, ok)
+
+	err = exporter.ExportSpans(context.Background(), []sdktrace.ReadOnlySpan{readOnly})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid token")
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	exporter, err := newExporter(Config{Exporter: ExporterFile, Endpoint: path})
+	require.NoError(t, err)
+	recordSpans(t, exporter)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var traces []otlpTraces
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var batch otlpTraces
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &batch))
+		traces = append(traces, batch)
+	}
+	require.NoError(t, scanner.Err())
+	// the syncer exports every span as it ends
+	assert.Len(t, traces, 2)
+	assertSpans(t, traces)
+
+	_, err = newExporter(Config{Exporter: ExporterFile})
+	assert.Error(t, err, "path required")
+}
+
+func TestInjectExtract(t *testing.T) {
+	installTestProvider(t)
+
+	assert.Nil(t, Inject(context.Background()), "no span in the context")
+	assert.Equal(t, context.Background(), Extract(context.Background(), nil))
+
+	ctx, span := otel.Tracer("netbird-test").Start(context.Background(), "offer")
+	defer span.End()
+
+	traceContext := Inject(ctx)
+	require.Contains(t, traceContext, "traceparent")
+
+	remote := trace.SpanContextFromContext(Extract(context.Background(), traceContext))
+	assert.True(t, remote.IsRemote())
+	assert.Equal(t, span.SpanContext().TraceID(), remote.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), remote.SpanID())
+}
+
+// healthServer records the span context of the streams
+type healthServer struct {
+	*health.Server
+	streamSpan chan trace.SpanContext
+}
+
+func (s *healthServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.streamSpan <- trace.SpanContextFromContext(stream.Context())
+	return nil
+}
+
+func TestInterceptors(t *testing.T) {
+	recorder := installTestProvider(t)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor()),
+	)
+	service := &healthServer{Server: health.NewServer(), streamSpan: make(chan trace.SpanContext, 1)}
+	healthpb.RegisterHealthServer(server, service)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(StreamClientInterceptor()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, root := otel.Tracer("netbird-test").Start(context.Background(), "login")
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+	require.Error(t, err)
+	root.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5, "a client and a server span per call and the root span")
+
+	var clientSpans, serverSpans []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID(), span.Name())
+		switch span.SpanKind() {
+		case trace.SpanKindClient:
+			clientSpans = append(clientSpans, span)
+		case trace.SpanKindServer:
+			serverSpans = append(serverSpans, span)
+		}
+	}
+	require.Len(t, clientSpans, 2)
+	require.Len(t, serverSpans, 2)
+
+	for i := range clientSpans {
+		assert.Equal(t, "/grpc.health.v1.Health/Check", clientSpans[i].Name())
+		assert.Equal(t, root.SpanContext().SpanID(), clientSpans[i].Parent().SpanID())
+		assert.Equal(t, clientSpans[i].SpanContext().SpanID(), serverSpans[i].Parent().SpanID())
+		assert.True(t, serverSpans[i].Parent().IsRemote())
+		assert.Contains(t, serverSpans[i].Attributes(), semconv.RPCServiceKey.String("grpc.health.v1.Health"))
+		assert.Contains(t, serverSpans[i].Attributes(), semconv.RPCMethodKey.String("Check"))
+	}
+	assert.Contains(t, serverSpans[0].Attributes(), semconv.RPCGRPCStatusCodeKey.Int(0))
+	assert.Contains(t, serverSpans[1].Attributes(), semconv.RPCGRPCStatusCodeKey.Int(5))
+	assert.Equal(t, "unknown service", serverSpans[1].Status().Description)
+
+	ctx, stream := otel.Tracer("netbird-test").Start(context.Background(), "sync")
+	defer stream.End()
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = watch.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+
+	streamSpan := <-service.streamSpan
+	assert.True(t, streamSpan.IsRemote())
+	assert.Equal(t, stream.SpanContext().SpanID(), streamSpan.SpanID(), "the stream continues the span opening it")
+}
+
+// installTestProvider installs a global tracer provider recording the spans in memory for the duration of the test
+func installTestProvider(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}